@@ -0,0 +1,134 @@
+//! Sous-commandes de la ligne de commande
+//!
+//! ```text
+//! pmomusic config dump [--effective]
+//! pmomusic config export <fichier>
+//! pmomusic config import <fichier> [--dry-run]
+//! pmomusic wake --renderer <nom|UDN>
+//! pmomusic wake --mac <adresse>
+//! pmomusic doctor [--wait <secondes>]
+//! pmomusic service install|uninstall|print
+//! ```
+//!
+//! `wake` envoie un paquet Wake-on-LAN à un renderer en veille, d'après les
+//! adresses MAC de `host.control_point.wol` (fixées ou apprises par le
+//! serveur à la découverte).
+//!
+//! `doctor` cherche pourquoi le serveur n'est pas découvert : il envoie un
+//! M-SEARCH, attend les réponses de ses propres devices (le serveur doit donc
+//! tourner), vérifie que leurs URL `LOCATION` sont joignables depuis les
+//! autres interfaces et passe en revue les pièges multicast et pare-feu.
+//!
+//! `service` installe PMOMusic en service d'arrière-plan (voir
+//! [`crate::service`]).
+//!
+//! Sans sous-commande, PMOMusic démarre normalement.
+
+use std::path::Path;
+use std::time::Duration;
+
+use pmocontrol::ControlPointConfigExt;
+use pmocontrol::wol::{self, MacAddress};
+use pmoserver::DiagnosticStatus;
+
+const CONFIG_USAGE: &str = "\
+usage: pmomusic config dump [--effective]
+       pmomusic config export <file>
+       pmomusic config import <file> [--dry-run]";
+
+const WAKE_USAGE: &str = "\
+usage: pmomusic wake --renderer <name|udn>
+       pmomusic wake --mac <address>";
+
+const DOCTOR_USAGE: &str = "usage: pmomusic doctor [--wait <seconds>]";
+
+/// Attente par défaut des réponses au M-SEARCH (MX = 2)
+const DOCTOR_WAIT: Duration = Duration::from_secs(4);
+
+/// Exécute la sous-commande demandée, `None` s'il faut démarrer le serveur
+pub fn run(args: &[String]) -> Option<Result<(), Box<dyn std::error::Error>>> {
+    match args.first().map(String::as_str) {
+        Some("config") => Some(config_command(&args[1..])),
+        Some("wake") => Some(wake_command(&args[1..])),
+        Some("doctor") => Some(doctor_command(&args[1..])),
+        Some("service") => Some(crate::service::command(&args[1..])),
+        _ => None,
+    }
+}
+
+fn config_command(args: &[String]) -> Result<(), Box<dyn std::error::Error>> {
+    let config = pmoconfig::get_config();
+    let flags: Vec<&str> = args.iter().skip(1).map(String::as_str).collect();
+
+    match args.first().map(String::as_str) {
+        Some("dump") if flags.contains(&"--effective") => print!("{}", config.dump_effective()?),
+        Some("dump") => print!("{}", config.dump()?),
+        Some("export") => {
+            let file = flags.first().ok_or(CONFIG_USAGE)?;
+            config.export_file(Path::new(file))?;
+            println!("Configuration exported to {}", file);
+        }
+        Some("import") => {
+            let file = flags
+                .iter()
+                .find(|f| !f.starts_with("--"))
+                .ok_or(CONFIG_USAGE)?;
+            if flags.contains(&"--dry-run") {
+                let document: serde_yaml::Value =
+                    serde_yaml::from_slice(&std::fs::read(file)?)?;
+                pmoconfig::dump::validate(&document)?;
+                println!("{} is a valid configuration", file);
+            } else {
+                config.import_file(Path::new(file))?;
+                println!(
+                    "Configuration imported from {} into {}",
+                    file,
+                    config.config_file_path()
+                );
+            }
+        }
+        _ => return Err(CONFIG_USAGE.into()),
+    }
+    Ok(())
+}
+
+fn wake_command(args: &[String]) -> Result<(), Box<dyn std::error::Error>> {
+    let flags: Vec<&str> = args.iter().map(String::as_str).collect();
+
+    let (name, mac) = match flags.as_slice() {
+        ["--renderer", name] => {
+            let target = pmoconfig::get_config()
+                .find_wol_target(name)?
+                .ok_or_else(|| format!("No MAC address known for renderer {}", name))?;
+            (target.name, target.mac)
+        }
+        ["--mac", address] => {
+            let mac: MacAddress = address.parse()?;
+            (mac.to_string(), mac)
+        }
+        _ => return Err(WAKE_USAGE.into()),
+    };
+
+    wol::wake(&mac)?;
+    println!("Wake-on-LAN packet sent to {} ({})", name, mac);
+    Ok(())
+}
+
+fn doctor_command(args: &[String]) -> Result<(), Box<dyn std::error::Error>> {
+    let flags: Vec<&str> = args.iter().map(String::as_str).collect();
+
+    let wait = match flags.as_slice() {
+        [] => DOCTOR_WAIT,
+        ["--wait", secs] => Duration::from_secs(secs.parse().map_err(|_| DOCTOR_USAGE)?),
+        _ => return Err(DOCTOR_USAGE.into()),
+    };
+
+    println!("Searching for PMOMusic devices ({}s)...", wait.as_secs());
+    let report = pmoupnp::ssdp::run_doctor(wait);
+    print!("{}", report);
+
+    if report.status == DiagnosticStatus::Error {
+        return Err("discovery problems found, see the advice above".into());
+    }
+    Ok(())
+}
@@ -1,16 +1,46 @@
 use pmoapp::{WebAppExt, Webapp};
 use pmocontrol::ControlPointExt;
 use pmomediaserver::{
-    MEDIA_SERVER, MediaServerDeviceExt, ParadiseStreamingExt, sources::SourcesExt,
+    AdminApiExt, MEDIA_SERVER, MediaServerDeviceExt, ParadiseStreamingExt, TranscodeExt,
+    sources::SourcesExt,
 };
-use pmoserver::Server;
+use pmoserver::{Diagnostic, DiagnosticStatus, Server};
 use pmosource::MusicSourceExt;
 use pmoupnp::UpnpServerExt;
 use pmowebrenderer::WebRendererExt;
 use tracing::info;
 
+mod cli;
+mod service;
+
 #[tokio::main]
 async fn main() -> Result<(), Box<dyn std::error::Error>> {
+    // Sous-commandes (pmomusic config ...) : exécutées sans démarrer le serveur
+    let args: Vec<String> = std::env::args().skip(1).collect();
+
+    // Lancé par le gestionnaire de services Windows
+    #[cfg(windows)]
+    if args == ["service", "run"] {
+        return tokio::task::block_in_place(service::run_windows_service);
+    }
+
+    if let Some(result) = cli::run(&args) {
+        return result;
+    }
+
+    serve(service::terminate_signal()).await?;
+
+    // Forcer l'arrêt du processus (les threads du ControlPoint tournent en boucle infinie)
+    std::process::exit(0);
+}
+
+/// Démarre PMOMusic et le fait tourner jusqu'à Ctrl+C ou jusqu'à `stop`
+///
+/// `stop` est la demande d'arrêt du gestionnaire de services (SIGTERM de
+/// systemd ou launchd, arrêt du service Windows).
+pub(crate) async fn serve(
+    stop: impl Future<Output = ()> + Send + 'static,
+) -> Result<(), Box<dyn std::error::Error>> {
     // ========== PHASE 1 : Infrastructure UPnP ==========
     // #[cfg(tokio_unstable)]
     // console_subscriber::init();
@@ -59,6 +89,18 @@ async fn main() -> Result<(), Box<dyn std::error::Error>> {
         tracing::warn!("⚠️ Failed to register URL source: {}", e);
     }
 
+    // Enregistrer les podcasts
+    info!("🎙️ Registering podcasts...");
+    if let Err(e) = server.write().await.register_podcasts().await {
+        tracing::warn!("⚠️ Failed to register podcasts: {}", e);
+    }
+
+    // Enregistrer les favoris
+    info!("⭐ Registering favorites...");
+    if let Err(e) = server.write().await.register_favorites().await {
+        tracing::warn!("⚠️ Failed to register favorites: {}", e);
+    }
+
     // Lister toutes les sources enregistrées
     let sources = server.read().await.list_music_sources().await;
     info!("✅ {} music source(s) registered", sources.len());
@@ -105,6 +147,10 @@ async fn main() -> Result<(), Box<dyn std::error::Error>> {
         server_instance.description_route()
     );
 
+    // Proxy de transcodage (/transcode) pour les renderers et le proxy de MediaServers
+    server.write().await.register_transcoder().await;
+    pmocontrol::transcode::set_transcoder_base_url(server_instance.base_url());
+
     // Enregistrer le Control Point (découverte renderers/serveurs + API REST + SSE)
     info!("🎛️  Registering Control Point...");
     let control_point = server
@@ -114,6 +160,26 @@ async fn main() -> Result<(), Box<dyn std::error::Error>> {
         .await
         .expect("Failed to register Control Point");
 
+    // Insertion des favoris dans les files des renderers (playlists OpenHome)
+    pmofavorites::attach_control_point(control_point.clone());
+
+    // Positions de lecture des podcasts : celles de la reprise du control point
+    pmopodcast::attach_control_point(control_point.clone());
+
+    // Monter les dossiers virtuels (sources.virtual_folders), une fois les
+    // fabriques de providers enregistrées (podcasts, proxy de MediaServers,
+    // plugins externes)
+    pmocontrol::media_server_proxy::MediaServerProxy::register_factory(
+        control_point.clone(),
+        &local_server_id,
+        server_instance.base_url(),
+    );
+    pmoplugin::PluginProvider::register_factory();
+    info!("📁 Mounting virtual folders...");
+    if let Err(e) = server.write().await.register_virtual_folders().await {
+        tracing::warn!("⚠️ Failed to mount virtual folders: {}", e);
+    }
+
     // Enregistrer le WebRenderer (endpoint WebSocket pour renderers navigateur)
     info!("🌐 Registering WebRenderer...");
     server
@@ -123,6 +189,9 @@ async fn main() -> Result<(), Box<dyn std::error::Error>> {
         .await
         .expect("Failed to register WebRenderer");
 
+    // API d'administration (caches, bibliothèque, abonnements)
+    server.write().await.register_admin_api().await;
+
     // Ajouter la webapp via le trait WebAppExt
     info!("📡 Registering Web application...");
     server
@@ -136,9 +205,34 @@ async fn main() -> Result<(), Box<dyn std::error::Error>> {
     info!("🌐 Starting HTTP server...");
     server.write().await.start().await;
 
+    // Autodiagnostic (aussi exposé sur /debug/diagnostics)
+    let diagnostics = server.read().await.diagnostics();
+    diagnostics.register(
+        "resampler",
+        || match pmoaudio::dsp::resampling::check_resampler() {
+            Ok(()) => Diagnostic::ok("libsoxr available"),
+            Err(e) => Diagnostic::error(
+                e.to_string(),
+                "install libsoxr (see INSTALL_LIBSOXR.md), resampling renderers will fail",
+            ),
+        },
+    );
+    let report = diagnostics.run_blocking().await;
+    if report.status == DiagnosticStatus::Ok {
+        info!("🩺 {}", report);
+    } else {
+        tracing::warn!("🩺 {}", report);
+    }
+
     info!("✅ PMOMusic is ready!");
     info!("Press Ctrl+C to stop...");
 
+    let shutdown_token = server.read().await.shutdown_token();
+    tokio::spawn(async move {
+        stop.await;
+        shutdown_token.cancel();
+    });
+
     // Extraire le join_handle AVANT de libérer le write lock,
     // pour pouvoir l'awaiter sans tenir le write lock du serveur global.
     // (Tenir le write lock pendant wait() bloquerait register_device() dynamique)
@@ -147,12 +241,14 @@ async fn main() -> Result<(), Box<dyn std::error::Error>> {
         let _ = h.await;
     }
 
+    // Écrire les variables d'état persistantes encore en attente
+    pmoupnp::state_variables::flush_persistent_state();
+
     // Le serveur HTTP est arrêté, mais des threads (ControlPoint, etc.) peuvent encore tourner
     // Attendre 2 secondes pour laisser le temps aux threads de se terminer
     info!("Waiting for background threads to finish...");
     tokio::time::sleep(std::time::Duration::from_secs(2)).await;
 
-    // Forcer l'arrêt du processus (les threads du ControlPoint tournent en boucle infinie)
     info!("✅ PMOMusic stopped");
-    std::process::exit(0);
+    Ok(())
 }
@@ -0,0 +1,477 @@
+//! Exécution de PMOMusic en service d'arrière-plan
+//!
+//! ```text
+//! pmomusic service install
+//! pmomusic service uninstall
+//! pmomusic service print [systemd|launchd|windows]
+//! ```
+//!
+//! - Windows : service du gestionnaire de services (démarrage automatique,
+//!   compte LocalSystem), lancé avec `pmomusic service run` ;
+//! - macOS : agent launchd (`~/Library/LaunchAgents`), relancé s'il s'arrête ;
+//! - Linux : unité systemd utilisateur (`~/.config/systemd/user`).
+//!
+//! `print` affiche la définition du service sans rien installer. Après
+//! l'installation, les règles de pare-feu nécessaires à la découverte (UDP
+//! 1900) et au port HTTP sont rappelées : elles ne sont pas créées
+//! automatiquement.
+
+use std::path::{Path, PathBuf};
+
+use pmoconfig::get_config;
+
+/// Nom du service Windows et de l'unité systemd
+const SERVICE_NAME: &str = "PMOMusic";
+
+/// Label de l'agent launchd
+const LAUNCHD_LABEL: &str = "org.pmomusic.server";
+
+const SERVICE_USAGE: &str = "\
+usage: pmomusic service install
+       pmomusic service uninstall
+       pmomusic service print [systemd|launchd|windows]";
+
+/// Gestionnaire de services visé
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum ServiceKind {
+    Systemd,
+    Launchd,
+    Windows,
+}
+
+impl ServiceKind {
+    fn current() -> Option<Self> {
+        if cfg!(target_os = "linux") {
+            Some(Self::Systemd)
+        } else if cfg!(target_os = "macos") {
+            Some(Self::Launchd)
+        } else if cfg!(windows) {
+            Some(Self::Windows)
+        } else {
+            None
+        }
+    }
+
+    fn parse(name: &str) -> Option<Self> {
+        match name {
+            "systemd" => Some(Self::Systemd),
+            "launchd" => Some(Self::Launchd),
+            "windows" => Some(Self::Windows),
+            _ => None,
+        }
+    }
+
+    /// Définition du service pour l'exécutable `exe`
+    fn definition(self, exe: &Path) -> String {
+        match self {
+            Self::Systemd => systemd_unit(exe),
+            Self::Launchd => launchd_plist(exe, &home_dir().join("Library/Logs/PMOMusic.log")),
+            Self::Windows => format!(
+                "sc.exe create {name} binPath= \"\\\"{exe}\\\" service run\" start= auto DisplayName= \"{name}\"\n",
+                name = SERVICE_NAME,
+                exe = exe.display()
+            ),
+        }
+    }
+}
+
+/// Exécute `pmomusic service ...`
+pub fn command(args: &[String]) -> Result<(), Box<dyn std::error::Error>> {
+    let flags: Vec<&str> = args.iter().map(String::as_str).collect();
+    let exe = std::env::current_exe()?;
+
+    match flags.as_slice() {
+        ["install"] => {
+            install(&exe)?;
+            print_firewall_hints(&exe);
+        }
+        ["uninstall"] => uninstall()?,
+        ["print"] => {
+            let kind = ServiceKind::current().ok_or("no service manager on this platform")?;
+            print!("{}", kind.definition(&exe));
+        }
+        ["print", kind] => {
+            let kind = ServiceKind::parse(kind).ok_or(SERVICE_USAGE)?;
+            print!("{}", kind.definition(&exe));
+        }
+        ["run"] => return Err("`service run` is reserved for the Windows service manager".into()),
+        _ => return Err(SERVICE_USAGE.into()),
+    }
+    Ok(())
+}
+
+/// Demande d'arrêt envoyée par systemd ou launchd (SIGTERM)
+///
+/// Ne se termine jamais sur les plateformes sans signaux Unix : l'arrêt y
+/// passe par Ctrl+C ou par le gestionnaire de services Windows.
+pub async fn terminate_signal() {
+    #[cfg(unix)]
+    {
+        use tokio::signal::unix::{SignalKind, signal};
+        if let Ok(mut sigterm) = signal(SignalKind::terminate()) {
+            sigterm.recv().await;
+            tracing::info!("SIGTERM received, stopping");
+            return;
+        }
+    }
+    std::future::pending::<()>().await
+}
+
+fn home_dir() -> PathBuf {
+    std::env::var_os("HOME")
+        .map(PathBuf::from)
+        .unwrap_or_default()
+}
+
+#[cfg(target_os = "linux")]
+fn systemd_unit_path() -> PathBuf {
+    home_dir().join(".config/systemd/user/pmomusic.service")
+}
+
+#[cfg(target_os = "macos")]
+fn launchd_plist_path() -> PathBuf {
+    home_dir().join(format!("Library/LaunchAgents/{}.plist", LAUNCHD_LABEL))
+}
+
+/// Unité systemd utilisateur
+fn systemd_unit(exe: &Path) -> String {
+    format!(
+        "[Unit]\n\
+         Description=PMOMusic UPnP media server\n\
+         Wants=network-online.target\n\
+         After=network-online.target\n\
+         \n\
+         [Service]\n\
+         ExecStart=\"{}\"\n\
+         Restart=on-failure\n\
+         RestartSec=5\n\
+         \n\
+         [Install]\n\
+         WantedBy=default.target\n",
+        exe.display()
+    )
+}
+
+/// Agent launchd : lancé à l'ouverture de session et relancé s'il s'arrête
+fn launchd_plist(exe: &Path, log: &Path) -> String {
+    let escape = |path: &Path| {
+        path.display()
+            .to_string()
+            .replace('&', "&amp;")
+            .replace('<', "&lt;")
+            .replace('>', "&gt;")
+    };
+    format!(
+        r#"<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+    <key>Label</key>
+    <string>{label}</string>
+    <key>ProgramArguments</key>
+    <array>
+        <string>{exe}</string>
+    </array>
+    <key>RunAtLoad</key>
+    <true/>
+    <key>KeepAlive</key>
+    <dict>
+        <key>SuccessfulExit</key>
+        <false/>
+    </dict>
+    <key>StandardOutPath</key>
+    <string>{log}</string>
+    <key>StandardErrorPath</key>
+    <string>{log}</string>
+</dict>
+</plist>
+"#,
+        label = LAUNCHD_LABEL,
+        exe = escape(exe),
+        log = escape(log),
+    )
+}
+
+/// Écrit `contents` dans `path` puis exécute `commands`
+#[cfg(any(target_os = "linux", target_os = "macos"))]
+fn install_file(
+    path: &Path,
+    contents: &str,
+    commands: &[&[&str]],
+) -> Result<(), Box<dyn std::error::Error>> {
+    if let Some(parent) = path.parent() {
+        std::fs::create_dir_all(parent)?;
+    }
+    std::fs::write(path, contents)?;
+    println!("Service definition written to {}", path.display());
+    run_commands(commands)
+}
+
+#[cfg(any(target_os = "linux", target_os = "macos"))]
+fn run_commands(commands: &[&[&str]]) -> Result<(), Box<dyn std::error::Error>> {
+    for command in commands {
+        let status = std::process::Command::new(command[0])
+            .args(&command[1..])
+            .status()?;
+        if !status.success() {
+            return Err(format!("`{}` failed ({})", command.join(" "), status).into());
+        }
+    }
+    Ok(())
+}
+
+#[cfg(target_os = "linux")]
+fn install(exe: &Path) -> Result<(), Box<dyn std::error::Error>> {
+    install_file(
+        &systemd_unit_path(),
+        &ServiceKind::Systemd.definition(exe),
+        &[
+            &["systemctl", "--user", "daemon-reload"],
+            &["systemctl", "--user", "enable", "--now", "pmomusic.service"],
+        ],
+    )?;
+    println!("Service started; to keep it running after logout: loginctl enable-linger");
+    Ok(())
+}
+
+#[cfg(target_os = "linux")]
+fn uninstall() -> Result<(), Box<dyn std::error::Error>> {
+    run_commands(&[&[
+        "systemctl",
+        "--user",
+        "disable",
+        "--now",
+        "pmomusic.service",
+    ]])?;
+    std::fs::remove_file(systemd_unit_path())?;
+    run_commands(&[&["systemctl", "--user", "daemon-reload"]])?;
+    println!("Service removed");
+    Ok(())
+}
+
+#[cfg(target_os = "macos")]
+fn install(exe: &Path) -> Result<(), Box<dyn std::error::Error>> {
+    let path = launchd_plist_path();
+    let path_str = path.display().to_string();
+    install_file(
+        &path,
+        &ServiceKind::Launchd.definition(exe),
+        &[&["launchctl", "load", "-w", &path_str]],
+    )?;
+    println!("Agent {} loaded", LAUNCHD_LABEL);
+    Ok(())
+}
+
+#[cfg(target_os = "macos")]
+fn uninstall() -> Result<(), Box<dyn std::error::Error>> {
+    let path = launchd_plist_path();
+    let path_str = path.display().to_string();
+    run_commands(&[&["launchctl", "unload", "-w", &path_str]])?;
+    std::fs::remove_file(&path)?;
+    println!("Agent {} removed", LAUNCHD_LABEL);
+    Ok(())
+}
+
+#[cfg(windows)]
+fn install(exe: &Path) -> Result<(), Box<dyn std::error::Error>> {
+    windows::install(exe)
+}
+
+#[cfg(windows)]
+fn uninstall() -> Result<(), Box<dyn std::error::Error>> {
+    windows::uninstall()
+}
+
+#[cfg(not(any(target_os = "linux", target_os = "macos", windows)))]
+fn install(_exe: &Path) -> Result<(), Box<dyn std::error::Error>> {
+    Err("no supported service manager on this platform".into())
+}
+
+#[cfg(not(any(target_os = "linux", target_os = "macos", windows)))]
+fn uninstall() -> Result<(), Box<dyn std::error::Error>> {
+    Err("no supported service manager on this platform".into())
+}
+
+/// Rappelle les règles de pare-feu utiles à la découverte et au HTTP
+fn print_firewall_hints(exe: &Path) {
+    let port = get_config().get_http_port();
+    println!();
+    println!("Control points must reach UDP port 1900 (SSDP) and TCP port {port} (HTTP).");
+    println!("If a firewall is active, allow them, for instance:");
+    if cfg!(windows) {
+        println!(
+            "  netsh advfirewall firewall add rule name=\"PMOMusic SSDP\" dir=in action=allow protocol=UDP localport=1900 profile=private"
+        );
+        println!(
+            "  netsh advfirewall firewall add rule name=\"PMOMusic HTTP\" dir=in action=allow protocol=TCP localport={port} profile=private"
+        );
+        println!("and check that the network profile is Private, not Public.");
+    } else if cfg!(target_os = "macos") {
+        let fw = "/usr/libexec/ApplicationFirewall/socketfilterfw";
+        println!("  sudo {} --add \"{}\"", fw, exe.display());
+        println!("  sudo {} --unblockapp \"{}\"", fw, exe.display());
+    } else {
+        println!("  sudo ufw allow 1900/udp && sudo ufw allow {port}/tcp");
+        println!(
+            "  sudo firewall-cmd --permanent --add-service=ssdp --add-port={port}/tcp && sudo firewall-cmd --reload"
+        );
+    }
+    println!("Then check discovery with `pmomusic doctor`.");
+}
+
+#[cfg(windows)]
+pub use windows::run_windows_service;
+
+/// Service du gestionnaire de services Windows
+#[cfg(windows)]
+mod windows {
+    use std::ffi::OsString;
+    use std::path::Path;
+    use std::sync::{Mutex, OnceLock};
+    use std::time::Duration;
+
+    use windows_service::service::{
+        ServiceAccess, ServiceControl, ServiceControlAccept, ServiceErrorControl, ServiceExitCode,
+        ServiceInfo, ServiceStartType, ServiceState, ServiceStatus, ServiceType,
+    };
+    use windows_service::service_control_handler::{self, ServiceControlHandlerResult};
+    use windows_service::service_manager::{ServiceManager, ServiceManagerAccess};
+    use windows_service::{define_windows_service, service_dispatcher};
+
+    use super::SERVICE_NAME;
+
+    /// Runtime tokio sur lequel le service exécute le serveur
+    static RUNTIME: OnceLock<tokio::runtime::Handle> = OnceLock::new();
+
+    define_windows_service!(ffi_service_main, service_main);
+
+    /// Point d'entrée de `pmomusic service run`
+    ///
+    /// Bloque jusqu'à l'arrêt du service. Doit être appelé depuis le runtime
+    /// tokio, hors d'une tâche asynchrone (`block_in_place`).
+    pub fn run_windows_service() -> Result<(), Box<dyn std::error::Error>> {
+        let _ = RUNTIME.set(tokio::runtime::Handle::current());
+        service_dispatcher::start(SERVICE_NAME, ffi_service_main).map_err(|e| {
+            format!(
+                "cannot reach the Windows service manager ({}); `service run` is reserved for it",
+                e
+            )
+        })?;
+        Ok(())
+    }
+
+    fn service_main(_arguments: Vec<OsString>) {
+        if let Err(e) = run_service() {
+            tracing::error!("❌ Windows service failed: {}", e);
+        }
+    }
+
+    fn status(state: ServiceState, exit_code: u32) -> ServiceStatus {
+        ServiceStatus {
+            service_type: ServiceType::OWN_PROCESS,
+            current_state: state,
+            controls_accepted: if state == ServiceState::Running {
+                ServiceControlAccept::STOP | ServiceControlAccept::SHUTDOWN
+            } else {
+                ServiceControlAccept::empty()
+            },
+            exit_code: ServiceExitCode::Win32(exit_code),
+            checkpoint: 0,
+            wait_hint: Duration::default(),
+            process_id: None,
+        }
+    }
+
+    fn run_service() -> windows_service::Result<()> {
+        let (stop_tx, stop_rx) = tokio::sync::oneshot::channel::<()>();
+        let stop_tx = Mutex::new(Some(stop_tx));
+
+        let status_handle =
+            service_control_handler::register(SERVICE_NAME, move |control| match control {
+                ServiceControl::Stop | ServiceControl::Shutdown => {
+                    if let Some(tx) = stop_tx.lock().unwrap().take() {
+                        let _ = tx.send(());
+                    }
+                    ServiceControlHandlerResult::NoError
+                }
+                ServiceControl::Interrogate => ServiceControlHandlerResult::NoError,
+                _ => ServiceControlHandlerResult::NotImplemented,
+            })?;
+        status_handle.set_service_status(status(ServiceState::Running, 0))?;
+
+        let runtime = RUNTIME
+            .get()
+            .expect("service started outside run_windows_service");
+        let result = runtime.block_on(crate::serve(async move {
+            let _ = stop_rx.await;
+        }));
+        let exit_code = match result {
+            Ok(()) => 0,
+            Err(e) => {
+                tracing::error!("❌ PMOMusic stopped with an error: {}", e);
+                1
+            }
+        };
+
+        status_handle.set_service_status(status(ServiceState::Stopped, exit_code))
+    }
+
+    pub fn install(exe: &Path) -> Result<(), Box<dyn std::error::Error>> {
+        let manager = ServiceManager::local_computer(
+            None::<&str>,
+            ServiceManagerAccess::CONNECT | ServiceManagerAccess::CREATE_SERVICE,
+        )?;
+        let info = ServiceInfo {
+            name: OsString::from(SERVICE_NAME),
+            display_name: OsString::from(SERVICE_NAME),
+            service_type: ServiceType::OWN_PROCESS,
+            start_type: ServiceStartType::AutoStart,
+            error_control: ServiceErrorControl::Normal,
+            executable_path: exe.to_path_buf(),
+            launch_arguments: vec![OsString::from("service"), OsString::from("run")],
+            dependencies: vec![],
+            account_name: None, // LocalSystem
+            account_password: None,
+        };
+        let service =
+            manager.create_service(&info, ServiceAccess::CHANGE_CONFIG | ServiceAccess::START)?;
+        service.set_description("PMOMusic UPnP media server")?;
+        service.start::<&str>(&[])?;
+        println!("Service {} installed and started", SERVICE_NAME);
+        Ok(())
+    }
+
+    pub fn uninstall() -> Result<(), Box<dyn std::error::Error>> {
+        let manager = ServiceManager::local_computer(None::<&str>, ServiceManagerAccess::CONNECT)?;
+        let service = manager.open_service(
+            SERVICE_NAME,
+            ServiceAccess::QUERY_STATUS | ServiceAccess::STOP | ServiceAccess::DELETE,
+        )?;
+        if service.query_status()?.current_state != ServiceState::Stopped {
+            service.stop()?;
+        }
+        service.delete()?;
+        println!("Service {} removed", SERVICE_NAME);
+        Ok(())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_definitions_point_to_the_executable() {
+        let exe = Path::new("/opt/PMO & Co/pmomusic");
+
+        let plist = launchd_plist(exe, Path::new("/tmp/pmomusic.log"));
+        assert!(plist.contains("<string>/opt/PMO &amp; Co/pmomusic</string>"));
+        assert!(plist.contains(LAUNCHD_LABEL));
+
+        let unit = systemd_unit(exe);
+        assert!(unit.contains("ExecStart=\"/opt/PMO & Co/pmomusic\"\n"));
+
+        let sc = ServiceKind::Windows.definition(exe);
+        assert!(sc.contains("service run"));
+    }
+}
@@ -76,6 +76,16 @@ if (isDev) {
       name: "UpnpExplorer",
       component: () => import("../components/UpnpExplorer.vue"),
     },
+    {
+      path: "/debug/upnp-actions",
+      name: "UpnpActions",
+      component: () => import("../components/ActionAuditLog.vue"),
+    },
+    {
+      path: "/debug/stream-latency",
+      name: "StreamLatency",
+      component: () => import("../components/StreamLatency.vue"),
+    },
     {
       path: "/debug/api-dashboard",
       name: "APIDashboard",
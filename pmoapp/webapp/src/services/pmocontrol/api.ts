@@ -16,6 +16,7 @@ import type {
   SuccessResponse,
   ErrorResponse,
 } from "./types";
+import { profileHeaders } from "../profiles";
 
 /**
  * Fetch avec timeout et AbortController
@@ -69,6 +70,7 @@ class PMOControlAPI {
       ...options,
       headers: {
         "Content-Type": "application/json",
+        ...profileHeaders(),
         ...options.headers,
       },
     });
@@ -250,6 +250,18 @@ export type RendererEventPayload =
       renderer_id: string;
       timestamp: string;
     }
+  | {
+      type: "alarm_triggered";
+      renderer_id: string;
+      alarm_id: string;
+      timestamp: string;
+    }
+  | {
+      type: "alarm_stopped";
+      renderer_id: string;
+      alarm_id: string;
+      timestamp: string;
+    }
   | {
       type: "online";
       renderer_id: string;
@@ -0,0 +1,64 @@
+// Profils d'écoute (favoris, historique, reprise de lecture)
+// Communique avec /api/profiles ; le profil choisi est mémorisé localement
+// et envoyé avec les requêtes via l'en-tête X-PMO-Profile.
+
+export interface ProfilesResponse {
+  default: string;
+  profiles: string[];
+}
+
+export const PROFILE_HEADER = "X-PMO-Profile";
+
+const STORAGE_KEY = "pmo-profile";
+
+/**
+ * Profil choisi sur cet appareil (null : profil par défaut du serveur)
+ */
+export function getActiveProfile(): string | null {
+  return localStorage.getItem(STORAGE_KEY);
+}
+
+export function setActiveProfile(profile: string | null): void {
+  if (profile) {
+    localStorage.setItem(STORAGE_KEY, profile);
+  } else {
+    localStorage.removeItem(STORAGE_KEY);
+  }
+}
+
+/**
+ * En-têtes à joindre aux requêtes pour les attribuer au profil choisi
+ */
+export function profileHeaders(): Record<string, string> {
+  const profile = getActiveProfile();
+  return profile ? { [PROFILE_HEADER]: profile } : {};
+}
+
+async function request<T>(path: string, options: RequestInit = {}): Promise<T> {
+  const response = await fetch(`/api/profiles${path}`, {
+    ...options,
+    headers: { "Content-Type": "application/json", ...options.headers },
+  });
+  const data = await response.json().catch(() => ({}));
+  if (!response.ok) {
+    throw new Error(data.error ?? `HTTP ${response.status}`);
+  }
+  return data as T;
+}
+
+export function listProfiles(): Promise<ProfilesResponse> {
+  return request<ProfilesResponse>("");
+}
+
+export function createProfile(name: string): Promise<ProfilesResponse> {
+  return request<ProfilesResponse>("", {
+    method: "POST",
+    body: JSON.stringify({ name }),
+  });
+}
+
+export function deleteProfile(name: string): Promise<ProfilesResponse> {
+  return request<ProfilesResponse>(`/${encodeURIComponent(name)}`, {
+    method: "DELETE",
+  });
+}
@@ -42,7 +42,7 @@ pub use nodes::*;
 pub use sources::PlaylistSource;
 
 #[cfg(feature = "http-stream")]
-pub use sources::UriSource;
+pub use sources::{ProbedTrack, StreamFormat, UriSource, probe_track};
 
 #[cfg(feature = "http-stream")]
 pub use sources::{PlayerCommand, PlayerEvent, PlayerHandle, PlayerSource};
@@ -23,6 +23,12 @@ mod flac_frame_utils;
 #[cfg(feature = "http-stream")]
 mod timed_broadcast;
 
+#[cfg(feature = "http-stream")]
+mod stream_latency;
+
+#[cfg(feature = "http-stream")]
+pub use stream_latency::{LatencySnapshot, StreamLatency};
+
 #[cfg(feature = "http-stream")]
 mod direct_flac_sink;
 
@@ -41,6 +47,14 @@ pub use direct_ogg_flac_sink::{
     DIRECT_OGG_FLAC_BITS_PER_SAMPLE, DIRECT_OGG_FLAC_CHANNELS, DIRECT_OGG_FLAC_SAMPLE_RATE,
 };
 
+#[cfg(feature = "http-stream")]
+mod transcode_sink;
+
+#[cfg(feature = "http-stream")]
+pub use transcode_sink::{
+    TranscodeContainer, TranscodeFormat, TranscodeSink, TranscodeStream, TRANSCODE_CHANNELS,
+};
+
 #[cfg(feature = "http-stream")]
 mod streaming_flac_sink;
 
@@ -0,0 +1,152 @@
+//! End-to-end latency measurement for streaming sinks.
+//!
+//! The sink marks the wall-clock instant at which each PCM chunk (already
+//! decoded and processed by the pipeline) is handed to the encoder, keyed by
+//! its audio timestamp. Client streams record a delivery when they hand a
+//! broadcast packet to the HTTP body: the packet's audio timestamp is matched
+//! against the marks, giving the time spent between decode and client write
+//! (encoder, broadcast pacing and client queue included).
+//!
+//! Statistics are shared by all clients of a stream, and are used to tune
+//! multiroom synchronisation and buffer sizes.
+
+use std::collections::VecDeque;
+use std::sync::Mutex;
+use std::time::{Duration, Instant};
+
+use serde::Serialize;
+
+/// Marks older than this are discarded (track restarts reuse timestamps).
+const MARK_RETENTION: Duration = Duration::from_secs(30);
+
+/// Upper bound on retained marks (~50 ms chunks over the retention window).
+const MAX_MARKS: usize = 1024;
+
+/// A packet only matches a mark that started at most this long before it.
+const MATCH_WINDOW_SEC: f64 = 1.0;
+
+/// Weight of the latest sample in the moving average.
+const AVERAGE_WEIGHT: f64 = 0.1;
+
+/// Snapshot of the latency statistics of a stream (milliseconds).
+#[derive(Debug, Clone, Copy, Default, PartialEq, Serialize)]
+pub struct LatencySnapshot {
+    /// Latency of the last delivered packet
+    pub last_ms: f64,
+    /// Exponential moving average
+    pub average_ms: f64,
+    /// Lowest latency observed
+    pub min_ms: f64,
+    /// Highest latency observed
+    pub max_ms: f64,
+    /// Number of measured deliveries
+    pub samples: u64,
+}
+
+#[derive(Debug, Default)]
+struct LatencyState {
+    marks: VecDeque<(f64, Instant)>,
+    stats: LatencySnapshot,
+}
+
+/// Latency tracker shared by a streaming sink and its clients.
+#[derive(Debug, Default)]
+pub struct StreamLatency {
+    state: Mutex<LatencyState>,
+}
+
+impl StreamLatency {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Records that the PCM at `timestamp_sec` enters the encoder now.
+    pub fn mark_input(&self, timestamp_sec: f64) {
+        self.mark_input_at(timestamp_sec, Instant::now());
+    }
+
+    /// Records the delivery of a packet to a client, returns its latency.
+    ///
+    /// Returns `None` when no mark matches (header pages, packets from a
+    /// previous track).
+    pub fn record_delivery(&self, audio_timestamp: f64) -> Option<Duration> {
+        self.record_delivery_at(audio_timestamp, Instant::now())
+    }
+
+    /// Current statistics.
+    pub fn snapshot(&self) -> LatencySnapshot {
+        self.state.lock().map(|s| s.stats).unwrap_or_default()
+    }
+
+    fn mark_input_at(&self, timestamp_sec: f64, now: Instant) {
+        let Ok(mut state) = self.state.lock() else {
+            return;
+        };
+        while let Some(&(_, at)) = state.marks.front() {
+            if state.marks.len() >= MAX_MARKS || now.duration_since(at) > MARK_RETENTION {
+                state.marks.pop_front();
+            } else {
+                break;
+            }
+        }
+        state.marks.push_back((timestamp_sec, now));
+    }
+
+    fn record_delivery_at(&self, audio_timestamp: f64, now: Instant) -> Option<Duration> {
+        let Ok(mut state) = self.state.lock() else {
+            return None;
+        };
+
+        // Most recent mark at or just before the packet timestamp.
+        let &(_, marked_at) = state.marks.iter().rev().find(|(ts, _)| {
+            let ahead = audio_timestamp - ts;
+            (0.0..MATCH_WINDOW_SEC).contains(&ahead)
+        })?;
+        let latency = now.saturating_duration_since(marked_at);
+
+        let ms = latency.as_secs_f64() * 1000.0;
+        let stats = &mut state.stats;
+        if stats.samples == 0 {
+            stats.average_ms = ms;
+            stats.min_ms = ms;
+            stats.max_ms = ms;
+        } else {
+            stats.average_ms += AVERAGE_WEIGHT * (ms - stats.average_ms);
+            stats.min_ms = stats.min_ms.min(ms);
+            stats.max_ms = stats.max_ms.max(ms);
+        }
+        stats.last_ms = ms;
+        stats.samples += 1;
+        Some(latency)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn delivery_matches_the_mark_of_its_chunk() {
+        let latency = StreamLatency::new();
+        let start = Instant::now();
+        latency.mark_input_at(0.0, start);
+        latency.mark_input_at(0.05, start + Duration::from_millis(10));
+        latency.mark_input_at(0.10, start + Duration::from_millis(20));
+
+        // Packet encoded from the second chunk, written 300 ms after its mark.
+        let measured = latency.record_delivery_at(0.07, start + Duration::from_millis(310));
+        assert_eq!(measured, Some(Duration::from_millis(300)));
+
+        latency.record_delivery_at(0.10, start + Duration::from_millis(420));
+        let stats = latency.snapshot();
+        assert_eq!(stats.samples, 2);
+        assert_eq!(stats.last_ms, 400.0);
+        assert_eq!(stats.min_ms, 300.0);
+        assert_eq!(stats.max_ms, 400.0);
+        assert!(stats.average_ms > 300.0 && stats.average_ms < 400.0);
+
+        // Packet from a previous track: nothing to match.
+        assert_eq!(latency.record_delivery_at(42.0, start), None);
+        assert_eq!(latency.snapshot().samples, 2);
+    }
+}
@@ -80,6 +80,7 @@ use tracing::{debug, error, info, trace, warn};
 
 use crate::byte_stream_reader::PcmChunk;
 use crate::chunk_to_pcm::chunk_to_pcm_bytes;
+use crate::sinks::stream_latency::LatencySnapshot;
 use crate::sinks::streaming_sink_common::{
     MetadataSnapshot, SharedClientStream, SharedSinkContext, SharedStreamHandleInner,
     StreamingSinkOptions,
@@ -141,6 +142,11 @@ impl StreamHandle {
     pub fn set_auto_stop(&self, enabled: bool) {
         self.inner.auto_stop.store(enabled, Ordering::SeqCst);
     }
+
+    /// Decode-to-client-write latency of the stream
+    pub fn latency(&self) -> LatencySnapshot {
+        self.inner.latency.snapshot()
+    }
 }
 
 pub struct FlacClientStream {
@@ -291,6 +297,7 @@ impl NodeLogic for StreamingFlacSinkLogic {
                                         }
                                     };
 
+                                    self.ctx.latency.mark_input(seg.timestamp_sec);
                                     if let Err(e) = pcm_tx.send(pcm_chunk).await {
                                         warn!("Failed to send PCM data to encoder: {}", e);
                                         break;
@@ -413,6 +420,10 @@ impl NodeLogic for StreamingFlacSinkLogic {
             }
         }
 
+        // Flush the encoder on a natural end (EndOfStream, input closed) and
+        // signal end of stream so HTTP clients are released promptly.
+        self.ctx.finish_stream(!stop_token.is_cancelled()).await;
+
         debug!("StreamingFlacSink processing complete");
         Ok(())
     }
@@ -536,6 +547,7 @@ impl StreamingFlacSink {
                 is_paused: Arc::new(AtomicBool::new(false)),
                 stream_type: Arc::new(RwLock::new(pmoaudio::StreamType::Finite)),
                 last_track_metadata: Arc::new(RwLock::new(None)),
+                latency: shared_handle.latency.clone(),
             },
         };
 
@@ -218,6 +218,7 @@ impl AsyncRead for IcyClientStream {
             match self.rx.try_recv() {
                 Ok(packet) => {
                     self.current_epoch = packet.epoch;
+                    self.handle.latency.record_delivery(packet.audio_timestamp);
                     // Calculate how many bytes until next metadata block
                     let until_metadata = self.metaint - (self.byte_count % self.metaint);
                     let to_buffer = packet.payload.len().min(until_metadata);
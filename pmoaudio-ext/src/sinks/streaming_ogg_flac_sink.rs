@@ -72,6 +72,7 @@ use tracing::{debug, error, trace, warn};
 use crate::byte_stream_reader::PcmChunk;
 use crate::chunk_to_pcm::chunk_to_pcm_bytes;
 use crate::sinks::flac_frame_utils::{extract_sample_rate_from_streaminfo, read_flac_header};
+use crate::sinks::stream_latency::LatencySnapshot;
 use crate::sinks::streaming_sink_common::{
     MetadataSnapshot, SharedClientStream, SharedSinkContext, SharedStreamHandleInner,
     StreamingSinkOptions,
@@ -126,6 +127,11 @@ impl OggFlacStreamHandle {
     pub fn is_paused(&self) -> bool {
         self.inner.is_paused.load(Ordering::SeqCst)
     }
+
+    /// Decode-to-client-write latency of the stream
+    pub fn latency(&self) -> LatencySnapshot {
+        self.inner.latency.snapshot()
+    }
 }
 
 /// OGG-FLAC client stream (implements AsyncRead).
@@ -382,6 +388,7 @@ impl NodeLogic for StreamingOggFlacSinkLogic {
                                         }
                                     };
 
+                                    self.ctx.latency.mark_input(seg.timestamp_sec);
                                     if let Err(e) = pcm_tx.send(pcm_chunk).await {
                                         warn!("Failed to send PCM data to OGG encoder: {}", e);
                                         break;
@@ -472,6 +479,10 @@ impl NodeLogic for StreamingOggFlacSinkLogic {
             }
         }
 
+        // Flush the encoder on a natural end (EndOfStream, input closed) and
+        // signal end of stream so HTTP clients are released promptly.
+        self.ctx.finish_stream(!stop_token.is_cancelled()).await;
+
         debug!("StreamingOggFlacSink processing complete");
         Ok(())
     }
@@ -527,22 +538,11 @@ impl StreamingOggFlacSink {
 
     /// Create a sink with a custom broadcast pacing limit and options.
     pub fn with_options(
-        mut encoder_options: EncoderOptions,
+        encoder_options: EncoderOptions,
         bits_per_sample: u8,
         broadcast_max_lead_time: f64,
         options: StreamingSinkOptions,
     ) -> (Self, OggFlacStreamHandle) {
-        // Validate bit depth
-        if ![16, 24, 32].contains(&bits_per_sample) {
-            panic!("bits_per_sample must be 16, 24, or 32");
-        }
-
-        // Transfer server_base_url from StreamingSinkOptions to EncoderOptions
-        encoder_options.server_base_url = options.server_base_url.clone();
-
-        // Create PCM channel (bounded for backpressure)
-        let (pcm_tx, pcm_rx) = mpsc::channel::<PcmChunk>(16);
-
         // Shared metadata
         let metadata = Arc::new(RwLock::new(MetadataSnapshot::default()));
 
@@ -562,14 +562,59 @@ impl StreamingOggFlacSink {
         let auto_stop = Arc::new(AtomicBool::new(true));
 
         let shared_handle = Arc::new(SharedStreamHandleInner::new(
-            broadcast.clone(),
-            metadata.clone(),
-            stop_token.clone(),
-            header.clone(),
-            auto_stop.clone(),
+            broadcast, metadata, stop_token, header, auto_stop,
         ));
 
         let handle = OggFlacStreamHandle::new(shared_handle.clone());
+        let sink = Self::from_shared(
+            encoder_options,
+            bits_per_sample,
+            broadcast_max_lead_time,
+            &options,
+            &shared_handle,
+        );
+
+        (sink, handle)
+    }
+
+    /// Create a sink feeding the clients of an existing handle.
+    ///
+    /// Used to rebuild a pipeline that was torn down while idle: connected
+    /// and future clients keep using the same handle. Same settings as
+    /// [`StreamingOggFlacSink::new`].
+    pub fn attach(
+        encoder_options: EncoderOptions,
+        bits_per_sample: u8,
+        handle: &OggFlacStreamHandle,
+    ) -> Self {
+        // The previous sink closed the broadcast when it stopped
+        handle.inner.broadcast.reopen();
+        Self::from_shared(
+            encoder_options,
+            bits_per_sample,
+            DEFAULT_BROADCAST_MAX_LEAD_TIME,
+            &StreamingSinkOptions::ogg_defaults(),
+            &handle.inner,
+        )
+    }
+
+    fn from_shared(
+        mut encoder_options: EncoderOptions,
+        bits_per_sample: u8,
+        broadcast_max_lead_time: f64,
+        options: &StreamingSinkOptions,
+        shared_handle: &Arc<SharedStreamHandleInner>,
+    ) -> Self {
+        // Validate bit depth
+        if ![16, 24, 32].contains(&bits_per_sample) {
+            panic!("bits_per_sample must be 16, 24, or 32");
+        }
+
+        // Transfer server_base_url from StreamingSinkOptions to EncoderOptions
+        encoder_options.server_base_url = options.server_base_url.clone();
+
+        // Create PCM channel (bounded for backpressure)
+        let (pcm_tx, pcm_rx) = mpsc::channel::<PcmChunk>(16);
 
         let logic = StreamingOggFlacSinkLogic {
             ctx: SharedSinkContext {
@@ -582,9 +627,9 @@ impl StreamingOggFlacSink {
                     use_only_default_metadata: options.use_only_default_metadata,
                     pcm_tx: Some(pcm_tx),
                     pcm_rx: Some(pcm_rx),
-                    metadata,
-                    broadcast,
-                    header,
+                    metadata: shared_handle.metadata.clone(),
+                    broadcast: shared_handle.broadcast.clone(),
+                    header: shared_handle.header.clone(),
                     encoder_state: None,
                     sample_rate: None,
                     broadcast_max_lead_time: broadcast_max_lead_time.max(0.0),
@@ -596,14 +641,13 @@ impl StreamingOggFlacSink {
                     is_paused: Arc::new(AtomicBool::new(false)),
                     stream_type: Arc::new(RwLock::new(pmoaudio::StreamType::Finite)),
                     last_track_metadata: Arc::new(RwLock::new(None)),
+                    latency: shared_handle.latency.clone(),
                 },
         };
 
-        let sink = StreamingOggFlacSink {
+        StreamingOggFlacSink {
             inner: Node::new_with_input(logic, 16),
-        };
-
-        (sink, handle)
+        }
     }
 }
 
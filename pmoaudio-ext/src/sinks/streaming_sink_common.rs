@@ -19,6 +19,7 @@ use tokio_util::sync::CancellationToken;
 use tracing::{debug, error, info, trace, warn};
 
 use crate::byte_stream_reader::{ByteStreamReader, PcmChunk};
+use crate::sinks::stream_latency::StreamLatency;
 use crate::sinks::timed_broadcast::{self, TryRecvError};
 
 /// Snapshot of track metadata shared across streaming sinks.
@@ -122,6 +123,8 @@ pub struct SharedStreamHandleInner {
     pub is_paused: Arc<AtomicBool>,
     /// Stream type (Continuous for radio, Finite for tracks)
     pub stream_type: Arc<RwLock<pmoaudio::StreamType>>,
+    /// Decode-to-client-write latency, shared with the sink
+    pub latency: Arc<StreamLatency>,
 }
 
 impl SharedStreamHandleInner {
@@ -141,6 +144,7 @@ impl SharedStreamHandleInner {
             auto_stop,
             is_paused: Arc::new(AtomicBool::new(false)),
             stream_type: Arc::new(RwLock::new(pmoaudio::StreamType::Finite)),
+            latency: Arc::new(StreamLatency::new()),
         }
     }
 
@@ -220,6 +224,10 @@ impl AsyncRead for SharedClientStream {
                     );
                     self.state = StreamState::Streaming;
                     continue;
+                } else if self.handle.broadcast.is_closed() {
+                    // Stream ended before the encoder produced a header: nothing will come.
+                    self.finished = true;
+                    return Poll::Ready(Ok(()));
                 } else {
                     // Header not yet available (encoder not yet started): wait and retry
                     let waker = cx.waker().clone();
@@ -250,6 +258,7 @@ impl AsyncRead for SharedClientStream {
             match self.rx.try_recv() {
                 Ok(packet) => {
                     self.current_epoch = packet.epoch;
+                    self.handle.latency.record_delivery(packet.audio_timestamp);
                     self.buffer.extend(packet.payload.iter());
                 }
                 Err(TryRecvError::Empty) => {
@@ -306,6 +315,8 @@ pub struct SharedSinkContext {
     pub stream_type: Arc<RwLock<pmoaudio::StreamType>>,
     /// Last TrackBoundary metadata received (for pause/resume)
     pub last_track_metadata: Arc<RwLock<Option<Arc<RwLock<dyn TrackMetadata>>>>>,
+    /// Latency tracker of the stream handle (PCM entering the encoder is marked here)
+    pub latency: Arc<StreamLatency>,
 }
 
 impl SharedSinkContext {
@@ -392,6 +403,29 @@ impl SharedSinkContext {
         Ok(())
     }
 
+    /// Terminates the stream once the sink stops.
+    ///
+    /// Closing the PCM channel lets the encoder emit its last frames. With
+    /// `flush`, the broadcaster task is awaited so these frames reach the
+    /// broadcast; otherwise (cancellation) it is aborted. The broadcast is
+    /// then closed: clients drain what is left and get EOF, so HTTP handlers
+    /// end the response instead of waiting for more data.
+    pub async fn finish_stream(&mut self, flush: bool) {
+        self.pcm_tx = None;
+        if let Some(state) = self.encoder_state.take() {
+            if flush {
+                trace!("Flushing encoder before end of stream...");
+                if let Err(e) = state.broadcaster_task.await {
+                    warn!("Broadcaster task error during flush: {:?}", e);
+                }
+            } else {
+                state.broadcaster_task.abort();
+            }
+        }
+        self.broadcast.close();
+        debug!("End of stream signaled to clients (flushed: {})", flush);
+    }
+
     /// Prepare encoder options for a new track so the next FLAC header embeds up-to-date metadata
     /// and duration (total_samples) when available.
     pub async fn prepare_encoder_options_for_track(
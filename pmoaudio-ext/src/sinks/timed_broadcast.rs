@@ -225,6 +225,18 @@ impl<T> Inner<T> {
             self.space_notify.notify_waiters();
         }
     }
+
+    fn reopen(&self) {
+        if let Ok(mut state) = self.state.lock() {
+            let pending = state.buffer.len() as u64;
+            state.buffer.clear();
+            state.head_seq += pending;
+            state.closed = false;
+            state.initialized = false;
+            state.last_segment_end = None;
+        }
+        self.is_closed.store(false, Ordering::SeqCst);
+    }
 }
 
 /// Créé un channel broadcast temporisé.
@@ -446,9 +458,26 @@ impl<T> Sender<T> {
     }
 
     /// Ferme explicitement le channel.
+    ///
+    /// Sert de marqueur de fin de flux : plus aucun paquet n'est accepté,
+    /// mais les receivers consomment d'abord les paquets encore en file
+    /// avant de recevoir [`TryRecvError::Closed`].
     pub fn close(&self) {
         self.inner.close();
     }
+
+    /// Rouvre un channel fermé pour un nouveau flux.
+    ///
+    /// Les paquets encore en file sont abandonnés et l'epoch repart du
+    /// prochain paquet envoyé.
+    pub fn reopen(&self) {
+        self.inner.reopen();
+    }
+
+    /// Indique si le channel a été fermé.
+    pub fn is_closed(&self) -> bool {
+        self.inner.is_closed.load(Ordering::SeqCst)
+    }
 }
 
 impl<T> Drop for Sender<T> {
@@ -604,3 +633,38 @@ pub(crate) fn calculate_broadcast_capacity(max_lead_time: f64) -> usize {
     let capacity = (max_lead_time * estimated_items_per_second) as usize;
     capacity.max(100) // Minimum 100 items
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[tokio::test]
+    async fn close_drains_pending_packets_before_closed() {
+        let (tx, mut rx) = channel::<u32>("test", 8);
+        tx.send(1, 0.0, 10.0).await.unwrap();
+        tx.send(2, 10.0, 10.0).await.unwrap();
+        tx.close();
+
+        assert!(tx.is_closed());
+        assert!(matches!(
+            tx.send(3, 20.0, 10.0).await,
+            Err(SendError::Closed(3))
+        ));
+        assert_eq!(rx.recv().await.unwrap().payload, 1);
+        assert_eq!(rx.recv().await.unwrap().payload, 2);
+        assert!(matches!(rx.recv().await, Err(RecvError::Closed)));
+    }
+
+    #[tokio::test]
+    async fn reopen_accepts_a_new_stream() {
+        let (tx, _rx) = channel::<u32>("test", 8);
+        tx.send(1, 0.0, 10.0).await.unwrap();
+        tx.close();
+        tx.reopen();
+
+        assert!(!tx.is_closed());
+        let mut rx = tx.subscribe();
+        tx.send(2, 0.0, 10.0).await.unwrap();
+        assert_eq!(rx.recv().await.unwrap().payload, 2);
+    }
+}
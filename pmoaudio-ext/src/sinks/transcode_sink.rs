@@ -0,0 +1,753 @@
+//! TranscodeSink — nœud puits de transcodage one-shot.
+//!
+//! Encode un unique flux audio en FLAC, WAV, AIFF, LPCM brut (DLNA
+//! `audio/L16`, `audio/L24`) ou Ogg/Opus, à la fréquence des chunks reçus. Contrairement à [`DirectFlacSink`](super::DirectFlacSink), le sink
+//! n'est pas reconnectable : il est créé pour une requête HTTP et s'arrête
+//! avec elle.
+//!
+//! # Architecture
+//!
+//! ```text
+//! AudioSegment (entiers)
+//!     ↓ NodeLogic::process()  [démarre l'encodeur au premier chunk]
+//! chunk_to_pcm_bytes() → PCM LE
+//!     ↓ mpsc::Sender<PcmChunk>
+//! ByteStreamReader (AsyncRead)
+//!     ↓ encode_flac_stream()  |  encode_ogg_opus_stream()
+//!     ↓                       |  en-tête WAV/AIFF + PCM (BE en AIFF/LPCM)
+//!     ↓ tokio::io::duplex pipe (256 KB)
+//!     ↓ TranscodeStream (AsyncRead) → Body HTTP
+//! ```
+//!
+//! La fréquence d'échantillonnage n'est connue qu'à l'arrivée du premier
+//! chunk : placer un `ResamplingNode` en amont pour imposer une fréquence.
+//! Opus impose 48 kHz (ou 8, 12, 16, 24 kHz) et 16 bits en entrée.
+//!
+//! # Fin de flux
+//!
+//! Le sink s'arrête sur `EndOfStream` ou à la fermeture de son entrée :
+//! l'encodeur vide ses dernières données puis ferme le pipe, et le client
+//! HTTP reçoit la fin de la réponse sans attendre de timeout.
+//!
+//! En WAV et AIFF, les tailles de l'en-tête sont inconnues par défaut. Avec
+//! [`TranscodeFormat::exact_length`], si le `TrackBoundary` de la source
+//! annonce une durée (source finie), l'en-tête déclare la taille exacte (en
+//! RF64 au-delà de 4 Go pour le WAV) et les données sont complétées par du
+//! silence ou tronquées pour la respecter. Certains renderers refusent en
+//! effet les tailles maximales des flux ouverts.
+//!
+//! # Ordre des octets
+//!
+//! Les chunks sont convertis en PCM little-endian ; AIFF et LPCM imposent le
+//! big-endian (ordre réseau) : chaque échantillon est retourné à l'écriture.
+
+use std::io;
+use std::pin::Pin;
+use std::sync::Arc;
+use std::task::{Context, Poll};
+use std::time::Duration;
+
+use async_trait::async_trait;
+use pmoaudio::{
+    pipeline::{AudioPipelineNode, Node, NodeLogic, PipelineHandle, StopReason},
+    AudioError, AudioSegment, SyncMarker, TypeRequirement, TypedAudioNode, _AudioSegment,
+};
+use pmoflac::{EncoderOptions, OpusEncoderOptions, PcmFormat, DEFAULT_OPUS_BITRATE};
+use tokio::io::{AsyncRead, AsyncReadExt, AsyncWrite, AsyncWriteExt, DuplexStream, ReadBuf};
+use tokio::sync::mpsc;
+use tokio_util::sync::CancellationToken;
+use tracing::debug;
+
+use crate::sinks::byte_stream_reader::{ByteStreamReader, PcmChunk};
+use crate::sinks::chunk_to_pcm::chunk_to_pcm_bytes;
+
+/// Nombre de canaux produits (les chunks pmoaudio sont toujours stéréo).
+pub const TRANSCODE_CHANNELS: u8 = 2;
+
+/// Capacité du pipe duplex.
+const PIPE_CAPACITY: usize = 256 * 1024;
+
+/// Taille des blocs de silence écrits pour compléter un WAV dimensionné.
+const WAV_PADDING_BLOCK: usize = 64 * 1024;
+
+/// Taille du tampon de conversion big-endian.
+const PCM_COPY_BLOCK: usize = 64 * 1024;
+
+/// Conteneur de sortie du transcodage.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum TranscodeContainer {
+    Flac,
+    Wav,
+    Aiff,
+    /// PCM brut big-endian, sans en-tête (DLNA LPCM).
+    Lpcm,
+    /// Opus avec perte, pour les liens à faible débit.
+    OggOpus,
+}
+
+impl TranscodeContainer {
+    /// Type MIME servi au client.
+    ///
+    /// Pour le LPCM, le type complet porte aussi la profondeur, la fréquence
+    /// et le nombre de canaux : voir [`TranscodeFormat::mime_type`].
+    pub fn mime_type(&self) -> &'static str {
+        match self {
+            TranscodeContainer::Flac => "audio/flac",
+            TranscodeContainer::Wav => "audio/wav",
+            TranscodeContainer::Aiff => "audio/aiff",
+            TranscodeContainer::Lpcm => "audio/L16",
+            TranscodeContainer::OggOpus => "audio/ogg",
+        }
+    }
+
+    /// Les échantillons sont-ils écrits en big-endian ?
+    fn big_endian(&self) -> bool {
+        matches!(self, TranscodeContainer::Aiff | TranscodeContainer::Lpcm)
+    }
+}
+
+/// Format de sortie : conteneur et profondeur (16, 24 ou 32 bits).
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct TranscodeFormat {
+    pub container: TranscodeContainer,
+    pub bits_per_sample: u8,
+    /// En WAV et AIFF, déclare les tailles exactes dans l'en-tête quand la
+    /// durée de la source est connue (sans effet en FLAC, LPCM et Opus).
+    pub exact_length: bool,
+    /// Débit cible en Opus, en bits/s (None = 96 kbit/s).
+    pub bitrate: Option<u32>,
+}
+
+impl TranscodeFormat {
+    /// Type MIME complet.
+    ///
+    /// En LPCM : `audio/L16;rate=44100;channels=2` (RFC 2586) ou `audio/L24`
+    /// (RFC 3190). La fréquence n'est indiquée que si elle est connue.
+    pub fn mime_type(&self, sample_rate: Option<u32>) -> String {
+        match self.container {
+            TranscodeContainer::Lpcm => {
+                let mut mime = format!("audio/L{}", self.bits_per_sample);
+                if let Some(rate) = sample_rate {
+                    mime.push_str(&format!(";rate={}", rate));
+                }
+                mime.push_str(&format!(";channels={}", TRANSCODE_CHANNELS));
+                mime
+            }
+            container => container.mime_type().to_string(),
+        }
+    }
+}
+
+// ─── Stream public ────────────────────────────────────────────────────────────
+
+/// Flux encodé exposé au handler HTTP.
+pub struct TranscodeStream {
+    inner: DuplexStream,
+}
+
+impl AsyncRead for TranscodeStream {
+    fn poll_read(
+        mut self: Pin<&mut Self>,
+        cx: &mut Context<'_>,
+        buf: &mut ReadBuf<'_>,
+    ) -> Poll<io::Result<()>> {
+        Pin::new(&mut self.inner).poll_read(cx, buf)
+    }
+}
+
+// ─── Logique du nœud ─────────────────────────────────────────────────────────
+
+struct TranscodeSinkLogic {
+    format: TranscodeFormat,
+    encoder_options: EncoderOptions,
+    /// Côté écriture du pipe, consommé au démarrage de l'encodeur.
+    pipe_writer: Option<DuplexStream>,
+    pcm_tx: Option<mpsc::Sender<PcmChunk>>,
+    sample_rate: u32,
+    /// Durée annoncée par le dernier `TrackBoundary`.
+    track_duration: Option<Duration>,
+}
+
+impl TranscodeSinkLogic {
+    fn start_encoder(&mut self, sample_rate: u32) -> Option<mpsc::Sender<PcmChunk>> {
+        let pipe_writer = self.pipe_writer.take()?;
+        let (pcm_tx, pcm_rx) = mpsc::channel::<PcmChunk>(8);
+        let current_ts = Arc::new(tokio::sync::RwLock::new(0.0f64));
+        let current_dur = Arc::new(tokio::sync::RwLock::new(0.0f64));
+        let pcm_reader = ByteStreamReader::new(pcm_rx, current_ts, current_dur);
+
+        let pcm_format = PcmFormat {
+            sample_rate,
+            channels: TRANSCODE_CHANNELS,
+            bits_per_sample: self.format.bits_per_sample,
+        };
+        let container = self.format.container;
+        let options = self.encoder_options.clone();
+        let opus_options = OpusEncoderOptions {
+            bitrate: self.format.bitrate.unwrap_or(DEFAULT_OPUS_BITRATE),
+        };
+        let data_len = match (container, self.format.exact_length, self.track_duration) {
+            (TranscodeContainer::Wav, true, Some(duration)) => {
+                Some(wav_data_len(&pcm_format, duration))
+            }
+            // Pas d'équivalent RF64 en AIFF : au-delà, flux de longueur inconnue.
+            (TranscodeContainer::Aiff, true, Some(duration)) => {
+                Some(wav_data_len(&pcm_format, duration)).filter(|len| *len <= AIFF_MAX_DATA_LEN)
+            }
+            _ => None,
+        };
+        debug!(
+            "TranscodeSink: starting {:?} encoder at {} Hz / {} bits",
+            container, sample_rate, pcm_format.bits_per_sample
+        );
+        tokio::spawn(async move {
+            let result = match container {
+                TranscodeContainer::Flac => {
+                    run_flac_encoder(pcm_reader, pipe_writer, pcm_format, options).await
+                }
+                TranscodeContainer::OggOpus => {
+                    run_opus_encoder(pcm_reader, pipe_writer, pcm_format, opus_options).await
+                }
+                TranscodeContainer::Wav | TranscodeContainer::Aiff | TranscodeContainer::Lpcm => {
+                    run_pcm_writer(pcm_reader, pipe_writer, container, pcm_format, data_len).await
+                }
+            };
+            if let Err(e) = result {
+                debug!("TranscodeSink encoder stopped: {}", e);
+            }
+        });
+
+        self.sample_rate = sample_rate;
+        self.pcm_tx = Some(pcm_tx.clone());
+        Some(pcm_tx)
+    }
+}
+
+#[async_trait]
+impl NodeLogic for TranscodeSinkLogic {
+    async fn process(
+        &mut self,
+        input: Option<mpsc::Receiver<Arc<AudioSegment>>>,
+        _output: Vec<mpsc::Sender<Arc<AudioSegment>>>,
+        stop_token: CancellationToken,
+    ) -> Result<(), AudioError> {
+        let mut input = input.ok_or_else(|| {
+            AudioError::ProcessingError("TranscodeSink requires an input".into())
+        })?;
+
+        loop {
+            let segment = tokio::select! {
+                _ = stop_token.cancelled() => {
+                    debug!("TranscodeSink: cancelled");
+                    break;
+                }
+                segment = input.recv() => segment,
+            };
+
+            let Some(seg) = segment else {
+                debug!("TranscodeSink: input channel closed");
+                break;
+            };
+
+            let chunk = match &seg.segment {
+                _AudioSegment::Chunk(chunk) => chunk,
+                _AudioSegment::Sync(marker) => match marker.as_ref() {
+                    SyncMarker::TrackBoundary { metadata, .. } => {
+                        self.track_duration =
+                            metadata.read().await.get_duration().await.ok().flatten();
+                        continue;
+                    }
+                    SyncMarker::EndOfStream => {
+                        debug!("TranscodeSink: end of stream");
+                        break;
+                    }
+                    _ => continue,
+                },
+            };
+
+            if chunk.len() == 0 {
+                continue;
+            }
+
+            let tx = match self.pcm_tx.clone() {
+                Some(tx) => {
+                    if chunk.sample_rate() != self.sample_rate {
+                        return Err(AudioError::ProcessingError(format!(
+                            "TranscodeSink: sample rate changed from {} to {} Hz",
+                            self.sample_rate,
+                            chunk.sample_rate()
+                        )));
+                    }
+                    tx
+                }
+                None => match self.start_encoder(chunk.sample_rate()) {
+                    Some(tx) => tx,
+                    None => break,
+                },
+            };
+
+            let pcm_chunk = PcmChunk {
+                bytes: chunk_to_pcm_bytes(chunk, self.format.bits_per_sample)?,
+                timestamp_sec: seg.timestamp_sec,
+                duration_sec: chunk.len() as f64 / self.sample_rate as f64,
+            };
+            if tx.send(pcm_chunk).await.is_err() {
+                // Client HTTP déconnecté : inutile de continuer à décoder.
+                debug!("TranscodeSink: client disconnected");
+                break;
+            }
+        }
+
+        // Fermer le canal PCM pour terminer proprement l'encodeur.
+        self.pcm_tx = None;
+        Ok(())
+    }
+
+    async fn cleanup(&mut self, _reason: StopReason) -> Result<(), AudioError> {
+        self.pcm_tx = None;
+        self.pipe_writer = None;
+        Ok(())
+    }
+}
+
+// ─── Encodeurs ───────────────────────────────────────────────────────────────
+
+async fn run_flac_encoder(
+    pcm_reader: ByteStreamReader,
+    mut pipe_writer: DuplexStream,
+    format: PcmFormat,
+    options: EncoderOptions,
+) -> Result<(), AudioError> {
+    let mut flac_stream = pmoflac::encode_flac_stream(pcm_reader, format, options)
+        .await
+        .map_err(|e| AudioError::ProcessingError(format!("FLAC encoder init: {}", e)))?;
+
+    tokio::io::copy(&mut flac_stream, &mut pipe_writer)
+        .await
+        .map_err(|e| AudioError::IoError(format!("FLAC pipe copy: {}", e)))?;
+
+    flac_stream
+        .wait()
+        .await
+        .map_err(|e| AudioError::ProcessingError(format!("FLAC encoder wait: {}", e)))?;
+
+    Ok(())
+}
+
+async fn run_opus_encoder(
+    pcm_reader: ByteStreamReader,
+    mut pipe_writer: DuplexStream,
+    format: PcmFormat,
+    options: OpusEncoderOptions,
+) -> Result<(), AudioError> {
+    let mut opus_stream = pmoflac::encode_ogg_opus_stream(pcm_reader, format, options)
+        .await
+        .map_err(|e| AudioError::ProcessingError(format!("Opus encoder init: {}", e)))?;
+
+    tokio::io::copy(&mut opus_stream, &mut pipe_writer)
+        .await
+        .map_err(|e| AudioError::IoError(format!("Opus pipe copy: {}", e)))?;
+
+    opus_stream
+        .wait()
+        .await
+        .map_err(|e| AudioError::ProcessingError(format!("Opus encoder wait: {}", e)))?;
+
+    Ok(())
+}
+
+async fn run_pcm_writer(
+    mut pcm_reader: ByteStreamReader,
+    mut pipe_writer: DuplexStream,
+    container: TranscodeContainer,
+    format: PcmFormat,
+    data_len: Option<u64>,
+) -> Result<(), AudioError> {
+    let header = match container {
+        TranscodeContainer::Wav => wav_header(&format, data_len),
+        TranscodeContainer::Aiff => aiff_header(&format, data_len),
+        _ => Vec::new(),
+    };
+    pipe_writer
+        .write_all(&header)
+        .await
+        .map_err(|e| AudioError::IoError(format!("{:?} header write: {}", container, e)))?;
+
+    // Largeur des échantillons à retourner (0 : PCM little-endian inchangé).
+    let swap_width = if container.big_endian() {
+        format.bits_per_sample as usize / 8
+    } else {
+        0
+    };
+
+    match data_len {
+        None => {
+            copy_pcm(&mut pcm_reader, &mut pipe_writer, swap_width)
+                .await
+                .map_err(|e| AudioError::IoError(format!("PCM pipe copy: {}", e)))?;
+        }
+        Some(len) => {
+            let copied = copy_pcm(
+                &mut (&mut pcm_reader).take(len),
+                &mut pipe_writer,
+                swap_width,
+            )
+            .await
+            .map_err(|e| AudioError::IoError(format!("PCM pipe copy: {}", e)))?;
+
+            // Source plus courte que sa durée annoncée : compléter par du silence.
+            let mut missing = len - copied;
+            let silence = vec![0u8; WAV_PADDING_BLOCK];
+            while missing > 0 {
+                let n = missing.min(WAV_PADDING_BLOCK as u64) as usize;
+                pipe_writer
+                    .write_all(&silence[..n])
+                    .await
+                    .map_err(|e| AudioError::IoError(format!("PCM padding write: {}", e)))?;
+                missing -= n as u64;
+            }
+            if copied < len {
+                debug!(
+                    "TranscodeSink: {:?} padded with {} bytes of silence",
+                    container,
+                    len - copied
+                );
+            }
+
+            // Source plus longue : ignorer l'excédent sans bloquer le pipeline.
+            let extra = tokio::io::copy(&mut pcm_reader, &mut tokio::io::sink())
+                .await
+                .map_err(|e| AudioError::IoError(format!("PCM drain: {}", e)))?;
+            if extra > 0 {
+                debug!(
+                    "TranscodeSink: {:?} truncated, {} bytes dropped",
+                    container, extra
+                );
+            }
+        }
+    }
+
+    pipe_writer
+        .shutdown()
+        .await
+        .map_err(|e| AudioError::IoError(format!("PCM pipe shutdown: {}", e)))?;
+
+    Ok(())
+}
+
+/// Copie le PCM en retournant chaque échantillon de `swap_width` octets
+/// (little-endian → big-endian). Avec `swap_width` ≤ 1, copie telle quelle.
+///
+/// Un échantillon peut être coupé entre deux lectures : ses premiers octets
+/// sont conservés en tête du tampon jusqu'à la lecture suivante. Un
+/// échantillon incomplet en fin de flux est abandonné.
+async fn copy_pcm<R, W>(reader: &mut R, writer: &mut W, swap_width: usize) -> io::Result<u64>
+where
+    R: AsyncRead + Unpin,
+    W: AsyncWrite + Unpin,
+{
+    if swap_width <= 1 {
+        return tokio::io::copy(reader, writer).await;
+    }
+
+    let mut buf = vec![0u8; PCM_COPY_BLOCK];
+    let mut pending = 0;
+    let mut total = 0u64;
+    loop {
+        let n = reader.read(&mut buf[pending..]).await?;
+        if n == 0 {
+            break;
+        }
+        let available = pending + n;
+        let whole = available - available % swap_width;
+        swap_samples(&mut buf[..whole], swap_width);
+        writer.write_all(&buf[..whole]).await?;
+        total += whole as u64;
+        buf.copy_within(whole..available, 0);
+        pending = available - whole;
+    }
+    Ok(total)
+}
+
+/// Inverse l'ordre des octets de chaque échantillon de `width` octets.
+fn swap_samples(bytes: &mut [u8], width: usize) {
+    for sample in bytes.chunks_exact_mut(width) {
+        sample.reverse();
+    }
+}
+
+/// Taille des données PCM d'une source de durée `duration`.
+fn wav_data_len(format: &PcmFormat, duration: Duration) -> u64 {
+    let block_align = format.channels as u64 * (format.bits_per_sample as u64 / 8);
+    let frames = (duration.as_secs_f64() * format.sample_rate as f64).round() as u64;
+    frames * block_align
+}
+
+/// Taille de l'en-tête RIFF/WAVE classique, hors données.
+const WAV_HEADER_LEN: u64 = 44;
+
+/// En-tête WAV.
+///
+/// - Sans `data_len`, les tailles RIFF et `data` valent `0xFFFFFFFF`,
+///   convention comprise par la plupart des lecteurs pour un flux ouvert.
+/// - Avec `data_len`, l'en-tête RIFF déclare les tailles exactes.
+/// - Au-delà de 4 Go, l'en-tête passe en RF64 (EBU Tech 3306) : les tailles
+///   sur 64 bits sont portées par le chunk `ds64`.
+fn wav_header(format: &PcmFormat, data_len: Option<u64>) -> Vec<u8> {
+    let channels = format.channels as u16;
+    let bits = format.bits_per_sample as u16;
+    let block_align = channels * (bits / 8);
+    let byte_rate = format.sample_rate * block_align as u32;
+
+    let riff_size = data_len.map(|len| len + WAV_HEADER_LEN - 8);
+    let rf64 = riff_size.is_some_and(|size| size > u32::MAX as u64);
+    let size32 = |size: Option<u64>| match size {
+        Some(size) if !rf64 => size as u32,
+        _ => u32::MAX,
+    };
+
+    let mut header = Vec::with_capacity(80);
+    if rf64 {
+        let data_len = data_len.unwrap_or_default();
+        header.extend_from_slice(b"RF64");
+        header.extend_from_slice(&u32::MAX.to_le_bytes());
+        header.extend_from_slice(b"WAVE");
+        header.extend_from_slice(b"ds64");
+        header.extend_from_slice(&28u32.to_le_bytes());
+        // La taille RIFF compte aussi le chunk ds64 (8 + 28 octets)
+        header.extend_from_slice(&(data_len + WAV_HEADER_LEN - 8 + 36).to_le_bytes());
+        header.extend_from_slice(&data_len.to_le_bytes());
+        header.extend_from_slice(&(data_len / block_align as u64).to_le_bytes());
+        header.extend_from_slice(&0u32.to_le_bytes()); // pas de table
+    } else {
+        header.extend_from_slice(b"RIFF");
+        header.extend_from_slice(&size32(riff_size).to_le_bytes());
+        header.extend_from_slice(b"WAVE");
+    }
+    header.extend_from_slice(b"fmt ");
+    header.extend_from_slice(&16u32.to_le_bytes());
+    header.extend_from_slice(&1u16.to_le_bytes()); // PCM
+    header.extend_from_slice(&channels.to_le_bytes());
+    header.extend_from_slice(&format.sample_rate.to_le_bytes());
+    header.extend_from_slice(&byte_rate.to_le_bytes());
+    header.extend_from_slice(&block_align.to_le_bytes());
+    header.extend_from_slice(&bits.to_le_bytes());
+    header.extend_from_slice(b"data");
+    header.extend_from_slice(&size32(data_len).to_le_bytes());
+    header
+}
+
+/// Taille de l'en-tête FORM/AIFF (chunks `COMM` et `SSND`), hors données.
+const AIFF_HEADER_LEN: u64 = 54;
+
+/// Plus grande taille de données représentable dans un en-tête AIFF.
+const AIFF_MAX_DATA_LEN: u64 = u32::MAX as u64 - (AIFF_HEADER_LEN - 8);
+
+/// En-tête AIFF.
+///
+/// Sans `data_len`, l'en-tête déclare la plus grande taille représentable
+/// (alignée sur une trame), faute de convention pour les flux ouverts.
+fn aiff_header(format: &PcmFormat, data_len: Option<u64>) -> Vec<u8> {
+    let channels = format.channels as u16;
+    let bits = format.bits_per_sample as u16;
+    let block_align = channels as u64 * (bits as u64 / 8);
+
+    let data_len = data_len
+        .filter(|len| *len <= AIFF_MAX_DATA_LEN)
+        .unwrap_or(AIFF_MAX_DATA_LEN / block_align * block_align);
+    let frames = (data_len / block_align) as u32;
+
+    let mut header = Vec::with_capacity(AIFF_HEADER_LEN as usize);
+    header.extend_from_slice(b"FORM");
+    header.extend_from_slice(&((data_len + AIFF_HEADER_LEN - 8) as u32).to_be_bytes());
+    header.extend_from_slice(b"AIFF");
+    header.extend_from_slice(b"COMM");
+    header.extend_from_slice(&18u32.to_be_bytes());
+    header.extend_from_slice(&channels.to_be_bytes());
+    header.extend_from_slice(&frames.to_be_bytes());
+    header.extend_from_slice(&bits.to_be_bytes());
+    header.extend_from_slice(&extended_sample_rate(format.sample_rate));
+    header.extend_from_slice(b"SSND");
+    header.extend_from_slice(&((data_len + 8) as u32).to_be_bytes());
+    header.extend_from_slice(&0u32.to_be_bytes()); // offset
+    header.extend_from_slice(&0u32.to_be_bytes()); // blockSize
+    header
+}
+
+/// Fréquence au format flottant étendu 80 bits IEEE 754 (champ `sampleRate`
+/// du chunk `COMM`).
+fn extended_sample_rate(rate: u32) -> [u8; 10] {
+    let mut bytes = [0u8; 10];
+    if rate == 0 {
+        return bytes;
+    }
+    let shift = (rate as u64).leading_zeros();
+    let exponent = 16_383 + 63 - shift as u16;
+    bytes[..2].copy_from_slice(&exponent.to_be_bytes());
+    bytes[2..].copy_from_slice(&((rate as u64) << shift).to_be_bytes());
+    bytes
+}
+
+// ─── Nœud public ─────────────────────────────────────────────────────────────
+
+pub struct TranscodeSink {
+    inner: Node<TranscodeSinkLogic>,
+}
+
+impl TranscodeSink {
+    /// Crée le sink et le flux encodé à servir au client.
+    pub fn new(format: TranscodeFormat, encoder_options: EncoderOptions) -> (Self, TranscodeStream) {
+        let (pipe_writer, pipe_reader) = tokio::io::duplex(PIPE_CAPACITY);
+
+        let logic = TranscodeSinkLogic {
+            format,
+            encoder_options,
+            pipe_writer: Some(pipe_writer),
+            pcm_tx: None,
+            sample_rate: 0,
+            track_duration: None,
+        };
+
+        let sink = Self {
+            inner: Node::new_with_input(logic, 16),
+        };
+
+        (sink, TranscodeStream { inner: pipe_reader })
+    }
+}
+
+#[async_trait]
+impl AudioPipelineNode for TranscodeSink {
+    fn get_tx(&self) -> Option<mpsc::Sender<Arc<AudioSegment>>> {
+        self.inner.get_tx()
+    }
+
+    fn register(&mut self, _child: Box<dyn AudioPipelineNode>) {
+        panic!("TranscodeSink is a terminal sink and cannot have children");
+    }
+
+    async fn run(self: Box<Self>, stop_token: CancellationToken) -> Result<(), AudioError> {
+        Box::new(self.inner).run(stop_token).await
+    }
+
+    fn start(self: Box<Self>) -> PipelineHandle {
+        Box::new(self.inner).start()
+    }
+}
+
+impl TypedAudioNode for TranscodeSink {
+    fn input_type(&self) -> Option<TypeRequirement> {
+        Some(TypeRequirement::any_integer())
+    }
+
+    fn output_type(&self) -> Option<TypeRequirement> {
+        None
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn wav_header_describes_stream() {
+        let header = wav_header(
+            &PcmFormat {
+                sample_rate: 44_100,
+                channels: 2,
+                bits_per_sample: 16,
+            },
+            None,
+        );
+        assert_eq!(header.len(), 44);
+        assert_eq!(&header[0..4], b"RIFF");
+        assert_eq!(&header[8..12], b"WAVE");
+        assert_eq!(u32::from_le_bytes(header[24..28].try_into().unwrap()), 44_100);
+        assert_eq!(u32::from_le_bytes(header[28..32].try_into().unwrap()), 176_400);
+        assert_eq!(u16::from_le_bytes(header[32..34].try_into().unwrap()), 4);
+        assert_eq!(&header[36..40], b"data");
+        assert_eq!(u32::from_le_bytes(header[4..8].try_into().unwrap()), u32::MAX);
+        assert_eq!(u32::from_le_bytes(header[40..44].try_into().unwrap()), u32::MAX);
+    }
+
+    #[test]
+    fn wav_header_declares_finite_length() {
+        let format = PcmFormat {
+            sample_rate: 48_000,
+            channels: 2,
+            bits_per_sample: 24,
+        };
+        let data_len = wav_data_len(&format, Duration::from_millis(1500));
+        assert_eq!(data_len, 72_000 * 6);
+
+        let header = wav_header(&format, Some(data_len));
+        assert_eq!(
+            u32::from_le_bytes(header[4..8].try_into().unwrap()),
+            data_len as u32 + 36
+        );
+        assert_eq!(
+            u32::from_le_bytes(header[40..44].try_into().unwrap()),
+            data_len as u32
+        );
+    }
+
+    #[test]
+    fn wav_header_switches_to_rf64_beyond_4gb() {
+        let format = PcmFormat {
+            sample_rate: 192_000,
+            channels: 2,
+            bits_per_sample: 24,
+        };
+        let data_len = 6 * 1_000_000_000;
+        let header = wav_header(&format, Some(data_len));
+        let u32_at = |at: usize| u32::from_le_bytes(header[at..at + 4].try_into().unwrap());
+        let u64_at = |at: usize| u64::from_le_bytes(header[at..at + 8].try_into().unwrap());
+
+        assert_eq!(header.len(), 80);
+        assert_eq!(&header[0..4], b"RF64");
+        assert_eq!(u32_at(4), u32::MAX);
+        assert_eq!(&header[12..16], b"ds64");
+        assert_eq!(u32_at(16), 28);
+        assert_eq!(u64_at(20), data_len + 72);
+        assert_eq!(u64_at(28), data_len);
+        assert_eq!(u64_at(36), 1_000_000_000);
+        assert_eq!(&header[48..52], b"fmt ");
+        assert_eq!(&header[72..76], b"data");
+        assert_eq!(u32_at(76), u32::MAX);
+    }
+
+    #[test]
+    fn aiff_header_declares_big_endian_sizes() {
+        let format = PcmFormat {
+            sample_rate: 44_100,
+            channels: 2,
+            bits_per_sample: 16,
+        };
+        let header = aiff_header(&format, Some(176_400));
+        let u32_at = |at: usize| u32::from_be_bytes(header[at..at + 4].try_into().unwrap());
+
+        assert_eq!(header.len(), AIFF_HEADER_LEN as usize);
+        assert_eq!(&header[0..4], b"FORM");
+        assert_eq!(u32_at(4), 176_400 + 46);
+        assert_eq!(&header[8..12], b"AIFF");
+        assert_eq!(&header[12..16], b"COMM");
+        assert_eq!(u32_at(22), 44_100);
+        assert_eq!(&header[28..38], &[0x40, 0x0E, 0xAC, 0x44, 0, 0, 0, 0, 0, 0]);
+        assert_eq!(&header[38..42], b"SSND");
+        assert_eq!(u32_at(42), 176_400 + 8);
+
+        // Flux ouvert : plus grande taille alignée sur une trame.
+        let open = aiff_header(&format, None);
+        let frames = u32::from_be_bytes(open[22..26].try_into().unwrap()) as u64;
+        assert_eq!(frames, AIFF_MAX_DATA_LEN / 4);
+    }
+
+    #[test]
+    fn samples_are_swapped_to_big_endian() {
+        let mut bytes = vec![0x01, 0x02, 0x03, 0x04, 0x05, 0x06];
+        swap_samples(&mut bytes, 3);
+        assert_eq!(bytes, [0x03, 0x02, 0x01, 0x06, 0x05, 0x04]);
+        swap_samples(&mut bytes, 2);
+        assert_eq!(bytes, [0x02, 0x03, 0x06, 0x01, 0x04, 0x05]);
+    }
+}
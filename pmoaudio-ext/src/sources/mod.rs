@@ -18,10 +18,16 @@ pub use playlist_source::PlaylistSource;
 mod uri_source;
 
 #[cfg(feature = "http-stream")]
-pub use uri_source::UriSource;
+pub use uri_source::{StreamFormat, UriSource};
 
 #[cfg(feature = "http-stream")]
 mod player_source;
 
 #[cfg(feature = "http-stream")]
 pub use player_source::{PlayerCommand, PlayerEvent, PlayerHandle, PlayerSource};
+
+#[cfg(feature = "http-stream")]
+mod track_probe;
+
+#[cfg(feature = "http-stream")]
+pub use track_probe::{PROBE_BYTES, ProbedTrack, probe_track};
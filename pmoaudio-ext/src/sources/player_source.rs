@@ -26,22 +26,48 @@
 //!
 //! Si `LoadNextUri` a été appelé avant la fin de la piste courante, la transition
 //! se fait via un `TrackBoundary` sans interruption du flux OGG.
+//!
+//! # Reconstruction
+//!
+//! Le `PlayerHandle` survit au nœud : quand le pipeline est détruit (mise en
+//! veille), la file de commandes revient au handle et
+//! [`PlayerSource::attach`] crée une nouvelle source sur les mêmes canaux.
+//! L'état de transport (URI, position) n'est pas conservé.
+//!
+//! # Maintien du signal
+//!
+//! Certains récepteurs HDMI/optiques perdent la synchronisation dès que le
+//! flux s'interrompt et coupent le début de la piste suivante au réveil. Avec
+//! [`PlayerHandle::set_keepalive`], la source émet en continu un silence
+//! dithéré (TPDF, ±1 LSB en 24 bits) tant qu'elle est arrêtée ou en pause,
+//! à la fréquence de la dernière piste jouée.
 
-use std::sync::Arc;
+use std::sync::{Arc, Mutex};
+use std::time::Duration;
 
 use async_trait::async_trait;
 use pmometadata::{MemoryTrackMetadata, TrackMetadata};
 use pmoaudio::{
-    AudioSegment,
+    AudioChunk, AudioChunkData, AudioSegment, _AudioSegment,
     nodes::AudioError,
     pipeline::{AudioPipelineNode, Node, NodeLogic, send_to_children},
     StreamType,
 };
-use tokio::sync::{broadcast, mpsc};
+use rand::Rng;
+use tokio::sync::{broadcast, mpsc, watch};
 use tokio_util::sync::CancellationToken;
 use tracing::{debug, info, warn};
 
-use super::uri_source::UriSource;
+use super::uri_source::{StreamFormat, UriSource};
+
+/// Période d'émission du silence de maintien
+const KEEPALIVE_PERIOD: Duration = Duration::from_millis(50);
+
+/// Fréquence du silence de maintien avant toute lecture
+const DEFAULT_KEEPALIVE_RATE: u32 = 48_000;
+
+/// Amplitude du dither : 1 LSB en 24 bits
+const KEEPALIVE_DITHER_LSB: f32 = 1.0 / 8_388_608.0;
 
 // ─── Commandes de transport ───────────────────────────────────────────────────
 
@@ -71,6 +97,8 @@ pub enum PlayerEvent {
     Playing {
         uri: String,
         duration_sec: Option<f64>,
+        /// Codec et format PCM de la piste
+        format: StreamFormat,
     },
     /// Lecture suspendue
     Paused {
@@ -93,6 +121,10 @@ pub enum PlayerEvent {
 pub struct PlayerHandle {
     command_tx: mpsc::Sender<PlayerCommand>,
     event_tx: broadcast::Sender<PlayerEvent>,
+    /// File de commandes rendue par une source détruite, en attente d'`attach`
+    detached_rx: Arc<Mutex<Option<mpsc::Receiver<PlayerCommand>>>>,
+    /// Émission d'un silence de maintien hors lecture
+    keepalive: Arc<watch::Sender<bool>>,
 }
 
 impl PlayerHandle {
@@ -130,6 +162,23 @@ impl PlayerHandle {
     pub fn subscribe_events(&self) -> broadcast::Receiver<PlayerEvent> {
         self.event_tx.subscribe()
     }
+
+    /// Aucune source n'est attachée (pipeline détruit) ?
+    pub fn is_detached(&self) -> bool {
+        self.detached_rx.lock().unwrap().is_some()
+    }
+
+    /// Active ou désactive le silence de maintien (arrêt et pause)
+    ///
+    /// Le réglage survit à la reconstruction du pipeline.
+    pub fn set_keepalive(&self, enabled: bool) {
+        self.keepalive.send_replace(enabled);
+    }
+
+    /// Le silence de maintien est-il actif ?
+    pub fn keepalive(&self) -> bool {
+        *self.keepalive.borrow()
+    }
 }
 
 // ─── État de transport ────────────────────────────────────────────────────────
@@ -151,6 +200,20 @@ enum TransportState {
 struct PlayerSourceLogic {
     command_rx: mpsc::Receiver<PlayerCommand>,
     event_tx: broadcast::Sender<PlayerEvent>,
+    detached_rx: Arc<Mutex<Option<mpsc::Receiver<PlayerCommand>>>>,
+    keepalive_rx: watch::Receiver<bool>,
+    /// Fréquence de la dernière piste jouée, reprise par le silence de maintien
+    sample_rate: u32,
+}
+
+impl Drop for PlayerSourceLogic {
+    /// Rend la file de commandes au handle : les commandes envoyées pendant
+    /// que le pipeline est détruit restent en attente de la source suivante.
+    fn drop(&mut self) {
+        let (_, closed) = mpsc::channel(1);
+        let command_rx = std::mem::replace(&mut self.command_rx, closed);
+        *self.detached_rx.lock().unwrap() = Some(command_rx);
+    }
 }
 
 #[async_trait]
@@ -167,6 +230,12 @@ impl NodeLogic for PlayerSourceLogic {
         let mut paused_at_sec: f64 = 0.0;
         let mut is_continuous: bool = false;
 
+        let mut keepalive_tick = tokio::time::interval(KEEPALIVE_PERIOD);
+        keepalive_tick.set_missed_tick_behavior(tokio::time::MissedTickBehavior::Delay);
+        let mut keepalive_open = true;
+        // Horodatage du silence : continue d'avancer pour garder un flux monotone
+        let mut keepalive_sec: f64 = 0.0;
+
         info!("PlayerSource: started");
 
         loop {
@@ -190,6 +259,22 @@ impl NodeLogic for PlayerSourceLogic {
                                 }
                             }
                         }
+                        changed = self.keepalive_rx.changed(), if keepalive_open => {
+                            if changed.is_err() {
+                                keepalive_open = false;
+                            } else {
+                                debug!("PlayerSource: keepalive {}", *self.keepalive_rx.borrow());
+                            }
+                        }
+                        _ = keepalive_tick.tick(), if *self.keepalive_rx.borrow() => {
+                            keepalive_sec = keepalive_sec.max(paused_at_sec);
+                            let silence = dithered_silence(keepalive_sec, self.sample_rate);
+                            keepalive_sec += KEEPALIVE_PERIOD.as_secs_f64();
+                            if let Err(e) = send_to_children("PlayerSource", &output, silence).await {
+                                debug!("PlayerSource: keepalive stopped: {}", e);
+                                break;
+                            }
+                        }
                     }
                 }
 
@@ -218,6 +303,7 @@ impl NodeLogic for PlayerSourceLogic {
                     let _ = self.event_tx.send(PlayerEvent::Playing {
                         uri: uri.clone(),
                         duration_sec,
+                        format: source.format(),
                     });
                     info!("PlayerSource: playing {:?} from {:.1}s continuous={}", uri, paused_at_sec, is_continuous);
 
@@ -460,6 +546,9 @@ impl PlayerSourceLogic {
                             // Mettre à jour la position courante
                             if seg.is_audio_chunk() {
                                 *paused_at_sec = seg.timestamp_sec;
+                                if let Some(rate) = seg.sample_rate() {
+                                    self.sample_rate = rate;
+                                }
                                 // Émettre Position ~1/s
                                 let sec = paused_at_sec.floor() as i64;
                                 if sec != last_reported_sec {
@@ -523,6 +612,21 @@ async fn send_track_boundary(
     send_to_children("PlayerSource", output, boundary).await
 }
 
+/// Construit un chunk de silence dithéré (TPDF) d'une période de maintien.
+fn dithered_silence(timestamp_sec: f64, sample_rate: u32) -> Arc<AudioSegment> {
+    let frames = (sample_rate as f64 * KEEPALIVE_PERIOD.as_secs_f64()) as usize;
+    let mut rng = rand::thread_rng();
+    let mut dither =
+        || (rng.gen_range(-0.5..0.5) + rng.gen_range(-0.5..0.5)) * KEEPALIVE_DITHER_LSB;
+    let samples: Vec<[f32; 2]> = (0..frames).map(|_| [dither(), dither()]).collect();
+    let chunk = AudioChunk::F32(AudioChunkData::new(samples, sample_rate, 0.0));
+    Arc::new(AudioSegment {
+        order: 0,
+        timestamp_sec,
+        segment: _AudioSegment::Chunk(Arc::new(chunk)),
+    })
+}
+
 // ─── Nœud public ─────────────────────────────────────────────────────────────
 
 /// Source audio avec contrôle de transport AVTransport UPnP.
@@ -540,22 +644,36 @@ impl PlayerSource {
         let (command_tx, command_rx) = mpsc::channel::<PlayerCommand>(32);
         let (event_tx, _) = broadcast::channel::<PlayerEvent>(16);
 
-        let logic = PlayerSourceLogic {
-            command_rx,
-            event_tx: event_tx.clone(),
-        };
-
         let handle = PlayerHandle {
             command_tx,
             event_tx,
+            detached_rx: Arc::new(Mutex::new(None)),
+            keepalive: Arc::new(watch::channel(false).0),
         };
 
-        (
-            Self {
-                inner: Node::new_source(logic),
-            },
-            handle,
-        )
+        (Self::with_receiver(&handle, command_rx), handle)
+    }
+
+    /// Crée une source sur les canaux d'un handle dont la source précédente
+    /// a été détruite.
+    ///
+    /// Retourne `None` si une source est encore attachée au handle.
+    pub fn attach(handle: &PlayerHandle) -> Option<Self> {
+        let command_rx = handle.detached_rx.lock().unwrap().take()?;
+        Some(Self::with_receiver(handle, command_rx))
+    }
+
+    fn with_receiver(handle: &PlayerHandle, command_rx: mpsc::Receiver<PlayerCommand>) -> Self {
+        let logic = PlayerSourceLogic {
+            command_rx,
+            event_tx: handle.event_tx.clone(),
+            detached_rx: handle.detached_rx.clone(),
+            keepalive_rx: handle.keepalive.subscribe(),
+            sample_rate: DEFAULT_KEEPALIVE_RATE,
+        };
+        Self {
+            inner: Node::new_source(logic),
+        }
     }
 }
 
@@ -573,3 +691,95 @@ impl AudioPipelineNode for PlayerSource {
         Box::new(self.inner).run(stop_token).await
     }
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    /// Frames d'un segment de silence de maintien
+    fn silence_frames(segment: &AudioSegment, sample_rate: u32) -> Vec<[f32; 2]> {
+        match &segment.segment {
+            _AudioSegment::Chunk(chunk) => match &**chunk {
+                AudioChunk::F32(data) => {
+                    assert_eq!(data.get_sample_rate(), sample_rate);
+                    data.get_frames().to_vec()
+                }
+                _ => panic!("expected an F32 chunk"),
+            },
+            _ => panic!("expected a chunk"),
+        }
+    }
+
+    #[test]
+    fn test_dithered_silence_within_one_lsb() {
+        for sample_rate in [44_100, 96_000] {
+            let segment = dithered_silence(1.5, sample_rate);
+            assert_eq!(segment.timestamp_sec, 1.5);
+            let frames = silence_frames(&segment, sample_rate);
+            assert_eq!(frames.len(), sample_rate as usize / 20);
+            assert!(
+                frames
+                    .iter()
+                    .flatten()
+                    .all(|s| s.abs() <= KEEPALIVE_DITHER_LSB)
+            );
+            // Dithéré, pas un silence numérique
+            assert!(frames.iter().flatten().any(|s| *s != 0.0));
+        }
+    }
+
+    #[tokio::test]
+    async fn test_keepalive_follows_watch() {
+        let (source, handle) = PlayerSource::new();
+        // La source détruite rend sa file de commandes au handle
+        drop(source);
+        let mut logic = PlayerSourceLogic {
+            command_rx: handle.detached_rx.lock().unwrap().take().unwrap(),
+            event_tx: handle.event_tx.clone(),
+            detached_rx: handle.detached_rx.clone(),
+            keepalive_rx: handle.keepalive.subscribe(),
+            // Fréquence de la dernière piste jouée
+            sample_rate: 44_100,
+        };
+        let (tx, mut rx) = mpsc::channel(16);
+        let stop_token = CancellationToken::new();
+        let task = tokio::spawn({
+            let stop_token = stop_token.clone();
+            async move { logic.process(None, vec![tx], stop_token).await }
+        });
+        let quiet = Duration::from_millis(200);
+
+        // Arrêtée sans maintien : rien n'est émis
+        assert!(tokio::time::timeout(quiet, rx.recv()).await.is_err());
+
+        // Maintien actif : silence à la fréquence de la dernière piste
+        handle.set_keepalive(true);
+        assert!(handle.keepalive());
+        let mut timestamps = Vec::new();
+        for _ in 0..3 {
+            let segment = tokio::time::timeout(Duration::from_secs(1), rx.recv())
+                .await
+                .expect("keepalive silence expected")
+                .unwrap();
+            let frames = silence_frames(&segment, 44_100);
+            assert_eq!(frames.len(), 2_205);
+            assert!(
+                frames
+                    .iter()
+                    .flatten()
+                    .all(|s| s.abs() <= KEEPALIVE_DITHER_LSB)
+            );
+            timestamps.push(segment.timestamp_sec);
+        }
+        assert!(timestamps.windows(2).all(|w| w[1] > w[0]));
+
+        // Maintien coupé : le flux s'arrête (un segment déjà parti est toléré)
+        handle.set_keepalive(false);
+        tokio::time::sleep(Duration::from_millis(100)).await;
+        while rx.try_recv().is_ok() {}
+        assert!(tokio::time::timeout(quiet, rx.recv()).await.is_err());
+
+        stop_token.cancel();
+        task.await.unwrap().unwrap();
+    }
+}
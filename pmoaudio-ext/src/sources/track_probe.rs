@@ -0,0 +1,179 @@
+//! Sondage des métadonnées d'une URI
+//!
+//! Quand un point de contrôle envoie une URI sans métadonnées DIDL-Lite,
+//! [`probe_track`] en extrait ce qu'il peut :
+//!
+//! - fichier local : tags et propriétés lus par `lofty` ;
+//! - flux ICY (radio Icecast/Shoutcast) : en-têtes `icy-name`,
+//!   `icy-genre`, `icy-description` ;
+//! - fichier HTTP : tags lus dans les [`PROBE_BYTES`] premiers octets
+//!   (ID3v2, commentaires Vorbis/FLAC sont en tête de fichier).
+//!
+//! À défaut de tags, le titre est le nom du fichier.
+
+use std::io::Read;
+use std::path::Path;
+use std::time::Duration;
+
+use pmoflac::AudioFileMetadata;
+use tracing::debug;
+
+/// Octets lus en tête d'un fichier HTTP pour en extraire les tags
+pub const PROBE_BYTES: u64 = 256 * 1024;
+
+/// Délai maximal d'une requête de sondage
+const PROBE_TIMEOUT: Duration = Duration::from_secs(3);
+
+/// Métadonnées devinées d'une URI
+#[derive(Debug, Clone, Default, PartialEq)]
+pub struct ProbedTrack {
+    pub title: Option<String>,
+    pub artist: Option<String>,
+    pub album: Option<String>,
+    pub genre: Option<String>,
+    pub year: Option<u32>,
+    pub track_number: Option<u32>,
+    pub duration_sec: Option<u64>,
+    pub sample_rate: Option<u32>,
+    pub channels: Option<u8>,
+    /// Flux radio (en-têtes ICY)
+    pub broadcast: bool,
+}
+
+impl ProbedTrack {
+    fn from_metadata(metadata: AudioFileMetadata) -> Self {
+        Self {
+            title: metadata.title,
+            artist: metadata.artist,
+            album: metadata.album,
+            genre: metadata.genre,
+            year: metadata.year,
+            track_number: metadata.track_number,
+            duration_sec: metadata.duration_secs,
+            sample_rate: metadata.sample_rate,
+            channels: metadata.channels,
+            broadcast: false,
+        }
+    }
+}
+
+/// Devine les métadonnées de `uri` (appel bloquant, réseau compris)
+pub fn probe_track(uri: &str) -> ProbedTrack {
+    let mut track = if uri.starts_with("http://") || uri.starts_with("https://") {
+        probe_http(uri).unwrap_or_else(|e| {
+            debug!(uri = %uri, "Metadata probe failed: {}", e);
+            ProbedTrack::default()
+        })
+    } else {
+        let path = uri.strip_prefix("file://").unwrap_or(uri);
+        AudioFileMetadata::from_file(Path::new(path))
+            .map(ProbedTrack::from_metadata)
+            .unwrap_or_else(|e| {
+                debug!(uri = %uri, "Metadata probe failed: {}", e);
+                ProbedTrack::default()
+            })
+    };
+    if track.title.is_none() {
+        track.title = title_from_uri(uri);
+    }
+    track
+}
+
+fn probe_http(url: &str) -> Result<ProbedTrack, String> {
+    let agent = ureq::AgentBuilder::new().timeout(PROBE_TIMEOUT).build();
+    let response = agent
+        .get(url)
+        .set("Range", &format!("bytes=0-{}", PROBE_BYTES - 1))
+        .call()
+        .map_err(|e| format!("HTTP GET failed: {}", e))?;
+
+    let icy = |name: &str| {
+        response
+            .header(name)
+            .map(str::trim)
+            .filter(|v| !v.is_empty())
+            .map(String::from)
+    };
+    if let Some(name) = icy("icy-name") {
+        return Ok(ProbedTrack {
+            title: Some(name),
+            artist: icy("icy-description"),
+            genre: icy("icy-genre"),
+            broadcast: true,
+            ..Default::default()
+        });
+    }
+
+    let mut head = Vec::new();
+    response
+        .into_reader()
+        .take(PROBE_BYTES)
+        .read_to_end(&mut head)
+        .map_err(|e| format!("HTTP read failed: {}", e))?;
+    AudioFileMetadata::from_bytes(&head)
+        .map(ProbedTrack::from_metadata)
+        .map_err(|e| format!("no tags: {}", e))
+}
+
+/// Nom du fichier désigné par l'URI, sans extension ni encodage `%XX`
+fn title_from_uri(uri: &str) -> Option<String> {
+    let path = uri.split(['?', '#']).next().unwrap_or(uri);
+    let name = path.trim_end_matches('/').rsplit('/').next()?;
+    let stem = name.rsplit_once('.').map_or(name, |(stem, _)| stem);
+    let title = percent_decode(stem).replace('_', " ");
+    let title = title.trim();
+    (!title.is_empty()).then(|| title.to_string())
+}
+
+fn percent_decode(s: &str) -> String {
+    let bytes = s.as_bytes();
+    let mut out = Vec::with_capacity(bytes.len());
+    let mut i = 0;
+    while i < bytes.len() {
+        let hex = (bytes[i] == b'%')
+            .then(|| s.get(i + 1..i + 3))
+            .flatten()
+            .and_then(|h| u8::from_str_radix(h, 16).ok());
+        match hex {
+            Some(b) => {
+                out.push(b);
+                i += 3;
+            }
+            None => {
+                out.push(bytes[i]);
+                i += 1;
+            }
+        }
+    }
+    String::from_utf8_lossy(&out).into_owned()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_title_from_uri() {
+        assert_eq!(
+            title_from_uri("http://nas:8200/music/01%20Blue_in_Green.flac?x=1").as_deref(),
+            Some("01 Blue in Green")
+        );
+        assert_eq!(
+            title_from_uri("file:///music/Kind of Blue/So What.mp3").as_deref(),
+            Some("So What")
+        );
+        assert_eq!(
+            title_from_uri("http://radio.example/").as_deref(),
+            Some("radio")
+        );
+        assert_eq!(percent_decode("caf%C3%A9%zz"), "café%zz");
+    }
+
+    #[test]
+    fn test_probe_missing_file() {
+        let track = probe_track("/nonexistent/Some_Track.flac");
+        assert_eq!(track.title.as_deref(), Some("Some Track"));
+        assert_eq!(track.artist, None);
+        assert!(!track.broadcast);
+    }
+}
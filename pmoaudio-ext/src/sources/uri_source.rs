@@ -34,7 +34,7 @@
 use std::sync::Arc;
 
 use pmoaudio::{AudioSegment, nodes::AudioError};
-use pmoflac::{StreamInfo, decode_audio_stream};
+use pmoflac::{AudioCodec, StreamInfo, decode_audio_stream};
 use tokio::io::AsyncReadExt;
 use tokio::sync::mpsc;
 use tokio_util::sync::CancellationToken;
@@ -44,10 +44,20 @@ use super::pcm_decode::{bytes_to_segment, validate_stream};
 
 const CHUNK_FRAMES: usize = 2048; // ~46ms @ 44.1kHz
 
+/// Format du flux décodé (avant tout traitement)
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct StreamFormat {
+    pub codec: AudioCodec,
+    pub sample_rate: u32,
+    pub bits_per_sample: u8,
+    pub channels: u8,
+}
+
 /// Source audio ouverte depuis une URI, prête à émettre des segments.
 pub struct UriSource {
     reader: Box<dyn tokio::io::AsyncRead + Send + Unpin>,
     stream_info: StreamInfo,
+    codec: AudioCodec,
     frames_to_skip: u64,
     /// true si c'est un flux continu (radio, stream) sans durée définie
     pub is_continuous: bool,
@@ -90,6 +100,16 @@ impl UriSource {
         })
     }
 
+    /// Codec et format PCM du flux source
+    pub fn format(&self) -> StreamFormat {
+        StreamFormat {
+            codec: self.codec,
+            sample_rate: self.stream_info.sample_rate,
+            bits_per_sample: self.stream_info.bits_per_sample,
+            channels: self.stream_info.channels,
+        }
+    }
+
     /// Retourne true si c'est un flux continu (radio, stream) sans durée définie.
     pub fn is_continuous(&self) -> bool {
         self.is_continuous
@@ -193,6 +213,7 @@ impl UriSource {
             .map_err(|e| AudioError::ProcessingError(format!("Decode error: {}", e)))?;
 
         let stream_info = stream.info().clone();
+        let codec = stream.codec();
         validate_stream(&stream_info)?;
 
         let frames_to_skip = (seek_sec * stream_info.sample_rate as f64) as u64;
@@ -208,7 +229,7 @@ impl UriSource {
         );
 
         let (_, reader) = stream.into_reader();
-        Ok(Self { reader: Box::new(reader), stream_info, frames_to_skip, is_continuous: false })
+        Ok(Self { reader: Box::new(reader), stream_info, codec, frames_to_skip, is_continuous: false })
     }
 
     async fn open_http(
@@ -249,6 +270,7 @@ impl UriSource {
             .map_err(|e| AudioError::ProcessingError(format!("Decode error: {}", e)))?;
 
         let stream_info = stream.info().clone();
+        let codec = stream.codec();
         validate_stream(&stream_info)?;
 
         let frames_to_skip = (seek_sec * stream_info.sample_rate as f64) as u64;
@@ -262,6 +284,7 @@ impl UriSource {
         Ok(Self { 
             reader: Box::new(reader), 
             stream_info, 
+            codec,
             frames_to_skip,
             is_continuous,
         })
@@ -5,7 +5,7 @@
 
 use std::sync::Arc;
 
-use crate::{dsp, AudioChunk, AudioChunkData, BitDepth, I24};
+use crate::{dsp, dsp::Ditherer, AudioChunk, AudioChunkData, BitDepth, I24};
 
 // ============================================================================
 // Conversions int → int (changement de bit depth)
@@ -389,6 +389,43 @@ impl AudioChunk {
             AudioChunk::F64(d) => AudioChunk::I16(convert_f64_to_i16(d)),
         }
     }
+
+    /// Convertit ce chunk vers i16 en appliquant le dither de `ditherer`
+    ///
+    /// Les chunks déjà en 16 bits sont conservés tels quels.
+    pub fn to_i16_dithered(&self, ditherer: &mut Ditherer) -> AudioChunk {
+        fn lsb16<T: Copy>(
+            frames: &[[T; 2]],
+            scale: impl Fn(T) -> f64,
+        ) -> impl Iterator<Item = [f64; 2]> + '_ {
+            frames.iter().map(move |[l, r]| [scale(*l), scale(*r)])
+        }
+
+        let (stereo, sample_rate, gain_db) = match self {
+            AudioChunk::I16(d) => return AudioChunk::I16(d.clone()),
+            AudioChunk::I24(d) => (
+                ditherer.quantize_i16(lsb16(d.get_frames(), |s: I24| s.as_i32() as f64 / 256.0)),
+                d.get_sample_rate(),
+                d.get_gain_db(),
+            ),
+            AudioChunk::I32(d) => (
+                ditherer.quantize_i16(lsb16(d.get_frames(), |s: i32| s as f64 / 65_536.0)),
+                d.get_sample_rate(),
+                d.get_gain_db(),
+            ),
+            AudioChunk::F32(d) => (
+                ditherer.quantize_i16(lsb16(d.get_frames(), |s: f32| s as f64 * 32_768.0)),
+                d.get_sample_rate(),
+                d.get_gain_db(),
+            ),
+            AudioChunk::F64(d) => (
+                ditherer.quantize_i16(lsb16(d.get_frames(), |s: f64| s * 32_768.0)),
+                d.get_sample_rate(),
+                d.get_gain_db(),
+            ),
+        };
+        AudioChunk::I16(AudioChunkData::new(stereo, sample_rate, gain_db))
+    }
 }
 
 // ============================================================================
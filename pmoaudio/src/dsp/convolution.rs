@@ -0,0 +1,272 @@
+//! Convolution partitionnée pour la correction de pièce
+//!
+//! Moteur de convolution à partitions uniformes (overlap-save dans le domaine
+//! fréquentiel) : la réponse impulsionnelle est découpée en blocs de `B`
+//! frames, chacun transformé une fois pour toutes ; chaque bloc d'entrée est
+//! transformé puis multiplié par toutes les partitions via une ligne de
+//! retard fréquentielle. Le coût est en O(log B) par échantillon quelle que
+//! soit la longueur de la réponse, pour une latence fixe de `B` frames.
+//!
+//! Les réponses impulsionnelles sont chargées depuis des fichiers WAV (PCM
+//! 16/24/32 bits ou float 32 bits, mono ou stéréo), tels qu'exportés par REW.
+
+use crate::dsp::crossfade::StereoProcessor;
+use crate::dsp::wav::decode_wav;
+use crate::nodes::AudioError;
+use rustfft::{num_complex::Complex32, Fft, FftPlanner};
+use std::path::Path;
+use std::sync::Arc;
+
+/// Taille de partition par défaut (frames)
+pub const DEFAULT_PARTITION_FRAMES: usize = 1024;
+
+/// Longueur maximale d'une réponse impulsionnelle (frames, ~2.7 s à 96 kHz)
+pub const MAX_IR_FRAMES: usize = 1 << 18;
+
+// ─── Réponse impulsionnelle ──────────────────────────────────────────────────
+
+/// Réponse impulsionnelle stéréo
+#[derive(Debug, Clone)]
+pub struct ImpulseResponse {
+    /// Fréquence d'échantillonnage de la réponse
+    pub sample_rate: u32,
+    /// Réponses gauche et droite (identiques pour un fichier mono)
+    pub channels: [Vec<f32>; 2],
+}
+
+impl ImpulseResponse {
+    /// Longueur en frames
+    pub fn len(&self) -> usize {
+        self.channels[0].len()
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.channels[0].is_empty()
+    }
+
+    /// Charge une réponse impulsionnelle depuis un fichier WAV
+    pub fn load_wav(path: impl AsRef<Path>) -> Result<Self, AudioError> {
+        let path = path.as_ref();
+        let bytes = std::fs::read(path)
+            .map_err(|e| AudioError::IoError(format!("{}: {}", path.display(), e)))?;
+        Self::parse_wav(&bytes)
+    }
+
+    /// Décode une réponse impulsionnelle depuis le contenu d'un fichier WAV
+    pub fn parse_wav(bytes: &[u8]) -> Result<Self, AudioError> {
+        let wav = decode_wav(bytes, MAX_IR_FRAMES)?;
+        Ok(Self {
+            sample_rate: wav.sample_rate,
+            channels: wav.channels,
+        })
+    }
+}
+
+// ─── Convolution partitionnée ────────────────────────────────────────────────
+
+/// Convolution d'un canal
+struct ChannelConvolver {
+    /// Spectres des partitions de la réponse
+    partitions: Vec<Vec<Complex32>>,
+    /// Ligne de retard fréquentielle (spectres des blocs d'entrée récents)
+    fdl: Vec<Vec<Complex32>>,
+    /// Index du bloc le plus récent dans la ligne de retard
+    fdl_head: usize,
+    /// Deux derniers blocs d'entrée (overlap-save)
+    window: Vec<f32>,
+}
+
+impl ChannelConvolver {
+    fn new(ir: &[f32], block: usize, fft: &Arc<dyn Fft<f32>>) -> Self {
+        let size = 2 * block;
+        let count = ir.len().div_ceil(block).max(1);
+        let partitions = (0..count)
+            .map(|p| {
+                let mut spectrum = vec![Complex32::default(); size];
+                for (i, &s) in ir.iter().skip(p * block).take(block).enumerate() {
+                    spectrum[i].re = s;
+                }
+                fft.process(&mut spectrum);
+                spectrum
+            })
+            .collect();
+
+        Self {
+            partitions,
+            fdl: vec![vec![Complex32::default(); size]; count],
+            fdl_head: 0,
+            window: vec![0.0; size],
+        }
+    }
+
+    /// Convolue un bloc de `block` frames (remplacé par la sortie)
+    fn process_block(
+        &mut self,
+        samples: &mut [f32],
+        fft: &Arc<dyn Fft<f32>>,
+        ifft: &Arc<dyn Fft<f32>>,
+        scratch: &mut [Complex32],
+    ) {
+        let block = samples.len();
+        let size = 2 * block;
+
+        self.window.copy_within(block.., 0);
+        self.window[block..].copy_from_slice(samples);
+
+        let count = self.fdl.len();
+        self.fdl_head = (self.fdl_head + count - 1) % count;
+        let spectrum = &mut self.fdl[self.fdl_head];
+        for (c, &s) in spectrum.iter_mut().zip(self.window.iter()) {
+            *c = Complex32::new(s, 0.0);
+        }
+        fft.process(spectrum);
+
+        scratch.fill(Complex32::default());
+        for (p, partition) in self.partitions.iter().enumerate() {
+            let input = &self.fdl[(self.fdl_head + p) % count];
+            for ((acc, x), h) in scratch.iter_mut().zip(input.iter()).zip(partition.iter()) {
+                *acc += x * h;
+            }
+        }
+        ifft.process(scratch);
+
+        // Overlap-save : seule la seconde moitié est valide
+        let norm = 1.0 / size as f32;
+        for (out, c) in samples.iter_mut().zip(scratch[block..].iter()) {
+            *out = c.re * norm;
+        }
+    }
+}
+
+/// Convolueur stéréo en flux continu, latence fixe d'une partition
+pub struct PartitionedConvolver {
+    block: usize,
+    fft: Arc<dyn Fft<f32>>,
+    ifft: Arc<dyn Fft<f32>>,
+    channels: [ChannelConvolver; 2],
+    /// Bloc d'entrée en cours de remplissage (par canal)
+    input: [Vec<f32>; 2],
+    /// Bloc de sortie en cours de restitution (par canal)
+    output: [Vec<f32>; 2],
+    /// Position dans le bloc courant
+    cursor: usize,
+    scratch: Vec<Complex32>,
+}
+
+impl PartitionedConvolver {
+    /// Prépare la convolution d'une réponse avec des partitions de `block` frames
+    pub fn new(ir: &ImpulseResponse, block: usize) -> Self {
+        let block = block.max(16).next_power_of_two();
+        let mut planner = FftPlanner::<f32>::new();
+        let fft = planner.plan_fft_forward(2 * block);
+        let ifft = planner.plan_fft_inverse(2 * block);
+        let channels = [
+            ChannelConvolver::new(&ir.channels[0], block, &fft),
+            ChannelConvolver::new(&ir.channels[1], block, &fft),
+        ];
+        Self {
+            block,
+            fft,
+            ifft,
+            channels,
+            input: [vec![0.0; block], vec![0.0; block]],
+            output: [vec![0.0; block], vec![0.0; block]],
+            cursor: 0,
+            scratch: vec![Complex32::default(); 2 * block],
+        }
+    }
+
+    /// Latence introduite (frames)
+    pub fn latency_frames(&self) -> usize {
+        self.block
+    }
+
+    /// Convolue un frame stéréo (la sortie est retardée d'une partition)
+    pub fn process_frame(&mut self, frame: [f32; 2]) -> [f32; 2] {
+        let out = [self.output[0][self.cursor], self.output[1][self.cursor]];
+        self.input[0][self.cursor] = frame[0];
+        self.input[1][self.cursor] = frame[1];
+        self.cursor += 1;
+
+        if self.cursor == self.block {
+            self.cursor = 0;
+            for ch in 0..2 {
+                self.output[ch].copy_from_slice(&self.input[ch]);
+                self.channels[ch].process_block(
+                    &mut self.output[ch],
+                    &self.fft,
+                    &self.ifft,
+                    &mut self.scratch,
+                );
+            }
+        }
+
+        out
+    }
+}
+
+impl StereoProcessor for PartitionedConvolver {
+    fn process_frame(&mut self, frame: [f32; 2]) -> [f32; 2] {
+        PartitionedConvolver::process_frame(self, frame)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn wav_i16_mono(sample_rate: u32, samples: &[i16]) -> Vec<u8> {
+        let data_len = samples.len() as u32 * 2;
+        let mut bytes = Vec::new();
+        bytes.extend_from_slice(b"RIFF");
+        bytes.extend_from_slice(&(36 + data_len).to_le_bytes());
+        bytes.extend_from_slice(b"WAVEfmt ");
+        bytes.extend_from_slice(&16u32.to_le_bytes());
+        bytes.extend_from_slice(&1u16.to_le_bytes());
+        bytes.extend_from_slice(&1u16.to_le_bytes());
+        bytes.extend_from_slice(&sample_rate.to_le_bytes());
+        bytes.extend_from_slice(&(sample_rate * 2).to_le_bytes());
+        bytes.extend_from_slice(&2u16.to_le_bytes());
+        bytes.extend_from_slice(&16u16.to_le_bytes());
+        bytes.extend_from_slice(b"data");
+        bytes.extend_from_slice(&data_len.to_le_bytes());
+        for s in samples {
+            bytes.extend_from_slice(&s.to_le_bytes());
+        }
+        bytes
+    }
+
+    #[test]
+    fn test_parse_mono_wav() {
+        let ir = ImpulseResponse::parse_wav(&wav_i16_mono(48_000, &[16_384, 0, -16_384])).unwrap();
+        assert_eq!(ir.sample_rate, 48_000);
+        assert_eq!(ir.len(), 3);
+        assert_eq!(ir.channels[0], ir.channels[1]);
+        assert!((ir.channels[0][0] - 0.5).abs() < 1e-6);
+        assert!((ir.channels[0][2] + 0.5).abs() < 1e-6);
+    }
+
+    #[test]
+    fn test_convolution_matches_direct_form() {
+        // Réponse plus longue qu'une partition pour exercer la ligne de retard
+        let taps: Vec<f32> = (0..40).map(|i| ((i * 7) % 11) as f32 / 10.0 - 0.5).collect();
+        let ir = ImpulseResponse {
+            sample_rate: 48_000,
+            channels: [taps.clone(), taps.clone()],
+        };
+        let mut conv = PartitionedConvolver::new(&ir, 16);
+        let latency = conv.latency_frames();
+
+        let input: Vec<f32> = (0..200).map(|i| ((i * 13) % 17) as f32 / 17.0 - 0.5).collect();
+        let output: Vec<f32> = input
+            .iter()
+            .map(|&x| conv.process_frame([x, -x])[0])
+            .collect();
+
+        for n in latency..output.len() {
+            let k = n - latency;
+            let expected: f32 = (0..=k.min(taps.len() - 1)).map(|j| taps[j] * input[k - j]).sum();
+            assert!((output[n] - expected).abs() < 1e-4, "frame {}", n);
+        }
+    }
+}
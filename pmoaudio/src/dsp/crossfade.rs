@@ -0,0 +1,188 @@
+//! Fondu enchaîné entre deux instances d'un traitement stéréo
+//!
+//! Quand les paramètres d'un étage DSP changent en cours de lecture
+//! (réponse impulsionnelle, réglages de crossfeed, balance...), remplacer
+//! brutalement le filtre produit une discontinuité audible. `Crossfade`
+//! conserve l'ancienne instance le temps d'un court fondu : pendant cette
+//! fenêtre, les deux instances traitent le même signal et leurs sorties sont
+//! mélangées avec des gains en cosinus surélevé (somme constante, adaptée à
+//! des signaux corrélés).
+
+/// Durée de fondu par défaut lors d'un changement de paramètres (ms)
+pub const DEFAULT_CROSSFADE_MS: u32 = 30;
+
+/// Nombre de frames correspondant à `ms` millisecondes
+pub fn crossfade_frames(ms: u32, sample_rate: u32) -> usize {
+    (ms as u64 * sample_rate as u64 / 1000) as usize
+}
+
+/// Traitement stéréo frame par frame
+pub trait StereoProcessor: Send {
+    fn process_frame(&mut self, frame: [f32; 2]) -> [f32; 2];
+}
+
+/// `None` se comporte comme un traitement neutre (bypass)
+impl<P: StereoProcessor> StereoProcessor for Option<P> {
+    fn process_frame(&mut self, frame: [f32; 2]) -> [f32; 2] {
+        match self {
+            Some(processor) => processor.process_frame(frame),
+            None => frame,
+        }
+    }
+}
+
+/// Enveloppe un traitement et fond les changements d'instance
+pub struct Crossfade<P> {
+    current: P,
+    /// Instance sortante et progression du fondu
+    previous: Option<P>,
+    /// Frames restantes avant le début du fondu (amorçage de l'instance entrante)
+    warmup: usize,
+    position: usize,
+    length: usize,
+}
+
+impl<P: StereoProcessor> Crossfade<P> {
+    /// Enveloppe `processor` sans fondu en cours
+    pub fn new(processor: P) -> Self {
+        Self {
+            current: processor,
+            previous: None,
+            warmup: 0,
+            position: 0,
+            length: 0,
+        }
+    }
+
+    /// Instance courante (entrante si un fondu est en cours)
+    pub fn current(&self) -> &P {
+        &self.current
+    }
+
+    /// Fondu en cours ?
+    pub fn is_fading(&self) -> bool {
+        self.previous.is_some()
+    }
+
+    /// Remplace l'instance courante avec un fondu de `fade_frames` frames.
+    ///
+    /// Si un fondu est déjà en cours, l'instance la plus ancienne est
+    /// abandonnée et le fondu repart de l'instance qui était entrante.
+    pub fn swap(&mut self, processor: P, fade_frames: usize) {
+        self.swap_with_warmup(processor, 0, fade_frames);
+    }
+
+    /// Comme [`swap`](Self::swap), mais l'instance sortante reste seule
+    /// audible pendant `warmup_frames` frames, le temps que l'instance
+    /// entrante remplisse ses tampons (convolueur, filtres à mémoire longue).
+    pub fn swap_with_warmup(&mut self, processor: P, warmup_frames: usize, fade_frames: usize) {
+        let outgoing = std::mem::replace(&mut self.current, processor);
+        if fade_frames == 0 {
+            self.previous = None;
+            return;
+        }
+        self.previous = Some(outgoing);
+        self.warmup = warmup_frames;
+        self.position = 0;
+        self.length = fade_frames;
+    }
+
+    /// Remplace l'instance courante sans fondu (changement de format)
+    pub fn reset(&mut self, processor: P) {
+        self.current = processor;
+        self.previous = None;
+    }
+
+    /// Traite une frame
+    pub fn process_frame(&mut self, frame: [f32; 2]) -> [f32; 2] {
+        let incoming = self.current.process_frame(frame);
+        let Some(previous) = self.previous.as_mut() else {
+            return incoming;
+        };
+
+        let outgoing = previous.process_frame(frame);
+        if self.warmup > 0 {
+            self.warmup -= 1;
+            return outgoing;
+        }
+        let t = (self.position as f32 + 0.5) / self.length as f32;
+        let gain_in = 0.5 - 0.5 * (std::f32::consts::PI * t).cos();
+        let gain_out = 1.0 - gain_in;
+
+        self.position += 1;
+        if self.position >= self.length {
+            self.previous = None;
+        }
+        [
+            incoming[0] * gain_in + outgoing[0] * gain_out,
+            incoming[1] * gain_in + outgoing[1] * gain_out,
+        ]
+    }
+}
+
+impl<P: StereoProcessor> StereoProcessor for Crossfade<P> {
+    fn process_frame(&mut self, frame: [f32; 2]) -> [f32; 2] {
+        Crossfade::process_frame(self, frame)
+    }
+}
+
+impl<P: StereoProcessor> Crossfade<Option<P>> {
+    /// Aucun traitement actif ni en cours de fondu : le signal peut passer tel quel
+    pub fn is_bypassed(&self) -> bool {
+        self.current.is_none() && self.previous.is_none()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    struct Gain(f32);
+
+    impl StereoProcessor for Gain {
+        fn process_frame(&mut self, frame: [f32; 2]) -> [f32; 2] {
+            [frame[0] * self.0, frame[1] * self.0]
+        }
+    }
+
+    #[test]
+    fn test_crossfade_is_monotonic_and_completes() {
+        let mut fader = Crossfade::new(Gain(0.0));
+        fader.swap(Gain(1.0), 100);
+        assert!(fader.is_fading());
+
+        let mut last = 0.0;
+        for _ in 0..100 {
+            let [l, _] = fader.process_frame([1.0, 1.0]);
+            assert!(l >= last && l <= 1.0);
+            last = l;
+        }
+        assert!(!fader.is_fading());
+        assert_eq!(fader.process_frame([1.0, 1.0]), [1.0, 1.0]);
+    }
+
+    #[test]
+    fn test_bypass_after_fade_out() {
+        let mut fader: Crossfade<Option<Gain>> = Crossfade::new(Some(Gain(2.0)));
+        assert!(!fader.is_bypassed());
+        fader.swap(None, 10);
+        assert!(!fader.is_bypassed());
+        for _ in 0..10 {
+            fader.process_frame([1.0, 1.0]);
+        }
+        assert!(fader.is_bypassed());
+    }
+
+    #[test]
+    fn test_warmup_keeps_outgoing_instance() {
+        let mut fader = Crossfade::new(Gain(0.5));
+        fader.swap_with_warmup(Gain(1.0), 4, 4);
+        for _ in 0..4 {
+            assert_eq!(fader.process_frame([1.0, 1.0]), [0.5, 0.5]);
+        }
+        for _ in 0..4 {
+            fader.process_frame([1.0, 1.0]);
+        }
+        assert!(!fader.is_fading());
+    }
+}
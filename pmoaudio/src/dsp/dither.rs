@@ -0,0 +1,153 @@
+//! Dither à la réduction de profondeur de bits
+//!
+//! Tronquer (ou arrondir) un signal 24 bits ou flottant vers 16 bits produit
+//! une erreur de quantification corrélée au signal : distorsion audible sur
+//! les passages faibles et les fins de notes. Un bruit TPDF (densité
+//! triangulaire, ±1 LSB crête) ajouté avant l'arrondi décorrèle cette erreur,
+//! qui devient un bruit de fond constant à environ -96 dBFS.
+//!
+//! En mode [`DitherMode::NoiseShaped`], l'erreur de quantification est en
+//! outre réinjectée par un filtre du second ordre `(1 - z⁻¹)²` qui repousse
+//! le bruit vers l'aigu, où l'oreille est moins sensible.
+
+use std::fmt;
+
+/// Traitement appliqué à la réduction de profondeur
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum DitherMode {
+    /// Arrondi simple
+    Off,
+    /// Bruit TPDF de ±1 LSB
+    #[default]
+    Tpdf,
+    /// TPDF avec mise en forme spectrale du bruit
+    NoiseShaped,
+}
+
+impl DitherMode {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            DitherMode::Off => "off",
+            DitherMode::Tpdf => "tpdf",
+            DitherMode::NoiseShaped => "shaped",
+        }
+    }
+
+    pub fn parse(s: &str) -> Option<Self> {
+        match s.trim().to_ascii_lowercase().as_str() {
+            "off" | "none" => Some(DitherMode::Off),
+            "tpdf" => Some(DitherMode::Tpdf),
+            "shaped" | "noise-shaped" | "noise_shaped" => Some(DitherMode::NoiseShaped),
+            _ => None,
+        }
+    }
+}
+
+impl fmt::Display for DitherMode {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.write_str(self.as_str())
+    }
+}
+
+/// Quantificateur avec dither, un état de mise en forme par canal
+///
+/// Les échantillons sont exprimés en LSB de la profondeur cible (un
+/// échantillon flottant normalisé vaut `x * 32768` pour une cible 16 bits).
+#[derive(Debug, Clone)]
+pub struct Ditherer {
+    mode: DitherMode,
+    /// État du générateur xorshift
+    rng: u64,
+    /// Deux dernières erreurs de quantification par canal
+    errors: [[f64; 2]; 2],
+}
+
+impl Ditherer {
+    pub fn new(mode: DitherMode) -> Self {
+        Self {
+            mode,
+            rng: 0x9E37_79B9_7F4A_7C15,
+            errors: [[0.0; 2]; 2],
+        }
+    }
+
+    pub fn mode(&self) -> DitherMode {
+        self.mode
+    }
+
+    /// Uniforme dans [-0.5, 0.5)
+    fn uniform(&mut self) -> f64 {
+        self.rng ^= self.rng << 13;
+        self.rng ^= self.rng >> 7;
+        self.rng ^= self.rng << 17;
+        (self.rng >> 11) as f64 / (1u64 << 53) as f64 - 0.5
+    }
+
+    /// Quantifie `value` (en LSB) sur le canal `ch`, dans [min, max]
+    pub fn quantize(&mut self, ch: usize, value: f64, min: f64, max: f64) -> f64 {
+        let shaped = match self.mode {
+            DitherMode::NoiseShaped => {
+                let [e1, e2] = self.errors[ch];
+                value - (2.0 * e1 - e2)
+            }
+            _ => value,
+        };
+        let noise = match self.mode {
+            DitherMode::Off => 0.0,
+            _ => self.uniform() + self.uniform(),
+        };
+        let quantized = (shaped + noise).round();
+        if self.mode == DitherMode::NoiseShaped {
+            // Erreur calculée avant écrêtage pour garder la boucle stable
+            let e = &mut self.errors[ch];
+            e[1] = e[0];
+            e[0] = quantized - shaped;
+        }
+        quantized.clamp(min, max)
+    }
+
+    /// Quantifie des frames exprimées en LSB vers des entiers 16 bits
+    pub fn quantize_i16(&mut self, frames: impl Iterator<Item = [f64; 2]>) -> Vec<[i16; 2]> {
+        let (min, max) = (i16::MIN as f64, i16::MAX as f64);
+        frames
+            .map(|[l, r]| {
+                [
+                    self.quantize(0, l, min, max) as i16,
+                    self.quantize(1, r, min, max) as i16,
+                ]
+            })
+            .collect()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_dither_modes() {
+        assert_eq!(DitherMode::parse("Shaped"), Some(DitherMode::NoiseShaped));
+        assert_eq!(DitherMode::parse("bogus"), None);
+
+        // Sans dither, un signal inférieur au demi-LSB disparaît
+        let quiet = vec![[0.3, -0.3]; 4096];
+        let mut plain = Ditherer::new(DitherMode::Off);
+        assert!(plain
+            .quantize_i16(quiet.iter().copied())
+            .iter()
+            .all(|f| *f == [0, 0]));
+
+        // Avec TPDF, le bruit reste dans ±2 LSB et sa moyenne suit le signal
+        let mut tpdf = Ditherer::new(DitherMode::Tpdf);
+        let out = tpdf.quantize_i16(quiet.iter().copied());
+        assert!(out.iter().all(|[l, r]| l.abs() <= 2 && r.abs() <= 2));
+        let mean = out.iter().map(|[l, _]| *l as f64).sum::<f64>() / out.len() as f64;
+        assert!((mean - 0.3).abs() < 0.1, "mean = {}", mean);
+
+        // La mise en forme reste bornée près de la pleine échelle
+        let mut shaped = Ditherer::new(DitherMode::NoiseShaped);
+        let loud = vec![[32767.4, -32768.0]; 1024];
+        let out = shaped.quantize_i16(loud.into_iter());
+        assert!(out.iter().all(|[l, _]| *l >= 32_760));
+    }
+}
@@ -0,0 +1,203 @@
+//! Mesure de la sonie intégrée (ITU-R BS.1770 / EBU R128)
+//!
+//! Filtrage K (shelf haut + passe-haut), énergie par blocs de 400 ms avec un
+//! recouvrement de 75 %, puis double fenêtrage : absolu à -70 LUFS et relatif
+//! à -10 LU sous la moyenne des blocs retenus.
+
+/// Niveau de référence ReplayGain 2.0 (LUFS)
+pub const REPLAYGAIN_REFERENCE_LUFS: f64 = -18.0;
+
+/// Seuil absolu de fenêtrage (LUFS)
+const ABSOLUTE_GATE_LUFS: f64 = -70.0;
+/// Seuil relatif de fenêtrage (LU sous la moyenne)
+const RELATIVE_GATE_LU: f64 = -10.0;
+/// Un bloc de mesure = 4 sous-blocs de 100 ms
+const SUBBLOCKS_PER_BLOCK: usize = 4;
+
+/// Gain ReplayGain (dB) correspondant à une sonie intégrée
+pub fn replay_gain_db(integrated_lufs: f64) -> f64 {
+    REPLAYGAIN_REFERENCE_LUFS - integrated_lufs
+}
+
+fn energy_to_lufs(energy: f64) -> f64 {
+    -0.691 + 10.0 * energy.log10()
+}
+
+/// Biquad en forme directe II transposée, un état par canal
+#[derive(Debug, Clone)]
+struct Biquad {
+    b: [f64; 3],
+    a: [f64; 2],
+    state: [[f64; 2]; 2],
+}
+
+impl Biquad {
+    fn process(&mut self, ch: usize, x: f64) -> f64 {
+        let s = &mut self.state[ch];
+        let y = self.b[0] * x + s[0];
+        s[0] = self.b[1] * x - self.a[0] * y + s[1];
+        s[1] = self.b[2] * x - self.a[1] * y;
+        y
+    }
+}
+
+/// Coefficients du filtre K pour une fréquence donnée
+fn k_weighting(sample_rate: u32) -> (Biquad, Biquad) {
+    let fs = sample_rate as f64;
+
+    // Étage 1 : shelf haut (+4 dB au-dessus de ~1.7 kHz)
+    let f0 = 1681.974450955533;
+    let gain_db = 3.999843853973347;
+    let q = 0.7071752369554196;
+    let k = (std::f64::consts::PI * f0 / fs).tan();
+    let vh = 10f64.powf(gain_db / 20.0);
+    let vb = vh.powf(0.4996667741545416);
+    let a0 = 1.0 + k / q + k * k;
+    let shelf = Biquad {
+        b: [
+            (vh + vb * k / q + k * k) / a0,
+            2.0 * (k * k - vh) / a0,
+            (vh - vb * k / q + k * k) / a0,
+        ],
+        a: [2.0 * (k * k - 1.0) / a0, (1.0 - k / q + k * k) / a0],
+        state: [[0.0; 2]; 2],
+    };
+
+    // Étage 2 : passe-haut RLB (~38 Hz)
+    let f0 = 38.13547087602444;
+    let q = 0.5003270373238773;
+    let k = (std::f64::consts::PI * f0 / fs).tan();
+    let a0 = 1.0 + k / q + k * k;
+    let highpass = Biquad {
+        b: [1.0, -2.0, 1.0],
+        a: [2.0 * (k * k - 1.0) / a0, (1.0 - k / q + k * k) / a0],
+        state: [[0.0; 2]; 2],
+    };
+
+    (shelf, highpass)
+}
+
+/// Mesure incrémentale de la sonie intégrée d'un flux stéréo
+#[derive(Debug, Clone)]
+pub struct LoudnessMeter {
+    sample_rate: u32,
+    shelf: Biquad,
+    highpass: Biquad,
+    /// Taille d'un sous-bloc de 100 ms (frames)
+    subblock_frames: usize,
+    /// Somme des carrés du sous-bloc courant (canaux additionnés)
+    current_sum: f64,
+    current_frames: usize,
+    /// Énergie moyenne des sous-blocs complets
+    subblocks: Vec<f64>,
+    peak: f32,
+    total_frames: u64,
+}
+
+impl LoudnessMeter {
+    pub fn new(sample_rate: u32) -> Self {
+        let (shelf, highpass) = k_weighting(sample_rate);
+        Self {
+            sample_rate,
+            shelf,
+            highpass,
+            subblock_frames: (sample_rate as usize / 10).max(1),
+            current_sum: 0.0,
+            current_frames: 0,
+            subblocks: Vec::new(),
+            peak: 0.0,
+            total_frames: 0,
+        }
+    }
+
+    pub fn sample_rate(&self) -> u32 {
+        self.sample_rate
+    }
+
+    /// Crête échantillon (linéaire, 1.0 = pleine échelle)
+    pub fn peak(&self) -> f32 {
+        self.peak
+    }
+
+    /// Durée mesurée (secondes)
+    pub fn duration_sec(&self) -> f64 {
+        self.total_frames as f64 / self.sample_rate as f64
+    }
+
+    /// Ajoute des frames stéréo normalisées dans [-1.0, 1.0]
+    pub fn push(&mut self, frames: &[[f32; 2]]) {
+        for frame in frames {
+            for ch in 0..2 {
+                let x = frame[ch];
+                self.peak = self.peak.max(x.abs());
+                let y = self.highpass.process(ch, self.shelf.process(ch, x as f64));
+                self.current_sum += y * y;
+            }
+            self.current_frames += 1;
+            if self.current_frames == self.subblock_frames {
+                self.subblocks
+                    .push(self.current_sum / self.subblock_frames as f64);
+                self.current_sum = 0.0;
+                self.current_frames = 0;
+            }
+        }
+        self.total_frames += frames.len() as u64;
+    }
+
+    /// Sonie intégrée (LUFS), `None` si moins de 400 ms ont été mesurés
+    /// ou si tout le signal est sous le seuil absolu
+    pub fn integrated_lufs(&self) -> Option<f64> {
+        let blocks: Vec<f64> = self
+            .subblocks
+            .windows(SUBBLOCKS_PER_BLOCK)
+            .map(|w| w.iter().sum::<f64>() / SUBBLOCKS_PER_BLOCK as f64)
+            .filter(|&e| e > 0.0 && energy_to_lufs(e) > ABSOLUTE_GATE_LUFS)
+            .collect();
+        if blocks.is_empty() {
+            return None;
+        }
+
+        let mean = blocks.iter().sum::<f64>() / blocks.len() as f64;
+        let relative_gate = energy_to_lufs(mean) + RELATIVE_GATE_LU;
+        let gated: Vec<f64> = blocks
+            .into_iter()
+            .filter(|&e| energy_to_lufs(e) > relative_gate)
+            .collect();
+        if gated.is_empty() {
+            return None;
+        }
+        Some(energy_to_lufs(gated.iter().sum::<f64>() / gated.len() as f64))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn sine(amplitude: f32, seconds: f32, sample_rate: u32) -> Vec<[f32; 2]> {
+        (0..(seconds * sample_rate as f32) as usize)
+            .map(|i| {
+                let s = amplitude
+                    * (2.0 * std::f32::consts::PI * 997.0 * i as f32 / sample_rate as f32).sin();
+                [s, s]
+            })
+            .collect()
+    }
+
+    #[test]
+    fn test_sine_integrated_loudness() {
+        // EBU Tech 3341 : sinus 1 kHz à -6 dBFS sur les deux canaux → -6 LUFS
+        for rate in [44_100, 48_000, 96_000] {
+            let mut meter = LoudnessMeter::new(rate);
+            meter.push(&sine(0.5, 5.0, rate));
+            let lufs = meter.integrated_lufs().unwrap();
+            assert!((lufs + 6.02).abs() < 0.1, "{} Hz: {} LUFS", rate, lufs);
+            assert!((meter.peak() - 0.5).abs() < 1e-3);
+        }
+
+        let mut silent = LoudnessMeter::new(48_000);
+        silent.push(&vec![[0.0; 2]; 48_000]);
+        assert!(silent.integrated_lufs().is_none());
+        assert!((replay_gain_db(-9.0) + 9.0).abs() < f64::EPSILON);
+    }
+}
@@ -1,16 +1,23 @@
 //! Module DSP pour les conversions et traitements audio optimisés (SIMD)
 
+pub mod convolution;
+pub mod crossfade;
 pub mod depth;
+pub mod dither;
 pub mod gain_16bits;
 pub mod gain_24bits;
 pub mod gain_32bits;
 pub mod int_float;
+pub mod loudness;
 pub mod resampling;
+pub mod wav;
 
 pub use depth::bitdepth_change_stereo;
+pub use dither::{DitherMode, Ditherer};
 pub use gain_16bits::apply_gain_stereo_i16;
 pub use gain_24bits::apply_gain_stereo_i24;
 pub use gain_32bits::apply_gain_stereo_i32;
+pub use loudness::{replay_gain_db, LoudnessMeter, REPLAYGAIN_REFERENCE_LUFS};
 
 pub use int_float::{
     i16_stereo_to_pairs_f32, i24_as_i32_stereo_to_pairs_f32, i32_stereo_to_interleaved_f32,
@@ -29,11 +29,10 @@ pub fn build_resampler(
     dest_hz: u32,
     bit_depth: BitDepth,
 ) -> Result<Resampler, ResamplingError> {
-    let qrecipe = match bit_depth {
-        BitDepth::B8 => QualityRecipe::Medium,
-        BitDepth::B16 => QualityRecipe::high(), // High pour 16-bit
-        BitDepth::B24 => QualityRecipe::very_high(), // VeryHigh pour 24-bit
-        BitDepth::B32 => QualityRecipe::very_high(), // VeryHigh pour 32-bit
+    let qrecipe = match quality_name(bit_depth) {
+        "medium" => QualityRecipe::Medium,
+        "high" => QualityRecipe::high(), // High pour 16-bit
+        _ => QualityRecipe::very_high(), // VeryHigh pour 24/32-bit
     };
 
     let quality = QualitySpec::new(qrecipe); // Phase response linear, no steep filter
@@ -50,6 +49,21 @@ pub fn build_resampler(
     })
 }
 
+/// Qualité libsoxr retenue pour une profondeur de bits
+pub fn quality_name(bit_depth: BitDepth) -> &'static str {
+    match bit_depth {
+        BitDepth::B8 => "medium",
+        BitDepth::B16 => "high",
+        BitDepth::B24 | BitDepth::B32 => "very_high",
+    }
+}
+
+/// Vérifie que libsoxr est utilisable en construisant un resampler
+/// 44,1 kHz → 48 kHz (autodiagnostic au démarrage)
+pub fn check_resampler() -> Result<(), ResamplingError> {
+    build_resampler(44_100, 48_000, BitDepth::B16).map(|_| ())
+}
+
 pub fn resampling(left: &[i32], right: &[i32], resampler: &mut Resampler) -> (Vec<i32>, Vec<i32>) {
     if left.len() != right.len() {
         panic!("Left and right channels must have the same length");
@@ -0,0 +1,98 @@
+//! Décodage de fichiers WAV courts chargés en mémoire
+//!
+//! Utilisé pour les réponses impulsionnelles de la convolution et les clips
+//! d'annonce : PCM 16/24/32 bits ou float 32 bits, mono ou stéréo
+//! (WAVE_FORMAT_EXTENSIBLE accepté). Au-delà de deux canaux, seuls les deux
+//! premiers sont conservés ; un fichier mono est dupliqué sur les deux.
+
+use crate::nodes::AudioError;
+
+/// Contenu décodé d'un fichier WAV
+#[derive(Debug, Clone)]
+pub struct WavData {
+    pub sample_rate: u32,
+    /// Canaux gauche et droit
+    pub channels: [Vec<f32>; 2],
+}
+
+/// Décode un fichier WAV en ne conservant que `max_frames` frames
+pub fn decode_wav(bytes: &[u8], max_frames: usize) -> Result<WavData, AudioError> {
+    let invalid = |msg: &str| AudioError::ProcessingError(format!("Invalid WAV: {}", msg));
+
+    if bytes.len() < 12 || &bytes[0..4] != b"RIFF" || &bytes[8..12] != b"WAVE" {
+        return Err(invalid("missing RIFF/WAVE header"));
+    }
+
+    let mut format: Option<(u16, u16, u32, u16)> = None;
+    let mut data: Option<&[u8]> = None;
+    let mut pos = 12;
+    while pos + 8 <= bytes.len() {
+        let id = &bytes[pos..pos + 4];
+        let size = u32::from_le_bytes(bytes[pos + 4..pos + 8].try_into().unwrap()) as usize;
+        let body = &bytes[pos + 8..(pos + 8 + size).min(bytes.len())];
+        match id {
+            b"fmt " if body.len() >= 16 => {
+                let mut tag = u16::from_le_bytes([body[0], body[1]]);
+                let channels = u16::from_le_bytes([body[2], body[3]]);
+                let sample_rate = u32::from_le_bytes(body[4..8].try_into().unwrap());
+                let bits = u16::from_le_bytes([body[14], body[15]]);
+                // WAVE_FORMAT_EXTENSIBLE : le vrai format est dans le sous-format
+                if tag == 0xFFFE && body.len() >= 26 {
+                    tag = u16::from_le_bytes([body[24], body[25]]);
+                }
+                format = Some((tag, channels, sample_rate, bits));
+            }
+            b"data" => data = Some(body),
+            _ => {}
+        }
+        // Les chunks RIFF sont alignés sur 2 octets
+        pos += 8 + size + (size & 1);
+    }
+
+    let (tag, channels, sample_rate, bits) = format.ok_or_else(|| invalid("missing fmt chunk"))?;
+    let data = data.ok_or_else(|| invalid("missing data chunk"))?;
+    if channels == 0 {
+        return Err(invalid("no channel"));
+    }
+    if sample_rate == 0 {
+        return Err(invalid("null sample rate"));
+    }
+
+    let decode: fn(&[u8]) -> f32 = match (tag, bits) {
+        (1, 16) => |b| i16::from_le_bytes([b[0], b[1]]) as f32 / 32_768.0,
+        (1, 24) => |b| (i32::from_le_bytes([0, b[0], b[1], b[2]]) >> 8) as f32 / 8_388_608.0,
+        (1, 32) => |b| i32::from_le_bytes([b[0], b[1], b[2], b[3]]) as f32 / 2_147_483_648.0,
+        (3, 32) => |b| f32::from_le_bytes([b[0], b[1], b[2], b[3]]),
+        _ => {
+            return Err(invalid(&format!(
+                "unsupported format {} / {} bits",
+                tag, bits
+            )))
+        }
+    };
+
+    let sample_bytes = bits as usize / 8;
+    let frame_bytes = sample_bytes * channels as usize;
+    let frames = (data.len() / frame_bytes).min(max_frames);
+    if frames == 0 {
+        return Err(invalid("empty data chunk"));
+    }
+
+    let mut left = Vec::with_capacity(frames);
+    let mut right = Vec::with_capacity(frames);
+    for frame in data.chunks_exact(frame_bytes).take(frames) {
+        let l = decode(&frame[..sample_bytes]);
+        let r = if channels >= 2 {
+            decode(&frame[sample_bytes..2 * sample_bytes])
+        } else {
+            l
+        };
+        left.push(l);
+        right.push(r);
+    }
+
+    Ok(WavData {
+        sample_rate,
+        channels: [left, right],
+    })
+}
@@ -117,15 +117,24 @@ pub use pipeline::AudioPipelineNode;
 
 // Exports publics des nodes
 pub use nodes::{
-    audio_sink::AudioSink,
+    analysis_node::{AnalysisFrame, AnalysisHandle, AnalysisNode},
+    announce_source::{AnnounceClip, AnnounceHandle, AnnounceSource},
+    audio_sink::{AudioSink, OutputCapabilities, OutputMode, OutputModeHandle},
+    channel_mix_node::{ChannelMixHandle, ChannelMixNode},
     converter_nodes::{ToF32Node, ToF64Node, ToI16Node, ToI24Node, ToI32Node},
+    convolution_node::{ConvolutionHandle, ConvolutionNode},
+    crossfeed_node::{CrossfeedHandle, CrossfeedNode, CrossfeedParams},
     file_source::FileSource,
     flac_file_sink::{FlacFileSink, FlacFileSinkStats},
     http_source::HttpSource,
-    resampling_node::ResamplingNode,
+    mixer_node::{FadeCurve, MixerHandle, MixerInput, MixerNode},
+    pregain_node::{PreGainNode, PreGainStore, TrackLoudness},
+    resampling_node::{ResampleTarget, ResamplingHandle, ResamplingNode, ResamplingStats},
     timer_buffer_node::TimerBufferNode,
     timer_node::TimerNode,
     position_tracker_node::{PositionHandle, PositionTrackerNode},
+    recorder_node::{RecorderConfig, RecorderHandle, RecorderNode},
+    volume_ramp_node::{VolumeRampHandle, VolumeRampNode},
     AudioError, AudioNode, TypedAudioNode,
 };
 
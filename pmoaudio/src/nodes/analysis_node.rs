@@ -0,0 +1,308 @@
+//! AnalysisNode — dérivation d'analyse (niveaux et spectre) pour l'interface.
+//!
+//! Nœud transparent : les segments passent sans modification, et à intervalle
+//! régulier (~20 Hz) le nœud publie une trame d'analyse :
+//! - RMS et crête par canal sur l'intervalle écoulé (dBFS) ;
+//! - spectre grossier (bandes logarithmiques, dBFS) de la somme mono.
+//!
+//! Les trames sont diffusées via un canal broadcast ; tant que personne n'est
+//! abonné, aucun calcul n'est effectué.
+
+use crate::{
+    nodes::AudioError,
+    pipeline::{send_to_children, AudioPipelineNode, Node, NodeLogic},
+    type_constraints::TypeRequirement,
+    AudioChunk, AudioSegment, _AudioSegment,
+};
+use rustfft::{num_complex::Complex32, Fft, FftPlanner};
+use std::sync::Arc;
+use tokio::sync::{broadcast, mpsc};
+use tokio_util::sync::CancellationToken;
+
+/// Fréquence de publication par défaut (trames par seconde)
+pub const DEFAULT_ANALYSIS_RATE_HZ: u32 = 20;
+/// Nombre de bandes du spectre
+pub const SPECTRUM_BANDS: usize = 32;
+/// Plancher des niveaux publiés (dBFS)
+pub const ANALYSIS_FLOOR_DB: f32 = -120.0;
+
+/// Taille de la FFT (frames)
+const FFT_SIZE: usize = 2048;
+/// Fréquence basse de la première bande (Hz)
+const SPECTRUM_MIN_HZ: f32 = 20.0;
+/// Fréquence haute de la dernière bande (Hz)
+const SPECTRUM_MAX_HZ: f32 = 20_000.0;
+
+/// Trame d'analyse publiée par le nœud
+#[derive(Debug, Clone)]
+pub struct AnalysisFrame {
+    /// Position dans le flux (secondes)
+    pub timestamp_sec: f64,
+    /// RMS gauche/droite (dBFS)
+    pub rms_db: [f32; 2],
+    /// Crête gauche/droite (dBFS)
+    pub peak_db: [f32; 2],
+    /// Niveaux des bandes du spectre, des graves vers les aigus (dBFS)
+    pub spectrum_db: Vec<f32>,
+}
+
+fn to_db(value: f32) -> f32 {
+    if value > 0.0 {
+        (20.0 * value.log10()).max(ANALYSIS_FLOOR_DB)
+    } else {
+        ANALYSIS_FLOOR_DB
+    }
+}
+
+// ─── Handle public ────────────────────────────────────────────────────────────
+
+/// Handle partageable pour s'abonner aux trames d'analyse.
+#[derive(Clone)]
+pub struct AnalysisHandle {
+    tx: broadcast::Sender<Arc<AnalysisFrame>>,
+}
+
+impl AnalysisHandle {
+    /// S'abonne aux trames d'analyse
+    pub fn subscribe(&self) -> broadcast::Receiver<Arc<AnalysisFrame>> {
+        self.tx.subscribe()
+    }
+}
+
+// ─── Logique du nœud ─────────────────────────────────────────────────────────
+
+struct AnalysisLogic {
+    tx: broadcast::Sender<Arc<AnalysisFrame>>,
+    rate_hz: u32,
+    fft: Arc<dyn Fft<f32>>,
+    /// Fenêtre de Hann
+    window: Vec<f32>,
+    /// Derniers échantillons mono (tampon circulaire)
+    history: Vec<f32>,
+    history_pos: usize,
+    /// Accumulateurs de l'intervalle courant
+    sum_sq: [f64; 2],
+    peak: [f32; 2],
+    frames: usize,
+}
+
+impl AnalysisLogic {
+    fn new(tx: broadcast::Sender<Arc<AnalysisFrame>>, rate_hz: u32) -> Self {
+        let window = (0..FFT_SIZE)
+            .map(|i| {
+                let x = std::f32::consts::PI * i as f32 / (FFT_SIZE - 1) as f32;
+                x.sin().powi(2)
+            })
+            .collect();
+        Self {
+            tx,
+            rate_hz: rate_hz.max(1),
+            fft: FftPlanner::<f32>::new().plan_fft_forward(FFT_SIZE),
+            window,
+            history: vec![0.0; FFT_SIZE],
+            history_pos: 0,
+            sum_sq: [0.0; 2],
+            peak: [0.0; 2],
+            frames: 0,
+        }
+    }
+
+    fn analyse_chunk(&mut self, chunk: &AudioChunk, timestamp_sec: f64) {
+        if self.tx.receiver_count() == 0 {
+            self.frames = 0;
+            return;
+        }
+
+        let sample_rate = chunk.sample_rate();
+        let interval = (sample_rate / self.rate_hz).max(1) as usize;
+        let data = match chunk.to_f32().apply_gain() {
+            AudioChunk::F32(data) => data,
+            _ => unreachable!("to_f32 always returns an F32 chunk"),
+        };
+
+        for (i, frame) in data.get_frames().iter().enumerate() {
+            for ch in 0..2 {
+                self.sum_sq[ch] += (frame[ch] as f64).powi(2);
+                self.peak[ch] = self.peak[ch].max(frame[ch].abs());
+            }
+            self.history[self.history_pos] = 0.5 * (frame[0] + frame[1]);
+            self.history_pos = (self.history_pos + 1) % FFT_SIZE;
+            self.frames += 1;
+
+            if self.frames >= interval {
+                let t = timestamp_sec + i as f64 / sample_rate as f64;
+                self.publish(t, sample_rate);
+            }
+        }
+    }
+
+    fn publish(&mut self, timestamp_sec: f64, sample_rate: u32) {
+        let frames = self.frames.max(1) as f64;
+        let rms = |sum: f64| to_db((sum / frames).sqrt() as f32);
+        let frame = AnalysisFrame {
+            timestamp_sec,
+            rms_db: [rms(self.sum_sq[0]), rms(self.sum_sq[1])],
+            peak_db: [to_db(self.peak[0]), to_db(self.peak[1])],
+            spectrum_db: self.spectrum(sample_rate),
+        };
+
+        self.sum_sq = [0.0; 2];
+        self.peak = [0.0; 2];
+        self.frames = 0;
+        // Aucun abonné : la trame est simplement perdue
+        let _ = self.tx.send(Arc::new(frame));
+    }
+
+    /// Spectre en bandes logarithmiques des FFT_SIZE derniers échantillons
+    fn spectrum(&self, sample_rate: u32) -> Vec<f32> {
+        let mut buffer: Vec<Complex32> = (0..FFT_SIZE)
+            .map(|i| {
+                let sample = self.history[(self.history_pos + i) % FFT_SIZE];
+                Complex32::new(sample * self.window[i], 0.0)
+            })
+            .collect();
+        self.fft.process(&mut buffer);
+
+        // Amplitude normalisée : une sinusoïde pleine échelle donne ~0 dBFS
+        let norm = 4.0 / FFT_SIZE as f32;
+        let bin_hz = sample_rate as f32 / FFT_SIZE as f32;
+        let max_hz = SPECTRUM_MAX_HZ.min(sample_rate as f32 / 2.0);
+        let ratio = (max_hz / SPECTRUM_MIN_HZ).powf(1.0 / SPECTRUM_BANDS as f32);
+
+        (0..SPECTRUM_BANDS)
+            .map(|band| {
+                let lo = SPECTRUM_MIN_HZ * ratio.powi(band as i32);
+                let hi = lo * ratio;
+                let first = ((lo / bin_hz) as usize).max(1);
+                let last = ((hi / bin_hz) as usize).clamp(first, FFT_SIZE / 2);
+                let peak = buffer[first..=last]
+                    .iter()
+                    .map(|c| c.norm())
+                    .fold(0.0f32, f32::max);
+                to_db(peak * norm)
+            })
+            .collect()
+    }
+}
+
+#[async_trait::async_trait]
+impl NodeLogic for AnalysisLogic {
+    async fn process(
+        &mut self,
+        input: Option<mpsc::Receiver<Arc<AudioSegment>>>,
+        output: Vec<mpsc::Sender<Arc<AudioSegment>>>,
+        stop_token: CancellationToken,
+    ) -> Result<(), AudioError> {
+        let mut input = input.ok_or_else(|| {
+            AudioError::ProcessingError("AnalysisNode requires an input".into())
+        })?;
+
+        loop {
+            let seg = tokio::select! {
+                _ = stop_token.cancelled() => break,
+                segment = input.recv() => match segment {
+                    None => break,
+                    Some(seg) => seg,
+                },
+            };
+
+            if let _AudioSegment::Chunk(chunk) = &seg.segment {
+                self.analyse_chunk(chunk, seg.timestamp_sec);
+            }
+
+            send_to_children("AnalysisNode", &output, seg).await?;
+        }
+
+        Ok(())
+    }
+}
+
+// ─── Nœud public ─────────────────────────────────────────────────────────────
+
+pub struct AnalysisNode {
+    inner: Node<AnalysisLogic>,
+}
+
+impl AnalysisNode {
+    /// Crée un nœud publiant `rate_hz` trames d'analyse par seconde
+    pub fn new(rate_hz: u32) -> (Self, AnalysisHandle) {
+        let (tx, _) = broadcast::channel(16);
+        let handle = AnalysisHandle { tx };
+        (Self::with_handle(&handle, rate_hz), handle)
+    }
+
+    /// Crée un nœud publiant sur un handle existant (les abonnés sont conservés)
+    pub fn with_handle(handle: &AnalysisHandle, rate_hz: u32) -> Self {
+        let logic = AnalysisLogic::new(handle.tx.clone(), rate_hz);
+        Self {
+            inner: Node::new_with_input(logic, 16),
+        }
+    }
+}
+
+#[async_trait::async_trait]
+impl AudioPipelineNode for AnalysisNode {
+    fn get_tx(&self) -> Option<mpsc::Sender<Arc<AudioSegment>>> {
+        self.inner.get_tx()
+    }
+
+    fn register(&mut self, child: Box<dyn AudioPipelineNode>) {
+        self.inner.register(child);
+    }
+
+    async fn run(self: Box<Self>, stop_token: CancellationToken) -> Result<(), AudioError> {
+        Box::new(self.inner).run(stop_token).await
+    }
+
+    fn start(self: Box<Self>) -> crate::pipeline::PipelineHandle {
+        Box::new(self.inner).start()
+    }
+}
+
+impl crate::TypedAudioNode for AnalysisNode {
+    fn input_type(&self) -> Option<TypeRequirement> {
+        None // Accepte tout
+    }
+
+    fn output_type(&self) -> Option<TypeRequirement> {
+        None // Passe tout
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::AudioChunkData;
+
+    #[test]
+    fn test_sine_levels_and_spectrum_peak() {
+        let (tx, mut rx) = broadcast::channel(16);
+        let mut logic = AnalysisLogic::new(tx, 20);
+
+        // 1 kHz pleine échelle, 100 ms à 48 kHz → deux trames
+        let frames: Vec<[f32; 2]> = (0..4800)
+            .map(|i| {
+                let s = (2.0 * std::f32::consts::PI * 1000.0 * i as f32 / 48_000.0).sin();
+                [s, s]
+            })
+            .collect();
+        let chunk = AudioChunk::F32(AudioChunkData::new(frames, 48_000, 0.0));
+        logic.analyse_chunk(&chunk, 0.0);
+
+        rx.try_recv().unwrap();
+        let frame = rx.try_recv().unwrap();
+        assert!((frame.rms_db[0] + 3.01).abs() < 0.1);
+        assert!(frame.peak_db[0].abs() < 0.1);
+
+        let loudest = frame
+            .spectrum_db
+            .iter()
+            .enumerate()
+            .max_by(|a, b| a.1.total_cmp(b.1))
+            .map(|(band, _)| band)
+            .unwrap();
+        let ratio = (SPECTRUM_MAX_HZ / SPECTRUM_MIN_HZ).powf(1.0 / SPECTRUM_BANDS as f32);
+        let lo = SPECTRUM_MIN_HZ * ratio.powi(loudest as i32);
+        assert!(lo <= 1000.0 && lo * ratio >= 1000.0);
+    }
+}
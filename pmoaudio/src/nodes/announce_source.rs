@@ -0,0 +1,447 @@
+//! AnnounceSource — annonces mixées par-dessus la musique.
+//!
+//! Les clips (carillon, message synthétisé…) sont mis en file via
+//! [`AnnounceHandle::announce`] et joués par un [`AnnounceSource`] qui
+//! alimente une entrée auxiliaire prioritaire d'un [`MixerNode`] : le
+//! ducking est celui du mixeur, qui abaisse la musique tant que l'entrée a
+//! du signal puis la remonte.
+//!
+//! Chaque clip est précédé d'un silence de la durée de la rampe de ducking,
+//! pour que la musique soit abaissée quand la voix commence. Il est
+//! rééchantillonné à la volée (interpolation linéaire) vers la fréquence de
+//! l'entrée principale du mixeur, suffisant pour de la voix ou un carillon.
+//!
+//! La source suit le débit du mixeur : une annonce n'est entendue que
+//! pendant la lecture.
+//!
+//! [`MixerNode`]: crate::MixerNode
+
+use crate::{
+    dsp::wav::decode_wav,
+    nodes::{mixer_node::MixerHandle, AudioError, DEFAULT_CHUNK_DURATION_MS},
+    pipeline::{send_to_children, AudioPipelineNode, Node, NodeLogic},
+    type_constraints::{SampleType, TypeRequirement},
+    AudioChunk, AudioChunkData, AudioSegment, _AudioSegment,
+};
+use std::collections::VecDeque;
+use std::path::Path;
+use std::sync::{
+    atomic::{AtomicBool, Ordering},
+    Arc, Mutex,
+};
+use std::time::Duration;
+use tokio::sync::{mpsc, Notify};
+use tokio_util::sync::CancellationToken;
+
+/// Atténuation par défaut de la musique pendant une annonce (dB)
+pub const DEFAULT_DUCK_DB: f32 = -18.0;
+/// Atténuation maximale (dB), en deçà la musique est coupée
+pub const MIN_DUCK_DB: f32 = -60.0;
+/// Durée par défaut des rampes de ducking (ms)
+pub const DEFAULT_DUCK_RAMP_MS: u32 = 300;
+/// Durée maximale des rampes de ducking (ms)
+pub const MAX_DUCK_RAMP_MS: u32 = 2_000;
+/// Longueur maximale d'un clip (frames, ~87 s à 96 kHz)
+pub const MAX_CLIP_FRAMES: usize = 1 << 23;
+/// Nombre maximal d'annonces en attente
+pub const MAX_PENDING_ANNOUNCEMENTS: usize = 8;
+/// Priorité de l'entrée des annonces dans le mixeur
+const ANNOUNCE_PRIORITY: u8 = 1;
+/// Avance maximale sur le mixeur (ms), bornant la fin d'un clip annulé
+const FEED_AHEAD_MS: u64 = 200;
+/// Intervalle de vérification du tampon de l'entrée
+const FEED_POLL_INTERVAL: Duration = Duration::from_millis(10);
+
+// ─── Clip ────────────────────────────────────────────────────────────────────
+
+/// Clip audio stéréo à annoncer
+#[derive(Debug, Clone)]
+pub struct AnnounceClip {
+    sample_rate: u32,
+    frames: Vec<[f32; 2]>,
+}
+
+impl AnnounceClip {
+    /// Crée un clip (tronqué à [`MAX_CLIP_FRAMES`])
+    pub fn new(sample_rate: u32, mut frames: Vec<[f32; 2]>) -> Result<Self, AudioError> {
+        if sample_rate == 0 || frames.is_empty() {
+            return Err(AudioError::ProcessingError(
+                "Empty announcement clip".into(),
+            ));
+        }
+        frames.truncate(MAX_CLIP_FRAMES);
+        Ok(Self {
+            sample_rate,
+            frames,
+        })
+    }
+
+    /// Décode un clip depuis le contenu d'un fichier WAV
+    pub fn from_wav(bytes: &[u8]) -> Result<Self, AudioError> {
+        let wav = decode_wav(bytes, MAX_CLIP_FRAMES)?;
+        let [left, right] = wav.channels;
+        let frames = left.into_iter().zip(right).map(|(l, r)| [l, r]).collect();
+        Self::new(wav.sample_rate, frames)
+    }
+
+    /// Charge un clip depuis un fichier WAV
+    pub fn load_wav(path: impl AsRef<Path>) -> Result<Self, AudioError> {
+        let path = path.as_ref();
+        let bytes = std::fs::read(path)
+            .map_err(|e| AudioError::IoError(format!("{}: {}", path.display(), e)))?;
+        Self::from_wav(&bytes)
+    }
+
+    pub fn sample_rate(&self) -> u32 {
+        self.sample_rate
+    }
+
+    /// Longueur en frames
+    pub fn len(&self) -> usize {
+        self.frames.len()
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.frames.is_empty()
+    }
+
+    /// Durée du clip
+    pub fn duration(&self) -> Duration {
+        Duration::from_secs_f64(self.frames.len() as f64 / self.sample_rate as f64)
+    }
+
+    /// Frame à une position fractionnaire (interpolation linéaire)
+    fn frame_at(&self, pos: f64) -> [f32; 2] {
+        let index = pos as usize;
+        let frac = (pos - index as f64) as f32;
+        let a = self.frames[index];
+        let b = self.frames.get(index + 1).copied().unwrap_or([0.0; 2]);
+        [a[0] + (b[0] - a[0]) * frac, a[1] + (b[1] - a[1]) * frac]
+    }
+}
+
+// ─── Handle public ────────────────────────────────────────────────────────────
+
+/// Handle partageable pour déclencher des annonces.
+#[derive(Clone)]
+pub struct AnnounceHandle {
+    mixer: MixerHandle,
+    /// Entrée du mixeur réservée aux annonces
+    input: usize,
+    queue: Arc<Mutex<VecDeque<Arc<AnnounceClip>>>>,
+    /// Un clip a été mis en file
+    queued: Arc<Notify>,
+    /// Un clip est en cours d'envoi au mixeur
+    playing: Arc<AtomicBool>,
+    /// Demande d'interruption du clip en cours
+    cancel: Arc<AtomicBool>,
+}
+
+impl AnnounceHandle {
+    fn new(mixer: &MixerHandle, duck_db: f32, ramp_ms: u32) -> Result<Self, AudioError> {
+        let input = mixer.add_input(ANNOUNCE_PRIORITY)?.index();
+        let handle = Self {
+            mixer: mixer.clone(),
+            input,
+            queue: Arc::new(Mutex::new(VecDeque::new())),
+            queued: Arc::new(Notify::new()),
+            playing: Arc::new(AtomicBool::new(false)),
+            cancel: Arc::new(AtomicBool::new(false)),
+        };
+        handle.set_duck_db(duck_db);
+        handle.set_ramp_ms(ramp_ms);
+        Ok(handle)
+    }
+
+    /// Met un clip en file ; retourne sa position (0 : prochain à jouer)
+    pub fn announce(&self, clip: AnnounceClip) -> Result<usize, AudioError> {
+        let mut queue = self.queue.lock().unwrap();
+        if queue.len() >= MAX_PENDING_ANNOUNCEMENTS {
+            return Err(AudioError::ProcessingError(format!(
+                "Too many pending announcements (max {})",
+                MAX_PENDING_ANNOUNCEMENTS
+            )));
+        }
+        queue.push_back(Arc::new(clip));
+        self.queued.notify_one();
+        Ok(queue.len() - 1)
+    }
+
+    /// Nombre d'annonces en attente (hors clip en cours)
+    pub fn pending(&self) -> usize {
+        self.queue.lock().unwrap().len()
+    }
+
+    /// Un clip est-il en cours de lecture ?
+    pub fn is_playing(&self) -> bool {
+        self.playing.load(Ordering::Relaxed) || self.mixer.is_active(self.input)
+    }
+
+    /// Abandonne le clip en cours et les annonces en attente
+    pub fn cancel(&self) {
+        self.queue.lock().unwrap().clear();
+        self.cancel.store(true, Ordering::Relaxed);
+        let _ = self.mixer.clear(self.input);
+    }
+
+    /// Atténuation de la musique pendant une annonce (dB)
+    pub fn duck_db(&self) -> f32 {
+        self.mixer.duck_db().max(MIN_DUCK_DB)
+    }
+
+    /// Définit l'atténuation (bornée à -60–0 dB ; -60 coupe la musique)
+    pub fn set_duck_db(&self, db: f32) {
+        let db = if db.is_finite() {
+            db.clamp(MIN_DUCK_DB, 0.0)
+        } else {
+            DEFAULT_DUCK_DB
+        };
+        self.mixer.set_duck_db(if db <= MIN_DUCK_DB {
+            f32::NEG_INFINITY
+        } else {
+            db
+        });
+    }
+
+    /// Durée des rampes en millisecondes
+    pub fn ramp_ms(&self) -> u32 {
+        self.mixer.ramp_ms()
+    }
+
+    /// Définit la durée des rampes (bornée à 2 s)
+    pub fn set_ramp_ms(&self, ramp_ms: u32) {
+        self.mixer.set_ramp_ms(ramp_ms.min(MAX_DUCK_RAMP_MS));
+    }
+
+    /// Mixeur dans lequel les annonces sont jouées
+    pub fn mixer(&self) -> &MixerHandle {
+        &self.mixer
+    }
+
+    fn next_clip(&self) -> Option<Arc<AnnounceClip>> {
+        self.queue.lock().unwrap().pop_front()
+    }
+}
+
+// ─── Logique de la source ────────────────────────────────────────────────────
+
+/// Clip en cours de lecture
+struct Playback {
+    clip: Arc<AnnounceClip>,
+    /// Silence restant avant le clip (frames), fixé au premier appel
+    lead_in: Option<usize>,
+    /// Position de lecture (frames du clip)
+    position: f64,
+}
+
+impl Playback {
+    fn new(clip: Arc<AnnounceClip>) -> Self {
+        Self {
+            clip,
+            lead_in: None,
+            position: 0.0,
+        }
+    }
+
+    /// Au plus `count` frames suivants à `sample_rate` (vide en fin de clip)
+    ///
+    /// Le silence initial dure `ramp_ms` : l'entrée du mixeur a du signal
+    /// pendant ce temps, la musique est donc abaissée avant la voix.
+    fn next_frames(&mut self, sample_rate: u32, ramp_ms: u32, count: usize) -> Vec<[f32; 2]> {
+        let lead_in = self
+            .lead_in
+            .get_or_insert((sample_rate as u64 * ramp_ms as u64 / 1000) as usize);
+        let silence = (*lead_in).min(count);
+        *lead_in -= silence;
+
+        let mut frames = vec![[0.0f32; 2]; silence];
+        let step = self.clip.sample_rate() as f64 / sample_rate as f64;
+        while frames.len() < count && self.position < self.clip.len() as f64 {
+            frames.push(self.clip.frame_at(self.position));
+            self.position += step;
+        }
+        frames
+    }
+}
+
+struct AnnounceLogic {
+    handle: AnnounceHandle,
+}
+
+impl AnnounceLogic {
+    /// Attend que le mixeur connaisse sa fréquence et que l'entrée ait moins
+    /// de [`FEED_AHEAD_MS`] en tampon ; None si la source est arrêtée
+    async fn wait_for_room(&self, stop_token: &CancellationToken) -> Option<u32> {
+        loop {
+            if let Some(sample_rate) = self.handle.mixer.sample_rate() {
+                let ahead = (sample_rate as u64 * FEED_AHEAD_MS / 1000) as usize;
+                if self.handle.mixer.buffered(self.handle.input) < ahead {
+                    return Some(sample_rate);
+                }
+            }
+            tokio::select! {
+                _ = stop_token.cancelled() => return None,
+                _ = tokio::time::sleep(FEED_POLL_INTERVAL) => {}
+            }
+        }
+    }
+}
+
+#[async_trait::async_trait]
+impl NodeLogic for AnnounceLogic {
+    async fn process(
+        &mut self,
+        _input: Option<mpsc::Receiver<Arc<AudioSegment>>>,
+        output: Vec<mpsc::Sender<Arc<AudioSegment>>>,
+        stop_token: CancellationToken,
+    ) -> Result<(), AudioError> {
+        let mut order = 0u64;
+        let mut timestamp_sec = 0.0f64;
+
+        while !stop_token.is_cancelled() {
+            let clip = match self.handle.next_clip() {
+                Some(clip) => clip,
+                None => {
+                    tokio::select! {
+                        _ = stop_token.cancelled() => break,
+                        _ = self.handle.queued.notified() => {}
+                    }
+                    continue;
+                }
+            };
+            self.handle.cancel.store(false, Ordering::Relaxed);
+            self.handle.playing.store(true, Ordering::Relaxed);
+
+            let mut playback = Playback::new(clip);
+            while let Some(sample_rate) = self.wait_for_room(&stop_token).await {
+                if self.handle.cancel.swap(false, Ordering::Relaxed) {
+                    let _ = self.handle.mixer.clear(self.handle.input);
+                    break;
+                }
+                let count = (sample_rate as f64 * DEFAULT_CHUNK_DURATION_MS / 1000.0) as usize;
+                let frames = playback.next_frames(sample_rate, self.handle.ramp_ms(), count);
+                if frames.is_empty() {
+                    break;
+                }
+                let duration = frames.len() as f64 / sample_rate as f64;
+                let chunk = AudioChunk::F32(AudioChunkData::new(frames, sample_rate, 0.0));
+                let seg = Arc::new(AudioSegment {
+                    order,
+                    timestamp_sec,
+                    segment: _AudioSegment::Chunk(Arc::new(chunk)),
+                });
+                send_to_children("AnnounceSource", &output, seg).await?;
+                order += 1;
+                timestamp_sec += duration;
+            }
+            self.handle.playing.store(false, Ordering::Relaxed);
+        }
+
+        // Le graphe peut être reconstruit : un clip interrompu n'est pas repris
+        self.handle.playing.store(false, Ordering::Relaxed);
+        Ok(())
+    }
+}
+
+// ─── Nœud public ─────────────────────────────────────────────────────────────
+
+/// Source des annonces, reliée à l'entrée auxiliaire du mixeur qui lui est
+/// réservée
+pub struct AnnounceSource {
+    inner: Node<AnnounceLogic>,
+}
+
+impl AnnounceSource {
+    /// Crée une source jouant dans `mixer`, avec l'atténuation et la durée
+    /// de rampe données
+    ///
+    /// Échoue si le mixeur n'a plus d'entrée libre.
+    pub fn new(
+        mixer: &MixerHandle,
+        duck_db: f32,
+        ramp_ms: u32,
+    ) -> Result<(Self, AnnounceHandle), AudioError> {
+        let handle = AnnounceHandle::new(mixer, duck_db, ramp_ms)?;
+        Ok((Self::with_handle(handle.clone())?, handle))
+    }
+
+    /// Crée une source pilotée par un handle existant (reconstruction de
+    /// pipeline) ; l'entrée du mixeur est recréée et enregistrée comme enfant.
+    pub fn with_handle(handle: AnnounceHandle) -> Result<Self, AudioError> {
+        let input = handle.mixer.attach_input(handle.input)?;
+        let mut inner = Node::new_source(AnnounceLogic { handle });
+        inner.register(input.boxed());
+        Ok(Self { inner })
+    }
+}
+
+#[async_trait::async_trait]
+impl AudioPipelineNode for AnnounceSource {
+    fn get_tx(&self) -> Option<mpsc::Sender<Arc<AudioSegment>>> {
+        self.inner.get_tx()
+    }
+
+    fn register(&mut self, child: Box<dyn AudioPipelineNode>) {
+        self.inner.register(child);
+    }
+
+    async fn run(self: Box<Self>, stop_token: CancellationToken) -> Result<(), AudioError> {
+        Box::new(self.inner).run(stop_token).await
+    }
+
+    fn start(self: Box<Self>) -> crate::pipeline::PipelineHandle {
+        Box::new(self.inner).start()
+    }
+}
+
+impl crate::TypedAudioNode for AnnounceSource {
+    fn input_type(&self) -> Option<TypeRequirement> {
+        None // Source
+    }
+
+    fn output_type(&self) -> Option<TypeRequirement> {
+        Some(TypeRequirement::specific(SampleType::F32))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::MixerNode;
+
+    #[test]
+    fn test_lead_in_and_resampling() {
+        // Clip à 24 kHz : 240 frames → 480 frames à 48 kHz, après 10 ms de silence
+        let clip = AnnounceClip::new(24_000, vec![[0.25, -0.25]; 240]).unwrap();
+        let mut playback = Playback::new(Arc::new(clip));
+        let first = playback.next_frames(48_000, 10, 600);
+        assert_eq!(first.len(), 600);
+        assert_eq!(first[479], [0.0, 0.0]);
+        assert_eq!(first[480], [0.25, -0.25]);
+        let second = playback.next_frames(48_000, 10, 600);
+        assert_eq!(second.len(), 360);
+        assert!(playback.next_frames(48_000, 10, 600).is_empty());
+    }
+
+    #[test]
+    fn test_handle_bounds_and_queue() {
+        let (_, mixer) = MixerNode::new(0.0);
+        let (_, handle) = AnnounceSource::new(&mixer, -80.0, 5_000).unwrap();
+        assert_eq!(mixer.input_count(), 2);
+        // Le ducking est celui du mixeur
+        assert_eq!(handle.duck_db(), MIN_DUCK_DB);
+        assert_eq!(mixer.duck_db(), f32::NEG_INFINITY);
+        assert_eq!(mixer.ramp_ms(), MAX_DUCK_RAMP_MS);
+        handle.set_duck_db(-20.0);
+        assert!((mixer.duck_db() + 20.0).abs() < 1e-3);
+
+        let clip = AnnounceClip::new(48_000, vec![[0.1, 0.1]; 480]).unwrap();
+        for i in 0..MAX_PENDING_ANNOUNCEMENTS {
+            assert_eq!(handle.announce(clip.clone()).unwrap(), i);
+        }
+        assert!(handle.announce(clip).is_err());
+        handle.cancel();
+        assert_eq!(handle.pending(), 0);
+        assert!(!handle.is_playing());
+    }
+}
@@ -1,12 +1,13 @@
 use crate::{
     dsp::{i16_stereo_to_pairs_f32, i24_as_i32_stereo_to_pairs_f32, i32_stereo_to_interleaved_f32},
-    nodes::{AudioError, TypedAudioNode, DEFAULT_CHANNEL_SIZE},
+    nodes::{resampling_node::ResamplingLogic, AudioError, TypedAudioNode, DEFAULT_CHANNEL_SIZE},
     pipeline::{Node, NodeLogic},
     type_constraints::TypeRequirement,
     AudioChunk, AudioPipelineNode, AudioSegment, BitDepth, SyncMarker,
 };
 use cpal::traits::{DeviceTrait, HostTrait, StreamTrait};
 use std::collections::VecDeque;
+use std::fmt;
 use std::sync::mpsc as std_mpsc;
 use std::sync::{Arc, Mutex};
 use std::thread;
@@ -158,10 +159,252 @@ fn chunk_to_f32_interleaved(chunk: &AudioChunk) -> Vec<f32> {
     }
 }
 
+// ═══════════════════════════════════════════════════════════════════════════
+// Capacités du périphérique de sortie
+// ═══════════════════════════════════════════════════════════════════════════
+
+/// Fréquences standard recherchées lors du sondage du périphérique
+const PROBED_SAMPLE_RATES: [u32; 9] = [
+    32_000, 44_100, 48_000, 88_200, 96_000, 176_400, 192_000, 352_800, 384_000,
+];
+
+/// Capacités stéréo d'un périphérique de sortie
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct OutputCapabilities {
+    /// Nom du périphérique
+    pub device: String,
+    /// Fréquences standard acceptées, par ordre croissant
+    pub sample_rates: Vec<u32>,
+    /// Tailles d'échantillon acceptées (bits), par ordre croissant
+    pub bit_depths: Vec<u16>,
+    /// Fréquence de la configuration par défaut du périphérique
+    pub default_sample_rate: u32,
+}
+
+impl OutputCapabilities {
+    /// Sonde le périphérique de sortie par défaut
+    pub fn probe_default() -> Result<Self, AudioError> {
+        let device = default_output_device()?;
+        Ok(probe_device(&device)?.0)
+    }
+
+    /// Choisit le mode de sortie d'un flux à `stream_rate`
+    ///
+    /// La fréquence du flux est conservée si le périphérique l'accepte.
+    /// Sinon le flux est rééchantillonné vers la plus petite fréquence
+    /// supérieure de la même famille (44,1 ou 48 kHz), à défaut vers la plus
+    /// haute fréquence acceptée.
+    pub fn choose_mode(&self, stream_rate: u32) -> OutputMode {
+        if self.sample_rates.contains(&stream_rate) {
+            return OutputMode::Native {
+                sample_rate: stream_rate,
+            };
+        }
+
+        let is_cd_family = |rate: u32| rate % 11_025 == 0;
+        let family: Vec<u32> = self
+            .sample_rates
+            .iter()
+            .copied()
+            .filter(|&rate| is_cd_family(rate) == is_cd_family(stream_rate))
+            .collect();
+        let target = family
+            .iter()
+            .copied()
+            .filter(|&rate| rate >= stream_rate)
+            .min()
+            .or_else(|| family.iter().copied().max())
+            .or_else(|| self.sample_rates.iter().copied().max())
+            .unwrap_or(self.default_sample_rate);
+
+        if target == stream_rate {
+            OutputMode::Native {
+                sample_rate: stream_rate,
+            }
+        } else {
+            OutputMode::Resampled {
+                from: stream_rate,
+                to: target,
+            }
+        }
+    }
+}
+
+/// Mode de sortie retenu par un [`AudioSink`]
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum OutputMode {
+    /// Le flux est joué à sa fréquence d'origine
+    Native { sample_rate: u32 },
+    /// Le flux est rééchantillonné pour le périphérique
+    Resampled { from: u32, to: u32 },
+}
+
+impl OutputMode {
+    /// Fréquence effective du périphérique
+    pub fn output_sample_rate(&self) -> u32 {
+        match *self {
+            OutputMode::Native { sample_rate } => sample_rate,
+            OutputMode::Resampled { to, .. } => to,
+        }
+    }
+}
+
+impl fmt::Display for OutputMode {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match *self {
+            OutputMode::Native { sample_rate } => write!(f, "native {} Hz", sample_rate),
+            OutputMode::Resampled { from, to } => write!(f, "resampled {} → {} Hz", from, to),
+        }
+    }
+}
+
+#[derive(Default)]
+struct OutputState {
+    capabilities: Option<OutputCapabilities>,
+    mode: Option<OutputMode>,
+}
+
+/// Accès au mode de sortie choisi par un [`AudioSink`]
+///
+/// Renseigné à l'ouverture du périphérique, c'est-à-dire à la réception
+/// du premier chunk.
+#[derive(Clone, Default)]
+pub struct OutputModeHandle {
+    state: Arc<Mutex<OutputState>>,
+}
+
+impl OutputModeHandle {
+    /// Mode de sortie courant (None tant que le périphérique n'est pas ouvert)
+    pub fn mode(&self) -> Option<OutputMode> {
+        self.state.lock().unwrap().mode
+    }
+
+    /// Capacités sondées du périphérique
+    pub fn capabilities(&self) -> Option<OutputCapabilities> {
+        self.state.lock().unwrap().capabilities.clone()
+    }
+
+    fn set(&self, capabilities: OutputCapabilities, mode: OutputMode) {
+        let mut state = self.state.lock().unwrap();
+        state.capabilities = Some(capabilities);
+        state.mode = Some(mode);
+    }
+}
+
+/// Formats d'échantillon alimentés par le sink, par ordre de préférence
+fn format_rank(format: cpal::SampleFormat) -> u8 {
+    match format {
+        cpal::SampleFormat::F32 => 3,
+        cpal::SampleFormat::I16 => 2,
+        cpal::SampleFormat::U16 => 1,
+        _ => 0,
+    }
+}
+
+fn default_output_device() -> Result<cpal::Device, AudioError> {
+    cpal::default_host()
+        .default_output_device()
+        .ok_or_else(|| AudioError::ProcessingError("No output device available".to_string()))
+}
+
+/// Sonde les configurations stéréo exploitables d'un périphérique
+fn probe_device(
+    device: &cpal::Device,
+) -> Result<(OutputCapabilities, Vec<cpal::SupportedStreamConfigRange>), AudioError> {
+    let ranges: Vec<_> = device
+        .supported_output_configs()
+        .map_err(|e| AudioError::ProcessingError(format!("Failed to query output configs: {}", e)))?
+        .filter(|r| r.channels() == 2 && format_rank(r.sample_format()) > 0)
+        .collect();
+
+    let sample_rates = PROBED_SAMPLE_RATES
+        .iter()
+        .copied()
+        .filter(|&rate| {
+            ranges
+                .iter()
+                .any(|r| r.min_sample_rate().0 <= rate && rate <= r.max_sample_rate().0)
+        })
+        .collect();
+    let mut bit_depths: Vec<u16> = ranges
+        .iter()
+        .map(|r| (r.sample_format().sample_size() * 8) as u16)
+        .collect();
+    bit_depths.sort_unstable();
+    bit_depths.dedup();
+
+    let capabilities = OutputCapabilities {
+        device: device.name().unwrap_or_else(|_| "Unknown".to_string()),
+        sample_rates,
+        bit_depths,
+        default_sample_rate: device
+            .default_output_config()
+            .map(|c| c.sample_rate().0)
+            .unwrap_or(48_000),
+    };
+    Ok((capabilities, ranges))
+}
+
+/// Choisit la configuration du stream cpal pour un flux à `stream_rate`
+fn select_output_config(
+    device: &cpal::Device,
+    stream_rate: u32,
+) -> Result<(cpal::SupportedStreamConfig, OutputCapabilities, OutputMode), AudioError> {
+    let (capabilities, ranges) = probe_device(device)?;
+    let mode = capabilities.choose_mode(stream_rate);
+    let rate = cpal::SampleRate(mode.output_sample_rate());
+
+    let config = ranges
+        .into_iter()
+        .filter(|r| r.min_sample_rate() <= rate && rate <= r.max_sample_rate())
+        .max_by_key(|r| format_rank(r.sample_format()))
+        .map(|r| r.with_sample_rate(rate));
+    if let Some(config) = config {
+        return Ok((config, capabilities, mode));
+    }
+
+    // Aucune configuration stéréo exploitable : configuration par défaut
+    let config = device
+        .default_output_config()
+        .map_err(|e| AudioError::ProcessingError(format!("Failed to get output config: {}", e)))?;
+    let output_rate = config.sample_rate().0;
+    let mode = if output_rate == stream_rate {
+        OutputMode::Native {
+            sample_rate: stream_rate,
+        }
+    } else {
+        OutputMode::Resampled {
+            from: stream_rate,
+            to: output_rate,
+        }
+    };
+    Ok((config, capabilities, mode))
+}
+
+/// Ramène un chunk à la fréquence de sortie (None si le rééchantillonnage échoue)
+fn adapt_chunk(
+    resampler: &mut ResamplingLogic,
+    chunk: &Arc<AudioChunk>,
+    output_rate: u32,
+) -> Option<Arc<AudioChunk>> {
+    if chunk.sample_rate() == output_rate {
+        return Some(chunk.clone());
+    }
+    match resampler.resample_chunk(chunk) {
+        Ok(resampled) => Some(Arc::new(resampled)),
+        Err(e) => {
+            tracing::warn!("AudioSink: dropping chunk, resampling failed: {}", e);
+            None
+        }
+    }
+}
+
 /// Sink qui joue les `AudioSegment` reçus sur la sortie audio standard via cpal.
 ///
 /// Ce sink :
 /// - Détecte automatiquement le format hardware (I16, F32, U16)
+/// - Ouvre le périphérique à la fréquence du flux si elle est supportée,
+///   sinon rééchantillonne vers la fréquence la plus proche
 /// - Accepte tous les formats AudioChunk en entrée
 /// - Convertit en utilisant les fonctions optimisées SIMD du module dsp
 /// - Gère TrackBoundary pour des transitions propres
@@ -174,18 +417,21 @@ fn chunk_to_f32_interleaved(chunk: &AudioChunk) -> Vec<f32> {
 /// Logique pure de lecture audio via cpal
 pub struct AudioSinkLogic {
     use_null_output: bool,
+    output: OutputModeHandle,
 }
 
 impl AudioSinkLogic {
     pub fn new() -> Self {
         Self {
             use_null_output: false,
+            output: OutputModeHandle::default(),
         }
     }
 
     pub fn with_null_output() -> Self {
         Self {
             use_null_output: true,
+            output: OutputModeHandle::default(),
         }
     }
 
@@ -261,25 +507,56 @@ impl NodeLogic for AudioSinkLogic {
             return Self::process_null_output(rx, stop_token).await;
         }
 
+        // Attendre le premier chunk : sa fréquence détermine la configuration
+        let first_chunk = loop {
+            let segment = tokio::select! {
+                result = rx.recv() => {
+                    match result {
+                        Some(seg) => seg,
+                        None => {
+                            tracing::debug!("AudioSinkLogic: input channel closed before any audio");
+                            return Ok(());
+                        }
+                    }
+                }
+                _ = stop_token.cancelled() => {
+                    tracing::debug!("AudioSinkLogic cancelled before any audio");
+                    return Ok(());
+                }
+            };
+            match &segment.segment {
+                crate::_AudioSegment::Chunk(chunk) => break chunk.clone(),
+                crate::_AudioSegment::Sync(marker) => {
+                    if matches!(**marker, SyncMarker::EndOfStream) {
+                        tracing::debug!("AudioSink: EndOfStream received before any audio");
+                        return Ok(());
+                    }
+                }
+            }
+        };
+
         // Créer le buffer partagé
         let buffer = Arc::new(Mutex::new(SharedBuffer::new()));
         let buffer_clone = buffer.clone();
 
         // Initialiser cpal
-        let host = cpal::default_host();
-        let device = host
-            .default_output_device()
-            .ok_or_else(|| AudioError::ProcessingError("No output device available".to_string()))?;
+        let device = default_output_device()?;
 
         tracing::debug!(
             "Using audio device: {}",
             device.name().unwrap_or_else(|_| "Unknown".to_string())
         );
 
-        // Obtenir la config par défaut
-        let config = device.default_output_config().map_err(|e| {
-            AudioError::ProcessingError(format!("Failed to get output config: {}", e))
-        })?;
+        // Choisir la configuration selon les capacités du périphérique
+        let (config, capabilities, mode) =
+            select_output_config(&device, first_chunk.sample_rate())?;
+        tracing::info!(
+            "🔊 AudioSink: {} on {} (supported rates: {:?})",
+            mode,
+            capabilities.device,
+            capabilities.sample_rates
+        );
+        self.output.set(capabilities, mode);
 
         let sample_format = config.sample_format();
         let sample_rate = config.sample_rate().0;
@@ -292,6 +569,12 @@ impl NodeLogic for AudioSinkLogic {
             sample_format
         );
 
+        // Rééchantillonnage vers la fréquence du périphérique si nécessaire
+        let mut resampler = ResamplingLogic::new(sample_rate);
+        if let Some(chunk) = adapt_chunk(&mut resampler, &first_chunk, sample_rate) {
+            buffer.lock().unwrap().push_chunk(chunk);
+        }
+
         // Créer un channel pour commander le thread du stream
         let (stream_cmd_tx, stream_cmd_rx) = std_mpsc::channel::<bool>();
 
@@ -449,10 +732,10 @@ impl NodeLogic for AudioSinkLogic {
             // Traiter selon le type de segment
             match &segment.segment {
                 crate::_AudioSegment::Chunk(chunk) => {
-                    // Ajouter le chunk au buffer (pas de conversion ici)
-                    {
+                    // Ajouter le chunk au buffer (conversion de format dans le callback)
+                    if let Some(chunk) = adapt_chunk(&mut resampler, chunk, sample_rate) {
                         let mut buf = buffer.lock().unwrap();
-                        buf.push_chunk(chunk.clone());
+                        buf.push_chunk(chunk);
                     }
 
                     tracing::trace!(
@@ -511,6 +794,13 @@ impl NodeLogic for AudioSinkLogic {
 /// Les conversions sont effectuées avec les fonctions optimisées SIMD du
 /// module `dsp::int_float`.
 ///
+/// # Fréquence d'échantillonnage
+///
+/// Le périphérique est sondé à la réception du premier chunk (voir
+/// [`OutputCapabilities`]) : il est ouvert à la fréquence du flux quand il
+/// l'accepte, sinon le flux est rééchantillonné. Le mode retenu est exposé
+/// par [`AudioSink::output_mode`].
+///
 /// # Volume
 ///
 /// Ce sink ne gère PAS le volume. Utilisez un `VolumeNode` avant AudioSink
@@ -537,33 +827,41 @@ impl NodeLogic for AudioSinkLogic {
 /// ```
 pub struct AudioSink {
     inner: Node<AudioSinkLogic>,
+    output: OutputModeHandle,
 }
 
 impl AudioSink {
     /// Crée un nouveau AudioSink
     pub fn new() -> Self {
+        Self::with_logic(AudioSinkLogic::new(), DEFAULT_CHANNEL_SIZE)
+    }
+
+    fn with_logic(logic: AudioSinkLogic, channel_size: usize) -> Self {
+        let output = logic.output.clone();
         Self {
-            inner: Node::new_with_input(AudioSinkLogic::new(), DEFAULT_CHANNEL_SIZE),
+            inner: Node::new_with_input(logic, channel_size),
+            output,
         }
     }
 
+    /// Handle de lecture du mode de sortie, à récupérer avant de démarrer
+    pub fn output_mode(&self) -> OutputModeHandle {
+        self.output.clone()
+    }
+
     pub fn make() -> Box<dyn AudioPipelineNode> {
         Self::new().boxed()
     }
 
     /// Crée un nouveau AudioSink avec une taille de channel personnalisée
     pub fn with_channel_size(channel_size: usize) -> Box<dyn AudioPipelineNode> {
-        Self {
-            inner: Node::new_with_input(AudioSinkLogic::new(), channel_size),
-        }.boxed()
+        Self::with_logic(AudioSinkLogic::new(), channel_size).boxed()
     }
 
     /// Crée un AudioSink avec null output (pour tests sans carte audio)
     /// Consomme les segments audio sans les jouer
     pub fn with_null_output() -> Box<dyn AudioPipelineNode> {
-        Self {
-            inner: Node::new_with_input(AudioSinkLogic::with_null_output(), DEFAULT_CHANNEL_SIZE),
-        }.boxed()
+        Self::with_logic(AudioSinkLogic::with_null_output(), DEFAULT_CHANNEL_SIZE).boxed()
     }
 }
 
@@ -667,4 +965,37 @@ mod tests {
         buffer.mark_end();
         assert!(buffer.is_finished());
     }
+
+    #[test]
+    fn test_output_mode_selection() {
+        let capabilities = OutputCapabilities {
+            device: "test".to_string(),
+            sample_rates: vec![48_000, 88_200, 96_000, 192_000],
+            bit_depths: vec![16, 32],
+            default_sample_rate: 48_000,
+        };
+
+        assert_eq!(
+            capabilities.choose_mode(96_000),
+            OutputMode::Native {
+                sample_rate: 96_000
+            }
+        );
+        // 44,1 kHz absent : multiple de la même famille
+        assert_eq!(
+            capabilities.choose_mode(44_100),
+            OutputMode::Resampled {
+                from: 44_100,
+                to: 88_200
+            }
+        );
+        // Au-delà des fréquences acceptées : la plus haute de la famille
+        assert_eq!(
+            capabilities.choose_mode(384_000),
+            OutputMode::Resampled {
+                from: 384_000,
+                to: 192_000
+            }
+        );
+    }
 }
@@ -0,0 +1,341 @@
+//! ChannelMixNode — balance gauche/droite, inversion des canaux et mono.
+//!
+//! Utile dans les pièces où les enceintes sont placées de façon asymétrique :
+//! la balance atténue un canal, l'inversion échange gauche et droite. Le mode
+//! mono (une seule enceinte : salle de bain, cuisine) somme les deux canaux
+//! avec une loi de panoramique à -3 dB.
+//!
+//! Réglages neutres (balance 0, pas d'inversion, stéréo) : les chunks passent
+//! sans modification. L'inversion seule conserve le type d'échantillon ; la
+//! balance et le mono convertissent en F32. Les changements de réglage en
+//! cours de lecture sont fondus sur `DEFAULT_CROSSFADE_MS`.
+
+use crate::{
+    dsp::crossfade::{crossfade_frames, Crossfade, StereoProcessor, DEFAULT_CROSSFADE_MS},
+    nodes::AudioError,
+    pipeline::{send_to_children, AudioPipelineNode, Node, NodeLogic},
+    type_constraints::TypeRequirement,
+    AudioChunk, AudioChunkData, AudioSegment, Sample, _AudioSegment,
+};
+use std::sync::{
+    atomic::{AtomicBool, AtomicI32, Ordering},
+    Arc,
+};
+use tokio::sync::mpsc;
+use tokio_util::sync::CancellationToken;
+
+/// Balance extrême (canal opposé coupé)
+pub const MAX_BALANCE: i32 = 100;
+
+/// Gain de chaque canal dans la somme mono (-3 dB, 1/√2)
+pub const MONO_PAN_GAIN: f32 = std::f32::consts::FRAC_1_SQRT_2;
+
+// ─── Handle public ────────────────────────────────────────────────────────────
+
+/// Handle partageable pour piloter la balance et l'inversion des canaux.
+#[derive(Clone, Default)]
+pub struct ChannelMixHandle {
+    /// Balance de -100 (gauche seule) à +100 (droite seule)
+    balance: Arc<AtomicI32>,
+    /// Inversion gauche/droite
+    swap: Arc<AtomicBool>,
+    /// Downmix mono
+    mono: Arc<AtomicBool>,
+}
+
+impl ChannelMixHandle {
+    /// Balance courante (-100..=100)
+    pub fn balance(&self) -> i32 {
+        self.balance.load(Ordering::Relaxed)
+    }
+
+    /// Définit la balance (bornée à -100..=100)
+    pub fn set_balance(&self, balance: i32) {
+        self.balance
+            .store(balance.clamp(-MAX_BALANCE, MAX_BALANCE), Ordering::Relaxed);
+    }
+
+    /// Inversion des canaux active ?
+    pub fn channel_swap(&self) -> bool {
+        self.swap.load(Ordering::Relaxed)
+    }
+
+    /// Active ou désactive l'inversion des canaux
+    pub fn set_channel_swap(&self, swap: bool) {
+        self.swap.store(swap, Ordering::Relaxed);
+    }
+
+    /// Downmix mono actif ?
+    pub fn mono(&self) -> bool {
+        self.mono.load(Ordering::Relaxed)
+    }
+
+    /// Active ou désactive le downmix mono
+    pub fn set_mono(&self, mono: bool) {
+        self.mono.store(mono, Ordering::Relaxed);
+    }
+
+    /// Gains linéaires (gauche, droite) correspondant à la balance
+    pub fn channel_gains(&self) -> (f32, f32) {
+        balance_gains(self.balance())
+    }
+}
+
+/// Gains (gauche, droite) pour une balance donnée.
+///
+/// Le canal du côté opposé à la balance est atténué linéairement,
+/// l'autre reste à gain unitaire.
+pub fn balance_gains(balance: i32) -> (f32, f32) {
+    let b = balance.clamp(-MAX_BALANCE, MAX_BALANCE) as f32 / MAX_BALANCE as f32;
+    if b >= 0.0 {
+        (1.0 - b, 1.0)
+    } else {
+        (1.0, 1.0 + b)
+    }
+}
+
+fn swap_frames<T: Sample>(data: &Arc<AudioChunkData<T>>) -> Arc<AudioChunkData<T>> {
+    let frames = data
+        .get_frames()
+        .iter()
+        .map(|frame| [frame[1], frame[0]])
+        .collect();
+    AudioChunkData::new(frames, data.get_sample_rate(), data.get_gain_db())
+}
+
+/// Inverse les canaux sans changer le type d'échantillon
+fn swap_chunk(chunk: &AudioChunk) -> AudioChunk {
+    match chunk {
+        AudioChunk::I16(d) => AudioChunk::I16(swap_frames(d)),
+        AudioChunk::I24(d) => AudioChunk::I24(swap_frames(d)),
+        AudioChunk::I32(d) => AudioChunk::I32(swap_frames(d)),
+        AudioChunk::F32(d) => AudioChunk::F32(swap_frames(d)),
+        AudioChunk::F64(d) => AudioChunk::F64(swap_frames(d)),
+    }
+}
+
+/// Réglages de mixage, utilisés comme traitement frame par frame pendant un fondu
+#[derive(Debug, Clone, Copy, PartialEq)]
+struct MixSettings {
+    balance: i32,
+    swap: bool,
+    mono: bool,
+}
+
+impl MixSettings {
+    fn from_handle(handle: &ChannelMixHandle) -> Self {
+        Self {
+            balance: handle.balance(),
+            swap: handle.channel_swap(),
+            mono: handle.mono(),
+        }
+    }
+}
+
+impl StereoProcessor for MixSettings {
+    fn process_frame(&mut self, frame: [f32; 2]) -> [f32; 2] {
+        let (gain_l, gain_r) = balance_gains(self.balance);
+        if self.mono {
+            // L'inversion n'a pas d'effet sur une somme mono
+            let m = (frame[0] + frame[1]) * MONO_PAN_GAIN;
+            return [m * gain_l, m * gain_r];
+        }
+        let [l, r] = if self.swap { [frame[1], frame[0]] } else { frame };
+        [l * gain_l, r * gain_r]
+    }
+}
+
+/// Applique un traitement frame par frame à un chunk converti en F32
+fn process_f32<P: StereoProcessor>(chunk: &AudioChunk, processor: &mut P) -> AudioChunk {
+    let data = match chunk.to_f32().apply_gain() {
+        AudioChunk::F32(data) => data,
+        _ => unreachable!("to_f32 always returns an F32 chunk"),
+    };
+    let frames = data
+        .get_frames()
+        .iter()
+        .map(|frame| processor.process_frame(*frame))
+        .collect();
+    AudioChunk::F32(AudioChunkData::new(frames, data.get_sample_rate(), 0.0))
+}
+
+/// Applique balance, inversion et mono à un chunk (None si réglages neutres)
+fn mix_chunk(chunk: &AudioChunk, balance: i32, swap: bool, mono: bool) -> Option<AudioChunk> {
+    if balance == 0 && !mono {
+        return swap.then(|| swap_chunk(chunk));
+    }
+
+    let mut settings = MixSettings {
+        balance,
+        swap,
+        mono,
+    };
+    Some(process_f32(chunk, &mut settings))
+}
+
+// ─── Logique du nœud ─────────────────────────────────────────────────────────
+
+struct ChannelMixLogic {
+    handle: ChannelMixHandle,
+    /// Réglages appliqués et fréquence du flux
+    configured: Option<(MixSettings, u32)>,
+    mix: Crossfade<MixSettings>,
+}
+
+impl ChannelMixLogic {
+    fn new(handle: ChannelMixHandle) -> Self {
+        let settings = MixSettings::from_handle(&handle);
+        Self {
+            handle,
+            configured: None,
+            mix: Crossfade::new(settings),
+        }
+    }
+
+    /// Mixe un chunk (None si réglages neutres et aucun fondu en cours)
+    fn process_chunk(&mut self, chunk: &AudioChunk) -> Option<AudioChunk> {
+        let wanted = MixSettings::from_handle(&self.handle);
+        let sample_rate = chunk.sample_rate();
+        match self.configured {
+            Some((settings, rate)) if rate == sample_rate => {
+                if settings != wanted {
+                    self.mix
+                        .swap(wanted, crossfade_frames(DEFAULT_CROSSFADE_MS, sample_rate));
+                }
+            }
+            _ => self.mix.reset(wanted),
+        }
+        self.configured = Some((wanted, sample_rate));
+
+        if self.mix.is_fading() {
+            return Some(process_f32(chunk, &mut self.mix));
+        }
+        mix_chunk(chunk, wanted.balance, wanted.swap, wanted.mono)
+    }
+}
+
+#[async_trait::async_trait]
+impl NodeLogic for ChannelMixLogic {
+    async fn process(
+        &mut self,
+        input: Option<mpsc::Receiver<Arc<AudioSegment>>>,
+        output: Vec<mpsc::Sender<Arc<AudioSegment>>>,
+        stop_token: CancellationToken,
+    ) -> Result<(), AudioError> {
+        let mut input = input.ok_or_else(|| {
+            AudioError::ProcessingError("ChannelMixNode requires an input".into())
+        })?;
+
+        loop {
+            let seg = tokio::select! {
+                _ = stop_token.cancelled() => break,
+                segment = input.recv() => match segment {
+                    None => break,
+                    Some(seg) => seg,
+                },
+            };
+
+            let seg = match &seg.segment {
+                _AudioSegment::Chunk(chunk) => match self.process_chunk(chunk) {
+                    Some(mixed) => Arc::new(AudioSegment {
+                        order: seg.order,
+                        timestamp_sec: seg.timestamp_sec,
+                        segment: _AudioSegment::Chunk(Arc::new(mixed)),
+                    }),
+                    None => seg,
+                },
+                _AudioSegment::Sync(_) => seg,
+            };
+
+            send_to_children("ChannelMixNode", &output, seg).await?;
+        }
+
+        Ok(())
+    }
+}
+
+// ─── Nœud public ─────────────────────────────────────────────────────────────
+
+pub struct ChannelMixNode {
+    inner: Node<ChannelMixLogic>,
+}
+
+impl ChannelMixNode {
+    /// Crée un nœud neutre (balance 0, pas d'inversion, stéréo)
+    pub fn new() -> (Self, ChannelMixHandle) {
+        let handle = ChannelMixHandle::default();
+        (Self::with_handle(handle.clone()), handle)
+    }
+
+    /// Crée un nœud piloté par un handle existant (reconstruction de pipeline)
+    pub fn with_handle(handle: ChannelMixHandle) -> Self {
+        Self {
+            inner: Node::new_with_input(ChannelMixLogic::new(handle), 16),
+        }
+    }
+}
+
+#[async_trait::async_trait]
+impl AudioPipelineNode for ChannelMixNode {
+    fn get_tx(&self) -> Option<mpsc::Sender<Arc<AudioSegment>>> {
+        self.inner.get_tx()
+    }
+
+    fn register(&mut self, child: Box<dyn AudioPipelineNode>) {
+        self.inner.register(child);
+    }
+
+    async fn run(self: Box<Self>, stop_token: CancellationToken) -> Result<(), AudioError> {
+        Box::new(self.inner).run(stop_token).await
+    }
+
+    fn start(self: Box<Self>) -> crate::pipeline::PipelineHandle {
+        Box::new(self.inner).start()
+    }
+}
+
+impl crate::TypedAudioNode for ChannelMixNode {
+    fn input_type(&self) -> Option<TypeRequirement> {
+        None // Accepte tout
+    }
+
+    fn output_type(&self) -> Option<TypeRequirement> {
+        None // Type d'entrée, F32 si balance ou mono actif
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_swap_keeps_sample_type() {
+        let chunk = AudioChunk::I32(AudioChunkData::new(vec![[1i32, 2i32]; 4], 48_000, 0.0));
+        assert!(mix_chunk(&chunk, 0, false, false).is_none());
+        match mix_chunk(&chunk, 0, true, false).unwrap() {
+            AudioChunk::I32(d) => assert_eq!(d.get_frames()[0], [2, 1]),
+            _ => panic!("expected I32"),
+        }
+    }
+
+    #[test]
+    fn test_mono_downmix_pan_law() {
+        let chunk = AudioChunk::F32(AudioChunkData::new(vec![[1.0f32, 0.0f32]; 4], 48_000, 0.0));
+        match mix_chunk(&chunk, 0, false, true).unwrap() {
+            AudioChunk::F32(d) => {
+                let [l, r] = d.get_frames()[0];
+                assert_eq!(l, r);
+                assert!((l - MONO_PAN_GAIN).abs() < 1e-6);
+            }
+            _ => panic!("expected F32"),
+        }
+    }
+
+    #[test]
+    fn test_balance_gains() {
+        assert_eq!(balance_gains(0), (1.0, 1.0));
+        assert_eq!(balance_gains(100), (0.0, 1.0));
+        assert_eq!(balance_gains(-50), (1.0, 0.5));
+        assert_eq!(balance_gains(500), (0.0, 1.0));
+    }
+}
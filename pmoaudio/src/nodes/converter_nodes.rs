@@ -14,6 +14,7 @@
 //! lignes par converter à ~20 lignes de logique pure).
 
 use crate::{
+    dsp::{DitherMode, Ditherer},
     nodes::AudioError,
     pipeline::{send_to_children, Node, NodeLogic},
     AudioChunk, AudioPipelineNode, AudioSegment,
@@ -33,7 +34,7 @@ pub struct ConverterLogic<F> {
 
 impl<F> ConverterLogic<F>
 where
-    F: Fn(&AudioChunk) -> AudioChunk + Send + 'static,
+    F: FnMut(&AudioChunk) -> AudioChunk + Send + 'static,
 {
     pub fn new(convert_fn: F) -> Self {
         Self { convert_fn }
@@ -43,7 +44,7 @@ where
 #[async_trait::async_trait]
 impl<F> NodeLogic for ConverterLogic<F>
 where
-    F: Fn(&AudioChunk) -> AudioChunk + Send + 'static,
+    F: FnMut(&AudioChunk) -> AudioChunk + Send + 'static,
 {
     async fn process(
         &mut self,
@@ -112,12 +113,34 @@ where
 // Converters spécifiques - Fonctions factory simplifiées
 // ═══════════════════════════════════════════════════════════════════════════
 
+/// Fonction de conversion avec état (dither)
+type StatefulConvert = Box<dyn FnMut(&AudioChunk) -> AudioChunk + Send>;
+
 /// Node de conversion vers I16 (16-bit signed integer)
-pub struct ToI16Node(Node<ConverterLogic<fn(&AudioChunk) -> AudioChunk>>);
+///
+/// Par défaut les échantillons sont arrondis ; [`ToI16Node::with_dither`]
+/// applique un dither (voir [`crate::dsp::dither`]) aux sources de plus
+/// de 16 bits.
+pub struct ToI16Node(Node<ConverterLogic<StatefulConvert>>);
 
 impl ToI16Node {
     pub fn new() -> Self {
-        Self(Node::new_with_input(ConverterLogic::new(|chunk: &AudioChunk| chunk.to_i16()), 16))
+        Self::with_dither(DitherMode::Off)
+    }
+
+    /// Conversion avec le dither `mode`
+    pub fn with_dither(mode: DitherMode) -> Self {
+        Self(Node::new_with_input(ConverterLogic::new(Self::convert_fn(mode)), 16))
+    }
+
+    fn convert_fn(mode: DitherMode) -> StatefulConvert {
+        match mode {
+            DitherMode::Off => Box::new(|chunk: &AudioChunk| chunk.to_i16()),
+            mode => {
+                let mut ditherer = Ditherer::new(mode);
+                Box::new(move |chunk: &AudioChunk| chunk.to_i16_dithered(&mut ditherer))
+            }
+        }
     }
 
     pub fn make() -> Box<dyn AudioPipelineNode> {
@@ -125,7 +148,7 @@ impl ToI16Node {
     }
 
     pub fn with_channel_size(channel_size: usize) -> Box<dyn AudioPipelineNode> {
-        Self(Node::new_with_input(ConverterLogic::new(|chunk: &AudioChunk| chunk.to_i16()), channel_size)).boxed()
+        Self(Node::new_with_input(ConverterLogic::new(Self::convert_fn(DitherMode::Off)), channel_size)).boxed()
     }
 }
 
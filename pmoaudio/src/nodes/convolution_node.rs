@@ -0,0 +1,295 @@
+//! ConvolutionNode — correction de pièce par convolution.
+//!
+//! Applique une réponse impulsionnelle (typiquement un filtre de correction
+//! calculé par REW) avec le moteur de convolution partitionnée de
+//! `dsp::convolution`. La convolution retarde le signal d'une partition :
+//! cette latence est exposée par le handle pour corriger l'horloge de
+//! position.
+//!
+//! Sans réponse chargée, ou si sa fréquence d'échantillonnage ne correspond
+//! pas au flux, les chunks passent sans modification.
+//!
+//! Un changement de réponse en cours de lecture est fondu sur
+//! `DEFAULT_CROSSFADE_MS` après amorçage du nouveau convolueur.
+
+use crate::{
+    dsp::convolution::{ImpulseResponse, PartitionedConvolver, DEFAULT_PARTITION_FRAMES},
+    dsp::crossfade::{crossfade_frames, Crossfade, DEFAULT_CROSSFADE_MS},
+    nodes::AudioError,
+    pipeline::{send_to_children, AudioPipelineNode, Node, NodeLogic},
+    type_constraints::TypeRequirement,
+    AudioChunk, AudioChunkData, AudioSegment, _AudioSegment,
+};
+use std::sync::{
+    atomic::{AtomicU32, AtomicU64, Ordering},
+    Arc, Mutex,
+};
+use tokio::sync::mpsc;
+use tokio_util::sync::CancellationToken;
+
+// ─── Handle public ────────────────────────────────────────────────────────────
+
+/// Handle partageable pour charger une réponse impulsionnelle et lire la latence.
+#[derive(Clone, Default)]
+pub struct ConvolutionHandle {
+    impulse: Arc<Mutex<Option<Arc<ImpulseResponse>>>>,
+    /// Incrémenté à chaque changement de réponse
+    generation: Arc<AtomicU64>,
+    /// Latence courante (frames, 0 si inactif)
+    latency_frames: Arc<AtomicU32>,
+    /// Fréquence d'échantillonnage du flux traité
+    sample_rate: Arc<AtomicU32>,
+}
+
+impl ConvolutionHandle {
+    /// Remplace la réponse impulsionnelle (None désactive la convolution)
+    pub fn set_impulse_response(&self, impulse: Option<ImpulseResponse>) {
+        *self.impulse.lock().unwrap() = impulse.map(Arc::new);
+        self.generation.fetch_add(1, Ordering::Relaxed);
+    }
+
+    /// Réponse impulsionnelle courante
+    pub fn impulse_response(&self) -> Option<Arc<ImpulseResponse>> {
+        self.impulse.lock().unwrap().clone()
+    }
+
+    /// Latence introduite par la convolution (frames)
+    pub fn latency_frames(&self) -> u32 {
+        self.latency_frames.load(Ordering::Relaxed)
+    }
+
+    /// Latence introduite par la convolution (secondes)
+    pub fn latency_sec(&self) -> f64 {
+        let sample_rate = self.sample_rate.load(Ordering::Relaxed);
+        if sample_rate == 0 {
+            return 0.0;
+        }
+        self.latency_frames() as f64 / sample_rate as f64
+    }
+}
+
+// ─── Logique du nœud ─────────────────────────────────────────────────────────
+
+struct ConvolutionLogic {
+    handle: ConvolutionHandle,
+    partition_frames: usize,
+    /// Génération et fréquence pour lesquelles le convolueur a été préparé
+    prepared: Option<(u64, u32)>,
+    convolver: Crossfade<Option<PartitionedConvolver>>,
+}
+
+impl ConvolutionLogic {
+    fn new(handle: ConvolutionHandle, partition_frames: usize) -> Self {
+        Self {
+            handle,
+            partition_frames,
+            prepared: None,
+            convolver: Crossfade::new(None),
+        }
+    }
+
+    /// (Re)prépare le convolueur si la réponse ou la fréquence a changé
+    fn prepare(&mut self, sample_rate: u32) {
+        let generation = self.handle.generation.load(Ordering::Relaxed);
+        let previous = self.prepared;
+        if previous == Some((generation, sample_rate)) {
+            return;
+        }
+        self.prepared = Some((generation, sample_rate));
+
+        let convolver = match self.handle.impulse_response() {
+            Some(ir) if ir.sample_rate == sample_rate => {
+                tracing::info!(
+                    "ConvolutionNode: {} frames impulse response at {} Hz",
+                    ir.len(),
+                    sample_rate
+                );
+                Some(PartitionedConvolver::new(&ir, self.partition_frames))
+            }
+            Some(ir) => {
+                tracing::warn!(
+                    "ConvolutionNode: impulse response is {} Hz but stream is {} Hz, bypassing",
+                    ir.sample_rate,
+                    sample_rate
+                );
+                None
+            }
+            None => None,
+        };
+
+        let latency = convolver.as_ref().map_or(0, |c| c.latency_frames() as u32);
+        match previous {
+            // Nouvelle réponse sur le même flux : fondu après amorçage
+            Some((_, rate)) if rate == sample_rate => self.convolver.swap_with_warmup(
+                convolver,
+                latency as usize,
+                crossfade_frames(DEFAULT_CROSSFADE_MS, sample_rate),
+            ),
+            // Premier chunk ou changement de fréquence : pas de continuité à préserver
+            _ => self.convolver.reset(convolver),
+        }
+        self.handle.latency_frames.store(latency, Ordering::Relaxed);
+        self.handle.sample_rate.store(sample_rate, Ordering::Relaxed);
+    }
+
+    /// Convolue un chunk (None si aucune réponse active)
+    fn process_chunk(&mut self, chunk: &AudioChunk) -> Option<AudioChunk> {
+        self.prepare(chunk.sample_rate());
+        if self.convolver.is_bypassed() {
+            return None;
+        }
+
+        let data = match chunk.to_f32().apply_gain() {
+            AudioChunk::F32(data) => data,
+            _ => unreachable!("to_f32 always returns an F32 chunk"),
+        };
+        let frames = data
+            .get_frames()
+            .iter()
+            .map(|frame| self.convolver.process_frame(*frame))
+            .collect();
+
+        Some(AudioChunk::F32(AudioChunkData::new(
+            frames,
+            data.get_sample_rate(),
+            0.0,
+        )))
+    }
+}
+
+#[async_trait::async_trait]
+impl NodeLogic for ConvolutionLogic {
+    async fn process(
+        &mut self,
+        input: Option<mpsc::Receiver<Arc<AudioSegment>>>,
+        output: Vec<mpsc::Sender<Arc<AudioSegment>>>,
+        stop_token: CancellationToken,
+    ) -> Result<(), AudioError> {
+        let mut input = input.ok_or_else(|| {
+            AudioError::ProcessingError("ConvolutionNode requires an input".into())
+        })?;
+
+        loop {
+            let seg = tokio::select! {
+                _ = stop_token.cancelled() => break,
+                segment = input.recv() => match segment {
+                    None => break,
+                    Some(seg) => seg,
+                },
+            };
+
+            let seg = match &seg.segment {
+                _AudioSegment::Chunk(chunk) => match self.process_chunk(chunk) {
+                    Some(processed) => Arc::new(AudioSegment {
+                        order: seg.order,
+                        timestamp_sec: seg.timestamp_sec,
+                        segment: _AudioSegment::Chunk(Arc::new(processed)),
+                    }),
+                    None => seg,
+                },
+                _AudioSegment::Sync(_) => seg,
+            };
+
+            send_to_children("ConvolutionNode", &output, seg).await?;
+        }
+
+        Ok(())
+    }
+}
+
+// ─── Nœud public ─────────────────────────────────────────────────────────────
+
+pub struct ConvolutionNode {
+    inner: Node<ConvolutionLogic>,
+}
+
+impl ConvolutionNode {
+    /// Crée un nœud sans réponse impulsionnelle (partitions par défaut)
+    pub fn new() -> (Self, ConvolutionHandle) {
+        Self::with_partition_frames(DEFAULT_PARTITION_FRAMES)
+    }
+
+    /// Crée un nœud avec une taille de partition donnée (= latence en frames)
+    pub fn with_partition_frames(partition_frames: usize) -> (Self, ConvolutionHandle) {
+        let handle = ConvolutionHandle::default();
+        let logic = ConvolutionLogic::new(handle.clone(), partition_frames);
+        let node = Self {
+            inner: Node::new_with_input(logic, 16),
+        };
+        (node, handle)
+    }
+
+    /// Crée un nœud piloté par un handle existant (reconstruction de pipeline)
+    ///
+    /// La réponse impulsionnelle du handle est re-préparée au premier chunk.
+    pub fn with_handle(handle: ConvolutionHandle) -> Self {
+        let logic = ConvolutionLogic::new(handle, DEFAULT_PARTITION_FRAMES);
+        Self {
+            inner: Node::new_with_input(logic, 16),
+        }
+    }
+}
+
+#[async_trait::async_trait]
+impl AudioPipelineNode for ConvolutionNode {
+    fn get_tx(&self) -> Option<mpsc::Sender<Arc<AudioSegment>>> {
+        self.inner.get_tx()
+    }
+
+    fn register(&mut self, child: Box<dyn AudioPipelineNode>) {
+        self.inner.register(child);
+    }
+
+    async fn run(self: Box<Self>, stop_token: CancellationToken) -> Result<(), AudioError> {
+        Box::new(self.inner).run(stop_token).await
+    }
+
+    fn start(self: Box<Self>) -> crate::pipeline::PipelineHandle {
+        Box::new(self.inner).start()
+    }
+}
+
+impl crate::TypedAudioNode for ConvolutionNode {
+    fn input_type(&self) -> Option<TypeRequirement> {
+        None // Accepte tout
+    }
+
+    fn output_type(&self) -> Option<TypeRequirement> {
+        None // Type d'entrée si inactif, F32 sinon
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_latency_and_sample_rate_mismatch() {
+        let handle = ConvolutionHandle::default();
+        let mut logic = ConvolutionLogic::new(handle.clone(), 256);
+        let chunk = AudioChunk::F32(AudioChunkData::new(vec![[1.0f32, 1.0f32]; 512], 48_000, 0.0));
+
+        assert!(logic.process_chunk(&chunk).is_none());
+        assert_eq!(handle.latency_frames(), 0);
+
+        handle.set_impulse_response(Some(ImpulseResponse {
+            sample_rate: 48_000,
+            channels: [vec![1.0], vec![1.0]],
+        }));
+        assert!(logic.process_chunk(&chunk).is_some());
+        assert_eq!(handle.latency_frames(), 256);
+        assert!((handle.latency_sec() - 256.0 / 48_000.0).abs() < 1e-9);
+
+        handle.set_impulse_response(Some(ImpulseResponse {
+            sample_rate: 44_100,
+            channels: [vec![1.0], vec![1.0]],
+        }));
+        // Le convolueur précédent est fondu avant le passage en bypass
+        assert!(logic.process_chunk(&chunk).is_some());
+        assert_eq!(handle.latency_frames(), 0);
+        for _ in 0..4 {
+            logic.process_chunk(&chunk);
+        }
+        assert!(logic.process_chunk(&chunk).is_none());
+    }
+}
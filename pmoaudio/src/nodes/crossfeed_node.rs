@@ -0,0 +1,428 @@
+//! CrossfeedNode — crossfeed de Bauer pour l'écoute au casque.
+//!
+//! Au casque, chaque oreille n'entend qu'un canal : les enregistrements très
+//! séparés (stéréo des années 60) fatiguent vite. Le crossfeed mélange à
+//! chaque canal une version filtrée passe-bas du canal opposé, comme le
+//! ferait l'acoustique d'une pièce avec des enceintes.
+//!
+//! Implémentation du filtre de Bauer tel que décrit par bs2b : passe-bas du
+//! premier ordre sur le canal croisé, shelving aigu sur le canal direct, et
+//! normalisation pour conserver un gain unitaire sur un signal mono.
+//!
+//! Désactivé, le nœud laisse passer les chunks sans modification. Activation,
+//! désactivation et changement de réglages sont fondus sur
+//! `DEFAULT_CROSSFADE_MS`.
+
+use crate::{
+    dsp::crossfade::{crossfade_frames, Crossfade, StereoProcessor, DEFAULT_CROSSFADE_MS},
+    nodes::AudioError,
+    pipeline::{send_to_children, AudioPipelineNode, Node, NodeLogic},
+    type_constraints::TypeRequirement,
+    AudioChunk, AudioChunkData, AudioSegment, _AudioSegment,
+};
+use std::f64::consts::PI;
+use std::sync::{
+    atomic::{AtomicBool, AtomicU32, Ordering},
+    Arc,
+};
+use tokio::sync::mpsc;
+use tokio_util::sync::CancellationToken;
+
+/// Fréquence de coupure minimale (Hz)
+pub const MIN_CROSSFEED_CUTOFF_HZ: u32 = 300;
+/// Fréquence de coupure maximale (Hz)
+pub const MAX_CROSSFEED_CUTOFF_HZ: u32 = 2000;
+/// Niveau de crossfeed minimal (dB)
+pub const MIN_CROSSFEED_FEED_DB: f32 = 1.0;
+/// Niveau de crossfeed maximal (dB)
+pub const MAX_CROSSFEED_FEED_DB: f32 = 15.0;
+
+// ─── Paramètres ──────────────────────────────────────────────────────────────
+
+/// Paramètres du filtre : fréquence de coupure et niveau de crossfeed
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub struct CrossfeedParams {
+    /// Fréquence de coupure du passe-bas croisé (Hz)
+    pub cutoff_hz: u32,
+    /// Niveau du canal croisé (dB, 4.5 = réglage bs2b par défaut)
+    pub feed_db: f32,
+}
+
+impl CrossfeedParams {
+    /// Réglage par défaut de bs2b (700 Hz, 4.5 dB)
+    pub const DEFAULT: Self = Self {
+        cutoff_hz: 700,
+        feed_db: 4.5,
+    };
+    /// Réglage de Chu Moy (700 Hz, 6 dB)
+    pub const CMOY: Self = Self {
+        cutoff_hz: 700,
+        feed_db: 6.0,
+    };
+    /// Réglage de Jan Meier (650 Hz, 9.5 dB)
+    pub const JMEIER: Self = Self {
+        cutoff_hz: 650,
+        feed_db: 9.5,
+    };
+
+    /// Paramètres bornés aux plages valides
+    pub fn clamped(self) -> Self {
+        let feed_db = if self.feed_db.is_finite() {
+            self.feed_db
+        } else {
+            Self::DEFAULT.feed_db
+        };
+        Self {
+            cutoff_hz: self
+                .cutoff_hz
+                .clamp(MIN_CROSSFEED_CUTOFF_HZ, MAX_CROSSFEED_CUTOFF_HZ),
+            feed_db: feed_db.clamp(MIN_CROSSFEED_FEED_DB, MAX_CROSSFEED_FEED_DB),
+        }
+    }
+}
+
+impl Default for CrossfeedParams {
+    fn default() -> Self {
+        Self::DEFAULT
+    }
+}
+
+// ─── Handle public ────────────────────────────────────────────────────────────
+
+/// Handle partageable pour activer et régler le crossfeed.
+#[derive(Clone)]
+pub struct CrossfeedHandle {
+    enabled: Arc<AtomicBool>,
+    cutoff_hz: Arc<AtomicU32>,
+    /// Niveau en dB (f32 encodé dans un AtomicU32)
+    feed_db: Arc<AtomicU32>,
+}
+
+impl CrossfeedHandle {
+    fn new(params: CrossfeedParams) -> Self {
+        let params = params.clamped();
+        Self {
+            enabled: Arc::new(AtomicBool::new(false)),
+            cutoff_hz: Arc::new(AtomicU32::new(params.cutoff_hz)),
+            feed_db: Arc::new(AtomicU32::new(params.feed_db.to_bits())),
+        }
+    }
+
+    /// Crossfeed actif ?
+    pub fn enabled(&self) -> bool {
+        self.enabled.load(Ordering::Relaxed)
+    }
+
+    /// Active ou désactive le crossfeed
+    pub fn set_enabled(&self, enabled: bool) {
+        self.enabled.store(enabled, Ordering::Relaxed);
+    }
+
+    /// Paramètres courants du filtre
+    pub fn params(&self) -> CrossfeedParams {
+        CrossfeedParams {
+            cutoff_hz: self.cutoff_hz.load(Ordering::Relaxed),
+            feed_db: f32::from_bits(self.feed_db.load(Ordering::Relaxed)),
+        }
+    }
+
+    /// Définit les paramètres du filtre (bornés aux plages valides)
+    pub fn set_params(&self, params: CrossfeedParams) {
+        let params = params.clamped();
+        self.cutoff_hz.store(params.cutoff_hz, Ordering::Relaxed);
+        self.feed_db.store(params.feed_db.to_bits(), Ordering::Relaxed);
+    }
+}
+
+// ─── Filtre de Bauer ─────────────────────────────────────────────────────────
+
+/// Coefficients du filtre pour une fréquence d'échantillonnage donnée
+#[derive(Debug, Clone, Copy)]
+struct Coefficients {
+    a0_lo: f64,
+    b1_lo: f64,
+    a0_hi: f64,
+    a1_hi: f64,
+    b1_hi: f64,
+    gain: f64,
+}
+
+impl Coefficients {
+    fn new(params: CrossfeedParams, sample_rate: u32) -> Self {
+        let feed_db = params.feed_db as f64;
+        let fc_lo = params.cutoff_hz as f64;
+        let sr = sample_rate as f64;
+
+        let gb_lo = feed_db * -5.0 / 6.0 - 3.0;
+        let gb_hi = feed_db / 6.0 - 3.0;
+        let g_lo = 10f64.powf(gb_lo / 20.0);
+        let g_hi = 1.0 - 10f64.powf(gb_hi / 20.0);
+        let fc_hi = fc_lo * 2f64.powf((gb_lo - 20.0 * g_hi.log10()) / 12.0);
+
+        let x = (-2.0 * PI * fc_lo / sr).exp();
+        let (a0_lo, b1_lo) = (g_lo * (1.0 - x), x);
+
+        let x = (-2.0 * PI * fc_hi / sr).exp();
+        let (a0_hi, a1_hi, b1_hi) = (1.0 - g_hi * (1.0 - x), -x, x);
+
+        Self {
+            a0_lo,
+            b1_lo,
+            a0_hi,
+            a1_hi,
+            b1_hi,
+            gain: 1.0 / (1.0 - g_hi + g_lo),
+        }
+    }
+}
+
+/// État du filtre (un jeu de valeurs par canal)
+#[derive(Debug, Default, Clone, Copy)]
+struct FilterState {
+    /// Entrée précédente
+    last_in: [f64; 2],
+    /// Sortie du passe-bas
+    lo: [f64; 2],
+    /// Sortie du shelving aigu
+    hi: [f64; 2],
+}
+
+impl FilterState {
+    fn process(&mut self, c: &Coefficients, frame: [f32; 2]) -> [f32; 2] {
+        let input = [frame[0] as f64, frame[1] as f64];
+        for ch in 0..2 {
+            self.lo[ch] = c.a0_lo * input[ch] + c.b1_lo * self.lo[ch];
+            self.hi[ch] = c.a0_hi * input[ch] + c.a1_hi * self.last_in[ch] + c.b1_hi * self.hi[ch];
+        }
+        self.last_in = input;
+        [
+            ((self.hi[0] + self.lo[1]) * c.gain) as f32,
+            ((self.hi[1] + self.lo[0]) * c.gain) as f32,
+        ]
+    }
+}
+
+/// Instance du filtre : coefficients et mémoire
+struct CrossfeedFilter {
+    coefficients: Coefficients,
+    state: FilterState,
+}
+
+impl CrossfeedFilter {
+    fn new(params: CrossfeedParams, sample_rate: u32) -> Self {
+        Self {
+            coefficients: Coefficients::new(params, sample_rate),
+            state: FilterState::default(),
+        }
+    }
+}
+
+impl StereoProcessor for CrossfeedFilter {
+    fn process_frame(&mut self, frame: [f32; 2]) -> [f32; 2] {
+        self.state.process(&self.coefficients, frame)
+    }
+}
+
+// ─── Logique du nœud ─────────────────────────────────────────────────────────
+
+struct CrossfeedLogic {
+    handle: CrossfeedHandle,
+    /// Réglages (None = désactivé) et fréquence du filtre courant
+    configured: Option<(Option<CrossfeedParams>, u32)>,
+    filter: Crossfade<Option<CrossfeedFilter>>,
+}
+
+impl CrossfeedLogic {
+    fn new(handle: CrossfeedHandle) -> Self {
+        Self {
+            handle,
+            configured: None,
+            filter: Crossfade::new(None),
+        }
+    }
+
+    /// Met à jour le filtre si les réglages ou la fréquence ont changé
+    fn configure(&mut self, sample_rate: u32) {
+        let wanted = self.handle.enabled().then(|| self.handle.params());
+        match self.configured {
+            Some((params, rate)) if rate == sample_rate => {
+                if params != wanted {
+                    self.filter.swap(
+                        wanted.map(|p| CrossfeedFilter::new(p, sample_rate)),
+                        crossfade_frames(DEFAULT_CROSSFADE_MS, sample_rate),
+                    );
+                }
+            }
+            // Premier chunk ou changement de fréquence : pas de fondu
+            _ => self
+                .filter
+                .reset(wanted.map(|p| CrossfeedFilter::new(p, sample_rate))),
+        }
+        self.configured = Some((wanted, sample_rate));
+    }
+
+    /// Filtre un chunk (None si le crossfeed est désactivé)
+    fn process_chunk(&mut self, chunk: &AudioChunk) -> Option<AudioChunk> {
+        self.configure(chunk.sample_rate());
+        if self.filter.is_bypassed() {
+            return None;
+        }
+
+        let data = match chunk.to_f32().apply_gain() {
+            AudioChunk::F32(data) => data,
+            _ => unreachable!("to_f32 always returns an F32 chunk"),
+        };
+        let frames = data
+            .get_frames()
+            .iter()
+            .map(|frame| self.filter.process_frame(*frame))
+            .collect();
+
+        Some(AudioChunk::F32(AudioChunkData::new(
+            frames,
+            data.get_sample_rate(),
+            0.0,
+        )))
+    }
+}
+
+#[async_trait::async_trait]
+impl NodeLogic for CrossfeedLogic {
+    async fn process(
+        &mut self,
+        input: Option<mpsc::Receiver<Arc<AudioSegment>>>,
+        output: Vec<mpsc::Sender<Arc<AudioSegment>>>,
+        stop_token: CancellationToken,
+    ) -> Result<(), AudioError> {
+        let mut input = input.ok_or_else(|| {
+            AudioError::ProcessingError("CrossfeedNode requires an input".into())
+        })?;
+
+        loop {
+            let seg = tokio::select! {
+                _ = stop_token.cancelled() => break,
+                segment = input.recv() => match segment {
+                    None => break,
+                    Some(seg) => seg,
+                },
+            };
+
+            let seg = match &seg.segment {
+                _AudioSegment::Chunk(chunk) => match self.process_chunk(chunk) {
+                    Some(processed) => Arc::new(AudioSegment {
+                        order: seg.order,
+                        timestamp_sec: seg.timestamp_sec,
+                        segment: _AudioSegment::Chunk(Arc::new(processed)),
+                    }),
+                    None => seg,
+                },
+                _AudioSegment::Sync(_) => seg,
+            };
+
+            send_to_children("CrossfeedNode", &output, seg).await?;
+        }
+
+        Ok(())
+    }
+}
+
+// ─── Nœud public ─────────────────────────────────────────────────────────────
+
+pub struct CrossfeedNode {
+    inner: Node<CrossfeedLogic>,
+}
+
+impl CrossfeedNode {
+    /// Crée un nœud désactivé avec les paramètres donnés
+    pub fn new(params: CrossfeedParams) -> (Self, CrossfeedHandle) {
+        let handle = CrossfeedHandle::new(params);
+        (Self::with_handle(handle.clone()), handle)
+    }
+
+    /// Crée un nœud piloté par un handle existant (reconstruction de pipeline)
+    pub fn with_handle(handle: CrossfeedHandle) -> Self {
+        Self {
+            inner: Node::new_with_input(CrossfeedLogic::new(handle), 16),
+        }
+    }
+}
+
+#[async_trait::async_trait]
+impl AudioPipelineNode for CrossfeedNode {
+    fn get_tx(&self) -> Option<mpsc::Sender<Arc<AudioSegment>>> {
+        self.inner.get_tx()
+    }
+
+    fn register(&mut self, child: Box<dyn AudioPipelineNode>) {
+        self.inner.register(child);
+    }
+
+    async fn run(self: Box<Self>, stop_token: CancellationToken) -> Result<(), AudioError> {
+        Box::new(self.inner).run(stop_token).await
+    }
+
+    fn start(self: Box<Self>) -> crate::pipeline::PipelineHandle {
+        Box::new(self.inner).start()
+    }
+}
+
+impl crate::TypedAudioNode for CrossfeedNode {
+    fn input_type(&self) -> Option<TypeRequirement> {
+        None // Accepte tout
+    }
+
+    fn output_type(&self) -> Option<TypeRequirement> {
+        None // Type d'entrée si désactivé, F32 sinon
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_mono_signal_keeps_unity_gain() {
+        let handle = CrossfeedHandle::new(CrossfeedParams::DEFAULT);
+        let mut logic = CrossfeedLogic::new(handle.clone());
+        let chunk = AudioChunk::F32(AudioChunkData::new(vec![[0.5f32, 0.5f32]; 48_000], 48_000, 0.0));
+
+        assert!(logic.process_chunk(&chunk).is_none());
+
+        handle.set_enabled(true);
+        match logic.process_chunk(&chunk).unwrap() {
+            AudioChunk::F32(d) => {
+                let [l, r] = d.get_frames()[47_999];
+                assert!((l - 0.5).abs() < 1e-3);
+                assert!((r - 0.5).abs() < 1e-3);
+            }
+            _ => panic!("expected F32"),
+        }
+    }
+
+    #[test]
+    fn test_hard_left_bleeds_into_right() {
+        let handle = CrossfeedHandle::new(CrossfeedParams::JMEIER);
+        handle.set_enabled(true);
+        let mut logic = CrossfeedLogic::new(handle);
+        let chunk = AudioChunk::F32(AudioChunkData::new(vec![[1.0f32, 0.0f32]; 4800], 48_000, 0.0));
+
+        match logic.process_chunk(&chunk).unwrap() {
+            AudioChunk::F32(d) => {
+                let [l, r] = d.get_frames()[4799];
+                assert!(r > 0.0 && r < l);
+            }
+            _ => panic!("expected F32"),
+        }
+    }
+
+    #[test]
+    fn test_params_are_clamped() {
+        let p = CrossfeedParams {
+            cutoff_hz: 10,
+            feed_db: 40.0,
+        }
+        .clamped();
+        assert_eq!(p.cutoff_hz, MIN_CROSSFEED_CUTOFF_HZ);
+        assert_eq!(p.feed_db, MAX_CROSSFEED_FEED_DB);
+    }
+}
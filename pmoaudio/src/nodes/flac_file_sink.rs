@@ -572,7 +572,7 @@ fn get_chunk_bit_depth(chunk: &AudioChunk) -> u8 {
 }
 
 /// Convertit un chunk audio en bytes PCM avec la profondeur de bit spécifiée
-fn chunk_to_pcm_bytes(chunk: &AudioChunk, bits_per_sample: u8) -> Result<Vec<u8>, AudioError> {
+pub(crate) fn chunk_to_pcm_bytes(chunk: &AudioChunk, bits_per_sample: u8) -> Result<Vec<u8>, AudioError> {
     // Vérifier que le chunk est de type entier
     match chunk {
         AudioChunk::F32(_) | AudioChunk::F64(_) => {
@@ -672,14 +672,14 @@ fn chunk_to_pcm_bytes(chunk: &AudioChunk, bits_per_sample: u8) -> Result<Vec<u8>
     Ok(bytes)
 }
 
-struct ByteStreamReader {
+pub(crate) struct ByteStreamReader {
     rx: mpsc::Receiver<Vec<u8>>,
     buffer: VecDeque<u8>,
     finished: bool,
 }
 
 impl ByteStreamReader {
-    fn new(rx: mpsc::Receiver<Vec<u8>>) -> Self {
+    pub(crate) fn new(rx: mpsc::Receiver<Vec<u8>>) -> Self {
         Self {
             rx,
             buffer: VecDeque::new(),
@@ -29,6 +29,9 @@ use tokio_util::{io::StreamReader, sync::CancellationToken};
 /// - `icy-url`: URL du stream source
 /// - `content-type`: type MIME du contenu (ex: audio/flac, audio/mpeg)
 ///
+/// La durée est renseignée quand le décodeur connaît le nombre total
+/// d'échantillons (STREAMINFO FLAC, chunk COMM AIFF).
+///
 /// Si aucun header `icy-name` n'est présent, le nom du fichier est extrait de l'URL
 /// et utilisé comme titre.
 ///
@@ -142,7 +145,7 @@ impl NodeLogic for HttpSourceLogic {
         }
 
         // Extraire les métadonnées depuis les headers HTTP
-        let metadata = extract_metadata_from_headers(&response, &self.url).await;
+        let mut metadata = extract_metadata_from_headers(&response, &self.url).await;
 
         // Convertir le stream de bytes en AsyncRead
         let bytes_stream = response.bytes_stream();
@@ -159,6 +162,14 @@ impl NodeLogic for HttpSourceLogic {
 
         validate_stream(&stream_info)?;
 
+        // Durée connue du flux (source finie) : utile aux sinks qui doivent
+        // annoncer une taille exacte (en-tête WAV)
+        if let Some(total) = stream_info.total_samples.filter(|&n| n > 0) {
+            let duration =
+                std::time::Duration::from_secs_f64(total as f64 / stream_info.sample_rate as f64);
+            let _ = metadata.set_duration(Some(duration)).await;
+        }
+
         // Calculer la taille des chunks si non spécifiée (0 = auto)
         let chunk_frames_final = if self.chunk_frames == 0 {
             let frames =
@@ -0,0 +1,905 @@
+//! MixerNode — somme de plusieurs entrées avec gain et ducking par priorité.
+//!
+//! Le pipeline étant un arbre, le mixeur a une entrée *principale* (son
+//! propre canal, entrée 0) et des entrées *auxiliaires* créées par
+//! [`MixerHandle::add_input`] : chaque [`MixerInput`] est un nœud terminal à
+//! enregistrer comme enfant d'une autre branche (seconde source, générateur
+//! de carillon…). À la reconstruction du pipeline,
+//! [`MixerHandle::attach_input`] recrée le nœud d'une entrée existante.
+//!
+//! L'entrée principale cadence la sortie : pour chacun de ses chunks, le
+//! mixeur prélève autant de frames dans le tampon de chaque entrée
+//! auxiliaire (silence si elle n'en a pas assez) et additionne le tout.
+//! Une entrée auxiliaire dont le tampon est plein attend (backpressure).
+//! Les entrées auxiliaires doivent être à la fréquence de l'entrée
+//! principale : placer un `ResamplingNode` devant si besoin, les chunks à une
+//! autre fréquence sont ignorés.
+//!
+//! Chaque entrée a un gain (changé avec un fondu linéaire ou à puissance
+//! constante, voir [`MixerHandle::crossfade`]) et une priorité : tant qu'une
+//! entrée reçoit du signal, les entrées de priorité inférieure sont
+//! abaissées du niveau de ducking.
+//!
+//! Sans entrée auxiliaire active ni fondu en cours, les chunks de l'entrée
+//! principale passent sans modification.
+
+use crate::{
+    nodes::AudioError,
+    pipeline::{send_to_children, AudioPipelineNode, Node, NodeLogic},
+    type_constraints::TypeRequirement,
+    AudioChunk, AudioChunkData, AudioSegment, _AudioSegment,
+};
+use std::collections::VecDeque;
+use std::f32::consts::FRAC_PI_2;
+use std::sync::{
+    atomic::{AtomicBool, AtomicU32, AtomicU8, Ordering},
+    Arc, Mutex,
+};
+use std::time::Duration;
+use tokio::sync::{mpsc, Notify};
+use tokio_util::sync::CancellationToken;
+
+/// Nombre maximal d'entrées, principale comprise
+pub const MAX_MIXER_INPUTS: usize = 8;
+/// Atténuation par défaut des entrées moins prioritaires (dB)
+pub const DEFAULT_MIXER_DUCK_DB: f32 = -18.0;
+/// Durée par défaut des rampes de ducking (ms)
+pub const DEFAULT_MIXER_RAMP_MS: u32 = 200;
+/// Profondeur du tampon d'une entrée auxiliaire (ms)
+const INPUT_BUFFER_MS: u64 = 500;
+
+// ─── Fondus ──────────────────────────────────────────────────────────────────
+
+/// Forme d'un changement de gain
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum FadeCurve {
+    /// Gain linéaire (signaux corrélés, changement de niveau)
+    #[default]
+    Linear,
+    /// Puissance constante (fondu enchaîné entre deux sources différentes)
+    EqualPower,
+}
+
+impl FadeCurve {
+    /// Progression du fondu pour `t` dans [0, 1]
+    fn shape(self, t: f32, rising: bool) -> f32 {
+        match (self, rising) {
+            (FadeCurve::Linear, _) => t,
+            (FadeCurve::EqualPower, true) => (t * FRAC_PI_2).sin(),
+            (FadeCurve::EqualPower, false) => 1.0 - (t * FRAC_PI_2).cos(),
+        }
+    }
+}
+
+/// Gain demandé pour une entrée
+#[derive(Debug, Clone, Copy)]
+struct GainRequest {
+    gain: f32,
+    fade: Duration,
+    curve: FadeCurve,
+    /// Incrémenté à chaque demande, pour détecter les changements
+    generation: u64,
+}
+
+// ─── Entrées ─────────────────────────────────────────────────────────────────
+
+/// État partagé d'une entrée entre le handle, le mixeur et le `MixerInput`
+struct MixerChannel {
+    frames: Mutex<VecDeque<[f32; 2]>>,
+    /// Fréquence des derniers frames reçus (0 : inconnue)
+    sample_rate: AtomicU32,
+    /// Place libérée dans le tampon
+    space: Notify,
+    request: Mutex<GainRequest>,
+    priority: AtomicU8,
+    /// Le `MixerInput` a terminé (les frames en tampon restent jouées)
+    finished: AtomicBool,
+}
+
+impl MixerChannel {
+    fn new(priority: u8) -> Self {
+        Self {
+            frames: Mutex::new(VecDeque::new()),
+            sample_rate: AtomicU32::new(0),
+            space: Notify::new(),
+            request: Mutex::new(GainRequest {
+                gain: 1.0,
+                fade: Duration::ZERO,
+                curve: FadeCurve::Linear,
+                generation: 0,
+            }),
+            priority: AtomicU8::new(priority),
+            finished: AtomicBool::new(false),
+        }
+    }
+
+    fn buffered(&self) -> usize {
+        self.frames.lock().unwrap().len()
+    }
+}
+
+// ─── Handle public ────────────────────────────────────────────────────────────
+
+/// Handle partageable pour piloter le mixeur.
+#[derive(Clone)]
+pub struct MixerHandle {
+    channels: Arc<Mutex<Vec<Arc<MixerChannel>>>>,
+    /// Gain appliqué aux entrées moins prioritaires (f32 encodé dans un AtomicU32)
+    duck_gain: Arc<AtomicU32>,
+    /// Durée des rampes de ducking en millisecondes
+    ramp_ms: Arc<AtomicU32>,
+    /// Fréquence de l'entrée principale (0 : inconnue)
+    sample_rate: Arc<AtomicU32>,
+}
+
+impl MixerHandle {
+    fn new(duck_db: f32) -> Self {
+        let handle = Self {
+            channels: Arc::new(Mutex::new(vec![Arc::new(MixerChannel::new(0))])),
+            duck_gain: Arc::new(AtomicU32::new(1.0f32.to_bits())),
+            ramp_ms: Arc::new(AtomicU32::new(DEFAULT_MIXER_RAMP_MS)),
+            sample_rate: Arc::new(AtomicU32::new(0)),
+        };
+        handle.set_duck_db(duck_db);
+        handle
+    }
+
+    fn channel(&self, input: usize) -> Result<Arc<MixerChannel>, AudioError> {
+        self.channels
+            .lock()
+            .unwrap()
+            .get(input)
+            .cloned()
+            .ok_or_else(|| AudioError::ProcessingError(format!("Unknown mixer input {}", input)))
+    }
+
+    /// Crée une entrée auxiliaire de la priorité donnée
+    ///
+    /// Le nœud retourné doit être enregistré comme enfant de la branche
+    /// qui l'alimente.
+    pub fn add_input(&self, priority: u8) -> Result<MixerInput, AudioError> {
+        let mut channels = self.channels.lock().unwrap();
+        if channels.len() >= MAX_MIXER_INPUTS {
+            return Err(AudioError::ProcessingError(format!(
+                "Too many mixer inputs (max {})",
+                MAX_MIXER_INPUTS
+            )));
+        }
+        let channel = Arc::new(MixerChannel::new(priority));
+        channels.push(channel.clone());
+        Ok(MixerInput::new(channels.len() - 1, channel))
+    }
+
+    /// Recrée le nœud d'une entrée auxiliaire existante (reconstruction de
+    /// pipeline) ; les frames en tampon de l'ancien nœud sont abandonnés.
+    pub fn attach_input(&self, input: usize) -> Result<MixerInput, AudioError> {
+        if input == 0 {
+            return Err(AudioError::ProcessingError(
+                "Mixer input 0 is the main input".into(),
+            ));
+        }
+        let channel = self.channel(input)?;
+        channel.frames.lock().unwrap().clear();
+        channel.finished.store(false, Ordering::Relaxed);
+        Ok(MixerInput::new(input, channel))
+    }
+
+    /// Abandonne les frames en tampon d'une entrée auxiliaire
+    pub fn clear(&self, input: usize) -> Result<(), AudioError> {
+        let channel = self.channel(input)?;
+        channel.frames.lock().unwrap().clear();
+        channel.space.notify_one();
+        Ok(())
+    }
+
+    /// Nombre de frames en tampon d'une entrée auxiliaire
+    pub fn buffered(&self, input: usize) -> usize {
+        match self.channel(input) {
+            Ok(channel) if input > 0 => channel.buffered(),
+            _ => 0,
+        }
+    }
+
+    /// Fréquence de l'entrée principale, connue dès son premier chunk
+    ///
+    /// Les entrées auxiliaires doivent produire à cette fréquence.
+    pub fn sample_rate(&self) -> Option<u32> {
+        match self.sample_rate.load(Ordering::Relaxed) {
+            0 => None,
+            rate => Some(rate),
+        }
+    }
+
+    /// Nombre d'entrées, principale comprise
+    pub fn input_count(&self) -> usize {
+        self.channels.lock().unwrap().len()
+    }
+
+    /// Gain linéaire demandé pour une entrée
+    pub fn gain(&self, input: usize) -> Option<f32> {
+        let channel = self.channel(input).ok()?;
+        let gain = channel.request.lock().unwrap().gain;
+        Some(gain)
+    }
+
+    /// Change le gain linéaire (>= 0) d'une entrée avec un fondu
+    pub fn set_gain(
+        &self,
+        input: usize,
+        gain: f32,
+        fade: Duration,
+        curve: FadeCurve,
+    ) -> Result<(), AudioError> {
+        let channel = self.channel(input)?;
+        let mut request = channel.request.lock().unwrap();
+        request.gain = if gain.is_finite() { gain.max(0.0) } else { 1.0 };
+        request.fade = fade;
+        request.curve = curve;
+        request.generation += 1;
+        Ok(())
+    }
+
+    /// Fondu enchaîné à puissance constante de `from` vers `to`
+    pub fn crossfade(&self, from: usize, to: usize, duration: Duration) -> Result<(), AudioError> {
+        self.channel(from)?;
+        self.channel(to)?;
+        self.set_gain(from, 0.0, duration, FadeCurve::EqualPower)?;
+        self.set_gain(to, 1.0, duration, FadeCurve::EqualPower)
+    }
+
+    /// Priorité d'une entrée
+    pub fn priority(&self, input: usize) -> Option<u8> {
+        let channel = self.channel(input).ok()?;
+        Some(channel.priority.load(Ordering::Relaxed))
+    }
+
+    /// Change la priorité d'une entrée
+    pub fn set_priority(&self, input: usize, priority: u8) -> Result<(), AudioError> {
+        self.channel(input)?
+            .priority
+            .store(priority, Ordering::Relaxed);
+        Ok(())
+    }
+
+    /// L'entrée a-t-elle du signal en attente ?
+    ///
+    /// L'entrée principale est toujours considérée active.
+    pub fn is_active(&self, input: usize) -> bool {
+        match self.channel(input) {
+            Ok(_) if input == 0 => true,
+            Ok(channel) => channel.buffered() > 0,
+            Err(_) => false,
+        }
+    }
+
+    /// L'entrée auxiliaire est-elle terminée et son tampon vidé ?
+    pub fn is_finished(&self, input: usize) -> bool {
+        match self.channel(input) {
+            Ok(channel) if input > 0 => {
+                channel.finished.load(Ordering::Relaxed) && channel.buffered() == 0
+            }
+            _ => false,
+        }
+    }
+
+    /// Atténuation des entrées moins prioritaires (dB)
+    pub fn duck_db(&self) -> f32 {
+        let gain = self.duck_gain();
+        if gain <= 0.0 {
+            f32::NEG_INFINITY
+        } else {
+            20.0 * gain.log10()
+        }
+    }
+
+    /// Définit l'atténuation (<= 0 dB)
+    pub fn set_duck_db(&self, db: f32) {
+        let gain = if db.is_nan() {
+            10f32.powf(DEFAULT_MIXER_DUCK_DB / 20.0)
+        } else {
+            10f32.powf(db.min(0.0) / 20.0)
+        };
+        self.duck_gain.store(gain.to_bits(), Ordering::Relaxed);
+    }
+
+    /// Durée des rampes de ducking en millisecondes
+    pub fn ramp_ms(&self) -> u32 {
+        self.ramp_ms.load(Ordering::Relaxed)
+    }
+
+    pub fn set_ramp_ms(&self, ramp_ms: u32) {
+        self.ramp_ms.store(ramp_ms, Ordering::Relaxed);
+    }
+
+    fn duck_gain(&self) -> f32 {
+        f32::from_bits(self.duck_gain.load(Ordering::Relaxed))
+    }
+}
+
+// ─── Logique du mixeur ───────────────────────────────────────────────────────
+
+/// Fondu de gain en cours
+struct Ramp {
+    from: f32,
+    to: f32,
+    position: usize,
+    length: usize,
+    curve: FadeCurve,
+}
+
+/// État d'une entrée propre à la tâche du mixeur
+struct ChannelState {
+    gain: f32,
+    ramp: Option<Ramp>,
+    generation: u64,
+    /// Facteur de ducking appliqué au dernier frame
+    duck: f32,
+    /// Fréquence incompatible déjà signalée
+    rate_warned: bool,
+}
+
+impl ChannelState {
+    fn new() -> Self {
+        Self {
+            gain: 1.0,
+            ramp: None,
+            generation: 0,
+            duck: 1.0,
+            rate_warned: false,
+        }
+    }
+
+    /// Prend en compte une nouvelle demande de gain
+    fn update(&mut self, request: GainRequest, sample_rate: u32) {
+        if request.generation == self.generation {
+            return;
+        }
+        self.generation = request.generation;
+        let length = (request.fade.as_secs_f64() * sample_rate as f64) as usize;
+        if length == 0 || request.gain == self.gain {
+            self.gain = request.gain;
+            self.ramp = None;
+        } else {
+            self.ramp = Some(Ramp {
+                from: self.gain,
+                to: request.gain,
+                position: 0,
+                length,
+                curve: request.curve,
+            });
+        }
+    }
+
+    fn is_steady(&self) -> bool {
+        self.ramp.is_none() && self.duck == 1.0
+    }
+
+    /// Gain du frame suivant
+    fn next_gain(&mut self) -> f32 {
+        if let Some(ramp) = &mut self.ramp {
+            let t = ramp.position as f32 / ramp.length as f32;
+            self.gain =
+                ramp.from + (ramp.to - ramp.from) * ramp.curve.shape(t, ramp.to > ramp.from);
+            ramp.position += 1;
+            if ramp.position >= ramp.length {
+                self.gain = ramp.to;
+                self.ramp = None;
+            }
+        }
+        self.gain
+    }
+}
+
+struct MixerLogic {
+    handle: MixerHandle,
+    states: Vec<ChannelState>,
+}
+
+impl MixerLogic {
+    fn new(handle: MixerHandle) -> Self {
+        Self {
+            handle,
+            states: Vec::new(),
+        }
+    }
+
+    /// Mixe un chunk de l'entrée principale avec les entrées auxiliaires
+    fn process_chunk(&mut self, chunk: &AudioChunk) -> Option<AudioChunk> {
+        let sample_rate = chunk.sample_rate();
+        let length = chunk.len();
+        self.handle
+            .sample_rate
+            .store(sample_rate, Ordering::Relaxed);
+        let channels: Vec<Arc<MixerChannel>> = self.handle.channels.lock().unwrap().clone();
+        while self.states.len() < channels.len() {
+            self.states.push(ChannelState::new());
+        }
+
+        // Frames des entrées auxiliaires pour ce chunk (None : pas de signal)
+        let mut inputs: Vec<Option<Vec<[f32; 2]>>> = vec![None];
+        for (channel, state) in channels.iter().zip(&mut self.states).skip(1) {
+            let rate = channel.sample_rate.load(Ordering::Relaxed);
+            let mut frames = channel.frames.lock().unwrap();
+            if rate != 0 && rate != sample_rate && !frames.is_empty() {
+                if !state.rate_warned {
+                    tracing::warn!(
+                        "MixerNode: dropping input at {} Hz (output at {} Hz)",
+                        rate,
+                        sample_rate
+                    );
+                    state.rate_warned = true;
+                }
+                frames.clear();
+            }
+            let count = length.min(frames.len());
+            let taken: Vec<[f32; 2]> = frames.drain(..count).collect();
+            drop(frames);
+            channel.space.notify_one();
+            inputs.push((!taken.is_empty()).then_some(taken));
+        }
+
+        // Priorité la plus haute parmi les entrées qui ont du signal
+        let top = channels
+            .iter()
+            .zip(&inputs)
+            .enumerate()
+            .filter(|(i, (_, frames))| *i == 0 || frames.is_some())
+            .map(|(_, (channel, _))| channel.priority.load(Ordering::Relaxed))
+            .max()
+            .unwrap_or(0);
+
+        let duck_gain = self.handle.duck_gain();
+        let ramp_frames = (sample_rate as u64 * self.handle.ramp_ms() as u64 / 1000).max(1);
+        let duck_step = ((1.0 - duck_gain) / ramp_frames as f32).max(f32::EPSILON);
+        let mut duck_targets = Vec::with_capacity(channels.len());
+        for (channel, state) in channels.iter().zip(&mut self.states) {
+            state.update(*channel.request.lock().unwrap(), sample_rate);
+            let priority = channel.priority.load(Ordering::Relaxed);
+            duck_targets.push(if priority < top { duck_gain } else { 1.0 });
+        }
+
+        let primary = &self.states[0];
+        if inputs.iter().skip(1).all(Option::is_none)
+            && primary.is_steady()
+            && primary.gain == 1.0
+            && duck_targets[0] == 1.0
+        {
+            return None;
+        }
+
+        let float_chunk = match chunk.to_f32().apply_gain() {
+            AudioChunk::F32(data) => data,
+            _ => unreachable!("to_f32 always returns an F32 chunk"),
+        };
+        let mut frames = float_chunk.clone_frames();
+        for (j, frame) in frames.iter_mut().enumerate() {
+            let mut mixed = [0.0f32; 2];
+            for (i, state) in self.states.iter_mut().enumerate().take(channels.len()) {
+                let target = duck_targets[i];
+                if state.duck > target {
+                    state.duck = (state.duck - duck_step).max(target);
+                } else if state.duck < target {
+                    state.duck = (state.duck + duck_step).min(target);
+                }
+                let gain = state.next_gain() * state.duck;
+                let source = if i == 0 {
+                    Some(*frame)
+                } else {
+                    inputs[i].as_ref().and_then(|f| f.get(j).copied())
+                };
+                if let Some(source) = source {
+                    mixed[0] += source[0] * gain;
+                    mixed[1] += source[1] * gain;
+                }
+            }
+            frame[0] = mixed[0].clamp(-1.0, 1.0);
+            frame[1] = mixed[1].clamp(-1.0, 1.0);
+        }
+
+        Some(AudioChunk::F32(AudioChunkData::new(
+            frames,
+            float_chunk.get_sample_rate(),
+            0.0,
+        )))
+    }
+}
+
+#[async_trait::async_trait]
+impl NodeLogic for MixerLogic {
+    async fn process(
+        &mut self,
+        input: Option<mpsc::Receiver<Arc<AudioSegment>>>,
+        output: Vec<mpsc::Sender<Arc<AudioSegment>>>,
+        stop_token: CancellationToken,
+    ) -> Result<(), AudioError> {
+        let mut input = input
+            .ok_or_else(|| AudioError::ProcessingError("MixerNode requires an input".into()))?;
+
+        loop {
+            let seg = tokio::select! {
+                _ = stop_token.cancelled() => break,
+                segment = input.recv() => match segment {
+                    None => break,
+                    Some(seg) => seg,
+                },
+            };
+
+            let seg = match &seg.segment {
+                _AudioSegment::Chunk(chunk) => match self.process_chunk(chunk) {
+                    Some(processed) => Arc::new(AudioSegment {
+                        order: seg.order,
+                        timestamp_sec: seg.timestamp_sec,
+                        segment: _AudioSegment::Chunk(Arc::new(processed)),
+                    }),
+                    None => seg,
+                },
+                _AudioSegment::Sync(_) => seg,
+            };
+
+            send_to_children("MixerNode", &output, seg).await?;
+        }
+
+        Ok(())
+    }
+}
+
+// ─── Nœud public ─────────────────────────────────────────────────────────────
+
+pub struct MixerNode {
+    inner: Node<MixerLogic>,
+}
+
+impl MixerNode {
+    /// Crée un mixeur sans entrée auxiliaire
+    pub fn new(duck_db: f32) -> (Self, MixerHandle) {
+        let handle = MixerHandle::new(duck_db);
+        (Self::with_handle(handle.clone()), handle)
+    }
+
+    /// Crée un nœud piloté par un handle existant (reconstruction de pipeline)
+    pub fn with_handle(handle: MixerHandle) -> Self {
+        Self {
+            inner: Node::new_with_input(MixerLogic::new(handle), 16),
+        }
+    }
+}
+
+#[async_trait::async_trait]
+impl AudioPipelineNode for MixerNode {
+    fn get_tx(&self) -> Option<mpsc::Sender<Arc<AudioSegment>>> {
+        self.inner.get_tx()
+    }
+
+    fn register(&mut self, child: Box<dyn AudioPipelineNode>) {
+        self.inner.register(child);
+    }
+
+    async fn run(self: Box<Self>, stop_token: CancellationToken) -> Result<(), AudioError> {
+        Box::new(self.inner).run(stop_token).await
+    }
+
+    fn start(self: Box<Self>) -> crate::pipeline::PipelineHandle {
+        Box::new(self.inner).start()
+    }
+}
+
+impl crate::TypedAudioNode for MixerNode {
+    fn input_type(&self) -> Option<TypeRequirement> {
+        None // Accepte tout
+    }
+
+    fn output_type(&self) -> Option<TypeRequirement> {
+        None // Passe tel quel sans mixage, F32 sinon
+    }
+}
+
+// ─── Entrée auxiliaire ───────────────────────────────────────────────────────
+
+struct MixerInputLogic {
+    channel: Arc<MixerChannel>,
+}
+
+impl MixerInputLogic {
+    /// Ajoute des frames au tampon en attendant qu'il ait de la place
+    async fn push(
+        &self,
+        chunk: &AudioChunk,
+        stop_token: &CancellationToken,
+    ) -> Result<(), AudioError> {
+        let sample_rate = chunk.sample_rate();
+        let capacity = (sample_rate as u64 * INPUT_BUFFER_MS / 1000) as usize;
+        let float_chunk = match chunk.to_f32().apply_gain() {
+            AudioChunk::F32(data) => data,
+            _ => unreachable!("to_f32 always returns an F32 chunk"),
+        };
+
+        loop {
+            // Enregistré avant la vérification pour ne pas manquer un réveil
+            let space = self.channel.space.notified();
+            {
+                let mut frames = self.channel.frames.lock().unwrap();
+                if frames.len() < capacity {
+                    self.channel
+                        .sample_rate
+                        .store(sample_rate, Ordering::Relaxed);
+                    frames.extend(float_chunk.get_frames().iter().copied());
+                    return Ok(());
+                }
+            }
+            tokio::select! {
+                _ = stop_token.cancelled() => return Ok(()),
+                _ = space => {}
+            }
+        }
+    }
+}
+
+#[async_trait::async_trait]
+impl NodeLogic for MixerInputLogic {
+    async fn process(
+        &mut self,
+        input: Option<mpsc::Receiver<Arc<AudioSegment>>>,
+        _output: Vec<mpsc::Sender<Arc<AudioSegment>>>,
+        stop_token: CancellationToken,
+    ) -> Result<(), AudioError> {
+        let mut input = input
+            .ok_or_else(|| AudioError::ProcessingError("MixerInput requires an input".into()))?;
+
+        loop {
+            let seg = tokio::select! {
+                _ = stop_token.cancelled() => break,
+                segment = input.recv() => match segment {
+                    None => break,
+                    Some(seg) => seg,
+                },
+            };
+            if let _AudioSegment::Chunk(chunk) = &seg.segment {
+                self.push(chunk, &stop_token).await?;
+            }
+        }
+
+        self.channel.finished.store(true, Ordering::Relaxed);
+        Ok(())
+    }
+}
+
+/// Entrée auxiliaire d'un [`MixerNode`] (nœud terminal)
+pub struct MixerInput {
+    index: usize,
+    inner: Node<MixerInputLogic>,
+}
+
+impl MixerInput {
+    fn new(index: usize, channel: Arc<MixerChannel>) -> Self {
+        Self {
+            index,
+            inner: Node::new_with_input(MixerInputLogic { channel }, 16),
+        }
+    }
+
+    /// Indice de l'entrée dans le mixeur
+    pub fn index(&self) -> usize {
+        self.index
+    }
+}
+
+#[async_trait::async_trait]
+impl AudioPipelineNode for MixerInput {
+    fn get_tx(&self) -> Option<mpsc::Sender<Arc<AudioSegment>>> {
+        self.inner.get_tx()
+    }
+
+    fn register(&mut self, _child: Box<dyn AudioPipelineNode>) {
+        tracing::warn!("MixerInput is a terminal node, child ignored");
+    }
+
+    async fn run(self: Box<Self>, stop_token: CancellationToken) -> Result<(), AudioError> {
+        Box::new(self.inner).run(stop_token).await
+    }
+
+    fn start(self: Box<Self>) -> crate::pipeline::PipelineHandle {
+        Box::new(self.inner).start()
+    }
+}
+
+impl crate::TypedAudioNode for MixerInput {
+    fn input_type(&self) -> Option<TypeRequirement> {
+        None // Accepte tout
+    }
+
+    fn output_type(&self) -> Option<TypeRequirement> {
+        None // Nœud terminal
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn chunk(frame: [f32; 2], len: usize, sample_rate: u32) -> AudioChunk {
+        AudioChunk::F32(AudioChunkData::new(vec![frame; len], sample_rate, 0.0))
+    }
+
+    fn frames(chunk: Option<AudioChunk>) -> Vec<[f32; 2]> {
+        match chunk {
+            Some(AudioChunk::F32(d)) => d.get_frames().to_vec(),
+            _ => panic!("expected a mixed F32 chunk"),
+        }
+    }
+
+    #[tokio::test]
+    async fn test_priority_ducking_release() {
+        let (_, handle) = MixerNode::new(-20.0);
+        handle.set_ramp_ms(10);
+        let mut logic = MixerLogic::new(handle.clone());
+        let music = chunk([0.5, 0.5], 960, 48_000);
+        let input = handle.add_input(1).unwrap();
+        input
+            .inner
+            .logic()
+            .push(&chunk([0.0, 0.0], 960, 48_000), &CancellationToken::new())
+            .await
+            .unwrap();
+
+        // Rampe de 10 ms (480 frames) vers -20 dB tant que l'entrée a du signal
+        let out = frames(logic.process_chunk(&music));
+        assert!(out[0][0] < 0.5 && out[0][0] > 0.49);
+        assert!((out[479][0] - 0.05).abs() < 1e-3);
+        assert!((out[900][0] - 0.05).abs() < 1e-3);
+        assert!(!handle.is_active(1));
+
+        // Entrée épuisée : remontée sur la même durée
+        let out = frames(logic.process_chunk(&music));
+        assert!(out[0][0] > 0.05 && out[0][0] < 0.06);
+        assert!((out[479][0] - 0.5).abs() < 1e-3);
+        assert_eq!(out[900][0], 0.5);
+
+        // Gain unitaire retrouvé : les chunks passent sans modification
+        assert!(logic.process_chunk(&music).is_none());
+    }
+
+    #[tokio::test]
+    async fn test_equal_power_crossfade_gain() {
+        let (_, handle) = MixerNode::new(-20.0);
+        let mut logic = MixerLogic::new(handle.clone());
+        // Musique à gauche, seconde source à droite, même priorité
+        let music = chunk([0.5, 0.0], 960, 48_000);
+        let input = handle.add_input(0).unwrap();
+        handle
+            .set_gain(1, 0.0, Duration::ZERO, FadeCurve::Linear)
+            .unwrap();
+        assert!(logic.process_chunk(&music).is_none());
+
+        input
+            .inner
+            .logic()
+            .push(&chunk([0.0, 0.5], 960, 48_000), &CancellationToken::new())
+            .await
+            .unwrap();
+        handle.crossfade(0, 1, Duration::from_millis(10)).unwrap();
+        let out = frames(logic.process_chunk(&music));
+        for frame in out.iter().take(480).step_by(60) {
+            let (from, to) = (frame[0] / 0.5, frame[1] / 0.5);
+            assert!((from * from + to * to - 1.0).abs() < 1e-3);
+        }
+        let half = std::f32::consts::FRAC_1_SQRT_2 * 0.5;
+        assert!((out[240][0] - half).abs() < 1e-3);
+        assert!((out[240][1] - half).abs() < 1e-3);
+        assert_eq!(out[700], [0.0, 0.5]);
+        assert_eq!(handle.gain(0), Some(0.0));
+        assert_eq!(handle.gain(1), Some(1.0));
+    }
+
+    #[tokio::test]
+    async fn test_aux_buffer_backpressure() {
+        let (_, handle) = MixerNode::new(-20.0);
+        let mut logic = MixerLogic::new(handle.clone());
+        let input = handle.add_input(1).unwrap();
+        let token = CancellationToken::new();
+        // 500 ms à 48 kHz : le tampon est plein après ce chunk
+        let voice = chunk([0.25, 0.25], 24_000, 48_000);
+        input.inner.logic().push(&voice, &token).await.unwrap();
+        assert_eq!(handle.buffered(1), 24_000);
+
+        let push = input.inner.logic().push(&voice, &token);
+        tokio::pin!(push);
+        assert!(tokio::time::timeout(Duration::from_millis(50), &mut push)
+            .await
+            .is_err());
+
+        // Le mixeur consomme des frames : l'entrée reprend
+        logic.process_chunk(&chunk([0.0, 0.0], 480, 48_000));
+        tokio::time::timeout(Duration::from_secs(1), push)
+            .await
+            .expect("push should resume once frames are mixed")
+            .unwrap();
+        assert_eq!(handle.buffered(1), 2 * 24_000 - 480);
+
+        handle.clear(1).unwrap();
+        assert!(!handle.is_active(1));
+        assert!(handle.attach_input(0).is_err());
+        assert_eq!(handle.attach_input(1).unwrap().index(), 1);
+    }
+
+    #[tokio::test]
+    async fn test_chunk_at_other_rate_is_dropped() {
+        let (_, handle) = MixerNode::new(-20.0);
+        let mut logic = MixerLogic::new(handle.clone());
+        let music = chunk([0.5, 0.5], 480, 48_000);
+        let input = handle.add_input(1).unwrap();
+        let token = CancellationToken::new();
+
+        input
+            .inner
+            .logic()
+            .push(&chunk([0.25, 0.25], 441, 44_100), &token)
+            .await
+            .unwrap();
+        assert!(handle.is_active(1));
+        // Frames à 44,1 kHz abandonnés : la musique passe inchangée
+        assert!(logic.process_chunk(&music).is_none());
+        assert!(!handle.is_active(1));
+        assert_eq!(handle.sample_rate(), Some(48_000));
+
+        // À la fréquence de sortie, l'entrée est mixée
+        input
+            .inner
+            .logic()
+            .push(&chunk([0.25, 0.25], 480, 48_000), &token)
+            .await
+            .unwrap();
+        assert!(logic.process_chunk(&music).is_some());
+    }
+
+    #[tokio::test]
+    async fn test_mix_duck_and_crossfade() {
+        let (_, handle) = MixerNode::new(-20.0);
+        handle.set_ramp_ms(0);
+        let mut logic = MixerLogic::new(handle.clone());
+        let music = AudioChunk::F32(AudioChunkData::new(
+            vec![[0.5f32, 0.5f32]; 480],
+            48_000,
+            0.0,
+        ));
+        assert!(logic.process_chunk(&music).is_none());
+
+        // Entrée prioritaire : la musique est abaissée de 20 dB
+        let input = handle.add_input(1).unwrap();
+        assert_eq!(input.index(), 1);
+        let voice = AudioChunk::F32(AudioChunkData::new(
+            vec![[0.25f32, -0.25f32]; 240],
+            48_000,
+            0.0,
+        ));
+        input
+            .inner
+            .logic()
+            .push(&voice, &CancellationToken::new())
+            .await
+            .unwrap();
+        assert!(handle.is_active(1));
+        let out = match logic.process_chunk(&music).unwrap() {
+            AudioChunk::F32(d) => d,
+            _ => panic!("expected F32"),
+        };
+        let frames = out.get_frames();
+        assert!((frames[100][0] - 0.30).abs() < 1e-3);
+        assert!((frames[100][1] + 0.20).abs() < 1e-3);
+        // Entrée épuisée : silence pour la fin du chunk
+        assert!((frames[300][0] - 0.05).abs() < 1e-3);
+        assert!(!handle.is_active(1));
+
+        // Fondu enchaîné à puissance constante sur 10 ms (480 frames)
+        handle.set_priority(1, 0).unwrap();
+        handle.crossfade(0, 1, Duration::from_millis(10)).unwrap();
+        let out = match logic.process_chunk(&music).unwrap() {
+            AudioChunk::F32(d) => d,
+            _ => panic!("expected F32"),
+        };
+        let frames = out.get_frames();
+        assert!((frames[240][0] - 0.5 * std::f32::consts::FRAC_1_SQRT_2).abs() < 1e-2);
+        assert_eq!(handle.gain(0), Some(0.0));
+        assert!(logic.process_chunk(&music).unwrap().len() == 480);
+        assert!(handle.add_input(0).is_ok());
+    }
+}
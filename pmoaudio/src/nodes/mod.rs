@@ -19,15 +19,24 @@ pub const DEFAULT_CHANNEL_SIZE: usize = 16;
 pub const DEFAULT_CHUNK_DURATION_MS: f64 = 50.0;
 
 // Modules actifs
+pub mod analysis_node;
+pub mod announce_source;
 pub mod audio_sink;
+pub mod channel_mix_node;
 pub mod converter_nodes;
+pub mod convolution_node;
+pub mod crossfeed_node;
 pub mod file_source;
 pub mod flac_file_sink;
 pub mod http_source;
+pub mod mixer_node;
+pub mod pregain_node;
 pub mod resampling_node;
 pub mod timer_buffer_node;
 pub mod timer_node;
 pub mod position_tracker_node;
+pub mod recorder_node;
+pub mod volume_ramp_node;
 
 // Modules temporairement désactivés
 /*
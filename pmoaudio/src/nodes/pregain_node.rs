@@ -0,0 +1,290 @@
+//! PreGainNode — normalisation de sonie par piste.
+//!
+//! À chaque `TrackBoundary`, le nœud choisit le gain de la piste :
+//! 1. le ReplayGain des tags (`TrackMetadata::get_replay_gain`) s'il existe ;
+//! 2. sinon la sonie mémorisée dans le [`PreGainStore`] pour l'empreinte du
+//!    contenu (`TrackMetadata::get_content_hash`) ;
+//! 3. sinon la piste est jouée sans correction et mesurée au passage : si elle
+//!    est lue jusqu'au bout, sa sonie est enregistrée pour les lectures suivantes.
+//!
+//! Le gain est limité pour que la crête mesurée ne dépasse pas la pleine échelle.
+
+use crate::{
+    dsp::loudness::{replay_gain_db, LoudnessMeter},
+    nodes::AudioError,
+    pipeline::{send_to_children, AudioPipelineNode, Node, NodeLogic},
+    type_constraints::TypeRequirement,
+    AudioChunk, AudioSegment, SyncMarker, _AudioSegment,
+};
+use pmometadata::TrackMetadata;
+use std::sync::Arc;
+use tokio::sync::{mpsc, RwLock};
+use tokio_util::sync::CancellationToken;
+use tracing::{debug, warn};
+
+/// Part minimale de la durée annoncée qui doit être mesurée avant d'enregistrer
+const MIN_MEASURED_RATIO: f64 = 0.9;
+
+/// Sonie mesurée d'une piste
+#[derive(Debug, Clone, Copy, PartialEq)]
+pub struct TrackLoudness {
+    /// Sonie intégrée (LUFS)
+    pub integrated_lufs: f64,
+    /// Crête échantillon (linéaire)
+    pub peak: f32,
+}
+
+impl TrackLoudness {
+    /// Gain ReplayGain à appliquer, limité pour éviter l'écrêtage
+    pub fn gain_db(&self) -> f64 {
+        let gain = replay_gain_db(self.integrated_lufs);
+        if self.peak > 0.0 {
+            gain.min(-20.0 * (self.peak as f64).log10())
+        } else {
+            gain
+        }
+    }
+}
+
+/// Stockage persistant des sonies, indexé par empreinte de contenu
+#[async_trait::async_trait]
+pub trait PreGainStore: Send + Sync {
+    async fn lookup(&self, content_hash: &str) -> Option<TrackLoudness>;
+    async fn store(&self, content_hash: &str, loudness: TrackLoudness);
+}
+
+/// Mesure en cours de la piste courante
+struct Measurement {
+    content_hash: String,
+    meter: Option<LoudnessMeter>,
+    expected_sec: Option<f64>,
+}
+
+// ─── Logique du nœud ─────────────────────────────────────────────────────────
+
+struct PreGainLogic {
+    store: Arc<dyn PreGainStore>,
+    gain_db: f64,
+    measurement: Option<Measurement>,
+}
+
+impl PreGainLogic {
+    fn new(store: Arc<dyn PreGainStore>) -> Self {
+        Self {
+            store,
+            gain_db: 0.0,
+            measurement: None,
+        }
+    }
+
+    /// Termine la mesure de la piste précédente et l'enregistre si elle est complète
+    async fn finish_measurement(&mut self) {
+        let Some(measurement) = self.measurement.take() else {
+            return;
+        };
+        let Some(meter) = measurement.meter else {
+            return;
+        };
+        if let Some(expected) = measurement.expected_sec {
+            if meter.duration_sec() < expected * MIN_MEASURED_RATIO {
+                debug!(
+                    "PreGainNode: {} only measured {:.1}/{:.1}s, not stored",
+                    measurement.content_hash,
+                    meter.duration_sec(),
+                    expected
+                );
+                return;
+            }
+        }
+        if let Some(integrated_lufs) = meter.integrated_lufs() {
+            let loudness = TrackLoudness {
+                integrated_lufs,
+                peak: meter.peak(),
+            };
+            debug!(
+                "PreGainNode: measured {} at {:.2} LUFS (gain {:+.2} dB)",
+                measurement.content_hash,
+                integrated_lufs,
+                loudness.gain_db()
+            );
+            self.store.store(&measurement.content_hash, loudness).await;
+        }
+    }
+
+    /// Choisit le gain de la piste qui commence
+    async fn start_track(&mut self, metadata: &Arc<RwLock<dyn TrackMetadata>>) {
+        self.finish_measurement().await;
+        self.gain_db = 0.0;
+
+        let (replay_gain, content_hash, duration) = {
+            let meta = metadata.read().await;
+            (
+                meta.get_replay_gain().await.ok().flatten(),
+                meta.get_content_hash().await.ok().flatten(),
+                meta.get_duration().await.ok().flatten(),
+            )
+        };
+
+        if let Some(gain) = replay_gain {
+            debug!("PreGainNode: using ReplayGain tag {:+.2} dB", gain);
+            self.gain_db = gain;
+            return;
+        }
+
+        let Some(content_hash) = content_hash else {
+            return;
+        };
+
+        match self.store.lookup(&content_hash).await {
+            Some(loudness) => {
+                self.gain_db = loudness.gain_db();
+                debug!(
+                    "PreGainNode: cached loudness for {} → {:+.2} dB",
+                    content_hash, self.gain_db
+                );
+            }
+            None => {
+                self.measurement = Some(Measurement {
+                    content_hash,
+                    meter: None,
+                    expected_sec: duration.map(|d| d.as_secs_f64()),
+                });
+            }
+        }
+    }
+
+    fn process_chunk(&mut self, chunk: &AudioChunk) -> Option<AudioChunk> {
+        if let Some(measurement) = self.measurement.as_mut() {
+            let meter = measurement
+                .meter
+                .get_or_insert_with(|| LoudnessMeter::new(chunk.sample_rate()));
+            if meter.sample_rate() == chunk.sample_rate() {
+                match chunk.to_f32().apply_gain() {
+                    AudioChunk::F32(data) => meter.push(data.get_frames()),
+                    _ => unreachable!("to_f32 always returns an F32 chunk"),
+                }
+            } else {
+                warn!("PreGainNode: sample rate changed mid-track, measurement dropped");
+                self.measurement = None;
+            }
+        }
+
+        if self.gain_db.abs() < f64::EPSILON {
+            return None;
+        }
+        Some(chunk.with_modified_gain_db(self.gain_db).apply_gain())
+    }
+}
+
+#[async_trait::async_trait]
+impl NodeLogic for PreGainLogic {
+    async fn process(
+        &mut self,
+        input: Option<mpsc::Receiver<Arc<AudioSegment>>>,
+        output: Vec<mpsc::Sender<Arc<AudioSegment>>>,
+        stop_token: CancellationToken,
+    ) -> Result<(), AudioError> {
+        let mut input = input.ok_or_else(|| {
+            AudioError::ProcessingError("PreGainNode requires an input".into())
+        })?;
+
+        loop {
+            let seg = tokio::select! {
+                _ = stop_token.cancelled() => break,
+                segment = input.recv() => match segment {
+                    None => break,
+                    Some(seg) => seg,
+                },
+            };
+
+            let seg = match &seg.segment {
+                _AudioSegment::Chunk(chunk) => match self.process_chunk(chunk) {
+                    Some(processed) => Arc::new(AudioSegment {
+                        order: seg.order,
+                        timestamp_sec: seg.timestamp_sec,
+                        segment: _AudioSegment::Chunk(Arc::new(processed)),
+                    }),
+                    None => seg,
+                },
+                _AudioSegment::Sync(marker) => {
+                    match marker.as_ref() {
+                        SyncMarker::TrackBoundary { metadata, .. } => {
+                            self.start_track(metadata).await
+                        }
+                        SyncMarker::EndOfStream => self.finish_measurement().await,
+                        _ => {}
+                    }
+                    seg
+                }
+            };
+
+            send_to_children("PreGainNode", &output, seg).await?;
+        }
+
+        Ok(())
+    }
+}
+
+// ─── Nœud public ─────────────────────────────────────────────────────────────
+
+pub struct PreGainNode {
+    inner: Node<PreGainLogic>,
+}
+
+impl PreGainNode {
+    pub fn new(store: Arc<dyn PreGainStore>) -> Self {
+        Self {
+            inner: Node::new_with_input(PreGainLogic::new(store), 16),
+        }
+    }
+}
+
+#[async_trait::async_trait]
+impl AudioPipelineNode for PreGainNode {
+    fn get_tx(&self) -> Option<mpsc::Sender<Arc<AudioSegment>>> {
+        self.inner.get_tx()
+    }
+
+    fn register(&mut self, child: Box<dyn AudioPipelineNode>) {
+        self.inner.register(child);
+    }
+
+    async fn run(self: Box<Self>, stop_token: CancellationToken) -> Result<(), AudioError> {
+        Box::new(self.inner).run(stop_token).await
+    }
+
+    fn start(self: Box<Self>) -> crate::pipeline::PipelineHandle {
+        Box::new(self.inner).start()
+    }
+}
+
+impl crate::TypedAudioNode for PreGainNode {
+    fn input_type(&self) -> Option<TypeRequirement> {
+        None // Accepte tout
+    }
+
+    fn output_type(&self) -> Option<TypeRequirement> {
+        None // Même type que l'entrée
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_gain_is_limited_by_peak() {
+        let quiet = TrackLoudness {
+            integrated_lufs: -30.0,
+            peak: 0.5,
+        };
+        // +12 dB demandés, mais la crête à -6 dBFS limite à ~+6 dB
+        assert!((quiet.gain_db() - 6.02).abs() < 0.01);
+
+        let loud = TrackLoudness {
+            integrated_lufs: -8.0,
+            peak: 1.0,
+        };
+        assert!((loud.gain_db() + 10.0).abs() < f64::EPSILON);
+    }
+}
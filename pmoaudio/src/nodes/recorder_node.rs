@@ -0,0 +1,490 @@
+//! RecorderNode — dérivation d'enregistrement du flux vers des fichiers FLAC.
+//!
+//! Nœud transparent : les segments passent sans modification vers les
+//! enfants. Quand l'enregistrement est actif, chaque chunk est converti en
+//! PCM 24 bits et transmis à une tâche d'encodage qui écrit des fichiers
+//! `<prefix>-AAAAMMJJ-HHMMSS.flac` horodatés dans le répertoire configuré.
+//! Un fichier ouvert dans la même seconde qu'un autre reçoit un numéro de
+//! séquence (`<prefix>-AAAAMMJJ-HHMMSS-1.flac`...) au lieu de l'écraser.
+//!
+//! Un nouveau fichier est ouvert quand la durée ou la taille maximale est
+//! atteinte, ou quand la fréquence d'échantillonnage change.
+//!
+//! L'encodage ne bloque jamais le pipeline : si la tâche d'écriture prend
+//! du retard, les chunks en excès sont abandonnés (avec un avertissement).
+
+use crate::{
+    nodes::{
+        flac_file_sink::{chunk_to_pcm_bytes, ByteStreamReader},
+        AudioError,
+    },
+    pipeline::{send_to_children, AudioPipelineNode, Node, NodeLogic},
+    type_constraints::TypeRequirement,
+    AudioChunk, AudioSegment, _AudioSegment,
+};
+use pmoflac::{encode_flac_stream, EncoderOptions, PcmFormat};
+use std::path::{Path, PathBuf};
+use std::sync::{
+    atomic::{AtomicBool, AtomicU64, Ordering},
+    Arc, Mutex,
+};
+use std::time::Duration;
+use tokio::io::{AsyncReadExt, AsyncWriteExt};
+use tokio::sync::mpsc;
+use tokio_util::sync::CancellationToken;
+
+/// Profondeur des fichiers enregistrés
+const RECORDER_BITS_PER_SAMPLE: u8 = 24;
+/// Nombre de chunks PCM en attente d'encodage avant abandon
+const RECORDER_QUEUE_SIZE: usize = 64;
+
+/// Paramètres de l'enregistreur
+#[derive(Debug, Clone)]
+pub struct RecorderConfig {
+    /// Répertoire des fichiers enregistrés
+    pub directory: PathBuf,
+    /// Préfixe des noms de fichiers
+    pub prefix: String,
+    /// Durée maximale d'un fichier (None = illimitée)
+    pub max_duration: Option<Duration>,
+    /// Taille maximale d'un fichier en octets (None = illimitée)
+    pub max_bytes: Option<u64>,
+}
+
+// ─── Handle public ────────────────────────────────────────────────────────────
+
+/// Handle partageable pour démarrer/arrêter l'enregistrement.
+#[derive(Clone)]
+pub struct RecorderHandle {
+    recording: Arc<AtomicBool>,
+    config: Arc<Mutex<RecorderConfig>>,
+    current_file: Arc<Mutex<Option<PathBuf>>>,
+}
+
+impl RecorderHandle {
+    /// Enregistrement en cours ?
+    pub fn is_recording(&self) -> bool {
+        self.recording.load(Ordering::Relaxed)
+    }
+
+    /// Démarre ou arrête l'enregistrement
+    pub fn set_recording(&self, recording: bool) {
+        self.recording.store(recording, Ordering::Relaxed);
+    }
+
+    /// Paramètres courants
+    pub fn config(&self) -> RecorderConfig {
+        self.config.lock().unwrap().clone()
+    }
+
+    /// Remplace les paramètres (appliqués au prochain fichier)
+    pub fn set_config(&self, config: RecorderConfig) {
+        *self.config.lock().unwrap() = config;
+    }
+
+    /// Fichier en cours d'écriture
+    pub fn current_file(&self) -> Option<PathBuf> {
+        self.current_file.lock().unwrap().clone()
+    }
+}
+
+// ─── Fichier en cours ────────────────────────────────────────────────────────
+
+struct Recording {
+    pcm_tx: mpsc::Sender<Vec<u8>>,
+    sample_rate: u32,
+    frames: u64,
+    max_frames: Option<u64>,
+    max_bytes: Option<u64>,
+    /// Octets FLAC écrits (mis à jour par la tâche d'écriture)
+    written: Arc<AtomicU64>,
+}
+
+impl Recording {
+    /// Le chunk suivant doit-il ouvrir un nouveau fichier ?
+    fn needs_rotation(&self, sample_rate: u32) -> bool {
+        self.sample_rate != sample_rate || self.is_full()
+    }
+
+    fn is_full(&self) -> bool {
+        self.max_frames.is_some_and(|max| self.frames >= max)
+            || self
+                .max_bytes
+                .is_some_and(|max| self.written.load(Ordering::Relaxed) >= max)
+    }
+}
+
+/// Chemin du prochain fichier horodaté `stamp`.
+///
+/// Un numéro de séquence est ajouté tant que le nom est déjà pris : deux
+/// rotations dans la même seconde ne tronquent pas le premier fichier.
+fn next_file_path(config: &RecorderConfig, stamp: &str, taken: impl Fn(&Path) -> bool) -> PathBuf {
+    let mut path = config
+        .directory
+        .join(format!("{}-{}.flac", config.prefix, stamp));
+    let mut sequence = 0;
+    while taken(&path) {
+        sequence += 1;
+        path = config
+            .directory
+            .join(format!("{}-{}-{}.flac", config.prefix, stamp, sequence));
+    }
+    path
+}
+
+/// Encode le PCM reçu et l'écrit dans `path`, en comptant les octets écrits
+async fn write_flac_file(
+    path: PathBuf,
+    pcm_rx: mpsc::Receiver<Vec<u8>>,
+    format: PcmFormat,
+    written: Arc<AtomicU64>,
+) -> Result<(), AudioError> {
+    if let Some(parent) = path.parent() {
+        tokio::fs::create_dir_all(parent)
+            .await
+            .map_err(|e| AudioError::IoError(format!("{}: {}", parent.display(), e)))?;
+    }
+
+    let mut flac = encode_flac_stream(ByteStreamReader::new(pcm_rx), format, EncoderOptions::default())
+        .await
+        .map_err(|e| AudioError::ProcessingError(format!("FLAC encode init failed: {}", e)))?;
+    let mut file = tokio::fs::File::create(&path)
+        .await
+        .map_err(|e| AudioError::IoError(format!("{}: {}", path.display(), e)))?;
+
+    let mut buffer = vec![0u8; 64 * 1024];
+    loop {
+        let n = flac
+            .read(&mut buffer)
+            .await
+            .map_err(|e| AudioError::ProcessingError(format!("FLAC encode failed: {}", e)))?;
+        if n == 0 {
+            break;
+        }
+        file.write_all(&buffer[..n])
+            .await
+            .map_err(|e| AudioError::IoError(format!("{}: {}", path.display(), e)))?;
+        written.fetch_add(n as u64, Ordering::Relaxed);
+    }
+    file.flush()
+        .await
+        .map_err(|e| AudioError::IoError(format!("{}: {}", path.display(), e)))?;
+    flac.wait()
+        .await
+        .map_err(|e| AudioError::ProcessingError(format!("Encoder failed: {}", e)))?;
+    Ok(())
+}
+
+// ─── Logique du nœud ─────────────────────────────────────────────────────────
+
+struct RecorderLogic {
+    handle: RecorderHandle,
+    current: Option<Recording>,
+    /// Dernier fichier ouvert, pas forcément encore créé par la tâche d'écriture
+    last_path: Option<PathBuf>,
+    /// Chunks abandonnés depuis le dernier avertissement
+    dropped: u64,
+}
+
+impl RecorderLogic {
+    fn open(&mut self, sample_rate: u32) -> Result<(), AudioError> {
+        let config = self.handle.config();
+        let stamp = chrono::Local::now().format("%Y%m%d-%H%M%S").to_string();
+        let path = next_file_path(&config, &stamp, |path| {
+            path.exists() || self.last_path.as_deref() == Some(path)
+        });
+
+        let format = PcmFormat {
+            sample_rate,
+            channels: 2,
+            bits_per_sample: RECORDER_BITS_PER_SAMPLE,
+        };
+        format
+            .validate()
+            .map_err(|e| AudioError::ProcessingError(format!("Invalid PCM format: {}", e)))?;
+
+        let (pcm_tx, pcm_rx) = mpsc::channel(RECORDER_QUEUE_SIZE);
+        let written = Arc::new(AtomicU64::new(0));
+        let task_path = path.clone();
+        let task_written = written.clone();
+        tokio::spawn(async move {
+            match write_flac_file(task_path.clone(), pcm_rx, format, task_written).await {
+                Ok(()) => tracing::info!("RecorderNode: closed {}", task_path.display()),
+                Err(e) => tracing::warn!("RecorderNode: {} failed: {}", task_path.display(), e),
+            }
+        });
+
+        tracing::info!("RecorderNode: recording to {}", path.display());
+        self.last_path = Some(path.clone());
+        *self.handle.current_file.lock().unwrap() = Some(path);
+        self.current = Some(Recording {
+            pcm_tx,
+            sample_rate,
+            frames: 0,
+            max_frames: config
+                .max_duration
+                .map(|d| (d.as_secs_f64() * sample_rate as f64) as u64),
+            max_bytes: config.max_bytes,
+            written,
+        });
+        Ok(())
+    }
+
+    /// Ferme le fichier en cours (la tâche d'écriture termine l'encodage)
+    fn close(&mut self) {
+        // Fermer le canal PCM termine le flux FLAC
+        self.current = None;
+        *self.handle.current_file.lock().unwrap() = None;
+    }
+
+    fn record_chunk(&mut self, chunk: &AudioChunk) -> Result<(), AudioError> {
+        if !self.handle.is_recording() {
+            if self.current.is_some() {
+                self.close();
+            }
+            return Ok(());
+        }
+
+        let sample_rate = chunk.sample_rate();
+        let rotate = self
+            .current
+            .as_ref()
+            .is_some_and(|r| r.needs_rotation(sample_rate));
+        if rotate {
+            self.close();
+        }
+        if self.current.is_none() {
+            self.open(sample_rate)?;
+        }
+
+        let pcm = chunk_to_pcm_bytes(&chunk.apply_gain().to_i24(), RECORDER_BITS_PER_SAMPLE)?;
+        let recording = self.current.as_mut().expect("recording opened above");
+        match recording.pcm_tx.try_send(pcm) {
+            Ok(()) => {
+                recording.frames += chunk.len() as u64;
+                if self.dropped > 0 {
+                    tracing::warn!("RecorderNode: {} chunks dropped (encoder too slow)", self.dropped);
+                    self.dropped = 0;
+                }
+            }
+            Err(mpsc::error::TrySendError::Full(_)) => self.dropped += 1,
+            Err(mpsc::error::TrySendError::Closed(_)) => {
+                // La tâche d'écriture a échoué : on retentera sur un nouveau fichier
+                self.close();
+            }
+        }
+        Ok(())
+    }
+}
+
+#[async_trait::async_trait]
+impl NodeLogic for RecorderLogic {
+    async fn process(
+        &mut self,
+        input: Option<mpsc::Receiver<Arc<AudioSegment>>>,
+        output: Vec<mpsc::Sender<Arc<AudioSegment>>>,
+        stop_token: CancellationToken,
+    ) -> Result<(), AudioError> {
+        let mut input = input.ok_or_else(|| {
+            AudioError::ProcessingError("RecorderNode requires an input".into())
+        })?;
+
+        loop {
+            let seg = tokio::select! {
+                _ = stop_token.cancelled() => break,
+                segment = input.recv() => match segment {
+                    None => break,
+                    Some(seg) => seg,
+                },
+            };
+
+            if let _AudioSegment::Chunk(chunk) = &seg.segment {
+                if let Err(e) = self.record_chunk(chunk) {
+                    // Un échec d'enregistrement ne doit pas couper la lecture
+                    tracing::warn!("RecorderNode: {}", e);
+                    self.handle.set_recording(false);
+                    self.close();
+                }
+            }
+
+            send_to_children("RecorderNode", &output, seg).await?;
+        }
+
+        self.close();
+        Ok(())
+    }
+}
+
+// ─── Nœud public ─────────────────────────────────────────────────────────────
+
+pub struct RecorderNode {
+    inner: Node<RecorderLogic>,
+}
+
+impl RecorderNode {
+    /// Crée un enregistreur inactif
+    pub fn new(config: RecorderConfig) -> (Self, RecorderHandle) {
+        let handle = RecorderHandle {
+            recording: Arc::new(AtomicBool::new(false)),
+            config: Arc::new(Mutex::new(config)),
+            current_file: Arc::new(Mutex::new(None)),
+        };
+        (Self::with_handle(handle.clone()), handle)
+    }
+
+    /// Crée un enregistreur piloté par un handle existant (reconstruction de
+    /// pipeline) : un enregistrement actif reprend dans un nouveau fichier
+    pub fn with_handle(handle: RecorderHandle) -> Self {
+        let logic = RecorderLogic {
+            handle,
+            current: None,
+            last_path: None,
+            dropped: 0,
+        };
+        Self {
+            inner: Node::new_with_input(logic, 16),
+        }
+    }
+}
+
+#[async_trait::async_trait]
+impl AudioPipelineNode for RecorderNode {
+    fn get_tx(&self) -> Option<mpsc::Sender<Arc<AudioSegment>>> {
+        self.inner.get_tx()
+    }
+
+    fn register(&mut self, child: Box<dyn AudioPipelineNode>) {
+        self.inner.register(child);
+    }
+
+    async fn run(self: Box<Self>, stop_token: CancellationToken) -> Result<(), AudioError> {
+        Box::new(self.inner).run(stop_token).await
+    }
+
+    fn start(self: Box<Self>) -> crate::pipeline::PipelineHandle {
+        Box::new(self.inner).start()
+    }
+}
+
+impl crate::TypedAudioNode for RecorderNode {
+    fn input_type(&self) -> Option<TypeRequirement> {
+        None // Accepte tout
+    }
+
+    fn output_type(&self) -> Option<TypeRequirement> {
+        None // Passe tout
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::AudioChunkData;
+
+    fn config(directory: &Path) -> RecorderConfig {
+        RecorderConfig {
+            directory: directory.to_path_buf(),
+            prefix: "test".into(),
+            max_duration: None,
+            max_bytes: None,
+        }
+    }
+
+    fn logic(config: RecorderConfig) -> RecorderLogic {
+        let (node, handle) = RecorderNode::new(config);
+        drop(node);
+        handle.set_recording(true);
+        RecorderLogic {
+            handle,
+            current: None,
+            last_path: None,
+            dropped: 0,
+        }
+    }
+
+    fn chunk(frames: usize, sample_rate: u32) -> AudioChunk {
+        AudioChunk::I32(AudioChunkData::new(vec![[0, 0]; frames], sample_rate, 0.0))
+    }
+
+    #[test]
+    fn test_next_file_path_adds_sequence() {
+        let config = config(Path::new("/records"));
+        let first = next_file_path(&config, "20260101-120000", |_| false);
+        assert_eq!(first, Path::new("/records/test-20260101-120000.flac"));
+
+        let second = next_file_path(&config, "20260101-120000", |path| path == first);
+        assert_eq!(second, Path::new("/records/test-20260101-120000-1.flac"));
+
+        let third = next_file_path(&config, "20260101-120000", |path| {
+            path == first || path == second
+        });
+        assert_eq!(third, Path::new("/records/test-20260101-120000-2.flac"));
+    }
+
+    #[tokio::test]
+    async fn test_rotation_on_duration() {
+        let dir = tempfile::tempdir().unwrap();
+        let mut config = config(dir.path());
+        config.max_duration = Some(Duration::from_secs(1));
+        let mut logic = logic(config);
+
+        logic.record_chunk(&chunk(4_000, 8_000)).unwrap();
+        let first = logic.handle.current_file().unwrap();
+        logic.record_chunk(&chunk(4_000, 8_000)).unwrap();
+        assert_eq!(logic.handle.current_file().unwrap(), first);
+
+        // Une seconde enregistrée : le chunk suivant ouvre un nouveau fichier,
+        // sans écraser le premier même dans la même seconde
+        logic.record_chunk(&chunk(4_000, 8_000)).unwrap();
+        let second = logic.handle.current_file().unwrap();
+        assert_ne!(second, first);
+        assert_eq!(logic.current.as_ref().unwrap().frames, 4_000);
+    }
+
+    #[tokio::test]
+    async fn test_rotation_on_size() {
+        let dir = tempfile::tempdir().unwrap();
+        let mut config = config(dir.path());
+        config.max_bytes = Some(1_000);
+        let mut logic = logic(config);
+
+        logic.record_chunk(&chunk(1_000, 8_000)).unwrap();
+        let first = logic.handle.current_file().unwrap();
+        let recording = logic.current.as_ref().unwrap();
+        assert!(!recording.needs_rotation(8_000));
+
+        recording.written.store(1_000, Ordering::Relaxed);
+        assert!(recording.needs_rotation(8_000));
+        logic.record_chunk(&chunk(1_000, 8_000)).unwrap();
+        assert_ne!(logic.handle.current_file().unwrap(), first);
+    }
+
+    #[tokio::test]
+    async fn test_rotation_on_sample_rate() {
+        let dir = tempfile::tempdir().unwrap();
+        let mut logic = logic(config(dir.path()));
+
+        logic.record_chunk(&chunk(1_000, 44_100)).unwrap();
+        let first = logic.handle.current_file().unwrap();
+        logic.record_chunk(&chunk(1_000, 44_100)).unwrap();
+        assert_eq!(logic.handle.current_file().unwrap(), first);
+
+        logic.record_chunk(&chunk(1_000, 48_000)).unwrap();
+        assert_ne!(logic.handle.current_file().unwrap(), first);
+        assert_eq!(logic.current.as_ref().unwrap().sample_rate, 48_000);
+    }
+
+    #[tokio::test]
+    async fn test_stop_recording_closes_file() {
+        let dir = tempfile::tempdir().unwrap();
+        let mut logic = logic(config(dir.path()));
+
+        logic.record_chunk(&chunk(1_000, 44_100)).unwrap();
+        assert!(logic.handle.current_file().is_some());
+
+        logic.handle.set_recording(false);
+        logic.record_chunk(&chunk(1_000, 44_100)).unwrap();
+        assert!(logic.handle.current_file().is_none());
+        assert!(logic.current.is_none());
+    }
+}
@@ -1,7 +1,10 @@
 //! ResamplingNode - Node de resampling pour normaliser le sample rate
 //!
 //! Ce node prend en entrée des chunks audio avec des sample rates variables
-//! et les resample vers un sample rate cible fixe.
+//! et les resample vers un sample rate cible fixe, ou, en mode famille
+//! ([`ResampleTarget::Family`]), vers le multiple de la fréquence source le
+//! plus proche d'une fréquence préférée : 44,1 kHz devient 88,2 kHz et
+//! 48 kHz devient 96 kHz, sans conversion 44,1 ↔ 48 inutile.
 //!
 //! # Usage
 //!
@@ -13,12 +16,21 @@
 //! source.register(Box::new(resampler));
 //! ```
 //!
+//! ```rust,no_run
+//! use pmoaudio::{ResampleTarget, ResamplingNode};
+//!
+//! // 44,1 → 88,2 kHz, 48 → 96 kHz, 192 → 96 kHz
+//! let resampler = ResamplingNode::with_target(ResampleTarget::family(96000));
+//! ```
+//!
 //! # Comportement
 //!
 //! - Détecte automatiquement les changements de sample rate
 //! - Recrée le resampler quand nécessaire
 //! - Passe les chunks directement si déjà au bon sample rate
 //! - Préserve les sync markers (TrackBoundary, etc.)
+//! - Publie fréquences, qualité et compteurs de frames via un
+//!   [`ResamplingHandle`]
 //!
 //! # Performance
 //!
@@ -29,17 +41,170 @@
 //! - 24-bit/32-bit : Very high quality
 
 use crate::{
-    dsp::resampling::{build_resampler, resampling, Resampler},
+    dsp::resampling::{build_resampler, quality_name, resampling, Resampler},
     nodes::{AudioError, TypedAudioNode},
     pipeline::{send_to_children, AudioPipelineNode, Node, NodeLogic},
     type_constraints::TypeRequirement,
     AudioChunk, AudioChunkData, AudioSegment, BitDepth, I24, StreamType,
 };
-use std::sync::Arc;
+use std::sync::{Arc, Mutex};
 use tokio::sync::mpsc;
 use tokio_util::sync::CancellationToken;
 use tracing;
 
+// ═══════════════════════════════════════════════════════════════════════════
+// ResampleTarget - Choix de la fréquence de sortie
+// ═══════════════════════════════════════════════════════════════════════════
+
+/// Fréquences acceptées en mode famille quand aucune liste n'est imposée
+const FAMILY_RATE_RANGE: std::ops::RangeInclusive<u32> = 8_000..=768_000;
+
+/// Fréquence de sortie d'un [`ResamplingNode`]
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum ResampleTarget {
+    /// Fréquence fixe, quelle que soit la source
+    Fixed(u32),
+    /// Multiple ou sous-multiple (puissance de 2) de la fréquence source le
+    /// plus proche de `preferred`, parmi `supported` (toute fréquence de 8 à
+    /// 768 kHz si la liste est vide)
+    ///
+    /// Si aucune fréquence de la famille de la source n'est acceptée, la
+    /// fréquence acceptée la plus proche de `preferred` est retenue.
+    Family { preferred: u32, supported: Vec<u32> },
+}
+
+impl ResampleTarget {
+    /// Mode famille sans contrainte de sortie
+    pub fn family(preferred: u32) -> Self {
+        ResampleTarget::Family {
+            preferred,
+            supported: Vec::new(),
+        }
+    }
+
+    /// Fréquence de sortie pour une source à `source_hz`
+    pub fn rate_for(&self, source_hz: u32) -> u32 {
+        let (preferred, supported) = match self {
+            ResampleTarget::Fixed(rate) => return *rate,
+            ResampleTarget::Family {
+                preferred,
+                supported,
+            } => (*preferred, supported),
+        };
+        let accepted = |rate: u32| {
+            if supported.is_empty() {
+                FAMILY_RATE_RANGE.contains(&rate)
+            } else {
+                supported.contains(&rate)
+            }
+        };
+        let nearest = |rates: &mut dyn Iterator<Item = u32>| {
+            rates.min_by_key(|&rate| (rate.abs_diff(preferred), rate))
+        };
+
+        // Famille de la source : source × 2^k, dans les deux sens
+        let mut family = vec![source_hz];
+        let mut rate = source_hz;
+        while rate % 2 == 0 && rate / 2 >= *FAMILY_RATE_RANGE.start() {
+            rate /= 2;
+            family.push(rate);
+        }
+        let mut rate = source_hz;
+        while rate <= *FAMILY_RATE_RANGE.end() / 2 {
+            rate *= 2;
+            family.push(rate);
+        }
+
+        nearest(&mut family.into_iter().filter(|&rate| accepted(rate)))
+            .or_else(|| nearest(&mut supported.iter().copied()))
+            .unwrap_or(preferred)
+    }
+}
+
+impl From<u32> for ResampleTarget {
+    fn from(rate: u32) -> Self {
+        ResampleTarget::Fixed(rate)
+    }
+}
+
+// ═══════════════════════════════════════════════════════════════════════════
+// ResamplingHandle - Statistiques du flux traité
+// ═══════════════════════════════════════════════════════════════════════════
+
+/// État du resampler et compteurs cumulés depuis le début du flux
+#[derive(Debug, Clone, Copy, Default, PartialEq)]
+pub struct ResamplingStats {
+    /// Fréquence du dernier chunk reçu (Hz, 0 avant le premier chunk)
+    pub source_hz: u32,
+    /// Fréquence de sortie correspondante (Hz)
+    pub target_hz: u32,
+    /// Qualité libsoxr du resampler actif, None si le flux passe tel quel
+    pub quality: Option<&'static str>,
+    /// Frames reçues
+    pub frames_in: u64,
+    /// Frames émises
+    pub frames_out: u64,
+    /// Frames attendues en sortie d'après les ratios successifs
+    pub expected_frames_out: f64,
+}
+
+impl ResamplingStats {
+    /// Ratio sortie / entrée courant (1.0 sans conversion)
+    pub fn ratio(&self) -> f64 {
+        if self.source_hz == 0 {
+            1.0
+        } else {
+            self.target_hz as f64 / self.source_hz as f64
+        }
+    }
+
+    /// Écart cumulé entre frames émises et frames attendues : le retard du
+    /// filtre au démarrage, puis une valeur stable si rien n'est perdu
+    pub fn drift_frames(&self) -> f64 {
+        self.frames_out as f64 - self.expected_frames_out
+    }
+}
+
+/// Handle partageable pour lire l'état du resampler
+#[derive(Clone, Default)]
+pub struct ResamplingHandle {
+    stats: Arc<Mutex<ResamplingStats>>,
+}
+
+impl ResamplingHandle {
+    /// Crée un handle aux compteurs nuls
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Statistiques courantes
+    pub fn stats(&self) -> ResamplingStats {
+        *self.stats.lock().unwrap()
+    }
+
+    /// Remet les compteurs à zéro (nouveau flux)
+    pub fn reset(&self) {
+        *self.stats.lock().unwrap() = ResamplingStats::default();
+    }
+
+    fn record(
+        &self,
+        source_hz: u32,
+        target_hz: u32,
+        quality: Option<&'static str>,
+        frames_in: usize,
+        frames_out: usize,
+    ) {
+        let mut stats = self.stats.lock().unwrap();
+        stats.source_hz = source_hz;
+        stats.target_hz = target_hz;
+        stats.quality = quality;
+        stats.frames_in += frames_in as u64;
+        stats.frames_out += frames_out as u64;
+        stats.expected_frames_out += frames_in as f64 * stats.ratio();
+    }
+}
+
 // ═══════════════════════════════════════════════════════════════════════════
 // ResamplingLogic - Logique pure de resampling
 // ═══════════════════════════════════════════════════════════════════════════
@@ -48,25 +213,38 @@ use tracing;
 ///
 /// Maintient un resampler et le met à jour selon les changements de sample rate.
 pub struct ResamplingLogic {
-    target_sample_rate: u32,
+    target: ResampleTarget,
     current_resampler: Option<ResamplerState>,
+    handle: ResamplingHandle,
 }
 
 struct ResamplerState {
     source_hz: u32,
+    target_hz: u32,
+    quality: &'static str,
     resampler: Resampler,
 }
 
 impl ResamplingLogic {
     pub fn new(target_sample_rate: u32) -> Self {
+        Self::with_target(ResampleTarget::Fixed(target_sample_rate))
+    }
+
+    pub fn with_target(target: ResampleTarget) -> Self {
+        Self::with_handle(target, ResamplingHandle::new())
+    }
+
+    /// Logique publiant ses statistiques sur `handle`
+    pub fn with_handle(target: ResampleTarget, handle: ResamplingHandle) -> Self {
         Self {
-            target_sample_rate,
+            target,
             current_resampler: None,
+            handle,
         }
     }
 
     /// Resample un chunk audio vers le sample rate cible
-    fn resample_chunk(&mut self, chunk: &AudioChunk) -> Result<AudioChunk, AudioError> {
+    pub(crate) fn resample_chunk(&mut self, chunk: &AudioChunk) -> Result<AudioChunk, AudioError> {
         let source_sr = chunk.sample_rate();
         let bit_depth = match chunk {
             AudioChunk::I16(_) => BitDepth::B16,
@@ -77,29 +255,34 @@ impl ResamplingLogic {
         };
 
         // Si déjà au bon sample rate, retourner tel quel
-        if source_sr == self.target_sample_rate {
+        let target_sr = self.target.rate_for(source_sr);
+        if source_sr == target_sr {
+            let frames = chunk.len();
+            self.handle
+                .record(source_sr, target_sr, None, frames, frames);
             return Ok(chunk.clone());
         }
 
         // Vérifier si on doit recréer le resampler
         let need_new_resampler = match &self.current_resampler {
             None => true,
-            Some(state) => state.source_hz != source_sr,
+            Some(state) => state.source_hz != source_sr || state.target_hz != target_sr,
         };
 
         if need_new_resampler {
             tracing::debug!(
                 "ResamplingLogic: creating resampler {}Hz → {}Hz (bit_depth={:?})",
                 source_sr,
-                self.target_sample_rate,
+                target_sr,
                 bit_depth
             );
-            let resampler = build_resampler(source_sr, self.target_sample_rate, bit_depth)
-                .map_err(|e| {
-                    AudioError::ProcessingError(format!("Resampler init failed: {}", e))
-                })?;
+            let resampler = build_resampler(source_sr, target_sr, bit_depth).map_err(|e| {
+                AudioError::ProcessingError(format!("Resampler init failed: {}", e))
+            })?;
             self.current_resampler = Some(ResamplerState {
                 source_hz: source_sr,
+                target_hz: target_sr,
+                quality: quality_name(bit_depth),
                 resampler,
             });
         }
@@ -112,13 +295,16 @@ impl ResamplingLogic {
         // Appliquer le resampling
         let (resampled_left, resampled_right) = resampling(&left, &right, &mut state.resampler);
 
+        self.handle.record(
+            source_sr,
+            target_sr,
+            Some(state.quality),
+            left.len(),
+            resampled_left.len(),
+        );
+
         // Recréer le chunk avec le nouveau sample rate
-        reconstruct_chunk(
-            chunk,
-            resampled_left,
-            resampled_right,
-            self.target_sample_rate,
-        )
+        reconstruct_chunk(chunk, resampled_left, resampled_right, target_sr)
     }
 }
 
@@ -132,8 +318,8 @@ impl NodeLogic for ResamplingLogic {
     ) -> Result<(), AudioError> {
         let mut rx = input.expect("ResamplingNode must have input");
         tracing::debug!(
-            "ResamplingLogic::process started, target={}Hz, {} children",
-            self.target_sample_rate,
+            "ResamplingLogic::process started, target={:?}, {} children",
+            self.target,
             output.len()
         );
 
@@ -324,7 +510,8 @@ fn reconstruct_chunk(
 /// ResamplingNode - Normalise le sample rate vers une valeur cible
 ///
 /// Ce node prend en entrée des chunks audio avec des sample rates variables
-/// et les resample vers un sample rate fixe.
+/// et les resample vers un sample rate fixe ou choisi dans la famille de la
+/// source (voir [`ResampleTarget`]).
 pub struct ResamplingNode {
     inner: Node<ResamplingLogic>,
 }
@@ -342,6 +529,19 @@ impl ResamplingNode {
         Self::new(target_sample_rate).boxed()
     }
 
+    /// Crée un node de resampling vers une fréquence fixe ou de la famille
+    /// de la source
+    pub fn with_target(target: ResampleTarget) -> Self {
+        let logic = ResamplingLogic::with_target(target);
+        Self { inner: Node::new_with_input(logic, 16) }
+    }
+
+    /// Crée un node publiant ses statistiques sur un handle existant
+    pub fn with_handle(target: ResampleTarget, handle: ResamplingHandle) -> Self {
+        let logic = ResamplingLogic::with_handle(target, handle);
+        Self { inner: Node::new_with_input(logic, 16) }
+    }
+
     /// Crée un nouveau node de resampling avec taille de canal personnalisée
     ///
     /// * `target_sample_rate` - Sample rate de sortie en Hz
@@ -462,6 +662,69 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_family_target() {
+        let target = ResampleTarget::family(96_000);
+        assert_eq!(target.rate_for(44_100), 88_200);
+        assert_eq!(target.rate_for(48_000), 96_000);
+        assert_eq!(target.rate_for(192_000), 96_000);
+        assert_eq!(target.rate_for(22_050), 88_200);
+        assert_eq!(ResampleTarget::family(192_000).rate_for(44_100), 176_400);
+        assert_eq!(ResampleTarget::Fixed(48_000).rate_for(44_100), 48_000);
+
+        // Sortie limitée à 48 kHz : 44,1 kHz reste natif
+        let capped = ResampleTarget::Family {
+            preferred: 96_000,
+            supported: vec![44_100, 48_000],
+        };
+        assert_eq!(capped.rate_for(88_200), 44_100);
+        assert_eq!(capped.rate_for(96_000), 48_000);
+        // Aucune fréquence de la famille acceptée
+        assert_eq!(capped.rate_for(32_000), 48_000);
+    }
+
+    #[test]
+    fn test_resample_chunk_family_keeps_native_rate() {
+        let mut logic = ResamplingLogic::with_target(ResampleTarget::family(44_100));
+        let chunk = AudioChunk::I16(AudioChunkData::new(vec![[100, 200]; 10], 88_200, 0.0));
+        let result = logic.resample_chunk(&chunk).unwrap();
+        assert_eq!(result.sample_rate(), 44_100);
+
+        let native = AudioChunk::I16(AudioChunkData::new(vec![[100, 200]; 10], 44_100, 0.0));
+        assert_eq!(logic.resample_chunk(&native).unwrap().sample_rate(), 44_100);
+    }
+
+    #[test]
+    fn test_resampling_stats() {
+        let handle = ResamplingHandle::new();
+        let mut logic = ResamplingLogic::with_handle(ResampleTarget::Fixed(48_000), handle.clone());
+        assert_eq!(handle.stats().ratio(), 1.0);
+
+        let native = AudioChunk::I16(AudioChunkData::new(vec![[0, 0]; 480], 48_000, 0.0));
+        logic.resample_chunk(&native).unwrap();
+        let stats = handle.stats();
+        assert_eq!(stats.quality, None);
+        assert_eq!((stats.frames_in, stats.frames_out), (480, 480));
+        assert_eq!(stats.drift_frames(), 0.0);
+
+        let chunk = AudioChunk::I24(AudioChunkData::new(
+            vec![[I24::new(0).unwrap(); 2]; 4410],
+            44_100,
+            0.0,
+        ));
+        let out = logic.resample_chunk(&chunk).unwrap();
+        let stats = handle.stats();
+        assert_eq!(stats.quality, Some("very_high"));
+        assert_eq!((stats.source_hz, stats.target_hz), (44_100, 48_000));
+        assert!((stats.ratio() - 48_000.0 / 44_100.0).abs() < 1e-12);
+        assert_eq!(stats.frames_in, 480 + 4410);
+        assert_eq!(stats.frames_out, 480 + out.len() as u64);
+        assert!((stats.expected_frames_out - (480.0 + 4800.0)).abs() < 1e-6);
+
+        handle.reset();
+        assert_eq!(handle.stats(), ResamplingStats::default());
+    }
+
     #[test]
     fn test_resample_chunk_no_change_if_same_rate() {
         let mut logic = ResamplingLogic::new(48000);
@@ -0,0 +1,280 @@
+//! VolumeRampNode — volume logiciel avec rampe de gain.
+//!
+//! Applique le volume demandé par le renderer (RenderingControl, OpenHome
+//! Volume) en faisant varier le gain linéairement sur une courte fenêtre
+//! (50–200 ms) au lieu de le changer d'un coup. Un saut de gain instantané
+//! produit un « zipper noise » audible sur les DAC bon marché.
+//!
+//! Tant que le gain vaut 1.0 et qu'aucune rampe n'est en cours, les chunks
+//! passent sans modification (aucune conversion, flux bit-perfect).
+
+use crate::{
+    nodes::AudioError,
+    pipeline::{send_to_children, AudioPipelineNode, Node, NodeLogic},
+    type_constraints::TypeRequirement,
+    AudioChunk, AudioChunkData, AudioSegment, _AudioSegment,
+};
+use std::sync::{
+    atomic::{AtomicU32, Ordering},
+    Arc,
+};
+use tokio::sync::mpsc;
+use tokio_util::sync::CancellationToken;
+
+/// Durée minimale de la rampe (ms)
+pub const MIN_VOLUME_RAMP_MS: u32 = 50;
+/// Durée maximale de la rampe (ms)
+pub const MAX_VOLUME_RAMP_MS: u32 = 200;
+/// Durée par défaut de la rampe (ms)
+pub const DEFAULT_VOLUME_RAMP_MS: u32 = 100;
+
+/// Atténuation correspondant au volume 1 (le volume 0 coupe le son)
+const VOLUME_RANGE_DB: f32 = 50.0;
+
+// ─── Handle public ────────────────────────────────────────────────────────────
+
+/// Handle partageable pour piloter le volume du nœud.
+#[derive(Clone)]
+pub struct VolumeRampHandle {
+    /// Gain cible (f32 encodé dans un AtomicU32)
+    target: Arc<AtomicU32>,
+    /// Durée de la rampe en millisecondes
+    ramp_ms: Arc<AtomicU32>,
+}
+
+impl VolumeRampHandle {
+    fn new(gain: f32, ramp_ms: u32) -> Self {
+        Self {
+            target: Arc::new(AtomicU32::new(gain.to_bits())),
+            ramp_ms: Arc::new(AtomicU32::new(clamp_ramp_ms(ramp_ms))),
+        }
+    }
+
+    /// Gain linéaire cible
+    pub fn gain_linear(&self) -> f32 {
+        f32::from_bits(self.target.load(Ordering::Relaxed))
+    }
+
+    /// Définit le gain linéaire cible (>= 0)
+    pub fn set_gain_linear(&self, gain: f32) {
+        let gain = if gain.is_finite() { gain.max(0.0) } else { 1.0 };
+        self.target.store(gain.to_bits(), Ordering::Relaxed);
+    }
+
+    /// Définit le volume UPnP (0–100) et l'état mute
+    pub fn set_volume(&self, volume: u16, mute: bool) {
+        let gain = if mute { 0.0 } else { volume_to_gain(volume) };
+        self.set_gain_linear(gain);
+    }
+
+    /// Durée de la rampe en millisecondes
+    pub fn ramp_ms(&self) -> u32 {
+        self.ramp_ms.load(Ordering::Relaxed)
+    }
+
+    /// Définit la durée de la rampe (bornée à 50–200 ms)
+    pub fn set_ramp_ms(&self, ramp_ms: u32) {
+        self.ramp_ms.store(clamp_ramp_ms(ramp_ms), Ordering::Relaxed);
+    }
+}
+
+fn clamp_ramp_ms(ramp_ms: u32) -> u32 {
+    ramp_ms.clamp(MIN_VOLUME_RAMP_MS, MAX_VOLUME_RAMP_MS)
+}
+
+/// Convertit un volume UPnP (0–100) en gain linéaire.
+///
+/// Courbe en dB : 100 → 0 dB, 1 → -49.5 dB, 0 → silence.
+pub fn volume_to_gain(volume: u16) -> f32 {
+    let volume = volume.min(100);
+    if volume == 0 {
+        return 0.0;
+    }
+    let db = -VOLUME_RANGE_DB * (1.0 - volume as f32 / 100.0);
+    10f32.powf(db / 20.0)
+}
+
+// ─── Logique du nœud ─────────────────────────────────────────────────────────
+
+struct VolumeRampLogic {
+    handle: VolumeRampHandle,
+    /// Gain effectivement appliqué au dernier frame
+    current: f32,
+    /// Cible de la rampe en cours
+    ramp_target: f32,
+    /// Incrément de gain par frame
+    step: f32,
+}
+
+impl VolumeRampLogic {
+    fn new(handle: VolumeRampHandle) -> Self {
+        let gain = handle.gain_linear();
+        Self {
+            handle,
+            current: gain,
+            ramp_target: gain,
+            step: 0.0,
+        }
+    }
+
+    /// Applique le gain (avec rampe) à un chunk
+    fn process_chunk(&mut self, chunk: &AudioChunk) -> Option<AudioChunk> {
+        let target = self.handle.gain_linear();
+        if target != self.ramp_target {
+            let frames = (chunk.sample_rate() as u64 * self.handle.ramp_ms() as u64 / 1000).max(1);
+            self.ramp_target = target;
+            self.step = (target - self.current) / frames as f32;
+        }
+
+        if self.current == self.ramp_target && self.current == 1.0 {
+            return None;
+        }
+
+        let float_chunk = match chunk.to_f32().apply_gain() {
+            AudioChunk::F32(data) => data,
+            _ => unreachable!("to_f32 always returns an F32 chunk"),
+        };
+        let mut frames = float_chunk.clone_frames();
+        for frame in &mut frames {
+            if self.current != self.ramp_target {
+                self.current += self.step;
+                let overshoot = (self.step > 0.0 && self.current > self.ramp_target)
+                    || (self.step < 0.0 && self.current < self.ramp_target);
+                if overshoot || self.step == 0.0 {
+                    self.current = self.ramp_target;
+                }
+            }
+            frame[0] *= self.current;
+            frame[1] *= self.current;
+        }
+
+        Some(AudioChunk::F32(AudioChunkData::new(
+            frames,
+            float_chunk.get_sample_rate(),
+            0.0,
+        )))
+    }
+}
+
+#[async_trait::async_trait]
+impl NodeLogic for VolumeRampLogic {
+    async fn process(
+        &mut self,
+        input: Option<mpsc::Receiver<Arc<AudioSegment>>>,
+        output: Vec<mpsc::Sender<Arc<AudioSegment>>>,
+        stop_token: CancellationToken,
+    ) -> Result<(), AudioError> {
+        let mut input = input.ok_or_else(|| {
+            AudioError::ProcessingError("VolumeRampNode requires an input".into())
+        })?;
+
+        loop {
+            let seg = tokio::select! {
+                _ = stop_token.cancelled() => break,
+                segment = input.recv() => match segment {
+                    None => break,
+                    Some(seg) => seg,
+                },
+            };
+
+            let seg = match &seg.segment {
+                _AudioSegment::Chunk(chunk) => match self.process_chunk(chunk) {
+                    Some(processed) => Arc::new(AudioSegment {
+                        order: seg.order,
+                        timestamp_sec: seg.timestamp_sec,
+                        segment: _AudioSegment::Chunk(Arc::new(processed)),
+                    }),
+                    None => seg,
+                },
+                _AudioSegment::Sync(_) => seg,
+            };
+
+            send_to_children("VolumeRampNode", &output, seg).await?;
+        }
+
+        Ok(())
+    }
+}
+
+// ─── Nœud public ─────────────────────────────────────────────────────────────
+
+pub struct VolumeRampNode {
+    inner: Node<VolumeRampLogic>,
+}
+
+impl VolumeRampNode {
+    /// Crée un nœud au gain unitaire avec la durée de rampe donnée
+    pub fn new(ramp_ms: u32) -> (Self, VolumeRampHandle) {
+        let handle = VolumeRampHandle::new(1.0, ramp_ms);
+        (Self::with_handle(handle.clone()), handle)
+    }
+
+    /// Crée un nœud piloté par un handle existant (reconstruction de pipeline)
+    pub fn with_handle(handle: VolumeRampHandle) -> Self {
+        Self {
+            inner: Node::new_with_input(VolumeRampLogic::new(handle), 16),
+        }
+    }
+}
+
+#[async_trait::async_trait]
+impl AudioPipelineNode for VolumeRampNode {
+    fn get_tx(&self) -> Option<mpsc::Sender<Arc<AudioSegment>>> {
+        self.inner.get_tx()
+    }
+
+    fn register(&mut self, child: Box<dyn AudioPipelineNode>) {
+        self.inner.register(child);
+    }
+
+    async fn run(self: Box<Self>, stop_token: CancellationToken) -> Result<(), AudioError> {
+        Box::new(self.inner).run(stop_token).await
+    }
+
+    fn start(self: Box<Self>) -> crate::pipeline::PipelineHandle {
+        Box::new(self.inner).start()
+    }
+}
+
+impl crate::TypedAudioNode for VolumeRampNode {
+    fn input_type(&self) -> Option<TypeRequirement> {
+        None // Accepte tout
+    }
+
+    fn output_type(&self) -> Option<TypeRequirement> {
+        None // Passe tel quel à gain unitaire, F32 sinon
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_ramp_reaches_target_without_step() {
+        let handle = VolumeRampHandle::new(1.0, 50);
+        let mut logic = VolumeRampLogic::new(handle.clone());
+
+        let chunk = AudioChunk::F32(AudioChunkData::new(vec![[1.0f32, 1.0f32]; 4800], 48_000, 0.0));
+        assert!(logic.process_chunk(&chunk).is_none());
+
+        handle.set_gain_linear(0.0);
+        let out = match logic.process_chunk(&chunk).unwrap() {
+            AudioChunk::F32(d) => d,
+            _ => panic!("expected F32"),
+        };
+        let frames = out.get_frames();
+        // 50 ms à 48 kHz = 2400 frames de rampe
+        assert!(frames[0][0] < 1.0 && frames[0][0] > 0.99);
+        assert!((frames[1200][0] - 0.5).abs() < 0.01);
+        assert_eq!(frames[2400][0], 0.0);
+        assert_eq!(frames[4799][0], 0.0);
+    }
+
+    #[test]
+    fn test_volume_curve() {
+        assert_eq!(volume_to_gain(0), 0.0);
+        assert!((volume_to_gain(100) - 1.0).abs() < f32::EPSILON);
+        assert!(volume_to_gain(50) < volume_to_gain(75));
+    }
+}
@@ -302,6 +302,9 @@ pub async fn add_with_metadata_extraction(
     if let Some(bitrate) = metadata.bitrate {
         let _ = meta.set_bitrate(Some(bitrate)).await;
     }
+    if let Some(replay_gain) = metadata.replay_gain {
+        let _ = meta.set_replay_gain(Some(replay_gain)).await;
+    }
 
     // Libérer le lock explicitement avant les opérations de collection
     drop(meta);
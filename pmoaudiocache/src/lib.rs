@@ -83,6 +83,7 @@
 pub mod cache;
 pub mod metadata;
 pub mod metadata_ext;
+pub mod pregain;
 pub mod track_metadata;
 
 /// Module public pour la création de transformers FLAC streaming
@@ -107,6 +108,7 @@ pub use cache::{
 };
 pub use metadata::AudioMetadata;
 pub use metadata_ext::{AudioMetadataExt, AudioTrackMetadataExt, TrackMetadataDidlExt};
+pub use pregain::{PreGainDb, get_pregain_db, register_pregain_db};
 pub use track_metadata::AudioCacheTrackMetadata;
 
 #[cfg(feature = "pmoconfig")]
@@ -267,6 +269,12 @@ impl AudioCacheExt for pmoserver::Server {
         let openapi = crate::ApiDoc::openapi();
         self.add_openapi(api_router, openapi, "audio").await;
 
+        // Mesures de sonie des pistes sans ReplayGain, partagées par les pipelines
+        match crate::pregain::PreGainDb::for_cache(&cache) {
+            Ok(db) => register_pregain_db(Arc::new(db)),
+            Err(e) => tracing::warn!("⚠️ Failed to open pre-gain database: {}", e),
+        }
+
         Ok(cache)
     }
 
@@ -69,6 +69,11 @@ pub struct AudioMetadata {
     #[cfg_attr(feature = "pmoserver", schema(example = 1411))]
     pub bitrate: Option<u32>,
 
+    /// ReplayGain de la piste (dB), si le fichier est taggé
+    #[serde(default)]
+    #[cfg_attr(feature = "pmoserver", schema(example = -7.35))]
+    pub replay_gain: Option<f64>,
+
     /// Informations sur la conversion appliquée lors de l'ingestion
     #[cfg_attr(feature = "pmoserver", schema(example = json!({"mode":"transcode","source_codec":"mp3"})))]
     pub conversion: Option<AudioConversionInfo>,
@@ -111,6 +116,7 @@ impl AudioMetadata {
             sample_rate: properties.sample_rate(),
             channels: properties.channels(),
             bitrate: properties.audio_bitrate(),
+            replay_gain: None,
             conversion: None,
         };
 
@@ -124,6 +130,9 @@ impl AudioMetadata {
             metadata.disc_number = tag.disk();
             metadata.disc_total = tag.disk_total();
             metadata.genre = tag.genre().map(|s| s.to_string());
+            metadata.replay_gain = tag
+                .get_string(&ItemKey::ReplayGainTrackGain)
+                .and_then(parse_replay_gain);
         }
 
         metadata
@@ -236,6 +245,17 @@ impl AudioMetadata {
     }
 }
 
+/// Décode une valeur ReplayGain (`"-7.35 dB"`, `"+1.2"`)
+fn parse_replay_gain(value: &str) -> Option<f64> {
+    let value = value.trim();
+    let number = value
+        .strip_suffix("dB")
+        .or_else(|| value.strip_suffix("db"))
+        .unwrap_or(value)
+        .trim();
+    number.parse::<f64>().ok().filter(|g| g.is_finite())
+}
+
 impl Default for AudioMetadata {
     fn default() -> Self {
         Self {
@@ -252,6 +272,7 @@ impl Default for AudioMetadata {
             sample_rate: None,
             channels: None,
             bitrate: None,
+            replay_gain: None,
             conversion: None,
         }
     }
@@ -277,6 +298,7 @@ mod tests {
             sample_rate: Some(44100),
             channels: Some(2),
             bitrate: Some(1411),
+            replay_gain: None,
             conversion: None,
         };
 
@@ -302,6 +324,7 @@ mod tests {
             sample_rate: None,
             channels: None,
             bitrate: None,
+            replay_gain: None,
             conversion: None,
         };
 
@@ -0,0 +1,154 @@
+//! Cache des mesures de sonie (pré-gain)
+//!
+//! Quand une piste n'a pas de tag ReplayGain, le [`PreGainNode`](pmoaudio::PreGainNode)
+//! mesure sa sonie à la première lecture. Ce module persiste ces mesures dans
+//! une base SQLite (`pregain.db`, à côté du cache audio), indexées par le pk
+//! du cache — une empreinte du contenu — pour normaliser les lectures
+//! suivantes sans pré-scan de la bibliothèque.
+
+use anyhow::Result;
+use chrono::Utc;
+use once_cell::sync::OnceCell;
+use pmoaudio::{PreGainStore, TrackLoudness};
+use rusqlite::{Connection, OptionalExtension, params};
+use std::path::Path;
+use std::sync::{Arc, Mutex};
+use tracing::warn;
+
+/// Nom du fichier de la base, dans le répertoire du cache audio
+pub const PREGAIN_DB_FILE: &str = "pregain.db";
+
+/// Base SQLite des sonies mesurées
+#[derive(Debug)]
+pub struct PreGainDb {
+    conn: Mutex<Connection>,
+}
+
+impl PreGainDb {
+    /// Ouvre (ou crée) la base à `path`
+    pub fn open(path: &Path) -> Result<Self> {
+        let conn = Connection::open(path)?;
+        conn.execute_batch(
+            "CREATE TABLE IF NOT EXISTS pregain (
+                content_hash    TEXT PRIMARY KEY,
+                integrated_lufs REAL NOT NULL,
+                peak            REAL NOT NULL,
+                measured_at     TEXT NOT NULL
+            );",
+        )?;
+        Ok(Self {
+            conn: Mutex::new(conn),
+        })
+    }
+
+    /// Ouvre la base associée au cache audio
+    pub fn for_cache(cache: &crate::Cache) -> Result<Self> {
+        Self::open(&cache.cache_dir().join(PREGAIN_DB_FILE))
+    }
+
+    pub fn get(&self, content_hash: &str) -> Result<Option<TrackLoudness>> {
+        let conn = self.conn.lock().unwrap();
+        let row = conn
+            .query_row(
+                "SELECT integrated_lufs, peak FROM pregain WHERE content_hash = ?1",
+                params![content_hash],
+                |row| {
+                    Ok(TrackLoudness {
+                        integrated_lufs: row.get(0)?,
+                        peak: row.get::<_, f64>(1)? as f32,
+                    })
+                },
+            )
+            .optional()?;
+        Ok(row)
+    }
+
+    pub fn set(&self, content_hash: &str, loudness: TrackLoudness) -> Result<()> {
+        let conn = self.conn.lock().unwrap();
+        conn.execute(
+            "INSERT INTO pregain (content_hash, integrated_lufs, peak, measured_at)
+             VALUES (?1, ?2, ?3, ?4)
+             ON CONFLICT(content_hash) DO UPDATE SET
+                integrated_lufs = excluded.integrated_lufs,
+                peak = excluded.peak,
+                measured_at = excluded.measured_at",
+            params![
+                content_hash,
+                loudness.integrated_lufs,
+                loudness.peak as f64,
+                Utc::now().to_rfc3339()
+            ],
+        )?;
+        Ok(())
+    }
+
+    /// Supprime une mesure (ex: fichier remplacé)
+    pub fn remove(&self, content_hash: &str) -> Result<()> {
+        let conn = self.conn.lock().unwrap();
+        conn.execute(
+            "DELETE FROM pregain WHERE content_hash = ?1",
+            params![content_hash],
+        )?;
+        Ok(())
+    }
+}
+
+#[async_trait::async_trait]
+impl PreGainStore for PreGainDb {
+    async fn lookup(&self, content_hash: &str) -> Option<TrackLoudness> {
+        match self.get(content_hash) {
+            Ok(loudness) => loudness,
+            Err(e) => {
+                warn!("Pre-gain lookup failed for {}: {}", content_hash, e);
+                None
+            }
+        }
+    }
+
+    async fn store(&self, content_hash: &str, loudness: TrackLoudness) {
+        if let Err(e) = self.set(content_hash, loudness) {
+            warn!("Failed to store pre-gain for {}: {}", content_hash, e);
+        }
+    }
+}
+
+// ============================================================================
+// Registre global singleton
+// ============================================================================
+
+static PREGAIN_DB: OnceCell<Arc<PreGainDb>> = OnceCell::new();
+
+/// Enregistre la base de pré-gain globale (seul le premier appel prend effet)
+pub fn register_pregain_db(db: Arc<PreGainDb>) {
+    let _ = PREGAIN_DB.set(db);
+}
+
+/// Base de pré-gain globale, si elle a été enregistrée
+pub fn get_pregain_db() -> Option<Arc<PreGainDb>> {
+    PREGAIN_DB.get().cloned()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_store_and_lookup() {
+        let dir = tempfile::tempdir().unwrap();
+        let db = PreGainDb::open(&dir.path().join(PREGAIN_DB_FILE)).unwrap();
+        assert!(db.get("abc").unwrap().is_none());
+
+        let loudness = TrackLoudness {
+            integrated_lufs: -11.5,
+            peak: 0.98,
+        };
+        db.set("abc", loudness).unwrap();
+        db.set("abc", loudness).unwrap();
+        let stored = db.get("abc").unwrap().unwrap();
+        assert_eq!(stored.integrated_lufs, -11.5);
+        assert!((stored.peak - 0.98).abs() < 1e-6);
+
+        db.remove("abc").unwrap();
+        assert!(db.get("abc").unwrap().is_none());
+    }
+}
@@ -405,6 +405,26 @@ impl TrackMetadata for AudioCacheTrackMetadata {
         Ok(Some(()))
     }
 
+    async fn get_replay_gain(&self) -> MetadataResult<f64> {
+        Ok(self.read_number("replay_gain")?.and_then(|n| n.as_f64()))
+    }
+
+    async fn set_replay_gain(&mut self, value: Option<f64>) -> MetadataResult<()> {
+        self.write_f64("replay_gain", value)?;
+        let _ = self.touch().await?;
+        Ok(Some(()))
+    }
+
+    async fn get_content_hash(&self) -> MetadataResult<String> {
+        // Le pk du cache est dérivé du contenu ; un lazy pk non téléchargé
+        // n'identifie encore qu'une URL.
+        Ok(match &self.real_pk {
+            Some(real_pk) => Some(real_pk.clone()),
+            None if !pmocache::is_lazy_pk(&self.pk) => Some(self.pk.clone()),
+            None => None,
+        })
+    }
+
     async fn get_updated_at(&self) -> MetadataResult<SystemTime> {
         Ok(self.read_timestamp()?)
     }
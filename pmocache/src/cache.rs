@@ -4,17 +4,19 @@
 //! avec métadonnées dans une base de données SQLite.
 
 use crate::cache_trait::FileCache;
-use crate::db::DB;
+use crate::db::{DbInit, DB};
 use crate::download::{
-    download_with_transformer, ingest_with_transformer, Download, StreamTransformer,
+    download_with_client, download_with_transformer, ingest_with_transformer, http_client,
+    peek_header_with_client, Download, StreamTransformer,
 };
 use crate::lazy::{lazy_prefix_from_pk, LazyEntryRemoteData, LazyProvider};
 use anyhow::{anyhow, bail, Result};
+use futures_util::{stream, StreamExt};
 use serde_json::{Number, Value};
 use sha2::{Digest, Sha256};
-use std::collections::HashMap;
+use std::collections::{HashMap, HashSet};
 use std::path::{Path, PathBuf};
-use std::sync::atomic::{AtomicU64, Ordering};
+use std::sync::atomic::{AtomicBool, AtomicU64, Ordering};
 use std::sync::{Arc, RwLock as StdRwLock};
 use tokio::io::{AsyncRead, AsyncReadExt};
 use tokio::sync::{broadcast, RwLock};
@@ -97,6 +99,9 @@ type CacheServeCallback = Arc<dyn Fn(&CacheBroadcastEvent) -> bool + Send + Sync
 /// Taille minimale de prébuffering par défaut (512 KB = ~5 secondes de FLAC)
 pub const DEFAULT_PREBUFFER_SIZE: u64 = 512 * 1024;
 
+/// Téléchargements menés de front par [`Cache::add_from_urls`]
+pub const BULK_CONCURRENCY: usize = 8;
+
 /// Paramètres statiques d'un cache spécialisé.
 pub trait CacheConfig: Send + Sync {
     /// Extension des fichiers générés (ex: `"webp"`, `"flac"`).
@@ -148,6 +153,9 @@ pub struct Cache<C: CacheConfig> {
     served_tx: Option<broadcast::Sender<CacheEvent>>,
     /// Providers responsables de préfixes lazy spécifiques
     lazy_providers: StdRwLock<HashMap<String, Arc<dyn LazyProvider>>>,
+    /// Base inutilisable : les fichiers présents sur disque sont servis sans
+    /// passer par la base (voir [`Cache::is_read_through`])
+    read_through: AtomicBool,
     /// Phantom data pour le type de configuration
     _phantom: std::marker::PhantomData<C>,
 }
@@ -404,10 +412,11 @@ impl<C: CacheConfig + 'static> Cache<C> {
     ) -> Result<Self> {
         let directory = PathBuf::from(dir);
         std::fs::create_dir_all(&directory)?;
-        let (db, was_reset) = DB::init(&directory.join("cache.db"))?;
+        let db_path = directory.join("cache.db");
+        let (db, outcome) = DB::init(&db_path)?;
 
         // Si le schéma a changé, effacer tous les fichiers du cache (cohérence DB/fichiers)
-        if was_reset {
+        if outcome == DbInit::SchemaReset {
             tracing::warn!("Cache schema changed, clearing all cache files in {:?}", directory);
             if let Ok(entries) = std::fs::read_dir(&directory) {
                 for entry in entries.flatten() {
@@ -422,6 +431,36 @@ impl<C: CacheConfig + 'static> Cache<C> {
         // Créer un channel pour les events (capacité de 100 events en buffer)
         let (served_tx, _) = broadcast::channel(100);
 
+        // Base corrompue ou en mémoire : l'index est reconstruit depuis les
+        // fichiers, les métadonnées (URL d'origine, collection) sont perdues
+        let rebuilt = match &outcome {
+            DbInit::Recovered { .. } | DbInit::InMemory { .. } => {
+                Self::rebuild_index(&directory, &db)
+            }
+            _ => 0,
+        };
+        match &outcome {
+            DbInit::Recovered { quarantine, reason } => tracing::warn!(
+                "⚠️ {} cache database was corrupted ({}), moved to {:?} and rebuilt from {} cached files; \
+                 origins and collections of these files are lost",
+                C::cache_name(),
+                reason,
+                quarantine,
+                rebuilt
+            ),
+            DbInit::InMemory { reason } => tracing::warn!(
+                "⚠️ {} cache database {:?} cannot be created ({}), serving {} cached files from an \
+                 in-memory index; check free space and permissions of {:?}",
+                C::cache_name(),
+                db_path,
+                reason,
+                rebuilt,
+                directory
+            ),
+            _ => {}
+        }
+        let read_through = matches!(outcome, DbInit::InMemory { .. });
+
         Ok(Self {
             dir: directory,
             limit,
@@ -433,10 +472,67 @@ impl<C: CacheConfig + 'static> Cache<C> {
             min_prebuffer_size: DEFAULT_PREBUFFER_SIZE,
             served_tx: Some(served_tx),
             lazy_providers: StdRwLock::new(HashMap::new()),
+            read_through: AtomicBool::new(read_through),
             _phantom: std::marker::PhantomData,
         })
     }
 
+    /// Réinscrit dans `db` chaque fichier complet du répertoire du cache
+    ///
+    /// Retourne le nombre d'entrées recréées.
+    fn rebuild_index(dir: &Path, db: &DB) -> usize {
+        let Ok(entries) = std::fs::read_dir(dir) else {
+            return 0;
+        };
+        let suffix = format!(".{}.complete", C::file_extension());
+        let mut rebuilt = 0;
+        for entry in entries.flatten() {
+            let file_name = entry.file_name();
+            let Some(name) = file_name.to_str() else {
+                continue;
+            };
+            // Marker de complétion : {pk}.{qualifier}.{EXT}.complete
+            let Some(pk) = name
+                .strip_suffix(&suffix)
+                .and_then(|stem| stem.split('.').next())
+            else {
+                continue;
+            };
+            if db.get(pk, false).is_ok() {
+                continue;
+            }
+            match db.add(pk, None, None) {
+                Ok(()) => rebuilt += 1,
+                Err(e) => tracing::debug!("Unable to reindex {}: {}", pk, e),
+            }
+        }
+        rebuilt
+    }
+
+    /// La base est-elle contournée ?
+    ///
+    /// Vrai quand aucune base n'a pu être ouverte sur disque, ou après une
+    /// erreur de requête autre qu'une entrée absente : [`Cache::get`] sert
+    /// alors tout fichier présent sur disque.
+    pub fn is_read_through(&self) -> bool {
+        self.read_through.load(Ordering::Relaxed)
+    }
+
+    /// Passe en mode read-through après une erreur de la base
+    ///
+    /// Un seul avertissement est journalisé, à la première erreur.
+    fn report_db_failure(&self, err: &rusqlite::Error) {
+        if !self.read_through.swap(true, Ordering::Relaxed) {
+            tracing::warn!(
+                "⚠️ {} cache database error ({}), serving cached files without it; \
+                 stop the server and delete {:?} to rebuild the index",
+                C::cache_name(),
+                err,
+                self.dir.join("cache.db")
+            );
+        }
+    }
+
     /// Lance une consolidation en arrière-plan pour un cache existant
     ///
     /// Cette fonction utilitaire lance une tâche asynchrone qui consolide le cache
@@ -670,6 +766,16 @@ impl<C: CacheConfig + 'static> Cache<C> {
     /// Deux URLs différentes pointant vers le même contenu auront le même pk,
     /// permettant une déduplication automatique.
     pub async fn add_from_url(&self, url: &str, collection: Option<&str>) -> Result<String> {
+        self.add_from_url_with_client(url, collection, None).await
+    }
+
+    /// [`Cache::add_from_url`], avec le client HTTP d'un lot s'il y en a un
+    async fn add_from_url_with_client(
+        &self,
+        url: &str,
+        collection: Option<&str>,
+        client: Option<&reqwest::Client>,
+    ) -> Result<String> {
         // 0. Vérifier d'abord si cette URL est déjà en cache (optimisation réseau)
         if let Ok(Some(existing_pk)) = self.db.get_pk_by_origin_url(url) {
             // Vérifier que le fichier est toujours complet et valide
@@ -691,7 +797,11 @@ impl<C: CacheConfig + 'static> Cache<C> {
         }
 
         // 1. Télécharger les 2048 premiers octets pour calculer le pk
-        let header = crate::download::peek_header(url, 2048)
+        let client = match client {
+            Some(client) => client.clone(),
+            None => http_client().map_err(|e| anyhow!("Failed to create HTTP client: {}", e))?,
+        };
+        let header = peek_header_with_client(&client, url, 2048)
             .await
             .map_err(|e| anyhow!("Failed to peek header: {}", e))?;
 
@@ -715,7 +825,7 @@ impl<C: CacheConfig + 'static> Cache<C> {
         tracing::debug!("Starting full download for pk {} from URL {}", pk, url);
         let file_path = self.get_file_path(&pk);
         let transformer = self.transformer_factory.as_ref().map(|f| f());
-        let download = download_with_transformer(&file_path, url, transformer);
+        let download = download_with_client(&file_path, url, &client, transformer);
 
         // Stocker dans la map des downloads en cours
         {
@@ -734,6 +844,79 @@ impl<C: CacheConfig + 'static> Cache<C> {
         .await
     }
 
+    /// Ajoute au cache un lot d'URLs
+    ///
+    /// Équivalent à [`Cache::add_from_url`] pour chaque URL distincte, mais
+    /// pensé pour les gros lots (pochettes de tout un document DIDL) :
+    ///
+    /// - une URL présente plusieurs fois n'est traitée qu'une fois ;
+    /// - les URLs déjà en cache sont retrouvées en une seule transaction
+    ///   SQLite, et leurs compteurs d'accès mis à jour en une autre ;
+    /// - les autres sont téléchargées [`BULK_CONCURRENCY`] à la fois par un
+    ///   même client HTTP, qui réutilise ses connexions.
+    ///
+    /// # Returns
+    ///
+    /// Pour chaque URL distincte, son pk ou l'erreur rencontrée
+    pub async fn add_from_urls(
+        &self,
+        urls: &[&str],
+        collection: Option<&str>,
+    ) -> HashMap<String, Result<String>> {
+        let mut seen = HashSet::new();
+        let unique: Vec<&str> = urls.iter().copied().filter(|u| seen.insert(*u)).collect();
+        let mut results = HashMap::with_capacity(unique.len());
+
+        // 1. URLs déjà en cache et complètes : aucune requête réseau
+        let known = self.db.get_pks_by_origin_urls(&unique).unwrap_or_else(|e| {
+            tracing::debug!("Bulk origin URL lookup failed: {}", e);
+            HashMap::new()
+        });
+        let mut hits = Vec::new();
+        let mut missing = Vec::new();
+        for url in unique {
+            match known.get(url) {
+                Some(pk)
+                    if self.get_file_path(pk).exists()
+                        && self.get_completion_marker_path(pk).exists() =>
+                {
+                    hits.push(pk.as_str());
+                    results.insert(url.to_string(), Ok(pk.clone()));
+                }
+                _ => missing.push(url),
+            }
+        }
+        if let Err(e) = self.db.update_hits(&hits) {
+            self.report_db_failure(&e);
+        }
+        tracing::debug!(
+            "Bulk add to {} cache: {} URLs already cached, {} to fetch",
+            C::cache_name(),
+            hits.len(),
+            missing.len()
+        );
+
+        // 2. Les autres, quelques-unes à la fois, par un même client HTTP
+        let client = match http_client() {
+            Ok(client) => Some(client),
+            Err(e) => {
+                tracing::warn!("Failed to create shared HTTP client: {}", e);
+                None
+            }
+        };
+        let client = client.as_ref();
+        let fetched: Vec<(&str, Result<String>)> = stream::iter(missing)
+            .map(|url| async move {
+                let pk = self.add_from_url_with_client(url, collection, client).await;
+                (url, pk)
+            })
+            .buffer_unordered(BULK_CONCURRENCY)
+            .collect()
+            .await;
+        results.extend(fetched.into_iter().map(|(url, pk)| (url.to_string(), pk)));
+        results
+    }
+
     /// Télécharge un fichier lazy et commute l'entrée existante
     pub async fn download_lazy_from_url(
         &self,
@@ -1023,8 +1206,18 @@ impl<C: CacheConfig + 'static> Cache<C> {
     ///
     /// * `pk` - Clé primaire du fichier
     pub async fn get(&self, pk: &str) -> Result<PathBuf> {
-        self.db.get(pk, false)?;
-        self.db.update_hit(pk)?;
+        match self.db.get(pk, false) {
+            Ok(_) => {
+                if let Err(e) = self.db.update_hit(pk) {
+                    self.report_db_failure(&e);
+                }
+            }
+            Err(rusqlite::Error::QueryReturnedNoRows) if !self.is_read_through() => {
+                return Err(rusqlite::Error::QueryReturnedNoRows.into());
+            }
+            Err(rusqlite::Error::QueryReturnedNoRows) => {}
+            Err(e) => self.report_db_failure(&e),
+        }
 
         let file_path = self.get_file_path(pk);
         if file_path.exists() {
@@ -1171,6 +1364,16 @@ impl<C: CacheConfig + 'static> Cache<C> {
     /// - Supprime les fichiers sans marker de complétion et leurs entrées DB
     /// - Supprime les fichiers sans entrées DB correspondantes
     pub async fn consolidate(&self) -> Result<()> {
+        // Sans base fiable, les fichiers absents de l'index ne sont pas
+        // orphelins : ne rien supprimer
+        if self.is_read_through() {
+            tracing::warn!(
+                "Skipping {} cache consolidation in read-through mode",
+                C::cache_name()
+            );
+            return Ok(());
+        }
+
         // Récupérer la liste des entrées à traiter
         let entries = self.db.get_all(false)?;
 
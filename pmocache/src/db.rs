@@ -9,7 +9,8 @@ use serde::Serialize;
 use serde_json::{Map, Number, Value};
 use tracing::{trace, warn};
 
-use std::path::Path;
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
 use std::str::FromStr;
 use std::sync::{Mutex, MutexGuard};
 
@@ -60,6 +61,25 @@ pub struct CacheEntry {
     pub metadata: Option<Value>,
 }
 
+/// Résultat de l'ouverture de la base par [`DB::init`]
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum DbInit {
+    /// Base existante intègre, ou nouvelle base
+    Opened,
+    /// Version de schéma différente : base recréée, les fichiers du cache
+    /// sont à effacer
+    SchemaReset,
+    /// Base illisible ou corrompue, mise de côté et recréée vide
+    Recovered {
+        /// Copie de la base corrompue (`None` si elle a dû être supprimée)
+        quarantine: Option<PathBuf>,
+        /// Erreur d'ouverture ou résultat de `PRAGMA quick_check`
+        reason: String,
+    },
+    /// Aucune base utilisable sur disque : index tenu en mémoire
+    InMemory { reason: String },
+}
+
 /// Base de données SQLite pour le cache
 ///
 /// Gère les métadonnées des éléments en cache :
@@ -110,12 +130,11 @@ impl DB {
         ConnGuard { ctx, guard }
     }
 
-    /// Initialise une nouvelle base de données avec une table personnalisée
+    /// Ouvre la base du cache, en la réparant si besoin
     ///
     /// # Arguments
     ///
     /// * `path` - Chemin vers le fichier de base de données SQLite
-    /// * `table_name` - Nom de la table à créer
     ///
     /// # Exemple
     ///
@@ -123,36 +142,90 @@ impl DB {
     /// use pmocache::db::DB;
     /// use std::path::Path;
     ///
-    /// let db = DB::init(Path::new("cache.db")).unwrap();
+    /// let (db, outcome) = DB::init(Path::new("cache.db")).unwrap();
     /// ```
-    /// Initialise la DB. Retourne `(db, was_reset)` où `was_reset` indique si la DB
-    /// a été supprimée et recréée suite à un changement de version de schéma.
-    /// Dans ce cas, l'appelant doit aussi effacer les fichiers du cache.
-    pub fn init(path: &Path) -> Result<(Self, bool), rusqlite::Error> {
-        let was_reset = if path.exists() {
-            if let Ok(conn) = Connection::open(path) {
-                let version: u32 = conn
-                    .query_row("PRAGMA user_version", [], |r| r.get(0))
-                    .unwrap_or(0);
-                if version != SCHEMA_VERSION {
-                    drop(conn);
+    ///
+    /// Une base existante passe `PRAGMA quick_check`. Une base illisible ou
+    /// corrompue est renommée en `cache.db.corrupt-<date>` et remplacée par
+    /// une base vide ([`DbInit::Recovered`]) ; si aucune base ne peut être
+    /// créée sur disque (disque plein, lecture seule), l'index est tenu en
+    /// mémoire ([`DbInit::InMemory`]). Dans ces deux cas l'appelant
+    /// reconstruit l'index depuis les fichiers du cache. Après un changement
+    /// de version de schéma ([`DbInit::SchemaReset`]), il doit au contraire
+    /// effacer les fichiers du cache.
+    pub fn init(path: &Path) -> Result<(Self, DbInit), rusqlite::Error> {
+        let mut outcome = DbInit::Opened;
+        if path.exists() {
+            match Self::check(path) {
+                Ok(version) if version != SCHEMA_VERSION => {
                     warn!(
                         "Cache DB schema version mismatch (found {}, expected {}), recreating",
                         version, SCHEMA_VERSION
                     );
                     std::fs::remove_file(path).ok();
-                    true
-                } else {
-                    false
+                    outcome = DbInit::SchemaReset;
+                }
+                Ok(_) => {}
+                Err(reason) => {
+                    let quarantine = Self::quarantine(path);
+                    outcome = DbInit::Recovered { quarantine, reason };
                 }
-            } else {
-                false
             }
-        } else {
-            false
-        };
+        }
+
+        match Connection::open(path).and_then(Self::setup) {
+            Ok(db) => Ok((db, outcome)),
+            Err(e) => {
+                let db = Connection::open_in_memory().and_then(Self::setup)?;
+                Ok((
+                    db,
+                    DbInit::InMemory {
+                        reason: e.to_string(),
+                    },
+                ))
+            }
+        }
+    }
+
+    /// Version du schéma d'une base existante, si elle est intègre
+    fn check(path: &Path) -> Result<u32, String> {
+        let conn = Connection::open(path).map_err(|e| e.to_string())?;
+        let status: String = conn
+            .query_row("PRAGMA quick_check", [], |r| r.get(0))
+            .map_err(|e| e.to_string())?;
+        if status != "ok" {
+            return Err(status);
+        }
+        conn.query_row("PRAGMA user_version", [], |r| r.get(0))
+            .map_err(|e| e.to_string())
+    }
+
+    /// Met de côté une base corrompue (et ses fichiers WAL) pour analyse
+    ///
+    /// Retourne le nouveau chemin, `None` si le renommage a échoué et que
+    /// la base a dû être supprimée.
+    fn quarantine(path: &Path) -> Option<PathBuf> {
+        let suffix = format!("corrupt-{}", Utc::now().format("%Y%m%d%H%M%S"));
+        let target = path.with_extension(format!("db.{}", suffix));
+        for ext in ["db-wal", "db-shm"] {
+            let sidecar = path.with_extension(ext);
+            if sidecar.exists() {
+                std::fs::rename(&sidecar, path.with_extension(format!("{}.{}", ext, suffix)))
+                    .or_else(|_| std::fs::remove_file(&sidecar))
+                    .ok();
+            }
+        }
+        match std::fs::rename(path, &target) {
+            Ok(()) => Some(target),
+            Err(_) => {
+                std::fs::remove_file(path).ok();
+                None
+            }
+        }
+    }
 
-        let conn = Connection::open(path)?;
+    /// Crée les tables et index sur une connexion
+    fn setup(conn: Connection) -> Result<Self, rusqlite::Error> {
         conn.execute("PRAGMA foreign_keys = ON", [])?;
 
         // 1. CRÉATION DES TABLES EN PREMIER
@@ -250,12 +323,9 @@ impl DB {
         // Inscrire la version du schéma
         conn.execute_batch(&format!("PRAGMA user_version = {}", SCHEMA_VERSION))?;
 
-        Ok((
-            Self {
-                conn: Mutex::new(conn),
-            },
-            was_reset,
-        ))
+        Ok(Self {
+            conn: Mutex::new(conn),
+        })
     }
 
     /// Ajoute ou met à jour une entrée dans la base de données
@@ -528,6 +598,29 @@ impl DB {
         .optional()
     }
 
+    /// Récupère les pk d'un lot d'URLs d'origine, en une seule transaction
+    ///
+    /// Les URLs inconnues sont absentes du résultat.
+    pub fn get_pks_by_origin_urls(
+        &self,
+        origin_urls: &[&str],
+    ) -> rusqlite::Result<HashMap<String, String>> {
+        let mut conn = self.lock_conn("get_pks_by_origin_urls");
+        let tx = conn.transaction()?;
+        let mut pks = HashMap::with_capacity(origin_urls.len());
+        {
+            let mut stmt =
+                tx.prepare("SELECT pk FROM metadata WHERE key = 'origin_url' AND value = ?")?;
+            for url in origin_urls {
+                if let Some(pk) = stmt.query_row([*url], |row| row.get(0)).optional()? {
+                    pks.insert(url.to_string(), pk);
+                }
+            }
+        }
+        tx.commit()?;
+        Ok(pks)
+    }
+
     /// Récupère uniquement les métadonnées JSON d'une entrée
     ///
     /// # Arguments
@@ -696,6 +789,25 @@ impl DB {
         Ok(())
     }
 
+    /// Met à jour les statistiques d'accès d'un lot d'entrées, en une seule
+    /// transaction
+    pub fn update_hits(&self, pks: &[&str]) -> rusqlite::Result<()> {
+        if pks.is_empty() {
+            return Ok(());
+        }
+        let mut conn = self.lock_conn("update_hits");
+        let tx = conn.transaction()?;
+        {
+            let mut stmt =
+                tx.prepare("UPDATE asset SET hits = hits + 1, last_used = ?1 WHERE pk = ?2")?;
+            let now = Utc::now().to_rfc3339();
+            for pk in pks {
+                stmt.execute(params![now, pk])?;
+            }
+        }
+        tx.commit()
+    }
+
     /// Purge toutes les entrées de la base de données.
     pub fn purge(&self) -> rusqlite::Result<()> {
         let conn = self.lock_conn("purge");
@@ -184,7 +184,8 @@ impl CacheInput {
 }
 
 enum DownloadSource {
-    Url(String),
+    /// URL, et client HTTP partagé le cas échéant
+    Url(String, Option<reqwest::Client>),
     Reader {
         reader: Box<dyn AsyncRead + Send + Unpin>,
         length: Option<u64>,
@@ -384,7 +385,40 @@ pub fn download_with_transformer<P: AsRef<Path>>(
     url: &str,
     transformer: Option<StreamTransformer>,
 ) -> Arc<Download> {
-    spawn_download(filename, DownloadSource::Url(url.to_string()), transformer)
+    spawn_download(
+        filename,
+        DownloadSource::Url(url.to_string(), None),
+        transformer,
+    )
+}
+
+/// Comme [`download_with_transformer`], avec un client HTTP partagé
+///
+/// Voir [`http_client`].
+pub fn download_with_client<P: AsRef<Path>>(
+    filename: P,
+    url: &str,
+    client: &reqwest::Client,
+    transformer: Option<StreamTransformer>,
+) -> Arc<Download> {
+    spawn_download(
+        filename,
+        DownloadSource::Url(url.to_string(), Some(client.clone())),
+        transformer,
+    )
+}
+
+/// Crée un client HTTP à partager entre plusieurs requêtes
+///
+/// Un même client garde ses connexions ouvertes d'une requête à l'autre :
+/// les fichiers d'un même serveur (pochettes d'un album, d'une playlist)
+/// sont récupérés sans nouvelle connexion TCP/TLS. Les délais sont fixés
+/// requête par requête. Le client est lié au runtime tokio courant : il
+/// ne doit pas lui survivre.
+pub fn http_client() -> Result<reqwest::Client, String> {
+    reqwest::Client::builder()
+        .build()
+        .map_err(|e| e.to_string())
 }
 
 /// Ingère un flux (`AsyncRead`) dans le cache avec transformation optionnelle.
@@ -437,13 +471,18 @@ async fn download_impl(
     transformer: Option<StreamTransformer>,
 ) -> Result<(), String> {
     let input = match source {
-        DownloadSource::Url(url) => {
-            let client = reqwest::Client::builder()
-                .timeout(Duration::from_secs(300))
-                .build()
-                .map_err(|e| e.to_string())?;
+        DownloadSource::Url(url, client) => {
+            let client = match client {
+                Some(client) => client,
+                None => http_client()?,
+            };
 
-            let response = match client.get(&url).send().await {
+            let response = match client
+                .get(&url)
+                .timeout(Duration::from_secs(300))
+                .send()
+                .await
+            {
                 Ok(resp) => resp,
                 Err(e) => {
                     let mut s = state.write().await;
@@ -588,15 +627,22 @@ async fn default_copy(
 /// let pk = pk_from_content_header(&header);
 /// ```
 pub async fn peek_header(url: &str, max_bytes: usize) -> Result<Vec<u8>, String> {
-    let client = reqwest::Client::builder()
-        .timeout(Duration::from_secs(30))
-        .build()
-        .map_err(|e| e.to_string())?;
+    peek_header_with_client(&http_client()?, url, max_bytes).await
+}
 
+/// Comme [`peek_header`], avec un client HTTP partagé
+///
+/// Voir [`http_client`].
+pub async fn peek_header_with_client(
+    client: &reqwest::Client,
+    url: &str,
+    max_bytes: usize,
+) -> Result<Vec<u8>, String> {
     // Essayer d'abord avec une requête Range
     let range_header = format!("bytes=0-{}", max_bytes - 1);
     let mut response = client
         .get(url)
+        .timeout(Duration::from_secs(30))
         .header("Range", range_header)
         .send()
         .await
@@ -168,8 +168,9 @@ pub fn covers_absolute_url_for_upnp(pk: &str, param: Option<&str>) -> String {
 }
 pub use db::{CacheEntry, DB};
 pub use download::{
-    download, download_with_transformer, ingest_with_transformer, peek_header, peek_reader_header,
-    Download, StreamTransformer, TransformContextHandle, TransformMetadata,
+    download, download_with_client, download_with_transformer, http_client, ingest_with_transformer,
+    peek_header, peek_header_with_client, peek_reader_header, Download, StreamTransformer,
+    TransformContextHandle, TransformMetadata,
 };
 pub use lazy::{lazy_prefix_from_pk, LazyEntryRemoteData, LazyProvider};
 
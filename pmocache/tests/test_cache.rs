@@ -26,6 +26,23 @@ fn create_test_cache(limit: usize) -> (TempDir, TestCache) {
     (temp_dir, cache)
 }
 
+/// Attend le marqueur de complétion, écrit en tâche de fond
+async fn wait_for_completion_marker(dir: &std::path::Path) {
+    let has_marker = || {
+        std::fs::read_dir(dir)
+            .unwrap()
+            .flatten()
+            .any(|e| e.file_name().to_string_lossy().ends_with(".complete"))
+    };
+    for _ in 0..100 {
+        if has_marker() {
+            return;
+        }
+        tokio::time::sleep(tokio::time::Duration::from_millis(10)).await;
+    }
+    panic!("no completion marker in {:?}", dir);
+}
+
 #[tokio::test]
 async fn test_cache_creation() {
     let (temp_dir, cache) = create_test_cache(10);
@@ -359,3 +376,129 @@ async fn test_is_finished() {
     // Vérifier qu'il est bien terminé
     assert!(cache.is_finished(&pk).await);
 }
+
+#[tokio::test]
+async fn test_cache_rebuilds_corrupted_db() {
+    let temp_dir = tempfile::tempdir().unwrap();
+    let dir = temp_dir.path().to_str().unwrap();
+
+    let test_file = tempfile::NamedTempFile::new().unwrap();
+    std::fs::write(test_file.path(), b"Payload surviving a corrupted db").unwrap();
+    let pk = {
+        let cache = TestCache::new(dir, 10).unwrap();
+        let pk = cache
+            .add_from_file(test_file.path().to_str().unwrap(), None)
+            .await
+            .unwrap();
+        cache.wait_until_finished(&pk).await.unwrap();
+        wait_for_completion_marker(temp_dir.path()).await;
+        pk
+    };
+    let _ = std::fs::remove_file(temp_dir.path().join("cache.db-wal"));
+    let _ = std::fs::remove_file(temp_dir.path().join("cache.db-shm"));
+    std::fs::write(temp_dir.path().join("cache.db"), b"garbage, not a database").unwrap();
+
+    let cache = TestCache::new(dir, 10).unwrap();
+    assert!(!cache.is_read_through());
+    assert!(cache.db.get(&pk, false).is_ok());
+    assert!(cache.get(&pk).await.is_ok());
+}
+
+#[tokio::test]
+async fn test_add_from_urls() {
+    let (temp_dir, cache) = create_test_cache(10);
+
+    let test_file = tempfile::NamedTempFile::new().unwrap();
+    std::fs::write(test_file.path(), b"Cover already in the cache").unwrap();
+    let pk = cache
+        .add_from_file(test_file.path().to_str().unwrap(), None)
+        .await
+        .unwrap();
+    cache.wait_until_finished(&pk).await.unwrap();
+    wait_for_completion_marker(temp_dir.path()).await;
+    // URL d'origine file://… : retrouvée en base, sans requête réseau
+    let origin = cache.db.get_origin_url(&pk).unwrap().unwrap();
+    let cached = origin.as_str();
+    let hits = cache.db.get(&pk, false).unwrap().hits;
+
+    // Port 9 (discard) : connexion refusée, sans attente réseau
+    let unreachable = "http://127.0.0.1:9/missing.jpg";
+    let results = cache
+        .add_from_urls(&[cached, unreachable, cached], None)
+        .await;
+
+    assert_eq!(results.len(), 2);
+    assert_eq!(results[cached].as_ref().unwrap(), &pk);
+    assert!(results[unreachable].is_err());
+    assert_eq!(cache.db.get(&pk, false).unwrap().hits, hits + 1);
+}
+
+/// Serveur HTTP minimal qui compte les requêtes reçues par chemin
+async fn serve_counting() -> (
+    String,
+    std::sync::Arc<std::sync::Mutex<std::collections::HashMap<String, usize>>>,
+) {
+    use tokio::io::{AsyncReadExt, AsyncWriteExt};
+
+    let listener = tokio::net::TcpListener::bind("127.0.0.1:0").await.unwrap();
+    let base = format!("http://{}", listener.local_addr().unwrap());
+    let requests = std::sync::Arc::new(std::sync::Mutex::new(std::collections::HashMap::new()));
+    let counter = requests.clone();
+    tokio::spawn(async move {
+        while let Ok((mut stream, _)) = listener.accept().await {
+            let counter = counter.clone();
+            tokio::spawn(async move {
+                let mut request = Vec::new();
+                let mut buf = [0u8; 1024];
+                while !request.windows(4).any(|w| w == b"\r\n\r\n") {
+                    match stream.read(&mut buf).await {
+                        Ok(0) | Err(_) => return,
+                        Ok(n) => request.extend_from_slice(&buf[..n]),
+                    }
+                }
+                let request = String::from_utf8_lossy(&request);
+                let path = request.split_whitespace().nth(1).unwrap_or("").to_string();
+                *counter.lock().unwrap().entry(path).or_insert(0) += 1;
+
+                let body = vec![b'x'; 8192];
+                let header = format!(
+                    "HTTP/1.1 200 OK\r\nContent-Type: image/jpeg\r\nContent-Length: {}\r\nConnection: close\r\n\r\n",
+                    body.len()
+                );
+                let _ = stream.write_all(header.as_bytes()).await;
+                let _ = stream.write_all(&body).await;
+                let _ = stream.shutdown().await;
+            });
+        }
+    });
+    (base, requests)
+}
+
+#[tokio::test]
+async fn test_add_from_urls_fetches_duplicates_once() {
+    let (base, requests) = serve_counting().await;
+    let single = format!("{}/single.jpg", base);
+    let repeated = format!("{}/repeated.jpg", base);
+
+    // Référence : requêtes nécessaires pour une URL seule
+    let (_single_dir, cache) = create_test_cache(10);
+    let results = cache.add_from_urls(&[single.as_str()], None).await;
+    let pk = results[&single].as_ref().unwrap().clone();
+    cache.wait_until_finished(&pk).await.unwrap();
+
+    // La même URL trois fois (pochette d'album répétée pour chaque piste)
+    let (_repeated_dir, cache) = create_test_cache(10);
+    let results = cache
+        .add_from_urls(
+            &[repeated.as_str(), repeated.as_str(), repeated.as_str()],
+            None,
+        )
+        .await;
+    assert_eq!(results.len(), 1);
+    let pk = results[&repeated].as_ref().unwrap().clone();
+    cache.wait_until_finished(&pk).await.unwrap();
+
+    let requests = requests.lock().unwrap();
+    assert!(requests["/single.jpg"] > 0);
+    assert_eq!(requests["/repeated.jpg"], requests["/single.jpg"]);
+}
@@ -1,4 +1,4 @@
-use pmocache::db::DB;
+use pmocache::db::{DbInit, DB};
 use serde_json::{json, Value};
 use tempfile::TempDir;
 
@@ -6,7 +6,7 @@ use tempfile::TempDir;
 fn create_test_db() -> (TempDir, DB) {
     let temp_dir = tempfile::tempdir().unwrap();
     let db_path = temp_dir.path().join("test.db");
-    let db = DB::init(&db_path).unwrap();
+    let (db, _) = DB::init(&db_path).unwrap();
     (temp_dir, db)
 }
 
@@ -19,6 +19,25 @@ fn test_db_init() {
     assert!(db_path.exists());
 }
 
+#[test]
+fn test_db_init_recovers_corrupted_file() {
+    let temp_dir = tempfile::tempdir().unwrap();
+    let db_path = temp_dir.path().join("cache.db");
+    std::fs::write(&db_path, b"not a sqlite database, just garbage").unwrap();
+
+    let (db, outcome) = DB::init(&db_path).unwrap();
+    match outcome {
+        DbInit::Recovered { quarantine, .. } => assert!(quarantine.unwrap().exists()),
+        other => panic!("unexpected outcome {:?}", other),
+    }
+    db.add("pk", None, None).unwrap();
+    assert!(db.get("pk", false).is_ok());
+
+    drop(db);
+    let (_, outcome) = DB::init(&db_path).unwrap();
+    assert_eq!(outcome, DbInit::Opened);
+}
+
 #[test]
 fn test_add_and_get() {
     let (_temp_dir, db) = create_test_db();
@@ -227,6 +246,30 @@ fn test_origin_url() {
     assert_eq!(retrieved_url, Some(url.to_string()));
 }
 
+#[test]
+fn test_batch_origin_lookup_and_hits() {
+    let (_temp_dir, db) = create_test_db();
+    let url_a = "https://example.com/a.jpg";
+    let url_b = "https://example.com/b.jpg";
+
+    db.add("pk_a", None, None).unwrap();
+    db.add("pk_b", None, None).unwrap();
+    db.set_origin_url("pk_a", url_a).unwrap();
+    db.set_origin_url("pk_b", url_b).unwrap();
+
+    let pks = db
+        .get_pks_by_origin_urls(&[url_a, "https://example.com/unknown.jpg", url_b])
+        .unwrap();
+    assert_eq!(pks.len(), 2);
+    assert_eq!(pks[url_a], "pk_a");
+    assert_eq!(pks[url_b], "pk_b");
+
+    db.update_hits(&["pk_a", "pk_b", "pk_a"]).unwrap();
+    db.update_hits(&[]).unwrap();
+    assert_eq!(db.get("pk_a", false).unwrap().hits, 2);
+    assert_eq!(db.get("pk_b", false).unwrap().hits, 1);
+}
+
 #[test]
 fn test_pk_collision_detection() {
     let (_temp_dir, db) = create_test_db();
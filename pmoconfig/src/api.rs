@@ -1,9 +1,9 @@
-use crate::Config;
+use crate::{secrets::SECRETS_SECTION, Config};
 use axum::{
     extract::{Path, State},
     http::StatusCode,
     response::{IntoResponse, Response},
-    routing::{get, post},
+    routing::{delete, get, post, put},
     Json, Router,
 };
 use serde::{Deserialize, Serialize};
@@ -36,6 +36,22 @@ pub struct UpdateConfigResponse {
     pub message: String,
 }
 
+/// Profils déclarés
+#[derive(Debug, Serialize, Deserialize, utoipa::ToSchema)]
+pub struct ProfilesResponse {
+    /// Profil des lectures d'origine UPnP
+    pub default: String,
+    /// Tous les profils, le profil par défaut en tête
+    pub profiles: Vec<String>,
+}
+
+/// Structure pour créer un profil ou changer le profil par défaut
+#[derive(Debug, Serialize, Deserialize, utoipa::ToSchema)]
+pub struct ProfileRequest {
+    /// Nom du profil (`[a-z0-9_-]`, 32 caractères au plus)
+    pub name: String,
+}
+
 /// Erreur API
 #[derive(Debug)]
 pub struct ApiError(anyhow::Error);
@@ -61,7 +77,7 @@ where
     }
 }
 
-/// GET /api/config - Récupérer toute la configuration
+/// GET /api/config - Récupérer toute la configuration (secrets masqués)
 #[utoipa::path(
     get,
     path = "/api/config",
@@ -71,7 +87,7 @@ where
     )
 )]
 async fn get_full_config(State(config): State<Arc<Config>>) -> Result<Json<JsonValue>, ApiError> {
-    let value = config.get_value(&[])?;
+    let value = config.get_redacted_value(&[])?;
     let json_value = yaml_to_json(&value)?;
     Ok(Json(json_value))
 }
@@ -94,7 +110,7 @@ async fn get_config_value(
     Path(path): Path<String>,
 ) -> Result<Json<ConfigValue>, ApiError> {
     let path_parts: Vec<&str> = path.split('.').collect();
-    let value = config.get_value(&path_parts)?;
+    let value = config.get_redacted_value(&path_parts)?;
     let json_value = yaml_to_json(&value)?;
 
     Ok(Json(ConfigValue {
@@ -120,7 +136,15 @@ async fn update_config_value(
     let path_parts: Vec<&str> = request.path.split('.').collect();
     let yaml_value = json_to_yaml(&request.value)?;
 
-    config.set_value(&path_parts, yaml_value)?;
+    // Les secrets passent par set_secret pour être chiffrés dès l'écriture
+    match (path_parts.split_first(), &yaml_value) {
+        (Some((&section, rest)), Value::String(secret))
+            if section.eq_ignore_ascii_case(SECRETS_SECTION) && !rest.is_empty() =>
+        {
+            config.set_secret(rest, secret)?
+        }
+        _ => config.set_value(&path_parts, yaml_value)?,
+    }
 
     Ok(Json(UpdateConfigResponse {
         success: true,
@@ -128,6 +152,84 @@ async fn update_config_value(
     }))
 }
 
+fn profiles_response(config: &Config) -> Result<ProfilesResponse, ApiError> {
+    Ok(ProfilesResponse {
+        default: config.get_default_profile()?,
+        profiles: config.get_profiles()?,
+    })
+}
+
+/// GET /api/profiles - Lister les profils
+#[utoipa::path(
+    get,
+    path = "/api/profiles",
+    tag = "profiles",
+    responses(
+        (status = 200, description = "Profils déclarés", body = ProfilesResponse)
+    )
+)]
+async fn list_profiles(
+    State(config): State<Arc<Config>>,
+) -> Result<Json<ProfilesResponse>, ApiError> {
+    Ok(Json(profiles_response(&config)?))
+}
+
+/// POST /api/profiles - Créer un profil
+#[utoipa::path(
+    post,
+    path = "/api/profiles",
+    tag = "profiles",
+    request_body = ProfileRequest,
+    responses(
+        (status = 200, description = "Profil créé", body = ProfilesResponse)
+    )
+)]
+async fn add_profile(
+    State(config): State<Arc<Config>>,
+    Json(request): Json<ProfileRequest>,
+) -> Result<Json<ProfilesResponse>, ApiError> {
+    config.add_profile(&request.name)?;
+    Ok(Json(profiles_response(&config)?))
+}
+
+/// PUT /api/profiles/default - Changer le profil par défaut
+#[utoipa::path(
+    put,
+    path = "/api/profiles/default",
+    tag = "profiles",
+    request_body = ProfileRequest,
+    responses(
+        (status = 200, description = "Profil par défaut changé", body = ProfilesResponse)
+    )
+)]
+async fn set_default_profile(
+    State(config): State<Arc<Config>>,
+    Json(request): Json<ProfileRequest>,
+) -> Result<Json<ProfilesResponse>, ApiError> {
+    config.set_default_profile(&request.name)?;
+    Ok(Json(profiles_response(&config)?))
+}
+
+/// DELETE /api/profiles/{name} - Supprimer un profil
+#[utoipa::path(
+    delete,
+    path = "/api/profiles/{name}",
+    tag = "profiles",
+    params(
+        ("name" = String, Path, description = "Nom du profil")
+    ),
+    responses(
+        (status = 200, description = "Profil supprimé", body = ProfilesResponse)
+    )
+)]
+async fn remove_profile(
+    State(config): State<Arc<Config>>,
+    Path(name): Path<String>,
+) -> Result<Json<ProfilesResponse>, ApiError> {
+    config.remove_profile(&name)?;
+    Ok(Json(profiles_response(&config)?))
+}
+
 /// Convertit une valeur YAML en JSON
 fn yaml_to_json(yaml: &Value) -> Result<JsonValue, ApiError> {
     // Serialize YAML to string then parse as JSON
@@ -148,5 +250,8 @@ pub fn create_router(config: Arc<Config>) -> Router {
         .route("/api/config", get(get_full_config))
         .route("/api/config", post(update_config_value))
         .route("/api/config/:path", get(get_config_value))
+        .route("/api/profiles", get(list_profiles).post(add_profile))
+        .route("/api/profiles/default", put(set_default_profile))
+        .route("/api/profiles/{name}", delete(remove_profile))
         .with_state(config)
 }
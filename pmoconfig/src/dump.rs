@@ -0,0 +1,336 @@
+//! Export, import et dump de la configuration effective
+//!
+//! Pour diagnostiquer un « il ignore mon réglage », [`Config::dump_effective`]
+//! liste chaque valeur effective avec sa provenance : valeur par défaut
+//! intégrée, fichier `config.yaml`, variable `PMOMUSIC_CONFIG__*` ou
+//! modification faite à l'exécution. Les secrets sont masqués.
+//!
+//! [`Config::export_file`] écrit la configuration courante dans un fichier ;
+//! [`Config::import_file`] remplace la configuration par un autre fichier
+//! après l'avoir validé contre la configuration par défaut.
+
+use crate::{Config, DEFAULT_CONFIG, ENV_PREFIX};
+use anyhow::{anyhow, Result};
+use serde_yaml::Value;
+use std::{collections::BTreeMap, env, fmt, fs, path::Path};
+
+/// Origine d'une valeur de configuration
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum Provenance {
+    /// Configuration par défaut intégrée au binaire
+    Default,
+    /// Fichier `config.yaml`
+    File,
+    /// Variable d'environnement (nom complet)
+    Env(String),
+    /// Modifiée en mémoire depuis le chargement (API, setter…)
+    Runtime,
+}
+
+impl fmt::Display for Provenance {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            Provenance::Default => write!(f, "default"),
+            Provenance::File => write!(f, "file"),
+            Provenance::Env(var) => write!(f, "env {}", var),
+            Provenance::Runtime => write!(f, "runtime"),
+        }
+    }
+}
+
+/// Valeur effective d'une clé feuille de la configuration
+#[derive(Debug, Clone)]
+pub struct EffectiveEntry {
+    /// Chemin pointé (ex: `host.http_port`)
+    pub path: String,
+    /// Valeur effective, secrets masqués
+    pub value: Value,
+    pub provenance: Provenance,
+}
+
+/// Aplatit un arbre YAML en `chemin.pointé → valeur`
+///
+/// Les séquences et les mappings vides sont des feuilles.
+fn flatten(value: &Value, prefix: &str, out: &mut BTreeMap<String, Value>) {
+    match value {
+        Value::Mapping(map) if !map.is_empty() => {
+            for (k, v) in map {
+                let key = match k {
+                    Value::String(s) => s.clone(),
+                    other => serde_yaml::to_string(other)
+                        .unwrap_or_default()
+                        .trim()
+                        .to_string(),
+                };
+                let path = if prefix.is_empty() {
+                    key
+                } else {
+                    format!("{}.{}", prefix, key)
+                };
+                flatten(v, &path, out);
+            }
+        }
+        _ => {
+            out.insert(prefix.to_string(), value.clone());
+        }
+    }
+}
+
+fn flattened(value: &Value) -> BTreeMap<String, Value> {
+    let mut out = BTreeMap::new();
+    flatten(value, "", &mut out);
+    out
+}
+
+/// Variables `PMOMUSIC_CONFIG__*` indexées par chemin pointé
+fn env_override_paths() -> BTreeMap<String, String> {
+    env::vars()
+        .filter_map(|(key, _)| {
+            let path = key
+                .strip_prefix(ENV_PREFIX)?
+                .split("__")
+                .map(str::to_lowercase)
+                .collect::<Vec<_>>()
+                .join(".");
+            Some((path, key))
+        })
+        .collect()
+}
+
+/// Représentation compacte d'une valeur sur une ligne
+fn inline_value(value: &Value) -> String {
+    match value {
+        Value::String(s) => format!("{:?}", s),
+        _ => serde_json::to_string(value).unwrap_or_else(|_| "?".to_string()),
+    }
+}
+
+/// Deux valeurs sont compatibles si la seconde peut remplacer la première
+fn compatible(default: &Value, imported: &Value) -> bool {
+    match (default, imported) {
+        (_, Value::Null) => true,
+        (Value::Bool(_), Value::Bool(_)) => true,
+        (Value::Number(_), Value::Number(_)) => true,
+        (Value::Number(_), Value::String(s)) => s.trim().parse::<f64>().is_ok(),
+        (Value::String(_), Value::String(_) | Value::Number(_) | Value::Bool(_)) => true,
+        // Les listes acceptent aussi une chaîne séparée par des virgules
+        (Value::Sequence(_), Value::Sequence(_) | Value::String(_)) => true,
+        (Value::Mapping(_), Value::Mapping(_)) => true,
+        _ => false,
+    }
+}
+
+/// Valide un document de configuration avant import
+///
+/// Vérifie que le document est un mapping, que les clés connues gardent le
+/// type de la configuration par défaut et que le port HTTP est utilisable.
+/// Toutes les erreurs sont rapportées d'un coup.
+pub fn validate(document: &Value) -> Result<()> {
+    if !matches!(document, Value::Mapping(_)) {
+        return Err(anyhow!("Configuration must be a YAML mapping"));
+    }
+    let document = Config::lower_keys_value(document.clone());
+    let defaults: Value = serde_yaml::from_str(DEFAULT_CONFIG)?;
+    let mut errors = Vec::new();
+
+    check_types(&defaults, &document, "", &mut errors);
+
+    if let Some(port) = document.get("host").and_then(|h| h.get("http_port")) {
+        let valid = match port {
+            Value::Number(n) => n.as_u64().is_some_and(|p| (1..=65535).contains(&p)),
+            Value::String(s) => s.trim().parse::<u16>().is_ok_and(|p| p > 0),
+            _ => false,
+        };
+        if !valid {
+            errors.push(format!(
+                "host.http_port: {} is not a valid port",
+                inline_value(port)
+            ));
+        }
+    }
+
+    if errors.is_empty() {
+        Ok(())
+    } else {
+        Err(anyhow!("Invalid configuration:\n  - {}", errors.join("\n  - ")))
+    }
+}
+
+fn check_types(default: &Value, imported: &Value, path: &str, errors: &mut Vec<String>) {
+    if !compatible(default, imported) {
+        errors.push(format!(
+            "{}: expected a value like {}, got {}",
+            path,
+            inline_value(default),
+            inline_value(imported)
+        ));
+        return;
+    }
+    if let (Value::Mapping(dmap), Value::Mapping(imap)) = (default, imported) {
+        for (k, v) in imap {
+            if let Some(dv) = dmap.get(k) {
+                let key = k.as_str().unwrap_or_default();
+                let child = if path.is_empty() {
+                    key.to_string()
+                } else {
+                    format!("{}.{}", path, key)
+                };
+                check_types(dv, v, &child, errors);
+            }
+        }
+    }
+}
+
+impl Config {
+    /// Chemin du fichier `config.yaml`
+    pub fn config_file_path(&self) -> &str {
+        &self.path
+    }
+
+    /// Configuration courante au format YAML, secrets masqués
+    pub fn dump(&self) -> Result<String> {
+        Ok(serde_yaml::to_string(&self.get_redacted_value(&[])?)?)
+    }
+
+    /// Valeurs effectives de toutes les clés feuilles avec leur provenance
+    pub fn effective_entries(&self) -> Result<Vec<EffectiveEntry>> {
+        let defaults = flattened(&Self::lower_keys_value(serde_yaml::from_str(DEFAULT_CONFIG)?));
+        let file = match fs::read(&self.path) {
+            Ok(data) => flattened(&Self::lower_keys_value(serde_yaml::from_slice(&data)?)),
+            Err(_) => BTreeMap::new(),
+        };
+        let env_paths = env_override_paths();
+        let current = flattened(&self.get_redacted_value(&[])?);
+        let raw = flattened(&self.get_value(&[])?);
+
+        let entries = current
+            .into_iter()
+            .map(|(path, value)| {
+                let effective = raw.get(&path);
+                let provenance = if let Some(var) = env_paths.get(&path) {
+                    Provenance::Env(var.clone())
+                } else if defaults.get(&path) == effective && file.get(&path) == effective {
+                    // Le chargement réécrit les défauts dans le fichier
+                    Provenance::Default
+                } else if file.get(&path) == effective {
+                    Provenance::File
+                } else if !file.contains_key(&path) && defaults.get(&path) == effective {
+                    Provenance::Default
+                } else {
+                    Provenance::Runtime
+                };
+                EffectiveEntry {
+                    path,
+                    value,
+                    provenance,
+                }
+            })
+            .collect();
+        Ok(entries)
+    }
+
+    /// Dump lisible de la configuration effective, annoté par provenance
+    ///
+    /// # Examples
+    ///
+    /// ```no_run
+    /// use pmoconfig::get_config;
+    ///
+    /// print!("{}", get_config().dump_effective()?);
+    /// # Ok::<(), anyhow::Error>(())
+    /// ```
+    pub fn dump_effective(&self) -> Result<String> {
+        let entries = self.effective_entries()?;
+        let width = entries.iter().map(|e| e.path.len()).max().unwrap_or(0);
+        let mut out = format!(
+            "# config file: {}{}\n",
+            self.path,
+            if self.read_only { " (read-only)" } else { "" }
+        );
+        for entry in entries {
+            out.push_str(&format!(
+                "{:width$} = {}  # {}\n",
+                entry.path,
+                inline_value(&entry.value),
+                entry.provenance,
+                width = width
+            ));
+        }
+        Ok(out)
+    }
+
+    /// Exporte la configuration courante dans `path`
+    ///
+    /// Le contenu est celui qu'écrirait [`Config::save`] : les secrets y sont
+    /// chiffrés avec la clé de cette machine et ne sont donc relisibles
+    /// qu'ici ; les indirections `env:` restent portables.
+    pub fn export_file(&self, path: &Path) -> Result<()> {
+        let data = crate::secrets::seal_for_save(&self.snapshot());
+        fs::write(path, serde_yaml::to_string(&data)?)?;
+        Ok(())
+    }
+
+    /// Importe un fichier de configuration après validation
+    ///
+    /// Le document est fusionné avec la configuration par défaut, les
+    /// variables d'environnement sont réappliquées, puis le résultat remplace
+    /// la configuration courante et est enregistré. En cas d'erreur de
+    /// validation, rien n'est modifié.
+    pub fn import_file(&self, path: &Path) -> Result<()> {
+        if self.read_only {
+            return Err(anyhow!("Configuration is read-only, import refused"));
+        }
+        let document: Value = serde_yaml::from_slice(&fs::read(path)?)?;
+        validate(&document)?;
+
+        let mut merged: Value = serde_yaml::from_str(DEFAULT_CONFIG)?;
+        crate::merge_yaml(&mut merged, &document);
+        let mut merged = Self::lower_keys_value(merged);
+        Self::apply_env_overrides(&mut merged);
+
+        self.update(|data| {
+            *data = merged;
+            Ok(())
+        })?;
+        tracing::info!(source=%path.display(), config_file=%self.path, "Configuration imported");
+        Ok(())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::secrets;
+
+    #[test]
+    fn test_validate_import() {
+        let ok: Value =
+            serde_yaml::from_str("host:\n  http_port: 9000\n  cors:\n    enabled: true\n").unwrap();
+        assert!(validate(&ok).is_ok());
+
+        let bad: Value = serde_yaml::from_str(
+            "host:\n  http_port: 70000\n  cors:\n    enabled: \"yes\"\n    origins: 3\n",
+        )
+        .unwrap();
+        let err = validate(&bad).unwrap_err().to_string();
+        assert!(err.contains("host.http_port"), "{}", err);
+        assert!(err.contains("host.cors.enabled"), "{}", err);
+        assert!(err.contains("host.cors.origins"), "{}", err);
+
+        assert!(validate(&Value::String("nope".into())).is_err());
+    }
+
+    #[test]
+    fn test_effective_entries_redact_secrets() {
+        let config = Config::in_memory(
+            serde_yaml::from_str("secrets:\n  mqtt:\n    password: hunter2\n").unwrap(),
+        );
+        let entries = config.effective_entries().unwrap();
+        let entry = entries
+            .iter()
+            .find(|e| e.path == "secrets.mqtt.password")
+            .unwrap();
+        assert_eq!(entry.value, Value::String(secrets::REDACTED.into()));
+        assert_eq!(entry.provenance, Provenance::Runtime);
+    }
+}
@@ -4,8 +4,13 @@
 //! - Loading configuration from YAML files
 //! - Merging with embedded default configuration
 //! - Environment variable overrides
+//! - Environment-only operation (read-only config, no writable file required)
 //! - Type-safe getters and setters for configuration values
-//! - Thread-safe singleton access pattern
+//! - Effective-config dump with provenance, validated import
+//! - Thread-safe singleton access pattern, or explicit injection with
+//!   [`set_config`]
+//! - Snapshot reads, atomic read-modify-write ([`Config::update`]) and
+//!   serialized, atomic saves of `config.yaml`
 //!
 //! ## Usage
 //!
@@ -26,19 +31,32 @@
 
 use anyhow::{anyhow, Result};
 use dirs::home_dir;
-use lazy_static::lazy_static;
 use pmoutils::guess_local_ip;
 use serde_yaml::{Mapping, Number, Value};
 use std::{
     env, fs,
     path::Path,
-    sync::{Arc, Mutex},
+    sync::{
+        Arc, Mutex, OnceLock, RwLock,
+        atomic::{AtomicU64, Ordering},
+    },
 };
 use tracing::info;
 use uuid::Uuid;
 
 // Module de chiffrement des mots de passe
 pub mod encryption;
+// Section `secrets` : indirection d'environnement, chiffrement, masquage
+pub mod secrets;
+// Dump de la configuration effective, export/import
+pub mod dump;
+// Getters typés avec valeur par défaut, durées et tailles avec unités
+pub mod typed;
+// Profils d'écoute nommés
+pub mod profiles;
+
+pub use profiles::DEFAULT_PROFILE;
+pub use typed::{parse_duration, parse_size};
 
 // Modules conditionnels pour l'API REST
 #[cfg(feature = "api")]
@@ -52,29 +70,42 @@ pub use openapi::ApiDoc;
 // Configuration par défaut intégrée
 const DEFAULT_CONFIG: &str = include_str!("pmomusic.yaml");
 
-lazy_static! {
-    static ref CONFIG: Arc<Config> =
-        Arc::new(Config::load_config("").expect("Failed to load PMOMusic configuration"));
-}
+static CONFIG: OnceLock<Arc<Config>> = OnceLock::new();
 
 const ENV_CONFIG_DIR: &str = "PMOMUSIC_CONFIG";
 const ENV_PREFIX: &str = "PMOMUSIC_CONFIG__";
+const ENV_READONLY: &str = "PMOMUSIC_CONFIG_READONLY";
 
 // Default values for configuration
 const DEFAULT_HTTP_PORT: u16 = 8080;
+const DEFAULT_IPV6_ENABLED: bool = false;
 const DEFAULT_LOG_BUFFER_CAPACITY: usize = 1000;
 const DEFAULT_LOG_MIN_LEVEL: &str = "TRACE";
 const DEFAULT_LOG_ENABLE_CONSOLE: bool = true;
+const DEFAULT_LOG_JOURNALD: &str = "auto";
+const DEFAULT_LOG_SYSLOG_ENABLED: bool = false;
+const DEFAULT_LOG_SYSLOG_FACILITY: &str = "daemon";
+const DEFAULT_CORS_ENABLED: bool = false;
+const DEFAULT_CORS_ALLOW_CREDENTIALS: bool = false;
+const DEFAULT_CORS_MAX_AGE_SECS: usize = 600;
+const DEFAULT_CORS_METHODS: &[&str] = &["GET", "POST", "PUT", "DELETE", "OPTIONS"];
+const DEFAULT_CORS_HEADERS: &[&str] = &["content-type", "authorization"];
+const DEFAULT_LIMITS_MAX_BODY_BYTES: usize = 256 * 1024;
+const DEFAULT_LIMITS_HEADER_READ_TIMEOUT_SECS: usize = 10;
+const DEFAULT_LIMITS_REQUEST_TIMEOUT_SECS: usize = 30;
+const DEFAULT_LIMITS_MAX_CONCURRENT_REQUESTS: usize = 64;
+const DEFAULT_HTTP10_ENABLED: bool = false;
+const DEFAULT_COMPRESSION_ENABLED: bool = true;
+const DEFAULT_COMPRESSION_MIN_BYTES: usize = 1024;
+const DEFAULT_REMOTE_ENABLED: bool = false;
+const DEFAULT_REMOTE_PORT: usize = 8443;
+const DEFAULT_REMOTE_ALLOWED_PATHS: &[&str] = &["/", "/app", "/api/", "/transcode", "/covers/"];
 
 /// Macro to generate getter/setter for usize values with default
 macro_rules! impl_usize_config {
     ($getter:ident, $setter:ident, $path:expr, $default:expr) => {
         pub fn $getter(&self) -> Result<usize> {
-            match self.get_value($path)? {
-                Value::Number(n) if n.is_i64() => Ok(n.as_i64().unwrap() as usize),
-                Value::Number(n) if n.is_u64() => Ok(n.as_u64().unwrap() as usize),
-                _ => Ok($default),
-            }
+            Ok(self.get_uint($path, $default as u64)? as usize)
         }
 
         pub fn $setter(&self, size: usize) -> Result<()> {
@@ -88,10 +119,7 @@ macro_rules! impl_usize_config {
 macro_rules! impl_bool_config {
     ($getter:ident, $setter:ident, $path:expr, $default:expr) => {
         pub fn $getter(&self) -> Result<bool> {
-            match self.get_value($path)? {
-                Value::Bool(b) => Ok(b),
-                _ => Ok($default),
-            }
+            self.get_bool($path, $default)
         }
 
         pub fn $setter(&self, value: bool) -> Result<()> {
@@ -108,6 +136,15 @@ macro_rules! impl_bool_config {
 /// - Handling environment variable overrides
 /// - Providing typed getters/setters for configuration values
 ///
+/// # Concurrency
+///
+/// The tree is held behind an `Arc` that writers replace as a whole: a
+/// reader gets a consistent [`snapshot`](Self::snapshot) without blocking
+/// writers for longer than a pointer copy, and never observes a
+/// half-applied change. Writers are serialized, and each change is saved
+/// while holding a dedicated lock so concurrent saves cannot interleave or
+/// overwrite a newer state with an older one.
+///
 /// # Examples
 ///
 /// ```no_run
@@ -117,21 +154,41 @@ macro_rules! impl_bool_config {
 /// let port = config.get_http_port();
 /// println!("HTTP port: {}", port);
 /// ```
-#[derive(Debug)]
 pub struct Config {
     config_dir: String,
     path: String,
-    data: Mutex<Value>,
+    read_only: bool,
+    /// Current tree, replaced as a whole by writers
+    data: RwLock<Arc<Value>>,
+    /// Serializes writes of `config.yaml`
+    save_lock: Mutex<()>,
+    /// Number of changes applied to the tree since loading
+    generation: AtomicU64,
+}
+
+// Debug manuel : les secrets ne doivent jamais apparaître dans les logs
+impl std::fmt::Debug for Config {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        let data = secrets::redact(&[], &self.snapshot());
+        f.debug_struct("Config")
+            .field("config_dir", &self.config_dir)
+            .field("path", &self.path)
+            .field("read_only", &self.read_only)
+            .field("data", &data)
+            .finish()
+    }
 }
 
 // Implémentation manuelle de Clone
 impl Clone for Config {
     fn clone(&self) -> Self {
-        let data = self.data.lock().unwrap().clone();
         Self {
             config_dir: self.config_dir.clone(),
             path: self.path.clone(),
-            data: Mutex::new(data),
+            read_only: self.read_only,
+            data: RwLock::new(self.snapshot()),
+            save_lock: Mutex::new(()),
+            generation: AtomicU64::new(self.generation()),
         }
     }
 }
@@ -213,6 +270,19 @@ impl Config {
         dir_path
     }
 
+    /// Indicates whether read-only (environment-only) mode was requested
+    ///
+    /// Set `PMOMUSIC_CONFIG_READONLY` to `1`, `true` or `yes` to run without
+    /// a writable config file (typical for containers with a read-only root
+    /// filesystem). All settings then come from the embedded defaults, an
+    /// optional existing `config.yaml` and `PMOMUSIC_CONFIG__*` variables.
+    fn read_only_requested() -> bool {
+        match env::var(ENV_READONLY) {
+            Ok(v) => matches!(v.trim().to_lowercase().as_str(), "1" | "true" | "yes" | "on"),
+            Err(_) => false,
+        }
+    }
+
     /// Loads the configuration from the specified directory
     ///
     /// This method:
@@ -220,7 +290,11 @@ impl Config {
     /// 2. Loads the default embedded configuration
     /// 3. Merges it with the external config.yaml file if present
     /// 4. Applies environment variable overrides
-    /// 5. Saves the merged configuration
+    /// 5. Saves the merged configuration (unless running read-only)
+    ///
+    /// If the configuration directory is not writable, the configuration
+    /// falls back to read-only mode instead of failing: values are kept in
+    /// memory and never written back.
     ///
     /// # Arguments
     ///
@@ -231,8 +305,23 @@ impl Config {
     /// Returns a `Result` containing the loaded `Config` or an error
     pub fn load_config(directory: &str) -> Result<Self> {
         // Obtenir le répertoire de configuration
-        let config_dir = Self::config_dir(directory);
-        info!(config_dir=%config_dir, "Using config directory");
+        let config_dir = Self::find_config_dir(directory);
+        let mut read_only = Self::read_only_requested();
+        if read_only {
+            info!(
+                env_var = ENV_READONLY,
+                "Read-only configuration requested, config file will not be written"
+            );
+        } else if let Err(e) = Self::validate_config_dir(Path::new(&config_dir)) {
+            tracing::warn!(
+                config_dir=%config_dir,
+                error=%e,
+                "Config directory is not writable, falling back to read-only configuration \
+                (use PMOMUSIC_CONFIG__* environment variables to configure PMOMusic)"
+            );
+            read_only = true;
+        }
+        info!(config_dir=%config_dir, read_only, "Using config directory");
 
         // Construire le chemin du fichier config.yaml
         let config_file_path = Path::new(&config_dir).join("config.yaml");
@@ -262,7 +351,10 @@ impl Config {
         let config = Config {
             config_dir,
             path,
-            data: Mutex::new(config_value),
+            read_only,
+            data: RwLock::new(Arc::new(config_value)),
+            save_lock: Mutex::new(()),
+            generation: AtomicU64::new(0),
         };
 
         // Sauvegarder la configuration
@@ -270,18 +362,123 @@ impl Config {
         Ok(config)
     }
 
+    /// Builds an in-memory configuration from the embedded defaults
+    ///
+    /// `PMOMUSIC_CONFIG__*` environment overrides are applied. No directory
+    /// is looked up or created and nothing is ever written to disk.
+    pub fn from_defaults() -> Self {
+        let default_value: Value =
+            serde_yaml::from_str(DEFAULT_CONFIG).expect("embedded default config is valid YAML");
+        let mut config_value = Self::lower_keys_value(default_value);
+        Self::apply_env_overrides(&mut config_value);
+
+        Config {
+            config_dir: String::new(),
+            path: String::new(),
+            read_only: true,
+            data: RwLock::new(Arc::new(config_value)),
+            save_lock: Mutex::new(()),
+            generation: AtomicU64::new(0),
+        }
+    }
+
+    /// Builds a read-only configuration around a given tree (tests)
+    #[cfg(test)]
+    pub(crate) fn in_memory(data: Value) -> Self {
+        Config {
+            config_dir: String::new(),
+            path: String::new(),
+            read_only: true,
+            data: RwLock::new(Arc::new(data)),
+            save_lock: Mutex::new(()),
+            generation: AtomicU64::new(0),
+        }
+    }
+
+    /// Returns `true` if the configuration is never written to disk
+    pub fn is_read_only(&self) -> bool {
+        self.read_only
+    }
+
+    /// Returns a consistent snapshot of the whole configuration tree
+    ///
+    /// The snapshot is immutable: changes made afterwards are not reflected
+    /// in it.
+    pub fn snapshot(&self) -> Arc<Value> {
+        Arc::clone(&self.data.read().unwrap())
+    }
+
+    /// Returns a counter incremented by every change to the configuration
+    ///
+    /// Values derived from the configuration can be cached together with the
+    /// generation they were computed for, and recomputed once it moves.
+    pub fn generation(&self) -> u64 {
+        self.generation.load(Ordering::Acquire)
+    }
+
     /// Saves the current configuration to the config.yaml file
     ///
+    /// In read-only mode this is a no-op: changes stay in memory only.
+    /// Clear-text values of the `secrets` section are encrypted on the way
+    /// out when `secrets.encrypt_at_rest` is enabled.
+    ///
+    /// Saves are serialized and always write the latest tree, through a
+    /// temporary file renamed over `config.yaml`, so the file is never left
+    /// half-written.
+    ///
     /// # Returns
     ///
     /// Returns a `Result` indicating success or failure
     pub fn save(&self) -> Result<()> {
-        let data = self.data.lock().unwrap();
-        let yaml = serde_yaml::to_string(&*data)?;
-        fs::write(&self.path, yaml)?;
+        if self.read_only {
+            tracing::debug!(config_file=%self.path, "Read-only configuration, skipping save");
+            return Ok(());
+        }
+        let _guard = self.save_lock.lock().unwrap();
+        let data = secrets::seal_for_save(&self.snapshot());
+        let yaml = serde_yaml::to_string(&data)?;
+        let tmp = format!("{}.tmp", self.path);
+        fs::write(&tmp, yaml)?;
+        fs::rename(&tmp, &self.path)?;
         Ok(())
     }
 
+    /// Atomically reads, modifies and saves the configuration
+    ///
+    /// `update` works on a private copy of the tree while other writers
+    /// wait. The copy replaces the current tree only if `update` succeeds,
+    /// so a failed change leaves nothing behind. Use it whenever a change
+    /// depends on the current value, to avoid losing a concurrent update
+    /// between a [`get_value`](Self::get_value) and a
+    /// [`set_value`](Self::set_value).
+    ///
+    /// # Examples
+    ///
+    /// ```no_run
+    /// use pmoconfig::get_config;
+    /// use serde_yaml::Value;
+    ///
+    /// // Incrémente un compteur sans perdre d'écriture concurrente
+    /// let runs = get_config().update(|data| {
+    ///     let runs = data["host"]["runs"].as_u64().unwrap_or(0) + 1;
+    ///     data["host"]["runs"] = Value::from(runs);
+    ///     Ok(runs)
+    /// })?;
+    /// # Ok::<(), anyhow::Error>(())
+    /// ```
+    pub fn update<T>(&self, update: impl FnOnce(&mut Value) -> Result<T>) -> Result<T> {
+        let result = {
+            let mut data = self.data.write().unwrap();
+            let mut next = Value::clone(&data);
+            let result = update(&mut next)?;
+            *data = Arc::new(next);
+            self.generation.fetch_add(1, Ordering::AcqRel);
+            result
+        };
+        self.save()?;
+        Ok(result)
+    }
+
     /// Sets a configuration value at the specified path and saves it
     ///
     /// # Arguments
@@ -293,11 +490,7 @@ impl Config {
     ///
     /// Returns a `Result` indicating success or failure
     pub fn set_value(&self, path: &[&str], value: Value) -> Result<()> {
-        let mut data = self.data.lock().unwrap();
-        Self::set_value_internal(&mut data, path, value.clone())?;
-        drop(data);
-        self.save()?;
-        Ok(())
+        self.update(|data| Self::set_value_internal(data, path, value))
     }
 
     fn set_value_internal(data: &mut Value, path: &[&str], value: Value) -> Result<()> {
@@ -332,8 +525,7 @@ impl Config {
     ///
     /// Returns a `Result` containing the YAML value or an error if the path doesn't exist
     pub fn get_value(&self, path: &[&str]) -> Result<Value> {
-        let data = self.data.lock().unwrap();
-        Self::get_value_internal(&data, path)
+        Self::get_value_internal(&self.snapshot(), path)
     }
 
     fn get_value_internal(data: &Value, path: &[&str]) -> Result<Value> {
@@ -503,29 +695,16 @@ impl Config {
     ///
     /// The HTTP port as a u16
     pub fn get_http_port(&self) -> u16 {
-        match self.get_value(&["host", "http_port"]) {
-            Ok(Value::Number(n)) if n.is_i64() => n.as_i64().unwrap() as u16,
-            Ok(Value::String(s)) => match s.parse::<u16>() {
-                Ok(port) => port,
-                Err(_) => {
-                    tracing::warn!(
-                        "Invalid HTTP port '{}', using default {}",
-                        s,
-                        DEFAULT_HTTP_PORT
-                    );
-                    DEFAULT_HTTP_PORT
-                }
-            },
-            Ok(_) => {
-                tracing::warn!(
-                    "HTTP port not a number or string, using default {}",
-                    DEFAULT_HTTP_PORT
-                );
-                DEFAULT_HTTP_PORT
-            }
+        let port = self
+            .get_int(&["host", "http_port"], DEFAULT_HTTP_PORT as i64)
+            .and_then(|port| {
+                u16::try_from(port).map_err(|_| anyhow!("host.http_port: {} out of range", port))
+            });
+        match port {
+            Ok(port) => port,
             Err(err) => {
                 tracing::warn!(
-                    "Failed to get HTTP port: {}, using default {}",
+                    "Invalid HTTP port: {}, using default {}",
                     err,
                     DEFAULT_HTTP_PORT
                 );
@@ -548,6 +727,13 @@ impl Config {
         self.set_value(&["host", "http_port"], Value::Number(n))
     }
 
+    impl_bool_config!(
+        get_ipv6_enabled,
+        set_ipv6_enabled,
+        &["host", "ipv6"],
+        DEFAULT_IPV6_ENABLED
+    );
+
     /// Gets the UDN (Unique Device Name) for a device, generating one if it doesn't exist
     ///
     /// # Arguments
@@ -559,19 +745,24 @@ impl Config {
     ///
     /// Returns a `Result` containing the UDN string, generating a new UUID if not found
     pub fn get_device_udn(&self, devtype: &str, name: &str) -> Result<String> {
+        fn sanitize(udn: &str) -> String {
+            let udn_str = udn.trim();
+            udn_str.strip_prefix("uuid:").unwrap_or(udn_str).to_string()
+        }
+
         let path = &["devices", devtype, name, "udn"];
-        match self.get_value(path) {
-            Ok(Value::String(udn)) => {
-                let udn_str = udn.trim();
-                let sanitized = udn_str.strip_prefix("uuid:").unwrap_or(udn_str).to_string();
-                Ok(sanitized)
-            }
+        if let Ok(Value::String(udn)) = self.get_value(path) {
+            return Ok(sanitize(&udn));
+        }
+        // Création atomique : deux appels concurrents obtiennent le même UDN
+        self.update(|data| match Self::get_value_internal(data, path) {
+            Ok(Value::String(udn)) => Ok(sanitize(&udn)),
             _ => {
                 let new_udn = Uuid::new_v4().to_string();
-                self.set_value(path, Value::String(new_udn.clone()))?;
+                Self::set_value_internal(data, path, Value::String(new_udn.clone()))?;
                 Ok(new_udn)
             }
-        }
+        })
     }
 
     /// Sets the UDN (Unique Device Name) for a device
@@ -617,6 +808,206 @@ impl Config {
     pub fn set_log_min_level(&self, level: String) -> Result<()> {
         self.set_value(&["host", "logger", "min_level"], Value::String(level))
     }
+
+    /// Sortie journald : `auto` (si lancé par systemd), `true` ou `false`
+    pub fn get_log_journald(&self) -> Result<String> {
+        match self.get_value(&["host", "logger", "journald"]) {
+            Ok(Value::Bool(b)) => Ok(b.to_string()),
+            Ok(Value::String(s)) => Ok(s.trim().to_lowercase()),
+            _ => Ok(DEFAULT_LOG_JOURNALD.to_string()),
+        }
+    }
+
+    /// Définit le mode de sortie journald (`auto`, `true` ou `false`)
+    pub fn set_log_journald(&self, mode: String) -> Result<()> {
+        self.set_value(&["host", "logger", "journald"], Value::String(mode))
+    }
+
+    impl_bool_config!(
+        get_log_syslog_enabled,
+        set_log_syslog_enabled,
+        &["host", "logger", "syslog", "enabled"],
+        DEFAULT_LOG_SYSLOG_ENABLED
+    );
+
+    /// Adresse du démon syslog : vide pour le socket local (`/dev/log`),
+    /// un chemin de socket Unix, ou `udp://hôte:port`
+    pub fn get_log_syslog_address(&self) -> Result<String> {
+        match self.get_value(&["host", "logger", "syslog", "address"]) {
+            Ok(Value::String(s)) => Ok(s.trim().to_string()),
+            _ => Ok(String::new()),
+        }
+    }
+
+    /// Définit l'adresse du démon syslog
+    pub fn set_log_syslog_address(&self, address: String) -> Result<()> {
+        self.set_value(
+            &["host", "logger", "syslog", "address"],
+            Value::String(address),
+        )
+    }
+
+    /// Facility syslog (`daemon`, `user`, `local0`..`local7`)
+    pub fn get_log_syslog_facility(&self) -> Result<String> {
+        match self.get_value(&["host", "logger", "syslog", "facility"]) {
+            Ok(Value::String(s)) => Ok(s.trim().to_lowercase()),
+            _ => Ok(DEFAULT_LOG_SYSLOG_FACILITY.to_string()),
+        }
+    }
+
+    /// Définit la facility syslog
+    pub fn set_log_syslog_facility(&self, facility: String) -> Result<()> {
+        self.set_value(
+            &["host", "logger", "syslog", "facility"],
+            Value::String(facility),
+        )
+    }
+
+    impl_bool_config!(
+        get_cors_enabled,
+        set_cors_enabled,
+        &["host", "cors", "enabled"],
+        DEFAULT_CORS_ENABLED
+    );
+
+    impl_bool_config!(
+        get_cors_allow_credentials,
+        set_cors_allow_credentials,
+        &["host", "cors", "allow_credentials"],
+        DEFAULT_CORS_ALLOW_CREDENTIALS
+    );
+
+    impl_usize_config!(
+        get_cors_max_age_secs,
+        set_cors_max_age_secs,
+        &["host", "cors", "max_age_secs"],
+        DEFAULT_CORS_MAX_AGE_SECS
+    );
+
+    impl_usize_config!(
+        get_limits_max_body_bytes,
+        set_limits_max_body_bytes,
+        &["host", "limits", "max_body_bytes"],
+        DEFAULT_LIMITS_MAX_BODY_BYTES
+    );
+
+    impl_usize_config!(
+        get_limits_header_read_timeout_secs,
+        set_limits_header_read_timeout_secs,
+        &["host", "limits", "header_read_timeout_secs"],
+        DEFAULT_LIMITS_HEADER_READ_TIMEOUT_SECS
+    );
+
+    impl_usize_config!(
+        get_limits_request_timeout_secs,
+        set_limits_request_timeout_secs,
+        &["host", "limits", "request_timeout_secs"],
+        DEFAULT_LIMITS_REQUEST_TIMEOUT_SECS
+    );
+
+    impl_usize_config!(
+        get_limits_max_concurrent_requests,
+        set_limits_max_concurrent_requests,
+        &["host", "limits", "max_concurrent_requests"],
+        DEFAULT_LIMITS_MAX_CONCURRENT_REQUESTS
+    );
+
+    impl_bool_config!(
+        get_http10_enabled,
+        set_http10_enabled,
+        &["host", "http10", "enabled"],
+        DEFAULT_HTTP10_ENABLED
+    );
+
+    /// Fragments de User-Agent des clients à traiter en HTTP/1.0 même
+    /// s'ils annoncent HTTP/1.1
+    pub fn get_http10_user_agents(&self) -> Result<Vec<String>> {
+        Ok(self.get_string_list(&["host", "http10", "user_agents"], &[]))
+    }
+
+    impl_bool_config!(
+        get_compression_enabled,
+        set_compression_enabled,
+        &["host", "compression", "enabled"],
+        DEFAULT_COMPRESSION_ENABLED
+    );
+
+    impl_usize_config!(
+        get_compression_min_bytes,
+        set_compression_min_bytes,
+        &["host", "compression", "min_bytes"],
+        DEFAULT_COMPRESSION_MIN_BYTES
+    );
+
+    impl_bool_config!(
+        get_remote_enabled,
+        set_remote_enabled,
+        &["host", "remote", "enabled"],
+        DEFAULT_REMOTE_ENABLED
+    );
+
+    impl_usize_config!(
+        get_remote_port,
+        set_remote_port,
+        &["host", "remote", "port"],
+        DEFAULT_REMOTE_PORT
+    );
+
+    /// Certificat TLS (PEM, chaîne complète) de l'accès distant
+    pub fn get_remote_tls_cert(&self) -> Result<String> {
+        match self.get_value(&["host", "remote", "tls_cert"]) {
+            Ok(Value::String(s)) => Ok(s.trim().to_string()),
+            _ => Ok(String::new()),
+        }
+    }
+
+    /// Clé privée TLS (PEM) de l'accès distant
+    pub fn get_remote_tls_key(&self) -> Result<String> {
+        match self.get_value(&["host", "remote", "tls_key"]) {
+            Ok(Value::String(s)) => Ok(s.trim().to_string()),
+            _ => Ok(String::new()),
+        }
+    }
+
+    /// Préfixes de chemins servis par l'accès distant
+    pub fn get_remote_allowed_paths(&self) -> Result<Vec<String>> {
+        Ok(self.get_string_list(
+            &["host", "remote", "allowed_paths"],
+            DEFAULT_REMOTE_ALLOWED_PATHS,
+        ))
+    }
+
+    /// Origines autorisées pour les requêtes cross-origin (`*` = toutes)
+    pub fn get_cors_origins(&self) -> Result<Vec<String>> {
+        Ok(self.get_string_list(&["host", "cors", "origins"], &[]))
+    }
+
+    /// Méthodes HTTP autorisées pour les requêtes cross-origin
+    pub fn get_cors_methods(&self) -> Result<Vec<String>> {
+        Ok(self.get_string_list(&["host", "cors", "methods"], DEFAULT_CORS_METHODS))
+    }
+
+    /// En-têtes autorisés pour les requêtes cross-origin (`*` = tous)
+    pub fn get_cors_headers(&self) -> Result<Vec<String>> {
+        Ok(self.get_string_list(&["host", "cors", "headers"], DEFAULT_CORS_HEADERS))
+    }
+
+    /// Lit une liste de chaînes (séquence YAML ou chaîne séparée par des virgules)
+    fn get_string_list(&self, path: &[&str], default: &[&str]) -> Vec<String> {
+        let items: Vec<String> = match self.get_value(path) {
+            Ok(Value::Sequence(items)) => items
+                .iter()
+                .filter_map(|item| item.as_str().map(str::to_string))
+                .collect(),
+            Ok(Value::String(s)) => s.split(',').map(str::to_string).collect(),
+            _ => return default.iter().map(|s| s.to_string()).collect(),
+        };
+        items
+            .into_iter()
+            .map(|s| s.trim().to_string())
+            .filter(|s| !s.is_empty())
+            .collect()
+    }
 }
 
 /// Returns the global configuration instance
@@ -637,7 +1028,45 @@ impl Config {
 /// let port = config.get_http_port();
 /// ```
 pub fn get_config() -> Arc<Config> {
-    CONFIG.clone()
+    CONFIG
+        .get_or_init(|| {
+            let config = Config::load_config("").unwrap_or_else(|e| {
+                tracing::error!(
+                    error=%e,
+                    "Failed to load PMOMusic configuration, using embedded defaults in memory"
+                );
+                Config::from_defaults()
+            });
+            Arc::new(config)
+        })
+        .clone()
+}
+
+/// Installs the global configuration instance
+///
+/// Lets programs embedding PMOMusic libraries provide their own
+/// configuration (typically [`Config::from_defaults`] tweaked with setters)
+/// instead of the `config.yaml` lookup. Must be called before the first
+/// [`get_config`] call.
+///
+/// # Errors
+///
+/// Fails if the global configuration has already been initialized.
+///
+/// # Examples
+///
+/// ```no_run
+/// use pmoconfig::{Config, set_config};
+///
+/// let config = Config::from_defaults();
+/// config.set_http_port(9000)?;
+/// set_config(config)?;
+/// # Ok::<(), anyhow::Error>(())
+/// ```
+pub fn set_config(config: Config) -> Result<()> {
+    CONFIG
+        .set(Arc::new(config))
+        .map_err(|_| anyhow!("PMOMusic configuration already initialized"))
 }
 
 /// Merges external YAML configuration into default configuration
@@ -665,3 +1094,60 @@ fn merge_yaml(default: &mut Value, external: &Value) {
         (d, e) => *d = e.clone(), // pour les scalaires ou séquences, on remplace
     }
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_concurrent_updates_are_saved() {
+        let dir = env::temp_dir().join(format!("pmoconfig-test-{}", Uuid::new_v4()));
+        fs::create_dir_all(&dir).unwrap();
+        let config = Arc::new(Config {
+            path: dir.join("config.yaml").to_string_lossy().to_string(),
+            read_only: false,
+            ..Config::in_memory(Value::Mapping(Mapping::new()))
+        });
+
+        let writers: Vec<_> = (0..8)
+            .map(|t| {
+                let config = Arc::clone(&config);
+                std::thread::spawn(move || {
+                    for i in 0..20 {
+                        let key = format!("k{}_{}", t, i);
+                        config.set_value(&["test", &key], Value::from(i)).unwrap();
+                        config
+                            .update(|data| {
+                                let n = data["test"]["count"].as_u64().unwrap_or(0);
+                                Config::set_value_internal(
+                                    data,
+                                    &["test", "count"],
+                                    Value::from(n + 1),
+                                )
+                            })
+                            .unwrap();
+                    }
+                })
+            })
+            .collect();
+        for writer in writers {
+            writer.join().unwrap();
+        }
+
+        let saved: Value = serde_yaml::from_slice(&fs::read(&config.path).unwrap()).unwrap();
+        assert_eq!(saved, *config.snapshot());
+        assert_eq!(saved["test"]["count"].as_u64(), Some(160));
+        assert_eq!(saved["test"].as_mapping().unwrap().len(), 161);
+
+        let udns: Vec<_> = (0..4)
+            .map(|_| {
+                let config = Arc::clone(&config);
+                std::thread::spawn(move || config.get_device_udn("mediarenderer", "salon").unwrap())
+            })
+            .collect();
+        let udns: Vec<String> = udns.into_iter().map(|h| h.join().unwrap()).collect();
+        assert!(udns.iter().all(|udn| *udn == udns[0]));
+
+        fs::remove_dir_all(&dir).unwrap();
+    }
+}
@@ -14,16 +14,23 @@ use utoipa::OpenApi;
         crate::api::get_full_config,
         crate::api::get_config_value,
         crate::api::update_config_value,
+        crate::api::list_profiles,
+        crate::api::add_profile,
+        crate::api::set_default_profile,
+        crate::api::remove_profile,
     ),
     components(
         schemas(
             crate::api::ConfigValue,
             crate::api::UpdateConfigRequest,
             crate::api::UpdateConfigResponse,
+            crate::api::ProfilesResponse,
+            crate::api::ProfileRequest,
         )
     ),
     tags(
-        (name = "config", description = "Endpoints de gestion de la configuration")
+        (name = "config", description = "Endpoints de gestion de la configuration"),
+        (name = "profiles", description = "Profils d'écoute (favoris, historique, reprise)")
     )
 )]
 pub struct ApiDoc;
@@ -0,0 +1,184 @@
+//! Profils d'écoute nommés (favoris, historique, reprise de lecture)
+//!
+//! Chaque membre du foyer peut avoir son propre profil. Le client web
+//! choisit le profil actif et l'envoie avec ses requêtes (en-tête
+//! `X-PMO-Profile` ou paramètre `?profile=`). Les lectures lancées depuis
+//! un point de contrôle UPnP tiers, qui ne connaît pas les profils, sont
+//! attribuées au profil par défaut.
+//!
+//! ```yaml
+//! profiles:
+//!   default: default
+//!   names:
+//!     - default
+//!     - alice
+//!     - bob
+//! ```
+
+use crate::Config;
+use anyhow::{anyhow, bail, Result};
+use serde_yaml::Value;
+
+/// Nom de la section des profils
+pub const PROFILES_SECTION: &str = "profiles";
+
+/// Profil utilisé quand aucun n'est configuré ni demandé
+pub const DEFAULT_PROFILE: &str = "default";
+
+/// Longueur maximale d'un nom de profil
+const MAX_PROFILE_NAME_LEN: usize = 32;
+
+/// Vérifie qu'un nom de profil est utilisable (`[a-z0-9_-]`, 32 caractères au plus)
+///
+/// Les noms servent de clés en base et dans les ObjectID du
+/// ContentDirectory, d'où l'alphabet restreint.
+pub fn validate_profile_name(name: &str) -> Result<()> {
+    if name.is_empty() || name.len() > MAX_PROFILE_NAME_LEN {
+        bail!(
+            "Invalid profile name {:?}: expected 1 to {} characters",
+            name,
+            MAX_PROFILE_NAME_LEN
+        );
+    }
+    if !name
+        .chars()
+        .all(|c| c.is_ascii_lowercase() || c.is_ascii_digit() || c == '_' || c == '-')
+    {
+        bail!(
+            "Invalid profile name {:?}: only a-z, 0-9, '_' and '-' are allowed",
+            name
+        );
+    }
+    Ok(())
+}
+
+/// Noms listés sous `profiles.names` dans un arbre de configuration
+fn profile_names(data: &Value) -> Vec<String> {
+    data[PROFILES_SECTION]["names"]
+        .as_sequence()
+        .map(|names| {
+            names
+                .iter()
+                .filter_map(Value::as_str)
+                .map(str::to_string)
+                .collect()
+        })
+        .unwrap_or_default()
+}
+
+impl Config {
+    /// Profil par défaut (celui des lectures d'origine UPnP)
+    pub fn get_default_profile(&self) -> Result<String> {
+        self.get_string(&[PROFILES_SECTION, "default"], DEFAULT_PROFILE)
+    }
+
+    /// Profils déclarés, le profil par défaut en tête
+    pub fn get_profiles(&self) -> Result<Vec<String>> {
+        let default = self.get_default_profile()?;
+        let mut profiles = vec![default.clone()];
+        profiles.extend(
+            profile_names(&self.snapshot())
+                .into_iter()
+                .filter(|name| *name != default),
+        );
+        Ok(profiles)
+    }
+
+    /// Retourne `true` si le profil existe
+    pub fn has_profile(&self, name: &str) -> Result<bool> {
+        Ok(self.get_profiles()?.iter().any(|p| p == name))
+    }
+
+    /// Profil à utiliser pour une requête : le profil demandé s'il existe,
+    /// le profil par défaut si aucun n'est demandé
+    pub fn resolve_profile(&self, requested: Option<&str>) -> Result<String> {
+        match requested.map(str::trim).filter(|name| !name.is_empty()) {
+            None => self.get_default_profile(),
+            Some(name) if self.has_profile(name)? => Ok(name.to_string()),
+            Some(name) => Err(anyhow!("Unknown profile: {}", name)),
+        }
+    }
+
+    /// Déclare un nouveau profil
+    pub fn add_profile(&self, name: &str) -> Result<()> {
+        validate_profile_name(name)?;
+        let default = self.get_default_profile()?;
+        self.update(|data| {
+            let mut names = profile_names(data);
+            if name == default || names.iter().any(|n| n == name) {
+                bail!("Profile already exists: {}", name);
+            }
+            names.push(name.to_string());
+            data[PROFILES_SECTION]["names"] =
+                Value::Sequence(names.into_iter().map(Value::String).collect());
+            Ok(())
+        })
+    }
+
+    /// Supprime un profil (le profil par défaut ne peut pas l'être)
+    ///
+    /// Les favoris, l'historique et les positions de reprise du profil ne
+    /// sont pas effacés : ils réapparaissent si le profil est recréé.
+    pub fn remove_profile(&self, name: &str) -> Result<()> {
+        if name == self.get_default_profile()? {
+            bail!("The default profile cannot be removed");
+        }
+        self.update(|data| {
+            let mut names = profile_names(data);
+            let before = names.len();
+            names.retain(|n| n != name);
+            if names.len() == before {
+                bail!("Unknown profile: {}", name);
+            }
+            data[PROFILES_SECTION]["names"] =
+                Value::Sequence(names.into_iter().map(Value::String).collect());
+            Ok(())
+        })
+    }
+
+    /// Change le profil par défaut (il est déclaré s'il ne l'était pas)
+    pub fn set_default_profile(&self, name: &str) -> Result<()> {
+        validate_profile_name(name)?;
+        let previous = self.get_default_profile()?;
+        self.update(|data| {
+            let mut names = profile_names(data);
+            for profile in [previous.as_str(), name] {
+                if !names.iter().any(|n| n == profile) {
+                    names.push(profile.to_string());
+                }
+            }
+            data[PROFILES_SECTION]["names"] =
+                Value::Sequence(names.into_iter().map(Value::String).collect());
+            data[PROFILES_SECTION]["default"] = Value::String(name.to_string());
+            Ok(())
+        })
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_yaml::Mapping;
+
+    #[test]
+    fn test_profiles() {
+        let config = Config::in_memory(Value::Mapping(Mapping::new()));
+        assert_eq!(config.get_profiles().unwrap(), vec![DEFAULT_PROFILE]);
+        assert_eq!(config.resolve_profile(None).unwrap(), DEFAULT_PROFILE);
+
+        config.add_profile("alice").unwrap();
+        assert!(config.add_profile("alice").is_err());
+        assert!(config.add_profile("Bob Smith").is_err());
+        assert_eq!(config.resolve_profile(Some("alice")).unwrap(), "alice");
+        assert!(config.resolve_profile(Some("bob")).is_err());
+
+        config.set_default_profile("alice").unwrap();
+        assert_eq!(
+            config.get_profiles().unwrap(),
+            vec!["alice", DEFAULT_PROFILE]
+        );
+        assert!(config.remove_profile("alice").is_err());
+        config.remove_profile(DEFAULT_PROFILE).unwrap();
+        assert_eq!(config.get_profiles().unwrap(), vec!["alice"]);
+    }
+}
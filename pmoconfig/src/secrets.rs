@@ -0,0 +1,277 @@
+//! Gestion des secrets (clés d'API, tokens, mots de passe)
+//!
+//! Les secrets sont regroupés sous la section `secrets` de la configuration :
+//!
+//! ```yaml
+//! secrets:
+//!   encrypt_at_rest: true
+//!   scrobbler:
+//!     lastfm_session_key: "encrypted:..."
+//!     listenbrainz_token: "env:LISTENBRAINZ_TOKEN"
+//!   spotify:
+//!     client_id: "..."
+//!     client_secret: "${SPOTIFY_CLIENT_SECRET}"
+//!   mqtt:
+//!     password: "encrypted:..."
+//! ```
+//!
+//! Une valeur peut être :
+//! - une indirection vers l'environnement (`env:NOM` ou `${NOM}`), résolue à
+//!   chaque lecture et jamais écrite en clair sur disque ;
+//! - une valeur chiffrée (`encrypted:...`, voir [`crate::encryption`]) ;
+//! - une valeur en clair, chiffrée au prochain enregistrement si
+//!   `encrypt_at_rest` est actif.
+//!
+//! Les secrets (et toute clé dont le nom évoque un mot de passe ou un token)
+//! sont masqués dans les réponses de l'API et dans la sortie `Debug` de
+//! [`Config`], donc dans les logs.
+
+use crate::{encryption, Config};
+use anyhow::{anyhow, Result};
+use serde_yaml::Value;
+use std::env;
+use tracing::warn;
+
+/// Nom de la section des secrets
+pub const SECRETS_SECTION: &str = "secrets";
+
+/// Valeur affichée à la place d'un secret
+pub const REDACTED: &str = "***";
+
+/// Clé activant le chiffrement au repos
+const ENCRYPT_AT_REST_KEY: &str = "encrypt_at_rest";
+const DEFAULT_ENCRYPT_AT_REST: bool = true;
+
+/// Fragments de noms de clés considérées comme sensibles
+const SENSITIVE_KEY_HINTS: &[&str] = &[
+    "password",
+    "secret",
+    "token",
+    "api_key",
+    "apikey",
+    "session_key",
+];
+
+/// Retourne `true` si le nom de clé désigne probablement un secret
+pub fn is_sensitive_key(key: &str) -> bool {
+    let key = key.to_ascii_lowercase();
+    SENSITIVE_KEY_HINTS.iter().any(|hint| key.contains(hint))
+}
+
+/// Nom de la variable d'environnement référencée par `value`, s'il y en a une
+///
+/// Formes acceptées : `env:NOM` et `${NOM}`.
+pub fn env_reference(value: &str) -> Option<&str> {
+    let value = value.trim();
+    let name = value.strip_prefix("env:").or_else(|| {
+        value
+            .strip_prefix("${")
+            .and_then(|rest| rest.strip_suffix('}'))
+    })?;
+    let name = name.trim();
+    (!name.is_empty()).then_some(name)
+}
+
+/// Résout une valeur de secret : indirection d'environnement, déchiffrement
+/// ou valeur en clair
+pub fn resolve_secret(value: &str) -> Result<String> {
+    resolve_secret_with(value, |name| env::var(name).ok())
+}
+
+/// [`resolve_secret`] avec une lecture de l'environnement fournie par l'appelant
+fn resolve_secret_with(value: &str, lookup: impl Fn(&str) -> Option<String>) -> Result<String> {
+    match env_reference(value) {
+        Some(name) => lookup(name)
+            .ok_or_else(|| anyhow!("Environment variable {} referenced by secret is not set", name)),
+        None => encryption::get_password(value),
+    }
+}
+
+/// Copie de `value` où les secrets sont remplacés par [`REDACTED`]
+///
+/// `path` est le chemin de `value` dans la configuration. Les indirections
+/// d'environnement ne sont pas sensibles et restent visibles.
+pub fn redact(path: &[&str], value: &Value) -> Value {
+    // `secrets` lui-même est une clé sensible : toute la section est masquée
+    redact_value(value, path.iter().any(|key| is_sensitive_key(key)))
+}
+
+fn redact_value(value: &Value, sensitive: bool) -> Value {
+    match value {
+        Value::Mapping(map) => Value::Mapping(
+            map.iter()
+                .map(|(k, v)| {
+                    let key = k.as_str().unwrap_or_default();
+                    (k.clone(), redact_value(v, sensitive || is_sensitive_key(key)))
+                })
+                .collect(),
+        ),
+        Value::Sequence(seq) => {
+            Value::Sequence(seq.iter().map(|v| redact_value(v, sensitive)).collect())
+        }
+        Value::String(s) if sensitive && !s.is_empty() && env_reference(s).is_none() => {
+            Value::String(REDACTED.to_string())
+        }
+        _ => value.clone(),
+    }
+}
+
+/// Copie de la configuration complète prête à être écrite sur disque :
+/// les secrets en clair de la section `secrets` sont chiffrés
+pub(crate) fn seal_for_save(data: &Value) -> Value {
+    let mut sealed = data.clone();
+    let Value::Mapping(root) = &mut sealed else {
+        return sealed;
+    };
+    let Some(section) = root.get_mut(SECRETS_SECTION) else {
+        return sealed;
+    };
+    if !encrypt_at_rest(section) {
+        return sealed;
+    }
+    seal_value(section);
+    sealed
+}
+
+fn encrypt_at_rest(section: &Value) -> bool {
+    match section.get(ENCRYPT_AT_REST_KEY) {
+        Some(Value::Bool(b)) => *b,
+        _ => DEFAULT_ENCRYPT_AT_REST,
+    }
+}
+
+fn seal_value(value: &mut Value) {
+    match value {
+        Value::Mapping(map) => map.values_mut().for_each(seal_value),
+        Value::String(s)
+            if !s.is_empty() && !encryption::is_encrypted(s) && env_reference(s).is_none() =>
+        {
+            match encryption::encrypt_password(s) {
+                Ok(encrypted) => *s = encrypted,
+                Err(e) => warn!("🔐 Cannot encrypt secret, keeping it in clear text: {}", e),
+            }
+        }
+        _ => {}
+    }
+}
+
+impl Config {
+    /// Récupère un secret de la section `secrets`
+    ///
+    /// # Arguments
+    ///
+    /// * `path` - Chemin relatif à `secrets` (ex: `&["spotify", "client_secret"]`)
+    ///
+    /// # Returns
+    ///
+    /// La valeur en clair, ou `None` si le secret n'est pas configuré
+    ///
+    /// # Examples
+    ///
+    /// ```no_run
+    /// use pmoconfig::get_config;
+    ///
+    /// let config = get_config();
+    /// let password = config.get_secret(&["mqtt", "password"])?;
+    /// # Ok::<(), anyhow::Error>(())
+    /// ```
+    pub fn get_secret(&self, path: &[&str]) -> Result<Option<String>> {
+        match self.get_value(&secret_path(path)) {
+            Ok(Value::String(s)) if !s.trim().is_empty() => resolve_secret(&s).map(Some),
+            Ok(Value::String(_)) | Ok(Value::Null) | Err(_) => Ok(None),
+            Ok(_) => Err(anyhow!("Secret {} is not a string", path.join("."))),
+        }
+    }
+
+    /// Enregistre un secret, chiffré si `secrets.encrypt_at_rest` est actif
+    ///
+    /// Une indirection d'environnement (`env:NOM`) est conservée telle quelle.
+    pub fn set_secret(&self, path: &[&str], value: &str) -> Result<()> {
+        let stored = if env_reference(value).is_some() || encryption::is_encrypted(value) {
+            value.to_string()
+        } else if self.get_secrets_encrypt_at_rest()? {
+            match encryption::encrypt_password(value) {
+                Ok(encrypted) => encrypted,
+                Err(e) => {
+                    warn!("🔐 Cannot encrypt secret {}: {}", path.join("."), e);
+                    value.to_string()
+                }
+            }
+        } else {
+            value.to_string()
+        };
+        self.set_value(&secret_path(path), Value::String(stored))
+    }
+
+    /// Fait pointer un secret vers une variable d'environnement
+    pub fn set_secret_env(&self, path: &[&str], var: &str) -> Result<()> {
+        self.set_value(&secret_path(path), Value::String(format!("env:{}", var)))
+    }
+
+    /// Supprime la valeur d'un secret
+    pub fn clear_secret(&self, path: &[&str]) -> Result<()> {
+        self.set_value(&secret_path(path), Value::Null)
+    }
+
+    pub fn get_secrets_encrypt_at_rest(&self) -> Result<bool> {
+        match self.get_value(&[SECRETS_SECTION, ENCRYPT_AT_REST_KEY]) {
+            Ok(Value::Bool(b)) => Ok(b),
+            _ => Ok(DEFAULT_ENCRYPT_AT_REST),
+        }
+    }
+
+    pub fn set_secrets_encrypt_at_rest(&self, enabled: bool) -> Result<()> {
+        self.set_value(&[SECRETS_SECTION, ENCRYPT_AT_REST_KEY], Value::Bool(enabled))
+    }
+
+    /// Comme [`Config::get_value`], mais avec les secrets masqués
+    pub fn get_redacted_value(&self, path: &[&str]) -> Result<Value> {
+        Ok(redact(path, &self.get_value(path)?))
+    }
+}
+
+fn secret_path<'a>(path: &[&'a str]) -> Vec<&'a str> {
+    std::iter::once(SECRETS_SECTION)
+        .chain(path.iter().copied())
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_env_reference_and_redaction() {
+        assert_eq!(env_reference("env:MQTT_PASSWORD"), Some("MQTT_PASSWORD"));
+        assert_eq!(env_reference("${SPOTIFY_SECRET}"), Some("SPOTIFY_SECRET"));
+        assert_eq!(env_reference("plain"), None);
+
+        let lookup = |name: &str| (name == "PMOCONFIG_TEST_SECRET").then(|| "s3cr3t".to_string());
+        assert_eq!(
+            resolve_secret_with("env:PMOCONFIG_TEST_SECRET", lookup).unwrap(),
+            "s3cr3t"
+        );
+        assert_eq!(
+            resolve_secret_with("${PMOCONFIG_TEST_SECRET}", lookup).unwrap(),
+            "s3cr3t"
+        );
+        assert!(resolve_secret_with("env:PMOCONFIG_TEST_MISSING", lookup).is_err());
+
+        let config: Value = serde_yaml::from_str(
+            "host:\n  http_port: 8080\n\
+             accounts:\n  qobuz:\n    username: me\n    password: hunter2\n\
+             secrets:\n  encrypt_at_rest: true\n  spotify:\n    client_id: abc\n    client_secret: env:SPOTIFY\n",
+        )
+        .unwrap();
+        let redacted = redact(&[], &config);
+        assert_eq!(redacted["host"]["http_port"], config["host"]["http_port"]);
+        assert_eq!(redacted["accounts"]["qobuz"]["username"], "me");
+        assert_eq!(redacted["accounts"]["qobuz"]["password"], REDACTED);
+        assert_eq!(redacted["secrets"]["spotify"]["client_id"], REDACTED);
+        assert_eq!(redacted["secrets"]["spotify"]["client_secret"], "env:SPOTIFY");
+        assert_eq!(redacted["secrets"]["encrypt_at_rest"], true);
+
+        let sub = redact(&["secrets", "spotify"], &config["secrets"]["spotify"]);
+        assert_eq!(sub["client_id"], REDACTED);
+    }
+}
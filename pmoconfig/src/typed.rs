@@ -0,0 +1,275 @@
+//! Accès typé aux valeurs de configuration
+//!
+//! [`Config::get_value`] renvoie un `serde_yaml::Value` brut : chaque
+//! appelant devait tester le type lui-même et retombait souvent sans bruit
+//! sur la valeur par défaut (un port écrit `8080.0` était ignoré). Les
+//! getters de ce module :
+//!
+//! - renvoient la valeur par défaut fournie si la clé est absente ou nulle ;
+//! - convertissent les écritures équivalentes (`"8080"`, `8080.0`, `"yes"`) ;
+//! - renvoient une erreur nommant la clé si la valeur est inutilisable.
+//!
+//! Les durées et les tailles acceptent des unités :
+//!
+//! ```yaml
+//! timeout: 5s          # ms, s, m, h, d ; combinables (1m30s) ; nombre seul = secondes
+//! max_size: 200MB      # B, KB/MB/GB/TB (×1000), KiB/MiB/GiB/TiB (×1024) ; nombre seul = octets
+//! ```
+
+use crate::Config;
+use anyhow::{Result, anyhow};
+use serde_yaml::Value;
+use std::time::Duration;
+
+/// Analyse une durée (`500ms`, `5s`, `1m30s`, `2h`, `1.5d`, `30`)
+///
+/// Un nombre sans unité est exprimé en secondes.
+pub fn parse_duration(input: &str) -> Result<Duration> {
+    let text = input.trim();
+    if text.is_empty() {
+        return Err(anyhow!("empty duration"));
+    }
+    if let Ok(secs) = text.parse::<f64>() {
+        return seconds(secs, input);
+    }
+
+    let mut total = 0.0;
+    let mut rest = text;
+    while !rest.is_empty() {
+        let number_end = rest
+            .find(|c: char| !(c.is_ascii_digit() || c == '.'))
+            .unwrap_or(rest.len());
+        let unit_end = rest[number_end..]
+            .find(|c: char| c.is_ascii_digit() || c == '.')
+            .map_or(rest.len(), |p| number_end + p);
+        let value: f64 = rest[..number_end]
+            .parse()
+            .map_err(|_| anyhow!("invalid duration '{}'", input))?;
+        let factor = match rest[number_end..unit_end].trim().to_lowercase().as_str() {
+            "ms" => 0.001,
+            "s" | "sec" | "secs" => 1.0,
+            "m" | "min" | "mins" => 60.0,
+            "h" => 3600.0,
+            "d" => 86400.0,
+            unit => return Err(anyhow!("unknown duration unit '{}' in '{}'", unit, input)),
+        };
+        total += value * factor;
+        rest = rest[unit_end..].trim_start();
+    }
+    seconds(total, input)
+}
+
+fn seconds(secs: f64, input: &str) -> Result<Duration> {
+    Duration::try_from_secs_f64(secs).map_err(|_| anyhow!("invalid duration '{}'", input))
+}
+
+/// Analyse une taille en octets (`512`, `64KB`, `200MB`, `1.5GiB`)
+pub fn parse_size(input: &str) -> Result<u64> {
+    let text = input.trim();
+    let number_end = text
+        .find(|c: char| !(c.is_ascii_digit() || c == '.'))
+        .unwrap_or(text.len());
+    let value: f64 = text[..number_end]
+        .parse()
+        .map_err(|_| anyhow!("invalid size '{}'", input))?;
+    let factor: f64 = match text[number_end..].trim().to_lowercase().as_str() {
+        "" | "b" => 1.0,
+        "k" | "kb" => 1e3,
+        "m" | "mb" => 1e6,
+        "g" | "gb" => 1e9,
+        "t" | "tb" => 1e12,
+        "kib" => 1024.0,
+        "mib" => 1024.0 * 1024.0,
+        "gib" => 1024.0 * 1024.0 * 1024.0,
+        "tib" => 1024.0 * 1024.0 * 1024.0 * 1024.0,
+        unit => return Err(anyhow!("unknown size unit '{}' in '{}'", unit, input)),
+    };
+    let bytes = value * factor;
+    if !bytes.is_finite() || bytes < 0.0 || bytes > u64::MAX as f64 {
+        return Err(anyhow!("invalid size '{}'", input));
+    }
+    Ok(bytes.round() as u64)
+}
+
+fn type_error(path: &[&str], expected: &str, value: &Value) -> anyhow::Error {
+    let found = serde_yaml::to_string(value).unwrap_or_default();
+    anyhow!(
+        "{}: expected {}, got {}",
+        path.join("."),
+        expected,
+        found.trim()
+    )
+}
+
+impl Config {
+    /// Valeur présente et non nulle à `path`
+    fn get_present(&self, path: &[&str]) -> Option<Value> {
+        match self.get_value(path) {
+            Ok(Value::Null) | Err(_) => None,
+            Ok(value) => Some(value),
+        }
+    }
+
+    /// Chaîne à `path` (nombres et booléens convertis)
+    pub fn get_string(&self, path: &[&str], default: &str) -> Result<String> {
+        match self.get_present(path) {
+            None => Ok(default.to_string()),
+            Some(Value::String(s)) => Ok(s),
+            Some(Value::Number(n)) => Ok(n.to_string()),
+            Some(Value::Bool(b)) => Ok(b.to_string()),
+            Some(other) => Err(type_error(path, "a string", &other)),
+        }
+    }
+
+    /// Entier à `path` (accepte `8080`, `8080.0` et `"8080"`)
+    pub fn get_int(&self, path: &[&str], default: i64) -> Result<i64> {
+        let value = match self.get_present(path) {
+            None => return Ok(default),
+            Some(value) => value,
+        };
+        let int = match &value {
+            Value::Number(n) => n
+                .as_i64()
+                .or_else(|| n.as_f64().filter(|f| f.fract() == 0.0).map(|f| f as i64)),
+            Value::String(s) => {
+                let s = s.trim();
+                s.parse::<i64>().ok().or_else(|| {
+                    s.parse::<f64>()
+                        .ok()
+                        .filter(|f| f.fract() == 0.0)
+                        .map(|f| f as i64)
+                })
+            }
+            _ => None,
+        };
+        int.ok_or_else(|| type_error(path, "an integer", &value))
+    }
+
+    /// Entier positif à `path`
+    pub fn get_uint(&self, path: &[&str], default: u64) -> Result<u64> {
+        let int = self.get_int(path, default as i64)?;
+        u64::try_from(int).map_err(|_| {
+            anyhow!(
+                "{}: expected a positive integer, got {}",
+                path.join("."),
+                int
+            )
+        })
+    }
+
+    /// Nombre à `path` (accepte `"0.5"`)
+    pub fn get_float(&self, path: &[&str], default: f64) -> Result<f64> {
+        match self.get_present(path) {
+            None => Ok(default),
+            Some(Value::Number(n)) => n
+                .as_f64()
+                .ok_or_else(|| anyhow!("{}: invalid number", path.join("."))),
+            Some(Value::String(s)) if s.trim().parse::<f64>().is_ok() => {
+                Ok(s.trim().parse().unwrap())
+            }
+            Some(other) => Err(type_error(path, "a number", &other)),
+        }
+    }
+
+    /// Booléen à `path` (accepte `true/false`, `yes/no`, `on/off`, `1/0`)
+    pub fn get_bool(&self, path: &[&str], default: bool) -> Result<bool> {
+        let value = match self.get_present(path) {
+            None => return Ok(default),
+            Some(value) => value,
+        };
+        let b = match &value {
+            Value::Bool(b) => Some(*b),
+            Value::Number(n) => match n.as_i64() {
+                Some(0) => Some(false),
+                Some(1) => Some(true),
+                _ => None,
+            },
+            Value::String(s) => match s.trim().to_lowercase().as_str() {
+                "true" | "yes" | "on" | "1" => Some(true),
+                "false" | "no" | "off" | "0" => Some(false),
+                _ => None,
+            },
+            _ => None,
+        };
+        b.ok_or_else(|| type_error(path, "a boolean", &value))
+    }
+
+    /// Durée à `path` (`"5s"`, `"1m30s"` ; nombre seul = secondes)
+    pub fn get_duration(&self, path: &[&str], default: Duration) -> Result<Duration> {
+        match self.get_present(path) {
+            None => Ok(default),
+            Some(Value::Number(n)) => {
+                let secs = n.as_f64().unwrap_or(-1.0);
+                Duration::try_from_secs_f64(secs)
+                    .map_err(|_| anyhow!("{}: invalid duration {}", path.join("."), n))
+            }
+            Some(Value::String(s)) => {
+                parse_duration(&s).map_err(|e| anyhow!("{}: {}", path.join("."), e))
+            }
+            Some(other) => Err(type_error(path, "a duration", &other)),
+        }
+    }
+
+    /// Taille en octets à `path` (`"200MB"`, `"1GiB"` ; nombre seul = octets)
+    pub fn get_size(&self, path: &[&str], default: u64) -> Result<u64> {
+        match self.get_present(path) {
+            None => Ok(default),
+            Some(Value::Number(n)) => {
+                parse_size(&n.to_string()).map_err(|e| anyhow!("{}: {}", path.join("."), e))
+            }
+            Some(Value::String(s)) => {
+                parse_size(&s).map_err(|e| anyhow!("{}: {}", path.join("."), e))
+            }
+            Some(other) => Err(type_error(path, "a size", &other)),
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn config(yaml: &str) -> Config {
+        Config::in_memory(serde_yaml::from_str(yaml).unwrap())
+    }
+
+    #[test]
+    fn test_parse_units() {
+        assert_eq!(parse_duration("5s").unwrap(), Duration::from_secs(5));
+        assert_eq!(parse_duration("1m30s").unwrap(), Duration::from_secs(90));
+        assert_eq!(parse_duration("250ms").unwrap(), Duration::from_millis(250));
+        assert_eq!(parse_duration("30").unwrap(), Duration::from_secs(30));
+        assert!(parse_duration("5 parsecs").is_err());
+
+        assert_eq!(parse_size("200MB").unwrap(), 200_000_000);
+        assert_eq!(parse_size("1.5KiB").unwrap(), 1536);
+        assert_eq!(parse_size("512").unwrap(), 512);
+        assert!(parse_size("-1MB").is_err());
+        assert!(parse_size("12 bananas").is_err());
+    }
+
+    #[test]
+    fn test_typed_getters() {
+        let c = config(
+            "host:\n  http_port: 8080.0\n  name: 42\n  flag: \"yes\"\n  timeout: 1m\n  \
+             cache: 2GB\n  bad: [1, 2]\n  ratio: \"0.5\"\n",
+        );
+        assert_eq!(c.get_int(&["host", "http_port"], 0).unwrap(), 8080);
+        assert_eq!(c.get_string(&["host", "name"], "").unwrap(), "42");
+        assert!(c.get_bool(&["host", "flag"], false).unwrap());
+        assert_eq!(
+            c.get_duration(&["host", "timeout"], Duration::ZERO)
+                .unwrap(),
+            Duration::from_secs(60)
+        );
+        assert_eq!(c.get_size(&["host", "cache"], 0).unwrap(), 2_000_000_000);
+        assert_eq!(c.get_float(&["host", "ratio"], 0.0).unwrap(), 0.5);
+
+        assert_eq!(c.get_int(&["host", "missing"], 7).unwrap(), 7);
+        assert_eq!(c.get_uint(&["nothing", "here"], 3).unwrap(), 3);
+
+        let err = c.get_int(&["host", "bad"], 0).unwrap_err().to_string();
+        assert!(err.contains("host.bad"), "{}", err);
+        assert!(c.get_uint(&["host", "http_port"], 0).is_ok());
+    }
+}
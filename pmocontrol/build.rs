@@ -0,0 +1,11 @@
+//! Génère le code du service gRPC (feature `grpc`) depuis les définitions
+//! protobuf livrées dans `proto/`. La compilation demande `protoc`.
+
+fn main() {
+    println!("cargo:rerun-if-changed=proto");
+    #[cfg(feature = "grpc")]
+    tonic_build::configure()
+        .build_client(false)
+        .compile_protos(&["proto/pmocontrol/v1/control.proto"], &["proto"])
+        .expect("Failed to compile protobuf definitions");
+}
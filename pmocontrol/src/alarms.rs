@@ -0,0 +1,345 @@
+//! Réveils et programmation de la lecture
+//!
+//! Le planificateur lance la lecture d'une playlist ou d'une radio (objet
+//! d'un serveur de médias) sur un renderer aux jours et heure choisis, en
+//! montant progressivement le volume, et peut l'arrêter à une heure donnée :
+//!
+//! ```yaml
+//! host:
+//!   control_point:
+//!     alarms:
+//!       enabled: true
+//!       entries:
+//!         - id: reveil-semaine
+//!           renderer: Chambre                # id, UDN ou nom convivial
+//!           server_id: "uuid:..."            # serveur de médias
+//!           object_id: "radiofrance:franceinter"
+//!           days: [mon, tue, wed, thu, fri]  # vide = tous les jours
+//!           start: "07:00"
+//!           stop: "08:00"                    # optionnel
+//!           volume: 30                       # volume atteint en fin de montée
+//!           fade_in: 2m                      # 0 = pas de montée
+//! ```
+//!
+//! Les heures sont locales. Un arrêt antérieur au début tombe le lendemain.
+//! La montée s'interrompt si le volume est changé pendant qu'elle se
+//! déroule. Les déclenchements sont publiés sur le bus d'événements des
+//! renderers (`alarm_triggered`, `alarm_stopped`), donc dans le flux SSE.
+
+use std::collections::HashSet;
+use std::sync::Arc;
+use std::thread;
+use std::time::Duration;
+
+use anyhow::{Result, anyhow, bail};
+use chrono::{Datelike, Local, NaiveDateTime, NaiveTime, Timelike, Weekday};
+use serde::{Deserialize, Serialize};
+use tracing::{debug, info, warn};
+
+use crate::config_ext::ControlPointConfigExt;
+use crate::control_point::ControlPoint;
+use crate::events::RendererEventBus;
+use crate::groups::member_matches;
+use crate::model::RendererEvent;
+use crate::music_renderer::MusicRenderer;
+use crate::{DeviceId, DeviceIdentity, DeviceOnline};
+
+/// Intervalle de vérification des réveils
+const TICK: Duration = Duration::from_secs(5);
+
+/// Intervalle entre deux paliers de la montée du volume
+const FADE_STEP: Duration = Duration::from_secs(1);
+
+/// Réveil ou lecture programmée
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Alarm {
+    pub id: String,
+    #[serde(default = "default_enabled")]
+    pub enabled: bool,
+    /// Identifiant, UDN ou nom convivial du renderer
+    pub renderer: String,
+    /// Serveur de médias de la playlist ou de la radio
+    pub server_id: String,
+    /// Objet à lire (container ou item)
+    pub object_id: String,
+    /// Jours de déclenchement (vide : tous les jours)
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub days: Vec<Weekday>,
+    /// Heure de début, `HH:MM`
+    pub start: String,
+    /// Heure d'arrêt, `HH:MM`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub stop: Option<String>,
+    /// Volume en fin de montée (défaut : volume du renderer au déclenchement)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub volume: Option<u16>,
+    /// Durée de la montée du volume (ex: `90s`, `2m`)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub fade_in: Option<String>,
+}
+
+fn default_enabled() -> bool {
+    true
+}
+
+fn parse_time(value: &str) -> Result<NaiveTime> {
+    NaiveTime::parse_from_str(value.trim(), "%H:%M")
+        .map_err(|_| anyhow!("Invalid time {:?}: expected HH:MM", value))
+}
+
+impl Alarm {
+    /// Vérifie les champs d'un réveil avant de l'enregistrer
+    pub fn validate(&self) -> Result<()> {
+        if self.id.trim().is_empty() {
+            bail!("Alarm id must not be empty");
+        }
+        if self.renderer.trim().is_empty() || self.object_id.trim().is_empty() {
+            bail!("Alarm {} needs a renderer and an object to play", self.id);
+        }
+        parse_time(&self.start)?;
+        if let Some(stop) = &self.stop {
+            parse_time(stop)?;
+        }
+        if self.volume.is_some_and(|v| v > 100) {
+            bail!("Alarm {}: volume must be between 0 and 100", self.id);
+        }
+        self.fade_in_duration()?;
+        Ok(())
+    }
+
+    /// Durée de la montée du volume (zéro si absente)
+    pub fn fade_in_duration(&self) -> Result<Duration> {
+        match self.fade_in.as_deref() {
+            None => Ok(Duration::ZERO),
+            Some(value) => pmoconfig::parse_duration(value)
+                .map_err(|e| anyhow!("Alarm {}: invalid fade_in: {}", self.id, e)),
+        }
+    }
+
+    fn on_day(&self, day: Weekday) -> bool {
+        self.days.is_empty() || self.days.contains(&day)
+    }
+
+    /// Le réveil démarre-t-il à cette minute ?
+    pub fn starts_at(&self, at: NaiveDateTime) -> bool {
+        self.enabled
+            && self.on_day(at.weekday())
+            && parse_time(&self.start).is_ok_and(|start| same_minute(start, at.time()))
+    }
+
+    /// La lecture programmée s'arrête-t-elle à cette minute ?
+    pub fn stops_at(&self, at: NaiveDateTime) -> bool {
+        let (Some(stop), Ok(start)) = (self.stop.as_deref(), parse_time(&self.start)) else {
+            return false;
+        };
+        let Ok(stop) = parse_time(stop) else {
+            return false;
+        };
+        // Un arrêt antérieur au début concerne un réveil de la veille
+        let day = if stop < start {
+            at.weekday().pred()
+        } else {
+            at.weekday()
+        };
+        self.enabled && self.on_day(day) && same_minute(stop, at.time())
+    }
+}
+
+fn same_minute(time: NaiveTime, now: NaiveTime) -> bool {
+    time.hour() == now.hour() && time.minute() == now.minute()
+}
+
+/// Renderer désigné par un réveil
+fn find_renderer(control_point: &ControlPoint, selector: &str) -> Option<Arc<MusicRenderer>> {
+    control_point
+        .list_music_renderers()
+        .into_iter()
+        .find(|r| member_matches(selector, &r.id(), r.udn(), r.friendly_name()))
+}
+
+/// Lance la lecture d'un réveil puis monte le volume
+fn start_alarm(
+    control_point: &ControlPoint,
+    events: &RendererEventBus,
+    alarm: &Alarm,
+) -> Result<()> {
+    let renderer = find_renderer(control_point, &alarm.renderer)
+        .ok_or_else(|| anyhow!("Renderer {} not found", alarm.renderer))?;
+    let id = renderer.id();
+    if !renderer.is_online() && !control_point.wake_renderer(&id)? {
+        bail!("Renderer {} is offline", renderer.friendly_name());
+    }
+
+    let fade_in = alarm.fade_in_duration()?;
+    let target = match alarm.volume {
+        Some(volume) => volume,
+        None => renderer.volume()?,
+    };
+    renderer.set_volume(if fade_in.is_zero() { target } else { 0 })?;
+
+    // Un réveil n'appartient à personne : il relève du profil par défaut
+    control_point.renderer_profiles().reset(&id);
+    control_point.play_content(&id, &DeviceId(alarm.server_id.clone()), &alarm.object_id)?;
+
+    info!(
+        "⏰ Alarm {} started on {}",
+        alarm.id,
+        renderer.friendly_name()
+    );
+    events.broadcast(RendererEvent::AlarmTriggered {
+        id,
+        alarm: alarm.id.clone(),
+    });
+
+    if !fade_in.is_zero() {
+        fade_volume(&renderer, target, fade_in);
+    }
+    Ok(())
+}
+
+/// Monte le volume de 0 à `target` en `duration`
+///
+/// S'arrête si le volume lu diffère du dernier palier (changé par
+/// l'utilisateur).
+fn fade_volume(renderer: &MusicRenderer, target: u16, duration: Duration) {
+    let steps = (duration.as_secs() / FADE_STEP.as_secs()).max(1);
+    let mut last = 0u16;
+    for step in 1..=steps {
+        thread::sleep(FADE_STEP);
+        match renderer.volume() {
+            Ok(current) if current.abs_diff(last) > 1 => {
+                debug!(
+                    "Volume of {} changed during fade-in, stopping the ramp",
+                    renderer.friendly_name()
+                );
+                return;
+            }
+            Ok(_) => {}
+            Err(e) => {
+                warn!("Fade-in of {} interrupted: {}", renderer.friendly_name(), e);
+                return;
+            }
+        }
+        let volume = (u64::from(target) * step / steps) as u16;
+        if let Err(e) = renderer.set_volume(volume) {
+            warn!("Fade-in of {} interrupted: {}", renderer.friendly_name(), e);
+            return;
+        }
+        last = volume;
+    }
+}
+
+/// Arrête la lecture programmée
+fn stop_alarm(
+    control_point: &ControlPoint,
+    events: &RendererEventBus,
+    alarm: &Alarm,
+) -> Result<()> {
+    let renderer = find_renderer(control_point, &alarm.renderer)
+        .ok_or_else(|| anyhow!("Renderer {} not found", alarm.renderer))?;
+    let id = renderer.id();
+    control_point.user_stop(&id)?;
+
+    info!("⏰ Alarm {} stopped {}", alarm.id, renderer.friendly_name());
+    events.broadcast(RendererEvent::AlarmStopped {
+        id,
+        alarm: alarm.id.clone(),
+    });
+    Ok(())
+}
+
+/// Démarre le planificateur si les réveils sont activés
+///
+/// La liste des réveils est relue dans la configuration à chaque
+/// vérification : les modifications via l'API REST s'appliquent sans
+/// redémarrage. Retourne `false` si les réveils sont désactivés.
+pub fn spawn_scheduler(control_point: Arc<ControlPoint>, events: RendererEventBus) -> Result<bool> {
+    if !pmoconfig::get_config().get_alarms_enabled()? {
+        return Ok(false);
+    }
+    info!("⏰ Alarm scheduler enabled");
+
+    thread::Builder::new()
+        .name("cp-alarms".into())
+        .spawn(move || {
+            // Déclenchements déjà traités : (id du réveil, arrêt ?, minute)
+            let mut done: HashSet<(String, bool, NaiveDateTime)> = HashSet::new();
+            loop {
+                let now = Local::now().naive_local();
+                let minute = now
+                    .with_second(0)
+                    .unwrap_or(now)
+                    .with_nanosecond(0)
+                    .unwrap_or(now);
+                done.retain(|(_, _, at)| *at == minute);
+
+                let alarms = pmoconfig::get_config().get_alarms().unwrap_or_else(|e| {
+                    warn!("Invalid alarm configuration: {}", e);
+                    Vec::new()
+                });
+                for alarm in alarms {
+                    for stop in [false, true] {
+                        let due = if stop {
+                            alarm.stops_at(now)
+                        } else {
+                            alarm.starts_at(now)
+                        };
+                        if !due || !done.insert((alarm.id.clone(), stop, minute)) {
+                            continue;
+                        }
+                        let control_point = Arc::clone(&control_point);
+                        let events = events.clone();
+                        let alarm = alarm.clone();
+                        // La montée du volume peut durer : un thread par déclenchement
+                        thread::spawn(move || {
+                            let result = if stop {
+                                stop_alarm(&control_point, &events, &alarm)
+                            } else {
+                                start_alarm(&control_point, &events, &alarm)
+                            };
+                            if let Err(e) = result {
+                                warn!("⏰ Alarm {} failed: {}", alarm.id, e);
+                            }
+                        });
+                    }
+                }
+                thread::sleep(TICK);
+            }
+        })?;
+    Ok(true)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use chrono::NaiveDate;
+
+    #[test]
+    fn test_alarm_schedule() {
+        let alarm: Alarm = serde_yaml::from_str(
+            "id: reveil\nrenderer: Chambre\nserver_id: srv\nobject_id: radio\n\
+             days: [mon, fri]\nstart: \"23:30\"\nstop: \"00:30\"\nfade_in: 2m\n",
+        )
+        .unwrap();
+        alarm.validate().unwrap();
+        assert_eq!(alarm.fade_in_duration().unwrap(), Duration::from_secs(120));
+
+        // 2026-10-16 est un vendredi
+        let at = |d, h, m| {
+            NaiveDate::from_ymd_opt(2026, 10, d)
+                .unwrap()
+                .and_hms_opt(h, m, 42)
+                .unwrap()
+        };
+        assert!(alarm.starts_at(at(16, 23, 30)));
+        assert!(!alarm.starts_at(at(17, 23, 30)));
+        assert!(alarm.stops_at(at(17, 0, 30)));
+        assert!(!alarm.stops_at(at(16, 0, 30)));
+
+        let disabled = Alarm {
+            enabled: false,
+            ..alarm
+        };
+        assert!(!disabled.starts_at(at(16, 23, 30)));
+    }
+}
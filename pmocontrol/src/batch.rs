@@ -0,0 +1,649 @@
+//! Exécution groupée de commandes (`POST /api/v1/batch`)
+//!
+//! Une automatisation de type « scène » (baisser le salon, charger une
+//! playlist dans la cuisine, lancer la lecture) envoie toutes ses commandes
+//! en une requête :
+//!
+//! ```json
+//! {
+//!   "operations": [
+//!     { "op": "set_volume", "renderer_id": "uuid:salon", "volume": 15 },
+//!     { "op": "load_queue", "renderer_id": "uuid:cuisine",
+//!       "server_id": "uuid:pmomusic", "object_id": "playlist:matin" },
+//!     { "op": "play", "renderer_id": "uuid:cuisine" }
+//!   ]
+//! }
+//! ```
+//!
+//! Le lot est exécuté comme une transaction, au mieux de ce que permettent
+//! les renderers :
+//!
+//! 1. toutes les opérations sont vérifiées avant d'en exécuter une seule
+//!    (renderers et serveurs connus, volumes valides) ;
+//! 2. elles sont exécutées dans l'ordre, et la première erreur arrête le lot ;
+//! 3. les changements de volume et de sourdine déjà appliqués sont alors
+//!    annulés. Les commandes de transport et les chargements de queue ne
+//!    sont pas réversibles et restent appliqués.
+//!
+//! La réponse donne le statut de chaque opération.
+
+use serde::{Deserialize, Serialize};
+use std::sync::Arc;
+use tracing::{debug, warn};
+
+use crate::control_point::ControlPoint;
+use crate::music_renderer::MusicRenderer;
+use crate::{DeviceId, DeviceOnline};
+
+#[cfg(feature = "pmoserver")]
+use crate::openapi::ErrorResponse;
+#[cfg(feature = "pmoserver")]
+use axum::{Json, Router, extract::State, http::StatusCode, routing::post};
+#[cfg(feature = "pmoserver")]
+use pmoserver::Profile;
+#[cfg(feature = "pmoserver")]
+use utoipa::OpenApi;
+
+/// Nombre maximal d'opérations par lot
+pub const MAX_BATCH_OPERATIONS: usize = 64;
+
+/// Opération d'un lot
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+#[cfg_attr(feature = "pmoserver", derive(utoipa::ToSchema))]
+#[serde(tag = "op", rename_all = "snake_case")]
+pub enum BatchOperation {
+    /// Fixe le volume (0-100)
+    SetVolume {
+        renderer_id: String,
+        volume: u16,
+    },
+    /// Active ou coupe la sourdine
+    SetMute {
+        renderer_id: String,
+        mute: bool,
+    },
+    /// Remplace la queue par un objet d'un serveur de médias
+    LoadQueue {
+        renderer_id: String,
+        server_id: String,
+        object_id: String,
+    },
+    /// Lance la lecture de la queue
+    Play {
+        renderer_id: String,
+    },
+    Pause {
+        renderer_id: String,
+    },
+    Stop {
+        renderer_id: String,
+    },
+    /// Passe à la piste suivante de la queue
+    Next {
+        renderer_id: String,
+    },
+}
+
+impl BatchOperation {
+    pub fn name(&self) -> &'static str {
+        match self {
+            BatchOperation::SetVolume { .. } => "set_volume",
+            BatchOperation::SetMute { .. } => "set_mute",
+            BatchOperation::LoadQueue { .. } => "load_queue",
+            BatchOperation::Play { .. } => "play",
+            BatchOperation::Pause { .. } => "pause",
+            BatchOperation::Stop { .. } => "stop",
+            BatchOperation::Next { .. } => "next",
+        }
+    }
+
+    pub fn renderer_id(&self) -> &str {
+        match self {
+            BatchOperation::SetVolume { renderer_id, .. }
+            | BatchOperation::SetMute { renderer_id, .. }
+            | BatchOperation::LoadQueue { renderer_id, .. }
+            | BatchOperation::Play { renderer_id }
+            | BatchOperation::Pause { renderer_id }
+            | BatchOperation::Stop { renderer_id }
+            | BatchOperation::Next { renderer_id } => renderer_id,
+        }
+    }
+}
+
+/// Statut d'une opération après l'exécution du lot
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[cfg_attr(feature = "pmoserver", derive(utoipa::ToSchema))]
+#[serde(rename_all = "snake_case")]
+pub enum OperationStatus {
+    /// Exécutée
+    Ok,
+    /// En échec (invalide ou erreur du renderer)
+    Failed,
+    /// Non exécutée à cause d'une autre opération en échec
+    Skipped,
+    /// Exécutée puis annulée après l'échec d'une opération suivante
+    RolledBack,
+}
+
+/// Résultat d'une opération
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+#[cfg_attr(feature = "pmoserver", derive(utoipa::ToSchema))]
+pub struct OperationResult {
+    /// Position de l'opération dans le lot
+    pub index: usize,
+    pub op: String,
+    pub renderer_id: String,
+    pub status: OperationStatus,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub error: Option<String>,
+}
+
+/// Compte rendu d'un lot
+#[derive(Debug, Clone, PartialEq, Eq, Serialize)]
+#[cfg_attr(feature = "pmoserver", derive(utoipa::ToSchema))]
+pub struct BatchReport {
+    /// Toutes les opérations ont été exécutées
+    pub success: bool,
+    pub results: Vec<OperationResult>,
+}
+
+/// Réglage à rétablir si le lot échoue
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum Undo {
+    Volume(u16),
+    Mute(bool),
+}
+
+/// Renderers visés par un lot (le control point, ou un double dans les tests)
+trait BatchTarget {
+    /// Vérifie une opération sans l'exécuter
+    fn check(&self, op: &BatchOperation) -> Result<(), String>;
+
+    /// Attribue les lectures lancées sur un renderer à un profil
+    fn set_profile(&self, renderer_id: &str, profile: &str);
+
+    /// Exécute une opération et retourne le réglage à rétablir en cas d'échec
+    fn apply(&self, op: &BatchOperation) -> anyhow::Result<Option<Undo>>;
+
+    /// Rétablit un réglage sur un renderer
+    fn undo(&self, renderer_id: &str, undo: Undo) -> anyhow::Result<()>;
+}
+
+impl ControlPoint {
+    fn batch_renderer(&self, renderer_id: &str) -> anyhow::Result<Arc<MusicRenderer>> {
+        self.music_renderer_by_id(&DeviceId(renderer_id.to_string()))
+            .ok_or_else(|| anyhow::anyhow!("Renderer {} disappeared", renderer_id))
+    }
+}
+
+impl BatchTarget for ControlPoint {
+    fn check(&self, op: &BatchOperation) -> Result<(), String> {
+        let renderer = self
+            .music_renderer_by_id(&DeviceId(op.renderer_id().to_string()))
+            .ok_or_else(|| format!("Renderer {} not found", op.renderer_id()))?;
+        if !renderer.is_online() {
+            return Err(format!("Renderer {} is offline", op.renderer_id()));
+        }
+        match op {
+            BatchOperation::SetVolume { volume, .. } if *volume > 100 => {
+                Err(format!("Volume {} out of range (0-100)", volume))
+            }
+            BatchOperation::LoadQueue { server_id, .. } => self
+                .media_server(&DeviceId(server_id.clone()))
+                .filter(|server| server.is_online())
+                .map(|_| ())
+                .ok_or_else(|| format!("Server {} not found or offline", server_id)),
+            _ => Ok(()),
+        }
+    }
+
+    fn set_profile(&self, renderer_id: &str, profile: &str) {
+        self.renderer_profiles()
+            .set(&DeviceId(renderer_id.to_string()), profile);
+    }
+
+    fn apply(&self, op: &BatchOperation) -> anyhow::Result<Option<Undo>> {
+        let rid = DeviceId(op.renderer_id().to_string());
+        let renderer = self.batch_renderer(op.renderer_id())?;
+        match op {
+            BatchOperation::SetVolume { volume, .. } => {
+                let previous = renderer.volume()?;
+                renderer.set_volume(*volume)?;
+                Ok(Some(Undo::Volume(previous)))
+            }
+            BatchOperation::SetMute { mute, .. } => {
+                let previous = renderer.mute()?;
+                renderer.set_mute(*mute)?;
+                Ok(Some(Undo::Mute(previous)))
+            }
+            BatchOperation::LoadQueue {
+                server_id,
+                object_id,
+                ..
+            } => {
+                let server_id = DeviceId(server_id.clone());
+                let items = self.playback_items(&server_id, object_id)?;
+                if items.is_empty() {
+                    anyhow::bail!("No playable content found in {}", object_id);
+                }
+                if items.len() > 1 {
+                    self.attach_queue_to_playlist_with_options(
+                        &rid,
+                        server_id,
+                        object_id.clone(),
+                        false,
+                    )?;
+                } else {
+                    self.clear_queue(&rid)?;
+                    self.enqueue_items(&rid, items)?;
+                }
+                Ok(None)
+            }
+            BatchOperation::Play { .. } => {
+                renderer.play()?;
+                Ok(None)
+            }
+            BatchOperation::Pause { .. } => {
+                renderer.pause()?;
+                Ok(None)
+            }
+            BatchOperation::Stop { .. } => {
+                self.user_stop(&rid)?;
+                Ok(None)
+            }
+            BatchOperation::Next { .. } => {
+                self.play_next_from_queue(&rid)?;
+                Ok(None)
+            }
+        }
+    }
+
+    fn undo(&self, renderer_id: &str, undo: Undo) -> anyhow::Result<()> {
+        let renderer = self.batch_renderer(renderer_id)?;
+        match undo {
+            Undo::Volume(volume) => renderer.set_volume(volume),
+            Undo::Mute(mute) => renderer.set_mute(mute),
+        }
+    }
+}
+
+fn result(index: usize, op: &BatchOperation, status: OperationStatus) -> OperationResult {
+    OperationResult {
+        index,
+        op: op.name().to_string(),
+        renderer_id: op.renderer_id().to_string(),
+        status,
+        error: None,
+    }
+}
+
+/// Exécute un lot d'opérations (voir la documentation du module)
+///
+/// Si `profile` est donné, les lectures lancées par le lot lui sont
+/// attribuées, une fois le lot validé. Bloquant : à appeler hors du runtime
+/// asynchrone.
+pub fn run_batch(
+    control_point: &ControlPoint,
+    operations: &[BatchOperation],
+    profile: Option<&str>,
+) -> BatchReport {
+    execute(control_point, operations, profile)
+}
+
+fn execute(
+    target: &impl BatchTarget,
+    operations: &[BatchOperation],
+    profile: Option<&str>,
+) -> BatchReport {
+    let mut results: Vec<OperationResult> = operations
+        .iter()
+        .enumerate()
+        .map(|(index, op)| result(index, op, OperationStatus::Skipped))
+        .collect();
+
+    // 1. Vérification de tout le lot
+    let mut valid = true;
+    for (index, op) in operations.iter().enumerate() {
+        if let Err(error) = target.check(op) {
+            results[index].status = OperationStatus::Failed;
+            results[index].error = Some(error);
+            valid = false;
+        }
+    }
+    if !valid {
+        return BatchReport {
+            success: false,
+            results,
+        };
+    }
+
+    // Les lectures lancées par le lot sont attribuées au profil demandé
+    if let Some(profile) = profile {
+        for op in operations {
+            if matches!(
+                op,
+                BatchOperation::LoadQueue { .. } | BatchOperation::Play { .. }
+            ) {
+                target.set_profile(op.renderer_id(), profile);
+            }
+        }
+    }
+
+    // 2. Exécution dans l'ordre, arrêt à la première erreur
+    let mut undo: Vec<(usize, Undo)> = Vec::new();
+    let mut failed = false;
+    for (index, op) in operations.iter().enumerate() {
+        match target.apply(op) {
+            Ok(step_undo) => {
+                debug!(
+                    "Batch operation {} ({}) done on {}",
+                    index,
+                    op.name(),
+                    op.renderer_id()
+                );
+                results[index].status = OperationStatus::Ok;
+                if let Some(step_undo) = step_undo {
+                    undo.push((index, step_undo));
+                }
+            }
+            Err(e) => {
+                warn!(
+                    "Batch operation {} ({}) failed on {}: {}",
+                    index,
+                    op.name(),
+                    op.renderer_id(),
+                    e
+                );
+                results[index].status = OperationStatus::Failed;
+                results[index].error = Some(e.to_string());
+                failed = true;
+                break;
+            }
+        }
+    }
+
+    // 3. Annulation des réglages déjà appliqués, du plus récent au plus ancien
+    if failed {
+        for (index, step_undo) in undo.into_iter().rev() {
+            let renderer_id = operations[index].renderer_id();
+            match target.undo(renderer_id, step_undo) {
+                Ok(()) => results[index].status = OperationStatus::RolledBack,
+                Err(e) => {
+                    warn!(
+                        "Failed to roll back batch operation {} on {}: {}",
+                        index, renderer_id, e
+                    );
+                    results[index].error = Some(format!("Rollback failed: {}", e));
+                }
+            }
+        }
+    }
+
+    BatchReport {
+        success: !failed,
+        results,
+    }
+}
+
+// ============================================================================
+// API REST
+// ============================================================================
+
+/// Requête d'exécution groupée
+#[cfg(feature = "pmoserver")]
+#[derive(Debug, Clone, Deserialize, utoipa::ToSchema)]
+pub struct BatchRequest {
+    /// Opérations, exécutées dans l'ordre
+    pub operations: Vec<BatchOperation>,
+}
+
+/// POST /api/v1/batch - Exécute un lot d'opérations
+#[cfg(feature = "pmoserver")]
+#[utoipa::path(
+    post,
+    path = "/batch",
+    request_body = BatchRequest,
+    responses(
+        (status = 200, description = "Lot exécuté entièrement", body = BatchReport),
+        (status = 400, description = "Requête invalide", body = ErrorResponse),
+        (status = 409, description = "Lot refusé ou interrompu (voir le statut de chaque opération)", body = BatchReport)
+    ),
+    tag = "batch"
+)]
+async fn run_batch_handler(
+    State(control_point): State<Arc<ControlPoint>>,
+    Profile(profile): Profile,
+    Json(req): Json<BatchRequest>,
+) -> Result<(StatusCode, Json<BatchReport>), (StatusCode, Json<ErrorResponse>)> {
+    if req.operations.is_empty() || req.operations.len() > MAX_BATCH_OPERATIONS {
+        return Err((
+            StatusCode::BAD_REQUEST,
+            Json(ErrorResponse {
+                error: format!(
+                    "A batch must contain between 1 and {} operations",
+                    MAX_BATCH_OPERATIONS
+                ),
+            }),
+        ));
+    }
+
+    let report = tokio::task::spawn_blocking(move || {
+        run_batch(&control_point, &req.operations, Some(profile.as_str()))
+    })
+    .await
+    .map_err(|e| {
+        warn!("Task join error during batch: {}", e);
+        (
+            StatusCode::INTERNAL_SERVER_ERROR,
+            Json(ErrorResponse {
+                error: format!("Internal task error: {}", e),
+            }),
+        )
+    })?;
+
+    let status = if report.success {
+        StatusCode::OK
+    } else {
+        StatusCode::CONFLICT
+    };
+    Ok((status, Json(report)))
+}
+
+/// Documentation OpenAPI de l'API d'exécution groupée
+#[cfg(feature = "pmoserver")]
+#[derive(OpenApi)]
+#[openapi(
+    paths(run_batch_handler),
+    components(schemas(
+        BatchRequest,
+        BatchOperation,
+        BatchReport,
+        OperationResult,
+        OperationStatus,
+        ErrorResponse,
+    )),
+    tags(
+        (name = "batch", description = "Exécution groupée de commandes (scènes, automatisations)")
+    )
+)]
+pub struct BatchApiDoc;
+
+/// Router de l'API d'exécution groupée (monté sous `/api/v1`)
+#[cfg(feature = "pmoserver")]
+pub fn create_batch_router(control_point: Arc<ControlPoint>) -> Router {
+    Router::new()
+        .route("/batch", post(run_batch_handler))
+        .with_state(control_point)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::cell::RefCell;
+
+    /// Renderers simulés : journalise les appels, échoue sur demande
+    #[derive(Default)]
+    struct FakeTarget {
+        /// Renderers inconnus (refusés à la vérification)
+        unknown: Vec<&'static str>,
+        /// Nom de l'opération qui échoue à l'exécution
+        failing_op: Option<&'static str>,
+        calls: RefCell<Vec<String>>,
+    }
+
+    impl BatchTarget for FakeTarget {
+        fn check(&self, op: &BatchOperation) -> Result<(), String> {
+            if self.unknown.iter().any(|id| *id == op.renderer_id()) {
+                return Err(format!("Renderer {} not found", op.renderer_id()));
+            }
+            Ok(())
+        }
+
+        fn set_profile(&self, renderer_id: &str, profile: &str) {
+            self.calls
+                .borrow_mut()
+                .push(format!("profile {} {}", renderer_id, profile));
+        }
+
+        fn apply(&self, op: &BatchOperation) -> anyhow::Result<Option<Undo>> {
+            self.calls
+                .borrow_mut()
+                .push(format!("{} {}", op.name(), op.renderer_id()));
+            if self.failing_op == Some(op.name()) {
+                anyhow::bail!("{} failed", op.name());
+            }
+            Ok(match op {
+                BatchOperation::SetVolume { .. } => Some(Undo::Volume(40)),
+                BatchOperation::SetMute { .. } => Some(Undo::Mute(false)),
+                _ => None,
+            })
+        }
+
+        fn undo(&self, renderer_id: &str, undo: Undo) -> anyhow::Result<()> {
+            self.calls
+                .borrow_mut()
+                .push(format!("undo {:?} {}", undo, renderer_id));
+            Ok(())
+        }
+    }
+
+    fn scene() -> Vec<BatchOperation> {
+        vec![
+            BatchOperation::SetVolume {
+                renderer_id: "salon".to_string(),
+                volume: 15,
+            },
+            BatchOperation::SetMute {
+                renderer_id: "cuisine".to_string(),
+                mute: true,
+            },
+            BatchOperation::Play {
+                renderer_id: "cuisine".to_string(),
+            },
+            BatchOperation::Next {
+                renderer_id: "salon".to_string(),
+            },
+        ]
+    }
+
+    fn statuses(report: &BatchReport) -> Vec<OperationStatus> {
+        report.results.iter().map(|result| result.status).collect()
+    }
+
+    #[test]
+    fn test_invalid_batch_has_no_side_effect() {
+        let target = FakeTarget {
+            unknown: vec!["salon"],
+            ..Default::default()
+        };
+        let report = execute(&target, &scene(), Some("alice"));
+
+        assert!(!report.success);
+        assert_eq!(
+            statuses(&report),
+            vec![
+                OperationStatus::Failed,
+                OperationStatus::Skipped,
+                OperationStatus::Skipped,
+                OperationStatus::Failed,
+            ]
+        );
+        assert!(report.results[0].error.is_some());
+        // Ni profil attribué, ni opération exécutée
+        assert!(target.calls.borrow().is_empty());
+    }
+
+    #[test]
+    fn test_failed_batch_rolls_back_in_reverse_order() {
+        let target = FakeTarget {
+            failing_op: Some("play"),
+            ..Default::default()
+        };
+        let report = execute(&target, &scene(), Some("alice"));
+
+        assert!(!report.success);
+        assert_eq!(
+            statuses(&report),
+            vec![
+                OperationStatus::RolledBack,
+                OperationStatus::RolledBack,
+                OperationStatus::Failed,
+                OperationStatus::Skipped,
+            ]
+        );
+        assert_eq!(
+            *target.calls.borrow(),
+            vec![
+                "profile cuisine alice",
+                "set_volume salon",
+                "set_mute cuisine",
+                "play cuisine",
+                "undo Mute(false) cuisine",
+                "undo Volume(40) salon",
+            ]
+        );
+    }
+
+    #[test]
+    fn test_successful_batch_keeps_changes() {
+        let target = FakeTarget::default();
+        let report = execute(&target, &scene(), None);
+
+        assert!(report.success);
+        assert!(
+            statuses(&report)
+                .iter()
+                .all(|status| *status == OperationStatus::Ok)
+        );
+        assert!(
+            target
+                .calls
+                .borrow()
+                .iter()
+                .all(|call| !call.starts_with("undo") && !call.starts_with("profile"))
+        );
+    }
+
+    #[test]
+    fn test_batch_operations_format() {
+        let ops: Vec<BatchOperation> = serde_json::from_str(
+            r#"[
+                {"op": "set_volume", "renderer_id": "uuid:salon", "volume": 15},
+                {"op": "load_queue", "renderer_id": "uuid:cuisine",
+                 "server_id": "uuid:pmomusic", "object_id": "playlist:matin"},
+                {"op": "play", "renderer_id": "uuid:cuisine"}
+            ]"#,
+        )
+        .unwrap();
+        assert_eq!(
+            ops[0],
+            BatchOperation::SetVolume {
+                renderer_id: "uuid:salon".to_string(),
+                volume: 15
+            }
+        );
+        assert_eq!(ops[1].name(), "load_queue");
+        assert_eq!(ops[2].renderer_id(), "uuid:cuisine");
+        assert!(serde_json::from_str::<BatchOperation>(r#"{"op": "reboot"}"#).is_err());
+    }
+}
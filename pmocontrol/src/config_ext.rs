@@ -0,0 +1,475 @@
+//! Extension pour intégrer la configuration du control point dans pmoconfig
+//!
+//! Ce module fournit le trait `ControlPointConfigExt` qui regroupe les
+//! réglages du control point : Wake-on-LAN, groupes de renderers
+//! (voir [`crate::groups`]), notifications (voir [`crate::notifications`]),
+//! reprise de lecture des contenus longs (voir [`crate::resume`]),
+//! historique d'écoute (voir [`crate::history`]), réveils (voir
+//! [`crate::alarms`]), hooks (voir [`crate::hooks`]) et service gRPC (voir
+//! `crate::grpc`) :
+//!
+//! ```yaml
+//! host:
+//!   control_point:
+//!     wol:
+//!       enabled: true
+//!       wait: 30s                        # attente maximale du retour en ligne
+//!       broadcast: ["255.255.255.255:9"]
+//!       renderers:                       # nom convivial ou UDN -> adresse MAC
+//!         bedroom: "aa:bb:cc:dd:ee:ff"
+//!       learned: {}                      # adresses apprises à la découverte
+//!     groups:                            # nom -> membres (id, UDN ou nom convivial)
+//!       rez-de-chaussee: ["Salon", "Cuisine"]
+//!     notifications:
+//!       enabled: false
+//!       events: [track_change, error]
+//!       renderers: []                    # vide = tous les renderers
+//!       min_interval: 5s
+//!       endpoints: []                    # ntfy, gotify ou webhook
+//!     resume:
+//!       enabled: true
+//!       classes: [audiobook, podcast]
+//!       min_duration: 20m                # morceau plus long = livre audio (0 = jamais)
+//!       directory: resume                # positions.json
+//!     history:
+//!       enabled: true
+//!       max_entries: 500                 # par profil d'écoute
+//!       directory: history               # history.json
+//!     alarms:
+//!       enabled: true
+//!       entries: []                      # réveils et lectures programmées
+//!     hooks:
+//!       enabled: true
+//!       rules: []                        # règles déclenchées par les événements
+//!     grpc:
+//!       enabled: false
+//!       listen: "127.0.0.1:50051"
+//! ```
+
+use anyhow::{Result, anyhow};
+use pmoconfig::Config;
+use serde::de::DeserializeOwned;
+use serde_yaml::{Mapping, Value};
+use std::net::SocketAddr;
+use std::time::Duration;
+
+use crate::alarms::Alarm;
+use crate::groups::RendererGroup;
+use crate::history::HistorySettings;
+use crate::hooks::Hook;
+use crate::notifications::{NotificationKind, NotificationSettings};
+use crate::resume::{ResumeClass, ResumeSettings};
+use crate::wol::MacAddress;
+
+const DEFAULT_WOL_ENABLED: bool = true;
+const DEFAULT_WOL_WAIT: Duration = Duration::from_secs(30);
+const DEFAULT_WOL_BROADCAST: &str = "255.255.255.255:9";
+const DEFAULT_NOTIFICATION_MIN_INTERVAL: Duration = Duration::from_secs(5);
+const DEFAULT_RESUME_MIN_DURATION: Duration = Duration::from_secs(20 * 60);
+const DEFAULT_RESUME_DIRECTORY: &str = "resume";
+const DEFAULT_HISTORY_MAX_ENTRIES: u64 = 500;
+const DEFAULT_HISTORY_DIRECTORY: &str = "history";
+const DEFAULT_GRPC_LISTEN: &str = "127.0.0.1:50051";
+
+/// Cible Wake-on-LAN résolue depuis la configuration
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct WolTarget {
+    /// Nom sous lequel la cible a été trouvée
+    pub name: String,
+    pub mac: MacAddress,
+}
+
+/// Trait d'extension pour gérer la configuration du control point dans pmoconfig.
+pub trait ControlPointConfigExt {
+    /// Indique si le Wake-on-LAN est activé (default: true)
+    fn get_wol_enabled(&self) -> Result<bool>;
+
+    /// Active ou désactive le Wake-on-LAN
+    fn set_wol_enabled(&self, enabled: bool) -> Result<()>;
+
+    /// Attente maximale du retour en ligne d'un renderer réveillé (default: 30s)
+    fn get_wol_wait(&self) -> Result<Duration>;
+
+    /// Adresses de diffusion des paquets magiques (default: 255.255.255.255:9)
+    fn get_wol_broadcast_addresses(&self) -> Result<Vec<SocketAddr>>;
+
+    /// Associe une adresse MAC à un renderer (nom convivial ou UDN)
+    fn set_wol_renderer_mac(&self, name: &str, mac: &MacAddress) -> Result<()>;
+
+    /// Adresse MAC apprise pour un UDN
+    fn get_wol_learned_mac(&self, udn: &str) -> Result<Option<MacAddress>>;
+
+    /// Mémorise l'adresse MAC d'un renderer découvert
+    ///
+    /// La configuration n'est réécrite que si l'adresse ou le nom a changé.
+    fn set_wol_learned_mac(&self, udn: &str, friendly_name: &str, mac: &MacAddress) -> Result<()>;
+
+    /// Cherche l'adresse MAC d'un renderer par UDN ou nom convivial
+    ///
+    /// Les associations explicites de `renderers` priment sur les adresses
+    /// apprises ; la comparaison ignore la casse.
+    fn find_wol_target(&self, name_or_udn: &str) -> Result<Option<WolTarget>>;
+
+    /// Groupes de renderers, triés par nom
+    fn get_renderer_groups(&self) -> Result<Vec<RendererGroup>>;
+
+    /// Crée ou remplace un groupe de renderers
+    fn set_renderer_group(&self, group: &RendererGroup) -> Result<()>;
+
+    /// Supprime un groupe, `false` s'il n'existait pas
+    fn remove_renderer_group(&self, name: &str) -> Result<bool>;
+
+    /// Réglages des notifications (désactivées par défaut)
+    fn get_notification_settings(&self) -> Result<NotificationSettings>;
+
+    /// Active ou désactive les notifications
+    fn set_notifications_enabled(&self, enabled: bool) -> Result<()>;
+
+    /// Réglages de la reprise de lecture (activée par défaut)
+    fn get_resume_settings(&self) -> Result<ResumeSettings>;
+
+    /// Répertoire des positions de reprise (default: resume)
+    fn get_resume_directory(&self) -> Result<String>;
+
+    /// Réglages de l'historique d'écoute (activé par défaut)
+    fn get_history_settings(&self) -> Result<HistorySettings>;
+
+    /// Répertoire de l'historique d'écoute (default: history)
+    fn get_history_directory(&self) -> Result<String>;
+
+    /// Indique si le planificateur de réveils est activé (default: true)
+    fn get_alarms_enabled(&self) -> Result<bool>;
+
+    /// Réveils et lectures programmées, dans l'ordre de la configuration
+    fn get_alarms(&self) -> Result<Vec<Alarm>>;
+
+    /// Crée ou remplace un réveil (même `id`)
+    fn set_alarm(&self, alarm: &Alarm) -> Result<()>;
+
+    /// Supprime un réveil, `false` s'il n'existait pas
+    fn remove_alarm(&self, id: &str) -> Result<bool>;
+
+    /// Indique si les hooks sont activés (default: true)
+    fn get_hooks_enabled(&self) -> Result<bool>;
+
+    /// Règles des hooks, dans l'ordre de la configuration
+    fn get_hooks(&self) -> Result<Vec<Hook>>;
+
+    /// Indique si le service gRPC est activé (default: false)
+    fn get_grpc_enabled(&self) -> Result<bool>;
+
+    /// Adresse d'écoute du service gRPC (default: 127.0.0.1:50051)
+    fn get_grpc_listen_address(&self) -> Result<SocketAddr>;
+}
+
+fn parse_mac(path: &str, value: &Value) -> Result<MacAddress> {
+    match value {
+        Value::String(s) => s
+            .parse()
+            .map_err(|e| anyhow!("Invalid MAC address at {}: {}", path, e)),
+        other => Err(anyhow!(
+            "Invalid MAC address at {}: expected a string, got {:?}",
+            path,
+            other
+        )),
+    }
+}
+
+fn mapping_at(config: &Config, path: &[&str]) -> Mapping {
+    match config.get_value(path) {
+        Ok(Value::Mapping(map)) => map,
+        _ => Mapping::new(),
+    }
+}
+
+/// Liste `host.control_point.<section>.<key>`, `None` si absente
+fn control_point_list<T: DeserializeOwned>(
+    config: &Config,
+    section: &str,
+    key: &str,
+) -> Result<Option<Vec<T>>> {
+    let path = ["host", "control_point", section, key];
+    match config.get_value(&path) {
+        Ok(Value::Null) | Err(_) => Ok(None),
+        Ok(value @ Value::Sequence(_)) => serde_yaml::from_value(value)
+            .map(Some)
+            .map_err(|e| anyhow!("Invalid {}: {}", path.join("."), e)),
+        Ok(other) => Err(anyhow!(
+            "Invalid {}: expected a list, got {:?}",
+            path.join("."),
+            other
+        )),
+    }
+}
+
+impl ControlPointConfigExt for Config {
+    fn get_wol_enabled(&self) -> Result<bool> {
+        self.get_bool(
+            &["host", "control_point", "wol", "enabled"],
+            DEFAULT_WOL_ENABLED,
+        )
+    }
+
+    fn set_wol_enabled(&self, enabled: bool) -> Result<()> {
+        self.set_value(
+            &["host", "control_point", "wol", "enabled"],
+            Value::Bool(enabled),
+        )
+    }
+
+    fn get_wol_wait(&self) -> Result<Duration> {
+        self.get_duration(&["host", "control_point", "wol", "wait"], DEFAULT_WOL_WAIT)
+    }
+
+    fn get_wol_broadcast_addresses(&self) -> Result<Vec<SocketAddr>> {
+        let path = ["host", "control_point", "wol", "broadcast"];
+        let entries = match self.get_value(&path) {
+            Ok(Value::Sequence(seq)) if !seq.is_empty() => seq,
+            Ok(Value::String(s)) if !s.is_empty() => vec![Value::String(s)],
+            _ => vec![Value::String(DEFAULT_WOL_BROADCAST.to_string())],
+        };
+        entries
+            .iter()
+            .map(|entry| {
+                let text = entry.as_str().unwrap_or_default();
+                text.parse::<SocketAddr>()
+                    .or_else(|_| format!("{}:9", text).parse::<SocketAddr>())
+                    .map_err(|_| {
+                        anyhow!(
+                            "Invalid broadcast address at {}: {:?}",
+                            path.join("."),
+                            entry
+                        )
+                    })
+            })
+            .collect()
+    }
+
+    fn set_wol_renderer_mac(&self, name: &str, mac: &MacAddress) -> Result<()> {
+        self.set_value(
+            &["host", "control_point", "wol", "renderers", name],
+            Value::String(mac.to_string()),
+        )
+    }
+
+    fn get_wol_learned_mac(&self, udn: &str) -> Result<Option<MacAddress>> {
+        let path = ["host", "control_point", "wol", "learned", udn, "mac"];
+        match self.get_value(&path) {
+            Ok(Value::Null) | Err(_) => Ok(None),
+            Ok(value) => parse_mac(&path.join("."), &value).map(Some),
+        }
+    }
+
+    fn set_wol_learned_mac(&self, udn: &str, friendly_name: &str, mac: &MacAddress) -> Result<()> {
+        let path = ["host", "control_point", "wol", "learned", udn];
+        let mut entry = Mapping::new();
+        entry.insert("mac".into(), Value::String(mac.to_string()));
+        entry.insert("name".into(), Value::String(friendly_name.to_string()));
+        let entry = Value::Mapping(entry);
+
+        if self.get_value(&path).ok().as_ref() == Some(&entry) {
+            return Ok(());
+        }
+        self.set_value(&path, entry)
+    }
+
+    fn find_wol_target(&self, name_or_udn: &str) -> Result<Option<WolTarget>> {
+        let wanted = name_or_udn.trim().to_lowercase();
+
+        let renderers = mapping_at(self, &["host", "control_point", "wol", "renderers"]);
+        for (key, value) in &renderers {
+            if key.as_str().map(str::to_lowercase).as_deref() == Some(wanted.as_str()) {
+                let path = format!("host.control_point.wol.renderers.{}", wanted);
+                return Ok(Some(WolTarget {
+                    name: wanted,
+                    mac: parse_mac(&path, value)?,
+                }));
+            }
+        }
+
+        let learned = mapping_at(self, &["host", "control_point", "wol", "learned"]);
+        for (key, value) in &learned {
+            let udn = key.as_str().unwrap_or_default();
+            let name = value
+                .get("name")
+                .and_then(Value::as_str)
+                .unwrap_or_default();
+            if udn.to_lowercase() != wanted && name.to_lowercase() != wanted {
+                continue;
+            }
+            let path = format!("host.control_point.wol.learned.{}.mac", udn);
+            let mac = value.get("mac").unwrap_or(&Value::Null);
+            return Ok(Some(WolTarget {
+                name: if name.is_empty() { udn } else { name }.to_string(),
+                mac: parse_mac(&path, mac)?,
+            }));
+        }
+
+        Ok(None)
+    }
+
+    fn get_renderer_groups(&self) -> Result<Vec<RendererGroup>> {
+        let groups = mapping_at(self, &["host", "control_point", "groups"]);
+        let mut result = Vec::with_capacity(groups.len());
+        for (key, value) in &groups {
+            let name = key.as_str().unwrap_or_default().to_string();
+            let members = match value {
+                Value::Sequence(seq) => seq
+                    .iter()
+                    .map(|member| match member {
+                        Value::String(s) => Ok(s.clone()),
+                        other => Err(anyhow!(
+                            "Invalid member in host.control_point.groups.{}: {:?}",
+                            name,
+                            other
+                        )),
+                    })
+                    .collect::<Result<Vec<_>>>()?,
+                Value::Null => Vec::new(),
+                other => {
+                    return Err(anyhow!(
+                        "Invalid group host.control_point.groups.{}: expected a list, got {:?}",
+                        name,
+                        other
+                    ));
+                }
+            };
+            result.push(RendererGroup { name, members });
+        }
+        result.sort_by(|a, b| a.name.cmp(&b.name));
+        Ok(result)
+    }
+
+    fn set_renderer_group(&self, group: &RendererGroup) -> Result<()> {
+        let members = group.members.iter().cloned().map(Value::String).collect();
+        self.set_value(
+            &["host", "control_point", "groups", &group.name],
+            Value::Sequence(members),
+        )
+    }
+
+    fn remove_renderer_group(&self, name: &str) -> Result<bool> {
+        let mut groups = mapping_at(self, &["host", "control_point", "groups"]);
+        if groups.remove(name.to_lowercase()).is_none() {
+            return Ok(false);
+        }
+        self.set_value(&["host", "control_point", "groups"], Value::Mapping(groups))?;
+        Ok(true)
+    }
+
+    fn get_notification_settings(&self) -> Result<NotificationSettings> {
+        Ok(NotificationSettings {
+            enabled: self.get_bool(
+                &["host", "control_point", "notifications", "enabled"],
+                false,
+            )?,
+            events: control_point_list(self, "notifications", "events")?
+                .unwrap_or_else(|| vec![NotificationKind::TrackChange, NotificationKind::Error]),
+            renderers: control_point_list(self, "notifications", "renderers")?.unwrap_or_default(),
+            min_interval: self.get_duration(
+                &["host", "control_point", "notifications", "min_interval"],
+                DEFAULT_NOTIFICATION_MIN_INTERVAL,
+            )?,
+            endpoints: control_point_list(self, "notifications", "endpoints")?.unwrap_or_default(),
+        })
+    }
+
+    fn set_notifications_enabled(&self, enabled: bool) -> Result<()> {
+        self.set_value(
+            &["host", "control_point", "notifications", "enabled"],
+            Value::Bool(enabled),
+        )
+    }
+
+    fn get_resume_settings(&self) -> Result<ResumeSettings> {
+        let min_duration = self.get_duration(
+            &["host", "control_point", "resume", "min_duration"],
+            DEFAULT_RESUME_MIN_DURATION,
+        )?;
+        Ok(ResumeSettings {
+            enabled: self.get_bool(&["host", "control_point", "resume", "enabled"], true)?,
+            classes: control_point_list(self, "resume", "classes")?
+                .unwrap_or_else(|| vec![ResumeClass::Audiobook, ResumeClass::Podcast]),
+            min_duration: (!min_duration.is_zero()).then_some(min_duration),
+        })
+    }
+
+    fn get_resume_directory(&self) -> Result<String> {
+        self.get_managed_dir(
+            &["host", "control_point", "resume", "directory"],
+            DEFAULT_RESUME_DIRECTORY,
+        )
+    }
+
+    fn get_history_settings(&self) -> Result<HistorySettings> {
+        Ok(HistorySettings {
+            enabled: self.get_bool(&["host", "control_point", "history", "enabled"], true)?,
+            max_entries: self.get_uint(
+                &["host", "control_point", "history", "max_entries"],
+                DEFAULT_HISTORY_MAX_ENTRIES,
+            )? as usize,
+        })
+    }
+
+    fn get_history_directory(&self) -> Result<String> {
+        self.get_managed_dir(
+            &["host", "control_point", "history", "directory"],
+            DEFAULT_HISTORY_DIRECTORY,
+        )
+    }
+
+    fn get_alarms_enabled(&self) -> Result<bool> {
+        self.get_bool(&["host", "control_point", "alarms", "enabled"], true)
+    }
+
+    fn get_alarms(&self) -> Result<Vec<Alarm>> {
+        Ok(control_point_list(self, "alarms", "entries")?.unwrap_or_default())
+    }
+
+    fn set_alarm(&self, alarm: &Alarm) -> Result<()> {
+        alarm.validate()?;
+        let mut alarms = self.get_alarms()?;
+        match alarms.iter_mut().find(|a| a.id == alarm.id) {
+            Some(existing) => *existing = alarm.clone(),
+            None => alarms.push(alarm.clone()),
+        }
+        self.set_value(
+            &["host", "control_point", "alarms", "entries"],
+            serde_yaml::to_value(alarms)?,
+        )
+    }
+
+    fn remove_alarm(&self, id: &str) -> Result<bool> {
+        let mut alarms = self.get_alarms()?;
+        let before = alarms.len();
+        alarms.retain(|a| a.id != id);
+        if alarms.len() == before {
+            return Ok(false);
+        }
+        self.set_value(
+            &["host", "control_point", "alarms", "entries"],
+            serde_yaml::to_value(alarms)?,
+        )?;
+        Ok(true)
+    }
+
+    fn get_hooks_enabled(&self) -> Result<bool> {
+        self.get_bool(&["host", "control_point", "hooks", "enabled"], true)
+    }
+
+    fn get_hooks(&self) -> Result<Vec<Hook>> {
+        Ok(control_point_list(self, "hooks", "rules")?.unwrap_or_default())
+    }
+
+    fn get_grpc_enabled(&self) -> Result<bool> {
+        self.get_bool(&["host", "control_point", "grpc", "enabled"], false)
+    }
+
+    fn get_grpc_listen_address(&self) -> Result<SocketAddr> {
+        let path = ["host", "control_point", "grpc", "listen"];
+        let listen = self.get_string(&path, DEFAULT_GRPC_LISTEN)?;
+        listen
+            .parse::<SocketAddr>()
+            .map_err(|_| anyhow!("Invalid listen address at {}: {:?}", path.join("."), listen))
+    }
+}
@@ -1,7 +1,7 @@
 use std::io;
 use std::sync::{Arc, Mutex, RwLock};
 use std::thread;
-use std::time::Duration;
+use std::time::{Duration, Instant};
 
 use anyhow::anyhow;
 use crossbeam_channel::Receiver;
@@ -10,9 +10,15 @@ use pmoupnp::ssdp::SsdpClient;
 use quick_xml::se::to_string as to_didl_string;
 use tracing::{debug, error, info, warn};
 
+use crate::config_ext::ControlPointConfigExt;
 use crate::discovery::manager::UDNRegistry;
 use crate::errors::ControlPointError;
 use crate::events::{MediaServerEventBus, RendererEventBus};
+use crate::groups::{
+    GroupMemberMode, GroupMemberOutcome, GroupPlan, MULTIROOM_COMMAND_TIMEOUT, RendererGroup,
+    member_matches,
+};
+use crate::linkplay_client::{extract_linkplay_host, join_group_for_host, ungroup_for_host};
 use crate::media_server::{playback_item_from_entry, MediaBrowser, MusicServer};
 use crate::media_server_events::spawn_media_server_event_runtime;
 use crate::model::{MediaServerEvent, RendererEvent};
@@ -21,13 +27,21 @@ use crate::music_renderer::{MusicRenderer, PlaybackPositionInfo, PlaylistBinding
 
 use crate::{DeviceId, DeviceIdentity, DeviceOnline, PlaybackSource};
 
+use crate::history::HistoryStore;
 #[cfg(feature = "pmoserver")]
 use crate::openapi::{
     CurrentTrackMetadata, FullRendererSnapshot, QueueItem, QueueSnapshotView, RendererBindingView,
     RendererStateView,
 };
-use crate::queue::{EnqueueMode, PlaybackItem, QueueSnapshot, SyncScheduleOutcome};
+use crate::profiles::RendererProfiles;
+use crate::queue::{
+    EnqueueMode, PlaybackItem, QueueSnapshot, RepeatMode, ShuffleMode, SyncScheduleOutcome,
+};
 use crate::registry::DeviceRegistry;
+use crate::resume::ResumeStore;
+
+/// Page size used when browsing a container to play it.
+const CONTENT_BROWSE_PAGE_SIZE: u32 = 50;
 
 /// Control point minimal :
 /// - lance un SsdpClient dans un thread,
@@ -42,6 +56,9 @@ pub struct ControlPoint {
     // udn_cache: Arc<Mutex<UDNRegistry>>,
     event_bus: RendererEventBus,
     media_event_bus: MediaServerEventBus,
+    resume: Arc<ResumeStore>,
+    history: Arc<HistoryStore>,
+    profiles: Arc<RendererProfiles>,
 }
 
 impl ControlPoint {
@@ -435,6 +452,9 @@ impl ControlPoint {
             // udn_cache,
             event_bus,
             media_event_bus,
+            resume: Arc::new(ResumeStore::from_config()),
+            history: Arc::new(HistoryStore::from_config()),
+            profiles: Arc::new(RendererProfiles::new()),
         })
     }
 
@@ -473,6 +493,257 @@ impl ControlPoint {
         reg.get_server(id)
     }
 
+    /// Wakes a sleeping renderer with a Wake-on-LAN magic packet.
+    ///
+    /// The MAC address comes from `host.control_point.wol` (explicit mapping
+    /// by friendly name or UDN first, then addresses learned at discovery).
+    /// Blocks until the renderer is seen online again or the configured wait
+    /// expires; returns whether it is online.
+    pub fn wake_renderer(&self, renderer_id: &DeviceId) -> Result<bool, ControlPointError> {
+        let renderer = self.music_renderer_by_id(renderer_id).ok_or_else(|| {
+            ControlPointError::SnapshotError(format!("Renderer {} not found", renderer_id.0))
+        })?;
+        if renderer.is_online() {
+            return Ok(true);
+        }
+
+        let config = pmoconfig::get_config();
+        let wol_error = |e: anyhow::Error| ControlPointError::WakeOnLanError(e.to_string());
+        let target = match config.find_wol_target(renderer.udn()).map_err(wol_error)? {
+            Some(target) => target,
+            None => config
+                .find_wol_target(renderer.friendly_name())
+                .map_err(wol_error)?
+                .ok_or_else(|| {
+                    ControlPointError::WakeOnLanError(format!(
+                        "No MAC address known for {}",
+                        renderer.friendly_name()
+                    ))
+                })?,
+        };
+        let wait = config.get_wol_wait().map_err(wol_error)?;
+
+        info!(
+            renderer = renderer_id.0.as_str(),
+            mac = %target.mac,
+            "🔔 Waking renderer with Wake-on-LAN"
+        );
+        crate::wol::wake(&target.mac).map_err(wol_error)?;
+
+        let deadline = Instant::now() + wait;
+        while Instant::now() < deadline {
+            if renderer.is_online() {
+                info!(renderer = renderer_id.0.as_str(), "Renderer is awake");
+                return Ok(true);
+            }
+            thread::sleep(Duration::from_millis(500));
+        }
+        warn!(
+            renderer = renderer_id.0.as_str(),
+            "Renderer still offline {}s after Wake-on-LAN",
+            wait.as_secs()
+        );
+        Ok(renderer.is_online())
+    }
+
+    /// Wakes the renderer first if it is offline and Wake-on-LAN is enabled.
+    ///
+    /// Failures are only logged: the command that follows reports its own error.
+    fn wake_before_command(&self, renderer: &MusicRenderer, renderer_id: &DeviceId) {
+        if renderer.is_online() || !pmoconfig::get_config().get_wol_enabled().unwrap_or(false) {
+            return;
+        }
+        if let Err(err) = self.wake_renderer(renderer_id) {
+            warn!(
+                renderer = renderer_id.0.as_str(),
+                error = %err,
+                "Cannot wake offline renderer"
+            );
+        }
+    }
+
+    /// Renderer group declared in `host.control_point.groups`.
+    pub fn renderer_group(&self, name: &str) -> Result<RendererGroup, ControlPointError> {
+        pmoconfig::get_config()
+            .get_renderer_groups()
+            .map_err(|e| ControlPointError::ControlPoint(e.to_string()))?
+            .into_iter()
+            .find(|group| group.name.eq_ignore_ascii_case(name))
+            .ok_or_else(|| ControlPointError::ControlPoint(format!("Group {} not found", name)))
+    }
+
+    /// Discovered renderers of a group, in the group order.
+    ///
+    /// Members that have not been discovered are skipped with a warning.
+    pub fn group_members(&self, name: &str) -> Result<Vec<Arc<MusicRenderer>>, ControlPointError> {
+        let group = self.renderer_group(name)?;
+        let renderers = self.list_music_renderers();
+        let mut members: Vec<Arc<MusicRenderer>> = Vec::new();
+
+        for selector in &group.members {
+            let found = renderers
+                .iter()
+                .find(|r| member_matches(selector, &r.id(), r.udn(), r.friendly_name()));
+            match found {
+                Some(renderer) if !members.iter().any(|m| m.id() == renderer.id()) => {
+                    members.push(Arc::clone(renderer))
+                }
+                Some(_) => {}
+                None => warn!(
+                    group = group.name.as_str(),
+                    member = selector.as_str(),
+                    "Group member not discovered, skipping"
+                ),
+            }
+        }
+        Ok(members)
+    }
+
+    /// Plays the same source on every member of a group.
+    ///
+    /// Without `uri`, the current item of the first member's queue is used.
+    /// LinkPlay members are synchronised through their multiroom group, the
+    /// others receive the source individually (see [`crate::groups`]).
+    /// Offline members are woken first when Wake-on-LAN is enabled.
+    pub fn play_group(
+        &self,
+        name: &str,
+        uri: Option<&str>,
+        metadata: Option<&str>,
+    ) -> Result<Vec<GroupMemberOutcome>, ControlPointError> {
+        let members = self.group_members(name)?;
+        let Some(first) = members.first() else {
+            return Err(ControlPointError::ControlPoint(format!(
+                "Group {} has no discovered renderer",
+                name
+            )));
+        };
+
+        let (uri, metadata) = match uri {
+            Some(uri) => (uri.to_string(), metadata.unwrap_or_default().to_string()),
+            None => {
+                let (item, _) = first.peek_current()?.ok_or_else(|| {
+                    ControlPointError::QueueError(format!(
+                        "Queue of {} is empty",
+                        first.friendly_name()
+                    ))
+                })?;
+                (item.uri.clone(), playback_item_to_didl(&item))
+            }
+        };
+
+        thread::scope(|scope| {
+            for member in &members {
+                scope.spawn(move || self.wake_before_command(member, &member.id()));
+            }
+        });
+
+        let multiroom: Vec<(DeviceId, bool)> =
+            members.iter().map(|m| (m.id(), m.is_linkplay())).collect();
+        let mut plan = GroupPlan::new(&multiroom);
+        let linkplay_host = |id: &DeviceId| {
+            members
+                .iter()
+                .find(|m| &m.id() == id)
+                .and_then(|m| extract_linkplay_host(m.location()))
+        };
+
+        if let Some(leader_host) = plan.leader.as_ref().and_then(linkplay_host) {
+            for follower in plan.followers.clone() {
+                let joined = linkplay_host(&follower)
+                    .ok_or_else(|| {
+                        ControlPointError::LinkPlayError(format!("{} has no host", follower.0))
+                    })
+                    .and_then(|host| {
+                        join_group_for_host(&host, &leader_host, MULTIROOM_COMMAND_TIMEOUT)
+                    });
+                if let Err(err) = joined {
+                    warn!(
+                        renderer = follower.0.as_str(),
+                        error = %err,
+                        "Cannot join multiroom group, playing individually"
+                    );
+                    plan.demote(&follower);
+                }
+            }
+        }
+
+        let individual_uri = crate::groups::individual_uri(&uri);
+        let outcomes = thread::scope(|scope| {
+            let handles: Vec<_> = members
+                .iter()
+                .map(|member| {
+                    let mode = plan.mode_of(&member.id());
+                    let (uri, metadata, individual_uri) = (&uri, &metadata, &individual_uri);
+                    scope.spawn(move || {
+                        let result = match mode {
+                            GroupMemberMode::Leader => member.play_uri(uri, metadata),
+                            GroupMemberMode::Follower => Ok(()),
+                            GroupMemberMode::Individual => {
+                                member.play_uri(individual_uri, metadata)
+                            }
+                        };
+                        if let Err(err) = &result {
+                            self.report_playback_error(&member.id(), err);
+                        }
+                        GroupMemberOutcome {
+                            renderer_id: member.id().0,
+                            friendly_name: member.friendly_name().to_string(),
+                            mode,
+                            error: result.err().map(|e| e.to_string()),
+                        }
+                    })
+                })
+                .collect();
+            handles
+                .into_iter()
+                .map(|handle| handle.join().expect("group playback thread panicked"))
+                .collect::<Vec<_>>()
+        });
+
+        info!(
+            group = name,
+            members = outcomes.len(),
+            failed = outcomes.iter().filter(|o| o.error.is_some()).count(),
+            uri = uri.as_str(),
+            "Group playback started"
+        );
+        Ok(outcomes)
+    }
+
+    /// Stops every member of a group and dissolves its multiroom group.
+    pub fn stop_group(&self, name: &str) -> Result<Vec<GroupMemberOutcome>, ControlPointError> {
+        let members = self.group_members(name)?;
+        let multiroom: Vec<(DeviceId, bool)> =
+            members.iter().map(|m| (m.id(), m.is_linkplay())).collect();
+        let plan = GroupPlan::new(&multiroom);
+
+        let leader_host = plan.leader.as_ref().and_then(|id| {
+            members
+                .iter()
+                .find(|m| &m.id() == id)
+                .and_then(|m| extract_linkplay_host(m.location()))
+        });
+        if let Some(host) = leader_host {
+            if let Err(err) = ungroup_for_host(&host, MULTIROOM_COMMAND_TIMEOUT) {
+                warn!(group = name, error = %err, "Cannot dissolve multiroom group");
+            }
+        }
+
+        Ok(members
+            .iter()
+            .map(|member| {
+                member.mark_user_stop_requested();
+                GroupMemberOutcome {
+                    renderer_id: member.id().0,
+                    friendly_name: member.friendly_name().to_string(),
+                    mode: plan.mode_of(&member.id()),
+                    error: member.stop().err().map(|e| e.to_string()),
+                }
+            })
+            .collect())
+    }
+
     /// Clears the renderer queue while preserving the playlist binding invariant.
     ///
     /// Invariant reminder: every user-driven queue mutation must call
@@ -559,6 +830,122 @@ impl ControlPoint {
         Ok(())
     }
 
+    /// Inserts items at `position` in the renderer queue.
+    ///
+    /// Positions past the end append. The current index is shifted so that
+    /// the currently playing track stays current. Like every user-driven
+    /// mutation, this detaches any playlist binding first.
+    pub fn insert_queue_items_at(
+        &self,
+        renderer_id: &DeviceId,
+        position: usize,
+        items: Vec<PlaybackItem>,
+    ) -> Result<(), ControlPointError> {
+        self.detach_playlist_binding(renderer_id, "insert_queue_items_at");
+
+        let renderer = self.music_renderer_by_id(renderer_id).ok_or_else(|| {
+            ControlPointError::SnapshotError(format!("Renderer {} not found", renderer_id.0))
+        })?;
+
+        let snapshot = renderer.queue_snapshot()?;
+        let mut queue = snapshot.items;
+        let position = position.min(queue.len());
+        let added = items.len();
+        queue.splice(position..position, items);
+
+        let current_index = inserted_index(snapshot.current_index, position, added);
+
+        renderer.replace_queue(queue, current_index)?;
+
+        debug!(
+            renderer = renderer_id.0.as_str(),
+            position,
+            added,
+            "Inserted playback items"
+        );
+        Ok(())
+    }
+
+    /// Removes the item at `position` from the renderer queue.
+    ///
+    /// When the current track is removed, the index moves back by one so
+    /// that the following item plays next.
+    pub fn remove_queue_item(
+        &self,
+        renderer_id: &DeviceId,
+        position: usize,
+    ) -> Result<PlaybackItem, ControlPointError> {
+        self.detach_playlist_binding(renderer_id, "remove_queue_item");
+
+        let renderer = self.music_renderer_by_id(renderer_id).ok_or_else(|| {
+            ControlPointError::SnapshotError(format!("Renderer {} not found", renderer_id.0))
+        })?;
+
+        let snapshot = renderer.queue_snapshot()?;
+        let mut queue = snapshot.items;
+        if position >= queue.len() {
+            return Err(ControlPointError::QueueError(format!(
+                "Queue index {} out of range",
+                position
+            )));
+        }
+        let removed = queue.remove(position);
+
+        let current_index = removed_index(snapshot.current_index, position);
+
+        renderer.replace_queue(queue, current_index)?;
+
+        debug!(
+            renderer = renderer_id.0.as_str(),
+            position,
+            "Removed playback item"
+        );
+        Ok(removed)
+    }
+
+    /// Moves the item at `from` to `to` in the renderer queue.
+    ///
+    /// The current index follows the currently playing track.
+    pub fn move_queue_item(
+        &self,
+        renderer_id: &DeviceId,
+        from: usize,
+        to: usize,
+    ) -> Result<(), ControlPointError> {
+        self.detach_playlist_binding(renderer_id, "move_queue_item");
+
+        let renderer = self.music_renderer_by_id(renderer_id).ok_or_else(|| {
+            ControlPointError::SnapshotError(format!("Renderer {} not found", renderer_id.0))
+        })?;
+
+        let snapshot = renderer.queue_snapshot()?;
+        let mut queue = snapshot.items;
+        if from >= queue.len() || to >= queue.len() {
+            return Err(ControlPointError::QueueError(format!(
+                "Queue move {} -> {} out of range (len {})",
+                from,
+                to,
+                queue.len()
+            )));
+        }
+        let item = queue.remove(from);
+        queue.insert(to, item);
+
+        let current_index = snapshot
+            .current_index
+            .map(|cur| moved_index(cur, from, to));
+
+        renderer.replace_queue(queue, current_index)?;
+
+        debug!(
+            renderer = renderer_id.0.as_str(),
+            from,
+            to,
+            "Moved playback item"
+        );
+        Ok(())
+    }
+
     /// Shuffles the queue of a renderer and restarts playback from the first track.
     ///
     /// This method:
@@ -586,6 +973,56 @@ impl ControlPoint {
         Ok(())
     }
 
+    /// Sets the shuffle mode of a renderer's queue without interrupting playback.
+    ///
+    /// See [`MusicRenderer::set_shuffle_mode`].
+    pub fn set_shuffle_mode(
+        &self,
+        renderer_id: &DeviceId,
+        mode: ShuffleMode,
+        seed: Option<u64>,
+    ) -> Result<(), ControlPointError> {
+        let renderer = self.music_renderer_by_id(renderer_id).ok_or_else(|| {
+            ControlPointError::SnapshotError(format!("Renderer {} not found", renderer_id.0))
+        })?;
+
+        renderer.set_shuffle_mode(mode, seed)?;
+
+        debug!(
+            renderer = renderer_id.0.as_str(),
+            mode = mode.as_str(),
+            "Queue shuffle mode changed"
+        );
+
+        Ok(())
+    }
+
+    /// Sets the repeat mode of a renderer's queue.
+    ///
+    /// See [`MusicRenderer::set_repeat_mode`].
+    pub fn set_repeat_mode(
+        &self,
+        renderer_id: &DeviceId,
+        repeat: RepeatMode,
+    ) -> Result<(), ControlPointError> {
+        let renderer = self.music_renderer_by_id(renderer_id).ok_or_else(|| {
+            ControlPointError::SnapshotError(format!("Renderer {} not found", renderer_id.0))
+        })?;
+
+        renderer.set_repeat_mode(repeat)?;
+
+        debug!(
+            renderer = renderer_id.0.as_str(),
+            repeat = repeat.as_str(),
+            "Queue repeat mode changed"
+        );
+
+        // The prefetched track may no longer be the right one
+        self.prefetch_next_track(&renderer, renderer_id);
+
+        Ok(())
+    }
+
     /// Read-only snapshot of the queue items and current index for a renderer.
     ///
     /// Returns both the queue items and the current playing index.
@@ -822,6 +1259,9 @@ impl ControlPoint {
             ControlPointError::SnapshotError(format!("Renderer {} not found", renderer_id.0))
         })?;
 
+        // A sleeping renderer must be woken before SetAVTransportURI
+        self.wake_before_command(&renderer, renderer_id);
+
         // Use generic queue access (works for all backends)
         let Some((item, remaining)) = renderer.peek_current()? else {
             debug!(
@@ -856,6 +1296,7 @@ impl ControlPoint {
                 error = %err,
                 "Failed to play current item from queue"
             );
+            self.report_playback_error(renderer_id, &err);
             renderer.set_playback_source(PlaybackSource::None);
             return Err(err);
         }
@@ -912,6 +1353,7 @@ impl ControlPoint {
                 error = %err,
                 "Failed to play next item from queue"
             );
+            self.report_playback_error(renderer_id, &err);
             renderer.set_playback_source(PlaybackSource::None);
             return Err(err);
         }
@@ -943,22 +1385,16 @@ impl ControlPoint {
             return;
         }
 
-        // Get the next item from the queue using peek_current
-        let Ok(Some((_, remaining))) = renderer.peek_current() else {
+        // Get the index of the following item, honouring the repeat mode
+        let Ok(Some(next_index)) = renderer.following_index() else {
             return;
         };
 
-        if remaining == 0 {
-            return;
-        }
-
         let queue_snapshot = match renderer.queue_snapshot() {
             Ok(snapshot) => snapshot,
             Err(_) => return,
         };
 
-        // Get next item (current + 1)
-        let next_index = queue_snapshot.current_index.map(|i| i + 1).unwrap_or(0);
         let Some(next_item) = queue_snapshot.items.get(next_index) else {
             return;
         };
@@ -1054,6 +1490,7 @@ impl ControlPoint {
                 error = %err,
                 "Failed to play from queue index"
             );
+            self.report_playback_error(renderer_id, &err);
             renderer.set_playback_source(PlaybackSource::None);
             return Err(err);
         }
@@ -1077,6 +1514,137 @@ impl ControlPoint {
         Ok(())
     }
 
+    /// Playable items of a media server object.
+    ///
+    /// A container yields its children (browsed page by page), an item
+    /// yields itself. Entries without a playable resource are skipped.
+    pub fn playback_items(
+        &self,
+        server_id: &DeviceId,
+        object_id: &str,
+    ) -> anyhow::Result<Vec<PlaybackItem>> {
+        // Get server from registry
+        let server = self
+            .media_server(server_id)
+            .ok_or_else(|| anyhow!("Server {} not found", server_id.0))?;
+
+        if !server.is_online() {
+            return Err(anyhow!("Server {} is offline", server_id.0));
+        }
+
+        if !server.has_content_directory() {
+            return Err(anyhow!(
+                "Server {} does not support ContentDirectory",
+                server_id.0
+            ));
+        }
+
+        // First, get metadata for the object to determine if it's a container or item
+        let object_metadata = server.browse_object(object_id)?;
+
+        let entries = if object_metadata.is_container {
+            // For containers, browse all children with pagination
+            let mut all_entries = Vec::new();
+            let mut offset = 0u32;
+            loop {
+                let page = server.browse_children(object_id, offset, CONTENT_BROWSE_PAGE_SIZE)?;
+                let fetched = page.len() as u32;
+                all_entries.extend(page);
+                if fetched < CONTENT_BROWSE_PAGE_SIZE {
+                    break;
+                }
+                offset += fetched;
+            }
+            all_entries
+        } else {
+            // For items, use the object itself
+            vec![object_metadata]
+        };
+
+        debug!(
+            server_id = server_id.0.as_str(),
+            object_id = object_id,
+            total_entries = entries.len(),
+            containers = entries.iter().filter(|e| e.is_container).count(),
+            items_count = entries.iter().filter(|e| !e.is_container).count(),
+            "Browse returned entries"
+        );
+
+        // Convert to PlaybackItem
+        let items: Vec<PlaybackItem> = entries
+            .iter()
+            .filter_map(|entry| playback_item_from_entry(server.clone(), entry))
+            .collect();
+
+        if items.is_empty() && !entries.is_empty() {
+            warn!(
+                server_id = server_id.0.as_str(),
+                object_id = object_id,
+                total_entries = entries.len(),
+                "No playable items found - all entries were filtered out"
+            );
+        }
+
+        Ok(items)
+    }
+
+    /// Replaces the queue of a renderer with a media server object and plays it.
+    ///
+    /// Containers with several items are bound to the queue (see
+    /// [`attach_queue_to_playlist_with_options`](Self::attach_queue_to_playlist_with_options))
+    /// so that the queue follows the playlist; a single item is enqueued
+    /// and played directly.
+    pub fn play_content(
+        &self,
+        renderer_id: &DeviceId,
+        server_id: &DeviceId,
+        object_id: &str,
+    ) -> anyhow::Result<()> {
+        debug!(
+            renderer = renderer_id.0.as_str(),
+            server = server_id.0.as_str(),
+            object = object_id,
+            "play_content: fetching playback items"
+        );
+
+        let items = self.playback_items(server_id, object_id)?;
+
+        debug!(
+            renderer = renderer_id.0.as_str(),
+            item_count = items.len(),
+            "play_content: fetched items"
+        );
+
+        if items.is_empty() {
+            return Err(anyhow!("No playable content found"));
+        }
+
+        if items.len() > 1 {
+            debug!(
+                renderer = renderer_id.0.as_str(),
+                server = server_id.0.as_str(),
+                object = object_id,
+                item_count = items.len(),
+                "Auto-binding playlist to renderer queue (auto_play = true)"
+            );
+            self.attach_queue_to_playlist_with_options(
+                renderer_id,
+                server_id.clone(),
+                object_id.to_string(),
+                true,
+            )?;
+            return Ok(());
+        }
+
+        self.clear_queue(renderer_id)?;
+        self.enqueue_items(renderer_id, items)?;
+
+        // Pour les renderers OpenHome, play_current_from_queue() va gérer automatiquement
+        // la lecture depuis la playlist native si elle existe
+        self.play_current_from_queue(renderer_id)?;
+        Ok(())
+    }
+
     /// Stop playback in response to user action (e.g., Stop button in UI).
     ///
     /// This method marks the stop as user-requested to prevent automatic
@@ -1096,6 +1664,74 @@ impl ControlPoint {
         renderer.stop()
     }
 
+    /// Publishes a playback failure so that SSE clients and notifications see it.
+    fn report_playback_error(&self, renderer_id: &DeviceId, err: &ControlPointError) {
+        self.event_bus.broadcast(RendererEvent::PlaybackError {
+            id: renderer_id.clone(),
+            message: err.to_string(),
+        });
+    }
+
+    /// Starts the now-playing notification dispatcher (see [`crate::notifications`]).
+    ///
+    /// Returns `false` when notifications are disabled or have no endpoint.
+    pub fn start_notifications(&self) -> anyhow::Result<bool> {
+        crate::notifications::spawn_dispatcher(self.subscribe_events(), self.registry())
+    }
+
+    /// Starts the resume-from-position tracker (see [`crate::resume`]).
+    ///
+    /// Returns `false` when resume tracking is disabled.
+    pub fn start_resume_tracking(&self) -> anyhow::Result<bool> {
+        crate::resume::spawn_tracker(
+            self.subscribe_events(),
+            self.registry(),
+            Arc::clone(&self.resume),
+            Arc::clone(&self.profiles),
+        )
+    }
+
+    /// Saved resume positions of long content (audiobooks, podcasts).
+    pub fn resume_positions(&self) -> Arc<ResumeStore> {
+        Arc::clone(&self.resume)
+    }
+
+    /// Starts the listening history recorder (see [`crate::history`]).
+    ///
+    /// Returns `false` when the history is disabled.
+    pub fn start_history(&self) -> anyhow::Result<bool> {
+        crate::history::spawn_recorder(
+            self.subscribe_events(),
+            self.registry(),
+            Arc::clone(&self.history),
+            Arc::clone(&self.profiles),
+        )
+    }
+
+    /// Listening history of every profile.
+    pub fn history(&self) -> Arc<HistoryStore> {
+        Arc::clone(&self.history)
+    }
+
+    /// Starts the alarm scheduler (see [`crate::alarms`]).
+    ///
+    /// Returns `false` when alarms are disabled.
+    pub fn start_alarms(self: &Arc<Self>) -> anyhow::Result<bool> {
+        crate::alarms::spawn_scheduler(Arc::clone(self), self.event_bus.clone())
+    }
+
+    /// Starts the event hooks (see [`crate::hooks`]).
+    ///
+    /// Returns `false` when hooks are disabled or none is configured.
+    pub fn start_hooks(self: &Arc<Self>) -> anyhow::Result<bool> {
+        crate::hooks::spawn_dispatcher(Arc::clone(self), self.subscribe_events())
+    }
+
+    /// Listening profile each renderer currently plays for (see [`crate::profiles`]).
+    pub fn renderer_profiles(&self) -> Arc<RendererProfiles> {
+        Arc::clone(&self.profiles)
+    }
+
     /// Subscribe to renderer events emitted by the control point runtime.
     ///
     /// Each subscriber receives all future events independently.
@@ -2055,3 +2691,93 @@ fn current_track_from_playback_item(item: &PlaybackItem) -> CurrentTrackMetadata
         album_art_uri: meta.and_then(|m| m.album_art_uri.clone()),
     }
 }
+
+/// Returns the new position of the item at `index` after moving the item at
+/// `from` to `to`.
+fn moved_index(index: usize, from: usize, to: usize) -> usize {
+    if index == from {
+        to
+    } else if from < index && to >= index {
+        index - 1
+    } else if from > index && to <= index {
+        index + 1
+    } else {
+        index
+    }
+}
+
+/// Returns the current index after inserting `added` items at `position`.
+fn inserted_index(current: Option<usize>, position: usize, added: usize) -> Option<usize> {
+    match current {
+        Some(cur) if cur >= position => Some(cur + added),
+        other => other,
+    }
+}
+
+/// Returns the current index after removing the item at `position`.
+///
+/// Removing the current track moves the index back by one, so that the
+/// following item plays next; removing track 0 while it is current leaves
+/// no current index, which the queue backend also resolves to index 0.
+fn removed_index(current: Option<usize>, position: usize) -> Option<usize> {
+    match current {
+        Some(cur) if cur >= position => cur.checked_sub(1),
+        other => other,
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_inserted_index_keeps_current_track() {
+        // Insertion avant ou sur la piste courante : l'index suit la piste
+        assert_eq!(inserted_index(Some(3), 0, 2), Some(5));
+        assert_eq!(inserted_index(Some(3), 3, 1), Some(4));
+        // Insertion après : rien ne bouge
+        assert_eq!(inserted_index(Some(3), 4, 2), Some(3));
+        assert_eq!(inserted_index(None, 0, 2), None);
+    }
+
+    #[test]
+    fn test_removed_index_before_at_after_current() {
+        assert_eq!(removed_index(Some(3), 1), Some(2));
+        // Piste courante supprimée : la suivante est jouée ensuite
+        assert_eq!(removed_index(Some(3), 3), Some(2));
+        assert_eq!(removed_index(Some(3), 5), Some(3));
+        assert_eq!(removed_index(None, 0), None);
+    }
+
+    #[test]
+    fn test_removed_index_current_first_item() {
+        // Plus d'index courant : le backend reprend alors à l'index 0,
+        // c'est-à-dire à la piste qui suivait celle supprimée
+        assert_eq!(removed_index(Some(0), 0), None);
+        assert_eq!(removed_index(Some(1), 0), Some(0));
+    }
+
+    #[test]
+    fn test_moved_index_current_item() {
+        assert_eq!(moved_index(2, 2, 0), 0);
+        assert_eq!(moved_index(2, 2, 4), 4);
+        assert_eq!(moved_index(2, 2, 2), 2);
+    }
+
+    #[test]
+    fn test_moved_index_across_current() {
+        // Une piste passe de devant à derrière la piste courante
+        assert_eq!(moved_index(2, 0, 4), 1);
+        assert_eq!(moved_index(2, 1, 2), 1);
+        // Et de derrière à devant
+        assert_eq!(moved_index(2, 4, 0), 3);
+        assert_eq!(moved_index(2, 3, 2), 3);
+    }
+
+    #[test]
+    fn test_moved_index_not_crossing_current() {
+        assert_eq!(moved_index(2, 0, 1), 2);
+        assert_eq!(moved_index(2, 3, 4), 2);
+        assert_eq!(moved_index(2, 4, 3), 2);
+    }
+}
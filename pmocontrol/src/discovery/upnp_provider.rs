@@ -1,6 +1,7 @@
-use std::io::BufReader;
+use std::io::Read;
 use std::time::Duration;
 
+use pmodidl::xml_guard::{self, XmlGuardError, XmlLimits};
 use quick_xml::{Error as XmlError, Reader, events::Event};
 use thiserror::Error;
 use tracing::debug;
@@ -25,6 +26,9 @@ pub enum DescriptionError {
     #[error("XML parsing error: {0}")]
     Xml(#[from] quick_xml::Error),
 
+    #[error("Rejected description XML: {0}")]
+    Unsafe(#[from] XmlGuardError),
+
     #[error("Missing required device element: {0}")]
     MissingField(&'static str),
 }
@@ -97,10 +101,15 @@ impl ParsedDeviceDescription {
         // response: http::Response<ureq::Body>
         let (_parts, body) = response.into_parts();
 
-        // body.into_reader() -> impl Read + 'static
-        let body_reader = body.into_reader();
+        // La description vient d'un hôte quelconque du LAN : lecture bornée
+        // puis contrôle (DOCTYPE, profondeur) avant parsing.
+        let mut body_bytes = Vec::new();
+        body.into_reader()
+            .take(XmlLimits::DEFAULT.max_bytes as u64 + 1)
+            .read_to_end(&mut body_bytes)?;
+        xml_guard::check(&body_bytes)?;
 
-        let mut reader = Reader::from_reader(BufReader::new(body_reader));
+        let mut reader = Reader::from_reader(body_bytes.as_slice());
         reader.config_mut().trim_text(true);
         debug!("Parsing description XML for {} at {}", udn, location);
 
@@ -39,6 +39,8 @@ pub enum ControlPointError {
     LinkPlayError(String),
     #[error("Chromecast Error: {0}")]
     ChromecastError(String),
+    #[error("Wake-on-LAN Error: {0}")]
+    WakeOnLanError(String),
     #[error("MediaServer Error: {0}")]
     MediaServerError(String),
     #[error("Queue Error: {0}")]
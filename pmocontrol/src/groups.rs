@@ -0,0 +1,190 @@
+//! Groupes de renderers
+//!
+//! Un groupe réunit plusieurs renderers sous un nom, pour y lancer la même
+//! source d'un seul geste :
+//!
+//! ```yaml
+//! host:
+//!   control_point:
+//!     groups:
+//!       rez-de-chaussee: ["Salon", "Cuisine", "uuid:4d696e69-444c-164e-9d41-b827eb54e1f3"]
+//! ```
+//!
+//! Les membres sont désignés par identifiant, UDN ou nom convivial. Pour
+//! chaque membre, la lecture passe par le meilleur mécanisme disponible :
+//!
+//! - les renderers LinkPlay rejoignent le groupe multiroom du premier d'entre
+//!   eux, qui lit la source et la redistribue en synchronisation ;
+//! - les autres reçoivent chacun la source par `play_uri` (SetAVTransportURI
+//!   pour UPnP AV), via le flux re-servi par PMOMusic (`/transcode`) quand un
+//!   transcodeur est enregistré, pour ne solliciter l'origine qu'à travers
+//!   notre serveur.
+
+use serde::Serialize;
+use std::time::Duration;
+
+use crate::DeviceId;
+use crate::transcode;
+
+/// Délai des commandes multiroom LinkPlay (rejoindre, dissoudre)
+pub const MULTIROOM_COMMAND_TIMEOUT: Duration = Duration::from_secs(3);
+
+/// Groupe de renderers déclaré dans la configuration
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct RendererGroup {
+    pub name: String,
+    /// Identifiants, UDN ou noms conviviaux des membres
+    pub members: Vec<String>,
+}
+
+impl RendererGroup {
+    /// Indique si un renderer est désigné par un des membres du groupe
+    pub fn contains(&self, id: &DeviceId, udn: &str, friendly_name: &str) -> bool {
+        self.members
+            .iter()
+            .any(|selector| member_matches(selector, id, udn, friendly_name))
+    }
+}
+
+/// Compare un sélecteur de membre à un renderer (casse ignorée)
+pub fn member_matches(selector: &str, id: &DeviceId, udn: &str, friendly_name: &str) -> bool {
+    let selector = selector.trim();
+    selector.eq_ignore_ascii_case(&id.0)
+        || selector.eq_ignore_ascii_case(udn)
+        || selector.eq_ignore_ascii_case(friendly_name)
+}
+
+/// Rôle d'un membre lors d'une lecture en groupe
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "snake_case")]
+pub enum GroupMemberMode {
+    /// Renderer LinkPlay qui lit la source pour son groupe multiroom
+    Leader,
+    /// Renderer LinkPlay synchronisé sur le leader
+    Follower,
+    /// Renderer piloté seul, avec la même source
+    Individual,
+}
+
+impl GroupMemberMode {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            GroupMemberMode::Leader => "leader",
+            GroupMemberMode::Follower => "follower",
+            GroupMemberMode::Individual => "individual",
+        }
+    }
+}
+
+/// Répartition des membres d'un groupe entre multiroom et lecture individuelle
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct GroupPlan {
+    pub leader: Option<DeviceId>,
+    pub followers: Vec<DeviceId>,
+    pub individual: Vec<DeviceId>,
+}
+
+impl GroupPlan {
+    /// Répartit les membres, `multiroom` indiquant le support LinkPlay
+    ///
+    /// Le multiroom n'a de sens qu'à partir de deux renderers compatibles ;
+    /// le premier dans l'ordre du groupe devient leader.
+    pub fn new(members: &[(DeviceId, bool)]) -> Self {
+        let multiroom: Vec<&DeviceId> = members
+            .iter()
+            .filter(|(_, multiroom)| *multiroom)
+            .map(|(id, _)| id)
+            .collect();
+
+        if multiroom.len() < 2 {
+            return Self {
+                individual: members.iter().map(|(id, _)| id.clone()).collect(),
+                ..Default::default()
+            };
+        }
+
+        Self {
+            leader: Some(multiroom[0].clone()),
+            followers: multiroom[1..].iter().map(|id| (*id).clone()).collect(),
+            individual: members
+                .iter()
+                .filter(|(_, multiroom)| !multiroom)
+                .map(|(id, _)| id.clone())
+                .collect(),
+        }
+    }
+
+    /// Rôle d'un membre dans ce plan
+    pub fn mode_of(&self, id: &DeviceId) -> GroupMemberMode {
+        if self.leader.as_ref() == Some(id) {
+            GroupMemberMode::Leader
+        } else if self.followers.contains(id) {
+            GroupMemberMode::Follower
+        } else {
+            GroupMemberMode::Individual
+        }
+    }
+
+    /// Repasse en lecture individuelle un suiveur qui n'a pas pu rejoindre
+    pub fn demote(&mut self, id: &DeviceId) {
+        if let Some(pos) = self.followers.iter().position(|f| f == id) {
+            self.individual.push(self.followers.remove(pos));
+        }
+    }
+}
+
+/// URI envoyée aux membres lus individuellement
+///
+/// La source est re-servie par le transcodeur de PMOMusic s'il est
+/// enregistré et qu'elle n'en provient pas déjà.
+pub fn individual_uri(uri: &str) -> String {
+    match transcode::transcoder_base_url() {
+        Some(base_url) if !uri.starts_with(&base_url) => {
+            transcode::transcode_url(&base_url, uri, transcode::DEFAULT_PROFILE)
+        }
+        _ => uri.to_string(),
+    }
+}
+
+/// Résultat d'une commande de groupe pour un membre
+#[derive(Debug, Clone, Serialize)]
+pub struct GroupMemberOutcome {
+    pub renderer_id: String,
+    pub friendly_name: String,
+    pub mode: GroupMemberMode,
+    pub error: Option<String>,
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn id(s: &str) -> DeviceId {
+        DeviceId(s.to_string())
+    }
+
+    #[test]
+    fn test_group_plan() {
+        let plan = GroupPlan::new(&[(id("upnp"), false), (id("lp1"), true), (id("lp2"), true)]);
+        assert_eq!(plan.leader, Some(id("lp1")));
+        assert_eq!(plan.followers, vec![id("lp2")]);
+        assert_eq!(plan.individual, vec![id("upnp")]);
+        assert_eq!(plan.mode_of(&id("lp2")), GroupMemberMode::Follower);
+
+        let mut plan = plan;
+        plan.demote(&id("lp2"));
+        assert_eq!(plan.mode_of(&id("lp2")), GroupMemberMode::Individual);
+
+        let single = GroupPlan::new(&[(id("lp1"), true), (id("upnp"), false)]);
+        assert_eq!(single.leader, None);
+        assert_eq!(single.individual.len(), 2);
+
+        let group = RendererGroup {
+            name: "bas".into(),
+            members: vec!["salon".into(), "UUID:ABC".into()],
+        };
+        assert!(group.contains(&id("x"), "uuid:abc", "Cuisine"));
+        assert!(group.contains(&id("y"), "uuid:def", "Salon"));
+        assert!(!group.contains(&id("z"), "uuid:def", "Chambre"));
+    }
+}
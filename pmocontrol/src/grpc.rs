@@ -0,0 +1,413 @@
+//! Service gRPC du point de contrôle
+//!
+//! En plus de l'API REST, les programmes qui embarquent pmomusic comme
+//! sidecar peuvent piloter les renderers en gRPC : transport, queue, volume
+//! et flux des événements. Les définitions protobuf sont livrées dans
+//! `pmocontrol/proto/pmocontrol/v1/control.proto` ; les clients (Go, C,
+//! Python…) génèrent leur code à partir de ce fichier.
+//!
+//! Le service est compilé avec la feature `grpc` (qui demande `protoc` à la
+//! compilation) et démarré si la configuration l'active :
+//!
+//! ```yaml
+//! host:
+//!   control_point:
+//!     grpc:
+//!       enabled: true
+//!       listen: "127.0.0.1:50051"
+//! ```
+//!
+//! Le service n'a pas d'authentification : il écoute par défaut sur la
+//! boucle locale et ne doit être exposé que sur un réseau de confiance.
+
+use std::fmt::Display;
+use std::net::SocketAddr;
+use std::sync::Arc;
+
+use anyhow::Result;
+use tokio::sync::mpsc;
+use tokio_stream::wrappers::ReceiverStream;
+use tonic::{Request, Response, Status};
+use tracing::{debug, info, warn};
+
+use crate::config_ext::ControlPointConfigExt;
+use crate::control_point::ControlPoint;
+use crate::model::{RendererEvent, TrackMetadata};
+use crate::music_renderer::{MusicRenderer, PlaybackPositionInfo};
+use crate::{DeviceId, DeviceIdentity, DeviceOnline};
+
+/// Code généré depuis `control.proto`
+pub mod proto {
+    tonic::include_proto!("pmocontrol.v1");
+}
+
+use proto::renderer_control_server::{RendererControl, RendererControlServer};
+use proto::renderer_event::Detail;
+
+/// Taille du tampon d'événements par abonné
+const EVENT_BUFFER: usize = 256;
+
+/// Implémentation du service `pmocontrol.v1.RendererControl`
+#[derive(Clone)]
+pub struct RendererControlService {
+    control_point: Arc<ControlPoint>,
+}
+
+impl RendererControlService {
+    pub fn new(control_point: Arc<ControlPoint>) -> Self {
+        Self { control_point }
+    }
+
+    fn renderer(&self, renderer_id: &str) -> Result<Arc<MusicRenderer>, Status> {
+        self.control_point
+            .music_renderer_by_id(&DeviceId(renderer_id.to_string()))
+            .ok_or_else(|| Status::not_found(format!("Renderer {} not found", renderer_id)))
+    }
+
+    /// Exécute une commande bloquante (SOAP) hors du runtime async
+    async fn blocking<T, F>(&self, f: F) -> Result<Response<T>, Status>
+    where
+        T: Send + 'static,
+        F: FnOnce(Arc<ControlPoint>) -> Result<T, Status> + Send + 'static,
+    {
+        let control_point = Arc::clone(&self.control_point);
+        tokio::task::spawn_blocking(move || f(control_point))
+            .await
+            .map_err(|e| Status::internal(e.to_string()))?
+            .map(Response::new)
+    }
+}
+
+fn internal(e: impl Display) -> Status {
+    Status::internal(e.to_string())
+}
+
+fn track(metadata: TrackMetadata) -> proto::Track {
+    proto::Track {
+        title: metadata.title,
+        artist: metadata.artist,
+        album: metadata.album,
+        album_art_uri: metadata.album_art_uri,
+        duration: metadata.duration,
+        is_continuous_stream: metadata.is_continuous_stream,
+    }
+}
+
+fn position(position: PlaybackPositionInfo) -> proto::Position {
+    proto::Position {
+        rel_time: position.rel_time,
+        track_duration: position.track_duration,
+    }
+}
+
+fn renderer_state(renderer: &MusicRenderer) -> Result<proto::RendererState, Status> {
+    let state = renderer.playback_state().map_err(internal)?;
+    Ok(proto::RendererState {
+        renderer_id: renderer.id().0,
+        transport_state: state.as_str().to_string(),
+        volume: renderer.volume().ok().map(u32::from),
+        mute: renderer.mute().ok(),
+        track: renderer.last_metadata().map(track),
+        position: renderer.playback_position().ok().map(position),
+    })
+}
+
+/// Convertit un événement du bus ; le nom `kind` suit le flux SSE
+fn event_message(event: RendererEvent) -> proto::RendererEvent {
+    let kind = event.kind().to_string();
+    let (id, detail) = match event {
+        RendererEvent::StateChanged { id, state } => {
+            (id, Some(Detail::State(state.as_str().to_string())))
+        }
+        RendererEvent::PositionChanged { id, position: p } => {
+            (id, Some(Detail::Position(position(p))))
+        }
+        RendererEvent::VolumeChanged { id, volume } => {
+            (id, Some(Detail::Volume(u32::from(volume))))
+        }
+        RendererEvent::MuteChanged { id, mute } => (id, Some(Detail::Mute(mute))),
+        RendererEvent::MetadataChanged { id, metadata } => {
+            (id, Some(Detail::Track(track(metadata))))
+        }
+        RendererEvent::PlaybackError { id, message } => (id, Some(Detail::Message(message))),
+        RendererEvent::QueueUpdated { id, queue_length } => {
+            (id, Some(Detail::QueueLength(queue_length as u32)))
+        }
+        RendererEvent::StreamStateChanged { id, is_stream } => {
+            (id, Some(Detail::IsStream(is_stream)))
+        }
+        RendererEvent::PlayModeChanged {
+            id,
+            shuffle,
+            repeat,
+        } => (
+            id,
+            Some(Detail::PlayMode(proto::PlayMode {
+                shuffle: shuffle.as_str().to_string(),
+                repeat: repeat.as_str().to_string(),
+            })),
+        ),
+        RendererEvent::TimerStarted {
+            id,
+            remaining_seconds,
+            ..
+        }
+        | RendererEvent::TimerUpdated {
+            id,
+            remaining_seconds,
+            ..
+        }
+        | RendererEvent::TimerTick {
+            id,
+            remaining_seconds,
+        } => (id, Some(Detail::RemainingSeconds(remaining_seconds))),
+        RendererEvent::AlarmTriggered { id, alarm } | RendererEvent::AlarmStopped { id, alarm } => {
+            (id, Some(Detail::AlarmId(alarm)))
+        }
+        RendererEvent::Online { id, info } => (id, Some(Detail::FriendlyName(info.friendly_name))),
+        other => (other.id().clone(), None),
+    };
+    proto::RendererEvent {
+        renderer_id: id.0,
+        kind,
+        timestamp_ms: chrono::Utc::now().timestamp_millis(),
+        detail,
+    }
+}
+
+#[tonic::async_trait]
+impl RendererControl for RendererControlService {
+    async fn list_renderers(
+        &self,
+        _request: Request<proto::ListRenderersRequest>,
+    ) -> Result<Response<proto::ListRenderersResponse>, Status> {
+        let renderers = self
+            .control_point
+            .list_music_renderers()
+            .into_iter()
+            .map(|r| proto::Renderer {
+                id: r.id().0,
+                friendly_name: r.friendly_name().to_string(),
+                model_name: r.model_name().to_string(),
+                manufacturer: r.manufacturer().to_string(),
+                online: r.is_online(),
+            })
+            .collect();
+        Ok(Response::new(proto::ListRenderersResponse { renderers }))
+    }
+
+    async fn get_renderer_state(
+        &self,
+        request: Request<proto::RendererRequest>,
+    ) -> Result<Response<proto::RendererState>, Status> {
+        let renderer = self.renderer(&request.into_inner().renderer_id)?;
+        self.blocking(move |_| renderer_state(&renderer)).await
+    }
+
+    async fn play(
+        &self,
+        request: Request<proto::RendererRequest>,
+    ) -> Result<Response<proto::Empty>, Status> {
+        let renderer = self.renderer(&request.into_inner().renderer_id)?;
+        self.blocking(move |_| renderer.play().map(|_| proto::Empty {}).map_err(internal))
+            .await
+    }
+
+    async fn pause(
+        &self,
+        request: Request<proto::RendererRequest>,
+    ) -> Result<Response<proto::Empty>, Status> {
+        let renderer = self.renderer(&request.into_inner().renderer_id)?;
+        self.blocking(move |_| renderer.pause().map(|_| proto::Empty {}).map_err(internal))
+            .await
+    }
+
+    async fn stop(
+        &self,
+        request: Request<proto::RendererRequest>,
+    ) -> Result<Response<proto::Empty>, Status> {
+        let id = self.renderer(&request.into_inner().renderer_id)?.id();
+        self.blocking(move |cp| cp.user_stop(&id).map(|_| proto::Empty {}).map_err(internal))
+            .await
+    }
+
+    async fn next(
+        &self,
+        request: Request<proto::RendererRequest>,
+    ) -> Result<Response<proto::Empty>, Status> {
+        let id = self.renderer(&request.into_inner().renderer_id)?.id();
+        self.blocking(move |cp| {
+            cp.play_next_from_queue(&id)
+                .map(|_| proto::Empty {})
+                .map_err(internal)
+        })
+        .await
+    }
+
+    async fn set_volume(
+        &self,
+        request: Request<proto::SetVolumeRequest>,
+    ) -> Result<Response<proto::Empty>, Status> {
+        let request = request.into_inner();
+        if request.volume > 100 {
+            return Err(Status::invalid_argument("Volume must be between 0 and 100"));
+        }
+        let renderer = self.renderer(&request.renderer_id)?;
+        self.blocking(move |_| {
+            renderer
+                .set_volume(request.volume as u16)
+                .map(|_| proto::Empty {})
+                .map_err(internal)
+        })
+        .await
+    }
+
+    async fn set_mute(
+        &self,
+        request: Request<proto::SetMuteRequest>,
+    ) -> Result<Response<proto::Empty>, Status> {
+        let request = request.into_inner();
+        let renderer = self.renderer(&request.renderer_id)?;
+        self.blocking(move |_| {
+            renderer
+                .set_mute(request.mute)
+                .map(|_| proto::Empty {})
+                .map_err(internal)
+        })
+        .await
+    }
+
+    async fn get_queue(
+        &self,
+        request: Request<proto::RendererRequest>,
+    ) -> Result<Response<proto::Queue>, Status> {
+        let renderer = self.renderer(&request.into_inner().renderer_id)?;
+        self.blocking(move |_| {
+            let snapshot = renderer.queue_snapshot().map_err(internal)?;
+            Ok(proto::Queue {
+                items: snapshot
+                    .items
+                    .into_iter()
+                    .map(|item| proto::QueueItem {
+                        server_id: item.media_server_id.0,
+                        object_id: item.didl_id,
+                        uri: item.uri,
+                        track: item.metadata.map(track),
+                    })
+                    .collect(),
+                current_index: snapshot.current_index.map(|i| i as u32),
+            })
+        })
+        .await
+    }
+
+    async fn load_queue(
+        &self,
+        request: Request<proto::LoadQueueRequest>,
+    ) -> Result<Response<proto::Empty>, Status> {
+        let request = request.into_inner();
+        let id = self.renderer(&request.renderer_id)?.id();
+        let server_id = DeviceId(request.server_id);
+        self.blocking(move |cp| {
+            cp.play_content(&id, &server_id, &request.object_id)
+                .map(|_| proto::Empty {})
+                .map_err(internal)
+        })
+        .await
+    }
+
+    async fn clear_queue(
+        &self,
+        request: Request<proto::RendererRequest>,
+    ) -> Result<Response<proto::Empty>, Status> {
+        let id = self.renderer(&request.into_inner().renderer_id)?.id();
+        self.blocking(move |cp| {
+            cp.clear_queue(&id)
+                .map(|_| proto::Empty {})
+                .map_err(internal)
+        })
+        .await
+    }
+
+    async fn play_queue_index(
+        &self,
+        request: Request<proto::PlayQueueIndexRequest>,
+    ) -> Result<Response<proto::Empty>, Status> {
+        let request = request.into_inner();
+        let id = self.renderer(&request.renderer_id)?.id();
+        self.blocking(move |cp| {
+            cp.play_queue_index(&id, request.index as usize)
+                .map(|_| proto::Empty {})
+                .map_err(internal)
+        })
+        .await
+    }
+
+    type SubscribeEventsStream = ReceiverStream<Result<proto::RendererEvent, Status>>;
+
+    async fn subscribe_events(
+        &self,
+        request: Request<proto::SubscribeEventsRequest>,
+    ) -> Result<Response<Self::SubscribeEventsStream>, Status> {
+        let renderer_ids = request.into_inner().renderer_ids;
+        let (tx, rx) = mpsc::channel(EVENT_BUFFER);
+        let events = self.control_point.subscribe_events();
+
+        // Pont crossbeam -> tokio, jusqu'à la fermeture du flux par le client
+        tokio::task::spawn_blocking(move || {
+            while let Ok(event) = events.recv() {
+                let message = event_message(event);
+                if !renderer_ids.is_empty() && !renderer_ids.contains(&message.renderer_id) {
+                    continue;
+                }
+                if tx.blocking_send(Ok(message)).is_err() {
+                    break;
+                }
+            }
+            debug!("gRPC event subscriber disconnected");
+        });
+
+        Ok(Response::new(ReceiverStream::new(rx)))
+    }
+}
+
+/// Démarre le serveur gRPC si la configuration l'active
+///
+/// Doit être appelé depuis un runtime tokio. Retourne `false` si le service
+/// est désactivé.
+pub fn spawn_server(control_point: Arc<ControlPoint>) -> Result<bool> {
+    let config = pmoconfig::get_config();
+    if !config.get_grpc_enabled()? {
+        return Ok(false);
+    }
+    let addr: SocketAddr = config.get_grpc_listen_address()?;
+    let service = RendererControlServer::new(RendererControlService::new(control_point));
+
+    tokio::spawn(async move {
+        info!("📡 gRPC control service listening on {}", addr);
+        if let Err(e) = tonic::transport::Server::builder()
+            .add_service(service)
+            .serve(addr)
+            .await
+        {
+            warn!("gRPC control service stopped: {}", e);
+        }
+    });
+    Ok(true)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_event_message() {
+        let message = event_message(RendererEvent::VolumeChanged {
+            id: DeviceId("uuid:salon".into()),
+            volume: 42,
+        });
+        assert_eq!(message.renderer_id, "uuid:salon");
+        assert_eq!(message.kind, "volume_changed");
+        assert_eq!(message.detail, Some(Detail::Volume(42)));
+    }
+}
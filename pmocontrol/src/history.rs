@@ -0,0 +1,344 @@
+//! Historique d'écoute par profil
+//!
+//! L'enregistreur écoute les changements de piste des renderers et ajoute
+//! chaque nouvelle piste à l'historique du profil qui l'écoute (voir
+//! [`crate::profiles`]). Chaque profil garde ses `max_entries` dernières
+//! pistes, enregistrées dans `history.json` au plus toutes les
+//! [`FLUSH_INTERVAL`].
+//!
+//! ```yaml
+//! host:
+//!   control_point:
+//!     history:
+//!       enabled: true
+//!       max_entries: 500
+//!       directory: history    # history.json
+//! ```
+
+use std::collections::HashMap;
+use std::fs;
+use std::io;
+use std::path::PathBuf;
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::sync::{Arc, Mutex, RwLock};
+use std::thread;
+use std::time::{Duration, Instant};
+
+use anyhow::Result;
+use chrono::{DateTime, Utc};
+use crossbeam_channel::{Receiver, RecvTimeoutError};
+use serde::{Deserialize, Serialize};
+use tracing::{debug, info, warn};
+
+use crate::config_ext::ControlPointConfigExt;
+use crate::model::{RendererEvent, TrackMetadata};
+use crate::profiles::RendererProfiles;
+use crate::{DeviceId, DeviceIdentity, DeviceRegistry};
+
+/// Délai maximal entre deux enregistrements de `history.json`
+pub const FLUSH_INTERVAL: Duration = Duration::from_secs(30);
+
+/// Nom du fichier de l'historique dans son répertoire
+const HISTORY_FILE: &str = "history.json";
+
+/// Réglages de l'historique d'écoute
+#[derive(Debug, Clone, PartialEq)]
+pub struct HistorySettings {
+    pub enabled: bool,
+    /// Nombre de pistes gardées par profil
+    pub max_entries: usize,
+}
+
+/// Piste écoutée
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct HistoryEntry {
+    pub profile: String,
+    pub renderer_id: String,
+    pub renderer: String,
+    #[serde(default)]
+    pub uri: Option<String>,
+    pub title: String,
+    #[serde(default)]
+    pub artist: Option<String>,
+    #[serde(default)]
+    pub album: Option<String>,
+    #[serde(default)]
+    pub album_art: Option<String>,
+    pub played_at: DateTime<Utc>,
+}
+
+/// Historique d'écoute, persisté dans `history.json`
+pub struct HistoryStore {
+    path: Option<PathBuf>,
+    max_entries: usize,
+    /// Pistes dans l'ordre d'écoute (la plus récente en dernier)
+    entries: Mutex<Vec<HistoryEntry>>,
+    dirty: AtomicBool,
+}
+
+impl HistoryStore {
+    /// Charge l'historique du répertoire configuré
+    ///
+    /// En cas d'erreur, l'historique est gardé en mémoire seulement.
+    pub fn from_config() -> Self {
+        let config = pmoconfig::get_config();
+        let max_entries = config
+            .get_history_settings()
+            .map(|s| s.max_entries)
+            .unwrap_or(500);
+        match config.get_history_directory() {
+            Ok(dir) => Self::open(PathBuf::from(dir).join(HISTORY_FILE), max_entries),
+            Err(e) => {
+                warn!("Listening history will not be persisted: {}", e);
+                Self::in_memory(max_entries)
+            }
+        }
+    }
+
+    /// Charge l'historique depuis `path` (vide si le fichier n'existe pas)
+    pub fn open(path: PathBuf, max_entries: usize) -> Self {
+        let entries = match fs::read(&path) {
+            Ok(bytes) => serde_json::from_slice(&bytes).unwrap_or_else(|e| {
+                warn!("Ignoring invalid history file {}: {}", path.display(), e);
+                Vec::new()
+            }),
+            Err(e) if e.kind() == io::ErrorKind::NotFound => Vec::new(),
+            Err(e) => {
+                warn!("Cannot read history file {}: {}", path.display(), e);
+                Vec::new()
+            }
+        };
+        Self {
+            path: Some(path),
+            max_entries,
+            entries: Mutex::new(entries),
+            dirty: AtomicBool::new(false),
+        }
+    }
+
+    /// Historique non persisté
+    pub fn in_memory(max_entries: usize) -> Self {
+        Self {
+            path: None,
+            max_entries,
+            entries: Mutex::new(Vec::new()),
+            dirty: AtomicBool::new(false),
+        }
+    }
+
+    fn lock(&self) -> std::sync::MutexGuard<'_, Vec<HistoryEntry>> {
+        self.entries.lock().expect("history mutex poisoned")
+    }
+
+    /// Ajoute une piste à l'historique de son profil
+    ///
+    /// Les pistes les plus anciennes du profil au-delà de `max_entries` sont
+    /// oubliées.
+    pub fn record(&self, entry: HistoryEntry) {
+        let mut entries = self.lock();
+        let profile = entry.profile.clone();
+        entries.push(entry);
+
+        let count = entries.iter().filter(|e| e.profile == profile).count();
+        let mut excess = count.saturating_sub(self.max_entries);
+        entries.retain(|e| {
+            if excess > 0 && e.profile == profile {
+                excess -= 1;
+                return false;
+            }
+            true
+        });
+        self.dirty.store(true, Ordering::Relaxed);
+    }
+
+    /// Pistes d'un profil, les plus récentes d'abord
+    pub fn list(&self, profile: &str, limit: Option<usize>) -> Vec<HistoryEntry> {
+        self.lock()
+            .iter()
+            .rev()
+            .filter(|e| e.profile == profile)
+            .take(limit.unwrap_or(usize::MAX))
+            .cloned()
+            .collect()
+    }
+
+    /// Efface l'historique d'un profil et retourne le nombre de pistes oubliées
+    pub fn clear(&self, profile: &str) -> usize {
+        let mut entries = self.lock();
+        let before = entries.len();
+        entries.retain(|e| e.profile != profile);
+        let count = before - entries.len();
+        if count > 0 {
+            self.dirty.store(true, Ordering::Relaxed);
+        }
+        count
+    }
+
+    /// Enregistre l'historique s'il a changé
+    pub fn flush(&self) -> io::Result<()> {
+        let Some(path) = &self.path else {
+            return Ok(());
+        };
+        if !self.dirty.swap(false, Ordering::Relaxed) {
+            return Ok(());
+        }
+
+        let json = serde_json::to_vec_pretty(&*self.lock()).map_err(io::Error::other)?;
+        let tmp = path.with_extension("json.tmp");
+        let result = fs::write(&tmp, json).and_then(|_| fs::rename(&tmp, path));
+        if result.is_err() {
+            self.dirty.store(true, Ordering::Relaxed);
+        }
+        result
+    }
+}
+
+/// Enregistreur des pistes écoutées
+struct Recorder {
+    registry: Arc<RwLock<DeviceRegistry>>,
+    store: Arc<HistoryStore>,
+    profiles: Arc<RendererProfiles>,
+    /// Dernière URI vue par renderer
+    current_uris: HashMap<DeviceId, String>,
+    /// Dernière piste enregistrée par renderer : (titre, artiste)
+    last_tracks: HashMap<DeviceId, (String, String)>,
+}
+
+impl Recorder {
+    fn track_change(&mut self, id: DeviceId, metadata: TrackMetadata) {
+        let Some(title) = metadata.title.filter(|t| !t.is_empty()) else {
+            return;
+        };
+        let artist = metadata.artist.clone().unwrap_or_default();
+        let track = (title.clone(), artist);
+        if self.last_tracks.get(&id) == Some(&track) {
+            return;
+        }
+        let Some(renderer) = self.registry.read().ok().and_then(|r| r.get_renderer(&id)) else {
+            return;
+        };
+        self.last_tracks.insert(id.clone(), track);
+
+        let profile = self.profiles.current(&renderer);
+        debug!("📜 {} played '{}' for profile {}", id.0, title, profile);
+        self.store.record(HistoryEntry {
+            profile,
+            renderer_id: id.0.clone(),
+            renderer: renderer.friendly_name().to_string(),
+            uri: self.current_uris.get(&id).cloned(),
+            title,
+            artist: metadata.artist,
+            album: metadata.album,
+            album_art: metadata.album_art_uri,
+            played_at: Utc::now(),
+        });
+    }
+
+    fn handle(&mut self, event: RendererEvent) {
+        match event {
+            RendererEvent::MetadataChanged { id, metadata } => self.track_change(id, metadata),
+            RendererEvent::PositionChanged { id, position } => {
+                if let Some(uri) = position.track_uri.filter(|uri| !uri.is_empty()) {
+                    self.current_uris.insert(id, uri);
+                }
+            }
+            RendererEvent::Offline { id } => {
+                self.current_uris.remove(&id);
+                self.last_tracks.remove(&id);
+            }
+            _ => {}
+        }
+    }
+}
+
+/// Démarre l'enregistreur si l'historique est activé
+///
+/// Retourne `false` s'il est désactivé.
+pub fn spawn_recorder(
+    events: Receiver<RendererEvent>,
+    registry: Arc<RwLock<DeviceRegistry>>,
+    store: Arc<HistoryStore>,
+    profiles: Arc<RendererProfiles>,
+) -> Result<bool> {
+    let settings = pmoconfig::get_config().get_history_settings()?;
+    if !settings.enabled || settings.max_entries == 0 {
+        return Ok(false);
+    }
+    info!(
+        "📜 Listening history enabled ({} entries per profile)",
+        settings.max_entries
+    );
+
+    let mut recorder = Recorder {
+        registry,
+        store,
+        profiles,
+        current_uris: HashMap::new(),
+        last_tracks: HashMap::new(),
+    };
+
+    thread::Builder::new()
+        .name("cp-history".into())
+        .spawn(move || {
+            let mut last_flush = Instant::now();
+            loop {
+                match events.recv_timeout(FLUSH_INTERVAL) {
+                    Ok(event) => recorder.handle(event),
+                    Err(RecvTimeoutError::Timeout) => {}
+                    Err(RecvTimeoutError::Disconnected) => break,
+                }
+                if last_flush.elapsed() >= FLUSH_INTERVAL {
+                    if let Err(e) = recorder.store.flush() {
+                        warn!("Failed to save listening history: {}", e);
+                    }
+                    last_flush = Instant::now();
+                }
+            }
+            if let Err(e) = recorder.store.flush() {
+                warn!("Failed to save listening history: {}", e);
+            }
+        })?;
+    Ok(true)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn entry(profile: &str, title: &str) -> HistoryEntry {
+        HistoryEntry {
+            profile: profile.to_string(),
+            renderer_id: "uuid:salon".to_string(),
+            renderer: "Salon".to_string(),
+            uri: None,
+            title: title.to_string(),
+            artist: None,
+            album: None,
+            album_art: None,
+            played_at: Utc::now(),
+        }
+    }
+
+    #[test]
+    fn test_history_per_profile() {
+        let store = HistoryStore::in_memory(2);
+        store.record(entry("alice", "A1"));
+        store.record(entry("bob", "B1"));
+        store.record(entry("alice", "A2"));
+        store.record(entry("alice", "A3"));
+
+        let titles = |profile| {
+            store
+                .list(profile, None)
+                .into_iter()
+                .map(|e| e.title)
+                .collect::<Vec<_>>()
+        };
+        assert_eq!(titles("alice"), vec!["A3", "A2"]);
+        assert_eq!(titles("bob"), vec!["B1"]);
+
+        assert_eq!(store.clear("alice"), 2);
+        assert!(titles("alice").is_empty());
+        assert_eq!(titles("bob"), vec!["B1"]);
+    }
+}
@@ -0,0 +1,412 @@
+//! Conditions des hooks
+//!
+//! Petit langage d'expressions sans effet de bord, évalué sur les variables
+//! d'un événement :
+//!
+//! ```text
+//! state == "PLAYING" && previous_state != "PLAYING"
+//! volume > 40 || (hour >= 22 && !mute)
+//! title contains "live"
+//! ```
+//!
+//! Opérateurs : `||`, `&&`, `!`, `==`, `!=`, `<`, `<=`, `>`, `>=` et
+//! `contains` (sous-chaîne, sans tenir compte de la casse). Les littéraux
+//! sont des nombres, des chaînes entre guillemets, `true`, `false` et
+//! `null`. Une variable absente vaut `null`.
+//!
+//! Les expressions sont compilées au chargement de la configuration ; leur
+//! taille et leur profondeur sont bornées.
+
+use std::collections::HashMap;
+use std::fmt;
+
+use anyhow::{Result, anyhow, bail};
+
+/// Longueur maximale d'une expression
+const MAX_LENGTH: usize = 1024;
+
+/// Profondeur maximale d'imbrication
+const MAX_DEPTH: usize = 32;
+
+/// Valeur d'une variable ou d'un littéral
+#[derive(Debug, Clone, PartialEq)]
+pub enum Value {
+    Null,
+    Bool(bool),
+    Number(f64),
+    Text(String),
+}
+
+impl Value {
+    fn truthy(&self) -> bool {
+        match self {
+            Value::Null => false,
+            Value::Bool(b) => *b,
+            Value::Number(n) => *n != 0.0,
+            Value::Text(s) => !s.is_empty(),
+        }
+    }
+
+    fn as_number(&self) -> Option<f64> {
+        match self {
+            Value::Number(n) => Some(*n),
+            Value::Text(s) => s.trim().parse().ok(),
+            _ => None,
+        }
+    }
+}
+
+impl fmt::Display for Value {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            Value::Null => Ok(()),
+            Value::Bool(b) => write!(f, "{}", b),
+            Value::Number(n) => write!(f, "{}", n),
+            Value::Text(s) => f.write_str(s),
+        }
+    }
+}
+
+impl From<&str> for Value {
+    fn from(value: &str) -> Self {
+        Value::Text(value.to_string())
+    }
+}
+
+impl From<String> for Value {
+    fn from(value: String) -> Self {
+        Value::Text(value)
+    }
+}
+
+impl From<bool> for Value {
+    fn from(value: bool) -> Self {
+        Value::Bool(value)
+    }
+}
+
+impl From<u32> for Value {
+    fn from(value: u32) -> Self {
+        Value::Number(f64::from(value))
+    }
+}
+
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+enum Op {
+    Eq,
+    Ne,
+    Lt,
+    Le,
+    Gt,
+    Ge,
+    Contains,
+}
+
+#[derive(Debug, Clone, PartialEq)]
+enum Token {
+    Ident(String),
+    Literal(Value),
+    Op(Op),
+    And,
+    Or,
+    Not,
+    Open,
+    Close,
+}
+
+#[derive(Debug, Clone, PartialEq)]
+enum Node {
+    Literal(Value),
+    Var(String),
+    Not(Box<Node>),
+    And(Box<Node>, Box<Node>),
+    Or(Box<Node>, Box<Node>),
+    Cmp(Op, Box<Node>, Box<Node>),
+}
+
+/// Condition compilée
+#[derive(Debug, Clone, PartialEq)]
+pub struct Expr {
+    source: String,
+    root: Node,
+}
+
+impl Expr {
+    /// Compile une expression
+    pub fn parse(source: &str) -> Result<Self> {
+        if source.len() > MAX_LENGTH {
+            bail!("Expression longer than {} characters", MAX_LENGTH);
+        }
+        let tokens = tokenize(source)?;
+        let mut parser = Parser {
+            tokens,
+            pos: 0,
+            depth: 0,
+        };
+        let root = parser.or()?;
+        if parser.pos < parser.tokens.len() {
+            bail!("Unexpected {:?} in {:?}", parser.tokens[parser.pos], source);
+        }
+        Ok(Self {
+            source: source.to_string(),
+            root,
+        })
+    }
+
+    /// Texte de l'expression
+    pub fn source(&self) -> &str {
+        &self.source
+    }
+
+    /// Évalue l'expression ; le résultat est sa valeur de vérité
+    pub fn matches(&self, variables: &HashMap<&str, Value>) -> bool {
+        eval(&self.root, variables).truthy()
+    }
+}
+
+fn tokenize(source: &str) -> Result<Vec<Token>> {
+    let chars: Vec<char> = source.chars().collect();
+    let mut tokens = Vec::new();
+    let mut i = 0;
+    while i < chars.len() {
+        let c = chars[i];
+        let next = chars.get(i + 1).copied();
+        match c {
+            c if c.is_whitespace() => i += 1,
+            '(' => {
+                tokens.push(Token::Open);
+                i += 1;
+            }
+            ')' => {
+                tokens.push(Token::Close);
+                i += 1;
+            }
+            '&' if next == Some('&') => {
+                tokens.push(Token::And);
+                i += 2;
+            }
+            '|' if next == Some('|') => {
+                tokens.push(Token::Or);
+                i += 2;
+            }
+            '=' if next == Some('=') => {
+                tokens.push(Token::Op(Op::Eq));
+                i += 2;
+            }
+            '!' if next == Some('=') => {
+                tokens.push(Token::Op(Op::Ne));
+                i += 2;
+            }
+            '!' => {
+                tokens.push(Token::Not);
+                i += 1;
+            }
+            '<' | '>' => {
+                let inclusive = next == Some('=');
+                tokens.push(Token::Op(match (c, inclusive) {
+                    ('<', false) => Op::Lt,
+                    ('<', true) => Op::Le,
+                    ('>', false) => Op::Gt,
+                    _ => Op::Ge,
+                }));
+                i += if inclusive { 2 } else { 1 };
+            }
+            '"' | '\'' => {
+                let end = chars[i + 1..]
+                    .iter()
+                    .position(|&ch| ch == c)
+                    .ok_or_else(|| anyhow!("Unterminated string in {:?}", source))?;
+                let text: String = chars[i + 1..i + 1 + end].iter().collect();
+                tokens.push(Token::Literal(Value::Text(text)));
+                i += end + 2;
+            }
+            c if c.is_ascii_digit() || (c == '-' && next.is_some_and(|n| n.is_ascii_digit())) => {
+                let start = i;
+                i += 1;
+                while i < chars.len() && (chars[i].is_ascii_digit() || chars[i] == '.') {
+                    i += 1;
+                }
+                let text: String = chars[start..i].iter().collect();
+                let number = text
+                    .parse()
+                    .map_err(|_| anyhow!("Invalid number {:?} in {:?}", text, source))?;
+                tokens.push(Token::Literal(Value::Number(number)));
+            }
+            c if c.is_alphabetic() || c == '_' => {
+                let start = i;
+                while i < chars.len() && (chars[i].is_alphanumeric() || chars[i] == '_') {
+                    i += 1;
+                }
+                let word: String = chars[start..i].iter().collect();
+                tokens.push(match word.as_str() {
+                    "true" => Token::Literal(Value::Bool(true)),
+                    "false" => Token::Literal(Value::Bool(false)),
+                    "null" => Token::Literal(Value::Null),
+                    "contains" => Token::Op(Op::Contains),
+                    _ => Token::Ident(word),
+                });
+            }
+            _ => bail!("Unexpected character {:?} in {:?}", c, source),
+        }
+    }
+    Ok(tokens)
+}
+
+struct Parser {
+    tokens: Vec<Token>,
+    pos: usize,
+    depth: usize,
+}
+
+impl Parser {
+    fn peek(&self) -> Option<&Token> {
+        self.tokens.get(self.pos)
+    }
+
+    fn or(&mut self) -> Result<Node> {
+        let mut left = self.and()?;
+        while self.peek() == Some(&Token::Or) {
+            self.pos += 1;
+            left = Node::Or(Box::new(left), Box::new(self.and()?));
+        }
+        Ok(left)
+    }
+
+    fn and(&mut self) -> Result<Node> {
+        let mut left = self.not()?;
+        while self.peek() == Some(&Token::And) {
+            self.pos += 1;
+            left = Node::And(Box::new(left), Box::new(self.not()?));
+        }
+        Ok(left)
+    }
+
+    fn not(&mut self) -> Result<Node> {
+        if self.peek() == Some(&Token::Not) {
+            self.pos += 1;
+            return self.nested(|parser| Ok(Node::Not(Box::new(parser.not()?))));
+        }
+        self.comparison()
+    }
+
+    fn comparison(&mut self) -> Result<Node> {
+        let left = self.primary()?;
+        if let Some(Token::Op(op)) = self.peek().cloned() {
+            self.pos += 1;
+            let right = self.primary()?;
+            return Ok(Node::Cmp(op, Box::new(left), Box::new(right)));
+        }
+        Ok(left)
+    }
+
+    fn primary(&mut self) -> Result<Node> {
+        let token = self
+            .peek()
+            .cloned()
+            .ok_or_else(|| anyhow!("Unexpected end of expression"))?;
+        self.pos += 1;
+        match token {
+            Token::Literal(value) => Ok(Node::Literal(value)),
+            Token::Ident(name) => Ok(Node::Var(name)),
+            Token::Open => {
+                let inner = self.nested(Parser::or)?;
+                if self.peek() != Some(&Token::Close) {
+                    bail!("Missing closing parenthesis");
+                }
+                self.pos += 1;
+                Ok(inner)
+            }
+            other => bail!("Unexpected {:?}", other),
+        }
+    }
+
+    fn nested(&mut self, f: impl FnOnce(&mut Self) -> Result<Node>) -> Result<Node> {
+        self.depth += 1;
+        if self.depth > MAX_DEPTH {
+            bail!("Expression nested deeper than {} levels", MAX_DEPTH);
+        }
+        let node = f(self);
+        self.depth -= 1;
+        node
+    }
+}
+
+fn eval(node: &Node, variables: &HashMap<&str, Value>) -> Value {
+    match node {
+        Node::Literal(value) => value.clone(),
+        Node::Var(name) => variables.get(name.as_str()).cloned().unwrap_or(Value::Null),
+        Node::Not(inner) => Value::Bool(!eval(inner, variables).truthy()),
+        Node::And(left, right) => {
+            Value::Bool(eval(left, variables).truthy() && eval(right, variables).truthy())
+        }
+        Node::Or(left, right) => {
+            Value::Bool(eval(left, variables).truthy() || eval(right, variables).truthy())
+        }
+        Node::Cmp(op, left, right) => Value::Bool(compare(
+            *op,
+            &eval(left, variables),
+            &eval(right, variables),
+        )),
+    }
+}
+
+fn compare(op: Op, left: &Value, right: &Value) -> bool {
+    if op == Op::Contains {
+        return left
+            .to_string()
+            .to_lowercase()
+            .contains(&right.to_string().to_lowercase());
+    }
+    let ordering = match (left, right) {
+        (Value::Null, Value::Null) => Some(std::cmp::Ordering::Equal),
+        (Value::Null, _) | (_, Value::Null) => None,
+        (Value::Bool(a), Value::Bool(b)) => Some(a.cmp(b)),
+        _ => match (left.as_number(), right.as_number()) {
+            (Some(a), Some(b)) => a.partial_cmp(&b),
+            _ => Some(left.to_string().cmp(&right.to_string())),
+        },
+    };
+    match (op, ordering) {
+        (Op::Ne, None) => true,
+        (_, None) => false,
+        (Op::Eq, Some(o)) => o.is_eq(),
+        (Op::Ne, Some(o)) => o.is_ne(),
+        (Op::Lt, Some(o)) => o.is_lt(),
+        (Op::Le, Some(o)) => o.is_le(),
+        (Op::Gt, Some(o)) => o.is_gt(),
+        (Op::Ge, Some(o)) => o.is_ge(),
+        (Op::Contains, _) => unreachable!(),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_expressions() {
+        let vars = HashMap::from([
+            ("state", Value::from("PLAYING")),
+            ("previous_state", Value::from("STOPPED")),
+            ("volume", Value::from(45u32)),
+            ("mute", Value::from(false)),
+            ("title", Value::from("Live at Leeds")),
+        ]);
+        let check = |source: &str| Expr::parse(source).unwrap().matches(&vars);
+
+        assert!(check(
+            r#"state == "PLAYING" && previous_state != "PLAYING""#
+        ));
+        assert!(check("volume > 40 && !mute"));
+        assert!(check("volume >= 45 && volume <= 45.0"));
+        assert!(!check("(volume < 10 || mute) && true"));
+        assert!(check("title contains 'LIVE'"));
+        assert!(!check("unknown == 'x'"));
+        assert!(check("unknown != 'x' && unknown == null"));
+
+        assert!(Expr::parse("state ==").is_err());
+        assert!(Expr::parse("(state == 'x'").is_err());
+        assert!(Expr::parse("state = 'x'").is_err());
+        assert!(Expr::parse(&"!".repeat(64)).is_err());
+    }
+}
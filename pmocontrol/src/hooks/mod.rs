@@ -0,0 +1,598 @@
+//! Hooks : petites règles déclenchées par les événements des renderers
+//!
+//! Pour la logique de liaison qui ne justifie pas un plugin (allumer une
+//! prise connectée quand la lecture démarre, baisser le volume le soir…),
+//! une règle associe des événements, une condition et des actions :
+//!
+//! ```yaml
+//! host:
+//!   control_point:
+//!     hooks:
+//!       enabled: true
+//!       rules:
+//!         - name: ampli-salon
+//!           renderers: [Salon]               # id, UDN ou nom convivial ; vide = tous
+//!           events: [state_changed]          # noms du flux SSE ; vide = tous
+//!           when: 'state == "PLAYING" && previous_state != "PLAYING"'
+//!           cooldown: 30s                    # par renderer
+//!           actions:
+//!             - webhook:
+//!                 url: "http://prise-salon.local/relay/0?turn=on"
+//!                 method: GET
+//!         - name: volume-du-soir
+//!           events: [volume_changed]
+//!           when: "volume > 30 && hour >= 22"
+//!           actions:
+//!             - set_volume: 30
+//! ```
+//!
+//! Variables des conditions et des gabarits (`{{variable}}` dans l'URL et
+//! le corps d'un webhook) : `event`, `renderer`, `renderer_id`, `state`,
+//! `previous_state`, `volume`, `mute`, `title`, `artist`, `album`,
+//! `message`, `alarm`, `queue_length`, `remaining_seconds`, `is_stream`,
+//! `hour`, `minute` et `weekday` (`mon`…`sun`). L'état, le volume, la
+//! sourdine et la piste sont ceux connus au moment de l'événement.
+//!
+//! Les règles sont isolées : les conditions n'ont pas d'effet de bord (voir
+//! [`expr`]) et les actions se limitent à la liste ci-dessous, sur le
+//! renderer de l'événement. Les règles sont lues au démarrage.
+//!
+//! Une règle ne doit pas se relancer elle-même (`volume-du-soir` change le
+//! volume, ce qui émet `volume_changed`…) : pendant [`ECHO_WINDOW`] après
+//! l'exécution d'actions sur un renderer, les événements que ces actions
+//! provoquent sur ce renderer (voir [`HookAction::echoes`]) ne déclenchent
+//! aucune règle. Une règle ne se déclenche par ailleurs pas plus d'une fois
+//! par [`MIN_COOLDOWN`] et par renderer, même sans `cooldown`, ce qui borne
+//! les boucles passant par l'extérieur (webhook vers une domotique qui pilote
+//! le renderer).
+
+pub mod expr;
+
+use std::collections::{BTreeMap, HashMap};
+use std::sync::Arc;
+use std::thread;
+use std::time::{Duration, Instant};
+
+use anyhow::{Result, anyhow, bail};
+use chrono::{Datelike, Local, Timelike};
+use crossbeam_channel::Receiver;
+use serde::{Deserialize, Serialize};
+use tracing::{debug, info, warn};
+use ureq::{Agent, http};
+
+use crate::config_ext::ControlPointConfigExt;
+use crate::control_point::ControlPoint;
+use crate::groups::member_matches;
+use crate::model::RendererEvent;
+use crate::notifications::{json_escape, render_template};
+use crate::{DeviceId, DeviceIdentity};
+
+pub use expr::{Expr, Value};
+
+/// Délai d'un webhook
+const WEBHOOK_TIMEOUT: Duration = Duration::from_secs(10);
+
+/// Durée pendant laquelle les événements provoqués par les actions d'une
+/// règle sont ignorés
+pub const ECHO_WINDOW: Duration = Duration::from_secs(5);
+
+/// Délai minimal entre deux déclenchements d'une règle pour un renderer
+pub const MIN_COOLDOWN: Duration = Duration::from_secs(1);
+
+/// Action d'une règle, appliquée au renderer de l'événement
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum HookAction {
+    /// Appel HTTP (prise connectée, domotique…)
+    Webhook {
+        url: String,
+        /// `GET`, `POST` (défaut), `PUT` ou `DELETE`
+        #[serde(default, skip_serializing_if = "Option::is_none")]
+        method: Option<String>,
+        /// En-têtes ; valeurs en clair, `env:` ou chiffrées
+        #[serde(default, skip_serializing_if = "BTreeMap::is_empty")]
+        headers: BTreeMap<String, String>,
+        /// Gabarit du corps (valeurs échappées pour JSON)
+        #[serde(default, skip_serializing_if = "Option::is_none")]
+        body: Option<String>,
+    },
+    SetVolume(u16),
+    SetMute(bool),
+    Play,
+    Pause,
+    Stop,
+    Next,
+    /// Message dans le journal du serveur
+    Log(String),
+}
+
+impl HookAction {
+    /// Événements que l'action provoque sur le renderer
+    pub fn echoes(&self) -> &'static [&'static str] {
+        match self {
+            HookAction::SetVolume(_) => &["volume_changed"],
+            HookAction::SetMute(_) => &["mute_changed"],
+            HookAction::Play | HookAction::Pause | HookAction::Stop => {
+                &["state_changed", "position_changed"]
+            }
+            HookAction::Next => &[
+                "state_changed",
+                "position_changed",
+                "metadata_changed",
+                "queue_updated",
+            ],
+            HookAction::Webhook { .. } | HookAction::Log(_) => &[],
+        }
+    }
+}
+
+/// Règle déclarée dans `host.control_point.hooks.rules`
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Hook {
+    pub name: String,
+    /// Renderers concernés (id, UDN ou nom convivial), tous si vide
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub renderers: Vec<String>,
+    /// Événements déclencheurs, tous si vide
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub events: Vec<String>,
+    /// Condition (voir [`expr`]), toujours vraie si absente
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub when: Option<String>,
+    pub actions: Vec<HookAction>,
+    /// Délai minimal entre deux déclenchements pour un même renderer
+    /// ([`MIN_COOLDOWN`] au moins)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub cooldown: Option<String>,
+}
+
+impl Hook {
+    /// Vérifie la règle et compile sa condition
+    pub fn compile(&self) -> Result<CompiledHook> {
+        if self.name.trim().is_empty() {
+            bail!("Hook name must not be empty");
+        }
+        if self.actions.is_empty() {
+            bail!("Hook {} has no action", self.name);
+        }
+        for action in &self.actions {
+            match action {
+                HookAction::SetVolume(volume) if *volume > 100 => {
+                    bail!("Hook {}: volume must be between 0 and 100", self.name)
+                }
+                HookAction::Webhook { method, .. } => {
+                    if let Some(method) = method {
+                        webhook_method(method).ok_or_else(|| {
+                            anyhow!("Hook {}: invalid method {}", self.name, method)
+                        })?;
+                    }
+                }
+                _ => {}
+            }
+        }
+        let condition = self
+            .when
+            .as_deref()
+            .map(Expr::parse)
+            .transpose()
+            .map_err(|e| anyhow!("Hook {}: {}", self.name, e))?;
+        let cooldown = match self.cooldown.as_deref() {
+            None => MIN_COOLDOWN,
+            Some(value) => pmoconfig::parse_duration(value)
+                .map_err(|e| anyhow!("Hook {}: invalid cooldown: {}", self.name, e))?
+                .max(MIN_COOLDOWN),
+        };
+        Ok(CompiledHook {
+            hook: self.clone(),
+            condition,
+            cooldown,
+        })
+    }
+}
+
+/// Règle prête à être évaluée
+#[derive(Debug, Clone)]
+pub struct CompiledHook {
+    pub hook: Hook,
+    condition: Option<Expr>,
+    cooldown: Duration,
+}
+
+impl CompiledHook {
+    /// La règle se déclenche-t-elle pour cet événement ?
+    pub fn matches(&self, event: &str, variables: &HashMap<&str, Value>) -> bool {
+        (self.hook.events.is_empty() || self.hook.events.iter().any(|e| e == event))
+            && self
+                .condition
+                .as_ref()
+                .is_none_or(|condition| condition.matches(variables))
+    }
+}
+
+fn webhook_method(method: &str) -> Option<&'static str> {
+    ["GET", "POST", "PUT", "DELETE"]
+        .into_iter()
+        .find(|m| m.eq_ignore_ascii_case(method))
+}
+
+/// État connu d'un renderer, complété au fil des événements
+#[derive(Debug, Clone, Default)]
+struct RendererState {
+    state: Option<String>,
+    volume: Option<u16>,
+    mute: Option<bool>,
+    title: Option<String>,
+    artist: Option<String>,
+    album: Option<String>,
+}
+
+/// Événements attendus en retour des actions exécutées, par renderer
+#[derive(Debug, Default)]
+struct Echoes {
+    expected: HashMap<DeviceId, (Instant, Vec<&'static str>)>,
+}
+
+impl Echoes {
+    /// Attend en retour les événements des actions exécutées à `at`
+    fn expect(&mut self, id: &DeviceId, actions: &[HookAction], at: Instant) {
+        let kinds: Vec<&'static str> = actions
+            .iter()
+            .flat_map(|action| action.echoes().iter().copied())
+            .collect();
+        if kinds.is_empty() {
+            return;
+        }
+        let (until, expected) = self
+            .expected
+            .entry(id.clone())
+            .or_insert_with(|| (at, Vec::new()));
+        if *until <= at {
+            expected.clear();
+        }
+        *until = at + ECHO_WINDOW;
+        for kind in kinds {
+            if !expected.contains(&kind) {
+                expected.push(kind);
+            }
+        }
+    }
+
+    /// L'événement est-il provoqué par une action récente ?
+    fn is_echo(&mut self, id: &DeviceId, kind: &str, at: Instant) -> bool {
+        match self.expected.get(id) {
+            Some((until, _)) if *until <= at => {
+                self.expected.remove(id);
+                false
+            }
+            Some((_, expected)) => expected.contains(&kind),
+            None => false,
+        }
+    }
+}
+
+/// Exécute les actions d'une règle
+fn run_actions(
+    control_point: &ControlPoint,
+    agent: &Agent,
+    hook: &Hook,
+    id: &DeviceId,
+    variables: &[(&str, String)],
+) -> Result<()> {
+    let vars: Vec<(&str, &str)> = variables.iter().map(|(k, v)| (*k, v.as_str())).collect();
+    let renderer = || {
+        control_point
+            .music_renderer_by_id(id)
+            .ok_or_else(|| anyhow!("Renderer {} not found", id.0))
+    };
+    for action in &hook.actions {
+        match action {
+            HookAction::Webhook {
+                url,
+                method,
+                headers,
+                body,
+            } => {
+                let url = render_template(url, &vars, |v| urlencoding::encode(v).into_owned());
+                let method = method.as_deref().and_then(webhook_method).unwrap_or("POST");
+                let mut request = http::Request::builder().method(method).uri(&url);
+                for (name, value) in headers {
+                    request = request.header(name, pmoconfig::secrets::resolve_secret(value)?);
+                }
+                let response = match body {
+                    Some(template) => agent.run(
+                        request
+                            .header("Content-Type", "application/json")
+                            .body(render_template(template, &vars, json_escape))?,
+                    )?,
+                    None => agent.run(request.body(())?)?,
+                };
+                if !response.status().is_success() {
+                    bail!("{} {} returned HTTP {}", method, url, response.status());
+                }
+                debug!("🪝 Hook {}: {} {}", hook.name, method, url);
+            }
+            HookAction::SetVolume(volume) => renderer()?.set_volume(*volume)?,
+            HookAction::SetMute(mute) => renderer()?.set_mute(*mute)?,
+            HookAction::Play => renderer()?.play()?,
+            HookAction::Pause => renderer()?.pause()?,
+            HookAction::Stop => control_point.user_stop(id)?,
+            HookAction::Next => control_point.play_next_from_queue(id)?,
+            HookAction::Log(template) => {
+                info!("🪝 {}", render_template(template, &vars, |v| v.to_string()))
+            }
+        }
+    }
+    Ok(())
+}
+
+/// Évaluation des règles sur le flux d'événements
+struct Dispatcher {
+    hooks: Vec<CompiledHook>,
+    control_point: Arc<ControlPoint>,
+    agent: Agent,
+    states: HashMap<DeviceId, RendererState>,
+    /// Dernier déclenchement par (règle, renderer)
+    fired: HashMap<(String, DeviceId), Instant>,
+    echoes: Echoes,
+}
+
+impl Dispatcher {
+    fn handle(&mut self, event: RendererEvent) {
+        let id = event.id().clone();
+        let kind = event.kind();
+        let previous = self.states.get(&id).cloned().unwrap_or_default();
+        let mut extra: Vec<(&'static str, String)> = Vec::new();
+
+        let state = self.states.entry(id.clone()).or_default();
+        match &event {
+            RendererEvent::StateChanged { state: s, .. } => {
+                state.state = Some(s.as_str().to_string())
+            }
+            RendererEvent::VolumeChanged { volume, .. } => state.volume = Some(*volume),
+            RendererEvent::MuteChanged { mute, .. } => state.mute = Some(*mute),
+            RendererEvent::MetadataChanged { metadata, .. } => {
+                state.title = metadata.title.clone();
+                state.artist = metadata.artist.clone().or_else(|| metadata.creator.clone());
+                state.album = metadata.album.clone();
+            }
+            RendererEvent::PlaybackError { message, .. } => {
+                extra.push(("message", message.clone()))
+            }
+            RendererEvent::QueueUpdated { queue_length, .. } => {
+                extra.push(("queue_length", queue_length.to_string()))
+            }
+            RendererEvent::StreamStateChanged { is_stream, .. } => {
+                extra.push(("is_stream", is_stream.to_string()))
+            }
+            RendererEvent::TimerStarted {
+                remaining_seconds, ..
+            }
+            | RendererEvent::TimerUpdated {
+                remaining_seconds, ..
+            }
+            | RendererEvent::TimerTick {
+                remaining_seconds, ..
+            } => extra.push(("remaining_seconds", remaining_seconds.to_string())),
+            RendererEvent::AlarmTriggered { alarm, .. }
+            | RendererEvent::AlarmStopped { alarm, .. } => extra.push(("alarm", alarm.clone())),
+            _ => {}
+        }
+        let state = state.clone();
+        if matches!(event, RendererEvent::Offline { .. }) {
+            self.states.remove(&id);
+        }
+        if self.echoes.is_echo(&id, kind, Instant::now()) {
+            debug!("🪝 Ignoring {} on {} caused by a hook", kind, id.0);
+            return;
+        }
+
+        let (renderer, udn) = self
+            .control_point
+            .music_renderer_by_id(&id)
+            .map(|r| (r.friendly_name().to_string(), r.udn().to_string()))
+            .unwrap_or_else(|| (id.0.clone(), String::new()));
+        let now = Local::now();
+        let weekday = now.weekday().to_string().to_lowercase();
+
+        let mut text: Vec<(&str, String)> = vec![
+            ("event", kind.to_string()),
+            ("renderer", renderer.clone()),
+            ("renderer_id", id.0.clone()),
+            ("weekday", weekday),
+        ];
+        let mut optional = |name, value: Option<String>| {
+            if let Some(value) = value {
+                text.push((name, value));
+            }
+        };
+        optional("state", state.state.clone());
+        optional("previous_state", previous.state.clone());
+        optional("title", state.title.clone());
+        optional("artist", state.artist.clone());
+        optional("album", state.album.clone());
+        text.extend(extra);
+
+        let mut variables: HashMap<&str, Value> = text
+            .iter()
+            .map(|(name, value)| (*name, Value::from(value.as_str())))
+            .collect();
+        variables.insert("hour", Value::from(now.hour()));
+        variables.insert("minute", Value::from(now.minute()));
+        if let Some(volume) = state.volume {
+            variables.insert("volume", Value::from(u32::from(volume)));
+            text.push(("volume", volume.to_string()));
+        }
+        if let Some(mute) = state.mute {
+            variables.insert("mute", Value::from(mute));
+            text.push(("mute", mute.to_string()));
+        }
+        if let Some(is_stream) = variables.get("is_stream").map(|v| v.to_string() == "true") {
+            variables.insert("is_stream", Value::from(is_stream));
+        }
+        text.push(("hour", now.hour().to_string()));
+        text.push(("minute", now.minute().to_string()));
+
+        for compiled in &self.hooks {
+            let hook = &compiled.hook;
+            if !hook.renderers.is_empty()
+                && !hook
+                    .renderers
+                    .iter()
+                    .any(|selector| member_matches(selector, &id, &udn, &renderer))
+            {
+                continue;
+            }
+            if !compiled.matches(kind, &variables) {
+                continue;
+            }
+            let key = (hook.name.clone(), id.clone());
+            let at = Instant::now();
+            if let Some(last) = self.fired.get(&key)
+                && at.duration_since(*last) < compiled.cooldown
+            {
+                debug!("🪝 Hook {} on {} in cooldown", hook.name, renderer);
+                continue;
+            }
+            self.fired.insert(key, at);
+            self.echoes.expect(&id, &hook.actions, at);
+
+            info!(
+                "🪝 Hook {} triggered by {} on {}",
+                hook.name, kind, renderer
+            );
+            let control_point = Arc::clone(&self.control_point);
+            let agent = self.agent.clone();
+            let hook = hook.clone();
+            let id = id.clone();
+            let variables: Vec<(&'static str, String)> = text
+                .iter()
+                .map(|(name, value)| (*name, value.clone()))
+                .collect();
+            // Les actions (HTTP, SOAP) ne doivent pas retarder les autres événements
+            thread::spawn(move || {
+                if let Err(e) = run_actions(&control_point, &agent, &hook, &id, &variables) {
+                    warn!("🪝 Hook {} failed: {}", hook.name, e);
+                }
+            });
+        }
+    }
+}
+
+/// Démarre l'évaluation des hooks si elle est activée
+///
+/// Une règle invalide est ignorée avec un avertissement. Retourne `false`
+/// si les hooks sont désactivés ou sans règle valide.
+pub fn spawn_dispatcher(
+    control_point: Arc<ControlPoint>,
+    events: Receiver<RendererEvent>,
+) -> Result<bool> {
+    let config = pmoconfig::get_config();
+    if !config.get_hooks_enabled()? {
+        return Ok(false);
+    }
+    let hooks: Vec<CompiledHook> = config
+        .get_hooks()?
+        .iter()
+        .filter_map(|hook| match hook.compile() {
+            Ok(compiled) => Some(compiled),
+            Err(e) => {
+                warn!("Ignoring hook: {}", e);
+                None
+            }
+        })
+        .collect();
+    if hooks.is_empty() {
+        return Ok(false);
+    }
+    info!("🪝 {} hook(s) enabled", hooks.len());
+
+    let agent: Agent = Agent::config_builder()
+        .timeout_global(Some(WEBHOOK_TIMEOUT))
+        .build()
+        .into();
+    let mut dispatcher = Dispatcher {
+        hooks,
+        control_point,
+        agent,
+        states: HashMap::new(),
+        fired: HashMap::new(),
+        echoes: Echoes::default(),
+    };
+
+    thread::Builder::new()
+        .name("cp-hooks".into())
+        .spawn(move || {
+            for event in events {
+                dispatcher.handle(event);
+            }
+        })?;
+    Ok(true)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_hook_config() {
+        let hook: Hook = serde_yaml::from_str(
+            "name: ampli\nrenderers: [Salon]\nevents: [state_changed]\n\
+             when: 'state == \"PLAYING\" && previous_state != \"PLAYING\"'\n\
+             cooldown: 30s\nactions:\n  - webhook: { url: \"http://plug/on\", method: get }\n  - set_volume: 20\n  - stop\n",
+        )
+        .unwrap();
+        assert_eq!(hook.actions.len(), 3);
+        assert_eq!(hook.actions[1], HookAction::SetVolume(20));
+        assert_eq!(hook.actions[2], HookAction::Stop);
+
+        let compiled = hook.compile().unwrap();
+        assert_eq!(compiled.cooldown, Duration::from_secs(30));
+        let vars = HashMap::from([
+            ("state", Value::from("PLAYING")),
+            ("previous_state", Value::from("PAUSED")),
+        ]);
+        assert!(compiled.matches("state_changed", &vars));
+        assert!(!compiled.matches("volume_changed", &vars));
+
+        let invalid = Hook {
+            actions: vec![HookAction::SetVolume(150)],
+            ..hook
+        };
+        assert!(invalid.compile().is_err());
+    }
+
+    #[test]
+    fn test_cooldown_has_a_floor() {
+        let hook: Hook =
+            serde_yaml::from_str("name: soir\nactions:\n  - set_volume: 30\n").unwrap();
+        assert_eq!(hook.compile().unwrap().cooldown, MIN_COOLDOWN);
+        let hook = Hook {
+            cooldown: Some("100ms".to_string()),
+            ..hook
+        };
+        assert_eq!(hook.compile().unwrap().cooldown, MIN_COOLDOWN);
+    }
+
+    #[test]
+    fn test_action_echoes_are_ignored() {
+        let salon = DeviceId("salon".to_string());
+        let cuisine = DeviceId("cuisine".to_string());
+        let mut echoes = Echoes::default();
+        let at = Instant::now();
+
+        // Journal seul : rien à attendre en retour
+        echoes.expect(&salon, &[HookAction::Log("soir".to_string())], at);
+        assert!(!echoes.is_echo(&salon, "volume_changed", at));
+
+        echoes.expect(&salon, &[HookAction::SetVolume(30)], at);
+        let soon = at + Duration::from_millis(200);
+        assert!(echoes.is_echo(&salon, "volume_changed", soon));
+        assert!(!echoes.is_echo(&salon, "state_changed", soon));
+        assert!(!echoes.is_echo(&cuisine, "volume_changed", soon));
+
+        // Passé la fenêtre, l'événement vient d'ailleurs
+        let later = at + ECHO_WINDOW;
+        assert!(!echoes.is_echo(&salon, "volume_changed", later));
+        assert!(echoes.expected.is_empty());
+    }
+}
@@ -1,21 +1,32 @@
 mod events;
 mod media_server_events;
 
+pub mod alarms;
 pub mod arylic_client;
+pub mod batch;
+pub mod config_ext;
 pub mod control_point;
 pub mod discovery;
 pub mod errors;
+pub mod groups;
+pub mod history;
+pub mod hooks;
 pub mod identity;
 pub mod linkplay_client;
 pub mod linkplay_utils;
 pub mod media_server;
 pub mod model;
 pub mod music_renderer;
+pub mod notifications;
 pub mod online;
+pub mod profiles;
 pub mod queue;
 pub mod registry;
+pub mod resume;
 pub mod soap_client;
+pub mod transcode;
 pub mod upnp_clients;
+pub mod wol;
 
 // pmoserver extension (optional)
 #[cfg(feature = "pmoserver")]
@@ -24,15 +35,22 @@ pub mod openapi;
 pub mod pmoserver_ext;
 #[cfg(feature = "pmoserver")]
 pub mod sse;
+#[cfg(feature = "mediaserver-proxy")]
+pub mod media_server_proxy;
+
+// gRPC control service (optional)
+#[cfg(feature = "grpc")]
+pub mod grpc;
 
 use std::time::Duration;
 
 #[cfg(feature = "pmoserver")]
 pub use pmoserver_ext::ControlPointExt;
 
+pub use config_ext::ControlPointConfigExt;
 pub use control_point::ControlPoint;
 pub use media_server::{MediaBrowser, MediaEntry, MediaResource, UpnpMediaServer};
-pub use queue::{EnqueueMode, PlaybackItem, QueueSnapshot};
+pub use queue::{EnqueueMode, PlaybackItem, QueueSnapshot, RepeatMode, ShuffleMode};
 
 pub use model::{
     MediaServerEvent, PlaybackSource, RendererCapabilities, RendererEvent, RendererInfo,
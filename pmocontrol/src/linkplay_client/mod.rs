@@ -126,6 +126,45 @@ pub fn fetch_status_for_host(
     parse_linkplay_status(&body)
 }
 
+/// Sends a raw `httpapi.asp` command and returns the response body.
+pub fn send_command_for_host(
+    host: &str,
+    command: &str,
+    timeout: Duration,
+) -> Result<String, ControlPointError> {
+    let url = format!("http://{}/httpapi.asp?command={}", host, command);
+    let mut response = build_agent(timeout).get(&url).call().map_err(|_| {
+        ControlPointError::LinkPlayError(format!(
+            "LinkPlay command {} failed for {}",
+            command, host
+        ))
+    })?;
+
+    response.body_mut().read_to_string().map_err(|e| {
+        ControlPointError::LinkPlayError(format!("Failed to read LinkPlay response body : {}", e))
+    })
+}
+
+/// Makes `follower_host` join the multiroom group led by `leader_host`.
+///
+/// The follower then plays whatever the leader plays, in sync.
+pub fn join_group_for_host(
+    follower_host: &str,
+    leader_host: &str,
+    timeout: Duration,
+) -> Result<(), ControlPointError> {
+    let command = format!(
+        "ConnectMasterAp:JoinGroupMaster:eth{}:wifi0.0.0.0",
+        leader_host
+    );
+    send_command_for_host(follower_host, &command, timeout).map(|_| ())
+}
+
+/// Dissolves the multiroom group led by `leader_host`.
+pub fn ungroup_for_host(leader_host: &str, timeout: Duration) -> Result<(), ControlPointError> {
+    send_command_for_host(leader_host, "multiroom:Ungroup", timeout).map(|_| ())
+}
+
 fn parse_linkplay_status(body: &str) -> Result<LinkPlayStatus, ControlPointError> {
     let raw: LinkPlayStatusRaw = serde_json::from_str(body).map_err(|e| {
         ControlPointError::LinkPlayError(format!("Failed to parse LinkPlay status JSON: {}", e))
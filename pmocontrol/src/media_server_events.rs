@@ -7,7 +7,9 @@ use std::thread;
 use std::time::{Duration, Instant};
 
 use anyhow::{Context, Result};
+use pmodidl::xml_guard::{self, XmlLimits};
 use crossbeam_channel::{Receiver, Sender, unbounded};
+use pmoupnp::clock::{Clock, system_clock};
 use tracing::{debug, error, info, warn};
 use ureq::{Agent, http};
 use xmltree::{Element, XMLNode};
@@ -22,6 +24,11 @@ use crate::{DeviceId, DeviceIdentity, DeviceOnline};
 const SUBSCRIPTION_TIMEOUT_SECS: u64 = 300;
 const RENEWAL_SAFETY_MARGIN_SECS: u64 = 60;
 const HTTP_READ_TIMEOUT_SECS: u64 = 5;
+/// Whole-request deadline: a client trickling bytes (slow-loris) must not
+/// hold the single listener thread longer than this.
+const HTTP_REQUEST_DEADLINE_SECS: u64 = 10;
+const MAX_NOTIFY_HEADER_BYTES: u64 = 16 * 1024;
+const MAX_NOTIFY_BODY_BYTES: usize = 1024 * 1024;
 const WORKER_LOOP_INTERVAL_MILLIS: u64 = 250;
 const RETRY_DELAY_SECS: u64 = 15;
 const SUBSCRIPTION_RESET_DELAY_SECS: u64 = 5;
@@ -52,6 +59,7 @@ pub(crate) fn spawn_media_server_event_runtime(
         Duration::from_secs(timeout_secs.max(1)),
         notify_rx,
         listener_addr.port(),
+        system_clock(),
     );
 
     thread::Builder::new()
@@ -80,27 +88,27 @@ impl IncomingNotify {
 }
 
 /// Manages retry timing for subscription operations
+///
+/// Times are read from the worker's [`Clock`] so tests can drive them.
 struct RetryPolicy {
     retry_after: Instant,
 }
 
 impl RetryPolicy {
-    fn new() -> Self {
-        Self {
-            retry_after: Instant::now(),
-        }
+    fn new(now: Instant) -> Self {
+        Self { retry_after: now }
     }
 
-    fn should_retry(&self) -> bool {
-        Instant::now() >= self.retry_after
+    fn should_retry(&self, now: Instant) -> bool {
+        now >= self.retry_after
     }
 
-    fn defer_retry(&mut self) {
-        self.retry_after = Instant::now() + Duration::from_secs(RETRY_DELAY_SECS);
+    fn defer_retry(&mut self, now: Instant) {
+        self.retry_after = now + Duration::from_secs(RETRY_DELAY_SECS);
     }
 
-    fn schedule_soon(&mut self) {
-        self.retry_after = Instant::now() + Duration::from_secs(SUBSCRIPTION_RESET_DELAY_SECS);
+    fn schedule_soon(&mut self, now: Instant) {
+        self.retry_after = now + Duration::from_secs(SUBSCRIPTION_RESET_DELAY_SECS);
     }
 }
 
@@ -153,9 +161,16 @@ struct HttpRequest {
 }
 
 fn read_http_request(stream: &mut TcpStream) -> io::Result<HttpRequest> {
-    let mut reader = BufReader::new(stream.try_clone()?);
+    let deadline = Instant::now() + Duration::from_secs(HTTP_REQUEST_DEADLINE_SECS);
+    let mut reader = BufReader::new(DeadlineReader {
+        stream: stream.try_clone()?,
+        deadline,
+    });
+    // Request line and headers share a single byte budget
+    let mut head = (&mut reader).take(MAX_NOTIFY_HEADER_BYTES);
+
     let mut request_line = String::new();
-    if reader.read_line(&mut request_line)? == 0 {
+    if head.read_line(&mut request_line)? == 0 {
         return Err(io::Error::new(
             io::ErrorKind::UnexpectedEof,
             "missing request line",
@@ -177,8 +192,14 @@ fn read_http_request(stream: &mut TcpStream) -> io::Result<HttpRequest> {
     let mut headers = HashMap::new();
     loop {
         let mut line = String::new();
-        let len = reader.read_line(&mut line)?;
+        let len = head.read_line(&mut line)?;
         if len == 0 {
+            if head.limit() == 0 {
+                return Err(io::Error::new(
+                    io::ErrorKind::InvalidData,
+                    "request headers too large",
+                ));
+            }
             break;
         }
         let trimmed = line.trim_end_matches(&['\r', '\n'][..]);
@@ -194,6 +215,12 @@ fn read_http_request(stream: &mut TcpStream) -> io::Result<HttpRequest> {
         .get("content-length")
         .and_then(|v| v.parse().ok())
         .unwrap_or(0);
+    if content_length > MAX_NOTIFY_BODY_BYTES {
+        return Err(io::Error::new(
+            io::ErrorKind::InvalidData,
+            format!("notify body too large: {} bytes", content_length),
+        ));
+    }
 
     let mut body = vec![0u8; content_length];
     reader.read_exact(&mut body)?;
@@ -206,6 +233,27 @@ fn read_http_request(stream: &mut TcpStream) -> io::Result<HttpRequest> {
     })
 }
 
+/// Reader enforcing an absolute deadline on top of the per-read timeout.
+struct DeadlineReader {
+    stream: TcpStream,
+    deadline: Instant,
+}
+
+impl Read for DeadlineReader {
+    fn read(&mut self, buf: &mut [u8]) -> io::Result<usize> {
+        let remaining = self.deadline.saturating_duration_since(Instant::now());
+        if remaining.is_zero() {
+            return Err(io::Error::new(
+                io::ErrorKind::TimedOut,
+                "request deadline exceeded",
+            ));
+        }
+        let per_read = Duration::from_secs(HTTP_READ_TIMEOUT_SECS).min(remaining);
+        self.stream.set_read_timeout(Some(per_read))?;
+        self.stream.read(buf)
+    }
+}
+
 fn write_http_response(stream: &mut TcpStream, status: u16, message: &str) -> io::Result<()> {
     let response = format!(
         "HTTP/1.1 {} {}\r\nContent-Length: 0\r\nConnection: close\r\n\r\n",
@@ -222,6 +270,7 @@ struct MediaServerEventWorker {
     listener_port: u16,
     subscriptions: HashMap<DeviceId, SubscriptionState>,
     path_index: HashMap<String, DeviceId>,
+    clock: Arc<dyn Clock>,
 }
 
 impl MediaServerEventWorker {
@@ -231,6 +280,7 @@ impl MediaServerEventWorker {
         http_timeout: Duration,
         notify_rx: Receiver<IncomingNotify>,
         listener_port: u16,
+        clock: Arc<dyn Clock>,
     ) -> Self {
         Self {
             registry,
@@ -240,6 +290,7 @@ impl MediaServerEventWorker {
             listener_port,
             subscriptions: HashMap::new(),
             path_index: HashMap::new(),
+            clock,
         }
     }
 
@@ -248,7 +299,8 @@ impl MediaServerEventWorker {
             self.drain_notifications();
             self.refresh_servers();
             self.renew_expiring();
-            thread::sleep(Duration::from_millis(WORKER_LOOP_INTERVAL_MILLIS));
+            self.clock
+                .sleep(Duration::from_millis(WORKER_LOOP_INTERVAL_MILLIS));
         }
     }
 
@@ -278,16 +330,17 @@ impl MediaServerEventWorker {
             }
 
             active.insert(server.id());
+            let now = self.clock.now();
             let entry = self
                 .subscriptions
                 .entry(server.id())
-                .or_insert_with(|| SubscriptionState::from_music_server(&server));
-            entry.update_from_server(&server);
+                .or_insert_with(|| SubscriptionState::from_music_server(&server, now));
+            entry.update_from_server(&server, now);
             self.path_index
                 .insert(entry.callback_path.clone(), entry.device_id.clone());
 
             if entry.event_sub_url.is_none() {
-                if entry.retry_policy.should_retry() {
+                if entry.retry_policy.should_retry(now) {
                     match fetch_event_sub_url(&entry.location, self.http_timeout) {
                         Ok(Some(url)) => {
                             debug!(
@@ -296,14 +349,14 @@ impl MediaServerEventWorker {
                                 "ContentDirectory eventSub URL resolved"
                             );
                             entry.event_sub_url = Some(url);
-                            entry.retry_policy = RetryPolicy::new();
+                            entry.retry_policy = RetryPolicy::new(self.clock.now());
                         }
                         Ok(None) => {
                             debug!(
                                 server = entry.friendly_name.as_str(),
                                 "No ContentDirectory eventSub URL found"
                             );
-                            entry.retry_policy.defer_retry();
+                            entry.retry_policy.defer_retry(self.clock.now());
                             continue;
                         }
                         Err(err) => {
@@ -312,7 +365,7 @@ impl MediaServerEventWorker {
                                 error = %err,
                                 "Failed to fetch ContentDirectory eventSub URL"
                             );
-                            entry.retry_policy.defer_retry();
+                            entry.retry_policy.defer_retry(self.clock.now());
                             continue;
                         }
                     }
@@ -321,16 +374,19 @@ impl MediaServerEventWorker {
                 }
             }
 
-            if entry.sid.is_none() && entry.retry_policy.should_retry() {
-                if let Err(err) =
-                    Self::subscribe_entry(self.listener_port, self.http_timeout, entry)
-                {
+            if entry.sid.is_none() && entry.retry_policy.should_retry(self.clock.now()) {
+                if let Err(err) = Self::subscribe_entry(
+                    self.listener_port,
+                    self.http_timeout,
+                    &*self.clock,
+                    entry,
+                ) {
                     warn!(
                         server = entry.friendly_name.as_str(),
                         error = %err,
                         "ContentDirectory SUBSCRIBE failed"
                     );
-                    entry.retry_policy.defer_retry();
+                    entry.retry_policy.defer_retry(self.clock.now());
                 }
             }
         }
@@ -351,25 +407,23 @@ impl MediaServerEventWorker {
     }
 
     fn renew_expiring(&mut self) {
-        let now = Instant::now();
-        let mut to_renew = Vec::new();
-        for (id, entry) in self.subscriptions.iter() {
-            if let Some(exp) = entry.expires_at {
-                if exp <= now + Duration::from_secs(RENEWAL_SAFETY_MARGIN_SECS) {
-                    to_renew.push(id.clone());
-                }
-            }
-        }
+        let now = self.clock.now();
+        let to_renew: Vec<DeviceId> = self
+            .subscriptions
+            .iter()
+            .filter(|(_, entry)| entry.needs_renewal(now))
+            .map(|(id, _)| id.clone())
+            .collect();
 
         for id in to_renew {
             if let Some(entry) = self.subscriptions.get_mut(&id) {
-                if let Err(err) = Self::renew_entry(self.http_timeout, entry) {
+                if let Err(err) = Self::renew_entry(self.http_timeout, &*self.clock, entry) {
                     warn!(
                         server = entry.friendly_name.as_str(),
                         error = %err,
                         "Failed to renew ContentDirectory subscription"
                     );
-                    entry.reset_subscription();
+                    entry.reset_subscription(self.clock.now());
                 }
             }
         }
@@ -378,6 +432,7 @@ impl MediaServerEventWorker {
     fn subscribe_entry(
         listener_port: u16,
         http_timeout: Duration,
+        clock: &dyn Clock,
         entry: &mut SubscriptionState,
     ) -> Result<()> {
         let event_url = entry
@@ -436,9 +491,10 @@ impl MediaServerEventWorker {
         )
         .unwrap_or(Duration::from_secs(SUBSCRIPTION_TIMEOUT_SECS));
 
+        let now = clock.now();
         entry.sid = Some(sid);
-        entry.expires_at = Some(Instant::now() + timeout);
-        entry.retry_policy.schedule_soon();
+        entry.expires_at = Some(now + timeout);
+        entry.retry_policy.schedule_soon(now);
 
         info!(
             server = entry.friendly_name.as_str(),
@@ -449,7 +505,11 @@ impl MediaServerEventWorker {
         Ok(())
     }
 
-    fn renew_entry(http_timeout: Duration, entry: &mut SubscriptionState) -> Result<()> {
+    fn renew_entry(
+        http_timeout: Duration,
+        clock: &dyn Clock,
+        entry: &mut SubscriptionState,
+    ) -> Result<()> {
         let event_url = entry
             .event_sub_url
             .as_ref()
@@ -483,7 +543,7 @@ impl MediaServerEventWorker {
                 .and_then(|value| value.to_str().ok()),
         )
         .unwrap_or(Duration::from_secs(SUBSCRIPTION_TIMEOUT_SECS));
-        entry.expires_at = Some(Instant::now() + timeout);
+        entry.expires_at = Some(clock.now() + timeout);
         debug!(
             server = entry.friendly_name.as_str(),
             "Renewed ContentDirectory subscription"
@@ -607,7 +667,7 @@ struct SubscriptionState {
 
 impl SubscriptionState {
     /// Creates a new subscription state from a MusicServer
-    fn from_music_server(server: &MusicServer) -> Self {
+    fn from_music_server(server: &MusicServer, now: Instant) -> Self {
         Self {
             callback_path: build_callback_path(&server.id()),
             device_id: server.id(),
@@ -616,29 +676,35 @@ impl SubscriptionState {
             event_sub_url: None,
             sid: None,
             expires_at: None,
-            retry_policy: RetryPolicy::new(),
+            retry_policy: RetryPolicy::new(now),
         }
     }
 
     /// Updates the subscription state from a MusicServer
-    fn update_from_server(&mut self, server: &MusicServer) {
+    fn update_from_server(&mut self, server: &MusicServer, now: Instant) {
         let new_location = server.location();
         if self.location != new_location {
             // Location changed - invalidate subscription
             self.event_sub_url = None;
             self.sid = None;
             self.expires_at = None;
-            self.retry_policy = RetryPolicy::new();
+            self.retry_policy = RetryPolicy::new(now);
         }
         self.device_id = server.id();
         self.location = new_location.to_string();
         self.friendly_name = server.friendly_name().to_string();
     }
 
-    fn reset_subscription(&mut self) {
+    /// Whether the subscription expires within the renewal safety margin
+    fn needs_renewal(&self, now: Instant) -> bool {
+        self.expires_at
+            .is_some_and(|exp| exp <= now + Duration::from_secs(RENEWAL_SAFETY_MARGIN_SECS))
+    }
+
+    fn reset_subscription(&mut self, now: Instant) {
         self.sid = None;
         self.expires_at = None;
-        self.retry_policy.schedule_soon();
+        self.retry_policy.schedule_soon(now);
     }
 }
 
@@ -670,6 +736,13 @@ fn build_callback_path(id: &DeviceId) -> String {
 
 fn parse_notify_payload(server_id: &DeviceId, body: &[u8]) -> Vec<MediaServerEvent> {
     let mut events = Vec::new();
+    if let Err(e) = xml_guard::check(body) {
+        warn!(
+            server = server_id.0.as_str(),
+            "Rejected ContentDirectory notify payload: {}", e
+        );
+        return events;
+    }
     let reader = std::io::Cursor::new(body);
     let Ok(root) = Element::parse(reader) else {
         warn!(
@@ -772,8 +845,12 @@ fn fetch_event_sub_url(location: &str, timeout: Duration) -> Result<Option<Strin
         .call()
         .with_context(|| format!("HTTP error when fetching description at {}", location))?;
     let (_parts, body) = response.into_parts();
-    let mut reader = BufReader::new(body.into_reader());
-    let root = Element::parse(&mut reader)?;
+    let mut bytes = Vec::new();
+    body.into_reader()
+        .take(XmlLimits::DEFAULT.max_bytes as u64 + 1)
+        .read_to_end(&mut bytes)?;
+    xml_guard::check(&bytes)?;
+    let root = Element::parse(bytes.as_slice())?;
 
     let device = match root.get_child("device") {
         Some(device) => device,
@@ -875,3 +952,42 @@ fn build_agent(timeout: Duration) -> Agent {
         .build()
         .into()
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use pmoupnp::clock::FakeClock;
+
+    #[test]
+    fn test_retry_and_renewal_timing() {
+        let clock = FakeClock::new();
+        let mut entry = SubscriptionState {
+            device_id: DeviceId("uuid:server".into()),
+            location: "http://192.168.1.2:8200/description.xml".into(),
+            friendly_name: "NAS".into(),
+            event_sub_url: Some("http://192.168.1.2:8200/evt/cd".into()),
+            sid: None,
+            expires_at: None,
+            callback_path: "/cd/server".into(),
+            retry_policy: RetryPolicy::new(clock.now()),
+        };
+        assert!(entry.retry_policy.should_retry(clock.now()));
+        entry.retry_policy.defer_retry(clock.now());
+        clock.advance(Duration::from_secs(RETRY_DELAY_SECS - 1));
+        assert!(!entry.retry_policy.should_retry(clock.now()));
+        clock.advance(Duration::from_secs(1));
+        assert!(entry.retry_policy.should_retry(clock.now()));
+
+        entry.sid = Some("uuid:sid".into());
+        entry.expires_at = Some(clock.now() + Duration::from_secs(SUBSCRIPTION_TIMEOUT_SECS));
+        assert!(!entry.needs_renewal(clock.now()));
+        clock.advance(Duration::from_secs(
+            SUBSCRIPTION_TIMEOUT_SECS - RENEWAL_SAFETY_MARGIN_SECS,
+        ));
+        assert!(entry.needs_renewal(clock.now()));
+
+        entry.reset_subscription(clock.now());
+        assert!(!entry.needs_renewal(clock.now()));
+        assert!(!entry.retry_policy.should_retry(clock.now()));
+    }
+}
@@ -0,0 +1,374 @@
+//! MediaServer proxy/aggregator content provider.
+//!
+//! Re-exposes the ContentDirectory trees of remote UPnP MediaServers (as seen
+//! by the control point) under a virtual folder of the local server. Paths
+//! relative to the mount:
+//!
+//! ```text
+//! ""                         one container per selected remote server
+//! server:{key}               root ("0") of a remote server
+//! object:{key}:{remote_id}   any other remote object
+//! ```
+//!
+//! `key` is a short stable hash of the remote server UDN, so object ids stay
+//! valid across restarts as long as the remote server keeps its own ids.
+//!
+//! Resources whose MIME type is listed in `transcode` are rewritten through
+//! the local transcoding endpoint (`/transcode?src=…&profile=…`) for
+//! renderers that cannot decode the original format.
+//!
+//! ```yaml
+//! sources:
+//!   virtual_folders:
+//!     - id: nas
+//!       title: "NAS"
+//!       provider: upnp-proxy
+//!       options:
+//!         servers: ["MinimServer"]          # friendlyName or UDN, empty = all
+//!         exclude: []
+//!         transcode: ["audio/x-ape", "audio/x-ms-wma"]
+//!         transcode_profile: flac
+//! ```
+
+use crate::control_point::ControlPoint;
+use crate::identity::DeviceIdentity;
+use crate::media_server::{MediaBrowser, MediaEntry, MusicServer};
+use crate::online::DeviceOnline;
+use crate::transcode;
+use pmodidl::{Container, Item, Resource};
+use pmosource::provider::{ContentProvider, MountConfig, register_provider_factory};
+use pmosource::{BrowseResult, MusicSourceError, Result, SearchQuery};
+use serde::Deserialize;
+use std::sync::Arc;
+use tracing::debug;
+
+/// Factory name used in `sources.virtual_folders`.
+pub const PROVIDER_NAME: &str = "upnp-proxy";
+
+/// Options of a proxy mount.
+#[derive(Debug, Clone, Default, Deserialize)]
+#[serde(default)]
+pub struct ProxyOptions {
+    /// Remote servers to expose (friendlyName or UDN); empty means all.
+    pub servers: Vec<String>,
+    /// Remote servers never exposed.
+    pub exclude: Vec<String>,
+    /// MIME types rewritten through the local transcoding endpoint.
+    pub transcode: Vec<String>,
+    /// Transcoding profile requested for rewritten resources.
+    pub transcode_profile: Option<String>,
+}
+
+/// Content provider browsing remote ContentDirectory servers.
+#[derive(Debug)]
+pub struct MediaServerProxy {
+    control_point: Arc<ControlPoint>,
+    local_udn: String,
+    base_url: String,
+    options: ProxyOptions,
+}
+
+impl MediaServerProxy {
+    pub fn new(
+        control_point: Arc<ControlPoint>,
+        local_udn: &str,
+        base_url: &str,
+        options: ProxyOptions,
+    ) -> Self {
+        Self {
+            control_point,
+            local_udn: normalize_udn(local_udn),
+            base_url: base_url.trim_end_matches('/').to_string(),
+            options,
+        }
+    }
+
+    /// Registers the `upnp-proxy` factory for virtual folders.
+    ///
+    /// `local_udn` is the UDN of our own MediaServer, which is never proxied
+    /// (browsing ourselves through ourselves would loop).
+    pub fn register_factory(control_point: Arc<ControlPoint>, local_udn: &str, base_url: &str) {
+        let local_udn = local_udn.to_string();
+        let base_url = base_url.to_string();
+        register_provider_factory(PROVIDER_NAME, move |mount: &MountConfig| {
+            let options: ProxyOptions = if mount.options.is_null() {
+                ProxyOptions::default()
+            } else {
+                serde_json::from_value(mount.options.clone()).map_err(|e| {
+                    MusicSourceError::BrowseError(format!("invalid {} options: {}", mount.id, e))
+                })?
+            };
+            Ok(Arc::new(MediaServerProxy::new(
+                control_point.clone(),
+                &local_udn,
+                &base_url,
+                options,
+            )) as Arc<dyn ContentProvider>)
+        });
+    }
+
+    fn matches(server: &MusicServer, patterns: &[String]) -> bool {
+        let udn = normalize_udn(server.udn());
+        patterns.iter().any(|p| {
+            normalize_udn(p) == udn || p.eq_ignore_ascii_case(server.friendly_name())
+        })
+    }
+
+    /// Online remote servers selected by the mount options.
+    fn servers(&self) -> Vec<Arc<MusicServer>> {
+        let servers = self.control_point.list_media_servers().unwrap_or_default();
+        servers
+            .into_iter()
+            .filter(|s| s.is_online() && s.has_content_directory())
+            .filter(|s| normalize_udn(s.udn()) != self.local_udn)
+            .filter(|s| self.options.servers.is_empty() || Self::matches(s, &self.options.servers))
+            .filter(|s| !Self::matches(s, &self.options.exclude))
+            .collect()
+    }
+
+    fn server(&self, key: &str) -> Result<Arc<MusicServer>> {
+        self.servers()
+            .into_iter()
+            .find(|s| server_key(s.udn()) == key)
+            .ok_or_else(|| MusicSourceError::SourceUnavailable(format!("remote server {}", key)))
+    }
+
+    /// Splits a relative path into (server key, remote object id).
+    fn parse_path(path: &str) -> Result<(&str, &str)> {
+        if let Some(key) = path.strip_prefix("server:") {
+            return Ok((key, "0"));
+        }
+        path.strip_prefix("object:")
+            .and_then(|rest| rest.split_once(':'))
+            .filter(|(key, id)| !key.is_empty() && !id.is_empty())
+            .ok_or_else(|| MusicSourceError::ObjectNotFound(path.to_string()))
+    }
+
+    fn local_path(key: &str, remote_id: &str) -> String {
+        match remote_id {
+            "0" => format!("server:{}", key),
+            "-1" => String::new(),
+            id => format!("object:{}:{}", key, id),
+        }
+    }
+
+    /// Runs a blocking control point request off the async runtime.
+    async fn remote<T, F>(&self, key: &str, f: F) -> Result<T>
+    where
+        T: Send + 'static,
+        F: FnOnce(&MusicServer) -> std::result::Result<T, crate::errors::ControlPointError>
+            + Send
+            + 'static,
+    {
+        let server = self.server(key)?;
+        tokio::task::spawn_blocking(move || f(&server))
+            .await
+            .map_err(|e| MusicSourceError::BrowseError(e.to_string()))?
+            .map_err(|e| MusicSourceError::BrowseError(e.to_string()))
+    }
+
+    fn server_container(server: &MusicServer) -> Container {
+        Container {
+            id: format!("server:{}", server_key(server.udn())),
+            parent_id: String::new(),
+            restricted: Some("1".to_string()),
+            child_count: None,
+            searchable: Some("1".to_string()),
+            title: server.friendly_name().to_string(),
+            class: "object.container".to_string(),
+            artist: None,
+            album_art: None,
+            containers: vec![],
+            items: vec![],
+        }
+    }
+
+    /// Rewritten (url, protocolInfo) for resources that need transcoding.
+    fn rewrite_resource(&self, url: &str, protocol_info: &str) -> Option<(String, String)> {
+        let mime = protocol_info.split(':').nth(2)?.to_ascii_lowercase();
+        if !self
+            .options
+            .transcode
+            .iter()
+            .any(|m| m.eq_ignore_ascii_case(&mime))
+        {
+            return None;
+        }
+        let profile = self
+            .options
+            .transcode_profile
+            .as_deref()
+            .unwrap_or(transcode::DEFAULT_PROFILE);
+        Some((
+            transcode::transcode_url(&self.base_url, url, profile),
+            transcode::profile_protocol_info(profile),
+        ))
+    }
+
+    fn to_didl(&self, key: &str, entries: Vec<MediaEntry>) -> BrowseResult {
+        let mut containers = Vec::new();
+        let mut items = Vec::new();
+        for entry in entries {
+            let id = Self::local_path(key, &entry.id);
+            let parent_id = Self::local_path(key, &entry.parent_id);
+            if entry.is_container {
+                containers.push(Container {
+                    id,
+                    parent_id,
+                    restricted: Some("1".to_string()),
+                    child_count: entry.child_count.map(|c| c.to_string()),
+                    searchable: None,
+                    title: entry.title,
+                    class: entry.class,
+                    artist: entry.artist,
+                    album_art: entry.album_art_uri,
+                    containers: vec![],
+                    items: vec![],
+                });
+                continue;
+            }
+
+            let resources = entry
+                .resources
+                .iter()
+                .map(|res| {
+                    let (url, protocol_info) = self
+                        .rewrite_resource(&res.uri, &res.protocol_info)
+                        .unwrap_or_else(|| (res.uri.clone(), res.protocol_info.clone()));
+                    Resource {
+                        protocol_info,
+                        bits_per_sample: None,
+                        sample_frequency: None,
+                        nr_audio_channels: None,
+                        duration: res.duration.clone(),
+                        url,
+                    }
+                })
+                .collect();
+            items.push(Item {
+                id,
+                parent_id,
+                restricted: Some("1".to_string()),
+                title: entry.title,
+                creator: entry.creator,
+                class: entry.class,
+                artist: entry.artist,
+                album: entry.album,
+                genre: entry.genre,
+                album_art: entry.album_art_uri,
+                album_art_pk: None,
+                date: entry.date,
+                original_track_number: entry.track_number,
+                resources,
+                descriptions: vec![],
+            });
+        }
+        BrowseResult::Mixed { containers, items }
+    }
+}
+
+#[async_trait::async_trait]
+impl ContentProvider for MediaServerProxy {
+    async fn browse(&self, path: &str) -> Result<BrowseResult> {
+        if path.is_empty() {
+            let servers = self.servers();
+            return Ok(BrowseResult::Containers(
+                servers.iter().map(|s| Self::server_container(s)).collect(),
+            ));
+        }
+        let (key, remote_id) = Self::parse_path(path)?;
+        let id = remote_id.to_string();
+        let entries = self
+            .remote(key, move |server| server.browse_children(&id, 0, 0))
+            .await?;
+        debug!("🔀 Proxied {} entries from {}", entries.len(), path);
+        Ok(self.to_didl(key, entries))
+    }
+
+    async fn get_item(&self, path: &str) -> Result<Item> {
+        let (key, remote_id) = Self::parse_path(path)?;
+        let id = remote_id.to_string();
+        let entry = self
+            .remote(key, move |server| server.browse_object(&id))
+            .await?;
+        self.to_didl(key, vec![entry])
+            .items()
+            .first()
+            .cloned()
+            .ok_or_else(|| MusicSourceError::ObjectNotFound(path.to_string()))
+    }
+
+    async fn resolve(&self, path: &str) -> Result<String> {
+        let item = self.get_item(path).await?;
+        item.resources
+            .first()
+            .map(|res| res.url.clone())
+            .ok_or_else(|| MusicSourceError::UriResolutionError(path.to_string()))
+    }
+
+    async fn search(&self, query: &SearchQuery) -> Result<BrowseResult> {
+        let criteria = format!(
+            "dc:title contains \"{}\"",
+            query.text.replace('\\', "\\\\").replace('"', "\\\"")
+        );
+        let (start, count) = (query.offset, query.limit);
+        let mut containers = Vec::new();
+        let mut items = Vec::new();
+        for server in self.servers() {
+            let key = server_key(server.udn());
+            let criteria = criteria.clone();
+            match self
+                .remote(&key, move |server| server.search("0", &criteria, start, count))
+                .await
+            {
+                Ok(entries) => {
+                    let result = self.to_didl(&key, entries);
+                    containers.extend(result.containers().iter().cloned());
+                    items.extend(result.items().iter().cloned());
+                }
+                Err(e) => debug!("Search failed on {}: {}", server.friendly_name(), e),
+            }
+        }
+        Ok(BrowseResult::Mixed { containers, items })
+    }
+
+    fn supports_search(&self) -> bool {
+        true
+    }
+}
+
+fn normalize_udn(udn: &str) -> String {
+    let udn = udn.trim();
+    udn.strip_prefix("uuid:")
+        .unwrap_or(udn)
+        .to_ascii_lowercase()
+}
+
+/// Short stable key identifying a remote server (FNV-1a of its UDN).
+fn server_key(udn: &str) -> String {
+    let hash = normalize_udn(udn)
+        .bytes()
+        .fold(0x811c9dc5u32, |h, b| (h ^ b as u32).wrapping_mul(0x01000193));
+    format!("{:08x}", hash)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_paths() {
+        assert_eq!(
+            MediaServerProxy::parse_path("object:0a1b2c3d:64$1$2").unwrap(),
+            ("0a1b2c3d", "64$1$2")
+        );
+        assert_eq!(
+            MediaServerProxy::parse_path("server:0a1b2c3d").unwrap(),
+            ("0a1b2c3d", "0")
+        );
+        assert!(MediaServerProxy::parse_path("object:0a1b2c3d").is_err());
+        assert_eq!(MediaServerProxy::local_path("k", "0"), "server:k");
+        assert_eq!(MediaServerProxy::local_path("k", "a:b"), "object:k:a:b");
+        assert_eq!(server_key("uuid:ABC"), server_key("abc"));
+    }
+}
@@ -1,4 +1,5 @@
 use crate::music_renderer::{PlaybackPositionInfo, PlaylistBinding};
+use crate::queue::{RepeatMode, ShuffleMode};
 use crate::{DeviceId, DeviceIdentity};
 
 /// Basic device information for event notifications
@@ -430,6 +431,11 @@ pub enum RendererEvent {
         id: DeviceId,
         metadata: TrackMetadata,
     },
+    /// Échec du lancement de la lecture
+    PlaybackError {
+        id: DeviceId,
+        message: String,
+    },
     QueueUpdated {
         id: DeviceId,
         queue_length: usize,
@@ -451,6 +457,12 @@ pub enum RendererEvent {
         id: DeviceId,
         is_stream: bool,
     },
+    /// Changement du mode aléatoire ou du mode de répétition de la queue
+    PlayModeChanged {
+        id: DeviceId,
+        shuffle: ShuffleMode,
+        repeat: RepeatMode,
+    },
     TimerStarted {
         id: DeviceId,
         duration_seconds: u32,
@@ -471,6 +483,16 @@ pub enum RendererEvent {
     TimerCancelled {
         id: DeviceId,
     },
+    /// Déclenchement d'un réveil (voir [`crate::alarms`])
+    AlarmTriggered {
+        id: DeviceId,
+        alarm: String,
+    },
+    /// Arrêt d'une lecture programmée
+    AlarmStopped {
+        id: DeviceId,
+        alarm: String,
+    },
     Online {
         id: DeviceId,
         info: DeviceBasicInfo,
@@ -480,6 +502,64 @@ pub enum RendererEvent {
     },
 }
 
+impl RendererEvent {
+    /// Renderer concerned by the event.
+    pub fn id(&self) -> &DeviceId {
+        match self {
+            RendererEvent::StateChanged { id, .. }
+            | RendererEvent::PositionChanged { id, .. }
+            | RendererEvent::VolumeChanged { id, .. }
+            | RendererEvent::MuteChanged { id, .. }
+            | RendererEvent::MetadataChanged { id, .. }
+            | RendererEvent::PlaybackError { id, .. }
+            | RendererEvent::QueueUpdated { id, .. }
+            | RendererEvent::QueueRefreshing { id }
+            | RendererEvent::QueueReadyToPlay { id }
+            | RendererEvent::QueueSyncCancelled { id }
+            | RendererEvent::BindingChanged { id, .. }
+            | RendererEvent::StreamStateChanged { id, .. }
+            | RendererEvent::PlayModeChanged { id, .. }
+            | RendererEvent::TimerStarted { id, .. }
+            | RendererEvent::TimerUpdated { id, .. }
+            | RendererEvent::TimerTick { id, .. }
+            | RendererEvent::TimerExpired { id }
+            | RendererEvent::TimerCancelled { id }
+            | RendererEvent::AlarmTriggered { id, .. }
+            | RendererEvent::AlarmStopped { id, .. }
+            | RendererEvent::Online { id, .. }
+            | RendererEvent::Offline { id } => id,
+        }
+    }
+
+    /// Event name, identical to the `type` field of the SSE payloads.
+    pub fn kind(&self) -> &'static str {
+        match self {
+            RendererEvent::StateChanged { .. } => "state_changed",
+            RendererEvent::PositionChanged { .. } => "position_changed",
+            RendererEvent::VolumeChanged { .. } => "volume_changed",
+            RendererEvent::MuteChanged { .. } => "mute_changed",
+            RendererEvent::MetadataChanged { .. } => "metadata_changed",
+            RendererEvent::PlaybackError { .. } => "playback_error",
+            RendererEvent::QueueUpdated { .. } => "queue_updated",
+            RendererEvent::QueueRefreshing { .. } => "queue_refreshing",
+            RendererEvent::QueueReadyToPlay { .. } => "queue_ready_to_play",
+            RendererEvent::QueueSyncCancelled { .. } => "queue_sync_cancelled",
+            RendererEvent::BindingChanged { .. } => "binding_changed",
+            RendererEvent::StreamStateChanged { .. } => "stream_state_changed",
+            RendererEvent::PlayModeChanged { .. } => "play_mode_changed",
+            RendererEvent::TimerStarted { .. } => "timer_started",
+            RendererEvent::TimerUpdated { .. } => "timer_updated",
+            RendererEvent::TimerTick { .. } => "timer_tick",
+            RendererEvent::TimerExpired { .. } => "timer_expired",
+            RendererEvent::TimerCancelled { .. } => "timer_cancelled",
+            RendererEvent::AlarmTriggered { .. } => "alarm_triggered",
+            RendererEvent::AlarmStopped { .. } => "alarm_stopped",
+            RendererEvent::Online { .. } => "online",
+            RendererEvent::Offline { .. } => "offline",
+        }
+    }
+}
+
 #[derive(Clone, Debug)]
 pub enum MediaServerEvent {
     GlobalUpdated {
@@ -34,7 +34,10 @@ use crate::music_renderer::{
     QueueTransportControl, TransportControl, VolumeControl,
 };
 use crate::online::DeviceConnectionState;
-use crate::queue::{EnqueueMode, MusicQueue, PlaybackItem, QueueBackend, QueueSnapshot};
+use crate::queue::{
+    EnqueueMode, MusicQueue, PlaybackItem, QueueBackend, QueueShuffle, QueueSnapshot, RepeatMode,
+    ShuffleMode,
+};
 use crate::{DeviceId, DeviceIdentity, DeviceOnline};
 
 use tracing::warn;
@@ -113,6 +116,10 @@ struct MusicRendererState {
     /// For continuous streams, this is kept stable and only updated when it increases
     /// (to avoid decreasing duration updates from radio metadata).
     current_track_duration: Option<String>,
+    /// Active shuffle mode, with the order to restore when it is turned off.
+    shuffle: Option<QueueShuffle>,
+    /// What to play once the current track has ended.
+    repeat: RepeatMode,
 }
 
 impl Default for MusicRendererState {
@@ -125,6 +132,8 @@ impl Default for MusicRendererState {
             has_played_since_track_start: false,
             track_start_time: None,
             current_track_duration: None,
+            shuffle: None,
+            repeat: RepeatMode::default(),
         }
     }
 }
@@ -737,7 +746,7 @@ impl MusicRenderer {
                         // from poisoning the backend mutex
                         // Also add retry logic for transient errors from renderer
                         let result = std::panic::catch_unwind(std::panic::AssertUnwindSafe(|| {
-                            self.play_next_from_queue_with_retry()
+                            self.play_following_from_queue_with_retry()
                         }));
                         match result {
                             Ok(Ok(())) => {}
@@ -801,7 +810,7 @@ impl MusicRenderer {
                             "NoMedia after queue-driven playback; advancing to next track"
                         );
                         let result = std::panic::catch_unwind(std::panic::AssertUnwindSafe(|| {
-                            self.play_next_from_queue()
+                            self.play_following_from_queue_with_retry()
                         }));
                         match result {
                             Ok(Ok(())) => {}
@@ -1144,14 +1153,53 @@ impl MusicRenderer {
         }))
     }
 
+    /// Index of the track to play once the current one has ended, honouring
+    /// the repeat mode. `None` at the end of the queue without repeat.
+    pub fn following_index(&self) -> Result<Option<usize>, ControlPointError> {
+        let repeat = self.repeat_mode();
+        let backend = self.lock_backend_for("following_index");
+        Ok(repeat.following_index(backend.current_index()?, backend.len()?))
+    }
+
+    /// Auto-advance once the current track has ended.
+    ///
+    /// Without repeat this is [`Self::play_next_from_queue_with_retry`]; with
+    /// repeat the current track is replayed, or the queue starts over after
+    /// its last track.
+    fn play_following_from_queue_with_retry(&self) -> Result<(), ControlPointError> {
+        if self.repeat_mode() == RepeatMode::Off {
+            return self.play_next_from_queue_with_retry();
+        }
+
+        let index = self
+            .following_index()?
+            .ok_or_else(|| ControlPointError::QueueError("No next track".into()))?;
+        self.set_queue_index(Some(index))?;
+        self.play_current_from_queue_with_retry()?;
+        self.emit_queue_updated();
+        Ok(())
+    }
+
     /// Advance the queue index by one without starting playback.
     ///
     /// Used by the WebRenderer gapless path: the browser autonomously transitions
     /// to the next track, so only the backend queue pointer needs to be updated
     /// to stay in sync.
+    ///
+    /// Honours the repeat mode, like the non-gapless auto-advance.
     pub fn advance_queue_index(&self) -> Result<bool, ControlPointError> {
+        let repeat = self.repeat_mode();
         let mut backend = self.lock_backend_for("advance_queue_index");
-        let advanced = backend.advance()?;
+        let advanced = match repeat {
+            RepeatMode::Off => backend.advance()?,
+            repeat => match repeat.following_index(backend.current_index()?, backend.len()?) {
+                Some(index) => {
+                    backend.set_index(Some(index))?;
+                    true
+                }
+                None => false,
+            },
+        };
         drop(backend);
         if advanced {
             self.emit_queue_updated();
@@ -1212,6 +1260,24 @@ impl MusicRenderer {
         }
     }
 
+    /// Transport control: play an URI directly, outside the queue
+    ///
+    /// Used for group playback: the renderer queue is left untouched and
+    /// auto-advance is disabled until the queue is played again.
+    pub fn play_uri(&self, uri: &str, metadata: &str) -> Result<(), ControlPointError> {
+        {
+            let mut watched = self
+                .watched_state
+                .lock()
+                .expect("WatchedState mutex poisoned");
+            watched.is_active = true;
+        }
+
+        self.set_playback_source(PlaybackSource::None);
+        self.clear_has_played_flag();
+        self.lock_backend_for("play_uri").play_uri(uri, metadata)
+    }
+
     /// Transport control: pause
     pub fn pause(&self) -> Result<(), ControlPointError> {
         self.lock_backend_for("pause").pause()
@@ -1898,6 +1964,110 @@ impl MusicRenderer {
 
         Ok(())
     }
+
+    /// Returns the repeat mode of the queue.
+    pub fn repeat_mode(&self) -> RepeatMode {
+        self.state
+            .lock()
+            .expect("RendererState mutex poisoned")
+            .repeat
+    }
+
+    /// Sets the repeat mode of the queue.
+    ///
+    /// On OpenHome renderers, which advance through their playlist on their
+    /// own, the device's Repeat follows `RepeatMode::All`; `RepeatMode::One`
+    /// is applied by the auto-advance when the renderer stops.
+    pub fn set_repeat_mode(&self, repeat: RepeatMode) -> Result<(), ControlPointError> {
+        if let MusicRendererBackend::OpenHome(oh) = &*self.lock_backend_for("set_repeat_mode") {
+            oh.set_native_repeat(repeat == RepeatMode::All)?;
+        }
+
+        self.state
+            .lock()
+            .expect("RendererState mutex poisoned")
+            .repeat = repeat;
+        self.emit_play_mode_changed();
+        Ok(())
+    }
+
+    fn emit_play_mode_changed(&self) {
+        let (shuffle, _) = self.shuffle_mode();
+        self.emit_event(RendererEvent::PlayModeChanged {
+            id: self.id(),
+            shuffle,
+            repeat: self.repeat_mode(),
+        });
+    }
+
+    /// Returns the active shuffle mode and its seed.
+    pub fn shuffle_mode(&self) -> (ShuffleMode, Option<u64>) {
+        let state = self.state.lock().expect("RendererState mutex poisoned");
+        match state.shuffle.as_ref() {
+            Some(shuffle) => (shuffle.mode(), Some(shuffle.seed())),
+            None => (ShuffleMode::Off, None),
+        }
+    }
+
+    /// Sets the shuffle mode of the queue without interrupting playback.
+    ///
+    /// Enabling shuffle (or changing its mode or seed) reorders the tracks
+    /// after the current one; turning it off restores the order the queue
+    /// had when shuffle was enabled. The current track keeps playing in
+    /// both cases. With a `seed`, the resulting order is reproducible.
+    ///
+    /// On OpenHome renderers the device's own Shuffle is turned off, since
+    /// the playlist itself is now in shuffled order.
+    pub fn set_shuffle_mode(
+        &self,
+        mode: ShuffleMode,
+        seed: Option<u64>,
+    ) -> Result<(), ControlPointError> {
+        let snapshot = self.queue_snapshot()?;
+        let previous = self
+            .state
+            .lock()
+            .expect("RendererState mutex poisoned")
+            .shuffle
+            .clone();
+
+        let (items, shuffle) = match (mode, previous) {
+            (ShuffleMode::Off, None) => return Ok(()),
+            (ShuffleMode::Off, Some(shuffle)) => {
+                let (items, _) = shuffle.restore(snapshot.items, snapshot.current_index);
+                (items, None)
+            }
+            (mode, previous) => {
+                let mut shuffle = match previous {
+                    Some(mut shuffle) => {
+                        shuffle.reconfigure(mode, seed);
+                        shuffle
+                    }
+                    None => QueueShuffle::new(mode, seed, &snapshot.items),
+                };
+                let (items, _) = shuffle.reshuffle(snapshot.items, snapshot.current_index);
+                (items, Some(shuffle))
+            }
+        };
+
+        if let MusicRendererBackend::OpenHome(oh) = &*self.lock_backend_for("set_shuffle_mode") {
+            if let Err(err) = oh.set_native_shuffle(false) {
+                warn!(
+                    renderer = self.id().0.as_str(),
+                    error = %err,
+                    "Failed to disable OpenHome native shuffle"
+                );
+            }
+        }
+
+        self.sync_queue(items)?;
+        self.state
+            .lock()
+            .expect("RendererState mutex poisoned")
+            .shuffle = shuffle;
+        self.emit_play_mode_changed();
+        Ok(())
+    }
 }
 
 /// Helper function to build DIDL-Lite metadata XML from TrackMetadata
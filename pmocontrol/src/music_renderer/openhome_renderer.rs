@@ -112,6 +112,26 @@ impl OpenHomeRenderer {
         self.has_playlist() || self.has_info() || self.has_time() || self.has_volume()
     }
 
+    /// Enables or disables the device's own playlist shuffle.
+    ///
+    /// Does nothing if it is already in the requested state.
+    pub fn set_native_shuffle(&self, shuffle: bool) -> Result<(), ControlPointError> {
+        let playlist = self.playlist_client_for("set_native_shuffle")?;
+        if playlist.shuffle()? != shuffle {
+            playlist.set_shuffle(shuffle)?;
+        }
+        Ok(())
+    }
+
+    /// Enables or disables the device's own playlist repeat.
+    pub fn set_native_repeat(&self, repeat: bool) -> Result<(), ControlPointError> {
+        let playlist = self.playlist_client_for("set_native_repeat")?;
+        if playlist.repeat()? != repeat {
+            playlist.set_repeat(repeat)?;
+        }
+        Ok(())
+    }
+
     fn playlist_client_for(&self, op: &str) -> Result<&OhPlaylistClient, ControlPointError> {
         let playlist = self.playlist.as_ref().ok_or_else(|| {
             ControlPointError::upnp_operation_not_supported(op, "OpenHome Playlist")
@@ -16,6 +16,7 @@ use crate::upnp_clients::{
     AvTransportClient, ConnectionInfo, ConnectionManagerClient, PositionInfo, ProtocolInfo,
     RenderingControlClient,
 };
+use crate::transcode;
 use crate::{DeviceIdentity, RendererInfo};
 
 /// High-level handle representing a renderer and its optional AVTransport client.
@@ -30,6 +31,8 @@ pub struct UpnpRenderer {
     cached_duration: Arc<Mutex<Option<String>>>,
     /// Flag indicating if currently playing a continuous stream (radio without duration)
     continuous_stream: Arc<Mutex<bool>>,
+    /// Sink protocolInfo list from ConnectionManager, fetched on first playback
+    sink_protocols: Arc<Mutex<Option<Vec<String>>>>,
 }
 
 impl UpnpRenderer {
@@ -112,9 +115,57 @@ impl UpnpRenderer {
             queue,
             cached_duration: Arc::new(Mutex::new(None)),
             continuous_stream: Arc::new(Mutex::new(false)),
+            sink_protocols: Arc::new(Mutex::new(None)),
         }
     }
 
+    /// Returns the renderer sink protocolInfo list, cached after the first query.
+    ///
+    /// Returns `None` when the renderer has no ConnectionManager or the query fails.
+    pub fn sink_protocols(&self) -> Option<Vec<String>> {
+        let mut cache = self.sink_protocols.lock().expect("sink_protocols mutex poisoned");
+        if cache.is_none() {
+            match self.protocol_info() {
+                Ok(info) => *cache = Some(info.sink),
+                Err(e) => {
+                    tracing::debug!("GetProtocolInfo failed, assuming renderer decodes everything: {}", e);
+                    return None;
+                }
+            }
+        }
+        cache.clone()
+    }
+
+    /// Returns the (uri, protocolInfo) to send to the renderer.
+    ///
+    /// When a transcoder is registered and the renderer does not advertise the
+    /// source MIME type, the resource is routed through `/transcode`.
+    fn playable_resource(&self, uri: &str, protocol_info: &str) -> (String, String) {
+        let original = (uri.to_string(), protocol_info.to_string());
+        let Some(base_url) = transcode::transcoder_base_url() else {
+            return original;
+        };
+        let Some(mime) = transcode::protocol_info_mime(protocol_info) else {
+            return original;
+        };
+        if mime.is_empty() || mime == "*" || uri.starts_with(&base_url) {
+            return original;
+        }
+        let Some(sink) = self.sink_protocols() else {
+            return original;
+        };
+        if sink.is_empty() || transcode::sink_accepts(&sink, mime) {
+            return original;
+        }
+
+        let profile = transcode::DEFAULT_PROFILE;
+        tracing::info!("Renderer does not accept {}, transcoding {} to {}", mime, uri, profile);
+        (
+            transcode::transcode_url(&base_url, uri, profile),
+            transcode::profile_protocol_info(profile),
+        )
+    }
+
     /// Returns true if currently playing a continuous stream (radio without duration)
     pub fn is_continuous_stream(&self) -> bool {
         *self.continuous_stream.lock().expect("continuous_stream mutex poisoned")
@@ -169,6 +220,7 @@ impl RendererFromMediaRendererInfo for UpnpRenderer {
             queue,
             cached_duration: Arc::new(Mutex::new(None)),
             continuous_stream: Arc::new(Mutex::new(false)),
+            sink_protocols: Arc::new(Mutex::new(None)),
         })
     }
 
@@ -180,12 +232,14 @@ impl RendererFromMediaRendererInfo for UpnpRenderer {
 
 impl QueueTransportControl for UpnpRenderer {
     fn play_item(&self, item: &PlaybackItem) -> Result<(), ControlPointError> {
+        let (uri, protocol_info) = self.playable_resource(&item.uri, &item.protocol_info);
         let metadata = if let Some(ref track_metadata) = item.metadata {
-            build_didl_lite_metadata(track_metadata, &item.uri, &item.protocol_info)
+            build_didl_lite_metadata(track_metadata, &uri, &protocol_info)
         } else {
             format!(
                 r#"<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/"><item id="0" parentID="-1" restricted="1"><res protocolInfo="{}">{}</res></item></DIDL-Lite>"#,
-                item.protocol_info, item.uri
+                protocol_info,
+                uri.replace('&', "&amp;")
             )
         };
 
@@ -199,7 +253,7 @@ impl QueueTransportControl for UpnpRenderer {
         }
 
         let avt = self.avtransport()?;
-        avt.set_av_transport_uri(&item.uri, &metadata)?;
+        avt.set_av_transport_uri(&uri, &metadata)?;
         avt.play(0, "1")?;
 
         Ok(())
@@ -0,0 +1,476 @@
+//! Notifications de lecture vers le téléphone ou le bureau
+//!
+//! Le répartiteur écoute les événements des renderers et publie les
+//! changements de piste et les erreurs de lecture vers des services de
+//! notification (ntfy, Gotify) ou des webhooks :
+//!
+//! ```yaml
+//! host:
+//!   control_point:
+//!     notifications:
+//!       enabled: true
+//!       events: [track_change, error]
+//!       renderers: ["Chambre des enfants"]   # vide = tous les renderers
+//!       min_interval: 5s                     # entre deux pistes d'un même renderer
+//!       endpoints:
+//!         - kind: ntfy
+//!           url: "https://ntfy.sh"
+//!           topic: "maison-musique"
+//!           title: "🎵 {{renderer}}"
+//!           message: "{{title}} — {{artist}}"
+//!         - kind: gotify
+//!           url: "https://gotify.example.org"
+//!           token: "env:GOTIFY_TOKEN"
+//!         - kind: webhook
+//!           url: "https://hooks.example.org/pmomusic"
+//!           headers: { authorization: "env:HOOK_AUTHORIZATION" }
+//!           body: '{"text": "{{renderer}} : {{title}}"}'
+//! ```
+//!
+//! Les gabarits acceptent `{{event}}`, `{{renderer}}`, `{{renderer_id}}`,
+//! `{{title}}`, `{{artist}}`, `{{album}}`, `{{cover}}` et `{{message}}`.
+//! Dans le corps d'un webhook, les valeurs sont échappées pour JSON ; sans
+//! gabarit de corps, le webhook reçoit un objet JSON de toutes les variables.
+//! Les jetons et les valeurs d'en-têtes peuvent être des indirections
+//! d'environnement ou des valeurs chiffrées (voir [`pmoconfig::secrets`]).
+
+use std::collections::{BTreeMap, HashMap};
+use std::sync::{Arc, RwLock};
+use std::thread;
+use std::time::{Duration, Instant};
+
+use anyhow::{Result, anyhow};
+use crossbeam_channel::Receiver;
+use serde::Deserialize;
+use tracing::{debug, info, warn};
+use ureq::Agent;
+
+use crate::config_ext::ControlPointConfigExt;
+use crate::model::{RendererEvent, TrackMetadata};
+use crate::{DeviceId, DeviceIdentity, DeviceRegistry};
+
+/// Délai d'envoi d'une notification
+const NOTIFICATION_TIMEOUT: Duration = Duration::from_secs(10);
+
+const DEFAULT_TITLE: &str = "{{renderer}}";
+const DEFAULT_TRACK_MESSAGE: &str = "🎵 {{title}} — {{artist}}";
+const DEFAULT_ERROR_MESSAGE: &str = "⚠️ {{message}}";
+
+/// Type d'événement notifié
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum NotificationKind {
+    /// Nouvelle piste sur un renderer
+    TrackChange,
+    /// Échec de lecture
+    Error,
+}
+
+impl NotificationKind {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            NotificationKind::TrackChange => "track_change",
+            NotificationKind::Error => "error",
+        }
+    }
+}
+
+/// Service destinataire
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum EndpointKind {
+    /// `POST {url}` du message JSON d'un sujet ntfy
+    Ntfy,
+    /// `POST {url}/message`, jeton d'application en en-tête
+    Gotify,
+    /// `POST {url}` avec un corps libre
+    Webhook,
+}
+
+/// Destination de notifications déclarée dans la configuration
+#[derive(Debug, Clone, PartialEq, Eq, Deserialize)]
+pub struct NotificationEndpoint {
+    pub kind: EndpointKind,
+    pub url: String,
+    /// Sujet ntfy
+    #[serde(default)]
+    pub topic: Option<String>,
+    /// Jeton d'accès (Bearer pour ntfy, jeton d'application pour Gotify)
+    #[serde(default)]
+    pub token: Option<String>,
+    /// Priorité (1 à 5 pour ntfy, 0 à 10 pour Gotify)
+    #[serde(default)]
+    pub priority: Option<u8>,
+    /// Gabarit du titre
+    #[serde(default)]
+    pub title: Option<String>,
+    /// Gabarit du message
+    #[serde(default)]
+    pub message: Option<String>,
+    /// Gabarit du corps (webhook uniquement)
+    #[serde(default)]
+    pub body: Option<String>,
+    /// En-têtes supplémentaires
+    #[serde(default)]
+    pub headers: BTreeMap<String, String>,
+    /// Événements envoyés à cette destination, tous si vide
+    #[serde(default)]
+    pub events: Vec<NotificationKind>,
+}
+
+/// Réglages des notifications
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct NotificationSettings {
+    pub enabled: bool,
+    pub events: Vec<NotificationKind>,
+    /// Identifiants, UDN ou noms conviviaux surveillés, tous si vide
+    pub renderers: Vec<String>,
+    /// Intervalle minimal entre deux changements de piste d'un même renderer
+    pub min_interval: Duration,
+    pub endpoints: Vec<NotificationEndpoint>,
+}
+
+/// Notification prête à être mise en forme
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Notification {
+    pub kind: NotificationKind,
+    pub renderer_id: String,
+    pub renderer: String,
+    pub title: String,
+    pub artist: String,
+    pub album: String,
+    pub cover: String,
+    pub message: String,
+}
+
+impl Notification {
+    /// Variables disponibles dans les gabarits
+    pub fn variables(&self) -> [(&'static str, &str); 8] {
+        [
+            ("event", self.kind.as_str()),
+            ("renderer", &self.renderer),
+            ("renderer_id", &self.renderer_id),
+            ("title", &self.title),
+            ("artist", &self.artist),
+            ("album", &self.album),
+            ("cover", &self.cover),
+            ("message", &self.message),
+        ]
+    }
+}
+
+/// Remplace les `{{variable}}` d'un gabarit
+///
+/// Chaque valeur passe par `escape` ; une variable inconnue est remplacée par
+/// une chaîne vide.
+pub fn render_template(
+    template: &str,
+    variables: &[(&str, &str)],
+    escape: impl Fn(&str) -> String,
+) -> String {
+    let mut out = String::with_capacity(template.len());
+    let mut rest = template;
+    while let Some(start) = rest.find("{{") {
+        let Some(len) = rest[start + 2..].find("}}") else {
+            break;
+        };
+        out.push_str(&rest[..start]);
+        let name = rest[start + 2..start + 2 + len].trim();
+        if let Some((_, value)) = variables.iter().find(|(var, _)| *var == name) {
+            out.push_str(&escape(value));
+        }
+        rest = &rest[start + 4 + len..];
+    }
+    out.push_str(rest);
+    out
+}
+
+/// Échappe une valeur pour l'insérer dans une chaîne JSON
+pub fn json_escape(value: &str) -> String {
+    let quoted = serde_json::to_string(value).unwrap_or_default();
+    quoted[1..quoted.len() - 1].to_string()
+}
+
+fn plain(value: &str) -> String {
+    value.to_string()
+}
+
+/// Résout un jeton ou une valeur d'en-tête (indirection ou valeur chiffrée)
+fn resolve(value: &str) -> Result<String> {
+    pmoconfig::secrets::resolve_secret(value)
+}
+
+/// Envoie une notification à une destination
+pub fn send(
+    agent: &Agent,
+    endpoint: &NotificationEndpoint,
+    notification: &Notification,
+) -> Result<()> {
+    let variables = notification.variables();
+    let default_message = match notification.kind {
+        NotificationKind::TrackChange => DEFAULT_TRACK_MESSAGE,
+        NotificationKind::Error => DEFAULT_ERROR_MESSAGE,
+    };
+    let title = render_template(
+        endpoint.title.as_deref().unwrap_or(DEFAULT_TITLE),
+        &variables,
+        plain,
+    );
+    let message = render_template(
+        endpoint.message.as_deref().unwrap_or(default_message),
+        &variables,
+        plain,
+    );
+    let url = endpoint.url.trim_end_matches('/');
+
+    let mut request = match endpoint.kind {
+        EndpointKind::Ntfy => {
+            let request = agent.post(url).header("Content-Type", "application/json");
+            match &endpoint.token {
+                Some(token) => {
+                    request.header("Authorization", &format!("Bearer {}", resolve(token)?))
+                }
+                None => request,
+            }
+        }
+        EndpointKind::Gotify => {
+            let token = endpoint
+                .token
+                .as_deref()
+                .ok_or_else(|| anyhow!("Gotify endpoint {} has no token", url))?;
+            agent
+                .post(&format!("{}/message", url))
+                .header("X-Gotify-Key", &resolve(token)?)
+                .header("Content-Type", "application/json")
+        }
+        EndpointKind::Webhook => agent.post(url).header("Content-Type", "application/json"),
+    };
+    for (name, value) in &endpoint.headers {
+        request = request.header(name, &resolve(value)?);
+    }
+
+    let body = match endpoint.kind {
+        EndpointKind::Ntfy => {
+            let topic = endpoint
+                .topic
+                .as_deref()
+                .ok_or_else(|| anyhow!("ntfy endpoint {} has no topic", url))?;
+            let mut object = serde_json::json!({
+                "topic": topic,
+                "title": title,
+                "message": message,
+                "priority": endpoint.priority.unwrap_or(3),
+            });
+            if !notification.cover.is_empty() {
+                object["icon"] = notification.cover.clone().into();
+            }
+            object.to_string()
+        }
+        EndpointKind::Gotify => serde_json::json!({
+            "title": title,
+            "message": message,
+            "priority": endpoint.priority.unwrap_or(5),
+        })
+        .to_string(),
+        EndpointKind::Webhook => match &endpoint.body {
+            Some(template) => render_template(template, &variables, json_escape),
+            None => {
+                let mut object: BTreeMap<&str, &str> = variables.iter().copied().collect();
+                object.insert("title", &title);
+                object.insert("message", &message);
+                serde_json::to_string(&object)?
+            }
+        },
+    };
+
+    request.send(body)?;
+    Ok(())
+}
+
+/// Répartiteur des notifications
+struct Dispatcher {
+    settings: NotificationSettings,
+    registry: Arc<RwLock<DeviceRegistry>>,
+    agent: Agent,
+    /// Dernière piste notifiée par renderer : (titre, artiste, instant)
+    last_tracks: HashMap<DeviceId, (String, String, Instant)>,
+}
+
+impl Dispatcher {
+    /// Nom convivial et UDN d'un renderer connu
+    fn identity(&self, id: &DeviceId) -> Option<(String, String)> {
+        let renderer = self.registry.read().ok()?.get_renderer(id)?;
+        Some((
+            renderer.friendly_name().to_string(),
+            renderer.udn().to_string(),
+        ))
+    }
+
+    fn notification(&self, kind: NotificationKind, id: &DeviceId) -> Option<Notification> {
+        if !self.settings.events.contains(&kind) {
+            return None;
+        }
+        let (renderer, udn) = self
+            .identity(id)
+            .unwrap_or_else(|| (id.0.clone(), String::new()));
+        if !self.settings.renderers.is_empty()
+            && !self
+                .settings
+                .renderers
+                .iter()
+                .any(|selector| crate::groups::member_matches(selector, id, &udn, &renderer))
+        {
+            return None;
+        }
+        Some(Notification {
+            kind,
+            renderer_id: id.0.clone(),
+            renderer,
+            title: String::new(),
+            artist: String::new(),
+            album: String::new(),
+            cover: String::new(),
+            message: String::new(),
+        })
+    }
+
+    /// Notification d'un changement de piste, sauf doublon ou rafale
+    ///
+    /// Les renderers republient les métadonnées de la piste en cours (position,
+    /// pochette) : seule une paire titre/artiste nouvelle est notifiée. Une
+    /// piste écartée par `min_interval` (pistes sautées en rafale) reste
+    /// candidate à la prochaine publication de ses métadonnées.
+    fn track_change(&mut self, id: &DeviceId, metadata: &TrackMetadata) -> Option<Notification> {
+        let title = metadata.title.clone().unwrap_or_default();
+        let artist = metadata
+            .artist
+            .clone()
+            .or_else(|| metadata.creator.clone())
+            .unwrap_or_default();
+        if title.is_empty() {
+            return None;
+        }
+
+        let now = Instant::now();
+        if let Some((last_title, last_artist, at)) = self.last_tracks.get(id) {
+            if *last_title == title && *last_artist == artist {
+                return None;
+            }
+            if now.duration_since(*at) < self.settings.min_interval {
+                debug!("🔕 Track change on {} throttled", id.0);
+                return None;
+            }
+        }
+
+        let mut notification = self.notification(NotificationKind::TrackChange, id)?;
+        self.last_tracks
+            .insert(id.clone(), (title.clone(), artist.clone(), now));
+        notification.title = title;
+        notification.artist = artist;
+        notification.album = metadata.album.clone().unwrap_or_default();
+        notification.cover = metadata.album_art_uri.clone().unwrap_or_default();
+        Some(notification)
+    }
+
+    fn handle(&mut self, event: RendererEvent) {
+        let notification = match event {
+            RendererEvent::MetadataChanged { id, metadata } => self.track_change(&id, &metadata),
+            RendererEvent::PlaybackError { id, message } => self
+                .notification(NotificationKind::Error, &id)
+                .map(|notification| Notification {
+                    message,
+                    ..notification
+                }),
+            RendererEvent::Offline { id } => {
+                self.last_tracks.remove(&id);
+                None
+            }
+            _ => None,
+        };
+        let Some(notification) = notification else {
+            return;
+        };
+
+        for endpoint in &self.settings.endpoints {
+            if !endpoint.events.is_empty() && !endpoint.events.contains(&notification.kind) {
+                continue;
+            }
+            match send(&self.agent, endpoint, &notification) {
+                Ok(()) => debug!(
+                    "🔔 {} notification for {} sent to {}",
+                    notification.kind.as_str(),
+                    notification.renderer,
+                    endpoint.url
+                ),
+                Err(e) => warn!(
+                    "Failed to send {} notification to {}: {}",
+                    notification.kind.as_str(),
+                    endpoint.url,
+                    e
+                ),
+            }
+        }
+    }
+}
+
+/// Démarre le répartiteur si les notifications sont activées
+///
+/// Retourne `false` si elles sont désactivées ou sans destination.
+pub fn spawn_dispatcher(
+    events: Receiver<RendererEvent>,
+    registry: Arc<RwLock<DeviceRegistry>>,
+) -> Result<bool> {
+    let settings = pmoconfig::get_config().get_notification_settings()?;
+    if !settings.enabled || settings.endpoints.is_empty() {
+        return Ok(false);
+    }
+    info!(
+        "🔔 Notifications enabled ({} endpoint(s), events: {:?})",
+        settings.endpoints.len(),
+        settings.events
+    );
+
+    let agent: Agent = Agent::config_builder()
+        .timeout_global(Some(NOTIFICATION_TIMEOUT))
+        .build()
+        .into();
+    let mut dispatcher = Dispatcher {
+        settings,
+        registry,
+        agent,
+        last_tracks: HashMap::new(),
+    };
+
+    thread::Builder::new()
+        .name("cp-notifications".into())
+        .spawn(move || {
+            for event in events {
+                dispatcher.handle(event);
+            }
+        })?;
+    Ok(true)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_render_template() {
+        let vars = [("title", "Let \"It\" Be"), ("renderer", "Salon")];
+        assert_eq!(
+            render_template("{{renderer}}: {{ title }}{{unknown}}", &vars, plain),
+            "Salon: Let \"It\" Be"
+        );
+        assert_eq!(
+            render_template(r#"{"text": "{{title}}"}"#, &vars, json_escape),
+            r#"{"text": "Let \"It\" Be"}"#
+        );
+        assert_eq!(render_template("{{title", &vars, plain), "{{title");
+
+        let endpoint: NotificationEndpoint =
+            serde_yaml::from_str("kind: ntfy\nurl: https://ntfy.sh\nevents: [error]").unwrap();
+        assert_eq!(endpoint.kind, EndpointKind::Ntfy);
+        assert_eq!(endpoint.events, vec![NotificationKind::Error]);
+    }
+}
@@ -297,6 +297,116 @@ pub struct SleepTimerRequest {
     pub duration_seconds: u32,
 }
 
+/// Requête pour changer le mode aléatoire de la queue
+#[cfg(feature = "pmoserver")]
+#[derive(Debug, Clone, Deserialize, ToSchema)]
+pub struct ShuffleModeRequest {
+    /// Mode : "off", "tracks" ou "albums"
+    pub mode: String,
+    /// Graine optionnelle pour un ordre reproductible
+    pub seed: Option<u64>,
+}
+
+/// Requête pour changer le mode de répétition de la queue
+#[cfg(feature = "pmoserver")]
+#[derive(Debug, Clone, Deserialize, ToSchema)]
+pub struct RepeatModeRequest {
+    /// Mode : "off", "one" ou "all"
+    pub mode: String,
+}
+
+/// Position de reprise mémorisée d'un contenu long
+#[cfg(feature = "pmoserver")]
+#[derive(Debug, Clone, Serialize, ToSchema)]
+pub struct ResumePositionEntry {
+    /// URI de la piste
+    pub uri: String,
+    /// Classe : "audiobook" ou "podcast"
+    pub class: String,
+    /// Position mémorisée en secondes
+    pub position_seconds: u32,
+    /// Durée totale en secondes si connue
+    pub duration_seconds: Option<u32>,
+    pub title: Option<String>,
+    /// Date de la dernière mise à jour (RFC 3339)
+    pub updated_at: String,
+}
+
+/// Requête pour fixer une position de reprise
+#[cfg(feature = "pmoserver")]
+#[derive(Debug, Clone, Deserialize, ToSchema)]
+pub struct ResumeSetRequest {
+    /// URI de la piste
+    pub uri: String,
+    /// Position en secondes
+    pub position_seconds: u32,
+    /// Classe : "audiobook" ou "podcast" (défaut : classe connue, sinon "audiobook")
+    pub class: Option<String>,
+}
+
+/// Requête pour oublier une ou toutes les positions de reprise
+#[cfg(feature = "pmoserver")]
+#[derive(Debug, Clone, Deserialize, ToSchema)]
+pub struct ResumeClearRequest {
+    /// URI de la piste (None = toutes les positions du profil)
+    pub uri: Option<String>,
+}
+
+/// Piste de l'historique d'écoute d'un profil
+#[cfg(feature = "pmoserver")]
+#[derive(Debug, Clone, Serialize, ToSchema)]
+pub struct HistoryEntrySummary {
+    pub title: String,
+    pub artist: Option<String>,
+    pub album: Option<String>,
+    pub album_art: Option<String>,
+    /// URI de la piste si connue
+    pub uri: Option<String>,
+    /// ID du renderer qui l'a jouée
+    pub renderer_id: String,
+    /// Nom convivial du renderer
+    pub renderer: String,
+    /// Date d'écoute (RFC 3339)
+    pub played_at: String,
+}
+
+/// Réveil ou lecture programmée (voir [`crate::alarms`])
+#[cfg(feature = "pmoserver")]
+#[derive(Debug, Clone, Serialize, Deserialize, ToSchema)]
+pub struct AlarmEntry {
+    /// Identifiant du réveil (un réveil de même id est remplacé)
+    pub id: String,
+    /// Réveil actif (défaut : true)
+    pub enabled: Option<bool>,
+    /// ID, UDN ou nom convivial du renderer
+    pub renderer: String,
+    /// ID du serveur de médias
+    pub server_id: String,
+    /// Playlist ou radio à lire
+    pub object_id: String,
+    /// Jours de déclenchement ("mon", "tue"…), vide = tous les jours
+    #[serde(default)]
+    pub days: Vec<String>,
+    /// Heure de début (HH:MM, heure locale)
+    pub start: String,
+    /// Heure d'arrêt (HH:MM)
+    pub stop: Option<String>,
+    /// Volume atteint en fin de montée (0-100)
+    pub volume: Option<u16>,
+    /// Durée de la montée du volume (ex: "90s", "2m")
+    pub fade_in: Option<String>,
+}
+
+/// Mode aléatoire de la queue
+#[cfg(feature = "pmoserver")]
+#[derive(Debug, Clone, Serialize, ToSchema)]
+pub struct ShuffleModeState {
+    /// Mode : "off", "tracks" ou "albums"
+    pub mode: String,
+    /// Graine utilisée (None si le mode aléatoire est désactivé)
+    pub seed: Option<u64>,
+}
+
 /// État du sleep timer
 #[cfg(feature = "pmoserver")]
 #[derive(Debug, Clone, Serialize, ToSchema)]
@@ -335,6 +445,60 @@ pub struct ErrorResponse {
     pub error: String,
 }
 
+// ============================================================================
+// GROUPES DE RENDERERS
+// ============================================================================
+
+/// Membre d'un groupe de renderers
+#[cfg(feature = "pmoserver")]
+#[derive(Debug, Clone, Serialize, ToSchema)]
+pub struct GroupMemberSummary {
+    /// Désignation dans la configuration (id, UDN ou nom convivial)
+    pub selector: String,
+    /// ID du renderer découvert correspondant
+    pub renderer_id: Option<String>,
+    /// Nom convivial du renderer découvert
+    pub friendly_name: Option<String>,
+    /// Renderer en ligne
+    pub online: bool,
+    /// Renderer compatible multiroom (LinkPlay)
+    pub multiroom: bool,
+}
+
+/// Groupe de renderers
+#[cfg(feature = "pmoserver")]
+#[derive(Debug, Clone, Serialize, ToSchema)]
+pub struct RendererGroupSummary {
+    /// Nom du groupe
+    pub name: String,
+    /// Membres, dans l'ordre du groupe
+    pub members: Vec<GroupMemberSummary>,
+}
+
+/// Requête de lecture sur un groupe
+#[cfg(feature = "pmoserver")]
+#[derive(Debug, Clone, Default, Deserialize, ToSchema)]
+pub struct GroupPlayRequest {
+    /// URI à lire (défaut : piste courante de la queue du premier membre)
+    pub uri: Option<String>,
+    /// Métadonnées DIDL-Lite associées
+    pub metadata: Option<String>,
+}
+
+/// Résultat d'une commande de groupe pour un membre
+#[cfg(feature = "pmoserver")]
+#[derive(Debug, Clone, Serialize, ToSchema)]
+pub struct GroupMemberResult {
+    /// ID du renderer
+    pub renderer_id: String,
+    /// Nom convivial
+    pub friendly_name: String,
+    /// Rôle : `leader`, `follower` (multiroom) ou `individual`
+    pub mode: String,
+    /// Erreur éventuelle
+    pub error: Option<String>,
+}
+
 // ============================================================================
 // DOCUMENTATION OPENAPI
 // ============================================================================
@@ -439,6 +603,23 @@ POST /control/renderers/{renderer_id}/queue/play
   }
 ```
 
+### Profils d'écoute
+L'en-tête `X-PMO-Profile` (ou `?profile=`) attribue au profil choisi les
+lectures lancées depuis la queue, son historique et ses positions de reprise.
+```
+GET /control/history?limit=50
+POST /control/history/clear
+GET /control/resume
+```
+
+### Jouer sur un groupe de renderers
+```
+GET /control/groups
+POST /control/groups/{group}/play
+  Body: {"uri": "http://..."}   (optionnel : piste courante du premier membre)
+POST /control/groups/{group}/stop
+```
+
 ### Naviguer dans un serveur
 ```
 GET /control/servers/{server_id}/containers/{container_id}
@@ -461,10 +642,21 @@ GET /control/servers/{server_id}/containers/{container_id}
         crate::pmoserver_ext::pause_renderer,
         crate::pmoserver_ext::stop_renderer,
         crate::pmoserver_ext::resume_renderer,
+        crate::pmoserver_ext::wake_renderer,
         crate::pmoserver_ext::next_renderer,
         crate::pmoserver_ext::seek_renderer,
         crate::pmoserver_ext::seek_queue_index,
         crate::pmoserver_ext::shuffle_queue,
+        crate::pmoserver_ext::set_shuffle_mode,
+        crate::pmoserver_ext::set_repeat_mode,
+        crate::pmoserver_ext::list_resume_positions,
+        crate::pmoserver_ext::set_resume_position,
+        crate::pmoserver_ext::clear_resume_positions,
+        crate::pmoserver_ext::list_history,
+        crate::pmoserver_ext::clear_history,
+        crate::pmoserver_ext::list_alarms,
+        crate::pmoserver_ext::save_alarm,
+        crate::pmoserver_ext::delete_alarm,
         crate::pmoserver_ext::set_renderer_volume,
         crate::pmoserver_ext::volume_up_renderer,
         crate::pmoserver_ext::volume_down_renderer,
@@ -480,6 +672,9 @@ GET /control/servers/{server_id}/containers/{container_id}
         crate::pmoserver_ext::transfer_queue,
         crate::pmoserver_ext::list_servers,
         crate::pmoserver_ext::browse_container,
+        crate::pmoserver_ext::list_groups,
+        crate::pmoserver_ext::play_group,
+        crate::pmoserver_ext::stop_group,
         crate::sse::all_events_sse,
         crate::sse::renderer_events_sse,
         crate::sse::media_server_events_sse,
@@ -505,6 +700,18 @@ GET /control/servers/{server_id}/containers/{container_id}
         TransferQueueRequest,
         SleepTimerRequest,
         SleepTimerState,
+        ShuffleModeRequest,
+        ShuffleModeState,
+        RepeatModeRequest,
+        ResumePositionEntry,
+        ResumeSetRequest,
+        ResumeClearRequest,
+        HistoryEntrySummary,
+        AlarmEntry,
+        GroupMemberSummary,
+        RendererGroupSummary,
+        GroupPlayRequest,
+        GroupMemberResult,
         SuccessResponse,
         ErrorResponse,
     )),
@@ -3,21 +3,27 @@
 //! Ce module fournit une API REST pour contrôler les renderers UPnP
 //! et naviguer dans les serveurs de médias.
 
+#[cfg(feature = "pmoserver")]
+use crate::config_ext::ControlPointConfigExt;
 #[cfg(feature = "pmoserver")]
 use crate::control_point::ControlPoint;
 #[cfg(feature = "pmoserver")]
-use crate::media_server::{MediaBrowser, playback_item_from_entry};
+use crate::groups::{GroupMemberOutcome, member_matches};
+#[cfg(feature = "pmoserver")]
+use crate::media_server::MediaBrowser;
 #[cfg(feature = "pmoserver")]
 use crate::MediaEntry;
 #[cfg(feature = "pmoserver")]
 use crate::model::{RendererCapabilities, RendererProtocol};
 #[cfg(feature = "pmoserver")]
 use crate::openapi::{
-    AttachPlaylistRequest, AttachedPlaylistInfo, BrowseResponse, ContainerEntry, ErrorResponse,
-    FullRendererSnapshot, MediaServerSummary, PlayContentRequest, QueueSnapshot,
-    RendererCapabilitiesSummary, RendererProtocolSummary, RendererState, RendererSummary,
-    SeekQueueRequest, SeekRequest, SleepTimerRequest, SleepTimerState, StreamState,
-    SuccessResponse, TransferQueueRequest, VolumeSetRequest,
+    AlarmEntry, AttachPlaylistRequest, AttachedPlaylistInfo, BrowseResponse, ContainerEntry,
+    ErrorResponse, FullRendererSnapshot, GroupMemberResult, GroupMemberSummary, GroupPlayRequest,
+    HistoryEntrySummary, MediaServerSummary, PlayContentRequest, QueueSnapshot,
+    RendererCapabilitiesSummary, RendererGroupSummary, RendererProtocolSummary, RendererState,
+    RendererSummary, RepeatModeRequest, ResumeClearRequest, ResumePositionEntry, ResumeSetRequest,
+    SeekQueueRequest, SeekRequest, ShuffleModeRequest, ShuffleModeState, SleepTimerRequest,
+    SleepTimerState, StreamState, SuccessResponse, TransferQueueRequest, VolumeSetRequest,
 };
 #[cfg(feature = "pmoserver")]
 use crate::queue::PlaybackItem;
@@ -25,6 +31,8 @@ use crate::queue::PlaybackItem;
 use crate::{DeviceId, DeviceIdentity, DeviceOnline};
 #[cfg(feature = "pmoserver")]
 use pmocovers;
+#[cfg(feature = "pmoserver")]
+use pmoserver::Profile;
 
 #[cfg(feature = "pmoserver")]
 use async_trait::async_trait;
@@ -33,7 +41,7 @@ use axum::{
     Json, Router,
     extract::{Path, Query, State},
     http::{StatusCode, header::HeaderMap},
-    routing::{get, post},
+    routing::{delete, get, post},
 };
 #[cfg(feature = "pmoserver")]
 use std::sync::Arc;
@@ -313,8 +321,10 @@ async fn get_renderer_binding(
 async fn play_renderer(
     State(state): State<ControlPointState>,
     Path(renderer_id): Path<String>,
+    Profile(profile): Profile,
 ) -> Result<Json<SuccessResponse>, (StatusCode, Json<ErrorResponse>)> {
     let rid = DeviceId(renderer_id.clone());
+    state.control_point.renderer_profiles().set(&rid, &profile);
     let renderer = state
         .control_point
         .music_renderer_by_id(&rid)
@@ -544,7 +554,7 @@ async fn resume_renderer(
     Path(renderer_id): Path<String>,
 ) -> Result<Json<SuccessResponse>, (StatusCode, Json<ErrorResponse>)> {
     let rid = DeviceId(renderer_id.clone());
-    state
+    let renderer = state
         .control_point
         .music_renderer_by_id(&rid)
         .ok_or_else(|| {
@@ -556,25 +566,29 @@ async fn resume_renderer(
             )
         })?;
 
+    // Un renderer hors ligne est d'abord réveillé par Wake-on-LAN
+    let timeout = if renderer.is_online() {
+        TRANSPORT_COMMAND_TIMEOUT
+    } else {
+        TRANSPORT_COMMAND_TIMEOUT + wake_timeout()
+    };
+
     let control_point = Arc::clone(&state.control_point);
     let rid_for_task = rid.clone();
     let resume_task =
         tokio::task::spawn_blocking(move || control_point.play_current_from_queue(&rid_for_task));
 
-    time::timeout(TRANSPORT_COMMAND_TIMEOUT, resume_task)
+    time::timeout(timeout, resume_task)
         .await
         .map_err(|_| {
             warn!(
                 "Resume command for renderer {} exceeded {:?}",
-                renderer_id, TRANSPORT_COMMAND_TIMEOUT
+                renderer_id, timeout
             );
             (
                 StatusCode::GATEWAY_TIMEOUT,
                 Json(ErrorResponse {
-                    error: format!(
-                        "Resume command timed out after {}s",
-                        TRANSPORT_COMMAND_TIMEOUT.as_secs()
-                    ),
+                    error: format!("Resume command timed out after {}s", timeout.as_secs()),
                 }),
             )
         })?
@@ -605,6 +619,87 @@ async fn resume_renderer(
     }))
 }
 
+/// Attente maximale du réveil d'un renderer, marge de l'envoi comprise
+#[cfg(feature = "pmoserver")]
+fn wake_timeout() -> Duration {
+    pmoconfig::get_config()
+        .get_wol_wait()
+        .unwrap_or(Duration::from_secs(30))
+        + Duration::from_secs(1)
+}
+
+/// POST /control/renderers/{renderer_id}/wake - Réveille un renderer en veille
+#[cfg(feature = "pmoserver")]
+#[utoipa::path(
+    post,
+    path = "/renderers/{renderer_id}/wake",
+    params(
+        ("renderer_id" = String, Path, description = "ID unique du renderer")
+    ),
+    responses(
+        (status = 200, description = "Renderer en ligne", body = SuccessResponse),
+        (status = 404, description = "Renderer non trouvé", body = ErrorResponse),
+        (status = 500, description = "Adresse MAC inconnue ou envoi impossible", body = ErrorResponse),
+        (status = 504, description = "Renderer toujours hors ligne", body = ErrorResponse)
+    ),
+    tag = "control"
+)]
+async fn wake_renderer(
+    State(state): State<ControlPointState>,
+    Path(renderer_id): Path<String>,
+) -> Result<Json<SuccessResponse>, (StatusCode, Json<ErrorResponse>)> {
+    let rid = DeviceId(renderer_id.clone());
+    state
+        .control_point
+        .music_renderer_by_id(&rid)
+        .ok_or_else(|| {
+            (
+                StatusCode::NOT_FOUND,
+                Json(ErrorResponse {
+                    error: format!("Renderer {} not found", renderer_id),
+                }),
+            )
+        })?;
+
+    let control_point = Arc::clone(&state.control_point);
+    let wake_task = tokio::task::spawn_blocking(move || control_point.wake_renderer(&rid));
+
+    let online = time::timeout(wake_timeout(), wake_task)
+        .await
+        .unwrap_or(Ok(Ok(false)))
+        .map_err(|e| {
+            warn!("Task join error during wake: {}", e);
+            (
+                StatusCode::INTERNAL_SERVER_ERROR,
+                Json(ErrorResponse {
+                    error: format!("Internal task error: {}", e),
+                }),
+            )
+        })?
+        .map_err(|e| {
+            warn!("Failed to wake renderer {}: {}", renderer_id, e);
+            (
+                StatusCode::INTERNAL_SERVER_ERROR,
+                Json(ErrorResponse {
+                    error: format!("Failed to wake renderer: {}", e),
+                }),
+            )
+        })?;
+
+    if !online {
+        return Err((
+            StatusCode::GATEWAY_TIMEOUT,
+            Json(ErrorResponse {
+                error: format!("Renderer {} did not come back online", renderer_id),
+            }),
+        ));
+    }
+
+    Ok(Json(SuccessResponse {
+        message: "Renderer is online".to_string(),
+    }))
+}
+
 /// POST /control/renderers/{renderer_id}/next - Passe au morceau suivant
 #[cfg(feature = "pmoserver")]
 #[utoipa::path(
@@ -703,9 +798,11 @@ async fn next_renderer(
 async fn seek_queue_index(
     State(state): State<ControlPointState>,
     Path(renderer_id): Path<String>,
+    Profile(profile): Profile,
     Json(payload): Json<SeekQueueRequest>,
 ) -> Result<Json<SuccessResponse>, (StatusCode, Json<ErrorResponse>)> {
     let rid = DeviceId(renderer_id.clone());
+    state.control_point.renderer_profiles().set(&rid, &profile);
     state
         .control_point
         .music_renderer_by_id(&rid)
@@ -1524,59 +1621,73 @@ async fn shuffle_queue(
     }))
 }
 
-// ============================================================================
-// HANDLERS - BINDING PLAYLIST
-// ============================================================================
-
-/// POST /control/renderers/{renderer_id}/binding/attach - Attache une playlist
+/// POST /control/renderers/{renderer_id}/queue/shuffle_mode - Change le mode aléatoire
+///
+/// Contrairement à `/queue/shuffle`, la lecture n'est pas interrompue : seuls
+/// les morceaux à venir sont réordonnés, et le mode "off" restaure l'ordre
+/// d'origine.
 #[cfg(feature = "pmoserver")]
 #[utoipa::path(
     post,
-    path = "/renderers/{renderer_id}/binding/attach",
+    path = "/renderers/{renderer_id}/queue/shuffle_mode",
     params(
         ("renderer_id" = String, Path, description = "ID unique du renderer")
     ),
-    request_body = AttachPlaylistRequest,
+    request_body = ShuffleModeRequest,
     responses(
-        (status = 200, description = "Playlist attachée", body = SuccessResponse),
-        (status = 404, description = "Renderer non trouvé", body = ErrorResponse)
+        (status = 200, description = "Mode aléatoire appliqué", body = ShuffleModeState),
+        (status = 404, description = "Renderer non trouvé", body = ErrorResponse),
+        (status = 400, description = "Mode invalide", body = ErrorResponse),
+        (status = 504, description = "Timeout de la commande", body = ErrorResponse),
+        (status = 500, description = "Erreur lors de l'exécution", body = ErrorResponse)
     ),
     tag = "control"
 )]
-async fn attach_playlist_binding(
+async fn set_shuffle_mode(
     State(state): State<ControlPointState>,
     Path(renderer_id): Path<String>,
-    Json(req): Json<AttachPlaylistRequest>,
-) -> Result<Json<SuccessResponse>, (StatusCode, Json<ErrorResponse>)> {
+    Json(req): Json<ShuffleModeRequest>,
+) -> Result<Json<ShuffleModeState>, (StatusCode, Json<ErrorResponse>)> {
     let rid = DeviceId(renderer_id.clone());
-    let sid = DeviceId(req.server_id.clone());
-    let container_id = req.container_id.clone();
-    let control_point = Arc::clone(&state.control_point);
 
-    // Spawn blocking task and wait for completion with timeout
-    let attach_task = tokio::task::spawn_blocking(move || {
-        control_point.attach_queue_to_playlist_with_options(&rid, sid, container_id, req.auto_play)
+    let mode = req
+        .mode
+        .parse::<crate::ShuffleMode>()
+        .map_err(|e| (StatusCode::BAD_REQUEST, Json(ErrorResponse { error: e })))?;
+
+    let renderer = state
+        .control_point
+        .music_renderer_by_id(&rid)
+        .ok_or_else(|| {
+            (
+                StatusCode::NOT_FOUND,
+                Json(ErrorResponse {
+                    error: format!("Renderer {} not found", renderer_id),
+                }),
+            )
+        })?;
+
+    let control_point = Arc::clone(&state.control_point);
+    let rid_for_task = rid.clone();
+    let seed = req.seed;
+    let task = tokio::task::spawn_blocking(move || {
+        control_point.set_shuffle_mode(&rid_for_task, mode, seed)
     });
 
-    time::timeout(ATTACH_PLAYLIST_TIMEOUT, attach_task)
+    time::timeout(QUEUE_COMMAND_TIMEOUT, task)
         .await
         .map_err(|_| {
-            warn!(
-                "Attach playlist for renderer {} exceeded {:?}",
-                renderer_id, ATTACH_PLAYLIST_TIMEOUT
-            );
             (
                 StatusCode::GATEWAY_TIMEOUT,
                 Json(ErrorResponse {
                     error: format!(
-                        "Attach playlist timed out after {}s",
-                        ATTACH_PLAYLIST_TIMEOUT.as_secs()
+                        "Shuffle mode command timed out after {}s",
+                        QUEUE_COMMAND_TIMEOUT.as_secs()
                     ),
                 }),
             )
         })?
         .map_err(|e| {
-            warn!("Task join error during attach playlist: {}", e);
             (
                 StatusCode::INTERNAL_SERVER_ERROR,
                 Json(ErrorResponse {
@@ -1586,95 +1697,53 @@ async fn attach_playlist_binding(
         })?
         .map_err(|e| {
             warn!(
-                renderer = renderer_id.as_str(),
-                server = req.server_id.as_str(),
-                container = req.container_id.as_str(),
-                error = %e,
-                "Failed to attach playlist"
+                "Failed to set shuffle mode for renderer {}: {}",
+                renderer_id, e
             );
             (
                 StatusCode::INTERNAL_SERVER_ERROR,
                 Json(ErrorResponse {
-                    error: format!("Failed to attach playlist: {}", e),
+                    error: format!("Failed to set shuffle mode: {}", e),
                 }),
             )
         })?;
 
-    debug!(
-        renderer = renderer_id.as_str(),
-        server = req.server_id.as_str(),
-        container = req.container_id.as_str(),
-        auto_play = req.auto_play,
-        "Playlist attached via HTTP API"
-    );
-
-    Ok(Json(SuccessResponse {
-        message: format!("Playlist {} attached to renderer", req.container_id),
+    let (mode, seed) = renderer.shuffle_mode();
+    Ok(Json(ShuffleModeState {
+        mode: mode.as_str().to_string(),
+        seed,
     }))
 }
 
-/// POST /control/renderers/{renderer_id}/binding/detach - Détache la playlist
-#[cfg(feature = "pmoserver")]
-#[utoipa::path(
-    post,
-    path = "/renderers/{renderer_id}/binding/detach",
-    params(
-        ("renderer_id" = String, Path, description = "ID unique du renderer")
-    ),
-    responses(
-        (status = 200, description = "Playlist détachée", body = SuccessResponse)
-    ),
-    tag = "control"
-)]
-async fn detach_playlist_binding(
-    State(state): State<ControlPointState>,
-    Path(renderer_id): Path<String>,
-) -> Json<SuccessResponse> {
-    let rid = DeviceId(renderer_id.clone());
-
-    state.control_point.detach_queue_playlist(&rid);
-
-    debug!(
-        renderer = renderer_id.as_str(),
-        "Playlist detached via HTTP API"
-    );
-
-    Json(SuccessResponse {
-        message: "Playlist detached".to_string(),
-    })
-}
-
-// ============================================================================
-// HANDLERS - QUEUE CONTENT
-// ============================================================================
-
-/// POST /control/renderers/{renderer_id}/queue/play - Lire du contenu immédiatement
+/// POST /control/renderers/{renderer_id}/queue/repeat_mode - Change le mode de répétition
 #[cfg(feature = "pmoserver")]
 #[utoipa::path(
     post,
-    path = "/renderers/{renderer_id}/queue/play",
+    path = "/renderers/{renderer_id}/queue/repeat_mode",
     params(
         ("renderer_id" = String, Path, description = "ID unique du renderer")
     ),
-    request_body = PlayContentRequest,
+    request_body = RepeatModeRequest,
     responses(
-        (status = 200, description = "Contenu en cours de lecture", body = SuccessResponse),
-        (status = 404, description = "Renderer ou serveur non trouvé", body = ErrorResponse),
+        (status = 200, description = "Mode de répétition appliqué", body = SuccessResponse),
+        (status = 404, description = "Renderer non trouvé", body = ErrorResponse),
+        (status = 400, description = "Mode invalide", body = ErrorResponse),
         (status = 500, description = "Erreur lors de l'exécution", body = ErrorResponse)
     ),
     tag = "control"
 )]
-async fn play_content(
+async fn set_repeat_mode(
     State(state): State<ControlPointState>,
     Path(renderer_id): Path<String>,
-    Json(req): Json<PlayContentRequest>,
+    Json(req): Json<RepeatModeRequest>,
 ) -> Result<Json<SuccessResponse>, (StatusCode, Json<ErrorResponse>)> {
     let rid = DeviceId(renderer_id.clone());
-    let sid = DeviceId(req.server_id.clone());
-    let object_id = req.object_id.clone();
-    let object_id_for_log = object_id.clone();
 
-    // Get renderer to verify it exists
+    let repeat = req
+        .mode
+        .parse::<crate::RepeatMode>()
+        .map_err(|e| (StatusCode::BAD_REQUEST, Json(ErrorResponse { error: e })))?;
+
     state
         .control_point
         .music_renderer_by_id(&rid)
@@ -1688,65 +1757,526 @@ async fn play_content(
         })?;
 
     let control_point = Arc::clone(&state.control_point);
-    let rid_for_log = rid.clone();
-    let object_id_for_log = object_id.clone();
-    let object_id_for_debug = object_id_for_log.clone();
-
-    // Launch the command in background and return immediately
-    // The UI will be updated via SSE events when playback starts
-    tokio::task::spawn(async move {
-        let result = tokio::task::spawn_blocking(move || {
-            debug!(
-                renderer = rid.0.as_str(),
-                server = sid.0.as_str(),
-                object = object_id.as_str(),
-                "play_content: fetching playback items"
-            );
-
-            // Fetch playback items from server
-            let items = fetch_playback_items(&control_point, &sid, &object_id)?;
-
-            debug!(
-                renderer = rid.0.as_str(),
-                item_count = items.len(),
-                "play_content: fetched items"
+    tokio::task::spawn_blocking(move || control_point.set_repeat_mode(&rid, repeat))
+        .await
+        .map_err(|e| {
+            (
+                StatusCode::INTERNAL_SERVER_ERROR,
+                Json(ErrorResponse {
+                    error: format!("Internal task error: {}", e),
+                }),
+            )
+        })?
+        .map_err(|e| {
+            warn!(
+                "Failed to set repeat mode for renderer {}: {}",
+                renderer_id, e
             );
+            (
+                StatusCode::INTERNAL_SERVER_ERROR,
+                Json(ErrorResponse {
+                    error: format!("Failed to set repeat mode: {}", e),
+                }),
+            )
+        })?;
 
-            if items.is_empty() {
-                return Err(anyhow::anyhow!("No playable content found"));
-            }
+    Ok(Json(SuccessResponse {
+        message: format!("Repeat mode set to {}", repeat.as_str()),
+    }))
+}
 
-            if items.len() > 1 {
-                debug!(
-                    renderer = rid.0.as_str(),
-                    server = sid.0.as_str(),
-                    object = object_id.as_str(),
-                    item_count = items.len(),
-                    "Auto-binding playlist to renderer queue (auto_play = true)"
-                );
-                control_point.attach_queue_to_playlist_with_options(
-                    &rid,
-                    sid.clone(),
-                    object_id.clone(),
-                    true,
-                )?;
-                return Ok(());
-            }
+// ============================================================================
+// HANDLERS - REPRISE DES CONTENUS LONGS
+// ============================================================================
 
-            // Clear queue
-            control_point.clear_queue(&rid)?;
+/// GET /control/resume - Liste les positions de reprise mémorisées
+#[cfg(feature = "pmoserver")]
+#[utoipa::path(
+    get,
+    path = "/resume",
+    responses(
+        (status = 200, description = "Positions de reprise, les plus récentes d'abord", body = Vec<ResumePositionEntry>)
+    ),
+    tag = "control"
+)]
+async fn list_resume_positions(
+    State(state): State<ControlPointState>,
+    Profile(profile): Profile,
+) -> Json<Vec<ResumePositionEntry>> {
+    let positions = state
+        .control_point
+        .resume_positions()
+        .list(Some(&profile))
+        .into_iter()
+        .map(|p| ResumePositionEntry {
+            uri: p.uri,
+            class: p.class.as_str().to_string(),
+            position_seconds: p.position_secs,
+            duration_seconds: p.duration_secs,
+            title: p.title,
+            updated_at: p.updated_at.to_rfc3339(),
+        })
+        .collect();
+    Json(positions)
+}
 
-            // Enqueue items
-            control_point.enqueue_items(&rid, items)?;
+/// POST /control/resume/set - Fixe la position de reprise d'une piste
+#[cfg(feature = "pmoserver")]
+#[utoipa::path(
+    post,
+    path = "/resume/set",
+    request_body = ResumeSetRequest,
+    responses(
+        (status = 200, description = "Position de reprise enregistrée", body = SuccessResponse),
+        (status = 400, description = "Classe invalide", body = ErrorResponse)
+    ),
+    tag = "control"
+)]
+async fn set_resume_position(
+    State(state): State<ControlPointState>,
+    Profile(profile): Profile,
+    Json(req): Json<ResumeSetRequest>,
+) -> Result<Json<SuccessResponse>, (StatusCode, Json<ErrorResponse>)> {
+    let class = req
+        .class
+        .as_deref()
+        .map(str::parse::<crate::resume::ResumeClass>)
+        .transpose()
+        .map_err(|e| (StatusCode::BAD_REQUEST, Json(ErrorResponse { error: e })))?;
+
+    let store = state.control_point.resume_positions();
+    store.set_position(&profile, &req.uri, req.position_seconds, class);
+    if let Err(e) = store.flush() {
+        warn!("Failed to save resume positions: {}", e);
+    }
 
-            // Start playback
-            // Pour les renderers OpenHome, play_current_from_queue() va gérer automatiquement
-            // la lecture depuis la playlist native si elle existe
-            control_point.play_current_from_queue(&rid)?;
+    Ok(Json(SuccessResponse {
+        message: format!(
+            "Resume position of {} set to {}s",
+            req.uri, req.position_seconds
+        ),
+    }))
+}
 
-            Ok::<(), anyhow::Error>(())
-        })
-        .await;
+/// POST /control/resume/clear - Oublie une position de reprise (ou toutes)
+#[cfg(feature = "pmoserver")]
+#[utoipa::path(
+    post,
+    path = "/resume/clear",
+    request_body = ResumeClearRequest,
+    responses(
+        (status = 200, description = "Position(s) oubliée(s)", body = SuccessResponse),
+        (status = 404, description = "Aucune position pour cette URI", body = ErrorResponse)
+    ),
+    tag = "control"
+)]
+async fn clear_resume_positions(
+    State(state): State<ControlPointState>,
+    Profile(profile): Profile,
+    Json(req): Json<ResumeClearRequest>,
+) -> Result<Json<SuccessResponse>, (StatusCode, Json<ErrorResponse>)> {
+    let store = state.control_point.resume_positions();
+    let message = match req.uri {
+        Some(uri) => {
+            if !store.clear(&profile, &uri) {
+                return Err((
+                    StatusCode::NOT_FOUND,
+                    Json(ErrorResponse {
+                        error: format!("No resume position for {}", uri),
+                    }),
+                ));
+            }
+            format!("Resume position of {} cleared", uri)
+        }
+        None => format!("{} resume positions cleared", store.clear_all(&profile)),
+    };
+    if let Err(e) = store.flush() {
+        warn!("Failed to save resume positions: {}", e);
+    }
+
+    Ok(Json(SuccessResponse { message }))
+}
+
+// ============================================================================
+// HANDLERS - HISTORY
+// ============================================================================
+
+/// Paramètres de l'historique
+#[cfg(feature = "pmoserver")]
+#[derive(Debug, serde::Deserialize)]
+struct HistoryQuery {
+    limit: Option<usize>,
+}
+
+/// GET /control/history - Historique d'écoute du profil
+#[cfg(feature = "pmoserver")]
+#[utoipa::path(
+    get,
+    path = "/history",
+    params(
+        ("limit" = Option<usize>, Query, description = "Nombre maximal de pistes")
+    ),
+    responses(
+        (status = 200, description = "Pistes écoutées, les plus récentes d'abord", body = Vec<HistoryEntrySummary>)
+    ),
+    tag = "control"
+)]
+async fn list_history(
+    State(state): State<ControlPointState>,
+    Profile(profile): Profile,
+    Query(params): Query<HistoryQuery>,
+) -> Json<Vec<HistoryEntrySummary>> {
+    let entries = state
+        .control_point
+        .history()
+        .list(&profile, params.limit)
+        .into_iter()
+        .map(|e| HistoryEntrySummary {
+            title: e.title,
+            artist: e.artist,
+            album: e.album,
+            album_art: e.album_art,
+            uri: e.uri,
+            renderer_id: e.renderer_id,
+            renderer: e.renderer,
+            played_at: e.played_at.to_rfc3339(),
+        })
+        .collect();
+    Json(entries)
+}
+
+/// POST /control/history/clear - Efface l'historique d'écoute du profil
+#[cfg(feature = "pmoserver")]
+#[utoipa::path(
+    post,
+    path = "/history/clear",
+    responses(
+        (status = 200, description = "Historique effacé", body = SuccessResponse)
+    ),
+    tag = "control"
+)]
+async fn clear_history(
+    State(state): State<ControlPointState>,
+    Profile(profile): Profile,
+) -> Json<SuccessResponse> {
+    let history = state.control_point.history();
+    let count = history.clear(&profile);
+    if let Err(e) = history.flush() {
+        warn!("Failed to save listening history: {}", e);
+    }
+    Json(SuccessResponse {
+        message: format!("{} history entries of profile {} cleared", count, profile),
+    })
+}
+
+// ============================================================================
+// HANDLERS - RÉVEILS
+// ============================================================================
+
+#[cfg(feature = "pmoserver")]
+fn alarm_entry(alarm: crate::alarms::Alarm) -> AlarmEntry {
+    AlarmEntry {
+        id: alarm.id,
+        enabled: Some(alarm.enabled),
+        renderer: alarm.renderer,
+        server_id: alarm.server_id,
+        object_id: alarm.object_id,
+        days: alarm
+            .days
+            .iter()
+            .map(|d| d.to_string().to_lowercase())
+            .collect(),
+        start: alarm.start,
+        stop: alarm.stop,
+        volume: alarm.volume,
+        fade_in: alarm.fade_in,
+    }
+}
+
+#[cfg(feature = "pmoserver")]
+fn alarm_from_entry(entry: AlarmEntry) -> Result<crate::alarms::Alarm, String> {
+    let days = entry
+        .days
+        .iter()
+        .map(|d| {
+            d.parse::<chrono::Weekday>()
+                .map_err(|_| format!("Invalid day: {}", d))
+        })
+        .collect::<Result<Vec<_>, _>>()?;
+    Ok(crate::alarms::Alarm {
+        id: entry.id,
+        enabled: entry.enabled.unwrap_or(true),
+        renderer: entry.renderer,
+        server_id: entry.server_id,
+        object_id: entry.object_id,
+        days,
+        start: entry.start,
+        stop: entry.stop,
+        volume: entry.volume,
+        fade_in: entry.fade_in,
+    })
+}
+
+/// GET /control/alarms - Liste les réveils
+#[cfg(feature = "pmoserver")]
+#[utoipa::path(
+    get,
+    path = "/alarms",
+    responses(
+        (status = 200, description = "Réveils et lectures programmées", body = Vec<AlarmEntry>),
+        (status = 500, description = "Configuration invalide", body = ErrorResponse)
+    ),
+    tag = "control"
+)]
+async fn list_alarms() -> Result<Json<Vec<AlarmEntry>>, (StatusCode, Json<ErrorResponse>)> {
+    let alarms = pmoconfig::get_config().get_alarms().map_err(|e| {
+        (
+            StatusCode::INTERNAL_SERVER_ERROR,
+            Json(ErrorResponse {
+                error: e.to_string(),
+            }),
+        )
+    })?;
+    Ok(Json(alarms.into_iter().map(alarm_entry).collect()))
+}
+
+/// POST /control/alarms - Crée ou remplace un réveil
+#[cfg(feature = "pmoserver")]
+#[utoipa::path(
+    post,
+    path = "/alarms",
+    request_body = AlarmEntry,
+    responses(
+        (status = 200, description = "Réveil enregistré", body = SuccessResponse),
+        (status = 400, description = "Réveil invalide", body = ErrorResponse)
+    ),
+    tag = "control"
+)]
+async fn save_alarm(
+    Json(req): Json<AlarmEntry>,
+) -> Result<Json<SuccessResponse>, (StatusCode, Json<ErrorResponse>)> {
+    let bad_request = |error: String| (StatusCode::BAD_REQUEST, Json(ErrorResponse { error }));
+    let alarm = alarm_from_entry(req).map_err(bad_request)?;
+    pmoconfig::get_config()
+        .set_alarm(&alarm)
+        .map_err(|e| bad_request(e.to_string()))?;
+    Ok(Json(SuccessResponse {
+        message: format!("Alarm {} saved", alarm.id),
+    }))
+}
+
+/// DELETE /control/alarms/{alarm_id} - Supprime un réveil
+#[cfg(feature = "pmoserver")]
+#[utoipa::path(
+    delete,
+    path = "/alarms/{alarm_id}",
+    params(
+        ("alarm_id" = String, Path, description = "Identifiant du réveil")
+    ),
+    responses(
+        (status = 200, description = "Réveil supprimé", body = SuccessResponse),
+        (status = 404, description = "Réveil non trouvé", body = ErrorResponse)
+    ),
+    tag = "control"
+)]
+async fn delete_alarm(
+    Path(alarm_id): Path<String>,
+) -> Result<Json<SuccessResponse>, (StatusCode, Json<ErrorResponse>)> {
+    match pmoconfig::get_config().remove_alarm(&alarm_id) {
+        Ok(true) => Ok(Json(SuccessResponse {
+            message: format!("Alarm {} removed", alarm_id),
+        })),
+        Ok(false) => Err((
+            StatusCode::NOT_FOUND,
+            Json(ErrorResponse {
+                error: format!("Alarm {} not found", alarm_id),
+            }),
+        )),
+        Err(e) => Err((
+            StatusCode::INTERNAL_SERVER_ERROR,
+            Json(ErrorResponse {
+                error: e.to_string(),
+            }),
+        )),
+    }
+}
+
+// ============================================================================
+// HANDLERS - BINDING PLAYLIST
+// ============================================================================
+
+/// POST /control/renderers/{renderer_id}/binding/attach - Attache une playlist
+#[cfg(feature = "pmoserver")]
+#[utoipa::path(
+    post,
+    path = "/renderers/{renderer_id}/binding/attach",
+    params(
+        ("renderer_id" = String, Path, description = "ID unique du renderer")
+    ),
+    request_body = AttachPlaylistRequest,
+    responses(
+        (status = 200, description = "Playlist attachée", body = SuccessResponse),
+        (status = 404, description = "Renderer non trouvé", body = ErrorResponse)
+    ),
+    tag = "control"
+)]
+async fn attach_playlist_binding(
+    State(state): State<ControlPointState>,
+    Path(renderer_id): Path<String>,
+    Json(req): Json<AttachPlaylistRequest>,
+) -> Result<Json<SuccessResponse>, (StatusCode, Json<ErrorResponse>)> {
+    let rid = DeviceId(renderer_id.clone());
+    let sid = DeviceId(req.server_id.clone());
+    let container_id = req.container_id.clone();
+    let control_point = Arc::clone(&state.control_point);
+
+    // Spawn blocking task and wait for completion with timeout
+    let attach_task = tokio::task::spawn_blocking(move || {
+        control_point.attach_queue_to_playlist_with_options(&rid, sid, container_id, req.auto_play)
+    });
+
+    time::timeout(ATTACH_PLAYLIST_TIMEOUT, attach_task)
+        .await
+        .map_err(|_| {
+            warn!(
+                "Attach playlist for renderer {} exceeded {:?}",
+                renderer_id, ATTACH_PLAYLIST_TIMEOUT
+            );
+            (
+                StatusCode::GATEWAY_TIMEOUT,
+                Json(ErrorResponse {
+                    error: format!(
+                        "Attach playlist timed out after {}s",
+                        ATTACH_PLAYLIST_TIMEOUT.as_secs()
+                    ),
+                }),
+            )
+        })?
+        .map_err(|e| {
+            warn!("Task join error during attach playlist: {}", e);
+            (
+                StatusCode::INTERNAL_SERVER_ERROR,
+                Json(ErrorResponse {
+                    error: format!("Internal task error: {}", e),
+                }),
+            )
+        })?
+        .map_err(|e| {
+            warn!(
+                renderer = renderer_id.as_str(),
+                server = req.server_id.as_str(),
+                container = req.container_id.as_str(),
+                error = %e,
+                "Failed to attach playlist"
+            );
+            (
+                StatusCode::INTERNAL_SERVER_ERROR,
+                Json(ErrorResponse {
+                    error: format!("Failed to attach playlist: {}", e),
+                }),
+            )
+        })?;
+
+    debug!(
+        renderer = renderer_id.as_str(),
+        server = req.server_id.as_str(),
+        container = req.container_id.as_str(),
+        auto_play = req.auto_play,
+        "Playlist attached via HTTP API"
+    );
+
+    Ok(Json(SuccessResponse {
+        message: format!("Playlist {} attached to renderer", req.container_id),
+    }))
+}
+
+/// POST /control/renderers/{renderer_id}/binding/detach - Détache la playlist
+#[cfg(feature = "pmoserver")]
+#[utoipa::path(
+    post,
+    path = "/renderers/{renderer_id}/binding/detach",
+    params(
+        ("renderer_id" = String, Path, description = "ID unique du renderer")
+    ),
+    responses(
+        (status = 200, description = "Playlist détachée", body = SuccessResponse)
+    ),
+    tag = "control"
+)]
+async fn detach_playlist_binding(
+    State(state): State<ControlPointState>,
+    Path(renderer_id): Path<String>,
+) -> Json<SuccessResponse> {
+    let rid = DeviceId(renderer_id.clone());
+
+    state.control_point.detach_queue_playlist(&rid);
+
+    debug!(
+        renderer = renderer_id.as_str(),
+        "Playlist detached via HTTP API"
+    );
+
+    Json(SuccessResponse {
+        message: "Playlist detached".to_string(),
+    })
+}
+
+// ============================================================================
+// HANDLERS - QUEUE CONTENT
+// ============================================================================
+
+/// POST /control/renderers/{renderer_id}/queue/play - Lire du contenu immédiatement
+#[cfg(feature = "pmoserver")]
+#[utoipa::path(
+    post,
+    path = "/renderers/{renderer_id}/queue/play",
+    params(
+        ("renderer_id" = String, Path, description = "ID unique du renderer")
+    ),
+    request_body = PlayContentRequest,
+    responses(
+        (status = 200, description = "Contenu en cours de lecture", body = SuccessResponse),
+        (status = 404, description = "Renderer ou serveur non trouvé", body = ErrorResponse),
+        (status = 500, description = "Erreur lors de l'exécution", body = ErrorResponse)
+    ),
+    tag = "control"
+)]
+async fn play_content(
+    State(state): State<ControlPointState>,
+    Path(renderer_id): Path<String>,
+    Profile(profile): Profile,
+    Json(req): Json<PlayContentRequest>,
+) -> Result<Json<SuccessResponse>, (StatusCode, Json<ErrorResponse>)> {
+    let rid = DeviceId(renderer_id.clone());
+    state.control_point.renderer_profiles().set(&rid, &profile);
+    let sid = DeviceId(req.server_id.clone());
+    let object_id = req.object_id.clone();
+    let object_id_for_log = object_id.clone();
+
+    // Get renderer to verify it exists
+    state
+        .control_point
+        .music_renderer_by_id(&rid)
+        .ok_or_else(|| {
+            (
+                StatusCode::NOT_FOUND,
+                Json(ErrorResponse {
+                    error: format!("Renderer {} not found", renderer_id),
+                }),
+            )
+        })?;
+
+    let control_point = Arc::clone(&state.control_point);
+    let rid_for_log = rid.clone();
+    let object_id_for_log = object_id.clone();
+    let object_id_for_debug = object_id_for_log.clone();
+
+    // Launch the command in background and return immediately
+    // The UI will be updated via SSE events when playback starts
+    tokio::task::spawn(async move {
+        let result =
+            tokio::task::spawn_blocking(move || control_point.play_content(&rid, &sid, &object_id))
+                .await;
 
         match result {
             Ok(Ok(())) => {
@@ -1801,9 +2331,11 @@ async fn play_content(
 async fn add_to_queue(
     State(state): State<ControlPointState>,
     Path(renderer_id): Path<String>,
+    Profile(profile): Profile,
     Json(req): Json<PlayContentRequest>,
 ) -> Result<Json<SuccessResponse>, (StatusCode, Json<ErrorResponse>)> {
     let rid = DeviceId(renderer_id.clone());
+    state.control_point.renderer_profiles().set(&rid, &profile);
     let sid = DeviceId(req.server_id.clone());
     let object_id = req.object_id.clone();
     let object_id_for_log = object_id.clone();
@@ -1898,9 +2430,11 @@ async fn add_to_queue(
 async fn add_after_current(
     State(state): State<ControlPointState>,
     Path(renderer_id): Path<String>,
+    Profile(profile): Profile,
     Json(req): Json<PlayContentRequest>,
 ) -> Result<Json<SuccessResponse>, (StatusCode, Json<ErrorResponse>)> {
     let rid = DeviceId(renderer_id.clone());
+    state.control_point.renderer_profiles().set(&rid, &profile);
     let sid = DeviceId(req.server_id.clone());
     let object_id = req.object_id.clone();
     let object_id_for_log = object_id.clone();
@@ -2294,69 +2828,7 @@ fn fetch_playback_items(
     server_id: &DeviceId,
     object_id: &str,
 ) -> anyhow::Result<Vec<PlaybackItem>> {
-    // Get server from registry
-    let server = control_point
-        .media_server(server_id)
-        .ok_or_else(|| anyhow::anyhow!("Server {} not found", server_id.0))?;
-
-    if !server.is_online() {
-        return Err(anyhow::anyhow!("Server {} is offline", server_id.0));
-    }
-
-    if !server.has_content_directory() {
-        return Err(anyhow::anyhow!(
-            "Server {} does not support ContentDirectory",
-            server_id.0
-        ));
-    }
-
-    // First, get metadata for the object to determine if it's a container or item
-    let object_metadata = server.browse_object(object_id)?;
-
-    let entries = if object_metadata.is_container {
-        // For containers, browse all children with pagination
-        let mut all_entries = Vec::new();
-        let mut offset = 0u32;
-        loop {
-            let page = server.browse_children(object_id, offset, BROWSE_DEFAULT_LIMIT)?;
-            let fetched = page.len() as u32;
-            all_entries.extend(page);
-            if fetched < BROWSE_DEFAULT_LIMIT {
-                break;
-            }
-            offset += fetched;
-        }
-        all_entries
-    } else {
-        // For items, use the object itself
-        vec![object_metadata]
-    };
-
-    debug!(
-        server_id = server_id.0.as_str(),
-        object_id = object_id,
-        total_entries = entries.len(),
-        containers = entries.iter().filter(|e| e.is_container).count(),
-        items_count = entries.iter().filter(|e| !e.is_container).count(),
-        "Browse returned entries"
-    );
-
-    // Convert to PlaybackItem
-    let items: Vec<PlaybackItem> = entries
-        .iter()
-        .filter_map(|entry| playback_item_from_entry(server.clone(), entry))
-        .collect();
-
-    if items.is_empty() && !entries.is_empty() {
-        warn!(
-            server_id = server_id.0.as_str(),
-            object_id = object_id,
-            total_entries = entries.len(),
-            "No playable items found - all entries were filtered out"
-        );
-    }
-
-    Ok(items)
+    control_point.playback_items(server_id, object_id)
 }
 
 #[cfg(feature = "pmoserver")]
@@ -2534,6 +3006,221 @@ async fn search_server(
     }))
 }
 
+// ============================================================================
+// HANDLERS - GROUPES DE RENDERERS
+// ============================================================================
+
+#[cfg(feature = "pmoserver")]
+fn group_results(outcomes: Vec<GroupMemberOutcome>) -> Vec<GroupMemberResult> {
+    outcomes
+        .into_iter()
+        .map(|outcome| GroupMemberResult {
+            renderer_id: outcome.renderer_id,
+            friendly_name: outcome.friendly_name,
+            mode: outcome.mode.as_str().to_string(),
+            error: outcome.error,
+        })
+        .collect()
+}
+
+#[cfg(feature = "pmoserver")]
+fn group_not_found(
+    state: &ControlPointState,
+    name: &str,
+) -> Result<(), (StatusCode, Json<ErrorResponse>)> {
+    state
+        .control_point
+        .renderer_group(name)
+        .map(|_| ())
+        .map_err(|e| {
+            (
+                StatusCode::NOT_FOUND,
+                Json(ErrorResponse {
+                    error: e.to_string(),
+                }),
+            )
+        })
+}
+
+/// GET /control/groups - Liste les groupes de renderers
+#[cfg(feature = "pmoserver")]
+#[utoipa::path(
+    get,
+    path = "/groups",
+    responses(
+        (status = 200, description = "Groupes de renderers", body = Vec<RendererGroupSummary>),
+        (status = 500, description = "Configuration des groupes invalide", body = ErrorResponse)
+    ),
+    tag = "control"
+)]
+async fn list_groups(
+    State(state): State<ControlPointState>,
+) -> Result<Json<Vec<RendererGroupSummary>>, (StatusCode, Json<ErrorResponse>)> {
+    let groups = pmoconfig::get_config().get_renderer_groups().map_err(|e| {
+        (
+            StatusCode::INTERNAL_SERVER_ERROR,
+            Json(ErrorResponse {
+                error: e.to_string(),
+            }),
+        )
+    })?;
+    let renderers = state.control_point.list_music_renderers();
+
+    let summaries = groups
+        .into_iter()
+        .map(|group| RendererGroupSummary {
+            members: group
+                .members
+                .iter()
+                .map(|selector| {
+                    let renderer = renderers
+                        .iter()
+                        .find(|r| member_matches(selector, &r.id(), r.udn(), r.friendly_name()));
+                    GroupMemberSummary {
+                        selector: selector.clone(),
+                        renderer_id: renderer.map(|r| r.id().0),
+                        friendly_name: renderer.map(|r| r.friendly_name().to_string()),
+                        online: renderer.is_some_and(|r| r.is_online()),
+                        multiroom: renderer.is_some_and(|r| r.is_linkplay()),
+                    }
+                })
+                .collect(),
+            name: group.name,
+        })
+        .collect();
+
+    Ok(Json(summaries))
+}
+
+/// POST /control/groups/{group}/play - Lit la même source sur tous les membres
+#[cfg(feature = "pmoserver")]
+#[utoipa::path(
+    post,
+    path = "/groups/{group}/play",
+    params(
+        ("group" = String, Path, description = "Nom du groupe")
+    ),
+    request_body(content = GroupPlayRequest, description = "Source (optionnelle)"),
+    responses(
+        (status = 200, description = "Résultat par membre", body = Vec<GroupMemberResult>),
+        (status = 404, description = "Groupe non trouvé", body = ErrorResponse),
+        (status = 500, description = "Erreur lors de l'exécution", body = ErrorResponse)
+    ),
+    tag = "control"
+)]
+async fn play_group(
+    State(state): State<ControlPointState>,
+    Path(group): Path<String>,
+    body: Option<Json<GroupPlayRequest>>,
+) -> Result<Json<Vec<GroupMemberResult>>, (StatusCode, Json<ErrorResponse>)> {
+    group_not_found(&state, &group)?;
+    let req = body.map(|Json(req)| req).unwrap_or_default();
+
+    // Les membres hors ligne peuvent devoir être réveillés
+    let timeout = TRANSPORT_COMMAND_TIMEOUT + wake_timeout();
+    let control_point = Arc::clone(&state.control_point);
+    let group_for_task = group.clone();
+    let play_task = tokio::task::spawn_blocking(move || {
+        control_point.play_group(&group_for_task, req.uri.as_deref(), req.metadata.as_deref())
+    });
+
+    let outcomes = time::timeout(timeout, play_task)
+        .await
+        .map_err(|_| {
+            warn!("Play command for group {} exceeded {:?}", group, timeout);
+            (
+                StatusCode::GATEWAY_TIMEOUT,
+                Json(ErrorResponse {
+                    error: format!("Group play timed out after {}s", timeout.as_secs()),
+                }),
+            )
+        })?
+        .map_err(|e| {
+            warn!("Task join error during group play: {}", e);
+            (
+                StatusCode::INTERNAL_SERVER_ERROR,
+                Json(ErrorResponse {
+                    error: format!("Internal task error: {}", e),
+                }),
+            )
+        })?
+        .map_err(|e| {
+            warn!("Failed to play on group {}: {}", group, e);
+            (
+                StatusCode::INTERNAL_SERVER_ERROR,
+                Json(ErrorResponse {
+                    error: format!("Failed to play on group: {}", e),
+                }),
+            )
+        })?;
+
+    Ok(Json(group_results(outcomes)))
+}
+
+/// POST /control/groups/{group}/stop - Arrête tous les membres du groupe
+#[cfg(feature = "pmoserver")]
+#[utoipa::path(
+    post,
+    path = "/groups/{group}/stop",
+    params(
+        ("group" = String, Path, description = "Nom du groupe")
+    ),
+    responses(
+        (status = 200, description = "Résultat par membre", body = Vec<GroupMemberResult>),
+        (status = 404, description = "Groupe non trouvé", body = ErrorResponse),
+        (status = 500, description = "Erreur lors de l'exécution", body = ErrorResponse)
+    ),
+    tag = "control"
+)]
+async fn stop_group(
+    State(state): State<ControlPointState>,
+    Path(group): Path<String>,
+) -> Result<Json<Vec<GroupMemberResult>>, (StatusCode, Json<ErrorResponse>)> {
+    group_not_found(&state, &group)?;
+
+    let control_point = Arc::clone(&state.control_point);
+    let group_for_task = group.clone();
+    let stop_task = tokio::task::spawn_blocking(move || control_point.stop_group(&group_for_task));
+
+    let outcomes = time::timeout(QUEUE_COMMAND_TIMEOUT, stop_task)
+        .await
+        .map_err(|_| {
+            warn!(
+                "Stop command for group {} exceeded {:?}",
+                group, QUEUE_COMMAND_TIMEOUT
+            );
+            (
+                StatusCode::GATEWAY_TIMEOUT,
+                Json(ErrorResponse {
+                    error: format!(
+                        "Group stop timed out after {}s",
+                        QUEUE_COMMAND_TIMEOUT.as_secs()
+                    ),
+                }),
+            )
+        })?
+        .map_err(|e| {
+            warn!("Task join error during group stop: {}", e);
+            (
+                StatusCode::INTERNAL_SERVER_ERROR,
+                Json(ErrorResponse {
+                    error: format!("Internal task error: {}", e),
+                }),
+            )
+        })?
+        .map_err(|e| {
+            warn!("Failed to stop group {}: {}", group, e);
+            (
+                StatusCode::INTERNAL_SERVER_ERROR,
+                Json(ErrorResponse {
+                    error: format!("Failed to stop group: {}", e),
+                }),
+            )
+        })?;
+
+    Ok(Json(group_results(outcomes)))
+}
+
 // ============================================================================
 // ROUTER & TRAIT
 // ============================================================================
@@ -2559,6 +3246,7 @@ pub fn create_api_router(state: ControlPointState, control_point: Arc<ControlPoi
         .route("/renderers/{renderer_id}/pause", post(pause_renderer))
         .route("/renderers/{renderer_id}/stop", post(stop_renderer))
         .route("/renderers/{renderer_id}/resume", post(resume_renderer))
+        .route("/renderers/{renderer_id}/wake", post(wake_renderer))
         .route("/renderers/{renderer_id}/next", post(next_renderer))
         .route("/renderers/{renderer_id}/seek", post(seek_renderer))
         // Queue control
@@ -2570,6 +3258,24 @@ pub fn create_api_router(state: ControlPointState, control_point: Arc<ControlPoi
             "/renderers/{renderer_id}/queue/shuffle",
             post(shuffle_queue),
         )
+        .route(
+            "/renderers/{renderer_id}/queue/shuffle_mode",
+            post(set_shuffle_mode),
+        )
+        .route(
+            "/renderers/{renderer_id}/queue/repeat_mode",
+            post(set_repeat_mode),
+        )
+        // Resume positions
+        .route("/resume", get(list_resume_positions))
+        .route("/resume/set", post(set_resume_position))
+        .route("/resume/clear", post(clear_resume_positions))
+        // Listening history
+        .route("/history", get(list_history))
+        .route("/history/clear", post(clear_history))
+        // Réveils
+        .route("/alarms", get(list_alarms).post(save_alarm))
+        .route("/alarms/{alarm_id}", delete(delete_alarm))
         // Volume control
         .route(
             "/renderers/{renderer_id}/volume/set",
@@ -2627,6 +3333,10 @@ pub fn create_api_router(state: ControlPointState, control_point: Arc<ControlPoi
             post(transfer_queue),
         )
         // Servers
+        // Groupes
+        .route("/groups", get(list_groups))
+        .route("/groups/{group}/play", post(play_group))
+        .route("/groups/{group}/stop", post(stop_group))
         .route("/servers", get(list_servers))
         .route(
             "/servers/{server_id}/containers/{container_id}",
@@ -2658,6 +3368,7 @@ pub trait ControlPointExt {
     ///   - `/renderers` - Liste et état des renderers
     ///   - `/servers` - Liste et navigation des serveurs de médias
     ///   - Contrôles de transport, volume, queue, binding
+    /// - Exécution groupée: `/api/v1/batch` (voir [`crate::batch`])
     /// - SSE Events: `/api/control/events/*`
     ///   - `/events` - Tous les événements (renderers + serveurs)
     ///   - `/events/renderers` - Événements renderers uniquement
@@ -2737,12 +3448,44 @@ impl ControlPointExt for pmoserver::Server {
         info!("   - SSDP discovery active");
         info!("   - Renderer polling active (1s interval)");
         info!("   - MediaServer event subscriptions active");
+        match control_point.start_notifications() {
+            Ok(true) => info!("   - Now-playing notifications active"),
+            Ok(false) => {}
+            Err(e) => warn!("Failed to start now-playing notifications: {}", e),
+        }
+        match control_point.start_resume_tracking() {
+            Ok(true) => info!("   - Resume tracking active"),
+            Ok(false) => {}
+            Err(e) => warn!("Failed to start resume tracking: {}", e),
+        }
+        match control_point.start_history() {
+            Ok(true) => info!("   - Listening history active"),
+            Ok(false) => {}
+            Err(e) => warn!("Failed to start listening history: {}", e),
+        }
+        match control_point.start_alarms() {
+            Ok(true) => info!("   - Alarm scheduler active"),
+            Ok(false) => {}
+            Err(e) => warn!("Failed to start alarm scheduler: {}", e),
+        }
+        match control_point.start_hooks() {
+            Ok(true) => info!("   - Event hooks active"),
+            Ok(false) => {}
+            Err(e) => warn!("Failed to start event hooks: {}", e),
+        }
+        #[cfg(feature = "grpc")]
+        match crate::grpc::spawn_server(control_point.clone()) {
+            Ok(true) => info!("   - gRPC control service active"),
+            Ok(false) => {}
+            Err(e) => warn!("Failed to start gRPC control service: {}", e),
+        }
 
         // 2. Enregistrer les routes HTTP REST et SSE
         self.init_control_point(control_point.clone()).await;
 
         info!("✅ Control Point API registered:");
         info!("   - REST API: /api/control/*");
+        info!("   - Batch API: /api/v1/batch");
         info!("   - SSE Events: /api/control/events/*");
         info!("   - OpenAPI docs: /swagger-ui/control");
 
@@ -2753,10 +3496,18 @@ impl ControlPointExt for pmoserver::Server {
         let state = ControlPointState::new(control_point.clone());
 
         // Créer le router API (inclut REST et SSE)
-        let api_router = create_api_router(state, control_point);
+        let api_router = create_api_router(state, control_point.clone());
 
         // L'enregistrer avec OpenAPI
         self.add_openapi(api_router, crate::openapi::ApiDoc::openapi(), "control")
             .await;
+
+        // Exécution groupée de commandes : /api/v1/batch
+        self.add_openapi(
+            crate::batch::create_batch_router(control_point),
+            crate::batch::BatchApiDoc::openapi(),
+            "v1",
+        )
+        .await;
     }
 }
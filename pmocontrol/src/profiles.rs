@@ -0,0 +1,77 @@
+//! Profil d'écoute des renderers
+//!
+//! Quand le client web lance une lecture (file, favori…), le profil de la
+//! requête est associé au renderer : l'historique et les positions de
+//! reprise de ce qu'il joue depuis la file du control point sont attribués
+//! à ce profil. Une lecture pilotée par un autre point de contrôle UPnP
+//! (source [`PlaybackSource::External`]) relève du profil par défaut (voir
+//! [`pmoconfig::profiles`]).
+
+use std::collections::HashMap;
+use std::sync::RwLock;
+
+use pmoconfig::DEFAULT_PROFILE;
+use tracing::debug;
+
+use crate::model::PlaybackSource;
+use crate::music_renderer::MusicRenderer;
+use crate::{DeviceId, DeviceIdentity};
+
+/// Profil par défaut configuré
+pub fn default_profile() -> String {
+    pmoconfig::get_config()
+        .get_default_profile()
+        .unwrap_or_else(|_| DEFAULT_PROFILE.to_string())
+}
+
+/// Profil associé à chaque renderer par la dernière lecture lancée
+#[derive(Debug, Default)]
+pub struct RendererProfiles {
+    profiles: RwLock<HashMap<DeviceId, String>>,
+}
+
+impl RendererProfiles {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Associe un profil au renderer
+    pub fn set(&self, id: &DeviceId, profile: &str) {
+        let previous = self
+            .profiles
+            .write()
+            .expect("renderer profiles lock poisoned")
+            .insert(id.clone(), profile.to_string());
+        if previous.as_deref() != Some(profile) {
+            debug!("Renderer {} now plays for profile {}", id.0, profile);
+        }
+    }
+
+    /// Rend le renderer au profil par défaut
+    pub fn reset(&self, id: &DeviceId) {
+        self.profiles
+            .write()
+            .expect("renderer profiles lock poisoned")
+            .remove(id);
+    }
+
+    /// Profil associé au renderer, ou le profil par défaut
+    pub fn get(&self, id: &DeviceId) -> String {
+        self.profiles
+            .read()
+            .expect("renderer profiles lock poisoned")
+            .get(id)
+            .cloned()
+            .unwrap_or_else(default_profile)
+    }
+
+    /// Profil de la lecture en cours sur un renderer
+    ///
+    /// Une lecture d'origine externe revient au profil par défaut.
+    pub fn current(&self, renderer: &MusicRenderer) -> String {
+        match renderer.playback_source() {
+            PlaybackSource::External => default_profile(),
+            _ => self.get(&renderer.id()),
+        }
+    }
+}
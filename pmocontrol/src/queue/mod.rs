@@ -2,12 +2,16 @@ mod backend;
 mod interne;
 mod music_queue;
 mod openhome;
+mod repeat;
+mod shuffle;
 mod snapshot;
 
 use std::sync::{Arc, Mutex};
 
 pub use backend::{EnqueueMode, QueueBackend};
 pub use music_queue::{MusicQueue, SyncScheduleOutcome};
+pub use repeat::RepeatMode;
+pub use shuffle::{QueueShuffle, ShuffleMode};
 pub use snapshot::{PlaybackItem, QueueSnapshot};
 
 // Internal queue implementations - not part of the public API
@@ -1,4 +1,4 @@
-use std::collections::HashMap;
+use std::collections::{HashMap, HashSet};
 use std::sync::{atomic::AtomicBool, Arc, Mutex};
 use std::time::SystemTime;
 use std::usize;
@@ -18,10 +18,18 @@ use crate::queue::{
 use crate::{DeviceId, DeviceIdentity, RendererInfo};
 
 /// Cache for OpenHome track IDs to avoid redundant SOAP calls
+///
+/// The renderer hands out a token with each `IdArray` response. Once the TTL
+/// has expired, the cached array is revalidated with `IdArrayChanged(token)`,
+/// which is much cheaper than transferring the whole array for large queues.
 #[derive(Debug)]
 struct TrackIdsCache {
     /// Cached track IDs
     ids: Option<Vec<u32>>,
+    /// Token returned with the cached IDs (0 if the renderer did not send one)
+    token: u32,
+    /// Whether the renderer answers IdArrayChanged
+    token_supported: bool,
     /// Timestamp of last cache update
     last_update: Option<SystemTime>,
 }
@@ -30,6 +38,8 @@ impl TrackIdsCache {
     fn new() -> Self {
         Self {
             ids: None,
+            token: 0,
+            token_supported: true,
             last_update: None,
         }
     }
@@ -53,9 +63,23 @@ impl TrackIdsCache {
         }
     }
 
+    /// Expired IDs that can be revalidated with IdArrayChanged
+    fn revalidatable(&self) -> Option<(u32, Vec<u32>)> {
+        match &self.ids {
+            Some(ids) if self.token_supported && self.token != 0 => Some((self.token, ids.clone())),
+            _ => None,
+        }
+    }
+
     /// Update cache with new IDs
-    fn set(&mut self, ids: Vec<u32>) {
+    fn set(&mut self, token: u32, ids: Vec<u32>) {
         self.ids = Some(ids);
+        self.token = token;
+        self.last_update = Some(SystemTime::now());
+    }
+
+    /// Mark the cached IDs as still current (IdArrayChanged returned false)
+    fn touch(&mut self) {
         self.last_update = Some(SystemTime::now());
     }
 
@@ -66,47 +90,48 @@ impl TrackIdsCache {
     }
 }
 
-/// Cache for ReadList results to avoid redundant SOAP calls within a short window.
-/// Key: sorted list of requested IDs. TTL: 500ms.
+/// Cache of ReadList entries keyed by OpenHome track ID.
+///
+/// OpenHome track IDs are never reassigned while the renderer runs, so an
+/// entry stays valid as long as its ID is in the playlist: only IDs that
+/// appeared since the last snapshot need to be read again.
 #[derive(Debug)]
 struct ReadListCache {
-    ids: Option<Vec<u32>>,
-    entries: Option<Vec<OhTrackEntry>>,
-    last_update: Option<SystemTime>,
+    entries: HashMap<u32, OhTrackEntry>,
 }
 
 impl ReadListCache {
     fn new() -> Self {
         Self {
-            ids: None,
-            entries: None,
-            last_update: None,
+            entries: HashMap::new(),
         }
     }
 
-    fn get(&self, id_list: &[u32]) -> Option<Vec<OhTrackEntry>> {
-        if let (Some(cached_ids), Some(entries), Some(last_update)) =
-            (&self.ids, &self.entries, self.last_update)
-        {
-            if let Ok(elapsed) = SystemTime::now().duration_since(last_update) {
-                if elapsed.as_millis() < 500 && cached_ids.as_slice() == id_list {
-                    return Some(entries.clone());
-                }
-            }
+    /// Drop entries whose ID left the playlist and return the IDs to read
+    fn prune_and_missing(&mut self, ids: &[u32]) -> Vec<u32> {
+        let wanted: HashSet<u32> = ids.iter().copied().collect();
+        self.entries.retain(|id, _| wanted.contains(id));
+        ids.iter()
+            .copied()
+            .filter(|id| !self.entries.contains_key(id))
+            .collect()
+    }
+
+    fn insert(&mut self, batch: Vec<OhTrackEntry>) {
+        for entry in batch {
+            self.entries.insert(entry.id, entry);
         }
-        None
     }
 
-    fn set(&mut self, ids: Vec<u32>, entries: Vec<OhTrackEntry>) {
-        self.ids = Some(ids);
-        self.entries = Some(entries);
-        self.last_update = Some(SystemTime::now());
+    /// Entries for `ids` in playlist order (IDs not read are skipped)
+    fn collect(&self, ids: &[u32]) -> Vec<OhTrackEntry> {
+        ids.iter()
+            .filter_map(|id| self.entries.get(id).cloned())
+            .collect()
     }
 
     fn invalidate(&mut self) {
-        self.ids = None;
-        self.entries = None;
-        self.last_update = None;
+        self.entries.clear();
     }
 }
 
@@ -231,10 +256,10 @@ impl OpenHomeQueue {
         }
     }
 
-    /// Invalide les caches track_ids et read_list (après insert/delete sans impact sur la piste courante).
+    /// Invalide le cache track_ids (après insert/delete sans impact sur la piste courante).
     fn invalidate_track_caches(&self) {
+        // Les entrées ReadList restent valides : les ids OpenHome ne sont pas réattribués.
         self.track_ids_cache.lock().expect("track_ids_cache mutex poisoned").invalidate();
-        self.read_list_cache.lock().expect("read_list_cache mutex poisoned").invalidate();
     }
 
     /// Invalide tous les caches (après delete_all, seek, stop — opérations qui changent la piste courante).
@@ -935,7 +960,23 @@ impl OpenHomeQueue {
     }
 }
 
+const DIDL_LITE_OPEN: &str = r#"<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/">"#;
+
 fn build_metadata_xml(item: &PlaybackItem) -> String {
+    build_didl_xml(std::slice::from_ref(item))
+}
+
+/// Builds a DIDL-Lite document containing one `<item>` per playback item.
+pub(super) fn build_didl_xml(items: &[PlaybackItem]) -> String {
+    let mut xml = String::from(DIDL_LITE_OPEN);
+    for item in items {
+        push_item_xml(&mut xml, item);
+    }
+    xml.push_str("</DIDL-Lite>");
+    xml
+}
+
+fn push_item_xml(xml: &mut String, item: &PlaybackItem) {
     let title = item
         .metadata
         .as_ref()
@@ -945,9 +986,6 @@ fn build_metadata_xml(item: &PlaybackItem) -> String {
     let escaped_uri = escape(item.uri.as_str());
     let escaped_id = escape(item.didl_id.as_str());
 
-    let mut xml = String::from(
-        r#"<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/">"#,
-    );
     xml.push_str(&format!(
         r#"<item id="{}" parentID="-1" restricted="1">"#,
         escaped_id
@@ -996,8 +1034,7 @@ fn build_metadata_xml(item: &PlaybackItem) -> String {
         }
     }
     xml.push_str(&format!(r#">{}</res>"#, escaped_uri));
-    xml.push_str(r#"<upnp:class>object.item.audioItem.musicTrack</upnp:class></item></DIDL-Lite>"#);
-    xml
+    xml.push_str(r#"<upnp:class>object.item.audioItem.musicTrack</upnp:class></item>"#);
 }
 
 /// Compare two PlaybackItems for equality.
@@ -1106,18 +1143,47 @@ impl QueueBackend for OpenHomeQueue {
             return Ok(cached_ids);
         }
 
-        // Cache miss or expired - fetch from service (keep lock held to prevent concurrent calls)
-        let ids = self.playlist_client.id_array()?;
+        // Expired - ask the renderer whether the array changed since our token
+        if let Some((token, cached_ids)) = cache.revalidatable() {
+            match self.playlist_client.id_array_changed(token) {
+                Ok(false) => {
+                    cache.touch();
+                    return Ok(cached_ids);
+                }
+                Ok(true) => {}
+                Err(err) => {
+                    debug!(
+                        renderer = self.renderer_id.0.as_str(),
+                        error = %err,
+                        "IdArrayChanged not supported, always fetching IdArray"
+                    );
+                    cache.token_supported = false;
+                }
+            }
+        }
+
+        // Cache miss or changed - fetch from service (keep lock held to prevent concurrent calls)
+        let previous_token = cache.token;
+        let (token, ids) = self.playlist_client.id_array_with_token()?;
 
         tracing::trace!(
             renderer = self.renderer_id.0.as_str(),
+            token,
             ids_count = ids.len(),
             ids = ?ids,
-            "track_ids: cache miss, fetched from Pizzicato"
+            "track_ids: cache miss, fetched from renderer"
         );
 
+        // A token going backwards means the renderer restarted: ids may be reused
+        if token < previous_token {
+            self.read_list_cache
+                .lock()
+                .expect("read_list_cache mutex poisoned")
+                .invalidate();
+        }
+
         // Update cache before releasing lock
-        cache.set(ids.clone());
+        cache.set(token, ids.clone());
 
         Ok(ids)
     }
@@ -1187,43 +1253,46 @@ impl QueueBackend for OpenHomeQueue {
             });
         }
 
-        // Read metadata for all tracks (batched), with 500ms cache to avoid
-        // redundant SOAP calls during sync_queue (which calls queue_snapshot twice).
+        // Read metadata only for tracks not seen yet (batched): entries of
+        // known ids are reused, which keeps large queues cheap to refresh.
         const MAX_BATCH: usize = 256;
-        let mut entries = Vec::with_capacity(ids.len());
-        for chunk in ids.chunks(MAX_BATCH) {
-            if let Some(cached) = self.read_list_cache.lock().expect("read_list_cache mutex poisoned").get(chunk) {
-                trace!(
-                    renderer = self.renderer_id.0.as_str(),
-                    "ReadList cache hit for {} IDs",
-                    chunk.len()
-                );
-                entries.extend(cached);
-                continue;
-            }
-            match self.playlist_client.read_list(chunk) {
-                Ok(batch) => {
-                    self.read_list_cache
-                        .lock()
-                        .unwrap()
-                        .set(chunk.to_vec(), batch.clone());
-                    entries.extend(batch);
-                }
+        let missing = self
+            .read_list_cache
+            .lock()
+            .expect("read_list_cache mutex poisoned")
+            .prune_and_missing(&ids);
+        trace!(
+            renderer = self.renderer_id.0.as_str(),
+            total = ids.len(),
+            missing = missing.len(),
+            "ReadList for new playlist entries"
+        );
+        for chunk in missing.chunks(MAX_BATCH) {
+            let batch = match self.playlist_client.read_list(chunk) {
+                Ok(batch) => batch,
                 Err(err) => {
                     // If batch fails, try one by one
                     if chunk.len() > 1 {
+                        let mut batch = Vec::with_capacity(chunk.len());
                         for id in chunk {
-                            match self.playlist_client.read_list(&[*id]) {
-                                Ok(mut single) => entries.append(&mut single),
-                                Err(inner_err) => return Err(inner_err),
-                            }
+                            batch.append(&mut self.playlist_client.read_list(&[*id])?);
                         }
+                        batch
                     } else {
                         return Err(err);
                     }
                 }
-            }
+            };
+            self.read_list_cache
+                .lock()
+                .expect("read_list_cache mutex poisoned")
+                .insert(batch);
         }
+        let entries = self
+            .read_list_cache
+            .lock()
+            .expect("read_list_cache mutex poisoned")
+            .collect(&ids);
 
         let mut items = Vec::with_capacity(entries.len());
 
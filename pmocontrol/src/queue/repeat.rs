@@ -0,0 +1,67 @@
+//! Repeat modes for renderer queues.
+//!
+//! The repeat mode only changes what happens when the current track ends
+//! (auto-advance and gapless prefetch): a track explicitly skipped with
+//! "next" still moves on, and the queue itself is never modified.
+
+use std::str::FromStr;
+
+/// What to play once the current track has ended.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum RepeatMode {
+    /// Stop at the end of the queue.
+    #[default]
+    Off,
+    /// Replay the current track.
+    One,
+    /// Start the queue over after its last track.
+    All,
+}
+
+impl RepeatMode {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            RepeatMode::Off => "off",
+            RepeatMode::One => "one",
+            RepeatMode::All => "all",
+        }
+    }
+
+    /// Index of the track following `current` in a queue of `len` items.
+    pub fn following_index(&self, current: Option<usize>, len: usize) -> Option<usize> {
+        let current = current.filter(|&idx| idx < len)?;
+        match self {
+            RepeatMode::One => Some(current),
+            _ if current + 1 < len => Some(current + 1),
+            RepeatMode::All => Some(0),
+            RepeatMode::Off => None,
+        }
+    }
+}
+
+impl FromStr for RepeatMode {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s.to_ascii_lowercase().as_str() {
+            "off" | "none" => Ok(RepeatMode::Off),
+            "one" | "track" => Ok(RepeatMode::One),
+            "all" | "queue" => Ok(RepeatMode::All),
+            other => Err(format!("Unknown repeat mode: {}", other)),
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn following_index_honours_repeat_mode() {
+        assert_eq!(RepeatMode::Off.following_index(Some(1), 3), Some(2));
+        assert_eq!(RepeatMode::Off.following_index(Some(2), 3), None);
+        assert_eq!(RepeatMode::One.following_index(Some(2), 3), Some(2));
+        assert_eq!(RepeatMode::All.following_index(Some(2), 3), Some(0));
+        assert_eq!(RepeatMode::All.following_index(None, 3), None);
+    }
+}
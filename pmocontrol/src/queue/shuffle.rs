@@ -0,0 +1,254 @@
+//! Shuffle modes for renderer queues.
+//!
+//! Shuffling reorders the queue itself rather than picking random tracks at
+//! playback time: every backend (internal queue, OpenHome playlist) then
+//! simply plays the queue in order, and control points see the actual
+//! upcoming tracks.
+//!
+//! A reshuffle is *stable*:
+//!   - tracks already played in this cycle and the current track keep their
+//!     position, so playback is never interrupted;
+//!   - only the upcoming part of the queue is reordered, so no track is
+//!     repeated before all the others have been played.
+//!
+//! With an explicit seed, the sequence of reshuffles is fully reproducible.
+//! Turning shuffle off restores the order the queue had when shuffle was
+//! enabled, keeping the current track current.
+
+use std::str::FromStr;
+
+use rand::rngs::StdRng;
+use rand::seq::SliceRandom;
+use rand::SeedableRng;
+
+use crate::PlaybackItem;
+
+/// Granularity of the shuffle.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum ShuffleMode {
+    /// Queue order.
+    #[default]
+    Off,
+    /// Tracks in random order.
+    Tracks,
+    /// Albums in random order, tracks of an album in their queue order.
+    Albums,
+}
+
+impl ShuffleMode {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            ShuffleMode::Off => "off",
+            ShuffleMode::Tracks => "tracks",
+            ShuffleMode::Albums => "albums",
+        }
+    }
+}
+
+impl FromStr for ShuffleMode {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s.to_ascii_lowercase().as_str() {
+            "off" | "none" => Ok(ShuffleMode::Off),
+            "tracks" | "track" => Ok(ShuffleMode::Tracks),
+            "albums" | "album" => Ok(ShuffleMode::Albums),
+            other => Err(format!("Unknown shuffle mode: {}", other)),
+        }
+    }
+}
+
+/// Shuffle state of a renderer queue.
+#[derive(Debug, Clone)]
+pub struct QueueShuffle {
+    mode: ShuffleMode,
+    seed: u64,
+    /// Number of reshuffles done so far (mixed into the seed).
+    round: u64,
+    /// Identity of the items in the order they had before shuffling.
+    original: Vec<(String, String)>,
+}
+
+fn item_key(item: &PlaybackItem) -> (String, String) {
+    (item.uri.clone(), item.didl_id.clone())
+}
+
+impl QueueShuffle {
+    /// Starts shuffling a queue whose current order is `items`.
+    ///
+    /// Without a seed, a random one is drawn.
+    pub fn new(mode: ShuffleMode, seed: Option<u64>, items: &[PlaybackItem]) -> Self {
+        Self {
+            mode,
+            seed: seed.unwrap_or_else(rand::random),
+            round: 0,
+            original: items.iter().map(item_key).collect(),
+        }
+    }
+
+    pub fn mode(&self) -> ShuffleMode {
+        self.mode
+    }
+
+    pub fn seed(&self) -> u64 {
+        self.seed
+    }
+
+    /// Changes mode and/or seed, keeping the order to restore.
+    pub fn reconfigure(&mut self, mode: ShuffleMode, seed: Option<u64>) {
+        self.mode = mode;
+        if let Some(seed) = seed {
+            self.seed = seed;
+            self.round = 0;
+        }
+    }
+
+    /// Reorders the tracks after `current` (the whole queue if there is no
+    /// current track).
+    ///
+    /// Returns the new items and the new current index.
+    pub fn reshuffle(
+        &mut self,
+        mut items: Vec<PlaybackItem>,
+        current: Option<usize>,
+    ) -> (Vec<PlaybackItem>, Option<usize>) {
+        let mut rng = StdRng::seed_from_u64(self.seed.wrapping_add(self.round));
+        self.round += 1;
+
+        let start = current.map_or(0, |idx| (idx + 1).min(items.len()));
+        let upcoming = items.split_off(start);
+        let upcoming = match self.mode {
+            ShuffleMode::Off => upcoming,
+            ShuffleMode::Tracks => {
+                let mut upcoming = upcoming;
+                upcoming.shuffle(&mut rng);
+                upcoming
+            }
+            ShuffleMode::Albums => shuffle_albums(upcoming, &mut rng),
+        };
+        items.extend(upcoming);
+
+        let current = current.or(if items.is_empty() { None } else { Some(0) });
+        (items, current)
+    }
+
+    /// Puts the items back in the order they had before shuffling.
+    ///
+    /// Items added since then keep their relative order, after the others.
+    pub fn restore(
+        &self,
+        items: Vec<PlaybackItem>,
+        current: Option<usize>,
+    ) -> (Vec<PlaybackItem>, Option<usize>) {
+        let rank = |item: &PlaybackItem| {
+            let key = item_key(item);
+            self.original
+                .iter()
+                .position(|k| *k == key)
+                .unwrap_or(usize::MAX)
+        };
+
+        let mut indexed: Vec<(usize, PlaybackItem)> = items.into_iter().enumerate().collect();
+        indexed.sort_by_key(|(_, item)| rank(item));
+
+        let current = current.and_then(|idx| indexed.iter().position(|(old, _)| *old == idx));
+        (indexed.into_iter().map(|(_, item)| item).collect(), current)
+    }
+}
+
+/// Shuffles albums as blocks.
+///
+/// Tracks are grouped by album (and artist, to keep "Greatest Hits" apart);
+/// tracks without an album are blocks of their own.
+fn shuffle_albums(items: Vec<PlaybackItem>, rng: &mut StdRng) -> Vec<PlaybackItem> {
+    let mut blocks: Vec<(Option<(String, Option<String>)>, Vec<PlaybackItem>)> = Vec::new();
+    for item in items {
+        let album = item.metadata.as_ref().and_then(|m| {
+            m.album
+                .clone()
+                .map(|album| (album, m.artist.clone().or_else(|| m.creator.clone())))
+        });
+        match blocks
+            .iter_mut()
+            .find(|(key, _)| album.is_some() && *key == album)
+        {
+            Some((_, tracks)) => tracks.push(item),
+            None => blocks.push((album, vec![item])),
+        }
+    }
+
+    blocks.shuffle(rng);
+    blocks.into_iter().flat_map(|(_, tracks)| tracks).collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::model::TrackMetadata;
+    use crate::DeviceId;
+
+    fn item(uri: &str, album: Option<&str>) -> PlaybackItem {
+        PlaybackItem {
+            media_server_id: DeviceId("server".to_string()),
+            backend_id: usize::MAX,
+            didl_id: uri.to_string(),
+            uri: uri.to_string(),
+            protocol_info: "http-get:*:audio/flac:*".to_string(),
+            metadata: album.map(|album| TrackMetadata {
+                title: Some(uri.to_string()),
+                artist: Some("Artist".to_string()),
+                album: Some(album.to_string()),
+                genre: None,
+                album_art_uri: None,
+                date: None,
+                track_number: None,
+                creator: None,
+                duration: None,
+                is_continuous_stream: false,
+            }),
+        }
+    }
+
+    fn uris(items: &[PlaybackItem]) -> Vec<&str> {
+        items.iter().map(|i| i.uri.as_str()).collect()
+    }
+
+    #[test]
+    fn reshuffle_keeps_history_and_is_reproducible() {
+        let items: Vec<PlaybackItem> = (0..20).map(|i| item(&format!("t{}", i), None)).collect();
+
+        let mut shuffle = QueueShuffle::new(ShuffleMode::Tracks, Some(42), &items);
+        let (shuffled, current) = shuffle.reshuffle(items.clone(), Some(2));
+        assert_eq!(current, Some(2));
+        assert_eq!(uris(&shuffled[..3]), ["t0", "t1", "t2"]);
+        assert_ne!(uris(&shuffled), uris(&items));
+
+        let mut again = QueueShuffle::new(ShuffleMode::Tracks, Some(42), &items);
+        assert_eq!(
+            uris(&again.reshuffle(items.clone(), Some(2)).0),
+            uris(&shuffled)
+        );
+
+        let (restored, current) = shuffle.restore(shuffled.clone(), Some(5));
+        assert_eq!(uris(&restored), uris(&items));
+        assert_eq!(restored[current.unwrap()].uri, shuffled[5].uri);
+    }
+
+    #[test]
+    fn album_shuffle_keeps_albums_together() {
+        let items = vec![
+            item("a1", Some("A")),
+            item("a2", Some("A")),
+            item("b1", Some("B")),
+            item("b2", Some("B")),
+            item("single", None),
+        ];
+
+        let mut shuffle = QueueShuffle::new(ShuffleMode::Albums, Some(7), &items);
+        let (shuffled, _) = shuffle.reshuffle(items, None);
+        let order = uris(&shuffled);
+        let pos = |uri| order.iter().position(|u| *u == uri).unwrap();
+        assert_eq!(pos("a2"), pos("a1") + 1);
+        assert_eq!(pos("b2"), pos("b1") + 1);
+    }
+}
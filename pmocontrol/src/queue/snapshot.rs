@@ -61,6 +61,11 @@ impl PlaybackItem {
         // un champ string interne ou une méthode as_str().
         format!("{}::{}", self.media_server_id.0, self.didl_id)
     }
+
+    /// Serializes this item as a single-item DIDL-Lite document.
+    pub fn to_didl_xml(&self) -> String {
+        super::openhome::build_didl_xml(std::slice::from_ref(self))
+    }
 }
 
 /// Logical snapshot of a renderer queue.
@@ -91,4 +96,9 @@ impl QueueSnapshot {
     pub fn is_empty(&self) -> bool {
         self.items.is_empty()
     }
+
+    /// Serializes all items, in play order, as one DIDL-Lite document.
+    pub fn to_didl_xml(&self) -> String {
+        super::openhome::build_didl_xml(&self.items)
+    }
 }
@@ -177,6 +177,11 @@ impl DeviceRegistry {
                 renderer.has_been_seen_now(max_age);
 
                 if !was_online {
+                    crate::wol::learn_in_background(
+                        info.udn(),
+                        info.friendly_name(),
+                        info.location(),
+                    );
                     self.renderer_bus.broadcast(RendererEvent::Online {
                         id: device_id.clone(),
                         info: info.basic_info(),
@@ -192,6 +197,7 @@ impl DeviceRegistry {
                 entry.music_renderer = Some(Arc::new(new_renderer));
                 self.udn_index
                     .insert(info.udn().to_ascii_lowercase(), device_id.clone());
+                crate::wol::learn_in_background(info.udn(), info.friendly_name(), info.location());
 
                 self.renderer_bus.broadcast(RendererEvent::Online {
                     id: device_id.clone(),
@@ -212,6 +218,7 @@ impl DeviceRegistry {
                 self.devices.insert(device_id.clone(), new_entry);
                 self.udn_index
                     .insert(info.udn().to_ascii_lowercase(), device_id.clone());
+                crate::wol::learn_in_background(info.udn(), info.friendly_name(), info.location());
 
                 self.renderer_bus.broadcast(RendererEvent::Online {
                     id: device_id,
@@ -0,0 +1,503 @@
+//! Reprise de lecture des contenus longs (livres audio, podcasts)
+//!
+//! Le suiveur écoute les positions de lecture des renderers et mémorise,
+//! pour chaque piste de classe reprenable, la dernière position atteinte.
+//! Quand la même piste est relancée, la lecture reprend automatiquement à
+//! cette position ; une piste écoutée jusqu'au bout est oubliée.
+//!
+//! ```yaml
+//! host:
+//!   control_point:
+//!     resume:
+//!       enabled: true
+//!       classes: [audiobook, podcast]
+//!       min_duration: 20m     # morceau plus long = livre audio (0 = jamais)
+//!       directory: resume     # positions.json
+//! ```
+//!
+//! La classe d'une piste est déduite de son genre (« Audiobook »,
+//! « Livre audio », « Podcast »…) ou, à défaut, de sa durée. Les positions
+//! sont indexées par profil d'écoute et URI (voir [`crate::profiles`]) et
+//! enregistrées dans `positions.json`, au plus toutes les [`FLUSH_INTERVAL`].
+//!
+//! Ce magasin est la seule source des positions : les positions d'épisodes
+//! de l'API podcasts (`pmopodcast::positions`) en sont une vue, indexée par
+//! l'URI que le ContentDirectory donne à chaque épisode.
+
+use std::collections::HashMap;
+use std::fs;
+use std::io;
+use std::path::PathBuf;
+use std::str::FromStr;
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::sync::{Arc, Mutex, RwLock};
+use std::thread;
+use std::time::{Duration, Instant};
+
+use anyhow::Result;
+use chrono::{DateTime, Utc};
+use crossbeam_channel::{Receiver, RecvTimeoutError};
+use serde::{Deserialize, Serialize};
+use tracing::{debug, info, warn};
+
+use crate::config_ext::ControlPointConfigExt;
+use crate::model::{RendererEvent, TrackMetadata};
+use crate::music_renderer::time_utils::parse_time_flexible;
+use crate::profiles::RendererProfiles;
+use crate::{DeviceId, DeviceIdentity, DeviceRegistry};
+
+/// Délai maximal entre deux enregistrements de `positions.json`
+pub const FLUSH_INTERVAL: Duration = Duration::from_secs(30);
+
+/// Nom du fichier des positions dans le répertoire de reprise
+const POSITIONS_FILE: &str = "positions.json";
+
+/// Écart minimal (secondes) entre position sauvée et position courante
+/// pour déclencher une reprise
+const RESUME_MARGIN_SECS: u32 = 5;
+
+/// Une piste dont il reste moins de secondes que cela est considérée finie
+const FINISHED_MARGIN_SECS: u32 = 30;
+
+const AUDIOBOOK_GENRES: &[&str] = &["audiobook", "audio book", "livre audio", "spoken word"];
+const PODCAST_GENRES: &[&str] = &["podcast"];
+
+/// Classe de contenu dont la position est mémorisée
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum ResumeClass {
+    Audiobook,
+    Podcast,
+}
+
+impl ResumeClass {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            ResumeClass::Audiobook => "audiobook",
+            ResumeClass::Podcast => "podcast",
+        }
+    }
+
+    /// Classe d'une piste d'après son genre, puis sa durée
+    ///
+    /// Sans genre reconnu, une piste d'au moins `min_duration` est traitée
+    /// comme un livre audio.
+    pub fn classify(metadata: &TrackMetadata, min_duration: Option<Duration>) -> Option<Self> {
+        if metadata.is_continuous_stream {
+            return None;
+        }
+
+        let genre = metadata.genre.as_deref().unwrap_or_default().to_lowercase();
+        if PODCAST_GENRES.iter().any(|g| genre.contains(g)) {
+            return Some(ResumeClass::Podcast);
+        }
+        if AUDIOBOOK_GENRES.iter().any(|g| genre.contains(g)) {
+            return Some(ResumeClass::Audiobook);
+        }
+
+        let duration = metadata
+            .duration
+            .as_deref()
+            .and_then(|d| parse_time_flexible(d.split('.').next().unwrap_or(d)).ok())?;
+        let min_duration = min_duration?;
+        (u64::from(duration) >= min_duration.as_secs()).then_some(ResumeClass::Audiobook)
+    }
+}
+
+impl FromStr for ResumeClass {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        match s.to_ascii_lowercase().as_str() {
+            "audiobook" => Ok(ResumeClass::Audiobook),
+            "podcast" => Ok(ResumeClass::Podcast),
+            other => Err(format!("Unknown resume class: {}", other)),
+        }
+    }
+}
+
+/// Réglages de la reprise de lecture
+#[derive(Debug, Clone, PartialEq)]
+pub struct ResumeSettings {
+    pub enabled: bool,
+    /// Classes dont la position est mémorisée
+    pub classes: Vec<ResumeClass>,
+    /// Durée à partir de laquelle une piste sans genre reconnu est reprise
+    pub min_duration: Option<Duration>,
+}
+
+/// Position mémorisée d'une piste
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct ResumePosition {
+    /// Profil d'écoute (les fichiers antérieurs aux profils relèvent de `default`)
+    #[serde(default = "default_profile_name")]
+    pub profile: String,
+    pub uri: String,
+    pub class: ResumeClass,
+    pub position_secs: u32,
+    #[serde(default)]
+    pub duration_secs: Option<u32>,
+    #[serde(default)]
+    pub title: Option<String>,
+    pub updated_at: DateTime<Utc>,
+}
+
+fn default_profile_name() -> String {
+    pmoconfig::DEFAULT_PROFILE.to_string()
+}
+
+/// Clé d'une position : (profil, URI)
+type PositionKey = (String, String);
+
+fn position_key(position: &ResumePosition) -> PositionKey {
+    (position.profile.clone(), position.uri.clone())
+}
+
+/// Positions de reprise, persistées dans `positions.json`
+pub struct ResumeStore {
+    path: Option<PathBuf>,
+    positions: Mutex<HashMap<PositionKey, ResumePosition>>,
+    dirty: AtomicBool,
+}
+
+impl ResumeStore {
+    /// Charge les positions du répertoire configuré
+    ///
+    /// En cas d'erreur, les positions sont gardées en mémoire seulement.
+    pub fn from_config() -> Self {
+        match pmoconfig::get_config().get_resume_directory() {
+            Ok(dir) => Self::open(PathBuf::from(dir).join(POSITIONS_FILE)),
+            Err(e) => {
+                warn!("Resume positions will not be persisted: {}", e);
+                Self::in_memory()
+            }
+        }
+    }
+
+    /// Charge les positions depuis `path` (vide si le fichier n'existe pas)
+    pub fn open(path: PathBuf) -> Self {
+        let positions = match fs::read(&path) {
+            Ok(bytes) => match serde_json::from_slice::<Vec<ResumePosition>>(&bytes) {
+                Ok(list) => list.into_iter().map(|p| (position_key(&p), p)).collect(),
+                Err(e) => {
+                    warn!("Ignoring invalid resume file {}: {}", path.display(), e);
+                    HashMap::new()
+                }
+            },
+            Err(e) if e.kind() == io::ErrorKind::NotFound => HashMap::new(),
+            Err(e) => {
+                warn!("Cannot read resume file {}: {}", path.display(), e);
+                HashMap::new()
+            }
+        };
+        Self {
+            path: Some(path),
+            positions: Mutex::new(positions),
+            dirty: AtomicBool::new(false),
+        }
+    }
+
+    /// Positions non persistées
+    pub fn in_memory() -> Self {
+        Self {
+            path: None,
+            positions: Mutex::new(HashMap::new()),
+            dirty: AtomicBool::new(false),
+        }
+    }
+
+    fn lock(&self) -> std::sync::MutexGuard<'_, HashMap<PositionKey, ResumePosition>> {
+        self.positions
+            .lock()
+            .expect("resume positions mutex poisoned")
+    }
+
+    pub fn get(&self, profile: &str, uri: &str) -> Option<ResumePosition> {
+        self.lock()
+            .get(&(profile.to_string(), uri.to_string()))
+            .cloned()
+    }
+
+    /// Positions d'un profil (toutes si `None`), les plus récentes d'abord
+    pub fn list(&self, profile: Option<&str>) -> Vec<ResumePosition> {
+        let mut list: Vec<_> = self
+            .lock()
+            .values()
+            .filter(|p| profile.is_none_or(|profile| p.profile == profile))
+            .cloned()
+            .collect();
+        list.sort_by(|a, b| b.updated_at.cmp(&a.updated_at));
+        list
+    }
+
+    /// Mémorise (ou remplace) une position
+    pub fn set(&self, position: ResumePosition) {
+        self.lock().insert(position_key(&position), position);
+        self.dirty.store(true, Ordering::Relaxed);
+    }
+
+    /// Fixe la position d'une piste à la main
+    ///
+    /// Durée, titre et classe déjà connus sont conservés ; une piste
+    /// inconnue est classée livre audio si `class` n'est pas précisé.
+    pub fn set_position(
+        &self,
+        profile: &str,
+        uri: &str,
+        position_secs: u32,
+        class: Option<ResumeClass>,
+    ) {
+        let previous = self.get(profile, uri);
+        self.set(ResumePosition {
+            profile: profile.to_string(),
+            uri: uri.to_string(),
+            class: class
+                .or(previous.as_ref().map(|p| p.class))
+                .unwrap_or(ResumeClass::Audiobook),
+            position_secs,
+            duration_secs: previous.as_ref().and_then(|p| p.duration_secs),
+            title: previous.and_then(|p| p.title),
+            updated_at: Utc::now(),
+        });
+    }
+
+    /// Oublie la position d'une piste, `false` si elle n'existait pas
+    pub fn clear(&self, profile: &str, uri: &str) -> bool {
+        let removed = self
+            .lock()
+            .remove(&(profile.to_string(), uri.to_string()))
+            .is_some();
+        if removed {
+            self.dirty.store(true, Ordering::Relaxed);
+        }
+        removed
+    }
+
+    /// Oublie toutes les positions d'un profil et retourne leur nombre
+    pub fn clear_all(&self, profile: &str) -> usize {
+        let mut positions = self.lock();
+        let before = positions.len();
+        positions.retain(|(p, _), _| p != profile);
+        let count = before - positions.len();
+        drop(positions);
+        if count > 0 {
+            self.dirty.store(true, Ordering::Relaxed);
+        }
+        count
+    }
+
+    /// Enregistre les positions si elles ont changé
+    pub fn flush(&self) -> io::Result<()> {
+        let Some(path) = &self.path else {
+            return Ok(());
+        };
+        if !self.dirty.swap(false, Ordering::Relaxed) {
+            return Ok(());
+        }
+
+        let json = serde_json::to_vec_pretty(&self.list(None)).map_err(io::Error::other)?;
+        let tmp = path.with_extension("json.tmp");
+        let result = fs::write(&tmp, json).and_then(|_| fs::rename(&tmp, path));
+        if result.is_err() {
+            self.dirty.store(true, Ordering::Relaxed);
+        }
+        result
+    }
+}
+
+/// Suiveur des positions de lecture
+struct Tracker {
+    settings: ResumeSettings,
+    registry: Arc<RwLock<DeviceRegistry>>,
+    store: Arc<ResumeStore>,
+    profiles: Arc<RendererProfiles>,
+    /// Dernière URI vue par renderer, pour détecter les changements de piste
+    current: HashMap<DeviceId, String>,
+}
+
+impl Tracker {
+    fn handle(&mut self, event: RendererEvent) {
+        let RendererEvent::PositionChanged { id, position } = event else {
+            return;
+        };
+        let Some(uri) = position.track_uri.filter(|uri| !uri.is_empty()) else {
+            return;
+        };
+        let Some(renderer) = self.registry.read().ok().and_then(|r| r.get_renderer(&id)) else {
+            return;
+        };
+        let profile = self.profiles.current(&renderer);
+        let is_new_track = self.current.get(&id) != Some(&uri);
+        self.current.insert(id, uri.clone());
+
+        let Some(metadata) = renderer.last_metadata() else {
+            return;
+        };
+        let Some(class) = ResumeClass::classify(&metadata, self.settings.min_duration)
+            .filter(|class| self.settings.classes.contains(class))
+        else {
+            return;
+        };
+        let Some(position_secs) = position
+            .rel_time
+            .as_deref()
+            .and_then(|t| parse_time_flexible(t).ok())
+        else {
+            return;
+        };
+        let duration_secs = position
+            .track_duration
+            .as_deref()
+            .and_then(|t| parse_time_flexible(t).ok())
+            .filter(|&d| d > 0);
+
+        if is_new_track {
+            if let Some(saved) = self.store.get(&profile, &uri) {
+                if saved.position_secs > position_secs + RESUME_MARGIN_SECS {
+                    info!(
+                        "⏯️ Resuming {} on {} at {}s",
+                        metadata.title.as_deref().unwrap_or(&uri),
+                        renderer.friendly_name(),
+                        saved.position_secs
+                    );
+                    if let Err(e) = renderer.seek(saved.position_secs) {
+                        warn!("Failed to resume {}: {}", uri, e);
+                    }
+                    return;
+                }
+            }
+        }
+
+        if duration_secs.is_some_and(|d| position_secs + FINISHED_MARGIN_SECS >= d) {
+            if self.store.clear(&profile, &uri) {
+                debug!("Resume position of {} cleared (finished)", uri);
+            }
+            return;
+        }
+
+        self.store.set(ResumePosition {
+            profile,
+            uri,
+            class,
+            position_secs,
+            duration_secs,
+            title: metadata.title,
+            updated_at: Utc::now(),
+        });
+    }
+}
+
+/// Démarre le suiveur si la reprise est activée
+///
+/// Retourne `false` si elle est désactivée.
+pub fn spawn_tracker(
+    events: Receiver<RendererEvent>,
+    registry: Arc<RwLock<DeviceRegistry>>,
+    store: Arc<ResumeStore>,
+    profiles: Arc<RendererProfiles>,
+) -> Result<bool> {
+    let settings = pmoconfig::get_config().get_resume_settings()?;
+    if !settings.enabled || settings.classes.is_empty() {
+        return Ok(false);
+    }
+    info!("⏯️ Resume tracking enabled for {:?}", settings.classes);
+
+    let mut tracker = Tracker {
+        settings,
+        registry,
+        store,
+        profiles,
+        current: HashMap::new(),
+    };
+
+    thread::Builder::new()
+        .name("cp-resume".into())
+        .spawn(move || {
+            let mut last_flush = Instant::now();
+            loop {
+                match events.recv_timeout(FLUSH_INTERVAL) {
+                    Ok(event) => tracker.handle(event),
+                    Err(RecvTimeoutError::Timeout) => {}
+                    Err(RecvTimeoutError::Disconnected) => break,
+                }
+                if last_flush.elapsed() >= FLUSH_INTERVAL {
+                    if let Err(e) = tracker.store.flush() {
+                        warn!("Failed to save resume positions: {}", e);
+                    }
+                    last_flush = Instant::now();
+                }
+            }
+            if let Err(e) = tracker.store.flush() {
+                warn!("Failed to save resume positions: {}", e);
+            }
+        })?;
+    Ok(true)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn metadata(genre: Option<&str>, duration: Option<&str>) -> TrackMetadata {
+        TrackMetadata {
+            title: Some("Chapitre 1".to_string()),
+            artist: None,
+            album: None,
+            genre: genre.map(str::to_string),
+            album_art_uri: None,
+            date: None,
+            track_number: None,
+            creator: None,
+            duration: duration.map(str::to_string),
+            is_continuous_stream: false,
+        }
+    }
+
+    #[test]
+    fn test_classify() {
+        let twenty_minutes = Some(Duration::from_secs(20 * 60));
+        assert_eq!(
+            ResumeClass::classify(&metadata(Some("Podcast"), None), twenty_minutes),
+            Some(ResumeClass::Podcast)
+        );
+        assert_eq!(
+            ResumeClass::classify(&metadata(Some("Livre audio"), Some("0:03:00")), None),
+            Some(ResumeClass::Audiobook)
+        );
+        assert_eq!(
+            ResumeClass::classify(&metadata(Some("Rock"), Some("0:45:00.000")), twenty_minutes),
+            Some(ResumeClass::Audiobook)
+        );
+        assert_eq!(
+            ResumeClass::classify(&metadata(Some("Rock"), Some("0:04:00")), twenty_minutes),
+            None
+        );
+    }
+
+    #[test]
+    fn test_store_roundtrip() {
+        let path = std::env::temp_dir().join(format!("pmo-resume-{}.json", std::process::id()));
+        let store = ResumeStore::open(path.clone());
+        store.set(ResumePosition {
+            profile: "alice".to_string(),
+            uri: "http://server/book.mp3".to_string(),
+            class: ResumeClass::Audiobook,
+            position_secs: 1234,
+            duration_secs: Some(3600),
+            title: None,
+            updated_at: Utc::now(),
+        });
+        store.flush().unwrap();
+
+        let reloaded = ResumeStore::open(path.clone());
+        assert_eq!(
+            reloaded
+                .get("alice", "http://server/book.mp3")
+                .map(|p| p.position_secs),
+            Some(1234)
+        );
+        assert!(reloaded.get("default", "http://server/book.mp3").is_none());
+        assert!(reloaded.clear("alice", "http://server/book.mp3"));
+        let _ = fs::remove_file(path);
+    }
+}
@@ -4,7 +4,7 @@ use std::time::Duration;
 
 use anyhow::{Context, Result};
 use pmoupnp::soap::{build_soap_request, parse_soap_envelope, SoapEnvelope};
-use tracing::{debug, trace, warn, info};
+use tracing::{debug, warn, info};
 use ureq::Agent;
 
 use crate::errors::ControlPointError;
@@ -108,7 +108,7 @@ pub fn invoke_upnp_action_with_timeout(
         "Sending SOAP request"
     );
 
-    trace!(body = body_xml.as_str(), "SOAP request body");
+    pmoupnp::trace_payload!(body_xml, "SOAP request body for {}", action);
 
     let request = if let Some(duration) = timeout {
         let config = Agent::config_builder()
@@ -144,6 +144,11 @@ pub enum RendererEventPayload {
         album_art_uri: Option<String>,
         timestamp: chrono::DateTime<chrono::Utc>,
     },
+    PlaybackError {
+        renderer_id: String,
+        message: String,
+        timestamp: chrono::DateTime<chrono::Utc>,
+    },
     QueueUpdated {
         renderer_id: String,
         queue_length: usize,
@@ -172,6 +177,12 @@ pub enum RendererEventPayload {
         is_stream: bool,
         timestamp: chrono::DateTime<chrono::Utc>,
     },
+    PlayModeChanged {
+        renderer_id: String,
+        shuffle: String,
+        repeat: String,
+        timestamp: chrono::DateTime<chrono::Utc>,
+    },
     TimerStarted {
         renderer_id: String,
         duration_seconds: u32,
@@ -197,6 +208,16 @@ pub enum RendererEventPayload {
         renderer_id: String,
         timestamp: chrono::DateTime<chrono::Utc>,
     },
+    AlarmTriggered {
+        renderer_id: String,
+        alarm_id: String,
+        timestamp: chrono::DateTime<chrono::Utc>,
+    },
+    AlarmStopped {
+        renderer_id: String,
+        alarm_id: String,
+        timestamp: chrono::DateTime<chrono::Utc>,
+    },
     Online {
         renderer_id: String,
         friendly_name: String,
@@ -292,6 +313,11 @@ async fn renderer_event_to_payload(
             album_art_uri: transform_cover_url(metadata.album_art_uri.as_deref(), base_url).await,
             timestamp,
         },
+        RendererEvent::PlaybackError { id, message } => RendererEventPayload::PlaybackError {
+            renderer_id: id.0,
+            message,
+            timestamp,
+        },
         RendererEvent::QueueUpdated { id, queue_length } => RendererEventPayload::QueueUpdated {
             renderer_id: id.0,
             queue_length,
@@ -322,6 +348,16 @@ async fn renderer_event_to_payload(
                 timestamp,
             }
         }
+        RendererEvent::PlayModeChanged {
+            id,
+            shuffle,
+            repeat,
+        } => RendererEventPayload::PlayModeChanged {
+            renderer_id: id.0,
+            shuffle: shuffle.as_str().to_string(),
+            repeat: repeat.as_str().to_string(),
+            timestamp,
+        },
         RendererEvent::TimerStarted {
             id,
             duration_seconds,
@@ -358,6 +394,16 @@ async fn renderer_event_to_payload(
             renderer_id: id.0,
             timestamp,
         },
+        RendererEvent::AlarmTriggered { id, alarm } => RendererEventPayload::AlarmTriggered {
+            renderer_id: id.0,
+            alarm_id: alarm,
+            timestamp,
+        },
+        RendererEvent::AlarmStopped { id, alarm } => RendererEventPayload::AlarmStopped {
+            renderer_id: id.0,
+            alarm_id: alarm,
+            timestamp,
+        },
         RendererEvent::Online { id, info } => RendererEventPayload::Online {
             renderer_id: id.0,
             friendly_name: info.friendly_name,
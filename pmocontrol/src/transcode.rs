@@ -0,0 +1,97 @@
+//! Routing of playback URIs through the server-side transcoder.
+//!
+//! The media server exposes `GET /transcode?src=<url>&profile=<name>`.
+//! Once its base URL is registered with [`set_transcoder_base_url`], renderers
+//! that do not advertise the source MIME type in their ConnectionManager sink
+//! list receive a transcoded URI instead of the original one.
+
+use std::sync::RwLock;
+
+/// Profile used when a renderer cannot decode the source format.
+pub const DEFAULT_PROFILE: &str = "flac";
+
+static TRANSCODER_BASE_URL: RwLock<Option<String>> = RwLock::new(None);
+
+/// Registers the base URL of the server hosting `/transcode`.
+pub fn set_transcoder_base_url(base_url: impl Into<String>) {
+    let base_url = base_url.into().trim_end_matches('/').to_string();
+    *TRANSCODER_BASE_URL
+        .write()
+        .expect("transcoder base url lock poisoned") = Some(base_url);
+}
+
+/// Base URL of the transcoder, if one has been registered.
+pub fn transcoder_base_url() -> Option<String> {
+    TRANSCODER_BASE_URL
+        .read()
+        .expect("transcoder base url lock poisoned")
+        .clone()
+}
+
+/// Builds the transcoding URL of `src` for `profile`.
+pub fn transcode_url(base_url: &str, src: &str, profile: &str) -> String {
+    format!(
+        "{}/transcode?src={}&profile={}",
+        base_url.trim_end_matches('/'),
+        urlencoding::encode(src),
+        urlencoding::encode(profile)
+    )
+}
+
+/// MIME type served by the transcoder for `profile`.
+pub fn profile_mime_type(profile: &str) -> &'static str {
+    if profile.to_ascii_lowercase().starts_with("wav") {
+        "audio/wav"
+    } else {
+        "audio/flac"
+    }
+}
+
+/// `http-get` protocolInfo advertised for a transcoded resource.
+pub fn profile_protocol_info(profile: &str) -> String {
+    format!("http-get:*:{}:*", profile_mime_type(profile))
+}
+
+/// Extracts the MIME type (third field) of a protocolInfo string.
+pub fn protocol_info_mime(protocol_info: &str) -> Option<&str> {
+    protocol_info.split(':').nth(2).map(str::trim)
+}
+
+fn canonical_mime(mime: &str) -> String {
+    let mime = mime.split(';').next().unwrap_or(mime).trim().to_ascii_lowercase();
+    match mime.as_str() {
+        "audio/x-flac" => "audio/flac".to_string(),
+        "audio/x-wav" | "audio/wave" => "audio/wav".to_string(),
+        "audio/mp3" => "audio/mpeg".to_string(),
+        _ => mime,
+    }
+}
+
+/// Returns true if a renderer sink list accepts `mime`.
+pub fn sink_accepts(sink: &[String], mime: &str) -> bool {
+    let wanted = canonical_mime(mime);
+    sink.iter()
+        .filter_map(|entry| protocol_info_mime(entry))
+        .any(|accepted| accepted == "*" || canonical_mime(accepted) == wanted)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn matches_sink_protocol_info() {
+        let sink = vec![
+            "http-get:*:audio/x-flac:*".to_string(),
+            "http-get:*:audio/L16;rate=44100;channels=2:*".to_string(),
+        ];
+        assert!(sink_accepts(&sink, "audio/flac"));
+        assert!(!sink_accepts(&sink, "audio/mpeg"));
+        assert!(sink_accepts(&["http-get:*:*:*".to_string()], "audio/ogg"));
+        assert_eq!(
+            transcode_url("http://host:8080/", "http://a/b c.ogg", "wav-cd"),
+            "http://host:8080/transcode?src=http%3A%2F%2Fa%2Fb%20c.ogg&profile=wav-cd"
+        );
+        assert_eq!(profile_mime_type("wav-cd"), "audio/wav");
+    }
+}
@@ -9,7 +9,7 @@ pub use crate::upnp_clients::connection_manager_client::{
 };
 pub use crate::upnp_clients::openhome_client::{
     OPENHOME_PLAYLIST_HEAD_ID, OhInfoClient, OhPlaylistClient, OhProductClient, OhRadioClient,
-    OhTimeClient, OhTrack, OhTrackEntry, OhVolumeClient,
+    OhTimeClient, OhTrack, OhTrackEntry, OhVolumeClient, parse_track_metadata_from_didl,
 };
 pub use crate::upnp_clients::rendering_control_client::RenderingControlClient;
 
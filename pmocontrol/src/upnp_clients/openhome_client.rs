@@ -319,6 +319,49 @@ impl OhPlaylistClient {
         handle_action_response("Previous", &call_result)
     }
 
+    pub fn repeat(&self) -> Result<bool, ControlPointError> {
+        let call_result = invoke_upnp_action(&self.control_url, &self.service_type, "Repeat", &[])?;
+        let envelope = ensure_success("Repeat", &call_result)?;
+        let response = find_child_with_suffix(&envelope.body.content, "RepeatResponse")
+            .ok_or_else(|| {
+                ControlPointError::UpnpMissingReturnValue("RepeatResponse".to_string())
+            })?;
+
+        let value = extract_child_text_any(response, &["Value"])?;
+
+        Ok(parse_bool(&value))
+    }
+
+    pub fn set_repeat(&self, repeat: bool) -> Result<(), ControlPointError> {
+        let repeat_str = if repeat { "1" } else { "0" };
+        let args = [("Value", repeat_str)];
+        let call_result =
+            invoke_upnp_action(&self.control_url, &self.service_type, "SetRepeat", &args)?;
+        handle_action_response("SetRepeat", &call_result)
+    }
+
+    pub fn shuffle(&self) -> Result<bool, ControlPointError> {
+        let call_result =
+            invoke_upnp_action(&self.control_url, &self.service_type, "Shuffle", &[])?;
+        let envelope = ensure_success("Shuffle", &call_result)?;
+        let response = find_child_with_suffix(&envelope.body.content, "ShuffleResponse")
+            .ok_or_else(|| {
+                ControlPointError::UpnpMissingReturnValue("ShuffleResponse".to_string())
+            })?;
+
+        let value = extract_child_text_any(response, &["Value"])?;
+
+        Ok(parse_bool(&value))
+    }
+
+    pub fn set_shuffle(&self, shuffle: bool) -> Result<(), ControlPointError> {
+        let shuffle_str = if shuffle { "1" } else { "0" };
+        let args = [("Value", shuffle_str)];
+        let call_result =
+            invoke_upnp_action(&self.control_url, &self.service_type, "SetShuffle", &args)?;
+        handle_action_response("SetShuffle", &call_result)
+    }
+
     pub fn seek_second_absolute(&self, second: u32) -> Result<(), ControlPointError> {
         let second_str = second.to_string();
         let args = [("Value", second_str.as_str())];
@@ -410,6 +453,14 @@ impl OhPlaylistClient {
     }
 
     pub fn id_array(&self) -> Result<Vec<u32>, ControlPointError> {
+        self.id_array_with_token().map(|(_, ids)| ids)
+    }
+
+    /// Return the playlist id array together with its change token.
+    ///
+    /// The token can later be passed to [`id_array_changed`](Self::id_array_changed)
+    /// to check cheaply whether the array must be fetched again.
+    pub fn id_array_with_token(&self) -> Result<(u32, Vec<u32>), ControlPointError> {
         let call_result =
             invoke_upnp_action(&self.control_url, &self.service_type, "IdArray", &[])?;
         let envelope = ensure_success("IdArray", &call_result)?;
@@ -433,34 +484,60 @@ impl OhPlaylistClient {
             );
         }
 
+        // Some renderers omit the token: 0 forces a full refresh next time.
+        let token = extract_child_text_optional(response, "Token")
+            .ok()
+            .flatten()
+            .and_then(|text| text.trim().parse::<u32>().ok())
+            .unwrap_or(0);
+
         // Try to extract the array element. If missing, assume empty playlist.
         let array_text = match extract_child_text_any(response, &["Array", "IdArray", "Value"]) {
             Ok(text) => text,
             Err(_) => {
                 // Element not found - playlist is likely empty
-                return Ok(Vec::new());
+                return Ok((token, Vec::new()));
             }
         };
 
-        // Handle empty string (another way renderers indicate empty playlist)
-        if array_text.trim().is_empty() {
-            return Ok(Vec::new());
-        }
+        Ok((token, decode_id_array(&array_text)?))
+    }
 
-        let bytes = decode_base64(&array_text)?;
-        if bytes.len() % 4 != 0 {
-            return Err(ControlPointError::SoapAction(format!(
-                "Invalid IdArray payload length {} (expected multiple of 4)",
-                bytes.len()
-            )));
-        }
+    /// Ask the renderer whether the id array changed since `token` was issued.
+    pub fn id_array_changed(&self, token: u32) -> Result<bool, ControlPointError> {
+        let token_str = token.to_string();
+        let args = [("Token", token_str.as_str())];
+        let call_result =
+            invoke_upnp_action(&self.control_url, &self.service_type, "IdArrayChanged", &args)?;
+        let envelope = ensure_success("IdArrayChanged", &call_result)?;
+        let response = find_child_with_suffix(&envelope.body.content, "IdArrayChangedResponse")
+            .ok_or_else(|| {
+                ControlPointError::upnp_missing_return_value("IdArrayChangedResponse")
+            })?;
+        let value = extract_child_text_any(response, &["Value", "IdArrayChanged"])?;
+        Ok(parse_bool(&value) || value.trim().eq_ignore_ascii_case("true"))
+    }
+}
 
-        let mut ids = Vec::with_capacity(bytes.len() / 4);
-        for chunk in bytes.chunks_exact(4) {
-            ids.push(u32::from_be_bytes([chunk[0], chunk[1], chunk[2], chunk[3]]));
-        }
-        Ok(ids)
+/// Decode an OpenHome `IdArray` value: base64 of big-endian packed ui4 ids.
+pub(crate) fn decode_id_array(array_text: &str) -> Result<Vec<u32>, ControlPointError> {
+    // Handle empty string (another way renderers indicate empty playlist)
+    if array_text.trim().is_empty() {
+        return Ok(Vec::new());
+    }
+
+    let bytes = decode_base64(array_text)?;
+    if bytes.len() % 4 != 0 {
+        return Err(ControlPointError::SoapAction(format!(
+            "Invalid IdArray payload length {} (expected multiple of 4)",
+            bytes.len()
+        )));
     }
+
+    Ok(bytes
+        .chunks_exact(4)
+        .map(|chunk| u32::from_be_bytes([chunk[0], chunk[1], chunk[2], chunk[3]]))
+        .collect())
 }
 
 impl OhInfoClient {
@@ -1037,6 +1114,9 @@ fn parse_track_list(payload: &str) -> Result<Vec<OhTrackEntry>, ControlPointErro
         "Decoded OpenHome TrackList payload"
     );
 
+    pmodidl::xml_guard::check(xml.as_bytes()).map_err(|err| {
+        ControlPointError::OpenHomeError(format!("Rejected OpenHome TrackList XML: {}", err))
+    })?;
     let mut reader = std::io::Cursor::new(xml.as_bytes());
     let root = Element::parse(&mut reader).map_err(|err| {
         ControlPointError::OpenHomeError(format!("Failed to parse OpenHome TrackList XML: {}", err))
@@ -1091,6 +1171,8 @@ fn parse_product_source_list(xml: &str) -> Result<Vec<OhProductSource>> {
         return Ok(Vec::new());
     }
 
+    pmodidl::xml_guard::check(xml.as_bytes())
+        .map_err(|err| anyhow!("Rejected OpenHome SourceXml payload: {}", err))?;
     let mut reader = std::io::Cursor::new(xml.as_bytes());
     let root = Element::parse(&mut reader)
         .map_err(|err| anyhow!("Failed to parse OpenHome SourceXml payload: {}", err))?;
@@ -1156,4 +1238,11 @@ mod tests {
             .expect("array content");
         assert_eq!(value, "AAAAAQAAAAI=");
     }
+
+    #[test]
+    fn decode_id_array_unpacks_big_endian_ui4() {
+        assert_eq!(decode_id_array("AAAAAQAAAAI=").unwrap(), vec![1, 2]);
+        assert!(decode_id_array("").unwrap().is_empty());
+        assert!(decode_id_array("AAAB").is_err());
+    }
 }
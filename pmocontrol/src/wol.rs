@@ -0,0 +1,249 @@
+//! Réveil des renderers en veille par Wake-on-LAN
+//!
+//! Beaucoup de renderers (amplis réseau, streamers) coupent leur pile UPnP
+//! en veille mais gardent la carte réseau à l'écoute des paquets magiques.
+//! L'adresse MAC d'un renderer est apprise dans la table ARP du système
+//! quand il apparaît sur le réseau, ou fixée dans la configuration
+//! (voir [`crate::config_ext::ControlPointConfigExt`]).
+
+use std::fmt;
+use std::net::{IpAddr, SocketAddr, UdpSocket};
+use std::str::FromStr;
+use std::thread;
+use std::time::Duration;
+
+use tracing::{debug, info, warn};
+
+use crate::config_ext::ControlPointConfigExt;
+
+/// Taille d'un paquet magique : 6 × 0xFF puis 16 fois l'adresse MAC
+pub const MAGIC_PACKET_LEN: usize = 102;
+
+/// Nombre d'envois de chaque paquet (UDP n'est pas fiable)
+const MAGIC_PACKET_REPEAT: usize = 3;
+
+/// Écart entre deux envois du même paquet
+const MAGIC_PACKET_SPACING: Duration = Duration::from_millis(100);
+
+/// Adresse MAC (EUI-48)
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash)]
+pub struct MacAddress(pub [u8; 6]);
+
+impl FromStr for MacAddress {
+    type Err = String;
+
+    /// Accepte `aa:bb:cc:dd:ee:ff`, `aa-bb-cc-dd-ee-ff` et les octets sur un
+    /// chiffre affichés par `arp` sous macOS (`a:b:c:d:e:f`)
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        let parts: Vec<&str> = s.trim().split([':', '-']).collect();
+        if parts.len() != 6 {
+            return Err(format!("'{}' is not a MAC address", s));
+        }
+        let mut bytes = [0u8; 6];
+        for (byte, part) in bytes.iter_mut().zip(&parts) {
+            if part.is_empty() || part.len() > 2 {
+                return Err(format!("'{}' is not a MAC address", s));
+            }
+            *byte = u8::from_str_radix(part, 16)
+                .map_err(|_| format!("'{}' is not a MAC address", s))?;
+        }
+        Ok(Self(bytes))
+    }
+}
+
+impl fmt::Display for MacAddress {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        let [a, b, c, d, e, g] = self.0;
+        write!(
+            f,
+            "{:02x}:{:02x}:{:02x}:{:02x}:{:02x}:{:02x}",
+            a, b, c, d, e, g
+        )
+    }
+}
+
+impl MacAddress {
+    /// Adresse nulle ou de diffusion, inutilisable pour un réveil
+    pub fn is_unusable(&self) -> bool {
+        self.0 == [0; 6] || self.0 == [0xff; 6]
+    }
+}
+
+/// Construit le paquet magique d'une adresse MAC
+pub fn magic_packet(mac: &MacAddress) -> [u8; MAGIC_PACKET_LEN] {
+    let mut packet = [0xffu8; MAGIC_PACKET_LEN];
+    for chunk in packet[6..].chunks_exact_mut(6) {
+        chunk.copy_from_slice(&mac.0);
+    }
+    packet
+}
+
+/// Diffuse le paquet magique d'une adresse MAC vers chacune des cibles
+pub fn send_magic_packet(mac: &MacAddress, targets: &[SocketAddr]) -> std::io::Result<()> {
+    let packet = magic_packet(mac);
+    let socket = UdpSocket::bind("0.0.0.0:0")?;
+    socket.set_broadcast(true)?;
+
+    for round in 0..MAGIC_PACKET_REPEAT {
+        if round > 0 {
+            thread::sleep(MAGIC_PACKET_SPACING);
+        }
+        for target in targets {
+            socket.send_to(&packet, target)?;
+        }
+    }
+    debug!("🔔 Magic packet for {} sent to {:?}", mac, targets);
+    Ok(())
+}
+
+/// Envoie un paquet magique vers les adresses de diffusion configurées
+pub fn wake(mac: &MacAddress) -> anyhow::Result<()> {
+    let targets = pmoconfig::get_config().get_wol_broadcast_addresses()?;
+    send_magic_packet(mac, &targets)?;
+    Ok(())
+}
+
+/// Adresse IP de l'hôte d'une URL de description (`http://host:port/...`)
+pub fn host_of_location(location: &str) -> Option<IpAddr> {
+    let authority = location.split("://").nth(1)?.split('/').next()?;
+    let host = match authority.strip_prefix('[') {
+        Some(rest) => rest.split(']').next()?,
+        None => authority.split(':').next()?,
+    };
+    host.parse().ok()
+}
+
+/// Cherche l'adresse MAC d'une IP dans le contenu de `/proc/net/arp`
+fn parse_proc_arp(content: &str, ip: IpAddr) -> Option<MacAddress> {
+    let ip = ip.to_string();
+    content.lines().skip(1).find_map(|line| {
+        let fields: Vec<&str> = line.split_whitespace().collect();
+        // IP address, HW type, Flags, HW address, Mask, Device
+        if fields.len() < 4 || fields[0] != ip || fields[2] == "0x0" {
+            return None;
+        }
+        fields[3].parse().ok()
+    })
+}
+
+/// Cherche l'adresse MAC d'une IP dans la sortie de `arp`
+///
+/// `? (192.168.1.20) at a:b:c:d:e:f on en0` (macOS, BSD) ou
+/// `192.168.1.20   aa-bb-cc-dd-ee-ff   dynamic` (Windows).
+fn parse_arp_output(output: &str, ip: IpAddr) -> Option<MacAddress> {
+    let ip = ip.to_string();
+    output
+        .lines()
+        .filter(|line| {
+            line.split_whitespace()
+                .any(|token| token.trim_matches(|c| c == '(' || c == ')') == ip)
+        })
+        .find_map(|line| line.split_whitespace().find_map(|token| token.parse().ok()))
+}
+
+/// Adresse MAC d'une IP du réseau local, d'après la table ARP du système
+///
+/// Il n'y a d'entrée que pour les hôtes du même segment avec lesquels la
+/// machine a échangé récemment, ce qui est le cas d'un renderer dont on
+/// vient de lire la description.
+pub fn lookup_mac(ip: IpAddr) -> Option<MacAddress> {
+    let mac = if cfg!(target_os = "linux") {
+        std::fs::read_to_string("/proc/net/arp")
+            .ok()
+            .and_then(|content| parse_proc_arp(&content, ip))
+    } else {
+        let flag = if cfg!(windows) { "-a" } else { "-n" };
+        std::process::Command::new("arp")
+            .args([flag, &ip.to_string()])
+            .output()
+            .ok()
+            .and_then(|out| parse_arp_output(&String::from_utf8_lossy(&out.stdout), ip))
+    };
+    mac.filter(|mac| !mac.is_unusable())
+}
+
+/// Apprend l'adresse MAC d'un renderer qui vient d'apparaître
+///
+/// La recherche et l'éventuelle écriture de la configuration se font dans un
+/// thread séparé pour ne pas retenir le registre.
+pub fn learn_in_background(udn: &str, friendly_name: &str, location: &str) {
+    let Some(ip) = host_of_location(location) else {
+        return;
+    };
+    let udn = udn.to_ascii_lowercase();
+    let friendly_name = friendly_name.to_string();
+
+    thread::spawn(move || {
+        let config = pmoconfig::get_config();
+        if !config.get_wol_enabled().unwrap_or(false) {
+            return;
+        }
+        let Some(mac) = lookup_mac(ip) else {
+            debug!(
+                "No ARP entry for {} ({}), MAC not learned",
+                friendly_name, ip
+            );
+            return;
+        };
+        let known = config.get_wol_learned_mac(&udn).ok().flatten();
+        if let Err(e) = config.set_wol_learned_mac(&udn, &friendly_name, &mac) {
+            warn!("Failed to store MAC address of {}: {}", friendly_name, e);
+        } else if known != Some(mac) {
+            info!(
+                "🔔 Learned MAC address {} for {} ({})",
+                mac, friendly_name, ip
+            );
+        }
+    });
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_mac_and_magic_packet() {
+        let mac: MacAddress = "00-11-22-AA-bb-Cc".parse().unwrap();
+        assert_eq!(mac.to_string(), "00:11:22:aa:bb:cc");
+        assert_eq!("0:11:22:aa:bb:cc".parse::<MacAddress>(), Ok(mac));
+        assert!("00:11:22:aa:bb".parse::<MacAddress>().is_err());
+        assert!("00:11:22:aa:bb:cg".parse::<MacAddress>().is_err());
+
+        let packet = magic_packet(&mac);
+        assert_eq!(&packet[..6], &[0xff; 6]);
+        assert_eq!(&packet[6..12], &mac.0);
+        assert_eq!(&packet[96..], &mac.0);
+    }
+
+    #[test]
+    fn test_arp_lookup_parsing() {
+        let ip: IpAddr = "192.168.1.20".parse().unwrap();
+        let proc_arp = "\
+IP address       HW type     Flags       HW address            Mask     Device
+192.168.1.2      0x1         0x2         11:22:33:44:55:66     *        eth0
+192.168.1.20     0x1         0x2         aa:bb:cc:dd:ee:ff     *        eth0";
+        assert_eq!(
+            parse_proc_arp(proc_arp, ip).unwrap().to_string(),
+            "aa:bb:cc:dd:ee:ff"
+        );
+        assert_eq!(
+            parse_proc_arp(proc_arp, "192.168.1.3".parse().unwrap()),
+            None
+        );
+
+        let macos = "? (192.168.1.20) at a:bb:c:dd:e:ff on en0 ifscope [ethernet]";
+        assert_eq!(
+            parse_arp_output(macos, ip).unwrap().to_string(),
+            "0a:bb:0c:dd:0e:ff"
+        );
+        let windows = "  192.168.1.20          aa-bb-cc-dd-ee-ff     dynamic";
+        assert!(parse_arp_output(windows, ip).is_some());
+
+        assert_eq!(
+            host_of_location("http://192.168.1.20:49152/description.xml"),
+            Some(ip)
+        );
+        assert_eq!(host_of_location("chromecast://192.168.1.20:8009"), Some(ip));
+        assert_eq!(host_of_location("http://renderer.local/desc.xml"), None);
+    }
+}
@@ -2,6 +2,8 @@
 //!
 //! Parser et utilitaires pour le format DIDL-Lite utilisé dans UPnP/DLNA.
 
+pub mod xml_guard;
+
 use bevy_reflect::Reflect;
 use serde::{Deserialize, Serialize};
 use std::borrow::Cow;
@@ -91,6 +93,9 @@ impl MediaMetadataParser for DIDLLite {
     type Error = quick_xml::de::DeError;
 
     fn parse(input: &str) -> Result<Self, Self::Error> {
+        // Les métadonnées DIDL arrivent de points de contrôle arbitraires
+        xml_guard::check(input.as_bytes())
+            .map_err(|e| quick_xml::de::DeError::Custom(e.to_string()))?;
         let sanitized = sanitize_singleton_elements(input);
         quick_xml::de::from_str(sanitized.as_ref())
     }
@@ -935,4 +940,15 @@ mod tests {
         assert_eq!(item_count.format, "DIDL-Lite");
         assert_eq!(item_count.data, 0);
     }
+
+    #[test]
+    fn test_parse_rejects_entity_declarations() {
+        let xml = r#"<?xml version="1.0"?>
+<!DOCTYPE DIDL-Lite [<!ENTITY xxe SYSTEM "file:///etc/passwd">]>
+<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/"
+           xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <item id="1" parentID="0"><dc:title>&xxe;</dc:title></item>
+</DIDL-Lite>"#;
+        assert!(DIDLLite::parse(xml).is_err());
+    }
 }
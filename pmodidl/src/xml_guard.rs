@@ -0,0 +1,160 @@
+//! Garde-fous pour le XML reçu du réseau.
+//!
+//! Les endpoints de contrôle et d'événements acceptent du XML venant de
+//! n'importe quel hôte du LAN. Avant de confier un document à un parser
+//! (xmltree, quick-xml), [`check`] vérifie :
+//!
+//! - la taille du document ;
+//! - l'absence de déclaration `<!DOCTYPE` / `<!ENTITY` : UPnP n'en utilise
+//!   jamais, et les refuser ferme la porte aux entités externes (XXE) comme
+//!   à l'expansion exponentielle (« billion laughs ») ;
+//! - la profondeur d'imbrication des éléments.
+//!
+//! Le contrôle est un simple balayage des octets, sans allocation.
+
+/// Limites appliquées à un document XML
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct XmlLimits {
+    /// Taille maximale du document en octets
+    pub max_bytes: usize,
+    /// Profondeur maximale d'imbrication des éléments
+    pub max_depth: usize,
+}
+
+impl XmlLimits {
+    /// Limites par défaut : 4 MiB, 64 niveaux
+    ///
+    /// Largement au-dessus d'une réponse Browse volumineuse ou d'une
+    /// description de device, très en dessous de ce qui pèse sur la mémoire.
+    pub const DEFAULT: XmlLimits = XmlLimits {
+        max_bytes: 4 * 1024 * 1024,
+        max_depth: 64,
+    };
+}
+
+impl Default for XmlLimits {
+    fn default() -> Self {
+        Self::DEFAULT
+    }
+}
+
+/// Raison du rejet d'un document XML
+#[derive(Debug, Clone, PartialEq, Eq, thiserror::Error)]
+pub enum XmlGuardError {
+    #[error("XML document too large: {size} bytes (max {max})")]
+    TooLarge { size: usize, max: usize },
+
+    #[error("XML DOCTYPE/ENTITY declarations are not allowed")]
+    DoctypeForbidden,
+
+    #[error("XML document nested too deeply (max depth {0})")]
+    TooDeep(usize),
+}
+
+/// Vérifie un document avec les limites par défaut
+pub fn check(xml: &[u8]) -> Result<(), XmlGuardError> {
+    check_with_limits(xml, &XmlLimits::DEFAULT)
+}
+
+/// Vérifie un document avec des limites explicites
+pub fn check_with_limits(xml: &[u8], limits: &XmlLimits) -> Result<(), XmlGuardError> {
+    if xml.len() > limits.max_bytes {
+        return Err(XmlGuardError::TooLarge {
+            size: xml.len(),
+            max: limits.max_bytes,
+        });
+    }
+
+    let mut depth = 0usize;
+    let mut pos = 0usize;
+    while let Some(offset) = find_byte(&xml[pos..], b'<') {
+        let start = pos + offset;
+        let rest = &xml[start..];
+
+        if rest.starts_with(b"<!--") {
+            pos = skip_past(xml, start + 4, b"-->");
+        } else if rest.starts_with(b"<![CDATA[") {
+            pos = skip_past(xml, start + 9, b"]]>");
+        } else if rest.starts_with(b"<!") {
+            // <!DOCTYPE, <!ENTITY, <!ELEMENT... : aucune n'a sa place en UPnP
+            return Err(XmlGuardError::DoctypeForbidden);
+        } else if rest.starts_with(b"<?") {
+            pos = skip_past(xml, start + 2, b"?>");
+        } else if rest.starts_with(b"</") {
+            depth = depth.saturating_sub(1);
+            pos = skip_past(xml, start + 2, b">");
+        } else {
+            let end = tag_end(xml, start + 1);
+            if end == 0 || xml[end - 1] != b'/' {
+                depth += 1;
+                if depth > limits.max_depth {
+                    return Err(XmlGuardError::TooDeep(limits.max_depth));
+                }
+            }
+            pos = (end + 1).min(xml.len());
+        }
+    }
+
+    Ok(())
+}
+
+fn find_byte(haystack: &[u8], needle: u8) -> Option<usize> {
+    haystack.iter().position(|&b| b == needle)
+}
+
+/// Position juste après `marker` (ou fin du document)
+fn skip_past(xml: &[u8], from: usize, marker: &[u8]) -> usize {
+    xml.get(from..)
+        .and_then(|rest| rest.windows(marker.len()).position(|w| w == marker))
+        .map_or(xml.len(), |p| from + p + marker.len())
+}
+
+/// Position du `>` fermant une balise ouvrante, guillemets d'attributs compris
+fn tag_end(xml: &[u8], from: usize) -> usize {
+    let mut quote: Option<u8> = None;
+    for (i, &b) in xml.iter().enumerate().skip(from) {
+        match quote {
+            Some(q) if b == q => quote = None,
+            Some(_) => {}
+            None if b == b'"' || b == b'\'' => quote = Some(b),
+            None if b == b'>' => return i,
+            None => {}
+        }
+    }
+    xml.len()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_accepts_regular_documents() {
+        let xml = br#"<?xml version="1.0"?><!-- <!DOCTYPE x> --><a x="1>2"><b/><c><![CDATA[<!ENTITY>]]></c></a>"#;
+        assert_eq!(check(xml), Ok(()));
+    }
+
+    #[test]
+    fn test_rejects_xxe_and_billion_laughs() {
+        let xxe = br#"<?xml version="1.0"?><!DOCTYPE foo [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><foo>&xxe;</foo>"#;
+        assert_eq!(check(xxe), Err(XmlGuardError::DoctypeForbidden));
+
+        let lol = br#"<?xml version="1.0"?><!DOCTYPE lolz [<!ENTITY lol "lol"><!ENTITY lol2 "&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;">]><lolz>&lol2;</lolz>"#;
+        assert_eq!(check(lol), Err(XmlGuardError::DoctypeForbidden));
+    }
+
+    #[test]
+    fn test_limits_depth_and_size() {
+        let deep = "<a>".repeat(100) + &"</a>".repeat(100);
+        assert_eq!(check(deep.as_bytes()), Err(XmlGuardError::TooDeep(64)));
+
+        let limits = XmlLimits {
+            max_bytes: 16,
+            max_depth: 64,
+        };
+        assert!(matches!(
+            check_with_limits(b"<a>0123456789abcdef</a>", &limits),
+            Err(XmlGuardError::TooLarge { .. })
+        ));
+    }
+}
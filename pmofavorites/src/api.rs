@@ -0,0 +1,136 @@
+//! Endpoints API REST pour les favoris
+//!
+//! - `GET    /api/favorites`               favoris (`?kind=track|album|radio`)
+//! - `POST   /api/favorites`               ajouter un favori
+//! - `GET    /api/favorites/{id}`          un favori
+//! - `DELETE /api/favorites/{id}`          retirer un favori
+//! - `POST   /api/favorites/{id}/insert`   insérer dans la file d'un renderer
+//!   (`{"renderer_id": "…", "position": 0}`, feature `control`)
+//!
+//! Les favoris sont ceux du profil d'écoute de la requête (en-tête
+//! `X-PMO-Profile` ou `?profile=`, profil par défaut sinon).
+
+use crate::error::FavoritesError;
+use crate::store::{Favorite, FavoriteKind, FavoritesStore, NewFavorite};
+use axum::{
+    Json, Router,
+    extract::{Path, Query, State},
+    http::StatusCode,
+    response::{IntoResponse, Response},
+    routing::get,
+};
+use pmoserver::Profile;
+use serde::Deserialize;
+use std::sync::Arc;
+
+/// État partagé des handlers
+#[derive(Clone)]
+pub struct FavoritesState {
+    pub store: Arc<FavoritesStore>,
+}
+
+impl IntoResponse for FavoritesError {
+    fn into_response(self) -> Response {
+        let status = match &self {
+            FavoritesError::UnknownFavorite(_) => StatusCode::NOT_FOUND,
+            FavoritesError::AlreadyExists(_) => StatusCode::CONFLICT,
+            FavoritesError::Invalid(_) => StatusCode::UNPROCESSABLE_ENTITY,
+            FavoritesError::Storage(_) => StatusCode::INTERNAL_SERVER_ERROR,
+            #[cfg(feature = "control")]
+            FavoritesError::UnknownRenderer(_) => StatusCode::NOT_FOUND,
+            #[cfg(feature = "control")]
+            FavoritesError::Renderer(_) => StatusCode::BAD_GATEWAY,
+        };
+        (
+            status,
+            Json(serde_json::json!({ "error": self.to_string() })),
+        )
+            .into_response()
+    }
+}
+
+#[derive(Debug, Deserialize)]
+pub struct ListQuery {
+    pub kind: Option<String>,
+}
+
+#[cfg(feature = "control")]
+#[derive(Debug, Deserialize)]
+pub struct InsertRequest {
+    pub renderer_id: String,
+    /// Index d'insertion dans la file (absent : en fin de file)
+    #[serde(default)]
+    pub position: Option<usize>,
+}
+
+/// Crée le router de l'API favoris
+pub fn create_router(state: FavoritesState) -> Router {
+    let router = Router::new()
+        .route("/", get(list_favorites).post(add_favorite))
+        .route("/{id}", get(get_favorite).delete(remove_favorite));
+    #[cfg(feature = "control")]
+    let router = router.route("/{id}/insert", axum::routing::post(insert_favorite));
+    router.with_state(state)
+}
+
+/// GET /api/favorites
+async fn list_favorites(
+    State(state): State<FavoritesState>,
+    Profile(profile): Profile,
+    Query(query): Query<ListQuery>,
+) -> Result<Json<Vec<Favorite>>, FavoritesError> {
+    let kind = query
+        .kind
+        .as_deref()
+        .map(str::parse::<FavoriteKind>)
+        .transpose()?;
+    Ok(Json(state.store.list(&profile, kind)?))
+}
+
+/// POST /api/favorites
+async fn add_favorite(
+    State(state): State<FavoritesState>,
+    Profile(profile): Profile,
+    Json(req): Json<NewFavorite>,
+) -> Result<(StatusCode, Json<Favorite>), FavoritesError> {
+    let favorite = state.store.add(&profile, req)?;
+    Ok((StatusCode::CREATED, Json(favorite)))
+}
+
+/// GET /api/favorites/{id}
+async fn get_favorite(
+    State(state): State<FavoritesState>,
+    Profile(profile): Profile,
+    Path(id): Path<String>,
+) -> Result<Json<Favorite>, FavoritesError> {
+    Ok(Json(state.store.get_in(&profile, &id)?))
+}
+
+/// DELETE /api/favorites/{id}
+async fn remove_favorite(
+    State(state): State<FavoritesState>,
+    Profile(profile): Profile,
+    Path(id): Path<String>,
+) -> Result<StatusCode, FavoritesError> {
+    state.store.remove(&profile, &id)?;
+    Ok(StatusCode::NO_CONTENT)
+}
+
+/// POST /api/favorites/{id}/insert
+#[cfg(feature = "control")]
+async fn insert_favorite(
+    State(state): State<FavoritesState>,
+    Profile(profile): Profile,
+    Path(id): Path<String>,
+    Json(req): Json<InsertRequest>,
+) -> Result<Json<serde_json::Value>, FavoritesError> {
+    let inserted = crate::control::insert_favorite(
+        &state.store,
+        &profile,
+        &id,
+        &req.renderer_id,
+        req.position,
+    )
+    .await?;
+    Ok(Json(serde_json::json!({ "inserted": inserted })))
+}
@@ -0,0 +1,35 @@
+//! Extension pour intégrer les favoris dans pmoconfig
+//!
+//! ```yaml
+//! sources:
+//!   favorites:
+//!     directory: "favorites"       # favorites.db
+//! ```
+
+use anyhow::Result;
+use pmoconfig::Config;
+use std::path::PathBuf;
+
+const DEFAULT_DIRECTORY: &str = "favorites";
+
+/// Nom de la base des favoris dans le répertoire configuré
+const DB_FILE: &str = "favorites.db";
+
+/// Trait d'extension pour la configuration des favoris
+pub trait FavoritesConfigExt {
+    /// Répertoire de stockage des favoris (créé si nécessaire)
+    fn get_favorites_dir(&self) -> Result<String>;
+
+    /// Chemin de la base SQLite des favoris
+    fn get_favorites_db_path(&self) -> Result<PathBuf>;
+}
+
+impl FavoritesConfigExt for Config {
+    fn get_favorites_dir(&self) -> Result<String> {
+        self.get_managed_dir(&["sources", "favorites", "directory"], DEFAULT_DIRECTORY)
+    }
+
+    fn get_favorites_db_path(&self) -> Result<PathBuf> {
+        Ok(PathBuf::from(self.get_favorites_dir()?).join(DB_FILE))
+    }
+}
@@ -0,0 +1,105 @@
+//! Insertion des favoris dans la file des renderers
+//!
+//! La file d'un renderer OpenHome est sa playlist native : insérer un favori
+//! se traduit par des actions `Insert` du service Playlist. Pour les autres
+//! renderers, le favori rejoint la file interne du control point.
+//!
+//! Le renderer joue ensuite pour le profil du favori : l'historique et les
+//! positions de reprise lui sont attribués.
+
+use crate::error::{FavoritesError, Result};
+use crate::provider::FavoritesProvider;
+use crate::store::{Favorite, FavoriteKind, FavoritesStore};
+use pmocontrol::model::TrackMetadata;
+use pmocontrol::{ControlPoint, DeviceId, PlaybackItem};
+use pmodidl::Item;
+use std::sync::{Arc, OnceLock};
+use tracing::info;
+
+/// Identifiant du « serveur » d'origine des pistes insérées depuis les favoris
+const FAVORITES_SOURCE_ID: &str = "x-pmo-favorites";
+
+static CONTROL_POINT: OnceLock<Arc<ControlPoint>> = OnceLock::new();
+
+/// Donne accès au control point pour l'insertion des favoris
+///
+/// À appeler une fois le control point enregistré ; les appels suivants
+/// sont ignorés.
+pub fn attach_control_point(control_point: Arc<ControlPoint>) {
+    let _ = CONTROL_POINT.set(control_point);
+}
+
+fn playback_item(favorite: &Favorite, item: Item) -> Option<PlaybackItem> {
+    let resource = item.resources.into_iter().next()?;
+    Some(PlaybackItem {
+        media_server_id: DeviceId(FAVORITES_SOURCE_ID.to_string()),
+        backend_id: usize::MAX,
+        didl_id: item.id,
+        uri: resource.url,
+        protocol_info: resource.protocol_info,
+        metadata: Some(TrackMetadata {
+            title: Some(item.title),
+            artist: item.artist,
+            album: item.album,
+            genre: item.genre,
+            album_art_uri: item.album_art,
+            date: item.date,
+            track_number: item.original_track_number,
+            creator: item.creator,
+            duration: resource.duration,
+            is_continuous_stream: favorite.kind == FavoriteKind::Radio,
+        }),
+    })
+}
+
+/// Insère un favori dans la file d'un renderer
+///
+/// `position` : index d'insertion (None : en fin de file). Retourne le
+/// nombre de pistes insérées.
+pub async fn insert_favorite(
+    store: &Arc<FavoritesStore>,
+    profile: &str,
+    favorite_id: &str,
+    renderer_id: &str,
+    position: Option<usize>,
+) -> Result<usize> {
+    let control_point = CONTROL_POINT
+        .get()
+        .cloned()
+        .ok_or_else(|| FavoritesError::Renderer("control point not available".to_string()))?;
+    let renderer_id = DeviceId(renderer_id.to_string());
+    if control_point.music_renderer_by_id(&renderer_id).is_none() {
+        return Err(FavoritesError::UnknownRenderer(renderer_id.0));
+    }
+
+    let favorite = store.get_in(profile, favorite_id)?;
+    let items: Vec<PlaybackItem> = FavoritesProvider::new(store.clone())
+        .items(&favorite)
+        .await
+        .map_err(|e| FavoritesError::Renderer(e.to_string()))?
+        .into_iter()
+        .filter_map(|item| playback_item(&favorite, item))
+        .collect();
+    if items.is_empty() {
+        return Err(FavoritesError::Invalid(format!(
+            "nothing playable in '{}'",
+            favorite.title
+        )));
+    }
+
+    let count = items.len();
+    control_point.renderer_profiles().set(&renderer_id, profile);
+    let rid = renderer_id.clone();
+    tokio::task::spawn_blocking(move || {
+        control_point.insert_queue_items_at(&rid, position.unwrap_or(usize::MAX), items)
+    })
+    .await
+    .map_err(|e| FavoritesError::Renderer(e.to_string()))?
+    .map_err(|e| FavoritesError::Renderer(e.to_string()))?;
+
+    info!(
+        "⭐ Inserted favorite '{}' ({} tracks) into renderer {}",
+        favorite.title, count, renderer_id.0
+    );
+    Ok(count)
+}
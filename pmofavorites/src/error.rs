@@ -0,0 +1,38 @@
+//! Types d'erreurs pour pmofavorites
+
+use thiserror::Error;
+
+/// Erreurs du magasin de favoris
+#[derive(Debug, Error)]
+pub enum FavoritesError {
+    #[error("Unknown favorite: {0}")]
+    UnknownFavorite(String),
+
+    #[error("Already a favorite: {0}")]
+    AlreadyExists(String),
+
+    #[error("Invalid favorite: {0}")]
+    Invalid(String),
+
+    #[error("Storage error: {0}")]
+    Storage(#[from] rusqlite::Error),
+
+    #[cfg(feature = "control")]
+    #[error("Unknown renderer: {0}")]
+    UnknownRenderer(String),
+
+    #[cfg(feature = "control")]
+    #[error("Renderer error: {0}")]
+    Renderer(String),
+}
+
+pub type Result<T> = std::result::Result<T, FavoritesError>;
+
+impl From<FavoritesError> for pmosource::MusicSourceError {
+    fn from(e: FavoritesError) -> Self {
+        match e {
+            FavoritesError::UnknownFavorite(id) => pmosource::MusicSourceError::ObjectNotFound(id),
+            other => pmosource::MusicSourceError::SourceUnavailable(other.to_string()),
+        }
+    }
+}
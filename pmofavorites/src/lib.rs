@@ -0,0 +1,42 @@
+//! # PMOFavorites
+//!
+//! Favoris (morceaux, albums, radios) pour PMOMusic.
+//!
+//! - Persistance dans une base SQLite (`favorites.db`)
+//! - Exposition dans le ContentDirectory sous un container « Favoris »
+//!   (provider `favorites` des dossiers virtuels, voir `pmosource::provider`)
+//! - API REST sous `/api/favorites`
+//! - Feature `control` : insertion d'un favori dans la file d'un renderer,
+//!   c'est-à-dire un `Insert` dans la playlist des renderers OpenHome
+//!
+//! Un morceau ou une radio est mémorisé avec son URI de lecture ; un album
+//! référence un container du ContentDirectory local (`qobuz:album:123`) dont
+//! les pistes sont relues à chaque parcours.
+//!
+//! Chaque profil d'écoute (voir `pmoconfig::profiles`) a ses propres
+//! favoris ; les control points UPnP voient ceux du profil par défaut.
+//!
+//! ## Configuration
+//!
+//! ```yaml
+//! sources:
+//!   favorites:
+//!     directory: "favorites"
+//! ```
+
+pub mod api;
+pub mod config_ext;
+#[cfg(feature = "control")]
+pub mod control;
+pub mod error;
+pub mod pmoserver_ext;
+pub mod provider;
+pub mod store;
+
+pub use config_ext::FavoritesConfigExt;
+#[cfg(feature = "control")]
+pub use control::attach_control_point;
+pub use error::{FavoritesError, Result};
+pub use pmoserver_ext::FavoritesExt;
+pub use provider::FavoritesProvider;
+pub use store::{Favorite, FavoriteKind, FavoritesStore, NewFavorite};
@@ -0,0 +1,47 @@
+//! Extension pmoserver pour les favoris
+
+use crate::api::{FavoritesState, create_router};
+use crate::provider::FavoritesProvider;
+use crate::store::FavoritesStore;
+use anyhow::Result;
+use pmoserver::Server;
+use std::sync::Arc;
+use tracing::info;
+
+/// Trait pour étendre pmoserver avec les favoris
+///
+/// # Exemple
+///
+/// ```rust,ignore
+/// use pmofavorites::FavoritesExt;
+///
+/// let store = server.init_favorites().await?;
+/// ```
+#[allow(async_fn_in_trait)]
+pub trait FavoritesExt {
+    /// Initialise les favoris
+    ///
+    /// Cette méthode :
+    /// - ouvre la base `favorites.db` du répertoire configuré
+    /// - enregistre la fabrique `favorites` des dossiers virtuels
+    /// - enregistre les routes `/api/favorites/*`
+    async fn init_favorites(&mut self) -> Result<Arc<FavoritesStore>>;
+}
+
+impl FavoritesExt for Server {
+    async fn init_favorites(&mut self) -> Result<Arc<FavoritesStore>> {
+        info!("Initializing favorites...");
+
+        let store = Arc::new(FavoritesStore::from_config()?);
+
+        FavoritesProvider::register_factory(store.clone());
+
+        let router = create_router(FavoritesState {
+            store: store.clone(),
+        });
+        self.add_router("/api/favorites", router).await;
+
+        info!("⭐ Favorites initialized ({} entries)", store.total()?);
+        Ok(store)
+    }
+}
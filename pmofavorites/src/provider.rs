@@ -0,0 +1,308 @@
+//! Favoris exposés comme dossier virtuel du ContentDirectory
+//!
+//! Arborescence (chemins relatifs au montage) :
+//!
+//! ```text
+//! ""                      Morceaux / Albums / Radios, puis un dossier par profil
+//! kind:{kind}             favoris d'une nature (profil par défaut)
+//! profile:{profile}       Morceaux / Albums / Radios d'un autre profil
+//! kind:{kind}:{profile}   favoris d'une nature de ce profil
+//! favorite:{id}           un morceau ou une radio
+//! album:{id}              pistes de l'album (relues depuis sa source)
+//! {source}:{kind}:{local} une piste d'album, servie par sa source
+//! ```
+//!
+//! Un control point UPnP ne connaît pas les profils : la racine présente les
+//! favoris du profil par défaut, et la recherche ne porte que sur eux.
+
+use crate::store::{Favorite, FavoriteKind, FavoritesStore};
+use pmoconfig::DEFAULT_PROFILE;
+use pmodidl::{Container, Item, Resource};
+use pmosource::provider::{ContentProvider, register_provider_factory};
+use pmosource::{BrowseResult, MusicSourceError, ObjectId, Result, SearchQuery};
+use std::sync::Arc;
+use std::time::SystemTime;
+
+/// Nom de la fabrique enregistrée pour `sources.virtual_folders`
+pub const PROVIDER_NAME: &str = "favorites";
+
+/// protocolInfo utilisé quand le favori n'en précise pas
+const DEFAULT_PROTOCOL_INFO: &str = "http-get:*:audio/*:*";
+
+/// Provider de contenu adossé au [`FavoritesStore`]
+#[derive(Debug, Clone)]
+pub struct FavoritesProvider {
+    store: Arc<FavoritesStore>,
+}
+
+impl FavoritesProvider {
+    pub fn new(store: Arc<FavoritesStore>) -> Self {
+        Self { store }
+    }
+
+    /// Enregistre la fabrique `favorites` pour les dossiers virtuels
+    pub fn register_factory(store: Arc<FavoritesStore>) {
+        register_provider_factory(PROVIDER_NAME, move |_| {
+            Ok(Arc::new(FavoritesProvider::new(store.clone())) as Arc<dyn ContentProvider>)
+        });
+    }
+
+    fn kind_title(kind: FavoriteKind) -> &'static str {
+        match kind {
+            FavoriteKind::Track => "Morceaux",
+            FavoriteKind::Album => "Albums",
+            FavoriteKind::Radio => "Radios",
+        }
+    }
+
+    /// Profil par défaut configuré
+    fn default_profile() -> String {
+        pmoconfig::get_config()
+            .get_default_profile()
+            .unwrap_or_else(|_| DEFAULT_PROFILE.to_string())
+    }
+
+    /// Chemin du dossier des favoris d'une nature d'un profil
+    fn kind_path(kind: FavoriteKind, profile: &str) -> String {
+        if profile == Self::default_profile() {
+            format!("kind:{}", kind)
+        } else {
+            format!("kind:{}:{}", kind, profile)
+        }
+    }
+
+    fn kind_container(&self, kind: FavoriteKind, profile: &str) -> Result<Container> {
+        let parent_id = if profile == Self::default_profile() {
+            String::new()
+        } else {
+            format!("profile:{}", profile)
+        };
+        Ok(Container {
+            id: Self::kind_path(kind, profile),
+            parent_id,
+            restricted: Some("1".to_string()),
+            child_count: Some(self.store.count(profile, kind)?.to_string()),
+            searchable: Some("1".to_string()),
+            title: Self::kind_title(kind).to_string(),
+            class: "object.container".to_string(),
+            artist: None,
+            album_art: None,
+            containers: vec![],
+            items: vec![],
+        })
+    }
+
+    fn kind_containers(&self, profile: &str) -> Result<Vec<Container>> {
+        FavoriteKind::ALL
+            .into_iter()
+            .map(|kind| self.kind_container(kind, profile))
+            .collect()
+    }
+
+    fn profile_container(profile: &str) -> Container {
+        Container {
+            id: format!("profile:{}", profile),
+            parent_id: String::new(),
+            restricted: Some("1".to_string()),
+            child_count: Some(FavoriteKind::ALL.len().to_string()),
+            searchable: Some("0".to_string()),
+            title: profile.to_string(),
+            class: "object.container".to_string(),
+            artist: None,
+            album_art: None,
+            containers: vec![],
+            items: vec![],
+        }
+    }
+
+    fn album_container(favorite: &Favorite) -> Container {
+        Container {
+            id: format!("album:{}", favorite.id),
+            parent_id: Self::kind_path(FavoriteKind::Album, &favorite.profile),
+            restricted: Some("1".to_string()),
+            child_count: None,
+            searchable: Some("0".to_string()),
+            title: favorite.title.clone(),
+            class: "object.container.album.musicAlbum".to_string(),
+            artist: favorite.artist.clone(),
+            album_art: favorite.album_art.clone(),
+            containers: vec![],
+            items: vec![],
+        }
+    }
+
+    /// Item DIDL d'un morceau ou d'une radio
+    pub fn favorite_item(favorite: &Favorite) -> Item {
+        let class = match favorite.kind {
+            FavoriteKind::Radio => "object.item.audioItem.audioBroadcast",
+            _ => "object.item.audioItem.musicTrack",
+        };
+        Item {
+            id: format!("favorite:{}", favorite.id),
+            parent_id: Self::kind_path(favorite.kind, &favorite.profile),
+            restricted: Some("1".to_string()),
+            title: favorite.title.clone(),
+            creator: favorite.artist.clone(),
+            class: class.to_string(),
+            artist: favorite.artist.clone(),
+            album: favorite.album.clone(),
+            genre: favorite.genre.clone(),
+            album_art: favorite.album_art.clone(),
+            album_art_pk: None,
+            date: None,
+            original_track_number: None,
+            resources: favorite
+                .uri
+                .iter()
+                .map(|uri| Resource {
+                    protocol_info: favorite
+                        .protocol_info
+                        .clone()
+                        .unwrap_or_else(|| DEFAULT_PROTOCOL_INFO.to_string()),
+                    bits_per_sample: None,
+                    sample_frequency: None,
+                    nr_audio_channels: None,
+                    duration: favorite.duration.clone(),
+                    url: uri.clone(),
+                })
+                .collect(),
+            descriptions: vec![],
+        }
+    }
+
+    /// Pistes d'un album favori, relues depuis la source qui le sert
+    ///
+    /// Les pistes gardent l'ObjectID attribué par leur source.
+    async fn album_items(favorite: &Favorite) -> Result<Vec<Item>> {
+        let object_id = favorite.object_id.as_deref().unwrap_or_default();
+        let source = Self::source_of(object_id).await?;
+        let items = match source.browse(object_id).await? {
+            BrowseResult::Items(items) | BrowseResult::Mixed { items, .. } => items,
+            BrowseResult::Containers(_) => vec![],
+        };
+        Ok(items
+            .into_iter()
+            .map(|mut item| {
+                item.parent_id = format!("album:{}", favorite.id);
+                item
+            })
+            .collect())
+    }
+
+    /// Source du ContentDirectory local qui sert un ObjectID
+    async fn source_of(object_id: &str) -> Result<Arc<dyn pmosource::MusicSource>> {
+        let source = ObjectId::parse(object_id)
+            .ok()
+            .and_then(|id| id.source().map(str::to_string))
+            .ok_or_else(|| MusicSourceError::ObjectNotFound(object_id.to_string()))?;
+        pmosource::api::get_source(&source)
+            .await
+            .ok_or_else(|| MusicSourceError::SourceUnavailable(source))
+    }
+
+    /// Items lisibles d'un favori (la piste, ou les pistes de l'album)
+    pub async fn items(&self, favorite: &Favorite) -> Result<Vec<Item>> {
+        match favorite.kind {
+            FavoriteKind::Album => Self::album_items(favorite).await,
+            _ => Ok(vec![Self::favorite_item(favorite)]),
+        }
+    }
+}
+
+#[async_trait::async_trait]
+impl ContentProvider for FavoritesProvider {
+    async fn browse(&self, path: &str) -> Result<BrowseResult> {
+        let not_found = || MusicSourceError::ObjectNotFound(path.to_string());
+        if path.is_empty() {
+            let default = Self::default_profile();
+            let mut containers = self.kind_containers(&default)?;
+            let profiles = pmoconfig::get_config()
+                .get_profiles()
+                .map_err(|e| MusicSourceError::SourceUnavailable(e.to_string()))?;
+            containers.extend(
+                profiles
+                    .iter()
+                    .filter(|profile| **profile != default)
+                    .map(|profile| Self::profile_container(profile)),
+            );
+            return Ok(BrowseResult::Containers(containers));
+        }
+        if let Some(profile) = path.strip_prefix("profile:") {
+            return Ok(BrowseResult::Containers(self.kind_containers(profile)?));
+        }
+        if let Some(rest) = path.strip_prefix("kind:") {
+            let (kind, profile) = match rest.split_once(':') {
+                Some((kind, profile)) => (kind, profile.to_string()),
+                None => (rest, Self::default_profile()),
+            };
+            let kind: FavoriteKind = kind.parse().map_err(|_| not_found())?;
+            let favorites = self.store.list(&profile, Some(kind))?;
+            return Ok(match kind {
+                FavoriteKind::Album => {
+                    BrowseResult::Containers(favorites.iter().map(Self::album_container).collect())
+                }
+                _ => BrowseResult::Items(favorites.iter().map(Self::favorite_item).collect()),
+            });
+        }
+        if let Some(id) = path.strip_prefix("album:") {
+            let favorite = self.store.get(id)?;
+            return Ok(BrowseResult::Items(Self::album_items(&favorite).await?));
+        }
+        let item = self.get_item(path).await?;
+        Ok(BrowseResult::Items(vec![item]))
+    }
+
+    async fn get_item(&self, path: &str) -> Result<Item> {
+        if let Some(id) = path.strip_prefix("favorite:") {
+            return Ok(Self::favorite_item(&self.store.get(id)?));
+        }
+        Self::source_of(path).await?.get_item(path).await
+    }
+
+    async fn resolve(&self, path: &str) -> Result<String> {
+        if let Some(id) = path.strip_prefix("favorite:") {
+            let favorite = self.store.get(id)?;
+            return favorite
+                .uri
+                .ok_or_else(|| MusicSourceError::ObjectNotFound(path.to_string()));
+        }
+        Self::source_of(path).await?.resolve_uri(path).await
+    }
+
+    async fn search(&self, query: &SearchQuery) -> Result<BrowseResult> {
+        let needle = query.text.to_lowercase();
+        let matches = |favorite: &Favorite| {
+            favorite.title.to_lowercase().contains(&needle)
+                || favorite
+                    .artist
+                    .as_deref()
+                    .is_some_and(|a| a.to_lowercase().contains(&needle))
+        };
+
+        let mut containers = Vec::new();
+        let mut items = Vec::new();
+        let favorites = self.store.list(&Self::default_profile(), None)?;
+        for favorite in favorites.iter().filter(|f| matches(f)) {
+            match favorite.kind {
+                FavoriteKind::Album => containers.push(Self::album_container(favorite)),
+                _ => items.push(Self::favorite_item(favorite)),
+            }
+        }
+        let offset = query.offset as usize;
+        let limit = query.limit as usize;
+        items = items.into_iter().skip(offset).take(limit).collect();
+        Ok(BrowseResult::Mixed { containers, items })
+    }
+
+    fn supports_search(&self) -> bool {
+        true
+    }
+
+    async fn update_id(&self) -> u32 {
+        self.store.update_id()
+    }
+
+    async fn last_change(&self) -> Option<SystemTime> {
+        self.store.last_change()
+    }
+}
@@ -0,0 +1,497 @@
+//! Magasin SQLite des favoris
+//!
+//! Chaque favori est identifié par un hash stable de son profil d'écoute,
+//! de sa nature et de sa référence (URI pour un morceau ou une radio,
+//! ObjectID pour un album) : ajouter deux fois le même favori à un profil est
+//! refusé. Les favoris du profil `default` gardent l'identifiant qu'ils
+//! avaient avant l'introduction des profils.
+
+use crate::config_ext::FavoritesConfigExt;
+use crate::error::{FavoritesError, Result};
+use chrono::{DateTime, Utc};
+use pmoconfig::DEFAULT_PROFILE;
+use pmosource::ObjectId;
+use rusqlite::{Connection, OptionalExtension, Row, params};
+use serde::{Deserialize, Serialize};
+use sha2::{Digest, Sha256};
+use std::fmt;
+use std::path::Path;
+use std::str::FromStr;
+use std::sync::Mutex;
+use std::sync::atomic::{AtomicU32, Ordering};
+use std::time::SystemTime;
+use tracing::info;
+
+/// Version du schéma de la base des favoris
+///
+/// - 1 : favoris communs
+/// - 2 : colonne `profile`
+const SCHEMA_VERSION: u32 = 2;
+
+const COLUMNS: &str = "id, kind, title, artist, album, genre, album_art, uri, protocol_info, \
+                       duration, object_id, added_at, profile";
+
+/// Nature d'un favori
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Hash, Serialize, Deserialize)]
+#[serde(rename_all = "lowercase")]
+pub enum FavoriteKind {
+    Track,
+    Album,
+    Radio,
+}
+
+impl FavoriteKind {
+    pub const ALL: [FavoriteKind; 3] = [
+        FavoriteKind::Track,
+        FavoriteKind::Album,
+        FavoriteKind::Radio,
+    ];
+
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            FavoriteKind::Track => "track",
+            FavoriteKind::Album => "album",
+            FavoriteKind::Radio => "radio",
+        }
+    }
+}
+
+impl fmt::Display for FavoriteKind {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.write_str(self.as_str())
+    }
+}
+
+impl FromStr for FavoriteKind {
+    type Err = FavoritesError;
+
+    fn from_str(s: &str) -> Result<Self> {
+        match s.to_ascii_lowercase().as_str() {
+            "track" => Ok(FavoriteKind::Track),
+            "album" => Ok(FavoriteKind::Album),
+            "radio" => Ok(FavoriteKind::Radio),
+            other => Err(FavoritesError::Invalid(format!("unknown kind: {}", other))),
+        }
+    }
+}
+
+/// Favori enregistré
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct Favorite {
+    pub id: String,
+    pub kind: FavoriteKind,
+    pub title: String,
+    pub artist: Option<String>,
+    pub album: Option<String>,
+    pub genre: Option<String>,
+    pub album_art: Option<String>,
+    /// URI de lecture (morceau, radio)
+    pub uri: Option<String>,
+    pub protocol_info: Option<String>,
+    /// Durée au format UPnP (`H:MM:SS`)
+    pub duration: Option<String>,
+    /// ObjectID du container dans le ContentDirectory local (album)
+    pub object_id: Option<String>,
+    pub added_at: DateTime<Utc>,
+    /// Profil d'écoute propriétaire
+    pub profile: String,
+}
+
+/// Favori à ajouter
+#[derive(Debug, Clone, Deserialize)]
+pub struct NewFavorite {
+    pub kind: FavoriteKind,
+    pub title: String,
+    #[serde(default)]
+    pub artist: Option<String>,
+    #[serde(default)]
+    pub album: Option<String>,
+    #[serde(default)]
+    pub genre: Option<String>,
+    #[serde(default)]
+    pub album_art: Option<String>,
+    #[serde(default)]
+    pub uri: Option<String>,
+    #[serde(default)]
+    pub protocol_info: Option<String>,
+    #[serde(default)]
+    pub duration: Option<String>,
+    #[serde(default)]
+    pub object_id: Option<String>,
+}
+
+impl NewFavorite {
+    /// Référence du favori : URI ou ObjectID selon sa nature
+    fn reference(&self) -> Result<&str> {
+        let title = self.title.trim();
+        if title.is_empty() {
+            return Err(FavoritesError::Invalid("empty title".to_string()));
+        }
+        match self.kind {
+            FavoriteKind::Track | FavoriteKind::Radio => {
+                let uri = self.uri.as_deref().map(str::trim).unwrap_or_default();
+                if !(uri.starts_with("http://") || uri.starts_with("https://")) {
+                    return Err(FavoritesError::Invalid(format!(
+                        "a {} needs an http(s) uri",
+                        self.kind
+                    )));
+                }
+                Ok(uri)
+            }
+            FavoriteKind::Album => {
+                let object_id = self.object_id.as_deref().map(str::trim).unwrap_or_default();
+                match ObjectId::parse(object_id) {
+                    Ok(ObjectId::Object { .. }) => Ok(object_id),
+                    _ => Err(FavoritesError::Invalid(format!(
+                        "an album needs a ContentDirectory object_id, got {:?}",
+                        object_id
+                    ))),
+                }
+            }
+        }
+    }
+}
+
+/// Identifiant stable d'un favori
+pub fn favorite_id(profile: &str, kind: FavoriteKind, reference: &str) -> String {
+    let key = if profile == DEFAULT_PROFILE {
+        format!("{}\n{}", kind, reference)
+    } else {
+        format!("{}\n{}\n{}", profile, kind, reference)
+    };
+    let digest = Sha256::digest(key.as_bytes());
+    let mut hex: String = digest.iter().map(|b| format!("{:02x}", b)).collect();
+    hex.truncate(16);
+    hex
+}
+
+fn favorite_from_row(row: &Row<'_>) -> rusqlite::Result<Favorite> {
+    let kind: String = row.get(1)?;
+    Ok(Favorite {
+        id: row.get(0)?,
+        kind: kind.parse().map_err(|e: FavoritesError| {
+            rusqlite::Error::FromSqlConversionFailure(1, rusqlite::types::Type::Text, e.into())
+        })?,
+        title: row.get(2)?,
+        artist: row.get(3)?,
+        album: row.get(4)?,
+        genre: row.get(5)?,
+        album_art: row.get(6)?,
+        uri: row.get(7)?,
+        protocol_info: row.get(8)?,
+        duration: row.get(9)?,
+        object_id: row.get(10)?,
+        added_at: row.get(11)?,
+        profile: row.get(12)?,
+    })
+}
+
+/// Favoris persistés dans SQLite
+#[derive(Debug)]
+pub struct FavoritesStore {
+    conn: Mutex<Connection>,
+    update_id: AtomicU32,
+    last_change: Mutex<Option<SystemTime>>,
+}
+
+impl FavoritesStore {
+    /// Ouvre (ou crée) la base des favoris
+    pub fn open(path: &Path) -> Result<Self> {
+        if let Some(parent) = path.parent() {
+            std::fs::create_dir_all(parent).map_err(|e| {
+                FavoritesError::Invalid(format!("cannot create {}: {}", parent.display(), e))
+            })?;
+        }
+        Self::with_connection(Connection::open(path)?)
+    }
+
+    /// Base en mémoire (tests, favoris non persistés)
+    pub fn in_memory() -> Result<Self> {
+        Self::with_connection(Connection::open_in_memory()?)
+    }
+
+    /// Ouvre la base depuis la configuration (`sources.favorites`)
+    pub fn from_config() -> anyhow::Result<Self> {
+        let path = pmoconfig::get_config().get_favorites_db_path()?;
+        Ok(Self::open(&path)?)
+    }
+
+    fn with_connection(conn: Connection) -> Result<Self> {
+        let version: u32 = conn.query_row("PRAGMA user_version", [], |r| r.get(0))?;
+        if version > SCHEMA_VERSION {
+            return Err(FavoritesError::Invalid(format!(
+                "favorites database schema {} is newer than supported ({})",
+                version, SCHEMA_VERSION
+            )));
+        }
+        if version == 1 {
+            info!(
+                "⭐ Migrating favorites database to schema {}",
+                SCHEMA_VERSION
+            );
+            conn.execute_batch(&format!(
+                "ALTER TABLE favorites ADD COLUMN profile TEXT NOT NULL DEFAULT '{}';",
+                DEFAULT_PROFILE
+            ))?;
+        }
+        conn.execute_batch(&format!(
+            "CREATE TABLE IF NOT EXISTS favorites (
+                id TEXT PRIMARY KEY,
+                kind TEXT NOT NULL,
+                title TEXT NOT NULL,
+                artist TEXT,
+                album TEXT,
+                genre TEXT,
+                album_art TEXT,
+                uri TEXT,
+                protocol_info TEXT,
+                duration TEXT,
+                object_id TEXT,
+                added_at TEXT NOT NULL,
+                profile TEXT NOT NULL DEFAULT '{}'
+            );
+            CREATE INDEX IF NOT EXISTS idx_favorites_kind ON favorites(kind, added_at);
+            CREATE INDEX IF NOT EXISTS idx_favorites_profile
+                ON favorites(profile, kind, added_at);
+            PRAGMA user_version = {};",
+            DEFAULT_PROFILE, SCHEMA_VERSION
+        ))?;
+
+        Ok(Self {
+            conn: Mutex::new(conn),
+            update_id: AtomicU32::new(1),
+            last_change: Mutex::new(None),
+        })
+    }
+
+    fn conn(&self) -> std::sync::MutexGuard<'_, Connection> {
+        self.conn
+            .lock()
+            .expect("favorites connection mutex poisoned")
+    }
+
+    /// Compteur de modifications (pour le SystemUpdateID du ContentDirectory)
+    pub fn update_id(&self) -> u32 {
+        self.update_id.load(Ordering::Relaxed)
+    }
+
+    /// Date de la dernière modification
+    pub fn last_change(&self) -> Option<SystemTime> {
+        *self.last_change.lock().unwrap()
+    }
+
+    fn touch(&self) {
+        self.update_id.fetch_add(1, Ordering::Relaxed);
+        *self.last_change.lock().unwrap() = Some(SystemTime::now());
+    }
+
+    /// Liste les favoris d'un profil, dans l'ordre d'ajout
+    pub fn list(&self, profile: &str, kind: Option<FavoriteKind>) -> Result<Vec<Favorite>> {
+        let conn = self.conn();
+        let favorites = match kind {
+            Some(kind) => {
+                let mut stmt = conn.prepare(&format!(
+                    "SELECT {} FROM favorites WHERE profile = ?1 AND kind = ?2 \
+                     ORDER BY added_at, rowid",
+                    COLUMNS
+                ))?;
+                stmt.query_map(params![profile, kind.as_str()], favorite_from_row)?
+                    .collect::<rusqlite::Result<Vec<_>>>()?
+            }
+            None => {
+                let mut stmt = conn.prepare(&format!(
+                    "SELECT {} FROM favorites WHERE profile = ?1 ORDER BY added_at, rowid",
+                    COLUMNS
+                ))?;
+                stmt.query_map(params![profile], favorite_from_row)?
+                    .collect::<rusqlite::Result<Vec<_>>>()?
+            }
+        };
+        Ok(favorites)
+    }
+
+    /// Nombre total de favoris, tous profils confondus
+    pub fn total(&self) -> Result<usize> {
+        let count: i64 = self
+            .conn()
+            .query_row("SELECT COUNT(*) FROM favorites", [], |r| r.get(0))?;
+        Ok(count as usize)
+    }
+
+    /// Nombre de favoris d'une nature dans un profil
+    pub fn count(&self, profile: &str, kind: FavoriteKind) -> Result<usize> {
+        let count: i64 = self.conn().query_row(
+            "SELECT COUNT(*) FROM favorites WHERE profile = ?1 AND kind = ?2",
+            params![profile, kind.as_str()],
+            |r| r.get(0),
+        )?;
+        Ok(count as usize)
+    }
+
+    /// Retourne un favori
+    pub fn get(&self, id: &str) -> Result<Favorite> {
+        self.conn()
+            .query_row(
+                &format!("SELECT {} FROM favorites WHERE id = ?1", COLUMNS),
+                params![id],
+                favorite_from_row,
+            )
+            .optional()?
+            .ok_or_else(|| FavoritesError::UnknownFavorite(id.to_string()))
+    }
+
+    /// Retourne un favori s'il appartient au profil
+    pub fn get_in(&self, profile: &str, id: &str) -> Result<Favorite> {
+        self.get(id)
+            .ok()
+            .filter(|favorite| favorite.profile == profile)
+            .ok_or_else(|| FavoritesError::UnknownFavorite(id.to_string()))
+    }
+
+    /// Ajoute un favori au profil
+    pub fn add(&self, profile: &str, new: NewFavorite) -> Result<Favorite> {
+        let reference = new.reference()?;
+        let favorite = Favorite {
+            id: favorite_id(profile, new.kind, reference),
+            kind: new.kind,
+            title: new.title.trim().to_string(),
+            uri: new.uri.as_deref().map(|u| u.trim().to_string()),
+            object_id: new.object_id.as_deref().map(|o| o.trim().to_string()),
+            artist: new.artist,
+            album: new.album,
+            genre: new.genre,
+            album_art: new.album_art,
+            protocol_info: new.protocol_info,
+            duration: new.duration,
+            added_at: Utc::now(),
+            profile: profile.to_string(),
+        };
+
+        let inserted = self.conn().execute(
+            &format!(
+                "INSERT OR IGNORE INTO favorites ({}) \
+                 VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13)",
+                COLUMNS
+            ),
+            params![
+                favorite.id,
+                favorite.kind.as_str(),
+                favorite.title,
+                favorite.artist,
+                favorite.album,
+                favorite.genre,
+                favorite.album_art,
+                favorite.uri,
+                favorite.protocol_info,
+                favorite.duration,
+                favorite.object_id,
+                favorite.added_at,
+                favorite.profile,
+            ],
+        )?;
+        if inserted == 0 {
+            return Err(FavoritesError::AlreadyExists(favorite.title));
+        }
+
+        self.touch();
+        info!(
+            "⭐ Added {} '{}' to favorites of {}",
+            favorite.kind, favorite.title, favorite.profile
+        );
+        Ok(favorite)
+    }
+
+    /// Retire un favori du profil
+    pub fn remove(&self, profile: &str, id: &str) -> Result<()> {
+        let removed = self.conn().execute(
+            "DELETE FROM favorites WHERE id = ?1 AND profile = ?2",
+            params![id, profile],
+        )?;
+        if removed == 0 {
+            return Err(FavoritesError::UnknownFavorite(id.to_string()));
+        }
+        self.touch();
+        Ok(())
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn radio(uri: &str) -> NewFavorite {
+        NewFavorite {
+            kind: FavoriteKind::Radio,
+            title: "FIP".to_string(),
+            artist: None,
+            album: None,
+            genre: None,
+            album_art: None,
+            uri: Some(uri.to_string()),
+            protocol_info: Some("http-get:*:audio/aac:*".to_string()),
+            duration: None,
+            object_id: None,
+        }
+    }
+
+    #[test]
+    fn test_add_list_remove() {
+        let store = FavoritesStore::in_memory().unwrap();
+        let fip = store
+            .add(
+                DEFAULT_PROFILE,
+                radio("https://icecast.radiofrance.fr/fip-hifi.aac"),
+            )
+            .unwrap();
+        assert!(matches!(
+            store.add(
+                DEFAULT_PROFILE,
+                radio("https://icecast.radiofrance.fr/fip-hifi.aac")
+            ),
+            Err(FavoritesError::AlreadyExists(_))
+        ));
+        assert!(matches!(
+            store.add(DEFAULT_PROFILE, radio("file:///etc/passwd")),
+            Err(FavoritesError::Invalid(_))
+        ));
+
+        let album = NewFavorite {
+            kind: FavoriteKind::Album,
+            object_id: Some("qobuz:album:123".to_string()),
+            uri: None,
+            ..radio("")
+        };
+        store.add(DEFAULT_PROFILE, album).unwrap();
+
+        assert_eq!(store.list(DEFAULT_PROFILE, None).unwrap().len(), 2);
+        assert_eq!(
+            store
+                .list(DEFAULT_PROFILE, Some(FavoriteKind::Radio))
+                .unwrap(),
+            vec![fip.clone()]
+        );
+        assert_eq!(
+            store.count(DEFAULT_PROFILE, FavoriteKind::Album).unwrap(),
+            1
+        );
+
+        // Chaque profil a ses propres favoris
+        let alice_fip = store
+            .add(
+                "alice",
+                radio("https://icecast.radiofrance.fr/fip-hifi.aac"),
+            )
+            .unwrap();
+        assert_ne!(alice_fip.id, fip.id);
+        assert_eq!(store.list("alice", None).unwrap(), vec![alice_fip]);
+        assert!(store.remove("alice", &fip.id).is_err());
+
+        let update_id = store.update_id();
+        store.remove(DEFAULT_PROFILE, &fip.id).unwrap();
+        assert!(store.update_id() > update_id);
+        assert!(matches!(
+            store.get(&fip.id),
+            Err(FavoritesError::UnknownFavorite(_))
+        ));
+    }
+}
@@ -10,8 +10,9 @@ use crate::{
     aac::{decode_aac_stream, AacDecodedStream, AacError},
     decode_aiff_stream, decode_flac_stream, decode_mp3_stream, decode_ogg_opus_stream,
     decode_ogg_vorbis_stream, decode_wav_stream, pcm::StreamInfo, prefixed_reader::PrefixedReader,
-    AiffDecodedStream, AiffError, FlacDecodedStream, FlacError, Mp3DecodedStream, Mp3Error,
-    OggDecodedStream, OggError, OggOpusDecodedStream, OggOpusError, WavDecodedStream, WavError,
+    transcode::AudioCodec, AiffDecodedStream, AiffError, FlacDecodedStream, FlacError,
+    Mp3DecodedStream, Mp3Error, OggDecodedStream, OggError, OggOpusDecodedStream, OggOpusError,
+    WavDecodedStream, WavError,
 };
 
 const MAX_SNIFF_BYTES: usize = 64 * 1024;
@@ -131,6 +132,19 @@ impl DecodedAudioStream {
         }
     }
 
+    /// Codec detected in the input stream.
+    pub fn codec(&self) -> AudioCodec {
+        match self {
+            DecodedAudioStream::Flac(_) => AudioCodec::Flac,
+            DecodedAudioStream::Mp3(_) => AudioCodec::Mp3,
+            DecodedAudioStream::OggVorbis(_) => AudioCodec::OggVorbis,
+            DecodedAudioStream::OggOpus(_) => AudioCodec::OggOpus,
+            DecodedAudioStream::Wav(_) => AudioCodec::Wav,
+            DecodedAudioStream::Aiff(_) => AudioCodec::Aiff,
+            DecodedAudioStream::Aac(_) => AudioCodec::Aac,
+        }
+    }
+
     pub async fn wait(self) -> Result<(), DecodeAudioError> {
         match self {
             DecodedAudioStream::Flac(inner) => inner.wait().await.map_err(DecodeAudioError::Flac),
@@ -10,6 +10,7 @@
 //!
 //! - **MP3 decoding**: Stream MP3 files to PCM data
 //! - **FLAC encoding/decoding**: Bidirectional FLAC ↔ PCM conversion
+//! - **Ogg/Opus encoding**: Low-bitrate output for constrained links
 //! - **Async streaming API**: Built on Tokio's `AsyncRead` trait
 //! - **Low memory footprint**: Processes data in chunks, not entire files
 //! - **Zero-copy where possible**: Efficient buffer management
@@ -108,6 +109,7 @@ pub mod mp3;
 pub mod ogg;
 mod ogg_common;
 pub mod opus;
+pub mod opus_encoder;
 mod pcm;
 mod prefixed_reader;
 mod stream;
@@ -127,6 +129,9 @@ pub use metadata::AudioFileMetadata;
 pub use mp3::{decode_mp3_stream, Mp3DecodedStream, Mp3Error};
 pub use ogg::{decode_ogg_vorbis_stream, OggDecodedStream, OggError};
 pub use opus::{decode_ogg_opus_stream, OggOpusDecodedStream, OggOpusError};
+pub use opus_encoder::{
+    encode_ogg_opus_stream, OggOpusEncodedStream, OpusEncoderOptions, DEFAULT_OPUS_BITRATE,
+};
 pub use pcm::{PcmFormat, StreamInfo};
 pub use transcode::{
     transcode_to_flac_stream, AudioCodec, FlacTranscodeStream, TranscodeError, TranscodeOptions,
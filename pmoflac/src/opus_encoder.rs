@@ -0,0 +1,333 @@
+//! # Ogg/Opus Streaming Encoder
+//!
+//! Encodes 16-bit little-endian PCM into an Ogg/Opus stream (RFC 7845),
+//! 100% streaming: 20 ms frames are encoded as soon as they are read and
+//! grouped into Ogg pages.
+//!
+//! Opus only accepts 8, 12, 16, 24 or 48 kHz input, mono or stereo: resample
+//! upstream. Intended for low-bitrate listening over constrained links.
+//!
+//! ```text
+//! PCM Input → [20 ms frames] → [libopus] → [Ogg pages] → AsyncRead Output
+//! ```
+
+use std::{
+    io,
+    pin::Pin,
+    task::{Context, Poll},
+    time::{SystemTime, UNIX_EPOCH},
+};
+
+use opus::{Application, Bitrate, Channels, Encoder as OpusEncoder};
+use tokio::io::{AsyncRead, AsyncReadExt, AsyncWriteExt};
+
+use crate::{
+    decoder_common::DUPLEX_BUFFER_SIZE, error::FlacError, ogg_common::crc, pcm::PcmFormat,
+    stream::ManagedAsyncReader,
+};
+
+/// Default target bitrate (bits/s).
+pub const DEFAULT_OPUS_BITRATE: u32 = 96_000;
+
+/// Frame duration: 20 ms, the usual trade-off between latency and overhead.
+const FRAMES_PER_SECOND: u32 = 50;
+
+/// Largest Opus packet (RFC 6716).
+const MAX_PACKET_SIZE: usize = 1275;
+
+/// Packets grouped in one Ogg page (200 ms of audio).
+const PACKETS_PER_PAGE: usize = 10;
+
+/// Encoder delay declared as pre-skip (libopus lookahead at 48 kHz).
+const PRE_SKIP: u16 = 312;
+
+const OGG_FLAG_BOS: u8 = 0x02;
+const OGG_FLAG_EOS: u8 = 0x04;
+
+/// Options for configuring Opus encoding.
+#[derive(Debug, Clone)]
+pub struct OpusEncoderOptions {
+    /// Target bitrate in bits per second (6 000 – 510 000).
+    /// Default: 96 000
+    pub bitrate: u32,
+}
+
+impl Default for OpusEncoderOptions {
+    fn default() -> Self {
+        Self {
+            bitrate: DEFAULT_OPUS_BITRATE,
+        }
+    }
+}
+
+/// Ogg/Opus encoded stream (AsyncRead).
+pub struct OggOpusEncodedStream {
+    format: PcmFormat,
+    reader: ManagedAsyncReader<FlacError>,
+}
+
+impl OggOpusEncodedStream {
+    /// Returns the PCM format used for encoding.
+    pub fn format(&self) -> PcmFormat {
+        self.format
+    }
+
+    /// Waits for the background encoding task to complete.
+    pub async fn wait(self) -> Result<(), FlacError> {
+        self.reader.wait().await
+    }
+}
+
+impl AsyncRead for OggOpusEncodedStream {
+    fn poll_read(
+        mut self: Pin<&mut Self>,
+        cx: &mut Context<'_>,
+        buf: &mut tokio::io::ReadBuf<'_>,
+    ) -> Poll<io::Result<()>> {
+        Pin::new(&mut self.reader).poll_read(cx, buf)
+    }
+}
+
+/// Encodes 16-bit PCM into an Ogg/Opus stream.
+///
+/// # Example
+///
+/// ```no_run
+/// use pmoflac::{encode_ogg_opus_stream, OpusEncoderOptions, PcmFormat};
+///
+/// # async fn example(pcm_reader: tokio::fs::File) -> Result<(), pmoflac::FlacError> {
+/// let format = PcmFormat { sample_rate: 48_000, channels: 2, bits_per_sample: 16 };
+/// let options = OpusEncoderOptions { bitrate: 64_000 };
+/// let stream = encode_ogg_opus_stream(pcm_reader, format, options).await?;
+/// # Ok(())
+/// # }
+/// ```
+pub async fn encode_ogg_opus_stream<R>(
+    mut reader: R,
+    format: PcmFormat,
+    options: OpusEncoderOptions,
+) -> Result<OggOpusEncodedStream, FlacError>
+where
+    R: AsyncRead + Unpin + Send + 'static,
+{
+    if format.bits_per_sample != 16 {
+        return Err(FlacError::Unsupported(format!(
+            "Opus encoder expects 16-bit PCM, got {} bits",
+            format.bits_per_sample
+        )));
+    }
+    if ![8_000, 12_000, 16_000, 24_000, 48_000].contains(&format.sample_rate) {
+        return Err(FlacError::Unsupported(format!(
+            "Opus does not support {} Hz",
+            format.sample_rate
+        )));
+    }
+    let channels = match format.channels {
+        1 => Channels::Mono,
+        2 => Channels::Stereo,
+        other => {
+            return Err(FlacError::Unsupported(format!(
+                "Opus encoder supports 1 or 2 channels, got {}",
+                other
+            )));
+        }
+    };
+
+    let mut encoder = OpusEncoder::new(format.sample_rate, channels, Application::Audio)
+        .map_err(|e| FlacError::Encode(format!("Opus encoder init: {}", e)))?;
+    encoder
+        .set_bitrate(Bitrate::Bits(options.bitrate as i32))
+        .map_err(|e| FlacError::Encode(format!("Opus bitrate {}: {}", options.bitrate, e)))?;
+
+    let (output_reader, mut output_writer) = tokio::io::duplex(DUPLEX_BUFFER_SIZE);
+
+    let handle = tokio::spawn(async move {
+        let channels = format.channels as usize;
+        let frame_samples = (format.sample_rate / FRAMES_PER_SECOND) as usize;
+        // Granule positions are always expressed at 48 kHz.
+        let granule_scale = (48_000 / format.sample_rate) as u64;
+
+        let mut pager = OggPager::new(stream_serial());
+        output_writer
+            .write_all(&pager.page(0, OGG_FLAG_BOS, &[&opus_head(format)]))
+            .await?;
+        output_writer
+            .write_all(&pager.page(0, 0, &[&opus_tags()]))
+            .await?;
+
+        let mut pcm_bytes = vec![0u8; frame_samples * channels * 2];
+        let mut samples = vec![0i16; frame_samples * channels];
+        let mut packet = vec![0u8; MAX_PACKET_SIZE];
+        let mut packets: Vec<Vec<u8>> = Vec::with_capacity(PACKETS_PER_PAGE);
+        let mut encoded_frames = 0u64;
+        let mut last = false;
+
+        while !last {
+            let filled = read_frame(&mut reader, &mut pcm_bytes).await?;
+            last = filled < pcm_bytes.len();
+            if filled == 0 {
+                break;
+            }
+            // Last frame: pad with silence, the final granule trims it.
+            pcm_bytes[filled..].fill(0);
+            for (sample, bytes) in samples.iter_mut().zip(pcm_bytes.chunks_exact(2)) {
+                *sample = i16::from_le_bytes([bytes[0], bytes[1]]);
+            }
+
+            let len = encoder
+                .encode(&samples, &mut packet)
+                .map_err(|e| FlacError::Encode(format!("Opus encode: {}", e)))?;
+            packets.push(packet[..len].to_vec());
+            encoded_frames += (filled / (channels * 2)) as u64;
+
+            if packets.len() == PACKETS_PER_PAGE && !last {
+                let granule = PRE_SKIP as u64 + encoded_frames * granule_scale;
+                let refs: Vec<&[u8]> = packets.iter().map(Vec::as_slice).collect();
+                output_writer
+                    .write_all(&pager.page(granule, 0, &refs))
+                    .await?;
+                packets.clear();
+            }
+        }
+
+        // Last page, flagged end of stream (possibly without packets when
+        // the input ended on a page boundary).
+        let granule = PRE_SKIP as u64 + encoded_frames * granule_scale;
+        let refs: Vec<&[u8]> = packets.iter().map(Vec::as_slice).collect();
+        output_writer
+            .write_all(&pager.page(granule, OGG_FLAG_EOS, &refs))
+            .await?;
+
+        output_writer.shutdown().await?;
+        Ok::<(), FlacError>(())
+    });
+
+    Ok(OggOpusEncodedStream {
+        format,
+        reader: ManagedAsyncReader::new("opus-encoder", output_reader, handle),
+    })
+}
+
+/// Fills `buf` with one frame of PCM, returns the number of bytes read
+/// (less than `buf.len()` only at end of stream).
+async fn read_frame<R>(reader: &mut R, buf: &mut [u8]) -> io::Result<usize>
+where
+    R: AsyncRead + Unpin,
+{
+    let mut filled = 0;
+    while filled < buf.len() {
+        let n = reader.read(&mut buf[filled..]).await?;
+        if n == 0 {
+            break;
+        }
+        filled += n;
+    }
+    Ok(filled)
+}
+
+fn stream_serial() -> u32 {
+    SystemTime::now()
+        .duration_since(UNIX_EPOCH)
+        .map(|d| d.subsec_nanos() ^ d.as_secs() as u32)
+        .unwrap_or(0x4f707573)
+}
+
+/// OpusHead identification header (RFC 7845 §5.1).
+fn opus_head(format: PcmFormat) -> Vec<u8> {
+    let mut head = Vec::with_capacity(19);
+    head.extend_from_slice(b"OpusHead");
+    head.push(1); // version
+    head.push(format.channels);
+    head.extend_from_slice(&PRE_SKIP.to_le_bytes());
+    head.extend_from_slice(&format.sample_rate.to_le_bytes());
+    head.extend_from_slice(&0i16.to_le_bytes()); // output gain
+    head.push(0); // channel mapping family
+    head
+}
+
+/// OpusTags comment header (RFC 7845 §5.2), without user comments.
+fn opus_tags() -> Vec<u8> {
+    let vendor = b"pmoflac Opus encoder";
+    let mut tags = Vec::with_capacity(16 + vendor.len());
+    tags.extend_from_slice(b"OpusTags");
+    tags.extend_from_slice(&(vendor.len() as u32).to_le_bytes());
+    tags.extend_from_slice(vendor);
+    tags.extend_from_slice(&0u32.to_le_bytes());
+    tags
+}
+
+/// Builds the Ogg pages of a single logical bitstream.
+struct OggPager {
+    serial: u32,
+    sequence: u32,
+}
+
+impl OggPager {
+    fn new(serial: u32) -> Self {
+        Self {
+            serial,
+            sequence: 0,
+        }
+    }
+
+    /// Builds a page holding complete packets (at most 255 lacing values).
+    fn page(&mut self, granule: u64, flags: u8, packets: &[&[u8]]) -> Vec<u8> {
+        let mut lacing = Vec::new();
+        for packet in packets {
+            lacing.extend(std::iter::repeat(255u8).take(packet.len() / 255));
+            lacing.push((packet.len() % 255) as u8);
+        }
+        debug_assert!(lacing.len() <= 255, "too many packets for one Ogg page");
+
+        let body_len: usize = packets.iter().map(|p| p.len()).sum();
+        let mut page = Vec::with_capacity(27 + lacing.len() + body_len);
+        page.extend_from_slice(b"OggS");
+        page.push(0); // version
+        page.push(flags);
+        page.extend_from_slice(&granule.to_le_bytes());
+        page.extend_from_slice(&self.serial.to_le_bytes());
+        page.extend_from_slice(&self.sequence.to_le_bytes());
+        page.extend_from_slice(&[0; 4]); // CRC, computed below
+        page.push(lacing.len() as u8);
+        page.extend_from_slice(&lacing);
+        for packet in packets {
+            page.extend_from_slice(packet);
+        }
+        self.sequence += 1;
+
+        let checksum = crc::vorbis_crc32_update(0, &page);
+        page[22..26].copy_from_slice(&checksum.to_le_bytes());
+        page
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn page_laces_packets_and_checksums() {
+        let mut pager = OggPager::new(7);
+        let long = vec![1u8; 300];
+        let page = pager.page(960, OGG_FLAG_EOS, &[&long, b"ab"]);
+
+        assert_eq!(&page[0..4], b"OggS");
+        assert_eq!(page[5], OGG_FLAG_EOS);
+        assert_eq!(u64::from_le_bytes(page[6..14].try_into().unwrap()), 960);
+        assert_eq!(u32::from_le_bytes(page[14..18].try_into().unwrap()), 7);
+        assert_eq!(page[26], 3);
+        assert_eq!(&page[27..30], &[255, 45, 2]);
+        assert_eq!(page.len(), 30 + 302);
+
+        let mut zeroed = page.clone();
+        zeroed[22..26].fill(0);
+        let expected = crc::vorbis_crc32_update(0, &zeroed);
+        assert_eq!(
+            u32::from_le_bytes(page[22..26].try_into().unwrap()),
+            expected
+        );
+
+        let next = pager.page(0, 0, &[]);
+        assert_eq!(u32::from_le_bytes(next[18..22].try_into().unwrap()), 1);
+    }
+}
@@ -34,6 +34,26 @@ pub enum AudioCodec {
     Aac,
 }
 
+impl AudioCodec {
+    /// Short display name (e.g. `FLAC`, `Vorbis`), as shown by control points.
+    pub fn name(self) -> &'static str {
+        match self {
+            AudioCodec::Flac => "FLAC",
+            AudioCodec::Mp3 => "MP3",
+            AudioCodec::OggVorbis => "Vorbis",
+            AudioCodec::OggOpus => "Opus",
+            AudioCodec::Wav => "WAV",
+            AudioCodec::Aiff => "AIFF",
+            AudioCodec::Aac => "AAC",
+        }
+    }
+
+    /// Whether the codec preserves the original PCM samples.
+    pub fn is_lossless(self) -> bool {
+        matches!(self, AudioCodec::Flac | AudioCodec::Wav | AudioCodec::Aiff)
+    }
+}
+
 /// Options controlling how the transcoder operates.
 #[derive(Debug, Clone)]
 pub struct TranscodeOptions {
@@ -0,0 +1,45 @@
+use tokio::io::AsyncReadExt;
+
+use pmoflac::{decode_ogg_opus_stream, encode_ogg_opus_stream, OpusEncoderOptions, PcmFormat};
+
+#[tokio::test]
+async fn encoded_opus_decodes_back_to_pcm() -> Result<(), Box<dyn std::error::Error>> {
+    // One second of a 440 Hz stereo tone at 48 kHz.
+    let mut pcm = Vec::with_capacity(48_000 * 4);
+    for n in 0..48_000 {
+        let t = n as f32 / 48_000.0;
+        let sample = ((t * 440.0 * std::f32::consts::TAU).sin() * 8_000.0) as i16;
+        pcm.extend_from_slice(&sample.to_le_bytes());
+        pcm.extend_from_slice(&sample.to_le_bytes());
+    }
+
+    let format = PcmFormat {
+        sample_rate: 48_000,
+        channels: 2,
+        bits_per_sample: 16,
+    };
+    let options = OpusEncoderOptions { bitrate: 32_000 };
+    let mut encoded_stream =
+        encode_ogg_opus_stream(std::io::Cursor::new(pcm), format, options).await?;
+    let mut encoded = Vec::new();
+    encoded_stream.read_to_end(&mut encoded).await?;
+    encoded_stream.wait().await?;
+
+    assert!(encoded.starts_with(b"OggS"));
+    // 32 kbit/s for one second, plus container overhead.
+    assert!(encoded.len() < 8_000, "unexpected size {}", encoded.len());
+
+    let mut decoded_stream = decode_ogg_opus_stream(std::io::Cursor::new(encoded)).await?;
+    assert_eq!(decoded_stream.info().channels, 2);
+    let mut decoded = Vec::new();
+    decoded_stream.read_to_end(&mut decoded).await?;
+    decoded_stream.wait().await?;
+
+    let frames = decoded.len() / 4;
+    assert!(
+        frames > 47_000 && frames <= 48_000,
+        "decoded {} frames",
+        frames
+    );
+    Ok(())
+}
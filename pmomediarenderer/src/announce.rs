@@ -0,0 +1,119 @@
+//! Annonces : clips préenregistrés et synthèse vocale
+//!
+//! Une annonce est mixée par l'étage `announce` de la chaîne de traitement,
+//! un [`pmoaudio::MixerNode`] alimenté par une [`pmoaudio::AnnounceSource`] :
+//! la musique est abaissée, le clip joué, puis la musique remonte.
+//!
+//! ```yaml
+//! host:
+//!   renderer:
+//!     announce:
+//!       directory: announcements     # clips WAV (doorbell.wav…)
+//!       duck_db: -18
+//!       ramp_ms: 300
+//!       tts_command: [piper, --model, fr_FR-siwis-medium.onnx, --output_file, "-"]
+//! ```
+//!
+//! La commande de synthèse reçoit le texte sur son entrée standard et doit
+//! écrire un fichier WAV sur sa sortie standard. Elle est lancée sans shell.
+
+use std::path::PathBuf;
+use std::process::Stdio;
+use std::time::Duration;
+
+use pmoaudio::AnnounceClip;
+use tokio::io::AsyncWriteExt;
+use tokio::process::Command;
+use tracing::debug;
+
+use crate::config_ext::MediaRendererConfigExt;
+use crate::error::MediaRendererError;
+
+/// Longueur maximale d'un texte à synthétiser (caractères)
+pub const MAX_TEXT_LENGTH: usize = 1_000;
+
+/// Délai maximal de la synthèse vocale
+const TTS_TIMEOUT: Duration = Duration::from_secs(30);
+
+/// Charge un clip préenregistré du répertoire des annonces
+///
+/// `name` est un nom de fichier simple ; l'extension `.wav` est facultative.
+pub fn load_clip(name: &str) -> Result<AnnounceClip, MediaRendererError> {
+    let name = name.trim();
+    if name.is_empty() || name.starts_with('.') || name.contains(['/', '\\']) || name.contains("..")
+    {
+        return Err(MediaRendererError::InvalidArgument(format!(
+            "invalid announcement clip name '{}'",
+            name
+        )));
+    }
+    let file = if name.contains('.') {
+        name.to_string()
+    } else {
+        format!("{}.wav", name)
+    };
+
+    let directory = pmoconfig::get_config()
+        .get_announce_directory()
+        .map_err(|e| MediaRendererError::AnnouncementError(e.to_string()))?;
+    let path = PathBuf::from(directory).join(file);
+    if !path.is_file() {
+        return Err(MediaRendererError::InvalidArgument(format!(
+            "unknown announcement clip '{}'",
+            name
+        )));
+    }
+    AnnounceClip::load_wav(&path).map_err(|e| MediaRendererError::AnnouncementError(e.to_string()))
+}
+
+/// Synthétise un texte avec la commande configurée
+pub async fn synthesize(text: &str) -> Result<AnnounceClip, MediaRendererError> {
+    let text = text.trim();
+    if text.is_empty() || text.chars().count() > MAX_TEXT_LENGTH {
+        return Err(MediaRendererError::InvalidArgument(format!(
+            "announcement text must be 1 to {} characters",
+            MAX_TEXT_LENGTH
+        )));
+    }
+    let command = pmoconfig::get_config()
+        .get_announce_tts_command()
+        .map_err(|e| MediaRendererError::AnnouncementError(e.to_string()))?
+        .ok_or_else(|| {
+            MediaRendererError::AnnouncementError("no text-to-speech command configured".into())
+        })?;
+
+    debug!("🗣️ Synthesizing announcement with {}", command[0]);
+    let mut child = Command::new(&command[0])
+        .args(&command[1..])
+        .stdin(Stdio::piped())
+        .stdout(Stdio::piped())
+        .stderr(Stdio::piped())
+        .kill_on_drop(true)
+        .spawn()
+        .map_err(|e| {
+            MediaRendererError::AnnouncementError(format!("cannot run {}: {}", command[0], e))
+        })?;
+
+    let mut stdin = child.stdin.take().expect("TTS stdin is piped");
+    stdin
+        .write_all(text.as_bytes())
+        .await
+        .map_err(|e| MediaRendererError::AnnouncementError(e.to_string()))?;
+    drop(stdin);
+
+    let output = tokio::time::timeout(TTS_TIMEOUT, child.wait_with_output())
+        .await
+        .map_err(|_| MediaRendererError::AnnouncementError(format!("{} timed out", command[0])))?
+        .map_err(|e| MediaRendererError::AnnouncementError(e.to_string()))?;
+    if !output.status.success() {
+        return Err(MediaRendererError::AnnouncementError(format!(
+            "{} failed ({}): {}",
+            command[0],
+            output.status,
+            String::from_utf8_lossy(&output.stderr).trim()
+        )));
+    }
+
+    AnnounceClip::from_wav(&output.stdout)
+        .map_err(|e| MediaRendererError::AnnouncementError(e.to_string()))
+}
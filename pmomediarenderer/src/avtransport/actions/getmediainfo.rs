@@ -1,6 +1,6 @@
 use crate::avtransport::variables::{
     AVTRANSPORTNEXTURI, AVTRANSPORTNEXTURIMETADATA, AVTRANSPORTURI, AVTRANSPORTURIMETADATA,
-    A_ARG_TYPE_INSTANCE_ID, CURRENTTRACK, NUMBEROFTRACKS,
+    A_ARG_TYPE_INSTANCE_ID, CURRENTMEDIADURATION, CURRENTTRACK, NUMBEROFTRACKS,
 };
 use pmoupnp::define_action;
 
@@ -8,6 +8,7 @@ define_action! {
     pub static GETMEDIAINFO = "GetMediaInfo" {
         in "InstanceID" => A_ARG_TYPE_INSTANCE_ID,
         out "NrTracks" => NUMBEROFTRACKS,
+        out "MediaDuration" => CURRENTMEDIADURATION,
         out "CurrentTrack" => CURRENTTRACK,
         out "CurrentURI" => AVTRANSPORTURI,
         out "CurrentURIMetaData" => AVTRANSPORTURIMETADATA,
@@ -11,6 +11,7 @@ mod previous;
 mod seek;
 mod setavtransportnexturi;
 mod setavtransporturi;
+mod setplaymode;
 mod stop;
 
 pub use getcurrenttransportactions::GETCURRENTTRANSPORTACTIONS;
@@ -26,4 +27,5 @@ pub use previous::PREVIOUS;
 pub use seek::SEEK;
 pub use setavtransportnexturi::SETNEXTAVTRANSPORTURI;
 pub use setavtransporturi::SETAVTRANSPORTURI;
+pub use setplaymode::SETPLAYMODE;
 pub use stop::STOP;
@@ -0,0 +1,9 @@
+use crate::avtransport::variables::{A_ARG_TYPE_INSTANCE_ID, CURRENTPLAYMODE};
+use pmoupnp::define_action;
+
+define_action! {
+    pub static SETPLAYMODE = "SetPlayMode" {
+        in "InstanceID" => A_ARG_TYPE_INSTANCE_ID,
+        in "NewPlayMode" => CURRENTPLAYMODE,
+    }
+}
@@ -30,6 +30,8 @@
 //! Et certaines actions optionnelles :
 //! - ✅ SetNextAVTransportURI
 //! - ✅ Seek, Next, Previous
+//! - ✅ SetPlayMode (NORMAL, REPEAT_ONE, REPEAT_ALL, SHUFFLE, SHUFFLE_NOREPEAT,
+//!   RANDOM et X_PMO_SHUFFLE_ALBUMS)
 //!
 //! ## Variables d'état
 //!
@@ -58,7 +60,8 @@
 //! - [`AVTRANSPORTNEXTURIMETADATA`] : Métadonnées de la ressource suivante
 //!
 //! ### Modes et capacités
-//! - [`CURRENTPLAYMODE`] : Mode de lecture (NORMAL, SHUFFLE, REPEAT_ONE, etc.)
+//! - [`CURRENTPLAYMODE`] : Mode de lecture (NORMAL, SHUFFLE, REPEAT_ONE, etc.),
+//!   plus `SHUFFLE_NOREPEAT` et `X_PMO_SHUFFLE_ALBUMS` (albums dans un ordre aléatoire)
 //! - [`PLAYBACKSTORAGEMEDIUM`] : Support de lecture (NETWORK, HDD, CD-DA, etc.)
 //! - [`POSSIBLEPLAYBACKSTORAGEMEDIA`] : Supports de lecture possibles
 //!
@@ -97,7 +100,7 @@ pub mod variables;
 use actions::{
     GETCURRENTTRANSPORTACTIONS, GETDEVICECAPABILITIES, GETMEDIAINFO, GETPOSITIONINFO,
     GETTRANSPORTINFO, GETTRANSPORTSETTINGS, NEXT, PAUSE, PLAY, PREVIOUS, SEEK, SETAVTRANSPORTURI,
-    SETNEXTAVTRANSPORTURI, STOP,
+    SETNEXTAVTRANSPORTURI, SETPLAYMODE, STOP,
 };
 use variables::{
     ABSOLUTETIMEPOSITION, AVTRANSPORTNEXTURI, AVTRANSPORTNEXTURIMETADATA, AVTRANSPORTURI,
@@ -150,6 +153,7 @@ define_service! {
             SEEK,
             SETNEXTAVTRANSPORTURI,
             SETAVTRANSPORTURI,
+            SETPLAYMODE,
             STOP,
         ]
     }
@@ -2,7 +2,7 @@ use pmoupnp::define_variable;
 
 define_variable! {
     pub static CURRENTPLAYMODE: String = "CurrentPlayMode" {
-        allowed: ["NORMAL", "SHUFFLE", "REPEAT_ONE", "REPEAT_ALL", "RANDOM", "DIRECT_1", "INTRO"],
+        allowed: ["NORMAL", "SHUFFLE", "REPEAT_ONE", "REPEAT_ALL", "RANDOM", "DIRECT_1", "INTRO", "SHUFFLE_NOREPEAT", "X_PMO_SHUFFLE_ALBUMS"],
         default: "NORMAL",
     }
 }
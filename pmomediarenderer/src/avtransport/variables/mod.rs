@@ -17,6 +17,8 @@ mod trackduration;
 mod transportplayspeed;
 mod transportstate;
 mod transportstatus;
+mod x_pmo_queue;
+mod x_pmo_session;
 
 pub use a_arg_type_instanceid::A_ARG_TYPE_INSTANCE_ID;
 pub use a_arg_type_playspeed::A_ARG_TYPE_PLAY_SPEED;
@@ -42,3 +44,8 @@ pub use trackduration::RELATIVETIMEPOSITION;
 pub use transportplayspeed::TRANSPORTPLAYSPEED;
 pub use transportstate::TRANSPORTSTATE;
 pub use transportstatus::TRANSPORTSTATUS;
+pub use x_pmo_queue::{
+    A_ARG_TYPE_X_PMO_QUEUE_INDEX, A_ARG_TYPE_X_PMO_QUEUE_ITEMS, A_ARG_TYPE_X_PMO_QUEUE_POSITION,
+    X_PMO_QUEUE_LENGTH,
+};
+pub use x_pmo_session::{A_ARG_TYPE_X_PMO_EXCLUSIVE, A_ARG_TYPE_X_PMO_SESSION_OWNER};
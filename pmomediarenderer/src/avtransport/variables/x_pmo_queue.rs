@@ -0,0 +1,21 @@
+use pmoupnp::define_variable;
+
+// Variables du vendor extension X_PMO_Queue* (gestion de la file interne)
+
+define_variable! {
+    pub static A_ARG_TYPE_X_PMO_QUEUE_POSITION: UI4 = "A_ARG_TYPE_X_PMO_QueuePosition"
+}
+
+define_variable! {
+    pub static A_ARG_TYPE_X_PMO_QUEUE_INDEX: I4 = "A_ARG_TYPE_X_PMO_QueueIndex"
+}
+
+define_variable! {
+    pub static A_ARG_TYPE_X_PMO_QUEUE_ITEMS: String = "A_ARG_TYPE_X_PMO_QueueItems"
+}
+
+define_variable! {
+    pub static X_PMO_QUEUE_LENGTH: UI4 = "X_PMO_QueueLength" {
+        evented: true,
+    }
+}
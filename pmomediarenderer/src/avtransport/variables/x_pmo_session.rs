@@ -0,0 +1,12 @@
+use pmoupnp::define_variable;
+
+// Variables du vendor extension X_PMO_TakeOver / X_PMO_GetSession
+// (session de contrôle du transport)
+
+define_variable! {
+    pub static A_ARG_TYPE_X_PMO_SESSION_OWNER: String = "A_ARG_TYPE_X_PMO_SessionOwner"
+}
+
+define_variable! {
+    pub static A_ARG_TYPE_X_PMO_EXCLUSIVE: Boolean = "A_ARG_TYPE_X_PMO_Exclusive"
+}
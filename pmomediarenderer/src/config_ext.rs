@@ -0,0 +1,520 @@
+//! Extension pour intégrer la configuration du MediaRenderer dans pmoconfig
+//!
+//! Ce module fournit le trait `MediaRendererConfigExt` qui permet d'ajouter
+//! les paramètres audio des renderers à pmoconfig::Config.
+
+use anyhow::Result;
+use pmoaudio::nodes::announce_source::{DEFAULT_DUCK_DB, DEFAULT_DUCK_RAMP_MS};
+use pmoaudio::nodes::volume_ramp_node::{
+    DEFAULT_VOLUME_RAMP_MS, MAX_VOLUME_RAMP_MS, MIN_VOLUME_RAMP_MS,
+};
+use pmoaudio::CrossfeedParams;
+use crate::dsp_chain::{default_chain, parse_chain, StageSpec};
+use crate::icecast::{IcecastSettings, DEFAULT_ICECAST_USER};
+use pmoconfig::Config;
+use std::path::PathBuf;
+use std::time::Duration;
+use serde_yaml::{Mapping, Number, Value};
+
+/// Délai d'inactivité par défaut avant destruction du graphe audio
+pub const DEFAULT_IDLE_TEARDOWN: Duration = Duration::from_secs(10 * 60);
+/// Durée maximale des fondus de transition (ms)
+pub const MAX_TRANSITION_MS: u32 = 2_000;
+
+/// Type de sortie d'un renderer
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum OutputProfile {
+    /// Enceintes (défaut)
+    #[default]
+    Speakers,
+    /// Casque : active l'étage de crossfeed
+    Headphones,
+}
+
+impl OutputProfile {
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            OutputProfile::Speakers => "speakers",
+            OutputProfile::Headphones => "headphones",
+        }
+    }
+
+    pub fn parse(s: &str) -> Option<Self> {
+        match s.trim().to_lowercase().as_str() {
+            "speakers" | "speaker" => Some(OutputProfile::Speakers),
+            "headphones" | "headphone" => Some(OutputProfile::Headphones),
+            _ => None,
+        }
+    }
+}
+
+/// Trait d'extension pour la configuration des MediaRenderer
+///
+/// # Exemple
+///
+/// ```rust,ignore
+/// use pmoconfig::get_config;
+/// use pmomediarenderer::MediaRendererConfigExt;
+///
+/// let config = get_config();
+/// let ramp_ms = config.get_volume_ramp_ms()?;
+/// ```
+pub trait MediaRendererConfigExt {
+    /// Récupère la durée de la rampe de volume
+    ///
+    /// # Returns
+    ///
+    /// La durée en millisecondes, bornée à 50–200 ms (défaut: 100)
+    fn get_volume_ramp_ms(&self) -> Result<u32>;
+
+    /// Définit la durée de la rampe de volume (bornée à 50–200 ms)
+    fn set_volume_ramp_ms(&self, ramp_ms: u32) -> Result<()>;
+
+    /// Récupère la balance enregistrée pour un renderer
+    ///
+    /// # Returns
+    ///
+    /// La balance de -100 (gauche) à +100 (droite) (défaut: 0)
+    fn get_renderer_balance(&self, udn: &str) -> Result<i16>;
+
+    /// Enregistre la balance d'un renderer
+    fn set_renderer_balance(&self, udn: &str, balance: i16) -> Result<()>;
+
+    /// Récupère l'inversion des canaux enregistrée pour un renderer (défaut: false)
+    fn get_renderer_channel_swap(&self, udn: &str) -> Result<bool>;
+
+    /// Enregistre l'inversion des canaux d'un renderer
+    fn set_renderer_channel_swap(&self, udn: &str, swap: bool) -> Result<()>;
+
+    /// Récupère le mode mono enregistré pour un renderer (défaut: false)
+    fn get_renderer_mono(&self, udn: &str) -> Result<bool>;
+
+    /// Enregistre le mode mono d'un renderer
+    fn set_renderer_mono(&self, udn: &str, mono: bool) -> Result<()>;
+
+    /// Récupère le type de sortie d'un renderer (défaut: enceintes)
+    fn get_renderer_output_profile(&self, udn: &str) -> Result<OutputProfile>;
+
+    /// Enregistre le type de sortie d'un renderer
+    fn set_renderer_output_profile(&self, udn: &str, profile: OutputProfile) -> Result<()>;
+
+    /// Récupère les réglages de crossfeed d'un renderer
+    ///
+    /// # Returns
+    ///
+    /// `(enabled, params)` : le crossfeed est actif par défaut sur une sortie
+    /// casque, avec les paramètres bs2b par défaut (700 Hz, 4.5 dB)
+    fn get_renderer_crossfeed(&self, udn: &str) -> Result<(bool, CrossfeedParams)>;
+
+    /// Enregistre les réglages de crossfeed d'un renderer
+    fn set_renderer_crossfeed(
+        &self,
+        udn: &str,
+        enabled: bool,
+        params: CrossfeedParams,
+    ) -> Result<()>;
+
+    /// Récupère le chemin de la réponse impulsionnelle (WAV) de correction
+    /// de pièce d'un renderer (défaut: aucune)
+    fn get_renderer_impulse_response(&self, udn: &str) -> Result<Option<PathBuf>>;
+
+    /// Enregistre (ou efface) la réponse impulsionnelle d'un renderer
+    fn set_renderer_impulse_response(&self, udn: &str, path: Option<PathBuf>) -> Result<()>;
+
+    /// Récupère le répertoire des enregistrements (créé si nécessaire)
+    fn get_recorder_directory(&self) -> Result<String>;
+
+    /// Récupère la durée maximale d'un fichier enregistré (minutes, 0 = illimitée)
+    fn get_recorder_max_minutes(&self) -> Result<u32>;
+
+    /// Récupère la taille maximale d'un fichier enregistré (Mo, 0 = illimitée)
+    fn get_recorder_max_megabytes(&self) -> Result<u32>;
+
+    /// Enregistrement actif pour un renderer ? (défaut: false)
+    fn get_renderer_recording(&self, udn: &str) -> Result<bool>;
+
+    /// Active ou désactive l'enregistrement d'un renderer
+    fn set_renderer_recording(&self, udn: &str, recording: bool) -> Result<()>;
+
+    /// Silence de maintien à l'arrêt et en pause pour un renderer ?
+    /// (`host.renderer.devices.<udn>.keepalive`, défaut: false)
+    fn get_renderer_keepalive(&self, udn: &str) -> Result<bool>;
+
+    /// Active ou désactive le silence de maintien d'un renderer
+    fn set_renderer_keepalive(&self, udn: &str, keepalive: bool) -> Result<()>;
+
+    /// Mode bit-perfect pour un renderer ?
+    /// (`host.renderer.devices.<udn>.bit_perfect`, défaut: false)
+    fn get_renderer_bit_perfect(&self, udn: &str) -> Result<bool>;
+
+    /// Active ou désactive le mode bit-perfect d'un renderer
+    fn set_renderer_bit_perfect(&self, udn: &str, bit_perfect: bool) -> Result<()>;
+
+    /// Récupère la diffusion Icecast d'un renderer
+    ///
+    /// # Returns
+    ///
+    /// `None` si la section `icecast` est absente, désactivée ou invalide
+    fn get_renderer_icecast(&self, udn: &str) -> Result<Option<IcecastSettings>>;
+
+    /// Récupère la chaîne de traitement audio d'un renderer
+    ///
+    /// Cherche `host.renderer.devices.<udn>.pipeline`, puis
+    /// `host.renderer.pipeline`, puis la chaîne par défaut.
+    ///
+    /// # Errors
+    ///
+    /// Retourne une erreur si la chaîne déclarée est invalide
+    /// (étage inconnu, paramètre invalide, étage répété)
+    fn get_renderer_pipeline(&self, udn: &str) -> Result<Vec<StageSpec>>;
+
+    /// Répertoire des clips d'annonce préenregistrés
+    /// (`host.renderer.announce.directory`, défaut: `announcements`)
+    fn get_announce_directory(&self) -> Result<String>;
+
+    /// Atténuation de la musique pendant une annonce
+    /// (`host.renderer.announce.duck_db`, défaut: -18 dB)
+    fn get_announce_duck_db(&self) -> Result<f32>;
+
+    /// Durée des rampes de ducking
+    /// (`host.renderer.announce.ramp_ms`, défaut: 300 ms)
+    fn get_announce_ramp_ms(&self) -> Result<u32>;
+
+    /// Commande de synthèse vocale (`host.renderer.announce.tts_command`)
+    ///
+    /// Le texte est passé sur l'entrée standard, la commande doit écrire un
+    /// fichier WAV sur sa sortie standard, par exemple
+    /// `[piper, --model, fr_FR-siwis-medium.onnx, --output_file, "-"]`.
+    ///
+    /// # Returns
+    ///
+    /// `None` si aucune commande n'est configurée
+    fn get_announce_tts_command(&self) -> Result<Option<Vec<String>>>;
+
+    /// Durée des fondus sur arrêt, pause et changement de piste
+    /// (`host.renderer.transition_ms`, défaut: 0, aucun fondu ; bornée à 2 s)
+    ///
+    /// Les fondus passent par le mixeur de l'étage `announce` : sans cet
+    /// étage dans la chaîne, la valeur est sans effet.
+    fn get_renderer_transition_ms(&self) -> Result<u32>;
+
+    /// Contrôle exclusif du transport ? (défaut: false)
+    ///
+    /// Si actif, les commandes de transport d'un autre point de contrôle que
+    /// le propriétaire de la session sont refusées (erreur UPnP 705) tant
+    /// que la lecture n'est pas arrêtée.
+    fn get_renderer_exclusive_control(&self) -> Result<bool>;
+
+    /// Délai d'inactivité avant destruction du graphe audio
+    /// (`host.renderer.idle_teardown`, défaut: 10 min ; 0 désactive)
+    ///
+    /// Voir [`crate::idle`] pour les conditions d'inactivité.
+    fn get_renderer_idle_teardown(&self) -> Result<Option<Duration>>;
+}
+
+impl MediaRendererConfigExt for Config {
+    fn get_volume_ramp_ms(&self) -> Result<u32> {
+        let ramp_ms = match self.get_value(&["host", "renderer", "volume_ramp_ms"]) {
+            Ok(Value::Number(n)) => n.as_u64().map(|v| v as u32),
+            Ok(Value::String(s)) => s.trim().parse::<u32>().ok(),
+            _ => None,
+        }
+        .unwrap_or(DEFAULT_VOLUME_RAMP_MS);
+        Ok(ramp_ms.clamp(MIN_VOLUME_RAMP_MS, MAX_VOLUME_RAMP_MS))
+    }
+
+    fn set_volume_ramp_ms(&self, ramp_ms: u32) -> Result<()> {
+        let ramp_ms = ramp_ms.clamp(MIN_VOLUME_RAMP_MS, MAX_VOLUME_RAMP_MS);
+        self.set_value(
+            &["host", "renderer", "volume_ramp_ms"],
+            Value::Number(Number::from(ramp_ms)),
+        )
+    }
+
+    fn get_renderer_balance(&self, udn: &str) -> Result<i16> {
+        let balance = match self.get_value(&["host", "renderer", "devices", udn, "balance"]) {
+            Ok(Value::Number(n)) => n.as_i64().unwrap_or(0),
+            Ok(Value::String(s)) => s.trim().parse::<i64>().unwrap_or(0),
+            _ => 0,
+        };
+        Ok(balance.clamp(-100, 100) as i16)
+    }
+
+    fn set_renderer_balance(&self, udn: &str, balance: i16) -> Result<()> {
+        self.set_value(
+            &["host", "renderer", "devices", udn, "balance"],
+            Value::Number(Number::from(balance.clamp(-100, 100))),
+        )
+    }
+
+    fn get_renderer_channel_swap(&self, udn: &str) -> Result<bool> {
+        device_flag(self, udn, "channel_swap")
+    }
+
+    fn set_renderer_channel_swap(&self, udn: &str, swap: bool) -> Result<()> {
+        self.set_value(
+            &["host", "renderer", "devices", udn, "channel_swap"],
+            Value::Bool(swap),
+        )
+    }
+
+    fn get_renderer_mono(&self, udn: &str) -> Result<bool> {
+        device_flag(self, udn, "mono")
+    }
+
+    fn set_renderer_mono(&self, udn: &str, mono: bool) -> Result<()> {
+        self.set_value(&["host", "renderer", "devices", udn, "mono"], Value::Bool(mono))
+    }
+
+    fn get_renderer_output_profile(&self, udn: &str) -> Result<OutputProfile> {
+        match self.get_value(&["host", "renderer", "devices", udn, "output"]) {
+            Ok(Value::String(s)) => Ok(OutputProfile::parse(&s).unwrap_or_default()),
+            _ => Ok(OutputProfile::default()),
+        }
+    }
+
+    fn set_renderer_output_profile(&self, udn: &str, profile: OutputProfile) -> Result<()> {
+        self.set_value(
+            &["host", "renderer", "devices", udn, "output"],
+            Value::String(profile.as_str().to_string()),
+        )
+    }
+
+    fn get_renderer_crossfeed(&self, udn: &str) -> Result<(bool, CrossfeedParams)> {
+        let map = match self.get_value(&["host", "renderer", "devices", udn, "crossfeed"]) {
+            Ok(Value::Mapping(map)) => map,
+            _ => return Ok((true, CrossfeedParams::DEFAULT)),
+        };
+
+        let enabled = map
+            .get(&Value::from("enabled"))
+            .and_then(Value::as_bool)
+            .unwrap_or(true);
+        let cutoff_hz = map
+            .get(&Value::from("cutoff_hz"))
+            .and_then(Value::as_u64)
+            .map(|v| v as u32)
+            .unwrap_or(CrossfeedParams::DEFAULT.cutoff_hz);
+        let feed_db = map
+            .get(&Value::from("feed_db"))
+            .and_then(Value::as_f64)
+            .map(|v| v as f32)
+            .unwrap_or(CrossfeedParams::DEFAULT.feed_db);
+
+        Ok((enabled, CrossfeedParams { cutoff_hz, feed_db }.clamped()))
+    }
+
+    fn set_renderer_crossfeed(
+        &self,
+        udn: &str,
+        enabled: bool,
+        params: CrossfeedParams,
+    ) -> Result<()> {
+        let params = params.clamped();
+        let mut map = Mapping::new();
+        map.insert(Value::from("enabled"), Value::Bool(enabled));
+        map.insert(
+            Value::from("cutoff_hz"),
+            Value::Number(Number::from(params.cutoff_hz)),
+        );
+        map.insert(
+            Value::from("feed_db"),
+            Value::Number(Number::from(params.feed_db as f64)),
+        );
+        self.set_value(
+            &["host", "renderer", "devices", udn, "crossfeed"],
+            Value::Mapping(map),
+        )
+    }
+
+    fn get_renderer_impulse_response(&self, udn: &str) -> Result<Option<PathBuf>> {
+        match self.get_value(&["host", "renderer", "devices", udn, "impulse_response"]) {
+            Ok(Value::String(s)) if !s.trim().is_empty() => Ok(Some(PathBuf::from(s.trim()))),
+            _ => Ok(None),
+        }
+    }
+
+    fn set_renderer_impulse_response(&self, udn: &str, path: Option<PathBuf>) -> Result<()> {
+        let value = match path {
+            Some(path) => Value::String(path.to_string_lossy().into_owned()),
+            None => Value::Null,
+        };
+        self.set_value(&["host", "renderer", "devices", udn, "impulse_response"], value)
+    }
+
+    fn get_recorder_directory(&self) -> Result<String> {
+        self.get_managed_dir(&["host", "renderer", "recorder", "directory"], "recordings")
+    }
+
+    fn get_recorder_max_minutes(&self) -> Result<u32> {
+        let minutes = self.get_uint(&["host", "renderer", "recorder", "max_minutes"], 60)?;
+        Ok(u32::try_from(minutes)?)
+    }
+
+    fn get_recorder_max_megabytes(&self) -> Result<u32> {
+        let megabytes = self.get_uint(&["host", "renderer", "recorder", "max_megabytes"], 0)?;
+        Ok(u32::try_from(megabytes)?)
+    }
+
+    fn get_renderer_recording(&self, udn: &str) -> Result<bool> {
+        device_flag(self, udn, "recording")
+    }
+
+    fn set_renderer_recording(&self, udn: &str, recording: bool) -> Result<()> {
+        self.set_value(
+            &["host", "renderer", "devices", udn, "recording"],
+            Value::Bool(recording),
+        )
+    }
+
+    fn get_renderer_keepalive(&self, udn: &str) -> Result<bool> {
+        device_flag(self, udn, "keepalive")
+    }
+
+    fn set_renderer_keepalive(&self, udn: &str, keepalive: bool) -> Result<()> {
+        self.set_value(
+            &["host", "renderer", "devices", udn, "keepalive"],
+            Value::Bool(keepalive),
+        )
+    }
+
+    fn get_renderer_bit_perfect(&self, udn: &str) -> Result<bool> {
+        device_flag(self, udn, "bit_perfect")
+    }
+
+    fn set_renderer_bit_perfect(&self, udn: &str, bit_perfect: bool) -> Result<()> {
+        self.set_value(
+            &["host", "renderer", "devices", udn, "bit_perfect"],
+            Value::Bool(bit_perfect),
+        )
+    }
+
+    fn get_renderer_icecast(&self, udn: &str) -> Result<Option<IcecastSettings>> {
+        let map = match self.get_value(&["host", "renderer", "devices", udn, "icecast"]) {
+            Ok(Value::Mapping(map)) => map,
+            _ => return Ok(None),
+        };
+        let text = |key: &str| {
+            map.get(&Value::from(key))
+                .and_then(Value::as_str)
+                .map(str::to_string)
+        };
+        let flag = |key: &str, default: bool| {
+            map.get(&Value::from(key))
+                .and_then(Value::as_bool)
+                .unwrap_or(default)
+        };
+
+        if !flag("enabled", true) {
+            return Ok(None);
+        }
+        let Some(url) = text("url") else {
+            return Ok(None);
+        };
+        let Some(mut settings) = IcecastSettings::from_url(&url, text("password").unwrap_or_default())
+        else {
+            tracing::warn!(udn, "Invalid Icecast URL: {}", url);
+            return Ok(None);
+        };
+        settings.user = text("user").unwrap_or_else(|| DEFAULT_ICECAST_USER.to_string());
+        settings.name = text("name");
+        settings.description = text("description");
+        settings.public = flag("public", false);
+        Ok(Some(settings))
+    }
+
+    fn get_renderer_pipeline(&self, udn: &str) -> Result<Vec<StageSpec>> {
+        let declared = [
+            self.get_value(&["host", "renderer", "devices", udn, "pipeline"]),
+            self.get_value(&["host", "renderer", "pipeline"]),
+        ];
+        for value in declared.into_iter().flatten() {
+            let items: Vec<String> = match value {
+                Value::Sequence(items) => items
+                    .iter()
+                    .map(|item| match item {
+                        Value::String(s) => s.clone(),
+                        other => serde_yaml::to_string(other)
+                            .unwrap_or_default()
+                            .trim()
+                            .to_string(),
+                    })
+                    .collect(),
+                Value::String(s) => s.split(',').map(str::to_string).collect(),
+                _ => continue,
+            };
+            return Ok(parse_chain(&items)?);
+        }
+        Ok(default_chain())
+    }
+
+    fn get_announce_directory(&self) -> Result<String> {
+        self.get_managed_dir(
+            &["host", "renderer", "announce", "directory"],
+            "announcements",
+        )
+    }
+
+    fn get_announce_duck_db(&self) -> Result<f32> {
+        let duck_db = match self.get_value(&["host", "renderer", "announce", "duck_db"]) {
+            Ok(Value::Number(n)) => n.as_f64().map_or(DEFAULT_DUCK_DB, |v| v as f32),
+            Ok(Value::String(s)) => s.trim().parse().unwrap_or(DEFAULT_DUCK_DB),
+            _ => DEFAULT_DUCK_DB,
+        };
+        Ok(duck_db)
+    }
+
+    fn get_announce_ramp_ms(&self) -> Result<u32> {
+        let ramp_ms = self.get_uint(
+            &["host", "renderer", "announce", "ramp_ms"],
+            u64::from(DEFAULT_DUCK_RAMP_MS),
+        )?;
+        Ok(u32::try_from(ramp_ms)?)
+    }
+
+    fn get_announce_tts_command(&self) -> Result<Option<Vec<String>>> {
+        let value = self.get_value(&["host", "renderer", "announce", "tts_command"]);
+        let command: Vec<String> = match value {
+            Ok(Value::Sequence(items)) => items
+                .iter()
+                .filter_map(|item| match item {
+                    Value::String(s) => Some(s.clone()),
+                    Value::Number(n) => Some(n.to_string()),
+                    _ => None,
+                })
+                .collect(),
+            Ok(Value::String(s)) => s.split_whitespace().map(str::to_string).collect(),
+            _ => vec![],
+        };
+        Ok((!command.is_empty()).then_some(command))
+    }
+
+    fn get_renderer_transition_ms(&self) -> Result<u32> {
+        let transition_ms = self.get_uint(&["host", "renderer", "transition_ms"], 0)?;
+        Ok(transition_ms.min(u64::from(MAX_TRANSITION_MS)) as u32)
+    }
+
+    fn get_renderer_exclusive_control(&self) -> Result<bool> {
+        match self.get_value(&["host", "renderer", "exclusive_control"]) {
+            Ok(Value::Bool(b)) => Ok(b),
+            Ok(Value::String(s)) => Ok(matches!(
+                s.trim().to_lowercase().as_str(),
+                "true" | "1" | "yes" | "on"
+            )),
+            _ => Ok(false),
+        }
+    }
+
+    fn get_renderer_idle_teardown(&self) -> Result<Option<Duration>> {
+        let timeout = self.get_duration(
+            &["host", "renderer", "idle_teardown"],
+            DEFAULT_IDLE_TEARDOWN,
+        )?;
+        Ok((!timeout.is_zero()).then_some(timeout))
+    }
+}
+
+/// Booléen par renderer `host.renderer.devices.<udn>.<key>` (défaut: false)
+fn device_flag(config: &Config, udn: &str, key: &str) -> Result<bool> {
+    config.get_bool(&["host", "renderer", "devices", udn, key], false)
+}
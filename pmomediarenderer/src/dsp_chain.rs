@@ -0,0 +1,248 @@
+//! Description de la chaîne de traitement audio d'un renderer
+//!
+//! La chaîne est déclarée dans la configuration comme une liste d'étages,
+//! par renderer ou globalement :
+//!
+//! ```yaml
+//! host:
+//!   renderer:
+//!     pipeline: [resample:96000, convolution, channels, crossfeed, announce, volume]
+//!     devices:
+//!       uuid:...:
+//!         pipeline: [resample:48000, channels, volume, recorder, analysis]
+//! ```
+//!
+//! Chaque entrée est `nom` ou `nom:paramètre`. `resample:family:<Hz>`
+//! rééchantillonne vers le multiple de la fréquence de la piste le plus
+//! proche de `<Hz>` (44,1 → 88,2 kHz, 48 → 96 kHz pour `family:96000`)
+//! plutôt que vers une fréquence fixe. La liste est validée au
+//! démarrage de l'instance : un nom inconnu, un paramètre invalide ou un
+//! étage répété fait retomber sur la chaîne par défaut avec une erreur
+//! dans les logs.
+//!
+//! En mode bit-perfect, seuls les étages qui se contentent de lire le flux
+//! (`recorder`, `analysis`) sont conservés : voir [`bit_perfect_chain`].
+
+use std::fmt;
+use std::str::FromStr;
+
+use thiserror::Error;
+
+/// Chaîne utilisée quand la configuration n'en déclare pas
+pub const DEFAULT_CHAIN: &[&str] = &[
+    "resample:96000",
+    "convolution",
+    "channels",
+    "crossfeed",
+    "announce",
+    "volume",
+    "recorder",
+    "analysis",
+];
+
+/// Plage acceptée pour `resample:<Hz>`
+const RESAMPLE_RANGE: std::ops::RangeInclusive<u32> = 8_000..=768_000;
+
+/// Étage de la chaîne de traitement
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum StageSpec {
+    /// Rééchantillonnage vers une fréquence fixe
+    Resample(u32),
+    /// Rééchantillonnage dans la famille (44,1 ou 48 kHz) de chaque piste,
+    /// vers la fréquence la plus proche de celle indiquée
+    ResampleFamily(u32),
+    /// Correction de pièce par convolution
+    Convolution,
+    /// Balance, inversion des canaux, mono
+    Channels,
+    /// Crossfeed (sorties casque)
+    Crossfeed,
+    /// Mixeur : annonces par-dessus la musique (ducking), fondus de transition
+    Announce,
+    /// Volume logiciel avec rampe
+    Volume,
+    /// Enregistrement FLAC
+    Recorder,
+    /// Niveaux et spectre pour l'interface
+    Analysis,
+}
+
+#[derive(Debug, Error, PartialEq, Eq)]
+pub enum ChainError {
+    #[error("unknown pipeline stage '{0}'")]
+    UnknownStage(String),
+
+    #[error("invalid parameter '{param}' for pipeline stage '{stage}'")]
+    InvalidParameter { stage: String, param: String },
+
+    #[error("pipeline stage '{0}' takes no parameter")]
+    UnexpectedParameter(String),
+
+    #[error("pipeline stage '{0}' is declared more than once")]
+    DuplicateStage(String),
+}
+
+impl StageSpec {
+    /// Nom de l'étage dans la configuration
+    pub fn name(&self) -> &'static str {
+        match self {
+            StageSpec::Resample(_) | StageSpec::ResampleFamily(_) => "resample",
+            StageSpec::Convolution => "convolution",
+            StageSpec::Channels => "channels",
+            StageSpec::Crossfeed => "crossfeed",
+            StageSpec::Announce => "announce",
+            StageSpec::Volume => "volume",
+            StageSpec::Recorder => "recorder",
+            StageSpec::Analysis => "analysis",
+        }
+    }
+
+    /// L'étage modifie-t-il les échantillons ?
+    pub fn alters_signal(&self) -> bool {
+        !matches!(self, StageSpec::Recorder | StageSpec::Analysis)
+    }
+}
+
+impl fmt::Display for StageSpec {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            StageSpec::Resample(rate) => write!(f, "resample:{}", rate),
+            StageSpec::ResampleFamily(rate) => write!(f, "resample:family:{}", rate),
+            other => f.write_str(other.name()),
+        }
+    }
+}
+
+impl FromStr for StageSpec {
+    type Err = ChainError;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        let (name, param) = match s.trim().split_once(':') {
+            Some((name, param)) => (name.trim(), Some(param.trim())),
+            None => (s.trim(), None),
+        };
+        let name = name.to_lowercase();
+
+        let stage = match name.as_str() {
+            "resample" => {
+                let invalid = || ChainError::InvalidParameter {
+                    stage: name.clone(),
+                    param: param.unwrap_or_default().to_string(),
+                };
+                let param = param.ok_or_else(invalid)?;
+                let (family, rate) = match param.split_once(':') {
+                    Some((mode, rate)) if mode.trim().eq_ignore_ascii_case("family") => {
+                        (true, rate.trim())
+                    }
+                    _ => (false, param),
+                };
+                let rate: u32 = rate.parse().map_err(|_| invalid())?;
+                if !RESAMPLE_RANGE.contains(&rate) {
+                    return Err(invalid());
+                }
+                return Ok(if family {
+                    StageSpec::ResampleFamily(rate)
+                } else {
+                    StageSpec::Resample(rate)
+                });
+            }
+            "convolution" => StageSpec::Convolution,
+            "channels" => StageSpec::Channels,
+            "crossfeed" => StageSpec::Crossfeed,
+            "announce" => StageSpec::Announce,
+            "volume" => StageSpec::Volume,
+            "recorder" => StageSpec::Recorder,
+            "analysis" => StageSpec::Analysis,
+            _ => return Err(ChainError::UnknownStage(s.trim().to_string())),
+        };
+
+        if param.is_some() {
+            return Err(ChainError::UnexpectedParameter(name));
+        }
+        Ok(stage)
+    }
+}
+
+/// Analyse et valide une chaîne déclarée
+pub fn parse_chain<S: AsRef<str>>(items: &[S]) -> Result<Vec<StageSpec>, ChainError> {
+    let mut chain: Vec<StageSpec> = Vec::with_capacity(items.len());
+    for item in items {
+        let stage: StageSpec = item.as_ref().parse()?;
+        if chain.iter().any(|s| s.name() == stage.name()) {
+            return Err(ChainError::DuplicateStage(stage.name().to_string()));
+        }
+        chain.push(stage);
+    }
+    Ok(chain)
+}
+
+/// Chaîne par défaut
+pub fn default_chain() -> Vec<StageSpec> {
+    parse_chain(DEFAULT_CHAIN).expect("default pipeline chain is valid")
+}
+
+/// Chaîne réduite du mode bit-perfect : sans rééchantillonnage, volume ni
+/// traitement, le flux décodé arrive tel quel à l'encodeur
+pub fn bit_perfect_chain(chain: &[StageSpec]) -> Vec<StageSpec> {
+    chain
+        .iter()
+        .copied()
+        .filter(|s| !s.alters_signal())
+        .collect()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_chain() {
+        let chain = parse_chain(&["resample:48000", "Channels", " volume "]).unwrap();
+        assert_eq!(
+            chain,
+            vec![StageSpec::Resample(48_000), StageSpec::Channels, StageSpec::Volume]
+        );
+        assert_eq!(default_chain().len(), DEFAULT_CHAIN.len());
+        assert_eq!(parse_chain(&["announce"]).unwrap(), vec![StageSpec::Announce]);
+        let family = parse_chain(&["resample:Family:96000"]).unwrap();
+        assert_eq!(family, vec![StageSpec::ResampleFamily(96_000)]);
+        assert_eq!(family[0].to_string(), "resample:family:96000");
+        assert!(family[0].alters_signal());
+        assert_eq!(
+            bit_perfect_chain(&default_chain()),
+            vec![StageSpec::Recorder, StageSpec::Analysis]
+        );
+    }
+
+    #[test]
+    fn test_parse_chain_errors() {
+        assert_eq!(
+            parse_chain(&["eq:living"]),
+            Err(ChainError::UnknownStage("eq:living".into()))
+        );
+        assert!(matches!(
+            parse_chain(&["resample"]),
+            Err(ChainError::InvalidParameter { .. })
+        ));
+        assert!(matches!(
+            parse_chain(&["resample:12"]),
+            Err(ChainError::InvalidParameter { .. })
+        ));
+        assert_eq!(
+            parse_chain(&["volume:3"]),
+            Err(ChainError::UnexpectedParameter("volume".into()))
+        );
+        assert_eq!(
+            parse_chain(&["volume", "volume"]),
+            Err(ChainError::DuplicateStage("volume".into()))
+        );
+        assert!(matches!(
+            parse_chain(&["resample:family"]),
+            Err(ChainError::InvalidParameter { .. })
+        ));
+        assert_eq!(
+            parse_chain(&["resample:48000", "resample:family:96000"]),
+            Err(ChainError::DuplicateStage("resample".into()))
+        );
+    }
+}
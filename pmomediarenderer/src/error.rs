@@ -15,4 +15,7 @@ pub enum MediaRendererError {
 
     #[error("Server not available")]
     ServerNotAvailable,
+
+    #[error("Announcement failed: {0}")]
+    AnnouncementError(String),
 }
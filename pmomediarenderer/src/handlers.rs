@@ -10,6 +10,7 @@ use pmoupnp::{action_handler, get, set};
 
 use crate::messages::PlaybackState;
 use crate::pipeline::{upnp_time_to_seconds, PipelineControl, PipelineHandle};
+use crate::session::{check_transport, exclusive_control};
 use crate::state::SharedState;
 
 // ─── AVTransport : commandes de transport ─────────────────────────────────────
@@ -23,7 +24,8 @@ pub fn play_handler(
     action_handler!(
         captures(pipeline, state, instance_id, stream_url_base) | data | {
             tracing::info!("[MediaRenderer] UPnP Play action invoked");
-            let has_uri = state.read().current_uri.is_some();
+            check_transport(&state, "Play", true, false)?;
+            let has_uri = state.read().now_playing.has_track();
             if !has_uri {
                 tracing::warn!("[MediaRenderer] UPnP Play ignored: no URI loaded");
                 return Ok(data);
@@ -45,6 +47,7 @@ pub fn play_handler(
 pub fn stop_handler(pipeline: PipelineHandle, state: SharedState) -> ActionHandler {
     action_handler!(
         captures(pipeline, state) | data | {
+            check_transport(&state, "Stop", false, false)?;
             pipeline.send(PipelineControl::Stop).await;
             pipeline.flac_handle.pause();
             pipeline
@@ -62,6 +65,7 @@ pub fn stop_handler(pipeline: PipelineHandle, state: SharedState) -> ActionHandl
 pub fn pause_handler(pipeline: PipelineHandle, state: SharedState) -> ActionHandler {
     action_handler!(
         captures(pipeline, state) | data | {
+            check_transport(&state, "Pause", false, false)?;
             pipeline.send(PipelineControl::Pause).await;
             pipeline.flac_handle.pause();
             pipeline
@@ -73,27 +77,30 @@ pub fn pause_handler(pipeline: PipelineHandle, state: SharedState) -> ActionHand
     )
 }
 
-pub fn next_handler(pipeline: PipelineHandle) -> ActionHandler {
+pub fn next_handler(pipeline: PipelineHandle, state: SharedState) -> ActionHandler {
     action_handler!(
-        captures(pipeline) | data | {
+        captures(pipeline, state) | data | {
+            check_transport(&state, "Next", false, false)?;
             pipeline.send(PipelineControl::Play).await;
             Ok(data)
         }
     )
 }
 
-pub fn previous_handler(pipeline: PipelineHandle) -> ActionHandler {
+pub fn previous_handler(pipeline: PipelineHandle, state: SharedState) -> ActionHandler {
     action_handler!(
-        captures(pipeline) | data | {
+        captures(pipeline, state) | data | {
+            check_transport(&state, "Previous", false, false)?;
             pipeline.send(PipelineControl::Play).await;
             Ok(data)
         }
     )
 }
 
-pub fn seek_handler(pipeline: PipelineHandle) -> ActionHandler {
+pub fn seek_handler(pipeline: PipelineHandle, state: SharedState) -> ActionHandler {
     action_handler!(
-        captures(pipeline) | data | {
+        captures(pipeline, state) | data | {
+            check_transport(&state, "Seek", false, false)?;
             let target: String = get!(&data, "Target", String);
             let pos_sec = upnp_time_to_seconds(&target);
             pipeline.send(PipelineControl::Seek(pos_sec)).await;
@@ -107,18 +114,22 @@ pub fn seek_handler(pipeline: PipelineHandle) -> ActionHandler {
 pub fn set_uri_handler(pipeline: PipelineHandle, state: SharedState) -> ActionHandler {
     action_handler!(captures(pipeline, state) |mut data| {
         tracing::info!("[MediaRenderer] UPnP SetAVTransportURI action invoked");
+        check_transport(&state, "SetAVTransportURI", true, false)?;
         let uri: String = get!(&data, "CurrentURI", String);
         let metadata: String = get_value::<String>(&data, "CurrentURIMetaData")
             .or_else(|_| get_value::<DIDLLite>(&data, "CurrentURIMetaData").map(|didl| didl.to_xml()))
             .unwrap_or_default();
 
         tracing::info!(uri = %uri, "SetAVTransportURI handler called - loading URI into pipeline");
+        let sniff = crate::sniff::is_missing(&metadata);
         {
             let mut s = state.write();
-            s.current_uri = Some(uri.clone());
-            s.current_metadata = Some(metadata);
+            s.now_playing.load(uri.clone(), Some(metadata));
             s.playback_state = PlaybackState::Transitioning;
         }
+        if sniff {
+            crate::sniff::spawn_metadata_sniff(state.clone(), uri.clone(), crate::sniff::MetadataSlot::Current);
+        }
         pipeline.send(PipelineControl::LoadUri(uri)).await;
         Ok(data)
     })
@@ -126,16 +137,21 @@ pub fn set_uri_handler(pipeline: PipelineHandle, state: SharedState) -> ActionHa
 
 pub fn set_next_uri_handler(pipeline: PipelineHandle, state: SharedState) -> ActionHandler {
     action_handler!(captures(pipeline, state) |mut data| {
+        check_transport(&state, "SetNextAVTransportURI", false, false)?;
         let uri: String = get!(&data, "NextURI", String);
         let metadata: String = get_value::<String>(&data, "NextURIMetaData")
             .or_else(|_| get_value::<DIDLLite>(&data, "NextURIMetaData").map(|didl| didl.to_xml()))
             .unwrap_or_default();
 
+        let sniff = crate::sniff::is_missing(&metadata);
         {
             let mut s = state.write();
             s.next_uri = Some(uri.clone());
             s.next_metadata = Some(metadata);
         }
+        if sniff {
+            crate::sniff::spawn_metadata_sniff(state.clone(), uri.clone(), crate::sniff::MetadataSlot::Next);
+        }
         pipeline.send(PipelineControl::LoadNextUri(uri)).await;
         Ok(data)
     })
@@ -146,12 +162,13 @@ pub fn set_next_uri_handler(pipeline: PipelineHandle, state: SharedState) -> Act
 pub fn get_position_info_handler(state: SharedState) -> ActionHandler {
     action_handler!(captures(state) |mut data| {
         let s = state.read();
-        set!(&mut data, "Track", if s.current_uri.is_some() { 1u32 } else { 0u32 });
-        set!(&mut data, "TrackDuration", s.duration.clone().unwrap_or_else(|| "00:00:00".to_string()));
-        set!(&mut data, "TrackURI", s.current_uri.clone().unwrap_or_default());
-        set!(&mut data, "TrackMetaData", s.current_metadata.clone().unwrap_or_default());
-        set!(&mut data, "RelTime", s.position.clone().unwrap_or_else(|| "00:00:00".to_string()));
-        set!(&mut data, "AbsTime", s.position.clone().unwrap_or_else(|| "00:00:00".to_string()));
+        let now = &s.now_playing;
+        set!(&mut data, "Track", if now.has_track() { 1u32 } else { 0u32 });
+        set!(&mut data, "TrackDuration", now.duration_upnp().unwrap_or_else(|| "00:00:00".to_string()));
+        set!(&mut data, "TrackURI", now.uri.clone().unwrap_or_default());
+        set!(&mut data, "TrackMetaData", now.metadata.clone().unwrap_or_default());
+        set!(&mut data, "RelTime", now.position_upnp().unwrap_or_else(|| "00:00:00".to_string()));
+        set!(&mut data, "AbsTime", now.position_upnp().unwrap_or_else(|| "00:00:00".to_string()));
         Ok(data)
     })
 }
@@ -175,15 +192,242 @@ pub fn get_transport_info_handler(state: SharedState) -> ActionHandler {
 pub fn get_media_info_handler(state: SharedState) -> ActionHandler {
     action_handler!(captures(state) |mut data| {
         let s = state.read();
-        set!(&mut data, "NrTracks", if s.current_uri.is_some() { 1u32 } else { 0u32 });
-        set!(&mut data, "CurrentURI", s.current_uri.clone().unwrap_or_default());
-        set!(&mut data, "CurrentURIMetaData", s.current_metadata.clone().unwrap_or_default());
+        set!(&mut data, "NrTracks", if s.now_playing.has_track() { 1u32 } else { 0u32 });
+        set!(&mut data, "MediaDuration", s.now_playing.duration_upnp().unwrap_or_else(|| "00:00:00".to_string()));
+        set!(&mut data, "CurrentURI", s.now_playing.uri.clone().unwrap_or_default());
+        set!(&mut data, "CurrentURIMetaData", s.now_playing.metadata.clone().unwrap_or_default());
         set!(&mut data, "NextURI", s.next_uri.clone().unwrap_or_default());
         set!(&mut data, "NextURIMetaData", s.next_metadata.clone().unwrap_or_default());
         Ok(data)
     })
 }
 
+// ─── AVTransport : session de contrôle (vendor X_PMO_*) ─────────────────────
+
+pub fn take_over_handler(state: SharedState) -> ActionHandler {
+    action_handler!(
+        captures(state) | data | {
+            check_transport(&state, "X_PMO_TakeOver", true, true)?;
+            Ok(data)
+        }
+    )
+}
+
+pub fn get_session_handler(state: SharedState) -> ActionHandler {
+    action_handler!(captures(state) |mut data| {
+        let (owner, agent) = match &state.read().session {
+            Some(session) => (session.owner.clone(), session.user_agent.clone().unwrap_or_default()),
+            None => (String::new(), String::new()),
+        };
+        set!(&mut data, "Owner", owner);
+        set!(&mut data, "OwnerAgent", agent);
+        set!(&mut data, "Exclusive", exclusive_control());
+        Ok(data)
+    })
+}
+
+// ─── AVTransport : file interne (vendor X_PMO_Queue*) ───────────────────────
+
+#[cfg(feature = "pmoserver")]
+fn queue_error(e: pmocontrol::errors::ControlPointError) -> pmoupnp::actions::ActionError {
+    pmoupnp::actions::ActionError::GeneralError(format!("Queue error: {}", e))
+}
+
+#[cfg(feature = "pmoserver")]
+pub fn queue_list_handler(pipeline: PipelineHandle) -> ActionHandler {
+    action_handler!(captures(pipeline) |mut data| {
+        let snapshot = pipeline.queue.snapshot().map_err(queue_error)?;
+        let current = snapshot.current_index.map(|i| i as i32).unwrap_or(-1);
+        set!(&mut data, "QueueLength", snapshot.len() as u32);
+        set!(&mut data, "CurrentIndex", current);
+        set!(&mut data, "Items", snapshot.to_didl_xml());
+        Ok(data)
+    })
+}
+
+#[cfg(feature = "pmoserver")]
+pub fn queue_insert_handler(pipeline: PipelineHandle, state: SharedState) -> ActionHandler {
+    action_handler!(captures(pipeline, state) |mut data| {
+        check_transport(&state, "X_PMO_QueueInsert", false, false)?;
+        let position: u32 = get!(&data, "Position", u32);
+        let uri: String = get!(&data, "URI", String);
+        let metadata: String = get_value::<String>(&data, "URIMetaData")
+            .or_else(|_| get_value::<DIDLLite>(&data, "URIMetaData").map(|didl| didl.to_xml()))
+            .unwrap_or_default();
+
+        tracing::info!(position, uri = %uri, "[MediaRenderer] X_PMO_QueueInsert");
+        pipeline
+            .queue
+            .insert(position as usize, uri, &metadata)
+            .map_err(queue_error)?;
+        let len = pipeline.queue.snapshot().map_err(queue_error)?.len();
+        set!(&mut data, "QueueLength", len as u32);
+        Ok(data)
+    })
+}
+
+#[cfg(feature = "pmoserver")]
+pub fn queue_remove_handler(pipeline: PipelineHandle, state: SharedState) -> ActionHandler {
+    action_handler!(captures(pipeline, state) |mut data| {
+        check_transport(&state, "X_PMO_QueueRemove", false, false)?;
+        let position: u32 = get!(&data, "Position", u32);
+
+        tracing::info!(position, "[MediaRenderer] X_PMO_QueueRemove");
+        pipeline
+            .queue
+            .remove(position as usize)
+            .map_err(queue_error)?;
+        let len = pipeline.queue.snapshot().map_err(queue_error)?.len();
+        set!(&mut data, "QueueLength", len as u32);
+        Ok(data)
+    })
+}
+
+#[cfg(feature = "pmoserver")]
+pub fn queue_move_handler(pipeline: PipelineHandle, state: SharedState) -> ActionHandler {
+    action_handler!(
+        captures(pipeline, state) | data | {
+            check_transport(&state, "X_PMO_QueueMove", false, false)?;
+            let from: u32 = get!(&data, "From", u32);
+            let to: u32 = get!(&data, "To", u32);
+
+            tracing::info!(from, to, "[MediaRenderer] X_PMO_QueueMove");
+            pipeline
+                .queue
+                .move_item(from as usize, to as usize)
+                .map_err(queue_error)?;
+            Ok(data)
+        }
+    )
+}
+
+#[cfg(feature = "pmoserver")]
+pub fn set_play_mode_handler(pipeline: PipelineHandle, state: SharedState) -> ActionHandler {
+    action_handler!(captures(pipeline, state) |data| {
+        check_transport(&state, "SetPlayMode", false, false)?;
+        let play_mode: String = get!(&data, "NewPlayMode", String);
+        let (shuffle, repeat) = crate::queue::play_mode_from_upnp(&play_mode).ok_or_else(|| {
+            pmoupnp::actions::ActionError::UpnpError {
+                code: "712".to_string(),
+                description: format!("Play mode not supported: {}", play_mode),
+            }
+        })?;
+
+        tracing::info!(play_mode = %play_mode, "[MediaRenderer] SetPlayMode");
+        pipeline
+            .queue
+            .set_play_mode(shuffle, repeat)
+            .map_err(queue_error)?;
+        Ok(data)
+    })
+}
+
+#[cfg(feature = "pmoserver")]
+pub fn set_resume_position_handler(pipeline: PipelineHandle) -> ActionHandler {
+    action_handler!(captures(pipeline) |data| {
+        let uri: String = get!(&data, "URI", String);
+        let position: String = get!(&data, "Position", String);
+        let seconds = upnp_time_to_seconds(&position) as u32;
+
+        tracing::info!(uri = %uri, seconds, "[MediaRenderer] X_PMO_SetResumePosition");
+        pipeline
+            .queue
+            .set_resume_position(&uri, seconds)
+            .map_err(|e| {
+                pmoupnp::actions::ActionError::GeneralError(format!("Resume error: {}", e))
+            })?;
+        Ok(data)
+    })
+}
+
+#[cfg(feature = "pmoserver")]
+pub fn clear_resume_position_handler(pipeline: PipelineHandle) -> ActionHandler {
+    action_handler!(captures(pipeline) |data| {
+        let uri: String = get!(&data, "URI", String);
+
+        tracing::info!(uri = %uri, "[MediaRenderer] X_PMO_ClearResumePosition");
+        let cleared = pipeline
+            .queue
+            .clear_resume_position(&uri)
+            .map_err(|e| {
+                pmoupnp::actions::ActionError::GeneralError(format!("Resume error: {}", e))
+            })?;
+        if !cleared {
+            return Err(pmoupnp::actions::ActionError::UpnpError {
+                code: "714".to_string(),
+                description: format!("No resume position for {}", uri),
+            });
+        }
+        Ok(data)
+    })
+}
+
+#[cfg(feature = "pmoserver")]
+pub fn get_transport_settings_handler(pipeline: PipelineHandle) -> ActionHandler {
+    action_handler!(captures(pipeline) |mut data| {
+        let (shuffle, repeat) = pipeline.queue.play_mode();
+        set!(
+            &mut data,
+            "PlayMode",
+            crate::queue::play_mode_to_upnp(shuffle, repeat).to_string()
+        );
+        Ok(data)
+    })
+}
+
+// ─── Time (OpenHome) ──────────────────────────────────────────────────────────
+
+pub fn time_handler(state: SharedState) -> ActionHandler {
+    action_handler!(captures(state) |mut data| {
+        let values = crate::time::TimeValues::from_state(&state);
+        set!(&mut data, "TrackCount", values.track_count);
+        set!(&mut data, "Duration", values.duration);
+        set!(&mut data, "Seconds", values.seconds);
+        Ok(data)
+    })
+}
+
+// ─── OpenHome Info ─────────────────────────────────────────────────────────────
+
+pub fn info_counters_handler(state: SharedState) -> ActionHandler {
+    action_handler!(captures(state) |mut data| {
+        let values = crate::info::InfoValues::from_state(&state);
+        set!(&mut data, "TrackCount", values.track_count);
+        set!(&mut data, "DetailsCount", values.details_count);
+        set!(&mut data, "MetatextCount", 0u32);
+        Ok(data)
+    })
+}
+
+pub fn info_track_handler(state: SharedState) -> ActionHandler {
+    action_handler!(captures(state) |mut data| {
+        let values = crate::info::InfoValues::from_state(&state);
+        set!(&mut data, "Uri", values.uri);
+        set!(&mut data, "Metadata", values.metadata);
+        Ok(data)
+    })
+}
+
+pub fn info_details_handler(state: SharedState) -> ActionHandler {
+    action_handler!(captures(state) |mut data| {
+        let values = crate::info::InfoValues::from_state(&state);
+        set!(&mut data, "Duration", values.duration);
+        set!(&mut data, "BitRate", 0u32);
+        set!(&mut data, "BitDepth", values.bit_depth);
+        set!(&mut data, "SampleRate", values.sample_rate);
+        set!(&mut data, "Lossless", values.lossless);
+        set!(&mut data, "CodecName", values.codec_name);
+        Ok(data)
+    })
+}
+
+pub fn info_metatext_handler() -> ActionHandler {
+    action_handler!(|mut data| {
+        set!(&mut data, "Value", String::new());
+        Ok(data)
+    })
+}
+
 // ─── ConnectionManager ─────────────────────────────────────────────────────────
 
 pub fn get_protocol_info_handler() -> ActionHandler {
@@ -200,10 +444,15 @@ pub fn get_protocol_info_handler() -> ActionHandler {
 
 // ─── RenderingControl ──────────────────────────────────────────────────────────
 
-pub fn set_volume_handler(state: SharedState) -> ActionHandler {
-    action_handler!(captures(state) |mut data| {
+pub fn set_volume_handler(pipeline: PipelineHandle, state: SharedState) -> ActionHandler {
+    action_handler!(captures(pipeline, state) |mut data| {
         let volume: u16 = get!(&data, "DesiredVolume", u16);
-        state.write().volume = volume;
+        let mute = {
+            let mut s = state.write();
+            s.volume = volume;
+            s.mute
+        };
+        pipeline.volume.set_volume(volume, mute);
         Ok(data)
     })
 }
@@ -216,10 +465,15 @@ pub fn get_volume_handler(state: SharedState) -> ActionHandler {
     })
 }
 
-pub fn set_mute_handler(state: SharedState) -> ActionHandler {
-    action_handler!(captures(state) |mut data| {
+pub fn set_mute_handler(pipeline: PipelineHandle, state: SharedState) -> ActionHandler {
+    action_handler!(captures(pipeline, state) |mut data| {
         let mute: bool = get!(&data, "DesiredMute", bool);
-        state.write().mute = mute;
+        let volume = {
+            let mut s = state.write();
+            s.mute = mute;
+            s.volume
+        };
+        pipeline.volume.set_volume(volume, mute);
         Ok(data)
     })
 }
@@ -231,3 +485,56 @@ pub fn get_mute_handler(state: SharedState) -> ActionHandler {
         Ok(data)
     })
 }
+
+pub fn set_balance_handler(pipeline: PipelineHandle, state: SharedState) -> ActionHandler {
+    action_handler!(captures(pipeline, state) |mut data| {
+        let balance: i16 = get!(&data, "DesiredBalance", i16);
+        let channel_swap = state.read().channel_swap;
+        tracing::info!(balance, "[MediaRenderer] X_PMO_SetBalance");
+        pipeline.set_channel_mix(balance, channel_swap);
+        Ok(data)
+    })
+}
+
+pub fn get_balance_handler(state: SharedState) -> ActionHandler {
+    action_handler!(captures(state) |mut data| {
+        let balance = state.read().balance;
+        set!(&mut data, "CurrentBalance", balance);
+        Ok(data)
+    })
+}
+
+pub fn set_channel_swap_handler(pipeline: PipelineHandle, state: SharedState) -> ActionHandler {
+    action_handler!(captures(pipeline, state) |mut data| {
+        let channel_swap: bool = get!(&data, "DesiredChannelSwap", bool);
+        let balance = state.read().balance;
+        tracing::info!(channel_swap, "[MediaRenderer] X_PMO_SetChannelSwap");
+        pipeline.set_channel_mix(balance, channel_swap);
+        Ok(data)
+    })
+}
+
+pub fn get_channel_swap_handler(state: SharedState) -> ActionHandler {
+    action_handler!(captures(state) |mut data| {
+        let channel_swap = state.read().channel_swap;
+        set!(&mut data, "CurrentChannelSwap", channel_swap);
+        Ok(data)
+    })
+}
+
+pub fn set_mono_handler(pipeline: PipelineHandle) -> ActionHandler {
+    action_handler!(captures(pipeline) |mut data| {
+        let mono: bool = get!(&data, "DesiredMono", bool);
+        tracing::info!(mono, "[MediaRenderer] X_PMO_SetMono");
+        pipeline.set_mono(mono);
+        Ok(data)
+    })
+}
+
+pub fn get_mono_handler(state: SharedState) -> ActionHandler {
+    action_handler!(captures(state) |mut data| {
+        let mono = state.read().mono;
+        set!(&mut data, "CurrentMono", mono);
+        Ok(data)
+    })
+}
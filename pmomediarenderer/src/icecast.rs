@@ -0,0 +1,391 @@
+//! Diffusion du flux d'un renderer vers un serveur Icecast/Shoutcast
+//!
+//! Le client se comporte comme une source Icecast (protocole `PUT` des
+//! versions 2.4+) : il s'abonne au flux OGG-FLAC du renderer et le pousse
+//! tel quel vers le point de montage configuré. Les métadonnées Vorbis
+//! Comment sont déjà portées par le flux chaîné ; le titre courant est en
+//! plus publié via `/admin/metadata` pour la page de statut du serveur.
+//!
+//! En cas d'erreur (serveur absent, montage occupé, coupure réseau), la
+//! connexion est retentée avec un délai croissant jusqu'à l'arrêt du pipeline.
+
+use std::time::Duration;
+
+use base64::Engine;
+use pmoaudio_ext::sinks::OggFlacStreamHandle;
+use tokio::io::{AsyncBufReadExt, AsyncReadExt, AsyncWriteExt, BufReader};
+use tokio::net::TcpStream;
+use tokio::task::JoinHandle;
+use tokio_util::sync::CancellationToken;
+use tracing::{debug, info, warn};
+
+/// Utilisateur source par défaut d'Icecast
+pub const DEFAULT_ICECAST_USER: &str = "source";
+
+/// Délai maximal entre deux tentatives de connexion
+const MAX_RETRY_DELAY: Duration = Duration::from_secs(60);
+/// Intervalle de vérification du titre courant
+const METADATA_POLL_INTERVAL: Duration = Duration::from_secs(2);
+
+/// Paramètres de diffusion Icecast d'un renderer
+#[derive(Debug, Clone, PartialEq)]
+pub struct IcecastSettings {
+    /// Hôte du serveur Icecast
+    pub host: String,
+    /// Port du serveur (8000 par défaut)
+    pub port: u16,
+    /// Point de montage (commence par '/')
+    pub mount: String,
+    /// Utilisateur source
+    pub user: String,
+    /// Mot de passe source
+    pub password: String,
+    /// Nom du flux (Ice-Name)
+    pub name: Option<String>,
+    /// Description du flux (Ice-Description)
+    pub description: Option<String>,
+    /// Publier le flux dans les annuaires (Ice-Public)
+    pub public: bool,
+}
+
+impl IcecastSettings {
+    /// Construit les paramètres depuis une URL `http://host[:port]/mount`
+    pub fn from_url(url: &str, password: String) -> Option<Self> {
+        let rest = url.trim().strip_prefix("http://")?;
+        let (authority, mount) = match rest.find('/') {
+            Some(i) => (&rest[..i], &rest[i..]),
+            None => return None,
+        };
+        if mount.len() < 2 {
+            return None;
+        }
+        let (host, port) = match authority.rsplit_once(':') {
+            Some((host, port)) => (host, port.parse().ok()?),
+            None => (authority, 8000),
+        };
+        if host.is_empty() {
+            return None;
+        }
+
+        Some(Self {
+            host: host.to_string(),
+            port,
+            mount: mount.to_string(),
+            user: DEFAULT_ICECAST_USER.to_string(),
+            password,
+            name: None,
+            description: None,
+            public: false,
+        })
+    }
+
+    fn authorization(&self) -> String {
+        let credentials = format!("{}:{}", self.user, self.password);
+        format!(
+            "Basic {}",
+            base64::engine::general_purpose::STANDARD.encode(credentials)
+        )
+    }
+}
+
+/// Encode une valeur pour une query string
+fn url_encode(value: &str) -> String {
+    value
+        .bytes()
+        .map(|b| match b {
+            b'A'..=b'Z' | b'a'..=b'z' | b'0'..=b'9' | b'-' | b'_' | b'.' | b'~' => {
+                (b as char).to_string()
+            }
+            _ => format!("%{:02X}", b),
+        })
+        .collect()
+}
+
+/// Valeur d'en-tête sûre : les caractères de contrôle (CR/LF compris) sont
+/// remplacés par des espaces, pour qu'un nom ou une description configurés
+/// ne puissent pas injecter d'en-têtes dans la requête source
+fn header_value(value: &str) -> String {
+    value
+        .chars()
+        .map(|c| if c.is_control() { ' ' } else { c })
+        .collect::<String>()
+        .trim()
+        .to_string()
+}
+
+/// Lit la ligne de statut HTTP et retourne le code
+async fn read_status<R: tokio::io::AsyncBufRead + Unpin>(reader: &mut R) -> std::io::Result<u16> {
+    let mut line = String::new();
+    reader.read_line(&mut line).await?;
+    line.split_whitespace()
+        .nth(1)
+        .and_then(|code| code.parse().ok())
+        .ok_or_else(|| {
+            std::io::Error::new(
+                std::io::ErrorKind::InvalidData,
+                format!("invalid HTTP status line: {:?}", line.trim()),
+            )
+        })
+}
+
+/// Ouvre la connexion source et attend l'acceptation du serveur
+async fn connect_source(settings: &IcecastSettings) -> std::io::Result<TcpStream> {
+    let stream = TcpStream::connect((settings.host.as_str(), settings.port)).await?;
+    let mut reader = BufReader::new(stream);
+
+    let mut request = format!(
+        "PUT {} HTTP/1.1\r\n\
+         Host: {}:{}\r\n\
+         Authorization: {}\r\n\
+         User-Agent: PMOMusic\r\n\
+         Content-Type: application/ogg\r\n\
+         Ice-Public: {}\r\n\
+         Expect: 100-continue\r\n",
+        settings.mount,
+        settings.host,
+        settings.port,
+        settings.authorization(),
+        settings.public as u8,
+    );
+    if let Some(name) = &settings.name {
+        request.push_str(&format!("Ice-Name: {}\r\n", header_value(name)));
+    }
+    if let Some(description) = &settings.description {
+        request.push_str(&format!(
+            "Ice-Description: {}\r\n",
+            header_value(description)
+        ));
+    }
+    request.push_str("\r\n");
+    reader.get_mut().write_all(request.as_bytes()).await?;
+
+    let status = read_status(&mut reader).await?;
+    // Consommer les en-têtes de la réponse
+    let mut line = String::new();
+    loop {
+        line.clear();
+        if reader.read_line(&mut line).await? == 0 || line == "\r\n" {
+            break;
+        }
+    }
+
+    match status {
+        100 | 200 => Ok(reader.into_inner()),
+        401 => Err(std::io::Error::new(
+            std::io::ErrorKind::PermissionDenied,
+            "authentication refused",
+        )),
+        403 => Err(std::io::Error::new(
+            std::io::ErrorKind::AddrInUse,
+            "mountpoint in use or forbidden",
+        )),
+        code => Err(std::io::Error::other(format!("unexpected HTTP status {}", code))),
+    }
+}
+
+/// Publie le titre courant via l'interface d'administration
+async fn update_metadata(settings: &IcecastSettings, song: &str) -> std::io::Result<()> {
+    let mut stream = TcpStream::connect((settings.host.as_str(), settings.port)).await?;
+    let request = format!(
+        "GET /admin/metadata?mount={}&mode=updinfo&song={} HTTP/1.0\r\n\
+         Host: {}:{}\r\n\
+         Authorization: {}\r\n\
+         User-Agent: PMOMusic\r\n\r\n",
+        url_encode(&settings.mount),
+        url_encode(song),
+        settings.host,
+        settings.port,
+        settings.authorization(),
+    );
+    stream.write_all(request.as_bytes()).await?;
+
+    let mut reader = BufReader::new(stream);
+    match read_status(&mut reader).await? {
+        200 => Ok(()),
+        code => Err(std::io::Error::other(format!("metadata update refused ({})", code))),
+    }
+}
+
+/// Titre « Artiste - Titre » du morceau en cours
+async fn current_song(handle: &OggFlacStreamHandle) -> Option<String> {
+    let metadata = handle.get_metadata().await;
+    match (metadata.artist, metadata.title) {
+        (Some(artist), Some(title)) => Some(format!("{} - {}", artist, title)),
+        (None, Some(title)) => Some(title),
+        _ => None,
+    }
+}
+
+/// Pousse le flux jusqu'à erreur ou arrêt
+async fn run_session(
+    settings: &IcecastSettings,
+    handle: &OggFlacStreamHandle,
+    stop_token: &CancellationToken,
+) -> std::io::Result<()> {
+    let mut socket = connect_source(settings).await?;
+    info!(
+        "📡 Icecast: streaming to {}:{}{}",
+        settings.host, settings.port, settings.mount
+    );
+
+    let mut client = handle.subscribe();
+    let mut buffer = vec![0u8; 16 * 1024];
+    let mut last_song: Option<String> = None;
+    let mut metadata_tick = tokio::time::interval(METADATA_POLL_INTERVAL);
+
+    loop {
+        tokio::select! {
+            _ = stop_token.cancelled() => return Ok(()),
+            _ = metadata_tick.tick() => {
+                let song = current_song(handle).await;
+                if song.is_some() && song != last_song {
+                    if let Some(song) = &song {
+                        if let Err(e) = update_metadata(settings, song).await {
+                            debug!("Icecast: metadata update failed: {}", e);
+                        }
+                    }
+                    last_song = song;
+                }
+            }
+            read = client.read(&mut buffer) => {
+                let n = read?;
+                if n == 0 {
+                    return Err(std::io::Error::new(
+                        std::io::ErrorKind::UnexpectedEof,
+                        "renderer stream closed",
+                    ));
+                }
+                socket.write_all(&buffer[..n]).await?;
+            }
+        }
+    }
+}
+
+/// Lance la diffusion Icecast du flux d'un renderer.
+///
+/// La tâche se termine à l'annulation de `stop_token`.
+pub fn spawn_icecast_source(
+    settings: IcecastSettings,
+    handle: OggFlacStreamHandle,
+    stop_token: CancellationToken,
+) -> JoinHandle<()> {
+    tokio::spawn(async move {
+        let mut delay = Duration::from_secs(1);
+        while !stop_token.is_cancelled() {
+            let started = std::time::Instant::now();
+            let result = run_session(&settings, &handle, &stop_token).await;
+            // Une session qui a tenu longtemps remet le délai à zéro
+            if started.elapsed() > MAX_RETRY_DELAY {
+                delay = Duration::from_secs(1);
+            }
+            match result {
+                Ok(()) => break,
+                Err(e) => warn!(
+                    "Icecast: {}:{}{} failed: {} (retry in {:?})",
+                    settings.host, settings.port, settings.mount, e, delay
+                ),
+            }
+
+            tokio::select! {
+                _ = stop_token.cancelled() => break,
+                _ = tokio::time::sleep(delay) => {}
+            }
+            delay = (delay * 2).min(MAX_RETRY_DELAY);
+        }
+        debug!("Icecast source task terminated");
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_settings_from_url() {
+        let s = IcecastSettings::from_url("http://radio.local:8010/salon.ogg", "hackme".into())
+            .unwrap();
+        assert_eq!(s.host, "radio.local");
+        assert_eq!(s.port, 8010);
+        assert_eq!(s.mount, "/salon.ogg");
+        assert_eq!(s.user, DEFAULT_ICECAST_USER);
+
+        let s = IcecastSettings::from_url("http://radio.local/live", String::new()).unwrap();
+        assert_eq!(s.port, 8000);
+
+        assert!(IcecastSettings::from_url("https://radio.local/live", String::new()).is_none());
+        assert!(IcecastSettings::from_url("http://radio.local", String::new()).is_none());
+    }
+
+    #[test]
+    fn test_header_value_strips_control_characters() {
+        assert_eq!(header_value("Radio salon"), "Radio salon");
+        assert_eq!(
+            header_value("Salon\r\nX-Injected: 1"),
+            "Salon  X-Injected: 1"
+        );
+        assert_eq!(header_value("\tJazz\n"), "Jazz");
+    }
+
+    /// Serveur Icecast d'une seule connexion : lit la requête source et
+    /// répond `status`, puis renvoie la requête reçue
+    async fn fake_server(status: &'static str) -> (u16, JoinHandle<String>) {
+        let listener = tokio::net::TcpListener::bind("127.0.0.1:0").await.unwrap();
+        let port = listener.local_addr().unwrap().port();
+        let server = tokio::spawn(async move {
+            let (stream, _) = listener.accept().await.unwrap();
+            let mut reader = BufReader::new(stream);
+            let mut request = String::new();
+            loop {
+                let mut line = String::new();
+                if reader.read_line(&mut line).await.unwrap() == 0 || line == "\r\n" {
+                    break;
+                }
+                request.push_str(&line);
+            }
+            let response = format!("HTTP/1.1 {}\r\nServer: Icecast 2.4.4\r\n\r\n", status);
+            reader
+                .get_mut()
+                .write_all(response.as_bytes())
+                .await
+                .unwrap();
+            request
+        });
+        (port, server)
+    }
+
+    fn local_settings(port: u16) -> IcecastSettings {
+        let mut settings = IcecastSettings::from_url(
+            &format!("http://127.0.0.1:{}/salon.ogg", port),
+            "hackme".into(),
+        )
+        .unwrap();
+        settings.name = Some("Salon\r\nX-Injected: 1".into());
+        settings.description = Some("PMOMusic".into());
+        settings
+    }
+
+    #[tokio::test]
+    async fn test_put_handshake_accepted() {
+        let (port, server) = fake_server("200 OK").await;
+        let settings = local_settings(port);
+
+        connect_source(&settings).await.unwrap();
+        let request = server.await.unwrap();
+
+        assert!(request.starts_with("PUT /salon.ogg HTTP/1.1\r\n"));
+        assert!(request.contains(&format!("Authorization: {}\r\n", settings.authorization())));
+        assert!(request.contains("Content-Type: application/ogg\r\n"));
+        assert!(request.contains("Ice-Name: Salon  X-Injected: 1\r\n"));
+        assert!(request.contains("Ice-Description: PMOMusic\r\n"));
+        assert!(!request.contains("\r\nX-Injected"));
+    }
+
+    #[tokio::test]
+    async fn test_put_handshake_auth_refused() {
+        let (port, server) = fake_server("401 Unauthorized").await;
+
+        let error = connect_source(&local_settings(port)).await.unwrap_err();
+        assert_eq!(error.kind(), std::io::ErrorKind::PermissionDenied);
+        server.await.unwrap();
+    }
+}
@@ -0,0 +1,105 @@
+//! Mise en veille des pipelines inactifs
+//!
+//! Un renderer arrêté, sans abonné GENA ni client de flux pendant
+//! `host.renderer.idle_teardown` voit son graphe audio détruit : décodeur,
+//! resampler et nœuds DSP sont libérés. Le graphe est reconstruit à la
+//! commande suivante (voir [`PipelineGraph::wake`]), ce qui laisse un
+//! appareil toujours allumé au repos complet entre deux écoutes.
+//!
+//! [`PipelineGraph::wake`]: crate::pipeline::PipelineGraph::wake
+
+use std::sync::Arc;
+use std::time::{Duration, Instant};
+
+use pmoupnp::devices::DeviceInstance;
+
+use crate::messages::PlaybackState;
+use crate::pipeline::PipelineHandle;
+use crate::state::SharedState;
+
+/// Période de vérification de l'inactivité
+const IDLE_CHECK_PERIOD: Duration = Duration::from_secs(5);
+
+/// Suivi de la durée d'inactivité continue
+#[derive(Debug, Default)]
+struct IdleTracker {
+    idle_since: Option<Instant>,
+}
+
+impl IdleTracker {
+    /// Enregistre une observation ; retourne true quand l'inactivité
+    /// vient de durer `timeout`.
+    fn observe(&mut self, idle: bool, now: Instant, timeout: Duration) -> bool {
+        if !idle {
+            self.idle_since = None;
+            return false;
+        }
+        let since = *self.idle_since.get_or_insert(now);
+        now.duration_since(since) >= timeout
+    }
+}
+
+/// Lance la tâche qui détruit le graphe audio après `timeout` d'inactivité.
+///
+/// Le renderer est inactif s'il est arrêté, sans client connecté au flux et
+/// sans abonnement GENA sur aucun de ses services. La tâche s'arrête avec
+/// le pipeline de l'instance.
+pub fn spawn_idle_monitor(
+    device: &Arc<DeviceInstance>,
+    state: SharedState,
+    pipeline: PipelineHandle,
+    timeout: Duration,
+) -> tokio::task::JoinHandle<()> {
+    let services = device.services();
+    let stop_token = pipeline.stop_token.clone();
+
+    tokio::spawn(async move {
+        let mut ticker = tokio::time::interval(IDLE_CHECK_PERIOD);
+        let mut tracker = IdleTracker::default();
+
+        loop {
+            tokio::select! {
+                _ = stop_token.cancelled() => break,
+                _ = ticker.tick() => {}
+            }
+
+            if !pipeline.graph.is_running() {
+                tracker = IdleTracker::default();
+                continue;
+            }
+
+            let idle = matches!(state.read().playback_state, PlaybackState::Stopped)
+                && pipeline.flac_handle.active_client_count() == 0
+                && services.iter().all(|s| s.subscribers().is_empty());
+
+            if tracker.observe(idle, Instant::now(), timeout) && pipeline.graph.teardown() {
+                tracing::info!(
+                    udn = %pipeline.udn,
+                    "[MediaRenderer] Idle for {:?}, audio pipeline torn down",
+                    timeout
+                );
+                tracker = IdleTracker::default();
+            }
+        }
+
+        tracing::debug!("[MediaRenderer] Idle monitor stopped");
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn activity_resets_the_idle_period() {
+        let timeout = Duration::from_secs(60);
+        let start = Instant::now();
+        let mut tracker = IdleTracker::default();
+
+        assert!(!tracker.observe(true, start, timeout));
+        assert!(!tracker.observe(true, start + Duration::from_secs(30), timeout));
+        assert!(!tracker.observe(false, start + Duration::from_secs(40), timeout));
+        assert!(!tracker.observe(true, start + Duration::from_secs(70), timeout));
+        assert!(tracker.observe(true, start + Duration::from_secs(130), timeout));
+    }
+}
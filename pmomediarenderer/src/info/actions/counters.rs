@@ -0,0 +1,10 @@
+use crate::info::variables::{DETAILSCOUNT, METATEXTCOUNT, TRACKCOUNT};
+use pmoupnp::define_action;
+
+define_action! {
+    pub static COUNTERS = "Counters" {
+        out "TrackCount" => TRACKCOUNT,
+        out "DetailsCount" => DETAILSCOUNT,
+        out "MetatextCount" => METATEXTCOUNT,
+    }
+}
@@ -0,0 +1,13 @@
+use crate::info::variables::{BITDEPTH, BITRATE, CODECNAME, DURATION, LOSSLESS, SAMPLERATE};
+use pmoupnp::define_action;
+
+define_action! {
+    pub static DETAILS = "Details" {
+        out "Duration" => DURATION,
+        out "BitRate" => BITRATE,
+        out "BitDepth" => BITDEPTH,
+        out "SampleRate" => SAMPLERATE,
+        out "Lossless" => LOSSLESS,
+        out "CodecName" => CODECNAME,
+    }
+}
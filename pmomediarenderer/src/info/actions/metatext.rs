@@ -0,0 +1,8 @@
+use crate::info::variables::METATEXT;
+use pmoupnp::define_action;
+
+define_action! {
+    pub static METATEXT_ACTION = "Metatext" {
+        out "Value" => METATEXT,
+    }
+}
@@ -0,0 +1,9 @@
+mod counters;
+mod details;
+mod metatext;
+mod track;
+
+pub use counters::COUNTERS;
+pub use details::DETAILS;
+pub use metatext::METATEXT_ACTION;
+pub use track::TRACK;
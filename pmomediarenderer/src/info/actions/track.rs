@@ -0,0 +1,9 @@
+use crate::info::variables::{METADATA, URI};
+use pmoupnp::define_action;
+
+define_action! {
+    pub static TRACK = "Track" {
+        out "Uri" => URI,
+        out "Metadata" => METADATA,
+    }
+}
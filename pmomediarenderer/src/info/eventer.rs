@@ -0,0 +1,119 @@
+//! Alimentation des variables du service Info depuis la piste courante
+
+use std::sync::Arc;
+use std::time::Duration;
+
+use pmoupnp::devices::DeviceInstance;
+use tokio_util::sync::CancellationToken;
+
+use crate::state::SharedState;
+
+/// Période d'échantillonnage de la piste courante
+const INFO_EVENT_PERIOD: Duration = Duration::from_secs(1);
+
+/// Valeurs courantes du service Info
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub(crate) struct InfoValues {
+    pub track_count: u32,
+    /// 1 dès que le format de la piste courante est connu
+    pub details_count: u32,
+    pub uri: String,
+    pub metadata: String,
+    pub duration: u32,
+    pub bit_depth: u32,
+    pub sample_rate: u32,
+    pub lossless: bool,
+    pub codec_name: String,
+}
+
+impl InfoValues {
+    pub(crate) fn from_state(state: &SharedState) -> Self {
+        let s = state.read();
+        let now = &s.now_playing;
+        Self {
+            track_count: s.track_count,
+            details_count: u32::from(now.codec.is_some()),
+            uri: now.uri.clone().unwrap_or_default(),
+            metadata: now.metadata.clone().unwrap_or_default(),
+            duration: now.duration_sec.map_or(0, |d| d as u32),
+            bit_depth: now.bit_depth.map_or(0, u32::from),
+            sample_rate: now.sample_rate.unwrap_or(0),
+            lossless: now.lossless.unwrap_or(false),
+            codec_name: now.codec.clone().unwrap_or_default(),
+        }
+    }
+}
+
+/// Lance la tâche qui recopie la piste courante dans le service Info.
+///
+/// Comme pour le service Time, l'état est échantillonné toutes les secondes
+/// et seules les variables modifiées sont mises à jour.
+///
+/// Retourne `None` si le device n'expose pas le service Info.
+pub fn spawn_info_eventer(
+    device: &Arc<DeviceInstance>,
+    state: SharedState,
+    stop_token: CancellationToken,
+) -> Option<tokio::task::JoinHandle<()>> {
+    let service = device.get_service("Info")?;
+    let track_count = service.get_typed_variable::<u32>("TrackCount")?;
+    let details_count = service.get_typed_variable::<u32>("DetailsCount")?;
+    let uri = service.get_typed_variable::<String>("Uri")?;
+    let metadata = service.get_typed_variable::<String>("Metadata")?;
+    let duration = service.get_typed_variable::<u32>("Duration")?;
+    let bit_depth = service.get_typed_variable::<u32>("BitDepth")?;
+    let sample_rate = service.get_typed_variable::<u32>("SampleRate")?;
+    let lossless = service.get_typed_variable::<bool>("Lossless")?;
+    let codec_name = service.get_typed_variable::<String>("CodecName")?;
+
+    Some(tokio::spawn(async move {
+        let mut ticker = tokio::time::interval(INFO_EVENT_PERIOD);
+        let mut last: Option<InfoValues> = None;
+
+        loop {
+            tokio::select! {
+                _ = stop_token.cancelled() => break,
+                _ = ticker.tick() => {}
+            }
+
+            let current = InfoValues::from_state(&state);
+            if last.as_ref() == Some(&current) {
+                continue;
+            }
+            let first = last.is_none();
+            let previous = last.unwrap_or_default();
+
+            if first || previous.track_count != current.track_count {
+                let _ = track_count.set(current.track_count).await;
+            }
+            if first || previous.details_count != current.details_count {
+                let _ = details_count.set(current.details_count).await;
+            }
+            if first || previous.uri != current.uri {
+                let _ = uri.set(current.uri.clone()).await;
+            }
+            if first || previous.metadata != current.metadata {
+                let _ = metadata.set(current.metadata.clone()).await;
+            }
+            if first || previous.duration != current.duration {
+                let _ = duration.set(current.duration).await;
+            }
+            if first || previous.bit_depth != current.bit_depth {
+                let _ = bit_depth.set(current.bit_depth).await;
+            }
+            if first || previous.sample_rate != current.sample_rate {
+                let _ = sample_rate.set(current.sample_rate).await;
+            }
+            if first || previous.lossless != current.lossless {
+                let _ = lossless.set(current.lossless).await;
+            }
+            if first || previous.codec_name != current.codec_name {
+                let _ = codec_name.set(current.codec_name.clone()).await;
+            }
+
+            last = Some(current);
+        }
+
+        tracing::debug!("[MediaRenderer] Info eventer stopped");
+    }))
+}
@@ -0,0 +1,68 @@
+//! # Info Service - Service OpenHome de description de la piste courante
+//!
+//! Ce module implémente le service `urn:av-openhome-org:service:Info:1`.
+//! Les control points OpenHome y lisent l'URI, les métadonnées DIDL-Lite et
+//! le format du flux en cours de lecture (codec, fréquence, profondeur).
+//!
+//! ## Fonctionnalités
+//!
+//! - **Counters** : retourne TrackCount, DetailsCount et MetatextCount
+//! - **Track** : retourne Uri et Metadata
+//! - **Details** : retourne Duration, BitRate, BitDepth, SampleRate, Lossless
+//!   et CodecName
+//! - **Metatext** : retourne Metatext
+//!
+//! ## Variables d'état
+//!
+//! Toutes les variables sont évènementielles et projetées depuis
+//! [`NowPlaying`](crate::now_playing::NowPlaying) par
+//! [`spawn_info_eventer`](crate::info::spawn_info_eventer). `BitRate` vaut 0
+//! (débit non mesuré) et `Metatext` reste vide : le renderer ne lit pas les
+//! métadonnées ICY des radios.
+//!
+//! ## Références
+//!
+//! - [OpenHome Info:1](http://wiki.openhome.org/wiki/Av:Developer:InfoService)
+
+use pmoupnp::define_service;
+
+pub mod actions;
+mod eventer;
+pub mod variables;
+
+pub use eventer::spawn_info_eventer;
+pub(crate) use eventer::InfoValues;
+
+use actions::{COUNTERS, DETAILS, METATEXT_ACTION, TRACK};
+use variables::{
+    BITDEPTH, BITRATE, CODECNAME, DETAILSCOUNT, DURATION, LOSSLESS, METADATA, METATEXT,
+    METATEXTCOUNT, SAMPLERATE, TRACKCOUNT, URI,
+};
+
+// Service Info:1 OpenHome
+// Voir la documentation du module pour plus de détails
+define_service! {
+    pub static INFO = "Info" {
+        domain: "av-openhome-org",
+        variables: [
+            BITDEPTH,
+            BITRATE,
+            CODECNAME,
+            DETAILSCOUNT,
+            DURATION,
+            LOSSLESS,
+            METADATA,
+            METATEXT,
+            METATEXTCOUNT,
+            SAMPLERATE,
+            TRACKCOUNT,
+            URI,
+        ],
+        actions: [
+            COUNTERS,
+            DETAILS,
+            METATEXT_ACTION,
+            TRACK,
+        ]
+    }
+}
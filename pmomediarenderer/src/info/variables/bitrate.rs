@@ -0,0 +1,7 @@
+use pmoupnp::define_variable;
+
+define_variable! {
+    pub static BITRATE: UI4 = "BitRate" {
+        evented: true,
+    }
+}
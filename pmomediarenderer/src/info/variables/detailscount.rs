@@ -0,0 +1,7 @@
+use pmoupnp::define_variable;
+
+define_variable! {
+    pub static DETAILSCOUNT: UI4 = "DetailsCount" {
+        evented: true,
+    }
+}
@@ -0,0 +1,25 @@
+mod bitdepth;
+mod bitrate;
+mod codecname;
+mod detailscount;
+mod duration;
+mod lossless;
+mod metadata;
+mod metatext;
+mod metatextcount;
+mod samplerate;
+mod trackcount;
+mod uri;
+
+pub use bitdepth::BITDEPTH;
+pub use bitrate::BITRATE;
+pub use codecname::CODECNAME;
+pub use detailscount::DETAILSCOUNT;
+pub use duration::DURATION;
+pub use lossless::LOSSLESS;
+pub use metadata::METADATA;
+pub use metatext::METATEXT;
+pub use metatextcount::METATEXTCOUNT;
+pub use samplerate::SAMPLERATE;
+pub use trackcount::TRACKCOUNT;
+pub use uri::URI;
@@ -0,0 +1,7 @@
+use pmoupnp::define_variable;
+
+define_variable! {
+    pub static SAMPLERATE: UI4 = "SampleRate" {
+        evented: true,
+    }
+}
@@ -0,0 +1,7 @@
+use pmoupnp::define_variable;
+
+define_variable! {
+    pub static TRACKCOUNT: UI4 = "TrackCount" {
+        evented: true,
+    }
+}
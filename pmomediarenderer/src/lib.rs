@@ -11,23 +11,54 @@
 //! - **AVTransport** : Contrôle de la lecture (play, pause, stop, seek, etc.)
 //! - **RenderingControl** : Contrôle du volume et du mute
 //! - **ConnectionManager** : Gestion des connexions et des protocoles supportés
+//!
+//! Les services OpenHome **Time** (barre de progression) et **Info** (URI,
+//! métadonnées et format de la piste courante) sont également exposés pour
+//! les control points OpenHome.
+//!
+//! La piste courante est décrite par un unique [`NowPlaying`], dont
+//! AVTransport, OpenHome et l'API REST ne sont que des projections. Une URI
+//! reçue sans métadonnées DIDL-Lite est sondée pour en synthétiser
+//! (voir [`sniff`]).
+//!
+//! Avec la feature `pmoserver`, l'AVTransport expose aussi les actions vendor
+//! `X_PMO_QueueList/Insert/Remove/Move` pour manipuler la file gapless interne.
 
 pub mod adapter;
+pub mod announce;
 pub mod avtransport;
+pub mod config_ext;
 pub mod connectionmanager;
+pub mod dsp_chain;
 pub mod error;
 pub mod handlers;
+pub mod icecast;
+pub mod idle;
+pub mod info;
 pub mod messages;
+pub mod now_playing;
 pub mod pipeline;
+#[cfg(feature = "pmoserver")]
+pub mod queue;
 pub mod registry;
 pub mod renderingcontrol;
 pub mod renderer;
+pub mod session;
+pub mod sniff;
 pub mod state;
+pub mod time;
 
+pub use config_ext::{MediaRendererConfigExt, OutputProfile};
+pub use pmoaudio::{CrossfeedParams, ResamplingStats};
+pub use pmoaudio_ext::LatencySnapshot;
 pub use error::MediaRendererError;
 pub use handlers::*;
 pub use messages::PlaybackState;
-pub use pipeline::{PipelineControl, PipelineHandle, seconds_to_upnp_time, upnp_time_to_seconds, InstancePipeline};
+pub use now_playing::NowPlaying;
+pub use pipeline::{PipelineControl, PipelineGraph, PipelineHandle, seconds_to_upnp_time, upnp_time_to_seconds, InstancePipeline};
+#[cfg(feature = "pmoserver")]
+pub use queue::RendererQueue;
 pub use registry::{MediaRendererInstance, MediaRendererRegistry};
+pub use session::TransportSession;
 pub use state::{RendererState, SharedState};
 pub use adapter::{DeviceAdapter, DeviceCommand, DevicePlaybackState, DeviceStateReport};
\ No newline at end of file
@@ -0,0 +1,127 @@
+//! Piste en cours de lecture
+//!
+//! [`NowPlaying`] est l'unique description de la piste courante. Les actions
+//! AVTransport y enregistrent l'URI et les métadonnées DIDL-Lite, le
+//! pipeline la complète depuis les événements du lecteur (durée, position,
+//! format du flux décodé). AVTransport (`GetPositionInfo`,
+//! `GetMediaInfo`), les services OpenHome Time et Info et l'API REST n'en
+//! sont que des projections.
+
+use pmoaudio_ext::StreamFormat;
+use serde::Serialize;
+
+use crate::pipeline::seconds_to_upnp_time;
+
+/// Piste courante du renderer
+#[derive(Debug, Clone, Default, PartialEq, Serialize)]
+pub struct NowPlaying {
+    pub uri: Option<String>,
+    /// Métadonnées DIDL-Lite fournies avec l'URI
+    pub metadata: Option<String>,
+    /// Durée (secondes), inconnue pour un flux continu
+    pub duration_sec: Option<f64>,
+    /// Position audible (secondes)
+    pub position_sec: Option<f64>,
+    /// Codec de la source (`FLAC`, `MP3`, `Vorbis`...)
+    pub codec: Option<String>,
+    /// Fréquence d'échantillonnage de la source (Hz)
+    pub sample_rate: Option<u32>,
+    /// Profondeur de la source (bits)
+    pub bit_depth: Option<u8>,
+    /// Codec sans perte
+    pub lossless: Option<bool>,
+    /// Origine du flux : `file` ou hôte HTTP
+    pub source: Option<String>,
+}
+
+impl NowPlaying {
+    /// Sélectionne une piste (SetAVTransportURI, piste suivante) ; durée,
+    /// position et format attendent le démarrage de la lecture.
+    pub fn load(&mut self, uri: String, metadata: Option<String>) {
+        *self = Self {
+            source: source_of(&uri),
+            uri: Some(uri),
+            metadata,
+            ..Self::default()
+        };
+    }
+
+    /// Lecture démarrée : durée et format du flux décodé
+    pub fn start(&mut self, duration_sec: Option<f64>, format: &StreamFormat) {
+        self.duration_sec = duration_sec.filter(|d| *d > 0.0);
+        self.position_sec = None;
+        self.codec = Some(format.codec.name().to_string());
+        self.sample_rate = Some(format.sample_rate);
+        self.bit_depth = Some(format.bits_per_sample);
+        self.lossless = Some(format.codec.is_lossless());
+    }
+
+    /// Y a-t-il une piste sélectionnée ?
+    pub fn has_track(&self) -> bool {
+        self.uri.is_some()
+    }
+
+    /// Durée au format UPnP `H:MM:SS`
+    pub fn duration_upnp(&self) -> Option<String> {
+        self.duration_sec.map(seconds_to_upnp_time)
+    }
+
+    /// Position au format UPnP `H:MM:SS`
+    pub fn position_upnp(&self) -> Option<String> {
+        self.position_sec.map(seconds_to_upnp_time)
+    }
+}
+
+/// Origine d'un flux d'après son URI
+fn source_of(uri: &str) -> Option<String> {
+    match uri.split_once("://") {
+        Some((scheme, rest))
+            if scheme.eq_ignore_ascii_case("http") || scheme.eq_ignore_ascii_case("https") =>
+        {
+            let authority = rest.split(['/', '?', '#']).next().unwrap_or_default();
+            let host = authority.rsplit('@').next().unwrap_or_default();
+            (!host.is_empty()).then(|| host.to_string())
+        }
+        _ => Some("file".to_string()),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use pmoflac::AudioCodec;
+
+    #[test]
+    fn test_now_playing_lifecycle() {
+        let mut now = NowPlaying {
+            position_sec: Some(12.0),
+            codec: Some("MP3".into()),
+            ..Default::default()
+        };
+        now.load(
+            "http://192.168.1.5:8080/audio/track.flac".into(),
+            Some("<DIDL-Lite/>".into()),
+        );
+        assert_eq!(now.source.as_deref(), Some("192.168.1.5:8080"));
+        assert_eq!(now.position_sec, None);
+        assert_eq!(now.codec, None);
+
+        let format = StreamFormat {
+            codec: AudioCodec::Flac,
+            sample_rate: 96_000,
+            bits_per_sample: 24,
+            channels: 2,
+        };
+        now.start(Some(185.0), &format);
+        now.position_sec = Some(61.4);
+        assert_eq!(now.duration_upnp().as_deref(), Some("0:03:05"));
+        assert_eq!(now.position_upnp().as_deref(), Some("0:01:01"));
+        assert_eq!(now.codec.as_deref(), Some("FLAC"));
+        assert_eq!((now.sample_rate, now.bit_depth), (Some(96_000), Some(24)));
+        assert_eq!(now.lossless, Some(true));
+        assert_eq!(now.metadata.as_deref(), Some("<DIDL-Lite/>"));
+
+        now.load("/music/track.mp3".into(), None);
+        assert_eq!(now.source.as_deref(), Some("file"));
+    }
+}
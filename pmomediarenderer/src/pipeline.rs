@@ -5,14 +5,29 @@
 //! - 一个 `StreamingOggFlacSink` 编码并向 HTTP 客户端传输 OGG-FLAC 流
 //! - 规范化节点（重采样 → 96 kHz，转换 → I24）
 
+use std::path::PathBuf;
+use std::sync::atomic::{AtomicBool, Ordering};
 use std::sync::Arc;
-use pmoaudio::{ResamplingNode, ToI24Node};
+use std::time::Duration;
+use pmoaudio::dsp::convolution::ImpulseResponse;
+use pmoaudio::nodes::AudioError;
+use pmoaudio::{
+    AnalysisHandle, AnalysisNode, AnnounceClip, AnnounceHandle, AnnounceSource, ChannelMixHandle,
+    ChannelMixNode, ConvolutionHandle, ConvolutionNode, CrossfeedHandle, CrossfeedNode,
+    CrossfeedParams, FadeCurve, MixerHandle, MixerNode, RecorderConfig, RecorderHandle,
+    RecorderNode, ResampleTarget, ResamplingHandle, ResamplingNode, ToI24Node, VolumeRampHandle,
+    VolumeRampNode,
+};
 use pmoaudio_ext::{PlayerCommand, PlayerHandle, PlayerSource};
 use pmoaudio_ext::sinks::{OggFlacStreamHandle, StreamingOggFlacSink};
 use pmoflac::EncoderOptions;
+use parking_lot::Mutex;
 use tokio_util::sync::CancellationToken;
-use tracing::{debug, warn};
+use tracing::{debug, info, warn};
 
+use crate::config_ext::{MediaRendererConfigExt, OutputProfile};
+use crate::dsp_chain::{bit_perfect_chain, default_chain, StageSpec};
+use crate::error::MediaRendererError;
 use crate::state::SharedState;
 
 // ─── Ré-export des commandes pour les handlers ────────────────────────────────
@@ -27,12 +42,217 @@ pub struct PipelineHandle {
     pub stop_token: CancellationToken,
     pub flac_handle: pmoaudio_ext::sinks::OggFlacStreamHandle,
     pub adapter: Arc<dyn crate::adapter::DeviceAdapter>,
-    #[allow(dead_code)]
+    /// Volume logiciel (rampe de gain)
+    pub volume: VolumeRampHandle,
+    /// Balance, inversion des canaux et mono
+    pub channels: ChannelMixHandle,
+    /// Crossfeed (sorties casque uniquement)
+    pub crossfeed: CrossfeedHandle,
+    /// Mixeur de l'étage `announce` (annonces et fondus de transition)
+    pub mixer: MixerHandle,
+    /// Annonces mixées par-dessus la musique
+    pub announce: AnnounceHandle,
+    /// Convolution de correction de pièce
+    pub convolution: ConvolutionHandle,
+    /// Niveaux et spectre du signal restitué (pour l'interface)
+    pub analysis: AnalysisHandle,
+    /// Enregistrement du signal restitué en FLAC
+    pub recorder: RecorderHandle,
+    /// Fréquences, qualité et compteurs du resampler
+    pub resampling: ResamplingHandle,
+    /// UDN de l'instance (clé des réglages persistés)
+    pub udn: String,
+    /// File de lecture interne (gérée par le ControlPoint)
+    #[cfg(feature = "pmoserver")]
+    pub queue: crate::queue::RendererQueue,
+    /// Graphe de traitement, détruit en veille (voir [`crate::idle`])
+    pub graph: Arc<PipelineGraph>,
+    /// Durée des fondus sur arrêt, pause et changement de piste (0 : aucun)
+    transition: Duration,
     state: SharedState,
 }
 
 impl PipelineHandle {
+    /// Applique et enregistre la balance (-100..=100) et l'inversion des canaux
+    pub fn set_channel_mix(&self, balance: i16, channel_swap: bool) {
+        let balance = balance.clamp(-100, 100);
+        {
+            let mut s = self.state.write();
+            s.balance = balance;
+            s.channel_swap = channel_swap;
+        }
+        self.channels.set_balance(balance as i32);
+        self.channels.set_channel_swap(channel_swap);
+
+        let config = pmoconfig::get_config();
+        if let Err(e) = config
+            .set_renderer_balance(&self.udn, balance)
+            .and_then(|_| config.set_renderer_channel_swap(&self.udn, channel_swap))
+        {
+            warn!(udn = %self.udn, "Cannot persist channel settings: {}", e);
+        }
+    }
+
+    /// Applique et enregistre le downmix mono (-3 dB)
+    pub fn set_mono(&self, mono: bool) {
+        self.state.write().mono = mono;
+        self.channels.set_mono(mono);
+
+        if let Err(e) = pmoconfig::get_config().set_renderer_mono(&self.udn, mono) {
+            warn!(udn = %self.udn, "Cannot persist mono setting: {}", e);
+        }
+    }
+
+    /// Applique et enregistre le type de sortie et les réglages de crossfeed.
+    ///
+    /// Le crossfeed n'est effectif que si la sortie est un casque.
+    pub fn set_crossfeed(&self, profile: OutputProfile, enabled: bool, params: CrossfeedParams) {
+        {
+            let mut s = self.state.write();
+            s.output_profile = profile;
+            s.crossfeed = enabled;
+        }
+        self.crossfeed.set_params(params);
+        self.crossfeed
+            .set_enabled(enabled && profile == OutputProfile::Headphones);
+
+        let config = pmoconfig::get_config();
+        if let Err(e) = config
+            .set_renderer_output_profile(&self.udn, profile)
+            .and_then(|_| config.set_renderer_crossfeed(&self.udn, enabled, params))
+        {
+            warn!(udn = %self.udn, "Cannot persist crossfeed settings: {}", e);
+        }
+    }
+
+    /// Démarre ou arrête l'enregistrement (persisté pour le redémarrage)
+    pub fn set_recording(&self, recording: bool) {
+        self.recorder.set_recording(recording);
+
+        if let Err(e) = pmoconfig::get_config().set_renderer_recording(&self.udn, recording) {
+            warn!(udn = %self.udn, "Cannot persist recording state: {}", e);
+        }
+    }
+
+    /// Active ou désactive le silence de maintien à l'arrêt et en pause
+    /// (persisté pour le redémarrage)
+    pub fn set_keepalive(&self, keepalive: bool) {
+        self.player.set_keepalive(keepalive);
+
+        if let Err(e) = pmoconfig::get_config().set_renderer_keepalive(&self.udn, keepalive) {
+            warn!(udn = %self.udn, "Cannot persist keepalive setting: {}", e);
+        }
+    }
+
+    /// Charge et enregistre la réponse impulsionnelle de correction de pièce
+    /// (None désactive la convolution)
+    pub fn set_impulse_response(&self, path: Option<PathBuf>) -> Result<(), AudioError> {
+        let impulse = path.as_deref().map(ImpulseResponse::load_wav).transpose()?;
+        self.convolution.set_impulse_response(impulse);
+
+        if let Err(e) = pmoconfig::get_config().set_renderer_impulse_response(&self.udn, path) {
+            warn!(udn = %self.udn, "Cannot persist impulse response: {}", e);
+        }
+        Ok(())
+    }
+
+    /// Met une annonce en file ; retourne sa position dans la file
+    ///
+    /// L'annonce n'est entendue que pendant la lecture, et seulement si la
+    /// chaîne du renderer contient l'étage `announce` (hors mode bit-perfect).
+    pub fn announce(&self, clip: AnnounceClip) -> Result<usize, MediaRendererError> {
+        if !self.graph.stages().contains(&StageSpec::Announce) {
+            return Err(MediaRendererError::AnnouncementError(
+                "the renderer pipeline has no active announce stage".into(),
+            ));
+        }
+        let duration = clip.duration();
+        let position = self
+            .announce
+            .announce(clip)
+            .map_err(|e| MediaRendererError::AnnouncementError(e.to_string()))?;
+        info!(
+            udn = %self.udn,
+            "📢 Announcement queued ({:.1} s, position {})",
+            duration.as_secs_f32(),
+            position
+        );
+        Ok(position)
+    }
+
+    /// Active ou désactive le mode bit-perfect (persisté pour le redémarrage)
+    ///
+    /// Le graphe est reconstruit avec la nouvelle chaîne ; une lecture en
+    /// cours reprend à la même position après une courte coupure.
+    pub async fn set_bit_perfect(&self, bit_perfect: bool) {
+        self.state.write().bit_perfect = bit_perfect;
+        if let Err(e) = pmoconfig::get_config().set_renderer_bit_perfect(&self.udn, bit_perfect) {
+            warn!(udn = %self.udn, "Cannot persist bit-perfect mode: {}", e);
+        }
+
+        if !self.graph.set_bit_perfect(bit_perfect) || !self.graph.teardown() {
+            return;
+        }
+        info!(udn = %self.udn, "🎚️ Bit-perfect mode {}", if bit_perfect { "on" } else { "off" });
+
+        let (uri, position) = {
+            let s = self.state.read();
+            let playing = matches!(s.playback_state, crate::messages::PlaybackState::Playing);
+            (
+                s.now_playing.uri.clone(),
+                playing.then(|| s.now_playing.position_sec.unwrap_or(0.0)),
+            )
+        };
+        if self.graph.wake().await {
+            if let Some(uri) = uri {
+                self.player.load_uri(uri).await;
+                if let Some(position) = position {
+                    self.player.seek(position).await;
+                }
+            }
+        }
+    }
+
+    /// Durée du fondu de transition, None si la lecture est arrêtée ou si
+    /// la chaîne n'a pas d'étage `announce` (le mixeur)
+    fn transition(&self) -> Option<Duration> {
+        let playing = matches!(
+            self.state.read().playback_state,
+            crate::messages::PlaybackState::Playing
+        );
+        (playing
+            && !self.transition.is_zero()
+            && self.graph.stages().contains(&StageSpec::Announce))
+        .then_some(self.transition)
+    }
+
     pub async fn send(&self, cmd: PipelineControl) {
+        // Pipeline détruit en veille : la nouvelle source part de l'état
+        // Stopped, on lui redonne la piste courante
+        if self.graph.wake().await && !matches!(cmd, PlayerCommand::LoadUri(_)) {
+            let current_uri = self.state.read().now_playing.uri.clone();
+            if let Some(uri) = current_uri {
+                self.player.load_uri(uri).await;
+            }
+        }
+
+        // Fondu de sortie avant d'interrompre la piste en cours ; le gain
+        // reste nul jusqu'à la reprise
+        let interrupts = matches!(
+            cmd,
+            PlayerCommand::LoadUri(_) | PlayerCommand::Stop | PlayerCommand::Pause
+        );
+        if let Some(fade) = self.transition().filter(|_| interrupts) {
+            let _ = self.mixer.set_gain(0, 0.0, fade, FadeCurve::Linear);
+            tokio::time::sleep(fade).await;
+        }
+        let resumes = matches!(cmd, PlayerCommand::LoadUri(_) | PlayerCommand::Play);
+        if resumes && self.mixer.gain(0).is_some_and(|gain| gain < 1.0) {
+            let _ = self
+                .mixer
+                .set_gain(0, 1.0, self.transition, FadeCurve::Linear);
+        }
+
         match cmd {
             PlayerCommand::LoadUri(uri) => self.player.load_uri(uri).await,
             PlayerCommand::LoadNextUri(uri) => self.player.load_next_uri(uri).await,
@@ -44,6 +264,192 @@ impl PipelineHandle {
     }
 }
 
+// ─── Graphe de traitement ────────────────────────────────────────────────────
+
+/// Graphe audio d'une instance, détruit après une période d'inactivité et
+/// reconstruit à la première commande.
+///
+/// Les nœuds (décodeur, resampler, DSP, encodeur) n'existent que tant que le
+/// graphe tourne ; leurs handles sont conservés, si bien que réglages,
+/// abonnés d'analyse et URL de flux survivent à une reconstruction.
+pub struct PipelineGraph {
+    chain: Vec<StageSpec>,
+    player: PlayerHandle,
+    flac_handle: OggFlacStreamHandle,
+    volume: VolumeRampHandle,
+    channels: ChannelMixHandle,
+    crossfeed: CrossfeedHandle,
+    mixer: MixerHandle,
+    announce: AnnounceHandle,
+    convolution: ConvolutionHandle,
+    analysis: AnalysisHandle,
+    recorder: RecorderHandle,
+    resampling: ResamplingHandle,
+    udn: String,
+    /// Chaîne réduite aux étages qui ne modifient pas le signal
+    bit_perfect: AtomicBool,
+    /// Arrêt de l'instance (parent des jetons de graphe)
+    stop_token: CancellationToken,
+    /// Jeton du graphe en cours, None s'il est détruit
+    running: Mutex<Option<CancellationToken>>,
+}
+
+impl PipelineGraph {
+    /// Étages de la chaîne de traitement
+    pub fn chain(&self) -> &[StageSpec] {
+        &self.chain
+    }
+
+    /// Étages effectivement construits (chaîne réduite en mode bit-perfect)
+    pub fn stages(&self) -> Vec<StageSpec> {
+        if self.is_bit_perfect() {
+            bit_perfect_chain(&self.chain)
+        } else {
+            self.chain.clone()
+        }
+    }
+
+    /// Le mode bit-perfect est-il actif ?
+    pub fn is_bit_perfect(&self) -> bool {
+        self.bit_perfect.load(Ordering::Relaxed)
+    }
+
+    /// Change de mode, pris en compte à la prochaine construction ;
+    /// retourne true si le mode a changé.
+    fn set_bit_perfect(&self, bit_perfect: bool) -> bool {
+        self.bit_perfect.swap(bit_perfect, Ordering::Relaxed) != bit_perfect
+    }
+
+    /// Le graphe est-il construit ?
+    pub fn is_running(&self) -> bool {
+        self.running.lock().is_some()
+    }
+
+    /// Détruit le graphe ; retourne false s'il l'était déjà.
+    ///
+    /// Les commandes reçues ensuite restent en file jusqu'à [`Self::wake`].
+    pub fn teardown(&self) -> bool {
+        match self.running.lock().take() {
+            Some(token) => {
+                token.cancel();
+                true
+            }
+            None => false,
+        }
+    }
+
+    /// Reconstruit le graphe s'il a été détruit ; retourne true s'il l'a été.
+    pub async fn wake(&self) -> bool {
+        // La source précédente rend sa file de commandes en se terminant
+        for _ in 0..20 {
+            if self.is_running() {
+                return false;
+            }
+            if self.build() {
+                info!(udn = %self.udn, "[MediaRenderer] Audio pipeline rebuilt");
+                return true;
+            }
+            tokio::time::sleep(Duration::from_millis(50)).await;
+        }
+        warn!(udn = %self.udn, "[MediaRenderer] Audio pipeline could not be rebuilt");
+        false
+    }
+
+    /// Construit et lance le graphe s'il n'est pas déjà en cours.
+    fn build(&self) -> bool {
+        use pmoaudio::pipeline::AudioPipelineNode;
+
+        let mut running = self.running.lock();
+        if running.is_some() || self.stop_token.is_cancelled() {
+            return false;
+        }
+        let Some(mut player_source) = PlayerSource::attach(&self.player) else {
+            return false;
+        };
+
+        let sink = StreamingOggFlacSink::attach(EncoderOptions::default(), 24, &self.flac_handle);
+        let mut to_i24 = ToI24Node::new();
+        to_i24.register(sink.boxed());
+
+        let mut analysis_node = Some(
+            AnalysisNode::with_handle(
+                &self.analysis,
+                pmoaudio::nodes::analysis_node::DEFAULT_ANALYSIS_RATE_HZ,
+            )
+            .boxed(),
+        );
+        let mut recorder_node = Some(RecorderNode::with_handle(self.recorder.clone()).boxed());
+        // Compteurs propres à chaque graphe (nouveau flux, chaîne éventuellement sans resampler)
+        self.resampling.reset();
+        let mut volume_node = Some(VolumeRampNode::with_handle(self.volume.clone()).boxed());
+        let mut crossfeed_node = Some(CrossfeedNode::with_handle(self.crossfeed.clone()).boxed());
+        let mut mixer_node = Some(MixerNode::with_handle(self.mixer.clone()).boxed());
+        let mut channel_node = Some(ChannelMixNode::with_handle(self.channels.clone()).boxed());
+        let mut convolution_node =
+            Some(ConvolutionNode::with_handle(self.convolution.clone()).boxed());
+
+        // Chaînage de la fin vers le début (parse_chain garantit l'unicité des étages)
+        let stages = self.stages();
+        let mut next: Box<dyn AudioPipelineNode> = to_i24.boxed();
+        for stage in stages.iter().rev() {
+            let mut node = match stage {
+                StageSpec::Resample(rate) => Some(
+                    ResamplingNode::with_handle(
+                        ResampleTarget::Fixed(*rate),
+                        self.resampling.clone(),
+                    )
+                    .boxed(),
+                ),
+                StageSpec::ResampleFamily(rate) => Some(
+                    ResamplingNode::with_handle(
+                        ResampleTarget::family(*rate),
+                        self.resampling.clone(),
+                    )
+                    .boxed(),
+                ),
+                StageSpec::Convolution => convolution_node.take(),
+                StageSpec::Channels => channel_node.take(),
+                StageSpec::Crossfeed => crossfeed_node.take(),
+                StageSpec::Announce => mixer_node.take(),
+                StageSpec::Volume => volume_node.take(),
+                StageSpec::Recorder => recorder_node.take(),
+                StageSpec::Analysis => analysis_node.take(),
+            }
+            .expect("pipeline stages are unique");
+            node.register(next);
+            next = node;
+        }
+        player_source.register(next);
+
+        let graph_token = self.stop_token.child_token();
+        let sink_stop = graph_token.clone();
+        tokio::spawn(async move {
+            if let Err(e) = player_source.boxed().run(sink_stop).await {
+                warn!("Audio pipeline error: {:?}", e);
+            }
+            debug!("Pipeline task terminated");
+        });
+
+        // Les annonces alimentent l'entrée auxiliaire du mixeur
+        if stages.contains(&StageSpec::Announce) {
+            match AnnounceSource::with_handle(self.announce.clone()) {
+                Ok(announce_source) => {
+                    let announce_stop = graph_token.clone();
+                    tokio::spawn(async move {
+                        if let Err(e) = announce_source.boxed().run(announce_stop).await {
+                            warn!("Announce source error: {:?}", e);
+                        }
+                    });
+                }
+                Err(e) => warn!(udn = %self.udn, "Announcements unavailable: {}", e),
+            }
+        }
+
+        *running = Some(graph_token);
+        true
+    }
+}
+
 // ─── Pipeline instancié ──────────────────────────────────────────────────────
 
 pub struct InstancePipeline {
@@ -61,31 +467,153 @@ impl InstancePipeline {
     ) -> Self {
         let stop_token = CancellationToken::new();
 
-        use pmoaudio::pipeline::AudioPipelineNode;
+        // Les nœuds renvoyés ici ne servent pas : seuls les handles sont
+        // conservés, le graphe est (re)construit par `PipelineGraph`
+        let (_, flac_handle) = StreamingOggFlacSink::new(EncoderOptions::default(), 24);
 
-        let (sink, flac_handle) = StreamingOggFlacSink::new(EncoderOptions::default(), 24);
+        // Dérivation d'analyse sur le signal final (après volume)
+        let (_, analysis) =
+            AnalysisNode::new(pmoaudio::nodes::analysis_node::DEFAULT_ANALYSIS_RATE_HZ);
 
-        let mut to_i24 = ToI24Node::new();
-        to_i24.register(sink.boxed());
+        // Enregistrement du signal final, un sous-répertoire par renderer
+        let (_, recorder) = RecorderNode::new(recorder_config(&udn));
+        recorder.set_recording(
+            pmoconfig::get_config()
+                .get_renderer_recording(&udn)
+                .unwrap_or(false),
+        );
+
+        let ramp_ms = pmoconfig::get_config()
+            .get_volume_ramp_ms()
+            .unwrap_or(pmoaudio::nodes::volume_ramp_node::DEFAULT_VOLUME_RAMP_MS);
+        let (_, volume) = VolumeRampNode::new(ramp_ms);
+        {
+            let s = state.read();
+            volume.set_volume(s.volume, s.mute);
+        }
 
-        let mut resampler = ResamplingNode::new(96_000);
-        resampler.register(to_i24.boxed());
+        // Crossfeed, actif uniquement pour une sortie casque
+        let crossfeed = {
+            let config = pmoconfig::get_config();
+            let profile = config.get_renderer_output_profile(&udn).unwrap_or_default();
+            let (enabled, params) = config
+                .get_renderer_crossfeed(&udn)
+                .unwrap_or((true, CrossfeedParams::DEFAULT));
+            let (_, handle) = CrossfeedNode::new(params);
+            handle.set_enabled(enabled && profile == OutputProfile::Headphones);
+            let mut s = state.write();
+            s.output_profile = profile;
+            s.crossfeed = enabled;
+            handle
+        };
 
-        let (mut player_source, player_handle) = PlayerSource::new();
-        player_source.register(resampler.boxed());
+        // Mixeur de l'étage `announce` : annonces prioritaires (niveau de
+        // ducking et durée des rampes) et fondus de transition
+        let (mixer, announce, transition) = {
+            let config = pmoconfig::get_config();
+            let duck_db = config
+                .get_announce_duck_db()
+                .unwrap_or(pmoaudio::nodes::announce_source::DEFAULT_DUCK_DB);
+            let ramp_ms = config
+                .get_announce_ramp_ms()
+                .unwrap_or(pmoaudio::nodes::announce_source::DEFAULT_DUCK_RAMP_MS);
+            let (_, mixer) = MixerNode::new(duck_db);
+            let (_, announce) = AnnounceSource::new(&mixer, duck_db, ramp_ms)
+                .expect("a new mixer has a free input");
+            let transition_ms = config.get_renderer_transition_ms().unwrap_or(0);
+            (
+                mixer,
+                announce,
+                Duration::from_millis(u64::from(transition_ms)),
+            )
+        };
 
-        let sink_stop = stop_token.clone();
-        tokio::spawn(async move {
-            if let Err(e) = player_source.boxed().run(sink_stop).await {
-                warn!("Audio pipeline error: {:?}", e);
+        // Balance / inversion des canaux / mono, restaurés depuis la configuration
+        let (_, channels) = ChannelMixNode::new();
+        {
+            let config = pmoconfig::get_config();
+            let balance = config.get_renderer_balance(&udn).unwrap_or(0);
+            let channel_swap = config.get_renderer_channel_swap(&udn).unwrap_or(false);
+            let mono = config.get_renderer_mono(&udn).unwrap_or(false);
+            channels.set_balance(balance as i32);
+            channels.set_channel_swap(channel_swap);
+            channels.set_mono(mono);
+            let mut s = state.write();
+            s.balance = balance;
+            s.channel_swap = channel_swap;
+            s.mono = mono;
+        }
+
+        // Correction de pièce : la réponse doit être à la fréquence du flux
+        // à cet endroit de la chaîne (96 kHz après le resampler par défaut)
+        let (_, convolution) = ConvolutionNode::new();
+        if let Ok(Some(path)) = pmoconfig::get_config().get_renderer_impulse_response(&udn) {
+            match ImpulseResponse::load_wav(&path) {
+                Ok(ir) => convolution.set_impulse_response(Some(ir)),
+                Err(e) => warn!(udn = %udn, "Cannot load impulse response {}: {}", path.display(), e),
             }
-            debug!("Pipeline task terminated");
+        }
+
+        // Chaîne déclarée dans la configuration ; les handles des étages
+        // absents restent valides mais sans effet sur le flux
+        let chain = pmoconfig::get_config()
+            .get_renderer_pipeline(&udn)
+            .unwrap_or_else(|e| {
+                warn!(udn = %udn, "Invalid renderer pipeline, using default: {}", e);
+                default_chain()
+            });
+        debug!(
+            udn = %udn,
+            "Renderer pipeline: {}",
+            chain.iter().map(ToString::to_string).collect::<Vec<_>>().join(" → ")
+        );
+
+        // Mode bit-perfect : chaîne réduite aux étages qui ne modifient pas le signal
+        let bit_perfect = pmoconfig::get_config()
+            .get_renderer_bit_perfect(&udn)
+            .unwrap_or(false);
+        state.write().bit_perfect = bit_perfect;
+        if bit_perfect {
+            info!(udn = %udn, "🎚️ Bit-perfect mode: resampling, volume and DSP bypassed");
+        }
+
+        // Silence de maintien pour les récepteurs qui perdent la synchronisation
+        let (_, player_handle) = PlayerSource::new();
+        player_handle.set_keepalive(
+            pmoconfig::get_config()
+                .get_renderer_keepalive(&udn)
+                .unwrap_or(false),
+        );
+
+        let resampling = ResamplingHandle::new();
+
+        let graph = Arc::new(PipelineGraph {
+            chain,
+            player: player_handle.clone(),
+            flac_handle: flac_handle.clone(),
+            volume: volume.clone(),
+            channels: channels.clone(),
+            crossfeed: crossfeed.clone(),
+            mixer: mixer.clone(),
+            announce: announce.clone(),
+            convolution: convolution.clone(),
+            analysis: analysis.clone(),
+            recorder: recorder.clone(),
+            resampling: resampling.clone(),
+            udn: udn.clone(),
+            bit_perfect: AtomicBool::new(bit_perfect),
+            stop_token: stop_token.clone(),
+            running: Mutex::new(None),
         });
+        if !graph.build() {
+            warn!(udn = %udn, "Audio pipeline could not be started");
+        }
 
         let event_rx = player_handle.subscribe_events();
         let state_clone = state.clone();
         let udn_clone = udn.clone();
         let adapter_clone = Arc::downgrade(&adapter);
+        let convolution_clone = convolution.clone();
         #[cfg(feature = "pmoserver")]
         let cp_clone = control_point.clone();
         tokio::spawn(async move {
@@ -93,6 +621,7 @@ impl InstancePipeline {
                 event_rx,
                 state_clone,
                 adapter_clone,
+                convolution_clone,
                 udn_clone,
                 #[cfg(feature = "pmoserver")]
                 cp_clone,
@@ -104,6 +633,20 @@ impl InstancePipeline {
             stop_token: stop_token.clone(),
             flac_handle: flac_handle.clone(),
             adapter,
+            volume,
+            channels,
+            crossfeed,
+            mixer,
+            announce,
+            convolution,
+            analysis,
+            recorder,
+            resampling,
+            udn: udn.clone(),
+            #[cfg(feature = "pmoserver")]
+            queue: crate::queue::RendererQueue::new(control_point, &udn),
+            graph,
+            transition,
             state,
         };
 
@@ -114,12 +657,34 @@ impl InstancePipeline {
     }
 }
 
+/// Paramètres d'enregistrement d'un renderer depuis la configuration
+fn recorder_config(udn: &str) -> RecorderConfig {
+    let config = pmoconfig::get_config();
+    let directory = config
+        .get_recorder_directory()
+        .unwrap_or_else(|_| "recordings".to_string());
+    let safe_udn: String = udn
+        .chars()
+        .map(|c| if c.is_ascii_alphanumeric() || c == '-' { c } else { '_' })
+        .collect();
+    let max_minutes = config.get_recorder_max_minutes().unwrap_or(60);
+    let max_megabytes = config.get_recorder_max_megabytes().unwrap_or(0);
+
+    RecorderConfig {
+        directory: PathBuf::from(directory).join(safe_udn),
+        prefix: "pmomusic".to_string(),
+        max_duration: (max_minutes > 0).then(|| Duration::from_secs(max_minutes as u64 * 60)),
+        max_bytes: (max_megabytes > 0).then(|| max_megabytes as u64 * 1024 * 1024),
+    }
+}
+
 // ─── Listener d'événements ────────────────────────────────────────────────────
 
 async fn run_event_listener(
     mut event_rx: tokio::sync::broadcast::Receiver<pmoaudio_ext::PlayerEvent>,
     state: SharedState,
     adapter: std::sync::Weak<dyn crate::adapter::DeviceAdapter>,
+    convolution: ConvolutionHandle,
     udn: String,
     #[cfg(feature = "pmoserver")]
     control_point: Arc<pmocontrol::ControlPoint>,
@@ -131,27 +696,37 @@ async fn run_event_listener(
     loop {
         match event_rx.recv().await {
             Ok(event) => match event {
-                PlayerEvent::Playing { uri, duration_sec } => {
+                PlayerEvent::Playing { uri, duration_sec, format } => {
                     let mut s = state.write();
                     s.playback_state = PlaybackState::Playing;
-                    s.current_uri = Some(uri);
-                    s.duration = duration_sec.map(seconds_to_upnp_time);
-                    s.position = None;
+                    // Enchaînement gapless : la piste suivante devient courante
+                    // avec ses métadonnées
+                    if s.next_uri.as_deref() == Some(uri.as_str()) {
+                        let metadata = s.next_metadata.take();
+                        s.now_playing.load(uri, metadata);
+                    } else if s.now_playing.uri.as_deref() != Some(uri.as_str()) {
+                        s.now_playing.load(uri, None);
+                    }
+                    s.now_playing.start(duration_sec, &format);
                     s.next_uri = None;
                     s.next_metadata = None;
+                    s.track_count = s.track_count.wrapping_add(1);
                 }
                 PlayerEvent::Paused { position_sec } => {
+                    let position_sec = (position_sec - convolution.latency_sec()).max(0.0);
                     let mut s = state.write();
                     s.playback_state = PlaybackState::Paused;
-                    s.position = Some(seconds_to_upnp_time(position_sec));
+                    s.now_playing.position_sec = Some(position_sec);
                 }
                 PlayerEvent::Stopped => {
                     let mut s = state.write();
                     s.playback_state = PlaybackState::Stopped;
-                    s.position = None;
+                    s.now_playing.position_sec = None;
                 }
                 PlayerEvent::Position { position_sec } => {
-                    state.write().position = Some(seconds_to_upnp_time(position_sec));
+                    // La convolution retarde le signal audible d'une partition
+                    let position_sec = (position_sec - convolution.latency_sec()).max(0.0);
+                    state.write().now_playing.position_sec = Some(position_sec);
                 }
                 PlayerEvent::TrackEnded => {
                     state.write().playback_state = PlaybackState::Transitioning;
@@ -0,0 +1,304 @@
+//! Accès à la file de lecture interne depuis les actions UPnP.
+//!
+//! La file gapless d'une instance MediaRenderer est gérée par le
+//! `ControlPoint` (pmocontrol). Ce module expose une vue restreinte de cette
+//! file, utilisée par les actions vendor `X_PMO_Queue*` de l'AVTransport afin
+//! qu'un control point purement UPnP puisse la manipuler au-delà de
+//! SetAVTransportURI/SetNextAVTransportURI.
+//!
+//! Un control point UPnP ne connaît pas les profils d'écoute : ce qu'il met
+//! en file relève du profil par défaut (voir `pmocontrol::profiles`).
+
+use std::sync::Arc;
+use std::time::Duration;
+
+use crossbeam_channel::RecvTimeoutError;
+use pmocontrol::errors::ControlPointError;
+use pmocontrol::profiles::default_profile;
+use pmocontrol::upnp_clients::parse_track_metadata_from_didl;
+use pmocontrol::{
+    ControlPoint, DeviceId, PlaybackItem, QueueSnapshot, RendererEvent, RepeatMode, ShuffleMode,
+};
+use pmoupnp::devices::DeviceInstance;
+use tokio_util::sync::CancellationToken;
+
+/// Identifiant du « serveur » d'origine des pistes insérées via SOAP
+const SOAP_QUEUE_SOURCE_ID: &str = "x-pmo-queue:soap";
+
+/// Protocol info par défaut des pistes insérées via SOAP
+const DEFAULT_PROTOCOL_INFO: &str = "http-get:*:audio/*:*";
+
+/// Intervalle de vérification de l'arrêt des ponts d'événements
+const EVENT_POLL_INTERVAL: Duration = Duration::from_secs(1);
+
+/// File de lecture d'une instance MediaRenderer
+#[derive(Clone)]
+pub struct RendererQueue {
+    control_point: Arc<ControlPoint>,
+    renderer_id: DeviceId,
+}
+
+impl RendererQueue {
+    pub fn new(control_point: Arc<ControlPoint>, udn: &str) -> Self {
+        Self {
+            control_point,
+            renderer_id: DeviceId(udn.to_string()),
+        }
+    }
+
+    /// Instantané de la file (pistes + index courant)
+    pub fn snapshot(&self) -> Result<QueueSnapshot, ControlPointError> {
+        self.control_point
+            .get_renderer_queue_snapshot(&self.renderer_id)
+    }
+
+    /// Insère une piste avant `position` (ajout en fin si hors limites)
+    pub fn insert(
+        &self,
+        position: usize,
+        uri: String,
+        metadata: &str,
+    ) -> Result<(), ControlPointError> {
+        let item = PlaybackItem {
+            media_server_id: DeviceId(SOAP_QUEUE_SOURCE_ID.to_string()),
+            backend_id: usize::MAX,
+            didl_id: uri.clone(),
+            uri,
+            protocol_info: DEFAULT_PROTOCOL_INFO.to_string(),
+            metadata: parse_track_metadata_from_didl(metadata),
+        };
+        self.control_point
+            .renderer_profiles()
+            .reset(&self.renderer_id);
+        self.control_point
+            .insert_queue_items_at(&self.renderer_id, position, vec![item])
+    }
+
+    /// Supprime la piste à `position`
+    pub fn remove(&self, position: usize) -> Result<(), ControlPointError> {
+        self.control_point
+            .remove_queue_item(&self.renderer_id, position)
+            .map(|_| ())
+    }
+
+    /// Déplace la piste `from` vers `to`
+    pub fn move_item(&self, from: usize, to: usize) -> Result<(), ControlPointError> {
+        self.control_point
+            .move_queue_item(&self.renderer_id, from, to)
+    }
+
+    /// Modes aléatoire et de répétition courants
+    pub fn play_mode(&self) -> (ShuffleMode, RepeatMode) {
+        self.control_point
+            .music_renderer_by_id(&self.renderer_id)
+            .map(|renderer| (renderer.shuffle_mode().0, renderer.repeat_mode()))
+            .unwrap_or_default()
+    }
+
+    /// Change les modes aléatoire et de répétition, sans interrompre la
+    /// piste en cours
+    pub fn set_play_mode(
+        &self,
+        shuffle: ShuffleMode,
+        repeat: RepeatMode,
+    ) -> Result<(), ControlPointError> {
+        self.control_point
+            .set_shuffle_mode(&self.renderer_id, shuffle, None)?;
+        self.control_point
+            .set_repeat_mode(&self.renderer_id, repeat)
+    }
+
+    /// Fixe la position de reprise d'une piste longue (livre audio, podcast)
+    pub fn set_resume_position(&self, uri: &str, position_secs: u32) -> std::io::Result<()> {
+        let store = self.control_point.resume_positions();
+        store.set_position(&default_profile(), uri, position_secs, None);
+        store.flush()
+    }
+
+    /// Oublie la position de reprise d'une piste, `false` si elle n'existait pas
+    pub fn clear_resume_position(&self, uri: &str) -> std::io::Result<bool> {
+        let store = self.control_point.resume_positions();
+        let cleared = store.clear(&default_profile(), uri);
+        store.flush().map(|_| cleared)
+    }
+}
+
+/// Lance la tâche qui recopie la longueur de la file dans `X_PMO_QueueLength`.
+///
+/// Toute modification de la file (actions SOAP, interface web, liaison à une
+/// playlist, lecture de la piste suivante) passe par le `ControlPoint`, qui
+/// émet un `QueueUpdated` : la variable est mise à jour à chaque événement
+/// de l'instance. La tâche s'arrête avec le pipeline de l'instance.
+///
+/// Retourne `None` si l'AVTransport n'expose pas la variable.
+pub fn spawn_queue_length_eventer(
+    device: &Arc<DeviceInstance>,
+    queue: RendererQueue,
+    stop_token: CancellationToken,
+) -> Option<tokio::task::JoinHandle<()>> {
+    let service = device.get_service("AVTransport")?;
+    let queue_length = service.get_typed_variable::<u32>("X_PMO_QueueLength")?;
+
+    let renderer_id = queue.renderer_id.clone();
+    let mut rx = renderer_events(&queue, stop_token.clone(), move |event| match event {
+        RendererEvent::QueueUpdated { id, queue_length } if id == renderer_id => Some(queue_length),
+        _ => None,
+    });
+
+    Some(tokio::spawn(async move {
+        let initial = queue.snapshot().map(|snapshot| snapshot.len()).unwrap_or(0);
+        let _ = queue_length.set(initial as u32).await;
+
+        loop {
+            let len = tokio::select! {
+                _ = stop_token.cancelled() => break,
+                len = rx.recv() => match len {
+                    Some(len) => len,
+                    None => break,
+                },
+            };
+            let _ = queue_length.set(len as u32).await;
+        }
+
+        tracing::debug!("[MediaRenderer] Queue length eventer stopped");
+    }))
+}
+
+/// Lance la tâche qui recopie les modes de la file dans `CurrentPlayMode`.
+///
+/// Les changements de mode (SetPlayMode, interface web, gRPC) passent par le
+/// `ControlPoint`, qui émet un `PlayModeChanged` : la variable, et donc le
+/// LastChange de l'AVTransport, suit chaque événement de l'instance. La
+/// tâche s'arrête avec le pipeline de l'instance.
+///
+/// Retourne `None` si l'AVTransport n'expose pas la variable.
+pub fn spawn_play_mode_eventer(
+    device: &Arc<DeviceInstance>,
+    queue: RendererQueue,
+    stop_token: CancellationToken,
+) -> Option<tokio::task::JoinHandle<()>> {
+    let service = device.get_service("AVTransport")?;
+    let current_play_mode = service.get_typed_variable::<String>("CurrentPlayMode")?;
+
+    let renderer_id = queue.renderer_id.clone();
+    let mut rx = renderer_events(&queue, stop_token.clone(), move |event| match event {
+        RendererEvent::PlayModeChanged {
+            id,
+            shuffle,
+            repeat,
+        } if id == renderer_id => Some(play_mode_to_upnp(shuffle, repeat)),
+        _ => None,
+    });
+
+    Some(tokio::spawn(async move {
+        let (shuffle, repeat) = queue.play_mode();
+        let _ = current_play_mode
+            .set(play_mode_to_upnp(shuffle, repeat).to_string())
+            .await;
+
+        loop {
+            let play_mode = tokio::select! {
+                _ = stop_token.cancelled() => break,
+                play_mode = rx.recv() => match play_mode {
+                    Some(play_mode) => play_mode,
+                    None => break,
+                },
+            };
+            let _ = current_play_mode.set(play_mode.to_string()).await;
+        }
+
+        tracing::debug!("[MediaRenderer] Play mode eventer stopped");
+    }))
+}
+
+/// Pont crossbeam -> tokio des événements du `ControlPoint` retenus par `select`
+///
+/// Le thread du pont vérifie l'arrêt de l'instance et la fermeture du canal
+/// au moins toutes les [`EVENT_POLL_INTERVAL`], même si aucun événement de
+/// l'instance n'arrive : il ne survit pas au pipeline.
+fn renderer_events<T: Send + 'static>(
+    queue: &RendererQueue,
+    stop_token: CancellationToken,
+    select: impl Fn(RendererEvent) -> Option<T> + Send + 'static,
+) -> tokio::sync::mpsc::UnboundedReceiver<T> {
+    let (tx, rx) = tokio::sync::mpsc::unbounded_channel();
+    let events = queue.control_point.subscribe_events();
+    tokio::task::spawn_blocking(move || {
+        while !stop_token.is_cancelled() && !tx.is_closed() {
+            match events.recv_timeout(EVENT_POLL_INTERVAL) {
+                Ok(event) => {
+                    if let Some(value) = select(event) {
+                        if tx.send(value).is_err() {
+                            break;
+                        }
+                    }
+                }
+                Err(RecvTimeoutError::Timeout) => {}
+                Err(RecvTimeoutError::Disconnected) => break,
+            }
+        }
+    });
+    rx
+}
+
+/// `CurrentPlayMode` AVTransport → modes de la file
+///
+/// `SHUFFLE` répète la file (comme la plupart des control points),
+/// `SHUFFLE_NOREPEAT` et `RANDOM` s'arrêtent après la dernière piste.
+/// `DIRECT_1` et `INTRO` ne sont pas gérés.
+pub fn play_mode_from_upnp(play_mode: &str) -> Option<(ShuffleMode, RepeatMode)> {
+    match play_mode {
+        "NORMAL" => Some((ShuffleMode::Off, RepeatMode::Off)),
+        "REPEAT_ONE" => Some((ShuffleMode::Off, RepeatMode::One)),
+        "REPEAT_ALL" => Some((ShuffleMode::Off, RepeatMode::All)),
+        "SHUFFLE" => Some((ShuffleMode::Tracks, RepeatMode::All)),
+        "SHUFFLE_NOREPEAT" | "RANDOM" => Some((ShuffleMode::Tracks, RepeatMode::Off)),
+        "X_PMO_SHUFFLE_ALBUMS" => Some((ShuffleMode::Albums, RepeatMode::Off)),
+        _ => None,
+    }
+}
+
+/// Modes de la file → `CurrentPlayMode` AVTransport
+///
+/// Une répétition combinée au mode aléatoire par album n'a pas de valeur
+/// propre : elle est rapportée comme `SHUFFLE`.
+pub fn play_mode_to_upnp(shuffle: ShuffleMode, repeat: RepeatMode) -> &'static str {
+    match (shuffle, repeat) {
+        (ShuffleMode::Off, RepeatMode::Off) => "NORMAL",
+        (ShuffleMode::Off, RepeatMode::One) => "REPEAT_ONE",
+        (ShuffleMode::Off, RepeatMode::All) => "REPEAT_ALL",
+        (ShuffleMode::Tracks, RepeatMode::Off) => "SHUFFLE_NOREPEAT",
+        (ShuffleMode::Albums, RepeatMode::Off) => "X_PMO_SHUFFLE_ALBUMS",
+        (_, _) => "SHUFFLE",
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_play_mode_round_trip() {
+        for play_mode in [
+            "NORMAL",
+            "REPEAT_ONE",
+            "REPEAT_ALL",
+            "SHUFFLE",
+            "SHUFFLE_NOREPEAT",
+            "X_PMO_SHUFFLE_ALBUMS",
+        ] {
+            let (shuffle, repeat) = play_mode_from_upnp(play_mode).unwrap();
+            assert_eq!(play_mode_to_upnp(shuffle, repeat), play_mode);
+        }
+        // RANDOM est un synonyme, rapporté sous son nom standard
+        let (shuffle, repeat) = play_mode_from_upnp("RANDOM").unwrap();
+        assert_eq!(play_mode_to_upnp(shuffle, repeat), "SHUFFLE_NOREPEAT");
+        // Combinaisons sans valeur propre
+        assert_eq!(
+            play_mode_to_upnp(ShuffleMode::Albums, RepeatMode::All),
+            "SHUFFLE"
+        );
+        assert!(play_mode_from_upnp("DIRECT_1").is_none());
+        assert!(play_mode_from_upnp("normal").is_none());
+    }
+}
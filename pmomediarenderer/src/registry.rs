@@ -7,8 +7,11 @@ use std::time::SystemTime;
 
 use pmoupnp::devices::DeviceInstance;
 
+use crate::adapter::DeviceCommand;
+use crate::config_ext::MediaRendererConfigExt;
 use crate::error::MediaRendererError;
-use crate::pipeline::{InstancePipeline, PipelineHandle};
+use crate::messages::PlaybackState;
+use crate::pipeline::{InstancePipeline, PipelineControl, PipelineHandle};
 use crate::renderer::MediaRendererFactory;
 use crate::state::{RendererState, SharedState};
 use super::adapter::DeviceAdapter;
@@ -82,7 +85,7 @@ impl MediaRendererRegistry {
                 let stream_url = format!("{}/{}/stream", stream_url_base, instance_id);
                 let should_play = {
                     let s = existing.state.read();
-                    s.current_uri.is_some() && matches!(
+                    s.now_playing.has_track() && matches!(
                         s.playback_state,
                         crate::messages::PlaybackState::Playing | crate::messages::PlaybackState::Transitioning
                     )
@@ -138,6 +141,18 @@ impl MediaRendererRegistry {
             .map(|i| i.pipeline.clone())
     }
 
+    /// Nombre d'instances actives (un flux par instance)
+    pub fn instance_count(&self) -> usize {
+        self.instances.read().len()
+    }
+
+    /// Instances actives, de la plus ancienne à la plus récente
+    pub fn instances(&self) -> Vec<Arc<MediaRendererInstance>> {
+        let mut instances: Vec<_> = self.instances.read().values().cloned().collect();
+        instances.sort_by_key(|i| i.created_at);
+        instances
+    }
+
     pub fn get_instance(&self, instance_id: &str) -> Option<Arc<MediaRendererInstance>> {
         self.instances.read().get(instance_id).cloned()
     }
@@ -178,16 +193,65 @@ impl MediaRendererRegistry {
         let instances = self.instances.read();
         if let Some(instance) = instances.get(instance_id) {
             let mut s = instance.state.write();
-            if s.duration.is_none() {
-                if let Some(dur) = duration_sec {
-                    if dur > 0.0 {
-                        s.duration = Some(crate::pipeline::seconds_to_upnp_time(dur));
-                    }
-                }
+            if s.now_playing.duration_sec.is_none() {
+                s.now_playing.duration_sec = duration_sec.filter(|d| *d > 0.0);
             }
         }
     }
 
+    /// Met en pause les instances en lecture et renvoie leur nombre.
+    ///
+    /// Appelé en sortie de veille du système : le flux vers le lecteur a été
+    /// coupé pendant la veille et reprendre d'office jouerait dans le vide.
+    /// La lecture repart de la position courante sur commande Play.
+    pub async fn pause_all(&self) -> usize {
+        let playing: Vec<Arc<MediaRendererInstance>> = self
+            .instances
+            .read()
+            .values()
+            .filter(|i| {
+                matches!(
+                    i.state.read().playback_state,
+                    PlaybackState::Playing | PlaybackState::Transitioning
+                )
+            })
+            .cloned()
+            .collect();
+
+        for instance in &playing {
+            let pipeline = &instance.pipeline;
+            pipeline.send(PipelineControl::Pause).await;
+            pipeline.flac_handle.pause();
+            pipeline.adapter.deliver(DeviceCommand::Pause);
+            instance.state.write().playback_state = PlaybackState::Paused;
+        }
+        playing.len()
+    }
+
+    /// Met les lectures en pause à chaque sortie de veille du système
+    /// (voir [`pmoupnp::power`]).
+    pub fn pause_on_resume(self: &Arc<Self>) {
+        use tokio::sync::broadcast::error::RecvError;
+
+        let mut resumes = pmoupnp::power::subscribe();
+        let registry = Arc::downgrade(self);
+        tokio::spawn(async move {
+            loop {
+                match resumes.recv().await {
+                    Ok(_) | Err(RecvError::Lagged(_)) => {}
+                    Err(RecvError::Closed) => return,
+                }
+                let Some(registry) = registry.upgrade() else {
+                    return;
+                };
+                let paused = registry.pause_all().await;
+                if paused > 0 {
+                    tracing::info!(paused, "MediaRenderer: playback paused after system sleep");
+                }
+            }
+        });
+    }
+
     pub fn schedule_unregister(self: &Arc<Self>, instance_id: &str) {
         use tokio_util::sync::CancellationToken;
 
@@ -314,6 +378,79 @@ impl MediaRendererRegistry {
             (Arc::new(device).create_instance(), ip)
         };
 
+        restore_volume(&device_instance, &state, &pipeline.pipeline_handle);
+
+        if crate::time::spawn_time_eventer(
+            &device_instance,
+            state.clone(),
+            pipeline.pipeline_handle.stop_token.clone(),
+        )
+        .is_none()
+        {
+            tracing::warn!(udn = %full_udn, "MediaRenderer: Time service not found, no OpenHome time events");
+        }
+        if crate::info::spawn_info_eventer(
+            &device_instance,
+            state.clone(),
+            pipeline.pipeline_handle.stop_token.clone(),
+        )
+        .is_none()
+        {
+            tracing::warn!(udn = %full_udn, "MediaRenderer: Info service not found, no OpenHome info events");
+        }
+        if crate::renderingcontrol::spawn_channels_eventer(
+            &device_instance,
+            pipeline.pipeline_handle.channels.clone(),
+            pipeline.pipeline_handle.stop_token.clone(),
+        )
+        .is_none()
+        {
+            tracing::warn!(udn = %full_udn, "MediaRenderer: X_PMO channel variables not found, no channel mix events");
+        }
+        #[cfg(feature = "pmoserver")]
+        if crate::queue::spawn_queue_length_eventer(
+            &device_instance,
+            pipeline.pipeline_handle.queue.clone(),
+            pipeline.pipeline_handle.stop_token.clone(),
+        )
+        .is_none()
+        {
+            tracing::warn!(udn = %full_udn, "MediaRenderer: X_PMO_QueueLength not found, no queue length events");
+        }
+        #[cfg(feature = "pmoserver")]
+        if crate::queue::spawn_play_mode_eventer(
+            &device_instance,
+            pipeline.pipeline_handle.queue.clone(),
+            pipeline.pipeline_handle.stop_token.clone(),
+        )
+        .is_none()
+        {
+            tracing::warn!(udn = %full_udn, "MediaRenderer: CurrentPlayMode not found, no play mode events");
+        }
+
+        match pmoconfig::get_config().get_renderer_idle_teardown() {
+            Ok(Some(timeout)) => {
+                crate::idle::spawn_idle_monitor(
+                    &device_instance,
+                    state.clone(),
+                    pipeline.pipeline_handle.clone(),
+                    timeout,
+                );
+            }
+            Ok(None) => {}
+            Err(e) => {
+                tracing::warn!(udn = %full_udn, "MediaRenderer: invalid idle_teardown: {}", e)
+            }
+        }
+
+        if let Ok(Some(settings)) = pmoconfig::get_config().get_renderer_icecast(&full_udn) {
+            crate::icecast::spawn_icecast_source(
+                settings,
+                pipeline.flac_handle.clone(),
+                pipeline.pipeline_handle.stop_token.clone(),
+            );
+        }
+
         Ok(MediaRendererInstance {
             instance_id: instance_id.to_string(),
             udn: full_udn,
@@ -385,4 +522,28 @@ impl MediaRendererRegistry {
         tracing::info!(udn = %udn, "MediaRenderer: registered with ControlPoint");
         Ok(())
     }
-}
\ No newline at end of file
+}
+
+/// Reprend le volume et le mute restaurés par les variables d'état persistantes
+/// de RenderingControl (défauts 100 / non muet si rien n'a été sauvegardé).
+fn restore_volume(device: &DeviceInstance, state: &SharedState, pipeline: &PipelineHandle) {
+    let Some(service) = device.get_service("RenderingControl") else {
+        return;
+    };
+    let volume = service
+        .get_typed_variable::<u16>("Volume")
+        .map(|v| v.get().min(100));
+    let mute = service.get_typed_variable::<bool>("Mute").map(|v| v.get());
+
+    let (volume, mute) = {
+        let mut s = state.write();
+        if let Some(volume) = volume {
+            s.volume = volume;
+        }
+        if let Some(mute) = mute {
+            s.mute = mute;
+        }
+        (s.volume, s.mute)
+    };
+    pipeline.volume.set_volume(volume, mute);
+}
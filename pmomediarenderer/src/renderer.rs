@@ -54,14 +54,28 @@ fn add_action(svc: &mut Service, action: Arc<Action>) -> Result<(), FactoryError
 use crate::avtransport::variables::{
     ABSOLUTETIMEPOSITION, AVTRANSPORTNEXTURI, AVTRANSPORTNEXTURIMETADATA, AVTRANSPORTURI,
     AVTRANSPORTURIMETADATA, A_ARG_TYPE_INSTANCE_ID as AVT_INSTANCE_ID, A_ARG_TYPE_PLAY_SPEED,
-    A_ARG_TYPE_SEEKMODE, CURRENTMEDIADURATION, CURRENTPLAYMODE, CURRENTTRACK, CURRENTTRACKDURATION,
+    A_ARG_TYPE_SEEKMODE, A_ARG_TYPE_X_PMO_EXCLUSIVE, A_ARG_TYPE_X_PMO_SESSION_OWNER,
+    CURRENTMEDIADURATION, CURRENTPLAYMODE, CURRENTTRACK, CURRENTTRACKDURATION,
     CURRENTTRACKMETADATA, CURRENTTRACKURI, NUMBEROFTRACKS, PLAYBACKSTORAGEMEDIUM,
     POSSIBLEPLAYBACKSTORAGEMEDIA, RELATIVETIMEPOSITION, SEEKMODE, TRANSPORTPLAYSPEED,
     TRANSPORTSTATE, TRANSPORTSTATUS,
 };
+#[cfg(feature = "pmoserver")]
+use crate::avtransport::variables::{
+    A_ARG_TYPE_X_PMO_QUEUE_INDEX, A_ARG_TYPE_X_PMO_QUEUE_ITEMS, A_ARG_TYPE_X_PMO_QUEUE_POSITION,
+    X_PMO_QUEUE_LENGTH,
+};
 
 use crate::renderingcontrol::variables::{
-    A_ARG_TYPE_CHANNEL, A_ARG_TYPE_INSTANCE_ID as RC_INSTANCE_ID, MUTE, VOLUME,
+    A_ARG_TYPE_CHANNEL, A_ARG_TYPE_INSTANCE_ID as RC_INSTANCE_ID, MUTE, VOLUME, X_PMO_BALANCE,
+    X_PMO_CHANNEL_SWAP, X_PMO_MONO,
+};
+
+use crate::time::variables::{DURATION as OH_DURATION, SECONDS as OH_SECONDS, TRACKCOUNT};
+
+use crate::info::variables::{
+    BITDEPTH, BITRATE, CODECNAME, DETAILSCOUNT, DURATION as INFO_DURATION, LOSSLESS, METADATA,
+    METATEXT, METATEXTCOUNT, SAMPLERATE, TRACKCOUNT as INFO_TRACKCOUNT, URI,
 };
 
 use crate::connectionmanager::variables::{
@@ -98,8 +112,10 @@ impl MediaRendererFactory {
             device_name,
             stream_url_base,
         )?;
-        let renderingcontrol = Self::build_renderingcontrol(state.clone())?;
+        let renderingcontrol = Self::build_renderingcontrol(pipeline.clone(), state.clone())?;
         let connectionmanager = Self::build_connectionmanager()?;
+        let time = Self::build_time(state.clone())?;
+        let info = Self::build_info(state.clone())?;
 
         let device = Device::new(
             device_name.to_string(),
@@ -115,6 +131,12 @@ impl MediaRendererFactory {
         device
             .add_service(Arc::new(connectionmanager))
             .map_err(|e| FactoryError::ServiceError(format!("{:?}", e)))?;
+        device
+            .add_service(Arc::new(time))
+            .map_err(|e| FactoryError::ServiceError(format!("{:?}", e)))?;
+        device
+            .add_service(Arc::new(info))
+            .map_err(|e| FactoryError::ServiceError(format!("{:?}", e)))?;
 
         Ok(device)
     }
@@ -173,19 +195,19 @@ impl MediaRendererFactory {
 
         let mut next = Action::new("Next".to_string());
         add_arg_in(&mut next, "InstanceID", &AVT_INSTANCE_ID)?;
-        next.set_handler(handlers::next_handler(pipeline.clone()));
+        next.set_handler(handlers::next_handler(pipeline.clone(), state.clone()));
         add_action(&mut svc, Arc::new(next))?;
 
         let mut previous = Action::new("Previous".to_string());
         add_arg_in(&mut previous, "InstanceID", &AVT_INSTANCE_ID)?;
-        previous.set_handler(handlers::previous_handler(pipeline.clone()));
+        previous.set_handler(handlers::previous_handler(pipeline.clone(), state.clone()));
         add_action(&mut svc, Arc::new(previous))?;
 
         let mut seek = Action::new("Seek".to_string());
         add_arg_in(&mut seek, "InstanceID", &AVT_INSTANCE_ID)?;
         add_arg_in(&mut seek, "Unit", &A_ARG_TYPE_SEEKMODE)?;
         add_arg_in(&mut seek, "Target", &SEEKMODE)?;
-        seek.set_handler(handlers::seek_handler(pipeline.clone()));
+        seek.set_handler(handlers::seek_handler(pipeline.clone(), state.clone()));
         add_action(&mut svc, Arc::new(seek))?;
 
         let mut set_uri = Action::new("SetAVTransportURI".to_string());
@@ -251,6 +273,12 @@ impl MediaRendererFactory {
 
         let mut get_settings = Action::new("GetTransportSettings".to_string());
         add_arg_in(&mut get_settings, "InstanceID", &AVT_INSTANCE_ID)?;
+        #[cfg(feature = "pmoserver")]
+        {
+            add_arg_out(&mut get_settings, "PlayMode", &CURRENTPLAYMODE)?;
+            get_settings.set_stateful(false);
+            get_settings.set_handler(handlers::get_transport_settings_handler(pipeline.clone()));
+        }
         add_action(&mut svc, Arc::new(get_settings))?;
 
         let mut get_caps = Action::new("GetDeviceCapabilities".to_string());
@@ -261,10 +289,136 @@ impl MediaRendererFactory {
         add_arg_in(&mut get_actions, "InstanceID", &AVT_INSTANCE_ID)?;
         add_action(&mut svc, Arc::new(get_actions))?;
 
+        Self::add_session_actions(&mut svc, &state)?;
+
+        #[cfg(feature = "pmoserver")]
+        Self::add_queue_actions(&mut svc, &pipeline, &state)?;
+
         Ok(svc)
     }
 
-    fn build_renderingcontrol(state: SharedState) -> Result<Service, FactoryError> {
+    /// Actions vendor de session de contrôle (voir [`crate::session`])
+    ///
+    /// - `X_PMO_TakeOver` : l'appelant devient propriétaire du transport,
+    ///   même verrouillé par un autre point de contrôle
+    /// - `X_PMO_GetSession` : propriétaire courant (IP et User-Agent, vides
+    ///   si aucun) et état du contrôle exclusif
+    fn add_session_actions(svc: &mut Service, state: &SharedState) -> Result<(), FactoryError> {
+        add_var(svc, &A_ARG_TYPE_X_PMO_SESSION_OWNER)?;
+        add_var(svc, &A_ARG_TYPE_X_PMO_EXCLUSIVE)?;
+
+        let mut take_over = Action::new("X_PMO_TakeOver".to_string());
+        add_arg_in(&mut take_over, "InstanceID", &AVT_INSTANCE_ID)?;
+        take_over.set_handler(handlers::take_over_handler(state.clone()));
+        add_action(svc, Arc::new(take_over))?;
+
+        let mut get_session = Action::new("X_PMO_GetSession".to_string());
+        add_arg_in(&mut get_session, "InstanceID", &AVT_INSTANCE_ID)?;
+        add_arg_out(&mut get_session, "Owner", &A_ARG_TYPE_X_PMO_SESSION_OWNER)?;
+        add_arg_out(
+            &mut get_session,
+            "OwnerAgent",
+            &A_ARG_TYPE_X_PMO_SESSION_OWNER,
+        )?;
+        add_arg_out(&mut get_session, "Exclusive", &A_ARG_TYPE_X_PMO_EXCLUSIVE)?;
+        get_session.set_stateful(false);
+        get_session.set_handler(handlers::get_session_handler(state.clone()));
+        add_action(svc, Arc::new(get_session))?;
+
+        Ok(())
+    }
+
+    /// Actions vendor de manipulation de la file gapless interne
+    ///
+    /// - `X_PMO_QueueList` : liste DIDL-Lite + index courant (-1 si aucun)
+    /// - `X_PMO_QueueInsert` : insère une piste avant `Position`
+    /// - `X_PMO_QueueRemove` : supprime la piste à `Position`
+    /// - `X_PMO_QueueMove` : déplace la piste `From` vers `To`
+    /// - `SetPlayMode` : modes aléatoire (par piste ou par album) et de
+    ///   répétition de la file, appliqués sans interrompre la piste en cours
+    ///
+    /// Les positions sont 0-based.
+    #[cfg(feature = "pmoserver")]
+    fn add_queue_actions(
+        svc: &mut Service,
+        pipeline: &PipelineHandle,
+        state: &SharedState,
+    ) -> Result<(), FactoryError> {
+        add_var(svc, &A_ARG_TYPE_X_PMO_QUEUE_POSITION)?;
+        add_var(svc, &A_ARG_TYPE_X_PMO_QUEUE_INDEX)?;
+        add_var(svc, &A_ARG_TYPE_X_PMO_QUEUE_ITEMS)?;
+        add_var(svc, &X_PMO_QUEUE_LENGTH)?;
+
+        let mut list = Action::new("X_PMO_QueueList".to_string());
+        add_arg_in(&mut list, "InstanceID", &AVT_INSTANCE_ID)?;
+        add_arg_out(&mut list, "QueueLength", &X_PMO_QUEUE_LENGTH)?;
+        add_arg_out(&mut list, "CurrentIndex", &A_ARG_TYPE_X_PMO_QUEUE_INDEX)?;
+        add_arg_out(&mut list, "Items", &A_ARG_TYPE_X_PMO_QUEUE_ITEMS)?;
+        list.set_stateful(false);
+        list.set_handler(handlers::queue_list_handler(pipeline.clone()));
+        add_action(svc, Arc::new(list))?;
+
+        let mut insert = Action::new("X_PMO_QueueInsert".to_string());
+        add_arg_in(&mut insert, "InstanceID", &AVT_INSTANCE_ID)?;
+        add_arg_in(&mut insert, "Position", &A_ARG_TYPE_X_PMO_QUEUE_POSITION)?;
+        add_arg_in(&mut insert, "URI", &AVTRANSPORTURI)?;
+        add_arg_in(&mut insert, "URIMetaData", &AVTRANSPORTURIMETADATA)?;
+        add_arg_out(&mut insert, "QueueLength", &X_PMO_QUEUE_LENGTH)?;
+        insert.set_handler(handlers::queue_insert_handler(
+            pipeline.clone(),
+            state.clone(),
+        ));
+        add_action(svc, Arc::new(insert))?;
+
+        let mut remove = Action::new("X_PMO_QueueRemove".to_string());
+        add_arg_in(&mut remove, "InstanceID", &AVT_INSTANCE_ID)?;
+        add_arg_in(&mut remove, "Position", &A_ARG_TYPE_X_PMO_QUEUE_POSITION)?;
+        add_arg_out(&mut remove, "QueueLength", &X_PMO_QUEUE_LENGTH)?;
+        remove.set_handler(handlers::queue_remove_handler(
+            pipeline.clone(),
+            state.clone(),
+        ));
+        add_action(svc, Arc::new(remove))?;
+
+        let mut move_item = Action::new("X_PMO_QueueMove".to_string());
+        add_arg_in(&mut move_item, "InstanceID", &AVT_INSTANCE_ID)?;
+        add_arg_in(&mut move_item, "From", &A_ARG_TYPE_X_PMO_QUEUE_POSITION)?;
+        add_arg_in(&mut move_item, "To", &A_ARG_TYPE_X_PMO_QUEUE_POSITION)?;
+        move_item.set_handler(handlers::queue_move_handler(
+            pipeline.clone(),
+            state.clone(),
+        ));
+        add_action(svc, Arc::new(move_item))?;
+
+        let mut set_play_mode = Action::new("SetPlayMode".to_string());
+        add_arg_in(&mut set_play_mode, "InstanceID", &AVT_INSTANCE_ID)?;
+        add_arg_in(&mut set_play_mode, "NewPlayMode", &CURRENTPLAYMODE)?;
+        set_play_mode.set_handler(handlers::set_play_mode_handler(
+            pipeline.clone(),
+            state.clone(),
+        ));
+        add_action(svc, Arc::new(set_play_mode))?;
+
+        let mut set_resume = Action::new("X_PMO_SetResumePosition".to_string());
+        add_arg_in(&mut set_resume, "InstanceID", &AVT_INSTANCE_ID)?;
+        add_arg_in(&mut set_resume, "URI", &AVTRANSPORTURI)?;
+        add_arg_in(&mut set_resume, "Position", &RELATIVETIMEPOSITION)?;
+        set_resume.set_handler(handlers::set_resume_position_handler(pipeline.clone()));
+        add_action(svc, Arc::new(set_resume))?;
+
+        let mut clear_resume = Action::new("X_PMO_ClearResumePosition".to_string());
+        add_arg_in(&mut clear_resume, "InstanceID", &AVT_INSTANCE_ID)?;
+        add_arg_in(&mut clear_resume, "URI", &AVTRANSPORTURI)?;
+        clear_resume.set_handler(handlers::clear_resume_position_handler(pipeline.clone()));
+        add_action(svc, Arc::new(clear_resume))?;
+
+        Ok(())
+    }
+
+    fn build_renderingcontrol(
+        pipeline: PipelineHandle,
+        state: SharedState,
+    ) -> Result<Service, FactoryError> {
         let mut svc = Service::new("RenderingControl".to_string());
 
         add_var(&mut svc, &RC_INSTANCE_ID)?;
@@ -276,7 +430,7 @@ impl MediaRendererFactory {
         add_arg_in(&mut set_vol, "InstanceID", &RC_INSTANCE_ID)?;
         add_arg_in(&mut set_vol, "Channel", &A_ARG_TYPE_CHANNEL)?;
         add_arg_in(&mut set_vol, "DesiredVolume", &VOLUME)?;
-        set_vol.set_handler(handlers::set_volume_handler(state.clone()));
+        set_vol.set_handler(handlers::set_volume_handler(pipeline.clone(), state.clone()));
         add_action(&mut svc, Arc::new(set_vol))?;
 
         let mut get_vol = Action::new("GetVolume".to_string());
@@ -291,7 +445,7 @@ impl MediaRendererFactory {
         add_arg_in(&mut set_mute, "InstanceID", &RC_INSTANCE_ID)?;
         add_arg_in(&mut set_mute, "Channel", &A_ARG_TYPE_CHANNEL)?;
         add_arg_in(&mut set_mute, "DesiredMute", &MUTE)?;
-        set_mute.set_handler(handlers::set_mute_handler(state.clone()));
+        set_mute.set_handler(handlers::set_mute_handler(pipeline.clone(), state.clone()));
         add_action(&mut svc, Arc::new(set_mute))?;
 
         let mut get_mute = Action::new("GetMute".to_string());
@@ -302,6 +456,125 @@ impl MediaRendererFactory {
         get_mute.set_handler(handlers::get_mute_handler(state.clone()));
         add_action(&mut svc, Arc::new(get_mute))?;
 
+        // Vendor : balance gauche/droite (-100..100) et inversion des canaux
+        add_var(&mut svc, &X_PMO_BALANCE)?;
+        add_var(&mut svc, &X_PMO_CHANNEL_SWAP)?;
+
+        let mut set_balance = Action::new("X_PMO_SetBalance".to_string());
+        add_arg_in(&mut set_balance, "InstanceID", &RC_INSTANCE_ID)?;
+        add_arg_in(&mut set_balance, "DesiredBalance", &X_PMO_BALANCE)?;
+        set_balance.set_handler(handlers::set_balance_handler(pipeline.clone(), state.clone()));
+        add_action(&mut svc, Arc::new(set_balance))?;
+
+        let mut get_balance = Action::new("X_PMO_GetBalance".to_string());
+        add_arg_in(&mut get_balance, "InstanceID", &RC_INSTANCE_ID)?;
+        add_arg_out(&mut get_balance, "CurrentBalance", &X_PMO_BALANCE)?;
+        get_balance.set_stateful(false);
+        get_balance.set_handler(handlers::get_balance_handler(state.clone()));
+        add_action(&mut svc, Arc::new(get_balance))?;
+
+        let mut set_swap = Action::new("X_PMO_SetChannelSwap".to_string());
+        add_arg_in(&mut set_swap, "InstanceID", &RC_INSTANCE_ID)?;
+        add_arg_in(&mut set_swap, "DesiredChannelSwap", &X_PMO_CHANNEL_SWAP)?;
+        set_swap.set_handler(handlers::set_channel_swap_handler(pipeline.clone(), state.clone()));
+        add_action(&mut svc, Arc::new(set_swap))?;
+
+        let mut get_swap = Action::new("X_PMO_GetChannelSwap".to_string());
+        add_arg_in(&mut get_swap, "InstanceID", &RC_INSTANCE_ID)?;
+        add_arg_out(&mut get_swap, "CurrentChannelSwap", &X_PMO_CHANNEL_SWAP)?;
+        get_swap.set_stateful(false);
+        get_swap.set_handler(handlers::get_channel_swap_handler(state.clone()));
+        add_action(&mut svc, Arc::new(get_swap))?;
+
+        // Vendor : downmix mono (une seule enceinte)
+        add_var(&mut svc, &X_PMO_MONO)?;
+
+        let mut set_mono = Action::new("X_PMO_SetMono".to_string());
+        add_arg_in(&mut set_mono, "InstanceID", &RC_INSTANCE_ID)?;
+        add_arg_in(&mut set_mono, "DesiredMono", &X_PMO_MONO)?;
+        set_mono.set_handler(handlers::set_mono_handler(pipeline.clone()));
+        add_action(&mut svc, Arc::new(set_mono))?;
+
+        let mut get_mono = Action::new("X_PMO_GetMono".to_string());
+        add_arg_in(&mut get_mono, "InstanceID", &RC_INSTANCE_ID)?;
+        add_arg_out(&mut get_mono, "CurrentMono", &X_PMO_MONO)?;
+        get_mono.set_stateful(false);
+        get_mono.set_handler(handlers::get_mono_handler(state.clone()));
+        add_action(&mut svc, Arc::new(get_mono))?;
+
+        Ok(svc)
+    }
+
+    /// Service OpenHome Time:1 (progression de la piste courante)
+    fn build_time(state: SharedState) -> Result<Service, FactoryError> {
+        let mut svc = Service::new("Time".to_string());
+        svc.set_domain(crate::time::OPENHOME_DOMAIN.to_string());
+
+        add_var(&mut svc, &TRACKCOUNT)?;
+        add_var(&mut svc, &OH_DURATION)?;
+        add_var(&mut svc, &OH_SECONDS)?;
+
+        let mut time = Action::new("Time".to_string());
+        add_arg_out(&mut time, "TrackCount", &TRACKCOUNT)?;
+        add_arg_out(&mut time, "Duration", &OH_DURATION)?;
+        add_arg_out(&mut time, "Seconds", &OH_SECONDS)?;
+        time.set_stateful(false);
+        time.set_handler(handlers::time_handler(state));
+        add_action(&mut svc, Arc::new(time))?;
+
+        Ok(svc)
+    }
+
+    /// Service OpenHome Info:1 (URI, métadonnées et format de la piste courante)
+    fn build_info(state: SharedState) -> Result<Service, FactoryError> {
+        let mut svc = Service::new("Info".to_string());
+        svc.set_domain(crate::time::OPENHOME_DOMAIN.to_string());
+
+        add_var(&mut svc, &INFO_TRACKCOUNT)?;
+        add_var(&mut svc, &DETAILSCOUNT)?;
+        add_var(&mut svc, &METATEXTCOUNT)?;
+        add_var(&mut svc, &URI)?;
+        add_var(&mut svc, &METADATA)?;
+        add_var(&mut svc, &INFO_DURATION)?;
+        add_var(&mut svc, &BITRATE)?;
+        add_var(&mut svc, &BITDEPTH)?;
+        add_var(&mut svc, &SAMPLERATE)?;
+        add_var(&mut svc, &LOSSLESS)?;
+        add_var(&mut svc, &CODECNAME)?;
+        add_var(&mut svc, &METATEXT)?;
+
+        let mut counters = Action::new("Counters".to_string());
+        add_arg_out(&mut counters, "TrackCount", &INFO_TRACKCOUNT)?;
+        add_arg_out(&mut counters, "DetailsCount", &DETAILSCOUNT)?;
+        add_arg_out(&mut counters, "MetatextCount", &METATEXTCOUNT)?;
+        counters.set_stateful(false);
+        counters.set_handler(handlers::info_counters_handler(state.clone()));
+        add_action(&mut svc, Arc::new(counters))?;
+
+        let mut track = Action::new("Track".to_string());
+        add_arg_out(&mut track, "Uri", &URI)?;
+        add_arg_out(&mut track, "Metadata", &METADATA)?;
+        track.set_stateful(false);
+        track.set_handler(handlers::info_track_handler(state.clone()));
+        add_action(&mut svc, Arc::new(track))?;
+
+        let mut details = Action::new("Details".to_string());
+        add_arg_out(&mut details, "Duration", &INFO_DURATION)?;
+        add_arg_out(&mut details, "BitRate", &BITRATE)?;
+        add_arg_out(&mut details, "BitDepth", &BITDEPTH)?;
+        add_arg_out(&mut details, "SampleRate", &SAMPLERATE)?;
+        add_arg_out(&mut details, "Lossless", &LOSSLESS)?;
+        add_arg_out(&mut details, "CodecName", &CODECNAME)?;
+        details.set_stateful(false);
+        details.set_handler(handlers::info_details_handler(state));
+        add_action(&mut svc, Arc::new(details))?;
+
+        let mut metatext = Action::new("Metatext".to_string());
+        add_arg_out(&mut metatext, "Value", &METATEXT)?;
+        metatext.set_stateful(false);
+        metatext.set_handler(handlers::info_metatext_handler());
+        add_action(&mut svc, Arc::new(metatext))?;
+
         Ok(svc)
     }
 
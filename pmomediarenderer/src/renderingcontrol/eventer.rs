@@ -0,0 +1,109 @@
+//! Alimentation des variables vendor X_PMO_* depuis le mixage des canaux
+
+use std::sync::Arc;
+use std::time::Duration;
+
+use pmoaudio::ChannelMixHandle;
+use pmoupnp::devices::DeviceInstance;
+use tokio_util::sync::CancellationToken;
+
+/// Période d'échantillonnage du mixage des canaux
+const CHANNELS_EVENT_PERIOD: Duration = Duration::from_secs(1);
+
+/// Valeurs courantes du mixage des canaux
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub(crate) struct ChannelValues {
+    pub balance: i16,
+    pub channel_swap: bool,
+    pub mono: bool,
+}
+
+impl ChannelValues {
+    pub(crate) fn from_handle(channels: &ChannelMixHandle) -> Self {
+        Self {
+            balance: channels.balance() as i16,
+            channel_swap: channels.channel_swap(),
+            mono: channels.mono(),
+        }
+    }
+}
+
+/// Lance la tâche qui recopie le mixage des canaux dans RenderingControl.
+///
+/// Le handle est la seule source de vérité : qu'il soit modifié par une
+/// action SOAP, l'API web ou la configuration, `X_PMO_Balance`,
+/// `X_PMO_ChannelSwap` et `X_PMO_Mono` le suivent. Comme pour le service
+/// Time, il est échantillonné toutes les secondes et seules les variables
+/// modifiées sont mises à jour.
+///
+/// Retourne `None` si le device n'expose pas ces variables.
+pub fn spawn_channels_eventer(
+    device: &Arc<DeviceInstance>,
+    channels: ChannelMixHandle,
+    stop_token: CancellationToken,
+) -> Option<tokio::task::JoinHandle<()>> {
+    let service = device.get_service("RenderingControl")?;
+    let balance = service.get_typed_variable::<i16>("X_PMO_Balance")?;
+    let channel_swap = service.get_typed_variable::<bool>("X_PMO_ChannelSwap")?;
+    let mono = service.get_typed_variable::<bool>("X_PMO_Mono")?;
+
+    Some(tokio::spawn(async move {
+        let mut ticker = tokio::time::interval(CHANNELS_EVENT_PERIOD);
+        let mut last: Option<ChannelValues> = None;
+
+        loop {
+            tokio::select! {
+                _ = stop_token.cancelled() => break,
+                _ = ticker.tick() => {}
+            }
+
+            let current = ChannelValues::from_handle(&channels);
+            if last == Some(current) {
+                continue;
+            }
+            let first = last.is_none();
+            let previous = last.unwrap_or_default();
+
+            if first || previous.balance != current.balance {
+                let _ = balance.set(current.balance).await;
+            }
+            if first || previous.channel_swap != current.channel_swap {
+                let _ = channel_swap.set(current.channel_swap).await;
+            }
+            if first || previous.mono != current.mono {
+                let _ = mono.set(current.mono).await;
+            }
+
+            last = Some(current);
+        }
+
+        tracing::debug!("[MediaRenderer] Channel mix eventer stopped");
+    }))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use pmoaudio::ChannelMixNode;
+
+    #[test]
+    fn test_from_handle_follows_channel_mix() {
+        let (_, channels) = ChannelMixNode::new();
+        assert_eq!(
+            ChannelValues::from_handle(&channels),
+            ChannelValues::default()
+        );
+
+        channels.set_balance(-150);
+        channels.set_channel_swap(true);
+        channels.set_mono(true);
+        assert_eq!(
+            ChannelValues::from_handle(&channels),
+            ChannelValues {
+                balance: -100,
+                channel_swap: true,
+                mono: true,
+            }
+        );
+    }
+}
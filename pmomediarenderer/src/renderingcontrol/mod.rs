@@ -52,8 +52,11 @@
 use pmoupnp::define_service;
 
 pub mod actions;
+mod eventer;
 pub mod variables;
 
+pub use eventer::spawn_channels_eventer;
+
 use actions::{GETMUTE, GETVOLUME, SETMUTE, SETVOLUME};
 use variables::{A_ARG_TYPE_CHANNEL, A_ARG_TYPE_INSTANCE_ID, MUTE, VOLUME};
 
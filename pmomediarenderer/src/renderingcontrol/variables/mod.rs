@@ -2,8 +2,10 @@ mod a_arg_type_channel;
 mod a_arg_type_instanceid;
 mod mute;
 mod volume;
+mod x_pmo_channels;
 
 pub use a_arg_type_channel::A_ARG_TYPE_CHANNEL;
 pub use a_arg_type_instanceid::A_ARG_TYPE_INSTANCE_ID;
 pub use mute::MUTE;
 pub use volume::VOLUME;
+pub use x_pmo_channels::{X_PMO_BALANCE, X_PMO_CHANNEL_SWAP, X_PMO_MONO};
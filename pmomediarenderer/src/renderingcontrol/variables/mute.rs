@@ -2,6 +2,8 @@ use pmoupnp::define_variable;
 
 define_variable! {
     pub static MUTE: Boolean = "Mute" {
+        default: false,
         evented: true,
+        persistent: true,
     }
 }
@@ -2,6 +2,8 @@ use pmoupnp::define_variable;
 
 define_variable! {
     pub static VOLUME: UI2 = "Volume" {
+        default: 100,
         evented: true,
+        persistent: true,
     }
 }
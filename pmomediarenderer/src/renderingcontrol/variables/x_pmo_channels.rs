@@ -0,0 +1,21 @@
+use pmoupnp::define_variable;
+
+// Variables du vendor extension X_PMO_* (balance, inversion des canaux, mono)
+
+define_variable! {
+    pub static X_PMO_BALANCE: I2 = "X_PMO_Balance" {
+        evented: true,
+    }
+}
+
+define_variable! {
+    pub static X_PMO_CHANNEL_SWAP: Boolean = "X_PMO_ChannelSwap" {
+        evented: true,
+    }
+}
+
+define_variable! {
+    pub static X_PMO_MONO: Boolean = "X_PMO_Mono" {
+        evented: true,
+    }
+}
@@ -0,0 +1,184 @@
+//! Session de transport : point de contrôle propriétaire de la lecture
+//!
+//! Le dernier client ayant émis `SetAVTransportURI` ou `Play` devient
+//! propriétaire du transport. En mode contrôle exclusif
+//! (`host.renderer.exclusive_control`), les commandes de transport des autres
+//! clients sont refusées avec l'erreur UPnP 705 (« Transport is locked »)
+//! tant que la lecture n'est pas arrêtée, sauf prise de contrôle explicite
+//! via l'action vendor `X_PMO_TakeOver`.
+//!
+//! Les clients sont identifiés par leur adresse IP (voir
+//! [`ActionCaller::id`]). Les commandes internes (API REST, pipeline) ne
+//! passent pas par une session et ne sont jamais refusées.
+
+use std::time::SystemTime;
+
+use pmoupnp::actions::{ActionCaller, ActionError};
+use serde::Serialize;
+
+use crate::config_ext::MediaRendererConfigExt;
+use crate::messages::PlaybackState;
+use crate::state::{RendererState, SharedState};
+
+/// Code d'erreur AVTransport « Transport is locked »
+pub const TRANSPORT_IS_LOCKED: &str = "705";
+
+/// Propriétaire courant du transport d'un renderer
+#[derive(Debug, Clone, Serialize)]
+pub struct TransportSession {
+    /// Identifiant du client (adresse IP)
+    pub owner: String,
+    /// `User-Agent` du client
+    pub user_agent: Option<String>,
+    /// Prise de possession du transport
+    pub since: SystemTime,
+    /// Dernière commande de transport reçue du propriétaire
+    pub last_command: String,
+    /// Date de cette dernière commande
+    pub last_seen: SystemTime,
+}
+
+impl TransportSession {
+    fn new(caller: &ActionCaller, action: &str) -> Self {
+        let now = SystemTime::now();
+        Self {
+            owner: caller.id(),
+            user_agent: caller.user_agent.clone(),
+            since: now,
+            last_command: action.to_string(),
+            last_seen: now,
+        }
+    }
+}
+
+/// Contrôle exclusif activé ? (`host.renderer.exclusive_control`, défaut: false)
+pub fn exclusive_control() -> bool {
+    pmoconfig::get_config()
+        .get_renderer_exclusive_control()
+        .unwrap_or(false)
+}
+
+/// Vérifie qu'une commande de transport est permise pour l'appelant courant.
+///
+/// `claim` indique si la commande fait de l'appelant le propriétaire
+/// (`SetAVTransportURI`, `Play`, `X_PMO_TakeOver`) ; `force` ignore le
+/// verrou (prise de contrôle explicite).
+///
+/// # Errors
+///
+/// [`ActionError::UpnpError`] 705 si le transport appartient à un autre
+/// client en mode exclusif.
+pub fn check_transport(
+    state: &SharedState,
+    action: &str,
+    claim: bool,
+    force: bool,
+) -> Result<(), ActionError> {
+    let Some(caller) = ActionCaller::current() else {
+        return Ok(());
+    };
+    let exclusive = !force && exclusive_control();
+    check_transport_for(&mut state.write(), &caller, action, claim, exclusive)
+}
+
+fn check_transport_for(
+    state: &mut RendererState,
+    caller: &ActionCaller,
+    action: &str,
+    claim: bool,
+    exclusive: bool,
+) -> Result<(), ActionError> {
+    let caller_id = caller.id();
+    let locked_by = state
+        .session
+        .as_ref()
+        .filter(|session| session.owner != caller_id)
+        .filter(|_| exclusive && !matches!(state.playback_state, PlaybackState::Stopped))
+        .map(|session| session.owner.clone());
+
+    if let Some(owner) = locked_by {
+        tracing::info!(
+            caller = %caller_id,
+            owner = %owner,
+            "[MediaRenderer] {} refused: transport locked",
+            action
+        );
+        return Err(ActionError::UpnpError {
+            code: TRANSPORT_IS_LOCKED.to_string(),
+            description: format!("Transport is locked by {}", owner),
+        });
+    }
+
+    match state.session.as_mut() {
+        Some(session) if session.owner == caller_id => {
+            session.last_command = action.to_string();
+            session.last_seen = SystemTime::now();
+            if caller.user_agent.is_some() {
+                session.user_agent = caller.user_agent.clone();
+            }
+        }
+        _ if claim => {
+            if let Some(previous) = &state.session {
+                tracing::info!(
+                    caller = %caller_id,
+                    previous = %previous.owner,
+                    "[MediaRenderer] transport taken over via {}",
+                    action
+                );
+            }
+            state.session = Some(TransportSession::new(caller, action));
+        }
+        _ => {}
+    }
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn caller(ip: &str) -> ActionCaller {
+        ActionCaller {
+            addr: Some(ip.parse().unwrap()),
+            user_agent: None,
+        }
+    }
+
+    #[test]
+    fn last_claiming_client_owns_the_transport() {
+        let mut state = RendererState::default();
+        let (a, b) = (caller("10.0.0.1"), caller("10.0.0.2"));
+
+        check_transport_for(&mut state, &a, "SetAVTransportURI", true, false).unwrap();
+        check_transport_for(&mut state, &b, "Pause", false, false).unwrap();
+        assert_eq!(state.session.as_ref().unwrap().owner, "10.0.0.1");
+
+        check_transport_for(&mut state, &b, "Play", true, false).unwrap();
+        let session = state.session.as_ref().unwrap();
+        assert_eq!(session.owner, "10.0.0.2");
+        assert_eq!(session.last_command, "Play");
+    }
+
+    #[test]
+    fn exclusive_mode_locks_other_clients_until_stopped() {
+        let mut state = RendererState::default();
+        let (a, b) = (caller("10.0.0.1"), caller("10.0.0.2"));
+
+        check_transport_for(&mut state, &a, "Play", true, true).unwrap();
+        state.playback_state = PlaybackState::Playing;
+
+        let err = check_transport_for(&mut state, &b, "Stop", false, true).unwrap_err();
+        assert!(
+            matches!(err, ActionError::UpnpError { ref code, .. } if code == TRANSPORT_IS_LOCKED)
+        );
+        check_transport_for(&mut state, &a, "Pause", false, true).unwrap();
+
+        // Prise de contrôle explicite : le verrou est ignoré
+        check_transport_for(&mut state, &b, "X_PMO_TakeOver", true, false).unwrap();
+        assert_eq!(state.session.as_ref().unwrap().owner, "10.0.0.2");
+
+        state.playback_state = PlaybackState::Stopped;
+        check_transport_for(&mut state, &a, "Play", true, true).unwrap();
+        assert_eq!(state.session.as_ref().unwrap().owner, "10.0.0.1");
+    }
+}
@@ -0,0 +1,135 @@
+//! Métadonnées de secours pour les URI reçues sans DIDL-Lite
+//!
+//! Certains points de contrôle (scripts, `curl`, lecteurs minimalistes)
+//! envoient `SetAVTransportURI` avec un `CurrentURIMetaData` vide. Le
+//! renderer sonde alors l'URI ([`pmoaudio_ext::probe_track`] : tags du
+//! fichier, en-têtes ICY des radios) et synthétise un DIDL-Lite, pour que
+//! l'interface, OpenHome Info et les autres consommateurs de
+//! [`NowPlaying`](crate::now_playing::NowPlaying) affichent au moins un
+//! titre.
+//!
+//! Le sondage se fait en tâche de fond : la réponse SOAP n'attend pas le
+//! réseau, et le résultat est ignoré si la piste a changé entre-temps.
+
+use pmoaudio_ext::{probe_track, ProbedTrack};
+use pmodidl::{DIDLLite, Item, Resource, ToXmlElement};
+
+use crate::pipeline::seconds_to_upnp_time;
+use crate::state::SharedState;
+
+/// Emplacement des métadonnées à compléter
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum MetadataSlot {
+    /// Piste courante (`SetAVTransportURI`)
+    Current,
+    /// Piste suivante (`SetNextAVTransportURI`)
+    Next,
+}
+
+/// Métadonnées absentes ou vides ?
+pub fn is_missing(metadata: &str) -> bool {
+    let metadata = metadata.trim();
+    metadata.is_empty() || metadata.eq_ignore_ascii_case("NOT_IMPLEMENTED")
+}
+
+/// DIDL-Lite d'un item unique décrivant `uri`
+pub fn didl_from_probe(uri: &str, track: &ProbedTrack) -> String {
+    let class = if track.broadcast {
+        "object.item.audioItem.audioBroadcast"
+    } else {
+        "object.item.audioItem.musicTrack"
+    };
+    let item = Item {
+        id: "0".to_string(),
+        parent_id: "-1".to_string(),
+        restricted: Some("1".to_string()),
+        title: track.title.clone().unwrap_or_else(|| uri.to_string()),
+        creator: track.artist.clone(),
+        class: class.to_string(),
+        artist: track.artist.clone(),
+        album: track.album.clone(),
+        genre: track.genre.clone(),
+        album_art: None,
+        album_art_pk: None,
+        date: track.year.map(|y| y.to_string()),
+        original_track_number: track.track_number.map(|n| n.to_string()),
+        resources: vec![Resource {
+            protocol_info: "http-get:*:*:*".to_string(),
+            bits_per_sample: None,
+            sample_frequency: track.sample_rate.map(|r| r.to_string()),
+            nr_audio_channels: track.channels.map(|c| c.to_string()),
+            duration: track.duration_sec.map(|d| seconds_to_upnp_time(d as f64)),
+            url: uri.to_string(),
+        }],
+        descriptions: vec![],
+    };
+    DIDLLite {
+        items: vec![item],
+        ..Default::default()
+    }
+    .to_xml()
+}
+
+/// Sonde `uri` en tâche de fond et complète ses métadonnées dans l'état
+/// si elle est toujours la piste de `slot` et n'en a pas reçu d'autres
+pub fn spawn_metadata_sniff(state: SharedState, uri: String, slot: MetadataSlot) {
+    tokio::spawn(async move {
+        let probed_uri = uri.clone();
+        let track = match tokio::task::spawn_blocking(move || probe_track(&probed_uri)).await {
+            Ok(track) => track,
+            Err(e) => {
+                tracing::warn!(uri = %uri, "Metadata sniffing task failed: {}", e);
+                return;
+            }
+        };
+        let didl = didl_from_probe(&uri, &track);
+
+        let mut s = state.write();
+        let (current_uri, metadata) = match slot {
+            MetadataSlot::Current => (s.now_playing.uri.as_deref(), &s.now_playing.metadata),
+            MetadataSlot::Next => (s.next_uri.as_deref(), &s.next_metadata),
+        };
+        if current_uri != Some(uri.as_str()) || !metadata.as_deref().map_or(true, is_missing) {
+            return;
+        }
+        tracing::info!(uri = %uri, title = ?track.title, "🔎 Synthesized metadata for URI without DIDL-Lite");
+        match slot {
+            MetadataSlot::Current => s.now_playing.metadata = Some(didl),
+            MetadataSlot::Next => s.next_metadata = Some(didl),
+        }
+    });
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_didl_from_probe() {
+        let track = ProbedTrack {
+            title: Some("So What".into()),
+            artist: Some("Miles Davis".into()),
+            album: Some("Kind of Blue".into()),
+            track_number: Some(1),
+            duration_sec: Some(562),
+            sample_rate: Some(44100),
+            channels: Some(2),
+            ..Default::default()
+        };
+        let didl = didl_from_probe("http://nas/so_what.flac", &track);
+        assert!(didl.contains("<dc:title>So What</dc:title>"));
+        assert!(didl.contains("<upnp:artist>Miles Davis</upnp:artist>"));
+        assert!(didl.contains("object.item.audioItem.musicTrack"));
+        assert!(didl.contains("duration=\"0:09:22\""));
+        assert!(didl.contains("http://nas/so_what.flac"));
+
+        let radio = ProbedTrack {
+            title: Some("FIP".into()),
+            broadcast: true,
+            ..Default::default()
+        };
+        assert!(didl_from_probe("http://icecast/fip", &radio).contains("audioBroadcast"));
+        assert!(is_missing("  "));
+        assert!(!is_missing(&didl));
+    }
+}
@@ -5,19 +5,36 @@ use std::collections::VecDeque;
 use std::sync::Arc;
 
 use crate::adapter::DeviceCommand;
+use crate::config_ext::OutputProfile;
 use crate::messages::PlaybackState;
+use crate::now_playing::NowPlaying;
+use crate::session::TransportSession;
 
 #[derive(Debug, Clone)]
 pub struct RendererState {
     pub playback_state: PlaybackState,
-    pub current_uri: Option<String>,
-    pub current_metadata: Option<String>,
+    /// Piste courante, source unique pour AVTransport, OpenHome et REST
+    pub now_playing: NowPlaying,
     pub next_uri: Option<String>,
     pub next_metadata: Option<String>,
-    pub position: Option<String>,
-    pub duration: Option<String>,
+    /// Nombre de pistes démarrées (OpenHome Time.TrackCount)
+    pub track_count: u32,
     pub volume: u16,
     pub mute: bool,
+    /// Balance de -100 (gauche) à +100 (droite)
+    pub balance: i16,
+    /// Inversion des canaux gauche/droite
+    pub channel_swap: bool,
+    /// Downmix mono (une seule enceinte)
+    pub mono: bool,
+    /// Type de sortie (enceintes ou casque)
+    pub output_profile: OutputProfile,
+    /// Crossfeed demandé (effectif seulement sur une sortie casque)
+    pub crossfeed: bool,
+    /// Mode bit-perfect : chaîne réduite aux étages qui ne modifient pas le signal
+    pub bit_perfect: bool,
+    /// Point de contrôle propriétaire du transport
+    pub session: Option<TransportSession>,
     pub pending_commands: VecDeque<DeviceCommand>,
 }
 
@@ -35,14 +52,19 @@ impl Default for RendererState {
     fn default() -> Self {
         Self {
             playback_state: PlaybackState::Stopped,
-            current_uri: None,
-            current_metadata: None,
+            now_playing: NowPlaying::default(),
             next_uri: None,
             next_metadata: None,
-            position: None,
-            duration: None,
+            track_count: 0,
             volume: 100,
             mute: false,
+            balance: 0,
+            channel_swap: false,
+            mono: false,
+            output_profile: OutputProfile::Speakers,
+            crossfeed: true,
+            bit_perfect: false,
+            session: None,
             pending_commands: VecDeque::new(),
         }
     }
@@ -0,0 +1,144 @@
+//! Alimentation des variables du service Time depuis l'horloge de lecture
+
+use std::sync::Arc;
+use std::time::Duration;
+
+use pmoupnp::devices::DeviceInstance;
+use tokio_util::sync::CancellationToken;
+
+use crate::state::SharedState;
+
+/// Période d'échantillonnage de l'horloge de lecture
+const TIME_EVENT_PERIOD: Duration = Duration::from_secs(1);
+
+/// Valeurs courantes du service Time
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub(crate) struct TimeValues {
+    pub track_count: u32,
+    pub duration: u32,
+    pub seconds: u32,
+}
+
+impl TimeValues {
+    pub(crate) fn from_state(state: &SharedState) -> Self {
+        let s = state.read();
+        Self {
+            track_count: s.track_count,
+            duration: s.now_playing.duration_sec.map_or(0, |d| d as u32),
+            seconds: s.now_playing.position_sec.map_or(0, |p| p as u32),
+        }
+    }
+
+    /// Variables à notifier depuis l'échantillon `last` (toutes au premier),
+    /// dans l'ordre TrackCount, Duration, Seconds
+    fn changes(&self, last: Option<&TimeValues>) -> [Option<u32>; 3] {
+        let changed =
+            |value: u32, previous: Option<u32>| (previous != Some(value)).then_some(value);
+        [
+            changed(self.track_count, last.map(|l| l.track_count)),
+            changed(self.duration, last.map(|l| l.duration)),
+            changed(self.seconds, last.map(|l| l.seconds)),
+        ]
+    }
+}
+
+/// Lance la tâche qui recopie l'horloge de lecture dans le service Time.
+///
+/// L'état est échantillonné toutes les secondes et seules les variables
+/// modifiées sont mises à jour : les événements GENA sont ainsi limités à
+/// 1 Hz, ce qu'attendent les control points OpenHome. La tâche s'arrête
+/// avec le pipeline de l'instance.
+pub fn spawn_time_eventer(
+    device: &Arc<DeviceInstance>,
+    state: SharedState,
+    stop_token: CancellationToken,
+) -> Option<tokio::task::JoinHandle<()>> {
+    let service = device.get_service("Time")?;
+    let track_count = service.get_typed_variable::<u32>("TrackCount")?;
+    let duration = service.get_typed_variable::<u32>("Duration")?;
+    let seconds = service.get_typed_variable::<u32>("Seconds")?;
+
+    Some(tokio::spawn(async move {
+        let mut ticker = tokio::time::interval(TIME_EVENT_PERIOD);
+        let mut last: Option<TimeValues> = None;
+
+        loop {
+            tokio::select! {
+                _ = stop_token.cancelled() => break,
+                _ = ticker.tick() => {}
+            }
+
+            let current = TimeValues::from_state(&state);
+            let [new_track_count, new_duration, new_seconds] = current.changes(last.as_ref());
+            if let Some(value) = new_track_count {
+                let _ = track_count.set(value).await;
+            }
+            if let Some(value) = new_duration {
+                let _ = duration.set(value).await;
+            }
+            if let Some(value) = new_seconds {
+                let _ = seconds.set(value).await;
+            }
+
+            last = Some(current);
+        }
+
+        tracing::debug!("[MediaRenderer] Time eventer stopped");
+    }))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::state::RendererState;
+    use parking_lot::RwLock;
+
+    fn state(track_count: u32, duration: Option<f64>, position: Option<f64>) -> SharedState {
+        let mut s = RendererState::default();
+        s.track_count = track_count;
+        s.now_playing.duration_sec = duration;
+        s.now_playing.position_sec = position;
+        Arc::new(RwLock::new(s))
+    }
+
+    #[test]
+    fn test_from_state() {
+        let values = TimeValues::from_state(&state(4, Some(241.7), Some(12.9)));
+        assert_eq!(
+            values,
+            TimeValues {
+                track_count: 4,
+                duration: 241,
+                seconds: 12,
+            }
+        );
+
+        // Flux continu ou rien en lecture : durée et position à 0
+        assert_eq!(
+            TimeValues::from_state(&state(0, None, None)),
+            TimeValues::default()
+        );
+    }
+
+    #[test]
+    fn test_first_sample_sends_everything() {
+        let values = TimeValues::default();
+        assert_eq!(values.changes(None), [Some(0), Some(0), Some(0)]);
+    }
+
+    #[test]
+    fn test_only_changed_values_are_sent() {
+        let first = TimeValues::from_state(&state(1, Some(180.0), Some(10.2)));
+        let same_second = TimeValues::from_state(&state(1, Some(180.0), Some(10.8)));
+        assert_eq!(same_second.changes(Some(&first)), [None, None, None]);
+
+        let next_second = TimeValues::from_state(&state(1, Some(180.0), Some(11.1)));
+        assert_eq!(next_second.changes(Some(&first)), [None, None, Some(11)]);
+
+        let next_track = TimeValues::from_state(&state(2, Some(200.0), Some(0.0)));
+        assert_eq!(
+            next_track.changes(Some(&next_second)),
+            [Some(2), Some(200), Some(0)]
+        );
+    }
+}
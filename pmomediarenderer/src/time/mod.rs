@@ -0,0 +1,36 @@
+//! # Time Service - Service OpenHome de progression de lecture
+//!
+//! Ce module implémente le service `urn:av-openhome-org:service:Time:1`.
+//! Les control points OpenHome (Linn Kazoo, Lumin, BubbleUPnP, ...) s'abonnent
+//! à ce service pour afficher la barre de progression au lieu d'interroger
+//! `GetPositionInfo` en boucle.
+//!
+//! ## Fonctionnalités
+//!
+//! - **Time** : retourne TrackCount, Duration et Seconds
+//!
+//! Le service est assemblé par la fabrique du renderer, qui y attache le
+//! handler de l'action `Time` : ce module n'en fournit que les variables.
+//!
+//! ## Variables d'état
+//!
+//! - [`TRACKCOUNT`] : Nombre de pistes démarrées depuis le lancement du renderer
+//! - [`DURATION`] : Durée de la piste courante en secondes (0 si inconnue)
+//! - [`SECONDS`] : Position dans la piste courante en secondes
+//!
+//! Les trois variables sont évènementielles. Elles sont alimentées depuis
+//! l'horloge de lecture par [`spawn_time_eventer`](crate::time::spawn_time_eventer)
+//! et les notifications sont modérées à 1 Hz par le notifier du service.
+//!
+//! ## Références
+//!
+//! - [OpenHome Time:1](http://wiki.openhome.org/wiki/Av:Developer:TimeService)
+
+mod eventer;
+pub mod variables;
+
+pub use eventer::spawn_time_eventer;
+pub(crate) use eventer::TimeValues;
+
+/// Domaine des services OpenHome
+pub const OPENHOME_DOMAIN: &str = "av-openhome-org";
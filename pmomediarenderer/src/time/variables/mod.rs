@@ -0,0 +1,7 @@
+mod duration;
+mod seconds;
+mod trackcount;
+
+pub use duration::DURATION;
+pub use seconds::SECONDS;
+pub use trackcount::TRACKCOUNT;
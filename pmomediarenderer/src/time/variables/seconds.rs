@@ -0,0 +1,7 @@
+use pmoupnp::define_variable;
+
+define_variable! {
+    pub static SECONDS: UI4 = "Seconds" {
+        evented: true,
+    }
+}
@@ -0,0 +1,488 @@
+//! # Admin API - Maintenance du serveur
+//!
+//! Endpoints d'administration regroupés sous `/api/admin` :
+//!
+//! - `POST /api/admin/covers/purge` - Vide le cache de couvertures
+//! - `POST /api/admin/covers/consolidate` - Supprime les orphelins et re-télécharge les fichiers manquants
+//! - `POST /api/admin/library/rescan` - Relit le catalogue des sources (toutes ou `?source=<id>`)
+//! - `GET /api/admin/subscriptions` - Liste les abonnements GENA de tous les devices
+//! - `DELETE /api/admin/subscriptions/{sid}` - Révoque un abonnement
+//! - `POST /api/admin/devices/{udn}/services/{service}/notifier/restart` - Redémarre le notifier d'un service
+//!
+//! La description OpenAPI est publiée dans `/api-docs/admin.json` et intégrée
+//! à la spécification globale `/api/openapi.json`.
+
+use crate::contentdirectory::state;
+use axum::{
+    Router,
+    extract::{Json, Path, Query},
+    http::StatusCode,
+    response::IntoResponse,
+    routing::{delete, get, post},
+};
+use pmoserver::Server;
+use pmosource::MusicSource;
+use pmoupnp::{UpnpTyped, upnp_server};
+use serde::{Deserialize, Serialize};
+use tracing::{info, warn};
+use utoipa::OpenApi;
+
+/// Résultat d'une opération d'administration
+#[derive(Debug, Serialize, utoipa::ToSchema)]
+pub struct AdminResponse {
+    /// Message de succès
+    pub message: String,
+}
+
+/// Message d'erreur
+#[derive(Debug, Serialize, utoipa::ToSchema)]
+pub struct AdminError {
+    /// Message d'erreur
+    pub error: String,
+}
+
+/// Paramètres de `POST /library/rescan`
+#[derive(Debug, Deserialize, utoipa::IntoParams)]
+pub struct RescanParams {
+    /// ID de la source à relire (toutes si absent)
+    pub source: Option<String>,
+}
+
+/// Résultat du rescan d'une source
+#[derive(Debug, Serialize, utoipa::ToSchema)]
+pub struct RescanResult {
+    /// ID de la source
+    pub source_id: String,
+    /// Le catalogue a été relu
+    pub rescanned: bool,
+    /// Raison de l'échec (ou opération non supportée)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub error: Option<String>,
+}
+
+/// Abonnement GENA actif
+#[derive(Debug, Serialize, utoipa::ToSchema)]
+pub struct SubscriptionInfo {
+    /// Identifiant de souscription
+    pub sid: String,
+    /// URL(s) de callback
+    pub callback: String,
+    /// UDN du device
+    pub udn: String,
+    /// Nom du service
+    pub service: String,
+}
+
+fn error_response(status: StatusCode, error: impl Into<String>) -> axum::response::Response {
+    (
+        status,
+        Json(AdminError {
+            error: error.into(),
+        }),
+    )
+        .into_response()
+}
+
+/// Vide le cache de couvertures
+#[utoipa::path(
+    post,
+    path = "/covers/purge",
+    responses(
+        (status = 200, description = "Cache vidé", body = AdminResponse),
+        (status = 503, description = "Cache non initialisé", body = AdminError),
+        (status = 500, description = "Erreur lors de la purge", body = AdminError),
+    ),
+    tag = "admin"
+)]
+async fn purge_covers() -> impl IntoResponse {
+    let Some(cache) = pmoupnp::get_cover_cache() else {
+        return error_response(StatusCode::SERVICE_UNAVAILABLE, "cover cache not initialized");
+    };
+    match cache.purge().await {
+        Ok(()) => {
+            info!("🧹 Cover cache purged from admin API");
+            Json(AdminResponse {
+                message: "Cover cache purged".to_string(),
+            })
+            .into_response()
+        }
+        Err(e) => error_response(StatusCode::INTERNAL_SERVER_ERROR, e.to_string()),
+    }
+}
+
+/// Consolide le cache de couvertures
+#[utoipa::path(
+    post,
+    path = "/covers/consolidate",
+    responses(
+        (status = 200, description = "Cache consolidé", body = AdminResponse),
+        (status = 503, description = "Cache non initialisé", body = AdminError),
+        (status = 500, description = "Erreur lors de la consolidation", body = AdminError),
+    ),
+    tag = "admin"
+)]
+async fn consolidate_covers() -> impl IntoResponse {
+    let Some(cache) = pmoupnp::get_cover_cache() else {
+        return error_response(StatusCode::SERVICE_UNAVAILABLE, "cover cache not initialized");
+    };
+    match cache.consolidate().await {
+        Ok(()) => {
+            info!("🧹 Cover cache consolidated from admin API");
+            Json(AdminResponse {
+                message: "Cover cache consolidated".to_string(),
+            })
+            .into_response()
+        }
+        Err(e) => error_response(StatusCode::INTERNAL_SERVER_ERROR, e.to_string()),
+    }
+}
+
+/// Relit le catalogue des sources musicales
+///
+/// Les clients UPnP sont prévenus par un incrément de SystemUpdateID dès
+/// qu'au moins une source a été relue.
+#[utoipa::path(
+    post,
+    path = "/library/rescan",
+    params(RescanParams),
+    responses(
+        (status = 200, description = "Résultat par source", body = [RescanResult]),
+        (status = 404, description = "Source inconnue", body = AdminError),
+    ),
+    tag = "admin"
+)]
+async fn rescan_library(Query(params): Query<RescanParams>) -> impl IntoResponse {
+    let sources = match params.source {
+        Some(id) => match pmosource::api::get_source(&id).await {
+            Some(source) => vec![source],
+            None => {
+                return error_response(StatusCode::NOT_FOUND, format!("unknown source '{}'", id));
+            }
+        },
+        None => pmosource::api::list_all_sources().await,
+    };
+
+    let mut results = Vec::with_capacity(sources.len());
+    for source in sources {
+        let source_id = source.id().to_string();
+        match source.rescan().await {
+            Ok(()) => {
+                info!("🔄 Source {} rescanned", source_id);
+                results.push(RescanResult {
+                    source_id,
+                    rescanned: true,
+                    error: None,
+                });
+            }
+            Err(e) => {
+                warn!("Rescan of source {} failed: {}", source_id, e);
+                results.push(RescanResult {
+                    source_id,
+                    rescanned: false,
+                    error: Some(e.to_string()),
+                });
+            }
+        }
+    }
+
+    if results.iter().any(|r| r.rescanned) {
+        state::notify_containers_updated(&["0"]);
+    }
+
+    Json(results).into_response()
+}
+
+/// Liste les abonnements GENA de tous les devices
+#[utoipa::path(
+    get,
+    path = "/subscriptions",
+    responses(
+        (status = 200, description = "Abonnements actifs", body = [SubscriptionInfo]),
+    ),
+    tag = "admin"
+)]
+async fn list_subscriptions() -> impl IntoResponse {
+    let subscriptions: Vec<SubscriptionInfo> = upnp_server::with_devices(|devices| {
+        devices
+            .iter()
+            .flat_map(|device| {
+                device.services().into_iter().flat_map(move |service| {
+                    service
+                        .subscribers()
+                        .into_iter()
+                        .map(move |(sid, callback)| SubscriptionInfo {
+                            sid,
+                            callback,
+                            udn: device.udn().to_string(),
+                            service: service.get_name().to_string(),
+                        })
+                })
+            })
+            .collect()
+    });
+
+    Json(subscriptions)
+}
+
+/// Révoque un abonnement GENA
+#[utoipa::path(
+    delete,
+    path = "/subscriptions/{sid}",
+    params(
+        ("sid" = String, Path, description = "Identifiant de souscription (uuid:...)")
+    ),
+    responses(
+        (status = 200, description = "Abonnement révoqué", body = AdminResponse),
+        (status = 404, description = "Abonnement inconnu", body = AdminError),
+    ),
+    tag = "admin"
+)]
+async fn evict_subscription(Path(sid): Path<String>) -> impl IntoResponse {
+    let services: Vec<_> = upnp_server::with_devices(|devices| {
+        devices
+            .iter()
+            .flat_map(|device| device.services())
+            .filter(|service| service.subscribers().iter().any(|(s, _)| *s == sid))
+            .collect()
+    });
+
+    if services.is_empty() {
+        return error_response(StatusCode::NOT_FOUND, format!("unknown subscription '{}'", sid));
+    }
+    for service in services {
+        service.remove_subscriber(&sid).await;
+        info!("🚫 Subscription {} evicted from {}", sid, service.get_name());
+    }
+
+    Json(AdminResponse {
+        message: format!("Subscription {} evicted", sid),
+    })
+    .into_response()
+}
+
+/// Redémarre le notifier périodique d'un service
+#[utoipa::path(
+    post,
+    path = "/devices/{udn}/services/{service}/notifier/restart",
+    params(
+        ("udn" = String, Path, description = "UDN du device"),
+        ("service" = String, Path, description = "Nom du service")
+    ),
+    responses(
+        (status = 200, description = "Notifier redémarré", body = AdminResponse),
+        (status = 404, description = "Device ou service inconnu", body = AdminError),
+        (status = 409, description = "Aucun notifier démarré pour ce service", body = AdminError),
+    ),
+    tag = "admin"
+)]
+async fn restart_notifier(Path((udn, service_name)): Path<(String, String)>) -> impl IntoResponse {
+    let Some(device) = upnp_server::get_device_by_udn(&udn) else {
+        return error_response(StatusCode::NOT_FOUND, format!("unknown device '{}'", udn));
+    };
+    let Some(service) = device.get_service(&service_name) else {
+        return error_response(
+            StatusCode::NOT_FOUND,
+            format!("unknown service '{}'", service_name),
+        );
+    };
+
+    if !service.restart_notifier() {
+        return error_response(
+            StatusCode::CONFLICT,
+            format!("no notifier started for service '{}'", service_name),
+        );
+    }
+
+    Json(AdminResponse {
+        message: format!("Notifier restarted for {}/{}", udn, service_name),
+    })
+    .into_response()
+}
+
+/// Documentation OpenAPI de l'API d'administration
+#[derive(OpenApi)]
+#[openapi(
+    paths(
+        purge_covers,
+        consolidate_covers,
+        rescan_library,
+        list_subscriptions,
+        evict_subscription,
+        restart_notifier,
+    ),
+    components(
+        schemas(
+            AdminResponse,
+            AdminError,
+            RescanResult,
+            SubscriptionInfo,
+        )
+    ),
+    tags(
+        (name = "admin", description = "Maintenance des caches, de la bibliothèque et des abonnements UPnP")
+    ),
+    info(
+        title = "PMOMusic Admin API",
+        version = "1.0.0",
+        description = "Administration du serveur : caches, bibliothèque, abonnements GENA"
+    )
+)]
+pub struct AdminApiDoc;
+
+/// Crée le router de l'API d'administration
+pub fn create_admin_router() -> Router {
+    Router::new()
+        .route("/covers/purge", post(purge_covers))
+        .route("/covers/consolidate", post(consolidate_covers))
+        .route("/library/rescan", post(rescan_library))
+        .route("/subscriptions", get(list_subscriptions))
+        .route("/subscriptions/{sid}", delete(evict_subscription))
+        .route(
+            "/devices/{udn}/services/{service}/notifier/restart",
+            post(restart_notifier),
+        )
+}
+
+/// Trait d'extension pour enregistrer l'API d'administration sur un serveur.
+#[async_trait::async_trait]
+pub trait AdminApiExt {
+    /// Enregistre l'API d'administration sous `/api/admin`.
+    ///
+    /// # Examples
+    ///
+    /// ```ignore
+    /// use pmomediaserver::AdminApiExt;
+    ///
+    /// server.write().await.register_admin_api().await;
+    /// ```
+    async fn register_admin_api(&mut self);
+}
+
+#[async_trait::async_trait]
+impl AdminApiExt for Server {
+    async fn register_admin_api(&mut self) {
+        self.add_openapi(create_admin_router(), AdminApiDoc::openapi(), "admin")
+            .await;
+        info!("✅ Admin API registered at /api/admin (spec: /api/openapi.json)");
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use axum::{body::Body, http};
+    use pmoserver::ServerBuilder;
+    use pmoupnp::UpnpServerExt;
+    use pmoupnp::devices::DeviceBuilder;
+    use pmoupnp::services::ServiceBuilder;
+    use std::sync::Arc;
+    use tower::ServiceExt;
+
+    async fn send(method: http::Method, uri: &str) -> (StatusCode, serde_json::Value) {
+        let request = http::Request::builder()
+            .method(method)
+            .uri(uri)
+            .body(Body::empty())
+            .unwrap();
+        let response = create_admin_router().oneshot(request).await.unwrap();
+        let status = response.status();
+        let body = axum::body::to_bytes(response.into_body(), usize::MAX)
+            .await
+            .unwrap();
+        (status, serde_json::from_slice(&body).unwrap())
+    }
+
+    #[tokio::test]
+    async fn test_unknown_targets() {
+        let (status, body) = send(http::Method::POST, "/library/rescan?source=nope").await;
+        assert_eq!(status, StatusCode::NOT_FOUND);
+        assert_eq!(body["error"], "unknown source 'nope'");
+
+        let (status, _) = send(http::Method::DELETE, "/subscriptions/uuid:nope").await;
+        assert_eq!(status, StatusCode::NOT_FOUND);
+
+        let (status, _) = send(
+            http::Method::POST,
+            "/devices/uuid:nope/services/AVTransport/notifier/restart",
+        )
+        .await;
+        assert_eq!(status, StatusCode::NOT_FOUND);
+    }
+
+    #[tokio::test]
+    async fn test_covers_without_cache() {
+        // Aucun cache de couvertures n'est initialisé dans les tests
+        let (status, _) = send(http::Method::POST, "/covers/purge").await;
+        assert_eq!(status, StatusCode::SERVICE_UNAVAILABLE);
+        let (status, _) = send(http::Method::POST, "/covers/consolidate").await;
+        assert_eq!(status, StatusCode::SERVICE_UNAVAILABLE);
+    }
+
+    #[tokio::test]
+    async fn test_subscriptions_and_notifier() {
+        let mut server = ServerBuilder::new("AdminTest", "http://localhost", 8080).build();
+        let device = DeviceBuilder::new("AdminTestDevice", "MediaServer")
+            .udn("uuid:7c1e2a40-0000-4000-8000-0000000000ad")
+            .service(Arc::new(
+                ServiceBuilder::new("AdminTestService").build().unwrap(),
+            ))
+            .build()
+            .unwrap();
+        let instance = server
+            .register_device(Arc::new(device), false)
+            .await
+            .unwrap();
+        let udn = instance.udn().to_string();
+        let service = instance.get_service("AdminTestService").unwrap();
+        let sid = "uuid:admin-test-subscriber".to_string();
+        service
+            .add_subscriber(sid.clone(), "<http://127.0.0.1:9/callback>".into())
+            .await;
+
+        let (status, body) = send(http::Method::GET, "/subscriptions").await;
+        assert_eq!(status, StatusCode::OK);
+        let listed = body
+            .as_array()
+            .unwrap()
+            .iter()
+            .find(|s| s["sid"] == sid.as_str())
+            .unwrap();
+        assert_eq!(listed["udn"], udn.as_str());
+        assert_eq!(listed["service"], "AdminTestService");
+
+        let restart = format!(
+            "/devices/{}/services/AdminTestService/notifier/restart",
+            udn
+        );
+        let (status, _) = send(http::Method::POST, &restart).await;
+        assert_eq!(status, StatusCode::OK);
+        assert!(service.notifier_running());
+
+        let unknown = format!("/devices/{}/services/Missing/notifier/restart", udn);
+        let (status, _) = send(http::Method::POST, &unknown).await;
+        assert_eq!(status, StatusCode::NOT_FOUND);
+
+        let (status, _) = send(http::Method::DELETE, &format!("/subscriptions/{}", sid)).await;
+        assert_eq!(status, StatusCode::OK);
+        assert!(service.subscribers().is_empty());
+    }
+
+    #[test]
+    fn test_openapi_documents_every_route() {
+        let doc = AdminApiDoc::openapi();
+        for path in [
+            "/covers/purge",
+            "/covers/consolidate",
+            "/library/rescan",
+            "/subscriptions",
+            "/subscriptions/{sid}",
+            "/devices/{udn}/services/{service}/notifier/restart",
+        ] {
+            assert!(
+                doc.paths.paths.contains_key(path),
+                "{} not documented",
+                path
+            );
+        }
+    }
+}
@@ -12,7 +12,7 @@
 
 use pmodidl::{Container, DIDLLite};
 use pmosource::api::{get_source as get_source_from_registry, list_all_sources};
-use pmosource::{BrowseResult, MediaSearchType, MusicSource, MusicSourceError, SearchQuery, SearchScope};
+use pmosource::{BrowseResult, MediaSearchType, MusicSource, MusicSourceError, ObjectId, SearchQuery, SearchScope};
 use pmodidl::ToXmlElement;
 use std::collections::HashSet;
 use std::sync::Arc;
@@ -45,6 +45,19 @@ fn to_didl_lite(containers: &[Container], items: &[pmodidl::Item]) -> Result<Str
     Ok(body)
 }
 
+/// Sources à interroger pour un ObjectID
+///
+/// La source désignée par le préfixe de l'identifiant est seule interrogée ;
+/// un identifiant sans propriétaire connu est proposé à toutes les sources.
+async fn sources_for_object(object_id: &str) -> Vec<Arc<dyn MusicSource>> {
+    if let Some(source_id) = ObjectId::parse(object_id).ok().as_ref().and_then(|id| id.source()) {
+        if let Some(source) = get_source_from_registry(source_id).await {
+            return vec![source];
+        }
+    }
+    list_all_sources().await
+}
+
 /// Extrait le texte de recherche depuis un critère UPnP SearchCriteria.
 ///
 /// Exemples supportés :
@@ -237,7 +250,7 @@ impl ContentHandler {
             }
 
             // Try to get item metadata first (for leaf items)
-            for source in list_all_sources().await {
+            for source in sources_for_object(object_id).await {
                 match source.get_item(object_id).await {
                     Ok(item) => {
                         let didl = to_didl_lite(&[], &[item])?;
@@ -260,7 +273,7 @@ impl ContentHandler {
 
             // Fallback to browse for containers
             let mut non_not_found_error: Option<String> = None;
-            for source in list_all_sources().await {
+            for source in sources_for_object(object_id).await {
                 match source.browse(object_id).await {
                     Ok(result) => {
                         // L'objet a été trouvé, retourner ses métadonnées
@@ -397,7 +410,7 @@ impl ContentHandler {
 
         // Sinon, chercher dans les sources
         let mut non_not_found_error: Option<String> = None;
-        for source in list_all_sources().await {
+        for source in sources_for_object(object_id).await {
             match source.browse(object_id).await {
                 Ok(result) => {
                     return self
@@ -72,6 +72,10 @@ pub mod server_ext;
 pub mod source_registry;
 pub mod sources;
 
+// API d'administration (caches, bibliothèque, abonnements)
+#[cfg(feature = "api")]
+pub mod admin_api;
+
 // API REST pour l'enregistrement des sources (requires features qobuz/paradise)
 #[cfg(any(feature = "qobuz", feature = "paradise"))]
 pub mod sources_api;
@@ -80,6 +84,10 @@ pub mod sources_api;
 #[cfg(feature = "paradise")]
 pub mod paradise_streaming;
 
+// Proxy de transcodage à la volée (requires feature transcode)
+#[cfg(feature = "transcode")]
+pub mod transcode;
+
 pub use content_handler::ContentHandler;
 pub use device::MEDIA_SERVER;
 pub use device_ext::MediaServerDeviceExt;
@@ -90,6 +98,12 @@ pub use sources::{SourceInitError, SourcesExt};
 #[cfg(feature = "paradise")]
 pub use paradise_streaming::ParadiseStreamingExt;
 
+#[cfg(feature = "api")]
+pub use admin_api::AdminApiExt;
+
+#[cfg(feature = "transcode")]
+pub use transcode::TranscodeExt;
+
 // Re-export sources when features are enabled
 #[cfg(feature = "qobuz")]
 pub use pmoqobuz;
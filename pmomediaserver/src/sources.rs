@@ -27,6 +27,14 @@ pub enum SourceInitError {
     #[error("Failed to initialize URL source: {0}")]
     UrlSourceError(String),
 
+    #[cfg(feature = "podcasts")]
+    #[error("Failed to initialize podcasts: {0}")]
+    PodcastError(String),
+
+    #[cfg(feature = "favorites")]
+    #[error("Failed to initialize favorites: {0}")]
+    FavoritesError(String),
+
     #[error("Configuration error: {0}")]
     ConfigError(String),
 
@@ -153,6 +161,36 @@ pub trait SourcesExt {
     /// directement. Aucune authentification requise.
     #[cfg(feature = "urlsource")]
     async fn register_urlsource(&mut self) -> Result<()>;
+
+    /// Enregistre le backend podcasts
+    ///
+    /// Charge les abonnements, lance le rafraîchissement des flux, expose
+    /// l'API `/api/podcasts` et monte le container « Podcasts » (identifiant
+    /// `podcasts`). Le provider reste disponible sous le nom `podcasts` pour
+    /// des montages supplémentaires dans `sources.virtual_folders`.
+    #[cfg(feature = "podcasts")]
+    async fn register_podcasts(&mut self) -> Result<()>;
+
+    /// Enregistre les favoris
+    ///
+    /// Ouvre la base des favoris, expose l'API `/api/favorites` et monte le
+    /// container « Favoris » (identifiant `favorites`). Le provider reste
+    /// disponible sous le nom `favorites` pour `sources.virtual_folders`.
+    #[cfg(feature = "favorites")]
+    async fn register_favorites(&mut self) -> Result<()>;
+
+    /// Monte les dossiers virtuels configurés
+    ///
+    /// Chaque entrée de `sources.virtual_folders` instancie un
+    /// `ContentProvider` via la fabrique nommée par `provider` (voir
+    /// `pmosource::provider::register_provider_factory`) et l'enregistre
+    /// comme source de premier niveau. Un montage invalide est ignoré avec
+    /// un avertissement.
+    ///
+    /// # Returns
+    ///
+    /// Le nombre de dossiers montés
+    async fn register_virtual_folders(&mut self) -> Result<usize>;
 }
 
 #[async_trait::async_trait]
@@ -321,6 +359,82 @@ impl SourcesExt for Server {
 
         Ok(())
     }
+
+    #[cfg(feature = "podcasts")]
+    async fn register_podcasts(&mut self) -> Result<()> {
+        use pmopodcast::{PodcastExt, PodcastProvider};
+        use pmosource::provider::ProviderSource;
+
+        tracing::info!("Initializing podcasts...");
+
+        let manager = self
+            .init_podcasts()
+            .await
+            .map_err(|e| SourceInitError::PodcastError(e.to_string()))?;
+
+        let provider = Arc::new(PodcastProvider::new(manager));
+        let source = ProviderSource::new("podcasts", "Podcasts", provider);
+        self.register_music_source(Arc::new(source)).await;
+
+        tracing::info!("✅ Podcasts source registered successfully");
+
+        Ok(())
+    }
+
+    #[cfg(feature = "favorites")]
+    async fn register_favorites(&mut self) -> Result<()> {
+        use pmofavorites::{FavoritesExt, FavoritesProvider};
+        use pmosource::provider::ProviderSource;
+
+        tracing::info!("Initializing favorites...");
+
+        let store = self
+            .init_favorites()
+            .await
+            .map_err(|e| SourceInitError::FavoritesError(e.to_string()))?;
+
+        let provider = Arc::new(FavoritesProvider::new(store));
+        let source = ProviderSource::new("favorites", "Favoris", provider);
+        self.register_music_source(Arc::new(source)).await;
+
+        tracing::info!("✅ Favorites source registered successfully");
+
+        Ok(())
+    }
+
+    async fn register_virtual_folders(&mut self) -> Result<usize> {
+        use pmosource::provider;
+
+        let mounts = provider::mounts_from_config()
+            .map_err(|e| SourceInitError::ConfigError(format!("sources.virtual_folders: {}", e)))?;
+
+        let mut mounted = 0;
+        for config in mounts {
+            if pmosource::api::get_source(&config.id).await.is_some() {
+                tracing::warn!(
+                    "⚠️ Virtual folder '{}' conflicts with an existing source, skipped",
+                    config.id
+                );
+                continue;
+            }
+            match provider::mount(&config) {
+                Ok(source) => {
+                    self.register_music_source(Arc::new(source)).await;
+                    tracing::info!(
+                        "📁 Virtual folder '{}' mounted ({} provider)",
+                        config.id,
+                        config.provider
+                    );
+                    mounted += 1;
+                }
+                Err(e) => {
+                    tracing::warn!("⚠️ Failed to mount virtual folder '{}': {}", config.id, e)
+                }
+            }
+        }
+
+        Ok(mounted)
+    }
 }
 
 #[cfg(test)]
@@ -0,0 +1,425 @@
+//! Proxy de transcodage à la volée
+//!
+//! `GET /transcode?src=<url>&profile=<nom>[&bitrate=<kbit/s>][&dither=<mode>]` télécharge un flux audio distant,
+//! le décode, le fait passer par un pipeline pmoaudio dédié et sert le
+//! résultat dans le format du profil demandé.
+//!
+//! Utilisé par le proxy MediaServer (`upnp-proxy`) et par le point de
+//! contrôle quand un renderer ne sait pas décoder le format source.
+//!
+//! # Profils
+//!
+//! | Profil       | Format   | Fréquence | Bits | Débit      | Dither   |
+//! |--------------|----------|-----------|------|------------|----------|
+//! | `flac`       | FLAC     | d'origine | 24   |            |          |
+//! | `flac-cd`    | FLAC     | 44,1 kHz  | 16   |            | `shaped` |
+//! | `flac-hires` | FLAC     | 96 kHz    | 24   |            |          |
+//! | `wav`        | WAV      | d'origine | 16   |            | `tpdf`   |
+//! | `wav-cd`     | WAV      | 44,1 kHz  | 16   |            | `shaped` |
+//! | `aiff`       | AIFF     | d'origine | 16   |            | `tpdf`   |
+//! | `l16`        | LPCM     | 44,1 kHz  | 16   |            | `tpdf`   |
+//! | `l24`        | LPCM     | 48 kHz    | 24   |            |          |
+//! | `opus`       | Ogg/Opus | 48 kHz    | 16   | 96 kbit/s  | `off`    |
+//! | `opus-low`   | Ogg/Opus | 48 kHz    | 16   | 32 kbit/s  | `off`    |
+//!
+//! Les profils LPCM (PCM brut big-endian, servi en
+//! `audio/L16;rate=44100;channels=2`) visent les renderers DLNA qui
+//! n'acceptent que ce format pour les flux non compressés ; leur fréquence
+//! est fixe car elle figure dans le type MIME.
+//!
+//! En WAV et AIFF, l'en-tête déclare la taille exacte des données (RF64
+//! au-delà de 4 Go en WAV) quand la source annonce sa durée ; sinon le flux
+//! est servi comme de longueur inconnue.
+//!
+//! Les profils Opus visent l'écoute à distance sur un lien contraint (VPN) ;
+//! le paramètre `bitrate` (en kbit/s, borné à 6–510) remplace leur débit.
+//!
+//! Les profils 16 bits réduisent la profondeur des sources 24 bits ou
+//! flottantes avec un dither TPDF (`tpdf`), éventuellement à mise en forme
+//! spectrale du bruit (`shaped`), plutôt que par simple arrondi (`off`) ;
+//! le paramètre `dither` remplace le mode du profil.
+//!
+//! Via l'accès distant ([`pmoserver::remote`]), seuls les profils Opus sont
+//! servis (`opus` remplace tout autre profil) et `src` doit désigner un
+//! contenu de ce serveur.
+//!
+//! Sans `profile`, le format est négocié depuis l'en-tête `Accept` : le
+//! premier format accepté parmi FLAC, WAV, AIFF, `audio/L24`, `audio/L16` et
+//! Ogg/Opus, sinon `flac`.
+//!
+//! # Pipeline
+//!
+//! ```text
+//! HttpSource(src) → [ResamplingNode] → ToI16Node (dither) | ToI24Node → TranscodeSink
+//! ```
+
+use axum::{
+    Extension, Router,
+    body::Body,
+    extract::Query,
+    http::{
+        HeaderMap, StatusCode,
+        header::{ACCEPT, CACHE_CONTROL, CONTENT_TYPE},
+    },
+    response::{IntoResponse, Response},
+    routing::get,
+};
+use pmoaudio::dsp::DitherMode;
+use pmoaudio::{AudioPipelineNode, HttpSource, ResamplingNode, ToI16Node, ToI24Node};
+use pmoaudio_ext::{TranscodeContainer, TranscodeFormat, TranscodeSink};
+use pmoflac::EncoderOptions;
+use pmoserver::{RemoteAccess, Server};
+use serde::Deserialize;
+use tokio_util::io::ReaderStream;
+use tokio_util::sync::CancellationToken;
+use tracing::{debug, info, warn};
+
+/// Chemin du endpoint sur le serveur
+pub const TRANSCODE_PATH: &str = "/transcode";
+
+/// Profil de sortie du transcodeur
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct TranscodeProfile {
+    pub name: &'static str,
+    pub container: TranscodeContainer,
+    /// Fréquence imposée (None = fréquence de la source)
+    pub sample_rate: Option<u32>,
+    pub bits_per_sample: u8,
+    /// Débit cible des profils avec perte, en bits/s
+    pub bitrate: Option<u32>,
+    /// Dither appliqué lors de la réduction à 16 bits
+    pub dither: DitherMode,
+}
+
+impl TranscodeProfile {
+    /// Type MIME servi pour ce profil
+    pub fn mime_type(&self) -> String {
+        self.format().mime_type(self.sample_rate)
+    }
+
+    fn format(&self) -> TranscodeFormat {
+        TranscodeFormat {
+            container: self.container,
+            bits_per_sample: self.bits_per_sample,
+            exact_length: true,
+            bitrate: self.bitrate,
+        }
+    }
+
+    /// Copie du profil avec un débit imposé (en kbit/s), pour les profils
+    /// avec perte uniquement
+    pub fn with_bitrate_kbps(&self, kbps: u32) -> Self {
+        let mut profile = *self;
+        if profile.bitrate.is_some() {
+            profile.bitrate = Some(kbps.clamp(MIN_BITRATE_KBPS, MAX_BITRATE_KBPS) * 1000);
+        }
+        profile
+    }
+
+    /// Copie du profil avec un autre mode de dither
+    pub fn with_dither(&self, dither: DitherMode) -> Self {
+        Self { dither, ..*self }
+    }
+}
+
+/// Profils intégrés, le premier est le profil par défaut
+pub const PROFILES: &[TranscodeProfile] = &[
+    TranscodeProfile {
+        name: "flac",
+        container: TranscodeContainer::Flac,
+        sample_rate: None,
+        bits_per_sample: 24,
+        bitrate: None,
+        dither: DitherMode::Off,
+    },
+    TranscodeProfile {
+        name: "flac-cd",
+        container: TranscodeContainer::Flac,
+        sample_rate: Some(44_100),
+        bits_per_sample: 16,
+        bitrate: None,
+        dither: DitherMode::NoiseShaped,
+    },
+    TranscodeProfile {
+        name: "flac-hires",
+        container: TranscodeContainer::Flac,
+        sample_rate: Some(96_000),
+        bits_per_sample: 24,
+        bitrate: None,
+        dither: DitherMode::Off,
+    },
+    TranscodeProfile {
+        name: "wav",
+        container: TranscodeContainer::Wav,
+        sample_rate: None,
+        bits_per_sample: 16,
+        bitrate: None,
+        dither: DitherMode::Tpdf,
+    },
+    TranscodeProfile {
+        name: "wav-cd",
+        container: TranscodeContainer::Wav,
+        sample_rate: Some(44_100),
+        bits_per_sample: 16,
+        bitrate: None,
+        dither: DitherMode::NoiseShaped,
+    },
+    TranscodeProfile {
+        name: "aiff",
+        container: TranscodeContainer::Aiff,
+        sample_rate: None,
+        bits_per_sample: 16,
+        bitrate: None,
+        dither: DitherMode::Tpdf,
+    },
+    TranscodeProfile {
+        name: "l16",
+        container: TranscodeContainer::Lpcm,
+        sample_rate: Some(44_100),
+        bits_per_sample: 16,
+        bitrate: None,
+        dither: DitherMode::Tpdf,
+    },
+    TranscodeProfile {
+        name: "l24",
+        container: TranscodeContainer::Lpcm,
+        sample_rate: Some(48_000),
+        bits_per_sample: 24,
+        bitrate: None,
+        dither: DitherMode::Off,
+    },
+    TranscodeProfile {
+        name: "opus",
+        container: TranscodeContainer::OggOpus,
+        sample_rate: Some(48_000),
+        bits_per_sample: 16,
+        bitrate: Some(96_000),
+        dither: DitherMode::Off,
+    },
+    TranscodeProfile {
+        name: "opus-low",
+        container: TranscodeContainer::OggOpus,
+        sample_rate: Some(48_000),
+        bits_per_sample: 16,
+        bitrate: Some(32_000),
+        dither: DitherMode::Off,
+    },
+];
+
+/// Bornes du paramètre `bitrate` (kbit/s, limites d'Opus)
+const MIN_BITRATE_KBPS: u32 = 6;
+const MAX_BITRATE_KBPS: u32 = 510;
+
+/// Types MIME reconnus dans `Accept`, par ordre de préférence
+const NEGOTIATED: &[(&[&str], &str)] = &[
+    (&["audio/flac", "audio/x-flac"], "flac"),
+    (&["audio/wav", "audio/x-wav", "audio/wave"], "wav"),
+    (&["audio/aiff", "audio/x-aiff"], "aiff"),
+    (&["audio/l24"], "l24"),
+    (&["audio/l16"], "l16"),
+    (&["audio/opus", "audio/ogg"], "opus"),
+];
+
+/// Retourne le profil nommé `name`
+pub fn profile(name: &str) -> Option<&'static TranscodeProfile> {
+    PROFILES.iter().find(|p| p.name.eq_ignore_ascii_case(name))
+}
+
+/// Choisit un profil à partir d'un en-tête `Accept`
+pub fn negotiate_profile(accept: Option<&str>) -> &'static TranscodeProfile {
+    let accept = accept.unwrap_or_default().to_ascii_lowercase();
+    NEGOTIATED
+        .iter()
+        .find(|(mimes, _)| mimes.iter().any(|mime| accept.contains(mime)))
+        .and_then(|(_, name)| profile(name))
+        .unwrap_or(&PROFILES[0])
+}
+
+#[derive(Debug, Deserialize)]
+struct TranscodeParams {
+    src: String,
+    profile: Option<String>,
+    /// Débit en kbit/s (profils avec perte)
+    bitrate: Option<u32>,
+    /// Mode de dither (`off`, `tpdf`, `shaped`)
+    dither: Option<String>,
+}
+
+/// Construit le pipeline de transcodage et lance son exécution
+fn spawn_pipeline(src: String, profile: &TranscodeProfile) -> pmoaudio_ext::TranscodeStream {
+    let (sink, stream) = TranscodeSink::new(profile.format(), EncoderOptions::default());
+
+    let mut converter: Box<dyn AudioPipelineNode> = match profile.bits_per_sample {
+        16 => Box::new(ToI16Node::with_dither(profile.dither)),
+        _ => Box::new(ToI24Node::new()),
+    };
+    converter.register(Box::new(sink));
+
+    let mut source: Box<dyn AudioPipelineNode> = Box::new(HttpSource::new(src.clone()));
+    match profile.sample_rate {
+        Some(rate) => {
+            let mut resampler: Box<dyn AudioPipelineNode> = Box::new(ResamplingNode::new(rate));
+            resampler.register(converter);
+            source.register(resampler);
+        }
+        None => source.register(converter),
+    }
+
+    tokio::spawn(async move {
+        match source.run(CancellationToken::new()).await {
+            Ok(()) => debug!("🎛️ Transcoding of {} finished", src),
+            Err(e) => debug!("🎛️ Transcoding of {} stopped: {}", src, e),
+        }
+    });
+
+    stream
+}
+
+/// GET /transcode?src=<url>&profile=<nom>
+async fn transcode_handler(
+    Query(params): Query<TranscodeParams>,
+    remote: Option<Extension<RemoteAccess>>,
+    headers: HeaderMap,
+) -> Response {
+    if !(params.src.starts_with("http://") || params.src.starts_with("https://")) {
+        return (StatusCode::BAD_REQUEST, "src must be an http(s) URL").into_response();
+    }
+
+    let profile = match params.profile.as_deref() {
+        Some(name) => match profile(name) {
+            Some(p) => p,
+            None => {
+                warn!("🎛️ Unknown transcoding profile '{}'", name);
+                return (StatusCode::BAD_REQUEST, format!("unknown profile '{}'", name))
+                    .into_response();
+            }
+        },
+        None => negotiate_profile(headers.get(ACCEPT).and_then(|v| v.to_str().ok())),
+    };
+    // Accès distant : contenus locaux uniquement, en Opus
+    let profile = if remote.is_some() {
+        if !is_local_source(&params.src) {
+            warn!("🎛️ Remote transcoding of foreign source {} refused", params.src);
+            return (StatusCode::FORBIDDEN, "src must point to this server").into_response();
+        }
+        if profile.container == TranscodeContainer::OggOpus {
+            profile
+        } else {
+            debug!("🎛️ Remote client: profile '{}' replaced by 'opus'", profile.name);
+            self::profile("opus").unwrap()
+        }
+    } else {
+        profile
+    };
+    let profile = match params.bitrate {
+        Some(kbps) => profile.with_bitrate_kbps(kbps),
+        None => *profile,
+    };
+    let profile = match params.dither.as_deref() {
+        Some(mode) => match DitherMode::parse(mode) {
+            Some(dither) => profile.with_dither(dither),
+            None => {
+                return (StatusCode::BAD_REQUEST, format!("unknown dither mode '{}'", mode))
+                    .into_response();
+            }
+        },
+        None => profile,
+    };
+
+    info!("🎛️ Transcoding {} with profile '{}'", params.src, profile.name);
+    let stream = spawn_pipeline(params.src, &profile);
+
+    Response::builder()
+        .status(StatusCode::OK)
+        .header(CONTENT_TYPE, profile.mime_type())
+        .header(CACHE_CONTROL, "no-cache")
+        .body(Body::from_stream(ReaderStream::new(stream)))
+        .unwrap_or_else(|_| StatusCode::INTERNAL_SERVER_ERROR.into_response())
+}
+
+/// Indique si `src` désigne un contenu servi par ce serveur
+fn is_local_source(src: &str) -> bool {
+    pmoserver::get_server_base_url().is_some_and(|base| {
+        src.strip_prefix(base.trim_end_matches('/'))
+            .is_some_and(|rest| rest.starts_with('/'))
+    })
+}
+
+/// Crée le router du transcodeur
+pub fn create_router() -> Router {
+    Router::new().route(TRANSCODE_PATH, get(transcode_handler))
+}
+
+/// Trait d'extension pour exposer le transcodeur sur le serveur
+#[async_trait::async_trait]
+pub trait TranscodeExt {
+    /// Enregistre le endpoint `/transcode`.
+    ///
+    /// # Examples
+    ///
+    /// ```ignore
+    /// use pmomediaserver::TranscodeExt;
+    ///
+    /// server.write().await.register_transcoder().await;
+    /// ```
+    async fn register_transcoder(&mut self);
+}
+
+#[async_trait::async_trait]
+impl TranscodeExt for Server {
+    async fn register_transcoder(&mut self) {
+        self.add_router("/", create_router()).await;
+        info!("✅ Transcoder registered at {}", TRANSCODE_PATH);
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn negotiates_profile_from_accept() {
+        assert_eq!(negotiate_profile(None).name, "flac");
+        assert_eq!(negotiate_profile(Some("audio/L16;rate=44100")).name, "l16");
+        assert_eq!(negotiate_profile(Some("audio/L16, audio/wav")).name, "wav");
+        assert_eq!(negotiate_profile(Some("audio/x-aiff")).name, "aiff");
+        assert_eq!(negotiate_profile(Some("audio/wav, audio/flac")).name, "flac");
+        assert_eq!(profile("FLAC-CD").map(|p| p.bits_per_sample), Some(16));
+        assert!(profile("mp3").is_none());
+    }
+
+    #[test]
+    fn lpcm_profiles_advertise_rate_and_channels() {
+        assert_eq!(
+            profile("l16").unwrap().mime_type(),
+            "audio/L16;rate=44100;channels=2"
+        );
+        assert_eq!(
+            profile("l24").unwrap().mime_type(),
+            "audio/L24;rate=48000;channels=2"
+        );
+        assert_eq!(profile("aiff").unwrap().mime_type(), "audio/aiff");
+    }
+
+    #[test]
+    fn bitrate_only_applies_to_lossy_profiles() {
+        let opus = profile("opus").unwrap();
+        assert_eq!(opus.with_bitrate_kbps(48).bitrate, Some(48_000));
+        assert_eq!(opus.with_bitrate_kbps(1).bitrate, Some(6_000));
+        assert_eq!(profile("flac").unwrap().with_bitrate_kbps(48).bitrate, None);
+        assert_eq!(
+            negotiate_profile(Some("audio/ogg; codecs=opus")).name,
+            "opus"
+        );
+    }
+
+    #[test]
+    fn sixteen_bit_pcm_profiles_are_dithered() {
+        assert_eq!(profile("flac-cd").unwrap().dither, DitherMode::NoiseShaped);
+        assert_eq!(profile("l16").unwrap().dither, DitherMode::Tpdf);
+        assert_eq!(
+            profile("wav").unwrap().with_dither(DitherMode::Off).dither,
+            DitherMode::Off
+        );
+    }
+}
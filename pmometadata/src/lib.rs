@@ -377,6 +377,23 @@ pub trait TrackMetadata: Send + Sync {
         Err(MetadataError::NotImplemented)
     }
 
+    /// ReplayGain de la piste (dB), tel que lu dans les tags du fichier.
+    async fn get_replay_gain(&self) -> MetadataResult<f64> {
+        Err(MetadataError::NotImplemented)
+    }
+
+    async fn set_replay_gain(&mut self, _value: Option<f64>) -> MetadataResult<()> {
+        Err(MetadataError::NotImplemented)
+    }
+
+    /// Empreinte du contenu audio (clé stable d'un fichier, indépendante de son URL).
+    ///
+    /// Champ en lecture seule : seuls les backends adossés à un fichier
+    /// (cache audio) peuvent le fournir.
+    async fn get_content_hash(&self) -> MetadataResult<String> {
+        Err(MetadataError::NotImplemented)
+    }
+
     /// Retourne l'URL de la cover avec logique de fallback.
     ///
     /// Cette méthode implémente la logique de priorité suivante :
@@ -568,6 +585,7 @@ where
         rating,
         cover_url,
         cover_pk,
+        replay_gain,
         extra
     );
 
@@ -603,6 +621,7 @@ pub struct MemoryTrackMetadata {
     rating: Option<f32>,
     cover_url: Option<String>,
     cover_pk: Option<String>,
+    replay_gain: Option<f64>,
     updated_at: Option<SystemTime>,
     extra: Option<HashMap<String, String>>,
 }
@@ -825,6 +844,16 @@ impl TrackMetadata for MemoryTrackMetadata {
         Ok(Some(()))
     }
 
+    async fn get_replay_gain(&self) -> MetadataResult<f64> {
+        Ok(self.replay_gain)
+    }
+
+    async fn set_replay_gain(&mut self, value: Option<f64>) -> MetadataResult<()> {
+        self.replay_gain = value;
+        self.touch().await?;
+        Ok(Some(()))
+    }
+
     async fn get_extra(&self) -> MetadataResult<HashMap<String, String>> {
         Ok(self.extra.clone())
     }
@@ -1092,6 +1092,17 @@ impl MusicSource for RadioParadiseSource {
         Some(*self.last_change.read().await)
     }
 
+    async fn rescan(&self) -> Result<()> {
+        let client = crate::client::RadioParadiseClient::new()
+            .await
+            .map_err(|e| MusicSourceError::SourceUnavailable(e.to_string()))?;
+        crate::channels::refresh_channels(&client)
+            .await
+            .map_err(|e| MusicSourceError::SourceUnavailable(e.to_string()))?;
+        self.bump_update_counter().await;
+        Ok(())
+    }
+
     async fn get_items(&self, offset: usize, count: usize) -> Result<Vec<Item>> {
         // For Radio Paradise, we don't have a global FIFO
         // Each channel has its own history
@@ -23,7 +23,7 @@ use crate::{
 };
 use anyhow::{anyhow, Context as AnyhowContext, Result};
 use once_cell::sync::OnceCell;
-use pmoaudio::{AudioError, AudioPipelineNode};
+use pmoaudio::{AudioError, AudioPipelineNode, PreGainNode};
 use pmoaudio_ext::{
     FlacClientStream, IcyClientStream, MetadataSnapshot, OggFlacClientStream, OggFlacStreamHandle,
     PlaylistSource, StreamHandle, StreamingFlacSink, StreamingOggFlacSink, StreamingSinkOptions,
@@ -285,12 +285,23 @@ impl ParadiseStreamChannel {
         downstream_children.push(Box::new(ogg_sink));
 
         // 5. Optionnel : ajouter le nœud de cache de covers
-        if let Some(cache) = cover_cache {
+        let downstream_children = if let Some(cache) = cover_cache {
             let mut cover_node = TrackBoundaryCoverNode::new(cache);
             for child in downstream_children {
                 cover_node.register(child);
             }
-            source.register(Box::new(cover_node));
+            vec![Box::new(cover_node) as Box<dyn AudioPipelineNode>]
+        } else {
+            downstream_children
+        };
+
+        // 6. Optionnel : normalisation de sonie (ReplayGain ou mesure mémorisée)
+        if let Some(pregain_db) = pmoaudiocache::get_pregain_db() {
+            let mut pregain_node = PreGainNode::new(pregain_db);
+            for child in downstream_children {
+                pregain_node.register(child);
+            }
+            source.register(Box::new(pregain_node));
         } else {
             for child in downstream_children {
                 source.register(child);
@@ -300,7 +311,7 @@ impl ParadiseStreamChannel {
         stream_handle.set_auto_stop(false);
         ogg_handle.set_auto_stop(false);
 
-        // 6. Lancer le pipeline audio
+        // 7. Lancer le pipeline audio
         let stop_token = CancellationToken::new();
         let pipeline_stop = stop_token.clone();
         let channel_display_name = descriptor.display_name.clone();
@@ -438,7 +449,7 @@ impl ParadiseStreamChannel {
             16,
             self.state.config.max_lead_seconds,
         );
-        source.register(Box::new(flac_sink));
+        source.register(with_pregain(Box::new(flac_sink)));
         let stop_token = CancellationToken::new();
         let stop_clone = stop_token.clone();
         let pipeline = tokio::spawn(async move {
@@ -482,7 +493,7 @@ impl ParadiseStreamChannel {
             16,
             self.state.config.max_lead_seconds,
         );
-        source.register(Box::new(ogg_sink));
+        source.register(with_pregain(Box::new(ogg_sink)));
         let stop_token = CancellationToken::new();
         let stop_clone = stop_token.clone();
         let pipeline = tokio::spawn(async move {
@@ -501,6 +512,19 @@ impl Drop for ParadiseStreamChannel {
     }
 }
 
+/// Insère un nœud de normalisation de sonie devant `node` si la base de
+/// pré-gain du cache audio est disponible.
+fn with_pregain(node: Box<dyn AudioPipelineNode>) -> Box<dyn AudioPipelineNode> {
+    match pmoaudiocache::get_pregain_db() {
+        Some(pregain_db) => {
+            let mut pregain_node = PreGainNode::new(pregain_db);
+            pregain_node.register(node);
+            Box::new(pregain_node)
+        }
+        None => node,
+    }
+}
+
 const MAX_BLOCK_LEAD: Duration = Duration::from_secs(3600);
 const BLOCK_LEAD_CHECK_CHUNK: Duration = Duration::from_secs(300);
 const LIVE_PREFETCH_MIN_TRACKS: usize = 5;
@@ -0,0 +1,224 @@
+//! Étage DSP fourni par un plugin (capacité `dsp_stage`)
+//!
+//! [`PluginDspNode`] s'insère dans un pipeline pmoaudio comme n'importe quel
+//! nœud de traitement : chaque chunk est converti en trames stéréo `f32`,
+//! envoyé au plugin (`dsp.process`) et remplacé par les trames renvoyées.
+//! Le plugin est prévenu du format (`dsp.configure`) avant le premier chunk
+//! et à chaque changement de fréquence d'échantillonnage.
+//!
+//! Les trames transitent en JSON : l'étage convient à des traitements légers
+//! (égalisation, analyse), pas à la convolution de longues réponses
+//! impulsionnelles. Un plugin en panne, trop lent ou qui renvoie un nombre
+//! de trames différent ne coupe pas la lecture : le chunk passe inchangé.
+
+use std::sync::Arc;
+
+use pmoaudio::pipeline::{AudioPipelineNode, Node, NodeLogic, PipelineHandle, send_to_children};
+use pmoaudio::type_constraints::TypeRequirement;
+use pmoaudio::{
+    _AudioSegment, AudioChunk, AudioChunkData, AudioError, AudioSegment, TypedAudioNode,
+};
+use serde_json::json;
+use tokio::sync::mpsc;
+use tokio_util::sync::CancellationToken;
+use tracing::{info, warn};
+
+use crate::error::{PluginError, Result};
+use crate::process::{PluginCommand, PluginProcess};
+use crate::protocol::{Capability, DspFormat, DspFrames};
+use crate::supervisor::PluginSupervisor;
+
+struct PluginDspLogic {
+    supervisor: PluginSupervisor,
+    /// Processus et fréquence annoncée au plugin
+    configured: Option<(Arc<PluginProcess>, u32)>,
+    /// Le dernier chunk est-il passé inchangé ? (un seul avertissement)
+    bypassed: bool,
+}
+
+impl PluginDspLogic {
+    /// Traite un chunk par le plugin
+    async fn process_frames(&mut self, chunk: &AudioChunk) -> Result<AudioChunk> {
+        let process = self.supervisor.process().await?;
+        let sample_rate = chunk.sample_rate();
+        // Un processus relancé doit être configuré à nouveau
+        let configured = self
+            .configured
+            .as_ref()
+            .is_some_and(|(p, rate)| Arc::ptr_eq(p, &process) && *rate == sample_rate);
+        if !configured {
+            process
+                .call::<serde_json::Value>(
+                    "dsp.configure",
+                    json!(DspFormat {
+                        sample_rate,
+                        channels: 2,
+                    }),
+                )
+                .await?;
+            self.configured = Some((Arc::clone(&process), sample_rate));
+        }
+
+        let data = match chunk.to_f32().apply_gain() {
+            AudioChunk::F32(data) => data,
+            _ => unreachable!("to_f32 always returns an F32 chunk"),
+        };
+        let frames = DspFrames {
+            frames: data.get_frames().to_vec(),
+        };
+        let reply: DspFrames = process.call("dsp.process", json!(frames)).await?;
+        if reply.frames.len() != frames.frames.len() {
+            return Err(PluginError::InvalidReply(
+                self.name().to_string(),
+                "dsp.process".to_string(),
+                format!(
+                    "{} frames returned for {}",
+                    reply.frames.len(),
+                    frames.frames.len()
+                ),
+            ));
+        }
+        Ok(AudioChunk::F32(AudioChunkData::new(
+            reply.frames,
+            sample_rate,
+            0.0,
+        )))
+    }
+
+    /// Chunk traité, ou le chunk d'origine si le plugin a échoué
+    async fn process_chunk(&mut self, chunk: &Arc<AudioChunk>) -> Arc<AudioChunk> {
+        match self.process_frames(chunk).await {
+            Ok(processed) => {
+                if std::mem::take(&mut self.bypassed) {
+                    info!("🔌 DSP plugin {} back in the pipeline", self.name());
+                }
+                Arc::new(processed)
+            }
+            Err(e) => {
+                if !std::mem::replace(&mut self.bypassed, true) {
+                    warn!("⚠️ DSP plugin {} bypassed: {}", self.name(), e);
+                }
+                Arc::clone(chunk)
+            }
+        }
+    }
+
+    fn name(&self) -> &str {
+        &self.supervisor.command().command
+    }
+}
+
+#[async_trait::async_trait]
+impl NodeLogic for PluginDspLogic {
+    async fn process(
+        &mut self,
+        input: Option<mpsc::Receiver<Arc<AudioSegment>>>,
+        output: Vec<mpsc::Sender<Arc<AudioSegment>>>,
+        stop_token: CancellationToken,
+    ) -> std::result::Result<(), AudioError> {
+        let mut input = input
+            .ok_or_else(|| AudioError::ProcessingError("PluginDspNode requires an input".into()))?;
+
+        loop {
+            let seg = tokio::select! {
+                _ = stop_token.cancelled() => break,
+                segment = input.recv() => match segment {
+                    None => break,
+                    Some(seg) => seg,
+                },
+            };
+
+            let seg = match &seg.segment {
+                _AudioSegment::Chunk(chunk) => Arc::new(AudioSegment {
+                    order: seg.order,
+                    timestamp_sec: seg.timestamp_sec,
+                    segment: _AudioSegment::Chunk(self.process_chunk(chunk).await),
+                }),
+                _AudioSegment::Sync(_) => seg,
+            };
+
+            send_to_children("PluginDspNode", &output, seg).await?;
+        }
+
+        Ok(())
+    }
+}
+
+/// Nœud de pipeline délégant le traitement du signal à un plugin
+pub struct PluginDspNode {
+    inner: Node<PluginDspLogic>,
+}
+
+impl PluginDspNode {
+    /// Crée le nœud ; le plugin est lancé au premier chunk
+    ///
+    /// `label` nomme l'usage du plugin dans les journaux.
+    pub fn new(label: &str, command: PluginCommand) -> Self {
+        let logic = PluginDspLogic {
+            supervisor: PluginSupervisor::new(label, command, Capability::DspStage),
+            configured: None,
+            bypassed: false,
+        };
+        Self {
+            inner: Node::new_with_input(logic, 16),
+        }
+    }
+}
+
+#[async_trait::async_trait]
+impl AudioPipelineNode for PluginDspNode {
+    fn get_tx(&self) -> Option<mpsc::Sender<Arc<AudioSegment>>> {
+        self.inner.get_tx()
+    }
+
+    fn register(&mut self, child: Box<dyn AudioPipelineNode>) {
+        self.inner.register(child);
+    }
+
+    async fn run(
+        self: Box<Self>,
+        stop_token: CancellationToken,
+    ) -> std::result::Result<(), AudioError> {
+        Box::new(self.inner).run(stop_token).await
+    }
+
+    fn start(self: Box<Self>) -> PipelineHandle {
+        Box::new(self.inner).start()
+    }
+}
+
+impl TypedAudioNode for PluginDspNode {
+    fn input_type(&self) -> Option<TypeRequirement> {
+        None // Accepte tout
+    }
+
+    fn output_type(&self) -> Option<TypeRequirement> {
+        None // F32, ou type d'entrée si le plugin est contourné
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[tokio::test]
+    async fn test_failed_plugin_is_bypassed() {
+        let command =
+            PluginCommand::from_options(&json!({ "command": "/nonexistent/pmomusic-dsp" }))
+                .unwrap();
+        let mut logic = PluginDspLogic {
+            supervisor: PluginSupervisor::new("test", command, Capability::DspStage),
+            configured: None,
+            bypassed: false,
+        };
+        let chunk = Arc::new(AudioChunk::F32(AudioChunkData::new(
+            vec![[0.25f32, -0.25f32]; 64],
+            48_000,
+            0.0,
+        )));
+
+        let out = logic.process_chunk(&chunk).await;
+        assert!(Arc::ptr_eq(&out, &chunk));
+        assert!(logic.bypassed);
+    }
+}
@@ -0,0 +1,50 @@
+//! Types d'erreurs pour pmoplugin
+
+use thiserror::Error;
+
+/// Erreurs d'un plugin externe
+#[derive(Debug, Error)]
+pub enum PluginError {
+    #[error("Invalid plugin configuration: {0}")]
+    Config(String),
+
+    #[error("Failed to start plugin {0}: {1}")]
+    Spawn(String, std::io::Error),
+
+    #[error("Plugin {0} exited")]
+    Exited(String),
+
+    #[error("Handshake with plugin {0} failed: {1}")]
+    Handshake(String, String),
+
+    #[error("Plugin {0} is restarting, next attempt in {1:?}")]
+    Backoff(String, std::time::Duration),
+
+    #[error("Invalid reply from plugin {0} to {1}: {2}")]
+    InvalidReply(String, String, String),
+
+    #[error("Plugin {0} did not answer {1} in time")]
+    Timeout(String, String),
+
+    #[error("Plugin error {code}: {message}")]
+    Rpc { code: i64, message: String },
+
+    #[error("Invalid message from plugin: {0}")]
+    Protocol(#[from] serde_json::Error),
+
+    #[error("I/O error: {0}")]
+    Io(#[from] std::io::Error),
+}
+
+pub type Result<T> = std::result::Result<T, PluginError>;
+
+impl From<PluginError> for pmosource::MusicSourceError {
+    fn from(e: PluginError) -> Self {
+        match e {
+            PluginError::Rpc { code, message } if code == crate::protocol::NOT_FOUND => {
+                pmosource::MusicSourceError::ObjectNotFound(message)
+            }
+            other => pmosource::MusicSourceError::SourceUnavailable(other.to_string()),
+        }
+    }
+}
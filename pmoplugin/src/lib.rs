@@ -0,0 +1,118 @@
+//! # PMOPlugin
+//!
+//! Plugins externes pour PMOMusic : un tiers étend le serveur sans le
+//! forker en livrant un exécutable (dans le langage de son choix) que
+//! PMOMusic lance et pilote par un contrat RPC versionné.
+//!
+//! ## Contrat
+//!
+//! Le plugin est un processus fils qui échange des messages JSON-RPC 2.0,
+//! un par ligne, sur son entrée et sa sortie standard ; sa sortie d'erreur
+//! est reprise dans les journaux du serveur.
+//!
+//! 1. Le processus est lancé avec les variables d'environnement
+//!    `PMOMUSIC_PLUGIN_MAGIC_COOKIE` (valeur [`MAGIC_COOKIE_VALUE`]) et
+//!    `PMOMUSIC_PLUGIN_PROTOCOL_VERSIONS` (versions supportées par l'hôte,
+//!    séparées par des virgules). Un plugin lancé sans le cookie doit
+//!    refuser de démarrer : ce n'est pas un programme interactif.
+//! 2. L'hôte envoie `handshake` avec les versions qu'il supporte ; le
+//!    plugin répond par la version retenue, son nom et ses capacités
+//!    (voir [`protocol::Handshake`]). Une version inconnue ou une capacité
+//!    manquante arrête le plugin.
+//! 3. L'hôte appelle ensuite les méthodes de la capacité utilisée.
+//!
+//! Version 1 du protocole, capacité `content_provider` (dossier virtuel du
+//! ContentDirectory, voir `pmosource::provider`) :
+//!
+//! | Méthode             | Paramètres                              | Résultat                    |
+//! |---------------------|-----------------------------------------|-----------------------------|
+//! | `provider.browse`   | `{path}`                                | `{containers, items}`       |
+//! | `provider.resolve`  | `{path}`                                | `{uri}`                     |
+//! | `provider.get_item` | `{path}`                                | objet                       |
+//! | `provider.search`   | `{text, media_type, limit, offset}`     | `{containers, items}`       |
+//! | `provider.update_id`| `{}`                                    | `{update_id}`               |
+//!
+//! `provider.search` n'est appelée que si le plugin annonce aussi la
+//! capacité `search`. Les objets sont décrits par [`protocol::PluginObject`]
+//! et les chemins sont relatifs au montage (`""` pour sa racine). Une
+//! erreur JSON-RPC de code [`protocol::NOT_FOUND`] signale un objet
+//! inconnu.
+//!
+//! Capacité `upnp_service` (service UPnP hébergé par un device, voir
+//! [`service`]) :
+//!
+//! | Méthode             | Paramètres                              | Résultat                    |
+//! |---------------------|-----------------------------------------|-----------------------------|
+//! | `service.describe`  | `{}`                                    | description du service      |
+//! | `service.invoke`    | `{action, args}`                        | `{args}`                    |
+//!
+//! La description (nom, variables d'état, actions et leurs arguments) suit
+//! [`protocol::ServiceDescription`]. Les arguments sont des chaînes au
+//! format SOAP, indexées par nom. Une erreur de code UPnP (400 à 999) est
+//! renvoyée telle quelle au client.
+//!
+//! Capacité `dsp_stage` (étage du pipeline audio, voir [`dsp`]) :
+//!
+//! | Méthode             | Paramètres                              | Résultat                    |
+//! |---------------------|-----------------------------------------|-----------------------------|
+//! | `dsp.configure`     | `{sample_rate, channels}`               | ignoré                      |
+//! | `dsp.process`       | `{frames}`                              | `{frames}`                  |
+//!
+//! Les trames sont des paires `[gauche, droite]` de flottants ; le plugin
+//! en renvoie autant qu'il en reçoit.
+//!
+//! Une capacité inconnue de l'hôte est ignorée : un plugin peut annoncer
+//! les capacités d'une version plus récente sans être refusé.
+//!
+//! ## Configuration
+//!
+//! Un plugin de contenu se monte comme tout dossier virtuel :
+//!
+//! ```yaml
+//! sources:
+//!   virtual_folders:
+//!     - id: bandcamp
+//!       title: "Bandcamp"
+//!       provider: plugin
+//!       options:
+//!         command: /usr/lib/pmomusic/plugins/bandcamp
+//!         args: ["--cache", "/var/cache/bandcamp"]
+//!         env: { BANDCAMP_USER: "moi" }
+//!         timeout: 30s            # délai de réponse d'un appel
+//! ```
+//!
+//! Le processus est lancé au premier accès au montage et relancé s'il
+//! s'est arrêté, avec un délai croissant s'il échoue en boucle (voir
+//! [`supervisor`]).
+//!
+//! Les services UPnP ([`plugin_service`]) et étages DSP
+//! ([`PluginDspNode`]) se construisent avec une [`PluginCommand`] lue par
+//! [`PluginCommand::from_options`] dans les mêmes options.
+
+pub mod dsp;
+pub mod error;
+pub mod process;
+pub mod protocol;
+pub mod provider;
+pub mod service;
+pub mod supervisor;
+
+pub use dsp::PluginDspNode;
+pub use error::{PluginError, Result};
+pub use process::{PluginCommand, PluginProcess};
+pub use protocol::{Capability, Handshake, PluginObject};
+pub use provider::PluginProvider;
+pub use service::plugin_service;
+pub use supervisor::PluginSupervisor;
+
+/// Versions du protocole supportées par l'hôte
+pub const PROTOCOL_VERSIONS: &[u32] = &[1];
+
+/// Variable d'environnement du cookie de lancement
+pub const MAGIC_COOKIE_KEY: &str = "PMOMUSIC_PLUGIN_MAGIC_COOKIE";
+
+/// Valeur du cookie de lancement
+pub const MAGIC_COOKIE_VALUE: &str = "b3f1c7e2-pmomusic-plugin";
+
+/// Variable d'environnement des versions du protocole supportées
+pub const PROTOCOL_VERSIONS_KEY: &str = "PMOMUSIC_PLUGIN_PROTOCOL_VERSIONS";
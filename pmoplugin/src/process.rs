@@ -0,0 +1,243 @@
+//! Processus d'un plugin et canal JSON-RPC
+
+use std::collections::HashMap;
+use std::process::Stdio;
+use std::sync::atomic::{AtomicBool, AtomicU64, Ordering};
+use std::sync::{Arc, Mutex};
+use std::time::Duration;
+
+use serde::Deserialize;
+use serde::de::DeserializeOwned;
+use serde_json::{Value, json};
+use tokio::io::{AsyncBufReadExt, AsyncWriteExt, BufReader};
+use tokio::process::{Child, ChildStdin, ChildStdout, Command};
+use tokio::sync::oneshot;
+use tracing::{debug, info, warn};
+
+use crate::error::{PluginError, Result};
+use crate::protocol::{Capability, Handshake, HandshakeParams, Request, Response};
+use crate::{MAGIC_COOKIE_KEY, MAGIC_COOKIE_VALUE, PROTOCOL_VERSIONS, PROTOCOL_VERSIONS_KEY};
+
+/// Délai de réponse par défaut d'un appel
+const DEFAULT_TIMEOUT: Duration = Duration::from_secs(30);
+
+/// Commande de lancement d'un plugin (options du montage)
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct PluginCommand {
+    pub command: String,
+    pub args: Vec<String>,
+    pub env: HashMap<String, String>,
+    /// Délai de réponse d'un appel
+    pub timeout: Duration,
+}
+
+#[derive(Deserialize)]
+struct RawCommand {
+    command: String,
+    #[serde(default)]
+    args: Vec<String>,
+    #[serde(default)]
+    env: HashMap<String, String>,
+    #[serde(default)]
+    timeout: Option<String>,
+}
+
+impl PluginCommand {
+    /// Lit la commande dans les options d'un montage
+    pub fn from_options(options: &Value) -> Result<Self> {
+        let raw: RawCommand = serde_json::from_value(options.clone())
+            .map_err(|e| PluginError::Config(e.to_string()))?;
+        if raw.command.trim().is_empty() {
+            return Err(PluginError::Config("command must not be empty".into()));
+        }
+        let timeout = match raw.timeout.as_deref() {
+            None => DEFAULT_TIMEOUT,
+            Some(value) => pmoconfig::parse_duration(value)
+                .map_err(|e| PluginError::Config(format!("invalid timeout: {}", e)))?,
+        };
+        Ok(Self {
+            command: raw.command,
+            args: raw.args,
+            env: raw.env,
+            timeout,
+        })
+    }
+}
+
+/// Partie du canal partagée avec la tâche de lecture
+#[derive(Debug, Default)]
+struct Pending {
+    waiters: Mutex<HashMap<u64, oneshot::Sender<Response>>>,
+    alive: AtomicBool,
+}
+
+/// Plugin lancé, après une poignée de main réussie
+#[derive(Debug)]
+pub struct PluginProcess {
+    name: String,
+    handshake: Handshake,
+    stdin: tokio::sync::Mutex<ChildStdin>,
+    pending: Arc<Pending>,
+    next_id: AtomicU64,
+    timeout: Duration,
+    _child: Child,
+}
+
+impl PluginProcess {
+    /// Lance le plugin et vérifie qu'il fournit la capacité demandée
+    pub async fn start(command: &PluginCommand, required: Capability) -> Result<Self> {
+        let versions: Vec<String> = PROTOCOL_VERSIONS.iter().map(u32::to_string).collect();
+        let mut child = Command::new(&command.command)
+            .args(&command.args)
+            .envs(&command.env)
+            .env(MAGIC_COOKIE_KEY, MAGIC_COOKIE_VALUE)
+            .env(PROTOCOL_VERSIONS_KEY, versions.join(","))
+            .stdin(Stdio::piped())
+            .stdout(Stdio::piped())
+            .stderr(Stdio::piped())
+            .kill_on_drop(true)
+            .spawn()
+            .map_err(|e| PluginError::Spawn(command.command.clone(), e))?;
+
+        let stdin = child.stdin.take().expect("plugin stdin is piped");
+        let stdout = child.stdout.take().expect("plugin stdout is piped");
+        let stderr = child.stderr.take().expect("plugin stderr is piped");
+
+        let pending = Arc::new(Pending::default());
+        pending.alive.store(true, Ordering::SeqCst);
+        tokio::spawn(read_responses(
+            command.command.clone(),
+            stdout,
+            Arc::clone(&pending),
+        ));
+        let label = command.command.clone();
+        tokio::spawn(async move {
+            let mut lines = BufReader::new(stderr).lines();
+            while let Ok(Some(line)) = lines.next_line().await {
+                info!("🔌 [{}] {}", label, line);
+            }
+        });
+
+        let mut process = Self {
+            name: command.command.clone(),
+            handshake: Handshake {
+                protocol_version: 0,
+                name: command.command.clone(),
+                version: None,
+                capabilities: vec![],
+            },
+            stdin: tokio::sync::Mutex::new(stdin),
+            pending,
+            next_id: AtomicU64::new(1),
+            timeout: command.timeout,
+            _child: child,
+        };
+
+        let params = HandshakeParams {
+            protocol_versions: PROTOCOL_VERSIONS.to_vec(),
+            host: "pmomusic",
+            host_version: env!("CARGO_PKG_VERSION"),
+        };
+        let handshake: Handshake = process
+            .call("handshake", json!(params))
+            .await
+            .map_err(|e| PluginError::Handshake(process.name.clone(), e.to_string()))?;
+        if !PROTOCOL_VERSIONS.contains(&handshake.protocol_version) {
+            return Err(PluginError::Handshake(
+                process.name,
+                format!(
+                    "unsupported protocol version {} (host supports {:?})",
+                    handshake.protocol_version, PROTOCOL_VERSIONS
+                ),
+            ));
+        }
+        if !handshake.supports(required) {
+            return Err(PluginError::Handshake(
+                process.name,
+                format!("plugin does not provide {:?}", required),
+            ));
+        }
+        process.handshake = handshake;
+        Ok(process)
+    }
+
+    /// Réponse du plugin à la poignée de main
+    pub fn handshake(&self) -> &Handshake {
+        &self.handshake
+    }
+
+    /// Le processus répond-il encore ?
+    pub fn is_alive(&self) -> bool {
+        self.pending.alive.load(Ordering::SeqCst)
+    }
+
+    /// Appelle une méthode du plugin
+    pub async fn call<T: DeserializeOwned>(&self, method: &str, params: Value) -> Result<T> {
+        if !self.is_alive() {
+            return Err(PluginError::Exited(self.name.clone()));
+        }
+        let id = self.next_id.fetch_add(1, Ordering::SeqCst);
+        let (tx, rx) = oneshot::channel();
+        self.pending.waiters.lock().unwrap().insert(id, tx);
+
+        let mut line = serde_json::to_vec(&Request::new(id, method, params))?;
+        line.push(b'\n');
+        let sent = {
+            let mut stdin = self.stdin.lock().await;
+            match stdin.write_all(&line).await {
+                Ok(()) => stdin.flush().await,
+                Err(e) => Err(e),
+            }
+        };
+        if let Err(e) = sent {
+            self.pending.waiters.lock().unwrap().remove(&id);
+            return Err(e.into());
+        }
+
+        let response = match tokio::time::timeout(self.timeout, rx).await {
+            Ok(Ok(response)) => response,
+            Ok(Err(_)) => return Err(PluginError::Exited(self.name.clone())),
+            Err(_) => {
+                self.pending.waiters.lock().unwrap().remove(&id);
+                return Err(PluginError::Timeout(self.name.clone(), method.to_string()));
+            }
+        };
+        if let Some(error) = response.error {
+            return Err(PluginError::Rpc {
+                code: error.code,
+                message: error.message,
+            });
+        }
+        Ok(serde_json::from_value(
+            response.result.unwrap_or(Value::Null),
+        )?)
+    }
+}
+
+/// Transmet les réponses du plugin aux appels en attente
+///
+/// À la fin du flux, le plugin est marqué arrêté et les appels en attente
+/// échouent.
+async fn read_responses(name: String, stdout: ChildStdout, pending: Arc<Pending>) {
+    let mut lines = BufReader::new(stdout).lines();
+    while let Ok(Some(line)) = lines.next_line().await {
+        if line.trim().is_empty() {
+            continue;
+        }
+        match serde_json::from_str::<Response>(&line) {
+            Ok(response) => {
+                let waiter = pending.waiters.lock().unwrap().remove(&response.id);
+                match waiter {
+                    Some(tx) => {
+                        let _ = tx.send(response);
+                    }
+                    None => debug!("Plugin {} answered unknown call {}", name, response.id),
+                }
+            }
+            Err(e) => warn!("Ignoring invalid message from plugin {}: {}", name, e),
+        }
+    }
+    pending.alive.store(false, Ordering::SeqCst);
+    pending.waiters.lock().unwrap().clear();
+    warn!("🔌 Plugin {} exited", name);
+}
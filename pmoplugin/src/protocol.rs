@@ -0,0 +1,348 @@
+//! Messages du contrat RPC entre PMOMusic et ses plugins
+//!
+//! JSON-RPC 2.0, un message par ligne. Les types de ce module sont le
+//! contrat public : un champ ne peut être ajouté que facultatif, toute
+//! autre modification demande une nouvelle version du protocole.
+
+use pmodidl::{Container, Item, Resource};
+use pmosource::BrowseResult;
+use serde::{Deserialize, Serialize};
+use serde_json::Value;
+use std::collections::HashMap;
+
+/// Code d'erreur JSON-RPC d'un objet inconnu
+pub const NOT_FOUND: i64 = -32004;
+
+/// Classe UPnP par défaut des containers
+const DEFAULT_CONTAINER_CLASS: &str = "object.container";
+
+/// Classe UPnP par défaut des items
+const DEFAULT_ITEM_CLASS: &str = "object.item.audioItem.musicTrack";
+
+/// protocolInfo par défaut d'une ressource
+const DEFAULT_PROTOCOL_INFO: &str = "http-get:*:*:*";
+
+/// Requête de l'hôte vers le plugin
+#[derive(Debug, Clone, Serialize)]
+pub struct Request<'a> {
+    pub jsonrpc: &'static str,
+    pub id: u64,
+    pub method: &'a str,
+    pub params: Value,
+}
+
+impl<'a> Request<'a> {
+    pub fn new(id: u64, method: &'a str, params: Value) -> Self {
+        Self {
+            jsonrpc: "2.0",
+            id,
+            method,
+            params,
+        }
+    }
+}
+
+/// Réponse du plugin
+#[derive(Debug, Clone, Deserialize)]
+pub struct Response {
+    pub id: u64,
+    #[serde(default)]
+    pub result: Option<Value>,
+    #[serde(default)]
+    pub error: Option<RpcError>,
+}
+
+/// Erreur JSON-RPC
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct RpcError {
+    pub code: i64,
+    pub message: String,
+}
+
+/// Capacité annoncée par un plugin
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum Capability {
+    /// Dossier virtuel du ContentDirectory
+    ContentProvider,
+    /// `provider.search`
+    Search,
+    /// Service UPnP hébergé par un device de PMOMusic
+    UpnpService,
+    /// Étage de traitement du pipeline audio
+    DspStage,
+    /// Capacité d'une version plus récente, ignorée
+    #[serde(other)]
+    Unknown,
+}
+
+/// Paramètres de `handshake`
+#[derive(Debug, Clone, Serialize)]
+pub struct HandshakeParams {
+    pub protocol_versions: Vec<u32>,
+    pub host: &'static str,
+    pub host_version: &'static str,
+}
+
+/// Réponse à `handshake`
+#[derive(Debug, Clone, PartialEq, Eq, Deserialize)]
+pub struct Handshake {
+    /// Version du protocole retenue par le plugin
+    pub protocol_version: u32,
+    /// Nom du plugin, pour les journaux
+    pub name: String,
+    #[serde(default)]
+    pub version: Option<String>,
+    #[serde(default)]
+    pub capabilities: Vec<Capability>,
+}
+
+impl Handshake {
+    pub fn supports(&self, capability: Capability) -> bool {
+        self.capabilities.contains(&capability)
+    }
+}
+
+/// Container ou item décrit par un plugin
+///
+/// Un objet avec `uri` est un item, les autres sont des containers.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+pub struct PluginObject {
+    /// Chemin relatif au montage
+    pub id: String,
+    /// Chemin du parent (`""` : racine du montage)
+    #[serde(default)]
+    pub parent_id: String,
+    pub title: String,
+    /// Classe UPnP (`object.container.album.musicAlbum`…)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub class: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub artist: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub album: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub creator: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub genre: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub date: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub track_number: Option<u32>,
+    /// URL de la pochette
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub album_art: Option<String>,
+    /// Nombre d'enfants d'un container
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub child_count: Option<u32>,
+    /// URI de lecture d'un item
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub uri: Option<String>,
+    /// protocolInfo UPnP de la ressource (ex: `http-get:*:audio/flac:*`)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub protocol_info: Option<String>,
+    /// Durée `H:MM:SS`
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub duration: Option<String>,
+}
+
+impl PluginObject {
+    pub fn is_item(&self) -> bool {
+        self.uri.is_some()
+    }
+
+    pub fn into_container(self) -> Container {
+        Container {
+            id: self.id,
+            parent_id: self.parent_id,
+            restricted: Some("1".to_string()),
+            child_count: self.child_count.map(|n| n.to_string()),
+            searchable: None,
+            title: self.title,
+            class: self
+                .class
+                .unwrap_or_else(|| DEFAULT_CONTAINER_CLASS.to_string()),
+            artist: self.artist,
+            album_art: self.album_art,
+            containers: vec![],
+            items: vec![],
+        }
+    }
+
+    pub fn into_item(self) -> Item {
+        let resources = self
+            .uri
+            .map(|url| Resource {
+                protocol_info: self
+                    .protocol_info
+                    .unwrap_or_else(|| DEFAULT_PROTOCOL_INFO.to_string()),
+                bits_per_sample: None,
+                sample_frequency: None,
+                nr_audio_channels: None,
+                duration: self.duration,
+                url,
+            })
+            .into_iter()
+            .collect();
+        Item {
+            id: self.id,
+            parent_id: self.parent_id,
+            restricted: Some("1".to_string()),
+            title: self.title,
+            creator: self.creator,
+            class: self.class.unwrap_or_else(|| DEFAULT_ITEM_CLASS.to_string()),
+            artist: self.artist,
+            album: self.album,
+            genre: self.genre,
+            album_art: self.album_art,
+            album_art_pk: None,
+            date: self.date,
+            original_track_number: self.track_number.map(|n| n.to_string()),
+            resources,
+            descriptions: vec![],
+        }
+    }
+}
+
+/// Résultat de `provider.browse` et `provider.search`
+#[derive(Debug, Clone, Default, Deserialize)]
+pub struct BrowseReply {
+    #[serde(default)]
+    pub containers: Vec<PluginObject>,
+    #[serde(default)]
+    pub items: Vec<PluginObject>,
+}
+
+impl From<BrowseReply> for BrowseResult {
+    fn from(reply: BrowseReply) -> Self {
+        BrowseResult::Mixed {
+            containers: reply
+                .containers
+                .into_iter()
+                .map(PluginObject::into_container)
+                .collect(),
+            items: reply
+                .items
+                .into_iter()
+                .map(PluginObject::into_item)
+                .collect(),
+        }
+    }
+}
+
+/// Résultat de `provider.resolve`
+#[derive(Debug, Clone, Deserialize)]
+pub struct ResolveReply {
+    pub uri: String,
+}
+
+/// Résultat de `provider.update_id`
+#[derive(Debug, Clone, Deserialize)]
+pub struct UpdateIdReply {
+    pub update_id: u32,
+}
+
+/// Résultat de `service.describe`
+#[derive(Debug, Clone, Deserialize)]
+pub struct ServiceDescription {
+    /// Nom du service (`urn:<domain>:service:<name>:<version>`)
+    pub name: String,
+    #[serde(default)]
+    pub version: Option<u32>,
+    /// Domaine du type de service (domaine UPnP par défaut)
+    #[serde(default)]
+    pub domain: Option<String>,
+    /// Identifiant du service (`urn:<domain>:serviceId:<identifier>`)
+    #[serde(default)]
+    pub identifier: Option<String>,
+    #[serde(default)]
+    pub variables: Vec<ServiceVariable>,
+    #[serde(default)]
+    pub actions: Vec<ServiceAction>,
+}
+
+/// Variable d'état d'un service
+#[derive(Debug, Clone, Deserialize)]
+pub struct ServiceVariable {
+    pub name: String,
+    /// Type UPnP (`string`, `ui4`, `boolean`…)
+    #[serde(rename = "type")]
+    pub var_type: String,
+    /// Valeur par défaut, au format SOAP
+    #[serde(default)]
+    pub default: Option<String>,
+}
+
+/// Action d'un service
+#[derive(Debug, Clone, Deserialize)]
+pub struct ServiceAction {
+    pub name: String,
+    #[serde(default)]
+    pub arguments: Vec<ServiceArgument>,
+}
+
+/// Argument d'une action, lié à une variable d'état
+#[derive(Debug, Clone, Deserialize)]
+pub struct ServiceArgument {
+    pub name: String,
+    pub direction: ArgumentDirection,
+    /// Variable d'état liée (`relatedStateVariable`)
+    pub variable: String,
+}
+
+/// Sens d'un argument
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum ArgumentDirection {
+    In,
+    Out,
+}
+
+/// Résultat de `service.invoke` : arguments de sortie, au format SOAP
+#[derive(Debug, Clone, Default, Deserialize)]
+pub struct InvokeReply {
+    #[serde(default)]
+    pub args: HashMap<String, String>,
+}
+
+/// Paramètres de `dsp.configure`
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+pub struct DspFormat {
+    pub sample_rate: u32,
+    pub channels: u32,
+}
+
+/// Paramètres et résultat de `dsp.process` : trames stéréo `[gauche, droite]`
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct DspFrames {
+    pub frames: Vec<[f32; 2]>,
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_handshake_and_objects() {
+        let handshake: Handshake = serde_json::from_str(
+            r#"{"protocol_version":1,"name":"bandcamp","capabilities":["content_provider","dsp_stage"]}"#,
+        )
+        .unwrap();
+        assert!(handshake.supports(Capability::ContentProvider));
+        assert!(!handshake.supports(Capability::Search));
+        assert!(handshake.supports(Capability::DspStage));
+
+        let reply: BrowseReply = serde_json::from_str(
+            r#"{"containers":[{"id":"album:1","title":"Album"}],
+                "items":[{"id":"track:1","parent_id":"album:1","title":"Piste",
+                          "uri":"http://example.org/1.flac","track_number":1}]}"#,
+        )
+        .unwrap();
+        let result = BrowseResult::from(reply);
+        assert_eq!(result.containers()[0].class, DEFAULT_CONTAINER_CLASS);
+        let item = &result.items()[0];
+        assert_eq!(item.original_track_number.as_deref(), Some("1"));
+        assert_eq!(item.resources[0].url, "http://example.org/1.flac");
+        assert_eq!(item.resources[0].protocol_info, DEFAULT_PROTOCOL_INFO);
+    }
+}
@@ -0,0 +1,128 @@
+//! Plugin de contenu monté comme dossier virtuel (provider `plugin`)
+
+use std::sync::Arc;
+use std::sync::atomic::{AtomicBool, Ordering};
+
+use pmodidl::Item;
+use pmosource::provider::{ContentProvider, MountConfig, register_provider_factory};
+use pmosource::{BrowseResult, MediaSearchType, MusicSourceError, Result, SearchQuery};
+use serde_json::json;
+
+use crate::process::{PluginCommand, PluginProcess};
+use crate::protocol::{BrowseReply, Capability, PluginObject, ResolveReply, UpdateIdReply};
+use crate::supervisor::PluginSupervisor;
+
+/// Nom de la fabrique enregistrée pour `sources.virtual_folders`
+pub const PROVIDER_NAME: &str = "plugin";
+
+/// Provider de contenu délégué à un plugin externe
+///
+/// Le processus est lancé au premier appel et relancé s'il s'est arrêté
+/// (voir [`crate::supervisor`]).
+#[derive(Debug)]
+pub struct PluginProvider {
+    supervisor: PluginSupervisor,
+    search: AtomicBool,
+}
+
+impl PluginProvider {
+    pub fn new(mount: &str, command: PluginCommand) -> Self {
+        Self {
+            supervisor: PluginSupervisor::new(mount, command, Capability::ContentProvider),
+            search: AtomicBool::new(false),
+        }
+    }
+
+    /// Enregistre la fabrique `plugin` pour les dossiers virtuels
+    pub fn register_factory() {
+        register_provider_factory(PROVIDER_NAME, |mount: &MountConfig| {
+            let command = PluginCommand::from_options(&mount.options).map_err(|e| {
+                MusicSourceError::NotSupported(format!("virtual folder '{}': {}", mount.id, e))
+            })?;
+            Ok(Arc::new(PluginProvider::new(&mount.id, command)) as Arc<dyn ContentProvider>)
+        });
+    }
+
+    async fn process(&self) -> Result<Arc<PluginProcess>> {
+        let process = self.supervisor.process().await?;
+        self.search.store(
+            process.handshake().supports(Capability::Search),
+            Ordering::SeqCst,
+        );
+        Ok(process)
+    }
+}
+
+fn media_type(media_type: &MediaSearchType) -> &'static str {
+    match media_type {
+        MediaSearchType::All => "all",
+        MediaSearchType::Tracks => "tracks",
+        MediaSearchType::Albums => "albums",
+        MediaSearchType::Artists => "artists",
+        MediaSearchType::Playlists => "playlists",
+    }
+}
+
+#[async_trait::async_trait]
+impl ContentProvider for PluginProvider {
+    async fn browse(&self, path: &str) -> Result<BrowseResult> {
+        let reply: BrowseReply = self
+            .process()
+            .await?
+            .call("provider.browse", json!({ "path": path }))
+            .await?;
+        Ok(reply.into())
+    }
+
+    async fn resolve(&self, path: &str) -> Result<String> {
+        let reply: ResolveReply = self
+            .process()
+            .await?
+            .call("provider.resolve", json!({ "path": path }))
+            .await?;
+        Ok(reply.uri)
+    }
+
+    async fn search(&self, query: &SearchQuery) -> Result<BrowseResult> {
+        let process = self.process().await?;
+        if !process.handshake().supports(Capability::Search) {
+            return Err(MusicSourceError::SearchNotSupported);
+        }
+        let reply: BrowseReply = process
+            .call(
+                "provider.search",
+                json!({
+                    "text": query.text,
+                    "media_type": media_type(&query.media_type),
+                    "limit": query.limit,
+                    "offset": query.offset,
+                }),
+            )
+            .await?;
+        Ok(reply.into())
+    }
+
+    async fn get_item(&self, path: &str) -> Result<Item> {
+        let object: PluginObject = self
+            .process()
+            .await?
+            .call("provider.get_item", json!({ "path": path }))
+            .await?;
+        Ok(object.into_item())
+    }
+
+    fn supports_search(&self) -> bool {
+        self.search.load(Ordering::SeqCst)
+    }
+
+    async fn update_id(&self) -> u32 {
+        let Ok(process) = self.process().await else {
+            return 0;
+        };
+        process
+            .call::<UpdateIdReply>("provider.update_id", json!({}))
+            .await
+            .map(|reply| reply.update_id)
+            .unwrap_or(0)
+    }
+}
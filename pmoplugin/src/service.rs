@@ -0,0 +1,246 @@
+//! Service UPnP fourni par un plugin (capacité `upnp_service`)
+//!
+//! Le plugin décrit son service (`service.describe`) au lancement ; l'hôte
+//! en construit un [`Service`] pmoupnp dont chaque action transmet ses
+//! arguments d'entrée au plugin (`service.invoke`) et renvoie ses arguments
+//! de sortie dans la réponse SOAP. Les valeurs sont échangées au format SOAP
+//! (chaînes). Le service s'ajoute à un device comme n'importe quel autre :
+//!
+//! ```ignore
+//! let service = pmoplugin::plugin_service("Lyrics", command).await?;
+//! let device = DeviceBuilder::new("MediaServer", "MediaServer")
+//!     .service(Arc::new(service))
+//!     .build()?;
+//! ```
+//!
+//! Les actions sont sans état : les variables d'état ne servent qu'à typer
+//! les arguments dans le SCPD. Une erreur JSON-RPC dont le code est un code
+//! d'erreur UPnP (400 à 999) est renvoyée telle quelle dans le SOAP fault.
+
+use std::collections::HashMap;
+use std::sync::Arc;
+
+use pmoupnp::actions::{
+    Action, ActionData, ActionError, ActionHandler, Argument, reflect_to_string, set_value,
+};
+use pmoupnp::services::{Service, ServiceBuilder};
+use pmoupnp::state_variables::StateVariable;
+use pmoupnp::variable_types::{StateValue, StateVarType};
+use serde_json::json;
+
+use crate::error::{PluginError, Result};
+use crate::process::PluginCommand;
+use crate::protocol::{ArgumentDirection, Capability, InvokeReply, ServiceDescription};
+use crate::supervisor::PluginSupervisor;
+
+/// Lance le plugin et construit le service qu'il décrit
+///
+/// `label` nomme l'usage du plugin dans les journaux.
+pub async fn plugin_service(label: &str, command: PluginCommand) -> Result<Service> {
+    let supervisor = Arc::new(PluginSupervisor::new(
+        label,
+        command,
+        Capability::UpnpService,
+    ));
+    let description: ServiceDescription = supervisor
+        .process()
+        .await?
+        .call("service.describe", json!({}))
+        .await?;
+    build_service(description, supervisor)
+}
+
+fn build_service(
+    description: ServiceDescription,
+    supervisor: Arc<PluginSupervisor>,
+) -> Result<Service> {
+    let plugin = supervisor.command().command.clone();
+    let invalid = |message: String| {
+        PluginError::InvalidReply(plugin.clone(), "service.describe".to_string(), message)
+    };
+
+    let mut builder = ServiceBuilder::new(description.name);
+    if let Some(version) = description.version {
+        builder = builder.version(version);
+    }
+    if let Some(domain) = description.domain {
+        builder = builder.domain(domain);
+    }
+    if let Some(identifier) = description.identifier {
+        builder = builder.identifier(identifier);
+    }
+
+    let mut variables = HashMap::new();
+    for declared in description.variables {
+        let var_type: StateVarType = declared
+            .var_type
+            .parse()
+            .map_err(|e| invalid(format!("variable {}: {}", declared.name, e)))?;
+        let mut variable = StateVariable::new(var_type, declared.name.clone());
+        if let Some(default) = declared.default.as_deref() {
+            StateValue::from_string(default, &var_type)
+                .and_then(|value| variable.set_default(&value))
+                .map_err(|e| invalid(format!("variable {}: {}", declared.name, e)))?;
+        }
+        let variable = Arc::new(variable);
+        builder = builder.variable(Arc::clone(&variable));
+        variables.insert(declared.name, variable);
+    }
+
+    for declared in description.actions {
+        let mut action = Action::new(declared.name.clone());
+        let mut inputs = Vec::new();
+        let mut outputs = Vec::new();
+        for argument in declared.arguments {
+            let variable = variables.get(&argument.variable).cloned().ok_or_else(|| {
+                invalid(format!(
+                    "argument {} of {} refers to unknown variable {}",
+                    argument.name, declared.name, argument.variable
+                ))
+            })?;
+            let argument = match argument.direction {
+                ArgumentDirection::In => {
+                    inputs.push(argument.name.clone());
+                    Argument::new_in(argument.name, variable)
+                }
+                ArgumentDirection::Out => {
+                    outputs.push(argument.name.clone());
+                    Argument::new_out(argument.name, variable)
+                }
+            };
+            action
+                .add_argument(Arc::new(argument))
+                .map_err(|e| invalid(format!("action {}: {:?}", declared.name, e)))?;
+        }
+        action.set_stateful(false);
+        action.set_handler(forward(
+            Arc::clone(&supervisor),
+            declared.name,
+            inputs,
+            outputs,
+        ));
+        builder = builder.action(Arc::new(action));
+    }
+
+    builder.build().map_err(|e| invalid(e.to_string()))
+}
+
+/// Handler transmettant une action au plugin
+fn forward(
+    supervisor: Arc<PluginSupervisor>,
+    action: String,
+    inputs: Vec<String>,
+    outputs: Vec<String>,
+) -> ActionHandler {
+    let outputs = Arc::new(outputs);
+    Arc::new(move |mut data: ActionData| {
+        let supervisor = Arc::clone(&supervisor);
+        let outputs = Arc::clone(&outputs);
+        let action = action.clone();
+        let args: HashMap<&str, String> = inputs
+            .iter()
+            .filter_map(|name| {
+                data.get(name)
+                    .map(|value| (name.as_str(), reflect_to_string(&**value)))
+            })
+            .collect();
+        let params = json!({ "action": action, "args": args });
+        Box::pin(async move {
+            let reply: InvokeReply = supervisor
+                .process()
+                .await
+                .map_err(action_error)?
+                .call("service.invoke", params)
+                .await
+                .map_err(action_error)?;
+            for (name, value) in reply.args {
+                if outputs.contains(&name) {
+                    set_value(&mut data, name, value);
+                }
+            }
+            Ok(data)
+        })
+    })
+}
+
+fn action_error(e: PluginError) -> ActionError {
+    match e {
+        PluginError::Rpc { code, message } if (400..1000).contains(&code) => {
+            ActionError::UpnpError {
+                code: code.to_string(),
+                description: message,
+            }
+        }
+        other => ActionError::GeneralError(other.to_string()),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use pmoupnp::UpnpTyped;
+
+    fn supervisor() -> Arc<PluginSupervisor> {
+        let command = PluginCommand::from_options(&json!({ "command": "lyrics" })).unwrap();
+        Arc::new(PluginSupervisor::new(
+            "test",
+            command,
+            Capability::UpnpService,
+        ))
+    }
+
+    #[test]
+    fn test_service_from_description() {
+        let description: ServiceDescription = serde_json::from_value(json!({
+            "name": "Lyrics",
+            "domain": "pmomusic-org",
+            "variables": [
+                { "name": "A_ARG_TYPE_Uri", "type": "string" },
+                { "name": "A_ARG_TYPE_Lines", "type": "ui4", "default": "0" }
+            ],
+            "actions": [{
+                "name": "GetLyrics",
+                "arguments": [
+                    { "name": "Uri", "direction": "in", "variable": "A_ARG_TYPE_Uri" },
+                    { "name": "Lines", "direction": "out", "variable": "A_ARG_TYPE_Lines" }
+                ]
+            }]
+        }))
+        .unwrap();
+        let service = build_service(description, supervisor()).unwrap();
+        assert_eq!(service.service_type(), "urn:pmomusic-org:service:Lyrics:1");
+        assert_eq!(service.variables().len(), 2);
+        let actions = service.actions();
+        assert_eq!(actions[0].get_name(), "GetLyrics");
+    }
+
+    #[test]
+    fn test_unknown_variable_is_rejected() {
+        let description: ServiceDescription = serde_json::from_value(json!({
+            "name": "Lyrics",
+            "actions": [{
+                "name": "GetLyrics",
+                "arguments": [{ "name": "Uri", "direction": "in", "variable": "Missing" }]
+            }]
+        }))
+        .unwrap();
+        assert!(matches!(
+            build_service(description, supervisor()),
+            Err(PluginError::InvalidReply(..))
+        ));
+    }
+
+    #[test]
+    fn test_upnp_error_codes_are_kept() {
+        let upnp = action_error(PluginError::Rpc {
+            code: 714,
+            message: "Illegal MIME-type".into(),
+        });
+        assert!(matches!(upnp, ActionError::UpnpError { ref code, .. } if code == "714"));
+        let other = action_error(PluginError::Rpc {
+            code: -32601,
+            message: "unknown method".into(),
+        });
+        assert!(matches!(other, ActionError::GeneralError(_)));
+    }
+}
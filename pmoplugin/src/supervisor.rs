@@ -0,0 +1,179 @@
+//! Lancement et relance d'un plugin
+//!
+//! Le processus est lancé au premier appel et relancé s'il s'est arrêté. Un
+//! plugin qui échoue en boucle (crash au démarrage, poignée de main refusée)
+//! n'est pas relancé à chaque appel : après un premier échec, les tentatives
+//! sont espacées d'un délai qui double à chaque échec, de
+//! [`RESPAWN_BACKOFF_MIN`] à [`RESPAWN_BACKOFF_MAX`]. Pendant ce délai, les
+//! appels échouent immédiatement avec [`PluginError::Backoff`]. Un plugin
+//! resté en vie plus de [`RESPAWN_BACKOFF_MAX`] repart sans pénalité.
+
+use std::sync::Arc;
+use std::time::{Duration, Instant};
+
+use tokio::sync::Mutex;
+use tracing::{info, warn};
+
+use crate::error::{PluginError, Result};
+use crate::process::{PluginCommand, PluginProcess};
+use crate::protocol::Capability;
+
+/// Délai avant la deuxième tentative de relance
+pub const RESPAWN_BACKOFF_MIN: Duration = Duration::from_secs(1);
+
+/// Délai maximal entre deux tentatives de relance
+pub const RESPAWN_BACKOFF_MAX: Duration = Duration::from_secs(60);
+
+#[derive(Debug, Default)]
+struct State {
+    process: Option<Arc<PluginProcess>>,
+    started_at: Option<Instant>,
+    /// Échecs consécutifs (lancements ratés et arrêts du plugin)
+    failures: u32,
+    retry_at: Option<Instant>,
+}
+
+impl State {
+    /// Enregistre un échec et programme la prochaine tentative
+    fn fail(&mut self, now: Instant) -> Duration {
+        self.process = None;
+        self.failures += 1;
+        let delay = backoff(self.failures);
+        self.retry_at = Some(now + delay);
+        delay
+    }
+}
+
+/// Délai avant la tentative suivant `failures` échecs consécutifs
+///
+/// La première relance est immédiate : un plugin qui s'arrête une fois est
+/// relancé sans attendre.
+fn backoff(failures: u32) -> Duration {
+    match failures {
+        0 | 1 => Duration::ZERO,
+        n => RESPAWN_BACKOFF_MIN
+            .saturating_mul(1 << (n - 2).min(16))
+            .min(RESPAWN_BACKOFF_MAX),
+    }
+}
+
+/// Processus d'un plugin, lancé à la demande pour une capacité
+#[derive(Debug)]
+pub struct PluginSupervisor {
+    /// Usage du plugin, pour les journaux (montage, service…)
+    label: String,
+    command: PluginCommand,
+    capability: Capability,
+    state: Mutex<State>,
+}
+
+impl PluginSupervisor {
+    pub fn new(label: &str, command: PluginCommand, capability: Capability) -> Self {
+        Self {
+            label: label.to_string(),
+            command,
+            capability,
+            state: Mutex::new(State::default()),
+        }
+    }
+
+    /// Commande de lancement du plugin
+    pub fn command(&self) -> &PluginCommand {
+        &self.command
+    }
+
+    /// Processus en cours, lancé ou relancé si nécessaire
+    pub async fn process(&self) -> Result<Arc<PluginProcess>> {
+        let mut state = self.state.lock().await;
+        let now = Instant::now();
+
+        if let Some(running) = state.process.as_ref() {
+            if running.is_alive() {
+                return Ok(Arc::clone(running));
+            }
+            if state
+                .started_at
+                .is_some_and(|t| now.duration_since(t) >= RESPAWN_BACKOFF_MAX)
+            {
+                state.failures = 0;
+            }
+            let delay = state.fail(now);
+            warn!(
+                "🔌 Plugin {} stopped for '{}', restarting in {:?}",
+                self.command.command, self.label, delay
+            );
+        }
+
+        if let Some(retry_at) = state.retry_at.filter(|t| *t > now) {
+            return Err(PluginError::Backoff(
+                self.command.command.clone(),
+                retry_at - now,
+            ));
+        }
+
+        match PluginProcess::start(&self.command, self.capability).await {
+            Ok(started) => {
+                let handshake = started.handshake();
+                info!(
+                    "🔌 Plugin {} {} started for '{}' (protocol v{})",
+                    handshake.name,
+                    handshake.version.as_deref().unwrap_or(""),
+                    self.label,
+                    handshake.protocol_version
+                );
+                let started = Arc::new(started);
+                state.process = Some(Arc::clone(&started));
+                state.started_at = Some(now);
+                state.retry_at = None;
+                Ok(started)
+            }
+            Err(e) => {
+                let delay = state.fail(now);
+                warn!(
+                    "❌ Plugin {} failed to start for '{}', next attempt in {:?}: {}",
+                    self.command.command, self.label, delay, e
+                );
+                Err(e)
+            }
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_backoff_doubles_up_to_max() {
+        assert_eq!(backoff(1), Duration::ZERO);
+        assert_eq!(backoff(2), Duration::from_secs(1));
+        assert_eq!(backoff(3), Duration::from_secs(2));
+        assert_eq!(backoff(5), Duration::from_secs(8));
+        assert_eq!(backoff(8), RESPAWN_BACKOFF_MAX);
+        assert_eq!(backoff(u32::MAX), RESPAWN_BACKOFF_MAX);
+    }
+
+    #[tokio::test]
+    async fn test_failed_starts_back_off() {
+        let command = PluginCommand::from_options(
+            &serde_json::json!({ "command": "/nonexistent/pmomusic-plugin" }),
+        )
+        .unwrap();
+        let supervisor = PluginSupervisor::new("test", command, Capability::ContentProvider);
+
+        // Premier échec : relance immédiate autorisée
+        assert!(matches!(
+            supervisor.process().await,
+            Err(PluginError::Spawn(..))
+        ));
+        assert!(matches!(
+            supervisor.process().await,
+            Err(PluginError::Spawn(..))
+        ));
+        // Deuxième échec : les appels suivants attendent
+        match supervisor.process().await {
+            Err(PluginError::Backoff(_, delay)) => assert!(delay <= RESPAWN_BACKOFF_MIN),
+            other => panic!("expected backoff, got {:?}", other),
+        }
+    }
+}
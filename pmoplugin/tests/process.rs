@@ -0,0 +1,152 @@
+//! Cycle de vie d'un plugin : poignée de main, appels, délai et arrêt
+//!
+//! Le plugin de test est un script shell qui répond aux requêtes sans
+//! analyser le JSON ; son comportement est choisi par `PLUGIN_MODE`.
+
+#![cfg(unix)]
+
+use std::collections::HashMap;
+use std::path::PathBuf;
+use std::sync::OnceLock;
+use std::time::Duration;
+
+use pmoplugin::{Capability, PluginCommand, PluginError, PluginProcess};
+use serde::Deserialize;
+use serde_json::{Value, json};
+
+const SCRIPT: &str = r#"
+[ "$PMOMUSIC_PLUGIN_MAGIC_COOKIE" = "b3f1c7e2-pmomusic-plugin" ] || exit 1
+while IFS= read -r line; do
+  id=$(printf '%s\n' "$line" | sed -n 's/.*"id":\([0-9]*\).*/\1/p')
+  method=$(printf '%s\n' "$line" | sed -n 's/.*"method":"\([^"]*\)".*/\1/p')
+  case "$method" in
+    handshake)
+      case "$PLUGIN_MODE" in
+        version) result='{"protocol_version":99,"name":"test","capabilities":["content_provider"]}' ;;
+        nocap) result='{"protocol_version":1,"name":"test","capabilities":["search"]}' ;;
+        *) result='{"protocol_version":1,"name":"test","version":"0.1","capabilities":["content_provider","dsp_stage","future_capability"]}' ;;
+      esac ;;
+    answer) result='{"value":42}' ;;
+    sleep) continue ;;
+    exit) exit 0 ;;
+    *)
+      echo "unknown method $method" >&2
+      printf '{"jsonrpc":"2.0","id":%s,"error":{"code":-32601,"message":"unknown method"}}\n' "$id"
+      continue ;;
+  esac
+  printf '{"jsonrpc":"2.0","id":%s,"result":%s}\n' "$id" "$result"
+done
+"#;
+
+/// Script du plugin, écrit une fois pour tous les tests
+fn script() -> PathBuf {
+    static SCRIPT_DIR: OnceLock<tempfile::TempDir> = OnceLock::new();
+    let dir = SCRIPT_DIR.get_or_init(|| {
+        let dir = tempfile::tempdir().unwrap();
+        std::fs::write(dir.path().join("plugin.sh"), SCRIPT).unwrap();
+        dir
+    });
+    dir.path().join("plugin.sh")
+}
+
+/// Commande lançant le script par `sh` (pas de bit d'exécution à poser)
+fn command(mode: &str) -> PluginCommand {
+    PluginCommand {
+        command: "sh".to_string(),
+        args: vec![script().to_string_lossy().into_owned()],
+        env: HashMap::from([("PLUGIN_MODE".to_string(), mode.to_string())]),
+        timeout: Duration::from_millis(500),
+    }
+}
+
+#[derive(Debug, Deserialize)]
+struct Answer {
+    value: u32,
+}
+
+#[tokio::test]
+async fn test_handshake_and_call() {
+    let plugin = PluginProcess::start(&command("ok"), Capability::ContentProvider)
+        .await
+        .unwrap();
+    let handshake = plugin.handshake();
+    assert_eq!(handshake.name, "test");
+    assert_eq!(handshake.version.as_deref(), Some("0.1"));
+    assert!(handshake.supports(Capability::DspStage));
+    assert!(handshake.supports(Capability::Unknown));
+
+    let answer: Answer = plugin.call("answer", json!({})).await.unwrap();
+    assert_eq!(answer.value, 42);
+
+    match plugin.call::<Value>("missing", json!({})).await {
+        Err(PluginError::Rpc { code, .. }) => assert_eq!(code, -32601),
+        other => panic!("expected an RPC error, got {:?}", other),
+    }
+    assert!(plugin.is_alive());
+}
+
+#[tokio::test]
+async fn test_unsupported_version_is_rejected() {
+    match PluginProcess::start(&command("version"), Capability::ContentProvider).await {
+        Err(PluginError::Handshake(_, message)) => {
+            assert!(message.contains("unsupported protocol version 99"))
+        }
+        other => panic!("expected a handshake error, got {:?}", other.map(|_| ())),
+    }
+}
+
+#[tokio::test]
+async fn test_missing_capability_is_rejected() {
+    match PluginProcess::start(&command("nocap"), Capability::ContentProvider).await {
+        Err(PluginError::Handshake(_, message)) => assert!(message.contains("ContentProvider")),
+        other => panic!("expected a handshake error, got {:?}", other.map(|_| ())),
+    }
+}
+
+#[tokio::test]
+async fn test_missing_cookie_stops_the_plugin() {
+    // Le script refuse de démarrer sans cookie : lancé par un autre `sh`
+    // qui l'efface, il s'arrête avant la poignée de main
+    let mut cmd = command("ok");
+    cmd.args = vec![
+        "-c".to_string(),
+        format!(
+            "unset PMOMUSIC_PLUGIN_MAGIC_COOKIE; exec sh {}",
+            script().display()
+        ),
+    ];
+    assert!(matches!(
+        PluginProcess::start(&cmd, Capability::ContentProvider).await,
+        Err(PluginError::Handshake(..))
+    ));
+}
+
+#[tokio::test]
+async fn test_call_times_out() {
+    let plugin = PluginProcess::start(&command("ok"), Capability::ContentProvider)
+        .await
+        .unwrap();
+    match plugin.call::<Value>("sleep", json!({})).await {
+        Err(PluginError::Timeout(_, method)) => assert_eq!(method, "sleep"),
+        other => panic!("expected a timeout, got {:?}", other),
+    }
+    // Un appel sans réponse ne bloque pas les suivants
+    let answer: Answer = plugin.call("answer", json!({})).await.unwrap();
+    assert_eq!(answer.value, 42);
+}
+
+#[tokio::test]
+async fn test_plugin_exit_fails_pending_and_later_calls() {
+    let plugin = PluginProcess::start(&command("ok"), Capability::ContentProvider)
+        .await
+        .unwrap();
+    assert!(matches!(
+        plugin.call::<Value>("exit", json!({})).await,
+        Err(PluginError::Exited(_))
+    ));
+    assert!(!plugin.is_alive());
+    assert!(matches!(
+        plugin.call::<Value>("answer", json!({})).await,
+        Err(PluginError::Exited(_))
+    ));
+}
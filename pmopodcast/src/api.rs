@@ -0,0 +1,221 @@
+//! Endpoints API REST pour les podcasts
+//!
+//! - `GET    /api/podcasts`                             abonnements
+//! - `POST   /api/podcasts`                             s'abonner (`{"url": "…"}`)
+//! - `POST   /api/podcasts/refresh`                     rafraîchir tous les flux
+//! - `DELETE /api/podcasts/{id}`                        se désabonner
+//! - `POST   /api/podcasts/{id}/refresh`                rafraîchir un flux
+//! - `GET    /api/podcasts/{id}/episodes`               épisodes et positions
+//! - `GET    /api/podcasts/episodes/{episode_id}/position`
+//! - `PUT    /api/podcasts/episodes/{episode_id}/position` (`{"position_secs": 120, "completed": false}`)
+//! - `DELETE /api/podcasts/episodes/{episode_id}/position`
+//!
+//! Les positions sont celles du profil de la requête (en-tête
+//! `X-PMO-Profile` ou `?profile=`), partagées avec la reprise de lecture du
+//! control point (voir [`crate::positions`]). Sans control point, les
+//! routes de position répondent `503`.
+
+use crate::error::PodcastError;
+use crate::feed::Episode;
+use crate::manager::{Podcast, PodcastManager};
+use crate::positions::{self, EpisodePosition};
+use crate::provider::PodcastProvider;
+use axum::{
+    Json, Router,
+    extract::{Path, State},
+    http::StatusCode,
+    response::{IntoResponse, Response},
+    routing::{delete, get, post},
+};
+use chrono::{DateTime, Utc};
+use pmoserver::Profile;
+use serde::{Deserialize, Serialize};
+use std::sync::Arc;
+
+/// État partagé des handlers
+#[derive(Clone)]
+pub struct PodcastState {
+    pub manager: Arc<PodcastManager>,
+    /// URI des épisodes, clés des positions de lecture
+    pub provider: PodcastProvider,
+}
+
+impl IntoResponse for PodcastError {
+    fn into_response(self) -> Response {
+        let status = match &self {
+            PodcastError::UnknownPodcast(_) | PodcastError::UnknownEpisode(_) => {
+                StatusCode::NOT_FOUND
+            }
+            PodcastError::AlreadySubscribed(_) => StatusCode::CONFLICT,
+            PodcastError::InvalidFeed(_) | PodcastError::Unsafe(_) => {
+                StatusCode::UNPROCESSABLE_ENTITY
+            }
+            PodcastError::Http(_) => StatusCode::BAD_GATEWAY,
+            PodcastError::Storage(_) => StatusCode::INTERNAL_SERVER_ERROR,
+            PodcastError::PositionsUnavailable => StatusCode::SERVICE_UNAVAILABLE,
+        };
+        (status, Json(serde_json::json!({ "error": self.to_string() }))).into_response()
+    }
+}
+
+/// Résumé d'un abonnement
+#[derive(Debug, Serialize)]
+pub struct PodcastSummary {
+    pub id: String,
+    pub title: String,
+    pub feed_url: String,
+    pub author: Option<String>,
+    pub image: Option<String>,
+    pub episode_count: usize,
+    pub last_refresh: Option<DateTime<Utc>>,
+    pub last_error: Option<String>,
+}
+
+impl From<&Podcast> for PodcastSummary {
+    fn from(p: &Podcast) -> Self {
+        Self {
+            id: p.id.clone(),
+            title: p.feed.title.clone(),
+            feed_url: p.feed_url.clone(),
+            author: p.feed.author.clone(),
+            image: p.feed.image.clone(),
+            episode_count: p.feed.episodes.len(),
+            last_refresh: p.last_refresh,
+            last_error: p.last_error.clone(),
+        }
+    }
+}
+
+/// Épisode accompagné de sa position de lecture
+#[derive(Debug, Serialize)]
+pub struct EpisodeView {
+    pub id: String,
+    #[serde(flatten)]
+    pub episode: Episode,
+    pub position: Option<EpisodePosition>,
+}
+
+#[derive(Debug, Deserialize)]
+pub struct SubscribeRequest {
+    pub url: String,
+}
+
+#[derive(Debug, Deserialize)]
+pub struct PositionRequest {
+    pub position_secs: u32,
+    /// Épisode terminé : sa position est oubliée
+    #[serde(default)]
+    pub completed: bool,
+}
+
+/// Crée le router de l'API podcasts
+pub fn create_router(state: PodcastState) -> Router {
+    Router::new()
+        .route("/", get(list_podcasts).post(subscribe))
+        .route("/refresh", post(refresh_all))
+        .route("/{id}", delete(unsubscribe))
+        .route("/{id}/refresh", post(refresh))
+        .route("/{id}/episodes", get(list_episodes))
+        .route(
+            "/episodes/{episode_id}/position",
+            get(get_position).put(set_position).delete(clear_position),
+        )
+        .with_state(state)
+}
+
+/// GET /api/podcasts
+async fn list_podcasts(State(state): State<PodcastState>) -> Json<Vec<PodcastSummary>> {
+    let podcasts = state.manager.podcasts().await;
+    Json(podcasts.iter().map(PodcastSummary::from).collect())
+}
+
+/// POST /api/podcasts
+async fn subscribe(
+    State(state): State<PodcastState>,
+    Json(req): Json<SubscribeRequest>,
+) -> Result<(StatusCode, Json<PodcastSummary>), PodcastError> {
+    let podcast = state.manager.subscribe(&req.url).await?;
+    Ok((StatusCode::CREATED, Json(PodcastSummary::from(&podcast))))
+}
+
+/// POST /api/podcasts/refresh
+async fn refresh_all(State(state): State<PodcastState>) -> StatusCode {
+    let manager = state.manager.clone();
+    tokio::spawn(async move { manager.refresh_all().await });
+    StatusCode::ACCEPTED
+}
+
+/// DELETE /api/podcasts/{id}
+async fn unsubscribe(
+    State(state): State<PodcastState>,
+    Path(id): Path<String>,
+) -> Result<StatusCode, PodcastError> {
+    state.manager.unsubscribe(&id).await?;
+    Ok(StatusCode::NO_CONTENT)
+}
+
+/// POST /api/podcasts/{id}/refresh
+async fn refresh(
+    State(state): State<PodcastState>,
+    Path(id): Path<String>,
+) -> Result<Json<PodcastSummary>, PodcastError> {
+    let podcast = state.manager.refresh(&id).await?;
+    Ok(Json(PodcastSummary::from(&podcast)))
+}
+
+/// GET /api/podcasts/{id}/episodes
+async fn list_episodes(
+    State(state): State<PodcastState>,
+    Profile(profile): Profile,
+    Path(id): Path<String>,
+) -> Result<Json<Vec<EpisodeView>>, PodcastError> {
+    let podcast = state.manager.podcast(&id).await?;
+    let mut episodes = Vec::with_capacity(podcast.feed.episodes.len());
+    for ep in &podcast.feed.episodes {
+        let uri = state.provider.episode_uri(ep).await;
+        episodes.push(EpisodeView {
+            id: podcast.episode_id(ep),
+            episode: ep.clone(),
+            position: positions::get(&profile, &uri).ok().flatten(),
+        });
+    }
+    Ok(Json(episodes))
+}
+
+/// GET /api/podcasts/episodes/{episode_id}/position
+async fn get_position(
+    State(state): State<PodcastState>,
+    Profile(profile): Profile,
+    Path(episode_id): Path<String>,
+) -> Result<Json<Option<EpisodePosition>>, PodcastError> {
+    let (_, episode) = state.manager.episode(&episode_id).await?;
+    let uri = state.provider.episode_uri(&episode).await;
+    Ok(Json(positions::get(&profile, &uri)?))
+}
+
+/// PUT /api/podcasts/episodes/{episode_id}/position
+///
+/// Retourne `null` pour un épisode terminé.
+async fn set_position(
+    State(state): State<PodcastState>,
+    Profile(profile): Profile,
+    Path(episode_id): Path<String>,
+    Json(req): Json<PositionRequest>,
+) -> Result<Json<Option<EpisodePosition>>, PodcastError> {
+    let (_, episode) = state.manager.episode(&episode_id).await?;
+    let uri = state.provider.episode_uri(&episode).await;
+    let position = positions::set(&profile, &uri, &episode, req.position_secs, req.completed)?;
+    Ok(Json(position))
+}
+
+/// DELETE /api/podcasts/episodes/{episode_id}/position
+async fn clear_position(
+    State(state): State<PodcastState>,
+    Profile(profile): Profile,
+    Path(episode_id): Path<String>,
+) -> Result<StatusCode, PodcastError> {
+    let (_, episode) = state.manager.episode(&episode_id).await?;
+    let uri = state.provider.episode_uri(&episode).await;
+    positions::clear(&profile, &uri)?;
+    Ok(StatusCode::NO_CONTENT)
+}
@@ -0,0 +1,75 @@
+//! Extension pour intégrer les podcasts dans pmoconfig
+//!
+//! ```yaml
+//! sources:
+//!   podcasts:
+//!     directory: "podcasts"        # abonnements, épisodes et positions
+//!     poll_interval_secs: 3600     # intervalle de rafraîchissement des flux
+//!     max_episodes: 200            # épisodes conservés par podcast
+//! ```
+
+use anyhow::Result;
+use pmoconfig::Config;
+use serde_yaml::Value;
+
+/// Intervalle par défaut entre deux rafraîchissements des flux (1 heure)
+pub const DEFAULT_POLL_INTERVAL_SECS: u64 = 3600;
+
+/// Nombre d'épisodes conservés par défaut pour chaque podcast
+pub const DEFAULT_MAX_EPISODES: usize = 200;
+
+const DEFAULT_DIRECTORY: &str = "podcasts";
+
+/// Trait d'extension pour la configuration des podcasts
+pub trait PodcastConfigExt {
+    /// Répertoire de stockage des abonnements (créé si nécessaire)
+    fn get_podcasts_dir(&self) -> Result<String>;
+
+    /// Intervalle de rafraîchissement des flux, en secondes
+    fn get_podcasts_poll_interval_secs(&self) -> Result<u64>;
+
+    /// Définit l'intervalle de rafraîchissement des flux
+    fn set_podcasts_poll_interval_secs(&self, secs: u64) -> Result<()>;
+
+    /// Nombre maximal d'épisodes conservés par podcast
+    fn get_podcasts_max_episodes(&self) -> Result<usize>;
+
+    /// Définit le nombre maximal d'épisodes conservés par podcast
+    fn set_podcasts_max_episodes(&self, max: usize) -> Result<()>;
+}
+
+impl PodcastConfigExt for Config {
+    fn get_podcasts_dir(&self) -> Result<String> {
+        self.get_managed_dir(&["sources", "podcasts", "directory"], DEFAULT_DIRECTORY)
+    }
+
+    fn get_podcasts_poll_interval_secs(&self) -> Result<u64> {
+        match self.get_value(&["sources", "podcasts", "poll_interval_secs"]) {
+            Ok(Value::Number(n)) if n.as_u64().is_some_and(|v| v > 0) => Ok(n.as_u64().unwrap()),
+            _ => Ok(DEFAULT_POLL_INTERVAL_SECS),
+        }
+    }
+
+    fn set_podcasts_poll_interval_secs(&self, secs: u64) -> Result<()> {
+        self.set_value(
+            &["sources", "podcasts", "poll_interval_secs"],
+            Value::Number(secs.into()),
+        )
+    }
+
+    fn get_podcasts_max_episodes(&self) -> Result<usize> {
+        match self.get_value(&["sources", "podcasts", "max_episodes"]) {
+            Ok(Value::Number(n)) if n.as_u64().is_some_and(|v| v > 0) => {
+                Ok(n.as_u64().unwrap() as usize)
+            }
+            _ => Ok(DEFAULT_MAX_EPISODES),
+        }
+    }
+
+    fn set_podcasts_max_episodes(&self, max: usize) -> Result<()> {
+        self.set_value(
+            &["sources", "podcasts", "max_episodes"],
+            Value::Number((max as u64).into()),
+        )
+    }
+}
@@ -0,0 +1,44 @@
+//! Types d'erreurs pour pmopodcast
+
+use thiserror::Error;
+
+/// Erreurs du backend podcasts
+#[derive(Debug, Error)]
+pub enum PodcastError {
+    #[error("HTTP error: {0}")]
+    Http(#[from] reqwest::Error),
+
+    #[error("Unsafe feed document: {0}")]
+    Unsafe(#[from] pmodidl::xml_guard::XmlGuardError),
+
+    #[error("Invalid feed: {0}")]
+    InvalidFeed(String),
+
+    #[error("Unknown podcast: {0}")]
+    UnknownPodcast(String),
+
+    #[error("Unknown episode: {0}")]
+    UnknownEpisode(String),
+
+    #[error("Already subscribed: {0}")]
+    AlreadySubscribed(String),
+
+    #[error("Storage error: {0}")]
+    Storage(String),
+
+    #[error("Playback positions unavailable: no control point attached")]
+    PositionsUnavailable,
+}
+
+pub type Result<T> = std::result::Result<T, PodcastError>;
+
+impl From<PodcastError> for pmosource::MusicSourceError {
+    fn from(e: PodcastError) -> Self {
+        match e {
+            PodcastError::UnknownPodcast(id) | PodcastError::UnknownEpisode(id) => {
+                pmosource::MusicSourceError::ObjectNotFound(id)
+            }
+            other => pmosource::MusicSourceError::SourceUnavailable(other.to_string()),
+        }
+    }
+}
@@ -0,0 +1,302 @@
+//! Lecture des flux de podcasts (RSS 2.0 / iTunes et Atom)
+//!
+//! Le parseur ne retient que ce qui est utile au ContentDirectory : titre,
+//! image et auteur du podcast, puis pour chaque épisode son identifiant, son
+//! titre, le fichier audio joint, sa durée et sa date de publication. Les
+//! entrées sans fichier audio sont ignorées.
+
+use crate::error::{PodcastError, Result};
+use chrono::{DateTime, Utc};
+use quick_xml::Reader;
+use quick_xml::escape::resolve_predefined_entity;
+use quick_xml::events::{BytesStart, Event};
+use serde::{Deserialize, Serialize};
+
+/// Podcast décrit par un flux
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct Feed {
+    pub title: String,
+    pub description: Option<String>,
+    pub author: Option<String>,
+    pub image: Option<String>,
+    /// Épisodes, dans l'ordre du flux (généralement du plus récent au plus ancien)
+    pub episodes: Vec<Episode>,
+}
+
+/// Épisode d'un podcast
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+pub struct Episode {
+    /// Identifiant fourni par le flux (`guid`/`id`), ou URL du fichier audio
+    pub guid: String,
+    pub title: String,
+    /// URL du fichier audio (enclosure)
+    pub audio_url: String,
+    pub mime_type: String,
+    pub duration_secs: Option<u64>,
+    pub published: Option<DateTime<Utc>>,
+    pub description: Option<String>,
+    pub image: Option<String>,
+}
+
+/// Analyse un document RSS ou Atom
+pub fn parse_feed(xml: &[u8]) -> Result<Feed> {
+    pmodidl::xml_guard::check(xml)?;
+
+    // Pas de trim_text : les espaces autour des entités (`2 &amp; fin`)
+    // sont significatifs, les valeurs sont nettoyées en fin d'élément
+    let mut reader = Reader::from_reader(xml);
+
+    let mut feed = Feed::default();
+    let mut episode: Option<Episode> = None;
+    let mut stack: Vec<String> = Vec::new();
+    let mut text = String::new();
+    let mut buf = Vec::new();
+    let mut is_feed = false;
+
+    loop {
+        match reader
+            .read_event_into(&mut buf)
+            .map_err(|e| PodcastError::InvalidFeed(e.to_string()))?
+        {
+            Event::Start(e) => {
+                let name = local_name(&e);
+                if stack.is_empty() {
+                    is_feed = matches!(name.as_str(), "rss" | "feed" | "RDF");
+                }
+                if matches!(name.as_str(), "item" | "entry") {
+                    episode = Some(Episode::default());
+                }
+                handle_attributes(&e, &name, &stack, &mut feed, episode.as_mut());
+                stack.push(name);
+                text.clear();
+            }
+            Event::Empty(e) => {
+                let name = local_name(&e);
+                handle_attributes(&e, &name, &stack, &mut feed, episode.as_mut());
+            }
+            Event::Text(e) => {
+                let decoded = e
+                    .decode()
+                    .map_err(|e| PodcastError::InvalidFeed(e.to_string()))?;
+                text.push_str(&decoded);
+            }
+            Event::CData(e) => {
+                text.push_str(&String::from_utf8_lossy(&e));
+            }
+            Event::GeneralRef(e) => {
+                if let Ok(Some(c)) = e.resolve_char_ref() {
+                    text.push(c);
+                } else if let Ok(name) = e.decode() {
+                    text.push_str(resolve_predefined_entity(&name).unwrap_or_default());
+                }
+            }
+            Event::End(_) => {
+                let Some(name) = stack.pop() else { continue };
+                let value = std::mem::take(&mut text).trim().to_string();
+                let parent = stack.last().map(String::as_str).unwrap_or_default();
+
+                if matches!(name.as_str(), "item" | "entry") {
+                    if let Some(mut ep) = episode.take() {
+                        if !ep.audio_url.is_empty() {
+                            if ep.guid.is_empty() {
+                                ep.guid = ep.audio_url.clone();
+                            }
+                            feed.episodes.push(ep);
+                        }
+                    }
+                    continue;
+                }
+                if value.is_empty() {
+                    continue;
+                }
+
+                match episode.as_mut() {
+                    Some(ep) if matches!(parent, "item" | "entry") => {
+                        apply_episode_field(ep, &name, value)
+                    }
+                    Some(_) => {}
+                    None => apply_feed_field(&mut feed, &name, parent, value),
+                }
+            }
+            Event::Eof => break,
+            _ => {}
+        }
+        buf.clear();
+    }
+
+    if !is_feed {
+        return Err(PodcastError::InvalidFeed(
+            "not an RSS or Atom document".to_string(),
+        ));
+    }
+    Ok(feed)
+}
+
+fn local_name(e: &BytesStart) -> String {
+    String::from_utf8_lossy(e.local_name().as_ref()).into_owned()
+}
+
+fn attribute(e: &BytesStart, key: &str) -> Option<String> {
+    e.attributes()
+        .flatten()
+        .find(|a| a.key.local_name().as_ref() == key.as_bytes())
+        .and_then(|a| a.unescape_value().ok())
+        .map(|v| v.trim().to_string())
+        .filter(|v| !v.is_empty())
+}
+
+/// Informations portées par des attributs (`enclosure`, `itunes:image`, `link`)
+fn handle_attributes(
+    e: &BytesStart,
+    name: &str,
+    stack: &[String],
+    feed: &mut Feed,
+    episode: Option<&mut Episode>,
+) {
+    match (name, episode) {
+        ("enclosure", Some(ep)) => {
+            if let Some(url) = attribute(e, "url") {
+                ep.audio_url = url;
+                ep.mime_type = attribute(e, "type").unwrap_or_else(|| "audio/mpeg".to_string());
+            }
+        }
+        ("link", Some(ep)) if attribute(e, "rel").as_deref() == Some("enclosure") => {
+            if let Some(url) = attribute(e, "href") {
+                ep.audio_url = url;
+                ep.mime_type = attribute(e, "type").unwrap_or_else(|| "audio/mpeg".to_string());
+            }
+        }
+        ("image", Some(ep)) => {
+            if let Some(href) = attribute(e, "href") {
+                ep.image = Some(href);
+            }
+        }
+        ("image", None) if stack.last().map(String::as_str) == Some("channel") => {
+            if let Some(href) = attribute(e, "href") {
+                feed.image = Some(href);
+            }
+        }
+        _ => {}
+    }
+}
+
+fn apply_feed_field(feed: &mut Feed, name: &str, parent: &str, value: String) {
+    match (name, parent) {
+        ("title", "channel" | "feed") => feed.title = value,
+        ("description" | "subtitle", "channel" | "feed") => {
+            feed.description.get_or_insert(value);
+        }
+        ("author", "channel") | ("name", "author") => {
+            feed.author.get_or_insert(value);
+        }
+        ("url", "image") | ("logo", "feed") => feed.image = Some(value),
+        ("icon", "feed") => {
+            feed.image.get_or_insert(value);
+        }
+        _ => {}
+    }
+}
+
+fn apply_episode_field(ep: &mut Episode, name: &str, value: String) {
+    match name {
+        "title" => ep.title = value,
+        "guid" | "id" => ep.guid = value,
+        "duration" => ep.duration_secs = parse_duration(&value),
+        "pubDate" => {
+            ep.published = DateTime::parse_from_rfc2822(&value)
+                .ok()
+                .map(|d| d.with_timezone(&Utc));
+        }
+        "published" | "updated" => {
+            if ep.published.is_none() || name == "published" {
+                ep.published = DateTime::parse_from_rfc3339(&value)
+                    .ok()
+                    .map(|d| d.with_timezone(&Utc));
+            }
+        }
+        "description" | "summary" => {
+            ep.description.get_or_insert(value);
+        }
+        _ => {}
+    }
+}
+
+/// Durée iTunes : `SSSS`, `MM:SS` ou `HH:MM:SS`
+pub fn parse_duration(value: &str) -> Option<u64> {
+    value
+        .split(':')
+        .try_fold(0u64, |acc, part| {
+            let part = part.trim().split('.').next()?;
+            Some(acc * 60 + part.parse::<u64>().ok()?)
+        })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const RSS: &str = r#"<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+  <channel>
+    <title>Les Pieds sur terre</title>
+    <description>Reportages</description>
+    <itunes:author>France Culture</itunes:author>
+    <itunes:image href="https://example.org/cover.jpg"/>
+    <item>
+      <title>Épisode 2 &amp; fin</title>
+      <guid isPermaLink="false">ep-2</guid>
+      <pubDate>Tue, 10 Jun 2025 04:00:00 +0000</pubDate>
+      <enclosure url="https://example.org/ep2.mp3" type="audio/mpeg" length="123"/>
+      <itunes:duration>00:28:04</itunes:duration>
+    </item>
+    <item>
+      <title>Sans audio</title>
+      <guid>no-audio</guid>
+    </item>
+  </channel>
+</rss>"#;
+
+    const ATOM: &str = r#"<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Atom cast</title>
+  <logo>https://example.org/logo.png</logo>
+  <entry>
+    <title>First</title>
+    <id>urn:uuid:1</id>
+    <published>2025-01-02T03:04:05Z</published>
+    <link rel="alternate" href="https://example.org/first"/>
+    <link rel="enclosure" type="audio/ogg" href="https://example.org/first.ogg"/>
+  </entry>
+</feed>"#;
+
+    #[test]
+    fn test_parse_rss() {
+        let feed = parse_feed(RSS.as_bytes()).unwrap();
+        assert_eq!(feed.title, "Les Pieds sur terre");
+        assert_eq!(feed.author.as_deref(), Some("France Culture"));
+        assert_eq!(feed.image.as_deref(), Some("https://example.org/cover.jpg"));
+        assert_eq!(feed.episodes.len(), 1);
+
+        let ep = &feed.episodes[0];
+        assert_eq!(ep.title, "Épisode 2 & fin");
+        assert_eq!(ep.guid, "ep-2");
+        assert_eq!(ep.audio_url, "https://example.org/ep2.mp3");
+        assert_eq!(ep.duration_secs, Some(28 * 60 + 4));
+        assert!(ep.published.is_some());
+    }
+
+    #[test]
+    fn test_parse_atom() {
+        let feed = parse_feed(ATOM.as_bytes()).unwrap();
+        assert_eq!(feed.title, "Atom cast");
+        assert_eq!(feed.image.as_deref(), Some("https://example.org/logo.png"));
+        let ep = &feed.episodes[0];
+        assert_eq!(ep.guid, "urn:uuid:1");
+        assert_eq!(ep.audio_url, "https://example.org/first.ogg");
+        assert_eq!(ep.mime_type, "audio/ogg");
+
+        assert!(parse_feed(b"<html><body/></html>").is_err());
+        assert_eq!(parse_duration("1:02:03"), Some(3723));
+        assert_eq!(parse_duration("95"), Some(95));
+    }
+}
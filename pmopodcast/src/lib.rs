@@ -0,0 +1,40 @@
+//! # PMOPodcast
+//!
+//! Abonnements aux podcasts pour PMOMusic.
+//!
+//! - Lecture des flux RSS 2.0 (avec extensions iTunes) et Atom
+//! - Rafraîchissement périodique et conservation locale des épisodes
+//! - Position de lecture de chaque épisode, par profil d'écoute, partagée
+//!   avec la reprise de lecture du control point (feature `control`)
+//! - Exposition dans le ContentDirectory sous un container « Podcasts »
+//!   (provider `podcasts` des dossiers virtuels, voir `pmosource::provider`)
+//! - API REST sous `/api/podcasts`
+//!
+//! ## Configuration
+//!
+//! ```yaml
+//! sources:
+//!   podcasts:
+//!     directory: "podcasts"
+//!     poll_interval_secs: 3600
+//!     max_episodes: 200
+//! ```
+
+pub mod api;
+pub mod config_ext;
+pub mod error;
+pub mod feed;
+pub mod manager;
+pub mod pmoserver_ext;
+pub mod positions;
+pub mod provider;
+
+pub use config_ext::PodcastConfigExt;
+pub use error::{PodcastError, Result};
+pub use feed::{Episode, Feed, parse_feed};
+pub use manager::{Podcast, PodcastManager};
+pub use pmoserver_ext::PodcastExt;
+pub use positions::EpisodePosition;
+#[cfg(feature = "control")]
+pub use positions::attach_control_point;
+pub use provider::PodcastProvider;
@@ -0,0 +1,524 @@
+//! Gestion des abonnements aux podcasts
+//!
+//! Le [`PodcastManager`] tient la liste des abonnements et rafraîchit les
+//! flux périodiquement. Les positions de lecture des épisodes relèvent de la
+//! reprise du control point (voir [`crate::positions`]).
+//!
+//! Les épisodes sont conservés localement : un épisode retiré du flux par
+//! l'éditeur reste disponible tant qu'il fait partie des `max_episodes` plus
+//! récents, et l'arborescence se parcourt même si le flux est injoignable.
+//! Tout est persisté dans `podcasts.json` sous le répertoire configuré.
+
+use crate::config_ext::PodcastConfigExt;
+use crate::error::{PodcastError, Result};
+use crate::feed::{Episode, Feed, parse_feed};
+use chrono::{DateTime, Utc};
+use serde::{Deserialize, Serialize};
+use sha2::{Digest, Sha256};
+use std::collections::HashSet;
+use std::path::PathBuf;
+use std::sync::Arc;
+use std::sync::atomic::{AtomicU32, Ordering};
+use std::time::{Duration, SystemTime};
+use tokio::sync::RwLock;
+use tracing::{debug, info, warn};
+
+/// Taille maximale d'un document de flux
+const MAX_FEED_BYTES: usize = 16 * 1024 * 1024;
+
+/// Nom du fichier de persistance
+const STORE_FILE: &str = "podcasts.json";
+
+/// Podcast auquel on est abonné
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct Podcast {
+    /// Identifiant stable, dérivé de l'URL du flux
+    pub id: String,
+    pub feed_url: String,
+    pub feed: Feed,
+    pub subscribed: DateTime<Utc>,
+    pub last_refresh: Option<DateTime<Utc>>,
+    /// Erreur du dernier rafraîchissement
+    pub last_error: Option<String>,
+}
+
+impl Podcast {
+    /// Identifiant stable d'un épisode de ce podcast
+    pub fn episode_id(&self, episode: &Episode) -> String {
+        episode_id(&self.id, &episode.guid)
+    }
+
+    /// Recherche un épisode par identifiant
+    pub fn episode(&self, episode_id: &str) -> Option<&Episode> {
+        self.feed
+            .episodes
+            .iter()
+            .find(|ep| self.episode_id(ep) == episode_id)
+    }
+}
+
+#[derive(Debug, Default, Serialize, Deserialize)]
+struct Store {
+    podcasts: Vec<Podcast>,
+}
+
+/// Identifiant stable d'un podcast
+pub fn podcast_id(feed_url: &str) -> String {
+    short_hash(feed_url.trim().as_bytes(), 12)
+}
+
+/// Identifiant stable d'un épisode
+pub fn episode_id(podcast_id: &str, guid: &str) -> String {
+    short_hash(format!("{}\n{}", podcast_id, guid).as_bytes(), 16)
+}
+
+fn short_hash(data: &[u8], len: usize) -> String {
+    let digest = Sha256::digest(data);
+    let mut hex: String = digest.iter().map(|b| format!("{:02x}", b)).collect();
+    hex.truncate(len);
+    hex
+}
+
+/// Abonnements et épisodes
+#[derive(Debug)]
+pub struct PodcastManager {
+    path: Option<PathBuf>,
+    client: reqwest::Client,
+    max_episodes: usize,
+    store: RwLock<Store>,
+    update_id: AtomicU32,
+    last_change: std::sync::Mutex<Option<SystemTime>>,
+}
+
+impl PodcastManager {
+    /// Crée un gestionnaire persisté dans `dir` (None : en mémoire seulement)
+    pub fn new(dir: Option<PathBuf>, max_episodes: usize) -> Result<Self> {
+        let client = reqwest::Client::builder()
+            .timeout(Duration::from_secs(30))
+            .user_agent(concat!("PMOMusic/", env!("CARGO_PKG_VERSION")))
+            .build()?;
+
+        let path = dir.map(|d| d.join(STORE_FILE));
+        let store = match &path {
+            Some(path) if path.exists() => {
+                let data = std::fs::read(path).map_err(|e| PodcastError::Storage(e.to_string()))?;
+                serde_json::from_slice(&data).map_err(|e| PodcastError::Storage(e.to_string()))?
+            }
+            _ => Store::default(),
+        };
+
+        Ok(Self {
+            path,
+            client,
+            max_episodes: max_episodes.max(1),
+            store: RwLock::new(store),
+            update_id: AtomicU32::new(1),
+            last_change: std::sync::Mutex::new(None),
+        })
+    }
+
+    /// Crée le gestionnaire depuis la configuration (`sources.podcasts`)
+    pub fn from_config() -> anyhow::Result<Self> {
+        let config = pmoconfig::get_config();
+        let dir = PathBuf::from(config.get_podcasts_dir()?);
+        Ok(Self::new(Some(dir), config.get_podcasts_max_episodes()?)?)
+    }
+
+    /// Lance le rafraîchissement périodique des flux
+    pub fn spawn_polling(self: &Arc<Self>, interval: Duration) -> tokio::task::JoinHandle<()> {
+        let manager = Arc::downgrade(self);
+        tokio::spawn(async move {
+            let mut ticker = tokio::time::interval(interval);
+            ticker.set_missed_tick_behavior(tokio::time::MissedTickBehavior::Delay);
+            loop {
+                ticker.tick().await;
+                let Some(manager) = manager.upgrade() else { break };
+                manager.refresh_all().await;
+            }
+        })
+    }
+
+    /// Compteur de modifications (pour le SystemUpdateID du ContentDirectory)
+    pub fn update_id(&self) -> u32 {
+        self.update_id.load(Ordering::Relaxed)
+    }
+
+    /// Date de la dernière modification
+    pub fn last_change(&self) -> Option<SystemTime> {
+        *self.last_change.lock().unwrap()
+    }
+
+    fn touch(&self) {
+        self.update_id.fetch_add(1, Ordering::Relaxed);
+        *self.last_change.lock().unwrap() = Some(SystemTime::now());
+    }
+
+    /// Liste les abonnements
+    pub async fn podcasts(&self) -> Vec<Podcast> {
+        self.store.read().await.podcasts.clone()
+    }
+
+    /// Retourne un abonnement
+    pub async fn podcast(&self, id: &str) -> Result<Podcast> {
+        self.store
+            .read()
+            .await
+            .podcasts
+            .iter()
+            .find(|p| p.id == id)
+            .cloned()
+            .ok_or_else(|| PodcastError::UnknownPodcast(id.to_string()))
+    }
+
+    /// Retourne un épisode et le podcast auquel il appartient
+    pub async fn episode(&self, episode_id: &str) -> Result<(Podcast, Episode)> {
+        let store = self.store.read().await;
+        store
+            .podcasts
+            .iter()
+            .find_map(|p| p.episode(episode_id).map(|ep| (p.clone(), ep.clone())))
+            .ok_or_else(|| PodcastError::UnknownEpisode(episode_id.to_string()))
+    }
+
+    /// S'abonne à un flux
+    pub async fn subscribe(&self, feed_url: &str) -> Result<Podcast> {
+        let feed_url = feed_url.trim();
+        if !(feed_url.starts_with("http://") || feed_url.starts_with("https://")) {
+            return Err(PodcastError::InvalidFeed(format!(
+                "unsupported feed URL: {}",
+                feed_url
+            )));
+        }
+        let id = podcast_id(feed_url);
+        if self.store.read().await.podcasts.iter().any(|p| p.id == id) {
+            return Err(PodcastError::AlreadySubscribed(feed_url.to_string()));
+        }
+
+        let mut feed = self.fetch(feed_url).await?;
+        feed.episodes.truncate(self.max_episodes);
+        let now = Utc::now();
+        let podcast = Podcast {
+            id,
+            feed_url: feed_url.to_string(),
+            feed,
+            subscribed: now,
+            last_refresh: Some(now),
+            last_error: None,
+        };
+
+        {
+            let mut store = self.store.write().await;
+            if store.podcasts.iter().any(|p| p.id == podcast.id) {
+                return Err(PodcastError::AlreadySubscribed(feed_url.to_string()));
+            }
+            store.podcasts.push(podcast.clone());
+        }
+        self.touch();
+        self.save().await?;
+        info!(
+            "🎙️ Subscribed to podcast '{}' ({} episodes)",
+            podcast.feed.title,
+            podcast.feed.episodes.len()
+        );
+        Ok(podcast)
+    }
+
+    /// Se désabonne d'un podcast
+    pub async fn unsubscribe(&self, id: &str) -> Result<()> {
+        {
+            let mut store = self.store.write().await;
+            let index = store
+                .podcasts
+                .iter()
+                .position(|p| p.id == id)
+                .ok_or_else(|| PodcastError::UnknownPodcast(id.to_string()))?;
+            let podcast = store.podcasts.remove(index);
+            info!("🎙️ Unsubscribed from podcast '{}'", podcast.feed.title);
+        }
+        self.touch();
+        self.save().await
+    }
+
+    /// Rafraîchit un podcast
+    pub async fn refresh(&self, id: &str) -> Result<Podcast> {
+        let feed_url = self.podcast(id).await?.feed_url;
+        let fetched = self.fetch(&feed_url).await;
+
+        let (podcast, changed) = {
+            let mut store = self.store.write().await;
+            let podcast = store
+                .podcasts
+                .iter_mut()
+                .find(|p| p.id == id)
+                .ok_or_else(|| PodcastError::UnknownPodcast(id.to_string()))?;
+            let changed = match &fetched {
+                Ok(feed) => {
+                    podcast.last_refresh = Some(Utc::now());
+                    podcast.last_error = None;
+                    merge_feed(&mut podcast.feed, feed.clone(), self.max_episodes)
+                }
+                Err(e) => {
+                    podcast.last_error = Some(e.to_string());
+                    false
+                }
+            };
+            (podcast.clone(), changed)
+        };
+
+        if changed {
+            self.touch();
+        }
+        self.save().await?;
+        fetched.map(|_| podcast)
+    }
+
+    /// Rafraîchit tous les podcasts (les erreurs sont journalisées)
+    pub async fn refresh_all(&self) {
+        let ids: Vec<String> = self.podcasts().await.into_iter().map(|p| p.id).collect();
+        for id in ids {
+            match self.refresh(&id).await {
+                Ok(p) => debug!("🎙️ Podcast '{}' refreshed", p.feed.title),
+                Err(e) => warn!("⚠️ Failed to refresh podcast {}: {}", id, e),
+            }
+        }
+    }
+
+    async fn fetch(&self, url: &str) -> Result<Feed> {
+        let mut response = self.client.get(url).send().await?.error_for_status()?;
+        if response
+            .content_length()
+            .is_some_and(|len| len as usize > MAX_FEED_BYTES)
+        {
+            return Err(PodcastError::InvalidFeed("feed too large".to_string()));
+        }
+
+        let mut body = Vec::new();
+        while let Some(chunk) = response.chunk().await? {
+            if body.len() + chunk.len() > MAX_FEED_BYTES {
+                return Err(PodcastError::InvalidFeed("feed too large".to_string()));
+            }
+            body.extend_from_slice(&chunk);
+        }
+
+        let feed = parse_feed(&body)?;
+        if feed.title.is_empty() && feed.episodes.is_empty() {
+            return Err(PodcastError::InvalidFeed(format!("empty feed: {}", url)));
+        }
+        Ok(feed)
+    }
+
+    async fn save(&self) -> Result<()> {
+        let Some(path) = &self.path else {
+            return Ok(());
+        };
+        let data = {
+            let store = self.store.read().await;
+            serde_json::to_vec_pretty(&*store).map_err(|e| PodcastError::Storage(e.to_string()))?
+        };
+        let tmp = path.with_extension("json.tmp");
+        tokio::fs::write(&tmp, data)
+            .await
+            .map_err(|e| PodcastError::Storage(e.to_string()))?;
+        tokio::fs::rename(&tmp, path)
+            .await
+            .map_err(|e| PodcastError::Storage(e.to_string()))
+    }
+}
+
+/// Fusionne un flux fraîchement lu dans le flux conservé
+///
+/// Les épisodes du nouveau flux passent devant, les anciens épisodes absents
+/// du flux sont conservés à la suite. Retourne true si le contenu a changé.
+fn merge_feed(current: &mut Feed, fresh: Feed, max_episodes: usize) -> bool {
+    let fresh_guids: HashSet<&str> = fresh.episodes.iter().map(|ep| ep.guid.as_str()).collect();
+    let mut episodes = fresh.episodes.clone();
+    episodes.extend(
+        current
+            .episodes
+            .iter()
+            .filter(|ep| !fresh_guids.contains(ep.guid.as_str()))
+            .cloned(),
+    );
+    episodes.truncate(max_episodes);
+
+    let merged = Feed { episodes, ..fresh };
+    let changed = *current != merged;
+    *current = merged;
+    changed
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn episode(guid: &str) -> Episode {
+        Episode {
+            guid: guid.to_string(),
+            title: guid.to_string(),
+            audio_url: format!("https://example.org/{}.mp3", guid),
+            mime_type: "audio/mpeg".to_string(),
+            ..Default::default()
+        }
+    }
+
+    fn rss(guids: &[&str]) -> Vec<u8> {
+        let items: String = guids
+            .iter()
+            .map(|guid| {
+                format!(
+                    "<item><title>{0}</title><guid>{0}</guid>\
+                     <enclosure url=\"https://example.org/{0}.mp3\" type=\"audio/mpeg\"/></item>",
+                    guid
+                )
+            })
+            .collect();
+        format!(
+            "<rss version=\"2.0\"><channel><title>Cast</title>{}</channel></rss>",
+            items
+        )
+        .into_bytes()
+    }
+
+    /// Serveur HTTP local : chaque requête reçoit le corps courant, annoncé
+    /// avec `Content-Length: declared` (rien d'annoncé si None)
+    async fn serve_feed(
+        body: Arc<std::sync::Mutex<Vec<u8>>>,
+        declared: impl Fn(usize) -> Option<usize> + Send + 'static,
+    ) -> String {
+        use tokio::io::{AsyncReadExt, AsyncWriteExt};
+
+        let listener = tokio::net::TcpListener::bind("127.0.0.1:0").await.unwrap();
+        let url = format!("http://{}/feed.xml", listener.local_addr().unwrap());
+        tokio::spawn(async move {
+            while let Ok((mut stream, _)) = listener.accept().await {
+                let body = body.lock().unwrap().clone();
+                let length = declared(body.len())
+                    .map(|len| format!("Content-Length: {}\r\n", len))
+                    .unwrap_or_default();
+                tokio::spawn(async move {
+                    let mut request = [0u8; 4096];
+                    let _ = stream.read(&mut request).await;
+                    let header = format!(
+                        "HTTP/1.1 200 OK\r\nContent-Type: application/rss+xml\r\n{}Connection: close\r\n\r\n",
+                        length
+                    );
+                    let _ = stream.write_all(header.as_bytes()).await;
+                    let _ = stream.write_all(&body).await;
+                });
+            }
+        });
+        url
+    }
+
+    #[test]
+    fn test_merge_keeps_cached_episodes() {
+        let mut current = Feed {
+            title: "Cast".to_string(),
+            episodes: vec![episode("b"), episode("a")],
+            ..Default::default()
+        };
+        let fresh = Feed {
+            title: "Cast".to_string(),
+            episodes: vec![episode("c"), episode("b")],
+            ..Default::default()
+        };
+
+        assert!(merge_feed(&mut current, fresh.clone(), 10));
+        let guids: Vec<&str> = current.episodes.iter().map(|e| e.guid.as_str()).collect();
+        assert_eq!(guids, ["c", "b", "a"]);
+
+        assert!(!merge_feed(&mut current, fresh.clone(), 10));
+        merge_feed(&mut current, fresh, 2);
+        assert_eq!(current.episodes.len(), 2);
+    }
+
+    #[test]
+    fn test_stable_ids() {
+        assert_eq!(
+            podcast_id("https://example.org/feed"),
+            podcast_id(" https://example.org/feed ")
+        );
+        assert_ne!(episode_id("p1", "a"), episode_id("p2", "a"));
+    }
+
+    #[tokio::test]
+    async fn test_polling_refreshes_feeds() {
+        let body = Arc::new(std::sync::Mutex::new(rss(&["a"])));
+        let url = serve_feed(body.clone(), Some).await;
+        let manager = Arc::new(PodcastManager::new(None, 10).unwrap());
+        let podcast = manager.subscribe(&url).await.unwrap();
+        let update_id = manager.update_id();
+
+        *body.lock().unwrap() = rss(&["b", "a"]);
+        let polling = manager.spawn_polling(Duration::from_millis(20));
+        tokio::time::timeout(Duration::from_secs(5), async {
+            loop {
+                let refreshed = manager.podcast(&podcast.id).await.unwrap();
+                if refreshed.feed.episodes.len() == 2 {
+                    break;
+                }
+                tokio::time::sleep(Duration::from_millis(10)).await;
+            }
+        })
+        .await
+        .expect("feed not refreshed by the polling task");
+
+        let refreshed = manager.podcast(&podcast.id).await.unwrap();
+        assert!(refreshed.last_error.is_none());
+        assert_eq!(refreshed.feed.episodes[0].guid, "b");
+        assert!(manager.update_id() > update_id);
+
+        // La tâche s'arrête avec le dernier Arc du gestionnaire
+        drop(manager);
+        tokio::time::timeout(Duration::from_secs(1), polling)
+            .await
+            .expect("polling task still running")
+            .unwrap();
+    }
+
+    #[tokio::test]
+    async fn test_feed_size_cap() {
+        let manager = PodcastManager::new(None, 10).unwrap();
+
+        // Taille annoncée trop grande : refusé sans lire le corps
+        let body = Arc::new(std::sync::Mutex::new(rss(&["a"])));
+        let url = serve_feed(body, |_| Some(MAX_FEED_BYTES + 1)).await;
+        let error = manager.subscribe(&url).await.unwrap_err();
+        assert!(matches!(error, PodcastError::InvalidFeed(ref e) if e == "feed too large"));
+
+        // Taille non annoncée : la lecture s'arrête au-delà de la limite
+        let mut big = rss(&["a"]);
+        big.resize(MAX_FEED_BYTES + 1, b' ');
+        let url = serve_feed(Arc::new(std::sync::Mutex::new(big)), |_| None).await;
+        let error = manager.subscribe(&url).await.unwrap_err();
+        assert!(matches!(error, PodcastError::InvalidFeed(ref e) if e == "feed too large"));
+
+        assert!(manager.podcasts().await.is_empty());
+    }
+
+    #[tokio::test]
+    async fn test_subscriptions_persist() {
+        let dir = tempfile::tempdir().unwrap();
+        let body = Arc::new(std::sync::Mutex::new(rss(&["a", "b"])));
+        let url = serve_feed(body, Some).await;
+
+        let manager = PodcastManager::new(Some(dir.path().to_path_buf()), 10).unwrap();
+        let podcast = manager.subscribe(&url).await.unwrap();
+        assert!(matches!(
+            manager.subscribe(&url).await,
+            Err(PodcastError::AlreadySubscribed(_))
+        ));
+
+        // Un nouveau gestionnaire relit abonnements et épisodes
+        let reloaded = PodcastManager::new(Some(dir.path().to_path_buf()), 10).unwrap();
+        let (_, episode) = reloaded
+            .episode(&podcast.episode_id(&podcast.feed.episodes[1]))
+            .await
+            .unwrap();
+        assert_eq!(episode.guid, "b");
+
+        reloaded.unsubscribe(&podcast.id).await.unwrap();
+        let reloaded = PodcastManager::new(Some(dir.path().to_path_buf()), 10).unwrap();
+        assert!(reloaded.podcasts().await.is_empty());
+    }
+}
@@ -0,0 +1,57 @@
+//! Extension pmoserver pour les podcasts
+
+use crate::api::{PodcastState, create_router};
+use crate::config_ext::PodcastConfigExt;
+use crate::manager::PodcastManager;
+use crate::provider::PodcastProvider;
+use anyhow::Result;
+use pmoserver::Server;
+use std::sync::Arc;
+use std::time::Duration;
+use tracing::info;
+
+/// Trait pour étendre pmoserver avec le backend podcasts
+///
+/// # Exemple
+///
+/// ```rust,ignore
+/// use pmopodcast::PodcastExt;
+///
+/// let manager = server.init_podcasts().await?;
+/// ```
+#[allow(async_fn_in_trait)]
+pub trait PodcastExt {
+    /// Initialise le backend podcasts
+    ///
+    /// Cette méthode :
+    /// - charge les abonnements depuis le répertoire configuré
+    /// - lance le rafraîchissement périodique des flux
+    /// - enregistre la fabrique `podcasts` des dossiers virtuels
+    /// - enregistre les routes `/api/podcasts/*`
+    async fn init_podcasts(&mut self) -> Result<Arc<PodcastManager>>;
+}
+
+impl PodcastExt for Server {
+    async fn init_podcasts(&mut self) -> Result<Arc<PodcastManager>> {
+        info!("Initializing podcasts...");
+
+        let manager = Arc::new(PodcastManager::from_config()?);
+        let interval = pmoconfig::get_config().get_podcasts_poll_interval_secs()?;
+        manager.spawn_polling(Duration::from_secs(interval));
+
+        PodcastProvider::register_factory(manager.clone());
+
+        let router = create_router(PodcastState {
+            manager: manager.clone(),
+            provider: PodcastProvider::new(manager.clone()),
+        });
+        self.add_router("/api/podcasts", router).await;
+
+        info!(
+            "🎙️ Podcasts initialized ({} subscriptions, refresh every {}s)",
+            manager.podcasts().await.len(),
+            interval
+        );
+        Ok(manager)
+    }
+}
@@ -0,0 +1,196 @@
+//! Positions de lecture des épisodes
+//!
+//! Les positions sont celles de la reprise de lecture du control point
+//! (`pmocontrol::resume`), seule source de vérité : la position atteinte sur
+//! un renderer se lit dans l'API podcasts, et une position fixée par l'API
+//! est celle où le renderer reprend l'épisode. Elles sont indexées par profil
+//! d'écoute et par URI de l'épisode telle que servie par le
+//! [`crate::PodcastProvider`].
+//!
+//! Un épisode écouté jusqu'au bout n'a plus de position (il repart du début).
+//! Sans control point (feature `control` absente, ou
+//! [`attach_control_point`] pas encore appelé), les positions ne sont pas
+//! disponibles.
+
+use crate::error::{PodcastError, Result};
+use crate::feed::Episode;
+use chrono::{DateTime, Utc};
+use serde::Serialize;
+
+/// Position de lecture mémorisée d'un épisode
+#[derive(Debug, Clone, PartialEq, Serialize)]
+pub struct EpisodePosition {
+    pub position_secs: u32,
+    pub duration_secs: Option<u32>,
+    pub updated: DateTime<Utc>,
+}
+
+#[cfg(feature = "control")]
+mod store {
+    use super::*;
+    use pmocontrol::ControlPoint;
+    use pmocontrol::resume::{ResumeClass, ResumePosition, ResumeStore};
+    use std::sync::{Arc, OnceLock};
+    use tracing::warn;
+
+    static CONTROL_POINT: OnceLock<Arc<ControlPoint>> = OnceLock::new();
+
+    /// Donne accès aux positions de reprise du control point
+    ///
+    /// À appeler une fois le control point enregistré ; les appels suivants
+    /// sont ignorés.
+    pub fn attach_control_point(control_point: Arc<ControlPoint>) {
+        let _ = CONTROL_POINT.set(control_point);
+    }
+
+    pub(super) fn resume_store() -> Result<Arc<ResumeStore>> {
+        CONTROL_POINT
+            .get()
+            .map(|cp| cp.resume_positions())
+            .ok_or(PodcastError::PositionsUnavailable)
+    }
+
+    impl From<ResumePosition> for EpisodePosition {
+        fn from(p: ResumePosition) -> Self {
+            Self {
+                position_secs: p.position_secs,
+                duration_secs: p.duration_secs,
+                updated: p.updated_at,
+            }
+        }
+    }
+
+    pub(super) fn get_in(store: &ResumeStore, profile: &str, uri: &str) -> Option<EpisodePosition> {
+        store.get(profile, uri).map(EpisodePosition::from)
+    }
+
+    pub(super) fn set_in(
+        store: &ResumeStore,
+        profile: &str,
+        uri: &str,
+        episode: &Episode,
+        position_secs: u32,
+        completed: bool,
+    ) -> Option<EpisodePosition> {
+        if completed {
+            clear_in(store, profile, uri);
+            return None;
+        }
+        let position = ResumePosition {
+            profile: profile.to_string(),
+            uri: uri.to_string(),
+            class: ResumeClass::Podcast,
+            position_secs,
+            duration_secs: episode.duration_secs.and_then(|d| u32::try_from(d).ok()),
+            title: Some(episode.title.clone()),
+            updated_at: Utc::now(),
+        };
+        store.set(position.clone());
+        flush(store);
+        Some(position.into())
+    }
+
+    pub(super) fn clear_in(store: &ResumeStore, profile: &str, uri: &str) -> bool {
+        let removed = store.clear(profile, uri);
+        flush(store);
+        removed
+    }
+
+    fn flush(store: &ResumeStore) {
+        if let Err(e) = store.flush() {
+            warn!("⚠️ Failed to save resume positions: {}", e);
+        }
+    }
+}
+
+#[cfg(feature = "control")]
+pub use store::attach_control_point;
+
+/// Position d'un épisode pour un profil
+pub fn get(profile: &str, uri: &str) -> Result<Option<EpisodePosition>> {
+    #[cfg(feature = "control")]
+    {
+        Ok(store::get_in(&store::resume_store()?, profile, uri))
+    }
+    #[cfg(not(feature = "control"))]
+    {
+        let _ = (profile, uri);
+        Err(PodcastError::PositionsUnavailable)
+    }
+}
+
+/// Fixe la position d'un épisode pour un profil
+///
+/// Un épisode terminé (`completed`) perd sa position : retourne `None`.
+pub fn set(
+    profile: &str,
+    uri: &str,
+    episode: &Episode,
+    position_secs: u32,
+    completed: bool,
+) -> Result<Option<EpisodePosition>> {
+    #[cfg(feature = "control")]
+    {
+        let store = store::resume_store()?;
+        Ok(store::set_in(
+            &store,
+            profile,
+            uri,
+            episode,
+            position_secs,
+            completed,
+        ))
+    }
+    #[cfg(not(feature = "control"))]
+    {
+        let _ = (profile, uri, episode, position_secs, completed);
+        Err(PodcastError::PositionsUnavailable)
+    }
+}
+
+/// Oublie la position d'un épisode pour un profil
+pub fn clear(profile: &str, uri: &str) -> Result<bool> {
+    #[cfg(feature = "control")]
+    {
+        Ok(store::clear_in(&store::resume_store()?, profile, uri))
+    }
+    #[cfg(not(feature = "control"))]
+    {
+        let _ = (profile, uri);
+        Err(PodcastError::PositionsUnavailable)
+    }
+}
+
+#[cfg(all(test, feature = "control"))]
+mod tests {
+    use super::store::*;
+    use pmocontrol::resume::{ResumeClass, ResumeStore};
+
+    #[test]
+    fn test_positions_follow_profiles() {
+        let store = ResumeStore::in_memory();
+        let episode = crate::feed::Episode {
+            guid: "ep1".to_string(),
+            title: "Épisode 1".to_string(),
+            duration_secs: Some(3600),
+            ..Default::default()
+        };
+        let uri = "http://server/audio/flac/L:ep1";
+
+        let position = set_in(&store, "alice", uri, &episode, 754, false).unwrap();
+        assert_eq!(position.position_secs, 754);
+        assert_eq!(position.duration_secs, Some(3600));
+        assert_eq!(get_in(&store, "alice", uri), Some(position));
+        assert_eq!(get_in(&store, "bob", uri), None);
+
+        // Position lue par la reprise du control point
+        let saved = store.get("alice", uri).unwrap();
+        assert_eq!(saved.class, ResumeClass::Podcast);
+        assert_eq!(saved.title.as_deref(), Some("Épisode 1"));
+
+        // Épisode terminé : la position est oubliée
+        assert_eq!(set_in(&store, "alice", uri, &episode, 3590, true), None);
+        assert_eq!(get_in(&store, "alice", uri), None);
+        assert!(!clear_in(&store, "alice", uri));
+    }
+}
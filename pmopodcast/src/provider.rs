@@ -0,0 +1,217 @@
+//! Podcasts exposés comme dossier virtuel du ContentDirectory
+//!
+//! Arborescence (chemins relatifs au montage) :
+//!
+//! ```text
+//! ""                      liste des podcasts
+//! podcast:{podcast_id}    épisodes d'un podcast
+//! episode:{episode_id}    un épisode
+//! ```
+//!
+//! Comme pour les autres sources, l'audio des épisodes passe par le cache
+//! audio : chaque enclosure reçoit une clé différée (`L:`) et n'est
+//! téléchargée puis convertie en FLAC qu'à la première lecture. Si le cache
+//! n'est pas initialisé, l'URL de l'éditeur est servie telle quelle.
+
+use crate::feed::Episode;
+use crate::manager::{Podcast, PodcastManager};
+use pmodidl::{Container, Item, Resource};
+use pmosource::provider::{ContentProvider, register_provider_factory};
+use pmosource::{BrowseResult, MusicSourceError, Result, SearchQuery, SourceCacheManager};
+use std::sync::{Arc, OnceLock};
+use std::time::SystemTime;
+use tracing::warn;
+
+/// Nom de la fabrique enregistrée pour `sources.virtual_folders`
+pub const PROVIDER_NAME: &str = "podcasts";
+
+/// Provider de contenu adossé au [`PodcastManager`]
+#[derive(Clone)]
+pub struct PodcastProvider {
+    manager: Arc<PodcastManager>,
+    /// Cache audio, obtenu du registre au premier usage
+    cache: Arc<OnceLock<Arc<SourceCacheManager>>>,
+}
+
+impl std::fmt::Debug for PodcastProvider {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        f.debug_struct("PodcastProvider")
+            .field("manager", &self.manager)
+            .field("cached", &self.cache.get().is_some())
+            .finish()
+    }
+}
+
+impl PodcastProvider {
+    pub fn new(manager: Arc<PodcastManager>) -> Self {
+        Self {
+            manager,
+            cache: Arc::new(OnceLock::new()),
+        }
+    }
+
+    /// Enregistre la fabrique `podcasts` pour les dossiers virtuels
+    pub fn register_factory(manager: Arc<PodcastManager>) {
+        register_provider_factory(PROVIDER_NAME, move |_| {
+            Ok(Arc::new(PodcastProvider::new(manager.clone())) as Arc<dyn ContentProvider>)
+        });
+    }
+
+    fn podcast_container(podcast: &Podcast) -> Container {
+        Container {
+            id: format!("podcast:{}", podcast.id),
+            parent_id: String::new(),
+            restricted: Some("1".to_string()),
+            child_count: Some(podcast.feed.episodes.len().to_string()),
+            searchable: Some("1".to_string()),
+            title: podcast.feed.title.clone(),
+            class: "object.container.album.musicAlbum".to_string(),
+            artist: podcast.feed.author.clone(),
+            album_art: podcast.feed.image.clone(),
+            containers: vec![],
+            items: vec![],
+        }
+    }
+
+    fn cache(&self) -> Option<&Arc<SourceCacheManager>> {
+        if let Some(cache) = self.cache.get() {
+            return Some(cache);
+        }
+        let cache = SourceCacheManager::from_registry(PROVIDER_NAME.to_string()).ok()?;
+        Some(self.cache.get_or_init(|| Arc::new(cache)))
+    }
+
+    /// URL et type MIME de l'audio d'un épisode (servi par le cache si possible)
+    async fn audio_resource(&self, episode: &Episode) -> (String, String) {
+        let Some(cache) = self.cache() else {
+            return (episode.audio_url.clone(), episode.mime_type.clone());
+        };
+        let url = match cache.cache_audio_lazy(&episode.audio_url, None).await {
+            Ok(pk) => cache.audio_url(&pk),
+            Err(e) => Err(e),
+        };
+        match url {
+            Ok(url) => (url, "audio/flac".to_string()),
+            Err(e) => {
+                warn!(
+                    "⚠️ Failed to cache podcast episode {}: {}",
+                    episode.audio_url, e
+                );
+                (episode.audio_url.clone(), episode.mime_type.clone())
+            }
+        }
+    }
+
+    /// URI de l'épisode telle que la voient les renderers
+    ///
+    /// C'est sous cette URI que la reprise de lecture mémorise la position
+    /// de l'épisode (voir [`crate::positions`]).
+    pub async fn episode_uri(&self, episode: &Episode) -> String {
+        self.audio_resource(episode).await.0
+    }
+
+    async fn episode_items(&self, podcast: &Podcast) -> Vec<Item> {
+        let mut items = Vec::with_capacity(podcast.feed.episodes.len());
+        for ep in &podcast.feed.episodes {
+            items.push(self.episode_item(podcast, ep).await);
+        }
+        items
+    }
+
+    async fn episode_item(&self, podcast: &Podcast, ep: &Episode) -> Item {
+        let duration = ep
+            .duration_secs
+            .map(|secs| format!("{}:{:02}:{:02}", secs / 3600, (secs / 60) % 60, secs % 60));
+        let (url, mime_type) = self.audio_resource(ep).await;
+        Item {
+            id: format!("episode:{}", podcast.episode_id(ep)),
+            parent_id: format!("podcast:{}", podcast.id),
+            restricted: Some("1".to_string()),
+            title: ep.title.clone(),
+            creator: podcast.feed.author.clone(),
+            class: "object.item.audioItem.musicTrack".to_string(),
+            artist: podcast.feed.author.clone(),
+            album: Some(podcast.feed.title.clone()),
+            genre: Some("Podcast".to_string()),
+            album_art: ep.image.clone().or_else(|| podcast.feed.image.clone()),
+            album_art_pk: None,
+            date: ep.published.map(|d| d.format("%Y-%m-%d").to_string()),
+            original_track_number: None,
+            resources: vec![Resource {
+                protocol_info: format!("http-get:*:{}:*", mime_type),
+                bits_per_sample: None,
+                sample_frequency: None,
+                nr_audio_channels: None,
+                duration,
+                url,
+            }],
+            descriptions: vec![],
+        }
+    }
+}
+
+#[async_trait::async_trait]
+impl ContentProvider for PodcastProvider {
+    async fn browse(&self, path: &str) -> Result<BrowseResult> {
+        if path.is_empty() {
+            let podcasts = self.manager.podcasts().await;
+            return Ok(BrowseResult::Containers(
+                podcasts.iter().map(Self::podcast_container).collect(),
+            ));
+        }
+        if let Some(id) = path.strip_prefix("podcast:") {
+            let podcast = self.manager.podcast(id).await?;
+            return Ok(BrowseResult::Items(self.episode_items(&podcast).await));
+        }
+        let item = self.get_item(path).await?;
+        Ok(BrowseResult::Items(vec![item]))
+    }
+
+    async fn get_item(&self, path: &str) -> Result<Item> {
+        let episode_id = path
+            .strip_prefix("episode:")
+            .ok_or_else(|| MusicSourceError::ObjectNotFound(path.to_string()))?;
+        let (podcast, episode) = self.manager.episode(episode_id).await?;
+        Ok(self.episode_item(&podcast, &episode).await)
+    }
+
+    async fn resolve(&self, path: &str) -> Result<String> {
+        let episode_id = path
+            .strip_prefix("episode:")
+            .ok_or_else(|| MusicSourceError::ObjectNotFound(path.to_string()))?;
+        let (_, episode) = self.manager.episode(episode_id).await?;
+        Ok(self.episode_uri(&episode).await)
+    }
+
+    async fn search(&self, query: &SearchQuery) -> Result<BrowseResult> {
+        let needle = query.text.to_lowercase();
+        let mut containers = Vec::new();
+        let mut items = Vec::new();
+        for podcast in self.manager.podcasts().await {
+            if podcast.feed.title.to_lowercase().contains(&needle) {
+                containers.push(Self::podcast_container(&podcast));
+            }
+            for ep in &podcast.feed.episodes {
+                if ep.title.to_lowercase().contains(&needle) {
+                    items.push(self.episode_item(&podcast, ep).await);
+                }
+            }
+        }
+        let offset = query.offset as usize;
+        let limit = query.limit as usize;
+        items = items.into_iter().skip(offset).take(limit).collect();
+        Ok(BrowseResult::Mixed { containers, items })
+    }
+
+    fn supports_search(&self) -> bool {
+        true
+    }
+
+    async fn update_id(&self) -> u32 {
+        self.manager.update_id()
+    }
+
+    async fn last_change(&self) -> Option<SystemTime> {
+        self.manager.last_change()
+    }
+}
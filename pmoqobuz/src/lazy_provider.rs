@@ -55,6 +55,7 @@ impl QobuzLazyProvider {
             sample_rate: track.sample_rate,
             channels: track.channels,
             bitrate: None,
+            replay_gain: None,
             conversion: None,
         }
     }
@@ -13,6 +13,7 @@ use pmodidl::{Container, Item};
 use pmosource::SourceCacheManager;
 use pmosource::{async_trait, BrowseResult, MediaSearchType, MusicSource, MusicSourceError, Result, SearchQuery, SearchScope};
 use serde_json::json;
+use std::collections::HashMap;
 use std::sync::Arc;
 use std::time::SystemTime;
 
@@ -248,6 +249,7 @@ impl QobuzSource {
             sample_rate: track.sample_rate,
             channels: track.channels,
             bitrate: None,
+            replay_gain: None,
             conversion: None,
         };
 
@@ -344,6 +346,7 @@ impl QobuzSource {
             sample_rate: track.sample_rate,
             channels: track.channels,
             bitrate: None,
+            replay_gain: None,
             conversion: None,
         };
 
@@ -601,7 +604,7 @@ impl QobuzSource {
             let cache_pk = item
                 .resources
                 .first()
-                .and_then(|r| r.url.strip_prefix("/audio/flac/").map(|s| s.to_string()));
+                .and_then(|r| pmosource::object_id::pk_from_audio_url(&r.url));
 
             if let Some(pk) = cache_pk {
                 if let Ok(Some(track_id_value)) = self
@@ -1009,25 +1012,31 @@ impl QobuzSource {
         }
     }
 
-    /// Cache les covers d'une liste d'items en parallèle (générique via `CoverCacheable`).
-    async fn cache_covers<T>(&self, items: Vec<T>) -> Vec<T>
+    /// Cache les covers d'une liste d'items en un lot (générique via `CoverCacheable`).
+    ///
+    /// Les URLs sont dédoublonnées : la pochette d'un album n'est téléchargée
+    /// qu'une fois, quel que soit le nombre de ses pistes dans la liste.
+    async fn cache_covers<T>(&self, mut items: Vec<T>) -> Vec<T>
     where
-        T: CoverCacheable + Send + 'static,
+        T: CoverCacheable,
     {
-        let futs: Vec<_> = items.into_iter().map(|mut item| {
-            let source = self.clone();
-            async move {
-                if let Some(image_url) = item.image_url().map(str::to_string) {
-                    if let Ok(pk) = source.inner.cache_manager.cache_cover(&image_url).await {
-                        if let Ok(url) = source.inner.cache_manager.cover_url(&pk, None) {
-                            item.set_image_cached(url);
-                        }
-                    }
-                }
-                item
+        let urls: Vec<&str> = items.iter().filter_map(T::image_url).collect();
+        if urls.is_empty() {
+            return items;
+        }
+        let cache_manager = &self.inner.cache_manager;
+        let cached: HashMap<String, String> = cache_manager
+            .cache_covers(&urls)
+            .await
+            .into_iter()
+            .filter_map(|(url, pk)| Some((url, cache_manager.cover_url(&pk.ok()?, None).ok()?)))
+            .collect();
+        for item in &mut items {
+            if let Some(url) = item.image_url().and_then(|url| cached.get(url)).cloned() {
+                item.set_image_cached(url);
             }
-        }).collect();
-        tokio::task::JoinSet::from_iter(futs).join_all().await
+        }
+        items
     }
 
     async fn cache_album_covers(&self, albums: Vec<crate::models::Album>) -> Vec<crate::models::Album> {
@@ -1038,26 +1047,9 @@ impl QobuzSource {
         self.cache_covers(playlists).await
     }
 
-    /// Cache les covers d'une liste de tracks en parallèle (via l'image de l'album).
+    /// Cache les covers d'une liste de tracks (via l'image de l'album).
     async fn cache_track_covers(&self, tracks: Vec<crate::models::Track>) -> Vec<crate::models::Track> {
-        let futs: Vec<_> = tracks.into_iter().map(|mut track| {
-            let source = self.clone();
-            async move {
-                if let Some(ref mut album) = track.album {
-                    if let Some(ref image_url) = album.image.clone() {
-                        if let Ok(pk) = source.inner.cache_manager.cache_cover(image_url).await {
-                            if let Ok(url) = source.inner.cache_manager.cover_url(&pk, None) {
-                                album.image_cached = Some(url);
-                            }
-                        }
-                    }
-                }
-                track
-            }
-        }).collect();
-        tokio::task::JoinSet::from_iter(futs)
-            .join_all()
-            .await
+        self.cache_covers(tracks).await
     }
 
     /// Enregistre une liste de tracks comme lazy entries dans l'audio cache (en parallèle).
@@ -1100,6 +1092,7 @@ impl QobuzSource {
                 sample_rate: track.sample_rate,
                 channels: track.channels,
                 bitrate: None,
+                replay_gain: None,
                 conversion: None,
             };
             async move {
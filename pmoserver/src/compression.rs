@@ -0,0 +1,128 @@
+//! Compression négociée des descriptions XML et de l'API JSON
+//!
+//! Les réponses `Browse` d'un gros ContentDirectory et les listes de l'API
+//! REST pèsent vite plusieurs centaines de kilo-octets ; compressées en gzip
+//! elles fondent d'un facteur 5 à 10, ce qui se sent nettement en Wi-Fi.
+//!
+//! La couche négocie l'encodage avec `Accept-Encoding` (gzip ou deflate) et
+//! ne compresse que les contenus XML et JSON au-delà d'une taille minimale.
+//! Les flux audio, les pochettes et les flux d'événements (SSE) ne sont
+//! jamais compressés : ils sont déjà compressés ou doivent partir sans délai.
+//!
+//! Configuration :
+//!
+//! ```yaml
+//! host:
+//!   compression:
+//!     enabled: true
+//!     min_bytes: 1024    # 65535 au plus
+//! ```
+
+use axum::http::{Extensions, HeaderMap, StatusCode, Version, header};
+use pmoconfig::get_config;
+use tower_http::compression::{
+    CompressionLayer,
+    predicate::{Predicate, SizeAbove},
+};
+use tracing::warn;
+
+/// Types MIME compressés (les suffixes `+xml` et `+json` le sont aussi)
+const COMPRESSIBLE_TYPES: &[&str] = &["text/xml", "application/xml", "application/json"];
+
+/// Réglages de compression
+#[derive(Debug, Clone, PartialEq)]
+pub struct CompressionSettings {
+    /// Taille en dessous de laquelle une réponse part telle quelle
+    /// (limitée à `u16::MAX` par tower-http)
+    pub min_bytes: u16,
+}
+
+impl Default for CompressionSettings {
+    fn default() -> Self {
+        Self { min_bytes: 1024 }
+    }
+}
+
+impl CompressionSettings {
+    /// Lit les réglages depuis la configuration (None si désactivé)
+    pub fn from_config() -> Option<Self> {
+        let config = get_config();
+        if !config.get_compression_enabled().unwrap_or(true) {
+            return None;
+        }
+        let min_bytes = config.get_compression_min_bytes().unwrap_or(1024);
+        let min_bytes = u16::try_from(min_bytes).unwrap_or_else(|_| {
+            warn!(
+                "⚠️ host.compression.min_bytes = {} exceeds {}, using {}",
+                min_bytes,
+                u16::MAX,
+                u16::MAX
+            );
+            u16::MAX
+        });
+        Some(Self { min_bytes })
+    }
+
+    /// Construit la couche tower-http correspondante (gzip et deflate)
+    pub fn layer(&self) -> CompressionLayer<impl Predicate> {
+        CompressionLayer::new().compress_when(SizeAbove::new(self.min_bytes).and(is_compressible))
+    }
+}
+
+/// Indique si le type de contenu d'une réponse est du XML ou du JSON
+pub fn is_compressible_type(content_type: &str) -> bool {
+    let mime = content_type
+        .split(';')
+        .next()
+        .unwrap_or_default()
+        .trim()
+        .to_ascii_lowercase();
+    COMPRESSIBLE_TYPES.contains(&mime.as_str()) || mime.ends_with("+xml") || mime.ends_with("+json")
+}
+
+fn is_compressible(_: StatusCode, _: Version, headers: &HeaderMap, _: &Extensions) -> bool {
+    headers
+        .get(header::CONTENT_TYPE)
+        .and_then(|v| v.to_str().ok())
+        .is_some_and(is_compressible_type)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use axum::{Router, body::Body, http, routing::get};
+    use tower::ServiceExt;
+
+    #[tokio::test]
+    async fn test_compresses_xml_but_not_audio() {
+        let xml = format!("<root>{}</root>", "<item/>".repeat(500));
+        let app = Router::new()
+            .route(
+                "/desc.xml",
+                get(move || async move {
+                    ([(header::CONTENT_TYPE, "text/xml; charset=\"utf-8\"")], xml)
+                }),
+            )
+            .route(
+                "/stream",
+                get(|| async { ([(header::CONTENT_TYPE, "audio/flac")], vec![0u8; 4096]) }),
+            )
+            .layer(CompressionSettings::default().layer());
+
+        let request = |uri: &str| {
+            http::Request::get(uri)
+                .header(header::ACCEPT_ENCODING, "gzip")
+                .body(Body::empty())
+                .unwrap()
+        };
+
+        let response = app.clone().oneshot(request("/desc.xml")).await.unwrap();
+        assert_eq!(response.headers()[header::CONTENT_ENCODING], "gzip");
+
+        let response = app.oneshot(request("/stream")).await.unwrap();
+        assert!(!response.headers().contains_key(header::CONTENT_ENCODING));
+
+        assert!(is_compressible_type("application/soap+xml"));
+        assert!(!is_compressible_type("text/event-stream"));
+    }
+}
@@ -17,6 +17,9 @@ pub trait ConfigExt {
     /// - `GET /api/config` - Récupérer toute la configuration
     /// - `GET /api/config/{path}` - Récupérer une valeur spécifique (ex: host.http_port)
     /// - `POST /api/config` - Mettre à jour une valeur
+    /// - `GET|POST /api/profiles` - Lister ou créer les profils d'écoute
+    /// - `PUT /api/profiles/default` - Changer le profil par défaut
+    /// - `DELETE /api/profiles/{name}` - Supprimer un profil
     /// - `GET /swagger-ui/config` - Documentation interactive Swagger
     ///
     /// # Exemple
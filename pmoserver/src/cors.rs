@@ -0,0 +1,231 @@
+//! Middleware CORS pour l'API JSON et les flux d'événements
+//!
+//! Permet à une interface web hébergée ailleurs (autre port, autre hôte)
+//! d'appeler l'API REST, les flux SSE et les WebSockets sans proxy. Les
+//! requêtes de pré-vérification (`OPTIONS`) sont traitées par la couche et
+//! n'atteignent pas les handlers.
+//!
+//! Configuration (désactivée par défaut) :
+//!
+//! ```yaml
+//! host:
+//!   cors:
+//!     enabled: true
+//!     origins: ["http://localhost:5173"]   # ou ["*"]
+//!     methods: ["GET", "POST", "PUT", "DELETE", "OPTIONS"]
+//!     headers: ["content-type", "authorization"]   # ou ["*"]
+//!     allow_credentials: false
+//!     max_age_secs: 600
+//! ```
+//!
+//! Avec `allow_credentials: true`, les jokers `*` sont refusés (la
+//! spécification les interdit) : origines, méthodes et en-têtes doivent être
+//! listés explicitement, sinon CORS reste désactivé.
+
+use axum::http::{HeaderName, HeaderValue, Method};
+use pmoconfig::get_config;
+use std::time::Duration;
+use tower_http::cors::{AllowHeaders, AllowMethods, AllowOrigin, CorsLayer};
+use tracing::{info, warn};
+
+/// Réglages CORS
+#[derive(Debug, Clone, PartialEq)]
+pub struct CorsSettings {
+    /// Origines autorisées (`*` = toutes)
+    pub origins: Vec<String>,
+    /// Méthodes autorisées (`*` = toutes)
+    pub methods: Vec<String>,
+    /// En-têtes autorisés (`*` = tous)
+    pub headers: Vec<String>,
+    /// Autoriser l'envoi de cookies / en-têtes d'authentification
+    pub allow_credentials: bool,
+    /// Durée de cache des réponses de pré-vérification
+    pub max_age: Duration,
+}
+
+impl CorsSettings {
+    /// Lit les réglages depuis la configuration (None si CORS désactivé)
+    pub fn from_config() -> Option<Self> {
+        let config = get_config();
+        if !config.get_cors_enabled().unwrap_or(false) {
+            return None;
+        }
+
+        let settings = Self {
+            origins: config.get_cors_origins().unwrap_or_default(),
+            methods: config.get_cors_methods().unwrap_or_default(),
+            headers: config.get_cors_headers().unwrap_or_default(),
+            allow_credentials: config.get_cors_allow_credentials().unwrap_or(false),
+            max_age: Duration::from_secs(config.get_cors_max_age_secs().unwrap_or(600) as u64),
+        };
+        if settings.origins.is_empty() {
+            warn!("CORS enabled but host.cors.origins is empty, CORS disabled");
+            return None;
+        }
+        if let Err(field) = settings.check() {
+            warn!(
+                "CORS allow_credentials requires an explicit host.cors.{} list, CORS disabled",
+                field
+            );
+            return None;
+        }
+        Some(settings)
+    }
+
+    /// Vérifie la cohérence des réglages.
+    ///
+    /// Les jokers `*` sont interdits avec `allow_credentials` ; retourne le
+    /// nom du champ fautif.
+    pub fn check(&self) -> Result<(), &'static str> {
+        if !self.allow_credentials {
+            return Ok(());
+        }
+        [
+            ("origins", &self.origins),
+            ("methods", &self.methods),
+            ("headers", &self.headers),
+        ]
+        .into_iter()
+        .find(|(_, items)| wildcard(items))
+        .map_or(Ok(()), |(field, _)| Err(field))
+    }
+
+    /// Construit la couche tower-http correspondante.
+    ///
+    /// Les réglages doivent avoir passé [`CorsSettings::check`] : tower-http
+    /// refuse `*` combiné aux credentials.
+    pub fn layer(&self) -> CorsLayer {
+        let origin = if wildcard(&self.origins) {
+            AllowOrigin::any()
+        } else {
+            AllowOrigin::list(self.origins.iter().filter_map(|origin| {
+                HeaderValue::from_str(origin.trim_end_matches('/'))
+                    .map_err(|_| warn!("Ignoring invalid CORS origin: {}", origin))
+                    .ok()
+            }))
+        };
+
+        let methods = if wildcard(&self.methods) {
+            AllowMethods::any()
+        } else {
+            AllowMethods::list(self.methods.iter().filter_map(|method| {
+                Method::from_bytes(method.to_ascii_uppercase().as_bytes())
+                    .map_err(|_| warn!("Ignoring invalid CORS method: {}", method))
+                    .ok()
+            }))
+        };
+
+        let headers = if wildcard(&self.headers) {
+            AllowHeaders::any()
+        } else {
+            AllowHeaders::list(self.headers.iter().filter_map(|header| {
+                HeaderName::from_bytes(header.to_ascii_lowercase().as_bytes())
+                    .map_err(|_| warn!("Ignoring invalid CORS header: {}", header))
+                    .ok()
+            }))
+        };
+
+        CorsLayer::new()
+            .allow_origin(origin)
+            .allow_methods(methods)
+            .allow_headers(headers)
+            .allow_credentials(self.allow_credentials)
+            .max_age(self.max_age)
+    }
+}
+
+/// Indique si la liste contient le joker `*`
+fn wildcard(items: &[String]) -> bool {
+    items.iter().any(|item| item == "*")
+}
+
+/// Couche CORS configurée, ou None si CORS est désactivé
+pub fn cors_layer_from_config() -> Option<CorsLayer> {
+    let settings = CorsSettings::from_config()?;
+    info!(
+        "🌍 CORS enabled for origins: {}",
+        settings.origins.join(", ")
+    );
+    Some(settings.layer())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use axum::{Router, body::Body, http::Request, routing::get};
+    use tower::ServiceExt;
+
+    fn settings(origins: &[&str], methods: &[&str], headers: &[&str]) -> CorsSettings {
+        let list = |items: &[&str]| items.iter().map(|item| item.to_string()).collect();
+        CorsSettings {
+            origins: list(origins),
+            methods: list(methods),
+            headers: list(headers),
+            allow_credentials: true,
+            max_age: Duration::from_secs(60),
+        }
+    }
+
+    #[test]
+    fn test_credentials_reject_wildcards() {
+        let explicit = settings(&["http://ui.local:5173"], &["GET"], &["content-type"]);
+        assert_eq!(explicit.check(), Ok(()));
+        assert_eq!(
+            settings(&["*"], &["GET"], &["content-type"]).check(),
+            Err("origins")
+        );
+        assert_eq!(
+            settings(&["http://ui.local:5173"], &["*"], &["content-type"]).check(),
+            Err("methods")
+        );
+        assert_eq!(
+            settings(&["http://ui.local:5173"], &["GET"], &["*"]).check(),
+            Err("headers")
+        );
+
+        let without_credentials = CorsSettings {
+            allow_credentials: false,
+            ..settings(&["*"], &["*"], &["*"])
+        };
+        assert_eq!(without_credentials.check(), Ok(()));
+    }
+
+    #[tokio::test]
+    async fn test_preflight_with_credentials() {
+        let settings = settings(
+            &["http://ui.local:5173/"],
+            &["GET", "post"],
+            &["content-type"],
+        );
+        assert_eq!(settings.check(), Ok(()));
+        let app = Router::new()
+            .route("/api/ping", get(|| async { "pong" }))
+            .layer(settings.layer());
+
+        let response = app
+            .oneshot(
+                Request::options("/api/ping")
+                    .header("origin", "http://ui.local:5173")
+                    .header("access-control-request-method", "POST")
+                    .body(Body::empty())
+                    .unwrap(),
+            )
+            .await
+            .unwrap();
+
+        assert_eq!(
+            response
+                .headers()
+                .get("access-control-allow-origin")
+                .unwrap(),
+            "http://ui.local:5173"
+        );
+        assert_eq!(
+            response
+                .headers()
+                .get("access-control-allow-credentials")
+                .unwrap(),
+            "true"
+        );
+    }
+}
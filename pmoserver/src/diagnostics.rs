@@ -0,0 +1,304 @@
+//! # Module Diagnostics - Autodiagnostic au démarrage
+//!
+//! Là où `/healthz` répond vite pour les orchestrateurs, les diagnostics
+//! cherchent les causes habituelles d'un serveur « invisible » ou bancal :
+//! pas de route multicast, port HTTP injoignable depuis l'adresse annoncée,
+//! dossiers de cache en lecture seule, horloge fantaisiste, resampler
+//! absent... Chaque vérification donne un état, un détail et, en cas de
+//! problème, un conseil.
+//!
+//! Les sous-systèmes enregistrent leurs vérifications via
+//! [`Server::register_diagnostic`](crate::Server::register_diagnostic). Le
+//! rapport est journalisé au démarrage sous forme d'un bloc concis et
+//! exposé en JSON sur `/debug/diagnostics`. Les vérifications peuvent faire
+//! de petites I/O (connexion locale, écriture d'un fichier témoin) : elles
+//! sont exécutées hors du runtime asynchrone.
+
+use axum::Json;
+use axum::extract::State;
+use serde::Serialize;
+use std::fmt;
+use std::io::ErrorKind;
+use std::net::{SocketAddr, TcpStream, ToSocketAddrs};
+use std::path::Path;
+use std::sync::{Arc, RwLock};
+use std::time::{Duration, SystemTime, UNIX_EPOCH};
+
+/// Délai de connexion de la vérification du port HTTP
+const CONNECT_TIMEOUT: Duration = Duration::from_secs(2);
+
+/// Tentatives de connexion en cas de refus, et délai entre deux tentatives
+const CONNECT_ATTEMPTS: u32 = 3;
+const RETRY_DELAY: Duration = Duration::from_millis(200);
+
+/// Date en deçà de laquelle l'horloge est manifestement fausse (2025-01-01)
+const MIN_PLAUSIBLE_EPOCH_SECS: u64 = 1_735_689_600;
+
+/// Résultat d'une vérification
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Serialize, utoipa::ToSchema)]
+#[serde(rename_all = "lowercase")]
+pub enum DiagnosticStatus {
+    /// Rien à signaler
+    Ok,
+    /// Fonctionne, mais probablement pas comme attendu
+    Warning,
+    /// Empêche une fonctionnalité de marcher
+    Error,
+}
+
+/// Résultat détaillé d'une vérification
+#[derive(Debug, Clone, PartialEq, Serialize, utoipa::ToSchema)]
+pub struct Diagnostic {
+    pub status: DiagnosticStatus,
+    /// Ce qui a été constaté
+    pub detail: String,
+    /// Que faire pour corriger le problème
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub advice: Option<String>,
+}
+
+impl Diagnostic {
+    pub fn ok(detail: impl Into<String>) -> Self {
+        Self {
+            status: DiagnosticStatus::Ok,
+            detail: detail.into(),
+            advice: None,
+        }
+    }
+
+    pub fn warning(detail: impl Into<String>, advice: impl Into<String>) -> Self {
+        Self {
+            status: DiagnosticStatus::Warning,
+            detail: detail.into(),
+            advice: Some(advice.into()),
+        }
+    }
+
+    pub fn error(detail: impl Into<String>, advice: impl Into<String>) -> Self {
+        Self {
+            status: DiagnosticStatus::Error,
+            detail: detail.into(),
+            advice: Some(advice.into()),
+        }
+    }
+}
+
+/// Vérification nommée du rapport
+#[derive(Debug, Clone, Serialize, utoipa::ToSchema)]
+pub struct DiagnosticEntry {
+    pub name: String,
+    #[serde(flatten)]
+    pub diagnostic: Diagnostic,
+}
+
+/// Rapport retourné par `/debug/diagnostics`
+#[derive(Debug, Clone, Serialize, utoipa::ToSchema)]
+pub struct DiagnosticsReport {
+    /// Pire état des vérifications
+    pub status: DiagnosticStatus,
+    /// Vérifications, dans l'ordre d'enregistrement
+    pub checks: Vec<DiagnosticEntry>,
+}
+
+impl DiagnosticsReport {
+    /// Rapport de vérifications déjà exécutées
+    pub fn new(checks: Vec<DiagnosticEntry>) -> Self {
+        let status = checks
+            .iter()
+            .map(|c| c.diagnostic.status)
+            .max()
+            .unwrap_or(DiagnosticStatus::Ok);
+        Self { status, checks }
+    }
+}
+
+impl fmt::Display for DiagnosticsReport {
+    /// Bloc concis, une ligne par vérification et une par conseil
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        let width = self.checks.iter().map(|c| c.name.len()).max().unwrap_or(0);
+        writeln!(f, "Diagnostics:")?;
+        for check in &self.checks {
+            let mark = match check.diagnostic.status {
+                DiagnosticStatus::Ok => "✅",
+                DiagnosticStatus::Warning => "⚠️ ",
+                DiagnosticStatus::Error => "❌",
+            };
+            writeln!(
+                f,
+                "  {} {:width$}  {}",
+                mark,
+                check.name,
+                check.diagnostic.detail,
+                width = width
+            )?;
+            if let Some(advice) = &check.diagnostic.advice {
+                writeln!(f, "     {:width$}  → {}", "", advice, width = width)?;
+            }
+        }
+        Ok(())
+    }
+}
+
+/// Fonction de vérification
+pub type DiagnosticCheck = Arc<dyn Fn() -> Diagnostic + Send + Sync>;
+
+/// Registre des vérifications de diagnostic
+#[derive(Clone, Default)]
+pub struct DiagnosticsRegistry {
+    checks: Arc<RwLock<Vec<(String, DiagnosticCheck)>>>,
+}
+
+impl DiagnosticsRegistry {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Enregistre (ou remplace) une vérification nommée
+    pub fn register<F>(&self, name: impl Into<String>, check: F)
+    where
+        F: Fn() -> Diagnostic + Send + Sync + 'static,
+    {
+        let name = name.into();
+        let mut checks = self.checks.write().unwrap();
+        match checks.iter_mut().find(|(n, _)| *n == name) {
+            Some(entry) => entry.1 = Arc::new(check),
+            None => checks.push((name, Arc::new(check))),
+        }
+    }
+
+    /// Exécute toutes les vérifications (bloquant)
+    pub fn run(&self) -> DiagnosticsReport {
+        let checks: Vec<(String, DiagnosticCheck)> = self.checks.read().unwrap().clone();
+        DiagnosticsReport::new(
+            checks
+                .into_iter()
+                .map(|(name, check)| DiagnosticEntry {
+                    name,
+                    diagnostic: check(),
+                })
+                .collect(),
+        )
+    }
+
+    /// Exécute toutes les vérifications hors du runtime asynchrone
+    pub async fn run_blocking(&self) -> DiagnosticsReport {
+        let registry = self.clone();
+        tokio::task::spawn_blocking(move || registry.run())
+            .await
+            .expect("diagnostic check panicked")
+    }
+}
+
+/// Handler pour l'endpoint /debug/diagnostics
+pub async fn get_diagnostics(
+    State(registry): State<DiagnosticsRegistry>,
+) -> Json<DiagnosticsReport> {
+    Json(registry.run_blocking().await)
+}
+
+/// Vérifie que l'horloge système est plausible
+///
+/// Une horloge restée en 1970 (carte sans RTC, NTP absent) casse TLS, les
+/// jetons des services de streaming et les dates des caches.
+pub fn check_clock() -> Diagnostic {
+    check_clock_at(SystemTime::now())
+}
+
+fn check_clock_at(now: SystemTime) -> Diagnostic {
+    let secs = now
+        .duration_since(UNIX_EPOCH)
+        .map(|d| d.as_secs())
+        .unwrap_or(0);
+    let now = chrono::DateTime::<chrono::Utc>::from(now);
+    if secs < MIN_PLAUSIBLE_EPOCH_SECS {
+        Diagnostic::error(
+            format!("system clock reads {}", now.format("%Y-%m-%d %H:%M:%S UTC")),
+            "enable time synchronisation (NTP, systemd-timesyncd or chrony)",
+        )
+    } else {
+        Diagnostic::ok(now.format("%Y-%m-%d %H:%M:%S UTC").to_string())
+    }
+}
+
+/// Vérifie qu'un dossier existe (ou peut être créé) et accepte l'écriture
+pub fn check_writable_dir(path: &Path) -> Diagnostic {
+    let probe = path.join(".pmomusic-write-test");
+    let result = std::fs::create_dir_all(path)
+        .and_then(|_| std::fs::write(&probe, b"ok"))
+        .and_then(|_| std::fs::remove_file(&probe));
+    match result {
+        Ok(()) => Diagnostic::ok(format!("{} is writable", path.display())),
+        Err(e) => Diagnostic::error(
+            format!("cannot write to {}: {}", path.display(), e),
+            "fix the directory permissions or point the cache to a writable location",
+        ),
+    }
+}
+
+/// Vérifie qu'une connexion TCP aboutit sur `host:port`
+///
+/// Utilisé pour le port HTTP annoncé aux points de contrôle : s'il est
+/// injoignable depuis l'adresse choisie, la découverte fonctionne mais
+/// aucune description ne peut être lue.
+pub fn check_tcp_reachable(host: &str, port: u16) -> Diagnostic {
+    let addrs: Vec<SocketAddr> = match (host, port).to_socket_addrs() {
+        Ok(addrs) => addrs.collect(),
+        Err(e) => {
+            return Diagnostic::error(
+                format!("cannot resolve {}: {}", host, e),
+                "set host.base_url to an address of this machine",
+            );
+        }
+    };
+    // Au démarrage, le socket d'écoute peut être ouvert juste après l'appel :
+    // un refus est retenté quelques fois avant de conclure
+    let mut last_error = None;
+    for attempt in 0..CONNECT_ATTEMPTS {
+        if attempt > 0 {
+            std::thread::sleep(RETRY_DELAY);
+        }
+        for addr in &addrs {
+            match TcpStream::connect_timeout(addr, CONNECT_TIMEOUT) {
+                Ok(_) => return Diagnostic::ok(format!("{} accepts connections", addr)),
+                Err(e) => last_error = Some((addr, e)),
+            }
+        }
+        if !matches!(&last_error, Some((_, e)) if e.kind() == ErrorKind::ConnectionRefused) {
+            break;
+        }
+    }
+    match last_error {
+        Some((addr, e)) => Diagnostic::error(
+            format!("{} is not reachable: {}", addr, e),
+            "check host.base_url and that no firewall blocks the HTTP port",
+        ),
+        None => Diagnostic::error(
+            format!("{} resolves to no address", host),
+            "set host.base_url to an address of this machine",
+        ),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_report_order_and_block() {
+        let registry = DiagnosticsRegistry::new();
+        registry.register("clock", || check_clock_at(UNIX_EPOCH));
+        registry.register("cache", || Diagnostic::ok("writable"));
+        registry.register("clock", || Diagnostic::ok("fine"));
+
+        let report = registry.run();
+        assert_eq!(report.status, DiagnosticStatus::Ok);
+        assert_eq!(report.checks[0].name, "clock");
+
+        registry.register("clock", || check_clock_at(UNIX_EPOCH));
+        let report = registry.run();
+        assert_eq!(report.status, DiagnosticStatus::Error);
+        let block = report.to_string();
+        assert!(block.contains("❌ clock  system clock reads 1970-01-01"));
+        assert!(block.contains("→ enable time synchronisation"));
+    }
+}
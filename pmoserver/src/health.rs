@@ -0,0 +1,207 @@
+//! # Module Health - Sonde de santé du serveur
+//!
+//! Ce module expose l'endpoint `/healthz` utilisé par les orchestrateurs de
+//! conteneurs (Docker, Kubernetes, ...) pour les sondes liveness/readiness.
+//!
+//! Chaque sous-système (SSDP, bases de données des caches, flux actifs, ...)
+//! enregistre une vérification nommée via [`Server::register_health_check`](crate::Server::register_health_check).
+//! Les vérifications sont évaluées à chaque requête et doivent donc rester
+//! peu coûteuses (pas d'I/O réseau).
+//!
+//! ## Codes de retour
+//!
+//! - `200 OK` si aucun composant n'est [`HealthStatus::Down`]
+//! - `503 Service Unavailable` sinon
+//!
+//! Un composant absent de cette instance (cache non enregistré, ...) est
+//! [`HealthStatus::Skipped`] et n'influe pas sur l'état global.
+
+use axum::Json;
+use axum::extract::State;
+use axum::http::StatusCode;
+use serde::Serialize;
+use std::collections::BTreeMap;
+use std::sync::{Arc, RwLock};
+
+/// État d'un composant
+#[derive(Debug, Clone, Copy, PartialEq, Eq, PartialOrd, Ord, Serialize, utoipa::ToSchema)]
+#[serde(rename_all = "lowercase")]
+pub enum HealthStatus {
+    /// Le composant n'est pas présent dans cette instance (non vérifié)
+    Skipped,
+    /// Le composant fonctionne normalement
+    Up,
+    /// Le composant fonctionne en mode dégradé (ou est désactivé)
+    Degraded,
+    /// Le composant est hors service
+    Down,
+}
+
+/// Résultat d'une vérification de santé
+#[derive(Debug, Clone, Serialize, utoipa::ToSchema)]
+pub struct ComponentHealth {
+    /// État du composant
+    pub status: HealthStatus,
+    /// Détail optionnel (message d'erreur, compteur, ...)
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub detail: Option<String>,
+}
+
+impl ComponentHealth {
+    pub fn up() -> Self {
+        Self {
+            status: HealthStatus::Up,
+            detail: None,
+        }
+    }
+
+    pub fn skipped(detail: impl Into<String>) -> Self {
+        Self {
+            status: HealthStatus::Skipped,
+            detail: Some(detail.into()),
+        }
+    }
+
+    pub fn degraded(detail: impl Into<String>) -> Self {
+        Self {
+            status: HealthStatus::Degraded,
+            detail: Some(detail.into()),
+        }
+    }
+
+    pub fn down(detail: impl Into<String>) -> Self {
+        Self {
+            status: HealthStatus::Down,
+            detail: Some(detail.into()),
+        }
+    }
+
+    /// Ajoute un détail à un résultat
+    pub fn with_detail(mut self, detail: impl Into<String>) -> Self {
+        self.detail = Some(detail.into());
+        self
+    }
+}
+
+/// Rapport complet retourné par `/healthz`
+#[derive(Debug, Clone, Serialize, utoipa::ToSchema)]
+pub struct HealthReport {
+    /// État global (le pire état des composants, au moins `up`)
+    pub status: HealthStatus,
+    /// État de chaque composant, trié par nom
+    pub components: BTreeMap<String, ComponentHealth>,
+}
+
+/// Fonction de vérification d'un composant
+pub type HealthCheck = Arc<dyn Fn() -> ComponentHealth + Send + Sync>;
+
+/// Registre des vérifications de santé
+#[derive(Clone, Default)]
+pub struct HealthRegistry {
+    checks: Arc<RwLock<BTreeMap<String, HealthCheck>>>,
+}
+
+impl HealthRegistry {
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Enregistre (ou remplace) une vérification nommée
+    pub fn register<F>(&self, name: impl Into<String>, check: F)
+    where
+        F: Fn() -> ComponentHealth + Send + Sync + 'static,
+    {
+        self.checks
+            .write()
+            .unwrap()
+            .insert(name.into(), Arc::new(check));
+    }
+
+    /// Supprime une vérification
+    pub fn unregister(&self, name: &str) {
+        self.checks.write().unwrap().remove(name);
+    }
+
+    /// Évalue toutes les vérifications
+    ///
+    /// Le composant `http` est toujours présent : si la requête aboutit,
+    /// le serveur HTTP répond.
+    pub fn report(&self) -> HealthReport {
+        // Copier les checks pour ne pas tenir le verrou pendant l'évaluation
+        let checks: Vec<(String, HealthCheck)> = self
+            .checks
+            .read()
+            .unwrap()
+            .iter()
+            .map(|(name, check)| (name.clone(), check.clone()))
+            .collect();
+
+        let mut components = BTreeMap::new();
+        components.insert("http".to_string(), ComponentHealth::up());
+        for (name, check) in checks {
+            components.insert(name, check());
+        }
+
+        let status = components
+            .values()
+            .map(|c| c.status)
+            .max()
+            .unwrap_or(HealthStatus::Up)
+            .max(HealthStatus::Up);
+
+        HealthReport { status, components }
+    }
+}
+
+/// Handler pour l'endpoint /healthz
+pub async fn get_health(
+    State(registry): State<HealthRegistry>,
+) -> (StatusCode, Json<HealthReport>) {
+    let report = registry.report();
+    let code = if report.status == HealthStatus::Down {
+        StatusCode::SERVICE_UNAVAILABLE
+    } else {
+        StatusCode::OK
+    };
+    (code, Json(report))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_report_worst_status() {
+        let registry = HealthRegistry::new();
+        assert_eq!(registry.report().status, HealthStatus::Up);
+
+        registry.register("ssdp", || ComponentHealth::degraded("disabled"));
+        assert_eq!(registry.report().status, HealthStatus::Degraded);
+
+        registry.register("cover_db", || ComponentHealth::down("locked"));
+        let report = registry.report();
+        assert_eq!(report.status, HealthStatus::Down);
+        assert_eq!(report.components.len(), 3);
+
+        registry.unregister("cover_db");
+        assert_eq!(registry.report().status, HealthStatus::Degraded);
+    }
+
+    #[test]
+    fn test_skipped_component_keeps_status() {
+        let registry = HealthRegistry::new();
+        registry.register("library_db", || {
+            ComponentHealth::skipped("cache not registered")
+        });
+        let report = registry.report();
+        assert_eq!(report.status, HealthStatus::Up);
+        assert_eq!(
+            report.components["library_db"].status,
+            HealthStatus::Skipped
+        );
+        assert_eq!(
+            serde_json::to_value(&report.components["library_db"]).unwrap()["status"],
+            "skipped"
+        );
+    }
+}
@@ -0,0 +1,148 @@
+//! Compatibilité HTTP/1.0 pour les renderers anciens
+//!
+//! Certains renderers parlent HTTP/1.0 et ne comprennent ni le transfert
+//! `chunked` ni `100-continue`. Pour ces clients, la couche :
+//!
+//! - retire l'en-tête `Expect` de la requête, pour que les handlers ne
+//!   l'interprètent pas ;
+//! - répond en HTTP/1.0 sans `Transfer-Encoding` : un flux de longueur
+//!   inconnue est délimité par la fermeture de la connexion ;
+//! - force `Connection: close`, y compris sur les flux audio qui annoncent
+//!   `keep-alive`.
+//!
+//! Un client est traité en HTTP/1.0 si sa requête est en HTTP/1.0 ou si son
+//! User-Agent contient un des fragments configurés (renderers qui annoncent
+//! HTTP/1.1 à tort).
+//!
+//! La réponse intermédiaire `100 Continue` n'est pas du ressort de ce
+//! middleware : hyper la gère au niveau de la connexion et l'envoie dès que
+//! le handler lit le corps d'une requête HTTP/1.1 portant
+//! `Expect: 100-continue`, avant tout retrait d'en-tête ici. Une vraie
+//! requête HTTP/1.0 n'en reçoit jamais (hyper ne l'envoie qu'en HTTP/1.1) ;
+//! un client reconnu par son seul User-Agent peut donc en recevoir une s'il
+//! envoie lui-même `Expect`.
+//!
+//! La couche est désactivée par défaut. Configuration :
+//!
+//! ```yaml
+//! host:
+//!   http10:
+//!     enabled: true
+//!     user_agents: ["Old-Renderer/1."]
+//! ```
+
+use axum::{
+    extract::Request,
+    http::{HeaderValue, Version, header},
+    middleware::Next,
+    response::Response,
+};
+use pmoconfig::get_config;
+use tracing::debug;
+
+/// Réglages de compatibilité HTTP/1.0
+#[derive(Debug, Clone, Default, PartialEq)]
+pub struct Http10Settings {
+    /// Fragments de User-Agent traités comme HTTP/1.0
+    pub user_agents: Vec<String>,
+}
+
+impl Http10Settings {
+    /// Lit les réglages depuis la configuration (None si désactivé)
+    pub fn from_config() -> Option<Self> {
+        let config = get_config();
+        if !config.get_http10_enabled().unwrap_or(false) {
+            return None;
+        }
+        Some(Self {
+            user_agents: config.get_http10_user_agents().unwrap_or_default(),
+        })
+    }
+
+    /// Indique si la requête vient d'un client HTTP/1.0
+    pub fn is_legacy(&self, req: &Request) -> bool {
+        if req.version() <= Version::HTTP_10 {
+            return true;
+        }
+        let Some(agent) = req
+            .headers()
+            .get(header::USER_AGENT)
+            .and_then(|v| v.to_str().ok())
+        else {
+            return false;
+        };
+        self.user_agents
+            .iter()
+            .any(|fragment| !fragment.is_empty() && agent.contains(fragment.as_str()))
+    }
+}
+
+/// Middleware appliquant le mode HTTP/1.0 aux clients concernés
+pub async fn http10_compat(settings: Http10Settings, mut req: Request, next: Next) -> Response {
+    if !settings.is_legacy(&req) {
+        return next.run(req).await;
+    }
+
+    let path = req.uri().path().to_string();
+    req.headers_mut().remove(header::EXPECT);
+
+    let mut response = next.run(req).await;
+    *response.version_mut() = Version::HTTP_10;
+    let headers = response.headers_mut();
+    headers.remove(header::TRANSFER_ENCODING);
+    headers.insert(header::CONNECTION, HeaderValue::from_static("close"));
+
+    debug!("HTTP/1.0 compatibility response for {}", path);
+    response
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use axum::{Router, body::Body, http, middleware, routing::get};
+    use tower::ServiceExt;
+
+    #[tokio::test]
+    async fn test_legacy_stream_is_unchunked() {
+        let settings = Http10Settings {
+            user_agents: vec!["OldBox".to_string()],
+        };
+        let app = Router::new()
+            .route(
+                "/stream",
+                get(|| async {
+                    (
+                        [
+                            (header::CONNECTION, "keep-alive"),
+                            (header::TRANSFER_ENCODING, "chunked"),
+                        ],
+                        "audio",
+                    )
+                }),
+            )
+            .layer(middleware::from_fn(move |req: Request, next: Next| {
+                http10_compat(settings.clone(), req, next)
+            }));
+
+        let legacy = http::Request::get("/stream")
+            .version(Version::HTTP_10)
+            .header(header::EXPECT, "100-continue")
+            .body(Body::empty())
+            .unwrap();
+        let response = app.clone().oneshot(legacy).await.unwrap();
+        assert_eq!(response.version(), Version::HTTP_10);
+        assert_eq!(response.headers()[header::CONNECTION], "close");
+        assert!(!response.headers().contains_key(header::TRANSFER_ENCODING));
+
+        let spoofed = http::Request::get("/stream")
+            .header(header::USER_AGENT, "OldBox/2.1 UPnP/1.0")
+            .body(Body::empty())
+            .unwrap();
+        let response = app.clone().oneshot(spoofed).await.unwrap();
+        assert_eq!(response.headers()[header::CONNECTION], "close");
+
+        let modern = http::Request::get("/stream").body(Body::empty()).unwrap();
+        let response = app.oneshot(modern).await.unwrap();
+        assert_eq!(response.headers()[header::CONNECTION], "keep-alive");
+    }
+}
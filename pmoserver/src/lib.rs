@@ -20,6 +20,12 @@
 //!
 //! - [`server`] : Implémentation du serveur principal et du builder
 //! - [`logs`] : Système de logs SSE pour monitoring en temps réel
+//! - [`health`] : Sonde `/healthz` pour les orchestrateurs de conteneurs
+//! - [`diagnostics`] : Autodiagnostic au démarrage et sur `/debug/diagnostics`
+//! - [`cors`] : Middleware CORS configurable pour les interfaces hébergées ailleurs
+//! - [`compression`] : Compression gzip/deflate des réponses XML et JSON
+//! - [`limits`] : Limites de taille, de durée et de concurrence des requêtes
+//! - [`remote`] : Accès distant authentifié en HTTPS, sans UPnP
 //!
 //! ## Exemple d'utilisation
 //!
@@ -68,16 +74,31 @@
 //! # }
 //! ```
 
+pub mod compression;
 pub mod config_ext;
+pub mod cors;
+pub mod diagnostics;
+pub mod health;
+pub mod http10;
+pub mod limits;
 pub mod logs;
+pub mod profile;
+pub mod remote;
 pub mod server;
 mod serve_embed;
 
 pub use config_ext::ConfigExt;
+pub use diagnostics::{
+    Diagnostic, DiagnosticEntry, DiagnosticStatus, DiagnosticsRegistry, DiagnosticsReport,
+};
+pub use health::{ComponentHealth, HealthRegistry, HealthReport, HealthStatus};
+pub use limits::RequestLimits;
 pub use logs::{
     LogState, LoggingOptions, LogsApiDoc, SseLayer, create_logs_router, init_logging, log_dump,
     log_setup_get, log_setup_post, log_sse,
 };
+pub use profile::Profile;
+pub use remote::{RemoteAccess, RemoteSettings};
 pub use server::{ApiRegistry, ApiRegistryEntry, Server, ServerBuilder, ServerInfo};
 
 // ============================================================================
@@ -140,11 +161,31 @@ static GLOBAL_SERVER: OnceCell<Arc<RwLock<Server>>> = OnceCell::new();
 /// }
 /// ```
 pub fn init_server() -> Arc<RwLock<Server>> {
+    init_server_with(ServerBuilder::new_configured)
+}
+
+/// Initialise le serveur global à partir d'un builder fourni par l'appelant
+///
+/// Comme [`init_server()`], seul le premier appel crée le serveur : `builder`
+/// n'est pas invoqué si le singleton existe déjà. Permet d'embarquer
+/// pmoserver avec son propre socket, router ou URL de base.
+///
+/// # Exemple
+///
+/// ```ignore
+/// use pmoserver::{init_server_with, ServerBuilder};
+///
+/// let listener = std::net::TcpListener::bind("0.0.0.0:0")?;
+/// let server = init_server_with(|| {
+///     ServerBuilder::new("MyApp", "http://192.168.1.10", 0).with_listener(listener)
+/// });
+/// ```
+pub fn init_server_with<F>(builder: F) -> Arc<RwLock<Server>>
+where
+    F: FnOnce() -> ServerBuilder,
+{
     GLOBAL_SERVER
-        .get_or_init(|| {
-            let server = ServerBuilder::new_configured().build();
-            Arc::new(RwLock::new(server))
-        })
+        .get_or_init(|| Arc::new(RwLock::new(builder().build())))
         .clone()
 }
 
@@ -0,0 +1,143 @@
+//! Limites des requêtes sur les endpoints exposés au LAN
+//!
+//! Les endpoints de contrôle SOAP et d'événements GENA acceptent des
+//! requêtes de n'importe quel hôte du réseau local. Un client défaillant
+//! (ou malveillant) ne doit pas pouvoir épuiser la mémoire avec un corps
+//! géant, ni monopoliser le serveur avec des requêtes qui traînent :
+//!
+//! - taille maximale du corps (413 au-delà) ;
+//! - durée maximale d'une requête, lecture du corps comprise (408) ;
+//! - nombre de requêtes traitées simultanément (503 + `Retry-After`).
+//!
+//! Le délai de lecture des en-têtes (slow-loris) est appliqué à toutes les
+//! connexions par [`Server::start`](crate::Server::start).
+//!
+//! Configuration :
+//!
+//! ```yaml
+//! host:
+//!   limits:
+//!     max_body_bytes: 262144
+//!     header_read_timeout_secs: 10
+//!     request_timeout_secs: 30
+//!     max_concurrent_requests: 64
+//! ```
+
+use axum::{
+    Router,
+    extract::{DefaultBodyLimit, Request},
+    http::{StatusCode, header},
+    middleware::{self, Next},
+    response::{IntoResponse, Response},
+};
+use once_cell::sync::Lazy;
+use pmoconfig::get_config;
+use std::sync::Arc;
+use std::time::Duration;
+use tokio::sync::Semaphore;
+use tracing::warn;
+
+/// Limites partagées par tous les endpoints de contrôle et d'événements
+static CONTROL_LIMITS: Lazy<RequestLimits> = Lazy::new(RequestLimits::from_config);
+
+/// Limites appliquées à un groupe de routes
+///
+/// Les routes protégées par une même instance partagent le même quota de
+/// requêtes simultanées.
+#[derive(Debug, Clone)]
+pub struct RequestLimits {
+    max_body_bytes: usize,
+    request_timeout: Duration,
+    permits: Arc<Semaphore>,
+}
+
+impl RequestLimits {
+    pub fn new(max_body_bytes: usize, request_timeout: Duration, max_concurrent: usize) -> Self {
+        Self {
+            max_body_bytes,
+            request_timeout,
+            permits: Arc::new(Semaphore::new(max_concurrent.max(1))),
+        }
+    }
+
+    /// Lit les limites depuis la configuration (`host.limits`)
+    pub fn from_config() -> Self {
+        let config = get_config();
+        Self::new(
+            config.get_limits_max_body_bytes().unwrap_or(256 * 1024),
+            Duration::from_secs(config.get_limits_request_timeout_secs().unwrap_or(30) as u64),
+            config.get_limits_max_concurrent_requests().unwrap_or(64),
+        )
+    }
+
+    /// Instance partagée par les endpoints SOAP et GENA
+    pub fn control() -> &'static RequestLimits {
+        &CONTROL_LIMITS
+    }
+
+    /// Applique les limites à toutes les routes du router
+    pub fn apply<S>(&self, router: Router<S>) -> Router<S>
+    where
+        S: Clone + Send + Sync + 'static,
+    {
+        let permits = self.permits.clone();
+        let timeout = self.request_timeout;
+        router
+            .layer(middleware::from_fn(move |req: Request, next: Next| {
+                limit_request(permits.clone(), timeout, req, next)
+            }))
+            .layer(DefaultBodyLimit::max(self.max_body_bytes))
+    }
+}
+
+async fn limit_request(
+    permits: Arc<Semaphore>,
+    timeout: Duration,
+    req: Request,
+    next: Next,
+) -> Response {
+    let Ok(_permit) = permits.try_acquire_owned() else {
+        warn!(
+            "Too many concurrent requests, rejecting {} {}",
+            req.method(),
+            req.uri().path()
+        );
+        return (StatusCode::SERVICE_UNAVAILABLE, [(header::RETRY_AFTER, "1")]).into_response();
+    };
+
+    let method = req.method().clone();
+    let path = req.uri().path().to_string();
+    match tokio::time::timeout(timeout, next.run(req)).await {
+        Ok(response) => response,
+        Err(_) => {
+            warn!("Request {} {} timed out after {:?}", method, path, timeout);
+            StatusCode::REQUEST_TIMEOUT.into_response()
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use axum::{body::Body, http, routing::post};
+    use tower::ServiceExt;
+
+    #[tokio::test]
+    async fn test_body_limit_and_concurrency() {
+        let limits = RequestLimits::new(16, Duration::from_secs(5), 1);
+        let app = limits.apply(Router::new().route("/", post(|body: String| async move { body })));
+
+        let small = http::Request::post("/").body(Body::from("ok")).unwrap();
+        let response = app.clone().oneshot(small).await.unwrap();
+        assert_eq!(response.status(), StatusCode::OK);
+
+        let big = http::Request::post("/").body(Body::from("x".repeat(1024))).unwrap();
+        let response = app.clone().oneshot(big).await.unwrap();
+        assert_eq!(response.status(), StatusCode::PAYLOAD_TOO_LARGE);
+
+        let _busy = limits.permits.clone().try_acquire_owned().unwrap();
+        let small = http::Request::post("/").body(Body::from("ok")).unwrap();
+        let response = app.oneshot(small).await.unwrap();
+        assert_eq!(response.status(), StatusCode::SERVICE_UNAVAILABLE);
+    }
+}
@@ -1,8 +1,10 @@
 // logs.rs
 mod sselayer;
+mod syslog;
 
 use pmoconfig::get_config;
 pub use sselayer::SseLayer;
+pub use syslog::SyslogLayer;
 
 use std::{
     collections::VecDeque,
@@ -331,7 +333,9 @@ pub fn init_logging() -> LogState {
     // L'ordre est important : le filtre doit être appliqué en premier
     let subscriber = Registry::default()
         .with(filter_layer)
-        .with(SseLayer::new(log_state.clone()));
+        .with(SseLayer::new(log_state.clone()))
+        .with(journald_layer(&config))
+        .with(syslog_layer(&config));
 
     let enable_console = match config.get_log_enable_console() {
         Ok(b) => b,
@@ -363,6 +367,81 @@ pub fn init_logging() -> LogState {
     log_state
 }
 
+/// Processus lancé par systemd avec sa sortie reliée au journal ?
+fn running_under_systemd() -> bool {
+    std::env::var_os("JOURNAL_STREAM").is_some() || std::env::var_os("INVOCATION_ID").is_some()
+}
+
+/// Sortie journald (`host.logger.journald`: auto / true / false).
+///
+/// La correspondance des priorités (ERROR=3 … TRACE=7) est assurée par
+/// `tracing-journald`.
+#[cfg(unix)]
+fn journald_layer(config: &pmoconfig::Config) -> Option<tracing_journald::Layer> {
+    let mode = config.get_log_journald().unwrap_or_else(|_| "auto".to_string());
+    let wanted = match mode.as_str() {
+        "true" | "on" | "yes" => true,
+        "auto" => running_under_systemd(),
+        _ => false,
+    };
+    if !wanted {
+        return None;
+    }
+
+    match tracing_journald::layer() {
+        Ok(layer) => {
+            eprintln!("ℹ️ Logging to journald");
+            Some(layer.with_syslog_identifier("pmomusic".to_string()))
+        }
+        Err(e) => {
+            eprintln!("⚠️ journald requested but unavailable: {}", e);
+            None
+        }
+    }
+}
+
+#[cfg(not(unix))]
+fn journald_layer(config: &pmoconfig::Config) -> Option<tracing_subscriber::layer::Identity> {
+    if config.get_log_journald().is_ok_and(|mode| mode == "true") {
+        eprintln!("⚠️ journald is only available on Linux, ignoring host.logger.journald");
+    }
+    None
+}
+
+/// Sortie syslog (`host.logger.syslog.*`)
+fn syslog_layer(config: &pmoconfig::Config) -> Option<SyslogLayer> {
+    if !config.get_log_syslog_enabled().unwrap_or(false) {
+        return None;
+    }
+
+    let facility_name = config
+        .get_log_syslog_facility()
+        .unwrap_or_else(|_| "daemon".to_string());
+    let facility = syslog::facility_code(&facility_name).unwrap_or_else(|| {
+        eprintln!(
+            "⚠️ Unknown syslog facility '{}', using 'daemon'",
+            facility_name
+        );
+        3
+    });
+    let address = config.get_log_syslog_address().unwrap_or_default();
+
+    match SyslogLayer::connect(&address, facility, "pmomusic") {
+        Ok(layer) => {
+            eprintln!(
+                "ℹ️ Logging to syslog ({}, facility {})",
+                if address.is_empty() { "local socket" } else { &address },
+                facility_name
+            );
+            Some(layer)
+        }
+        Err(e) => {
+            eprintln!("⚠️ Unable to connect to syslog: {}", e);
+            None
+        }
+    }
+}
+
 /// Request body pour la configuration du logging
 #[derive(Debug, Deserialize, utoipa::ToSchema)]
 pub struct LogSetupRequest {
@@ -5,12 +5,12 @@ use tracing_subscriber::{Layer, layer::Context};
 use super::{LogEntry, LogState};
 use std::time::SystemTime;
 
-struct LogVisitor {
-    message: String,
+pub(super) struct LogVisitor {
+    pub(super) message: String,
 }
 
 impl LogVisitor {
-    fn new() -> Self {
+    pub(super) fn new() -> Self {
         Self {
             message: String::new(),
         }
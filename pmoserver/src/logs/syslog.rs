@@ -0,0 +1,204 @@
+use tracing::{Event, Level, Subscriber};
+use tracing_subscriber::{Layer, layer::Context};
+
+use super::sselayer::LogVisitor;
+use std::net::{Ipv4Addr, Ipv6Addr, SocketAddr, ToSocketAddrs, UdpSocket};
+use std::sync::Mutex;
+
+/// Sévérité syslog (RFC 5424) correspondant à un niveau tracing
+pub fn severity(level: &Level) -> u8 {
+    match *level {
+        Level::ERROR => 3, // err
+        Level::WARN => 4,  // warning
+        Level::INFO => 6,  // info
+        Level::DEBUG | Level::TRACE => 7, // debug
+    }
+}
+
+/// Code de facility syslog à partir de son nom
+pub fn facility_code(name: &str) -> Option<u8> {
+    let code = match name {
+        "kern" => 0,
+        "user" => 1,
+        "mail" => 2,
+        "daemon" => 3,
+        "auth" => 4,
+        "syslog" => 5,
+        "lpr" => 6,
+        "news" => 7,
+        "uucp" => 8,
+        "cron" => 9,
+        "authpriv" => 10,
+        "ftp" => 11,
+        "local0" => 16,
+        "local1" => 17,
+        "local2" => 18,
+        "local3" => 19,
+        "local4" => 20,
+        "local5" => 21,
+        "local6" => 22,
+        "local7" => 23,
+        _ => return None,
+    };
+    Some(code)
+}
+
+enum Transport {
+    #[cfg(unix)]
+    Unix(std::os::unix::net::UnixDatagram),
+    Udp(UdpSocket),
+}
+
+/// Layer de tracing qui envoie les events à un démon syslog
+///
+/// Socket local : format court `<PRI>tag[pid]: message`, le démon ajoute
+/// l'horodatage et le nom d'hôte. UDP : format RFC 5424 avec ces champs
+/// laissés vides (`-`), renseignés par le récepteur.
+pub struct SyslogLayer {
+    transport: Mutex<Transport>,
+    facility: u8,
+    tag: String,
+    pid: u32,
+}
+
+impl SyslogLayer {
+    /// Connecte la sortie syslog
+    ///
+    /// `address` vide : socket local (`/dev/log`, ou `/var/run/syslog` sur
+    /// macOS). `udp://hôte:port` : démon distant. Sinon : chemin d'un socket
+    /// Unix.
+    pub fn connect(address: &str, facility: u8, tag: &str) -> std::io::Result<Self> {
+        let transport = if let Some(remote) = address.strip_prefix("udp://") {
+            Transport::Udp(Self::connect_udp(remote)?)
+        } else {
+            Self::connect_local(address)?
+        };
+
+        Ok(Self {
+            transport: Mutex::new(transport),
+            facility,
+            tag: tag.to_string(),
+            pid: std::process::id(),
+        })
+    }
+
+    /// Socket UDP connecté au démon distant, lié à l'adresse locale de même
+    /// famille (IPv4 ou IPv6) que l'adresse résolue
+    fn connect_udp(remote: &str) -> std::io::Result<UdpSocket> {
+        let mut last_error = None;
+        for addr in remote.to_socket_addrs()? {
+            let local: SocketAddr = match addr {
+                SocketAddr::V4(_) => (Ipv4Addr::UNSPECIFIED, 0).into(),
+                SocketAddr::V6(_) => (Ipv6Addr::UNSPECIFIED, 0).into(),
+            };
+            match UdpSocket::bind(local).and_then(|socket| {
+                socket.connect(addr)?;
+                Ok(socket)
+            }) {
+                Ok(socket) => return Ok(socket),
+                Err(e) => last_error = Some(e),
+            }
+        }
+        Err(last_error.unwrap_or_else(|| {
+            std::io::Error::new(
+                std::io::ErrorKind::InvalidInput,
+                format!("syslog address {} did not resolve", remote),
+            )
+        }))
+    }
+
+    #[cfg(unix)]
+    fn connect_local(address: &str) -> std::io::Result<Transport> {
+        use std::os::unix::net::UnixDatagram;
+
+        let candidates: Vec<&str> = if address.is_empty() {
+            vec!["/dev/log", "/var/run/syslog", "/var/run/log"]
+        } else {
+            vec![address]
+        };
+
+        let mut last_error = None;
+        for path in candidates {
+            let socket = UnixDatagram::unbound()?;
+            match socket.connect(path) {
+                Ok(()) => return Ok(Transport::Unix(socket)),
+                Err(e) => last_error = Some(e),
+            }
+        }
+        Err(last_error.unwrap_or_else(|| std::io::Error::other("no syslog socket found")))
+    }
+
+    #[cfg(not(unix))]
+    fn connect_local(_address: &str) -> std::io::Result<Transport> {
+        Err(std::io::Error::new(
+            std::io::ErrorKind::Unsupported,
+            "local syslog socket is only available on Unix, use udp://host:port",
+        ))
+    }
+
+    fn format(&self, level: &Level, target: &str, message: &str, rfc5424: bool) -> String {
+        let pri = self.facility as u16 * 8 + severity(level) as u16;
+        if rfc5424 {
+            format!(
+                "<{}>1 - - {} {} - - {}: {}",
+                pri, self.tag, self.pid, target, message
+            )
+        } else {
+            format!("<{}>{}[{}]: {}: {}", pri, self.tag, self.pid, target, message)
+        }
+    }
+}
+
+impl<S> Layer<S> for SyslogLayer
+where
+    S: Subscriber,
+{
+    fn on_event(&self, event: &Event<'_>, _ctx: Context<'_, S>) {
+        let mut visitor = LogVisitor::new();
+        event.record(&mut visitor);
+        let metadata = event.metadata();
+
+        let transport = self.transport.lock().unwrap();
+        // Les erreurs d'envoi sont ignorées : logger l'échec relancerait cette layer
+        let _ = match &*transport {
+            #[cfg(unix)]
+            Transport::Unix(socket) => {
+                let line = self.format(metadata.level(), metadata.target(), &visitor.message, false);
+                socket.send(line.as_bytes())
+            }
+            Transport::Udp(socket) => {
+                let line = self.format(metadata.level(), metadata.target(), &visitor.message, true);
+                socket.send(line.as_bytes())
+            }
+        };
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_priority_mapping() {
+        let daemon = facility_code("daemon").unwrap();
+        assert_eq!(daemon as u16 * 8 + severity(&Level::ERROR) as u16, 27);
+        assert_eq!(severity(&Level::WARN), 4);
+        assert_eq!(severity(&Level::TRACE), 7);
+        assert_eq!(facility_code("local7"), Some(23));
+        assert_eq!(facility_code("bogus"), None);
+    }
+
+    #[test]
+    fn test_udp_socket_follows_address_family() {
+        let socket = SyslogLayer::connect_udp("127.0.0.1:514").unwrap();
+        assert!(socket.local_addr().unwrap().is_ipv4());
+
+        // IPv6 peut être absent de l'environnement de test
+        if UdpSocket::bind("[::1]:0").is_ok() {
+            let socket = SyslogLayer::connect_udp("[::1]:514").unwrap();
+            assert!(socket.local_addr().unwrap().is_ipv6());
+        }
+
+        assert!(SyslogLayer::connect_udp("not an address").is_err());
+    }
+}
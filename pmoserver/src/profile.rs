@@ -0,0 +1,64 @@
+//! Profil d'écoute d'une requête HTTP
+//!
+//! Le client web envoie le profil choisi par l'utilisateur dans l'en-tête
+//! `X-PMO-Profile` (ou le paramètre `?profile=`, pratique pour les liens).
+//! Sans l'un ni l'autre, la requête est attribuée au profil par défaut
+//! (voir [`pmoconfig::profiles`]).
+//!
+//! ```rust,ignore
+//! use pmoserver::Profile;
+//!
+//! async fn list(Profile(profile): Profile) -> String {
+//!     format!("favoris de {profile}")
+//! }
+//! ```
+
+use axum::{
+    Json,
+    extract::{FromRequestParts, Query},
+    http::{StatusCode, request::Parts},
+    response::{IntoResponse, Response},
+};
+use pmoconfig::get_config;
+use std::collections::HashMap;
+
+/// En-tête portant le profil actif
+pub const PROFILE_HEADER: &str = "x-pmo-profile";
+
+/// Paramètre de requête portant le profil actif
+pub const PROFILE_QUERY_PARAM: &str = "profile";
+
+/// Profil résolu d'une requête (toujours un profil déclaré)
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Profile(pub String);
+
+impl<S> FromRequestParts<S> for Profile
+where
+    S: Send + Sync,
+{
+    type Rejection = Response;
+
+    async fn from_request_parts(parts: &mut Parts, _state: &S) -> Result<Self, Self::Rejection> {
+        let from_header = parts
+            .headers
+            .get(PROFILE_HEADER)
+            .and_then(|value| value.to_str().ok())
+            .map(str::to_string);
+        let requested = from_header.or_else(|| {
+            Query::<HashMap<String, String>>::try_from_uri(&parts.uri)
+                .ok()
+                .and_then(|Query(mut params)| params.remove(PROFILE_QUERY_PARAM))
+        });
+
+        get_config()
+            .resolve_profile(requested.as_deref())
+            .map(Profile)
+            .map_err(|e| {
+                (
+                    StatusCode::BAD_REQUEST,
+                    Json(serde_json::json!({ "error": e.to_string() })),
+                )
+                    .into_response()
+            })
+    }
+}
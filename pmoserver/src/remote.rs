@@ -0,0 +1,383 @@
+//! Accès distant authentifié
+//!
+//! Pour écouter sa bibliothèque hors de chez soi sans exposer UPnP, le
+//! serveur peut ouvrir un second port, en HTTPS uniquement, qui sert le même
+//! router que le port local avec trois restrictions :
+//!
+//! - seuls les chemins de `allowed_paths` sont servis (interface web, API
+//!   REST, flux transcodés, pochettes) ; les routes UPnP (`/device/...` :
+//!   descriptions, contrôle SOAP, événements GENA) ne le sont jamais, quelle
+//!   que soit la configuration ;
+//! - chaque requête doit présenter un jeton (`Authorization: Bearer`, ou
+//!   paramètre `?token=` pour les lecteurs qui ne savent pas ajouter
+//!   d'en-tête) ou le mot de passe (authentification Basic, nom
+//!   d'utilisateur libre) ;
+//! - les requêtes sont marquées par l'extension [`RemoteAccess`], que les
+//!   handlers consultent pour restreindre ce qu'ils servent (ex: le
+//!   transcodeur n'y sert que de l'Opus).
+//!
+//! SSDP et le port HTTP principal restent inchangés : ne rediriger vers
+//! l'extérieur (box, VPN) que le port distant.
+//!
+//! Configuration :
+//!
+//! ```yaml
+//! host:
+//!   remote:
+//!     enabled: true
+//!     port: 8443
+//!     tls_cert: "/etc/pmomusic/fullchain.pem"
+//!     tls_key: "/etc/pmomusic/privkey.pem"
+//!     allowed_paths: ["/", "/app", "/api/", "/transcode", "/covers/"]
+//! secrets:
+//!   remote:
+//!     token: "env:PMOMUSIC_REMOTE_TOKEN"
+//!     password: "..."
+//! ```
+//!
+//! Dans `allowed_paths`, une entrée terminée par `/` est un préfixe, les
+//! autres couvrent le chemin exact et ses sous-chemins (`/` ne couvre que la
+//! racine).
+
+use axum::{
+    Router,
+    extract::Request,
+    http::{HeaderMap, StatusCode, header},
+    middleware::{self, Next},
+    response::{IntoResponse, Response},
+};
+use axum_server::{Handle, tls_rustls::RustlsConfig};
+use base64::{Engine as _, engine::general_purpose::STANDARD};
+use pmoconfig::get_config;
+use std::net::SocketAddr;
+use std::path::PathBuf;
+use std::sync::Arc;
+use std::time::Duration;
+use tokio_util::sync::CancellationToken;
+use tracing::{error, info, warn};
+
+/// Préfixes UPnP jamais servis à distance
+const UPNP_PREFIXES: &[&str] = &["/device/", "/service/"];
+
+/// Délai avant de répondre à une authentification refusée (force brute)
+const AUTH_FAILURE_DELAY: Duration = Duration::from_millis(500);
+
+/// Marqueur des requêtes reçues par l'accès distant
+///
+/// Présent dans les extensions de la requête ; un handler l'obtient avec
+/// `Option<Extension<RemoteAccess>>`.
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct RemoteAccess;
+
+/// Réglages de l'accès distant
+#[derive(Clone)]
+pub struct RemoteSettings {
+    pub port: u16,
+    pub tls_cert: PathBuf,
+    pub tls_key: PathBuf,
+    pub allowed_paths: Vec<String>,
+    token: Option<String>,
+    password: Option<String>,
+}
+
+impl std::fmt::Debug for RemoteSettings {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        f.debug_struct("RemoteSettings")
+            .field("port", &self.port)
+            .field("tls_cert", &self.tls_cert)
+            .field("tls_key", &self.tls_key)
+            .field("allowed_paths", &self.allowed_paths)
+            .field("token", &self.token.as_ref().map(|_| "***"))
+            .field("password", &self.password.as_ref().map(|_| "***"))
+            .finish()
+    }
+}
+
+impl RemoteSettings {
+    pub fn new(
+        port: u16,
+        tls_cert: impl Into<PathBuf>,
+        tls_key: impl Into<PathBuf>,
+        allowed_paths: Vec<String>,
+        token: Option<String>,
+        password: Option<String>,
+    ) -> Self {
+        Self {
+            port,
+            tls_cert: tls_cert.into(),
+            tls_key: tls_key.into(),
+            allowed_paths,
+            token: token.filter(|t| !t.is_empty()),
+            password: password.filter(|p| !p.is_empty()),
+        }
+    }
+
+    /// Lit les réglages depuis la configuration (`host.remote` et
+    /// `secrets.remote`)
+    ///
+    /// Retourne `None` si l'accès distant est désactivé, ou s'il lui manque
+    /// des identifiants ou un certificat : il n'est jamais ouvert sans
+    /// authentification ni sans TLS.
+    pub fn from_config() -> Option<Self> {
+        let config = get_config();
+        if !config.get_remote_enabled().unwrap_or(false) {
+            return None;
+        }
+
+        let token = config.get_secret(&["remote", "token"]).ok().flatten();
+        let password = config.get_secret(&["remote", "password"]).ok().flatten();
+        let tls_cert = config.get_remote_tls_cert().unwrap_or_default();
+        let tls_key = config.get_remote_tls_key().unwrap_or_default();
+        let port = config.get_remote_port().unwrap_or(8443);
+
+        let settings = Self::new(
+            u16::try_from(port).unwrap_or(0),
+            tls_cert,
+            tls_key,
+            config.get_remote_allowed_paths().unwrap_or_default(),
+            token,
+            password,
+        );
+
+        if settings.token.is_none() && settings.password.is_none() {
+            warn!(
+                "🌍 Remote access enabled without credentials (secrets.remote.token or \
+                 secrets.remote.password): not started"
+            );
+            return None;
+        }
+        if settings.tls_cert.as_os_str().is_empty() || settings.tls_key.as_os_str().is_empty() {
+            warn!("🌍 Remote access requires host.remote.tls_cert and tls_key: not started");
+            return None;
+        }
+        if settings.port == 0 {
+            warn!(
+                "🌍 Invalid host.remote.port {}: remote access not started",
+                port
+            );
+            return None;
+        }
+        Some(settings)
+    }
+
+    /// Indique si `path` peut être servi à distance
+    pub fn is_path_allowed(&self, path: &str) -> bool {
+        if UPNP_PREFIXES.iter().any(|prefix| path.starts_with(prefix)) {
+            return false;
+        }
+        self.allowed_paths.iter().any(|allowed| {
+            if allowed == "/" {
+                path == "/"
+            } else if allowed.ends_with('/') {
+                path.starts_with(allowed.as_str())
+            } else {
+                path.strip_prefix(allowed.as_str())
+                    .is_some_and(|rest| rest.is_empty() || rest.starts_with('/'))
+            }
+        })
+    }
+
+    /// Vérifie les identifiants présentés par la requête
+    pub fn is_authorized(&self, headers: &HeaderMap, query: Option<&str>) -> bool {
+        let authorization = headers
+            .get(header::AUTHORIZATION)
+            .and_then(|v| v.to_str().ok())
+            .map(str::trim);
+
+        if let (Some(token), Some(value)) = (&self.token, authorization) {
+            if let Some(presented) = strip_scheme(value, "Bearer") {
+                if constant_time_eq(presented.as_bytes(), token.as_bytes()) {
+                    return true;
+                }
+            }
+        }
+
+        if let (Some(password), Some(value)) = (&self.password, authorization) {
+            let presented = strip_scheme(value, "Basic")
+                .and_then(|encoded| STANDARD.decode(encoded).ok())
+                .and_then(|decoded| String::from_utf8(decoded).ok());
+            if let Some((_, presented)) = presented.as_deref().and_then(|c| c.split_once(':')) {
+                if constant_time_eq(presented.as_bytes(), password.as_bytes()) {
+                    return true;
+                }
+            }
+        }
+
+        if let (Some(token), Some(query)) = (&self.token, query) {
+            let presented = query
+                .split('&')
+                .find_map(|pair| pair.strip_prefix("token="));
+            if presented.is_some_and(|p| constant_time_eq(p.as_bytes(), token.as_bytes())) {
+                return true;
+            }
+        }
+
+        false
+    }
+
+    /// Applique le filtrage et l'authentification à toutes les routes
+    pub fn apply(self: &Arc<Self>, router: Router) -> Router {
+        let settings = self.clone();
+        router.layer(middleware::from_fn(move |req: Request, next: Next| {
+            remote_guard(settings.clone(), req, next)
+        }))
+    }
+}
+
+/// Retire le schéma d'authentification (`Bearer`, `Basic`) d'un en-tête
+fn strip_scheme<'a>(value: &'a str, scheme: &str) -> Option<&'a str> {
+    let (presented, rest) = value.split_once(' ')?;
+    presented.eq_ignore_ascii_case(scheme).then(|| rest.trim())
+}
+
+/// Comparaison en temps constant (à longueur égale)
+fn constant_time_eq(a: &[u8], b: &[u8]) -> bool {
+    a.len() == b.len() && a.iter().zip(b).fold(0u8, |acc, (x, y)| acc | (x ^ y)) == 0
+}
+
+async fn remote_guard(settings: Arc<RemoteSettings>, mut req: Request, next: Next) -> Response {
+    let path = req.uri().path();
+    if !settings.is_path_allowed(path) {
+        warn!(
+            "🌍 Remote request to {} refused (not exposed remotely)",
+            path
+        );
+        return StatusCode::NOT_FOUND.into_response();
+    }
+
+    if !settings.is_authorized(req.headers(), req.uri().query()) {
+        warn!(
+            "🌍 Remote request to {} refused (authentication failed)",
+            path
+        );
+        tokio::time::sleep(AUTH_FAILURE_DELAY).await;
+        return (
+            StatusCode::UNAUTHORIZED,
+            [(header::WWW_AUTHENTICATE, "Basic realm=\"PMOMusic\"")],
+        )
+            .into_response();
+    }
+
+    req.extensions_mut().insert(RemoteAccess);
+    next.run(req).await
+}
+
+/// Sert `router` en HTTPS sur le port distant jusqu'à l'annulation de
+/// `shutdown`
+pub async fn serve_remote(
+    settings: Arc<RemoteSettings>,
+    router: Router,
+    shutdown: CancellationToken,
+) {
+    let tls = match RustlsConfig::from_pem_file(&settings.tls_cert, &settings.tls_key).await {
+        Ok(tls) => tls,
+        Err(e) => {
+            error!(
+                "🌍 Remote access not started, cannot load TLS certificate: {}",
+                e
+            );
+            return;
+        }
+    };
+
+    let handle = Handle::new();
+    let shutdown_handle = handle.clone();
+    tokio::spawn(async move {
+        shutdown.cancelled().await;
+        shutdown_handle.graceful_shutdown(Some(Duration::from_secs(5)));
+    });
+
+    let addr = SocketAddr::from(([0, 0, 0, 0], settings.port));
+    info!("🌍 Remote access listening on https://{}", addr);
+    let app = settings.apply(router);
+    if let Err(e) = axum_server::bind_rustls(addr, tls)
+        .handle(handle)
+        .serve(app.into_make_service_with_connect_info::<SocketAddr>())
+        .await
+    {
+        error!("🌍 Remote access stopped: {}", e);
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use axum::{body::Body, http, routing::get};
+    use tower::ServiceExt;
+
+    fn settings() -> Arc<RemoteSettings> {
+        Arc::new(RemoteSettings::new(
+            8443,
+            "cert.pem",
+            "key.pem",
+            vec!["/app".into(), "/api/".into(), "/device/".into()],
+            Some("s3cret".into()),
+            Some("hunter2".into()),
+        ))
+    }
+
+    async fn status(app: &Router, uri: &str, authorization: Option<&str>) -> StatusCode {
+        let mut request = http::Request::get(uri);
+        if let Some(value) = authorization {
+            request = request.header(header::AUTHORIZATION, value);
+        }
+        let response = app
+            .clone()
+            .oneshot(request.body(Body::empty()).unwrap())
+            .await
+            .unwrap();
+        response.status()
+    }
+
+    #[tokio::test]
+    async fn test_remote_guard() {
+        let app = settings().apply(
+            Router::new()
+                .route("/api/library", get(|| async { "ok" }))
+                .route("/device/x/desc.xml", get(|| async { "<root/>" }))
+                .route("/secret", get(|| async { "no" })),
+        );
+        let basic = format!("Basic {}", STANDARD.encode("anyone:hunter2"));
+
+        assert_eq!(
+            status(&app, "/api/library", None).await,
+            StatusCode::UNAUTHORIZED
+        );
+        assert_eq!(
+            status(&app, "/api/library", Some("Bearer nope")).await,
+            StatusCode::UNAUTHORIZED
+        );
+        assert_eq!(
+            status(&app, "/api/library", Some("Bearer s3cret")).await,
+            StatusCode::OK
+        );
+        assert_eq!(
+            status(&app, "/api/library", Some(&basic)).await,
+            StatusCode::OK
+        );
+        assert_eq!(
+            status(&app, "/api/library?token=s3cret", None).await,
+            StatusCode::OK
+        );
+
+        // UPnP reste local, même listé dans allowed_paths
+        assert_eq!(
+            status(&app, "/device/x/desc.xml", Some("Bearer s3cret")).await,
+            StatusCode::NOT_FOUND
+        );
+        assert_eq!(
+            status(&app, "/secret", Some("Bearer s3cret")).await,
+            StatusCode::NOT_FOUND
+        );
+    }
+
+    #[test]
+    fn test_allowed_paths() {
+        let settings = settings();
+        assert!(settings.is_path_allowed("/app"));
+        assert!(settings.is_path_allowed("/app/library"));
+        assert!(!settings.is_path_allowed("/apple"));
+        assert!(!settings.is_path_allowed("/"));
+        assert!(!settings.is_path_allowed("/service/AVTransport/control"));
+    }
+}
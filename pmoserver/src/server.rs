@@ -13,12 +13,18 @@
 //! - 📚 **Documentation API** : OpenAPI/Swagger automatique avec `add_openapi()`
 //! - ⚡ **Gestion gracieuse** : Arrêt propre sur Ctrl+C
 
+use crate::diagnostics::{Diagnostic, DiagnosticsRegistry, get_diagnostics};
+use crate::health::{ComponentHealth, HealthRegistry, get_health};
 use crate::logs::{LogState, init_logging, log_dump, log_sse};
 use axum::extract::State;
 use axum::handler::Handler;
 use axum::response::Redirect;
 use axum::routing::{any, get, post};
 use axum::{Json, Router};
+use hyper_util::rt::{TokioExecutor, TokioIo, TokioTimer};
+use hyper_util::server::conn::auto;
+use hyper_util::server::graceful::GracefulShutdown;
+use hyper_util::service::TowerToHyperService;
 use crate::serve_embed::ServeEmbed;
 use pmoconfig::get_config;
 use rust_embed::RustEmbed;
@@ -28,7 +34,7 @@ use std::net::SocketAddr;
 use std::sync::Arc;
 use tokio::{signal, sync::RwLock, task::JoinHandle};
 use tokio_util::sync::CancellationToken;
-use tracing::{error, info, warn};
+use tracing::{debug, error, info, warn};
 use utoipa::OpenApi;
 use utoipa_swagger_ui::SwaggerUi;
 
@@ -72,6 +78,9 @@ pub struct ApiRegistry {
 
 type ApiRegistryState = Arc<RwLock<Vec<ApiRegistryEntry>>>;
 
+/// Spécification OpenAPI fusionnée de toutes les APIs enregistrées
+type ApiDocState = Arc<RwLock<utoipa::openapi::OpenApi>>;
+
 /// Handler pour l'endpoint /api/registry
 async fn get_api_registry(State(registry): State<ApiRegistryState>) -> Json<ApiRegistry> {
     let apis = registry.read().await.clone();
@@ -83,6 +92,88 @@ async fn get_api_registry(State(registry): State<ApiRegistryState>) -> Json<ApiR
     })
 }
 
+/// Handler pour l'endpoint /api/openapi.json
+async fn get_openapi_json(State(doc): State<ApiDocState>) -> Json<utoipa::openapi::OpenApi> {
+    Json(doc.read().await.clone())
+}
+
+/// Boucle d'acceptation HTTP
+///
+/// Équivalent de `axum::serve`, avec un délai maximal de réception des
+/// en-têtes : une connexion qui les envoie au compte-gouttes (slow-loris)
+/// est fermée au lieu d'occuper le serveur indéfiniment.
+async fn serve_with_header_timeout(
+    listener: tokio::net::TcpListener,
+    router: Router,
+    header_read_timeout: std::time::Duration,
+    shutdown: impl Future<Output = ()>,
+) -> std::io::Result<()> {
+    let mut builder = auto::Builder::new(TokioExecutor::new());
+    builder
+        .http1()
+        .timer(TokioTimer::new())
+        .header_read_timeout(header_read_timeout);
+    // WebSockets sur HTTP/2, comme axum::serve
+    builder.http2().enable_connect_protocol();
+
+    let graceful = GracefulShutdown::new();
+    tokio::pin!(shutdown);
+
+    loop {
+        tokio::select! {
+            accepted = listener.accept() => {
+                let (stream, remote) = match accepted {
+                    Ok(accepted) => accepted,
+                    Err(e) => {
+                        // EMFILE & co : on laisse au système le temps de respirer
+                        warn!("Failed to accept HTTP connection: {}", e);
+                        tokio::time::sleep(std::time::Duration::from_millis(100)).await;
+                        continue;
+                    }
+                };
+                // Clients IPv4 d'un socket double pile : ::ffff:a.b.c.d -> a.b.c.d
+                let remote = SocketAddr::new(remote.ip().to_canonical(), remote.port());
+                // Adresse du client disponible via l'extracteur ConnectInfo<SocketAddr>
+                let service = TowerToHyperService::new(
+                    router
+                        .clone()
+                        .layer(axum::Extension(axum::extract::ConnectInfo(remote))),
+                );
+                let connection = builder
+                    .serve_connection_with_upgrades(TokioIo::new(stream), service)
+                    .into_owned();
+                let connection = graceful.watch(connection);
+                tokio::spawn(async move {
+                    if let Err(e) = connection.await {
+                        debug!("HTTP connection from {} closed: {}", remote, e);
+                    }
+                });
+            }
+            _ = &mut shutdown => break,
+        }
+    }
+
+    drop(listener);
+    graceful.shutdown().await;
+    Ok(())
+}
+
+/// Écoute sur `[::]` en acceptant aussi les clients IPv4
+///
+/// `IPV6_V6ONLY` est désactivé explicitement : Windows et certains réglages
+/// système l'activent par défaut, ce qui couperait l'accès en IPv4.
+fn bind_dual_stack(addr: SocketAddr) -> std::io::Result<std::net::TcpListener> {
+    use socket2::{Domain, Protocol, Socket, Type};
+
+    let socket = Socket::new(Domain::IPV6, Type::STREAM, Some(Protocol::TCP))?;
+    socket.set_only_v6(false)?;
+    socket.set_reuse_address(true)?;
+    socket.bind(&addr.into())?;
+    socket.listen(1024)?;
+    socket.set_nonblocking(true)?;
+    Ok(socket.into())
+}
+
 /// Serveur principal
 pub struct Server {
     name: String,
@@ -93,7 +184,12 @@ pub struct Server {
     join_handle: Option<JoinHandle<()>>,
     log_state: Option<LogState>,
     api_registry: ApiRegistryState,
+    api_doc: ApiDocState,
+    health: HealthRegistry,
+    diagnostics: DiagnosticsRegistry,
     shutdown_token: CancellationToken,
+    /// Socket d'écoute fourni par l'appelant (sinon bind sur `http_port`)
+    listener: Option<std::net::TcpListener>,
 }
 
 impl Server {
@@ -112,18 +208,40 @@ impl Server {
     /// let server = Server::new("MyAPI", "http://localhost:3000", 3000);
     /// ```
     pub fn new(name: impl Into<String>, base_url: impl Into<String>, http_port: u16) -> Self {
+        let name = name.into();
         let api_registry = Arc::new(RwLock::new(Vec::new()));
+        let api_doc = Arc::new(RwLock::new(utoipa::openapi::OpenApi::new(
+            utoipa::openapi::Info::new(name.clone(), env!("CARGO_PKG_VERSION")),
+            utoipa::openapi::Paths::new(),
+        )));
+        let health = HealthRegistry::new();
+        let diagnostics = DiagnosticsRegistry::new();
 
         let base_url = base_url.into();
 
-        // Créer le router initial avec l'endpoint de registre
+        // Créer le router initial avec l'endpoint de registre et la sonde de santé
         // Note: le base_url_layer est appliqué plus tard via le fallback dynamique
         let registry_route = Router::new()
             .route("/api/registry", get(get_api_registry))
-            .with_state(api_registry.clone());
+            .with_state(api_registry.clone())
+            .merge(
+                Router::new()
+                    .route("/api/openapi.json", get(get_openapi_json))
+                    .with_state(api_doc.clone()),
+            )
+            .merge(
+                Router::new()
+                    .route("/healthz", get(get_health))
+                    .with_state(health.clone()),
+            )
+            .merge(
+                Router::new()
+                    .route("/debug/diagnostics", get(get_diagnostics))
+                    .with_state(diagnostics.clone()),
+            );
 
         let server = Self {
-            name: name.into(),
+            name,
             base_url,
             http_port,
             router: Arc::new(RwLock::new(registry_route)),
@@ -131,8 +249,13 @@ impl Server {
             join_handle: None,
             log_state: None,
             api_registry,
+            api_doc,
+            health,
+            diagnostics,
             shutdown_token: CancellationToken::new(),
+            listener: None,
         };
+        server.register_builtin_diagnostics();
 
         // Initialiser PMO_SERVER_URL avec l'URL complète (incluant le port).
         // base_url() normalise l'URL en ajoutant le port si absent.
@@ -157,6 +280,79 @@ impl Server {
         self.shutdown_token.clone()
     }
 
+    /// Enregistre une vérification de santé exposée par `/healthz`
+    ///
+    /// La closure est appelée à chaque requête sur `/healthz` et doit rester
+    /// rapide. Un composant `Down` fait répondre la sonde en 503.
+    ///
+    /// # Exemple
+    ///
+    /// ```rust,ignore
+    /// # use pmoserver::{Server, health::ComponentHealth};
+    /// # let server = Server::new("Test", "http://localhost:3000", 3000);
+    /// server.register_health_check("library_db", || ComponentHealth::up());
+    /// ```
+    pub fn register_health_check<F>(&self, name: &str, check: F)
+    where
+        F: Fn() -> ComponentHealth + Send + Sync + 'static,
+    {
+        self.health.register(name, check);
+    }
+
+    /// Retourne le registre des vérifications de santé
+    pub fn health(&self) -> HealthRegistry {
+        self.health.clone()
+    }
+
+    /// Enregistre une vérification exposée par `/debug/diagnostics`
+    ///
+    /// Contrairement aux sondes de santé, la vérification peut faire de
+    /// petites I/O : elle est exécutée hors du runtime asynchrone, au
+    /// démarrage et à chaque requête sur `/debug/diagnostics`.
+    ///
+    /// # Exemple
+    ///
+    /// ```rust,ignore
+    /// # use pmoserver::{Server, diagnostics};
+    /// # let server = Server::new("Test", "http://localhost:3000", 3000);
+    /// server.register_diagnostic("covers_dir", || {
+    ///     diagnostics::check_writable_dir("/var/cache/pmomusic/covers".as_ref())
+    /// });
+    /// ```
+    pub fn register_diagnostic<F>(&self, name: &str, check: F)
+    where
+        F: Fn() -> Diagnostic + Send + Sync + 'static,
+    {
+        self.diagnostics.register(name, check);
+    }
+
+    /// Retourne le registre des diagnostics
+    pub fn diagnostics(&self) -> DiagnosticsRegistry {
+        self.diagnostics.clone()
+    }
+
+    /// Vérifications propres au serveur HTTP : horloge et port HTTP joignable
+    /// depuis l'adresse annoncée
+    fn register_builtin_diagnostics(&self) {
+        self.register_diagnostic("clock", crate::diagnostics::check_clock);
+
+        let base_url = self.base_url();
+        let http_port = self.http_port;
+        self.register_diagnostic("http_port", move || {
+            let uri: axum::http::Uri = match base_url.parse() {
+                Ok(uri) => uri,
+                Err(e) => {
+                    return Diagnostic::error(
+                        format!("invalid base URL {}: {}", base_url, e),
+                        "fix host.base_url",
+                    );
+                }
+            };
+            let host = uri.host().unwrap_or("127.0.0.1").trim_matches(['[', ']']);
+            crate::diagnostics::check_tcp_reachable(host, uri.port_u16().unwrap_or(http_port))
+        });
+    }
+
     /// Ajoute une route JSON dynamique
     ///
     /// Crée un endpoint qui retourne du JSON. La closure fournie sera appelée
@@ -457,6 +653,7 @@ impl Server {
     /// - `/api/api1/users` et `/api/api2/products` sont accessibles via Axum.
     /// - `/swagger-ui/api1` et `/swagger-ui/api2` affichent la documentation Swagger correspondante.
     /// - `/api-docs/api1.json` et `/api-docs/api2.json` fournissent les spécifications OpenAPI respectives.
+    /// - `/api/openapi.json` fournit la spécification fusionnée de toutes les APIs.
     pub async fn add_openapi(
         &mut self,
         api_router: Router,
@@ -498,9 +695,15 @@ impl Server {
         registry.push(registry_entry);
         drop(registry);
 
+        // Ajouter les chemins à la spécification globale (/api/openapi.json)
+        let base_path = format!("/api/{}", name);
+        let mut doc = self.api_doc.write().await;
+        *doc = std::mem::replace(&mut *doc, utoipa::openapi::OpenApi::default())
+            .nest(&base_path, openapi.clone());
+        drop(doc);
+
         let swagger = SwaggerUi::new(swagger_path_static).url(openapi_json_path_static, openapi);
 
-        let base_path = format!("/api/{}", name);
         let nested_router = Router::new().nest(&base_path, api_router);
 
         let mut r = self.router.write().await;
@@ -528,7 +731,8 @@ impl Server {
     /// Démarre le serveur HTTP
     ///
     /// Lance le serveur sur le port configuré et met en place la gestion
-    /// de Ctrl+C pour un arrêt gracieux.
+    /// de Ctrl+C pour un arrêt gracieux. Ouvre aussi l'accès distant si
+    /// `host.remote` est configuré (voir [`crate::remote`]).
     ///
     /// # Exemple
     ///
@@ -542,21 +746,40 @@ impl Server {
     /// # }
     /// ```
     pub async fn start(&mut self) {
-        let addr = SocketAddr::from(([0, 0, 0, 0], self.http_port));
+        let ipv6 = get_config().get_ipv6_enabled().unwrap_or(false);
+        let addr = if ipv6 {
+            SocketAddr::from(([0u16; 8], self.http_port))
+        } else {
+            SocketAddr::from(([0, 0, 0, 0], self.http_port))
+        };
         info!(
             "Server {} running at [http://{}:{}](http://{}:{})",
             self.name, self.base_url, self.http_port, self.base_url, self.http_port
         );
 
-        let router = self.router.clone();
         let shutdown_token = self.shutdown_token.clone();
+        let dynamic_router = self.embedded_router();
+        let provided_listener = self.listener.take();
+        let header_read_timeout = std::time::Duration::from_secs(
+            get_config()
+                .get_limits_header_read_timeout_secs()
+                .unwrap_or(10) as u64,
+        );
 
         // Créer un channel pour signaler l'arrêt gracieux
         let (shutdown_tx, shutdown_rx) = tokio::sync::oneshot::channel::<()>();
 
         self.join_handle = Some(tokio::spawn(async move {
             let server_future = async {
-                let listener = match tokio::net::TcpListener::bind(addr).await {
+                let bound = match provided_listener {
+                    Some(listener) => listener
+                        .set_nonblocking(true)
+                        .and_then(|_| tokio::net::TcpListener::from_std(listener)),
+                    None if ipv6 => bind_dual_stack(addr)
+                        .and_then(tokio::net::TcpListener::from_std),
+                    None => tokio::net::TcpListener::bind(addr).await,
+                };
+                let listener = match bound {
                     Ok(l) => l,
                     Err(e) => {
                         error!("Failed to bind to {}: {}", addr, e);
@@ -564,29 +787,19 @@ impl Server {
                     }
                 };
 
-                // Utiliser un router dynamique qui relit le router à chaque requête.
-                // Cela permet d'enregistrer de nouvelles routes après le démarrage du serveur
-                // (ex: WebRenderer dynamique).
-                let dynamic_router = axum::Router::new().fallback(move |req: axum::extract::Request| {
-                    let router = router.clone();
-                    async move {
-                        use tower::ServiceExt;
-                        let r = router.read().await.clone();
-                        r.into_service::<axum::body::Body>().oneshot(req).await
-                    }
-                });
-
-                axum::serve(listener, dynamic_router.into_make_service())
-                    .with_graceful_shutdown(async move {
-                        let _ = shutdown_rx.await;
-                    })
-                    .await
+                serve_with_header_timeout(listener, dynamic_router, header_read_timeout, async move {
+                    let _ = shutdown_rx.await;
+                })
+                .await
             };
 
             tokio::pin!(server_future);
             let ctrl_c = signal::ctrl_c();
             tokio::pin!(ctrl_c);
 
+            // Arrêt sur Ctrl+C, ou quand le token est annulé de l'extérieur
+            // (gestionnaire de services)
+            let stop_requested = shutdown_token.clone();
             tokio::select! {
                 result = &mut server_future => {
                     if let Err(err) = result {
@@ -594,18 +807,79 @@ impl Server {
                     } else {
                         info!("Serveur HTTP arrêté proprement");
                     }
+                    return;
                 }
-                _ = &mut ctrl_c => {
-                    info!("Ctrl+C reçu, arrêt gracieux");
-                    shutdown_token.cancel();
-                    let _ = shutdown_tx.send(());
+                _ = &mut ctrl_c => info!("Ctrl+C reçu, arrêt gracieux"),
+                _ = stop_requested.cancelled() => info!("Arrêt demandé, arrêt gracieux"),
+            }
 
-                    if tokio::time::timeout(std::time::Duration::from_secs(5), &mut server_future).await.is_err() {
-                        warn!("Arrêt gracieux trop long, fermeture forcée du serveur HTTP");
-                    }
-                }
+            shutdown_token.cancel();
+            let _ = shutdown_tx.send(());
+            if tokio::time::timeout(std::time::Duration::from_secs(5), &mut server_future)
+                .await
+                .is_err()
+            {
+                warn!("Arrêt gracieux trop long, fermeture forcée du serveur HTTP");
             }
         }));
+
+        // Accès distant (HTTPS authentifié) sur un second port, si configuré
+        if let Some(remote) = crate::remote::RemoteSettings::from_config() {
+            tokio::spawn(crate::remote::serve_remote(
+                Arc::new(remote),
+                self.embedded_router(),
+                self.shutdown_token.clone(),
+            ));
+        }
+    }
+
+    /// Router complet du serveur, pour l'intégrer dans une autre application
+    ///
+    /// Le router relit les routes à chaque requête : celles ajoutées après
+    /// l'appel (ex: WebRenderer dynamique) sont donc servies aussi. La CORS
+    /// et la compression configurées sont appliquées. À utiliser à la place de [`Server::start`]
+    /// quand l'application hôte possède déjà son serveur HTTP.
+    ///
+    /// # Exemple
+    ///
+    /// ```rust,ignore
+    /// # use pmoserver::ServerBuilder;
+    /// let server = ServerBuilder::new("Embedded", "http://192.168.1.10", 8080).build();
+    /// let app = axum::Router::new().nest_service("/upnp", server.embedded_router());
+    /// ```
+    pub fn embedded_router(&self) -> Router {
+        let router = self.router.clone();
+
+        // Utiliser un router dynamique qui relit le router à chaque requête.
+        // Cela permet d'enregistrer de nouvelles routes après le démarrage du serveur
+        // (ex: WebRenderer dynamique).
+        let dynamic_router = axum::Router::new().fallback(move |req: axum::extract::Request| {
+            let router = router.clone();
+            async move {
+                use tower::ServiceExt;
+                let r = router.read().await.clone();
+                r.into_service::<axum::body::Body>().oneshot(req).await
+            }
+        });
+        // CORS autour du routeur dynamique : couvre aussi les routes ajoutées après le démarrage
+        let router = match crate::cors::cors_layer_from_config() {
+            Some(cors) => dynamic_router.layer(cors),
+            None => dynamic_router,
+        };
+        // Compression gzip/deflate des descriptions XML et de l'API JSON (jamais de l'audio)
+        let router = match crate::compression::CompressionSettings::from_config() {
+            Some(settings) => router.layer(settings.layer()),
+            None => router,
+        };
+        // Mode HTTP/1.0 (flux non chunked, Connection: close) pour les renderers anciens
+        match crate::http10::Http10Settings::from_config() {
+            Some(settings) => router.layer(axum::middleware::from_fn(
+                move |req: axum::extract::Request, next: axum::middleware::Next| {
+                    crate::http10::http10_compat(settings.clone(), req, next)
+                },
+            )),
+            None => router,
+        }
     }
 
     /// Attend la fin du serveur
@@ -723,10 +997,16 @@ impl Server {
 }
 
 /// Builder pattern
+///
+/// Permet aux applications qui embarquent pmoserver de fournir leur propre
+/// socket d'écoute, un router de base ou une URL publique explicite, sans
+/// passer par la configuration globale.
 pub struct ServerBuilder {
     name: String,
     base_url: String,
     http_port: u16,
+    listener: Option<std::net::TcpListener>,
+    router: Option<Router>,
 }
 
 impl ServerBuilder {
@@ -742,16 +1022,53 @@ impl ServerBuilder {
             name: name.into(),
             base_url: base_url.into(),
             http_port,
+            listener: None,
+            router: None,
         }
     }
 
     pub fn new_configured() -> Self {
         let config = get_config();
-        Self {
-            name: "PMO-Music-Server".to_string(),
-            base_url: config.get_base_url(),
-            http_port: config.get_http_port(),
+        Self::new(
+            "PMO-Music-Server",
+            config.get_base_url(),
+            config.get_http_port(),
+        )
+    }
+
+    /// Nom du serveur (pour les logs)
+    pub fn with_name(mut self, name: impl Into<String>) -> Self {
+        self.name = name.into();
+        self
+    }
+
+    /// URL publique annoncée aux clients (descriptions UPnP, URLs de contenu)
+    pub fn with_base_url(mut self, base_url: impl Into<String>) -> Self {
+        self.base_url = base_url.into();
+        self
+    }
+
+    pub fn with_http_port(mut self, http_port: u16) -> Self {
+        self.http_port = http_port;
+        self
+    }
+
+    /// Socket d'écoute déjà ouvert par l'application hôte
+    ///
+    /// Le port annoncé devient celui du socket (utile avec un bind sur le
+    /// port 0).
+    pub fn with_listener(mut self, listener: std::net::TcpListener) -> Self {
+        if let Ok(addr) = listener.local_addr() {
+            self.http_port = addr.port();
         }
+        self.listener = Some(listener);
+        self
+    }
+
+    /// Router de base fusionné avec les routes internes du serveur
+    pub fn with_router(mut self, router: Router) -> Self {
+        self.router = Some(router);
+        self
     }
 
     /// Construit le serveur
@@ -766,6 +1083,78 @@ impl ServerBuilder {
     ///     .build();
     /// ```
     pub fn build(self) -> Server {
-        Server::new(self.name, self.base_url, self.http_port)
+        let mut server = Server::new(self.name, self.base_url, self.http_port);
+        server.listener = self.listener;
+        if let Some(router) = self.router {
+            // Le serveur vient d'être créé : personne d'autre ne tient le router
+            if let Some(lock) = Arc::get_mut(&mut server.router) {
+                let base = std::mem::replace(lock.get_mut(), Router::new());
+                *lock.get_mut() = base.merge(router);
+            }
+        }
+        server
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn builder_takes_port_from_listener() {
+        let listener = std::net::TcpListener::bind("127.0.0.1:0").unwrap();
+        let port = listener.local_addr().unwrap().port();
+        let server = ServerBuilder::new("Embedded", "http://localhost", 8080)
+            .with_base_url("http://192.168.1.10")
+            .with_listener(listener)
+            .build();
+
+        assert_eq!(server.http_port, port);
+        assert!(server.listener.is_some());
+        assert_eq!(server.base_url(), format!("http://192.168.1.10:{}", port));
+    }
+
+    fn api_doc(path: &str) -> utoipa::openapi::OpenApi {
+        use utoipa::openapi::path::{HttpMethod, OperationBuilder, PathItem, PathsBuilder};
+
+        utoipa::openapi::OpenApiBuilder::new()
+            .paths(PathsBuilder::new().path(
+                path,
+                PathItem::new(HttpMethod::Get, OperationBuilder::new().build()),
+            ))
+            .build()
+    }
+
+    #[tokio::test]
+    async fn openapi_json_merges_registered_apis() {
+        use axum::{body::Body, http};
+        use tower::ServiceExt;
+
+        let mut server = ServerBuilder::new("Merged", "http://localhost", 8080).build();
+        server
+            .add_openapi(Router::new(), api_doc("/items"), "alpha")
+            .await;
+        server
+            .add_openapi(Router::new(), api_doc("/status"), "beta")
+            .await;
+
+        let router = server.router.read().await.clone();
+        let request = http::Request::get("/api/openapi.json")
+            .body(Body::empty())
+            .unwrap();
+        let response = router.oneshot(request).await.unwrap();
+        assert_eq!(response.status(), http::StatusCode::OK);
+
+        let body = axum::body::to_bytes(response.into_body(), usize::MAX)
+            .await
+            .unwrap();
+        let doc: serde_json::Value = serde_json::from_slice(&body).unwrap();
+        let paths: Vec<&str> = doc["paths"]
+            .as_object()
+            .unwrap()
+            .keys()
+            .map(String::as_str)
+            .collect();
+        assert_eq!(paths, ["/api/alpha/items", "/api/beta/status"]);
     }
 }
@@ -193,6 +193,27 @@ impl SourceCacheManager {
             .map_err(|e| MusicSourceError::CacheError(e.to_string()))
     }
 
+    /// Cacher d'un coup les couvertures d'un lot d'URLs
+    ///
+    /// Pour les gros documents DIDL : URLs dédoublonnées, une transaction
+    /// SQLite et un client HTTP pour tout le lot (voir
+    /// [`pmocache::Cache::add_from_urls`]).
+    ///
+    /// # Returns
+    ///
+    /// Pour chaque URL distincte, la clé primaire (pk) de l'image ou l'erreur
+    pub async fn cache_covers(&self, urls: &[&str]) -> HashMap<String, Result<String>> {
+        self.cover_cache
+            .add_from_urls(urls, Some(&self.collection_id))
+            .await
+            .into_iter()
+            .map(|(url, pk)| {
+                let pk = pk.map_err(|e| MusicSourceError::CacheError(e.to_string()));
+                (url, pk)
+            })
+            .collect()
+    }
+
     /// Obtenir l'URL d'une couverture en cache
     ///
     /// # Arguments
@@ -36,6 +36,8 @@
 //! ```
 
 pub mod cache;
+pub mod object_id;
+pub mod provider;
 
 use pmodidl::{Container, Item};
 use std::fmt::Debug;
@@ -581,6 +583,20 @@ pub trait MusicSource: Debug + Send + Sync {
     /// The `SystemTime` of the last modification, or `None` if never modified.
     async fn last_change(&self) -> Option<SystemTime>;
 
+    /// Re-read the source's catalog from its backend
+    ///
+    /// Called from the admin API when the user asks for a library rescan.
+    /// Sources whose content is computed on the fly don't need to override
+    /// this; the default reports the operation as not supported.
+    ///
+    /// # Returns
+    ///
+    /// `Ok(())` once the catalog has been refreshed (and `update_id` bumped
+    /// if the content changed).
+    async fn rescan(&self) -> Result<()> {
+        Err(MusicSourceError::NotSupported("rescan".to_string()))
+    }
+
     // ============= Pagination & Search =============
 
     /// Get a paginated list of items
@@ -946,6 +962,8 @@ pub use pmoplaylist;
 
 // Re-export cache types
 pub use cache::{CacheStatistics, SourceCacheManager, TrackMetadata};
+pub use object_id::{ObjectId, ObjectIdMapper};
+pub use provider::{ContentProvider, MountConfig, ProviderSource, register_provider_factory};
 
 // Server extension modules (feature-gated)
 #[cfg(feature = "server")]
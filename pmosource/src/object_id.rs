@@ -0,0 +1,258 @@
+//! Identifiants d'objets ContentDirectory
+//!
+//! Toutes les sources suivent le même schéma d'ObjectID :
+//!
+//! ```text
+//! 0                               racine du serveur
+//! {source}                        racine d'une source        (qobuz)
+//! {source}:{kind}:{local}         objet d'une source         (qobuz:album:123)
+//! ```
+//!
+//! `local` peut lui-même contenir des `:` (`qobuz:search:albums:tracks:…`).
+//!
+//! Les pistes servies depuis le cache audio utilisent `{source}:track:{pk}`.
+//! Le pk est dérivé de l'URL d'origine ou du contenu (voir `pmocache`) : il
+//! ne change pas lors d'un rescan, et les favoris des points de contrôle
+//! restent valides. [`ObjectIdMapper`] fait la correspondance entre ces
+//! identifiants, les lignes du cache et les URLs de flux.
+
+use pmodidl::Item;
+use std::fmt;
+use std::str::FromStr;
+
+/// ObjectID de la racine du ContentDirectory
+pub const ROOT_ID: &str = "0";
+
+/// ParentID de la racine
+pub const ROOT_PARENT_ID: &str = "-1";
+
+/// Préfixe des routes du cache audio (`/audio/flac/{pk}[/{param}]`)
+const AUDIO_ROUTE: &str = "/audio/flac/";
+
+/// Types d'objets partagés par les sources
+pub mod kind {
+    pub const TRACK: &str = "track";
+    pub const ALBUM: &str = "album";
+    pub const ARTIST: &str = "artist";
+    pub const PLAYLIST: &str = "playlist";
+    pub const GENRE: &str = "genre";
+    pub const CHANNEL: &str = "channel";
+}
+
+/// ObjectID décodé
+#[derive(Debug, Clone, PartialEq, Eq, Hash)]
+pub enum ObjectId {
+    /// Racine du serveur (`0`)
+    Root,
+    /// Racine d'une source (`qobuz`)
+    Source(String),
+    /// Objet d'une source (`qobuz:album:123`)
+    Object {
+        source: String,
+        kind: String,
+        local: String,
+    },
+}
+
+/// ObjectID mal formé
+#[derive(Debug, Clone, PartialEq, Eq, thiserror::Error)]
+#[error("Invalid ObjectID: {0:?}")]
+pub struct InvalidObjectId(pub String);
+
+fn is_valid_segment(segment: &str) -> bool {
+    !segment.is_empty() && !segment.chars().any(|c| c == ':' || c.is_control())
+}
+
+impl ObjectId {
+    /// Construit l'identifiant d'un objet d'une source
+    pub fn new(
+        source: impl Into<String>,
+        kind: impl Into<String>,
+        local: impl Into<String>,
+    ) -> Result<Self, InvalidObjectId> {
+        let (source, kind, local) = (source.into(), kind.into(), local.into());
+        if !is_valid_segment(&source)
+            || !is_valid_segment(&kind)
+            || local.is_empty()
+            || local.chars().any(char::is_control)
+        {
+            return Err(InvalidObjectId(format!("{}:{}:{}", source, kind, local)));
+        }
+        Ok(Self::Object {
+            source,
+            kind,
+            local,
+        })
+    }
+
+    /// Décode un ObjectID reçu d'un point de contrôle
+    pub fn parse(id: &str) -> Result<Self, InvalidObjectId> {
+        if id == ROOT_ID {
+            return Ok(Self::Root);
+        }
+        let mut parts = id.splitn(3, ':');
+        let source = parts.next().unwrap_or_default();
+        match (parts.next(), parts.next()) {
+            (None, _) if is_valid_segment(source) => Ok(Self::Source(source.to_string())),
+            (Some(kind), Some(local)) => Self::new(source, kind, local),
+            _ => Err(InvalidObjectId(id.to_string())),
+        }
+    }
+
+    /// Source propriétaire (None pour la racine)
+    pub fn source(&self) -> Option<&str> {
+        match self {
+            Self::Root => None,
+            Self::Source(source) | Self::Object { source, .. } => Some(source),
+        }
+    }
+
+    /// Type de l'objet (`album`, `track`…)
+    pub fn kind(&self) -> Option<&str> {
+        match self {
+            Self::Object { kind, .. } => Some(kind),
+            _ => None,
+        }
+    }
+
+    /// Partie propre à la source (identifiant en base, slug…)
+    pub fn local(&self) -> Option<&str> {
+        match self {
+            Self::Object { local, .. } => Some(local),
+            _ => None,
+        }
+    }
+}
+
+impl fmt::Display for ObjectId {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        match self {
+            Self::Root => f.write_str(ROOT_ID),
+            Self::Source(source) => f.write_str(source),
+            Self::Object {
+                source,
+                kind,
+                local,
+            } => write!(f, "{}:{}:{}", source, kind, local),
+        }
+    }
+}
+
+impl FromStr for ObjectId {
+    type Err = InvalidObjectId;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        Self::parse(s)
+    }
+}
+
+/// Correspondance entre ObjectIDs, lignes du cache audio et URLs de flux
+/// pour une source donnée
+#[derive(Debug, Clone)]
+pub struct ObjectIdMapper {
+    source: String,
+}
+
+impl ObjectIdMapper {
+    pub fn new(source: impl Into<String>) -> Self {
+        Self {
+            source: source.into(),
+        }
+    }
+
+    /// ObjectID de la racine de la source
+    pub fn root_id(&self) -> ObjectId {
+        ObjectId::Source(self.source.clone())
+    }
+
+    /// ObjectID d'un objet de la source
+    pub fn object_id(&self, kind: &str, local: &str) -> Result<ObjectId, InvalidObjectId> {
+        ObjectId::new(&*self.source, kind, local)
+    }
+
+    /// ObjectID d'une piste du cache audio
+    pub fn track_id(&self, pk: &str) -> Result<ObjectId, InvalidObjectId> {
+        self.object_id(kind::TRACK, pk)
+    }
+
+    /// pk du cache audio désigné par un ObjectID de piste de cette source
+    pub fn pk_from_id(&self, id: &str) -> Option<String> {
+        match ObjectId::parse(id).ok()? {
+            ObjectId::Object {
+                source,
+                kind,
+                local,
+            } if source == self.source && kind == kind::TRACK => Some(local),
+            _ => None,
+        }
+    }
+
+    /// ObjectID de la piste servie par une URL du cache audio
+    pub fn id_from_url(&self, url: &str) -> Option<ObjectId> {
+        self.track_id(&pk_from_audio_url(url)?).ok()
+    }
+
+    /// ObjectID stable d'un item DIDL, déduit de sa première ressource
+    pub fn id_for_item(&self, item: &Item) -> Option<ObjectId> {
+        item.resources
+            .iter()
+            .find_map(|res| self.id_from_url(&res.url))
+    }
+}
+
+/// Extrait le pk d'une URL du cache audio, absolue ou relative
+///
+/// `http://host:8080/audio/flac/{pk}/orig` → `{pk}`
+pub fn pk_from_audio_url(url: &str) -> Option<String> {
+    let path = match url.find("://") {
+        Some(scheme_end) => {
+            let after = &url[scheme_end + 3..];
+            &after[after.find('/')?..]
+        }
+        None => url,
+    };
+    let rest = path.strip_prefix(AUDIO_ROUTE)?;
+    let pk = rest.split(['/', '?', '#']).next()?;
+    (!pk.is_empty()).then(|| pk.to_string())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_parse_round_trip() {
+        for id in [
+            "0",
+            "qobuz",
+            "qobuz:album:123",
+            "qobuz:search:albums:tracks:miles davis",
+            "radio-paradise:channel:main:live",
+        ] {
+            assert_eq!(ObjectId::parse(id).unwrap().to_string(), id);
+        }
+
+        let id = ObjectId::parse("radio-paradise:channel:main:live").unwrap();
+        assert_eq!(id.source(), Some("radio-paradise"));
+        assert_eq!(id.kind(), Some("channel"));
+        assert_eq!(id.local(), Some("main:live"));
+
+        assert!(ObjectId::parse("").is_err());
+        assert!(ObjectId::parse("qobuz:album").is_err());
+        assert!(ObjectId::parse("qobuz::123").is_err());
+    }
+
+    #[test]
+    fn test_mapper_urls() {
+        let mapper = ObjectIdMapper::new("qobuz");
+        let url = "http://192.168.1.10:8080/audio/flac/L:0a1b2c/orig";
+        assert_eq!(pk_from_audio_url(url).as_deref(), Some("L:0a1b2c"));
+        assert_eq!(pk_from_audio_url("/audio/flac/abc").as_deref(), Some("abc"));
+        assert_eq!(pk_from_audio_url("http://host/covers/jpeg/abc"), None);
+
+        let id = mapper.id_from_url(url).unwrap();
+        assert_eq!(id.to_string(), "qobuz:track:L:0a1b2c");
+        assert_eq!(mapper.pk_from_id(&id.to_string()).as_deref(), Some("L:0a1b2c"));
+        assert_eq!(mapper.pk_from_id("radiofrance:track:abc"), None);
+    }
+}
@@ -0,0 +1,362 @@
+//! Dossiers virtuels : API de plug-in pour le ContentDirectory
+//!
+//! Un [`ContentProvider`] expose une arborescence (podcasts, annuaire de
+//! radios, proxy d'un autre serveur UPnP…) sans connaître le reste du
+//! serveur. Il est monté sous un container de premier niveau configurable
+//! par [`ProviderSource`], qui l'adapte en [`MusicSource`].
+//!
+//! Le provider travaille avec des chemins relatifs : `""` désigne la racine
+//! du montage, les autres objets suivent le schéma `{kind}:{local}`. Le
+//! montage les préfixe par son identifiant pour obtenir les ObjectIDs
+//! `{mount}:{kind}:{local}` (voir [`crate::object_id`]).
+//!
+//! Les providers sont instanciés depuis la configuration par des fabriques
+//! enregistrées avec [`register_provider_factory`] :
+//!
+//! ```yaml
+//! sources:
+//!   virtual_folders:
+//!     - id: podcasts            # identifiant du montage (racine de l'arborescence)
+//!       title: "Podcasts"       # titre du container de premier niveau
+//!       provider: podcasts      # nom de la fabrique
+//!       options:                # paramètres propres au provider
+//!         feeds: []
+//! ```
+
+use crate::{BrowseResult, MusicSource, MusicSourceError, Result, SearchQuery, SourceCapabilities};
+use pmodidl::{Container, Item};
+use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+use std::fmt::Debug;
+use std::sync::{Arc, LazyLock, RwLock};
+use std::time::SystemTime;
+
+/// Backend de contenu monté comme dossier virtuel
+///
+/// Tous les chemins sont relatifs au montage (`""` pour sa racine).
+#[async_trait::async_trait]
+pub trait ContentProvider: Debug + Send + Sync {
+    /// Liste les enfants d'un chemin
+    ///
+    /// Les `id`/`parent_id` des objets retournés sont relatifs ; un
+    /// `parent_id` vide désigne la racine du montage.
+    async fn browse(&self, path: &str) -> Result<BrowseResult>;
+
+    /// Retourne l'URI de lecture d'un item
+    async fn resolve(&self, path: &str) -> Result<String>;
+
+    /// Recherche dans le contenu du provider
+    async fn search(&self, query: &SearchQuery) -> Result<BrowseResult> {
+        let _ = query;
+        Err(MusicSourceError::SearchNotSupported)
+    }
+
+    /// Métadonnées d'un item seul
+    async fn get_item(&self, path: &str) -> Result<Item> {
+        Err(MusicSourceError::ObjectNotFound(path.to_string()))
+    }
+
+    /// Le provider implémente-t-il [`ContentProvider::search`] ?
+    fn supports_search(&self) -> bool {
+        false
+    }
+
+    /// Image du container de premier niveau (vide : image par défaut du serveur)
+    fn image(&self) -> &[u8] {
+        &[]
+    }
+
+    /// Compteur de modifications du contenu
+    async fn update_id(&self) -> u32 {
+        0
+    }
+
+    /// Date de la dernière modification du contenu
+    async fn last_change(&self) -> Option<SystemTime> {
+        None
+    }
+}
+
+/// Point de montage d'un provider (entrée de `sources.virtual_folders`)
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+pub struct MountConfig {
+    /// Identifiant du montage, utilisé comme préfixe des ObjectIDs
+    pub id: String,
+    /// Titre du container de premier niveau
+    pub title: String,
+    /// Nom de la fabrique de provider
+    pub provider: String,
+    /// Paramètres propres au provider
+    #[serde(default)]
+    pub options: serde_json::Value,
+}
+
+/// Fabrique de provider, appelée pour chaque montage configuré
+pub type ProviderFactory =
+    Arc<dyn Fn(&MountConfig) -> Result<Arc<dyn ContentProvider>> + Send + Sync>;
+
+static FACTORIES: LazyLock<RwLock<HashMap<String, ProviderFactory>>> =
+    LazyLock::new(|| RwLock::new(HashMap::new()));
+
+/// Enregistre une fabrique de provider sous un nom
+///
+/// Une fabrique déjà enregistrée sous ce nom est remplacée.
+pub fn register_provider_factory<F>(name: &str, factory: F)
+where
+    F: Fn(&MountConfig) -> Result<Arc<dyn ContentProvider>> + Send + Sync + 'static,
+{
+    FACTORIES
+        .write()
+        .unwrap()
+        .insert(name.to_string(), Arc::new(factory));
+}
+
+/// Noms des fabriques enregistrées
+pub fn provider_factories() -> Vec<String> {
+    let mut names: Vec<String> = FACTORIES.read().unwrap().keys().cloned().collect();
+    names.sort();
+    names
+}
+
+/// Instancie le provider d'un montage et l'adapte en source
+pub fn mount(config: &MountConfig) -> Result<ProviderSource> {
+    let factory = FACTORIES
+        .read()
+        .unwrap()
+        .get(&config.provider)
+        .cloned()
+        .ok_or_else(|| {
+            MusicSourceError::NotSupported(format!("unknown content provider '{}'", config.provider))
+        })?;
+    let provider = factory(config)?;
+    Ok(ProviderSource::new(&config.id, &config.title, provider))
+}
+
+/// Lit les montages configurés (`sources.virtual_folders`)
+#[cfg(feature = "server")]
+pub fn mounts_from_config() -> anyhow::Result<Vec<MountConfig>> {
+    use serde_yaml::Value;
+    match pmoconfig::get_config().get_value(&["sources", "virtual_folders"]) {
+        Ok(Value::Sequence(mounts)) => Ok(serde_yaml::from_value(Value::Sequence(mounts))?),
+        _ => Ok(Vec::new()),
+    }
+}
+
+/// Adaptateur [`ContentProvider`] → [`MusicSource`]
+///
+/// Traduit les ObjectIDs `{mount}:{path}` en chemins relatifs à l'entrée et
+/// préfixe les identifiants des objets retournés par le provider.
+#[derive(Debug, Clone)]
+pub struct ProviderSource {
+    id: String,
+    title: String,
+    provider: Arc<dyn ContentProvider>,
+}
+
+impl ProviderSource {
+    pub fn new(id: &str, title: &str, provider: Arc<dyn ContentProvider>) -> Self {
+        Self {
+            id: id.to_string(),
+            title: title.to_string(),
+            provider,
+        }
+    }
+
+    /// Provider monté
+    pub fn provider(&self) -> &Arc<dyn ContentProvider> {
+        &self.provider
+    }
+
+    /// Chemin relatif désigné par un ObjectID du montage
+    fn to_path<'a>(&self, object_id: &'a str) -> Result<&'a str> {
+        if object_id == self.id {
+            return Ok("");
+        }
+        object_id
+            .strip_prefix(self.id.as_str())
+            .and_then(|rest| rest.strip_prefix(':'))
+            .filter(|path| !path.is_empty())
+            .ok_or_else(|| MusicSourceError::ObjectNotFound(object_id.to_string()))
+    }
+
+    /// ObjectID d'un chemin relatif
+    fn to_object_id(&self, path: &str) -> String {
+        if path.is_empty() {
+            self.id.clone()
+        } else {
+            format!("{}:{}", self.id, path)
+        }
+    }
+
+    fn map_item(&self, mut item: Item) -> Item {
+        item.id = self.to_object_id(&item.id);
+        item.parent_id = self.to_object_id(&item.parent_id);
+        item
+    }
+
+    fn map_container(&self, mut container: Container) -> Container {
+        container.id = self.to_object_id(&container.id);
+        container.parent_id = self.to_object_id(&container.parent_id);
+        container.containers = std::mem::take(&mut container.containers)
+            .into_iter()
+            .map(|c| self.map_container(c))
+            .collect();
+        container.items = std::mem::take(&mut container.items)
+            .into_iter()
+            .map(|i| self.map_item(i))
+            .collect();
+        container
+    }
+
+    fn map_result(&self, result: BrowseResult) -> BrowseResult {
+        match result {
+            BrowseResult::Containers(containers) => BrowseResult::Containers(
+                containers.into_iter().map(|c| self.map_container(c)).collect(),
+            ),
+            BrowseResult::Items(items) => {
+                BrowseResult::Items(items.into_iter().map(|i| self.map_item(i)).collect())
+            }
+            BrowseResult::Mixed { containers, items } => BrowseResult::Mixed {
+                containers: containers.into_iter().map(|c| self.map_container(c)).collect(),
+                items: items.into_iter().map(|i| self.map_item(i)).collect(),
+            },
+        }
+    }
+}
+
+#[async_trait::async_trait]
+impl MusicSource for ProviderSource {
+    fn name(&self) -> &str {
+        &self.title
+    }
+
+    fn id(&self) -> &str {
+        &self.id
+    }
+
+    fn default_image(&self) -> &[u8] {
+        self.provider.image()
+    }
+
+    fn capabilities(&self) -> SourceCapabilities {
+        SourceCapabilities {
+            supports_search: self.provider.supports_search(),
+            ..Default::default()
+        }
+    }
+
+    async fn root_container(&self) -> Result<Container> {
+        Ok(Container {
+            id: self.id.clone(),
+            parent_id: crate::object_id::ROOT_ID.to_string(),
+            restricted: Some("1".to_string()),
+            child_count: None,
+            searchable: Some(if self.provider.supports_search() { "1" } else { "0" }.to_string()),
+            title: self.title.clone(),
+            class: "object.container".to_string(),
+            artist: None,
+            album_art: None,
+            containers: vec![],
+            items: vec![],
+        })
+    }
+
+    async fn browse(&self, object_id: &str) -> Result<BrowseResult> {
+        let path = self.to_path(object_id)?;
+        let result = self.provider.browse(path).await?;
+        Ok(self.map_result(result))
+    }
+
+    async fn get_item(&self, object_id: &str) -> Result<Item> {
+        let path = self.to_path(object_id)?;
+        let item = self.provider.get_item(path).await?;
+        Ok(self.map_item(item))
+    }
+
+    async fn search(&self, query: &SearchQuery) -> Result<BrowseResult> {
+        let result = self.provider.search(query).await?;
+        Ok(self.map_result(result))
+    }
+
+    async fn resolve_uri(&self, object_id: &str) -> Result<String> {
+        self.provider.resolve(self.to_path(object_id)?).await
+    }
+
+    fn supports_fifo(&self) -> bool {
+        false
+    }
+
+    async fn append_track(&self, _track: Item) -> Result<()> {
+        Err(MusicSourceError::FifoNotSupported)
+    }
+
+    async fn remove_oldest(&self) -> Result<Option<Item>> {
+        Err(MusicSourceError::FifoNotSupported)
+    }
+
+    async fn update_id(&self) -> u32 {
+        self.provider.update_id().await
+    }
+
+    async fn last_change(&self) -> Option<SystemTime> {
+        self.provider.last_change().await
+    }
+
+    async fn get_items(&self, _offset: usize, _count: usize) -> Result<Vec<Item>> {
+        Ok(vec![])
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[derive(Debug)]
+    struct Echo;
+
+    #[async_trait::async_trait]
+    impl ContentProvider for Echo {
+        async fn browse(&self, path: &str) -> Result<BrowseResult> {
+            let container = Container {
+                id: "feed:1".to_string(),
+                parent_id: path.to_string(),
+                restricted: Some("1".to_string()),
+                child_count: None,
+                searchable: None,
+                title: "Feed".to_string(),
+                class: "object.container".to_string(),
+                artist: None,
+                album_art: None,
+                containers: vec![],
+                items: vec![],
+            };
+            Ok(BrowseResult::Containers(vec![container]))
+        }
+
+        async fn resolve(&self, path: &str) -> Result<String> {
+            Ok(format!("http://example.org/{}", path))
+        }
+    }
+
+    #[tokio::test]
+    async fn test_mount_id_mapping() {
+        register_provider_factory("echo", |_| Ok(Arc::new(Echo) as Arc<dyn ContentProvider>));
+        let source = mount(&MountConfig {
+            id: "pods".to_string(),
+            title: "Podcasts".to_string(),
+            provider: "echo".to_string(),
+            options: serde_json::Value::Null,
+        })
+        .unwrap();
+
+        let root = source.browse("pods").await.unwrap();
+        assert_eq!(root.containers()[0].id, "pods:feed:1");
+        assert_eq!(root.containers()[0].parent_id, "pods");
+
+        let uri = source.resolve_uri("pods:episode:42").await.unwrap();
+        assert_eq!(uri, "http://example.org/episode:42");
+
+        assert!(source.browse("podsx:feed:1").await.is_err());
+        assert!(source.browse("qobuz:album:1").await.is_err());
+    }
+}
@@ -0,0 +1,82 @@
+//! Coût du service d'une description de device et d'un SCPD
+//!
+//! `render` sérialise l'arbre `xmltree` à chaque requête (ancien
+//! fonctionnement), `cached` renvoie les octets mis en cache par génération
+//! de configuration.
+//!
+//! ```sh
+//! cargo bench -p pmoupnp --bench descriptions
+//! ```
+
+use std::sync::Arc;
+
+use criterion::{Criterion, black_box, criterion_group, criterion_main};
+use pmoupnp::UpnpModel;
+use pmoupnp::actions::{Action, Argument};
+use pmoupnp::devices::{Device, DeviceInstance};
+use pmoupnp::services::Service;
+use pmoupnp::state_variables::StateVariable;
+use pmoupnp::variable_types::StateVarType;
+use pmoupnp::xml_format::write_xml;
+
+/// Service de taille comparable à AVTransport : 30 variables, 20 actions
+fn service(name: &str) -> Arc<Service> {
+    let mut service = Service::new(name.to_string());
+    let variables: Vec<_> = (0..30)
+        .map(|i| Arc::new(StateVariable::new(StateVarType::String, format!("Var{i}"))))
+        .collect();
+    for variable in &variables {
+        service.add_variable(variable.clone()).unwrap();
+    }
+    for i in 0..20 {
+        let mut action = Action::new(format!("Action{i}"));
+        for j in 0..3 {
+            let variable = variables[(i + j) % variables.len()].clone();
+            let argument = Argument::new_in(format!("Arg{j}"), variable);
+            action.add_argument(Arc::new(argument)).unwrap();
+        }
+        service.add_action(Arc::new(action)).unwrap();
+    }
+    Arc::new(service)
+}
+
+fn device() -> Arc<DeviceInstance> {
+    let mut device = Device::new(
+        "BenchRenderer".to_string(),
+        "MediaRenderer".to_string(),
+        "Bench Renderer".to_string(),
+    );
+    device.set_udn("0b9a6c1e-5d2f-4c3a-9e8b-000000000001".to_string());
+    for name in ["AVTransport", "RenderingControl", "ConnectionManager"] {
+        device.add_service(service(name)).unwrap();
+    }
+    device.create_instance()
+}
+
+fn bench_description(c: &mut Criterion) {
+    let device = device();
+    let mut group = c.benchmark_group("device_description");
+    group.bench_function("render", |b| {
+        b.iter(|| black_box(write_xml(&device.description_element()).unwrap()))
+    });
+    group.bench_function("cached", |b| {
+        b.iter(|| black_box(device.description_xml().unwrap()))
+    });
+    group.finish();
+}
+
+fn bench_scpd(c: &mut Criterion) {
+    let device = device();
+    let service = device.get_service("AVTransport").unwrap();
+    let mut group = c.benchmark_group("scpd");
+    group.bench_function("render", |b| {
+        b.iter(|| black_box(write_xml(&service.scpd_element()).unwrap()))
+    });
+    group.bench_function("cached", |b| {
+        b.iter(|| black_box(service.scpd_xml().unwrap()))
+    });
+    group.finish();
+}
+
+criterion_group!(benches, bench_description, bench_scpd);
+criterion_main!(benches);
@@ -0,0 +1,85 @@
+//! Coût d'un cycle de notification GENA pour 50 à 200 abonnés
+//!
+//! `per_subscriber` reproduit l'ancien fonctionnement (corps reconstruit pour
+//! chaque abonné), `shared_bodies` le [`PropertySet`] actuel (un corps par
+//! profil de contournements, partagé).
+//!
+//! ```sh
+//! cargo bench -p pmoupnp --bench notify_subscribers
+//! ```
+
+use criterion::{BenchmarkId, Criterion, black_box, criterion_group, criterion_main};
+use pmoupnp::eventing::{PropertySet, render_propertyset};
+use pmoupnp::quirks::{ClientQuirks, EventEncoding};
+
+/// Propriétés typiques d'un AVTransport en lecture
+fn properties() -> Vec<(String, String)> {
+    let last_change = format!(
+        r#"<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0"><TransportState val="PLAYING"/><CurrentTrackMetaData val="{}"/></InstanceID></Event>"#,
+        "&lt;DIDL-Lite&gt;".repeat(40)
+    );
+    vec![
+        ("LastChange".to_string(), last_change),
+        ("TransportState".to_string(), "PLAYING".to_string()),
+        ("RelativeTimePosition".to_string(), "0:01:23".to_string()),
+    ]
+}
+
+/// Abonnés avec un mélange réaliste de profils (majorité par défaut)
+fn subscriber_quirks(count: usize) -> Vec<ClientQuirks> {
+    (0..count)
+        .map(|i| match i % 10 {
+            0 => ClientQuirks {
+                event_encoding: EventEncoding::Escaped,
+                ..Default::default()
+            },
+            1 => ClientQuirks {
+                event_encoding: EventEncoding::Cdata,
+                ..Default::default()
+            },
+            _ => ClientQuirks::default(),
+        })
+        .collect()
+}
+
+fn bench_notify(c: &mut Criterion) {
+    let mut group = c.benchmark_group("notify_subscribers");
+    for count in [50, 100, 200] {
+        let quirks = subscriber_quirks(count);
+
+        group.bench_with_input(
+            BenchmarkId::new("per_subscriber", count),
+            &quirks,
+            |b, quirks| {
+                b.iter(|| {
+                    let properties = properties();
+                    let bodies: Vec<String> = quirks
+                        .iter()
+                        .map(|q| {
+                            let mut body = String::new();
+                            render_propertyset(&properties, q, &mut body);
+                            body
+                        })
+                        .collect();
+                    black_box(bodies)
+                })
+            },
+        );
+
+        group.bench_with_input(
+            BenchmarkId::new("shared_bodies", count),
+            &quirks,
+            |b, quirks| {
+                b.iter(|| {
+                    let mut set = PropertySet::new(properties());
+                    let bodies: Vec<_> = quirks.iter().map(|q| set.body_for(*q)).collect();
+                    black_box(bodies)
+                })
+            },
+        );
+    }
+    group.finish();
+}
+
+criterion_group!(benches, bench_notify);
+criterion_main!(benches);
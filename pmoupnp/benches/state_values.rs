@@ -0,0 +1,107 @@
+//! Coût des conversions et comparaisons de `StateValue`
+//!
+//! Ces chemins sont parcourus pour chaque argument SOAP (`from_string`) et
+//! chaque mise à jour de variable événementielle (comparaison avec l'ancienne
+//! valeur, `try_cast`), ainsi que pour la vérification des valeurs autorisées.
+//!
+//! ```sh
+//! cargo bench -p pmoupnp --bench state_values
+//! ```
+
+use criterion::{Criterion, black_box, criterion_group, criterion_main};
+use pmoupnp::state_variables::{StateVariable, UpnpVariable};
+use pmoupnp::variable_types::{StateValue, StateVarType};
+
+/// Arguments SOAP typiques d'un point de contrôle AVTransport
+const SOAP_ARGS: &[(&str, StateVarType)] = &[
+    ("0", StateVarType::UI4),
+    ("REL_TIME", StateVarType::String),
+    ("0:01:23", StateVarType::String),
+    ("1", StateVarType::Boolean),
+    ("-12", StateVarType::I4),
+    ("Master", StateVarType::String),
+];
+
+fn bench_parse(c: &mut Criterion) {
+    c.bench_function("from_string/soap_args", |b| {
+        b.iter(|| {
+            for (raw, var_type) in SOAP_ARGS {
+                black_box(StateValue::from_string(black_box(raw), var_type).unwrap());
+            }
+        })
+    });
+}
+
+fn bench_cast(c: &mut Criterion) {
+    let mut group = c.benchmark_group("try_cast");
+    let volume = StateValue::UI2(42);
+    let state = StateValue::String("PLAYING".to_string());
+
+    group.bench_function("identity", |b| {
+        b.iter(|| black_box(&state).try_cast(StateVarType::String).unwrap())
+    });
+    group.bench_function("ui2_to_ui4", |b| {
+        b.iter(|| black_box(&volume).try_cast(StateVarType::UI4).unwrap())
+    });
+    group.bench_function("ui2_to_string", |b| {
+        b.iter(|| black_box(&volume).try_cast(StateVarType::String).unwrap())
+    });
+    group.finish();
+}
+
+fn bench_compare(c: &mut Criterion) {
+    let mut group = c.benchmark_group("compare");
+    let last_change = StateValue::String(format!(
+        r#"<Event xmlns="urn:schemas-upnp-org:metadata-1-0/AVT/"><InstanceID val="0">{}</InstanceID></Event>"#,
+        "<TransportState val=\"PLAYING\"/>".repeat(20)
+    ));
+    let same_change = last_change.clone();
+    let (a, b) = (StateValue::UI4(120), StateValue::UI4(121));
+    let (wide, narrow) = (StateValue::UI4(120), StateValue::UI1(120));
+
+    group.bench_function("eq/string", |bench| {
+        bench.iter(|| black_box(&last_change) == black_box(&same_change))
+    });
+    group.bench_function("eq/ui4", |bench| {
+        bench.iter(|| black_box(&a) == black_box(&b))
+    });
+    group.bench_function("eq/ui4_ui1", |bench| {
+        bench.iter(|| black_box(&wide) == black_box(&narrow))
+    });
+    group.bench_function("cmp/ui4", |bench| {
+        bench.iter(|| black_box(&a).partial_cmp(black_box(&b)))
+    });
+    group.finish();
+}
+
+fn bench_allowed(c: &mut Criterion) {
+    let mut var = StateVariable::new(StateVarType::String, "TransportState".to_string());
+    let states = [
+        "STOPPED",
+        "PLAYING",
+        "TRANSITIONING",
+        "PAUSED_PLAYBACK",
+        "PAUSED_RECORDING",
+        "RECORDING",
+        "NO_MEDIA_PRESENT",
+    ];
+    let values: Vec<_> = states
+        .iter()
+        .map(|s| StateValue::String(s.to_string()))
+        .collect();
+    var.extend_allowed_values(&values).unwrap();
+    let last = StateValue::String("NO_MEDIA_PRESENT".to_string());
+
+    c.bench_function("allowed/transport_state", |b| {
+        b.iter(|| var.is_an_allowed_value(black_box(&last)))
+    });
+}
+
+criterion_group!(
+    benches,
+    bench_parse,
+    bench_cast,
+    bench_compare,
+    bench_allowed
+);
+criterion_main!(benches);
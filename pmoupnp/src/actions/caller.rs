@@ -0,0 +1,87 @@
+//! Identité du point de contrôle à l'origine d'une action SOAP.
+//!
+//! Le handler de contrôle exécute chaque action dans la portée d'un
+//! [`ActionCaller`] : les handlers peuvent ainsi savoir quel client les
+//! invoque (suivi de session, contrôle exclusif) sans changer la signature
+//! des [`ActionHandler`](super::ActionHandler).
+//!
+//! ```rust
+//! use pmoupnp::actions::ActionCaller;
+//!
+//! # async fn example() {
+//! let caller = ActionCaller {
+//!     addr: Some("192.168.1.20".parse().unwrap()),
+//!     user_agent: Some("BubbleUPnP".to_string()),
+//! };
+//! caller
+//!     .scope(async {
+//!         let current = ActionCaller::current().unwrap();
+//!         assert_eq!(current.id(), "192.168.1.20");
+//!     })
+//!     .await;
+//! # }
+//! ```
+
+use std::{future::Future, net::IpAddr};
+
+tokio::task_local! {
+    static CALLER: ActionCaller;
+}
+
+/// Client à l'origine de l'action en cours.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct ActionCaller {
+    /// Adresse IP du client (absente hors requête HTTP)
+    pub addr: Option<IpAddr>,
+    /// En-tête `User-Agent` du client
+    pub user_agent: Option<String>,
+}
+
+impl ActionCaller {
+    /// Appelant de l'action en cours d'exécution.
+    ///
+    /// Retourne `None` hors du handler de contrôle SOAP (appel interne,
+    /// API REST…).
+    pub fn current() -> Option<ActionCaller> {
+        CALLER.try_with(Clone::clone).ok()
+    }
+
+    /// Exécute `future` avec cet appelant comme appelant courant.
+    pub async fn scope<F: Future>(self, future: F) -> F::Output {
+        CALLER.scope(self, future).await
+    }
+
+    /// Identifiant stable du client : son adresse IP, à défaut son
+    /// `User-Agent`.
+    ///
+    /// Plusieurs points de contrôle sur une même machine partagent donc
+    /// la même identité.
+    pub fn id(&self) -> String {
+        match (&self.addr, &self.user_agent) {
+            (Some(addr), _) => addr.to_string(),
+            (None, Some(agent)) => agent.clone(),
+            (None, None) => "unknown".to_string(),
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[tokio::test]
+    async fn caller_is_only_visible_inside_scope() {
+        assert!(ActionCaller::current().is_none());
+
+        let caller = ActionCaller {
+            addr: Some("10.0.0.5".parse().unwrap()),
+            user_agent: None,
+        };
+        let seen = caller
+            .clone()
+            .scope(async { ActionCaller::current() })
+            .await;
+        assert_eq!(seen, Some(caller));
+        assert!(ActionCaller::current().is_none());
+    }
+}
@@ -10,6 +10,11 @@ pub enum ActionError {
 
     #[error("Set operation error: {0}")]
     SetError(String),
+
+    /// Erreur UPnP avec code spécifique au service (ex: 705 pour AVTransport),
+    /// renvoyée telle quelle dans le SOAP fault
+    #[error("UPnP error {code}: {description}")]
+    UpnpError { code: String, description: String },
 }
 
 impl From<std::io::Error> for ActionError {
@@ -9,6 +9,7 @@ mod arg_inst_set_methods;
 mod arg_instance_methods;
 mod arg_set_methods;
 mod argument_methods;
+mod caller;
 mod handler_helpers;
 
 mod macros;
@@ -20,6 +21,7 @@ use crate::{
 use std::sync::{Arc, RwLock};
 
 pub use action_handler::{ActionData, ActionFuture, ActionHandler};
+pub use caller::ActionCaller;
 pub use errors::ActionError;
 pub use handler_helpers::{get_value, reflect_to_string, set_value};
 
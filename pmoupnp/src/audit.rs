@@ -0,0 +1,304 @@
+//! Journal d'audit des actions UPnP invoquées.
+//!
+//! Chaque requête de contrôle SOAP est enregistrée dans un tampon circulaire
+//! en mémoire : date, service, action, client (adresse et User-Agent),
+//! arguments résumés et masqués, résultat ou fault, durée. Le journal est
+//! exposé par `GET /api/upnp/actions` et affiché dans la page de debug de
+//! la webapp — de quoi répondre à « qu'a réellement envoyé mconnect ? ».
+//!
+//! ```yaml
+//! host:
+//!   upnp:
+//!     audit:
+//!       capacity: 500    # 0 désactive le journal
+//! ```
+
+use chrono::{DateTime, Utc};
+use once_cell::sync::Lazy;
+use serde::{Deserialize, Serialize};
+use std::{
+    collections::VecDeque,
+    net::SocketAddr,
+    sync::{
+        RwLock,
+        atomic::{AtomicU64, Ordering},
+    },
+    time::Instant,
+};
+
+use crate::config_ext::UpnpConfigExt;
+
+/// Longueur maximale d'une valeur d'argument dans le résumé
+const MAX_ARG_LEN: usize = 120;
+
+static AUDIT_LOG: Lazy<ActionAuditLog> = Lazy::new(|| {
+    ActionAuditLog::new(
+        pmoconfig::get_config()
+            .get_upnp_audit_capacity()
+            .unwrap_or(500),
+    )
+});
+
+/// Journal d'audit global
+pub fn audit_log() -> &'static ActionAuditLog {
+    &AUDIT_LOG
+}
+
+/// Issue d'une invocation
+#[derive(Debug, Clone, PartialEq, Serialize, Deserialize)]
+#[serde(tag = "status", rename_all = "snake_case")]
+pub enum ActionOutcome {
+    /// Action exécutée, arguments de sortie résumés
+    Ok { output: Vec<(String, String)> },
+    /// Fault SOAP renvoyé au client
+    Fault { code: u32, description: String },
+}
+
+/// Une invocation d'action enregistrée
+#[derive(Debug, Clone, Serialize, Deserialize)]
+pub struct ActionAuditEntry {
+    /// Numéro d'ordre croissant
+    pub id: u64,
+    pub timestamp: DateTime<Utc>,
+    /// UDN du device (vide si inconnu)
+    pub device_udn: String,
+    pub service: String,
+    /// Nom de l'action (vide si la requête n'a pas pu être analysée)
+    pub action: String,
+    /// Adresse IP du client
+    pub client_addr: Option<String>,
+    pub user_agent: Option<String>,
+    /// Arguments d'entrée, valeurs masquées et tronquées
+    pub args: Vec<(String, String)>,
+    pub outcome: ActionOutcome,
+    pub duration_ms: f64,
+}
+
+/// Filtre de consultation du journal
+#[derive(Debug, Clone, Default, Deserialize)]
+pub struct AuditQuery {
+    /// Nom d'action exact
+    pub action: Option<String>,
+    /// Service exact
+    pub service: Option<String>,
+    /// Fragment de l'adresse ou du User-Agent du client
+    pub client: Option<String>,
+    /// Seulement les faults
+    #[serde(default)]
+    pub faults_only: bool,
+    /// Entrées d'identifiant strictement supérieur (suivi incrémental)
+    pub since: Option<u64>,
+    /// Nombre maximal d'entrées, les plus récentes d'abord
+    pub limit: Option<usize>,
+}
+
+impl AuditQuery {
+    fn matches(&self, entry: &ActionAuditEntry) -> bool {
+        if self.action.as_ref().is_some_and(|a| *a != entry.action)
+            || self.service.as_ref().is_some_and(|s| *s != entry.service)
+            || self.since.is_some_and(|id| entry.id <= id)
+            || (self.faults_only && matches!(entry.outcome, ActionOutcome::Ok { .. }))
+        {
+            return false;
+        }
+        match &self.client {
+            Some(fragment) => [&entry.client_addr, &entry.user_agent]
+                .into_iter()
+                .flatten()
+                .any(|value| value.contains(fragment.as_str())),
+            None => true,
+        }
+    }
+}
+
+/// Tampon circulaire des dernières invocations
+pub struct ActionAuditLog {
+    capacity: usize,
+    next_id: AtomicU64,
+    entries: RwLock<VecDeque<ActionAuditEntry>>,
+}
+
+impl ActionAuditLog {
+    pub fn new(capacity: usize) -> Self {
+        Self {
+            capacity,
+            next_id: AtomicU64::new(1),
+            entries: RwLock::new(VecDeque::with_capacity(capacity.min(1024))),
+        }
+    }
+
+    pub fn is_enabled(&self) -> bool {
+        self.capacity > 0
+    }
+
+    /// Ajoute une entrée, en évinçant la plus ancienne si le tampon est plein
+    pub fn push(&self, mut entry: ActionAuditEntry) {
+        if !self.is_enabled() {
+            return;
+        }
+        entry.id = self.next_id.fetch_add(1, Ordering::Relaxed);
+        let mut entries = self.entries.write().unwrap();
+        while entries.len() >= self.capacity {
+            entries.pop_front();
+        }
+        entries.push_back(entry);
+    }
+
+    /// Entrées correspondant au filtre, les plus récentes d'abord
+    pub fn query(&self, query: &AuditQuery) -> Vec<ActionAuditEntry> {
+        let entries = self.entries.read().unwrap();
+        entries
+            .iter()
+            .rev()
+            .filter(|entry| query.matches(entry))
+            .take(query.limit.unwrap_or(usize::MAX))
+            .cloned()
+            .collect()
+    }
+
+    pub fn len(&self) -> usize {
+        self.entries.read().unwrap().len()
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.len() == 0
+    }
+
+    pub fn clear(&self) {
+        self.entries.write().unwrap().clear();
+    }
+}
+
+/// Résumé masqué et tronqué d'une valeur d'argument
+pub fn summarize_value(value: &str) -> String {
+    let value = crate::payload_log::redact(value);
+    if value.chars().count() <= MAX_ARG_LEN {
+        return value;
+    }
+    let mut summary: String = value.chars().take(MAX_ARG_LEN).collect();
+    summary.push('…');
+    summary
+}
+
+/// Invocation en cours, enregistrée par [`ActionRecord::finish`]
+///
+/// Le handler de contrôle renseigne l'action et les arguments au fil de
+/// l'analyse ; une requête rejetée avant d'identifier l'action est
+/// enregistrée avec une action vide.
+pub struct ActionRecord {
+    started: Instant,
+    entry: ActionAuditEntry,
+}
+
+impl ActionRecord {
+    pub fn start(
+        device_udn: String,
+        service: String,
+        client_addr: Option<SocketAddr>,
+        user_agent: Option<String>,
+    ) -> Self {
+        Self {
+            started: Instant::now(),
+            entry: ActionAuditEntry {
+                id: 0,
+                timestamp: Utc::now(),
+                device_udn,
+                service,
+                action: String::new(),
+                client_addr: client_addr.map(|addr| addr.ip().to_string()),
+                user_agent,
+                args: Vec::new(),
+                outcome: ActionOutcome::Ok { output: Vec::new() },
+                duration_ms: 0.0,
+            },
+        }
+    }
+
+    pub fn set_action(&mut self, action: &str) {
+        self.entry.action = action.to_string();
+    }
+
+    pub fn set_args<'a>(&mut self, args: impl IntoIterator<Item = (&'a String, &'a String)>) {
+        self.entry.args = args
+            .into_iter()
+            .map(|(name, value)| (name.clone(), summarize_value(value)))
+            .collect();
+        self.entry.args.sort();
+    }
+
+    pub fn set_output(&mut self, output: &[(String, String)]) {
+        self.entry.outcome = ActionOutcome::Ok {
+            output: output
+                .iter()
+                .map(|(name, value)| (name.clone(), summarize_value(value)))
+                .collect(),
+        };
+    }
+
+    /// `code` : code d'erreur UPnP (cf. [`crate::soap::error_codes`])
+    pub fn set_fault(&mut self, code: &str, description: impl Into<String>) {
+        self.entry.outcome = ActionOutcome::Fault {
+            code: code.parse().unwrap_or(0),
+            description: description.into(),
+        };
+    }
+
+    /// Enregistre l'invocation dans le journal global
+    pub fn finish(mut self) {
+        self.entry.duration_ms = self.started.elapsed().as_secs_f64() * 1000.0;
+        audit_log().push(self.entry);
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn record(action: &str, agent: &str, fault: bool) -> ActionAuditEntry {
+        let mut record = ActionRecord::start(
+            "uuid:test".into(),
+            "AVTransport".into(),
+            Some("192.168.1.42:50000".parse().unwrap()),
+            Some(agent.into()),
+        );
+        record.set_action(action);
+        record.set_args([(
+            &"CurrentURI".to_string(),
+            &"http://srv/track.flac?token=s3cr3t".to_string(),
+        )]);
+        if fault {
+            record.set_fault("402", "Invalid Args");
+        }
+        record.entry
+    }
+
+    #[test]
+    fn test_ring_and_query() {
+        let log = ActionAuditLog::new(2);
+        log.push(record("Play", "mconnect/3.0", false));
+        log.push(record("SetAVTransportURI", "mconnect/3.0", true));
+        log.push(record("Stop", "BubbleUPnP", false));
+        assert_eq!(log.len(), 2);
+
+        let all = log.query(&AuditQuery::default());
+        assert_eq!(all[0].action, "Stop");
+        assert_eq!(all[1].action, "SetAVTransportURI");
+        assert_eq!(all[1].client_addr.as_deref(), Some("192.168.1.42"));
+        assert!(!all[1].args[0].1.contains("s3cr3t"));
+
+        let query = AuditQuery {
+            client: Some("mconnect".into()),
+            faults_only: true,
+            ..Default::default()
+        };
+        let faults = log.query(&query);
+        assert_eq!(faults.len(), 1);
+        assert_eq!(faults[0].action, "SetAVTransportURI");
+
+        let query = AuditQuery {
+            since: Some(faults[0].id),
+            ..Default::default()
+        };
+        assert_eq!(log.query(&query).len(), 1);
+    }
+}
@@ -0,0 +1,178 @@
+//! Horloge injectable
+//!
+//! Les composants qui mesurent ou attendent le temps dans leurs propres
+//! threads (serveur SSDP, renouvellement des abonnements du control point)
+//! passent par une [`Clock`] plutôt que par `Instant::now` et
+//! `thread::sleep`. En production c'est [`SystemClock`] ; les tests utilisent
+//! [`FakeClock`], dont le temps n'avance que sur commande, pour vérifier
+//! annonces et échéances sans attendre réellement.
+//!
+//! Les tâches tokio (notifier GENA, persistance des variables) s'appuient
+//! sur `tokio::time`, que les tests figent avec `tokio::time::pause`.
+
+use chrono::{DateTime, Utc};
+use std::fmt;
+use std::sync::{Arc, Condvar, Mutex};
+use std::time::{Duration, Instant};
+
+/// Source de temps
+pub trait Clock: Send + Sync + fmt::Debug {
+    /// Instant courant (horloge monotone)
+    fn now(&self) -> Instant;
+
+    /// Date courante (en-têtes DATE, horodatages)
+    fn utc_now(&self) -> DateTime<Utc>;
+
+    /// Bloque le thread courant pendant `duration`
+    fn sleep(&self, duration: Duration);
+}
+
+/// Horloge du système
+#[derive(Debug, Default, Clone, Copy)]
+pub struct SystemClock;
+
+impl Clock for SystemClock {
+    fn now(&self) -> Instant {
+        Instant::now()
+    }
+
+    fn utc_now(&self) -> DateTime<Utc> {
+        Utc::now()
+    }
+
+    fn sleep(&self, duration: Duration) {
+        std::thread::sleep(duration)
+    }
+}
+
+/// Horloge du système, partagée
+pub fn system_clock() -> Arc<dyn Clock> {
+    Arc::new(SystemClock)
+}
+
+#[derive(Debug, Default)]
+struct FakeState {
+    elapsed: Duration,
+    /// Échéances des threads en attente
+    deadlines: Vec<Duration>,
+}
+
+impl FakeState {
+    /// Threads bloqués, sans compter ceux déjà à échéance qui n'ont pas
+    /// encore repris la main
+    fn blocked(&self) -> usize {
+        self.deadlines
+            .iter()
+            .filter(|deadline| **deadline > self.elapsed)
+            .count()
+    }
+}
+
+/// Horloge de test : le temps n'avance que par [`FakeClock::advance`]
+///
+/// Un thread qui appelle [`Clock::sleep`] reste bloqué jusqu'à ce que le
+/// temps virtuel atteigne son échéance. [`FakeClock::wait_for_sleepers`]
+/// permet d'attendre que les threads observés soient tous en attente avant
+/// d'avancer le temps.
+#[derive(Debug)]
+pub struct FakeClock {
+    origin: Instant,
+    origin_utc: DateTime<Utc>,
+    state: Mutex<FakeState>,
+    changed: Condvar,
+}
+
+impl FakeClock {
+    /// Horloge figée à l'instant présent
+    pub fn new() -> Self {
+        Self {
+            origin: Instant::now(),
+            origin_utc: Utc::now(),
+            state: Mutex::new(FakeState::default()),
+            changed: Condvar::new(),
+        }
+    }
+
+    /// Temps virtuel écoulé depuis la création
+    pub fn elapsed(&self) -> Duration {
+        self.state.lock().unwrap().elapsed
+    }
+
+    /// Avance le temps virtuel et réveille les threads arrivés à échéance
+    pub fn advance(&self, duration: Duration) {
+        self.state.lock().unwrap().elapsed += duration;
+        self.changed.notify_all();
+    }
+
+    /// Attend (en temps réel, au plus `timeout`) qu'au moins `count`
+    /// threads soient bloqués dans [`Clock::sleep`], avant leur échéance
+    pub fn wait_for_sleepers(&self, count: usize, timeout: Duration) -> bool {
+        let state = self.state.lock().unwrap();
+        let (state, _) = self
+            .changed
+            .wait_timeout_while(state, timeout, |state| state.blocked() < count)
+            .unwrap();
+        state.blocked() >= count
+    }
+}
+
+impl Default for FakeClock {
+    fn default() -> Self {
+        Self::new()
+    }
+}
+
+impl Clock for FakeClock {
+    fn now(&self) -> Instant {
+        self.origin + self.elapsed()
+    }
+
+    fn utc_now(&self) -> DateTime<Utc> {
+        self.origin_utc + chrono::Duration::from_std(self.elapsed()).unwrap_or_default()
+    }
+
+    fn sleep(&self, duration: Duration) {
+        let mut state = self.state.lock().unwrap();
+        let deadline = state.elapsed + duration;
+        if duration.is_zero() {
+            return;
+        }
+        state.deadlines.push(deadline);
+        self.changed.notify_all();
+        state = self
+            .changed
+            .wait_while(state, |state| state.elapsed < deadline)
+            .unwrap();
+        if let Some(pos) = state.deadlines.iter().position(|d| *d == deadline) {
+            state.deadlines.swap_remove(pos);
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_fake_clock() {
+        let clock = Arc::new(FakeClock::new());
+        let start = clock.now();
+
+        let sleeper = {
+            let clock = Arc::clone(&clock);
+            std::thread::spawn(move || {
+                clock.sleep(Duration::from_secs(60));
+                clock.now()
+            })
+        };
+        assert!(clock.wait_for_sleepers(1, Duration::from_secs(5)));
+
+        clock.advance(Duration::from_secs(30));
+        assert!(clock.wait_for_sleepers(1, Duration::from_secs(5)));
+        clock.advance(Duration::from_secs(30));
+
+        let woke_at = sleeper.join().unwrap();
+        assert_eq!(woke_at - start, Duration::from_secs(60));
+        assert!(!clock.wait_for_sleepers(1, Duration::from_millis(10)));
+    }
+}
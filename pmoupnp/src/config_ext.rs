@@ -5,15 +5,32 @@
 //!
 //! Il suit le même pattern que pmocache/src/config_ext.rs pour la cohérence.
 
-use anyhow::Result;
+use anyhow::{Result, anyhow};
 use pmoconfig::Config;
 use serde_yaml::Value;
+use std::time::Duration;
+
+use crate::features::FeatureRule;
+use crate::policies::DevicePolicy;
+use crate::quirks::QuirkRule;
+use crate::ssdp::{IpNet, SsdpSettings};
+use crate::xml_format::XmlOptions;
 
 // Constantes par défaut pour les noms UPnP
 const DEFAULT_MANUFACTURER: &str = "PMOMusic";
 const DEFAULT_UDN_PREFIX: &str = "pmomusic";
 const DEFAULT_MODEL_NAME_PREFIX: &str = "PMOMusic";
 const DEFAULT_FRIENDLY_NAME_PREFIX: &str = "PMOMusic";
+const DEFAULT_SSDP_ENABLED: bool = true;
+const DEFAULT_LOG_PAYLOADS: bool = false;
+const DEFAULT_HTTP_CACHE_CONTROL: &str = "max-age=1800";
+const DEFAULT_AUDIT_CAPACITY: usize = 500;
+const DEFAULT_STATE_HISTORY_CAPACITY: usize = 32;
+const DEFAULT_STATE_HISTORY_MAX_AGE_SECS: usize = 600;
+const DEFAULT_EVENT_MAX_CONCURRENT_DELIVERIES: usize = 16;
+const DEFAULT_EVENT_DELIVERY_TIMEOUT_SECS: usize = 10;
+const DEFAULT_SLEEP_DETECTION_ENABLED: bool = true;
+const DEFAULT_SLEEP_DETECTION_THRESHOLD: Duration = Duration::from_secs(10);
 
 /// Trait d'extension pour ajouter la configuration UPnP à pmoconfig
 ///
@@ -70,6 +87,203 @@ pub trait UpnpConfigExt {
 
     /// Définit le préfixe pour les noms conviviaux des devices UPnP
     fn set_upnp_friendly_name_prefix(&self, prefix: String) -> Result<()>;
+
+    /// Indique si les annonces SSDP sont activées
+    ///
+    /// Désactiver SSDP permet de servir uniquement HTTP, par exemple derrière
+    /// un reverse proxy ou dans un conteneur sans accès au multicast.
+    ///
+    /// # Returns
+    ///
+    /// `true` si SSDP doit être démarré (défaut: `true`)
+    fn get_ssdp_enabled(&self) -> Result<bool>;
+
+    /// Active ou désactive les annonces SSDP
+    fn set_ssdp_enabled(&self, enabled: bool) -> Result<()>;
+
+    /// Indique si les payloads SOAP/SSDP complets sont journalisés
+    ///
+    /// Les payloads sont rendus en Markdown au niveau TRACE, avec les URLs
+    /// de callback et les jetons masqués.
+    ///
+    /// # Returns
+    ///
+    /// `true` si les payloads doivent être journalisés (défaut: `false`)
+    fn get_upnp_log_payloads(&self) -> Result<bool>;
+
+    /// Active ou désactive la journalisation des payloads
+    fn set_upnp_log_payloads(&self, enabled: bool) -> Result<()>;
+
+    /// Récupère les règles de contournement par client (`host.upnp.quirks`)
+    ///
+    /// # Returns
+    ///
+    /// Les règles dans l'ordre de la configuration (défaut: aucune)
+    fn get_upnp_quirks(&self) -> Result<Vec<QuirkRule>>;
+
+    /// Définit les règles de contournement par client
+    fn set_upnp_quirks(&self, rules: Vec<QuirkRule>) -> Result<()>;
+
+    /// Récupère les règles de désactivation de services et d'actions
+    /// (`host.upnp.features`)
+    ///
+    /// # Returns
+    ///
+    /// Les règles dans l'ordre de la configuration (défaut: aucune)
+    fn get_upnp_features(&self) -> Result<Vec<FeatureRule>>;
+
+    /// Définit les règles de désactivation de services et d'actions
+    fn set_upnp_features(&self, rules: Vec<FeatureRule>) -> Result<()>;
+
+    /// Récupère les restrictions de volume et d'heures par device
+    /// (`host.upnp.policies`)
+    ///
+    /// # Returns
+    ///
+    /// Les règles dans l'ordre de la configuration (défaut: aucune)
+    fn get_upnp_policies(&self) -> Result<Vec<DevicePolicy>>;
+
+    /// Définit les restrictions de volume et d'heures par device
+    fn set_upnp_policies(&self, policies: Vec<DevicePolicy>) -> Result<()>;
+
+    /// Récupère l'en-tête `Server` par défaut des réponses HTTP
+    ///
+    /// # Returns
+    ///
+    /// La valeur configurée, ou `None` pour la chaîne automatique
+    /// `<OS>/<version> UPnP/1.1 <fabricant>/1.0` (défaut)
+    fn get_upnp_http_server_header(&self) -> Result<Option<String>>;
+
+    /// Définit l'en-tête `Server` par défaut (`None` : chaîne automatique)
+    fn set_upnp_http_server_header(&self, server: Option<String>) -> Result<()>;
+
+    /// Récupère l'en-tête `Cache-Control` par défaut des descriptions et SCPD
+    ///
+    /// # Returns
+    ///
+    /// Les directives (défaut: `max-age=1800`), une chaîne vide désactivant
+    /// l'en-tête
+    fn get_upnp_http_cache_control(&self) -> Result<String>;
+
+    /// Définit l'en-tête `Cache-Control` par défaut
+    fn set_upnp_http_cache_control(&self, directives: String) -> Result<()>;
+
+    /// Récupère la taille du journal d'audit des actions (`host.upnp.audit.capacity`)
+    ///
+    /// # Returns
+    ///
+    /// Le nombre d'invocations conservées (défaut: 500, 0 désactive le journal)
+    fn get_upnp_audit_capacity(&self) -> Result<usize>;
+
+    /// Définit la taille du journal d'audit des actions
+    fn set_upnp_audit_capacity(&self, capacity: usize) -> Result<()>;
+
+    /// Récupère le nombre de valeurs gardées par variable événementielle
+    /// (`host.upnp.state_history.capacity`)
+    ///
+    /// # Returns
+    ///
+    /// Le nombre d'entrées (défaut: 32, 0 désactive l'historique)
+    fn get_upnp_state_history_capacity(&self) -> Result<usize>;
+
+    /// Définit le nombre de valeurs gardées par variable
+    fn set_upnp_state_history_capacity(&self, capacity: usize) -> Result<()>;
+
+    /// Récupère l'âge maximal des entrées d'historique, en secondes
+    ///
+    /// # Returns
+    ///
+    /// L'âge maximal (défaut: 600)
+    fn get_upnp_state_history_max_age_secs(&self) -> Result<usize>;
+
+    /// Définit l'âge maximal des entrées d'historique
+    fn set_upnp_state_history_max_age_secs(&self, secs: usize) -> Result<()>;
+
+    /// Récupère les options de sérialisation XML (`host.upnp.xml`)
+    ///
+    /// # Returns
+    ///
+    /// Les options, les clés absentes prenant leur valeur par défaut
+    /// (indentation active, ordre non imposé, mode complet)
+    fn get_upnp_xml_options(&self) -> Result<XmlOptions>;
+
+    /// Définit les options de sérialisation XML
+    fn set_upnp_xml_options(&self, options: XmlOptions) -> Result<()>;
+
+    /// Récupère le nombre maximal de NOTIFY GENA envoyés simultanément
+    /// (`host.upnp.events.max_concurrent_deliveries`)
+    ///
+    /// # Returns
+    ///
+    /// La limite, commune à tous les services (défaut: 16)
+    fn get_upnp_event_max_concurrent_deliveries(&self) -> Result<usize>;
+
+    /// Définit le nombre maximal de NOTIFY GENA simultanés
+    fn set_upnp_event_max_concurrent_deliveries(&self, limit: usize) -> Result<()>;
+
+    /// Récupère le délai maximal d'envoi d'un NOTIFY GENA
+    /// (`host.upnp.events.delivery_timeout_secs`)
+    ///
+    /// # Returns
+    ///
+    /// Le délai en secondes (défaut: 10)
+    fn get_upnp_event_delivery_timeout_secs(&self) -> Result<usize>;
+
+    /// Définit le délai maximal d'envoi d'un NOTIFY GENA
+    fn set_upnp_event_delivery_timeout_secs(&self, secs: usize) -> Result<()>;
+
+    /// Indique si les sorties de veille du système sont détectées
+    /// (`host.upnp.sleep_detection.enabled`, voir [`crate::power`])
+    ///
+    /// # Returns
+    ///
+    /// `true` si la surveillance doit tourner (défaut: `true`)
+    fn get_upnp_sleep_detection_enabled(&self) -> Result<bool>;
+
+    /// Active ou désactive la détection des sorties de veille
+    fn set_upnp_sleep_detection_enabled(&self, enabled: bool) -> Result<()>;
+
+    /// Récupère l'écart d'horloge qui signale une veille
+    /// (`host.upnp.sleep_detection.threshold`)
+    ///
+    /// # Returns
+    ///
+    /// Le seuil (défaut: 10 s)
+    fn get_upnp_sleep_detection_threshold(&self) -> Result<Duration>;
+
+    /// Récupère les paramètres d'annonce SSDP (`host.ssdp`)
+    ///
+    /// # Returns
+    ///
+    /// max-age, intervalle et gigue des alive, TTL multicast, en-tête
+    /// SERVER imposé, rafale initiale, plafond du délai de réponse aux
+    /// M-SEARCH et interfaces servies (liste YAML ou chaîne séparée par des
+    /// virgules) ; valeurs par défaut pour les clés absentes
+    fn get_ssdp_settings(&self) -> Result<SsdpSettings>;
+
+    /// Définit les paramètres d'annonce SSDP (pris en compte au démarrage)
+    fn set_ssdp_settings(&self, settings: &SsdpSettings) -> Result<()>;
+
+    /// Récupère le dernier BOOTID.UPNP.ORG annoncé (`host.ssdp.boot_id`)
+    ///
+    /// # Returns
+    ///
+    /// Le BOOTID du démarrage précédent, ou 0 s'il n'a jamais été conservé
+    fn get_ssdp_boot_id(&self) -> Result<u32>;
+
+    /// Conserve le BOOTID.UPNP.ORG annoncé
+    fn set_ssdp_boot_id(&self, id: u32) -> Result<()>;
+
+    /// Récupère le CONFIGID.UPNP.ORG d'un device (`host.upnp.config_ids.<udn>`)
+    ///
+    /// # Returns
+    ///
+    /// Le numéro et l'empreinte de la description à laquelle il
+    /// correspond, `None` si le device n'a encore jamais été annoncé
+    fn get_upnp_config_id(&self, udn: &str) -> Result<Option<(u32, String)>>;
+
+    /// Conserve le CONFIGID.UPNP.ORG d'un device et l'empreinte de sa description
+    fn set_upnp_config_id(&self, udn: &str, id: u32, fingerprint: &str) -> Result<()>;
 }
 
 impl UpnpConfigExt for Config {
@@ -125,4 +339,361 @@ impl UpnpConfigExt for Config {
             Value::String(prefix),
         )
     }
+
+    fn get_ssdp_enabled(&self) -> Result<bool> {
+        match self.get_value(&["host", "ssdp", "enabled"]) {
+            Ok(Value::Bool(b)) => Ok(b),
+            Ok(Value::String(s)) => Ok(!matches!(
+                s.trim().to_lowercase().as_str(),
+                "false" | "0" | "no" | "off"
+            )),
+            _ => Ok(DEFAULT_SSDP_ENABLED),
+        }
+    }
+
+    fn set_ssdp_enabled(&self, enabled: bool) -> Result<()> {
+        self.set_value(&["host", "ssdp", "enabled"], Value::Bool(enabled))
+    }
+
+    fn get_upnp_log_payloads(&self) -> Result<bool> {
+        match self.get_value(&["host", "upnp", "log_payloads"]) {
+            Ok(Value::Bool(b)) => Ok(b),
+            _ => Ok(DEFAULT_LOG_PAYLOADS),
+        }
+    }
+
+    fn set_upnp_log_payloads(&self, enabled: bool) -> Result<()> {
+        self.set_value(&["host", "upnp", "log_payloads"], Value::Bool(enabled))
+    }
+
+    fn get_upnp_quirks(&self) -> Result<Vec<QuirkRule>> {
+        match self.get_value(&["host", "upnp", "quirks"]) {
+            Ok(Value::Sequence(rules)) => Ok(serde_yaml::from_value(Value::Sequence(rules))?),
+            _ => Ok(Vec::new()),
+        }
+    }
+
+    fn set_upnp_quirks(&self, rules: Vec<QuirkRule>) -> Result<()> {
+        self.set_value(&["host", "upnp", "quirks"], serde_yaml::to_value(rules)?)
+    }
+
+    fn get_upnp_features(&self) -> Result<Vec<FeatureRule>> {
+        match self.get_value(&["host", "upnp", "features"]) {
+            Ok(Value::Sequence(rules)) => Ok(serde_yaml::from_value(Value::Sequence(rules))?),
+            _ => Ok(Vec::new()),
+        }
+    }
+
+    fn set_upnp_features(&self, rules: Vec<FeatureRule>) -> Result<()> {
+        self.set_value(&["host", "upnp", "features"], serde_yaml::to_value(rules)?)
+    }
+
+    fn get_upnp_policies(&self) -> Result<Vec<DevicePolicy>> {
+        match self.get_value(&["host", "upnp", "policies"]) {
+            Ok(Value::Sequence(policies)) => Ok(serde_yaml::from_value(Value::Sequence(policies))?),
+            _ => Ok(Vec::new()),
+        }
+    }
+
+    fn set_upnp_policies(&self, policies: Vec<DevicePolicy>) -> Result<()> {
+        self.set_value(
+            &["host", "upnp", "policies"],
+            serde_yaml::to_value(policies)?,
+        )
+    }
+
+    fn get_upnp_http_server_header(&self) -> Result<Option<String>> {
+        match self.get_value(&["host", "upnp", "http", "server_header"]) {
+            Ok(Value::String(s)) if !s.trim().is_empty() => Ok(Some(s)),
+            _ => Ok(None),
+        }
+    }
+
+    fn set_upnp_http_server_header(&self, server: Option<String>) -> Result<()> {
+        self.set_value(
+            &["host", "upnp", "http", "server_header"],
+            Value::String(server.unwrap_or_default()),
+        )
+    }
+
+    fn get_upnp_http_cache_control(&self) -> Result<String> {
+        match self.get_value(&["host", "upnp", "http", "cache_control"]) {
+            Ok(Value::String(s)) => Ok(s),
+            _ => Ok(DEFAULT_HTTP_CACHE_CONTROL.to_string()),
+        }
+    }
+
+    fn set_upnp_http_cache_control(&self, directives: String) -> Result<()> {
+        self.set_value(
+            &["host", "upnp", "http", "cache_control"],
+            Value::String(directives),
+        )
+    }
+
+    fn get_upnp_audit_capacity(&self) -> Result<usize> {
+        match self.get_value(&["host", "upnp", "audit", "capacity"]) {
+            Ok(Value::Number(n)) => Ok(n.as_u64().map_or(DEFAULT_AUDIT_CAPACITY, |n| n as usize)),
+            _ => Ok(DEFAULT_AUDIT_CAPACITY),
+        }
+    }
+
+    fn set_upnp_audit_capacity(&self, capacity: usize) -> Result<()> {
+        self.set_value(
+            &["host", "upnp", "audit", "capacity"],
+            Value::Number(capacity.into()),
+        )
+    }
+
+    fn get_upnp_state_history_capacity(&self) -> Result<usize> {
+        match self.get_value(&["host", "upnp", "state_history", "capacity"]) {
+            Ok(Value::Number(n)) => Ok(n
+                .as_u64()
+                .map_or(DEFAULT_STATE_HISTORY_CAPACITY, |n| n as usize)),
+            _ => Ok(DEFAULT_STATE_HISTORY_CAPACITY),
+        }
+    }
+
+    fn set_upnp_state_history_capacity(&self, capacity: usize) -> Result<()> {
+        self.set_value(
+            &["host", "upnp", "state_history", "capacity"],
+            Value::Number(capacity.into()),
+        )
+    }
+
+    fn get_upnp_state_history_max_age_secs(&self) -> Result<usize> {
+        match self.get_value(&["host", "upnp", "state_history", "max_age_secs"]) {
+            Ok(Value::Number(n)) => Ok(n
+                .as_u64()
+                .map_or(DEFAULT_STATE_HISTORY_MAX_AGE_SECS, |n| n as usize)),
+            _ => Ok(DEFAULT_STATE_HISTORY_MAX_AGE_SECS),
+        }
+    }
+
+    fn set_upnp_state_history_max_age_secs(&self, secs: usize) -> Result<()> {
+        self.set_value(
+            &["host", "upnp", "state_history", "max_age_secs"],
+            Value::Number(secs.into()),
+        )
+    }
+
+    fn get_upnp_xml_options(&self) -> Result<XmlOptions> {
+        match self.get_value(&["host", "upnp", "xml"]) {
+            Ok(value @ Value::Mapping(_)) => Ok(serde_yaml::from_value(value)?),
+            _ => Ok(XmlOptions::default()),
+        }
+    }
+
+    fn set_upnp_xml_options(&self, options: XmlOptions) -> Result<()> {
+        self.set_value(&["host", "upnp", "xml"], serde_yaml::to_value(options)?)
+    }
+
+    fn get_upnp_event_max_concurrent_deliveries(&self) -> Result<usize> {
+        Ok(self.get_uint(
+            &["host", "upnp", "events", "max_concurrent_deliveries"],
+            DEFAULT_EVENT_MAX_CONCURRENT_DELIVERIES as u64,
+        )? as usize)
+    }
+
+    fn set_upnp_event_max_concurrent_deliveries(&self, limit: usize) -> Result<()> {
+        self.set_value(
+            &["host", "upnp", "events", "max_concurrent_deliveries"],
+            Value::from(limit),
+        )
+    }
+
+    fn get_upnp_event_delivery_timeout_secs(&self) -> Result<usize> {
+        Ok(self.get_uint(
+            &["host", "upnp", "events", "delivery_timeout_secs"],
+            DEFAULT_EVENT_DELIVERY_TIMEOUT_SECS as u64,
+        )? as usize)
+    }
+
+    fn set_upnp_event_delivery_timeout_secs(&self, secs: usize) -> Result<()> {
+        self.set_value(
+            &["host", "upnp", "events", "delivery_timeout_secs"],
+            Value::from(secs),
+        )
+    }
+
+    fn get_upnp_sleep_detection_enabled(&self) -> Result<bool> {
+        self.get_bool(
+            &["host", "upnp", "sleep_detection", "enabled"],
+            DEFAULT_SLEEP_DETECTION_ENABLED,
+        )
+    }
+
+    fn set_upnp_sleep_detection_enabled(&self, enabled: bool) -> Result<()> {
+        self.set_value(
+            &["host", "upnp", "sleep_detection", "enabled"],
+            Value::Bool(enabled),
+        )
+    }
+
+    fn get_upnp_sleep_detection_threshold(&self) -> Result<Duration> {
+        self.get_duration(
+            &["host", "upnp", "sleep_detection", "threshold"],
+            DEFAULT_SLEEP_DETECTION_THRESHOLD,
+        )
+    }
+
+    fn get_ssdp_settings(&self) -> Result<SsdpSettings> {
+        let defaults = SsdpSettings::default();
+        let max_age = self.get_uint(&["host", "ssdp", "max_age"], defaults.max_age as u64)?;
+        let announce_interval = match self.get_string(&["host", "ssdp", "announce_interval"], "")? {
+            s if s.trim().is_empty() => None,
+            _ => Some(self.get_duration(&["host", "ssdp", "announce_interval"], Duration::ZERO)?),
+        };
+        let server = self.get_string(&["host", "ssdp", "server"], "")?;
+        let list = |key: &str| -> Vec<String> {
+            match self.get_value(&["host", "ssdp", key]) {
+                Ok(Value::Sequence(items)) => items
+                    .iter()
+                    .filter_map(|v| v.as_str().map(|s| s.trim().to_string()))
+                    .filter(|s| !s.is_empty())
+                    .collect(),
+                Ok(Value::String(s)) => s
+                    .split(',')
+                    .map(str::trim)
+                    .filter(|s| !s.is_empty())
+                    .map(String::from)
+                    .collect(),
+                _ => Vec::new(),
+            }
+        };
+        let networks = |key: &str| -> Result<Vec<IpNet>> {
+            list(key)
+                .iter()
+                .map(|s| {
+                    s.parse::<IpNet>()
+                        .map_err(|e| anyhow!("host.ssdp.{}: {}", key, e))
+                })
+                .collect()
+        };
+        Ok(SsdpSettings {
+            max_age: u32::try_from(max_age)?,
+            announce_interval,
+            jitter: self.get_float(&["host", "ssdp", "jitter"], defaults.jitter)?,
+            multicast_ttl: u32::try_from(self.get_uint(
+                &["host", "ssdp", "multicast_ttl"],
+                defaults.multicast_ttl as u64,
+            )?)?,
+            server: (!server.trim().is_empty()).then_some(server),
+            initial_burst: u32::try_from(self.get_uint(
+                &["host", "ssdp", "initial_burst"],
+                defaults.initial_burst as u64,
+            )?)?,
+            notify_repeat: u32::try_from(self.get_uint(
+                &["host", "ssdp", "notify_repeat"],
+                defaults.notify_repeat as u64,
+            )?)?,
+            burst_spacing: self
+                .get_duration(&["host", "ssdp", "burst_spacing"], defaults.burst_spacing)?,
+            max_response_delay: self.get_duration(
+                &["host", "ssdp", "max_response_delay"],
+                defaults.max_response_delay,
+            )?,
+            interfaces: list("interfaces"),
+            ipv6: self.get_ipv6_enabled()?,
+            msearch_rate: self
+                .get_float(&["host", "ssdp", "msearch_rate"], defaults.msearch_rate)?,
+            msearch_burst: u32::try_from(self.get_uint(
+                &["host", "ssdp", "msearch_burst"],
+                defaults.msearch_burst as u64,
+            )?)?,
+            allow: networks("allow")?,
+            deny: networks("deny")?,
+        })
+    }
+
+    fn set_ssdp_settings(&self, settings: &SsdpSettings) -> Result<()> {
+        let interval = settings
+            .announce_interval
+            .map(|d| format!("{}s", d.as_secs()))
+            .unwrap_or_default();
+        self.set_value(&["host", "ssdp", "max_age"], Value::from(settings.max_age))?;
+        self.set_value(
+            &["host", "ssdp", "announce_interval"],
+            Value::String(interval),
+        )?;
+        self.set_value(&["host", "ssdp", "jitter"], Value::from(settings.jitter))?;
+        self.set_value(
+            &["host", "ssdp", "multicast_ttl"],
+            Value::from(settings.multicast_ttl),
+        )?;
+        self.set_value(
+            &["host", "ssdp", "server"],
+            Value::String(settings.server.clone().unwrap_or_default()),
+        )?;
+        self.set_value(
+            &["host", "ssdp", "initial_burst"],
+            Value::from(settings.initial_burst),
+        )?;
+        self.set_value(
+            &["host", "ssdp", "notify_repeat"],
+            Value::from(settings.notify_repeat),
+        )?;
+        self.set_value(
+            &["host", "ssdp", "burst_spacing"],
+            Value::String(format!("{}ms", settings.burst_spacing.as_millis())),
+        )?;
+        self.set_value(
+            &["host", "ssdp", "max_response_delay"],
+            Value::String(format!("{}ms", settings.max_response_delay.as_millis())),
+        )?;
+        self.set_value(
+            &["host", "ssdp", "interfaces"],
+            Value::Sequence(
+                settings
+                    .interfaces
+                    .iter()
+                    .map(|s| Value::String(s.clone()))
+                    .collect(),
+            ),
+        )?;
+        self.set_value(
+            &["host", "ssdp", "msearch_rate"],
+            Value::from(settings.msearch_rate),
+        )?;
+        self.set_value(
+            &["host", "ssdp", "msearch_burst"],
+            Value::from(settings.msearch_burst),
+        )?;
+        for (key, nets) in [("allow", &settings.allow), ("deny", &settings.deny)] {
+            self.set_value(
+                &["host", "ssdp", key],
+                Value::Sequence(nets.iter().map(|n| Value::String(n.to_string())).collect()),
+            )?;
+        }
+        self.set_ipv6_enabled(settings.ipv6)
+    }
+
+    fn get_ssdp_boot_id(&self) -> Result<u32> {
+        Ok(u32::try_from(
+            self.get_uint(&["host", "ssdp", "boot_id"], 0)?,
+        )?)
+    }
+
+    fn set_ssdp_boot_id(&self, id: u32) -> Result<()> {
+        self.set_value(&["host", "ssdp", "boot_id"], Value::from(id))
+    }
+
+    fn get_upnp_config_id(&self, udn: &str) -> Result<Option<(u32, String)>> {
+        if !matches!(
+            self.get_value(&["host", "upnp", "config_ids", udn]),
+            Ok(Value::Mapping(_))
+        ) {
+            return Ok(None);
+        }
+        let id = self.get_uint(&["host", "upnp", "config_ids", udn, "id"], 0)?;
+        let fingerprint =
+            self.get_string(&["host", "upnp", "config_ids", udn, "fingerprint"], "")?;
+        Ok(Some((u32::try_from(id)?, fingerprint)))
+    }
+
+    fn set_upnp_config_id(&self, udn: &str, id: u32, fingerprint: &str) -> Result<()> {
+        let mut entry = serde_yaml::Mapping::new();
+        entry.insert(Value::from("id"), Value::from(id));
+        entry.insert(Value::from("fingerprint"), Value::from(fingerprint));
+        self.set_value(&["host", "upnp", "config_ids", udn], Value::Mapping(entry))
+    }
 }
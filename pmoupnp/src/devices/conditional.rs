@@ -0,0 +1,130 @@
+//! Requêtes conditionnelles sur les descriptions et les SCPD
+//!
+//! Beaucoup de points de contrôle relisent la description du device et les
+//! SCPD toutes les quelques minutes. Ces documents ne changent qu'avec la
+//! configuration : chaque réponse porte un `ETag` (empreinte du XML) et un
+//! `Last-Modified` (premier service de cette version du document), et une
+//! requête `If-None-Match` / `If-Modified-Since` qui correspond reçoit un
+//! `304 Not Modified` sans corps.
+
+use std::hash::{DefaultHasher, Hash, Hasher};
+
+use axum::{
+    http::{HeaderMap, HeaderValue, StatusCode, header},
+    response::{IntoResponse, Response},
+};
+use chrono::{DateTime, SubsecRound, Utc};
+
+use super::description_cache::CachedXml;
+
+/// Validateurs HTTP d'une version d'un document XML
+#[derive(Debug, Clone, PartialEq)]
+pub(crate) struct Validators {
+    /// ETag fort, entre guillemets
+    pub etag: String,
+    /// Date du premier service de cette version (à la seconde)
+    pub last_modified: DateTime<Utc>,
+}
+
+impl Validators {
+    /// Validateurs du document `xml`, rendu à nouveau après `previous`.
+    ///
+    /// La date de modification n'avance que lorsque le contenu change.
+    pub fn of(xml: &[u8], previous: Option<&Validators>) -> Self {
+        let mut hasher = DefaultHasher::new();
+        xml.hash(&mut hasher);
+        let etag = format!("\"{:016x}\"", hasher.finish());
+
+        match previous {
+            Some(previous) if previous.etag == etag => previous.clone(),
+            _ => Self {
+                etag,
+                last_modified: Utc::now().trunc_subsecs(0),
+            },
+        }
+    }
+
+    /// La copie du client (en-têtes de sa requête) est-elle à jour ?
+    ///
+    /// `If-None-Match` prime sur `If-Modified-Since` (RFC 9110 §13.2.2).
+    pub fn is_fresh(&self, request: &HeaderMap) -> bool {
+        if let Some(tags) = request
+            .get(header::IF_NONE_MATCH)
+            .and_then(|v| v.to_str().ok())
+        {
+            return tags
+                .split(',')
+                .map(str::trim)
+                .any(|tag| tag == "*" || tag.strip_prefix("W/").unwrap_or(tag) == self.etag);
+        }
+
+        request
+            .get(header::IF_MODIFIED_SINCE)
+            .and_then(|v| v.to_str().ok())
+            .and_then(|date| DateTime::parse_from_rfc2822(date).ok())
+            .is_some_and(|since| self.last_modified <= since)
+    }
+
+    /// Ajoute `ETag` et `Last-Modified` à une réponse.
+    pub fn apply(&self, response: &mut Response) {
+        let headers = response.headers_mut();
+        if let Ok(etag) = HeaderValue::from_str(&self.etag) {
+            headers.insert(header::ETAG, etag);
+        }
+        let date = self.last_modified.format("%a, %d %b %Y %H:%M:%S GMT");
+        if let Ok(date) = HeaderValue::from_str(&date.to_string()) {
+            headers.insert(header::LAST_MODIFIED, date);
+        }
+    }
+}
+
+/// Réponse XML tenant compte des en-têtes conditionnels de la requête :
+/// `304 Not Modified` si la copie du client est à jour, sinon `200 OK`
+/// avec le document. Les deux portent `ETag` et `Last-Modified`.
+pub(crate) fn conditional_xml_response(request: &HeaderMap, document: CachedXml) -> Response {
+    let validators = document.validators;
+    let mut response = if validators.is_fresh(request) {
+        StatusCode::NOT_MODIFIED.into_response()
+    } else {
+        super::device_instance::xml_response(document.xml)
+    };
+    validators.apply(&mut response);
+    response
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn validators_follow_document_changes() {
+        let first = Validators::of(b"<root/>", None);
+        assert_eq!(Validators::of(b"<root/>", Some(&first)), first);
+        assert_ne!(
+            Validators::of(b"<root></root>", Some(&first)).etag,
+            first.etag
+        );
+
+        let mut request = HeaderMap::new();
+        assert!(!first.is_fresh(&request));
+
+        request.insert(
+            header::IF_NONE_MATCH,
+            HeaderValue::from_static("\"0\", W/\"1\""),
+        );
+        assert!(!first.is_fresh(&request));
+        request.insert(
+            header::IF_NONE_MATCH,
+            HeaderValue::from_str(&format!("\"0\", W/{}", first.etag)).unwrap(),
+        );
+        assert!(first.is_fresh(&request));
+
+        let mut request = HeaderMap::new();
+        let date = first.last_modified.format("%a, %d %b %Y %H:%M:%S GMT");
+        request.insert(
+            header::IF_MODIFIED_SINCE,
+            HeaderValue::from_str(&date.to_string()).unwrap(),
+        );
+        assert!(first.is_fresh(&request));
+    }
+}
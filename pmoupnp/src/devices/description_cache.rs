@@ -0,0 +1,118 @@
+//! Cache des descriptions de devices et des SCPD
+//!
+//! Sérialiser l'arbre `xmltree` d'une description ou d'un SCPD à chaque
+//! requête coûte bien plus que de renvoyer des octets déjà prêts. Le XML
+//! rendu est conservé par route, avec ses validateurs HTTP, et étiqueté par
+//! la génération sous laquelle il a été produit :
+//!
+//! - la génération de la configuration ([`pmoconfig::Config::generation`]),
+//!   qui avance à chaque écriture de la configuration ;
+//! - la génération des descriptions, qui avance à chaque ajout de service ou
+//!   de sous-device, et à chaque changement des règles de désactivation ou
+//!   des options de sérialisation (voir [`invalidate_descriptions`]).
+//!
+//! Un document rendu sous une génération dépassée est simplement recalculé
+//! à la requête suivante.
+
+use std::{
+    collections::HashMap,
+    sync::{
+        RwLock,
+        atomic::{AtomicU64, Ordering},
+    },
+};
+
+use bytes::Bytes;
+use once_cell::sync::Lazy;
+
+use super::conditional::Validators;
+
+/// Génération des descriptions (topologie, règles, options XML)
+static GENERATION: AtomicU64 = AtomicU64::new(0);
+
+/// Documents rendus, par route
+static CACHE: Lazy<RwLock<HashMap<String, CachedXml>>> = Lazy::new(|| RwLock::new(HashMap::new()));
+
+/// Document XML rendu et ses validateurs HTTP
+#[derive(Debug, Clone)]
+pub(crate) struct CachedXml {
+    /// Génération (configuration, descriptions) du rendu
+    generation: (u64, u64),
+    /// Document sérialisé
+    pub xml: Bytes,
+    /// `ETag` et `Last-Modified` du document
+    pub validators: Validators,
+}
+
+/// Invalide toutes les descriptions et SCPD en cache.
+///
+/// À appeler après toute modification qui change le XML servi sans passer
+/// par la configuration.
+pub fn invalidate_descriptions() {
+    GENERATION.fetch_add(1, Ordering::AcqRel);
+}
+
+fn current_generation() -> (u64, u64) {
+    (
+        pmoconfig::get_config().generation(),
+        GENERATION.load(Ordering::Acquire),
+    )
+}
+
+/// Document servi sur `route`, rendu par `render` si le cache est vide ou
+/// dépassé.
+///
+/// `Last-Modified` n'avance que si le nouveau rendu diffère de l'ancien.
+pub(crate) fn cached_xml<E>(
+    route: &str,
+    render: impl FnOnce() -> Result<String, E>,
+) -> Result<CachedXml, E> {
+    cached_xml_at(route, current_generation(), render)
+}
+
+fn cached_xml_at<E>(
+    route: &str,
+    generation: (u64, u64),
+    render: impl FnOnce() -> Result<String, E>,
+) -> Result<CachedXml, E> {
+    let previous = CACHE.read().unwrap().get(route).cloned();
+    if let Some(cached) = &previous
+        && cached.generation == generation
+    {
+        return Ok(cached.clone());
+    }
+
+    let xml = Bytes::from(render()?);
+    let cached = CachedXml {
+        generation,
+        validators: Validators::of(&xml, previous.as_ref().map(|c| &c.validators)),
+        xml,
+    };
+    CACHE
+        .write()
+        .unwrap()
+        .insert(route.to_string(), cached.clone());
+    Ok(cached)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn new_generation_triggers_a_new_render() {
+        let route = "/device/cache-test/desc.xml";
+        let render = |xml: &'static str| move || Ok::<_, ()>(xml.to_string());
+
+        let first = cached_xml_at(route, (0, 0), render("<root/>")).unwrap();
+        let cached = cached_xml_at(route, (0, 0), render("<other/>")).unwrap();
+        assert_eq!(cached.xml, first.xml);
+
+        let same = cached_xml_at(route, (0, 1), render("<root/>")).unwrap();
+        assert_eq!(same.validators, first.validators);
+
+        let changed = cached_xml_at(route, (1, 1), render("<other/>")).unwrap();
+        assert_eq!(changed.xml, "<other/>");
+        assert_ne!(changed.validators.etag, first.validators.etag);
+    }
+}
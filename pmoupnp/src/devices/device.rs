@@ -49,6 +49,9 @@ pub struct Device {
     /// UDN (Unique Device Name) - sera généré à l'instance
     udn_prefix: String,
 
+    /// UDN fixé par l'appelant ; sinon l'instance le lit (ou le crée) dans la configuration
+    udn: Option<String>,
+
     /// UPC (Universal Product Code)
     upc: Option<String>,
 
@@ -58,6 +61,12 @@ pub struct Device {
     /// URL de présentation
     presentation_url: Option<String>,
 
+    /// En-tête `Server` des réponses HTTP (défaut : `host.upnp.http.server_header`)
+    server_header: Option<String>,
+
+    /// En-tête `Cache-Control` des descriptions (défaut : `host.upnp.http.cache_control`)
+    cache_control: Option<String>,
+
     /// Services du device
     services: RwLock<HashMap<String, Arc<Service>>>,
 
@@ -80,9 +89,12 @@ impl Clone for Device {
             model_url: self.model_url.clone(),
             serial_number: self.serial_number.clone(),
             udn_prefix: self.udn_prefix.clone(),
+            udn: self.udn.clone(),
             upc: self.upc.clone(),
             icon_url: self.icon_url.clone(),
             presentation_url: self.presentation_url.clone(),
+            server_header: self.server_header.clone(),
+            cache_control: self.cache_control.clone(),
             services: RwLock::new(self.services.read().unwrap().clone()),
             devices: RwLock::new(self.devices.read().unwrap().clone()),
         }
@@ -114,9 +126,12 @@ impl Device {
             model_url: None,
             serial_number: None,
             udn_prefix: "pmomusic".to_string(),
+            udn: None,
             upc: None,
             icon_url: None,
             presentation_url: None,
+            server_header: None,
+            cache_control: None,
             services: RwLock::new(HashMap::new()),
             devices: RwLock::new(HashMap::new()),
         }
@@ -192,9 +207,12 @@ impl Device {
             model_url: None,
             serial_number: None,
             udn_prefix,
+            udn: None,
             upc: None,
             icon_url: None,
             presentation_url: None,
+            server_header: None,
+            cache_control: None,
             services: RwLock::new(HashMap::new()),
             devices: RwLock::new(HashMap::new()),
         }
@@ -268,11 +286,44 @@ impl Device {
         &self.udn_prefix
     }
 
+    /// Fixe l'UDN des instances de ce device.
+    ///
+    /// Sans UDN fixé, chaque instance lit (ou génère et persiste) le sien
+    /// dans la configuration globale.
+    pub fn set_udn(&mut self, udn: String) {
+        self.udn = Some(udn);
+    }
+
+    /// Retourne l'UDN fixé, s'il y en a un.
+    pub fn udn(&self) -> Option<&str> {
+        self.udn.as_deref()
+    }
+
     /// Définit l'URL de présentation.
     pub fn set_presentation_url(&mut self, url: String) {
         self.presentation_url = Some(url);
     }
 
+    /// Définit l'en-tête `Server` des réponses HTTP et des annonces SSDP.
+    pub fn set_server_header(&mut self, server: String) {
+        self.server_header = Some(server);
+    }
+
+    /// Retourne l'en-tête `Server` propre à ce device, s'il y en a un.
+    pub fn server_header(&self) -> Option<&str> {
+        self.server_header.as_deref()
+    }
+
+    /// Définit l'en-tête `Cache-Control` de la description et des SCPD.
+    pub fn set_cache_control(&mut self, directives: String) {
+        self.cache_control = Some(directives);
+    }
+
+    /// Retourne l'en-tête `Cache-Control` propre à ce device, s'il y en a un.
+    pub fn cache_control(&self) -> Option<&str> {
+        self.cache_control.as_deref()
+    }
+
     /// Ajoute un service au device.
     ///
     /// # Errors
@@ -0,0 +1,227 @@
+//! Builder de devices UPnP indépendant de la configuration globale.
+
+use std::sync::Arc;
+
+use crate::services::Service;
+
+use super::{Device, errors::DeviceError};
+
+/// Construit un [`Device`] sans passer par `pmoconfig`.
+///
+/// Contrairement à [`Device::new_from_config`], toutes les valeurs sont
+/// fournies par l'appelant. Avec un UDN explicite ([`DeviceBuilder::udn`]),
+/// l'instanciation du device ne lit ni n'écrit la configuration : c'est le
+/// point d'entrée des programmes tiers qui embarquent la pile UPnP.
+///
+/// # Examples
+///
+/// ```rust,ignore
+/// use pmoupnp::devices::DeviceBuilder;
+/// use pmoupnp::services::ServiceBuilder;
+/// use std::sync::Arc;
+///
+/// let device = DeviceBuilder::new("MyRenderer", "MediaRenderer")
+///     .friendly_name("Living Room")
+///     .manufacturer("ACME")
+///     .udn("2f402f80-da50-11e1-9b23-001788a2d9a3")
+///     .service(Arc::new(ServiceBuilder::new("RenderingControl").build()?))
+///     .build()?;
+/// server.register_device(Arc::new(device), true).await?;
+/// ```
+pub struct DeviceBuilder {
+    name: String,
+    device_type: String,
+    version: u8,
+    friendly_name: Option<String>,
+    manufacturer: Option<String>,
+    manufacturer_url: Option<String>,
+    model_name: Option<String>,
+    model_description: Option<String>,
+    model_number: Option<String>,
+    serial_number: Option<String>,
+    presentation_url: Option<String>,
+    server_header: Option<String>,
+    cache_control: Option<String>,
+    udn: Option<String>,
+    services: Vec<Arc<Service>>,
+    devices: Vec<Arc<Device>>,
+}
+
+impl DeviceBuilder {
+    /// Nouveau builder pour un device `name` de type `device_type`
+    /// (ex: `"MediaRenderer"`)
+    pub fn new(name: impl Into<String>, device_type: impl Into<String>) -> Self {
+        Self {
+            name: name.into(),
+            device_type: device_type.into(),
+            version: 1,
+            friendly_name: None,
+            manufacturer: None,
+            manufacturer_url: None,
+            model_name: None,
+            model_description: None,
+            model_number: None,
+            serial_number: None,
+            presentation_url: None,
+            server_header: None,
+            cache_control: None,
+            udn: None,
+            services: Vec::new(),
+            devices: Vec::new(),
+        }
+    }
+
+    pub fn version(mut self, version: u8) -> Self {
+        self.version = version;
+        self
+    }
+
+    /// Nom affiché par les points de contrôle (défaut : le nom du device)
+    pub fn friendly_name(mut self, friendly_name: impl Into<String>) -> Self {
+        self.friendly_name = Some(friendly_name.into());
+        self
+    }
+
+    pub fn manufacturer(mut self, manufacturer: impl Into<String>) -> Self {
+        self.manufacturer = Some(manufacturer.into());
+        self
+    }
+
+    pub fn manufacturer_url(mut self, url: impl Into<String>) -> Self {
+        self.manufacturer_url = Some(url.into());
+        self
+    }
+
+    pub fn model_name(mut self, model_name: impl Into<String>) -> Self {
+        self.model_name = Some(model_name.into());
+        self
+    }
+
+    pub fn model_description(mut self, description: impl Into<String>) -> Self {
+        self.model_description = Some(description.into());
+        self
+    }
+
+    pub fn model_number(mut self, number: impl Into<String>) -> Self {
+        self.model_number = Some(number.into());
+        self
+    }
+
+    pub fn serial_number(mut self, serial: impl Into<String>) -> Self {
+        self.serial_number = Some(serial.into());
+        self
+    }
+
+    pub fn presentation_url(mut self, url: impl Into<String>) -> Self {
+        self.presentation_url = Some(url.into());
+        self
+    }
+
+    /// En-tête `Server` des réponses HTTP et des annonces SSDP
+    pub fn server_header(mut self, server: impl Into<String>) -> Self {
+        self.server_header = Some(server.into());
+        self
+    }
+
+    /// En-tête `Cache-Control` de la description et des SCPD
+    pub fn cache_control(mut self, directives: impl Into<String>) -> Self {
+        self.cache_control = Some(directives.into());
+        self
+    }
+
+    /// UDN fixe des instances (avec ou sans préfixe `uuid:`)
+    pub fn udn(mut self, udn: impl Into<String>) -> Self {
+        self.udn = Some(udn.into());
+        self
+    }
+
+    pub fn service(mut self, service: Arc<Service>) -> Self {
+        self.services.push(service);
+        self
+    }
+
+    /// Ajoute un sous-device (embedded device)
+    pub fn device(mut self, device: Arc<Device>) -> Self {
+        self.devices.push(device);
+        self
+    }
+
+    /// Construit le device.
+    pub fn build(self) -> Result<Device, DeviceError> {
+        let friendly_name = self.friendly_name.unwrap_or_else(|| self.name.clone());
+        let mut device = Device::new(self.name, self.device_type, friendly_name);
+        device.set_version(self.version)?;
+        if let Some(manufacturer) = self.manufacturer {
+            device.set_manufacturer(manufacturer);
+        }
+        if let Some(url) = self.manufacturer_url {
+            device.set_manufacturer_url(url);
+        }
+        if let Some(model_name) = self.model_name {
+            device.set_model_name(model_name);
+        }
+        if let Some(description) = self.model_description {
+            device.set_model_description(description);
+        }
+        if let Some(number) = self.model_number {
+            device.set_model_number(number);
+        }
+        if let Some(serial) = self.serial_number {
+            device.set_serial_number(serial);
+        }
+        if let Some(url) = self.presentation_url {
+            device.set_presentation_url(url);
+        }
+        if let Some(server) = self.server_header {
+            device.set_server_header(server);
+        }
+        if let Some(directives) = self.cache_control {
+            device.set_cache_control(directives);
+        }
+        if let Some(udn) = self.udn {
+            device.set_udn(udn);
+        }
+        for service in self.services {
+            device.add_service(service)?;
+        }
+        for sub_device in self.devices {
+            device.add_device(sub_device)?;
+        }
+        Ok(device)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::UpnpModel;
+    use crate::services::ServiceBuilder;
+
+    #[test]
+    fn builds_instance_with_fixed_udn() {
+        let service = ServiceBuilder::new("RenderingControl").build().unwrap();
+        let device = DeviceBuilder::new("EmbeddedRenderer", "MediaRenderer")
+            .friendly_name("Living Room")
+            .manufacturer("ACME")
+            .udn("uuid:2f402f80-da50-11e1-9b23-001788a2d9a3")
+            .service(Arc::new(service))
+            .build()
+            .unwrap();
+
+        assert_eq!(device.friendly_name(), "Living Room");
+        assert_eq!(device.manufacturer(), "ACME");
+
+        let instance = device.create_instance();
+        assert_eq!(instance.udn(), "2f402f80-da50-11e1-9b23-001788a2d9a3");
+        assert!(instance.get_service("RenderingControl").is_some());
+
+        let duplicate = DeviceBuilder::new("Broken", "MediaRenderer")
+            .service(Arc::new(ServiceBuilder::new("AVTransport").build().unwrap()))
+            .service(Arc::new(ServiceBuilder::new("AVTransport").build().unwrap()))
+            .build();
+        assert!(matches!(
+            duplicate,
+            Err(DeviceError::ServiceAlreadyExists(_))
+        ));
+    }
+}
@@ -1,25 +1,49 @@
 //! Implémentation de DeviceInstance.
 
 use axum::{
-    http::StatusCode,
+    http::{HeaderMap, HeaderValue, StatusCode, header},
     response::{IntoResponse, Response},
 };
+use bytes::Bytes;
 use std::{
     collections::HashMap,
     sync::{Arc, RwLock},
     time::Duration,
 };
 use tracing::info;
-use xmltree::{Element, EmitterConfig, XMLNode};
+use xmltree::{Element, XMLNode};
 
 use crate::{
-    UpnpInstance, UpnpObject, UpnpObjectType, UpnpTyped, UpnpTypedInstance,
-    devices::{Device, errors::DeviceError},
+    UpnpConfigExt, UpnpInstance, UpnpObject, UpnpObjectType, UpnpTyped, UpnpTypedInstance,
+    devices::{
+        CachedXml, Device, cached_xml, conditional_xml_response, errors::DeviceError,
+        invalidate_descriptions,
+    },
     services::ServiceInstance,
 };
 
 const DEFAULT_NOTIFY_INTERVAL: Duration = Duration::from_secs(1);
 
+/// Version du produit annoncée dans l'en-tête `Server` par défaut
+const SERVER_PRODUCT_VERSION: &str = "1.0";
+
+/// Réponse `200 OK` pour un document XML UTF-8, avec `Content-Length`.
+pub(crate) fn xml_response(xml: impl Into<Bytes>) -> Response {
+    let xml = xml.into();
+    (
+        StatusCode::OK,
+        [
+            (
+                header::CONTENT_TYPE,
+                HeaderValue::from_static("text/xml; charset=\"utf-8\""),
+            ),
+            (header::CONTENT_LENGTH, HeaderValue::from(xml.len())),
+        ],
+        xml,
+    )
+        .into_response()
+}
+
 /// Instance d'un device UPnP.
 ///
 /// Représente une instance concrète d'un device UPnP, avec ses services instanciés
@@ -79,25 +103,33 @@ impl UpnpInstance for DeviceInstance {
     type Model = Device;
 
     fn new(model: &Device) -> Self {
-        // Obtenir ou créer un UDN persistant via la configuration
-        let device_name = model.get_name();
-        let device_type = model.device_category();
-        let udn = match pmoconfig::get_config().get_device_udn(&device_type, device_name) {
-            Ok(config_udn) => Self::normalize_udn(config_udn),
-            Err(err) => {
-                tracing::warn!(
-                    "Failed to get/save UDN from config ({err:?}), using generated UUID"
-                );
-                Self::normalize_udn(uuid::Uuid::new_v4().to_string())
+        let local_ip = pmoutils::guess_local_ip();
+
+        let (udn, server_base_url) = match model.udn() {
+            // UDN fourni par l'appelant : aucune lecture de la configuration.
+            // L'URL de base définitive est posée par register_device().
+            Some(udn) => (Self::normalize_udn(udn), format!("http://{}", local_ip)),
+            None => {
+                // Obtenir ou créer un UDN persistant via la configuration
+                let device_name = model.get_name();
+                let device_type = model.device_category();
+                let udn = match pmoconfig::get_config().get_device_udn(&device_type, device_name) {
+                    Ok(config_udn) => Self::normalize_udn(config_udn),
+                    Err(err) => {
+                        tracing::warn!(
+                            "Failed to get/save UDN from config ({err:?}), using generated UUID"
+                        );
+                        Self::normalize_udn(uuid::Uuid::new_v4().to_string())
+                    }
+                };
+
+                // Obtenir l'IP locale et le port depuis la configuration
+                // TODO: c'est amusant cet instanciation sauvage de base_url
+                let port = pmoconfig::get_config().get_http_port();
+                (udn, format!("http://{}:{}", local_ip, port))
             }
         };
 
-        // Obtenir l'IP locale et le port depuis la configuration
-        // TODO: c'est amusant cet instanciation sauvage de base_url
-        let local_ip = pmoutils::guess_local_ip();
-        let port = pmoconfig::get_config().get_http_port();
-        let server_base_url = format!("http://{}:{}", local_ip, port);
-
         Self {
             object: UpnpObjectType {
                 name: model.get_name().to_string(),
@@ -156,10 +188,17 @@ impl UpnpObject for DeviceInstance {
         elem.children.push(XMLNode::Element(udn));
 
         // serviceList
-        let services = self.services.read().unwrap();
+        let services: Vec<_> = self
+            .services
+            .read()
+            .unwrap()
+            .values()
+            .filter(|service| service.is_enabled())
+            .cloned()
+            .collect();
         if !services.is_empty() {
             let mut service_list = Element::new("serviceList");
-            for service in services.values() {
+            for service in services {
                 service_list
                     .children
                     .push(XMLNode::Element(service.to_xml_element()));
@@ -248,6 +287,7 @@ impl DeviceInstance {
         service.set_device(Arc::clone(self));
 
         services.insert(name, service);
+        invalidate_descriptions();
         Ok(())
     }
 
@@ -271,6 +311,7 @@ impl DeviceInstance {
         }
 
         devices.insert(name, device);
+        invalidate_descriptions();
         Ok(())
     }
 
@@ -292,9 +333,9 @@ impl DeviceInstance {
         // Handler pour la description du device
         let instance_desc = self.clone();
         server
-            .add_handler(&self.description_route(), move || {
+            .add_handler(&self.description_route(), move |headers: HeaderMap| {
                 let instance = instance_desc.clone();
-                async move { instance.description_handler().await }
+                async move { instance.description_handler(&headers).await }
             })
             .await;
 
@@ -343,34 +384,108 @@ impl DeviceInstance {
     }
 
     /// Handler HTTP pour la description du device.
-    async fn description_handler(&self) -> Response {
+    ///
+    /// Répond `304 Not Modified` si la requête conditionnelle du client
+    /// correspond à la description courante.
+    async fn description_handler(&self, request: &HeaderMap) -> Response {
         tracing::info!("📋 Device description requested for {}", self.get_name());
 
-        let elem = self.description_element();
+        match self.cached_description() {
+            Ok(document) => self.xml_response(request, document),
+            Err(e) => {
+                tracing::error!("❌ Failed to serialize device description XML: {}", e);
+                StatusCode::INTERNAL_SERVER_ERROR.into_response()
+            }
+        }
+    }
+
+    /// Description XML sérialisée du device.
+    ///
+    /// Le document est rendu une fois puis servi depuis le cache tant que ni
+    /// la configuration ni les services du device ne changent.
+    pub fn description_xml(&self) -> Result<Bytes, xmltree::Error> {
+        self.cached_description().map(|document| document.xml)
+    }
 
-        let config = EmitterConfig::new()
-            .perform_indent(true)
-            .indent_string("  ");
+    fn cached_description(&self) -> Result<CachedXml, xmltree::Error> {
+        cached_xml(&self.description_route(), || {
+            let mut root = self.description_element();
+            let config_id = self.config_id_of(&crate::xml_format::write_xml(&root)?);
+            root.attributes
+                .insert("configId".to_string(), config_id.to_string());
+            let xml = crate::xml_format::write_xml(&root)?;
+            tracing::debug!("✅ Device description generated ({} bytes)", xml.len());
+            Ok(xml)
+        })
+    }
 
-        let mut xml_output = Vec::new();
-        if let Err(e) = elem.write_with_config(&mut xml_output, config) {
-            tracing::error!("❌ Failed to serialize device description XML: {}", e);
-            return StatusCode::INTERNAL_SERVER_ERROR.into_response();
+    /// Version de la description (CONFIGID.UPNP.ORG et attribut `configId`).
+    ///
+    /// Elle avance à chaque changement de la description, d'un démarrage à
+    /// l'autre comme en cours de fonctionnement.
+    pub fn config_id(&self) -> u32 {
+        match crate::xml_format::write_xml(&self.description_element()) {
+            Ok(xml) => self.config_id_of(&xml),
+            Err(e) => {
+                tracing::warn!("❌ Failed to serialize device description XML: {}", e);
+                0
+            }
         }
+    }
 
-        let xml = String::from_utf8_lossy(&xml_output).to_string();
-
-        tracing::debug!("✅ Device description generated ({} bytes)", xml.len());
+    /// Version de la description `xml`, rendue sans l'attribut `configId`.
+    fn config_id_of(&self, xml: &str) -> u32 {
+        crate::ssdp::config_id(self.udn(), xml.as_bytes())
+    }
 
-        (
-            StatusCode::OK,
-            [(
-                axum::http::header::CONTENT_TYPE,
-                "text/xml; charset=\"utf-8\"",
-            )],
-            xml,
+    /// En-tête `Server` des réponses HTTP et des annonces SSDP de ce device.
+    ///
+    /// Par ordre de priorité : valeur du modèle, `host.upnp.http.server_header`,
+    /// puis `<OS> UPnP/1.1 <fabricant>/1.0`.
+    pub fn server_header(&self) -> String {
+        if let Some(server) = self.model.server_header() {
+            return server.to_string();
+        }
+        if let Ok(Some(server)) = pmoconfig::get_config().get_upnp_http_server_header() {
+            return server;
+        }
+        format!(
+            "{} UPnP/1.1 {}/{}",
+            pmoutils::get_os_string(),
+            self.model.manufacturer(),
+            SERVER_PRODUCT_VERSION
         )
-            .into_response()
+    }
+
+    /// En-tête `Cache-Control` de la description et des SCPD de ce device
+    /// (`None` si désactivé).
+    pub fn cache_control(&self) -> Option<String> {
+        let directives = match self.model.cache_control() {
+            Some(directives) => directives.to_string(),
+            None => pmoconfig::get_config()
+                .get_upnp_http_cache_control()
+                .unwrap_or_default(),
+        };
+        (!directives.trim().is_empty()).then_some(directives)
+    }
+
+    /// Réponse HTTP pour un document XML servi par ce device.
+    ///
+    /// La longueur est annoncée par `Content-Length` (pas de chunked ni de
+    /// `Connection: close`), pour que les clients HTTP/1.1 gardent la
+    /// connexion ouverte entre description et SCPD. `ETag` et
+    /// `Last-Modified` permettent aux clients de revalider leur copie :
+    /// une requête conditionnelle à jour reçoit `304 Not Modified`.
+    pub(crate) fn xml_response(&self, request: &HeaderMap, document: CachedXml) -> Response {
+        let mut response = conditional_xml_response(request, document);
+        let headers = response.headers_mut();
+        if let Ok(server) = HeaderValue::from_str(&self.server_header()) {
+            headers.insert(header::SERVER, server);
+        }
+        if let Some(Ok(directives)) = self.cache_control().map(|d| HeaderValue::from_str(&d)) {
+            headers.insert(header::CACHE_CONTROL, directives);
+        }
+        response
     }
 
     /// Crée un SsdpDevice configuré pour ce device UPnP.
@@ -379,39 +494,21 @@ impl DeviceInstance {
     /// - L'UDN du device
     /// - Le type de device
     /// - La location (URL de description)
-    /// - Le serveur (User-Agent avec OS/version détecté automatiquement)
-    /// - Les types de notification pour tous les services
-    ///
-    /// # Arguments
-    ///
-    /// * `app_name` - Nom de l'application (ex: "PMOMusic")
-    /// * `app_version` - Version de l'application (ex: "1.0")
+    /// - Le serveur, identique à l'en-tête HTTP (voir [`Self::server_header`])
+    /// - Les types de notification de tout l'arbre : services, sous-devices
+    ///   et leurs services (voir [`crate::ssdp::SsdpDevice::from_device_instance`])
     ///
     /// # Exemple
     ///
     /// ```ignore
     /// let renderer_instance = MEDIA_RENDERER.create_instance();
-    /// let ssdp_device = renderer_instance.to_ssdp_device("PMOMusic", "1.0");
+    /// let ssdp_device = renderer_instance.to_ssdp_device();
     /// ssdp_server.add_device(ssdp_device);
     /// ```
-    pub fn to_ssdp_device(&self, app_name: &str, app_version: &str) -> crate::ssdp::SsdpDevice {
+    pub fn to_ssdp_device(&self) -> crate::ssdp::SsdpDevice {
         let location = format!("{}{}", self.base_url(), self.description_route());
-        let os_string = pmoutils::get_os_string();
-        let server_string = format!("{} UPnP/1.1 {}/{}", os_string, app_name, app_version);
-
-        let mut ssdp_device = crate::ssdp::SsdpDevice::new(
-            self.udn().to_string(),
-            self.model.device_type(),
-            location,
-            server_string,
-        );
-
-        // Ajouter les types de notification pour chaque service
-        for service in self.services() {
-            ssdp_device.add_notification_type(service.service_type());
-        }
 
-        ssdp_device
+        crate::ssdp::SsdpDevice::from_device_instance(self, location, self.server_header())
     }
 
     fn normalize_udn<S: Into<String>>(raw: S) -> String {
@@ -421,3 +518,68 @@ impl DeviceInstance {
         sanitized.to_string()
     }
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::UpnpModel;
+    use crate::devices::DeviceBuilder;
+
+    fn document(route: &str, xml: &str) -> CachedXml {
+        cached_xml(route, || Ok::<_, ()>(xml.to_string())).unwrap()
+    }
+
+    #[test]
+    fn xml_response_uses_device_headers() {
+        let device = DeviceBuilder::new("HeaderRenderer", "MediaRenderer")
+            .manufacturer("ACME")
+            .server_header("ACME/2.0 UPnP/1.1 Renderer/2.0")
+            .cache_control("max-age=60")
+            .build()
+            .unwrap();
+        let instance = device.create_instance();
+        let xml = "<root>headers</root>";
+
+        let response = instance.xml_response(&HeaderMap::new(), document("/test/headers", xml));
+        assert_eq!(response.status(), StatusCode::OK);
+        let headers = response.headers();
+        assert_eq!(
+            headers[header::CONTENT_LENGTH],
+            xml.len().to_string().as_str()
+        );
+        assert_eq!(headers[header::SERVER], "ACME/2.0 UPnP/1.1 Renderer/2.0");
+        assert_eq!(headers[header::CACHE_CONTROL], "max-age=60");
+        assert_eq!(instance.server_header(), "ACME/2.0 UPnP/1.1 Renderer/2.0");
+    }
+
+    #[test]
+    fn empty_cache_control_disables_header() {
+        let device = DeviceBuilder::new("NoCacheRenderer", "MediaRenderer")
+            .cache_control(" ")
+            .build()
+            .unwrap();
+        let instance = device.create_instance();
+        assert_eq!(instance.cache_control(), None);
+
+        let response = instance.xml_response(&HeaderMap::new(), document("/test/no-cache", "<a/>"));
+        assert!(!response.headers().contains_key(header::CACHE_CONTROL));
+        assert_eq!(response.headers()[header::CONTENT_LENGTH], "4");
+    }
+
+    #[test]
+    fn ssdp_server_matches_http_server() {
+        let device = DeviceBuilder::new("ServerRenderer", "MediaRenderer")
+            .manufacturer("ACME")
+            .build()
+            .unwrap();
+        let instance = device.create_instance();
+        assert_eq!(instance.to_ssdp_device().server, instance.server_header());
+        if pmoconfig::get_config()
+            .get_upnp_http_server_header()
+            .unwrap()
+            .is_none()
+        {
+            assert!(instance.server_header().ends_with(" UPnP/1.1 ACME/1.0"));
+        }
+    }
+}
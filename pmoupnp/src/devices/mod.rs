@@ -31,13 +31,20 @@
 //! let instance = device.create_instance();
 //! ```
 
+mod conditional;
+mod description_cache;
 mod device;
+mod device_builder;
 mod device_instance;
 mod device_methods;
 mod device_registry;
 pub mod errors;
 
+pub(crate) use conditional::conditional_xml_response;
+pub use description_cache::invalidate_descriptions;
+pub(crate) use description_cache::{CachedXml, cached_xml};
 pub use device::Device;
+pub use device_builder::DeviceBuilder;
 pub use device_instance::DeviceInstance;
 pub use device_registry::{
     ActionInfo, ArgumentInfo, DeviceInfo, DeviceInstanceSet, DeviceRegistry, ServiceInfo,
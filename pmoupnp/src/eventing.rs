@@ -0,0 +1,218 @@
+//! Construction et envoi des notifications GENA
+//!
+//! Un cycle de notification envoie les mêmes propriétés à tous les abonnés
+//! d'un service. Le corps `propertyset` n'est donc construit qu'une fois par
+//! profil de contournements ([`ClientQuirks`]) et partagé sans copie
+//! ([`Bytes`]) entre les abonnés. Les envois passent par un client HTTP
+//! unique (pool de connexions réutilisé) et un nombre borné d'envois
+//! simultanés, commun à tous les services :
+//!
+//! ```yaml
+//! host:
+//!   upnp:
+//!     events:
+//!       max_concurrent_deliveries: 16
+//!       delivery_timeout_secs: 10
+//! ```
+//!
+//! Le délai d'envoi évite qu'un abonné disparu sans désabonnement ne
+//! monopolise un des envois simultanés.
+
+use bytes::Bytes;
+use futures_util::stream::{self, StreamExt};
+use once_cell::sync::Lazy;
+use std::time::Duration;
+use tokio::sync::Semaphore;
+use tracing::{debug, error};
+
+use crate::config_ext::UpnpConfigExt;
+use crate::quirks::ClientQuirks;
+
+const PROPERTYSET_OPEN: &str = r#"<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0">"#;
+const PROPERTYSET_CLOSE: &str = "</e:propertyset>";
+
+static EVENT_CLIENT: Lazy<reqwest::Client> = Lazy::new(|| {
+    let timeout = pmoconfig::get_config()
+        .get_upnp_event_delivery_timeout_secs()
+        .unwrap_or(10);
+    reqwest::Client::builder()
+        .timeout(Duration::from_secs(timeout.max(1) as u64))
+        .build()
+        .unwrap_or_else(|e| {
+            error!("Failed to build event HTTP client: {}, using defaults", e);
+            reqwest::Client::new()
+        })
+});
+
+static DELIVERY_SLOTS: Lazy<Semaphore> = Lazy::new(|| {
+    let slots = pmoconfig::get_config()
+        .get_upnp_event_max_concurrent_deliveries()
+        .unwrap_or(16);
+    Semaphore::new(slots.max(1))
+});
+
+/// Écrit le corps `propertyset` d'un événement dans `out`
+pub fn render_propertyset(
+    properties: &[(String, String)],
+    quirks: &ClientQuirks,
+    out: &mut String,
+) {
+    out.push_str(PROPERTYSET_OPEN);
+    for (name, value) in properties {
+        out.push_str("<e:property><");
+        out.push_str(name);
+        out.push('>');
+        quirks.push_event_value(value, out);
+        out.push_str("</");
+        out.push_str(name);
+        out.push_str("></e:property>");
+    }
+    out.push_str(PROPERTYSET_CLOSE);
+}
+
+/// Propriétés d'un cycle de notification et corps déjà construits
+pub struct PropertySet {
+    properties: Vec<(String, String)>,
+    bodies: Vec<(ClientQuirks, Bytes)>,
+    buffer: String,
+}
+
+impl PropertySet {
+    /// `properties` : (nom de variable, valeur UPnP), triées par nom
+    pub fn new(mut properties: Vec<(String, String)>) -> Self {
+        properties.sort_unstable_by(|a, b| a.0.cmp(&b.0));
+        let estimate = PROPERTYSET_OPEN.len()
+            + PROPERTYSET_CLOSE.len()
+            + properties
+                .iter()
+                .map(|(name, value)| 2 * name.len() + value.len() + 32)
+                .sum::<usize>();
+        Self {
+            properties,
+            bodies: Vec::new(),
+            buffer: String::with_capacity(estimate),
+        }
+    }
+
+    pub fn is_empty(&self) -> bool {
+        self.properties.is_empty()
+    }
+
+    /// Corps pour un abonné, construit au premier usage de son profil
+    pub fn body_for(&mut self, quirks: ClientQuirks) -> Bytes {
+        if let Some((_, body)) = self.bodies.iter().find(|(q, _)| *q == quirks) {
+            return body.clone();
+        }
+        self.buffer.clear();
+        render_propertyset(&self.properties, &quirks, &mut self.buffer);
+        let body = Bytes::copy_from_slice(self.buffer.as_bytes());
+        self.bodies.push((quirks, body.clone()));
+        body
+    }
+
+    /// Nombre de corps distincts construits
+    pub fn rendered_bodies(&self) -> usize {
+        self.bodies.len()
+    }
+}
+
+/// Un NOTIFY à envoyer
+pub struct Delivery {
+    pub sid: String,
+    pub callback: String,
+    pub seq: u32,
+    pub body: Bytes,
+}
+
+/// Envoie un NOTIFY en respectant la limite d'envois simultanés
+pub async fn deliver(delivery: Delivery) {
+    let Ok(_slot) = DELIVERY_SLOTS.acquire().await else {
+        return;
+    };
+    let callback = delivery
+        .callback
+        .trim()
+        .trim_matches(|c| c == '<' || c == '>');
+    crate::trace_payload!(
+        std::str::from_utf8(&delivery.body).unwrap_or_default(),
+        "📨 Event for SID {} (SEQ {})",
+        delivery.sid,
+        delivery.seq
+    );
+
+    match EVENT_CLIENT
+        .request(reqwest::Method::from_bytes(b"NOTIFY").unwrap(), callback)
+        .header("Content-Type", r#"text/xml; charset="utf-8"#)
+        .header("NT", "upnp:event")
+        .header("NTS", "upnp:propchange")
+        .header("SID", &delivery.sid)
+        .header("SEQ", delivery.seq)
+        .body(delivery.body)
+        .send()
+        .await
+    {
+        Ok(resp) => {
+            debug!(
+                "✅ Notified subscriber {} (SEQ {}), status={}",
+                crate::payload_log::redact_url(callback),
+                delivery.seq,
+                resp.status()
+            );
+        }
+        Err(e) => {
+            error!(
+                "Failed to notify subscriber {}: {}",
+                crate::payload_log::redact_url(callback),
+                e
+            );
+        }
+    }
+}
+
+/// Envoie les NOTIFY d'un cycle dans une seule tâche
+pub fn spawn_deliveries(deliveries: Vec<Delivery>) {
+    if deliveries.is_empty() {
+        return;
+    }
+    tokio::spawn(async move {
+        stream::iter(deliveries)
+            .for_each_concurrent(None, deliver)
+            .await;
+    });
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::quirks::EventEncoding;
+
+    #[test]
+    fn test_bodies_shared_per_profile() {
+        let mut set = PropertySet::new(vec![
+            ("Volume".into(), "42".into()),
+            ("LastChange".into(), "<Event/>".into()),
+        ]);
+        let raw = ClientQuirks::default();
+        let escaped = ClientQuirks {
+            event_encoding: EventEncoding::Escaped,
+            ..Default::default()
+        };
+
+        let first = set.body_for(raw);
+        for _ in 0..50 {
+            assert_eq!(set.body_for(raw), first);
+        }
+        let other = set.body_for(escaped);
+        assert_eq!(set.rendered_bodies(), 2);
+
+        let first = std::str::from_utf8(&first).unwrap();
+        assert!(first.starts_with(PROPERTYSET_OPEN));
+        assert!(first.find("LastChange").unwrap() < first.find("Volume").unwrap());
+        assert!(first.contains("<LastChange><Event/></LastChange>"));
+        assert!(
+            std::str::from_utf8(&other)
+                .unwrap()
+                .contains("&lt;Event/&gt;")
+        );
+    }
+}
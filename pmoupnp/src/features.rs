@@ -0,0 +1,161 @@
+//! Désactivation de services et d'actions par configuration
+//!
+//! Annoncer une action non implémentée (ou mal supportée par un point de
+//! contrôle) est pire que ne pas l'annoncer : le point de contrôle l'appelle
+//! et échoue. Les règles de `host.upnp.features` retirent des services
+//! entiers ou des actions du device : ils disparaissent de la description,
+//! des SCPD et des annonces SSDP, et leur invocation renvoie `401 Invalid
+//! Action`.
+//!
+//! ```yaml
+//! host:
+//!   upnp:
+//!     features:
+//!       - service: ContentDirectory   # nom du service...
+//!         disable: [Search]           # actions désactivées
+//!       - device: MediaRenderer       # optionnel : nom ou catégorie du device
+//!         service: AVTransport
+//!         disable: [Seek]
+//!       - service: av-openhome-org    # ...ou domaine : toute la suite OpenHome
+//! ```
+//!
+//! Une règle sans `disable` désactive le service entier.
+
+use once_cell::sync::Lazy;
+use serde::{Deserialize, Serialize};
+use std::sync::RwLock;
+use tracing::warn;
+
+use crate::config_ext::UpnpConfigExt;
+
+/// Règle de désactivation
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct FeatureRule {
+    /// Nom ou catégorie du device (absent ou `*` : tous)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub device: Option<String>,
+    /// Nom du service (ex: `AVTransport`) ou domaine de son type
+    /// (ex: `av-openhome-org`), `*` pour tous
+    pub service: String,
+    /// Actions désactivées ; vide : service entier
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub disable: Vec<String>,
+}
+
+/// Service tel que vu par les règles
+#[derive(Debug, Clone, Copy)]
+pub struct ServiceRef<'a> {
+    /// Nom du device parent (vide si inconnu)
+    pub device_name: &'a str,
+    /// Catégorie du device parent (ex: `MediaRenderer`)
+    pub device_category: &'a str,
+    pub service_name: &'a str,
+    /// Type complet (`urn:domaine:service:Nom:1`)
+    pub service_type: &'a str,
+}
+
+impl FeatureRule {
+    fn matches_service(&self, service: &ServiceRef<'_>) -> bool {
+        let device_ok = match self.device.as_deref() {
+            None | Some("*") => true,
+            Some(device) => {
+                device.eq_ignore_ascii_case(service.device_name)
+                    || device.eq_ignore_ascii_case(service.device_category)
+            }
+        };
+        let service_ok = self.service == "*"
+            || self.service.eq_ignore_ascii_case(service.service_name)
+            || service
+                .service_type
+                .strip_prefix("urn:")
+                .and_then(|t| t.split(':').next())
+                .is_some_and(|domain| domain.eq_ignore_ascii_case(&self.service));
+        device_ok && service_ok
+    }
+}
+
+static FEATURE_RULES: Lazy<RwLock<Vec<FeatureRule>>> = Lazy::new(|| {
+    let rules = pmoconfig::get_config()
+        .get_upnp_features()
+        .unwrap_or_else(|e| {
+            warn!("Invalid host.upnp.features configuration: {}", e);
+            Vec::new()
+        });
+    RwLock::new(rules)
+});
+
+/// Règles actives
+pub fn feature_rules() -> Vec<FeatureRule> {
+    FEATURE_RULES.read().unwrap().clone()
+}
+
+/// Remplace les règles (à faire avant l'enregistrement des devices)
+pub fn set_feature_rules(rules: Vec<FeatureRule>) {
+    *FEATURE_RULES.write().unwrap() = rules;
+    crate::devices::invalidate_descriptions();
+}
+
+/// Le service est-il annoncé ?
+pub fn is_service_enabled(service: &ServiceRef<'_>) -> bool {
+    is_service_enabled_with(&FEATURE_RULES.read().unwrap(), service)
+}
+
+/// L'action est-elle annoncée et invocable ?
+pub fn is_action_enabled(service: &ServiceRef<'_>, action: &str) -> bool {
+    is_action_enabled_with(&FEATURE_RULES.read().unwrap(), service, action)
+}
+
+fn is_service_enabled_with(rules: &[FeatureRule], service: &ServiceRef<'_>) -> bool {
+    !rules
+        .iter()
+        .any(|rule| rule.disable.is_empty() && rule.matches_service(service))
+}
+
+fn is_action_enabled_with(rules: &[FeatureRule], service: &ServiceRef<'_>, action: &str) -> bool {
+    !rules.iter().any(|rule| {
+        rule.matches_service(service)
+            && (rule.disable.is_empty() || rule.disable.iter().any(|a| a == action))
+    })
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_feature_rules() {
+        let rules: Vec<FeatureRule> = serde_yaml::from_str(
+            "- service: ContentDirectory\n  disable: [Search]\n\
+             - device: MediaRenderer\n  service: AVTransport\n  disable: [Seek]\n\
+             - service: av-openhome-org\n",
+        )
+        .unwrap();
+
+        let cds = ServiceRef {
+            device_name: "MediaServer",
+            device_category: "MediaServer",
+            service_name: "ContentDirectory",
+            service_type: "urn:schemas-upnp-org:service:ContentDirectory:1",
+        };
+        assert!(is_service_enabled_with(&rules, &cds));
+        assert!(!is_action_enabled_with(&rules, &cds, "Search"));
+        assert!(is_action_enabled_with(&rules, &cds, "Browse"));
+
+        let avt = ServiceRef {
+            device_name: "PMOMusic Renderer",
+            device_category: "MediaRenderer",
+            service_name: "AVTransport",
+            service_type: "urn:schemas-upnp-org:service:AVTransport:1",
+        };
+        assert!(!is_action_enabled_with(&rules, &avt, "Seek"));
+        assert!(is_action_enabled_with(&rules, &avt, "Play"));
+
+        let product = ServiceRef {
+            service_name: "Product",
+            service_type: "urn:av-openhome-org:service:Product:1",
+            ..avt
+        };
+        assert!(!is_service_enabled_with(&rules, &product));
+        assert!(!is_action_enabled_with(&rules, &product, "Standby"));
+    }
+}
@@ -2,9 +2,17 @@ mod object_set;
 mod object_trait;
 
 pub mod actions;
+pub mod audit;
 pub mod cache_registry;
+pub mod clock;
 pub mod config_ext;
 pub mod devices;
+pub mod eventing;
+pub mod features;
+pub mod payload_log;
+pub mod policies;
+pub mod power;
+pub mod quirks;
 pub mod services;
 pub mod soap;
 pub mod ssdp;
@@ -13,6 +21,7 @@ pub mod upnp_api;
 pub mod upnp_server;
 pub mod value_ranges;
 pub mod variable_types;
+pub mod xml_format;
 
 use std::sync::RwLock;
 use std::{collections::HashMap, sync::Arc};
@@ -23,7 +32,7 @@ pub use pmocovers::get_cover_cache;
 
 pub use crate::config_ext::UpnpConfigExt;
 pub use crate::object_trait::*;
-pub use crate::upnp_server::UpnpServerExt;
+pub use crate::upnp_server::{SsdpMode, UpnpServerExt, UpnpServerOptions};
 
 #[derive(Debug, Clone)]
 pub struct UpnpObjectType {
@@ -37,7 +37,7 @@
 
 use std::{fmt::Debug, sync::Arc};
 
-use xmltree::{Element, EmitterConfig};
+use xmltree::Element;
 
 use crate::UpnpObjectType;
 
@@ -104,15 +104,7 @@ pub trait UpnpObject: Clone + Debug {
     fn to_xml(&self) -> String {
         let elem = self.to_xml_element();
 
-        let config = EmitterConfig::new()
-            .perform_indent(true)
-            .indent_string("  ");
-
-        let mut buf = Vec::new();
-        elem.write_with_config(&mut buf, config)
-            .expect("Failed to write XML");
-
-        String::from_utf8(buf).expect("Invalid UTF-8")
+        crate::xml_format::write_xml(&elem).expect("Failed to write XML")
     }
 
     /// Convertit l'objet en représentation Markdown.
@@ -0,0 +1,206 @@
+//! Journalisation des payloads SOAP/SSDP.
+//!
+//! Le rendu Markdown des payloads complets (bloc `<details>` affiché par la
+//! webapp) est coûteux en volume : il n'est produit que si
+//! `host.upnp.log_payloads` est activé **et** que le niveau TRACE est actif
+//! pour la cible appelante.
+//!
+//! Avant d'être journalisés, les payloads passent par [`redact`] : les URLs
+//! de callback GENA perdent leur chemin et les jetons / mots de passe sont
+//! masqués.
+
+use once_cell::sync::Lazy;
+use std::sync::atomic::{AtomicBool, Ordering};
+
+use crate::config_ext::UpnpConfigExt;
+
+/// Valeur de remplacement des secrets
+const REDACTED: &str = "***";
+
+/// En-têtes dont la valeur est entièrement masquée
+const SECRET_HEADERS: &[&str] = &["authorization", "cookie", "set-cookie", "x-user-auth-token"];
+
+/// En-têtes contenant des URLs de callback
+const CALLBACK_HEADERS: &[&str] = &["callback"];
+
+/// Paramètres de requête et éléments XML considérés comme secrets
+const SECRET_KEYS: &[&str] = &[
+    "token",
+    "access_token",
+    "user_auth_token",
+    "auth",
+    "password",
+    "passwd",
+    "secret",
+    "app_secret",
+    "signature",
+    "request_sig",
+    "api_key",
+    "apikey",
+];
+
+static LOG_PAYLOADS: Lazy<AtomicBool> = Lazy::new(|| {
+    AtomicBool::new(
+        pmoconfig::get_config()
+            .get_upnp_log_payloads()
+            .unwrap_or(false),
+    )
+});
+
+/// Active ou désactive la journalisation des payloads à chaud
+pub fn set_payload_logging(enabled: bool) {
+    LOG_PAYLOADS.store(enabled, Ordering::Relaxed);
+}
+
+/// La journalisation des payloads est-elle activée (indépendamment du niveau) ?
+pub fn payload_logging_enabled() -> bool {
+    LOG_PAYLOADS.load(Ordering::Relaxed)
+}
+
+/// Journalise un payload au niveau TRACE, en Markdown, après masquage.
+///
+/// Le payload n'est ni masqué ni formaté si la journalisation est désactivée
+/// ou si TRACE n'est pas actif.
+#[macro_export]
+macro_rules! trace_payload {
+    ($payload:expr, $($title:tt)+) => {
+        if $crate::payload_log::payload_logging_enabled()
+            && ::tracing::enabled!(::tracing::Level::TRACE)
+        {
+            ::tracing::trace!(
+                "{}\n<details>\n\n```\n{}\n```\n</details>\n",
+                format!($($title)+),
+                $crate::payload_log::redact(&$payload)
+            );
+        }
+    };
+}
+
+/// Masque les URLs de callback et les secrets d'un payload HTTP/SOAP.
+pub fn redact(payload: &str) -> String {
+    let mut out = String::with_capacity(payload.len());
+    for (i, line) in payload.split('\n').enumerate() {
+        if i > 0 {
+            out.push('\n');
+        }
+        out.push_str(&redact_line(line));
+    }
+    out
+}
+
+/// Réduit une URL à son schéma et son hôte (`http://10.0.0.5:49152/…`)
+pub fn redact_url(url: &str) -> String {
+    let trimmed = url.trim();
+    let Some(scheme_end) = trimmed.find("://") else {
+        return REDACTED.to_string();
+    };
+    let authority_start = scheme_end + 3;
+    let authority_end = trimmed[authority_start..]
+        .find(|c| c == '/' || c == '?' || c == '>')
+        .map_or(trimmed.len(), |p| authority_start + p);
+    // Retirer d'éventuels identifiants user:pass@
+    let authority = &trimmed[authority_start..authority_end];
+    let host = authority.rsplit('@').next().unwrap_or(authority);
+
+    let mut out = format!("{}{}", &trimmed[..authority_start], host);
+    if authority_end < trimmed.len() {
+        out.push_str("/…");
+    }
+    out
+}
+
+/// Masque une valeur d'en-tête CALLBACK (`<url1><url2>`)
+pub fn redact_callbacks(value: &str) -> String {
+    value
+        .split('>')
+        .map(|part| part.trim().trim_start_matches('<'))
+        .filter(|part| !part.is_empty())
+        .map(|url| format!("<{}>", redact_url(url)))
+        .collect()
+}
+
+fn redact_line(line: &str) -> String {
+    if let Some((name, value)) = line.split_once(':') {
+        let header = name.trim().to_ascii_lowercase();
+        if !header.contains(' ') && !header.contains('<') {
+            if SECRET_HEADERS.contains(&header.as_str()) {
+                return format!("{}: {}", name, REDACTED);
+            }
+            if CALLBACK_HEADERS.contains(&header.as_str()) {
+                return format!("{}: {}", name, redact_callbacks(value));
+            }
+        }
+    }
+    redact_xml_elements(&redact_query_params(line))
+}
+
+/// `token=abc&x=1` → `token=***&x=1`
+fn redact_query_params(line: &str) -> String {
+    let mut out = String::with_capacity(line.len());
+    let mut rest = line;
+    while let Some(eq) = rest.find('=') {
+        let key_start = rest[..eq]
+            .rfind(|c: char| !(c.is_ascii_alphanumeric() || c == '_' || c == '-'))
+            .map_or(0, |p| p + 1);
+        let key = rest[key_start..eq].to_ascii_lowercase();
+        out.push_str(&rest[..=eq]);
+        rest = &rest[eq + 1..];
+
+        if SECRET_KEYS.contains(&key.as_str()) && !rest.starts_with('"') {
+            let value_end = rest
+                .find(|c: char| matches!(c, '&' | ' ' | '"' | '\'' | '<' | '>' | ';' | '\r'))
+                .unwrap_or(rest.len());
+            out.push_str(REDACTED);
+            rest = &rest[value_end..];
+        }
+    }
+    out.push_str(rest);
+    out
+}
+
+/// `<Password>abc</Password>` → `<Password>***</Password>`
+fn redact_xml_elements(line: &str) -> String {
+    let mut out = line.to_string();
+    for key in SECRET_KEYS {
+        let lower = out.to_ascii_lowercase();
+        let open = format!("<{}>", key);
+        let close = format!("</{}>", key);
+        let (Some(start), Some(end)) = (lower.find(&open), lower.find(&close)) else {
+            continue;
+        };
+        let value_start = start + open.len();
+        if value_start <= end {
+            out.replace_range(value_start..end, REDACTED);
+        }
+    }
+    out
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_redact_callback_and_tokens() {
+        let payload = "SUBSCRIBE /evt HTTP/1.1\r\n\
+                       CALLBACK: <http://192.168.1.20:49152/notify/abc?sid=1>\r\n\
+                       Authorization: Bearer xyz\r\n\
+                       \r\n\
+                       <CurrentURI>http://srv/track.flac?token=s3cr3t&fmt=27</CurrentURI>";
+        let redacted = redact(payload);
+
+        assert!(redacted.contains("CALLBACK: <http://192.168.1.20:49152/…>"));
+        assert!(redacted.contains("Authorization: ***"));
+        assert!(redacted.contains("token=***&fmt=27"));
+        assert!(!redacted.contains("s3cr3t"));
+        assert!(!redacted.contains("xyz"));
+    }
+
+    #[test]
+    fn test_redact_xml_password() {
+        assert_eq!(
+            redact("<Password>hunter2</Password>"),
+            "<Password>***</Password>"
+        );
+    }
+}
@@ -0,0 +1,292 @@
+//! Restrictions par device : volume maximal et heures calmes
+//!
+//! Les règles de `host.upnp.policies` sont appliquées au moment de
+//! l'invocation SOAP, quel que soit le point de contrôle :
+//!
+//! - pendant les heures calmes, `Play` (et les `SeekId`/`SeekIndex`
+//!   OpenHome, qui lancent la lecture) est refusé avec l'erreur `701` et
+//!   la raison est journalisée ;
+//! - un `SetVolume` au-delà du maximum en vigueur est ramené au maximum.
+//!
+//! ```yaml
+//! host:
+//!   upnp:
+//!     policies:
+//!       - device: Chambre             # nom ou catégorie du device (absent : tous)
+//!         max_volume:
+//!           - { from: "20:00", to: "08:00", max: 25 }
+//!           - { max: 60 }             # sans plage : toute la journée
+//!         quiet_hours:
+//!           - { from: "21:30", to: "07:00" }
+//! ```
+//!
+//! Les heures sont locales, au format `HH:MM` ; une plage dont la fin
+//! précède le début passe minuit. Quand plusieurs règles s'appliquent, le
+//! maximum le plus bas l'emporte. Le volume en cours n'est pas modifié à
+//! l'entrée dans une plage : seules les nouvelles consignes sont bornées.
+
+use chrono::{Local, NaiveTime};
+use once_cell::sync::Lazy;
+use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+use std::sync::RwLock;
+use tracing::{info, warn};
+
+use crate::config_ext::UpnpConfigExt;
+use crate::features::ServiceRef;
+
+/// Code d'erreur renvoyé pour une lecture refusée (`Transition not available`)
+pub const QUIET_HOURS_ERROR: &str = "701";
+
+/// Actions refusées pendant les heures calmes
+const PLAY_ACTIONS: &[&str] = &["Play", "SeekId", "SeekIndex"];
+
+/// Arguments de `SetVolume` (RenderingControl, puis OpenHome Volume)
+const VOLUME_ARGS: &[&str] = &["DesiredVolume", "Value"];
+
+/// Plage horaire quotidienne, bornes `HH:MM` en heure locale
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct TimeWindow {
+    pub from: String,
+    pub to: String,
+}
+
+impl TimeWindow {
+    fn bounds(&self) -> Option<(NaiveTime, NaiveTime)> {
+        let parse = |s: &str| NaiveTime::parse_from_str(s.trim(), "%H:%M").ok();
+        Some((parse(&self.from)?, parse(&self.to)?))
+    }
+
+    /// L'heure `now` est-elle dans la plage ?
+    ///
+    /// Une plage invalide ne contient aucune heure.
+    pub fn contains(&self, now: NaiveTime) -> bool {
+        match self.bounds() {
+            Some((from, to)) if from <= to => from <= now && now < to,
+            Some((from, to)) => now >= from || now < to,
+            None => false,
+        }
+    }
+}
+
+/// Volume maximal, éventuellement limité à une plage horaire
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct VolumeCap {
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub from: Option<String>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub to: Option<String>,
+    pub max: u32,
+}
+
+impl VolumeCap {
+    fn applies_at(&self, now: NaiveTime) -> bool {
+        match (&self.from, &self.to) {
+            (None, None) => true,
+            (from, to) => TimeWindow {
+                from: from.clone().unwrap_or_else(|| "00:00".into()),
+                to: to.clone().unwrap_or_else(|| "00:00".into()),
+            }
+            .contains(now),
+        }
+    }
+}
+
+/// Restrictions d'un device
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct DevicePolicy {
+    /// Nom ou catégorie du device (absent ou `*` : tous)
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub device: Option<String>,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub max_volume: Vec<VolumeCap>,
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub quiet_hours: Vec<TimeWindow>,
+}
+
+impl DevicePolicy {
+    fn matches(&self, service: &ServiceRef<'_>) -> bool {
+        match self.device.as_deref() {
+            None | Some("*") => true,
+            Some(device) => {
+                device.eq_ignore_ascii_case(service.device_name)
+                    || device.eq_ignore_ascii_case(service.device_category)
+            }
+        }
+    }
+
+    /// Plages mal formées (signalées au chargement)
+    fn invalid_windows(&self) -> Vec<String> {
+        let quiet = self
+            .quiet_hours
+            .iter()
+            .filter(|w| w.bounds().is_none())
+            .map(|w| format!("{}-{}", w.from, w.to));
+        let caps = self
+            .max_volume
+            .iter()
+            .filter(|cap| {
+                [&cap.from, &cap.to]
+                    .into_iter()
+                    .flatten()
+                    .any(|t| NaiveTime::parse_from_str(t.trim(), "%H:%M").is_err())
+            })
+            .map(|cap| {
+                format!(
+                    "{}-{}",
+                    cap.from.as_deref().unwrap_or(""),
+                    cap.to.as_deref().unwrap_or("")
+                )
+            });
+        quiet.chain(caps).collect()
+    }
+}
+
+/// Refus d'une action par une règle
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct PolicyViolation {
+    pub code: &'static str,
+    pub reason: String,
+}
+
+static POLICIES: Lazy<RwLock<Vec<DevicePolicy>>> = Lazy::new(|| {
+    let policies = pmoconfig::get_config()
+        .get_upnp_policies()
+        .unwrap_or_else(|e| {
+            warn!("Invalid host.upnp.policies configuration: {}", e);
+            Vec::new()
+        });
+    for policy in &policies {
+        for window in policy.invalid_windows() {
+            warn!(
+                "Ignoring invalid time window {} in policy for {}",
+                window,
+                policy.device.as_deref().unwrap_or("*")
+            );
+        }
+    }
+    RwLock::new(policies)
+});
+
+/// Règles actives
+pub fn policies() -> Vec<DevicePolicy> {
+    POLICIES.read().unwrap().clone()
+}
+
+/// Remplace les règles
+pub fn set_policies(policies: Vec<DevicePolicy>) {
+    *POLICIES.write().unwrap() = policies;
+}
+
+/// Applique les règles à une invocation, à l'heure locale courante
+///
+/// Les arguments de volume peuvent être réécrits ; une action refusée
+/// retourne la raison du refus.
+pub fn enforce(
+    service: &ServiceRef<'_>,
+    action: &str,
+    args: &mut HashMap<String, String>,
+) -> Result<(), PolicyViolation> {
+    enforce_with(
+        &POLICIES.read().unwrap(),
+        service,
+        action,
+        args,
+        Local::now().time(),
+    )
+}
+
+fn enforce_with(
+    policies: &[DevicePolicy],
+    service: &ServiceRef<'_>,
+    action: &str,
+    args: &mut HashMap<String, String>,
+    now: NaiveTime,
+) -> Result<(), PolicyViolation> {
+    let matching: Vec<&DevicePolicy> = policies.iter().filter(|p| p.matches(service)).collect();
+    if matching.is_empty() {
+        return Ok(());
+    }
+
+    if PLAY_ACTIONS.contains(&action) {
+        let window = matching
+            .iter()
+            .flat_map(|p| p.quiet_hours.iter())
+            .find(|w| w.contains(now));
+        if let Some(window) = window {
+            return Err(PolicyViolation {
+                code: QUIET_HOURS_ERROR,
+                reason: format!(
+                    "Playback is not allowed on {} during quiet hours ({}-{})",
+                    service.device_name, window.from, window.to
+                ),
+            });
+        }
+    }
+
+    if action == "SetVolume" {
+        let cap = matching
+            .iter()
+            .flat_map(|p| p.max_volume.iter())
+            .filter(|cap| cap.applies_at(now))
+            .map(|cap| cap.max)
+            .min();
+        if let Some(cap) = cap {
+            for name in VOLUME_ARGS {
+                let Some(value) = args.get_mut(*name) else {
+                    continue;
+                };
+                if value.trim().parse::<u32>().is_ok_and(|v| v > cap) {
+                    info!(
+                        "🔉 Volume {} on {} capped to {} by policy",
+                        value, service.device_name, cap
+                    );
+                    *value = cap.to_string();
+                }
+            }
+        }
+    }
+
+    Ok(())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_policies() {
+        let policies: Vec<DevicePolicy> = serde_yaml::from_str(
+            "- device: Chambre\n  max_volume:\n    - { from: \"20:00\", to: \"08:00\", max: 25 }\n    - { max: 60 }\n  quiet_hours:\n    - { from: \"21:30\", to: \"07:00\" }\n",
+        )
+        .unwrap();
+        let chambre = ServiceRef {
+            device_name: "Chambre",
+            device_category: "MediaRenderer",
+            service_name: "RenderingControl",
+            service_type: "urn:schemas-upnp-org:service:RenderingControl:1",
+        };
+        let salon = ServiceRef {
+            device_name: "Salon",
+            ..chambre
+        };
+        let at = |h, m| NaiveTime::from_hms_opt(h, m, 0).unwrap();
+        let volume = |v: &str| HashMap::from([("DesiredVolume".to_string(), v.to_string())]);
+
+        let mut args = volume("80");
+        enforce_with(&policies, &chambre, "SetVolume", &mut args, at(12, 0)).unwrap();
+        assert_eq!(args["DesiredVolume"], "60");
+        let mut args = volume("40");
+        enforce_with(&policies, &chambre, "SetVolume", &mut args, at(23, 0)).unwrap();
+        assert_eq!(args["DesiredVolume"], "25");
+        let mut args = volume("80");
+        enforce_with(&policies, &salon, "SetVolume", &mut args, at(23, 0)).unwrap();
+        assert_eq!(args["DesiredVolume"], "80");
+
+        let mut args = HashMap::new();
+        let refused = enforce_with(&policies, &chambre, "Play", &mut args, at(2, 0));
+        assert_eq!(refused.unwrap_err().code, QUIET_HOURS_ERROR);
+        assert!(enforce_with(&policies, &chambre, "Play", &mut args, at(7, 0)).is_ok());
+        assert!(enforce_with(&policies, &salon, "Play", &mut args, at(2, 0)).is_ok());
+    }
+}
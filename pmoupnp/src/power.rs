@@ -0,0 +1,187 @@
+//! Détection des sorties de veille du système
+//!
+//! Après une veille (portable refermé), les abonnés GENA ont expiré, les
+//! control points ont oublié nos devices et les flux en cours sont coupés,
+//! alors que nos sockets et nos tâches reprennent comme si de rien n'était :
+//! les renderers restent visibles mais ne répondent plus correctement.
+//!
+//! Un thread de surveillance relève régulièrement les horloges du système.
+//! Un écart entre deux relevés supérieur au seuil signale une veille :
+//!
+//! - sous Linux, `CLOCK_BOOTTIME` avance pendant la veille et
+//!   `CLOCK_MONOTONIC` non, leur différence donne la durée exacte ;
+//! - ailleurs, on compare l'horloge murale à l'horloge monotone, et l'on
+//!   détecte aussi le gel du thread lui-même (horloge monotone qui continue
+//!   pendant la veille).
+//!
+//! La veille n'est constatée qu'au réveil : les abonnés de
+//! [`subscribe`] reçoivent un [`ResumeEvent`] et remettent leur état à plat
+//! (réannonce SSDP, abonnements, pipelines).
+//!
+//! ```yaml
+//! host:
+//!   upnp:
+//!     sleep_detection:
+//!       enabled: true
+//!       threshold: 10s        # écart d'horloge qui signale une veille
+//! ```
+
+use once_cell::sync::Lazy;
+use std::sync::Once;
+use std::time::{Duration, Instant, SystemTime};
+use tokio::sync::broadcast;
+use tracing::{debug, info, warn};
+
+use crate::config_ext::UpnpConfigExt;
+
+/// Intervalle entre deux relevés d'horloge
+const TICK: Duration = Duration::from_secs(2);
+
+/// Sortie de veille constatée
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct ResumeEvent {
+    /// Durée estimée de la veille
+    pub slept: Duration,
+}
+
+static RESUME_EVENTS: Lazy<broadcast::Sender<ResumeEvent>> = Lazy::new(|| broadcast::channel(8).0);
+
+static WATCHER: Once = Once::new();
+
+/// S'abonne aux sorties de veille
+///
+/// Le thread de surveillance démarre au premier abonnement, sauf si
+/// `host.upnp.sleep_detection.enabled` est faux.
+pub fn subscribe() -> broadcast::Receiver<ResumeEvent> {
+    let receiver = RESUME_EVENTS.subscribe();
+    WATCHER.call_once(start_watcher);
+    receiver
+}
+
+/// Signale une sortie de veille détectée par ailleurs (ex. notification
+/// du système hôte)
+pub fn notify_resume(slept: Duration) {
+    info!("☀️ System resumed after {:?} asleep", slept);
+    let _ = RESUME_EVENTS.send(ResumeEvent { slept });
+}
+
+/// Temps passé en veille par le système, s'il le mesure (Linux)
+#[cfg(any(target_os = "linux", target_os = "android"))]
+fn suspended_time() -> Option<Duration> {
+    fn clock(id: libc::clockid_t) -> Option<Duration> {
+        let mut ts = libc::timespec {
+            tv_sec: 0,
+            tv_nsec: 0,
+        };
+        // SAFETY: `ts` est une structure valide appartenant à la pile
+        if unsafe { libc::clock_gettime(id, &mut ts) } != 0 {
+            return None;
+        }
+        Some(Duration::new(ts.tv_sec as u64, ts.tv_nsec as u32))
+    }
+    let boottime = clock(libc::CLOCK_BOOTTIME)?;
+    let monotonic = clock(libc::CLOCK_MONOTONIC)?;
+    Some(boottime.saturating_sub(monotonic))
+}
+
+#[cfg(not(any(target_os = "linux", target_os = "android")))]
+fn suspended_time() -> Option<Duration> {
+    None
+}
+
+/// Durée de veille entre deux relevés espacés de `tick`
+///
+/// `monotonic` et `wall` sont les durées écoulées selon l'horloge monotone
+/// et l'horloge murale (absente si elle a reculé), `suspended` la
+/// progression du temps de veille mesuré par le système.
+fn slept_between(
+    tick: Duration,
+    monotonic: Duration,
+    wall: Option<Duration>,
+    suspended: Option<Duration>,
+) -> Duration {
+    if let Some(suspended) = suspended {
+        return suspended;
+    }
+    let frozen = monotonic.saturating_sub(tick);
+    let hidden = wall.map_or(Duration::ZERO, |wall| wall.saturating_sub(monotonic));
+    frozen.max(hidden)
+}
+
+fn start_watcher() {
+    let config = pmoconfig::get_config();
+    if !config.get_upnp_sleep_detection_enabled().unwrap_or(true) {
+        info!("💤 Sleep detection disabled by configuration");
+        return;
+    }
+    let threshold = config
+        .get_upnp_sleep_detection_threshold()
+        .unwrap_or(Duration::from_secs(10))
+        .max(TICK);
+
+    let spawned = std::thread::Builder::new()
+        .name("sleep-watcher".into())
+        .spawn(move || {
+            debug!("💤 Sleep watcher started (threshold {:?})", threshold);
+            let mut last_instant = Instant::now();
+            let mut last_wall = SystemTime::now();
+            let mut last_suspended = suspended_time();
+            loop {
+                std::thread::sleep(TICK);
+
+                let instant = Instant::now();
+                let wall = SystemTime::now();
+                let suspended = suspended_time();
+
+                let slept = slept_between(
+                    TICK,
+                    instant.duration_since(last_instant),
+                    wall.duration_since(last_wall).ok(),
+                    suspended
+                        .zip(last_suspended)
+                        .map(|(now, before)| now.saturating_sub(before)),
+                );
+                if slept >= threshold {
+                    notify_resume(slept);
+                }
+
+                last_instant = instant;
+                last_wall = wall;
+                last_suspended = suspended;
+            }
+        });
+    if let Err(e) = spawned {
+        warn!("❌ Failed to start sleep watcher: {}", e);
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_slept_between() {
+        let tick = Duration::from_secs(2);
+        let secs = Duration::from_secs;
+
+        // Relevé normal
+        assert_eq!(slept_between(tick, secs(2), Some(secs(2)), None), secs(0));
+        // Horloge monotone arrêtée pendant la veille (macOS)
+        assert_eq!(
+            slept_between(tick, secs(2), Some(secs(602)), None),
+            secs(600)
+        );
+        // Thread gelé, horloge monotone qui continue (Windows)
+        assert_eq!(
+            slept_between(tick, secs(302), Some(secs(302)), None),
+            secs(300)
+        );
+        // Horloge murale recalée en arrière
+        assert_eq!(slept_between(tick, secs(2), None, None), secs(0));
+        // Mesure du système prioritaire (Linux)
+        assert_eq!(
+            slept_between(tick, secs(2), Some(secs(3600)), Some(secs(0))),
+            secs(0)
+        );
+    }
+}
@@ -0,0 +1,324 @@
+//! Base de contournements par client (quirks)
+//!
+//! Les renderers et points de contrôle réels interprètent UPnP/DLNA chacun à
+//! leur manière. Ce module associe des contournements à un client, reconnu
+//! par ses en-têtes HTTP (`User-Agent`, `X-AV-Client-Info`,
+//! `X-AV-Physical-Unit-Info`, `friendlyName.dlna.org`).
+//!
+//! Les règles sont évaluées dans l'ordre : règles intégrées, puis règles de
+//! la configuration, puis règles ajoutées par [`register_rule`]. Une règle
+//! qui correspond ne modifie que les réglages qu'elle précise ; une règle
+//! plus tardive l'emporte.
+//!
+//! ```yaml
+//! host:
+//!   upnp:
+//!     quirks:
+//!       - match: "foobar2000"         # sous-chaîne, insensible à la casse
+//!         xml_declaration: newline    # as_is | newline | compact
+//!         chunked: false              # réponses SOAP en chunked
+//!         prefer_wav: false           # préférer WAV/LPCM à FLAC
+//!         event_encoding: escaped     # raw | escaped | cdata
+//! ```
+
+use axum::{
+    body::Body,
+    http::{HeaderMap, HeaderValue, header},
+    response::Response,
+};
+use once_cell::sync::Lazy;
+use quick_xml::escape::escape;
+use serde::{Deserialize, Serialize};
+use std::sync::RwLock;
+use tracing::{debug, warn};
+
+use crate::config_ext::UpnpConfigExt;
+
+/// En-têtes utilisés pour identifier un client
+const IDENTITY_HEADERS: &[&str] = &[
+    "user-agent",
+    "x-av-client-info",
+    "x-av-physical-unit-info",
+    "friendlyname.dlna.org",
+];
+
+/// Taille maximale d'une réponse SOAP réécrite
+const MAX_REWRITE_BYTES: usize = 4 * 1024 * 1024;
+
+/// Mise en forme de la déclaration XML
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum XmlDeclaration {
+    /// Document inchangé
+    #[default]
+    AsIs,
+    /// Saut de ligne après `<?xml ...?>`
+    Newline,
+    /// Racine collée à la déclaration
+    Compact,
+}
+
+/// Encodage des valeurs dans le corps des événements GENA
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(rename_all = "snake_case")]
+pub enum EventEncoding {
+    /// Valeurs insérées telles quelles
+    #[default]
+    Raw,
+    /// Valeurs échappées (`&lt;Event ...&gt;`)
+    Escaped,
+    /// Valeurs dans une section CDATA
+    Cdata,
+}
+
+/// Contournements résolus pour un client
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq, Serialize)]
+pub struct ClientQuirks {
+    /// Mise en forme de la déclaration XML des réponses et événements
+    pub xml_declaration: XmlDeclaration,
+    /// Réponses SOAP en `Transfer-Encoding: chunked` plutôt qu'avec `Content-Length`
+    pub chunked: bool,
+    /// Le client lit mal le FLAC : proposer WAV/LPCM en priorité
+    pub prefer_wav: bool,
+    /// Encodage des valeurs dans les notifications GENA
+    pub event_encoding: EventEncoding,
+}
+
+/// Règle de la base de contournements
+///
+/// Les champs absents laissent le réglage courant inchangé.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct QuirkRule {
+    /// Sous-chaîne recherchée (sans casse) dans les en-têtes d'identification
+    #[serde(rename = "match")]
+    pub pattern: String,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub xml_declaration: Option<XmlDeclaration>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub chunked: Option<bool>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub prefer_wav: Option<bool>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub event_encoding: Option<EventEncoding>,
+}
+
+impl QuirkRule {
+    fn builtin(pattern: &str) -> Self {
+        Self {
+            pattern: pattern.to_string(),
+            xml_declaration: None,
+            chunked: None,
+            prefer_wav: None,
+            event_encoding: None,
+        }
+    }
+
+    /// La règle s'applique-t-elle à cette identité (déjà en minuscules) ?
+    pub fn matches(&self, identity: &str) -> bool {
+        !self.pattern.is_empty() && identity.contains(&self.pattern.to_lowercase())
+    }
+
+    fn apply(&self, quirks: &mut ClientQuirks) {
+        if let Some(v) = self.xml_declaration {
+            quirks.xml_declaration = v;
+        }
+        if let Some(v) = self.chunked {
+            quirks.chunked = v;
+        }
+        if let Some(v) = self.prefer_wav {
+            quirks.prefer_wav = v;
+        }
+        if let Some(v) = self.event_encoding {
+            quirks.event_encoding = v;
+        }
+    }
+}
+
+/// Règles intégrées
+fn builtin_rules() -> Vec<QuirkRule> {
+    vec![
+        // Pas de décodeur FLAC sur ces consoles
+        QuirkRule {
+            prefer_wav: Some(true),
+            ..QuirkRule::builtin("Xbox")
+        },
+        QuirkRule {
+            prefer_wav: Some(true),
+            ..QuirkRule::builtin("PLAYSTATION 3")
+        },
+        // Les TV Samsung gèrent mal les réponses chunked
+        QuirkRule {
+            chunked: Some(false),
+            ..QuirkRule::builtin("SEC_HHP")
+        },
+    ]
+}
+
+static RULES: Lazy<RwLock<Vec<QuirkRule>>> = Lazy::new(|| {
+    let mut rules = builtin_rules();
+    match pmoconfig::get_config().get_upnp_quirks() {
+        Ok(configured) => rules.extend(configured),
+        Err(e) => warn!("Invalid host.upnp.quirks configuration: {}", e),
+    }
+    RwLock::new(rules)
+});
+
+/// Ajoute une règle, prioritaire sur les règles existantes
+pub fn register_rule(rule: QuirkRule) {
+    RULES.write().unwrap().push(rule);
+}
+
+/// Liste les règles actives, dans l'ordre d'évaluation
+pub fn rules() -> Vec<QuirkRule> {
+    RULES.read().unwrap().clone()
+}
+
+/// Chaîne d'identification d'un client (en-têtes concaténés, en minuscules)
+pub fn client_identity(headers: &HeaderMap) -> String {
+    IDENTITY_HEADERS
+        .iter()
+        .filter_map(|name| headers.get(*name))
+        .filter_map(|value| value.to_str().ok())
+        .collect::<Vec<_>>()
+        .join(" ")
+        .to_lowercase()
+}
+
+/// Résout les contournements à appliquer à une identité
+pub fn quirks_for_identity(identity: &str) -> ClientQuirks {
+    let mut quirks = ClientQuirks::default();
+    if identity.is_empty() {
+        return quirks;
+    }
+    for rule in RULES.read().unwrap().iter() {
+        if rule.matches(identity) {
+            debug!("🩹 Quirk rule '{}' applies to '{}'", rule.pattern, identity);
+            rule.apply(&mut quirks);
+        }
+    }
+    quirks
+}
+
+/// Résout les contournements à appliquer au client d'une requête
+pub fn quirks_for_headers(headers: &HeaderMap) -> ClientQuirks {
+    quirks_for_identity(&client_identity(headers))
+}
+
+impl ClientQuirks {
+    /// Met en forme la déclaration XML d'un document
+    pub fn format_xml(&self, xml: String) -> String {
+        if self.xml_declaration == XmlDeclaration::AsIs || !xml.starts_with("<?xml") {
+            return xml;
+        }
+        let Some(end) = xml.find("?>").map(|p| p + 2) else {
+            return xml;
+        };
+        let rest = xml[end..].trim_start();
+        match self.xml_declaration {
+            XmlDeclaration::Newline => format!("{}\n{}", &xml[..end], rest),
+            XmlDeclaration::Compact => format!("{}{}", &xml[..end], rest),
+            XmlDeclaration::AsIs => unreachable!(),
+        }
+    }
+
+    /// Encode une valeur de variable pour le corps d'un événement GENA
+    pub fn encode_event_value(&self, value: &str) -> String {
+        let mut out = String::with_capacity(value.len());
+        self.push_event_value(value, &mut out);
+        out
+    }
+
+    /// Comme [`encode_event_value`](Self::encode_event_value), à la suite de `out`
+    pub fn push_event_value(&self, value: &str, out: &mut String) {
+        match self.event_encoding {
+            EventEncoding::Raw => out.push_str(value),
+            EventEncoding::Escaped => out.push_str(&escape(value)),
+            EventEncoding::Cdata => {
+                out.push_str("<![CDATA[");
+                out.push_str(&value.replace("]]>", "]]]]><![CDATA[>"));
+                out.push_str("]]>");
+            }
+        }
+    }
+
+    /// Applique les contournements à une réponse SOAP
+    ///
+    /// Sans contournement actif, la réponse est renvoyée telle quelle.
+    pub async fn apply_to_response(&self, response: Response) -> Response {
+        if self.xml_declaration == XmlDeclaration::AsIs && !self.chunked {
+            return response;
+        }
+
+        let (mut parts, body) = response.into_parts();
+        let bytes = match axum::body::to_bytes(body, MAX_REWRITE_BYTES).await {
+            Ok(bytes) => bytes,
+            Err(e) => {
+                warn!("Failed to buffer SOAP response for quirks: {}", e);
+                return Response::from_parts(parts, Body::empty());
+            }
+        };
+
+        let bytes = match String::from_utf8(bytes.to_vec()) {
+            Ok(xml) => self.format_xml(xml).into_bytes(),
+            Err(_) => bytes.to_vec(),
+        };
+
+        parts.headers.remove(header::CONTENT_LENGTH);
+        let body = if self.chunked {
+            // Un flux de taille inconnue force Transfer-Encoding: chunked
+            let chunk: Result<Vec<u8>, std::io::Error> = Ok(bytes);
+            Body::from_stream(futures_util::stream::once(async move { chunk }))
+        } else {
+            parts.headers.insert(
+                header::CONTENT_LENGTH,
+                HeaderValue::from(bytes.len()),
+            );
+            Body::from(bytes)
+        };
+        Response::from_parts(parts, body)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_rules_resolution() {
+        let mut headers = HeaderMap::new();
+        headers.insert(header::USER_AGENT, HeaderValue::from_static("Xbox/2.0.4548.0 UPnP/1.0 Xbox/2.0.4548.0"));
+        assert!(quirks_for_headers(&headers).prefer_wav);
+
+        register_rule(QuirkRule {
+            xml_declaration: Some(XmlDeclaration::Newline),
+            event_encoding: Some(EventEncoding::Escaped),
+            ..QuirkRule::builtin("TestRenderer")
+        });
+        let quirks = quirks_for_identity("testrenderer/1.0 dlnadoc/1.50");
+        assert_eq!(quirks.xml_declaration, XmlDeclaration::Newline);
+        assert!(!quirks.prefer_wav);
+
+        assert_eq!(quirks_for_identity(""), ClientQuirks::default());
+    }
+
+    #[test]
+    fn test_format_and_encode() {
+        let xml = r#"<?xml version="1.0"?>   <a/>"#.to_string();
+        let newline = ClientQuirks {
+            xml_declaration: XmlDeclaration::Newline,
+            ..Default::default()
+        };
+        assert_eq!(newline.format_xml(xml.clone()), "<?xml version=\"1.0\"?>\n<a/>");
+        let compact = ClientQuirks {
+            xml_declaration: XmlDeclaration::Compact,
+            ..Default::default()
+        };
+        assert_eq!(compact.format_xml(xml), "<?xml version=\"1.0\"?><a/>");
+
+        let escaped = ClientQuirks {
+            event_encoding: EventEncoding::Escaped,
+            ..Default::default()
+        };
+        assert_eq!(escaped.encode_event_value("<Event/>"), "&lt;Event/&gt;");
+    }
+}
@@ -27,6 +27,8 @@
 /// - `"ServiceName"` : Nom du service UPnP (chaîne littérale)
 /// - `variables:` : Section listant les références aux variables d'état
 /// - `actions:` : Section listant les références aux actions
+/// - `domain:` (optionnel, en tête) : domaine du type de service, par exemple
+///   `"av-openhome-org"` pour `urn:av-openhome-org:service:Time:1`
 ///
 /// # Type de retour
 ///
@@ -77,6 +79,38 @@
 /// - Utilise `.expect()` pour les erreurs d'ajout
 #[macro_export]
 macro_rules! define_service {
+    (pub static $name:ident = $service_name:literal {
+        domain: $domain:literal,
+        variables: [
+            $($var:expr),* $(,)?
+        ],
+        actions: [
+            $($action:expr),* $(,)?
+        ]
+    }) => {
+        pub static $name: once_cell::sync::Lazy<std::sync::Arc<$crate::services::Service>> =
+            once_cell::sync::Lazy::new(|| {
+                use $crate::UpnpTyped;
+
+                let mut svc = $crate::services::Service::new($service_name.to_string());
+                svc.set_domain($domain.to_string());
+
+                $(
+                    svc.add_variable(std::sync::Arc::clone(&*$var))
+                        .expect(&format!("Cannot add variable {} to service {}",
+                            (*$var).get_name(), svc.name()));
+                )*
+
+                $(
+                    svc.add_action(std::sync::Arc::clone(&*$action))
+                        .expect(&format!("Cannot add action {} to service {}",
+                            (*$action).get_name(), svc.name()));
+                )*
+
+                std::sync::Arc::new(svc)
+            });
+    };
+
     (pub static $name:ident = $service_name:literal {
         variables: [
             $($var:expr),* $(,)?
@@ -43,17 +43,22 @@
 
 mod errors;
 mod macros;
+mod service_builder;
 mod service_instance;
 mod service_methods;
 
 use std::sync::Arc;
 
 pub use errors::ServiceError;
+pub use service_builder::ServiceBuilder;
 pub use service_instance::ServiceInstance;
-use xmltree::{Element, EmitterConfig, XMLNode};
+use xmltree::{Element, XMLNode};
 
 use crate::{UpnpObject, UpnpObjectType, actions::ActionSet, state_variables::StateVariableSet};
 
+/// Domaine par défaut des types de service (forum UPnP)
+pub const UPNP_SERVICE_DOMAIN: &str = "schemas-upnp-org";
+
 /// Service UPnP (modèle).
 ///
 /// Représente la définition d'un service UPnP avec ses actions et variables d'état.
@@ -90,6 +95,7 @@ use crate::{UpnpObject, UpnpObjectType, actions::ActionSet, state_variables::Sta
 /// );
 /// service.add_variable(search_caps);
 /// ```
+
 #[derive(Debug, Clone)]
 pub struct Service {
     /// Métadonnées de l'objet UPnP
@@ -101,6 +107,9 @@ pub struct Service {
     /// Version du service (>= 1)
     version: u32,
 
+    /// Domaine du type de service (ex: "schemas-upnp-org", "av-openhome-org")
+    domain: String,
+
     /// Actions disponibles dans ce service
     actions: ActionSet,
 
@@ -138,6 +147,7 @@ impl Service {
             },
             identifier: name,
             version: 1,
+            domain: UPNP_SERVICE_DOMAIN.to_string(),
             state_table: StateVariableSet::new(),
             actions: ActionSet::new(),
         }
@@ -241,6 +251,48 @@ impl Service {
         Ok(())
     }
 
+    /// Retourne le domaine du type de service.
+    ///
+    /// # Examples
+    ///
+    /// ```rust
+    /// # use pmoupnp::services::Service;
+    /// let service = Service::new("AVTransport".to_string());
+    /// assert_eq!(service.domain(), "schemas-upnp-org");
+    /// ```
+    pub fn domain(&self) -> &str {
+        &self.domain
+    }
+
+    /// Définit le domaine du type de service.
+    ///
+    /// Utilisé pour les services hors du forum UPnP, comme OpenHome
+    /// (`urn:av-openhome-org:service:Time:1`).
+    ///
+    /// # Examples
+    ///
+    /// ```rust
+    /// # use pmoupnp::services::Service;
+    /// let mut service = Service::new("Time".to_string());
+    /// service.set_domain("av-openhome-org".to_string());
+    /// assert_eq!(service.service_type(), "urn:av-openhome-org:service:Time:1");
+    /// assert_eq!(service.service_id(), "urn:av-openhome-org:serviceId:Time");
+    /// ```
+    pub fn set_domain(&mut self, domain: String) {
+        self.domain = domain;
+    }
+
+    /// Domaine des identifiants de service (`serviceId`).
+    ///
+    /// Le forum UPnP utilise `upnp-org`, les autres domaines sont repris tels quels.
+    pub fn service_id_domain(&self) -> &str {
+        if self.domain == UPNP_SERVICE_DOMAIN {
+            "upnp-org"
+        } else {
+            &self.domain
+        }
+    }
+
     /// Ajoute une variable d'état au service.
     ///
     /// # Arguments
@@ -375,7 +427,8 @@ impl Service {
     /// ```
     pub fn service_type(&self) -> String {
         format!(
-            "urn:schemas-upnp-org:service:{}:{}",
+            "urn:{}:service:{}:{}",
+            self.domain,
             self.name(),
             self.version
         )
@@ -383,20 +436,18 @@ impl Service {
 
     /// Retourne l'ideintifiant du service UPnP.
     ///
-    /// Format: `urn:schemas-upnp-org:serviceId:{name}`
+    /// Format: `urn:{domaine}:serviceId:{name}`, le domaine étant celui de
+    /// [`Self::service_id_domain`].
     ///
     /// # Examples
     ///
     /// ```rust
     /// # use pmoupnp::services::Service;
     /// let service = Service::new("AVTransport".to_string());
-    /// assert_eq!(
-    ///     service.service_id(),
-    ///     "urn:schemas-upnp-org:serviceId:AVTransport"
-    /// );
+    /// assert_eq!(service.service_id(), "urn:upnp-org:serviceId:AVTransport");
     /// ```
     pub fn service_id(&self) -> String {
-        format!("urn:schemas-upnp-org:serviceId:{}", self.name())
+        format!("urn:{}:serviceId:{}", self.service_id_domain(), self.name())
     }
 
     /// Retourne l'URL de base du service.
@@ -510,15 +561,7 @@ impl Service {
     pub fn scpd_xml(&self) -> String {
         let elem = self.scpd_element();
 
-        let config = EmitterConfig::new()
-            .perform_indent(true)
-            .indent_string("  ");
-
-        let mut buf = Vec::new();
-        elem.write_with_config(&mut buf, config)
-            .expect("Failed to write XML");
-
-        String::from_utf8(buf).expect("Invalid UTF-8")
+        crate::xml_format::write_xml(&elem).expect("Failed to write XML")
     }
 }
 
@@ -0,0 +1,100 @@
+//! Builder de services UPnP.
+
+use std::sync::Arc;
+
+use crate::{actions::Action, state_variables::StateVariable};
+
+use super::{Service, ServiceError};
+
+/// Construit un [`Service`] en une expression.
+///
+/// Les erreurs (version invalide, doublons) sont remontées par
+/// [`ServiceBuilder::build`].
+///
+/// # Examples
+///
+/// ```rust
+/// # use pmoupnp::services::ServiceBuilder;
+/// # use pmoupnp::actions::Action;
+/// # use pmoupnp::state_variables::StateVariable;
+/// # use pmoupnp::variable_types::StateVarType;
+/// # use std::sync::Arc;
+/// let service = ServiceBuilder::new("Volume")
+///     .domain("example-com")
+///     .version(2)
+///     .variable(Arc::new(StateVariable::new(StateVarType::UI2, "Level".to_string())))
+///     .action(Arc::new(Action::new("GetLevel".to_string())))
+///     .build()
+///     .unwrap();
+/// assert_eq!(service.service_type(), "urn:example-com:service:Volume:2");
+/// ```
+pub struct ServiceBuilder {
+    name: String,
+    identifier: Option<String>,
+    version: u32,
+    domain: Option<String>,
+    variables: Vec<Arc<StateVariable>>,
+    actions: Vec<Arc<Action>>,
+}
+
+impl ServiceBuilder {
+    /// Nouveau builder pour le service `name` (version 1, domaine UPnP)
+    pub fn new(name: impl Into<String>) -> Self {
+        Self {
+            name: name.into(),
+            identifier: None,
+            version: 1,
+            domain: None,
+            variables: Vec::new(),
+            actions: Vec::new(),
+        }
+    }
+
+    pub fn identifier(mut self, identifier: impl Into<String>) -> Self {
+        self.identifier = Some(identifier.into());
+        self
+    }
+
+    pub fn version(mut self, version: u32) -> Self {
+        self.version = version;
+        self
+    }
+
+    /// Domaine du type de service (ex: `av-openhome-org`)
+    pub fn domain(mut self, domain: impl Into<String>) -> Self {
+        self.domain = Some(domain.into());
+        self
+    }
+
+    pub fn variable(mut self, variable: Arc<StateVariable>) -> Self {
+        self.variables.push(variable);
+        self
+    }
+
+    pub fn action(mut self, action: Arc<Action>) -> Self {
+        self.actions.push(action);
+        self
+    }
+
+    /// Construit le service.
+    ///
+    /// Les variables sont ajoutées avant les actions, qui peuvent donc
+    /// référencer n'importe laquelle d'entre elles.
+    pub fn build(self) -> Result<Service, ServiceError> {
+        let mut service = Service::new(self.name);
+        service.set_version(self.version)?;
+        if let Some(identifier) = self.identifier {
+            service.set_identifier(identifier);
+        }
+        if let Some(domain) = self.domain {
+            service.set_domain(domain);
+        }
+        for variable in self.variables {
+            service.add_variable(variable)?;
+        }
+        for action in self.actions {
+            service.add_action(action)?;
+        }
+        Ok(service)
+    }
+}
@@ -29,28 +29,37 @@
 //! ```
 
 use axum::{
+    Extension, Router,
     body::Body,
-    extract::{Request, State},
+    extract::{ConnectInfo, Request, State},
     http::{HeaderMap, StatusCode},
     response::{IntoResponse, Response},
+    routing::{any, post},
 };
 use bevy_reflect::Reflect;
+use bytes::Bytes;
 use quick_xml::escape::escape;
 use std::{
     collections::HashMap,
+    net::SocketAddr,
     sync::{Arc, Mutex, RwLock},
     time::Duration,
 };
 use tokio::time;
-use tracing::{debug, error, info, warn};
-use xmltree::{Element, EmitterConfig, XMLNode};
+use tracing::{debug, error, info, trace, warn};
+use xmltree::{Element, XMLNode};
 
 use crate::{
     UpnpInstance, UpnpObject, UpnpObjectType, UpnpTyped, UpnpTypedInstance,
     actions::{ActionInstance, ActionInstanceSet},
-    devices::DeviceInstance,
+    audit::ActionRecord,
+    devices::{CachedXml, DeviceInstance, cached_xml},
+    eventing::{self, Delivery, PropertySet},
+    features, policies,
+    quirks::{ClientQuirks, quirks_for_headers},
     services::{Service, ServiceError},
-    state_variables::{StateVarInstance, StateVarInstanceSet, UpnpVariable},
+    state_variables::{StateVarInstance, StateVarInstanceSet, TypedStateVar, UpnpVariable},
+    variable_types::TypedValue,
 };
 
 /// Méthodes HTTP pour les événements UPnP.
@@ -118,11 +127,17 @@ pub struct ServiceInstance {
     /// Abonnés aux événements (SID -> Callback URL)
     subscribers: Arc<RwLock<HashMap<String, String>>>,
 
+    /// Contournements propres à chaque abonné (SID -> quirks)
+    subscriber_quirks: Arc<RwLock<HashMap<String, ClientQuirks>>>,
+
     /// Buffer des changements en attente de notification (nom de variable -> valeur réflexive)
     changed_buffer: Arc<Mutex<HashMap<String, Arc<dyn Reflect>>>>,
 
     /// Compteurs de séquence par abonné
     seqid: Arc<Mutex<HashMap<String, u32>>>,
+
+    /// Tâche du notifier périodique et son intervalle
+    notifier: Arc<Mutex<Option<(Duration, tokio::task::JoinHandle<()>)>>>,
 }
 
 impl std::fmt::Debug for ServiceInstance {
@@ -205,8 +220,10 @@ impl UpnpInstance for ServiceInstance {
             statevariables,
             actions,
             subscribers: Arc::new(RwLock::new(HashMap::new())),
+            subscriber_quirks: Arc::new(RwLock::new(HashMap::new())),
             changed_buffer: Arc::new(Mutex::new(HashMap::new())),
             seqid: Arc::new(Mutex::new(HashMap::new())),
+            notifier: Arc::new(Mutex::new(None)),
         }
     }
 }
@@ -324,7 +341,11 @@ impl ServiceInstance {
     /// assert_eq!(instance.service_id(), "urn:upnp-org:serviceId:AVTransport");
     /// ```
     pub fn service_id(&self) -> String {
-        format!("urn:upnp-org:serviceId:{}", self.identifier)
+        format!(
+            "urn:{}:serviceId:{}",
+            self.model.service_id_domain(),
+            self.identifier
+        )
     }
 
     /// Récupère une variable d'état par son nom.
@@ -352,6 +373,14 @@ impl ServiceInstance {
         self.statevariables.get_by_name(name)
     }
 
+    /// Retourne une variable d'état typée par nom.
+    ///
+    /// `None` si la variable n'existe pas ou si son type UPnP ne correspond
+    /// pas à `T` (voir [`TypedValue`]).
+    pub fn get_typed_variable<T: TypedValue>(&self, name: &str) -> Option<TypedStateVar<T>> {
+        TypedStateVar::new(self.get_variable(name)?).ok()
+    }
+
     /// Récupère une action par son nom.
     ///
     /// # Arguments
@@ -400,8 +429,62 @@ impl ServiceInstance {
     /// service_instance.set_device(device_instance);
     /// ```
     pub fn set_device(&self, device: Arc<DeviceInstance>) {
-        let mut dev = self.device.write().unwrap();
-        *dev = Some(device);
+        let first_attach = {
+            let mut dev = self.device.write().unwrap();
+            dev.replace(device).is_none()
+        };
+
+        // Le device fournit l'UDN : les variables persistantes peuvent être restaurées
+        if first_attach {
+            for var in self.statevariables.all() {
+                var.restore_persisted_value();
+            }
+        }
+    }
+
+    /// UDN du device parent, s'il est défini.
+    pub fn device_udn(&self) -> Option<String> {
+        let device = self.device.read().unwrap();
+        device.as_ref().map(|device| device.udn().to_string())
+    }
+
+    /// Applique `f` à la vue de ce service utilisée par les règles de
+    /// [`crate::features`].
+    fn with_feature_ref<R>(&self, f: impl FnOnce(&features::ServiceRef<'_>) -> R) -> R {
+        let (device_name, device_category) = match self.device.read().unwrap().as_ref() {
+            Some(device) => (
+                device.get_name().to_string(),
+                device.get_model().device_category().to_string(),
+            ),
+            None => (String::new(), String::new()),
+        };
+        let service_type = self.service_type();
+        f(&features::ServiceRef {
+            device_name: &device_name,
+            device_category: &device_category,
+            service_name: self.get_name(),
+            service_type: &service_type,
+        })
+    }
+
+    /// Le service est-il annoncé (description, SSDP) ?
+    pub fn is_enabled(&self) -> bool {
+        self.with_feature_ref(features::is_service_enabled)
+    }
+
+    /// L'action est-elle annoncée dans le SCPD et invocable ?
+    pub fn is_action_enabled(&self, action: &str) -> bool {
+        self.with_feature_ref(|service| features::is_action_enabled(service, action))
+    }
+
+    /// Applique les restrictions de [`crate::policies`] à une invocation
+    /// (les arguments de volume peuvent être bornés).
+    pub fn enforce_policies(
+        &self,
+        action: &str,
+        args: &mut HashMap<String, String>,
+    ) -> Result<(), policies::PolicyViolation> {
+        self.with_feature_ref(|service| policies::enforce(service, action, args))
     }
 
     /// Retourne la route du service (chemin relatif).
@@ -569,22 +652,32 @@ impl ServiceInstance {
         // Handler SCPD
         let instance_scpd = self.clone();
         server
-            .add_handler(&self.scpd_route(), move || {
+            .add_handler(&self.scpd_route(), move |headers: HeaderMap| {
                 let instance = instance_scpd.clone();
-                async move { instance.scpd_handler().await }
+                async move { instance.scpd_handler(&headers).await }
             })
             .await;
 
+        // Contrôle et événements sont ouverts à tout le LAN : taille du corps,
+        // durée et concurrence des requêtes bornées (host.limits)
+        let limits = pmoserver::RequestLimits::control();
+
         // Handler control
         let instance_control = Arc::new(self.clone());
+        let control = Router::new()
+            .route("/", post(control_handler))
+            .with_state(instance_control);
         server
-            .add_post_handler_with_state(&self.control_route(), control_handler, instance_control)
+            .add_router(&self.control_route(), limits.apply(control))
             .await;
 
         // Handler événements (SUBSCRIBE/UNSUBSCRIBE sont des verbes spécifiques, pas GET)
         let instance_event = self.clone();
+        let event = Router::new()
+            .route("/", any(event_sub_handler))
+            .with_state(instance_event);
         server
-            .add_any_handler_with_state(&self.event_route(), event_sub_handler, instance_event)
+            .add_router(&self.event_route(), limits.apply(event))
             .await;
 
         Ok(())
@@ -628,10 +721,22 @@ impl ServiceInstance {
 
         elem.children.push(XMLNode::Element(spec));
 
-        // actionList (depuis le modèle)
-        if !self.model.actions.all().is_empty() {
-            elem.children
-                .push(XMLNode::Element(self.model.actions.to_xml_element()));
+        // actionList (depuis le modèle, sans les actions désactivées)
+        let actions: Vec<_> = self
+            .model
+            .actions
+            .all()
+            .into_iter()
+            .filter(|action| self.is_action_enabled(action.get_name()))
+            .collect();
+        if !actions.is_empty() {
+            let mut action_list = Element::new("actionList");
+            for action in actions {
+                action_list
+                    .children
+                    .push(XMLNode::Element(action.to_xml_element()));
+            }
+            elem.children.push(XMLNode::Element(action_list));
         }
 
         // serviceStateTable (depuis le modèle)
@@ -650,44 +755,52 @@ impl ServiceInstance {
     ///
     /// # Returns
     ///
-    /// Une réponse HTTP 200 avec le XML SCPD, ou 500 en cas d'erreur de sérialisation.
+    /// Une réponse HTTP 200 avec le XML SCPD, 304 si la requête conditionnelle
+    /// du client correspond au SCPD courant, ou 500 en cas d'erreur de
+    /// sérialisation.
     ///
     /// # Format de réponse
     ///
-    /// - Content-Type: `text/xml; charset="utf-8"`
-    /// - Body: Document SCPD formaté avec indentation
-    async fn scpd_handler(&self) -> Response {
+    /// - Content-Type: `text/xml; charset="utf-8"`, Content-Length
+    /// - ETag et Last-Modified
+    /// - Server et Cache-Control du device parent
+    /// - Body: Document SCPD sérialisé selon [`crate::xml_format`]
+    async fn scpd_handler(&self, request: &HeaderMap) -> Response {
         info!("📋 SCPD requested for service {}", self.get_name());
 
-        let elem = self.scpd_element();
-
-        let config = EmitterConfig::new()
-            .perform_indent(true)
-            .indent_string("  ");
+        let document = match self.cached_scpd() {
+            Ok(document) => document,
+            Err(e) => {
+                error!("❌ Failed to serialize SCPD XML: {}", e);
+                return StatusCode::INTERNAL_SERVER_ERROR.into_response();
+            }
+        };
 
-        let mut xml_output = Vec::new();
-        if let Err(e) = elem.write_with_config(&mut xml_output, config) {
-            error!("❌ Failed to serialize SCPD XML: {}", e);
-            return StatusCode::INTERNAL_SERVER_ERROR.into_response();
+        // En-têtes Server/Cache-Control du device parent
+        match self.device.read().unwrap().as_ref() {
+            Some(device) => device.xml_response(request, document),
+            None => crate::devices::conditional_xml_response(request, document),
         }
+    }
 
-        let xml = String::from_utf8_lossy(&xml_output).to_string();
-
-        debug!(
-            "✅ SCPD generated for {} ({} bytes)",
-            self.get_name(),
-            xml.len()
-        );
+    /// Document SCPD sérialisé.
+    ///
+    /// Le document est rendu une fois puis servi depuis le cache tant que ni
+    /// la configuration ni la composition des devices ne changent.
+    pub fn scpd_xml(&self) -> Result<Bytes, xmltree::Error> {
+        self.cached_scpd().map(|document| document.xml)
+    }
 
-        (
-            StatusCode::OK,
-            [(
-                axum::http::header::CONTENT_TYPE,
-                "text/xml; charset=\"utf-8\"",
-            )],
-            xml,
-        )
-            .into_response()
+    fn cached_scpd(&self) -> Result<CachedXml, xmltree::Error> {
+        cached_xml(&self.scpd_route(), || {
+            let xml = crate::xml_format::write_xml(&self.scpd_element())?;
+            debug!(
+                "✅ SCPD generated for {} ({} bytes)",
+                self.get_name(),
+                xml.len()
+            );
+            Ok(xml)
+        })
     }
 
     /// Ajoute un abonné aux événements.
@@ -724,6 +837,11 @@ impl ServiceInstance {
     /// * `sid` - Identifiant de la souscription (SID)
     /// * `timeout` - Nouvelle durée de validité (format "Second-{n}")
     ///
+    /// # Returns
+    ///
+    /// `false` si le SID est inconnu (abonnement expiré ou oublié après une
+    /// veille) : l'abonné doit se réabonner.
+    ///
     /// # Examples
     ///
     /// ```rust,no_run
@@ -736,8 +854,12 @@ impl ServiceInstance {
     /// instance.renew_subscriber("uuid:12345", "Second-1800").await;
     /// # }
     /// ```
-    pub async fn renew_subscriber(&self, sid: &str, timeout: &str) {
+    pub async fn renew_subscriber(&self, sid: &str, timeout: &str) -> bool {
+        if !self.subscribers.read().unwrap().contains_key(sid) {
+            return false;
+        }
         info!("♻️ Renewed SID {} for timeout {}", sid, timeout);
+        true
     }
 
     /// Supprime un abonné.
@@ -761,6 +883,59 @@ impl ServiceInstance {
     pub async fn remove_subscriber(&self, sid: &str) {
         let mut subscribers = self.subscribers.write().unwrap();
         subscribers.remove(sid);
+        self.subscriber_quirks.write().unwrap().remove(sid);
+    }
+
+    /// Oublie tous les abonnés, par exemple en sortie de veille du système.
+    ///
+    /// Leurs abonnements ont pu expirer pendant la veille et leurs compteurs
+    /// SEQ ne correspondent plus à ce que les control points attendent : les
+    /// renouvellements sont alors refusés et les abonnés se réabonnent, en
+    /// repartant de l'événement initial (SEQ 0).
+    ///
+    /// # Returns
+    ///
+    /// Le nombre d'abonnés retirés.
+    pub fn clear_subscribers(&self) -> usize {
+        let count = {
+            let mut subscribers = self.subscribers.write().unwrap();
+            let count = subscribers.len();
+            subscribers.clear();
+            count
+        };
+        self.subscriber_quirks.write().unwrap().clear();
+        self.seqid.lock().unwrap().clear();
+        count
+    }
+
+    /// Associe des contournements à un abonné (résolus à la souscription).
+    pub fn set_subscriber_quirks(&self, sid: &str, quirks: ClientQuirks) {
+        self.subscriber_quirks
+            .write()
+            .unwrap()
+            .insert(sid.to_string(), quirks);
+    }
+
+    fn quirks_for_subscriber(&self, sid: &str) -> ClientQuirks {
+        self.subscriber_quirks
+            .read()
+            .unwrap()
+            .get(sid)
+            .copied()
+            .unwrap_or_default()
+    }
+
+    /// Liste les abonnés actuels (SID, URL de callback), triés par SID.
+    pub fn subscribers(&self) -> Vec<(String, String)> {
+        let mut list: Vec<(String, String)> = self
+            .subscribers
+            .read()
+            .unwrap()
+            .iter()
+            .map(|(sid, callback)| (sid.clone(), callback.clone()))
+            .collect();
+        list.sort();
+        list
     }
 
     /// Envoie l'événement initial à un nouvel abonné.
@@ -792,10 +967,10 @@ impl ServiceInstance {
         };
 
         if let Some(callback) = callback {
-            let mut changed = HashMap::new();
+            let mut changed = Vec::new();
             for sv in self.statevariables.all() {
                 if sv.is_sending_notification() {
-                    changed.insert(sv.get_name().to_string(), sv.value().to_string());
+                    changed.push((sv.get_name().to_string(), sv.value().to_string()));
                 }
             }
 
@@ -803,43 +978,13 @@ impl ServiceInstance {
                 return;
             }
 
-            tokio::spawn(async move {
-                let callback = callback.trim().trim_matches(|c| c == '<' || c == '>');
-
-                let mut body =
-                    r#"<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0">"#.to_string();
-                for (name, val) in changed {
-                    body.push_str(&format!(
-                        "<e:property><{0}>{1}</{0}></e:property>",
-                        name, val
-                    ));
-                }
-                body.push_str("</e:propertyset>");
-
-                let client = reqwest::Client::new();
-                match client
-                    .request(reqwest::Method::from_bytes(b"NOTIFY").unwrap(), callback)
-                    .header("Content-Type", r#"text/xml; charset="utf-8"#)
-                    .header("NT", "upnp:event")
-                    .header("NTS", "upnp:propchange")
-                    .header("SID", &sid)
-                    .header("SEQ", "0")
-                    .body(body)
-                    .send()
-                    .await
-                {
-                    Ok(resp) => {
-                        info!(
-                            "✅ Initial event sent to {}, status={}",
-                            callback,
-                            resp.status()
-                        );
-                    }
-                    Err(e) => {
-                        error!("Failed to send initial event to {}: {}", callback, e);
-                    }
-                }
-            });
+            let body = PropertySet::new(changed).body_for(self.quirks_for_subscriber(&sid));
+            eventing::spawn_deliveries(vec![Delivery {
+                sid,
+                callback,
+                seq: 0,
+                body,
+            }]);
         }
     }
 
@@ -879,19 +1024,27 @@ impl ServiceInstance {
     ///
     /// # Returns
     ///
-    /// Le prochain numéro de séquence sous forme de chaîne.
-    fn next_seq(&self, sid: &str) -> String {
+    /// Le prochain numéro de séquence.
+    fn next_seq(&self, sid: &str) -> u32 {
         let mut seqid = self.seqid.lock().unwrap();
-        let counter = seqid.entry(sid.to_string()).or_insert(0);
-        *counter += 1;
-        counter.to_string()
+        match seqid.get_mut(sid) {
+            Some(counter) => {
+                *counter = counter.wrapping_add(1).max(1);
+                *counter
+            }
+            None => {
+                seqid.insert(sid.to_string(), 1);
+                1
+            }
+        }
     }
 
     /// Notifie tous les abonnés des changements en attente.
     ///
-    /// Cette méthode envoie les changements bufferisés à tous les abonnés actuels
-    /// via des requêtes HTTP NOTIFY. Les changements sont envoyés de manière
-    /// asynchrone dans des tâches séparées.
+    /// Les valeurs sont converties une seule fois et le corps de l'événement
+    /// construit une fois par profil de contournements, puis partagé entre
+    /// les abonnés. Les envois partent dans une seule tâche, avec un nombre
+    /// borné d'envois simultanés (cf. [`crate::eventing`]).
     ///
     /// # Examples
     ///
@@ -910,12 +1063,15 @@ impl ServiceInstance {
     /// # }
     /// ```
     pub async fn notify_subscribers(&self) {
-        let subscribers_copy = {
+        let subscribers_copy: Vec<(String, String)> = {
             let subscribers = self.subscribers.read().unwrap();
             if subscribers.is_empty() {
                 return;
             }
-            subscribers.clone()
+            subscribers
+                .iter()
+                .map(|(sid, callback)| (sid.clone(), callback.clone()))
+                .collect()
         };
 
         let changed = {
@@ -926,46 +1082,30 @@ impl ServiceInstance {
             std::mem::take(&mut *buffer)
         };
 
-        for (sid, callback) in subscribers_copy {
-            let changed_clone = changed.clone();
-            let seq = self.next_seq(&sid);
-
-            tokio::spawn(async move {
-                let callback = callback.trim().trim_matches(|c| c == '<' || c == '>');
-
-                let mut body =
-                    r#"<e:propertyset xmlns:e="urn:schemas-upnp-org:event-1-0">"#.to_string();
-                for (name, val) in changed_clone {
-                    // Convertir la valeur Reflect en String
+        let mut properties = PropertySet::new(
+            changed
+                .into_iter()
+                .map(|(name, val)| {
                     let val_str = Self::reflect_to_string(&*val);
-                    body.push_str(&format!(
-                        "<e:property><{0}>{1}</{0}></e:property>",
-                        name, val_str
-                    ));
-                }
-                body.push_str("</e:propertyset>");
-
-                let client = reqwest::Client::new();
-                match client
-                    .request(reqwest::Method::from_bytes(b"NOTIFY").unwrap(), callback)
-                    .header("Content-Type", r#"text/xml; charset="utf-8"#)
-                    .header("NT", "upnp:event")
-                    .header("NTS", "upnp:propchange")
-                    .header("SID", &sid)
-                    .header("SEQ", seq)
-                    .body(body)
-                    .send()
-                    .await
-                {
-                    Ok(_) => {
-                        info!("✅ Notified subscriber {} of changes", callback);
-                    }
-                    Err(e) => {
-                        error!("Failed to notify subscriber {}: {}", callback, e);
-                    }
+                    (name, val_str)
+                })
+                .collect(),
+        );
+
+        let deliveries = subscribers_copy
+            .into_iter()
+            .map(|(sid, callback)| {
+                let seq = self.next_seq(&sid);
+                let body = properties.body_for(self.quirks_for_subscriber(&sid));
+                Delivery {
+                    sid,
+                    callback,
+                    seq,
+                    body,
                 }
-            });
-        }
+            })
+            .collect();
+        eventing::spawn_deliveries(deliveries);
     }
 
     /// Convertit une valeur Reflect en String pour la notification UPnP.
@@ -1101,10 +1241,12 @@ impl ServiceInstance {
     /// # Returns
     ///
     /// Un handle vers la tâche tokio du notifier.
-    pub fn start_notifier(&self, interval: Duration) -> tokio::task::JoinHandle<()> {
+    ///
+    /// Un notifier déjà démarré pour cette instance est arrêté au préalable.
+    pub fn start_notifier(&self, interval: Duration) -> tokio::task::AbortHandle {
         let instance = self.clone();
 
-        tokio::spawn(async move {
+        let handle = tokio::spawn(async move {
             let mut ticker = time::interval(interval);
             info!("✅ Starting notifier every {:?}", interval);
 
@@ -1112,7 +1254,37 @@ impl ServiceInstance {
                 ticker.tick().await;
                 instance.notify_subscribers().await;
             }
-        })
+        });
+        let abort = handle.abort_handle();
+
+        if let Some((_, previous)) = self.notifier.lock().unwrap().replace((interval, handle)) {
+            previous.abort();
+        }
+        abort
+    }
+
+    /// Redémarre le notifier avec le même intervalle.
+    ///
+    /// Utile si la tâche s'est arrêtée (panique dans un callback) ou pour
+    /// purger un envoi bloqué. Retourne `false` si aucun notifier n'a été
+    /// démarré pour ce service.
+    pub fn restart_notifier(&self) -> bool {
+        let interval = match self.notifier.lock().unwrap().as_ref() {
+            Some((interval, _)) => *interval,
+            None => return false,
+        };
+        warn!("🔁 Restarting notifier for service {}", self.get_name());
+        self.start_notifier(interval);
+        true
+    }
+
+    /// Le notifier est-il démarré et toujours actif ?
+    pub fn notifier_running(&self) -> bool {
+        self.notifier
+            .lock()
+            .unwrap()
+            .as_ref()
+            .map_or(false, |(_, handle)| !handle.is_finished())
     }
 }
 
@@ -1167,6 +1339,7 @@ async fn event_sub_handler(
                     instance
                         .add_subscriber(new_sid.clone(), callback.to_string())
                         .await;
+                    instance.set_subscriber_quirks(&new_sid, quirks_for_headers(&headers));
                 }
                 let timeout_val = if timeout.is_empty() {
                     "Second-1800"
@@ -1175,7 +1348,9 @@ async fn event_sub_handler(
                 };
                 info!(
                     "🔒 New subscription: SID={}, Callback={}, Timeout={}",
-                    new_sid, callback, timeout_val
+                    new_sid,
+                    crate::payload_log::redact_callbacks(callback),
+                    timeout_val
                 );
 
                 let sid_clone = new_sid.clone();
@@ -1187,7 +1362,13 @@ async fn event_sub_handler(
                 (new_sid, timeout_val.to_string())
             } else {
                 // Renouvellement
-                instance.renew_subscriber(sid, timeout).await;
+                if !instance.renew_subscriber(sid, timeout).await {
+                    warn!(
+                        "⛔ Renewal for unknown SID={}, resubscription required",
+                        sid
+                    );
+                    return StatusCode::PRECONDITION_FAILED.into_response();
+                }
                 info!("♻️ Renew subscription: SID={}, Timeout={}", sid, timeout);
                 (sid.to_string(), timeout.to_string())
             };
@@ -1241,7 +1422,49 @@ async fn event_sub_handler(
 /// - Action non trouvée
 /// - Arguments invalides
 /// - Échec de l'exécution de l'action
-async fn control_handler(State(instance): State<Arc<ServiceInstance>>, body: String) -> Response {
+async fn control_handler(
+    State(instance): State<Arc<ServiceInstance>>,
+    connect_info: Option<Extension<ConnectInfo<SocketAddr>>>,
+    headers: HeaderMap,
+    body: String,
+) -> Response {
+    let quirks = quirks_for_headers(&headers);
+
+    let device_udn = instance
+        .device
+        .read()
+        .unwrap()
+        .as_ref()
+        .map(|d| d.udn().to_string())
+        .unwrap_or_default();
+    let user_agent = headers
+        .get(axum::http::header::USER_AGENT)
+        .and_then(|v| v.to_str().ok())
+        .map(str::to_string);
+    let client_addr = connect_info.map(|Extension(ConnectInfo(addr))| addr);
+    let caller = crate::actions::ActionCaller {
+        addr: client_addr.map(|addr| addr.ip()),
+        user_agent: user_agent.clone(),
+    };
+    let mut record = ActionRecord::start(
+        device_udn,
+        instance.get_name().to_string(),
+        client_addr,
+        user_agent,
+    );
+
+    let response = caller
+        .scope(handle_control(instance, body, &mut record))
+        .await;
+    record.finish();
+    quirks.apply_to_response(response).await
+}
+
+async fn handle_control(
+    instance: Arc<ServiceInstance>,
+    body: String,
+    record: &mut ActionRecord,
+) -> Response {
     use crate::{
         UpnpTypedInstance,
         soap::{build_soap_fault, build_soap_response, error_codes, parse_soap_action},
@@ -1251,12 +1474,17 @@ async fn control_handler(State(instance): State<Arc<ServiceInstance>>, body: Str
     use tracing::debug;
 
     info!("📡 Control request for {}", instance.get_name());
+    crate::trace_payload!(body, "📥 SOAP request for {}", instance.get_name());
 
     // Parser le SOAP pour extraire l'action et ses arguments
-    let soap_action = match parse_soap_action(body.as_bytes()) {
+    let mut soap_action = match parse_soap_action(body.as_bytes()) {
         Ok(action) => action,
         Err(e) => {
             error!("❌ Failed to parse SOAP: {:?}", e);
+            record.set_fault(
+                error_codes::INVALID_ACTION,
+                "The SOAP request could not be parsed",
+            );
             let fault_xml = build_soap_fault(
                 "s:Client",
                 "Invalid SOAP request",
@@ -1276,13 +1504,28 @@ async fn control_handler(State(instance): State<Arc<ServiceInstance>>, body: Str
     };
 
     debug!("🎬 Received SOAP action: {}", soap_action.name);
-    debug!("🎬 SOAP arguments: {:?}", soap_action.args);
-
-    // Trouver l'action correspondante dans l'instance
-    let action_instance = match instance.action(&soap_action.name) {
+    record.set_action(&soap_action.name);
+    record.set_args(&soap_action.args);
+    if soap_action.version == crate::soap::SoapVersion::V1_2 {
+        debug!("🎬 SOAP 1.2 envelope accepted, replying in SOAP 1.1");
+    }
+    debug!(
+        "🎬 SOAP arguments: {}",
+        crate::payload_log::redact(&format!("{:?}", soap_action.args))
+    );
+
+    // Trouver l'action correspondante dans l'instance (hors actions désactivées)
+    let action_instance = match instance
+        .action(&soap_action.name)
+        .filter(|_| instance.is_action_enabled(&soap_action.name))
+    {
         Some(action_inst) => action_inst,
         None => {
             error!("❌ Action not found: {}", soap_action.name);
+            record.set_fault(
+                error_codes::INVALID_ACTION,
+                format!("Action '{}' not found", soap_action.name),
+            );
             let fault_xml = build_soap_fault(
                 "s:Client",
                 "Invalid Action",
@@ -1301,10 +1544,38 @@ async fn control_handler(State(instance): State<Arc<ServiceInstance>>, body: Str
         }
     };
 
+    // Restrictions par device (heures calmes, volume maximal)
+    if let Err(violation) = instance.enforce_policies(&soap_action.name, &mut soap_action.args) {
+        warn!(
+            "⛔ Action {} refused by policy: {}",
+            soap_action.name, violation.reason
+        );
+        record.set_fault(violation.code, violation.reason.clone());
+        let fault_xml = build_soap_fault(
+            "s:Client",
+            "UPnPError",
+            Some(violation.code),
+            Some(&violation.reason)
+        ).unwrap_or_else(|_| String::from("<?xml version=\"1.0\"?><s:Envelope xmlns:s=\"http://schemas.xmlsoap.org/soap/envelope/\"><s:Body><s:Fault><faultcode>s:Server</faultcode><faultstring>Internal Error</faultstring></s:Fault></s:Body></s:Envelope>"));
+        return (
+            StatusCode::INTERNAL_SERVER_ERROR,
+            [(
+                axum::http::header::CONTENT_TYPE,
+                "text/xml; charset=\"utf-8\"",
+            )],
+            fault_xml,
+        )
+            .into_response();
+    }
+
     // Convertir les arguments SOAP (String) en StateValue
     let mut soap_values = HashMap::new();
     for (arg_name, arg_value) in soap_action.args {
-        debug!("🔍 Processing SOAP arg: {} = '{}'", arg_name, arg_value);
+        trace!(
+            "🔍 Processing SOAP arg: {} = '{}'",
+            arg_name,
+            crate::payload_log::redact(&arg_value)
+        );
         // Trouver l'argument correspondant pour obtenir son type
         if let Some(arg_inst) = action_instance.argument(&arg_name) {
             if let Some(var_inst) = arg_inst.get_variable_instance() {
@@ -1312,11 +1583,15 @@ async fn control_handler(State(instance): State<Arc<ServiceInstance>>, body: Str
                 // Parser la valeur selon le type de la variable
                 match StateValue::from_string(&arg_value, &var_model.as_state_var_type()) {
                     Ok(value) => {
-                        debug!("✅ Parsed {} = {:?}", arg_name, value);
+                        trace!("✅ Parsed {} = {:?}", arg_name, value);
                         soap_values.insert(arg_name, value);
                     }
                     Err(e) => {
                         error!("❌ Failed to parse argument '{}': {:?}", arg_name, e);
+                        record.set_fault(
+                            error_codes::ARGUMENT_VALUE_INVALID,
+                            format!("Invalid value for argument '{}'", arg_name),
+                        );
                         let fault_xml = build_soap_fault(
                             "s:Client",
                             "Invalid Arguments",
@@ -1359,6 +1634,8 @@ async fn control_handler(State(instance): State<Arc<ServiceInstance>>, body: Str
                 }
             }
 
+            record.set_output(&soap_values);
+
             // Construire la réponse SOAP
             let response_xml = build_soap_response(
                 &instance.service_type(),
@@ -1372,6 +1649,7 @@ async fn control_handler(State(instance): State<Arc<ServiceInstance>>, body: Str
                     Some("Failed to build SOAP response")
                 ).unwrap_or_else(|_| String::from("<?xml version=\"1.0\"?><s:Envelope xmlns:s=\"http://schemas.xmlsoap.org/soap/envelope/\"><s:Body><s:Fault><faultcode>s:Server</faultcode><faultstring>Internal Error</faultstring></s:Fault></s:Body></s:Envelope>"))
             });
+            crate::trace_payload!(response_xml, "📤 SOAP response for {}", soap_action.name);
 
             (
                 StatusCode::OK,
@@ -1383,8 +1661,34 @@ async fn control_handler(State(instance): State<Arc<ServiceInstance>>, body: Str
             )
                 .into_response()
         }
+        Err(crate::actions::ActionError::UpnpError { code, description }) => {
+            warn!(
+                "⛔ Action {} refused: {} {}",
+                soap_action.name, code, description
+            );
+            record.set_fault(&code, description.clone());
+            let fault_xml = build_soap_fault(
+                "s:Client",
+                "UPnPError",
+                Some(&code),
+                Some(&description)
+            ).unwrap_or_else(|_| String::from("<?xml version=\"1.0\"?><s:Envelope xmlns:s=\"http://schemas.xmlsoap.org/soap/envelope/\"><s:Body><s:Fault><faultcode>s:Server</faultcode><faultstring>Internal Error</faultstring></s:Fault></s:Body></s:Envelope>"));
+            (
+                StatusCode::INTERNAL_SERVER_ERROR,
+                [(
+                    axum::http::header::CONTENT_TYPE,
+                    "text/xml; charset=\"utf-8\"",
+                )],
+                fault_xml,
+            )
+                .into_response()
+        }
         Err(e) => {
             error!("❌ Action execution failed: {:?}", e);
+            record.set_fault(
+                error_codes::ACTION_FAILED,
+                format!("Action execution failed: {:?}", e),
+            );
             let fault_xml = build_soap_fault(
                 "s:Server",
                 "Action Failed",
@@ -1531,4 +1835,66 @@ mod tests {
             "urn:schemas-upnp-org:service:AVTransport:2"
         );
     }
+
+    #[tokio::test]
+    async fn test_subscribers_sorted_by_sid() {
+        let instance = ServiceInstance::new(&Service::new("AVTransport".to_string()));
+        instance
+            .add_subscriber("uuid:b".to_string(), "<http://10.0.0.2/cb>".to_string())
+            .await;
+        instance
+            .add_subscriber("uuid:a".to_string(), "<http://10.0.0.1/cb>".to_string())
+            .await;
+
+        assert_eq!(
+            instance.subscribers(),
+            vec![
+                ("uuid:a".to_string(), "<http://10.0.0.1/cb>".to_string()),
+                ("uuid:b".to_string(), "<http://10.0.0.2/cb>".to_string()),
+            ]
+        );
+
+        instance.remove_subscriber("uuid:a").await;
+        assert_eq!(instance.subscribers().len(), 1);
+    }
+
+    async fn wait_finished(handle: &tokio::task::AbortHandle) {
+        time::timeout(Duration::from_secs(1), async {
+            while !handle.is_finished() {
+                tokio::task::yield_now().await;
+            }
+        })
+        .await
+        .expect("notifier task still running");
+    }
+
+    #[tokio::test]
+    async fn test_restart_notifier() {
+        let instance = ServiceInstance::new(&Service::new("AVTransport".to_string()));
+        assert!(!instance.notifier_running());
+        // Rien à redémarrer tant qu'aucun notifier n'a été lancé
+        assert!(!instance.restart_notifier());
+
+        let first = instance.start_notifier(Duration::from_secs(60));
+        assert!(instance.notifier_running());
+
+        // Le redémarrage arrête l'ancienne tâche et en lance une nouvelle
+        assert!(instance.restart_notifier());
+        wait_finished(&first).await;
+        assert!(instance.notifier_running());
+    }
+
+    #[tokio::test]
+    async fn test_restart_dead_notifier() {
+        let instance = ServiceInstance::new(&Service::new("AVTransport".to_string()));
+        let handle = instance.start_notifier(Duration::from_secs(60));
+
+        // Tâche arrêtée de l'extérieur (équivalent d'une panique)
+        handle.abort();
+        wait_finished(&handle).await;
+        assert!(!instance.notifier_running());
+
+        assert!(instance.restart_notifier());
+        assert!(instance.notifier_running());
+    }
 }
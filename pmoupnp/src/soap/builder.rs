@@ -1,5 +1,6 @@
 //! Construction de réponses SOAP
 
+use super::{SOAP_ENCODING_STYLE, SOAP_ENVELOPE_NS};
 use xmltree::{Element, XMLNode};
 
 fn build_soap_envelope_with_body(body_child: Element) -> Result<String, xmltree::Error> {
@@ -9,24 +10,15 @@ fn build_soap_envelope_with_body(body_child: Element) -> Result<String, xmltree:
 
     // Envelope
     let mut envelope = Element::new("s:Envelope");
-    envelope.attributes.insert(
-        "xmlns:s".to_string(),
-        "http://schemas.xmlsoap.org/soap/envelope/".to_string(),
-    );
-    envelope.attributes.insert(
-        "s:encodingStyle".to_string(),
-        "http://schemas.xmlsoap.org/soap/encoding/".to_string(),
-    );
+    envelope
+        .attributes
+        .insert("xmlns:s".to_string(), SOAP_ENVELOPE_NS.to_string());
+    envelope
+        .attributes
+        .insert("s:encodingStyle".to_string(), SOAP_ENCODING_STYLE.to_string());
     envelope.children.push(XMLNode::Element(body));
 
-    let mut buf = Vec::new();
-    let config = xmltree::EmitterConfig::new()
-        .write_document_declaration(true)
-        .perform_indent(true)
-        .indent_string("  ");
-    envelope.write_with_config(&mut buf, config)?;
-
-    Ok(String::from_utf8(buf).unwrap())
+    crate::xml_format::write_xml(&envelope)
 }
 
 /// Construit une réponse SOAP UPnP
@@ -113,4 +105,18 @@ mod tests {
         assert!(xml.contains("StopResponse"));
         assert!(xml.contains("xmlns:u=\"urn:schemas-upnp-org:service:AVTransport:1\""));
     }
+
+    #[test]
+    fn test_build_request_is_strict_soap_11() {
+        let xml = build_soap_request(
+            "urn:schemas-upnp-org:service:AVTransport:1",
+            "Play",
+            &[("InstanceID", "0"), ("Speed", "1")],
+        )
+        .unwrap();
+
+        assert!(xml.contains(&format!("xmlns:s=\"{}\"", SOAP_ENVELOPE_NS)));
+        assert!(xml.contains(&format!("s:encodingStyle=\"{}\"", SOAP_ENCODING_STYLE)));
+        assert!(!xml.contains("soap-envelope"));
+    }
 }
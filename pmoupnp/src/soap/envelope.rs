@@ -2,6 +2,37 @@
 
 use xmltree::Element;
 
+/// Namespace de l'enveloppe SOAP 1.1 (seul émis par les builders)
+pub const SOAP_ENVELOPE_NS: &str = "http://schemas.xmlsoap.org/soap/envelope/";
+
+/// Namespace de l'enveloppe SOAP 1.2 (accepté en lecture)
+pub const SOAP12_ENVELOPE_NS: &str = "http://www.w3.org/2003/05/soap-envelope";
+
+/// Style d'encodage SOAP 1.1 exigé par UPnP
+pub const SOAP_ENCODING_STYLE: &str = "http://schemas.xmlsoap.org/soap/encoding/";
+
+/// Version SOAP d'une enveloppe reçue
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum SoapVersion {
+    /// SOAP 1.1 (UPnP Device Architecture)
+    V1_1,
+    /// SOAP 1.2, envoyé par certains points de contrôle
+    V1_2,
+}
+
+impl SoapVersion {
+    /// Déduit la version du namespace de l'élément Envelope.
+    ///
+    /// Une enveloppe sans namespace est traitée comme SOAP 1.1.
+    pub fn from_namespace(namespace: Option<&str>) -> Option<Self> {
+        match namespace {
+            None | Some("") | Some(SOAP_ENVELOPE_NS) => Some(SoapVersion::V1_1),
+            Some(SOAP12_ENVELOPE_NS) => Some(SoapVersion::V1_2),
+            Some(_) => None,
+        }
+    }
+}
+
 /// Enveloppe SOAP complète
 #[derive(Debug, Clone)]
 pub struct SoapEnvelope {
@@ -1,5 +1,6 @@
 //! SOAP Faults pour UPnP
 
+use super::{SOAP_ENCODING_STYLE, SOAP_ENVELOPE_NS};
 use xmltree::{Element, XMLNode};
 
 /// Erreur SOAP (Fault)
@@ -120,21 +121,16 @@ pub fn build_soap_fault(
 
     // Construire l'Envelope
     let mut envelope = Element::new("s:Envelope");
-    envelope.attributes.insert(
-        "xmlns:s".to_string(),
-        "http://schemas.xmlsoap.org/soap/envelope/".to_string(),
-    );
+    envelope
+        .attributes
+        .insert("xmlns:s".to_string(), SOAP_ENVELOPE_NS.to_string());
+    envelope
+        .attributes
+        .insert("s:encodingStyle".to_string(), SOAP_ENCODING_STYLE.to_string());
     envelope.children.push(XMLNode::Element(body));
 
     // Sérialiser
-    let mut buf = Vec::new();
-    let config = xmltree::EmitterConfig::new()
-        .write_document_declaration(true)
-        .perform_indent(true)
-        .indent_string("  ");
-    envelope.write_with_config(&mut buf, config)?;
-
-    Ok(String::from_utf8(buf).unwrap())
+    crate::xml_format::write_xml(&envelope)
 }
 
 #[cfg(test)]
@@ -5,9 +5,9 @@
 //!
 //! ## Fonctionnalités
 //!
-//! - ✅ Parsing d'enveloppes SOAP
+//! - ✅ Parsing d'enveloppes SOAP 1.1 et 1.2 (`encodingStyle` optionnel)
 //! - ✅ Extraction d'actions UPnP avec arguments
-//! - ✅ Construction de réponses SOAP
+//! - ✅ Construction de réponses SOAP (toujours en SOAP 1.1)
 //! - ✅ Gestion des SOAP Faults
 //! - ✅ Support des namespaces UPnP
 //!
@@ -54,9 +54,12 @@ mod fault;
 mod parser;
 
 pub use builder::{build_soap_request, build_soap_response};
-pub use envelope::{SoapBody, SoapEnvelope, SoapHeader};
+pub use envelope::{
+    SOAP_ENCODING_STYLE, SOAP_ENVELOPE_NS, SOAP12_ENVELOPE_NS, SoapBody, SoapEnvelope, SoapHeader,
+    SoapVersion,
+};
 pub use fault::{SoapFault, build_soap_fault};
-pub use parser::{SoapAction, parse_soap_action, parse_soap_envelope};
+pub use parser::{SoapAction, SoapParseError, parse_soap_action, parse_soap_envelope};
 
 /// Codes d'erreur SOAP UPnP standards
 pub mod error_codes {
@@ -1,6 +1,12 @@
 //! Parser SOAP pour actions UPnP
+//!
+//! Le parser est tolérant : il accepte les enveloppes SOAP 1.1 et 1.2, une
+//! enveloppe sans namespace, et n'exige pas `encodingStyle` (omis par
+//! plusieurs points de contrôle). Les réponses restent strictement en
+//! SOAP 1.1 (voir `builder`).
 
-use super::{SoapBody, SoapEnvelope, SoapHeader};
+use super::{SoapBody, SoapEnvelope, SoapHeader, SoapVersion};
+use pmodidl::xml_guard::{self, XmlGuardError};
 use std::collections::HashMap;
 use std::io::BufReader;
 use xmltree::Element;
@@ -16,6 +22,9 @@ pub struct SoapAction {
 
     /// Arguments de l'action
     pub args: HashMap<String, String>,
+
+    /// Version SOAP de l'enveloppe reçue
+    pub version: SoapVersion,
 }
 
 /// Erreur de parsing SOAP
@@ -24,9 +33,15 @@ pub enum SoapParseError {
     #[error("XML parse error: {0}")]
     XmlError(#[from] xmltree::ParseError),
 
+    #[error("Rejected XML: {0}")]
+    Unsafe(#[from] XmlGuardError),
+
     #[error("Missing SOAP Envelope")]
     MissingEnvelope,
 
+    #[error("Unsupported SOAP Envelope namespace: {0}")]
+    UnsupportedEnvelope(String),
+
     #[error("Missing SOAP Body")]
     MissingBody,
 
@@ -36,42 +51,52 @@ pub enum SoapParseError {
 
 /// Parse une action SOAP à partir de bytes XML
 pub fn parse_soap_action(xml: &[u8]) -> Result<SoapAction, SoapParseError> {
-    let envelope = parse_soap_envelope(xml)?;
-    extract_action_from_body(&envelope.body)
+    let (envelope, version) = parse_envelope(xml)?;
+    let mut action = extract_action_from_body(&envelope.body)?;
+    action.version = version;
+    Ok(action)
 }
 
 /// Parse une enveloppe SOAP complète
 pub fn parse_soap_envelope(xml: &[u8]) -> Result<SoapEnvelope, SoapParseError> {
+    parse_envelope(xml).map(|(envelope, _)| envelope)
+}
+
+fn parse_envelope(xml: &[u8]) -> Result<(SoapEnvelope, SoapVersion), SoapParseError> {
+    // Pas de DOCTYPE (XXE, billion laughs), taille et profondeur bornées
+    xml_guard::check(xml)?;
+
     let reader = BufReader::new(xml);
     let root = Element::parse(reader)?;
 
-    // Vérifier que c'est bien une Envelope
-    if !root.name.ends_with("Envelope") {
+    // Vérifier que c'est bien une Envelope SOAP 1.1 ou 1.2
+    if root.name != "Envelope" {
         return Err(SoapParseError::MissingEnvelope);
     }
+    let version = SoapVersion::from_namespace(root.namespace.as_deref()).ok_or_else(|| {
+        SoapParseError::UnsupportedEnvelope(root.namespace.clone().unwrap_or_default())
+    })?;
+
+    // Les éléments Header et Body sont cherchés par nom local : certains
+    // clients les laissent sans préfixe ou les qualifient différemment.
+    let child = |name: &str| {
+        root.children
+            .iter()
+            .filter_map(|n| n.as_element())
+            .find(|e| e.name == name)
+    };
 
     // Extraire Header (optionnel)
-    let header = root
-        .get_child("Header")
-        .or_else(|| root.children.iter().find_map(|n| n.as_element()))
-        .filter(|e| e.name.ends_with("Header"))
-        .map(|e| SoapHeader { content: e.clone() });
+    let header = child("Header").map(|e| SoapHeader { content: e.clone() });
 
     // Extraire Body (obligatoire)
-    let body_elem = root
-        .get_child("Body")
-        .or_else(|| {
-            root.children
-                .iter()
-                .find_map(|n| n.as_element().filter(|e| e.name.ends_with("Body")))
-        })
-        .ok_or(SoapParseError::MissingBody)?;
+    let body_elem = child("Body").ok_or(SoapParseError::MissingBody)?;
 
     let body = SoapBody {
         content: body_elem.clone(),
     };
 
-    Ok(SoapEnvelope { header, body })
+    Ok((SoapEnvelope { header, body }, version))
 }
 
 /// Extrait l'action UPnP du corps SOAP
@@ -103,6 +128,7 @@ fn extract_action_from_body(body: &SoapBody) -> Result<SoapAction, SoapParseErro
         name,
         namespace,
         args,
+        version: SoapVersion::V1_1,
     })
 }
 
@@ -145,4 +171,99 @@ mod tests {
         assert_eq!(action.name, "Stop");
         assert!(action.args.is_empty());
     }
+
+    // Requêtes représentatives de points de contrôle courants : préfixes
+    // variés, encodingStyle absent, attributs de type, SOAP 1.2.
+
+    const BUBBLEUPNP_SET_URI: &str = r#"<?xml version="1.0" encoding="utf-8" standalone="yes"?><s:Envelope s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/" xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><u:SetAVTransportURI xmlns:u="urn:schemas-upnp-org:service:AVTransport:1"><InstanceID>0</InstanceID><CurrentURI>http://192.168.1.10:8080/audio/flac/abc123</CurrentURI><CurrentURIMetaData>&lt;DIDL-Lite xmlns=&quot;urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/&quot;&gt;&lt;/DIDL-Lite&gt;</CurrentURIMetaData></u:SetAVTransportURI></s:Body></s:Envelope>"#;
+
+    const FOOBAR2000_BROWSE: &str = "<?xml version=\"1.0\"?>\r\n<SOAP-ENV:Envelope xmlns:SOAP-ENV=\"http://schemas.xmlsoap.org/soap/envelope/\" SOAP-ENV:encodingStyle=\"http://schemas.xmlsoap.org/soap/encoding/\"><SOAP-ENV:Body><m:Browse xmlns:m=\"urn:schemas-upnp-org:service:ContentDirectory:1\"><ObjectID xmlns:dt=\"urn:schemas-microsoft-com:datatypes\" dt:dt=\"string\">0</ObjectID><BrowseFlag xmlns:dt=\"urn:schemas-microsoft-com:datatypes\" dt:dt=\"string\">BrowseDirectChildren</BrowseFlag><Filter>*</Filter><StartingIndex>0</StartingIndex><RequestedCount>100</RequestedCount><SortCriteria></SortCriteria></m:Browse></SOAP-ENV:Body></SOAP-ENV:Envelope>\r\n";
+
+    const KAZOO_SET_VOLUME: &str = r#"<?xml version="1.0" encoding="utf-8"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/">
+  <s:Body>
+    <u:SetVolume xmlns:u="urn:schemas-upnp-org:service:RenderingControl:1">
+      <InstanceID>0</InstanceID>
+      <Channel>Master</Channel>
+      <DesiredVolume>42</DesiredVolume>
+    </u:SetVolume>
+  </s:Body>
+</s:Envelope>"#;
+
+    const WMP_GET_PROTOCOL_INFO: &str = r#"<?xml version="1.0" encoding="utf-8"?><SOAP-ENV:Envelope xmlns:SOAP-ENV="http://schemas.xmlsoap.org/soap/envelope/" SOAP-ENV:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><SOAP-ENV:Header/><SOAP-ENV:Body><m:GetProtocolInfo xmlns:m="urn:schemas-upnp-org:service:ConnectionManager:1"/></SOAP-ENV:Body></SOAP-ENV:Envelope>"#;
+
+    const SOAP12_GET_VOLUME: &str = r#"<?xml version="1.0"?>
+<env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope">
+  <env:Body>
+    <u:GetVolume xmlns:u="urn:schemas-upnp-org:service:RenderingControl:1">
+      <InstanceID>0</InstanceID>
+      <Channel>Master</Channel>
+    </u:GetVolume>
+  </env:Body>
+</env:Envelope>"#;
+
+    #[test]
+    fn test_parse_control_point_requests() {
+        let action = parse_soap_action(BUBBLEUPNP_SET_URI.as_bytes()).unwrap();
+        assert_eq!(action.name, "SetAVTransportURI");
+        assert_eq!(action.version, SoapVersion::V1_1);
+        assert!(action.args["CurrentURIMetaData"].starts_with("<DIDL-Lite"));
+
+        let action = parse_soap_action(FOOBAR2000_BROWSE.as_bytes()).unwrap();
+        assert_eq!(action.name, "Browse");
+        assert_eq!(action.args["ObjectID"], "0");
+        assert_eq!(action.args["SortCriteria"], "");
+
+        let action = parse_soap_action(KAZOO_SET_VOLUME.as_bytes()).unwrap();
+        assert_eq!(action.name, "SetVolume");
+        assert_eq!(action.args["DesiredVolume"], "42");
+
+        let envelope = parse_soap_envelope(WMP_GET_PROTOCOL_INFO.as_bytes()).unwrap();
+        assert!(envelope.header.is_some());
+        let action = parse_soap_action(WMP_GET_PROTOCOL_INFO.as_bytes()).unwrap();
+        assert_eq!(action.name, "GetProtocolInfo");
+        assert!(action.args.is_empty());
+    }
+
+    #[test]
+    fn test_parse_soap12_envelope() {
+        let action = parse_soap_action(SOAP12_GET_VOLUME.as_bytes()).unwrap();
+        assert_eq!(action.name, "GetVolume");
+        assert_eq!(action.version, SoapVersion::V1_2);
+        assert_eq!(action.args["Channel"], "Master");
+    }
+
+    #[test]
+    fn test_reject_unknown_envelope_namespace() {
+        let xml = r#"<x:Envelope xmlns:x="urn:example:not-soap"><x:Body><u:Stop xmlns:u="urn:x"/></x:Body></x:Envelope>"#;
+        assert!(matches!(
+            parse_soap_action(xml.as_bytes()),
+            Err(SoapParseError::UnsupportedEnvelope(_))
+        ));
+    }
+
+    #[test]
+    fn test_reject_malicious_payloads() {
+        let xxe = r#"<?xml version="1.0"?><!DOCTYPE s:Envelope [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><u:Browse xmlns:u="urn:schemas-upnp-org:service:ContentDirectory:1"><ObjectID>&xxe;</ObjectID></u:Browse></s:Body></s:Envelope>"#;
+        assert!(matches!(
+            parse_soap_action(xxe.as_bytes()),
+            Err(SoapParseError::Unsafe(XmlGuardError::DoctypeForbidden))
+        ));
+
+        let lol = r#"<?xml version="1.0"?><!DOCTYPE lolz [<!ENTITY lol "lol"><!ENTITY lol1 "&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;&lol;"><!ENTITY lol2 "&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;&lol1;">]><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><u:Stop xmlns:u="urn:schemas-upnp-org:service:AVTransport:1"><InstanceID>&lol2;</InstanceID></u:Stop></s:Body></s:Envelope>"#;
+        assert!(matches!(
+            parse_soap_action(lol.as_bytes()),
+            Err(SoapParseError::Unsafe(XmlGuardError::DoctypeForbidden))
+        ));
+
+        let deep = format!(
+            r#"<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><u:Stop xmlns:u="urn:x">{}{}</u:Stop></s:Body></s:Envelope>"#,
+            "<a>".repeat(10_000),
+            "</a>".repeat(10_000)
+        );
+        assert!(matches!(
+            parse_soap_action(deep.as_bytes()),
+            Err(SoapParseError::Unsafe(XmlGuardError::TooDeep(_)))
+        ));
+    }
 }
@@ -19,12 +19,12 @@ The client may still join the multicast group for debugging, but NOTIFY receptio
 */
 //! Client SSDP pour la découverte des devices UPnP
 
-use super::{MAX_AGE, SSDP_MULTICAST_ADDR, SSDP_PORT};
+use super::{DiscoveredDevice, MAX_AGE, SSDP_MULTICAST_ADDR, SSDP_PORT, SsdpTransport};
 use socket2::{Domain, Protocol, Socket, Type};
 use std::collections::HashMap;
 use std::net::{SocketAddr, UdpSocket};
 use std::sync::Arc;
-use std::time::Duration;
+use std::time::{Duration, Instant};
 use tracing::{debug, info, trace, warn};
 
 /// Événements SSDP intéressants pour un control point
@@ -56,7 +56,7 @@ pub enum SsdpEvent {
 /// Client SSDP pour envoyer des M-SEARCH et écouter les annonces
 #[derive(Clone)]
 pub struct SsdpClient {
-    socket: Arc<UdpSocket>,
+    socket: Arc<dyn SsdpTransport>,
 }
 
 impl SsdpClient {
@@ -119,6 +119,12 @@ impl SsdpClient {
         })
     }
 
+    /// Crée un client sur un transport donné (ex. [`super::MemoryNetwork`]
+    /// dans les tests)
+    pub fn with_transport(transport: Arc<dyn SsdpTransport>) -> Self {
+        Self { socket: transport }
+    }
+
     /// Envoie un M-SEARCH pour un type donné
     pub fn send_msearch(&self, st: &str, mx: u32) -> std::io::Result<()> {
         let mx = mx.max(1); // MX doit être >= 1
@@ -140,10 +146,7 @@ impl SsdpClient {
         match self.socket.send_to(msg.as_bytes(), addr) {
             Ok(_) => {
                 info!("📤 M-SEARCH sent (ST={}, MX={})", st, mx);
-                debug!(
-                    "📨 M-SEARCH payload\n<details>\n\n```\n{}\n```\n</details>\n",
-                    msg
-                );
+                crate::trace_payload!(msg, "📨 M-SEARCH payload");
                 Ok(())
             }
             Err(e) => {
@@ -153,6 +156,71 @@ impl SsdpClient {
         }
     }
 
+    /// Envoie un M-SEARCH et collecte les réponses reçues pendant `window`
+    ///
+    /// Les NOTIFY reçus entre-temps sont ignorés.
+    pub fn search(&self, st: &str, mx: u32, window: Duration) -> std::io::Result<Vec<SsdpEvent>> {
+        self.send_msearch(st, mx)?;
+
+        let deadline = Instant::now() + window;
+        let mut responses = Vec::new();
+        let mut buf = [0u8; 8192];
+        while Instant::now() < deadline {
+            match self.socket.recv_from(&mut buf) {
+                Ok((n, from)) => {
+                    let data = String::from_utf8_lossy(&buf[..n]);
+                    if let Some(event @ SsdpEvent::SearchResponse { .. }) =
+                        parse_message(&data, from)
+                    {
+                        responses.push(event);
+                    }
+                }
+                Err(e)
+                    if matches!(
+                        e.kind(),
+                        std::io::ErrorKind::WouldBlock | std::io::ErrorKind::TimedOut
+                    ) => {}
+                Err(e) => return Err(e),
+            }
+        }
+        Ok(responses)
+    }
+
+    /// Envoie un M-SEARCH et renvoie les devices ayant répondu pendant
+    /// MX + 1 s, un par USN
+    pub fn discover(&self, st: &str, mx: u32) -> std::io::Result<Vec<DiscoveredDevice>> {
+        let window = Duration::from_secs(u64::from(mx.max(1)) + 1);
+        let mut devices: Vec<DiscoveredDevice> = Vec::new();
+        for event in self.search(st, mx, window)? {
+            if let Some(device) = DiscoveredDevice::from_event(&event) {
+                if !devices.iter().any(|d| d.usn == device.usn) {
+                    devices.push(device);
+                }
+            }
+        }
+        info!("🔎 SSDP discovery (ST={}): {} device(s)", st, devices.len());
+        Ok(devices)
+    }
+
+    /// Attend un message SSDP pendant le délai de lecture du transport
+    ///
+    /// Renvoie `None` si rien n'est arrivé ou si le message n'est pas
+    /// exploitable.
+    pub(super) fn recv_event(&self, buf: &mut [u8]) -> std::io::Result<Option<SsdpEvent>> {
+        match self.socket.recv_from(buf) {
+            Ok((n, from)) => Ok(parse_message(&String::from_utf8_lossy(&buf[..n]), from)),
+            Err(e)
+                if matches!(
+                    e.kind(),
+                    std::io::ErrorKind::WouldBlock | std::io::ErrorKind::TimedOut
+                ) =>
+            {
+                Ok(None)
+            }
+            Err(e) => Err(e),
+        }
+    }
+
     /// Boucle de réception bloquante pour traiter les événements SSDP
     pub fn run_event_loop<F>(&self, mut on_event: F) -> !
     where
@@ -1,4 +1,44 @@
 //! Représentation d'un device SSDP
+//!
+//! Un [`SsdpDevice`] porte tous les types de notification (NT) annoncés
+//! pour un device racine, avec l'USN de chacun. UDA 1.1 en impose, pour
+//! le device racine comme pour chaque sous-device :
+//!
+//! - `upnp:rootdevice` (device racine seulement) ;
+//! - `uuid:<udn>` ;
+//! - le type du device ;
+//! - le type de chacun de ses services.
+//!
+//! [`SsdpDevice::from_device_instance`] les dérive de l'arbre d'un
+//! [`DeviceInstance`] : sous-devices et nouveaux services sont annoncés
+//! sans liste à tenir à jour à la main.
+
+use crate::UpnpTypedInstance;
+use crate::devices::DeviceInstance;
+
+/// Type de notification annoncé, avec l'USN qui l'identifie
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct NotificationType {
+    /// NT des NOTIFY et ST des réponses M-SEARCH
+    pub nt: String,
+
+    /// Unique Service Name : `uuid:<udn>` ou `uuid:<udn>::<nt>`
+    pub usn: String,
+}
+
+impl NotificationType {
+    /// Type de notification `nt` du device (ou sous-device) d'UDN `uuid`
+    /// (sans le préfixe "uuid:")
+    pub fn new(uuid: &str, nt: impl Into<String>) -> Self {
+        let nt = nt.into();
+        let usn = if nt.starts_with("uuid:") {
+            nt.clone()
+        } else {
+            format!("uuid:{}::{}", uuid, nt)
+        };
+        Self { nt, usn }
+    }
+}
 
 /// Device SSDP avec ses métadonnées pour les annonces
 #[derive(Debug, Clone)]
@@ -15,9 +55,12 @@ pub struct SsdpDevice {
     /// Identifiant du serveur (ex: "Linux/5.0 UPnP/1.1 PMOMusic/1.0")
     pub server: String,
 
-    /// Liste des types de notification (NT) à annoncer
-    /// Typiquement: [uuid:xxx, device_type, services...]
-    pub notification_types: Vec<String>,
+    /// Types de notification (NT) à annoncer, avec leur USN
+    /// Typiquement: [uuid:xxx, upnp:rootdevice, device_type, services...]
+    pub notification_types: Vec<NotificationType>,
+
+    /// Version de la description (CONFIGID.UPNP.ORG, 0 à 16777215)
+    pub config_id: u32,
 }
 
 impl SsdpDevice {
@@ -25,9 +68,9 @@ impl SsdpDevice {
     pub fn new(uuid: String, device_type: String, location: String, server: String) -> Self {
         // Construction automatique des NTs standards
         let notification_types = vec![
-            format!("uuid:{}", uuid),
-            "upnp:rootdevice".to_string(),
-            device_type.clone(),
+            NotificationType::new(&uuid, format!("uuid:{}", uuid)),
+            NotificationType::new(&uuid, "upnp:rootdevice"),
+            NotificationType::new(&uuid, device_type.clone()),
         ];
 
         Self {
@@ -36,18 +79,134 @@ impl SsdpDevice {
             location,
             server,
             notification_types,
+            config_id: 0,
+        }
+    }
+
+    /// Crée le device SSDP d'un device UPnP racine
+    ///
+    /// Les NTs sont dérivés de l'arbre du device : ses services actifs,
+    /// puis pour chaque sous-device (récursivement) son UUID, son type et
+    /// ses services actifs. Le CONFIGID est celui de la description.
+    pub fn from_device_instance(device: &DeviceInstance, location: String, server: String) -> Self {
+        let mut ssdp_device = Self::new(
+            device.udn().to_string(),
+            device.get_model().device_type(),
+            location,
+            server,
+        );
+        ssdp_device.add_device_tree(device, true);
+        ssdp_device.config_id = device.config_id();
+        ssdp_device
+    }
+
+    fn add_device_tree(&mut self, device: &DeviceInstance, is_root: bool) {
+        let uuid = device.udn();
+        if !is_root {
+            self.add_notification_type_for(uuid, format!("uuid:{}", uuid));
+            self.add_notification_type_for(uuid, device.get_model().device_type());
+        }
+        for service in device.services().into_iter().filter(|s| s.is_enabled()) {
+            self.add_notification_type_for(uuid, service.service_type());
+        }
+        for embedded in device.devices() {
+            self.add_device_tree(&embedded, false);
         }
     }
 
-    /// Ajoute un type de notification (ex: pour un service)
+    /// Ajoute un type de notification du device racine (ex: pour un service)
     pub fn add_notification_type(&mut self, nt: String) {
-        if !self.notification_types.contains(&nt) {
+        let uuid = self.uuid.clone();
+        self.add_notification_type_for(&uuid, nt);
+    }
+
+    /// Ajoute un type de notification du sous-device d'UUID `uuid`
+    pub fn add_notification_type_for(&mut self, uuid: &str, nt: String) {
+        let nt = NotificationType::new(uuid, nt);
+        if !self.notification_types.iter().any(|n| n.usn == nt.usn) {
             self.notification_types.push(nt);
         }
     }
 
     /// Retourne la liste des types de notification
-    pub fn get_notification_types(&self) -> &[String] {
+    pub fn get_notification_types(&self) -> &[NotificationType] {
         &self.notification_types
     }
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::UpnpModel;
+    use crate::devices::DeviceBuilder;
+    use crate::services::ServiceBuilder;
+    use std::sync::Arc;
+
+    #[test]
+    fn test_notification_types_from_device_tree() {
+        let service = |name: &str| Arc::new(ServiceBuilder::new(name).build().unwrap());
+        let root = DeviceBuilder::new("SsdpRoot", "MediaServer")
+            .udn("uuid:5d9a1c3e-0000-4000-8000-000000000001")
+            .service(service("ContentDirectory"))
+            .build()
+            .unwrap()
+            .create_instance();
+        let embedded = DeviceBuilder::new("SsdpEmbedded", "MediaRenderer")
+            .udn("uuid:5d9a1c3e-0000-4000-8000-000000000002")
+            .service(service("ContentDirectory"))
+            .build()
+            .unwrap()
+            .create_instance();
+        root.add_device(Arc::clone(&embedded)).unwrap();
+
+        let device = SsdpDevice::from_device_instance(
+            &root,
+            "http://192.168.1.10:8080/device/root/desc.xml".into(),
+            "Linux UPnP/1.1 Test/1.0".into(),
+        );
+        let (root_uuid, embedded_uuid) = (root.udn(), embedded.udn());
+        let directory = service("ContentDirectory").service_type();
+        let expected = [
+            (format!("uuid:{}", root_uuid), format!("uuid:{}", root_uuid)),
+            (
+                "upnp:rootdevice".to_string(),
+                format!("uuid:{}::upnp:rootdevice", root_uuid),
+            ),
+            (
+                root.get_model().device_type(),
+                format!("uuid:{}::{}", root_uuid, root.get_model().device_type()),
+            ),
+            (
+                directory.clone(),
+                format!("uuid:{}::{}", root_uuid, directory),
+            ),
+            (
+                format!("uuid:{}", embedded_uuid),
+                format!("uuid:{}", embedded_uuid),
+            ),
+            (
+                embedded.get_model().device_type(),
+                format!(
+                    "uuid:{}::{}",
+                    embedded_uuid,
+                    embedded.get_model().device_type()
+                ),
+            ),
+            (
+                directory.clone(),
+                format!("uuid:{}::{}", embedded_uuid, directory),
+            ),
+        ];
+        let actual: Vec<(String, String)> = device
+            .get_notification_types()
+            .iter()
+            .map(|target| (target.nt.clone(), target.usn.clone()))
+            .collect();
+        assert_eq!(actual, expected);
+
+        // Un NT déjà annoncé n'est pas dupliqué
+        let mut device = device;
+        device.add_notification_type(directory);
+        assert_eq!(device.get_notification_types().len(), expected.len());
+    }
+}
@@ -0,0 +1,373 @@
+//! Découverte des devices UPnP du réseau
+//!
+//! - [`discover`] / [`SsdpClient::discover`] : un M-SEARCH, puis les
+//!   réponses reçues pendant MX + 1 s, une par USN
+//! - [`SsdpMonitor`] : suit en continu les NOTIFY alive/byebye et les
+//!   réponses à ses propres M-SEARCH, et publie l'apparition et la
+//!   disparition des devices (byebye ou max-age écoulé sans nouvelle annonce)
+//!
+//! Les devices sont identifiés par leur UDN, préfixe commun des USN d'un
+//! même device (`uuid:...::urn:...`).
+
+use std::collections::HashMap;
+use std::io;
+use std::net::SocketAddr;
+use std::sync::Arc;
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::time::{Duration, Instant};
+
+use tokio::sync::mpsc;
+use tracing::{debug, info, warn};
+
+use super::{SsdpClient, SsdpEvent};
+use crate::clock::{Clock, system_clock};
+
+/// Device vu sur le réseau
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct DiscoveredDevice {
+    /// UDN (`uuid:...`)
+    pub udn: String,
+    pub usn: String,
+    /// NT de l'annonce ou ST de la réponse
+    pub target: String,
+    pub location: String,
+    pub server: String,
+    /// Durée de validité de l'annonce (secondes)
+    pub max_age: u32,
+    pub from: SocketAddr,
+}
+
+impl DiscoveredDevice {
+    /// Device décrit par une annonce alive ou une réponse à un M-SEARCH
+    pub fn from_event(event: &SsdpEvent) -> Option<Self> {
+        let (usn, target, location, server, max_age, from) = match event {
+            SsdpEvent::Alive {
+                usn,
+                nt,
+                location,
+                server,
+                max_age,
+                from,
+            } => (usn, nt, location, server, max_age, from),
+            SsdpEvent::SearchResponse {
+                usn,
+                st,
+                location,
+                server,
+                max_age,
+                from,
+            } => (usn, st, location, server, max_age, from),
+            SsdpEvent::ByeBye { .. } => return None,
+        };
+        Some(Self {
+            udn: udn_of(usn).to_string(),
+            usn: usn.clone(),
+            target: target.clone(),
+            location: location.clone(),
+            server: server.clone(),
+            max_age: *max_age,
+            from: *from,
+        })
+    }
+}
+
+/// UDN d'un USN (`uuid:x::urn:...` → `uuid:x`)
+pub fn udn_of(usn: &str) -> &str {
+    usn.split("::").next().unwrap_or(usn)
+}
+
+/// Cause de la disparition d'un device
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub enum DisappearReason {
+    /// Annonce `ssdp:byebye`
+    ByeBye,
+    /// Aucune annonce pendant max-age
+    Expired,
+}
+
+/// Changement de la liste des devices présents
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum DeviceEvent {
+    /// Premier message reçu d'un UDN
+    Appeared(DiscoveredDevice),
+    /// Le device s'annonce avec une autre LOCATION (redémarrage, nouvelle
+    /// adresse)
+    Updated(DiscoveredDevice),
+    Disappeared {
+        udn: String,
+        reason: DisappearReason,
+    },
+}
+
+/// Découverte ponctuelle sur un client éphémère, sans bloquer le runtime
+///
+/// Abandonner le futur (timeout, `select!`) rend la main immédiatement ; la
+/// recherche se termine d'elle-même au bout de MX + 1 s.
+pub async fn discover(st: &str, mx: u32) -> io::Result<Vec<DiscoveredDevice>> {
+    let st = st.to_string();
+    tokio::task::spawn_blocking(move || SsdpClient::new()?.discover(&st, mx))
+        .await
+        .map_err(io::Error::other)?
+}
+
+/// Devices présents et échéance de leur dernière annonce
+#[derive(Debug, Default)]
+struct DeviceTable {
+    devices: HashMap<String, (DiscoveredDevice, Instant)>,
+}
+
+impl DeviceTable {
+    fn apply(&mut self, event: &SsdpEvent, now: Instant) -> Option<DeviceEvent> {
+        if let SsdpEvent::ByeBye { usn, .. } = event {
+            let udn = udn_of(usn);
+            return self.devices.remove(udn).map(|_| DeviceEvent::Disappeared {
+                udn: udn.to_string(),
+                reason: DisappearReason::ByeBye,
+            });
+        }
+
+        let device = DiscoveredDevice::from_event(event)?;
+        let expires = now + Duration::from_secs(u64::from(device.max_age));
+        match self.devices.get_mut(&device.udn) {
+            Some((known, deadline)) => {
+                *deadline = (*deadline).max(expires);
+                if known.location == device.location {
+                    return None;
+                }
+                *known = device.clone();
+                Some(DeviceEvent::Updated(device))
+            }
+            None => {
+                self.devices
+                    .insert(device.udn.clone(), (device.clone(), expires));
+                Some(DeviceEvent::Appeared(device))
+            }
+        }
+    }
+
+    fn expire(&mut self, now: Instant) -> Vec<DeviceEvent> {
+        let expired: Vec<String> = self
+            .devices
+            .iter()
+            .filter(|(_, (_, deadline))| *deadline <= now)
+            .map(|(udn, _)| udn.clone())
+            .collect();
+        expired
+            .into_iter()
+            .map(|udn| {
+                self.devices.remove(&udn);
+                DeviceEvent::Disappeared {
+                    udn,
+                    reason: DisappearReason::Expired,
+                }
+            })
+            .collect()
+    }
+}
+
+/// Surveillance continue des devices annoncés sur le réseau
+///
+/// Un thread écoute le client et publie des [`DeviceEvent`] ; il s'arrête
+/// avec [`SsdpMonitor::stop`], à la destruction du moniteur ou quand le
+/// récepteur est abandonné.
+pub struct SsdpMonitor {
+    client: SsdpClient,
+    stop: Arc<AtomicBool>,
+}
+
+impl SsdpMonitor {
+    /// Démarre un moniteur sur un nouveau client SSDP
+    pub fn start() -> io::Result<(Self, mpsc::UnboundedReceiver<DeviceEvent>)> {
+        Ok(Self::with_client(SsdpClient::new()?, system_clock()))
+    }
+
+    /// Démarre un moniteur sur un client donné (ex. sur un
+    /// [`super::MemoryNetwork`] avec une [`crate::clock::FakeClock`] dans
+    /// les tests)
+    pub fn with_client(
+        client: SsdpClient,
+        clock: Arc<dyn Clock>,
+    ) -> (Self, mpsc::UnboundedReceiver<DeviceEvent>) {
+        let (tx, rx) = mpsc::unbounded_channel();
+        let stop = Arc::new(AtomicBool::new(false));
+
+        let listener = client.clone();
+        let listener_stop = Arc::clone(&stop);
+        std::thread::spawn(move || {
+            let mut table = DeviceTable::default();
+            let mut buf = [0u8; 8192];
+            while !listener_stop.load(Ordering::Relaxed) && !tx.is_closed() {
+                match listener.recv_event(&mut buf) {
+                    Ok(Some(event)) => {
+                        if let Some(change) = table.apply(&event, clock.now()) {
+                            debug!("📡 SSDP monitor: {:?}", change);
+                            let _ = tx.send(change);
+                        }
+                    }
+                    Ok(None) => {}
+                    Err(e) => {
+                        warn!("❌ SSDP monitor read error: {}", e);
+                        std::thread::sleep(Duration::from_millis(100));
+                    }
+                }
+                for change in table.expire(clock.now()) {
+                    debug!("📡 SSDP monitor: {:?}", change);
+                    let _ = tx.send(change);
+                }
+            }
+            info!("🛑 SSDP monitor stopped");
+        });
+
+        (Self { client, stop }, rx)
+    }
+
+    /// Envoie un M-SEARCH ; les réponses alimentent le flux d'événements
+    pub fn search(&self, st: &str, mx: u32) -> io::Result<()> {
+        self.client.send_msearch(st, mx)
+    }
+
+    /// Arrête l'écoute
+    pub fn stop(&self) {
+        self.stop.store(true, Ordering::Relaxed);
+    }
+}
+
+impl Drop for SsdpMonitor {
+    fn drop(&mut self) {
+        self.stop();
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::clock::FakeClock;
+    use crate::ssdp::MemoryNetwork;
+    use std::net::Ipv4Addr;
+
+    const RENDERER: &str = "urn:schemas-upnp-org:device:MediaRenderer:1";
+
+    fn notify(nts: &str, usn: &str, location: &str) -> String {
+        format!(
+            "NOTIFY * HTTP/1.1\r\n\
+             HOST: 239.255.255.250:1900\r\n\
+             CACHE-CONTROL: max-age=60\r\n\
+             LOCATION: {}\r\n\
+             NT: {}\r\n\
+             NTS: {}\r\n\
+             USN: {}\r\n\
+             \r\n",
+            location, RENDERER, nts, usn
+        )
+    }
+
+    #[test]
+    fn test_udn_of() {
+        assert_eq!(udn_of("uuid:abc::urn:x:device:y:1"), "uuid:abc");
+        assert_eq!(udn_of("uuid:abc"), "uuid:abc");
+    }
+
+    #[test]
+    fn test_device_table() {
+        let from: SocketAddr = "192.168.1.20:1900".parse().unwrap();
+        let alive = |location: &str| SsdpEvent::Alive {
+            usn: format!("uuid:abc::{}", RENDERER),
+            nt: RENDERER.into(),
+            location: location.into(),
+            server: "Test".into(),
+            max_age: 60,
+            from,
+        };
+        let start = Instant::now();
+        let mut table = DeviceTable::default();
+
+        assert!(matches!(
+            table.apply(&alive("http://a/desc.xml"), start),
+            Some(DeviceEvent::Appeared(d)) if d.udn == "uuid:abc"
+        ));
+        assert_eq!(table.apply(&alive("http://a/desc.xml"), start), None);
+        assert!(matches!(
+            table.apply(&alive("http://b/desc.xml"), start),
+            Some(DeviceEvent::Updated(d)) if d.location == "http://b/desc.xml"
+        ));
+        assert!(table.expire(start + Duration::from_secs(59)).is_empty());
+        assert_eq!(
+            table.expire(start + Duration::from_secs(60)),
+            vec![DeviceEvent::Disappeared {
+                udn: "uuid:abc".into(),
+                reason: DisappearReason::Expired,
+            }]
+        );
+
+        table.apply(&alive("http://a/desc.xml"), start);
+        let byebye = SsdpEvent::ByeBye {
+            usn: "uuid:abc::upnp:rootdevice".into(),
+            nt: "upnp:rootdevice".into(),
+            from,
+        };
+        assert_eq!(
+            table.apply(&byebye, start),
+            Some(DeviceEvent::Disappeared {
+                udn: "uuid:abc".into(),
+                reason: DisappearReason::ByeBye,
+            })
+        );
+        assert_eq!(table.apply(&byebye, start), None);
+    }
+
+    #[test]
+    fn test_monitor_in_memory() {
+        let network = MemoryNetwork::new(Ipv4Addr::new(192, 168, 1, 10));
+        let clock = Arc::new(FakeClock::new());
+        let client = SsdpClient::with_transport(Arc::new(network.clone()));
+        let (monitor, mut events) = SsdpMonitor::with_client(client, clock.clone());
+        let from: SocketAddr = "192.168.1.20:1900".parse().unwrap();
+        let usn = format!("uuid:abc::{}", RENDERER);
+
+        monitor.search(RENDERER, 2).unwrap();
+        assert!(network.sent()[0].1.starts_with("M-SEARCH"));
+
+        network.inject(
+            notify("ssdp:alive", &usn, "http://a/desc.xml").as_bytes(),
+            from,
+        );
+        match events.blocking_recv() {
+            Some(DeviceEvent::Appeared(device)) => {
+                assert_eq!(device.udn, "uuid:abc");
+                assert_eq!(device.max_age, 60);
+            }
+            other => panic!("unexpected event {:?}", other),
+        }
+
+        clock.advance(Duration::from_secs(61));
+        assert_eq!(
+            events.blocking_recv(),
+            Some(DeviceEvent::Disappeared {
+                udn: "uuid:abc".into(),
+                reason: DisappearReason::Expired,
+            })
+        );
+
+        network.inject(
+            notify("ssdp:alive", &usn, "http://a/desc.xml").as_bytes(),
+            from,
+        );
+        assert!(matches!(
+            events.blocking_recv(),
+            Some(DeviceEvent::Appeared(_))
+        ));
+        network.inject(
+            notify("ssdp:byebye", &usn, "http://a/desc.xml").as_bytes(),
+            from,
+        );
+        assert_eq!(
+            events.blocking_recv(),
+            Some(DeviceEvent::Disappeared {
+                udn: "uuid:abc".into(),
+                reason: DisappearReason::ByeBye,
+            })
+        );
+
+        drop(monitor);
+    }
+}
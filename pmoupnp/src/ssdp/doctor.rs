@@ -0,0 +1,362 @@
+//! Diagnostic de la découverte (`pmomusic doctor`)
+//!
+//! « Mon serveur n'apparaît pas » a presque toujours l'une de ces causes :
+//! conteneur en réseau bridge, multicast routé sur la mauvaise interface,
+//! pare-feu qui écarte le port UDP 1900 ou les réponses unicast, ou URL
+//! `LOCATION` annoncée injoignable depuis le reste du LAN.
+//!
+//! [`run_doctor`] rejoue le scénario d'un point de contrôle : il envoie un
+//! M-SEARCH `ssdp:all`, repère parmi les réponses celles des devices de la
+//! configuration (par leur UDN), puis lit chaque `LOCATION` depuis une autre
+//! interface que celle annoncée. Le résultat est un
+//! [`DiagnosticsReport`] avec un conseil pour chaque problème.
+
+use std::collections::BTreeSet;
+use std::io::{self, Read, Write};
+use std::net::{IpAddr, SocketAddr, TcpStream, ToSocketAddrs};
+use std::time::Duration;
+
+use pmoserver::{Diagnostic, DiagnosticEntry, DiagnosticsReport};
+use serde_yaml::Value;
+use socket2::{Domain, Socket, Type};
+use url::Url;
+
+use super::{SSDP_MULTICAST_ADDR, SsdpClient, SsdpEvent, detect_network_environment};
+use crate::config_ext::UpnpConfigExt;
+
+/// Délai de connexion et de lecture des URL `LOCATION`
+const FETCH_TIMEOUT: Duration = Duration::from_secs(3);
+
+/// Conseil pare-feu propre à la plateforme
+#[cfg(target_os = "linux")]
+const FIREWALL_ADVICE: &str = "allow UDP port 1900 and the unicast replies \
+    (`ufw allow 1900/udp` or `firewall-cmd --add-service=ssdp`)";
+#[cfg(target_os = "windows")]
+const FIREWALL_ADVICE: &str = "allow pmomusic on Private networks in Windows Defender Firewall \
+    and check that the network profile is Private, not Public";
+#[cfg(target_os = "macos")]
+const FIREWALL_ADVICE: &str =
+    "allow incoming connections for pmomusic in System Settings > Network > Firewall";
+#[cfg(not(any(target_os = "linux", target_os = "windows", target_os = "macos")))]
+const FIREWALL_ADVICE: &str = "allow UDP port 1900 and the unicast replies in the firewall";
+
+/// Exécute le diagnostic de découverte
+///
+/// Bloquant : les réponses au M-SEARCH sont attendues pendant `window`.
+pub fn run_doctor(window: Duration) -> DiagnosticsReport {
+    let config = pmoconfig::get_config();
+    let udns = config
+        .get_value(&["devices"])
+        .map(|devices| udns_in(&devices))
+        .unwrap_or_default();
+
+    let mut checks = vec![
+        entry("network", check_network()),
+        entry(
+            "multicast_route",
+            check_multicast_route(announced_ip(&config.get_base_url())),
+        ),
+    ];
+
+    if !config.get_ssdp_enabled().unwrap_or(true) {
+        checks.push(entry(
+            "ssdp",
+            Diagnostic::warning(
+                "SSDP disabled by configuration",
+                "set host.ssdp.enabled to true, control points cannot discover the server otherwise",
+            ),
+        ));
+    }
+
+    match SsdpClient::new().and_then(|client| client.search("ssdp:all", 2, window)) {
+        Err(e) => checks.push(entry(
+            "m_search",
+            Diagnostic::error(
+                format!("cannot send M-SEARCH: {}", e),
+                "check that a network interface is up and allows multicast",
+            ),
+        )),
+        Ok(responses) => {
+            let locations = own_locations(&responses, &udns);
+            checks.push(entry(
+                "m_search",
+                check_search(&responses, &locations, udns.is_empty()),
+            ));
+            for location in &locations {
+                checks.push(entry("location", check_location(location)));
+            }
+        }
+    }
+
+    DiagnosticsReport::new(checks)
+}
+
+/// Conteneur en réseau bridge ?
+pub(crate) fn check_network() -> Diagnostic {
+    let env = detect_network_environment();
+    match (env.in_container, env.bridged) {
+        (true, true) => Diagnostic::warning(
+            "container with bridged networking, SSDP multicast stays inside the container",
+            "use host networking (`--network host` / `network_mode: host`)",
+        ),
+        (true, false) => Diagnostic::ok("container with host networking"),
+        _ => Diagnostic::ok(format!("{} IPv4 interface(s)", env.addresses.len())),
+    }
+}
+
+/// Le multicast SSDP part-il par l'interface des URL annoncées ?
+pub(crate) fn check_multicast_route(announced: Option<IpAddr>) -> Diagnostic {
+    match super::multicast_route() {
+        Err(e) => Diagnostic::error(
+            format!("no route to {}: {}", SSDP_MULTICAST_ADDR, e),
+            "add a default route or `ip route add 224.0.0.0/4 dev <interface>`",
+        ),
+        Ok(local) if local.is_loopback() => Diagnostic::error(
+            format!(
+                "multicast leaves through the loopback interface ({})",
+                local
+            ),
+            "connect a network interface or add a multicast route on the LAN interface",
+        ),
+        Ok(local) => match announced {
+            Some(announced) if announced != local => Diagnostic::warning(
+                format!(
+                    "multicast leaves through {} but descriptions are announced on {}",
+                    local, announced
+                ),
+                "set host.base_url to the LAN address, or route 224.0.0.0/4 through its interface",
+            ),
+            _ => Diagnostic::ok(format!("via {}", local)),
+        },
+    }
+}
+
+/// Adresse IP de `host.base_url` (URL complète ou simple adresse)
+pub(crate) fn announced_ip(base_url: &str) -> Option<IpAddr> {
+    Url::parse(base_url)
+        .ok()
+        .and_then(|url| {
+            url.host_str()
+                .and_then(|host| host.trim_matches(['[', ']']).parse().ok())
+        })
+        .or_else(|| base_url.parse().ok())
+}
+
+fn entry(name: &str, diagnostic: Diagnostic) -> DiagnosticEntry {
+    DiagnosticEntry {
+        name: name.to_string(),
+        diagnostic,
+    }
+}
+
+/// UDN des devices de la configuration (`devices.<type>.<nom>.udn`)
+fn udns_in(devices: &Value) -> Vec<String> {
+    let Value::Mapping(types) = devices else {
+        return Vec::new();
+    };
+    types
+        .values()
+        .filter_map(Value::as_mapping)
+        .flat_map(|names| names.values())
+        .filter_map(|device| device.get("udn").and_then(Value::as_str))
+        .map(|udn| udn.trim().trim_start_matches("uuid:").to_ascii_lowercase())
+        .collect()
+}
+
+/// URL `LOCATION` distinctes des réponses venant de nos devices
+fn own_locations(responses: &[SsdpEvent], udns: &[String]) -> Vec<String> {
+    let locations: BTreeSet<&String> = responses
+        .iter()
+        .filter_map(|event| match event {
+            SsdpEvent::SearchResponse { usn, location, .. } => Some((usn, location)),
+            _ => None,
+        })
+        .filter(|(usn, _)| {
+            let udn = usn.trim_start_matches("uuid:").split("::").next();
+            udn.is_some_and(|udn| udns.contains(&udn.to_ascii_lowercase()))
+        })
+        .map(|(_, location)| location)
+        .collect();
+    locations.into_iter().cloned().collect()
+}
+
+fn check_search(responses: &[SsdpEvent], own: &[String], no_udns: bool) -> Diagnostic {
+    if !own.is_empty() {
+        Diagnostic::ok(format!(
+            "{} response(s), {} description(s) from this server",
+            responses.len(),
+            own.len()
+        ))
+    } else if no_udns {
+        Diagnostic::warning(
+            format!(
+                "{} response(s), no device in the configuration",
+                responses.len()
+            ),
+            "start pmomusic once so that its devices are registered, then run doctor again",
+        )
+    } else if responses.is_empty() {
+        Diagnostic::error(
+            "no response to M-SEARCH, not even from other devices",
+            format!("multicast or its replies are filtered: {}", FIREWALL_ADVICE),
+        )
+    } else {
+        Diagnostic::error(
+            format!(
+                "{} response(s) from other devices, none from this server",
+                responses.len()
+            ),
+            format!("check that pmomusic is running, then {}", FIREWALL_ADVICE),
+        )
+    }
+}
+
+/// Lit `location` depuis chacune des autres interfaces de la machine
+fn check_location(location: &str) -> Diagnostic {
+    let url = match Url::parse(location) {
+        Ok(url) => url,
+        Err(e) => {
+            return Diagnostic::error(
+                format!("{} is not a valid URL: {}", location, e),
+                "set host.base_url to a full URL such as http://192.168.1.10:8080",
+            );
+        }
+    };
+    let host: Option<IpAddr> = url
+        .host_str()
+        .and_then(|h| h.trim_matches(['[', ']']).parse().ok());
+    if host.is_some_and(|ip| ip.is_loopback()) {
+        return Diagnostic::error(
+            format!("{} points to the loopback interface", location),
+            "set host.base_url to the LAN address of this machine",
+        );
+    }
+
+    let others: Vec<IpAddr> = detect_network_environment()
+        .addresses
+        .into_iter()
+        .map(|(_, ip)| IpAddr::V4(ip))
+        .filter(|ip| Some(*ip) != host)
+        .collect();
+    if others.is_empty() {
+        return match fetch_status(&url, None) {
+            Ok(200) => Diagnostic::ok(format!("{} fetched (single interface)", location)),
+            Ok(status) => http_status_error(location, status),
+            Err(e) => unreachable_location(&url, format!("{}: {}", location, e)),
+        };
+    }
+
+    let mut failures = Vec::new();
+    for local in &others {
+        match fetch_status(&url, Some(*local)) {
+            Ok(200) => {}
+            Ok(status) => return http_status_error(location, status),
+            Err(e) => failures.push(format!("from {}: {}", local, e)),
+        }
+    }
+    if failures.is_empty() {
+        let from: Vec<String> = others.iter().map(IpAddr::to_string).collect();
+        Diagnostic::ok(format!("{} fetched from {}", location, from.join(", ")))
+    } else {
+        unreachable_location(
+            &url,
+            format!("{} unreachable {}", location, failures.join("; ")),
+        )
+    }
+}
+
+fn http_status_error(location: &str, status: u16) -> Diagnostic {
+    Diagnostic::error(
+        format!("{} answered HTTP {}", location, status),
+        "another service may be using the HTTP port, check host.http_port",
+    )
+}
+
+fn unreachable_location(url: &Url, detail: String) -> Diagnostic {
+    Diagnostic::error(
+        detail,
+        format!(
+            "allow TCP port {} in the firewall and check that host.base_url is an address of this machine",
+            url.port_or_known_default().unwrap_or(80)
+        ),
+    )
+}
+
+/// Code de statut d'un `GET` sur `url`, depuis l'adresse locale `local`
+fn fetch_status(url: &Url, local: Option<IpAddr>) -> io::Result<u16> {
+    let host = url.host_str().unwrap_or_default();
+    let port = url.port_or_known_default().unwrap_or(80);
+    let addr: SocketAddr = (host.trim_matches(['[', ']']), port)
+        .to_socket_addrs()?
+        .next()
+        .ok_or_else(|| io::Error::new(io::ErrorKind::NotFound, "host has no address"))?;
+
+    let socket = Socket::new(Domain::for_address(addr), Type::STREAM, None)?;
+    if let Some(local) = local.filter(|l| l.is_ipv4() == addr.is_ipv4()) {
+        socket.bind(&SocketAddr::new(local, 0).into())?;
+    }
+    socket.connect_timeout(&addr.into(), FETCH_TIMEOUT)?;
+
+    let mut stream: TcpStream = socket.into();
+    stream.set_read_timeout(Some(FETCH_TIMEOUT))?;
+    write!(
+        stream,
+        "GET {} HTTP/1.0\r\nHost: {}:{}\r\n\r\n",
+        &url[url::Position::BeforePath..],
+        host,
+        port
+    )?;
+
+    let mut head = [0u8; 64];
+    let n = stream.read(&mut head)?;
+    String::from_utf8_lossy(&head[..n])
+        .split_whitespace()
+        .nth(1)
+        .and_then(|code| code.parse().ok())
+        .ok_or_else(|| io::Error::new(io::ErrorKind::InvalidData, "not an HTTP response"))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn own_responses_are_matched_by_udn() {
+        let devices: Value = serde_yaml::from_str(
+            "MediaServer:\n  PMOMusic:\n    udn: uuid:AB-12\nMediaRenderer:\n  Salon:\n    udn: cd-34\n",
+        )
+        .unwrap();
+        let udns = udns_in(&devices);
+        assert_eq!(udns, ["ab-12", "cd-34"]);
+
+        let response = |usn: &str, location: &str| SsdpEvent::SearchResponse {
+            usn: usn.to_string(),
+            st: "ssdp:all".to_string(),
+            location: location.to_string(),
+            server: "Linux UPnP/1.1".to_string(),
+            max_age: 1800,
+            from: "192.168.1.10:1900".parse().unwrap(),
+        };
+        let responses = [
+            response(
+                "uuid:ab-12::upnp:rootdevice",
+                "http://192.168.1.10:8080/a.xml",
+            ),
+            response("uuid:ab-12", "http://192.168.1.10:8080/a.xml"),
+            response(
+                "uuid:ff-00::upnp:rootdevice",
+                "http://192.168.1.20/desc.xml",
+            ),
+        ];
+        assert_eq!(
+            own_locations(&responses, &udns),
+            ["http://192.168.1.10:8080/a.xml"]
+        );
+
+        assert_eq!(
+            announced_ip("http://192.168.1.10:8080"),
+            "192.168.1.10".parse().ok()
+        );
+        assert_eq!(announced_ip("192.168.1.10"), "192.168.1.10".parse().ok());
+    }
+}
@@ -0,0 +1,240 @@
+//! Filtrage des M-SEARCH reçus
+//!
+//! Un point de contrôle défaillant ou malveillant qui inonde le groupe
+//! multicast de M-SEARCH ne doit pas transformer le serveur en générateur
+//! de réponses unicast (ni de journaux). Avant toute analyse, chaque
+//! M-SEARCH passe :
+//!
+//! - la liste de refus puis, si elle n'est pas vide, la liste
+//!   d'autorisation ([`IpNet`], `host.ssdp.deny` / `host.ssdp.allow`) ;
+//! - un seau à jetons par adresse source ([`MsearchLimiter`]) : `burst`
+//!   M-SEARCH d'affilée, puis `rate` par seconde.
+//!
+//! Les M-SEARCH écartés sont ignorés en silence, hormis un avertissement au
+//! début et un bilan à la fin de chaque rafale limitée.
+
+use std::collections::HashMap;
+use std::fmt;
+use std::net::IpAddr;
+use std::str::FromStr;
+use std::time::{Duration, Instant};
+
+/// Nombre maximal de sources suivies par le limiteur
+const MAX_TRACKED_SOURCES: usize = 4096;
+
+/// Période de purge des sources inactives
+const PRUNE_INTERVAL: Duration = Duration::from_secs(60);
+
+/// Réseau IP en notation CIDR (`192.168.1.0/24`, `fe80::/10`) ou adresse
+/// seule
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct IpNet {
+    addr: IpAddr,
+    prefix: u8,
+}
+
+impl IpNet {
+    /// Le réseau contient-il `ip` ? Une adresse IPv4 encapsulée en IPv6
+    /// (`::ffff:a.b.c.d`) est comparée comme une adresse IPv4.
+    pub fn contains(&self, ip: IpAddr) -> bool {
+        match (self.addr.to_canonical(), ip.to_canonical()) {
+            (IpAddr::V4(net), IpAddr::V4(ip)) => {
+                let mask = u32::MAX
+                    .checked_shl(32 - u32::from(self.prefix))
+                    .unwrap_or(0);
+                u32::from(net) & mask == u32::from(ip) & mask
+            }
+            (IpAddr::V6(net), IpAddr::V6(ip)) => {
+                let mask = u128::MAX
+                    .checked_shl(128 - u32::from(self.prefix))
+                    .unwrap_or(0);
+                u128::from(net) & mask == u128::from(ip) & mask
+            }
+            _ => false,
+        }
+    }
+}
+
+impl FromStr for IpNet {
+    type Err = String;
+
+    fn from_str(s: &str) -> Result<Self, Self::Err> {
+        let s = s.trim();
+        let (addr, prefix) = match s.split_once('/') {
+            Some((addr, prefix)) => (addr, Some(prefix)),
+            None => (s, None),
+        };
+        let addr = addr
+            .parse::<IpAddr>()
+            .map_err(|_| format!("invalid IP address in '{}'", s))?
+            .to_canonical();
+        let max = if addr.is_ipv4() { 32 } else { 128 };
+        let prefix = match prefix {
+            Some(p) => p
+                .parse::<u8>()
+                .ok()
+                .filter(|p| *p <= max)
+                .ok_or_else(|| format!("invalid prefix length in '{}'", s))?,
+            None => max,
+        };
+        Ok(Self { addr, prefix })
+    }
+}
+
+impl fmt::Display for IpNet {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        write!(f, "{}/{}", self.addr, self.prefix)
+    }
+}
+
+/// Décision du limiteur pour un M-SEARCH
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub(super) enum Admission {
+    /// M-SEARCH traité ; `dropped` M-SEARCH de la source venaient d'être
+    /// écartés
+    Accepted { dropped: u64 },
+    /// M-SEARCH écarté ; `first` pour le premier d'une rafale
+    Dropped { first: bool },
+}
+
+/// Seau à jetons d'une source
+#[derive(Debug)]
+struct Bucket {
+    tokens: f64,
+    updated: Instant,
+    dropped: u64,
+}
+
+/// Limiteur de débit des M-SEARCH par adresse source
+#[derive(Debug)]
+pub(super) struct MsearchLimiter {
+    /// Jetons regagnés par seconde (0 : pas de limite)
+    rate: f64,
+    /// Contenance du seau
+    burst: f64,
+    buckets: HashMap<IpAddr, Bucket>,
+    last_prune: Option<Instant>,
+}
+
+impl MsearchLimiter {
+    pub(super) fn new(rate: f64, burst: u32) -> Self {
+        Self {
+            rate: rate.max(0.0),
+            burst: f64::from(burst.max(1)),
+            buckets: HashMap::new(),
+            last_prune: None,
+        }
+    }
+
+    /// Consomme un jeton de la source `ip` s'il en reste
+    pub(super) fn admit(&mut self, ip: IpAddr, now: Instant) -> Admission {
+        if self.rate <= 0.0 {
+            return Admission::Accepted { dropped: 0 };
+        }
+        self.prune(now);
+
+        let ip = ip.to_canonical();
+        let (rate, burst) = (self.rate, self.burst);
+        let bucket = self.buckets.entry(ip).or_insert(Bucket {
+            tokens: burst,
+            updated: now,
+            dropped: 0,
+        });
+        let elapsed = now.saturating_duration_since(bucket.updated).as_secs_f64();
+        bucket.tokens = (bucket.tokens + elapsed * rate).min(burst);
+        bucket.updated = now;
+
+        if bucket.tokens >= 1.0 {
+            bucket.tokens -= 1.0;
+            Admission::Accepted {
+                dropped: std::mem::take(&mut bucket.dropped),
+            }
+        } else {
+            bucket.dropped += 1;
+            Admission::Dropped {
+                first: bucket.dropped == 1,
+            }
+        }
+    }
+
+    /// Oublie les sources dont le seau est de nouveau plein
+    ///
+    /// Faite toutes les minutes, ou dès que le nombre de sources suivies
+    /// atteint [`MAX_TRACKED_SOURCES`] (adresses source usurpées).
+    fn prune(&mut self, now: Instant) {
+        let due = self
+            .last_prune
+            .is_none_or(|last| now.saturating_duration_since(last) >= PRUNE_INTERVAL);
+        if !due && self.buckets.len() < MAX_TRACKED_SOURCES {
+            return;
+        }
+        self.last_prune = Some(now);
+        let refill = Duration::from_secs_f64(self.burst / self.rate);
+        self.buckets
+            .retain(|_, b| now.saturating_duration_since(b.updated) < refill);
+        if self.buckets.len() >= MAX_TRACKED_SOURCES {
+            self.buckets.clear();
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_ip_net() {
+        let lan: IpNet = "192.168.1.0/24".parse().unwrap();
+        assert!(lan.contains("192.168.1.42".parse().unwrap()));
+        assert!(lan.contains("::ffff:192.168.1.42".parse().unwrap()));
+        assert!(!lan.contains("192.168.2.1".parse().unwrap()));
+        assert!(!lan.contains("fe80::1".parse().unwrap()));
+
+        let host: IpNet = "10.0.0.5".parse().unwrap();
+        assert_eq!(host.to_string(), "10.0.0.5/32");
+        assert!(host.contains("10.0.0.5".parse().unwrap()));
+        assert!(!host.contains("10.0.0.6".parse().unwrap()));
+
+        let any: IpNet = "0.0.0.0/0".parse().unwrap();
+        assert!(any.contains("8.8.8.8".parse().unwrap()));
+        let link_local: IpNet = "fe80::/10".parse().unwrap();
+        assert!(link_local.contains("fe80::1234".parse().unwrap()));
+
+        assert!("192.168.1.0/33".parse::<IpNet>().is_err());
+        assert!("eth0".parse::<IpNet>().is_err());
+    }
+
+    #[test]
+    fn test_msearch_limiter() {
+        let ip: IpAddr = "192.168.1.50".parse().unwrap();
+        let other: IpAddr = "192.168.1.51".parse().unwrap();
+        let start = Instant::now();
+        let mut limiter = MsearchLimiter::new(2.0, 3);
+
+        for _ in 0..3 {
+            assert_eq!(limiter.admit(ip, start), Admission::Accepted { dropped: 0 });
+        }
+        assert_eq!(limiter.admit(ip, start), Admission::Dropped { first: true });
+        assert_eq!(
+            limiter.admit(ip, start),
+            Admission::Dropped { first: false }
+        );
+        assert_eq!(
+            limiter.admit(other, start),
+            Admission::Accepted { dropped: 0 }
+        );
+
+        // 2 jetons par seconde : un jeton regagné en 500 ms
+        let later = start + Duration::from_millis(500);
+        assert_eq!(limiter.admit(ip, later), Admission::Accepted { dropped: 2 });
+        assert_eq!(limiter.admit(ip, later), Admission::Dropped { first: true });
+
+        let unlimited = &mut MsearchLimiter::new(0.0, 1);
+        for _ in 0..100 {
+            assert_eq!(
+                unlimited.admit(ip, start),
+                Admission::Accepted { dropped: 0 }
+            );
+        }
+    }
+}
@@ -0,0 +1,168 @@
+//! Santé du socket SSDP et reprise après panne réseau
+//!
+//! Après une mise en veille, un changement d'interface ou une coupure Wi-Fi,
+//! le socket multicast peut échouer en boucle ou, pire, rester ouvert mais
+//! sourd (l'abonnement au groupe est perdu avec l'interface). Le listener
+//! recrée alors le socket avec un backoff exponentiel et réannonce les
+//! devices ; l'état est exposé par [`SsdpHealth`] (sonde `ssdp` de `/healthz`).
+
+use chrono::{DateTime, Utc};
+use serde::Serialize;
+use std::sync::{Arc, RwLock};
+use std::time::Duration;
+
+use super::SsdpTransport;
+
+/// Erreurs de lecture consécutives avant de recréer le socket
+pub(super) const MAX_CONSECUTIVE_ERRORS: u32 = 3;
+
+/// Intervalle de vérification de l'adresse IP locale
+pub(super) const IP_CHECK_INTERVAL: Duration = Duration::from_secs(10);
+
+/// État du socket SSDP
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize)]
+#[serde(rename_all = "snake_case")]
+pub enum SsdpHealthState {
+    /// Socket non ouvert
+    Stopped,
+    /// Socket opérationnel
+    Healthy,
+    /// Socket en erreur, recréation en cours
+    Recovering,
+}
+
+/// Santé du serveur SSDP
+#[derive(Debug, Clone, PartialEq, Serialize)]
+pub struct SsdpHealth {
+    pub state: SsdpHealthState,
+    /// Depuis quand l'état courant dure
+    pub since: DateTime<Utc>,
+    /// Nombre de recréations réussies du socket
+    pub recoveries: u64,
+    /// Tentatives de recréation échouées depuis la dernière panne
+    pub failed_attempts: u32,
+    pub last_error: Option<String>,
+    pub last_error_at: Option<DateTime<Utc>>,
+}
+
+impl SsdpHealth {
+    pub fn new(state: SsdpHealthState) -> Self {
+        Self {
+            state,
+            since: Utc::now(),
+            recoveries: 0,
+            failed_attempts: 0,
+            last_error: None,
+            last_error_at: None,
+        }
+    }
+
+    pub fn is_healthy(&self) -> bool {
+        self.state == SsdpHealthState::Healthy
+    }
+
+    fn set_state(&mut self, state: SsdpHealthState) {
+        if self.state != state {
+            self.state = state;
+            self.since = Utc::now();
+        }
+    }
+
+    pub(super) fn record_error(&mut self, error: impl ToString) {
+        self.last_error = Some(error.to_string());
+        self.last_error_at = Some(Utc::now());
+    }
+
+    pub(super) fn mark_healthy(&mut self) {
+        self.set_state(SsdpHealthState::Healthy);
+    }
+
+    pub(super) fn mark_recovering(&mut self, error: impl ToString) {
+        self.record_error(error);
+        self.set_state(SsdpHealthState::Recovering);
+    }
+
+    pub(super) fn mark_attempt_failed(&mut self, error: impl ToString) {
+        self.record_error(error);
+        self.failed_attempts += 1;
+    }
+
+    pub(super) fn mark_recovered(&mut self) {
+        self.recoveries += 1;
+        self.failed_attempts = 0;
+        self.set_state(SsdpHealthState::Healthy);
+    }
+}
+
+/// Backoff exponentiel borné entre deux tentatives de recréation
+#[derive(Debug, Clone)]
+pub(super) struct Backoff {
+    max: Duration,
+    current: Duration,
+}
+
+impl Backoff {
+    pub fn new(initial: Duration, max: Duration) -> Self {
+        Self {
+            max,
+            current: initial,
+        }
+    }
+
+    /// Attente avant la prochaine tentative, puis doublement
+    pub fn next_delay(&mut self) -> Duration {
+        let delay = self.current;
+        self.current = (self.current * 2).min(self.max);
+        delay
+    }
+}
+
+impl Default for Backoff {
+    fn default() -> Self {
+        Self::new(Duration::from_secs(1), Duration::from_secs(60))
+    }
+}
+
+/// Socket partagé entre les threads du serveur, remplaçable à chaud
+#[derive(Default)]
+pub(super) struct SharedSocket {
+    current: RwLock<Option<Arc<dyn SsdpTransport>>>,
+}
+
+impl SharedSocket {
+    pub fn get(&self) -> Option<Arc<dyn SsdpTransport>> {
+        self.current.read().unwrap().clone()
+    }
+
+    pub fn replace(&self, socket: Arc<dyn SsdpTransport>) {
+        *self.current.write().unwrap() = Some(socket);
+    }
+
+    pub fn is_open(&self) -> bool {
+        self.current.read().unwrap().is_some()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_backoff_and_health() {
+        let mut backoff = Backoff::new(Duration::from_secs(1), Duration::from_secs(5));
+        let delays: Vec<u64> = (0..5).map(|_| backoff.next_delay().as_secs()).collect();
+        assert_eq!(delays, vec![1, 2, 4, 5, 5]);
+
+        let mut health = SsdpHealth::new(SsdpHealthState::Healthy);
+        health.mark_recovering("Network is down");
+        health.mark_attempt_failed("No such device");
+        assert_eq!(health.state, SsdpHealthState::Recovering);
+        assert_eq!(health.failed_attempts, 1);
+
+        health.mark_recovered();
+        assert!(health.is_healthy());
+        assert_eq!(health.recoveries, 1);
+        assert_eq!(health.failed_attempts, 0);
+        assert_eq!(health.last_error.as_deref(), Some("No such device"));
+    }
+}
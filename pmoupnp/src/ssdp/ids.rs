@@ -0,0 +1,144 @@
+//! Identifiants UPnP 1.1 des annonces SSDP
+//!
+//! - `BOOTID.UPNP.ORG` change à chaque démarrage, et à chaque sortie de
+//!   veille (voir [`super::SsdpServer::handle_resume`]). Il est conservé
+//!   dans `host.ssdp.boot_id` pour croître d'un démarrage à l'autre, même si
+//!   l'horloge de la machine recule.
+//! - `CONFIGID.UPNP.ORG` identifie la version de la description d'un
+//!   device. Chaque device garde dans `host.upnp.config_ids.<udn>` son
+//!   numéro et l'empreinte de la description correspondante ; le numéro
+//!   avance quand l'empreinte change.
+//!
+//! Les deux valeurs restent dans les bornes UDA : 31 bits pour BOOTID,
+//! 0 à 16777215 pour CONFIGID.
+
+use std::sync::Mutex;
+use std::sync::atomic::{AtomicU32, Ordering};
+use std::time::{SystemTime, UNIX_EPOCH};
+
+use once_cell::sync::Lazy;
+use tracing::{info, warn};
+
+use crate::config_ext::UpnpConfigExt;
+
+/// Plus grande valeur de BOOTID.UPNP.ORG
+const MAX_BOOT_ID: u32 = 0x7fff_ffff;
+
+/// Plus grande valeur de CONFIGID.UPNP.ORG
+const MAX_CONFIG_ID: u32 = 0xff_ffff;
+
+/// BOOTID courant : lu et avancé au premier accès, puis suivi des sorties
+/// de veille ([`persist_boot_id`])
+static BOOT_ID: Lazy<AtomicU32> = Lazy::new(|| {
+    let config = pmoconfig::get_config();
+    let previous = config.get_ssdp_boot_id().unwrap_or(0);
+    let id = next_boot_id(previous);
+    match config.set_ssdp_boot_id(id) {
+        Ok(()) => info!(
+            "🔢 SSDP BOOTID.UPNP.ORG {} (previous boot: {})",
+            id, previous
+        ),
+        Err(e) => warn!("⚠️ Unable to persist SSDP BOOTID {}: {}", id, e),
+    }
+    AtomicU32::new(id)
+});
+
+/// Sérialise les mises à jour de `host.upnp.config_ids`
+static CONFIG_IDS: Mutex<()> = Mutex::new(());
+
+/// BOOTID.UPNP.ORG courant
+pub fn boot_id() -> u32 {
+    BOOT_ID.load(Ordering::Relaxed)
+}
+
+/// Conserve un BOOTID avancé en cours de fonctionnement (sortie de veille)
+pub(crate) fn persist_boot_id(id: u32) {
+    BOOT_ID.store(id, Ordering::Relaxed);
+    if let Err(e) = pmoconfig::get_config().set_ssdp_boot_id(id) {
+        warn!("⚠️ Unable to persist SSDP BOOTID {}: {}", id, e);
+    }
+}
+
+/// BOOTID suivant `previous`
+///
+/// Sans valeur conservée, on part des secondes depuis l'epoch, croissantes
+/// d'un démarrage à l'autre ; la valeur repart de 1 après 2³¹ - 1.
+pub(crate) fn next_boot_id(previous: u32) -> u32 {
+    if previous == 0 {
+        return SystemTime::now()
+            .duration_since(UNIX_EPOCH)
+            .map(|d| (d.as_secs() & u64::from(MAX_BOOT_ID)) as u32)
+            .unwrap_or(1)
+            .max(1);
+    }
+    if previous >= MAX_BOOT_ID {
+        1
+    } else {
+        previous + 1
+    }
+}
+
+/// CONFIGID.UPNP.ORG du device `udn` dont la description est `description`
+///
+/// Le numéro conservé est repris tant que la description ne change pas ;
+/// sinon il avance et la nouvelle empreinte est enregistrée.
+pub fn config_id(udn: &str, description: &[u8]) -> u32 {
+    let _guard = CONFIG_IDS.lock().unwrap();
+    let config = pmoconfig::get_config();
+    let fingerprint = fingerprint(description);
+    let stored = config.get_upnp_config_id(udn).unwrap_or_else(|e| {
+        warn!("Invalid host.upnp.config_ids entry for {}: {}", udn, e);
+        None
+    });
+    let (id, changed) = next_config_id(stored.as_ref(), &fingerprint);
+    if changed {
+        info!("🔢 CONFIGID.UPNP.ORG of {} is now {}", udn, id);
+        if let Err(e) = config.set_upnp_config_id(udn, id, &fingerprint) {
+            warn!("⚠️ Unable to persist CONFIGID of {}: {}", udn, e);
+        }
+    }
+    id
+}
+
+/// Numéro pour l'empreinte `fingerprint`, et s'il diffère de `stored`
+fn next_config_id(stored: Option<&(u32, String)>, fingerprint: &str) -> (u32, bool) {
+    match stored {
+        Some((id, previous)) if previous == fingerprint => (*id, false),
+        Some((id, _)) => (id.wrapping_add(1) & MAX_CONFIG_ID, true),
+        None => (0, true),
+    }
+}
+
+/// Empreinte FNV-1a 64 bits, stable d'une version de Rust à l'autre
+fn fingerprint(data: &[u8]) -> String {
+    let hash = data.iter().fold(0xcbf2_9ce4_8422_2325u64, |hash, byte| {
+        (hash ^ u64::from(*byte)).wrapping_mul(0x0000_0100_0000_01b3)
+    });
+    format!("{:016x}", hash)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_next_boot_id() {
+        assert!(next_boot_id(0) >= 1);
+        assert_eq!(next_boot_id(41), 42);
+        assert_eq!(next_boot_id(MAX_BOOT_ID), 1);
+    }
+
+    #[test]
+    fn test_next_config_id() {
+        let a = fingerprint(b"<root>a</root>");
+        let b = fingerprint(b"<root>b</root>");
+        assert_ne!(a, b);
+        assert_eq!(next_config_id(None, &a), (0, true));
+        assert_eq!(next_config_id(Some(&(7, a.clone())), &a), (7, false));
+        assert_eq!(next_config_id(Some(&(7, a)), &b), (8, true));
+        assert_eq!(
+            next_config_id(Some(&(MAX_CONFIG_ID, b.clone())), "x"),
+            (0, true)
+        );
+    }
+}
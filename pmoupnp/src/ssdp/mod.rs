@@ -6,35 +6,120 @@
 //! ## Fonctionnalités
 //!
 //! - ✅ Envoi de NOTIFY alive/byebye en multicast
-//! - ✅ Réponse aux M-SEARCH en unicast
-//! - ✅ Gestion multi-devices avec types de notification
-//! - ✅ Annonces périodiques automatiques
+//! - ✅ Réponse aux M-SEARCH en unicast, limitée par source (seau à jetons,
+//!   listes `allow` / `deny` en CIDR)
+//! - ✅ Gestion multi-devices avec types de notification, dérivés de l'arbre
+//!   du device : sous-devices et services compris ([`SsdpDevice::from_device_instance`])
+//! - ✅ Alive initiaux répétés (rafale UDA) puis annonces périodiques
 //! - ✅ Arrêt propre avec byebye
+//! - ✅ Recréation du socket après une panne réseau (veille, changement d'interface)
+//! - ✅ En-têtes UPnP 1.1 BOOTID.UPNP.ORG (conservé d'un démarrage à
+//!   l'autre) et CONFIGID.UPNP.ORG (version de la description) dans tous
+//!   les messages ([`boot_id`], [`config_id`])
+//! - ✅ Réannonce en sortie de veille, précédée de `ssdp:update` avec
+//!   NEXTBOOTID.UPNP.ORG
+//! - ✅ Détection des réseaux bridge en conteneur
+//! - ✅ Un listener et un annonceur par interface sur les hôtes multi-réseaux
+//!   ([`MultiInterfaceServer`])
+//! - ✅ IPv6 (`host.ipv6`) : écoute et annonces sur `[FF02::C]:1900` en plus
+//!   d'IPv4, avec une LOCATION en IPv6 ([`Ipv6Binder`])
+//! - ✅ Découverte des autres devices : M-SEARCH ponctuel ([`discover`]) et
+//!   suivi continu des apparitions et disparitions ([`SsdpMonitor`])
+//! - ✅ Diagnostic de la découverte ([`run_doctor`], `pmomusic doctor`)
 //!
 //! ## Architecture
 //!
 //! - [`SsdpServer`] : Serveur SSDP principal gérant les devices
 //! - [`SsdpDevice`] : Représentation d'un device pour SSDP
+//! - [`SsdpTransport`] / [`SsdpBinder`] : accès au réseau, remplaçable par
+//!   [`MemoryNetwork`] dans les tests (avec [`crate::clock::FakeClock`])
 //!
 //! ## Constants SSDP
 //!
-//! - **Multicast Address**: 239.255.255.250:1900
-//! - **Max-Age**: 1800 secondes (30 minutes)
-//! - **Announcement Period**: 900 secondes (15 minutes, Max-Age/2)
+//! - **Multicast Address**: 239.255.255.250:1900 (IPv4), [FF02::C]:1900 (IPv6)
+//! - **Max-Age**: 1800 secondes (30 minutes) par défaut
+//! - **Announcement Period**: Max-Age/2 par défaut, ±10 % de gigue
+//!
+//! Ces valeurs, le TTL multicast et l'en-tête SERVER se règlent par serveur
+//! via [`SsdpSettings`] (`host.ssdp` dans la configuration).
 
 mod client;
 mod device;
+mod discovery;
+mod doctor;
+mod filter;
+mod health;
+mod ids;
+mod multi;
+mod network;
 mod server;
+mod settings;
+mod transport;
 
 pub use client::{SsdpClient, SsdpEvent};
-pub use device::SsdpDevice;
+pub use device::{NotificationType, SsdpDevice};
+pub use discovery::{
+    DeviceEvent, DisappearReason, DiscoveredDevice, SsdpMonitor, discover, udn_of,
+};
+pub use doctor::run_doctor;
+pub(crate) use doctor::{announced_ip, check_multicast_route, check_network};
+pub use filter::IpNet;
+pub use health::{SsdpHealth, SsdpHealthState};
+pub use ids::{boot_id, config_id};
+pub use multi::MultiInterfaceServer;
+pub use network::{
+    NetworkEnvironment, detect_network_environment, interface_index, interface_ipv6,
+    log_network_environment, multicast_interfaces, multicast_route,
+};
 pub use server::SsdpServer;
+pub use settings::{
+    DEFAULT_ANNOUNCE_JITTER, DEFAULT_BURST_SPACING, DEFAULT_INITIAL_BURST, DEFAULT_MSEARCH_BURST,
+    DEFAULT_MSEARCH_RATE, DEFAULT_MULTICAST_TTL, SsdpSettings,
+};
+pub use transport::{
+    InterfaceBinder, Ipv6Binder, MemoryNetwork, MulticastBinder, SsdpBinder, SsdpTransport,
+};
 
 /// Adresse multicast SSDP
 pub const SSDP_MULTICAST_ADDR: &str = "239.255.255.250";
 
+/// Adresse multicast SSDP IPv6 (portée lien)
+pub const SSDP_MULTICAST_ADDR_V6: &str = "FF02::C";
+
 /// Port SSDP
 pub const SSDP_PORT: u16 = 1900;
 
-/// Durée de validité des annonces (en secondes)
+/// Durée de validité des annonces par défaut (en secondes)
 pub const MAX_AGE: u32 = 1800;
+
+/// Annonceur SSDP utilisé par le serveur UPnP
+///
+/// [`SsdpServer`] est l'implémentation par défaut. Une application qui
+/// embarque pmoupnp et possède déjà sa propre pile SSDP peut en fournir une
+/// autre via `UpnpServerOptions::with_ssdp_announcer`.
+pub trait SsdpAnnouncer: Send + Sync {
+    /// Annonce un device (NOTIFY alive) et répond désormais aux M-SEARCH
+    fn add_device(&self, device: SsdpDevice);
+
+    /// Retire un device (NOTIFY byebye)
+    fn remove_device(&self, uuid: &str);
+
+    /// Indique si l'annonceur est opérationnel
+    fn is_running(&self) -> bool;
+
+    /// Nombre de devices annoncés
+    fn device_count(&self) -> usize;
+
+    /// Remet les annonces à plat après une veille du système (voir
+    /// [`crate::power`]) ; sans effet par défaut
+    fn handle_resume(&self) {}
+
+    /// Santé de l'annonceur (par défaut déduite de [`is_running`](Self::is_running))
+    fn health(&self) -> SsdpHealth {
+        SsdpHealth::new(if self.is_running() {
+            SsdpHealthState::Healthy
+        } else {
+            SsdpHealthState::Stopped
+        })
+    }
+}
@@ -0,0 +1,292 @@
+//! Serveur SSDP multi-interfaces
+//!
+//! Sur une machine multi-réseaux (LAN + Wi-Fi + VPN), un socket unique
+//! n'écoute et n'annonce que sur l'interface choisie par le système : les
+//! devices restent invisibles depuis les autres réseaux. [`MultiInterfaceServer`]
+//! lance un [`SsdpServer`] par interface retenue par
+//! [`SsdpSettings::interfaces`], chacun avec son propre socket et une
+//! LOCATION pointant sur l'adresse de son interface.
+//!
+//! Avec [`SsdpSettings::ipv6`], chaque interface portant une adresse IPv6
+//! reçoit en plus un serveur sur `[FF02::C]:1900`, dont la LOCATION pointe
+//! sur cette adresse IPv6 ; [`MultiInterfaceServer::start_dual_stack`] fait
+//! de même pour l'interface du système seule.
+
+use std::io;
+use std::net::{IpAddr, Ipv4Addr};
+use std::sync::Arc;
+
+use tracing::{info, warn};
+
+use super::{
+    InterfaceBinder, Ipv6Binder, MulticastBinder, SsdpAnnouncer, SsdpBinder, SsdpDevice,
+    SsdpHealth, SsdpHealthState, SsdpServer, SsdpSettings, interface_ipv6, multicast_interfaces,
+};
+use crate::clock::system_clock;
+
+/// Serveur SSDP d'une interface
+struct InterfaceServer {
+    name: String,
+    ip: IpAddr,
+    /// Hôte des LOCATION annoncées (`None` : LOCATION du device inchangée)
+    host: Option<String>,
+    server: SsdpServer,
+}
+
+/// Un [`SsdpServer`] par interface réseau sélectionnée et par famille
+/// d'adresses
+///
+/// Les devices ajoutés sont annoncés sur chaque interface ; le BOOTID est
+/// commun à tous les serveurs.
+pub struct MultiInterfaceServer {
+    servers: Vec<InterfaceServer>,
+}
+
+impl MultiInterfaceServer {
+    /// Démarre un serveur sur chaque interface retenue par `settings`
+    ///
+    /// Une interface dont le socket ne peut être ouvert est ignorée ; échoue
+    /// si aucune interface ne correspond ou si aucun serveur ne démarre.
+    pub fn start(settings: SsdpSettings) -> io::Result<Self> {
+        let selected: Vec<(String, Ipv4Addr)> = multicast_interfaces()
+            .into_iter()
+            .filter(|(name, ip)| settings.selects_interface(name, *ip))
+            .collect();
+        if selected.is_empty() {
+            return Err(io::Error::new(
+                io::ErrorKind::NotFound,
+                format!(
+                    "no multicast interface matches host.ssdp.interfaces {:?}",
+                    settings.interfaces
+                ),
+            ));
+        }
+
+        let mut servers = Self {
+            servers: Vec::new(),
+        };
+        for (name, ip) in selected {
+            let binder = InterfaceBinder::new(name.clone(), ip);
+            servers.spawn(&settings, &name, IpAddr::V4(ip), true, Arc::new(binder));
+            if settings.ipv6 {
+                match interface_ipv6(&name) {
+                    Some(ip6) => {
+                        let binder = Ipv6Binder::on_interface(name.clone());
+                        servers.spawn(&settings, &name, IpAddr::V6(ip6), true, Arc::new(binder));
+                    }
+                    None => info!("SSDP: interface {} has no IPv6 address, IPv4 only", name),
+                }
+            }
+        }
+
+        if servers.servers.is_empty() {
+            return Err(io::Error::other(
+                "SSDP server could not start on any interface",
+            ));
+        }
+        Ok(servers)
+    }
+
+    /// Démarre un serveur IPv4 sur l'interface du système et un serveur
+    /// IPv6 sur `FF02::C`
+    ///
+    /// La LOCATION des annonces IPv4 est celle du device ; celle des
+    /// annonces IPv6 pointe sur l'adresse IPv6 de l'interface. Faute
+    /// d'IPv6 utilisable, seul le serveur IPv4 tourne.
+    pub fn start_dual_stack(settings: SsdpSettings) -> io::Result<Self> {
+        let mut servers = Self {
+            servers: Vec::new(),
+        };
+
+        let v4 = MulticastBinder;
+        let ip = v4.local_ip();
+        if !servers.spawn(&settings, "default", ip, false, Arc::new(v4)) {
+            return Err(io::Error::other("SSDP server could not start on IPv4"));
+        }
+
+        let v6 = Ipv6Binder::new();
+        let name = v6.interface().unwrap_or_else(|| "default".to_string());
+        let ip6 = v6.local_ip();
+        if ip6.is_unspecified() {
+            warn!(
+                "⚠️ host.ipv6 is enabled but {} has no IPv6 address, SSDP IPv4 only",
+                name
+            );
+        } else {
+            servers.spawn(&settings, &name, ip6, true, Arc::new(v6));
+        }
+        Ok(servers)
+    }
+
+    /// Démarre un serveur sur `binder` ; `rewrite` annonce `ip` dans la
+    /// LOCATION des devices
+    ///
+    /// Renvoie `false` (l'échec est journalisé) si le socket ne s'ouvre pas.
+    fn spawn(
+        &mut self,
+        settings: &SsdpSettings,
+        name: &str,
+        ip: IpAddr,
+        rewrite: bool,
+        binder: Arc<dyn SsdpBinder>,
+    ) -> bool {
+        let mut server = SsdpServer::with_transport(settings.clone(), binder, system_clock());
+        server.use_persistent_boot_id();
+        match server.start() {
+            Ok(()) => {
+                info!("✅ SSDP server started on interface {} ({})", name, ip);
+                self.servers.push(InterfaceServer {
+                    name: name.to_string(),
+                    ip,
+                    host: rewrite.then(|| url_host(ip, name)),
+                    server,
+                });
+                true
+            }
+            Err(e) => {
+                warn!(
+                    "⚠️ SSDP server failed on interface {} ({}): {}",
+                    name, ip, e
+                );
+                false
+            }
+        }
+    }
+
+    /// Interfaces servies (nom, adresse au démarrage), une entrée par
+    /// famille d'adresses
+    pub fn interfaces(&self) -> Vec<(String, IpAddr)> {
+        self.servers
+            .iter()
+            .map(|s| (s.name.clone(), s.ip))
+            .collect()
+    }
+}
+
+/// Hôte d'URL pour l'adresse `ip` de l'interface `interface`
+///
+/// Les adresses IPv6 sont entre crochets ; une adresse de lien local porte
+/// l'identifiant de zone (`%25` est le `%` encodé, RFC 6874).
+fn url_host(ip: IpAddr, interface: &str) -> String {
+    match ip {
+        IpAddr::V4(ip) => ip.to_string(),
+        IpAddr::V6(ip) if ip.is_unicast_link_local() => format!("[{}%25{}]", ip, interface),
+        IpAddr::V6(ip) => format!("[{}]", ip),
+    }
+}
+
+/// Remplace l'hôte IP d'une URL de description par `host`
+///
+/// Les URLs dont l'hôte n'est pas une adresse IP (nom DNS) sont
+/// conservées telles quelles.
+fn location_for(location: &str, host: &str) -> String {
+    let Some((scheme, rest)) = location.split_once("://") else {
+        return location.to_string();
+    };
+    let authority_end = rest.find('/').unwrap_or(rest.len());
+    let (authority, path) = rest.split_at(authority_end);
+    let (current, port) = match authority.strip_prefix('[') {
+        Some(bracketed) => match bracketed.split_once(']') {
+            Some((ip, tail)) => (ip, tail.strip_prefix(':')),
+            None => return location.to_string(),
+        },
+        None => match authority.split_once(':') {
+            Some((host, port)) => (host, Some(port)),
+            None => (authority, None),
+        },
+    };
+    let current = current.split('%').next().unwrap_or(current);
+    if current.parse::<IpAddr>().is_err() {
+        return location.to_string();
+    }
+    match port {
+        Some(port) => format!("{}://{}:{}{}", scheme, host, port, path),
+        None => format!("{}://{}{}", scheme, host, path),
+    }
+}
+
+impl SsdpAnnouncer for MultiInterfaceServer {
+    fn add_device(&self, device: SsdpDevice) {
+        for s in &self.servers {
+            let mut device = device.clone();
+            if let Some(host) = &s.host {
+                device.location = location_for(&device.location, host);
+            }
+            s.server.add_device(device);
+        }
+    }
+
+    fn remove_device(&self, uuid: &str) {
+        for s in &self.servers {
+            s.server.remove_device(uuid);
+        }
+    }
+
+    fn is_running(&self) -> bool {
+        self.servers.iter().any(|s| s.server.is_running())
+    }
+
+    fn device_count(&self) -> usize {
+        self.servers.first().map_or(0, |s| s.server.device_count())
+    }
+
+    fn handle_resume(&self) {
+        for s in &self.servers {
+            s.server.handle_resume();
+        }
+    }
+
+    fn health(&self) -> SsdpHealth {
+        let healths: Vec<SsdpHealth> = self.servers.iter().map(|s| s.server.health()).collect();
+        healths
+            .iter()
+            .find(|h| h.state != SsdpHealthState::Healthy)
+            .or(healths.first())
+            .cloned()
+            .unwrap_or_else(|| SsdpHealth::new(SsdpHealthState::Stopped))
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use std::net::Ipv6Addr;
+
+    #[test]
+    fn test_location_for() {
+        let host = url_host(IpAddr::V4(Ipv4Addr::new(10, 8, 0, 2)), "tun0");
+        assert_eq!(
+            location_for("http://192.168.1.10:8080/device/desc.xml", &host),
+            "http://10.8.0.2:8080/device/desc.xml"
+        );
+        assert_eq!(
+            location_for("http://192.168.1.10/desc.xml", &host),
+            "http://10.8.0.2/desc.xml"
+        );
+        assert_eq!(
+            location_for("http://pmomusic.local:8080/desc.xml", &host),
+            "http://pmomusic.local:8080/desc.xml"
+        );
+    }
+
+    #[test]
+    fn test_location_for_ipv6() {
+        let global = url_host(IpAddr::V6("2001:db8::10".parse().unwrap()), "eth0");
+        assert_eq!(global, "[2001:db8::10]");
+        assert_eq!(
+            location_for("http://192.168.1.10:8080/desc.xml", &global),
+            "http://[2001:db8::10]:8080/desc.xml"
+        );
+        assert_eq!(
+            location_for("http://[2001:db8::1]:8080/desc.xml", &global),
+            "http://[2001:db8::10]:8080/desc.xml"
+        );
+
+        let link_local = Ipv6Addr::new(0xfe80, 0, 0, 0, 0, 0, 0, 1);
+        let host = url_host(IpAddr::V6(link_local), "eth0");
+        assert_eq!(
+            location_for("http://192.168.1.10:8080/desc.xml", &host),
+            "http://[fe80::1%25eth0]:8080/desc.xml"
+        );
+    }
+}
@@ -0,0 +1,204 @@
+//! Détection de l'environnement réseau (conteneurs)
+//!
+//! SSDP repose sur le multicast UDP (239.255.255.250:1900). Dans un conteneur
+//! Docker/Podman en réseau « bridge », ce trafic ne traverse pas le NAT : les
+//! annonces partent mais ne sont jamais vues du LAN et les M-SEARCH n'arrivent
+//! jamais. Ce module détecte cette situation pour afficher un avertissement
+//! exploitable au démarrage.
+
+use std::net::{IpAddr, Ipv4Addr, Ipv6Addr, UdpSocket};
+use std::path::Path;
+use tracing::{info, warn};
+
+/// Description de l'environnement réseau du processus
+#[derive(Debug, Clone, Default)]
+pub struct NetworkEnvironment {
+    /// Le processus tourne dans un conteneur
+    pub in_container: bool,
+    /// Le conteneur semble utiliser un réseau bridge (multicast non routé)
+    pub bridged: bool,
+    /// Adresses IPv4 non-loopback visibles
+    pub addresses: Vec<(String, Ipv4Addr)>,
+}
+
+/// Détecte si le processus tourne dans un conteneur
+fn detect_container() -> bool {
+    if Path::new("/.dockerenv").exists() || Path::new("/run/.containerenv").exists() {
+        return true;
+    }
+
+    if std::env::var_os("KUBERNETES_SERVICE_HOST").is_some() {
+        return true;
+    }
+
+    match std::fs::read_to_string("/proc/1/cgroup") {
+        Ok(cgroup) => ["docker", "kubepods", "containerd", "libpod", "lxc"]
+            .iter()
+            .any(|marker| cgroup.contains(marker)),
+        Err(_) => false,
+    }
+}
+
+/// Adresses des réseaux bridge par défaut de Docker (172.17.0.0/16 et
+/// suivants dans 172.16.0.0/12) et Podman (10.88.0.0/16)
+fn is_default_bridge_address(ip: &Ipv4Addr) -> bool {
+    let o = ip.octets();
+    (o[0] == 172 && (16..=31).contains(&o[1])) || (o[0] == 10 && o[1] == 88)
+}
+
+/// Analyse l'environnement réseau courant
+///
+/// Un conteneur est considéré en réseau bridge lorsque toutes ses interfaces
+/// non-loopback portent une adresse d'un bridge par défaut. En réseau hôte
+/// (`--network host`), les interfaces physiques de la machine sont visibles.
+pub fn detect_network_environment() -> NetworkEnvironment {
+    let addresses: Vec<(String, Ipv4Addr)> = get_if_addrs::get_if_addrs()
+        .map(|ifaces| {
+            ifaces
+                .into_iter()
+                .filter_map(|iface| match iface.ip() {
+                    std::net::IpAddr::V4(ip) if !ip.is_loopback() => Some((iface.name, ip)),
+                    _ => None,
+                })
+                .collect()
+        })
+        .unwrap_or_default();
+
+    let in_container = detect_container();
+    let bridged = in_container
+        && !addresses.is_empty()
+        && addresses.iter().all(|(_, ip)| is_default_bridge_address(ip));
+
+    NetworkEnvironment {
+        in_container,
+        bridged,
+        addresses,
+    }
+}
+
+/// Journalise l'environnement réseau et avertit si SSDP ne pourra pas fonctionner
+pub fn log_network_environment(env: &NetworkEnvironment) {
+    if !env.in_container {
+        return;
+    }
+
+    if env.bridged {
+        let addrs: Vec<String> = env
+            .addresses
+            .iter()
+            .map(|(name, ip)| format!("{}={}", name, ip))
+            .collect();
+        warn!(
+            "⚠️ Running in a container with bridged networking ({}): SSDP multicast \
+            will not reach the LAN and devices will not be discovered. \
+            Use host networking (`docker run --network host` / `network_mode: host`), \
+            or disable SSDP with PMOMUSIC_CONFIG__HOST__SSDP__ENABLED=false and set \
+            PMOMUSIC_CONFIG__HOST__BASE_URL to the externally reachable address.",
+            addrs.join(", ")
+        );
+    } else {
+        info!("🐳 Running in a container with host networking");
+    }
+}
+
+/// Interfaces IPv4 actives et compatibles multicast, hors loopback
+///
+/// Sous Linux, les drapeaux `IFF_UP` et `IFF_MULTICAST` sont lus dans
+/// `/sys/class/net/<nom>/flags` ; ailleurs, toute interface portant une
+/// adresse IPv4 est retenue.
+pub fn multicast_interfaces() -> Vec<(String, Ipv4Addr)> {
+    get_if_addrs::get_if_addrs()
+        .map(|ifaces| {
+            ifaces
+                .into_iter()
+                .filter_map(|iface| match iface.ip() {
+                    IpAddr::V4(ip) if !ip.is_loopback() && is_multicast_capable(&iface.name) => {
+                        Some((iface.name, ip))
+                    }
+                    _ => None,
+                })
+                .collect()
+        })
+        .unwrap_or_default()
+}
+
+/// Adresse IPv6 d'une interface à annoncer dans la LOCATION
+///
+/// Une adresse globale ou ULA est préférée à l'adresse de lien local, que
+/// les control points doivent accompagner d'un identifiant de zone.
+pub fn interface_ipv6(name: &str) -> Option<Ipv6Addr> {
+    let addrs: Vec<Ipv6Addr> = get_if_addrs::get_if_addrs()
+        .ok()?
+        .into_iter()
+        .filter(|iface| iface.name == name)
+        .filter_map(|iface| match iface.ip() {
+            IpAddr::V6(ip) if !ip.is_loopback() && !ip.is_multicast() => Some(ip),
+            _ => None,
+        })
+        .collect();
+    addrs
+        .iter()
+        .find(|ip| !ip.is_unicast_link_local())
+        .or(addrs.first())
+        .copied()
+}
+
+/// Index système d'une interface (0, interface par défaut, si inconnu)
+pub fn interface_index(name: &str) -> u32 {
+    #[cfg(unix)]
+    {
+        match std::ffi::CString::new(name) {
+            Ok(name) => unsafe { libc::if_nametoindex(name.as_ptr()) },
+            Err(_) => 0,
+        }
+    }
+
+    #[cfg(not(unix))]
+    {
+        let _ = name;
+        0
+    }
+}
+
+/// Drapeaux `IFF_UP` et `IFF_MULTICAST` d'une interface (vrai si inconnus)
+fn is_multicast_capable(name: &str) -> bool {
+    const IFF_UP: u32 = 0x1;
+    const IFF_MULTICAST: u32 = 0x1000;
+    match std::fs::read_to_string(format!("/sys/class/net/{}/flags", name)) {
+        Ok(flags) => u32::from_str_radix(flags.trim().trim_start_matches("0x"), 16)
+            .map(|f| f & IFF_UP != 0 && f & IFF_MULTICAST != 0)
+            .unwrap_or(true),
+        Err(_) => true,
+    }
+}
+
+/// Adresse locale par laquelle partirait le multicast SSDP
+///
+/// Aucun paquet n'est émis : « connecter » un socket UDP demande seulement
+/// au noyau de choisir la route vers 239.255.255.250. Une erreur
+/// (`Network is unreachable`) signifie qu'aucune route, ni par défaut ni
+/// `224.0.0.0/4`, ne mène au groupe SSDP.
+pub fn multicast_route() -> std::io::Result<IpAddr> {
+    let socket = UdpSocket::bind((Ipv4Addr::UNSPECIFIED, 0))?;
+    socket.connect((super::SSDP_MULTICAST_ADDR, super::SSDP_PORT))?;
+    Ok(socket.local_addr()?.ip())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_default_bridge_address() {
+        assert!(is_default_bridge_address(&Ipv4Addr::new(172, 17, 0, 2)));
+        assert!(is_default_bridge_address(&Ipv4Addr::new(10, 88, 0, 5)));
+        assert!(!is_default_bridge_address(&Ipv4Addr::new(192, 168, 1, 10)));
+        assert!(!is_default_bridge_address(&Ipv4Addr::new(172, 32, 0, 1)));
+    }
+
+    #[test]
+    fn test_unknown_interface() {
+        assert_eq!(interface_ipv6("pmo-no-such-if0"), None);
+        assert_eq!(interface_index("pmo-no-such-if0"), 0);
+    }
+}
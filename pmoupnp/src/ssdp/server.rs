@@ -1,126 +1,239 @@
 //! Serveur SSDP
 
-use super::{MAX_AGE, SSDP_MULTICAST_ADDR, SSDP_PORT, SsdpDevice};
-use socket2::{Domain, Protocol, Socket, Type};
+use super::filter::{Admission, MsearchLimiter};
+use super::health::{Backoff, IP_CHECK_INTERVAL, MAX_CONSECUTIVE_ERRORS, SharedSocket};
+use super::{
+    MulticastBinder, NotificationType, SsdpAnnouncer, SsdpBinder, SsdpDevice, SsdpHealth,
+    SsdpHealthState, SsdpSettings, SsdpTransport,
+};
+use crate::clock::{Clock, system_clock};
 use std::collections::HashMap;
-use std::net::{SocketAddr, UdpSocket};
+use std::net::{IpAddr, SocketAddr};
+use std::sync::atomic::{AtomicBool, AtomicU32, Ordering};
 use std::sync::{Arc, RwLock};
-use std::time::Duration;
+use std::time::{Duration, Instant};
 use tracing::{debug, info, warn};
 
+type Devices = Arc<RwLock<HashMap<String, SsdpDevice>>>;
+
+/// Parties du serveur partagées avec ses threads
+#[derive(Clone)]
+struct Handles {
+    devices: Devices,
+    socket: Arc<SharedSocket>,
+    settings: Arc<SsdpSettings>,
+    boot_id: Arc<AtomicU32>,
+    binder: Arc<dyn SsdpBinder>,
+    clock: Arc<dyn Clock>,
+}
+
 /// Serveur SSDP gérant les annonces et découvertes
 pub struct SsdpServer {
     /// Devices enregistrés (UUID -> Device)
-    devices: Arc<RwLock<HashMap<String, SsdpDevice>>>,
+    devices: Devices,
+
+    /// Socket UDP pour SSDP, recréé par le listener après une panne réseau
+    socket: Arc<SharedSocket>,
+
+    /// max-age, intervalle d'annonce, TTL, en-tête SERVER
+    settings: Arc<SsdpSettings>,
+
+    /// État du socket, exposé par [`SsdpServer::health`]
+    health: Arc<RwLock<SsdpHealth>>,
+
+    /// BOOTID.UPNP.ORG courant, incrémenté à chaque sortie de veille
+    boot_id: Arc<AtomicU32>,
 
-    /// Socket UDP pour SSDP
-    socket: Option<Arc<UdpSocket>>,
+    /// Le BOOTID est celui de `host.ssdp.boot_id` et y est reporté quand il
+    /// avance (voir [`super::ids`])
+    persist_boot_id: bool,
+
+    /// Sortie de veille signalée, traitée par le listener
+    resume_pending: Arc<AtomicBool>,
+
+    /// Ouverture du transport (socket multicast ou réseau simulé)
+    binder: Arc<dyn SsdpBinder>,
+
+    /// Attentes et mesures de temps des threads du serveur
+    clock: Arc<dyn Clock>,
 }
 
 impl SsdpServer {
-    /// Crée un nouveau serveur SSDP
+    /// Crée un nouveau serveur SSDP paramétré par `host.ssdp`
     pub fn new() -> Self {
+        Self::with_settings(SsdpSettings::from_config())
+    }
+
+    /// Crée un serveur SSDP avec ses propres paramètres d'annonce
+    ///
+    /// Le BOOTID annoncé est celui du démarrage, conservé dans la
+    /// configuration.
+    pub fn with_settings(settings: SsdpSettings) -> Self {
+        let mut server = Self::with_transport(settings, Arc::new(MulticastBinder), system_clock());
+        server.use_persistent_boot_id();
+        server
+    }
+
+    /// Crée un serveur SSDP sur un transport et une horloge donnés
+    ///
+    /// Les tests y passent un [`super::MemoryNetwork`] et une
+    /// [`crate::clock::FakeClock`] pour se passer du réseau et des attentes
+    /// réelles. Le BOOTID, tiré de l'horloge, n'est pas conservé (voir
+    /// [`Self::use_persistent_boot_id`]).
+    pub fn with_transport(
+        settings: SsdpSettings,
+        binder: Arc<dyn SsdpBinder>,
+        clock: Arc<dyn Clock>,
+    ) -> Self {
         Self {
             devices: Arc::new(RwLock::new(HashMap::new())),
-            socket: None,
+            socket: Arc::new(SharedSocket::default()),
+            settings: Arc::new(settings),
+            health: Arc::new(RwLock::new(SsdpHealth::new(SsdpHealthState::Stopped))),
+            boot_id: Arc::new(AtomicU32::new(super::ids::next_boot_id(0))),
+            persist_boot_id: false,
+            resume_pending: Arc::new(AtomicBool::new(false)),
+            binder,
+            clock,
         }
     }
 
-    /// Démarre le serveur SSDP
-    ///
-    /// # Returns
-    ///
-    /// `Ok(())` si le démarrage a réussi, `Err` sinon
-    pub fn start(&mut self) -> std::io::Result<()> {
-        let addr = format!("{}:{}", SSDP_MULTICAST_ADDR, SSDP_PORT);
+    fn handles(&self) -> Handles {
+        Handles {
+            devices: Arc::clone(&self.devices),
+            socket: Arc::clone(&self.socket),
+            settings: Arc::clone(&self.settings),
+            boot_id: Arc::clone(&self.boot_id),
+            binder: Arc::clone(&self.binder),
+            clock: Arc::clone(&self.clock),
+        }
+    }
 
-        // Créer le socket avec socket2 pour permettre la réutilisation du port
-        // Ceci est essentiel pour que plusieurs clients/serveurs UPnP puissent coexister
-        let socket2 = Socket::new(Domain::IPV4, Type::DGRAM, Some(Protocol::UDP))?;
+    /// Paramètres d'annonce
+    pub fn settings(&self) -> &SsdpSettings {
+        &self.settings
+    }
 
-        // SO_REUSEADDR : permet à plusieurs sockets de bind sur le même port
-        // Essentiel sur toutes les plateformes pour le multicast
-        socket2.set_reuse_address(true)?;
+    /// Indique si le socket SSDP est ouvert
+    pub fn is_running(&self) -> bool {
+        self.socket.is_open()
+    }
 
-        // SO_REUSEPORT : nécessaire sur Unix (macOS/Linux/BSD) pour que plusieurs processus
-        // puissent recevoir du trafic multicast sur le même port.
-        // Windows n'a pas besoin de SO_REUSEPORT - SO_REUSEADDR suffit.
-        #[cfg(unix)]
-        {
-            use std::os::unix::io::AsRawFd;
-            let fd = socket2.as_raw_fd();
-            let optval: libc::c_int = 1;
-            unsafe {
-                let result = libc::setsockopt(
-                    fd,
-                    libc::SOL_SOCKET,
-                    libc::SO_REUSEPORT,
-                    &optval as *const _ as *const libc::c_void,
-                    std::mem::size_of_val(&optval) as libc::socklen_t,
-                );
-                if result != 0 {
-                    return Err(std::io::Error::last_os_error());
+    /// État du socket (sain, en cours de recréation, arrêté)
+    pub fn health(&self) -> SsdpHealth {
+        self.health.read().unwrap().clone()
+    }
+
+    /// Nombre de devices annoncés
+    pub fn device_count(&self) -> usize {
+        self.devices.read().map(|d| d.len()).unwrap_or(0)
+    }
+
+    /// BOOTID.UPNP.ORG annoncé
+    pub fn boot_id(&self) -> u32 {
+        self.boot_id.load(Ordering::Relaxed)
+    }
+
+    /// Annonce le BOOTID du démarrage (`host.ssdp.boot_id`) et y reporte
+    /// ses avancées
+    ///
+    /// Les serveurs d'un même hôte annoncent ainsi la même valeur (voir
+    /// [`super::MultiInterfaceServer`]).
+    pub fn use_persistent_boot_id(&mut self) {
+        self.boot_id.store(super::ids::boot_id(), Ordering::Relaxed);
+        self.persist_boot_id = true;
+    }
+
+    /// Remet les annonces à plat après une veille du système
+    ///
+    /// Les control points ont pu oublier nos devices pendant la veille, et
+    /// l'abonnement au groupe multicast ne survit pas toujours à la
+    /// réinitialisation de l'interface. Les devices restant disponibles, on
+    /// annonce le changement de BOOTID par des `ssdp:update` (BOOTID
+    /// courant, NEXTBOOTID.UPNP.ORG suivant) comme le prévoit UDA 1.1, puis
+    /// on l'incrémente (les abonnements GENA antérieurs sont caducs) ; le
+    /// listener recrée ensuite le socket et relance la rafale d'alive.
+    pub fn handle_resume(&self) {
+        let current = self.boot_id();
+        let next = super::ids::next_boot_id(current);
+        if let Some(socket) = self.socket.get() {
+            let devices: Vec<SsdpDevice> = self.devices.read().unwrap().values().cloned().collect();
+            for device in &devices {
+                for nt in device.get_notification_types() {
+                    self.send_update(&*socket, device, nt, current, next);
                 }
             }
-            debug!("✅ SO_REUSEPORT enabled (Unix)");
         }
-
-        #[cfg(windows)]
-        {
-            debug!("✅ SO_REUSEADDR enabled (Windows - SO_REUSEPORT not needed)");
-        }
-
-        // Bind sur 0.0.0.0:1900
-        let bind_addr: SocketAddr = format!("0.0.0.0:{}", SSDP_PORT).parse().unwrap();
-        socket2.bind(&bind_addr.into())?;
-
-        // Convertir en UdpSocket standard
-        let mut socket: UdpSocket = socket2.into();
-
-        // Rejoindre le groupe multicast
-        socket.join_multicast_v4(
-            &SSDP_MULTICAST_ADDR.parse().unwrap(),
-            &"0.0.0.0".parse().unwrap(),
-        )?;
-
-        // Sur macOS, join_multicast_v4 peut positionner IP_MULTICAST_IF
-        // sur une interface bridge/VM. On remet explicitement l'interface
-        // de sortie sur l'IP principale.
-        let local_ip: std::net::Ipv4Addr = pmoutils::guess_local_ip()
-            .parse()
-            .unwrap_or("0.0.0.0".parse().unwrap());
-        {
-            let socket2 = Socket::from(socket);
-            socket2.set_multicast_if_v4(&local_ip)?;
-            debug!(
-                "SSDP server: multicast outgoing interface set to {}",
-                local_ip
-            );
-            socket = socket2.into();
+        self.boot_id.store(next, Ordering::Relaxed);
+        if self.persist_boot_id {
+            super::ids::persist_boot_id(next);
         }
+        info!(
+            "☀️ SSDP re-announcing devices after sleep (BOOTID {})",
+            next
+        );
+        self.resume_pending.store(true, Ordering::Relaxed);
+    }
 
-        socket.set_read_timeout(Some(Duration::from_secs(1)))?;
-        socket.set_multicast_loop_v4(false)?;
+    /// Démarre le serveur SSDP
+    ///
+    /// # Returns
+    ///
+    /// `Ok(())` si le démarrage a réussi, `Err` sinon
+    pub fn start(&mut self) -> std::io::Result<()> {
+        let addr = self.binder.multicast_group();
+        let (socket, local_ip) = self.binder.open(&self.settings)?;
+        self.socket.replace(socket);
+        self.health.write().unwrap().mark_healthy();
 
-        let socket = Arc::new(socket);
-        self.socket = Some(socket.clone());
+        info!(
+            "✅ SSDP server started on {} (max-age={}s, announce every {:?} ±{:.0}%, TTL={})",
+            addr,
+            self.settings.max_age,
+            self.settings.announce_period(),
+            self.settings.jitter * 100.0,
+            self.settings.multicast_ttl
+        );
 
-        info!("✅ SSDP server started on {}", addr);
+        // Devices enregistrés avant l'ouverture du socket : rafale initiale
+        let pending: Vec<SsdpDevice> = self.devices.read().unwrap().values().cloned().collect();
+        for device in pending {
+            self.spawn_alive_burst(device);
+        }
 
         // Lancer les goroutines d'annonces périodiques et d'écoute M-SEARCH
-        self.start_periodic_announcements(socket.clone());
-        self.start_msearch_listener(socket.clone());
+        self.start_periodic_announcements();
+        self.start_msearch_listener(local_ip);
 
         Ok(())
     }
 
-    /// Ajoute un device et envoie un alive initial
+    /// Ajoute un device et lance sa rafale d'alive initiaux
+    ///
+    /// Un device déjà annoncé dont le CONFIGID a changé (description
+    /// modifiée) est d'abord retiré par des byebye portant l'ancien
+    /// CONFIGID, comme l'exige UDA 1.1.
     pub fn add_device(&self, device: SsdpDevice) {
         let uuid = device.uuid.clone();
         let mut devices = self.devices.write().unwrap();
-        devices.insert(uuid.clone(), device.clone());
+        let previous = devices.insert(uuid.clone(), device.clone());
         drop(devices);
 
+        if let Some(previous) = previous
+            && previous.config_id != device.config_id
+            && let Some(socket) = self.socket.get()
+        {
+            info!(
+                "🔁 SSDP device {} changed CONFIGID {} -> {}",
+                uuid, previous.config_id, device.config_id
+            );
+            let boot_id = self.boot_id();
+            let group = self.binder.multicast_group();
+            for nt in previous.get_notification_types() {
+                Self::send_byebye(&*socket, group, boot_id, &previous, nt);
+            }
+        }
+
         info!(
             "🆕 SSDP device registered: {} ({} NTs)",
             uuid,
@@ -129,21 +242,69 @@ impl SsdpServer {
         debug!(
             "🆕 SSDP device notification types for {}: {:?}",
             uuid,
-            device.get_notification_types()
+            device
+                .get_notification_types()
+                .iter()
+                .map(|target| &target.usn)
+                .collect::<Vec<_>>()
         );
 
-        // Envoyer alive pour tous les NTs
-        if let Some(ref socket) = self.socket {
-            let nts = device.get_notification_types();
-            for nt in nts.iter() {
-                Self::send_alive(socket, &device, nt, false);
-                // Petit délai pour éviter de saturer le buffer UDP sur macOS
-                std::thread::sleep(Duration::from_millis(5));
-            }
+        if self.socket.is_open() {
+            self.spawn_alive_burst(device);
         }
     }
 
-    /// Supprime un device et envoie un byebye
+    /// Émet les alive initiaux de tous les NTs du device, plusieurs fois
+    ///
+    /// La rafale tourne dans son propre thread pour ne pas bloquer
+    /// l'enregistrement ; elle s'interrompt si le device est retiré entre
+    /// deux émissions (le byebye ne doit pas être suivi d'un alive).
+    fn spawn_alive_burst(&self, device: SsdpDevice) {
+        Self::alive_burst(self.handles(), device);
+    }
+
+    fn alive_burst(handles: Handles, device: SsdpDevice) {
+        std::thread::spawn(move || {
+            let Handles {
+                devices,
+                socket,
+                settings,
+                boot_id,
+                binder,
+                clock,
+            } = handles;
+            let delays = settings.burst_delays();
+            let rounds = delays.len();
+            for (round, delay) in delays.into_iter().enumerate() {
+                clock.sleep(delay);
+                if !devices.read().unwrap().contains_key(&device.uuid) {
+                    debug!("🛑 Alive burst for {} stopped: device removed", device.uuid);
+                    return;
+                }
+                let Some(socket) = socket.get() else {
+                    return;
+                };
+                debug!(
+                    "📣 Initial alive burst {}/{} for {}",
+                    round + 1,
+                    rounds,
+                    device.uuid
+                );
+                let boot_id = boot_id.load(Ordering::Relaxed);
+                let group = binder.multicast_group();
+                for nt in device.get_notification_types() {
+                    Self::send_alive(&*socket, &settings, group, boot_id, &device, nt, false);
+                    // Petit délai pour éviter de saturer le buffer UDP sur macOS
+                    clock.sleep(Duration::from_millis(5));
+                }
+            }
+        });
+    }
+
+    /// Supprime un device et envoie ses byebye
+    ///
+    /// La première série part tout de suite, les répétitions
+    /// (`notify_repeat`) dans un thread à part.
     pub fn remove_device(&self, uuid: &str) {
         let mut devices = self.devices.write().unwrap();
         if let Some(device) = devices.remove(uuid) {
@@ -155,48 +316,80 @@ impl SsdpServer {
                 device.get_notification_types().len()
             );
 
-            // Envoyer byebye pour tous les NTs
-            if let Some(ref socket) = self.socket {
+            let handles = self.handles();
+            let delays = self.settings.repeat_delays();
+            let devices = [device];
+            Self::byebye_rounds(&handles, &devices, &delays[..1]);
+            if delays.len() > 1 {
+                std::thread::spawn(move || Self::byebye_rounds(&handles, &devices, &delays[1..]));
+            }
+        }
+    }
+
+    /// Émet les byebye de tous les NTs de `devices`, une série après chacun
+    /// des `delays`
+    ///
+    /// Un device de nouveau enregistré entre deux séries n'est plus
+    /// concerné : un byebye ne doit pas suivre son alive.
+    fn byebye_rounds(handles: &Handles, devices: &[SsdpDevice], delays: &[Duration]) {
+        let group = handles.binder.multicast_group();
+        for delay in delays {
+            handles.clock.sleep(*delay);
+            let Some(socket) = handles.socket.get() else {
+                return;
+            };
+            let boot_id = handles.boot_id.load(Ordering::Relaxed);
+            let registered = handles.devices.read().unwrap();
+            for device in devices {
+                if registered.contains_key(&device.uuid) {
+                    continue;
+                }
                 for nt in device.get_notification_types() {
-                    self.send_byebye(socket, &device, nt);
+                    Self::send_byebye(&*socket, group, boot_id, device, nt);
                 }
             }
         }
     }
 
-    /// Envoie un NOTIFY alive
-    fn send_alive(socket: &UdpSocket, device: &SsdpDevice, nt: &str, is_periodic: bool) {
-        let usn = if nt.starts_with("uuid:") {
-            format!("{}", nt)
-        } else {
-            format!("uuid:{}::{}", device.uuid, nt)
-        };
+    /// Envoie un NOTIFY alive au groupe multicast `group`
+    fn send_alive(
+        socket: &dyn SsdpTransport,
+        settings: &SsdpSettings,
+        group: SocketAddr,
+        boot_id: u32,
+        device: &SsdpDevice,
+        target: &NotificationType,
+        is_periodic: bool,
+    ) {
+        let NotificationType { nt, usn } = target;
 
         let msg = format!(
             "NOTIFY * HTTP/1.1\r\n\
-             HOST: {}:{}\r\n\
+             HOST: {}\r\n\
              CACHE-CONTROL: max-age={}\r\n\
              LOCATION: {}\r\n\
              NT: {}\r\n\
              NTS: ssdp:alive\r\n\
              SERVER: {}\r\n\
              USN: {}\r\n\
+             BOOTID.UPNP.ORG: {}\r\n\
+             CONFIGID.UPNP.ORG: {}\r\n\
              \r\n",
-            SSDP_MULTICAST_ADDR, SSDP_PORT, MAX_AGE, device.location, nt, device.server, usn
+            group,
+            settings.max_age,
+            device.location,
+            nt,
+            settings.server_header(&device.server),
+            usn,
+            boot_id,
+            device.config_id
         );
 
-        let addr: SocketAddr = format!("{}:{}", SSDP_MULTICAST_ADDR, SSDP_PORT)
-            .parse()
-            .unwrap();
-
-        match socket.send_to(msg.as_bytes(), addr) {
+        match socket.send_to(msg.as_bytes(), group) {
             Ok(_) => {
                 let label = if is_periodic { " (periodic)" } else { "" };
                 info!("✅ NOTIFY alive{}: {} (NT={})", label, usn, nt);
-                debug!(
-                    "📣 NOTIFY alive{} payload\n<details>\n\n```\n{}\n```\n</details>\n",
-                    label, msg
-                );
+                crate::trace_payload!(msg, "📣 NOTIFY alive{} payload", label);
             }
 
             Err(e) => {
@@ -206,58 +399,111 @@ impl SsdpServer {
         }
     }
 
-    /// Envoie un NOTIFY byebye
-    fn send_byebye(&self, socket: &UdpSocket, device: &SsdpDevice, nt: &str) {
-        let usn = if nt.starts_with("uuid:") {
-            format!("{}", nt)
-        } else {
-            format!("uuid:{}::{}", device.uuid, nt)
-        };
+    /// Envoie un NOTIFY byebye au groupe multicast `group`
+    fn send_byebye(
+        socket: &dyn SsdpTransport,
+        group: SocketAddr,
+        boot_id: u32,
+        device: &SsdpDevice,
+        target: &NotificationType,
+    ) {
+        let NotificationType { nt, usn } = target;
 
         let msg = format!(
             "NOTIFY * HTTP/1.1\r\n\
-             HOST: {}:{}\r\n\
+             HOST: {}\r\n\
              NT: {}\r\n\
              NTS: ssdp:byebye\r\n\
              USN: {}\r\n\
+             BOOTID.UPNP.ORG: {}\r\n\
+             CONFIGID.UPNP.ORG: {}\r\n\
              \r\n",
-            SSDP_MULTICAST_ADDR, SSDP_PORT, nt, usn
+            group, nt, usn, boot_id, device.config_id
         );
 
-        let addr: SocketAddr = format!("{}:{}", SSDP_MULTICAST_ADDR, SSDP_PORT)
-            .parse()
-            .unwrap();
-
-        match socket.send_to(msg.as_bytes(), addr) {
+        match socket.send_to(msg.as_bytes(), group) {
             Ok(_) => {
                 info!("👋 NOTIFY byebye: {} (NT={})", usn, nt);
-                debug!(
-                    "📣 NOTIFY byebye payload\n<details>\n\n```\n{}\n```\n</details>\n",
-                    msg
-                );
+                crate::trace_payload!(msg, "📣 NOTIFY byebye payload");
             }
             Err(e) => warn!("❌ Failed to send NOTIFY byebye for {}: {}", usn, e),
         }
     }
 
-    /// Démarre les annonces périodiques (toutes les MAX_AGE/2 secondes)
-    fn start_periodic_announcements(&self, socket: Arc<UdpSocket>) {
-        let devices = Arc::clone(&self.devices);
-        let period = Duration::from_secs((MAX_AGE / 2) as u64);
+    /// Envoie un NOTIFY update annonçant le passage du BOOTID `current` à
+    /// `next`
+    fn send_update(
+        &self,
+        socket: &dyn SsdpTransport,
+        device: &SsdpDevice,
+        target: &NotificationType,
+        current: u32,
+        next: u32,
+    ) {
+        let group = self.binder.multicast_group();
+        let NotificationType { nt, usn } = target;
+
+        let msg = format!(
+            "NOTIFY * HTTP/1.1\r\n\
+             HOST: {}\r\n\
+             LOCATION: {}\r\n\
+             NT: {}\r\n\
+             NTS: ssdp:update\r\n\
+             USN: {}\r\n\
+             BOOTID.UPNP.ORG: {}\r\n\
+             CONFIGID.UPNP.ORG: {}\r\n\
+             NEXTBOOTID.UPNP.ORG: {}\r\n\
+             \r\n",
+            group, device.location, nt, usn, current, device.config_id, next
+        );
+
+        match socket.send_to(msg.as_bytes(), group) {
+            Ok(_) => {
+                info!("🔢 NOTIFY update: {} (NT={}, NEXTBOOTID={})", usn, nt, next);
+                crate::trace_payload!(msg, "📣 NOTIFY update payload");
+            }
+            Err(e) => warn!("❌ Failed to send NOTIFY update for {}: {}", usn, e),
+        }
+    }
+
+    /// Démarre les annonces périodiques (toutes les max-age/2 secondes par
+    /// défaut, avec gigue)
+    fn start_periodic_announcements(&self) {
+        let Handles {
+            devices,
+            socket: shared,
+            settings,
+            boot_id,
+            binder,
+            clock,
+        } = self.handles();
 
         std::thread::spawn(move || {
             loop {
-                debug!("⏰ SSDP periodic announcement tick");
-                std::thread::sleep(period);
+                let delay = settings.next_announce_delay();
+                debug!("⏰ SSDP periodic announcement in {:?}", delay);
+                clock.sleep(delay);
 
-                // Clone la liste des devices pour libérer le lock rapidement
-                let devices_snapshot: Vec<SsdpDevice> = {
-                    let devices = devices.read().unwrap();
-                    devices.values().cloned().collect()
+                let Some(socket) = shared.get() else {
+                    continue;
                 };
-                for device in &devices_snapshot {
-                    for nt in device.get_notification_types() {
-                        Self::send_alive(&socket, device, nt, true);
+
+                // Chaque série est répétée (UDP non fiable) ; la liste des
+                // devices est relue à chaque fois pour ne pas annoncer un
+                // device retiré entre-temps
+                let group = binder.multicast_group();
+                for delay in settings.repeat_delays() {
+                    clock.sleep(delay);
+                    // Clone la liste des devices pour libérer le lock rapidement
+                    let devices_snapshot: Vec<SsdpDevice> = {
+                        let devices = devices.read().unwrap();
+                        devices.values().cloned().collect()
+                    };
+                    let boot_id = boot_id.load(Ordering::Relaxed);
+                    for device in &devices_snapshot {
+                        for nt in device.get_notification_types() {
+                            Self::send_alive(&*socket, &settings, group, boot_id, device, nt, true);
+                        }
                     }
                 }
             }
@@ -265,44 +511,171 @@ impl SsdpServer {
     }
 
     /// Démarre l'écoute des M-SEARCH
-    fn start_msearch_listener(&self, socket: Arc<UdpSocket>) {
-        let devices = Arc::clone(&self.devices);
+    ///
+    /// Le listener surveille aussi le socket : après
+    /// [`MAX_CONSECUTIVE_ERRORS`] erreurs de lecture consécutives, ou si
+    /// l'adresse IP locale change (veille, changement de réseau), il recrée
+    /// le socket avec [`Self::recover`]. Il en va de même après une sortie
+    /// de veille signalée par [`Self::handle_resume`].
+    fn start_msearch_listener(&self, local_ip: IpAddr) {
+        let handles = self.handles();
+        let health = Arc::clone(&self.health);
+        let resume_pending = Arc::clone(&self.resume_pending);
 
         std::thread::spawn(move || {
+            let Handles {
+                socket: shared,
+                settings,
+                binder,
+                clock,
+                ..
+            } = handles.clone();
             let mut buf = [0u8; 8192];
+            let mut limiter = MsearchLimiter::new(settings.msearch_rate, settings.msearch_burst);
+            let mut bound_ip = local_ip;
+            let mut consecutive_errors = 0u32;
+            let mut last_ip_check = clock.now();
             loop {
+                let Some(socket) = shared.get() else {
+                    clock.sleep(Duration::from_secs(1));
+                    continue;
+                };
+                let mut failure = None;
                 match socket.recv_from(&mut buf) {
                     Ok((n, src)) => {
+                        consecutive_errors = 0;
                         let data = String::from_utf8_lossy(&buf[..n]);
-                        if data.starts_with("M-SEARCH") {
-                            debug!(
-                                "🔍 M-SEARCH received from {}\n<details>\n\n```\n{}\n```\n</details>\n",
-                                src, data
-                            );
+                        if data.starts_with("M-SEARCH")
+                            && Self::admit_msearch(&settings, &mut limiter, &src, clock.now())
+                        {
+                            debug!("🔍 M-SEARCH received from {}", src);
+                            crate::trace_payload!(data, "🔍 M-SEARCH received from {}", src);
                             if let Some(st) = Self::parse_st(&data) {
-                                // Clone la liste des devices pour libérer le lock rapidement
-                                let devices_snapshot: Vec<SsdpDevice> = {
-                                    let devices = devices.read().unwrap();
-                                    devices.values().cloned().collect()
-                                };
-                                for device in &devices_snapshot {
-                                    Self::handle_msearch(&socket, &src, &st, device);
+                                let delay = settings.response_delay(Self::parse_mx(&data));
+                                if delay.is_zero() {
+                                    Self::answer_msearch(&handles, &*socket, &src, &st);
+                                } else {
+                                    Self::spawn_delayed_answer(handles.clone(), delay, src, st);
                                 }
                             }
                         }
                     }
-                    Err(e) if e.kind() == std::io::ErrorKind::WouldBlock => {
+                    Err(e)
+                        if matches!(
+                            e.kind(),
+                            std::io::ErrorKind::WouldBlock | std::io::ErrorKind::TimedOut
+                        ) =>
+                    {
                         // Timeout, continuer
-                        continue;
                     }
                     Err(e) => {
                         warn!("❌ SSDP read error: {}", e);
+                        health.write().unwrap().record_error(&e);
+                        consecutive_errors += 1;
+                        if consecutive_errors >= MAX_CONSECUTIVE_ERRORS {
+                            failure = Some(format!(
+                                "{} consecutive read errors: {}",
+                                consecutive_errors, e
+                            ));
+                        } else {
+                            clock.sleep(Duration::from_millis(100));
+                        }
                     }
                 }
+                drop(socket);
+
+                if failure.is_none() && resume_pending.swap(false, Ordering::Relaxed) {
+                    failure = Some("system resumed from sleep".to_string());
+                }
+
+                if failure.is_none()
+                    && clock.now().duration_since(last_ip_check) >= IP_CHECK_INTERVAL
+                {
+                    last_ip_check = clock.now();
+                    let ip = binder.local_ip();
+                    if ip != bound_ip {
+                        failure =
+                            Some(format!("local address changed from {} to {}", bound_ip, ip));
+                    }
+                }
+
+                if let Some(reason) = failure {
+                    bound_ip = Self::recover(&handles, &health, &reason);
+                    consecutive_errors = 0;
+                    last_ip_check = clock.now();
+                }
             }
         });
     }
 
+    /// Le M-SEARCH reçu de `src` doit-il être traité ?
+    ///
+    /// Les sources refusées sont ignorées sans bruit ; pour les sources
+    /// limitées, seuls le début et la fin de la rafale sont journalisés.
+    fn admit_msearch(
+        settings: &SsdpSettings,
+        limiter: &mut MsearchLimiter,
+        src: &SocketAddr,
+        now: Instant,
+    ) -> bool {
+        if !settings.accepts_source(src.ip()) {
+            return false;
+        }
+        match limiter.admit(src.ip(), now) {
+            Admission::Accepted { dropped } => {
+                if dropped > 0 {
+                    info!(
+                        "🔍 M-SEARCH from {} no longer rate limited ({} dropped)",
+                        src.ip(),
+                        dropped
+                    );
+                }
+                true
+            }
+            Admission::Dropped { first } => {
+                if first {
+                    warn!("⚠️ M-SEARCH flood from {}, rate limiting", src.ip());
+                }
+                false
+            }
+        }
+    }
+
+    /// Recrée le socket jusqu'au succès (backoff exponentiel de 1 s à 60 s),
+    /// puis réannonce tous les devices
+    ///
+    /// Renvoie l'adresse de la nouvelle interface de sortie.
+    fn recover(handles: &Handles, health: &RwLock<SsdpHealth>, reason: &str) -> IpAddr {
+        warn!("⚠️ SSDP socket unusable ({}), re-creating it", reason);
+        health.write().unwrap().mark_recovering(reason);
+
+        let mut backoff = Backoff::default();
+        loop {
+            handles.clock.sleep(backoff.next_delay());
+            match handles.binder.open(&handles.settings) {
+                Ok((socket, local_ip)) => {
+                    handles.socket.replace(socket);
+                    health.write().unwrap().mark_recovered();
+                    info!(
+                        "✅ SSDP socket re-created on {}, re-announcing devices",
+                        local_ip
+                    );
+
+                    let snapshot: Vec<SsdpDevice> =
+                        handles.devices.read().unwrap().values().cloned().collect();
+                    for device in snapshot {
+                        Self::alive_burst(handles.clone(), device);
+                    }
+                    return local_ip;
+                }
+                Err(e) => {
+                    warn!("❌ SSDP socket re-creation failed: {}", e);
+                    health.write().unwrap().mark_attempt_failed(e);
+                }
+            }
+        }
+    }
+
     /// Parse le champ ST d'un M-SEARCH
     fn parse_st(data: &str) -> Option<String> {
         for line in data.lines() {
@@ -315,26 +688,60 @@ impl SsdpServer {
         None
     }
 
-    /// Répond à un M-SEARCH
-    fn handle_msearch(socket: &UdpSocket, src: &SocketAddr, st: &str, device: &SsdpDevice) {
-        let mut nts = Vec::new();
+    /// Parse le champ MX (délai de réponse maximal, en secondes) d'un M-SEARCH
+    fn parse_mx(data: &str) -> Option<u32> {
+        data.lines()
+            .find(|line| line.to_uppercase().starts_with("MX:"))
+            .and_then(|line| line[3..].trim().parse().ok())
+    }
 
-        if st == "ssdp:all" {
-            nts.extend(device.get_notification_types().iter().cloned());
-        } else if device.get_notification_types().contains(&st.to_string()) {
-            nts.push(st.to_string());
-        } else {
-            return; // Pas de match
+    /// Répond à un M-SEARCH pour tous les devices concernés
+    fn answer_msearch(handles: &Handles, socket: &dyn SsdpTransport, src: &SocketAddr, st: &str) {
+        // Clone la liste des devices pour libérer le lock rapidement
+        let devices_snapshot: Vec<SsdpDevice> = {
+            let devices = handles.devices.read().unwrap();
+            devices.values().cloned().collect()
+        };
+        let boot_id = handles.boot_id.load(Ordering::Relaxed);
+        let date = handles.clock.utc_now();
+        for device in &devices_snapshot {
+            Self::handle_msearch(socket, &handles.settings, boot_id, date, src, st, device);
         }
+    }
 
-        for nt in nts {
-            let usn = if nt.starts_with("uuid:") {
-                format!("{}", nt)
-            } else {
-                format!("uuid:{}::{}", device.uuid, nt)
-            };
+    /// Répond à un M-SEARCH après `delay`, sans bloquer le listener
+    ///
+    /// Les devices et le socket sont relus au moment de l'envoi : un device
+    /// retiré entre-temps n'est pas annoncé.
+    fn spawn_delayed_answer(handles: Handles, delay: Duration, src: SocketAddr, st: String) {
+        debug!("⏳ M-SEARCH response to {} delayed by {:?}", src, delay);
+        std::thread::spawn(move || {
+            handles.clock.sleep(delay);
+            if let Some(socket) = handles.socket.get() {
+                Self::answer_msearch(&handles, &*socket, &src, &st);
+            }
+        });
+    }
 
-            let date = chrono::Utc::now().format("%a, %d %b %Y %H:%M:%S GMT");
+    /// Répond à un M-SEARCH pour un device
+    fn handle_msearch(
+        socket: &dyn SsdpTransport,
+        settings: &SsdpSettings,
+        boot_id: u32,
+        date: chrono::DateTime<chrono::Utc>,
+        src: &SocketAddr,
+        st: &str,
+        device: &SsdpDevice,
+    ) {
+        // Un même type (service présent dans plusieurs sous-devices) donne
+        // une réponse par USN
+        let targets = device
+            .get_notification_types()
+            .iter()
+            .filter(|target| st == "ssdp:all" || target.nt == st);
+
+        for NotificationType { nt, usn } in targets {
+            let date = date.format("%a, %d %b %Y %H:%M:%S GMT");
 
             let resp = format!(
                 "HTTP/1.1 200 OK\r\n\
@@ -345,15 +752,22 @@ impl SsdpServer {
                  SERVER: {}\r\n\
                  ST: {}\r\n\
                  USN: {}\r\n\
+                 BOOTID.UPNP.ORG: {}\r\n\
+                 CONFIGID.UPNP.ORG: {}\r\n\
                  \r\n",
-                MAX_AGE, date, device.location, device.server, nt, usn
+                settings.max_age,
+                date,
+                device.location,
+                settings.server_header(&device.server),
+                nt,
+                usn,
+                boot_id,
+                device.config_id
             );
-            match socket.send_to(resp.as_bytes(), src) {
+            match socket.send_to(resp.as_bytes(), *src) {
                 Ok(_) => {
-                    debug!(
-                        "📡 M-SEARCH response sent to {} with ST={}\n\n### payload\n\n<details>\n\n```\n{}\n```\n</details>\n",
-                        src, nt, resp
-                    );
+                    debug!("📡 M-SEARCH response sent to {} with ST={}", src, nt);
+                    crate::trace_payload!(resp, "📡 M-SEARCH response sent to {} with ST={}", src, nt);
                 }
                 Err(e) => warn!("❌ Failed to send M-SEARCH response to {}: {}", src, e),
             }
@@ -361,6 +775,32 @@ impl SsdpServer {
     }
 }
 
+impl SsdpAnnouncer for SsdpServer {
+    fn add_device(&self, device: SsdpDevice) {
+        SsdpServer::add_device(self, device)
+    }
+
+    fn remove_device(&self, uuid: &str) {
+        SsdpServer::remove_device(self, uuid)
+    }
+
+    fn is_running(&self) -> bool {
+        SsdpServer::is_running(self)
+    }
+
+    fn device_count(&self) -> usize {
+        SsdpServer::device_count(self)
+    }
+
+    fn health(&self) -> SsdpHealth {
+        SsdpServer::health(self)
+    }
+
+    fn handle_resume(&self) {
+        SsdpServer::handle_resume(self)
+    }
+}
+
 impl Default for SsdpServer {
     fn default() -> Self {
         Self::new()
@@ -369,15 +809,118 @@ impl Default for SsdpServer {
 
 impl Drop for SsdpServer {
     fn drop(&mut self) {
-        // Envoyer byebye pour tous les devices
-        if let Some(ref socket) = self.socket {
+        // Vider la table d'abord : les rafales et annonces périodiques en
+        // cours s'arrêtent et aucun alive ne suit les byebye. Toutes les
+        // séries sont émises ici, avant la fermeture du socket.
+        let devices: Vec<SsdpDevice> = std::mem::take(&mut *self.devices.write().unwrap())
+            .into_values()
+            .collect();
+        if self.socket.is_open() && !devices.is_empty() {
             info!("✅ Shutting down SSDP server, sending byebye for all devices");
-            let devices = self.devices.read().unwrap();
-            for device in devices.values() {
-                for nt in device.get_notification_types() {
-                    self.send_byebye(socket, device, nt);
-                }
+            let delays = self.settings.repeat_delays();
+            Self::byebye_rounds(&self.handles(), &devices, &delays);
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::clock::FakeClock;
+    use crate::ssdp::MemoryNetwork;
+    use std::net::Ipv4Addr;
+
+    /// Fait avancer l'horloge par pas de 10 ms jusqu'à `expected` émissions
+    fn run_until_sent(clock: &FakeClock, network: &MemoryNetwork, expected: usize) {
+        for _ in 0..500 {
+            if network.wait_sent(expected, Duration::ZERO) {
+                return;
             }
+            clock.wait_for_sleepers(2, Duration::from_secs(1));
+            clock.advance(Duration::from_millis(10));
         }
+        panic!("only {} datagrams sent", network.sent().len());
+    }
+
+    #[test]
+    fn test_server_in_memory() {
+        let network = MemoryNetwork::new(Ipv4Addr::new(192, 168, 1, 10));
+        let clock = Arc::new(FakeClock::new());
+        let settings = SsdpSettings {
+            announce_interval: Some(Duration::from_secs(60)),
+            jitter: 0.0,
+            initial_burst: 2,
+            notify_repeat: 2,
+            ..Default::default()
+        };
+        let mut server =
+            SsdpServer::with_transport(settings, Arc::new(network.clone()), clock.clone());
+        server.start().unwrap();
+        server.add_device(SsdpDevice::new(
+            "abc".into(),
+            "urn:schemas-upnp-org:device:MediaRenderer:1".into(),
+            "http://192.168.1.10:8080/description.xml".into(),
+            "Linux UPnP/1.1 Test/1.0".into(),
+        ));
+
+        // Rafale initiale : 2 × 3 NT
+        run_until_sent(&clock, &network, 6);
+        let multicast: SocketAddr = "239.255.255.250:1900".parse().unwrap();
+        let boot_id = format!("BOOTID.UPNP.ORG: {}", server.boot_id());
+        for (target, msg) in network.take_sent() {
+            assert_eq!(target, multicast);
+            assert!(msg.contains("NTS: ssdp:alive"));
+            assert!(msg.contains(&boot_id));
+            assert!(msg.contains("CONFIGID.UPNP.ORG: 0"));
+        }
+
+        // M-SEARCH : réponse unicast datée par l'horloge
+        let searcher: SocketAddr = "192.168.1.50:50000".parse().unwrap();
+        network.inject(
+            b"M-SEARCH * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\n\
+              MAN: \"ssdp:discover\"\r\nMX: 1\r\nST: upnp:rootdevice\r\n\r\n",
+            searcher,
+        );
+        run_until_sent(&clock, &network, 1);
+        let (target, response) = network.take_sent().remove(0);
+        assert_eq!(target, searcher);
+        assert!(response.contains("USN: uuid:abc::upnp:rootdevice"));
+        let date = clock.utc_now().format("%a, %d %b %Y");
+        assert!(response.contains(&format!("DATE: {}", date)));
+
+        // Changement d'adresse : recréation du transport puis réannonce
+        network.set_local_ip(Ipv4Addr::new(10, 0, 0, 10));
+        clock.advance(IP_CHECK_INTERVAL);
+        run_until_sent(&clock, &network, 6);
+        assert_eq!(network.open_count(), 2);
+        assert_eq!(server.health().recoveries, 1);
+        network.take_sent();
+
+        // Sortie de veille : ssdp:update vers le BOOTID suivant, puis réannonce
+        let boot_id = server.boot_id();
+        server.handle_resume();
+        let next = format!("NEXTBOOTID.UPNP.ORG: {}", boot_id + 1);
+        let updates = network.take_sent();
+        assert_eq!(updates.len(), 3);
+        assert!(
+            updates
+                .iter()
+                .all(|(_, msg)| msg.contains("NTS: ssdp:update") && msg.contains(&next))
+        );
+        assert_eq!(server.boot_id(), boot_id + 1);
+        run_until_sent(&clock, &network, 6);
+        network.take_sent();
+
+        // Arrêt : deux séries de byebye, émises avant que drop ne rende la main
+        let shutdown = std::thread::spawn(move || drop(server));
+        run_until_sent(&clock, &network, 6);
+        shutdown.join().unwrap();
+        let byebye = network.sent();
+        assert_eq!(byebye.len(), 6);
+        assert!(
+            byebye
+                .iter()
+                .all(|(_, msg)| msg.contains("NTS: ssdp:byebye"))
+        );
     }
 }
@@ -0,0 +1,416 @@
+//! Paramètres d'annonce SSDP
+//!
+//! ```yaml
+//! host:
+//!   ssdp:
+//!     max_age: 1800          # CACHE-CONTROL des NOTIFY et réponses M-SEARCH
+//!     announce_interval: ""  # vide : max_age / 2 ; sinon "10m", "300"...
+//!     jitter: 0.1            # ±10 % sur l'intervalle des alive périodiques
+//!     multicast_ttl: 2       # TTL IP des paquets multicast (UDA : 2 par défaut)
+//!     server: ""             # vide : en-tête SERVER de chaque device
+//!     initial_burst: 3       # répétitions des alive à l'ajout d'un device
+//!     notify_repeat: 2       # répétitions des alive périodiques et des byebye
+//!     burst_spacing: 200ms   # écart moyen entre deux répétitions (100 à 500 ms)
+//!     max_response_delay: 5s # plafond du délai des réponses M-SEARCH (MX)
+//!     interfaces: []         # [] : interface du système ; [all] ; [eth0, 10.8.0.2]
+//!     msearch_rate: 5        # M-SEARCH traités par seconde et par source (0 : illimité)
+//!     msearch_burst: 20      # M-SEARCH acceptés d'affilée avant la limite
+//!     allow: []              # [] : toutes sources ; sinon [192.168.1.0/24, ...]
+//!     deny: []               # sources ignorées, prioritaire sur allow
+//!   ipv6: false              # annonces et écoute aussi sur [FF02::C]:1900
+//! ```
+//!
+//! La gigue évite que plusieurs instances pmomusic démarrées ensemble
+//! n'annoncent en rafale au même instant, indéfiniment. Un
+//! `announce_interval` trop long pour `max_age` est ramené pour que la
+//! série suivante parte, gigue comprise, avant 90 % de `max_age` : les
+//! points de contrôle n'oublient jamais un device entre deux annonces.
+//!
+//! UDP n'étant pas fiable, UDA recommande d'envoyer plusieurs fois chaque
+//! alive initial : après un délai aléatoire de moins de 100 ms, la série
+//! complète des NT est émise `initial_burst` fois, séparées d'un écart
+//! aléatoire entre la moitié et une fois et demie `burst_spacing`, ramené
+//! entre 100 et 500 ms. Les alive périodiques et les byebye sont répétés de
+//! la même façon `notify_repeat` fois ; à l'arrêt du serveur, tous les
+//! byebye sont émis avant la fermeture du socket.
+//!
+//! Les réponses à un M-SEARCH multicast sont étalées sur un délai aléatoire
+//! entre 0 et `MX` secondes, comme l'exige UDA, pour éviter qu'un device
+//! ne réponde en rafale à plusieurs points de contrôle qui cherchent en même
+//! temps. `max_response_delay` borne ce délai quel que soit le `MX` demandé.
+//!
+//! Un M-SEARCH n'est traité que si sa source n'est pas dans `deny` et, si
+//! `allow` n'est pas vide, y figure (notation CIDR ou adresse seule). Chaque
+//! source dispose en outre d'un seau à jetons (voir [`super::filter`]) :
+//! un point de contrôle qui inonde le réseau de M-SEARCH ne déclenche pas
+//! plus de `msearch_rate` réponses par seconde.
+//!
+//! Par défaut, un seul socket écoute et annonce sur l'interface choisie par
+//! le système. Sur une machine multi-réseaux, `interfaces` lance un serveur
+//! par interface (voir [`super::MultiInterfaceServer`]) : `all` retient
+//! toutes les interfaces actives compatibles multicast, sinon la liste
+//! donne des noms d'interface ou des adresses IPv4.
+//!
+//! `host.ipv6`, qui met aussi le serveur HTTP en double pile, ajoute sur
+//! chaque interface servie un serveur SSDP IPv6 (groupe `FF02::C`) dont la
+//! LOCATION désigne l'adresse IPv6 de l'interface.
+
+use std::net::{IpAddr, Ipv4Addr};
+use std::time::Duration;
+
+use tracing::warn;
+
+use super::MAX_AGE;
+use super::filter::IpNet;
+use crate::config_ext::UpnpConfigExt;
+
+/// TTL multicast recommandé par UDA 1.1
+pub const DEFAULT_MULTICAST_TTL: u32 = 2;
+
+/// Gigue par défaut des annonces périodiques (fraction de l'intervalle)
+pub const DEFAULT_ANNOUNCE_JITTER: f64 = 0.1;
+
+/// Nombre d'émissions des alive initiaux
+pub const DEFAULT_INITIAL_BURST: u32 = 3;
+
+/// Écart moyen entre deux émissions des alive initiaux
+pub const DEFAULT_BURST_SPACING: Duration = Duration::from_millis(200);
+
+/// Nombre d'émissions des alive périodiques et des byebye
+pub const DEFAULT_NOTIFY_REPEAT: u32 = 2;
+
+/// Plafond par défaut du délai de réponse aux M-SEARCH (UDA : MX ≤ 5)
+pub const DEFAULT_MAX_RESPONSE_DELAY: Duration = Duration::from_secs(5);
+
+/// M-SEARCH traités par seconde et par source
+pub const DEFAULT_MSEARCH_RATE: f64 = 5.0;
+
+/// M-SEARCH acceptés d'affilée d'une même source
+pub const DEFAULT_MSEARCH_BURST: u32 = 20;
+
+/// Part de `max_age` que l'intervalle des annonces, gigue comprise, ne
+/// dépasse jamais
+const MAX_ANNOUNCE_FRACTION: f64 = 0.9;
+
+/// Délai aléatoire maximal avant la première émission (UDA 1.1)
+const MAX_INITIAL_DELAY: Duration = Duration::from_millis(100);
+
+/// Écart minimal entre deux émissions d'une même série de NOTIFY
+const MIN_REPEAT_SPACING: Duration = Duration::from_millis(100);
+
+/// Écart maximal entre deux émissions d'une même série de NOTIFY
+const MAX_REPEAT_SPACING: Duration = Duration::from_millis(500);
+
+/// Paramètres d'un [`super::SsdpServer`]
+#[derive(Debug, Clone, PartialEq)]
+pub struct SsdpSettings {
+    /// Durée de validité des annonces (secondes)
+    pub max_age: u32,
+    /// Intervalle des alive périodiques (`None` : `max_age / 2`)
+    pub announce_interval: Option<Duration>,
+    /// Gigue relative appliquée à chaque intervalle, entre 0 et 0.5
+    pub jitter: f64,
+    /// TTL IP des paquets multicast
+    pub multicast_ttl: u32,
+    /// En-tête SERVER imposé à tous les devices (`None` : celui du device)
+    pub server: Option<String>,
+    /// Nombre d'émissions des alive initiaux (au moins 1)
+    pub initial_burst: u32,
+    /// Nombre d'émissions des alive périodiques et des byebye (au moins 1)
+    pub notify_repeat: u32,
+    /// Écart moyen entre deux émissions d'une même série de NOTIFY
+    pub burst_spacing: Duration,
+    /// Plafond du délai aléatoire des réponses M-SEARCH
+    pub max_response_delay: Duration,
+    /// Interfaces servies : vide pour l'interface du système, `all` pour
+    /// toutes, sinon noms d'interface ou adresses IPv4
+    pub interfaces: Vec<String>,
+    /// Écoute et annonces aussi en IPv6 (`host.ipv6`)
+    pub ipv6: bool,
+    /// M-SEARCH traités par seconde et par source (0 : pas de limite)
+    pub msearch_rate: f64,
+    /// M-SEARCH acceptés d'affilée d'une même source
+    pub msearch_burst: u32,
+    /// Sources autorisées (vide : toutes)
+    pub allow: Vec<IpNet>,
+    /// Sources ignorées, même si elles figurent dans `allow`
+    pub deny: Vec<IpNet>,
+}
+
+impl Default for SsdpSettings {
+    fn default() -> Self {
+        Self {
+            max_age: MAX_AGE,
+            announce_interval: None,
+            jitter: DEFAULT_ANNOUNCE_JITTER,
+            multicast_ttl: DEFAULT_MULTICAST_TTL,
+            server: None,
+            initial_burst: DEFAULT_INITIAL_BURST,
+            notify_repeat: DEFAULT_NOTIFY_REPEAT,
+            burst_spacing: DEFAULT_BURST_SPACING,
+            max_response_delay: DEFAULT_MAX_RESPONSE_DELAY,
+            interfaces: Vec::new(),
+            ipv6: false,
+            msearch_rate: DEFAULT_MSEARCH_RATE,
+            msearch_burst: DEFAULT_MSEARCH_BURST,
+            allow: Vec::new(),
+            deny: Vec::new(),
+        }
+    }
+}
+
+impl SsdpSettings {
+    /// Paramètres de `host.ssdp`, valeurs par défaut en cas d'erreur
+    pub fn from_config() -> Self {
+        let settings = pmoconfig::get_config()
+            .get_ssdp_settings()
+            .unwrap_or_else(|e| {
+                warn!("Invalid host.ssdp configuration: {}, using defaults", e);
+                Self::default()
+            });
+        if let Some(interval) = settings.announce_interval {
+            if interval > settings.max_announce_period() {
+                warn!(
+                    "⚠️ host.ssdp.announce_interval {:?} too long for max_age {} s, using {:?}",
+                    interval,
+                    settings.max_age,
+                    settings.announce_period()
+                );
+            }
+        }
+        settings
+    }
+
+    /// Intervalle nominal entre deux séries d'alive périodiques
+    ///
+    /// Borné pour que l'intervalle le plus long, gigue comprise, reste sous
+    /// 90 % de `max_age`.
+    pub fn announce_period(&self) -> Duration {
+        self.announce_interval
+            .unwrap_or_else(|| Duration::from_secs(u64::from(self.max_age / 2)))
+            .min(self.max_announce_period())
+            .max(Duration::from_secs(1))
+    }
+
+    /// Plus long intervalle nominal compatible avec `max_age` et la gigue
+    fn max_announce_period(&self) -> Duration {
+        let jitter = self.jitter.clamp(0.0, 0.5);
+        Duration::from_secs(u64::from(self.max_age)).mul_f64(MAX_ANNOUNCE_FRACTION / (1.0 + jitter))
+    }
+
+    /// Attente avant la prochaine série, gigue incluse
+    pub fn next_announce_delay(&self) -> Duration {
+        let jitter = self.jitter.clamp(0.0, 0.5);
+        let factor = if jitter > 0.0 {
+            1.0 + rand::random_range(-jitter..=jitter)
+        } else {
+            1.0
+        };
+        self.announce_period().mul_f64(factor)
+    }
+
+    /// Délais avant chacune des émissions des alive initiaux
+    ///
+    /// Le premier est inférieur à 100 ms, les suivants donnés par
+    /// [`repeat_spacing`](Self::repeat_spacing).
+    pub fn burst_delays(&self) -> Vec<Duration> {
+        let mut delays = Vec::with_capacity(self.initial_burst.max(1) as usize);
+        delays.push(MAX_INITIAL_DELAY.mul_f64(rand::random_range(0.0..1.0)));
+        for _ in 1..self.initial_burst {
+            delays.push(self.repeat_spacing());
+        }
+        delays
+    }
+
+    /// Délais avant chacune des émissions des alive périodiques et des
+    /// byebye
+    ///
+    /// La première part sans délai.
+    pub fn repeat_delays(&self) -> Vec<Duration> {
+        let mut delays = Vec::with_capacity(self.notify_repeat.max(1) as usize);
+        delays.push(Duration::ZERO);
+        for _ in 1..self.notify_repeat {
+            delays.push(self.repeat_spacing());
+        }
+        delays
+    }
+
+    /// Écart entre deux émissions d'une série : entre la moitié et une fois
+    /// et demie [`burst_spacing`](Self::burst_spacing), ramené entre 100 et
+    /// 500 ms
+    fn repeat_spacing(&self) -> Duration {
+        self.burst_spacing
+            .mul_f64(rand::random_range(0.5..=1.5))
+            .clamp(MIN_REPEAT_SPACING, MAX_REPEAT_SPACING)
+    }
+
+    /// Délai avant la réponse à un M-SEARCH d'en-tête `MX` (secondes)
+    ///
+    /// Tiré dans [0, MX], borné par
+    /// [`max_response_delay`](Self::max_response_delay). Sans `MX` (recherche
+    /// unicast UDA 1.1), la réponse est immédiate.
+    pub fn response_delay(&self, mx: Option<u32>) -> Duration {
+        let Some(mx) = mx else {
+            return Duration::ZERO;
+        };
+        let window = Duration::from_secs(u64::from(mx)).min(self.max_response_delay);
+        window.mul_f64(rand::random_range(0.0..=1.0))
+    }
+
+    /// Un serveur par interface plutôt qu'un socket unique ?
+    pub fn per_interface(&self) -> bool {
+        !self.interfaces.is_empty()
+    }
+
+    /// L'interface `name`, d'adresse `ip`, fait-elle partie de la sélection ?
+    pub fn selects_interface(&self, name: &str, ip: Ipv4Addr) -> bool {
+        self.interfaces
+            .iter()
+            .any(|item| item.eq_ignore_ascii_case("all") || item == name || item.parse() == Ok(ip))
+    }
+
+    /// Les M-SEARCH de `ip` sont-ils traités ?
+    pub fn accepts_source(&self, ip: IpAddr) -> bool {
+        !self.deny.iter().any(|net| net.contains(ip))
+            && (self.allow.is_empty() || self.allow.iter().any(|net| net.contains(ip)))
+    }
+
+    /// En-tête SERVER à annoncer pour un device
+    pub fn server_header<'a>(&'a self, device_server: &'a str) -> &'a str {
+        self.server.as_deref().unwrap_or(device_server)
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_announce_delay() {
+        let settings = SsdpSettings {
+            max_age: 100,
+            ..Default::default()
+        };
+        assert_eq!(settings.announce_period(), Duration::from_secs(50));
+        for _ in 0..100 {
+            let delay = settings.next_announce_delay();
+            assert!(delay >= Duration::from_secs(45) && delay <= Duration::from_secs(55));
+        }
+
+        let fixed = SsdpSettings {
+            announce_interval: Some(Duration::from_secs(30)),
+            jitter: 0.0,
+            server: Some("Custom/1.0".into()),
+            ..settings
+        };
+        assert_eq!(fixed.next_announce_delay(), Duration::from_secs(30));
+        assert_eq!(fixed.server_header("Linux UPnP/1.1"), "Custom/1.0");
+    }
+
+    #[test]
+    fn test_announce_period_within_max_age() {
+        let settings = SsdpSettings {
+            max_age: 110,
+            announce_interval: Some(Duration::from_secs(600)),
+            ..Default::default()
+        };
+        assert!((settings.announce_period().as_secs_f64() - 90.0).abs() < 1e-3);
+        for _ in 0..100 {
+            assert!(settings.next_announce_delay().as_secs_f64() < 99.001);
+        }
+
+        let fixed = SsdpSettings {
+            jitter: 0.0,
+            ..settings
+        };
+        assert!((fixed.announce_period().as_secs_f64() - 99.0).abs() < 1e-3);
+    }
+
+    #[test]
+    fn test_burst_delays() {
+        let settings = SsdpSettings::default();
+        let delays = settings.burst_delays();
+        assert_eq!(delays.len(), 3);
+        assert!(delays[0] < MAX_INITIAL_DELAY);
+        for delay in &delays[1..] {
+            assert!(*delay >= Duration::from_millis(99) && *delay <= Duration::from_millis(301));
+        }
+
+        let single = SsdpSettings {
+            initial_burst: 0,
+            ..settings
+        };
+        assert_eq!(single.burst_delays().len(), 1);
+    }
+
+    #[test]
+    fn test_repeat_delays() {
+        let settings = SsdpSettings {
+            notify_repeat: 3,
+            burst_spacing: Duration::from_secs(2),
+            ..Default::default()
+        };
+        let delays = settings.repeat_delays();
+        assert_eq!(delays.len(), 3);
+        assert_eq!(delays[0], Duration::ZERO);
+        assert!(delays[1..].iter().all(|d| *d == MAX_REPEAT_SPACING));
+
+        let tight = SsdpSettings {
+            notify_repeat: 0,
+            burst_spacing: Duration::ZERO,
+            ..settings
+        };
+        assert_eq!(tight.repeat_delays(), vec![Duration::ZERO]);
+        assert_eq!(tight.repeat_spacing(), MIN_REPEAT_SPACING);
+    }
+
+    #[test]
+    fn test_response_delay() {
+        let settings = SsdpSettings {
+            max_response_delay: Duration::from_secs(2),
+            ..Default::default()
+        };
+        assert_eq!(settings.response_delay(None), Duration::ZERO);
+        assert_eq!(settings.response_delay(Some(0)), Duration::ZERO);
+        for _ in 0..100 {
+            assert!(settings.response_delay(Some(1)) <= Duration::from_secs(1));
+            assert!(settings.response_delay(Some(120)) <= Duration::from_secs(2));
+        }
+    }
+
+    #[test]
+    fn test_accepts_source() {
+        let ip = |s: &str| s.parse::<IpAddr>().unwrap();
+        assert!(SsdpSettings::default().accepts_source(ip("203.0.113.7")));
+
+        let settings = SsdpSettings {
+            allow: vec!["192.168.1.0/24".parse().unwrap()],
+            deny: vec!["192.168.1.66".parse().unwrap()],
+            ..Default::default()
+        };
+        assert!(settings.accepts_source(ip("192.168.1.20")));
+        assert!(!settings.accepts_source(ip("192.168.1.66")));
+        assert!(!settings.accepts_source(ip("10.0.0.1")));
+    }
+
+    #[test]
+    fn test_interface_selection() {
+        let ip = Ipv4Addr::new(10, 8, 0, 2);
+        assert!(!SsdpSettings::default().per_interface());
+
+        let all = SsdpSettings {
+            interfaces: vec!["ALL".into()],
+            ..Default::default()
+        };
+        assert!(all.per_interface());
+        assert!(all.selects_interface("tun0", ip));
+
+        let some = SsdpSettings {
+            interfaces: vec!["eth0".into(), "10.8.0.2".into()],
+            ..Default::default()
+        };
+        assert!(some.selects_interface("eth0", Ipv4Addr::new(192, 168, 1, 10)));
+        assert!(some.selects_interface("tun0", ip));
+        assert!(!some.selects_interface("wlan0", Ipv4Addr::new(192, 168, 2, 10)));
+    }
+}
@@ -0,0 +1,458 @@
+//! Transport UDP du serveur SSDP
+//!
+//! [`SsdpServer`](super::SsdpServer) n'accède au réseau qu'à travers
+//! [`SsdpTransport`] (émission et réception de datagrammes) et
+//! [`SsdpBinder`] (ouverture du transport, au démarrage et après une panne).
+//! [`MulticastBinder`] ouvre le socket multicast réel, [`InterfaceBinder`]
+//! un socket limité à une interface, [`Ipv6Binder`] un socket abonné à
+//! `FF02::C` ; [`MemoryNetwork`]
+//! simule le réseau en mémoire pour les tests : on y injecte des M-SEARCH et
+//! on relève les NOTIFY et réponses émis.
+
+use socket2::{Domain, Protocol, Socket, Type};
+use std::collections::VecDeque;
+use std::io;
+use std::net::{IpAddr, Ipv4Addr, Ipv6Addr, SocketAddr, SocketAddrV6, UdpSocket};
+use std::sync::{Arc, Condvar, Mutex};
+use std::time::Duration;
+use tracing::debug;
+
+use super::{SSDP_MULTICAST_ADDR, SSDP_MULTICAST_ADDR_V6, SSDP_PORT, SsdpSettings};
+
+/// Émission et réception de datagrammes SSDP
+pub trait SsdpTransport: Send + Sync {
+    /// Envoie un datagramme
+    fn send_to(&self, buf: &[u8], target: SocketAddr) -> io::Result<usize>;
+
+    /// Reçoit un datagramme
+    ///
+    /// Renvoie `WouldBlock` ou `TimedOut` si rien n'arrive pendant le délai
+    /// de lecture, pour que le listener puisse surveiller le transport.
+    fn recv_from(&self, buf: &mut [u8]) -> io::Result<(usize, SocketAddr)>;
+}
+
+impl SsdpTransport for UdpSocket {
+    fn send_to(&self, buf: &[u8], target: SocketAddr) -> io::Result<usize> {
+        UdpSocket::send_to(self, buf, target)
+    }
+
+    fn recv_from(&self, buf: &mut [u8]) -> io::Result<(usize, SocketAddr)> {
+        UdpSocket::recv_from(self, buf)
+    }
+}
+
+/// Ouverture du transport du serveur SSDP
+pub trait SsdpBinder: Send + Sync {
+    /// Ouvre un transport
+    ///
+    /// Renvoie aussi l'adresse de l'interface de sortie, surveillée par le
+    /// listener pour détecter un changement de réseau.
+    fn open(&self, settings: &SsdpSettings) -> io::Result<(Arc<dyn SsdpTransport>, IpAddr)>;
+
+    /// Adresse locale courante de l'interface de sortie
+    fn local_ip(&self) -> IpAddr;
+
+    /// Groupe multicast des NOTIFY (239.255.255.250:1900 par défaut)
+    fn multicast_group(&self) -> SocketAddr {
+        SocketAddr::new(IpAddr::V4(SSDP_MULTICAST_ADDR.parse().unwrap()), SSDP_PORT)
+    }
+}
+
+/// Socket UDP multicast sur 0.0.0.0:1900
+#[derive(Debug, Default, Clone, Copy)]
+pub struct MulticastBinder;
+
+impl MulticastBinder {
+    /// Adresse IPv4 principale de la machine
+    fn primary_ipv4(&self) -> Ipv4Addr {
+        pmoutils::guess_local_ip()
+            .parse()
+            .unwrap_or(Ipv4Addr::UNSPECIFIED)
+    }
+}
+
+impl SsdpBinder for MulticastBinder {
+    fn open(&self, settings: &SsdpSettings) -> io::Result<(Arc<dyn SsdpTransport>, IpAddr)> {
+        // Sur macOS, join_multicast_v4 peut positionner IP_MULTICAST_IF
+        // sur une interface bridge/VM. On remet explicitement l'interface
+        // de sortie sur l'IP principale.
+        let local_ip = self.primary_ipv4();
+        let socket = open_multicast_socket(settings, None, local_ip)?;
+        Ok((Arc::new(socket), IpAddr::V4(local_ip)))
+    }
+
+    fn local_ip(&self) -> IpAddr {
+        IpAddr::V4(self.primary_ipv4())
+    }
+}
+
+/// Socket multicast limité à une interface
+///
+/// Le groupe SSDP n'est rejoint que sur cette interface et les annonces en
+/// partent. Sous Linux, `IP_MULTICAST_ALL` est désactivé pour que le socket
+/// ne reçoive pas les M-SEARCH arrivés par les autres interfaces.
+#[derive(Debug, Clone)]
+pub struct InterfaceBinder {
+    name: String,
+    ip: Ipv4Addr,
+}
+
+impl InterfaceBinder {
+    /// Interface `name`, d'adresse `ip` au démarrage
+    pub fn new(name: impl Into<String>, ip: Ipv4Addr) -> Self {
+        Self {
+            name: name.into(),
+            ip,
+        }
+    }
+
+    /// Nom de l'interface
+    pub fn name(&self) -> &str {
+        &self.name
+    }
+}
+
+impl InterfaceBinder {
+    /// Adresse courante de l'interface (elle peut changer, DHCP), à défaut
+    /// celle du démarrage
+    fn current_ipv4(&self) -> Ipv4Addr {
+        super::multicast_interfaces()
+            .into_iter()
+            .find(|(name, _)| *name == self.name)
+            .map_or(self.ip, |(_, ip)| ip)
+    }
+}
+
+impl SsdpBinder for InterfaceBinder {
+    fn open(&self, settings: &SsdpSettings) -> io::Result<(Arc<dyn SsdpTransport>, IpAddr)> {
+        let ip = self.current_ipv4();
+        let socket = open_multicast_socket(settings, Some(ip), ip)?;
+        debug!(
+            "SSDP server: socket bound to interface {} ({})",
+            self.name, ip
+        );
+        Ok((Arc::new(socket), IpAddr::V4(ip)))
+    }
+
+    fn local_ip(&self) -> IpAddr {
+        IpAddr::V4(self.current_ipv4())
+    }
+}
+
+/// Socket multicast IPv6 abonné à `FF02::C` sur une interface
+///
+/// `FF02::C` a une portée lien : le groupe est rejoint sur une interface
+/// précise (par défaut celle qui porte l'adresse IPv4 principale), et les
+/// annonces en partent. L'adresse surveillée est l'adresse IPv6 de
+/// l'interface reprise dans la LOCATION, de préférence globale ou ULA.
+#[derive(Debug, Default, Clone)]
+pub struct Ipv6Binder {
+    name: Option<String>,
+}
+
+impl Ipv6Binder {
+    /// Interface portant l'adresse IPv4 principale
+    pub fn new() -> Self {
+        Self::default()
+    }
+
+    /// Interface `name`
+    pub fn on_interface(name: impl Into<String>) -> Self {
+        Self {
+            name: Some(name.into()),
+        }
+    }
+
+    /// Interface servie, résolue à chaque ouverture
+    pub fn interface(&self) -> Option<String> {
+        self.name.clone().or_else(|| {
+            let primary: Ipv4Addr = pmoutils::guess_local_ip().parse().ok()?;
+            super::multicast_interfaces()
+                .into_iter()
+                .find(|(_, ip)| *ip == primary)
+                .map(|(name, _)| name)
+        })
+    }
+}
+
+impl SsdpBinder for Ipv6Binder {
+    fn open(&self, settings: &SsdpSettings) -> io::Result<(Arc<dyn SsdpTransport>, IpAddr)> {
+        let Some(name) = self.interface() else {
+            return Err(io::Error::new(
+                io::ErrorKind::NotFound,
+                "no network interface for IPv6 SSDP",
+            ));
+        };
+        let Some(ip) = super::interface_ipv6(&name) else {
+            return Err(io::Error::new(
+                io::ErrorKind::AddrNotAvailable,
+                format!("interface {} has no IPv6 address", name),
+            ));
+        };
+        let index = super::interface_index(&name);
+        let socket = open_multicast_socket_v6(settings, index)?;
+        debug!(
+            "SSDP server: IPv6 socket bound to interface {} (index {}, {})",
+            name, index, ip
+        );
+        Ok((Arc::new(socket), IpAddr::V6(ip)))
+    }
+
+    fn local_ip(&self) -> IpAddr {
+        self.interface()
+            .and_then(|name| super::interface_ipv6(&name))
+            .map_or(IpAddr::V6(Ipv6Addr::UNSPECIFIED), IpAddr::V6)
+    }
+
+    fn multicast_group(&self) -> SocketAddr {
+        SocketAddr::V6(SocketAddrV6::new(
+            SSDP_MULTICAST_ADDR_V6.parse().unwrap(),
+            SSDP_PORT,
+            0,
+            0,
+        ))
+    }
+}
+
+/// Active `SO_REUSEPORT` (Unix) pour partager le port 1900 entre processus
+fn set_reuse_port(socket: &Socket) -> io::Result<()> {
+    #[cfg(unix)]
+    {
+        use std::os::unix::io::AsRawFd;
+        let fd = socket.as_raw_fd();
+        let optval: libc::c_int = 1;
+        unsafe {
+            let result = libc::setsockopt(
+                fd,
+                libc::SOL_SOCKET,
+                libc::SO_REUSEPORT,
+                &optval as *const _ as *const libc::c_void,
+                std::mem::size_of_val(&optval) as libc::socklen_t,
+            );
+            if result != 0 {
+                return Err(io::Error::last_os_error());
+            }
+        }
+        debug!("✅ SO_REUSEPORT enabled (Unix)");
+    }
+
+    #[cfg(windows)]
+    {
+        let _ = socket;
+        debug!("✅ SO_REUSEADDR enabled (Windows - SO_REUSEPORT not needed)");
+    }
+
+    Ok(())
+}
+
+/// Ouvre un socket UDP sur 0.0.0.0:1900 abonné au groupe SSDP
+///
+/// `interface` restreint l'abonnement à une interface (toutes si `None`),
+/// `outgoing` est l'interface de sortie des annonces.
+fn open_multicast_socket(
+    settings: &SsdpSettings,
+    interface: Option<Ipv4Addr>,
+    outgoing: Ipv4Addr,
+) -> io::Result<UdpSocket> {
+    // Créer le socket avec socket2 pour permettre la réutilisation du port
+    // Ceci est essentiel pour que plusieurs clients/serveurs UPnP puissent coexister
+    let socket2 = Socket::new(Domain::IPV4, Type::DGRAM, Some(Protocol::UDP))?;
+
+    // SO_REUSEADDR : permet à plusieurs sockets de bind sur le même port
+    // Essentiel sur toutes les plateformes pour le multicast
+    socket2.set_reuse_address(true)?;
+
+    // SO_REUSEPORT : nécessaire sur Unix (macOS/Linux/BSD) pour que plusieurs processus
+    // puissent recevoir du trafic multicast sur le même port.
+    // Windows n'a pas besoin de SO_REUSEPORT - SO_REUSEADDR suffit.
+    set_reuse_port(&socket2)?;
+
+    // Sous Linux, un socket lié à 0.0.0.0 reçoit par défaut le trafic de
+    // tous les groupes rejoints par la machine, quelle que soit l'interface
+    #[cfg(target_os = "linux")]
+    if interface.is_some() {
+        use std::os::unix::io::AsRawFd;
+        let optval: libc::c_int = 0;
+        unsafe {
+            let result = libc::setsockopt(
+                socket2.as_raw_fd(),
+                libc::IPPROTO_IP,
+                libc::IP_MULTICAST_ALL,
+                &optval as *const _ as *const libc::c_void,
+                std::mem::size_of_val(&optval) as libc::socklen_t,
+            );
+            if result != 0 {
+                return Err(io::Error::last_os_error());
+            }
+        }
+    }
+
+    // Bind sur 0.0.0.0:1900
+    let bind_addr: SocketAddr = format!("0.0.0.0:{}", SSDP_PORT).parse().unwrap();
+    socket2.bind(&bind_addr.into())?;
+
+    // Convertir en UdpSocket standard
+    let mut socket: UdpSocket = socket2.into();
+
+    // Rejoindre le groupe multicast
+    socket.join_multicast_v4(
+        &SSDP_MULTICAST_ADDR.parse().unwrap(),
+        &interface.unwrap_or(Ipv4Addr::UNSPECIFIED),
+    )?;
+
+    {
+        let socket2 = Socket::from(socket);
+        socket2.set_multicast_if_v4(&outgoing)?;
+        debug!(
+            "SSDP server: multicast outgoing interface set to {}",
+            outgoing
+        );
+        socket = socket2.into();
+    }
+
+    socket.set_read_timeout(Some(Duration::from_secs(1)))?;
+    socket.set_multicast_loop_v4(false)?;
+    socket.set_multicast_ttl_v4(settings.multicast_ttl)?;
+
+    Ok(socket)
+}
+
+/// Ouvre un socket UDP sur [::]:1900 abonné à `FF02::C` sur l'interface
+/// d'index `index`, qui est aussi l'interface de sortie des annonces
+fn open_multicast_socket_v6(settings: &SsdpSettings, index: u32) -> io::Result<UdpSocket> {
+    let socket2 = Socket::new(Domain::IPV6, Type::DGRAM, Some(Protocol::UDP))?;
+    // Le socket IPv4 écoute déjà le port 1900 : pas de double pile ici
+    socket2.set_only_v6(true)?;
+    socket2.set_reuse_address(true)?;
+    set_reuse_port(&socket2)?;
+
+    let bind_addr = SocketAddr::new(IpAddr::V6(Ipv6Addr::UNSPECIFIED), SSDP_PORT);
+    socket2.bind(&bind_addr.into())?;
+
+    let group: Ipv6Addr = SSDP_MULTICAST_ADDR_V6.parse().unwrap();
+    socket2.join_multicast_v6(&group, index)?;
+    socket2.set_multicast_if_v6(index)?;
+    socket2.set_multicast_loop_v6(false)?;
+    socket2.set_multicast_hops_v6(settings.multicast_ttl)?;
+    socket2.set_read_timeout(Some(Duration::from_secs(1)))?;
+
+    Ok(socket2.into())
+}
+
+/// Délai de lecture d'un [`MemoryNetwork`] (temps réel)
+const MEMORY_READ_TIMEOUT: Duration = Duration::from_millis(20);
+
+#[derive(Debug)]
+struct MemoryState {
+    local_ip: IpAddr,
+    inbox: VecDeque<(Vec<u8>, SocketAddr)>,
+    sent: Vec<(SocketAddr, String)>,
+    opened: usize,
+}
+
+/// Réseau SSDP simulé en mémoire, pour les tests
+///
+/// Sert à la fois de [`SsdpBinder`] et de [`SsdpTransport`] : chaque
+/// ouverture renvoie le même réseau, dont on peut changer l'adresse locale
+/// pour simuler un changement d'interface.
+#[derive(Debug, Clone)]
+pub struct MemoryNetwork {
+    state: Arc<Mutex<MemoryState>>,
+    changed: Arc<Condvar>,
+}
+
+impl MemoryNetwork {
+    pub fn new(local_ip: impl Into<IpAddr>) -> Self {
+        Self {
+            state: Arc::new(Mutex::new(MemoryState {
+                local_ip: local_ip.into(),
+                inbox: VecDeque::new(),
+                sent: Vec::new(),
+                opened: 0,
+            })),
+            changed: Arc::new(Condvar::new()),
+        }
+    }
+
+    /// Fait arriver un datagramme, comme envoyé par `from`
+    pub fn inject(&self, data: &[u8], from: SocketAddr) {
+        self.state
+            .lock()
+            .unwrap()
+            .inbox
+            .push_back((data.to_vec(), from));
+        self.changed.notify_all();
+    }
+
+    /// Datagrammes émis jusqu'ici (destination, contenu)
+    pub fn sent(&self) -> Vec<(SocketAddr, String)> {
+        self.state.lock().unwrap().sent.clone()
+    }
+
+    /// Attend (en temps réel, au plus `timeout`) qu'au moins `count`
+    /// datagrammes aient été émis
+    pub fn wait_sent(&self, count: usize, timeout: Duration) -> bool {
+        let state = self.state.lock().unwrap();
+        let (state, _) = self
+            .changed
+            .wait_timeout_while(state, timeout, |state| state.sent.len() < count)
+            .unwrap();
+        state.sent.len() >= count
+    }
+
+    /// Retire et renvoie les datagrammes émis jusqu'ici
+    pub fn take_sent(&self) -> Vec<(SocketAddr, String)> {
+        std::mem::take(&mut self.state.lock().unwrap().sent)
+    }
+
+    /// Change l'adresse locale annoncée par [`SsdpBinder::local_ip`]
+    pub fn set_local_ip(&self, ip: impl Into<IpAddr>) {
+        self.state.lock().unwrap().local_ip = ip.into();
+    }
+
+    /// Nombre d'ouvertures du transport (démarrage et recréations)
+    pub fn open_count(&self) -> usize {
+        self.state.lock().unwrap().opened
+    }
+}
+
+impl SsdpTransport for MemoryNetwork {
+    fn send_to(&self, buf: &[u8], target: SocketAddr) -> io::Result<usize> {
+        self.state
+            .lock()
+            .unwrap()
+            .sent
+            .push((target, String::from_utf8_lossy(buf).into_owned()));
+        self.changed.notify_all();
+        Ok(buf.len())
+    }
+
+    fn recv_from(&self, buf: &mut [u8]) -> io::Result<(usize, SocketAddr)> {
+        let state = self.state.lock().unwrap();
+        let (mut state, _) = self
+            .changed
+            .wait_timeout_while(state, MEMORY_READ_TIMEOUT, |state| state.inbox.is_empty())
+            .unwrap();
+        let Some((data, from)) = state.inbox.pop_front() else {
+            return Err(io::ErrorKind::WouldBlock.into());
+        };
+        let n = data.len().min(buf.len());
+        buf[..n].copy_from_slice(&data[..n]);
+        Ok((n, from))
+    }
+}
+
+impl SsdpBinder for MemoryNetwork {
+    fn open(&self, _settings: &SsdpSettings) -> io::Result<(Arc<dyn SsdpTransport>, IpAddr)> {
+        let local_ip = {
+            let mut state = self.state.lock().unwrap();
+            state.opened += 1;
+            state.local_ip
+        };
+        Ok((Arc::new(self.clone()), local_ip))
+    }
+
+    fn local_ip(&self) -> IpAddr {
+        self.state.lock().unwrap().local_ip
+    }
+}
@@ -0,0 +1,130 @@
+//! Conditions d'événement des variables d'état
+//!
+//! Une condition reçoit l'ancienne et la nouvelle valeur d'une variable
+//! événementielle et décide du sort de la notification GENA :
+//!
+//! - [`EventDecision::SendNow`] : notifier les abonnés immédiatement, sans
+//!   attendre le prochain tick du notifier ;
+//! - [`EventDecision::Coalesce`] : mettre le changement en file, il partira
+//!   avec les autres au prochain tick (comportement par défaut) ;
+//! - [`EventDecision::Suppress`] : ne pas notifier ce changement.
+//!
+//! Quand plusieurs conditions sont définies, un seul `Suppress` suffit à
+//! écarter l'événement ; sinon un seul `SendNow` suffit à l'envoyer
+//! immédiatement.
+//!
+//! ```ignore
+//! use pmoupnp::state_variables::conditions;
+//!
+//! // Pas d'événement pour moins de 2 points de volume
+//! volume.add_event_condition("delta".to_string(), conditions::min_delta(2.0));
+//! ```
+
+use std::sync::Arc;
+
+use crate::state_variables::StateConditionFunc;
+use crate::variable_types::{StateValue, UpnpVarType};
+
+/// Sort d'une notification de changement de valeur
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default)]
+pub enum EventDecision {
+    /// Notifier immédiatement
+    SendNow,
+    /// Notifier au prochain tick du notifier
+    #[default]
+    Coalesce,
+    /// Ne pas notifier
+    Suppress,
+}
+
+impl EventDecision {
+    /// Combine les décisions de plusieurs conditions.
+    ///
+    /// `Suppress` l'emporte sur tout, puis `SendNow` sur `Coalesce`.
+    pub fn combine(self, other: EventDecision) -> EventDecision {
+        use EventDecision::*;
+        match (self, other) {
+            (Suppress, _) | (_, Suppress) => Suppress,
+            (SendNow, _) | (_, SendNow) => SendNow,
+            _ => Coalesce,
+        }
+    }
+}
+
+/// Écarte les changements numériques d'amplitude inférieure à `delta`.
+///
+/// Les valeurs non numériques sont toujours mises en file.
+pub fn min_delta(delta: f64) -> StateConditionFunc {
+    let numeric = |v: &StateValue| v.is_integer() || v.is_float();
+    Arc::new(move |old: &StateValue, new: &StateValue| {
+        if !numeric(old) || !numeric(new) {
+            return EventDecision::Coalesce;
+        }
+        match (f64::try_from(old), f64::try_from(new)) {
+            (Ok(old), Ok(new)) if (new - old).abs() < delta => EventDecision::Suppress,
+            _ => EventDecision::Coalesce,
+        }
+    })
+}
+
+/// Écarte les mises à jour qui ne changent pas la valeur.
+pub fn on_change() -> StateConditionFunc {
+    Arc::new(|old: &StateValue, new: &StateValue| {
+        if old == new {
+            EventDecision::Suppress
+        } else {
+            EventDecision::Coalesce
+        }
+    })
+}
+
+/// Notifie immédiatement chaque changement (états de transport…).
+pub fn immediate() -> StateConditionFunc {
+    Arc::new(|_: &StateValue, _: &StateValue| EventDecision::SendNow)
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn suppress_wins_over_send_now() {
+        use EventDecision::*;
+        assert_eq!(SendNow.combine(Coalesce), SendNow);
+        assert_eq!(SendNow.combine(Suppress), Suppress);
+        assert_eq!(Coalesce.combine(Coalesce), Coalesce);
+    }
+
+    #[test]
+    fn instance_combines_its_conditions() {
+        use crate::UpnpInstance;
+        use crate::state_variables::{StateVarInstance, StateVariable};
+        use crate::variable_types::StateVarType;
+
+        let model = StateVariable::new(StateVarType::UI2, "Volume".to_string());
+        model.add_event_condition("delta".to_string(), min_delta(2.0));
+        model.add_event_condition("now".to_string(), immediate());
+        let volume = StateVarInstance::new(&model);
+
+        let (old, small, large) = (
+            StateValue::UI2(40),
+            StateValue::UI2(41),
+            StateValue::UI2(50),
+        );
+        assert_eq!(volume.event_decision(&old, &small), EventDecision::Suppress);
+        assert_eq!(volume.event_decision(&old, &large), EventDecision::SendNow);
+    }
+
+    #[test]
+    fn min_delta_ignores_small_numeric_changes() {
+        let condition = min_delta(2.0);
+        assert_eq!(
+            condition(&StateValue::UI2(40), &StateValue::UI2(41)),
+            EventDecision::Suppress
+        );
+        assert_eq!(
+            condition(&StateValue::UI2(40), &StateValue::UI2(45)),
+            EventDecision::Coalesce
+        );
+    }
+}
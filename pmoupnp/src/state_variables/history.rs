@@ -0,0 +1,143 @@
+//! Historique borné des valeurs des variables événementielles.
+//!
+//! Chaque variable qui envoie des événements garde ses dernières valeurs
+//! horodatées, consultables par l'API de debug
+//! (`GET /api/upnp/devices/{udn}/services/{service}/history`). Utile pour
+//! comprendre pourquoi une mise à jour n'est pas arrivée dans un `LastChange`
+//! modéré, ou pourquoi un point de contrôle affiche un volume périmé.
+//!
+//! La rétention est bornée en nombre d'entrées et en âge :
+//!
+//! ```yaml
+//! host:
+//!   upnp:
+//!     state_history:
+//!       capacity: 32       # entrées par variable (0 désactive)
+//!       max_age_secs: 600
+//! ```
+
+use chrono::{DateTime, Duration, Utc};
+use once_cell::sync::Lazy;
+use serde::Serialize;
+use std::collections::VecDeque;
+
+use crate::config_ext::UpnpConfigExt;
+
+/// Limites de rétention communes à toutes les variables
+#[derive(Debug, Clone, Copy, PartialEq, Eq)]
+pub struct HistoryRetention {
+    /// Nombre maximal d'entrées par variable (0 : historique désactivé)
+    pub capacity: usize,
+    /// Âge maximal d'une entrée
+    pub max_age: Duration,
+}
+
+static RETENTION: Lazy<HistoryRetention> = Lazy::new(|| {
+    let config = pmoconfig::get_config();
+    HistoryRetention {
+        capacity: config.get_upnp_state_history_capacity().unwrap_or(32),
+        max_age: Duration::seconds(
+            config.get_upnp_state_history_max_age_secs().unwrap_or(600) as i64
+        ),
+    }
+});
+
+/// Rétention configurée (`host.upnp.state_history`)
+pub fn retention() -> HistoryRetention {
+    *RETENTION
+}
+
+/// Une valeur prise par la variable
+#[derive(Debug, Clone, PartialEq, Serialize)]
+pub struct StateChange {
+    pub timestamp: DateTime<Utc>,
+    /// Valeur sous forme de chaîne UPnP
+    pub value: String,
+    /// Faux si la valeur écrite était identique à la précédente
+    pub changed: bool,
+    /// Vrai si la valeur a été transmise au service pour notification
+    pub queued_for_event: bool,
+}
+
+/// Historique d'une variable, le plus ancien en tête
+#[derive(Debug, Clone, Default)]
+pub struct StateHistory {
+    entries: VecDeque<StateChange>,
+}
+
+impl StateHistory {
+    /// Ajoute une entrée puis applique la rétention
+    pub fn record(&mut self, change: StateChange, retention: HistoryRetention) {
+        if retention.capacity == 0 {
+            return;
+        }
+        self.entries.push_back(change);
+        self.prune(retention, Utc::now());
+    }
+
+    /// Retire les entrées en surnombre ou trop anciennes
+    pub fn prune(&mut self, retention: HistoryRetention, now: DateTime<Utc>) {
+        while self.entries.len() > retention.capacity {
+            self.entries.pop_front();
+        }
+        while self
+            .entries
+            .front()
+            .is_some_and(|e| now - e.timestamp > retention.max_age)
+        {
+            self.entries.pop_front();
+        }
+    }
+
+    /// Entrées encore dans la fenêtre de rétention, la plus récente d'abord
+    pub fn entries(&self, retention: HistoryRetention) -> Vec<StateChange> {
+        let now = Utc::now();
+        self.entries
+            .iter()
+            .rev()
+            .filter(|e| now - e.timestamp <= retention.max_age)
+            .cloned()
+            .collect()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn change(value: &str, age_secs: i64) -> StateChange {
+        StateChange {
+            timestamp: Utc::now() - Duration::seconds(age_secs),
+            value: value.to_string(),
+            changed: true,
+            queued_for_event: true,
+        }
+    }
+
+    #[test]
+    fn test_bounded_retention() {
+        let retention = HistoryRetention {
+            capacity: 2,
+            max_age: Duration::seconds(60),
+        };
+        let mut history = StateHistory::default();
+        history.record(change("10", 120), retention);
+        history.record(change("20", 5), retention);
+        history.record(change("30", 1), retention);
+
+        let values: Vec<_> = history
+            .entries(retention)
+            .into_iter()
+            .map(|e| e.value)
+            .collect();
+        assert_eq!(values, vec!["30", "20"]);
+
+        let disabled = HistoryRetention {
+            capacity: 0,
+            ..retention
+        };
+        let mut history = StateHistory::default();
+        history.record(change("10", 0), disabled);
+        assert!(history.entries(disabled).is_empty());
+    }
+}
@@ -9,7 +9,10 @@ use xmltree::Element;
 use crate::{
     UpnpObjectType, UpnpTyped, UpnpTypedInstance,
     object_trait::{UpnpInstance, UpnpObject},
-    state_variables::{StateVarInstance, StateVariable, UpnpVariable},
+    state_variables::{
+        EventDecision, StateChange, StateConditionFunc, StateHistory, StateKey, StateVarInstance,
+        StateVariable, UpnpVariable, history,
+    },
     variable_types::{StateValue, StateValueError, UpnpVarType},
 };
 
@@ -47,6 +50,7 @@ impl UpnpInstance for StateVarInstance {
             last_notification: RwLock::new(Utc::now()),
             service: RwLock::new(None),
             reflexive_cache: RwLock::new(None),
+            history: RwLock::new(StateHistory::default()),
         }
     }
 }
@@ -87,6 +91,7 @@ impl Clone for StateVarInstance {
             last_notification: RwLock::new(self.last_notification.read().unwrap().clone()),
             service: RwLock::new(self.service.read().unwrap().clone()),
             reflexive_cache: RwLock::new(None), // Le cache n'est pas cloné, il sera recalculé si nécessaire
+            history: RwLock::new(self.history.read().unwrap().clone()),
         }
     }
 }
@@ -125,13 +130,23 @@ impl StateVarInstance {
         }
 
         // Mise à jour avec les locks
-        let mut old_val = self.old_value.write().unwrap();
-        let mut val = self.value.write().unwrap();
-        let mut modified = self.last_modified.write().unwrap();
+        let (changed, previous, timestamp) = {
+            let mut old_val = self.old_value.write().unwrap();
+            let mut val = self.value.write().unwrap();
+            let mut modified = self.last_modified.write().unwrap();
+
+            let changed = *val != new_value;
+            let previous = std::mem::replace(&mut *val, new_value.clone());
+            *old_val = previous.clone();
+            *modified = Utc::now();
+            (changed, previous, *modified)
+        };
 
-        *old_val = val.clone();
-        *val = new_value.clone();
-        *modified = Utc::now();
+        if self.model.is_persistent() {
+            if let Some(key) = self.state_key() {
+                crate::state_variables::persistence::schedule_state(key, new_value.to_string());
+            }
+        }
 
         // Invalider le cache réflexif
         {
@@ -141,28 +156,112 @@ impl StateVarInstance {
 
         // Notifier le service parent si la variable envoie des événements
         if self.is_sending_notification() {
-            // Relâcher les locks avant d'appeler le service
-            drop(val);
-            drop(old_val);
-            drop(modified);
-
-            if let Some(weak_service) = self.service.read().unwrap().as_ref() {
-                if let Some(service) = weak_service.upgrade() {
-                    // Obtenir la valeur réflexive (sans propager l'erreur car on est dans une notification)
-                    if let Ok(reflected_value) = self.reflexive_value() {
-                        service.event_to_be_sent(self.get_name().to_string(), reflected_value);
+            let decision = self.event_decision(&previous, &new_value);
+            let service = self
+                .service
+                .read()
+                .unwrap()
+                .as_ref()
+                .and_then(|weak_service| weak_service.upgrade());
+
+            let mut queued_for_event = false;
+            if let Some(service) = service.filter(|_| decision != EventDecision::Suppress) {
+                // Obtenir la valeur réflexive (sans propager l'erreur car on est dans une notification)
+                if let Ok(reflected_value) = self.reflexive_value() {
+                    service.event_to_be_sent(self.get_name().to_string(), reflected_value);
+                    queued_for_event = true;
+                    if decision == EventDecision::SendNow {
+                        service.notify_subscribers().await;
                     }
                 }
             }
+
+            self.history.write().unwrap().record(
+                StateChange {
+                    timestamp,
+                    value: new_value.to_string(),
+                    changed,
+                    queued_for_event,
+                },
+                history::retention(),
+            );
         }
 
         Ok(())
     }
+
+    /// Décision combinée des conditions d'événement pour un changement
+    /// `old` → `new` ([`EventDecision::Coalesce`] sans condition)
+    pub fn event_decision(&self, old: &StateValue, new: &StateValue) -> EventDecision {
+        let conditions: Vec<StateConditionFunc> = self
+            .get_definition()
+            .event_conditions
+            .read()
+            .unwrap()
+            .values()
+            .cloned()
+            .collect();
+        conditions
+            .iter()
+            .fold(EventDecision::Coalesce, |decision, condition| {
+                decision.combine(condition(old, new))
+            })
+    }
+
+    /// Clé de persistance (nécessite un service rattaché à un device)
+    fn state_key(&self) -> Option<StateKey> {
+        let service = self.service.read().unwrap().as_ref()?.upgrade()?;
+        Some(StateKey {
+            udn: service.device_udn()?,
+            service: service.get_name().to_string(),
+            variable: self.get_name().to_string(),
+        })
+    }
+
+    /// Restaure la valeur persistée, sans notification ni nouvelle sauvegarde.
+    ///
+    /// Appelée quand le service est rattaché à son device.
+    pub(crate) fn restore_persisted_value(&self) {
+        if !self.model.is_persistent() {
+            return;
+        }
+        let Some(key) = self.state_key() else {
+            return;
+        };
+        let Some(stored) = crate::state_variables::persistence::load_state(&key) else {
+            return;
+        };
+
+        match StateValue::from_string(&stored, &self.as_state_var_type()) {
+            Ok(value) => {
+                *self.old_value.write().unwrap() = value.clone();
+                *self.value.write().unwrap() = value;
+                *self.last_modified.write().unwrap() = Utc::now();
+                *self.reflexive_cache.write().unwrap() = None;
+                tracing::debug!("Restored {}/{} = {}", key.service, key.variable, stored);
+            }
+            Err(e) => tracing::warn!(
+                "Ignoring persisted value '{}' for {}/{}: {:?}",
+                stored,
+                key.service,
+                key.variable,
+                e
+            ),
+        }
+    }
+
     /// Accès à la valeur
     pub fn value(&self) -> StateValue {
         self.value.read().unwrap().clone()
     }
 
+    /// Dernières valeurs écrites, la plus récente d'abord.
+    ///
+    /// Vide pour les variables qui n'envoient pas d'événements.
+    pub fn history(&self) -> Vec<StateChange> {
+        self.history.read().unwrap().entries(history::retention())
+    }
+
     /// Accès au timestamp
     pub fn last_modified(&self) -> DateTime<Utc> {
         self.last_modified.read().unwrap().clone()
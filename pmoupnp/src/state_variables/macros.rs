@@ -45,6 +45,17 @@
 /// }
 /// ```
 ///
+/// ## Variable persistante
+///
+/// ```ignore
+/// define_variable! {
+///     pub static VOLUME: UI2 = "Volume" {
+///         evented: true,
+///         persistent: true,
+///     }
+/// }
+/// ```
+///
 /// ## Variable avec toutes les options
 ///
 /// ```ignore
@@ -69,6 +80,7 @@
 /// - `allowed: [...]` : Liste des valeurs autorisées (enum)
 /// - `default: "..."` : Valeur par défaut
 /// - `evented: true` : Active les notifications d'événements
+/// - `persistent: true` : Sauvegarde la valeur et la restaure au redémarrage
 ///
 /// # Examples
 ///
@@ -115,6 +127,7 @@ macro_rules! define_variable {
         $(allowed: [$($value:literal),* $(,)?],)?
         $(default: $default:literal,)?
         $(evented: $evented:literal,)?
+        $(persistent: $persistent:literal,)?
     }) => {
         pub static $name: once_cell::sync::Lazy<std::sync::Arc<$crate::state_variables::StateVariable>> =
             once_cell::sync::Lazy::new(|| {
@@ -140,6 +153,12 @@ macro_rules! define_variable {
                     }
                 )?
 
+                $(
+                    if $persistent {
+                        sv.set_persistent();
+                    }
+                )?
+
                 std::sync::Arc::new(sv)
             });
     };
@@ -1,18 +1,32 @@
+pub mod conditions;
 mod errors;
+pub mod history;
 mod instance_methods;
 mod macros;
+pub mod persistence;
+mod typed;
 mod var_inst_set_methods;
 mod var_set_methods;
 mod variable_methods;
 mod variable_trait;
 
-use std::{collections::HashMap, sync::Arc};
+use std::{
+    collections::{HashMap, HashSet},
+    sync::{Arc, OnceLock},
+};
 
 pub use crate::state_variables::variable_trait::UpnpVariable;
 use bevy_reflect::Reflect;
 use chrono::{DateTime, Utc};
+pub use conditions::EventDecision;
 pub use errors::StateVariableError;
+pub use history::{StateChange, StateHistory};
+pub use persistence::{
+    ConfigStateStore, MemoryStateStore, StateKey, StateStore, flush_persistent_state,
+    set_state_store,
+};
 use std::sync::RwLock;
+pub use typed::TypedStateVar;
 
 use crate::{
     UpnpObjectSet, UpnpObjectType,
@@ -20,8 +34,9 @@ use crate::{
     variable_types::{StateValue, StateVarType},
 };
 
-/// Type pour les fonctions de condition d'événement
-pub type StateConditionFunc = Arc<dyn Fn(&StateVarInstance) -> bool + Send + Sync>;
+/// Type pour les fonctions de condition d'événement : reçoit l'ancienne puis
+/// la nouvelle valeur et décide du sort de la notification
+pub type StateConditionFunc = Arc<dyn Fn(&StateValue, &StateValue) -> EventDecision + Send + Sync>;
 
 /// Type pour les fonctions de parsing de valeurs depuis des chaînes
 pub type StringValueParser =
@@ -41,7 +56,12 @@ pub struct StateVariable {
     default_value: Option<StateValue>,
     value_range: Option<ValueRange>,
     allowed_values: Arc<RwLock<Vec<StateValue>>>,
+    /// Index des valeurs autorisées chaînes, construit à la première
+    /// vérification et invalidé quand la liste change
+    allowed_index: OnceLock<HashSet<String>>,
     send_events: bool,
+    /// Valeur sauvegardée et restaurée entre les redémarrages
+    persistent: bool,
     parse: Option<StringValueParser>,
     marshal: Option<ValueSerializer>,
 }
@@ -59,6 +79,8 @@ pub struct StateVarInstance {
     service: RwLock<Option<std::sync::Weak<crate::services::ServiceInstance>>>,
     /// Cache pour la valeur réflexive (utilisé quand un parser est défini)
     reflexive_cache: RwLock<Option<Arc<dyn Reflect>>>,
+    /// Dernières valeurs (variables événementielles uniquement)
+    history: RwLock<StateHistory>,
 }
 
 pub type StateVarInstanceSet = UpnpObjectSet<StateVarInstance>;
@@ -0,0 +1,180 @@
+//! Persistance des variables d'état marquées `persistent`.
+//!
+//! Les valeurs des variables persistantes sont sauvegardées dans un
+//! [`StateStore`] à chaque modification et restaurées quand le service est
+//! rattaché à son device (voir `ServiceInstance::set_device`).
+//!
+//! Les écritures sont regroupées : une modification programme une
+//! sauvegarde après [`PERSIST_DELAY`], et toutes les valeurs modifiées
+//! entre-temps sont écrites en une fois. Un volume réglé à la molette ne
+//! provoque donc qu'une écriture au lieu de plusieurs dizaines.
+//!
+//! Le store par défaut ([`ConfigStateStore`]) écrit dans la configuration
+//! sous `host.upnp.state.<udn>.<service>.<variable>`. Un autre store peut
+//! être installé avec [`set_state_store`].
+
+use std::collections::HashMap;
+use std::sync::{
+    Arc, Mutex, RwLock,
+    atomic::{AtomicBool, Ordering},
+};
+use std::time::Duration;
+
+use once_cell::sync::Lazy;
+use serde_yaml::Value;
+use tracing::{debug, warn};
+
+use crate::state_variables::StateVariableError;
+
+/// Délai de regroupement des écritures
+pub const PERSIST_DELAY: Duration = Duration::from_secs(2);
+
+/// Identifie une variable d'état d'une instance de device
+#[derive(Debug, Clone, PartialEq, Eq, Hash)]
+pub struct StateKey {
+    pub udn: String,
+    pub service: String,
+    pub variable: String,
+}
+
+/// Stockage des valeurs persistées (sous forme de chaînes UPnP)
+pub trait StateStore: Send + Sync {
+    /// Valeur sauvegardée pour `key`, si elle existe
+    fn load(&self, key: &StateKey) -> Option<String>;
+
+    /// Sauvegarde un lot de valeurs
+    fn save(&self, entries: &[(StateKey, String)]) -> Result<(), StateVariableError>;
+}
+
+/// Store adossé à la configuration pmoconfig
+#[derive(Debug, Default)]
+pub struct ConfigStateStore;
+
+impl ConfigStateStore {
+    fn path(key: &StateKey) -> [&str; 6] {
+        [
+            "host",
+            "upnp",
+            "state",
+            &key.udn,
+            &key.service,
+            &key.variable,
+        ]
+    }
+}
+
+impl StateStore for ConfigStateStore {
+    fn load(&self, key: &StateKey) -> Option<String> {
+        match pmoconfig::get_config().get_value(&Self::path(key)) {
+            Ok(Value::String(s)) => Some(s),
+            Ok(Value::Number(n)) => Some(n.to_string()),
+            Ok(Value::Bool(b)) => Some(if b { "1" } else { "0" }.to_string()),
+            _ => None,
+        }
+    }
+
+    fn save(&self, entries: &[(StateKey, String)]) -> Result<(), StateVariableError> {
+        let config = pmoconfig::get_config();
+        for (key, value) in entries {
+            config
+                .set_value(&Self::path(key), Value::String(value.clone()))
+                .map_err(|e| StateVariableError::Unknown(e.to_string()))?;
+        }
+        Ok(())
+    }
+}
+
+/// Store en mémoire (tests, instances éphémères)
+#[derive(Debug, Default)]
+pub struct MemoryStateStore {
+    values: Mutex<HashMap<StateKey, String>>,
+}
+
+impl StateStore for MemoryStateStore {
+    fn load(&self, key: &StateKey) -> Option<String> {
+        self.values.lock().unwrap().get(key).cloned()
+    }
+
+    fn save(&self, entries: &[(StateKey, String)]) -> Result<(), StateVariableError> {
+        let mut values = self.values.lock().unwrap();
+        for (key, value) in entries {
+            values.insert(key.clone(), value.clone());
+        }
+        Ok(())
+    }
+}
+
+struct Persistence {
+    store: RwLock<Arc<dyn StateStore>>,
+    pending: Mutex<HashMap<StateKey, String>>,
+    scheduled: AtomicBool,
+}
+
+static PERSISTENCE: Lazy<Persistence> = Lazy::new(|| Persistence {
+    store: RwLock::new(Arc::new(ConfigStateStore)),
+    pending: Mutex::new(HashMap::new()),
+    scheduled: AtomicBool::new(false),
+});
+
+/// Remplace le store des variables persistantes
+pub fn set_state_store(store: Arc<dyn StateStore>) {
+    *PERSISTENCE.store.write().unwrap() = store;
+}
+
+/// Lit la valeur persistée d'une variable
+pub(crate) fn load_state(key: &StateKey) -> Option<String> {
+    let store = PERSISTENCE.store.read().unwrap().clone();
+    store.load(key)
+}
+
+/// Programme la sauvegarde d'une valeur
+pub(crate) fn schedule_state(key: StateKey, value: String) {
+    PERSISTENCE.pending.lock().unwrap().insert(key, value);
+
+    if PERSISTENCE.scheduled.swap(true, Ordering::AcqRel) {
+        return;
+    }
+    match tokio::runtime::Handle::try_current() {
+        Ok(handle) => {
+            handle.spawn(async {
+                tokio::time::sleep(PERSIST_DELAY).await;
+                flush_persistent_state();
+            });
+        }
+        // Hors runtime : écriture immédiate
+        Err(_) => flush_persistent_state(),
+    }
+}
+
+/// Écrit immédiatement les valeurs en attente (à appeler avant l'arrêt)
+pub fn flush_persistent_state() {
+    PERSISTENCE.scheduled.store(false, Ordering::Release);
+    let entries: Vec<(StateKey, String)> = PERSISTENCE.pending.lock().unwrap().drain().collect();
+    if entries.is_empty() {
+        return;
+    }
+
+    let store = PERSISTENCE.store.read().unwrap().clone();
+    match store.save(&entries) {
+        Ok(()) => debug!("Persisted {} state variable(s)", entries.len()),
+        Err(e) => warn!("Failed to persist state variables: {}", e),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_memory_store_roundtrip() {
+        let store = MemoryStateStore::default();
+        let key = StateKey {
+            udn: "uuid:test".into(),
+            service: "RenderingControl".into(),
+            variable: "Volume".into(),
+        };
+        assert!(store.load(&key).is_none());
+        store.save(&[(key.clone(), "42".into())]).unwrap();
+        assert_eq!(store.load(&key).as_deref(), Some("42"));
+    }
+}
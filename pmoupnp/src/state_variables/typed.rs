@@ -0,0 +1,111 @@
+//! Accès typé aux variables d'état
+//!
+//! [`TypedStateVar<T>`] enveloppe une [`StateVarInstance`] dont le type UPnP
+//! a été vérifié une fois pour toutes à la création : lecture et écriture
+//! manipulent directement des `T` (`u16` pour un volume, `bool` pour un
+//! mute…), sans construire ni décomposer de [`StateValue`].
+//!
+//! ```ignore
+//! let volume = service.get_typed_variable::<u16>("Volume").unwrap();
+//! volume.set(volume.get().min(100)).await?;
+//! ```
+
+use std::fmt;
+use std::marker::PhantomData;
+use std::sync::Arc;
+
+use crate::UpnpTyped;
+use crate::state_variables::{StateVarInstance, StateVariableError};
+use crate::variable_types::{StateValue, StateValueError, TypedValue, UpnpVarType};
+
+/// Variable d'état dont la valeur est de type Rust `T`.
+pub struct TypedStateVar<T: TypedValue> {
+    instance: Arc<StateVarInstance>,
+    _value: PhantomData<fn() -> T>,
+}
+
+impl<T: TypedValue> TypedStateVar<T> {
+    /// Enveloppe `instance` si son type UPnP peut porter un `T`.
+    ///
+    /// # Errors
+    ///
+    /// [`StateVariableError::TypeError`] si le type de la variable ne
+    /// correspond pas à `T`.
+    pub fn new(instance: Arc<StateVarInstance>) -> Result<Self, StateVariableError> {
+        let var_type = instance.as_state_var_type();
+        if !T::accepts(var_type) {
+            return Err(StateVariableError::TypeError(format!(
+                "{} is {:?}, not {}",
+                instance.get_name(),
+                var_type,
+                std::any::type_name::<T>()
+            )));
+        }
+        Ok(Self {
+            instance,
+            _value: PhantomData,
+        })
+    }
+
+    /// Valeur courante.
+    pub fn get(&self) -> T {
+        T::from_state_value(&self.instance.value())
+            .expect("state variable type checked at construction")
+    }
+
+    /// Met à jour la valeur (événements et persistance compris).
+    pub async fn set(&self, value: T) -> Result<(), StateValueError> {
+        let value = value.into_state_value(self.instance.as_state_var_type());
+        self.instance.set_value(value).await
+    }
+
+    /// Variable sous-jacente.
+    pub fn instance(&self) -> &Arc<StateVarInstance> {
+        &self.instance
+    }
+
+    /// Valeur courante non typée.
+    pub fn value(&self) -> StateValue {
+        self.instance.value()
+    }
+}
+
+impl<T: TypedValue> Clone for TypedStateVar<T> {
+    fn clone(&self) -> Self {
+        Self {
+            instance: self.instance.clone(),
+            _value: PhantomData,
+        }
+    }
+}
+
+impl<T: TypedValue> fmt::Debug for TypedStateVar<T> {
+    fn fmt(&self, f: &mut fmt::Formatter<'_>) -> fmt::Result {
+        f.debug_struct("TypedStateVar")
+            .field("name", &self.instance.get_name())
+            .field("type", &std::any::type_name::<T>())
+            .finish()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::UpnpInstance;
+    use crate::state_variables::StateVariable;
+    use crate::variable_types::StateVarType;
+
+    #[tokio::test]
+    async fn typed_variable_reads_and_writes_native_values() {
+        let model = StateVariable::new(StateVarType::UI2, "Volume".to_string());
+        let instance = Arc::new(StateVarInstance::new(&model));
+
+        assert!(TypedStateVar::<bool>::new(instance.clone()).is_err());
+
+        let volume = TypedStateVar::<u16>::new(instance).unwrap();
+        assert_eq!(volume.get(), 0);
+        volume.set(42).await.unwrap();
+        assert_eq!(volume.get(), 42);
+        assert!(matches!(volume.value(), StateValue::UI2(42)));
+    }
+}
@@ -1,4 +1,8 @@
-use std::{collections::HashMap, fmt, sync::Arc};
+use std::{
+    collections::HashMap,
+    fmt,
+    sync::{Arc, OnceLock},
+};
 
 use std::sync::RwLock;
 use xmltree::{Element, XMLNode};
@@ -128,7 +132,9 @@ impl Clone for StateVariable {
             default_value: self.default_value.clone(),
             value_range: self.value_range.clone(),
             allowed_values: allowed_values_clone,
+            allowed_index: self.allowed_index.clone(),
             send_events: self.send_events,
+            persistent: self.persistent,
             // parse et marshal sont typiquement des Arc<dyn ...> — on clone l'Arc (shallow).
             // Deep-cloner une closure ou un trait-objet n'est pas possible en général.
             parse: self.parse.clone(),
@@ -156,6 +162,7 @@ impl fmt::Debug for StateVariable {
                 &format_args!("len={}", self.allowed_values.read().unwrap().len()),
             )
             .field("send_events", &self.send_events)
+            .field("persistent", &self.persistent)
             .field(
                 "parse",
                 &self
@@ -197,7 +204,9 @@ impl StateVariable {
             default_value: None,
             value_range: None,
             allowed_values: Arc::new(RwLock::new(Vec::new())),
+            allowed_index: OnceLock::new(),
             send_events: false,
+            persistent: false,
             parse: None,
             marshal: None,
         }
@@ -318,6 +327,7 @@ impl StateVariable {
     }
 
     pub fn extend_allowed_values(&mut self, values: &[StateValue]) -> Result<(), StateValueError> {
+        self.allowed_index.take();
         let mut av = self.allowed_values.write().unwrap();
 
         for v in values {
@@ -334,6 +344,7 @@ impl StateVariable {
     }
 
     pub fn push_allowed_value(&mut self, value: &StateValue) -> Result<(), StateValueError> {
+        self.allowed_index.take();
         let mut av = self.allowed_values.write().unwrap();
 
         if self.as_state_var_type() == value.as_state_var_type() {
@@ -355,6 +366,16 @@ impl StateVariable {
         self.send_events = false;
     }
 
+    /// Marque la variable comme persistante : sa valeur est sauvegardée à
+    /// chaque modification et restaurée au démarrage suivant.
+    pub fn set_persistent(&mut self) {
+        self.persistent = true;
+    }
+
+    pub fn unset_persistent(&mut self) {
+        self.persistent = false;
+    }
+
     pub fn set_value_parser(&mut self, parser: StringValueParser) -> Result<(), StateValueError> {
         if self.as_state_var_type() == StateVarType::String {
             self.parse = Some(parser);
@@ -410,4 +431,9 @@ impl StateVariable {
     pub fn sends_events(&self) -> bool {
         self.send_events
     }
+
+    /// Indique si la valeur de cette variable est persistée.
+    pub fn is_persistent(&self) -> bool {
+        self.persistent
+    }
 }
@@ -1,6 +1,6 @@
 use crate::{
     state_variables::StateVariable,
-    variable_types::{StateValue, UpnpVarType},
+    variable_types::{StateValue, StateVarType, UpnpVarType},
 };
 
 /// Trait pour accéder aux propriétés et contraintes d'une variable UPnP.
@@ -248,8 +248,28 @@ pub trait UpnpVariable {
     /// retourne `false`. Utilisez [`has_allowed_values`](Self::has_allowed_values)
     /// pour distinguer "pas de liste" de "valeur non autorisée".
     fn is_an_allowed_value(&self, value: &StateValue) -> bool {
-        let guard = self.get_definition().allowed_values.read().unwrap();
+        let definition = self.get_definition();
+
+        // Listes de chaînes (cas de loin le plus courant) : recherche dans
+        // l'index plutôt que comparaison avec chaque valeur
+        if let StateValue::String(s) = value {
+            if definition.value_type == StateVarType::String {
+                return definition
+                    .allowed_index
+                    .get_or_init(|| {
+                        definition
+                            .allowed_values
+                            .read()
+                            .unwrap()
+                            .iter()
+                            .map(ToString::to_string)
+                            .collect()
+                    })
+                    .contains(s.as_str());
+            }
+        }
 
+        let guard = definition.allowed_values.read().unwrap();
         guard.contains(value)
     }
 
@@ -296,3 +316,25 @@ pub trait UpnpVariable {
         self.get_definition().marshal.is_some()
     }
 }
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn allowed_index_follows_the_allowed_list() {
+        let mut var = StateVariable::new(StateVarType::String, "TransportState".to_string());
+        var.extend_allowed_values(&[
+            StateValue::String("STOPPED".to_string()),
+            StateValue::String("PLAYING".to_string()),
+        ])
+        .unwrap();
+
+        assert!(var.is_an_allowed_value(&StateValue::String("PLAYING".to_string())));
+        assert!(!var.is_an_allowed_value(&StateValue::String("PAUSED_PLAYBACK".to_string())));
+
+        var.push_allowed_value(&StateValue::String("PAUSED_PLAYBACK".to_string()))
+            .unwrap();
+        assert!(var.is_an_allowed_value(&StateValue::String("PAUSED_PLAYBACK".to_string())));
+    }
+}
@@ -8,17 +8,26 @@
 //! - `GET /api/upnp/devices` - Liste tous les devices
 //! - `GET /api/upnp/devices/:udn` - Détails d'un device
 //! - `GET /api/upnp/devices/:udn/services/:service/variables` - Variables d'un service
+//! - `GET /api/upnp/devices/:udn/services/:service/history` - Historique des variables événementielles
+//! - `GET /api/upnp/actions` - Journal d'audit des actions invoquées
+//! - `DELETE /api/upnp/actions` - Vide le journal d'audit
 
-use crate::{UpnpTyped, UpnpTypedInstance, state_variables::UpnpVariable, upnp_server};
+use crate::{
+    UpnpTyped, UpnpTypedInstance,
+    audit::{AuditQuery, audit_log},
+    state_variables::UpnpVariable,
+    upnp_server,
+};
 use axum::{
     Router,
-    extract::Path,
+    extract::{Path, Query},
     http::StatusCode,
     response::{IntoResponse, Json},
     routing::get,
 };
 use async_trait::async_trait;
 use pmoserver::Server;
+use serde::Deserialize;
 use serde_json::json;
 use tracing::info;
 
@@ -216,6 +225,71 @@ async fn get_service_variables(
     }
 }
 
+/// Handler : Historique des variables événementielles d'un service.
+///
+/// GET /api/upnp/devices/:udn/services/:service/history?variable=
+async fn get_service_history(
+    Path((udn, service_name)): Path<(String, String)>,
+    Query(query): Query<HistoryQuery>,
+) -> impl IntoResponse {
+    let Some(device) = upnp_server::get_device_by_udn(&udn) else {
+        return (
+            StatusCode::NOT_FOUND,
+            Json(json!({ "error": "Device not found", "udn": udn })),
+        );
+    };
+    let Some(service) = device.get_service(&service_name) else {
+        return (
+            StatusCode::NOT_FOUND,
+            Json(json!({ "error": "Service not found", "service": service_name })),
+        );
+    };
+
+    let wanted = query.variable.as_deref();
+    let variables: serde_json::Map<String, serde_json::Value> = service
+        .statevariables()
+        .all()
+        .iter()
+        .filter(|v| v.is_sending_notification())
+        .filter(|v| wanted.is_none_or(|name| name == v.get_name()))
+        .map(|v| (v.get_name().to_string(), json!(v.history())))
+        .collect();
+
+    (
+        StatusCode::OK,
+        Json(json!({
+            "udn": udn,
+            "service": service_name,
+            "variables": variables
+        })),
+    )
+}
+
+#[derive(Debug, Default, Deserialize)]
+struct HistoryQuery {
+    /// Restreint la réponse à une variable
+    variable: Option<String>,
+}
+
+/// Handler : Journal d'audit des actions, les plus récentes d'abord.
+///
+/// GET /api/upnp/actions?action=&service=&client=&faults_only=&since=&limit=
+async fn list_actions(Query(query): Query<AuditQuery>) -> impl IntoResponse {
+    let log = audit_log();
+    Json(json!({
+        "enabled": log.is_enabled(),
+        "entries": log.query(&query),
+    }))
+}
+
+/// Handler : Vide le journal d'audit.
+///
+/// DELETE /api/upnp/actions
+async fn clear_actions() -> impl IntoResponse {
+    audit_log().clear();
+    StatusCode::NO_CONTENT
+}
+
 /// Trait d'extension pour enregistrer l'API UPnP sur un serveur.
 ///
 /// Similaire à `WebAppExt` et `CoverCacheExt`.
@@ -243,7 +317,12 @@ impl UpnpApiExt for Server {
             .route(
                 "/devices/{udn}/services/{service}/variables",
                 get(get_service_variables),
-            );
+            )
+            .route(
+                "/devices/{udn}/services/{service}/history",
+                get(get_service_history),
+            )
+            .route("/actions", get(list_actions).delete(clear_actions));
 
         // Monter le routeur sur /api/upnp via add_router
         self.add_router("/api/upnp", app).await;
@@ -252,5 +331,7 @@ impl UpnpApiExt for Server {
         info!("   - GET /api/upnp/devices");
         info!("   - GET /api/upnp/devices/:udn");
         info!("   - GET /api/upnp/devices/:udn/services/:service/variables");
+        info!("   - GET /api/upnp/devices/:udn/services/:service/history");
+        info!("   - GET|DELETE /api/upnp/actions");
     }
 }
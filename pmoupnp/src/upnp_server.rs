@@ -22,13 +22,13 @@ use once_cell::sync::Lazy;
 use std::sync::Arc;
 use std::sync::RwLock;
 
-use pmoserver::Server;
+use pmoserver::{Server, ServerBuilder};
 use utoipa::OpenApi;
 
 use crate::UpnpModel;
 use crate::devices::errors::DeviceError;
 use crate::devices::{Device, DeviceInstance, DeviceRegistry};
-use crate::ssdp::SsdpServer;
+use crate::ssdp::{MultiInterfaceServer, SsdpAnnouncer, SsdpHealthState, SsdpServer, SsdpSettings};
 use crate::upnp_api::UpnpApiExt;
 
 use pmoaudiocache::Cache as AudioCache;
@@ -46,8 +46,73 @@ static DEVICE_REGISTRY: Lazy<RwLock<DeviceRegistry>> =
 /// Serveur SSDP global et thread-safe.
 ///
 /// Utilise Lazy pour une initialisation paresseuse et RwLock pour le partage entre threads.
-/// Permet l'annonce automatique des devices UPnP sur le réseau.
-static SSDP_SERVER: Lazy<RwLock<Option<SsdpServer>>> = Lazy::new(|| RwLock::new(None));
+/// Permet l'annonce automatique des devices UPnP sur le réseau. Par défaut un
+/// [`SsdpServer`], mais l'application peut fournir son propre annonceur.
+static SSDP_SERVER: Lazy<RwLock<Option<Box<dyn SsdpAnnouncer>>>> =
+    Lazy::new(|| RwLock::new(None));
+
+/// Gestion de SSDP par [`UpnpServerExt::create_upnp_server_with`]
+#[derive(Default)]
+pub enum SsdpMode {
+    /// Selon la configuration (`host.ssdp.enabled`), avec le [`SsdpServer`] intégré
+    #[default]
+    FromConfig,
+    /// Pas d'annonces SSDP (HTTP seul, ex: derrière un reverse proxy)
+    Disabled,
+    /// Annonceur fourni par l'application hôte
+    Custom(Box<dyn SsdpAnnouncer>),
+}
+
+/// Options de création du serveur UPnP global
+///
+/// Destinées aux applications qui embarquent la pile UPnP de PMOMusic :
+/// elles peuvent fournir leur propre socket/router/URL via un
+/// [`ServerBuilder`] et désactiver ou remplacer SSDP.
+///
+/// # Examples
+///
+/// ```rust,ignore
+/// use pmoupnp::upnp_server::UpnpServerOptions;
+/// use pmoserver::{Server, ServerBuilder};
+///
+/// let listener = std::net::TcpListener::bind("0.0.0.0:0")?;
+/// let options = UpnpServerOptions::default()
+///     .with_server(
+///         ServerBuilder::new("MyApp", "http://192.168.1.10", 0).with_listener(listener),
+///     )
+///     .without_ssdp();
+/// let server = Server::create_upnp_server_with(options).await?;
+/// ```
+#[derive(Default)]
+pub struct UpnpServerOptions {
+    /// Builder du serveur HTTP global (sinon construit depuis la configuration)
+    pub server: Option<ServerBuilder>,
+    pub ssdp: SsdpMode,
+    /// Paramètres du [`SsdpServer`] intégré (sinon `host.ssdp`)
+    pub ssdp_settings: Option<SsdpSettings>,
+}
+
+impl UpnpServerOptions {
+    pub fn with_server(mut self, builder: ServerBuilder) -> Self {
+        self.server = Some(builder);
+        self
+    }
+
+    pub fn without_ssdp(mut self) -> Self {
+        self.ssdp = SsdpMode::Disabled;
+        self
+    }
+
+    pub fn with_ssdp_announcer(mut self, announcer: Box<dyn SsdpAnnouncer>) -> Self {
+        self.ssdp = SsdpMode::Custom(announcer);
+        self
+    }
+
+    pub fn with_ssdp_settings(mut self, settings: SsdpSettings) -> Self {
+        self.ssdp_settings = Some(settings);
+        self
+    }
+}
 
 /// Trait pour étendre un serveur avec des fonctionnalités UPnP.
 ///
@@ -182,6 +247,12 @@ pub trait UpnpServerExt {
     /// est déjà initialisé.
     fn init_ssdp(&self) -> Result<(), std::io::Error>;
 
+    /// Initialise le serveur SSDP avec des paramètres d'annonce explicites
+    ///
+    /// Comme [`init_ssdp`](Self::init_ssdp), sans effet si SSDP est déjà
+    /// initialisé.
+    fn init_ssdp_with(&self, settings: SsdpSettings) -> Result<(), std::io::Error>;
+
     /// Vérifie si le serveur SSDP est initialisé
     ///
     /// # Returns
@@ -189,6 +260,11 @@ pub trait UpnpServerExt {
     /// `true` si SSDP est actif, `false` sinon
     fn ssdp_enabled(&self) -> bool;
 
+    /// Remplace l'annonceur SSDP par celui de l'application hôte
+    ///
+    /// Les devices enregistrés ensuite avec `with_ssdp` lui sont confiés.
+    fn set_ssdp_announcer(&self, announcer: Box<dyn SsdpAnnouncer>);
+
     /// Crée et initialise le serveur UPnP global (factory method)
     ///
     /// Cette méthode factory initialise le **singleton global** du serveur avec
@@ -225,6 +301,12 @@ pub trait UpnpServerExt {
     /// server.read().await.wait().await;
     /// ```
     async fn create_upnp_server() -> Result<Arc<tokio::sync::RwLock<Server>>, anyhow::Error>;
+
+    /// Comme [`UpnpServerExt::create_upnp_server`], avec des options
+    /// d'intégration (serveur HTTP fourni, SSDP désactivé ou remplacé)
+    async fn create_upnp_server_with(
+        options: UpnpServerOptions,
+    ) -> Result<Arc<tokio::sync::RwLock<Server>>, anyhow::Error>;
 }
 
 // Implémentation du trait UpnpServer pour pmoserver::Server
@@ -265,12 +347,8 @@ impl UpnpServerExt for Server {
         if with_ssdp && self.ssdp_enabled() {
             let ssdp_opt = SSDP_SERVER.read().unwrap();
             if let Some(ref ssdp) = *ssdp_opt {
-                use crate::config_ext::UpnpConfigExt;
-                let config = pmoconfig::get_config();
-                let manufacturer = config
-                    .get_upnp_manufacturer()
-                    .unwrap_or_else(|_| "PMOMusic".to_string());
-                let ssdp_device = di.to_ssdp_device(&manufacturer, "1.0");
+                // SERVER identique à l'en-tête HTTP du device
+                let ssdp_device = di.to_ssdp_device();
                 ssdp.add_device(ssdp_device);
                 info!("✅ SSDP announcement for {}", di.udn());
             }
@@ -370,6 +448,10 @@ impl UpnpServerExt for Server {
     // ========= SSDP Management Implementation =========
 
     fn init_ssdp(&self) -> Result<(), std::io::Error> {
+        self.init_ssdp_with(SsdpSettings::from_config())
+    }
+
+    fn init_ssdp_with(&self, settings: SsdpSettings) -> Result<(), std::io::Error> {
         use tracing::info;
 
         let mut ssdp_opt = SSDP_SERVER.write().unwrap();
@@ -378,9 +460,15 @@ impl UpnpServerExt for Server {
             return Ok(());
         }
 
-        let mut ssdp = SsdpServer::new();
-        ssdp.start()?;
-        *ssdp_opt = Some(ssdp);
+        if settings.per_interface() {
+            *ssdp_opt = Some(Box::new(MultiInterfaceServer::start(settings)?));
+        } else if settings.ipv6 {
+            *ssdp_opt = Some(Box::new(MultiInterfaceServer::start_dual_stack(settings)?));
+        } else {
+            let mut ssdp = SsdpServer::with_settings(settings);
+            ssdp.start()?;
+            *ssdp_opt = Some(Box::new(ssdp));
+        }
 
         info!("✅ SSDP server initialized");
         Ok(())
@@ -390,12 +478,31 @@ impl UpnpServerExt for Server {
         SSDP_SERVER.read().unwrap().is_some()
     }
 
+    fn set_ssdp_announcer(&self, announcer: Box<dyn SsdpAnnouncer>) {
+        *SSDP_SERVER.write().unwrap() = Some(announcer);
+        tracing::info!("✅ Custom SSDP announcer installed");
+    }
+
     async fn create_upnp_server() -> Result<Arc<tokio::sync::RwLock<Server>>, anyhow::Error> {
+        Self::create_upnp_server_with(UpnpServerOptions::default()).await
+    }
+
+    async fn create_upnp_server_with(
+        options: UpnpServerOptions,
+    ) -> Result<Arc<tokio::sync::RwLock<Server>>, anyhow::Error> {
         use tracing::{error, info, warn};
 
         // 1. Initialiser le serveur global singleton
-        info!("🔧 Initializing global UPnP server from configuration...");
-        let server_arc = pmoserver::init_server();
+        let server_arc = match options.server {
+            Some(builder) => {
+                info!("🔧 Initializing global UPnP server from embedding options...");
+                pmoserver::init_server_with(move || builder)
+            }
+            None => {
+                info!("🔧 Initializing global UPnP server from configuration...");
+                pmoserver::init_server()
+            }
+        };
 
         // 2. Initialiser le logging HTTP (routes de logs + tracing)
         info!("📝 Initializing logging...");
@@ -422,41 +529,201 @@ impl UpnpServerExt for Server {
         info!("📡 Registering UPnP API...");
         server_arc.write().await.register_upnp_api().await;
 
-        // 6. Initialiser SSDP
-        info!("📡 Initializing SSDP discovery...");
-        match server_arc.write().await.init_ssdp() {
-            Ok(_) => info!("✅ SSDP server initialized"),
-            Err(e) => {
-                let kind = e.kind();
-                if kind == std::io::ErrorKind::AddrInUse {
-                    let port = crate::ssdp::SSDP_PORT;
-                    if let Some(process) = find_process_using_port(TransportProtocol::Udp, port) {
-                        error!(
-                            "❌ SSDP initialization failed: port {} is already in use by \
-                            PID {} ({}) owned by {}: {}",
-                            port, process.pid, process.process_name, process.owner, e
-                        );
+        // 6. Initialiser SSDP (désactivable pour un usage HTTP seul, ex: reverse proxy)
+        use crate::config_ext::UpnpConfigExt;
+        let start_builtin_ssdp = match options.ssdp {
+            SsdpMode::FromConfig => {
+                let enabled = pmoconfig::get_config().get_ssdp_enabled().unwrap_or(true);
+                if !enabled {
+                    warn!(
+                        "🔕 SSDP disabled by configuration (host.ssdp.enabled=false), serving HTTP only"
+                    );
+                }
+                enabled
+            }
+            SsdpMode::Disabled => {
+                warn!("🔕 SSDP disabled by embedding options, serving HTTP only");
+                false
+            }
+            SsdpMode::Custom(announcer) => {
+                server_arc.read().await.set_ssdp_announcer(announcer);
+                false
+            }
+        };
+        if start_builtin_ssdp {
+            let network = crate::ssdp::detect_network_environment();
+            crate::ssdp::log_network_environment(&network);
+
+            info!("📡 Initializing SSDP discovery...");
+            let settings = options
+                .ssdp_settings
+                .unwrap_or_else(SsdpSettings::from_config);
+            match server_arc.write().await.init_ssdp_with(settings) {
+                Ok(_) => info!("✅ SSDP server initialized"),
+                Err(e) => {
+                    let kind = e.kind();
+                    if kind == std::io::ErrorKind::AddrInUse {
+                        let port = crate::ssdp::SSDP_PORT;
+                        if let Some(process) = find_process_using_port(TransportProtocol::Udp, port) {
+                            error!(
+                                "❌ SSDP initialization failed: port {} is already in use by \
+                                PID {} ({}) owned by {}: {}",
+                                port, process.pid, process.process_name, process.owner, e
+                            );
+                        } else {
+                            error!(
+                                "❌ SSDP initialization failed: port {} is already in use. \
+                                Unable to identify the blocking process automatically. \
+                                Check manually with `lsof -nP -i UDP:{}`: {}",
+                                port, port, e
+                            );
+                        }
                     } else {
-                        error!(
-                            "❌ SSDP initialization failed: port {} is already in use. \
-                            Unable to identify the blocking process automatically. \
-                            Check manually with `lsof -nP -i UDP:{}`: {}",
-                            port, port, e
-                        );
+                        error!("❌ SSDP initialization failed: {}", e);
                     }
-                } else {
-                    error!("❌ SSDP initialization failed: {}", e);
+                    return Err(e.into());
                 }
-                return Err(e.into());
             }
         }
 
+        // 7. Sondes de santé des sous-systèmes (/healthz) et autodiagnostic
+        register_health_checks(&*server_arc.read().await);
+        register_diagnostics(&*server_arc.read().await);
+
+        // 8. Réannonce et abonnements remis à plat en sortie de veille
+        spawn_resume_handler();
+
         info!("🎉 UPnP server infrastructure ready");
         info!("📝 Next: Register devices and music sources");
         Ok(server_arc)
     }
 }
 
+/// Retire les abonnés GENA d'un device et de ses sous-devices.
+fn clear_subscriptions(device: &DeviceInstance) -> usize {
+    device
+        .services()
+        .iter()
+        .map(|service| service.clear_subscribers())
+        .sum::<usize>()
+        + device
+            .devices()
+            .iter()
+            .map(|sub| clear_subscriptions(sub))
+            .sum::<usize>()
+}
+
+/// Remet SSDP et GENA à plat à chaque sortie de veille du système.
+///
+/// Voir [`crate::power`] : l'annonceur réannonce les devices avec un
+/// nouveau BOOTID et les abonnés, dont les abonnements ont pu expirer
+/// pendant la veille, sont oubliés pour qu'ils se réabonnent.
+fn spawn_resume_handler() {
+    use tokio::sync::broadcast::error::RecvError;
+    use tracing::info;
+
+    let mut resumes = crate::power::subscribe();
+    tokio::spawn(async move {
+        loop {
+            match resumes.recv().await {
+                Ok(_) | Err(RecvError::Lagged(_)) => {}
+                Err(RecvError::Closed) => return,
+            }
+
+            if let Some(ssdp) = SSDP_SERVER.read().unwrap().as_ref() {
+                ssdp.handle_resume();
+            }
+
+            let devices = DEVICE_REGISTRY.read().unwrap().list_devices();
+            let cleared: usize = devices.iter().map(|d| clear_subscriptions(d)).sum();
+            info!(
+                "☀️ {} GENA subscription(s) dropped after sleep, subscribers must resubscribe",
+                cleared
+            );
+        }
+    });
+}
+
+/// Enregistre les vérifications de santé des sous-systèmes UPnP.
+///
+/// - `ssdp` : socket SSDP ouvert et sain (dégradé pendant une recréation)
+/// - `cover_db` : base SQLite du cache de couvertures
+/// - `library_db` : base SQLite du cache audio
+///
+/// Un cache non enregistré est ignoré (`skipped`) plutôt qu'en panne.
+fn register_health_checks(server: &Server) {
+    use pmoserver::ComponentHealth;
+
+    server.register_health_check("ssdp", || match SSDP_SERVER.read() {
+        Ok(guard) => match guard.as_ref() {
+            Some(ssdp) => {
+                let health = ssdp.health();
+                match health.state {
+                    SsdpHealthState::Healthy => ComponentHealth::up().with_detail(format!(
+                        "{} device(s), {} socket recovery(ies)",
+                        ssdp.device_count(),
+                        health.recoveries
+                    )),
+                    SsdpHealthState::Recovering => ComponentHealth::degraded(format!(
+                        "re-creating socket since {} ({} failed attempt(s)): {}",
+                        health.since.to_rfc3339(),
+                        health.failed_attempts,
+                        health.last_error.as_deref().unwrap_or("unknown error")
+                    )),
+                    SsdpHealthState::Stopped => ComponentHealth::down("socket closed"),
+                }
+            }
+            None => ComponentHealth::degraded("disabled or not initialized"),
+        },
+        Err(_) => ComponentHealth::down("registry lock poisoned"),
+    });
+
+    server.register_health_check("cover_db", || match pmocovers::get_cover_cache() {
+        Some(cache) => match cache.db.count() {
+            Ok(n) => ComponentHealth::up().with_detail(format!("{} entries", n)),
+            Err(e) => ComponentHealth::down(e.to_string()),
+        },
+        None => ComponentHealth::skipped("cache not registered"),
+    });
+
+    server.register_health_check("library_db", || match pmoaudiocache::get_audio_cache() {
+        Some(cache) => match cache.db.count() {
+            Ok(n) => ComponentHealth::up().with_detail(format!("{} entries", n)),
+            Err(e) => ComponentHealth::down(e.to_string()),
+        },
+        None => ComponentHealth::skipped("cache not registered"),
+    });
+}
+
+/// Enregistre les vérifications d'autodiagnostic des sous-systèmes UPnP.
+///
+/// - `network` : conteneur en réseau bridge (multicast non routé)
+/// - `multicast_route` : route vers le groupe SSDP, par l'adresse annoncée
+/// - `cover_cache` / `audio_cache` : dossiers des caches accessibles en écriture
+fn register_diagnostics(server: &Server) {
+    use pmoserver::{Diagnostic, diagnostics::check_writable_dir};
+
+    server.register_diagnostic("network", crate::ssdp::check_network);
+
+    let announced = crate::ssdp::announced_ip(&server.info().base_url);
+    server.register_diagnostic("multicast_route", move || {
+        if SSDP_SERVER.read().map(|s| s.is_none()).unwrap_or(true) {
+            return Diagnostic::ok("SSDP disabled, no multicast needed");
+        }
+        crate::ssdp::check_multicast_route(announced)
+    });
+
+    server.register_diagnostic("cover_cache", || match pmocovers::get_cover_cache() {
+        Some(cache) => check_writable_dir(cache.cache_dir()),
+        None => Diagnostic::warning("cache not registered", "check the startup logs"),
+    });
+
+    server.register_diagnostic("audio_cache", || match pmoaudiocache::get_audio_cache() {
+        Some(cache) => check_writable_dir(cache.cache_dir()),
+        None => Diagnostic::warning("cache not registered", "check the startup logs"),
+    });
+}
+
 /// Fonctions helper pour accéder au registre depuis les handlers.
 ///
 /// Ces fonctions permettent d'accéder au registre global depuis
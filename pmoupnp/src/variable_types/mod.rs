@@ -7,6 +7,7 @@ mod fromstr;
 mod reflect_impl;
 mod type_methods;
 mod type_trait;
+mod typed_value;
 mod value_methods;
 mod value_trait;
 
@@ -41,6 +42,7 @@ use uuid::Uuid;
 
 pub use errors::StateValueError;
 pub use type_trait::UpnpVarType;
+pub use typed_value::TypedValue;
 
 pub use crate::variable_types::value_trait::UpnpValue;
 
@@ -0,0 +1,98 @@
+//! Correspondance entre types Rust et types de variables UPnP
+//!
+//! [`TypedValue`] relie un type Rust aux [`StateVarType`] qui le
+//! représentent, ce qui permet de manipuler une variable d'état sans passer
+//! par [`StateValue`] (voir [`TypedStateVar`](crate::state_variables::TypedStateVar)).
+
+use chrono::{DateTime, FixedOffset, NaiveDate, NaiveDateTime, NaiveTime};
+use url::Url;
+use uuid::Uuid;
+
+use crate::variable_types::{StateValue, StateVarType};
+
+/// Type Rust représentable par une ou plusieurs variantes de [`StateValue`].
+///
+/// | Type Rust                 | Types UPnP                      |
+/// |---------------------------|---------------------------------|
+/// | `u8` / `u16` / `u32`      | `ui1` / `ui2` / `ui4`           |
+/// | `i8` / `i16`              | `i1` / `i2`                     |
+/// | `i32`                     | `i4`, `int`                     |
+/// | `f32`                     | `r4`                            |
+/// | `f64`                     | `r8`, `number`, `fixed.14.4`    |
+/// | `bool`                    | `boolean`                       |
+/// | `char`                    | `char`                          |
+/// | `String`                  | `string`, `bin.base64`, `bin.hex` |
+/// | `Url` / `Uuid`            | `uri` / `uuid`                  |
+/// | `NaiveDate` / `NaiveDateTime` / `NaiveTime` | `date` / `dateTime` / `time` |
+/// | `DateTime<FixedOffset>`   | `dateTime.tz`, `time.tz`        |
+pub trait TypedValue: Sized + Send + Sync + 'static {
+    /// Le type UPnP `var_type` peut-il porter ce type Rust ?
+    fn accepts(var_type: StateVarType) -> bool;
+
+    /// Convertit la valeur vers la variante correspondant à `var_type`.
+    ///
+    /// `var_type` doit être accepté par [`TypedValue::accepts`].
+    fn into_state_value(self, var_type: StateVarType) -> StateValue;
+
+    /// Extrait la valeur d'une variante acceptée, sans conversion.
+    fn from_state_value(value: &StateValue) -> Option<Self>;
+}
+
+/// Implémente [`TypedValue`] ; la première variante sert par défaut.
+macro_rules! typed_value {
+    ($t:ty => $first:ident $(| $other:ident)*) => {
+        impl TypedValue for $t {
+            fn accepts(var_type: StateVarType) -> bool {
+                matches!(var_type, StateVarType::$first $(| StateVarType::$other)*)
+            }
+
+            fn into_state_value(self, var_type: StateVarType) -> StateValue {
+                match var_type {
+                    $(StateVarType::$other => StateValue::$other(self),)*
+                    _ => StateValue::$first(self),
+                }
+            }
+
+            fn from_state_value(value: &StateValue) -> Option<Self> {
+                match value {
+                    StateValue::$first(v) $(| StateValue::$other(v))* => Some(v.clone()),
+                    _ => None,
+                }
+            }
+        }
+    };
+}
+
+typed_value!(u8 => UI1);
+typed_value!(u16 => UI2);
+typed_value!(u32 => UI4);
+typed_value!(i8 => I1);
+typed_value!(i16 => I2);
+typed_value!(i32 => I4 | Int);
+typed_value!(f32 => R4);
+typed_value!(f64 => R8 | Number | Fixed14_4);
+typed_value!(bool => Boolean);
+typed_value!(char => Char);
+typed_value!(String => String | BinBase64 | BinHex);
+typed_value!(Url => URI);
+typed_value!(Uuid => UUID);
+typed_value!(NaiveDate => Date);
+typed_value!(NaiveDateTime => DateTime);
+typed_value!(NaiveTime => Time);
+typed_value!(DateTime<FixedOffset> => DateTimeTZ | TimeTZ);
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn values_round_trip_through_their_variant() {
+        let value = 42i32.into_state_value(StateVarType::Int);
+        assert!(matches!(value, StateValue::Int(42)));
+        assert_eq!(i32::from_state_value(&value), Some(42));
+
+        assert!(u16::accepts(StateVarType::UI2));
+        assert!(!u16::accepts(StateVarType::UI4));
+        assert_eq!(u16::from_state_value(&StateValue::UI4(3)), None);
+    }
+}
@@ -10,6 +10,19 @@ impl UpnpVarType for StateValue {
 
 impl PartialEq for StateValue {
     fn eq(&self, other: &Self) -> bool {
+        // Chemin rapide : même variante, sans conversion ni allocation
+        match (self, other) {
+            (StateValue::String(a), StateValue::String(b))
+            | (StateValue::BinBase64(a), StateValue::BinBase64(b))
+            | (StateValue::BinHex(a), StateValue::BinHex(b)) => return a == b,
+            (StateValue::UI4(a), StateValue::UI4(b)) => return a == b,
+            (StateValue::I4(a), StateValue::I4(b)) | (StateValue::Int(a), StateValue::Int(b)) => {
+                return a == b;
+            }
+            (StateValue::R8(a), StateValue::R8(b)) => return a == b,
+            _ => {}
+        }
+
         match (self, other) {
             (a, b) if a.is_integer() && b.is_integer() => {
                 if let (Ok(ia), Ok(ib)) = (i64::try_from(a), i64::try_from(b)) {
@@ -50,6 +63,17 @@ impl PartialEq for StateValue {
 
 impl PartialOrd for StateValue {
     fn partial_cmp(&self, other: &Self) -> Option<Ordering> {
+        // Chemin rapide : même variante, sans conversion ni allocation
+        match (self, other) {
+            (StateValue::String(a), StateValue::String(b)) => return Some(a.cmp(b)),
+            (StateValue::UI4(a), StateValue::UI4(b)) => return Some(a.cmp(b)),
+            (StateValue::I4(a), StateValue::I4(b)) | (StateValue::Int(a), StateValue::Int(b)) => {
+                return Some(a.cmp(b));
+            }
+            (StateValue::R8(a), StateValue::R8(b)) => return a.partial_cmp(b),
+            _ => {}
+        }
+
         match (self, other) {
             (a, b) if a.is_integer() && b.is_integer() => {
                 if let (Ok(ia), Ok(ib)) = (i64::try_from(a), i64::try_from(b)) {
@@ -155,14 +179,20 @@ impl StateValue {
                 .ok_or_else(|| StateValueError::ParseError("Empty string for Char".to_string()))
                 .map(StateValue::Char),
             StateVarType::String => Ok(StateValue::String(s.to_string())),
-            StateVarType::Boolean => match s.to_lowercase().as_str() {
-                "true" | "1" | "yes" => Ok(StateValue::Boolean(true)),
-                "false" | "0" | "no" => Ok(StateValue::Boolean(false)),
-                _ => Err(StateValueError::ParseError(format!(
-                    "Invalid boolean value: {}",
-                    s
-                ))),
-            },
+            StateVarType::Boolean => {
+                const TRUE: [&str; 3] = ["true", "1", "yes"];
+                const FALSE: [&str; 3] = ["false", "0", "no"];
+                if TRUE.iter().any(|t| s.eq_ignore_ascii_case(t)) {
+                    Ok(StateValue::Boolean(true))
+                } else if FALSE.iter().any(|f| s.eq_ignore_ascii_case(f)) {
+                    Ok(StateValue::Boolean(false))
+                } else {
+                    Err(StateValueError::ParseError(format!(
+                        "Invalid boolean value: {}",
+                        s
+                    )))
+                }
+            }
             StateVarType::BinBase64 => Ok(StateValue::BinBase64(s.to_string())),
             StateVarType::BinHex => Ok(StateValue::BinHex(s.to_string())),
             StateVarType::Date => NaiveDate::parse_from_str(s, "%Y-%m-%d")
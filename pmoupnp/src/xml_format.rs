@@ -0,0 +1,209 @@
+//! Options de sérialisation XML des documents UPnP.
+//!
+//! Les descriptions de devices, les SCPD et les enveloppes SOAP passent
+//! toutes par [`write_xml`], qui applique les options globales
+//! (`host.upnp.xml`) :
+//!
+//! - `indent` : indentation (désactivable, certains renderers n'acceptent
+//!   pas d'espace avant ou autour de la déclaration XML) ;
+//! - `deterministic` : ordre stable des listes issues de tables de hachage
+//!   (services, sous-devices, actions, variables) et des attributs, pour
+//!   des descriptions identiques d'un démarrage à l'autre ;
+//! - `compact` : sans indentation ni éléments optionnels vides, pour les
+//!   points de contrôle à mémoire limitée.
+//!
+//! ```yaml
+//! host:
+//!   upnp:
+//!     xml:
+//!       indent: true
+//!       deterministic: false
+//!       compact: false
+//! ```
+
+use once_cell::sync::Lazy;
+use serde::{Deserialize, Serialize};
+use std::sync::RwLock;
+use tracing::warn;
+use xmltree::{Element, EmitterConfig, XMLNode};
+
+/// Listes dont l'ordre des éléments n'a pas de sens, avec la clé de tri
+const UNORDERED_LISTS: &[(&str, &str)] = &[
+    ("serviceList", "serviceId"),
+    ("deviceList", "UDN"),
+    ("actionList", "name"),
+    ("serviceStateTable", "name"),
+];
+
+/// Éléments optionnels supprimés en mode compact lorsqu'ils sont vides
+const OPTIONAL_ELEMENTS: &[&str] = &[
+    "manufacturerURL",
+    "modelDescription",
+    "modelNumber",
+    "modelURL",
+    "serialNumber",
+    "UPC",
+    "presentationURL",
+    "iconList",
+    "argumentList",
+    "allowedValueList",
+];
+
+/// Options de sérialisation XML
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(default)]
+pub struct XmlOptions {
+    /// Indente le document (ignoré en mode compact)
+    pub indent: bool,
+    /// Trie les listes non ordonnées et les attributs
+    pub deterministic: bool,
+    /// Supprime l'indentation et les éléments optionnels vides
+    pub compact: bool,
+}
+
+impl Default for XmlOptions {
+    fn default() -> Self {
+        Self {
+            indent: true,
+            deterministic: false,
+            compact: false,
+        }
+    }
+}
+
+static XML_OPTIONS: Lazy<RwLock<XmlOptions>> = Lazy::new(|| {
+    use crate::config_ext::UpnpConfigExt;
+    let options = pmoconfig::get_config()
+        .get_upnp_xml_options()
+        .unwrap_or_else(|e| {
+            warn!("Invalid host.upnp.xml configuration: {}", e);
+            XmlOptions::default()
+        });
+    RwLock::new(options)
+});
+
+/// Options courantes
+pub fn xml_options() -> XmlOptions {
+    *XML_OPTIONS.read().unwrap()
+}
+
+/// Remplace les options (ex: application qui embarque la pile UPnP)
+pub fn set_xml_options(options: XmlOptions) {
+    *XML_OPTIONS.write().unwrap() = options;
+    crate::devices::invalidate_descriptions();
+}
+
+/// Sérialise `elem` (avec déclaration XML) selon les options globales
+pub fn write_xml(elem: &Element) -> Result<String, xmltree::Error> {
+    write_xml_with(elem, xml_options())
+}
+
+/// Sérialise `elem` (avec déclaration XML) selon `options`
+pub fn write_xml_with(elem: &Element, options: XmlOptions) -> Result<String, xmltree::Error> {
+    let mut elem = elem.clone();
+    if options.deterministic {
+        canonicalize(&mut elem);
+    }
+    if options.compact {
+        strip_optional(&mut elem);
+    }
+
+    let indent = options.indent && !options.compact;
+    let config = EmitterConfig::new()
+        .write_document_declaration(true)
+        .perform_indent(indent)
+        .indent_string("  ");
+
+    let mut buf = Vec::new();
+    elem.write_with_config(&mut buf, config)?;
+    let xml = String::from_utf8_lossy(&buf);
+    // Rien ne doit précéder la déclaration XML
+    Ok(xml.trim_start().to_string())
+}
+
+fn child_text(elem: &Element, name: &str) -> String {
+    elem.get_child(name)
+        .and_then(|child| child.get_text())
+        .map(|text| text.into_owned())
+        .unwrap_or_default()
+}
+
+/// Trie récursivement les listes non ordonnées et les attributs
+fn canonicalize(elem: &mut Element) {
+    elem.attributes.sort_keys();
+
+    if let Some((_, key)) = UNORDERED_LISTS.iter().find(|(list, _)| *list == elem.name) {
+        elem.children.retain(|node| matches!(node, XMLNode::Element(_)));
+        elem.children.sort_by_cached_key(|node| match node {
+            XMLNode::Element(child) => child_text(child, key),
+            _ => String::new(),
+        });
+    }
+
+    for node in elem.children.iter_mut() {
+        if let XMLNode::Element(child) = node {
+            canonicalize(child);
+        }
+    }
+}
+
+/// Supprime les éléments optionnels vides et les nœuds d'espacement
+fn strip_optional(elem: &mut Element) {
+    elem.children.retain(|node| match node {
+        XMLNode::Element(child) => {
+            !(OPTIONAL_ELEMENTS.contains(&child.name.as_str())
+                && child.children.iter().all(|n| match n {
+                    XMLNode::Text(t) => t.trim().is_empty(),
+                    _ => false,
+                }))
+        }
+        XMLNode::Text(text) => !text.trim().is_empty(),
+        XMLNode::Comment(_) => false,
+        _ => true,
+    });
+    for node in elem.children.iter_mut() {
+        if let XMLNode::Element(child) = node {
+            strip_optional(child);
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn service(id: &str) -> XMLNode {
+        let mut service = Element::new("service");
+        let mut service_id = Element::new("serviceId");
+        service_id.children.push(XMLNode::Text(id.to_string()));
+        service.children.push(XMLNode::Element(service_id));
+        XMLNode::Element(service)
+    }
+
+    #[test]
+    fn compact_and_deterministic_output() {
+        let mut device = Element::new("device");
+        device.children.push(XMLNode::Element(Element::new("serialNumber")));
+        let mut list = Element::new("serviceList");
+        list.children.push(service("urn:upnp-org:serviceId:b"));
+        list.children.push(service("urn:upnp-org:serviceId:a"));
+        device.children.push(XMLNode::Element(list));
+
+        let xml = write_xml_with(
+            &device,
+            XmlOptions {
+                indent: true,
+                deterministic: true,
+                compact: true,
+            },
+        )
+        .unwrap();
+
+        assert!(xml.starts_with("<?xml"));
+        assert!(!xml.contains('\n'));
+        assert!(!xml.contains("serialNumber"));
+        let a = xml.find("serviceId:a").unwrap();
+        let b = xml.find("serviceId:b").unwrap();
+        assert!(a < b);
+    }
+}
@@ -19,13 +19,20 @@ use pmocontrol::ControlPoint;
 use pmomediarenderer::MediaRendererError;
 #[cfg(feature = "pmoserver")]
 use crate::register::{
-    nowplaying_handler, pause_handler, play_handler, position_update_handler,
-    register_handler, report_handler, set_uri_handler, state_handler, unregister_handler,
+    announce_handler, cancel_announce_handler, get_announce_handler, get_bit_perfect_handler, get_channels_handler, get_crossfeed_handler, get_keepalive_handler, get_recorder_handler, nowplaying_handler, pause_handler, play_handler, position_update_handler,
+    register_handler, report_handler, set_bit_perfect_handler, set_channels_handler, set_crossfeed_handler, set_keepalive_handler, set_recorder_handler, set_uri_handler, state_handler,
+    unregister_handler,
 };
 #[cfg(feature = "pmoserver")]
 use pmomediarenderer::MediaRendererRegistry;
 #[cfg(feature = "pmoserver")]
 use crate::stream::stream_handler;
+#[cfg(feature = "pmoserver")]
+use crate::meters::meters_sse_handler;
+#[cfg(feature = "pmoserver")]
+use crate::latency::latency_handler;
+#[cfg(feature = "pmoserver")]
+use crate::resampler::{resampler_handler, resampler_list_handler};
 
 /// Trait pour étendre pmoserver::Server avec les routes WebRenderer
 #[cfg(feature = "pmoserver")]
@@ -45,6 +52,24 @@ impl WebRendererExt for pmoserver::Server {
         control_point: Arc<ControlPoint>,
     ) -> Result<(), MediaRendererError> {
         let registry = Arc::new(MediaRendererRegistry::new(control_point));
+        registry.pause_on_resume();
+
+        // Sonde /healthz : nombre de flux WebRenderer actifs et pire latence moyenne
+        let health_registry = registry.clone();
+        self.register_health_check("streams", move || {
+            let instances = health_registry.instances();
+            let worst_latency = instances
+                .iter()
+                .map(|i| i.flac_handle.latency())
+                .filter(|l| l.samples > 0)
+                .map(|l| l.average_ms)
+                .reduce(f64::max);
+            let detail = match worst_latency {
+                Some(ms) => format!("{} active, latency {:.0} ms", instances.len(), ms),
+                None => format!("{} active", instances.len()),
+            };
+            pmoserver::ComponentHealth::up().with_detail(detail)
+        });
 
         // POST /api/webrenderer/register
         self.add_post_handler_with_state(
@@ -58,7 +83,11 @@ impl WebRendererExt for pmoserver::Server {
         // POST /api/webrenderer/{id}/play -> tell player to start streaming
         // POST /api/webrenderer/{id}/pause, /set_uri, /report
         // GET /api/webrenderer/{id}/command, /position
+        // GET /api/webrenderer/latency -> latence de bout en bout par flux
+        // GET /api/webrenderer/resampler -> état du rééchantillonnage par flux
         let dynamic_router = Router::new()
+            .route("/latency", get(latency_handler))
+            .route("/resampler", get(resampler_list_handler))
             .route("/{id}/stream", get(stream_handler))
             .route("/{id}", delete(unregister_handler))
             .route("/{id}/play", post(play_handler))
@@ -69,15 +98,41 @@ impl WebRendererExt for pmoserver::Server {
             .route("/{id}/position", post(position_update_handler))
             .route("/{id}/nowplaying", get(nowplaying_handler))
             .route("/{id}/state", get(state_handler))
+            .route("/{id}/channels", get(get_channels_handler).post(set_channels_handler))
+            .route("/{id}/crossfeed", get(get_crossfeed_handler).post(set_crossfeed_handler))
+            .route("/{id}/meters", get(meters_sse_handler))
+            .route("/{id}/resampler", get(resampler_handler))
+            .route("/{id}/recorder", get(get_recorder_handler).post(set_recorder_handler))
+            .route("/{id}/keepalive", get(get_keepalive_handler).post(set_keepalive_handler))
+            .route(
+                "/{id}/bitperfect",
+                get(get_bit_perfect_handler).post(set_bit_perfect_handler),
+            )
+            .route(
+                "/{id}/announce",
+                get(get_announce_handler)
+                    .post(announce_handler)
+                    .delete(cancel_announce_handler),
+            )
             .with_state(registry.clone());
         self.add_router("/api/webrenderer", dynamic_router).await;
 
         tracing::info!("WebRenderer server-side streaming endpoints registered");
         tracing::info!("  POST   /api/webrenderer/register");
+        tracing::info!("  GET    /api/webrenderer/latency");
+        tracing::info!("  GET    /api/webrenderer/resampler");
         tracing::info!("  GET    /api/webrenderer/{{id}}/stream");
         tracing::info!("  DELETE /api/webrenderer/{{id}}");
         tracing::info!("  GET    /api/webrenderer/{{id}}/nowplaying");
         tracing::info!("  GET    /api/webrenderer/{{id}}/state");
+        tracing::info!("  GET    /api/webrenderer/{{id}}/channels  (POST to update)");
+        tracing::info!("  GET    /api/webrenderer/{{id}}/crossfeed (POST to update)");
+        tracing::info!("  GET    /api/webrenderer/{{id}}/meters    (SSE)");
+        tracing::info!("  GET    /api/webrenderer/{{id}}/resampler");
+        tracing::info!("  GET    /api/webrenderer/{{id}}/recorder  (POST to update)");
+        tracing::info!("  GET    /api/webrenderer/{{id}}/keepalive (POST to update)");
+        tracing::info!("  GET    /api/webrenderer/{{id}}/bitperfect (POST to update)");
+        tracing::info!("  GET    /api/webrenderer/{{id}}/announce  (POST, DELETE to cancel)");
         Ok(())
     }
 }
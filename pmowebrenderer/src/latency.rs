@@ -0,0 +1,38 @@
+//! Latence de bout en bout des flux WebRenderer
+//!
+//! Route : `GET /api/webrenderer/latency`
+//!
+//! Pour chaque instance, délai entre la sortie du décodage (entrée du PCM
+//! dans l'encodeur) et l'écriture des données vers le navigateur : dernière
+//! mesure, moyenne glissante et extrêmes. Sert à régler la synchronisation
+//! multiroom et la taille des tampons, et alimente la page de debug.
+
+use axum::{Json, extract::State, response::IntoResponse};
+use serde::Serialize;
+use std::sync::Arc;
+
+use pmomediarenderer::{LatencySnapshot, MediaRendererRegistry};
+
+#[derive(Debug, Serialize)]
+pub struct StreamLatencyEntry {
+    pub instance_id: String,
+    pub udn: String,
+    pub clients: usize,
+    pub latency: LatencySnapshot,
+}
+
+pub async fn latency_handler(
+    State(registry): State<Arc<MediaRendererRegistry>>,
+) -> impl IntoResponse {
+    let entries: Vec<StreamLatencyEntry> = registry
+        .instances()
+        .iter()
+        .map(|instance| StreamLatencyEntry {
+            instance_id: instance.instance_id.clone(),
+            udn: instance.udn.clone(),
+            clients: instance.flac_handle.active_client_count(),
+            latency: instance.flac_handle.latency(),
+        })
+        .collect();
+    Json(entries)
+}
@@ -7,7 +7,13 @@
 
 mod adapter;
 mod helpers;
+#[cfg(feature = "pmoserver")]
+mod latency;
+#[cfg(feature = "pmoserver")]
+mod meters;
 mod register;
+#[cfg(feature = "pmoserver")]
+mod resampler;
 mod stream;
 
 #[cfg(feature = "pmoserver")]
@@ -0,0 +1,65 @@
+//! SSE des niveaux et du spectre d'un renderer
+//!
+//! Route : `GET /api/webrenderer/{id}/meters`
+//!
+//! Chaque évènement `meters` contient RMS/crête par canal et un spectre
+//! grossier, publiés ~20 fois par seconde par le pipeline : l'interface peut
+//! afficher des vu-mètres sans ouvrir un second flux audio.
+
+use axum::{
+    extract::{Path, State},
+    http::StatusCode,
+    response::sse::{Event, KeepAlive, Sse},
+    response::IntoResponse,
+};
+use futures::stream;
+use serde::Serialize;
+use std::sync::Arc;
+use tokio::sync::broadcast::error::RecvError;
+
+use pmomediarenderer::MediaRendererRegistry;
+
+#[derive(Debug, Serialize)]
+pub struct MetersPayload {
+    pub timestamp_sec: f64,
+    pub rms_db: [f32; 2],
+    pub peak_db: [f32; 2],
+    pub spectrum_db: Vec<f32>,
+}
+
+pub async fn meters_sse_handler(
+    State(registry): State<Arc<MediaRendererRegistry>>,
+    Path(instance_id): Path<String>,
+) -> impl IntoResponse {
+    let Some(instance) = registry.get_instance(&instance_id) else {
+        return StatusCode::NOT_FOUND.into_response();
+    };
+    let rx = instance.pipeline.analysis.subscribe();
+
+    let events = stream::unfold(rx, |mut rx| async move {
+        loop {
+            match rx.recv().await {
+                Ok(frame) => {
+                    let payload = MetersPayload {
+                        timestamp_sec: frame.timestamp_sec,
+                        rms_db: frame.rms_db,
+                        peak_db: frame.peak_db,
+                        spectrum_db: frame.spectrum_db.clone(),
+                    };
+                    let Ok(json) = serde_json::to_string(&payload) else {
+                        continue;
+                    };
+                    let event = Event::default().event("meters").data(json);
+                    return Some((Ok::<_, axum::Error>(event), rx));
+                }
+                // Client trop lent : on saute les trames perdues
+                Err(RecvError::Lagged(_)) => continue,
+                Err(RecvError::Closed) => return None,
+            }
+        }
+    });
+
+    Sse::new(events)
+        .keep_alive(KeepAlive::default())
+        .into_response()
+}
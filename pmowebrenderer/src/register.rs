@@ -14,7 +14,10 @@ use std::sync::Arc;
 
 use pmomediarenderer::PlaybackState;
 use pmomediarenderer::PipelineControl;
-use pmomediarenderer::{DeviceCommand, MediaRendererRegistry};
+use pmomediarenderer::{
+    CrossfeedParams, DeviceCommand, MediaRendererError, MediaRendererInstance,
+    MediaRendererRegistry, OutputProfile, TransportSession,
+};
 
 use crate::adapter::BrowserAdapter;
 use crate::helpers::extract_browser_name;
@@ -155,10 +158,10 @@ pub async fn report_handler(
     };
     let mut state = instance.state.write();
     if let Some(pos) = report.position_sec {
-        state.position = Some(pmomediarenderer::seconds_to_upnp_time(pos));
+        state.now_playing.position_sec = Some(pos);
     }
     if let Some(dur) = report.duration_sec {
-        state.duration = Some(pmomediarenderer::seconds_to_upnp_time(dur));
+        state.now_playing.duration_sec = Some(dur);
     }
     if let Some(s) = &report.state {
         state.playback_state = match s.as_str() {
@@ -168,7 +171,7 @@ pub async fn report_handler(
             _ => state.playback_state.clone(),
         };
     }
-    tracing::debug!(instance_id = %instance_id, position = ?state.position, "player state updated");
+    tracing::debug!(instance_id = %instance_id, position = ?state.now_playing.position_sec, "player state updated");
     StatusCode::OK.into_response()
 }
 
@@ -199,7 +202,7 @@ pub async fn play_handler(
         }
     };
     
-    let has_uri = instance.state.read().current_uri.is_some();
+    let has_uri = instance.state.read().now_playing.has_track();
     if !has_uri {
         tracing::warn!(instance_id = %instance_id, "Play command ignored: no URI loaded");
         let mut headers = HeaderMap::new();
@@ -224,8 +227,17 @@ pub struct NowPlayingResponse {
     pub current_metadata: Option<String>,
     pub position: Option<String>,
     pub duration: Option<String>,
+    /// Codec de la source (`FLAC`, `MP3`...)
+    pub codec: Option<String>,
+    pub sample_rate: Option<u32>,
+    pub bit_depth: Option<u8>,
+    pub lossless: Option<bool>,
+    /// Origine du flux : `file` ou hôte HTTP
+    pub source: Option<String>,
     pub volume: u16,
     pub mute: bool,
+    /// Flux transmis sans rééchantillonnage, volume ni traitement
+    pub bit_perfect: bool,
 }
 
 #[axum::debug_handler]
@@ -245,12 +257,18 @@ pub async fn nowplaying_handler(
             PlaybackState::Stopped => "STOPPED",
             PlaybackState::Transitioning => "TRANSITIONING",
         }.to_string(),
-        current_uri: s.current_uri.clone(),
-        current_metadata: s.current_metadata.clone(),
-        position: s.position.clone(),
-        duration: s.duration.clone(),
+        current_uri: s.now_playing.uri.clone(),
+        current_metadata: s.now_playing.metadata.clone(),
+        position: s.now_playing.position_upnp(),
+        duration: s.now_playing.duration_upnp(),
+        codec: s.now_playing.codec.clone(),
+        sample_rate: s.now_playing.sample_rate,
+        bit_depth: s.now_playing.bit_depth,
+        lossless: s.now_playing.lossless,
+        source: s.now_playing.source.clone(),
         volume: s.volume,
         mute: s.mute,
+        bit_perfect: s.bit_perfect,
     };
     (StatusCode::OK, Json(response)).into_response()
 }
@@ -268,6 +286,11 @@ pub struct RendererStateResponse {
     pub duration: Option<String>,
     pub volume: u16,
     pub mute: bool,
+    pub balance: i16,
+    pub channel_swap: bool,
+    pub mono: bool,
+    /// Point de contrôle propriétaire du transport (dernier SetAVTransportURI/Play)
+    pub session: Option<TransportSession>,
 }
 
 #[axum::debug_handler]
@@ -289,14 +312,367 @@ pub async fn state_handler(
             PlaybackState::Stopped => "STOPPED",
             PlaybackState::Transitioning => "TRANSITIONING",
         }.to_string(),
-        current_uri: s.current_uri.clone(),
-        current_metadata: s.current_metadata.clone(),
+        current_uri: s.now_playing.uri.clone(),
+        current_metadata: s.now_playing.metadata.clone(),
         next_uri: s.next_uri.clone(),
         next_metadata: s.next_metadata.clone(),
-        position: s.position.clone(),
-        duration: s.duration.clone(),
+        position: s.now_playing.position_upnp(),
+        duration: s.now_playing.duration_upnp(),
         volume: s.volume,
         mute: s.mute,
+        balance: s.balance,
+        channel_swap: s.channel_swap,
+        mono: s.mono,
+        session: s.session.clone(),
     };
     (StatusCode::OK, Json(response)).into_response()
-}
\ No newline at end of file
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ChannelSettings {
+    /// Balance de -100 (gauche) à +100 (droite)
+    pub balance: i16,
+    /// Inversion des canaux gauche/droite
+    pub channel_swap: bool,
+    /// Downmix mono (-3 dB), pour une enceinte unique
+    pub mono: bool,
+}
+
+#[derive(Debug, Deserialize)]
+pub struct ChannelSettingsRequest {
+    pub balance: Option<i16>,
+    pub channel_swap: Option<bool>,
+    pub mono: Option<bool>,
+}
+
+#[axum::debug_handler]
+pub async fn get_channels_handler(
+    State(registry): State<Arc<MediaRendererRegistry>>,
+    Path(instance_id): Path<String>,
+) -> impl IntoResponse {
+    let Some(state) = registry.get_state(&instance_id) else {
+        return StatusCode::NOT_FOUND.into_response();
+    };
+    let s = state.read();
+    let settings = ChannelSettings {
+        balance: s.balance,
+        channel_swap: s.channel_swap,
+        mono: s.mono,
+    };
+    (StatusCode::OK, Json(settings)).into_response()
+}
+
+#[axum::debug_handler]
+pub async fn set_channels_handler(
+    State(registry): State<Arc<MediaRendererRegistry>>,
+    Path(instance_id): Path<String>,
+    Json(req): Json<ChannelSettingsRequest>,
+) -> impl IntoResponse {
+    let Some(instance) = registry.get_instance(&instance_id) else {
+        return StatusCode::NOT_FOUND.into_response();
+    };
+    let (balance, channel_swap) = {
+        let s = instance.state.read();
+        (
+            req.balance.unwrap_or(s.balance),
+            req.channel_swap.unwrap_or(s.channel_swap),
+        )
+    };
+    tracing::info!(instance_id = %instance_id, balance, channel_swap, "WebRenderer: channel settings");
+    instance.pipeline.set_channel_mix(balance, channel_swap);
+    if let Some(mono) = req.mono {
+        instance.pipeline.set_mono(mono);
+    }
+
+    let s = instance.state.read();
+    let settings = ChannelSettings {
+        balance: s.balance,
+        channel_swap: s.channel_swap,
+        mono: s.mono,
+    };
+    (StatusCode::OK, Json(settings)).into_response()
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct CrossfeedSettings {
+    /// Type de sortie : "speakers" ou "headphones"
+    pub output: String,
+    /// Crossfeed demandé (effectif seulement sur une sortie casque)
+    pub enabled: bool,
+    /// Crossfeed effectivement appliqué
+    pub active: bool,
+    /// Fréquence de coupure (Hz)
+    pub cutoff_hz: u32,
+    /// Niveau du canal croisé (dB)
+    pub feed_db: f32,
+}
+
+#[derive(Debug, Deserialize)]
+pub struct CrossfeedSettingsRequest {
+    pub output: Option<String>,
+    pub enabled: Option<bool>,
+    pub cutoff_hz: Option<u32>,
+    pub feed_db: Option<f32>,
+}
+
+fn crossfeed_settings(instance: &MediaRendererInstance) -> CrossfeedSettings {
+    let s = instance.state.read();
+    let params = instance.pipeline.crossfeed.params();
+    CrossfeedSettings {
+        output: s.output_profile.as_str().to_string(),
+        enabled: s.crossfeed,
+        active: instance.pipeline.crossfeed.enabled(),
+        cutoff_hz: params.cutoff_hz,
+        feed_db: params.feed_db,
+    }
+}
+
+#[axum::debug_handler]
+pub async fn get_crossfeed_handler(
+    State(registry): State<Arc<MediaRendererRegistry>>,
+    Path(instance_id): Path<String>,
+) -> impl IntoResponse {
+    let Some(instance) = registry.get_instance(&instance_id) else {
+        return StatusCode::NOT_FOUND.into_response();
+    };
+    (StatusCode::OK, Json(crossfeed_settings(&instance))).into_response()
+}
+
+#[axum::debug_handler]
+pub async fn set_crossfeed_handler(
+    State(registry): State<Arc<MediaRendererRegistry>>,
+    Path(instance_id): Path<String>,
+    Json(req): Json<CrossfeedSettingsRequest>,
+) -> impl IntoResponse {
+    let Some(instance) = registry.get_instance(&instance_id) else {
+        return StatusCode::NOT_FOUND.into_response();
+    };
+    let profile = match req.output.as_deref() {
+        Some(output) => match OutputProfile::parse(output) {
+            Some(profile) => profile,
+            None => return StatusCode::BAD_REQUEST.into_response(),
+        },
+        None => instance.state.read().output_profile,
+    };
+    let enabled = req.enabled.unwrap_or_else(|| instance.state.read().crossfeed);
+    let current = instance.pipeline.crossfeed.params();
+    let params = CrossfeedParams {
+        cutoff_hz: req.cutoff_hz.unwrap_or(current.cutoff_hz),
+        feed_db: req.feed_db.unwrap_or(current.feed_db),
+    };
+    tracing::info!(
+        instance_id = %instance_id,
+        output = profile.as_str(),
+        enabled,
+        cutoff_hz = params.cutoff_hz,
+        feed_db = params.feed_db,
+        "WebRenderer: crossfeed settings"
+    );
+    instance.pipeline.set_crossfeed(profile, enabled, params);
+
+    (StatusCode::OK, Json(crossfeed_settings(&instance))).into_response()
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct RecorderStatus {
+    /// Enregistrement en cours
+    pub recording: bool,
+    /// Fichier en cours d'écriture
+    pub file: Option<String>,
+}
+
+#[derive(Debug, Deserialize)]
+pub struct RecorderRequest {
+    pub recording: bool,
+}
+
+fn recorder_status(instance: &MediaRendererInstance) -> RecorderStatus {
+    let recorder = &instance.pipeline.recorder;
+    RecorderStatus {
+        recording: recorder.is_recording(),
+        file: recorder
+            .current_file()
+            .map(|p| p.to_string_lossy().into_owned()),
+    }
+}
+
+#[axum::debug_handler]
+pub async fn get_recorder_handler(
+    State(registry): State<Arc<MediaRendererRegistry>>,
+    Path(instance_id): Path<String>,
+) -> impl IntoResponse {
+    let Some(instance) = registry.get_instance(&instance_id) else {
+        return StatusCode::NOT_FOUND.into_response();
+    };
+    (StatusCode::OK, Json(recorder_status(&instance))).into_response()
+}
+
+#[axum::debug_handler]
+pub async fn set_recorder_handler(
+    State(registry): State<Arc<MediaRendererRegistry>>,
+    Path(instance_id): Path<String>,
+    Json(req): Json<RecorderRequest>,
+) -> impl IntoResponse {
+    let Some(instance) = registry.get_instance(&instance_id) else {
+        return StatusCode::NOT_FOUND.into_response();
+    };
+    tracing::info!(instance_id = %instance_id, recording = req.recording, "WebRenderer: recorder");
+    instance.pipeline.set_recording(req.recording);
+    (StatusCode::OK, Json(recorder_status(&instance))).into_response()
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct KeepaliveSettings {
+    /// Silence dithéré émis à l'arrêt et en pause
+    pub keepalive: bool,
+}
+
+#[axum::debug_handler]
+pub async fn get_keepalive_handler(
+    State(registry): State<Arc<MediaRendererRegistry>>,
+    Path(instance_id): Path<String>,
+) -> impl IntoResponse {
+    let Some(instance) = registry.get_instance(&instance_id) else {
+        return StatusCode::NOT_FOUND.into_response();
+    };
+    let keepalive = instance.pipeline.player.keepalive();
+    (StatusCode::OK, Json(KeepaliveSettings { keepalive })).into_response()
+}
+
+#[axum::debug_handler]
+pub async fn set_keepalive_handler(
+    State(registry): State<Arc<MediaRendererRegistry>>,
+    Path(instance_id): Path<String>,
+    Json(req): Json<KeepaliveSettings>,
+) -> impl IntoResponse {
+    let Some(instance) = registry.get_instance(&instance_id) else {
+        return StatusCode::NOT_FOUND.into_response();
+    };
+    tracing::info!(instance_id = %instance_id, keepalive = req.keepalive, "WebRenderer: keepalive");
+    instance.pipeline.set_keepalive(req.keepalive);
+    (StatusCode::OK, Json(req)).into_response()
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct BitPerfectSettings {
+    /// Rééchantillonnage, volume et traitements contournés
+    pub bit_perfect: bool,
+}
+
+#[axum::debug_handler]
+pub async fn get_bit_perfect_handler(
+    State(registry): State<Arc<MediaRendererRegistry>>,
+    Path(instance_id): Path<String>,
+) -> impl IntoResponse {
+    let Some(instance) = registry.get_instance(&instance_id) else {
+        return StatusCode::NOT_FOUND.into_response();
+    };
+    let bit_perfect = instance.pipeline.graph.is_bit_perfect();
+    (StatusCode::OK, Json(BitPerfectSettings { bit_perfect })).into_response()
+}
+
+#[axum::debug_handler]
+pub async fn set_bit_perfect_handler(
+    State(registry): State<Arc<MediaRendererRegistry>>,
+    Path(instance_id): Path<String>,
+    Json(req): Json<BitPerfectSettings>,
+) -> impl IntoResponse {
+    let Some(instance) = registry.get_instance(&instance_id) else {
+        return StatusCode::NOT_FOUND.into_response();
+    };
+    tracing::info!(instance_id = %instance_id, bit_perfect = req.bit_perfect, "WebRenderer: bit-perfect");
+    instance.pipeline.set_bit_perfect(req.bit_perfect).await;
+    (StatusCode::OK, Json(req)).into_response()
+}
+
+#[derive(Debug, Serialize, Deserialize)]
+pub struct AnnounceStatus {
+    /// Annonce en cours de mixage
+    pub playing: bool,
+    /// Annonces en attente
+    pub pending: usize,
+    /// Atténuation de la musique pendant une annonce (dB)
+    pub duck_db: f32,
+    /// Durée des rampes de ducking (ms)
+    pub ramp_ms: u32,
+}
+
+/// Annonce à jouer : un clip préenregistré ou un texte à synthétiser
+#[derive(Debug, Deserialize)]
+pub struct AnnounceRequest {
+    pub clip: Option<String>,
+    pub text: Option<String>,
+}
+
+fn announce_status(instance: &MediaRendererInstance) -> AnnounceStatus {
+    let announce = &instance.pipeline.announce;
+    AnnounceStatus {
+        playing: announce.is_playing(),
+        pending: announce.pending(),
+        duck_db: announce.duck_db(),
+        ramp_ms: announce.ramp_ms(),
+    }
+}
+
+fn announce_error_response(error: MediaRendererError) -> axum::response::Response {
+    let status = match error {
+        MediaRendererError::InvalidArgument(_) => StatusCode::BAD_REQUEST,
+        _ => StatusCode::UNPROCESSABLE_ENTITY,
+    };
+    (status, error.to_string()).into_response()
+}
+
+#[axum::debug_handler]
+pub async fn get_announce_handler(
+    State(registry): State<Arc<MediaRendererRegistry>>,
+    Path(instance_id): Path<String>,
+) -> impl IntoResponse {
+    let Some(instance) = registry.get_instance(&instance_id) else {
+        return StatusCode::NOT_FOUND.into_response();
+    };
+    (StatusCode::OK, Json(announce_status(&instance))).into_response()
+}
+
+#[axum::debug_handler]
+pub async fn announce_handler(
+    State(registry): State<Arc<MediaRendererRegistry>>,
+    Path(instance_id): Path<String>,
+    Json(req): Json<AnnounceRequest>,
+) -> impl IntoResponse {
+    let Some(instance) = registry.get_instance(&instance_id) else {
+        return StatusCode::NOT_FOUND.into_response();
+    };
+    // Le mixage se fait dans le flux : rien n'est entendu hors lecture
+    if !matches!(instance.state.read().playback_state, PlaybackState::Playing) {
+        return (StatusCode::CONFLICT, "renderer is not playing").into_response();
+    }
+
+    let clip = match (req.clip.as_deref(), req.text.as_deref()) {
+        (Some(name), None) => pmomediarenderer::announce::load_clip(name),
+        (None, Some(text)) => pmomediarenderer::announce::synthesize(text).await,
+        _ => {
+            return (StatusCode::BAD_REQUEST, "expected either 'clip' or 'text'").into_response();
+        }
+    };
+    let position = match clip.and_then(|clip| instance.pipeline.announce(clip)) {
+        Ok(position) => position,
+        Err(e) => {
+            tracing::warn!(instance_id = %instance_id, "WebRenderer: announcement rejected: {}", e);
+            return announce_error_response(e);
+        }
+    };
+    tracing::info!(instance_id = %instance_id, position, "WebRenderer: announcement queued");
+    (StatusCode::ACCEPTED, Json(announce_status(&instance))).into_response()
+}
+
+#[axum::debug_handler]
+pub async fn cancel_announce_handler(
+    State(registry): State<Arc<MediaRendererRegistry>>,
+    Path(instance_id): Path<String>,
+) -> impl IntoResponse {
+    let Some(instance) = registry.get_instance(&instance_id) else {
+        return StatusCode::NOT_FOUND.into_response();
+    };
+    instance.pipeline.announce.cancel();
+    (StatusCode::OK, Json(announce_status(&instance))).into_response()
+}
@@ -0,0 +1,76 @@
+//! État du rééchantillonnage des flux WebRenderer
+//!
+//! Routes :
+//! - `GET /api/webrenderer/resampler` : toutes les instances
+//! - `GET /api/webrenderer/{id}/resampler` : une instance
+//!
+//! Pour chaque flux : fréquences d'entrée et de sortie, ratio, qualité
+//! libsoxr et frames cumulées depuis la construction du graphe. L'écart
+//! entre frames émises et attendues (`drift_frames`) doit rester stable ;
+//! s'il dérive, des échantillons sont perdus ou dupliqués.
+
+use axum::{
+    Json,
+    extract::{Path, State},
+    http::StatusCode,
+    response::IntoResponse,
+};
+use serde::Serialize;
+use std::sync::Arc;
+
+use pmomediarenderer::{MediaRendererInstance, MediaRendererRegistry, ResamplingStats};
+
+#[derive(Debug, Serialize)]
+pub struct ResamplerStatus {
+    pub instance_id: String,
+    pub udn: String,
+    /// Rééchantillonnage contourné (mode bit-perfect)
+    pub bit_perfect: bool,
+    /// Fréquence du flux décodé (Hz, 0 avant le premier chunk)
+    pub source_hz: u32,
+    /// Fréquence en sortie du resampler (Hz)
+    pub target_hz: u32,
+    pub ratio: f64,
+    /// Qualité libsoxr, absente si le flux passe sans conversion
+    pub quality: Option<&'static str>,
+    pub frames_in: u64,
+    pub frames_out: u64,
+    pub drift_frames: f64,
+}
+
+fn resampler_status(instance: &MediaRendererInstance) -> ResamplerStatus {
+    let stats: ResamplingStats = instance.pipeline.resampling.stats();
+    ResamplerStatus {
+        instance_id: instance.instance_id.clone(),
+        udn: instance.udn.clone(),
+        bit_perfect: instance.pipeline.graph.is_bit_perfect(),
+        source_hz: stats.source_hz,
+        target_hz: stats.target_hz,
+        ratio: stats.ratio(),
+        quality: stats.quality,
+        frames_in: stats.frames_in,
+        frames_out: stats.frames_out,
+        drift_frames: stats.drift_frames(),
+    }
+}
+
+pub async fn resampler_list_handler(
+    State(registry): State<Arc<MediaRendererRegistry>>,
+) -> impl IntoResponse {
+    let entries: Vec<ResamplerStatus> = registry
+        .instances()
+        .iter()
+        .map(|instance| resampler_status(instance))
+        .collect();
+    Json(entries)
+}
+
+pub async fn resampler_handler(
+    State(registry): State<Arc<MediaRendererRegistry>>,
+    Path(instance_id): Path<String>,
+) -> impl IntoResponse {
+    let Some(instance) = registry.get_instance(&instance_id) else {
+        return StatusCode::NOT_FOUND.into_response();
+    };
+    (StatusCode::OK, Json(resampler_status(&instance))).into_response()
+}